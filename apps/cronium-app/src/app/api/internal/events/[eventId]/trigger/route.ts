@@ -0,0 +1,84 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { storage } from "@/server/storage";
+import { jobService } from "@/lib/services/job-service";
+import { buildJobPayload } from "@/lib/scheduler/job-payload-builder";
+import { EventType, JobType, LogStatus } from "@/shared/schema";
+
+interface TriggerRequest {
+  source?: string;
+  trigger?: string;
+  input?: Record<string, unknown>;
+}
+
+// Queue a job for an event fired by an orchestrator-side trigger (a watched
+// directory, a message queue subscription or a webhook)
+export async function POST(
+  request: NextRequest,
+  { params }: { params: { eventId: string } },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const eventId = parseInt(params.eventId);
+    if (isNaN(eventId)) {
+      return NextResponse.json({ error: "Invalid event ID" }, { status: 400 });
+    }
+
+    const body = (await request.json()) as TriggerRequest;
+
+    const event = await storage.getEventWithRelations(eventId);
+    if (!event) {
+      return NextResponse.json({ error: "Event not found" }, { status: 404 });
+    }
+
+    // Create log entry for tracking
+    const log = await storage.createLog({
+      eventId: event.id,
+      status: LogStatus.PENDING,
+      startTime: new Date(),
+      eventName: event.name ?? "Unknown",
+      eventType: event.type,
+      userId: event.userId,
+    });
+
+    const jobPayload = buildJobPayload(event, log.id, body.input ?? {});
+
+    let jobType: (typeof JobType)[keyof typeof JobType];
+    if (event.type === EventType.HTTP_REQUEST) {
+      jobType = JobType.HTTP_REQUEST;
+    } else if (event.toolActionConfig) {
+      jobType = JobType.TOOL_ACTION;
+    } else {
+      jobType = JobType.SCRIPT;
+    }
+
+    const job = await jobService.createJob({
+      eventId: event.id,
+      userId: String(event.userId),
+      type: jobType,
+      payload: jobPayload,
+      metadata: {
+        eventName: event.name ?? "Unknown",
+        triggeredBy: body.source ?? "trigger",
+        trigger: body.trigger,
+        orchestratorId: request.headers.get("x-orchestrator-id") ?? undefined,
+        logId: log.id,
+      },
+    });
+
+    return NextResponse.json({ jobId: job.id, logId: log.id });
+  } catch (error) {
+    console.error("Error triggering event:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
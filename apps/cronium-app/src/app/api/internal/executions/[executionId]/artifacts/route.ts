@@ -0,0 +1,66 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { executionService } from "@/lib/services/execution-service";
+
+// Record a file uploaded by a script against its execution. The runtime
+// keeps the content; the execution only lists where to find it.
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ executionId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { executionId } = await params;
+    const body = (await request.json()) as {
+      id: string;
+      name: string;
+      size: number;
+      mimeType?: string;
+      checksum?: string;
+      ref?: { backend: string; key: string; size?: number };
+      createdAt?: string;
+    };
+
+    if (!body.id || !body.name) {
+      return NextResponse.json(
+        { error: "Artifact ID and name required" },
+        { status: 400 },
+      );
+    }
+
+    const execution = await executionService.appendMetadataItem(
+      executionId,
+      "artifacts",
+      {
+        id: body.id,
+        name: body.name,
+        size: body.size,
+        mimeType: body.mimeType,
+        checksum: body.checksum,
+        ref: body.ref,
+        createdAt: body.createdAt ?? new Date().toISOString(),
+      },
+    );
+    if (!execution) {
+      return NextResponse.json(
+        { error: "Execution not found" },
+        { status: 404 },
+      );
+    }
+
+    return NextResponse.json({ success: true });
+  } catch (error) {
+    console.error("Error registering artifact:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,77 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { executionService } from "@/lib/services/execution-service";
+
+interface HelperCallStats {
+  operation: string;
+  calls: number;
+  errors: number;
+  totalMs: number;
+  p50Ms: number;
+  p95Ms: number;
+}
+
+interface HelperStats {
+  calls: number;
+  errors: number;
+  totalMs: number;
+  operations: HelperCallStats[];
+  updatedAt: string;
+}
+
+// Attach the runtime's summary of an execution's helper calls to the
+// execution record. Each summary covers every call so far and replaces the
+// previous one; a summary older than the stored one is ignored.
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ executionId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { executionId } = await params;
+    const body = (await request.json()) as HelperStats;
+
+    if (typeof body.calls !== "number" || !Array.isArray(body.operations)) {
+      return NextResponse.json(
+        { error: "calls and operations are required" },
+        { status: 400 },
+      );
+    }
+
+    const execution = await executionService.getExecution(executionId);
+    if (!execution) {
+      return NextResponse.json(
+        { error: "Execution not found" },
+        { status: 404 },
+      );
+    }
+
+    const metadata = (execution.metadata as Record<string, unknown>) ?? {};
+    const current = metadata.helperStats as HelperStats | undefined;
+    if (
+      current &&
+      new Date(body.updatedAt).getTime() < new Date(current.updatedAt).getTime()
+    ) {
+      return NextResponse.json({ success: true, applied: false });
+    }
+
+    await executionService.updateExecution(executionId, {
+      metadata: { ...metadata, helperStats: body },
+    });
+
+    return NextResponse.json({ success: true, applied: true });
+  } catch (error) {
+    console.error("Error saving helper stats:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,138 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { db } from "@/server/db";
+import { storage } from "@/server/storage";
+import { jobService } from "@/lib/services/job-service";
+import { transformJobForRuntime } from "@/lib/services/job-transformer";
+import { buildJobPayload } from "@/lib/scheduler/job-payload-builder";
+import {
+  EventType,
+  JobType,
+  LogStatus,
+  jobs as jobsTable,
+} from "@/shared/schema";
+import { and, eq, sql } from "drizzle-orm";
+
+interface JobSubmission {
+  eventId: string;
+  userId: string;
+  parentExecutionId: string;
+  parentJobId?: string;
+  parentEventId: string;
+  rootExecutionId: string;
+  rootJobId?: string;
+  depth: number;
+  input?: unknown;
+  metadata?: Record<string, unknown>;
+  idempotencyKey: string;
+}
+
+// Queue a child job submitted by a running script through the runtime. The
+// child runs the requested event as the parent's user, which must own the
+// event or have it shared. A repeated submission with the same idempotency
+// key returns the job created the first time.
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ executionId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { executionId } = await params;
+    const body = (await request.json()) as JobSubmission;
+
+    if (body.parentExecutionId !== executionId) {
+      return NextResponse.json(
+        { error: "parentExecutionId does not match the execution" },
+        { status: 400 },
+      );
+    }
+    const eventId = parseInt(body.eventId, 10);
+    if (isNaN(eventId)) {
+      return NextResponse.json({ error: "Invalid event ID" }, { status: 400 });
+    }
+
+    if (body.idempotencyKey) {
+      const [existing] = await db
+        .select()
+        .from(jobsTable)
+        .where(
+          and(
+            eq(jobsTable.userId, body.userId),
+            sql`${jobsTable.metadata}->>'idempotencyKey' = ${body.idempotencyKey}`,
+          ),
+        )
+        .limit(1);
+      if (existing) {
+        return NextResponse.json(transformJobForRuntime(existing));
+      }
+    }
+
+    const event = await storage.getEventWithRelations(eventId);
+    if (!event || (event.userId !== body.userId && !event.shared)) {
+      return NextResponse.json({ error: "Event not found" }, { status: 404 });
+    }
+
+    // Create log entry for tracking
+    const log = await storage.createLog({
+      eventId: event.id,
+      status: LogStatus.PENDING,
+      startTime: new Date(),
+      eventName: event.name ?? "Unknown",
+      eventType: event.type,
+      userId: body.userId,
+    });
+
+    // Input that is not an object is passed to the child under "input"
+    const input =
+      typeof body.input === "object" && body.input !== null
+        ? (body.input as Record<string, unknown>)
+        : body.input !== undefined
+          ? { input: body.input }
+          : {};
+    const jobPayload = buildJobPayload(event, log.id, input);
+
+    let jobType: (typeof JobType)[keyof typeof JobType];
+    if (event.type === EventType.HTTP_REQUEST) {
+      jobType = JobType.HTTP_REQUEST;
+    } else if (event.toolActionConfig) {
+      jobType = JobType.TOOL_ACTION;
+    } else {
+      jobType = JobType.SCRIPT;
+    }
+
+    const job = await jobService.createJob({
+      eventId: event.id,
+      userId: body.userId,
+      type: jobType,
+      payload: jobPayload,
+      metadata: {
+        ...body.metadata,
+        eventName: event.name ?? "Unknown",
+        triggeredBy: "script",
+        parentExecutionId: body.parentExecutionId,
+        parentJobId: body.parentJobId,
+        parentEventId: body.parentEventId,
+        rootExecutionId: body.rootExecutionId,
+        rootJobId: body.rootJobId,
+        depth: body.depth,
+        idempotencyKey: body.idempotencyKey,
+        logId: log.id,
+      },
+    });
+
+    return NextResponse.json(transformJobForRuntime(job), { status: 201 });
+  } catch (error) {
+    console.error("Error submitting child job:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,52 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { executionService } from "@/lib/services/execution-service";
+
+// Record a script's progress report on its execution
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ executionId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { executionId } = await params;
+    const body = (await request.json()) as {
+      percentage: number;
+      message?: string;
+    };
+
+    const execution = await executionService.getExecution(executionId);
+    if (!execution) {
+      return NextResponse.json(
+        { error: "Execution not found" },
+        { status: 404 },
+      );
+    }
+
+    await executionService.updateExecution(executionId, {
+      metadata: {
+        ...(execution.metadata as Record<string, unknown>),
+        progress: {
+          percentage: body.percentage,
+          message: body.message,
+          updatedAt: new Date().toISOString(),
+        },
+      },
+    });
+
+    return NextResponse.json({ success: true });
+  } catch (error) {
+    console.error("Error saving progress:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,89 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { executionService } from "@/lib/services/execution-service";
+
+interface PartialResults {
+  sequence: number;
+  final: boolean;
+  output?: unknown;
+  variables: Record<string, unknown>;
+  updatedAt: string;
+}
+
+// Record a batch of partial results synced by the runtime while the script
+// runs, or mark them final once the script submitted its results. Batches
+// can arrive out of order: older sequences and anything after the final
+// marker are ignored.
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ executionId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { executionId } = await params;
+    const body = (await request.json()) as {
+      sequence: number;
+      partial: boolean;
+      output?: unknown;
+      variables?: Record<string, unknown>;
+      timestamp: string;
+    };
+
+    if (typeof body.sequence !== "number" || body.sequence < 1) {
+      return NextResponse.json(
+        { error: "sequence must be a positive number" },
+        { status: 400 },
+      );
+    }
+
+    const execution = await executionService.getExecution(executionId);
+    if (!execution) {
+      return NextResponse.json(
+        { error: "Execution not found" },
+        { status: 404 },
+      );
+    }
+
+    const metadata = (execution.metadata as Record<string, unknown>) ?? {};
+    const current = metadata.partialResults as PartialResults | undefined;
+    if (current && (current.final || body.sequence < current.sequence)) {
+      return NextResponse.json({ success: true, applied: false });
+    }
+
+    // The final results went through the regular output and variable
+    // routes; the partial preview is dropped
+    const next: PartialResults = body.partial
+      ? {
+          sequence: body.sequence,
+          final: false,
+          output: body.output !== undefined ? body.output : current?.output,
+          variables: { ...current?.variables, ...body.variables },
+          updatedAt: body.timestamp,
+        }
+      : {
+          sequence: body.sequence,
+          final: true,
+          variables: {},
+          updatedAt: body.timestamp,
+        };
+
+    await executionService.updateExecution(executionId, {
+      metadata: { ...metadata, partialResults: next },
+    });
+
+    return NextResponse.json({ success: true, applied: true });
+  } catch (error) {
+    console.error("Error saving partial results:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,96 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { approvalService } from "@/lib/services/approval-service";
+import { jobService } from "@/lib/services/job-service";
+
+// Open an approval request for a held job and return its current decision
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ jobId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { jobId } = await params;
+    const body = (await request.json()) as {
+      orchestratorId: string;
+      message?: string;
+      approvers?: string[];
+      expiresAt: string;
+      timestamp: string;
+    };
+
+    const expiresAt = new Date(body.expiresAt);
+    if (!body.orchestratorId || isNaN(expiresAt.getTime())) {
+      return NextResponse.json(
+        { error: "orchestratorId and expiresAt required" },
+        { status: 400 },
+      );
+    }
+
+    const job = await jobService.getJob(jobId);
+    if (!job) {
+      return NextResponse.json({ error: "Job not found" }, { status: 404 });
+    }
+    if (job.orchestratorId !== body.orchestratorId) {
+      return NextResponse.json(
+        { error: "Job is not claimed by this orchestrator" },
+        { status: 409 },
+      );
+    }
+
+    const approval = await approvalService.request(jobId, {
+      orchestratorId: body.orchestratorId,
+      message: body.message,
+      approvers: body.approvers,
+      expiresAt,
+    });
+
+    return NextResponse.json(approvalService.toDecision(approval));
+  } catch (error) {
+    console.error("Error requesting approval:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
+
+// Get the current decision on a job's approval request
+export async function GET(
+  request: NextRequest,
+  { params }: { params: Promise<{ jobId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { jobId } = await params;
+    const approval = await approvalService.get(jobId);
+    if (!approval) {
+      return NextResponse.json(
+        { error: "No approval request for this job" },
+        { status: 404 },
+      );
+    }
+
+    return NextResponse.json(approvalService.toDecision(approval));
+  } catch (error) {
+    console.error("Error getting approval:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
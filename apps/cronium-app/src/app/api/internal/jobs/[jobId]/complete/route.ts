@@ -29,6 +29,9 @@ export async function POST(
       metrics?: Record<string, unknown>;
       scriptOutput?: unknown; // Data from cronium.output()
       condition?: boolean; // Condition from cronium.setCondition()
+      receipt?: Record<string, unknown>; // Signed execution receipt
+      sensitiveDataDetected?: string[]; // Detectors that masked output
+      error?: Record<string, unknown>; // Failure details, incl. retryable
       timestamp: string;
     };
 
@@ -130,7 +133,7 @@ export async function POST(
       updateData.metrics = body.metrics;
     }
 
-    // Store scriptOutput and condition in the result field
+    // Store scriptOutput, condition and the receipt in the result field
     const result: Record<string, unknown> = {};
     if (body.scriptOutput !== undefined) {
       result.scriptOutput = body.scriptOutput;
@@ -144,6 +147,15 @@ export async function POST(
     if (body.metrics !== undefined) {
       result.metrics = body.metrics;
     }
+    if (body.receipt !== undefined) {
+      result.receipt = body.receipt;
+    }
+    if (body.sensitiveDataDetected?.length) {
+      result.sensitiveDataDetected = body.sensitiveDataDetected;
+    }
+    if (body.error !== undefined) {
+      result.error = body.error;
+    }
 
     // Only add result if we have data to store
     if (Object.keys(result).length > 0) {
@@ -0,0 +1,77 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { jobService } from "@/lib/services/job-service";
+import { orchestratorService } from "@/lib/services/orchestrator-service";
+
+// Hand an unstarted job over to a peer orchestrator
+export async function POST(
+  request: NextRequest,
+  { params }: { params: Promise<{ jobId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { jobId } = await params;
+    const body = (await request.json()) as {
+      fromOrchestratorId: string;
+      targetOrchestratorId: string;
+      reason?: string;
+      timestamp: string;
+    };
+
+    if (!jobId) {
+      return NextResponse.json({ error: "Job ID required" }, { status: 400 });
+    }
+
+    if (!body.fromOrchestratorId || !body.targetOrchestratorId) {
+      return NextResponse.json(
+        { error: "Source and target orchestrator IDs required" },
+        { status: 400 },
+      );
+    }
+
+    // Only registered orchestrators can receive work
+    const target = await orchestratorService.getAgent(
+      body.targetOrchestratorId,
+    );
+    if (!target) {
+      return NextResponse.json(
+        { error: "Target orchestrator not registered" },
+        { status: 404 },
+      );
+    }
+
+    const job = await jobService.getJob(jobId);
+    if (!job) {
+      return NextResponse.json({ error: "Job not found" }, { status: 404 });
+    }
+
+    // Only the orchestrator holding an unstarted job can hand it over
+    const updatedJob = await jobService.handoffJob(
+      jobId,
+      body.fromOrchestratorId,
+      body.targetOrchestratorId,
+      body.reason ?? "",
+    );
+    if (!updatedJob) {
+      return NextResponse.json(
+        { error: "Job not held unstarted by this orchestrator" },
+        { status: 409 },
+      );
+    }
+
+    return NextResponse.json({ success: true, job: updatedJob });
+  } catch (error) {
+    console.error("Error handing off job:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -0,0 +1,37 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { jobService } from "@/lib/services/job-service";
+import { executionService } from "@/lib/services/execution-service";
+import { transformJobForRuntime } from "@/lib/services/job-transformer";
+
+// Get a job with its status, and its output once it has finished, for the
+// runtime to report a child job to the script that submitted it
+export async function GET(
+  request: NextRequest,
+  { params }: { params: Promise<{ jobId: string }> },
+) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const { jobId } = await params;
+    const job = await jobService.getJob(jobId);
+    if (!job) {
+      return NextResponse.json({ error: "Job not found" }, { status: 404 });
+    }
+
+    const execution = await executionService.getExecutionByJobId(jobId);
+    return NextResponse.json(transformJobForRuntime(job, execution?.id));
+  } catch (error) {
+    console.error("Error fetching job:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
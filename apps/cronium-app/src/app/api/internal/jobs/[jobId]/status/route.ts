@@ -1,7 +1,7 @@
 import type { NextRequest } from "next/server";
 import { NextResponse } from "next/server";
 import { jobService } from "@/lib/services/job-service";
-import { JobStatus } from "@shared/schema";
+import { JobHoldStatus, JobStatus } from "@shared/schema";
 
 // Update job status
 export async function PUT(
@@ -19,7 +19,7 @@ export async function PUT(
 
     const { jobId } = await params;
     const body = (await request.json()) as {
-      status: JobStatus;
+      status: JobStatus | JobHoldStatus;
       timestamp: string;
       details?: {
         message?: string;
@@ -39,7 +39,19 @@ export async function PUT(
     // Update job status and log status using the new method
     let updatedJob;
     switch (body.status) {
+      case JobHoldStatus.WAITING:
+      case JobHoldStatus.AWAITING_APPROVAL: {
+        const hold: { status: string; message?: string } = {
+          status: body.status,
+        };
+        if (body.details?.message !== undefined) {
+          hold.message = body.details.message;
+        }
+        updatedJob = await jobService.setHold(jobId, hold);
+        break;
+      }
       case JobStatus.RUNNING:
+        await jobService.setHold(jobId, null);
         updatedJob = await jobService.updateJobStatus(
           jobId,
           JobStatus.RUNNING,
@@ -73,6 +85,14 @@ export async function PUT(
           exitCode: body.details?.exitCode ?? 1,
         });
         break;
+      case JobStatus.CANCELLED:
+        updatedJob = await jobService.updateJob(jobId, {
+          status: JobStatus.CANCELLED,
+          completedAt: new Date(),
+          lastError:
+            body.details?.error ?? body.details?.message ?? "Cancelled",
+        });
+        break;
       default:
         return NextResponse.json(
           { error: "Invalid status transition" },
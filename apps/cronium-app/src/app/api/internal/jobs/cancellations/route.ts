@@ -0,0 +1,73 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { jobService } from "@/lib/services/job-service";
+
+// Window returned to an orchestrator asking for the first time
+const DEFAULT_WINDOW_MS = 5 * 60 * 1000;
+// Cancellations committing while the query runs can be older than its
+// start, so the next poll starts a little earlier; orchestrators ignore
+// cancellations they have handled
+const OVERLAP_MS = 10 * 1000;
+
+// List the jobs of an orchestrator cancelled since its last poll, for
+// orchestrators without a push channel
+export async function GET(request: NextRequest) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const searchParams = request.nextUrl.searchParams;
+    const orchestratorId = searchParams.get("orchestratorId");
+
+    if (!orchestratorId) {
+      return NextResponse.json(
+        { error: "orchestratorId parameter required" },
+        { status: 400 },
+      );
+    }
+
+    const now = new Date();
+    const sinceParam = searchParams.get("since");
+    const since = sinceParam
+      ? new Date(sinceParam)
+      : new Date(now.getTime() - DEFAULT_WINDOW_MS);
+    if (isNaN(since.getTime())) {
+      return NextResponse.json(
+        { error: "since must be a timestamp" },
+        { status: 400 },
+      );
+    }
+
+    const cancelled = await jobService.listCancellations(
+      orchestratorId,
+      since,
+      now,
+    );
+
+    return NextResponse.json({
+      cancellations: cancelled.map((job) => {
+        const metadata = (job.metadata ?? {}) as Record<string, unknown>;
+        return {
+          jobId: job.id,
+          reason:
+            typeof metadata.cancelReason === "string"
+              ? metadata.cancelReason
+              : undefined,
+          cancelledAt: (job.completedAt ?? now).toISOString(),
+        };
+      }),
+      asOf: new Date(now.getTime() - OVERLAP_MS).toISOString(),
+    });
+  } catch (error) {
+    console.error("Error listing cancelled jobs:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
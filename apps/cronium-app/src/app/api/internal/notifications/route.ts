@@ -0,0 +1,120 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import { db } from "@/server/db";
+import { events, users } from "@/shared/schema";
+import { eq } from "drizzle-orm";
+import { sendEmail } from "@/lib/email";
+
+const severities = ["info", "warning", "critical"] as const;
+
+type NotificationSeverity = (typeof severities)[number];
+
+interface OrchestratorNotification {
+  type: string;
+  severity: NotificationSeverity;
+  title: string;
+  message: string;
+  eventId?: string;
+  jobId?: string;
+  orchestratorId: string;
+  details?: Record<string, unknown>;
+  timestamp: string;
+}
+
+// Deliver a notification raised by an orchestrator, such as an event being
+// quarantined after repeated failures, to the owner of the event by email.
+// Notifications without an event, or whose owner has no email address, are
+// only logged.
+export async function POST(request: NextRequest) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const body = (await request.json()) as OrchestratorNotification;
+
+    if (!body.type || !body.title || !body.message) {
+      return NextResponse.json(
+        { error: "type, title and message are required" },
+        { status: 400 },
+      );
+    }
+    if (!severities.includes(body.severity)) {
+      return NextResponse.json(
+        { error: `severity must be one of ${severities.join(", ")}` },
+        { status: 400 },
+      );
+    }
+
+    console.log("Orchestrator notification:", {
+      type: body.type,
+      severity: body.severity,
+      title: body.title,
+      eventId: body.eventId,
+      jobId: body.jobId,
+      orchestratorId: body.orchestratorId,
+      timestamp: body.timestamp,
+    });
+
+    const eventId = body.eventId ? parseInt(body.eventId, 10) : NaN;
+    if (isNaN(eventId)) {
+      return NextResponse.json({ success: true, delivered: false });
+    }
+
+    const [owner] = await db
+      .select({ email: users.email, eventName: events.name })
+      .from(events)
+      .innerJoin(users, eq(events.userId, users.id))
+      .where(eq(events.id, eventId))
+      .limit(1);
+
+    if (!owner) {
+      return NextResponse.json({ error: "Event not found" }, { status: 404 });
+    }
+    if (!owner.email) {
+      return NextResponse.json({ success: true, delivered: false });
+    }
+
+    const details = Object.entries(body.details ?? {})
+      .map(([key, value]) => `${key}: ${JSON.stringify(value)}`)
+      .join("\n");
+    const text = [
+      body.message,
+      "",
+      `Event: ${owner.eventName}`,
+      body.jobId ? `Job: ${body.jobId}` : "",
+      `Orchestrator: ${body.orchestratorId}`,
+      `Time: ${body.timestamp}`,
+      details ? `\n${details}` : "",
+    ]
+      .filter((line) => line !== "")
+      .join("\n");
+
+    const delivered = await sendEmail({
+      to: owner.email,
+      subject: `[${body.severity.toUpperCase()}] ${body.title}`,
+      text,
+      html: `<pre>${escapeHtml(text)}</pre>`,
+    });
+
+    return NextResponse.json({ success: true, delivered });
+  } catch (error) {
+    console.error("Error delivering notification:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
+
+function escapeHtml(value: string): string {
+  return value
+    .replace(/&/g, "&amp;")
+    .replace(/</g, "&lt;")
+    .replace(/>/g, "&gt;")
+    .replace(/"/g, "&quot;");
+}
@@ -0,0 +1,63 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import {
+  type AgentRegistration,
+  orchestratorService,
+} from "@/lib/services/orchestrator-service";
+
+// Register an orchestrator's capacity and return its peers in the region
+export async function POST(request: NextRequest) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const body = (await request.json()) as AgentRegistration & {
+      timestamp: string;
+    };
+
+    if (!body.orchestratorId) {
+      return NextResponse.json(
+        { error: "Orchestrator ID required" },
+        { status: 400 },
+      );
+    }
+
+    const registration: AgentRegistration = {
+      orchestratorId: body.orchestratorId,
+      region: body.region ?? "",
+      maxConcurrent: body.maxConcurrent ?? 0,
+      activeJobs: body.activeJobs ?? 0,
+      pendingJobs: body.pendingJobs ?? 0,
+      workStealing: body.workStealing ?? false,
+    };
+    await orchestratorService.register(registration);
+
+    const peers = await orchestratorService.getPeers(
+      registration.orchestratorId,
+      registration.region,
+    );
+
+    return NextResponse.json({
+      peers: peers.map((peer) => ({
+        orchestratorId: peer.id,
+        region: peer.region,
+        maxConcurrent: peer.maxConcurrent,
+        activeJobs: peer.activeJobs,
+        pendingJobs: peer.pendingJobs,
+        workStealing: peer.workStealing,
+        lastSeen: peer.lastSeen.toISOString(),
+      })),
+    });
+  } catch (error) {
+    console.error("Error registering orchestrator:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
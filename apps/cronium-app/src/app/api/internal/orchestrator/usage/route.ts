@@ -0,0 +1,55 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+import {
+  type UsageReport,
+  orchestratorService,
+} from "@/lib/services/orchestrator-service";
+
+// Store the resource usage an orchestrator's jobs used in a period
+export async function POST(request: NextRequest) {
+  try {
+    // Verify internal API token
+    const authHeader = request.headers.get("authorization");
+    const token = authHeader?.replace("Bearer ", "");
+
+    if (!token || token !== process.env.INTERNAL_API_KEY) {
+      return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+    }
+
+    const body = (await request.json()) as UsageReport;
+
+    if (!body.orchestratorId) {
+      return NextResponse.json(
+        { error: "Orchestrator ID required" },
+        { status: 400 },
+      );
+    }
+
+    const periodStart = new Date(body.periodStart);
+    const periodEnd = new Date(body.periodEnd);
+    if (
+      isNaN(periodStart.getTime()) ||
+      isNaN(periodEnd.getTime()) ||
+      periodEnd < periodStart
+    ) {
+      return NextResponse.json(
+        { error: "Valid periodStart and periodEnd required" },
+        { status: 400 },
+      );
+    }
+
+    if (!Array.isArray(body.entries)) {
+      return NextResponse.json({ error: "Entries required" }, { status: 400 });
+    }
+
+    const stored = await orchestratorService.recordUsage(body);
+
+    return NextResponse.json({ success: true, stored });
+  } catch (error) {
+    console.error("Error storing usage report:", error);
+    return NextResponse.json(
+      { error: "Internal server error" },
+      { status: 500 },
+    );
+  }
+}
@@ -24,6 +24,7 @@ export async function GET(
       .select({
         key: userVariables.key,
         value: userVariables.value,
+        sensitive: userVariables.sensitive,
         updatedAt: userVariables.updatedAt,
       })
       .from(userVariables)
@@ -48,6 +49,7 @@ export async function GET(
     return NextResponse.json({
       key: varData.key,
       value: varData.value,
+      sensitive: varData.sensitive,
       updatedAt: varData.updatedAt,
     });
   } catch (error) {
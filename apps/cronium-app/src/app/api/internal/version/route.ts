@@ -0,0 +1,22 @@
+import type { NextRequest } from "next/server";
+import { NextResponse } from "next/server";
+
+// Version of the internal API contract used by orchestrators. Bump it when a
+// change to the internal routes breaks older orchestrators.
+const CONTRACT_VERSION = 1;
+
+// Version handshake performed by orchestrators at startup
+export async function GET(request: NextRequest) {
+  // Verify internal API token
+  const authHeader = request.headers.get("authorization");
+  const token = authHeader?.replace("Bearer ", "");
+
+  if (!token || token !== process.env.INTERNAL_API_KEY) {
+    return NextResponse.json({ error: "Unauthorized" }, { status: 401 });
+  }
+
+  return NextResponse.json({
+    contractVersion: CONTRACT_VERSION,
+    version: process.env.npm_package_version ?? "unknown",
+  });
+}
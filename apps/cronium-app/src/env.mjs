@@ -22,6 +22,7 @@ export const env = createEnv({
       .default("http://orchestrator:8080"),
     VALKEY_URL: z.string().optional(),
     REDIS_URL: z.string().optional(),
+    SCHEDULER_CRON_JITTER_SECONDS: z.coerce.number().int().min(0).default(0),
   },
   client: {
     PUBLIC_APP_URL: z.string().url(),
@@ -42,6 +43,7 @@ export const env = createEnv({
     ORCHESTRATOR_URL: process.env.ORCHESTRATOR_URL,
     VALKEY_URL: process.env.VALKEY_URL,
     REDIS_URL: process.env.REDIS_URL,
+    SCHEDULER_CRON_JITTER_SECONDS: process.env.SCHEDULER_CRON_JITTER_SECONDS,
   },
   skipValidation: !!process.env.SKIP_ENV_VALIDATION,
   emptyStringAsUndefined: true,
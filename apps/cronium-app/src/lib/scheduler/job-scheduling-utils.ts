@@ -1,10 +1,38 @@
 import { parseExpression } from "cron-parser";
+import { env } from "@/env.mjs";
 import { jobService } from "@/lib/services/job-service";
 import type { CreateJobInput } from "@/lib/services/job-service";
 import { storage } from "@/server/storage";
 import type { EventWithRelations } from "@/server/storage";
 import { TimeUnit } from "@/shared/schema";
 
+/**
+ * Delay a cron firing by a fixed per-event offset so events sharing a
+ * schedule (every "0 * * * *" job, say) spread across the minute instead of
+ * all firing at once. The offset never exceeds half the gap to the following
+ * firing, so the schedule cannot skip a run.
+ */
+function applyCronJitter(eventId: number, next: Date, following: Date): Date {
+  const maxOffset = env.SCHEDULER_CRON_JITTER_SECONDS * 1000;
+  if (maxOffset <= 0) {
+    return next;
+  }
+  const cap = Math.min(maxOffset, (following.getTime() - next.getTime()) / 2);
+  return new Date(next.getTime() + Math.floor(cap * stableFraction(eventId)));
+}
+
+/**
+ * Map an event ID to a stable value in [0, 1) (FNV-1a)
+ */
+function stableFraction(eventId: number): number {
+  let hash = 0x811c9dc5;
+  for (const char of String(eventId)) {
+    hash ^= char.charCodeAt(0);
+    hash = Math.imul(hash, 0x01000193);
+  }
+  return (hash >>> 0) / 0x100000000;
+}
+
 /**
  * Calculate the next execution time for a scheduled event
  */
@@ -17,7 +45,8 @@ export function calculateNextExecutionTime(
       const interval = parseExpression(String(event.customSchedule), {
         currentDate: new Date(),
       });
-      return interval.next().toDate();
+      const next = interval.next().toDate();
+      return applyCronJitter(event.id, next, interval.next().toDate());
     }
 
     // For simple schedules, calculate based on last run
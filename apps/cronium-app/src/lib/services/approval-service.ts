@@ -0,0 +1,158 @@
+import { db } from "@server/db";
+import {
+  ApprovalStatus,
+  type JobApproval,
+  UserRole,
+  jobApprovals as approvalsTable,
+  jobs as jobsTable,
+} from "@shared/schema";
+import { and, eq } from "drizzle-orm";
+
+export interface ApprovalRequest {
+  orchestratorId: string;
+  message?: string;
+  approvers?: string[];
+  expiresAt: Date;
+}
+
+// The decision an orchestrator polls for
+export interface ApprovalDecision {
+  status: ApprovalStatus;
+  decidedBy?: string;
+  comment?: string;
+  decidedAt?: string;
+}
+
+export interface Approver {
+  id: string;
+  email?: string | null;
+  role?: string | null;
+}
+
+export class ApprovalError extends Error {}
+
+// Approval requests of held jobs. Orchestrators open them and poll for the
+// decision; only users other than the job's owner may decide.
+export class ApprovalService {
+  private db = db;
+
+  /**
+   * Open an approval request for a job. A request that is already open is
+   * returned as it is, so a retried or resumed hold keeps its decision.
+   */
+  async request(jobId: string, req: ApprovalRequest): Promise<JobApproval> {
+    await this.db
+      .insert(approvalsTable)
+      .values({
+        jobId,
+        orchestratorId: req.orchestratorId,
+        message: req.message ?? null,
+        approvers: req.approvers ?? [],
+        expiresAt: req.expiresAt,
+      })
+      .onConflictDoNothing();
+
+    const approval = await this.get(jobId);
+    if (!approval) {
+      throw new Error(`approval request for job ${jobId} was not stored`);
+    }
+    return approval;
+  }
+
+  /**
+   * Get a job's approval request, expiring it once its time has passed
+   */
+  async get(jobId: string): Promise<JobApproval | null> {
+    const [approval] = await this.db
+      .select()
+      .from(approvalsTable)
+      .where(eq(approvalsTable.jobId, jobId))
+      .limit(1);
+    if (!approval) {
+      return null;
+    }
+
+    if (
+      approval.status === ApprovalStatus.PENDING &&
+      approval.expiresAt.getTime() <= Date.now()
+    ) {
+      const [expired] = await this.db
+        .update(approvalsTable)
+        .set({ status: ApprovalStatus.EXPIRED, decidedAt: new Date() })
+        .where(
+          and(
+            eq(approvalsTable.jobId, jobId),
+            eq(approvalsTable.status, ApprovalStatus.PENDING),
+          ),
+        )
+        .returning();
+      return expired ?? this.get(jobId);
+    }
+    return approval;
+  }
+
+  /**
+   * Approve or reject a pending request. The job's owner can never decide,
+   * and when the job names approvers the user must be one of them by ID or
+   * email; otherwise only admins can decide.
+   */
+  async decide(
+    jobId: string,
+    user: Approver,
+    approve: boolean,
+    comment?: string,
+  ): Promise<JobApproval> {
+    const [job] = await this.db
+      .select({ userId: jobsTable.userId })
+      .from(jobsTable)
+      .where(eq(jobsTable.id, jobId))
+      .limit(1);
+    const approval = await this.get(jobId);
+    if (!job || !approval) {
+      throw new ApprovalError("No approval request for this job");
+    }
+
+    if (job.userId === user.id) {
+      throw new ApprovalError("The job's owner cannot decide its approval");
+    }
+    const named =
+      approval.approvers.includes(user.id) ||
+      (!!user.email && approval.approvers.includes(user.email));
+    if (approval.approvers.length > 0 ? !named : user.role !== UserRole.ADMIN) {
+      throw new ApprovalError("Not an approver of this job");
+    }
+
+    const [decided] = await this.db
+      .update(approvalsTable)
+      .set({
+        status: approve ? ApprovalStatus.APPROVED : ApprovalStatus.REJECTED,
+        decidedBy: user.email ?? user.id,
+        comment: comment ?? null,
+        decidedAt: new Date(),
+      })
+      .where(
+        and(
+          eq(approvalsTable.jobId, jobId),
+          eq(approvalsTable.status, ApprovalStatus.PENDING),
+        ),
+      )
+      .returning();
+    if (!decided) {
+      throw new ApprovalError(`Approval is already ${approval.status}`);
+    }
+    return decided;
+  }
+
+  /**
+   * Convert a request to the decision reported to orchestrators
+   */
+  toDecision(approval: JobApproval): ApprovalDecision {
+    const decision: ApprovalDecision = { status: approval.status };
+    if (approval.decidedBy) decision.decidedBy = approval.decidedBy;
+    if (approval.comment) decision.comment = approval.comment;
+    if (approval.decidedAt) decision.decidedAt = approval.decidedAt.toISOString();
+    return decision;
+  }
+}
+
+export const approvalService = new ApprovalService();
@@ -5,13 +5,52 @@ import {
 } from "./job-transformer";
 import { transformSSHJobForOrchestrator } from "./ssh-job-transformer";
 import { storage } from "@/server/storage";
+import { db } from "@/server/db";
+import { userVariables } from "@/shared/schema";
+import { and, eq } from "drizzle-orm";
 
 /**
- * Enhanced job transformer that handles multi-server SSH jobs
+ * Enhanced job transformer that handles multi-server SSH jobs and lists the
+ * user's sensitive variables
  */
 export async function enhancedTransformJobForOrchestrator(
   job: Job,
 ): Promise<OrchestratorJob> {
+  const transformedJob = await transformWithServers(job);
+
+  // The orchestrator only caches variables when it has this list; without
+  // it any variable may be sensitive
+  try {
+    transformedJob.execution.sensitiveVariables = await sensitiveVariableKeys(
+      job.userId,
+    );
+  } catch (error) {
+    console.error(
+      `Error listing sensitive variables for job ${job.id}:`,
+      error,
+    );
+  }
+
+  return transformedJob;
+}
+
+/**
+ * Keys of the user's variables flagged sensitive
+ */
+async function sensitiveVariableKeys(userId: string): Promise<string[]> {
+  const rows = await db
+    .select({ key: userVariables.key })
+    .from(userVariables)
+    .where(
+      and(eq(userVariables.userId, userId), eq(userVariables.sensitive, true)),
+    );
+  return rows.map((row) => row.key);
+}
+
+/**
+ * Transform a job, adding the server details of SSH jobs
+ */
+async function transformWithServers(job: Job): Promise<OrchestratorJob> {
   // Get base transformation
   let transformedJob = transformJobForOrchestrator(job);
 
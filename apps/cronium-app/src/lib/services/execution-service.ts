@@ -5,7 +5,7 @@ import {
   JobStatus,
   executions as executionsTable,
 } from "@shared/schema";
-import { eq, and, desc, isNull } from "drizzle-orm";
+import { eq, and, desc, isNull, sql } from "drizzle-orm";
 
 export interface CreateExecutionInput {
   id: string; // Format: exec-${jobId}-${timestamp}
@@ -176,6 +176,34 @@ export class ExecutionService {
     });
   }
 
+  /**
+   * Append an item to a list in the execution's metadata. An item whose id
+   * is already in the list is not added again, so retried requests are safe.
+   * The append happens in one statement so concurrent appends are not lost.
+   */
+  async appendMetadataItem(
+    executionId: string,
+    key: string,
+    item: { id: string } & Record<string, unknown>,
+  ): Promise<Execution | null> {
+    const list = sql`coalesce(${executionsTable.metadata}->${key}, '[]'::jsonb)`;
+    const [updated] = await this.db
+      .update(executionsTable)
+      .set({
+        metadata: sql`jsonb_set(${executionsTable.metadata}, ARRAY[${key}]::text[], ${list} || jsonb_build_array(${JSON.stringify(item)}::jsonb))`,
+        updatedAt: new Date(),
+      })
+      .where(
+        and(
+          eq(executionsTable.id, executionId),
+          sql`NOT ${list} @> ${JSON.stringify([{ id: item.id }])}::jsonb`,
+        ),
+      )
+      .returning();
+
+    return updated ?? this.getExecution(executionId);
+  }
+
   /**
    * Get active executions (running or queued)
    */
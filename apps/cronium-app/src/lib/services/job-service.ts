@@ -8,7 +8,7 @@ import {
   jobs as jobsTable,
   LogStatus,
 } from "@shared/schema";
-import { eq, desc, and, or, isNull, lte, gte } from "drizzle-orm";
+import { eq, desc, and, or, isNull, lte, gte, sql } from "drizzle-orm";
 import { customAlphabet } from "nanoid";
 
 // Use only alphanumeric characters for job IDs to avoid issues with dashes
@@ -70,8 +70,13 @@ export interface UpdateJobInput {
   };
   attempts?: number;
   lastError?: string;
+  metadata?: Record<string, unknown>;
 }
 
+// How long a handed-off job is reserved for its target orchestrator before
+// any orchestrator may claim it again
+const HANDOFF_RESERVATION_SECONDS = 60;
+
 export interface JobFilter {
   status?: JobStatus | JobStatus[];
   orchestratorId?: string;
@@ -223,6 +228,7 @@ export class JobService {
     if ("result" in update) updateData.result = update.result;
     if ("attempts" in update) updateData.attempts = update.attempts;
     if ("lastError" in update) updateData.lastError = update.lastError;
+    if ("metadata" in update) updateData.metadata = update.metadata;
 
     const [updated] = await this.db
       .update(jobsTable)
@@ -246,6 +252,11 @@ export class JobService {
       eq(jobsTable.status, JobStatus.QUEUED),
       isNull(jobsTable.orchestratorId),
       lte(jobsTable.scheduledFor, new Date()),
+      // Jobs handed off to a peer are reserved for it for a while
+      sql`(${jobsTable.metadata}->'handoff'->>'to' IS NULL
+        OR ${jobsTable.metadata}->'handoff'->>'to' = ${orchestratorId}
+        OR (${jobsTable.metadata}->'handoff'->>'at')::timestamptz
+          < now() - make_interval(secs => ${HANDOFF_RESERVATION_SECONDS}))`,
     ];
 
     if (jobTypes && jobTypes.length > 0) {
@@ -298,6 +309,65 @@ export class JobService {
       );
   }
 
+  /**
+   * Hand an acknowledged but unstarted job over to another orchestrator. The
+   * job goes back to the queue, reserved for the target's next poll.
+   */
+  async handoffJob(
+    jobId: string,
+    fromOrchestratorId: string,
+    targetOrchestratorId: string,
+    reason: string,
+  ): Promise<Job | null> {
+    const [updated] = await this.db
+      .update(jobsTable)
+      .set({
+        status: JobStatus.QUEUED,
+        orchestratorId: null,
+        metadata: sql`${jobsTable.metadata} || ${JSON.stringify({
+          handoff: {
+            from: fromOrchestratorId,
+            to: targetOrchestratorId,
+            reason,
+            at: new Date().toISOString(),
+          },
+        })}::jsonb`,
+        updatedAt: new Date(),
+      })
+      .where(
+        and(
+          eq(jobsTable.id, jobId),
+          eq(jobsTable.orchestratorId, fromOrchestratorId),
+          eq(jobsTable.status, JobStatus.CLAIMED),
+        ),
+      )
+      .returning();
+
+    return updated ?? null;
+  }
+
+  /**
+   * Record what a claimed job is held for before it runs, or clear the hold
+   * with null
+   */
+  async setHold(
+    jobId: string,
+    hold: { status: string; message?: string } | null,
+  ): Promise<Job | null> {
+    const metadata = hold
+      ? sql`coalesce(${jobsTable.metadata}, '{}'::jsonb) || ${JSON.stringify({
+          hold: { ...hold, since: new Date().toISOString() },
+        })}::jsonb`
+      : sql`coalesce(${jobsTable.metadata}, '{}'::jsonb) - 'hold'`;
+    const [updated] = await this.db
+      .update(jobsTable)
+      .set({ metadata, updatedAt: new Date() })
+      .where(eq(jobsTable.id, jobId))
+      .returning();
+
+    return updated ?? null;
+  }
+
   /**
    * Mark a job as started
    */
@@ -362,6 +432,31 @@ export class JobService {
     });
   }
 
+  /**
+   * List the jobs claimed by an orchestrator that were cancelled between
+   * since and until, so it can stop those still running
+   */
+  async listCancellations(
+    orchestratorId: string,
+    since: Date,
+    until: Date,
+    limit = 500,
+  ): Promise<Job[]> {
+    return this.db
+      .select()
+      .from(jobsTable)
+      .where(
+        and(
+          eq(jobsTable.orchestratorId, orchestratorId),
+          eq(jobsTable.status, JobStatus.CANCELLED),
+          gte(jobsTable.completedAt, since),
+          lte(jobsTable.completedAt, until),
+        ),
+      )
+      .orderBy(jobsTable.completedAt)
+      .limit(limit);
+  }
+
   /**
    * Get job statistics
    */
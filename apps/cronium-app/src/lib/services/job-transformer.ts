@@ -16,6 +16,8 @@ export interface OrchestratorJob {
     timeout: number;
     inputData: Record<string, unknown>;
     variables: Record<string, unknown>;
+    // Keys of variables that must not be cached in plaintext
+    sensitiveVariables?: string[];
     target: {
       type: string;
       serverId?: string;
@@ -214,3 +216,42 @@ export function transformJobForOrchestrator(job: Job): OrchestratorJob {
 export function transformJobsForOrchestrator(jobs: Job[]): OrchestratorJob[] {
   return jobs.map(transformJobForOrchestrator);
 }
+
+/**
+ * A child job as reported to the runtime for the script that submitted it
+ */
+export interface RuntimeJob {
+  id: string;
+  eventId: string;
+  parentExecutionId: string;
+  executionId?: string;
+  status: string;
+  output?: unknown;
+  error?: string;
+  createdAt: Date;
+  completedAt?: Date;
+}
+
+/**
+ * Transform a job for the runtime's child job API, with the ID of its
+ * latest execution when it has one
+ */
+export function transformJobForRuntime(
+  job: Job,
+  executionId?: string,
+): RuntimeJob {
+  const metadata = (job.metadata as Record<string, unknown>) ?? {};
+  const result = (job.result as { output?: unknown; error?: string }) ?? {};
+
+  return {
+    id: job.id,
+    eventId: String(job.eventId),
+    parentExecutionId: String(metadata.parentExecutionId ?? ""),
+    executionId,
+    status: job.status,
+    output: result.output,
+    error: job.lastError ?? result.error,
+    createdAt: job.createdAt,
+    completedAt: job.completedAt ?? undefined,
+  };
+}
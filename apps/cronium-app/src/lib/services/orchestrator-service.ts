@@ -0,0 +1,145 @@
+import { db } from "@server/db";
+import {
+  type InsertOrchestratorUsage,
+  type OrchestratorAgent,
+  orchestratorAgents as agentsTable,
+  orchestratorUsage as usageTable,
+} from "@shared/schema";
+import { and, eq, gte, ne, sql } from "drizzle-orm";
+
+// Orchestrators that have not registered for this long are no longer
+// offered as peers
+const PEER_STALE_AFTER_MS = 2 * 60 * 1000;
+
+export interface AgentRegistration {
+  orchestratorId: string;
+  region: string;
+  maxConcurrent: number;
+  activeJobs: number;
+  pendingJobs: number;
+  workStealing: boolean;
+}
+
+export interface UsageEntry {
+  tenant: string;
+  eventId?: string;
+  executions: number;
+  failures: number;
+  durationSeconds: number;
+  cpuSeconds: number;
+  memoryGbHours: number;
+  transferBytes: number;
+}
+
+export interface UsageReport {
+  orchestratorId: string;
+  periodStart: string;
+  periodEnd: string;
+  entries: UsageEntry[];
+}
+
+// Registry of running orchestrators and their capacity, used for work
+// stealing between peers of a region
+export class OrchestratorService {
+  private db = db;
+
+  /**
+   * Record an orchestrator's capacity, creating it on first registration
+   */
+  async register(reg: AgentRegistration): Promise<void> {
+    const values = {
+      region: reg.region,
+      maxConcurrent: reg.maxConcurrent,
+      activeJobs: reg.activeJobs,
+      pendingJobs: reg.pendingJobs,
+      workStealing: reg.workStealing,
+      lastSeen: new Date(),
+    };
+
+    await this.db
+      .insert(agentsTable)
+      .values({ id: reg.orchestratorId, ...values })
+      .onConflictDoUpdate({ target: agentsTable.id, set: values });
+  }
+
+  /**
+   * List the other orchestrators of a region that registered recently
+   */
+  async getPeers(
+    orchestratorId: string,
+    region: string,
+  ): Promise<OrchestratorAgent[]> {
+    return this.db
+      .select()
+      .from(agentsTable)
+      .where(
+        and(
+          eq(agentsTable.region, region),
+          ne(agentsTable.id, orchestratorId),
+          gte(agentsTable.lastSeen, new Date(Date.now() - PEER_STALE_AFTER_MS)),
+        ),
+      );
+  }
+
+  /**
+   * Store a usage report. Orchestrators resend reports the backend did not
+   * acknowledge, so an entry already stored for the period is replaced.
+   */
+  async recordUsage(report: UsageReport): Promise<number> {
+    const periodStart = new Date(report.periodStart);
+    const periodEnd = new Date(report.periodEnd);
+    const rows: InsertOrchestratorUsage[] = report.entries.map((entry) => ({
+      orchestratorId: report.orchestratorId,
+      periodStart,
+      periodEnd,
+      tenant: entry.tenant ?? "",
+      eventId: entry.eventId ?? "",
+      executions: entry.executions ?? 0,
+      failures: entry.failures ?? 0,
+      durationSeconds: entry.durationSeconds ?? 0,
+      cpuSeconds: entry.cpuSeconds ?? 0,
+      memoryGbHours: entry.memoryGbHours ?? 0,
+      transferBytes: entry.transferBytes ?? 0,
+    }));
+    if (rows.length === 0) {
+      return 0;
+    }
+
+    await this.db
+      .insert(usageTable)
+      .values(rows)
+      .onConflictDoUpdate({
+        target: [
+          usageTable.orchestratorId,
+          usageTable.periodStart,
+          usageTable.tenant,
+          usageTable.eventId,
+        ],
+        set: {
+          periodEnd: sql`excluded.period_end`,
+          executions: sql`excluded.executions`,
+          failures: sql`excluded.failures`,
+          durationSeconds: sql`excluded.duration_seconds`,
+          cpuSeconds: sql`excluded.cpu_seconds`,
+          memoryGbHours: sql`excluded.memory_gb_hours`,
+          transferBytes: sql`excluded.transfer_bytes`,
+        },
+      });
+    return rows.length;
+  }
+
+  /**
+   * Get a registered orchestrator
+   */
+  async getAgent(orchestratorId: string): Promise<OrchestratorAgent | null> {
+    const [agent] = await this.db
+      .select()
+      .from(agentsTable)
+      .where(eq(agentsTable.id, orchestratorId))
+      .limit(1);
+
+    return agent ?? null;
+  }
+}
+
+export const orchestratorService = new OrchestratorService();
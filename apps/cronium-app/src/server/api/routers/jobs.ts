@@ -17,6 +17,10 @@ import {
   createPaginatedResult,
 } from "@/server/utils/db-patterns";
 import { jobService } from "@/lib/services/job-service";
+import {
+  ApprovalError,
+  approvalService,
+} from "@/lib/services/approval-service";
 import { JobStatus, logs } from "@/shared/schema";
 import { db } from "@/server/db";
 import { eq } from "drizzle-orm";
@@ -49,6 +53,12 @@ const cancelJobSchema = z.object({
   jobId: z.string(),
 });
 
+const decideApprovalSchema = z.object({
+  jobId: z.string(),
+  approve: z.boolean(),
+  comment: z.string().max(1000).optional(),
+});
+
 const getJobLogsSchema = z.object({
   jobId: z.string(),
   limit: z.number().min(1).max(1000).default(100),
@@ -197,6 +207,43 @@ export const jobsRouter = createTRPCRouter({
       );
     }),
 
+  // Approve or reject a job held for approval. The service refuses the
+  // job's owner and users who are not among its approvers.
+  decideApproval: jobProcedure
+    .input(decideApprovalSchema)
+    .mutation(async ({ ctx, input }) => {
+      return withErrorHandling(
+        async () => {
+          try {
+            const approval = await approvalService.decide(
+              input.jobId,
+              {
+                id: ctx.session.user.id,
+                email: ctx.session.user.email,
+                role: ctx.session.user.role,
+              },
+              input.approve,
+              input.comment,
+            );
+            return mutationResponse(
+              approvalService.toDecision(approval),
+              input.approve ? "Job approved" : "Job rejected",
+            );
+          } catch (error) {
+            if (error instanceof ApprovalError) {
+              throw new TRPCError({ code: "FORBIDDEN", message: error.message });
+            }
+            throw error;
+          }
+        },
+        {
+          component: "jobsRouter",
+          operationName: "decideApproval",
+          userId: ctx.session.user.id,
+        },
+      );
+    }),
+
   // Get job logs
   logs: jobProcedure.input(getJobLogsSchema).query(async ({ ctx, input }) => {
     return withErrorHandling(
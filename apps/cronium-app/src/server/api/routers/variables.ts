@@ -117,6 +117,7 @@ export const variablesRouter = createTRPCRouter({
           key: input.key,
           value: input.value,
           description: input.description ?? null,
+          sensitive: input.sensitive ?? false,
         });
 
         return variable;
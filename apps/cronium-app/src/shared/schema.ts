@@ -9,6 +9,8 @@ import {
   boolean,
   jsonb,
   unique,
+  doublePrecision,
+  bigint,
 } from "drizzle-orm/pg-core";
 
 // Enums
@@ -115,6 +117,13 @@ export enum JobStatus {
   CANCELLED = "cancelled",
 }
 
+// Reported by an orchestrator while it holds a claimed job before running it.
+// The job stays claimed; the hold is kept in its metadata.
+export enum JobHoldStatus {
+  WAITING = "waiting",
+  AWAITING_APPROVAL = "awaiting_approval",
+}
+
 export enum JobPriority {
   LOW = 0,
   NORMAL = 1,
@@ -594,6 +603,9 @@ export const userVariables = pgTable(
     key: varchar("key", { length: 255 }).notNull(),
     value: text("value").notNull(),
     description: text("description"),
+    // Sensitive values are never cached in plaintext by the orchestrator or
+    // the runtime
+    sensitive: boolean("sensitive").default(false).notNull(),
     createdAt: timestamp("created_at").defaultNow().notNull(),
     updatedAt: timestamp("updated_at").defaultNow().notNull(),
   },
@@ -1443,3 +1455,79 @@ export type RunnerPayload = typeof runnerPayloads.$inferSelect;
 export type InsertRunnerPayload = typeof runnerPayloads.$inferInsert;
 export type RunnerDeployment = typeof runnerDeployments.$inferSelect;
 export type InsertRunnerDeployment = typeof runnerDeployments.$inferInsert;
+
+// Orchestrator tables
+export const orchestratorAgents = pgTable("orchestrator_agents", {
+  id: varchar("id", { length: 255 }).primaryKey(), // Orchestrator ID
+  region: varchar("region", { length: 100 }).notNull().default(""),
+  maxConcurrent: integer("max_concurrent").notNull().default(0),
+  activeJobs: integer("active_jobs").notNull().default(0),
+  pendingJobs: integer("pending_jobs").notNull().default(0),
+  workStealing: boolean("work_stealing").notNull().default(false),
+  lastSeen: timestamp("last_seen").notNull().defaultNow(),
+});
+
+export type OrchestratorAgent = typeof orchestratorAgents.$inferSelect;
+export type InsertOrchestratorAgent = typeof orchestratorAgents.$inferInsert;
+
+// Resource usage orchestrators report per period, tenant and event for
+// chargeback. A report sent again replaces its earlier rows.
+export const orchestratorUsage = pgTable(
+  "orchestrator_usage",
+  {
+    id: serial("id").primaryKey(),
+    orchestratorId: varchar("orchestrator_id", { length: 255 }).notNull(),
+    periodStart: timestamp("period_start").notNull(),
+    periodEnd: timestamp("period_end").notNull(),
+    tenant: varchar("tenant", { length: 255 }).notNull().default(""),
+    eventId: varchar("event_id", { length: 255 }).notNull().default(""),
+    executions: integer("executions").notNull().default(0),
+    failures: integer("failures").notNull().default(0),
+    durationSeconds: doublePrecision("duration_seconds").notNull().default(0),
+    cpuSeconds: doublePrecision("cpu_seconds").notNull().default(0),
+    memoryGbHours: doublePrecision("memory_gb_hours").notNull().default(0),
+    transferBytes: bigint("transfer_bytes", { mode: "number" })
+      .notNull()
+      .default(0),
+    createdAt: timestamp("created_at").notNull().defaultNow(),
+  },
+  (table) => ({
+    uniquePeriodEntry: unique("unique_orchestrator_usage_entry").on(
+      table.orchestratorId,
+      table.periodStart,
+      table.tenant,
+      table.eventId,
+    ),
+  }),
+);
+
+export type OrchestratorUsage = typeof orchestratorUsage.$inferSelect;
+export type InsertOrchestratorUsage = typeof orchestratorUsage.$inferInsert;
+
+// Approval requests of jobs held until a human decides
+export enum ApprovalStatus {
+  PENDING = "pending",
+  APPROVED = "approved",
+  REJECTED = "rejected",
+  EXPIRED = "expired",
+}
+
+export const jobApprovals = pgTable("job_approvals", {
+  jobId: varchar("job_id", { length: 50 })
+    .primaryKey()
+    .references(() => jobs.id, { onDelete: "cascade" }),
+  status: varchar("status", { length: 20 })
+    .$type<ApprovalStatus>()
+    .notNull()
+    .default(ApprovalStatus.PENDING),
+  message: text("message"),
+  approvers: jsonb("approvers").$type<string[]>().notNull().default([]),
+  orchestratorId: varchar("orchestrator_id", { length: 255 }).notNull(),
+  expiresAt: timestamp("expires_at").notNull(),
+  decidedBy: varchar("decided_by", { length: 255 }),
+  comment: text("comment"),
+  decidedAt: timestamp("decided_at"),
+  createdAt: timestamp("created_at").notNull().defaultNow(),
+});
+
+export type JobApproval = typeof jobApprovals.$inferSelect;
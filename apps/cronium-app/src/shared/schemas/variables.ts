@@ -25,6 +25,7 @@ export const createUserVariableSchema = z
       .string()
       .max(500, "Description must be less than 500 characters")
       .optional(),
+    sensitive: z.boolean().optional(),
   })
   .refine(
     (data) => {
@@ -54,6 +55,7 @@ export const updateUserVariableSchema = z
       .optional(),
     value: z.string().max(10000).optional(),
     description: z.string().max(500).optional(),
+    sensitive: z.boolean().optional(),
   })
   .refine(
     (data) => {
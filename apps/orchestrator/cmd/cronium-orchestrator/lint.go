@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/lint"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFormat string
+	lintStrict bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <spec.yaml>...",
+	Short: "Check job and event specification files",
+	Long: `Checks job and event specification files (YAML or JSON) against the job
+types, executor rules and configured resource limits: script type, target,
+resources, environment variable names, cron schedule, parameters, matrix,
+gates and sandbox profile.
+
+Diagnostics are printed as file:line:column: severity: message [code], or as a
+JSON array with --format json. The command exits non-zero when an error is
+found, or a warning with --strict.
+
+Limits and sandbox profiles come from the configuration as for the agent, but
+API credentials are not needed.`,
+	Args: cobra.MinimumNArgs(1),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Only limits and profiles are needed, so API settings may be missing
+		var err error
+		cfg, err = config.Read(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		return nil
+	},
+	RunE: runLint,
+}
+
+func init() {
+	lintCmd.Flags().StringVar(&lintFormat, "format", "text", "output format (text or json)")
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "fail on warnings too")
+
+	rootCmd.AddCommand(lintCmd)
+}
+
+func runLint(cmd *cobra.Command, args []string) error {
+	if lintFormat != "text" && lintFormat != "json" {
+		return fmt.Errorf("unsupported format %q (text or json)", lintFormat)
+	}
+
+	linter, err := lint.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create linter: %w", err)
+	}
+
+	diagnostics := []lint.Diagnostic{}
+	for _, path := range args {
+		diagnostics = append(diagnostics, linter.LintFile(path)...)
+	}
+
+	errorCount, warningCount := 0, 0
+	for _, d := range diagnostics {
+		if d.Severity == lint.SeverityError {
+			errorCount++
+		} else {
+			warningCount++
+		}
+	}
+
+	if lintFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diagnostics); err != nil {
+			return err
+		}
+	} else {
+		for _, d := range diagnostics {
+			fmt.Println(d)
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) checked: %d error(s), %d warning(s)\n", len(args), errorCount, warningCount)
+	}
+
+	if errorCount > 0 || (lintStrict && warningCount > 0) {
+		// The diagnostics are the report; only the summary goes to main
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+		return fmt.Errorf("lint found %d error(s) and %d warning(s)", errorCount, warningCount)
+	}
+	return nil
+}
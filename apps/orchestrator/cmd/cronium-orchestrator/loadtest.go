@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/ssh"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/loadtest"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	loadtestJobs        int
+	loadtestType        string
+	loadtestConcurrency int
+	loadtestDuration    time.Duration
+	loadtestServerID    string
+	loadtestTimeout     time.Duration
+	loadtestJSON        bool
+)
+
+var loadtestCmd = &cobra.Command{
+	Use:   "loadtest",
+	Short: "Run synthetic jobs through the executors and report throughput",
+	Long: `Runs synthetic jobs through the real execution pipeline and reports
+throughput, setup, run, cleanup and total latency percentiles, the resource
+usage of the jobs and of the orchestrator itself. Each job prints a marker and
+sleeps for --duration, so setup is measured apart from the script's run time.
+
+Container jobs run locally; SSH jobs run on the server given with --server.
+Load test executions are not recorded in the backend. The command fails when
+any job failed, so it can guard executor changes in CI.`,
+	Example: `  cronium-orchestrator loadtest --jobs 500 --type container --concurrency 20
+  cronium-orchestrator loadtest --jobs 100 --type ssh --server srv_1 --duration 2s --json`,
+	Args: cobra.NoArgs,
+	RunE: runLoadtest,
+}
+
+func init() {
+	loadtestCmd.Flags().IntVar(&loadtestJobs, "jobs", 100, "number of synthetic jobs")
+	loadtestCmd.Flags().StringVar(&loadtestType, "type", string(types.JobTypeContainer), "job type: container or ssh")
+	loadtestCmd.Flags().IntVar(&loadtestConcurrency, "concurrency", 10, "jobs run at once")
+	loadtestCmd.Flags().DurationVar(&loadtestDuration, "duration", 0, "how long each job sleeps (0 for a no-op)")
+	loadtestCmd.Flags().StringVar(&loadtestServerID, "server", "", "server ID for ssh jobs")
+	loadtestCmd.Flags().DurationVar(&loadtestTimeout, "timeout", 5*time.Minute, "maximum duration of each job")
+	loadtestCmd.Flags().BoolVar(&loadtestJSON, "json", false, "print the report as JSON")
+
+	rootCmd.AddCommand(loadtestCmd)
+}
+
+func runLoadtest(cmd *cobra.Command, args []string) error {
+	if loadtestJobs < 1 || loadtestConcurrency < 1 {
+		return fmt.Errorf("--jobs and --concurrency must be at least 1")
+	}
+	if loadtestTimeout <= 0 || loadtestDuration < 0 {
+		return fmt.Errorf("--timeout must be positive and --duration not negative")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		executor executors.Executor
+		jobType  types.JobType
+		target   types.Target
+		name     string
+		err      error
+	)
+
+	switch types.JobType(loadtestType) {
+	case types.JobTypeSSH:
+		if loadtestServerID == "" {
+			return fmt.Errorf("--server is required for ssh jobs")
+		}
+		apiClient, err := api.NewClient(cfg.API, log)
+		if err != nil {
+			return fmt.Errorf("failed to create API client: %w", err)
+		}
+		server, err := apiClient.GetServer(ctx, loadtestServerID)
+		if err != nil {
+			return err
+		}
+
+		// As with selftest, nothing is reported to the backend and helpers
+		// run in bundled mode
+		executor, err = ssh.NewExecutor(cfg.SSH, nil, "", 0, "", log)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH executor: %w", err)
+		}
+		serverID := server.ID
+		jobType = types.JobTypeSSH
+		target = types.Target{Type: types.TargetTypeServer, ServerID: &serverID, ServerDetails: server}
+		name = fmt.Sprintf("ssh:%s (%s)", server.Name, server.Host)
+	case types.JobTypeContainer:
+		executor, err = container.NewExecutor(cfg.Container, nil, log)
+		if err != nil {
+			return fmt.Errorf("failed to create container executor: %w", err)
+		}
+		jobType = types.JobTypeContainer
+		target = types.Target{Type: types.TargetTypeLocal}
+		name = "container:local"
+	default:
+		return fmt.Errorf("unsupported job type %q: use container or ssh", loadtestType)
+	}
+
+	newJob, err := loadtest.NewJobFactory(jobType, target, loadtestDuration)
+	if err != nil {
+		return err
+	}
+	opts := loadtest.Options{
+		Jobs:        loadtestJobs,
+		Concurrency: loadtestConcurrency,
+		Duration:    loadtestDuration,
+		Timeout:     loadtestTimeout,
+	}
+	if usage := cfg.Monitoring.ResourceUsage; usage.Enabled {
+		opts.SampleInterval = usage.SampleInterval
+	}
+
+	report := loadtest.Run(ctx, executor, newJob, name, opts, log)
+
+	if loadtestJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+	} else {
+		printLoadtestReport(report)
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d load test jobs failed", report.Failed, report.Succeeded+report.Failed)
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("load test interrupted")
+	}
+	return nil
+}
+
+// printLoadtestReport writes a human-readable report to stdout
+func printLoadtestReport(report *loadtest.Report) {
+	fmt.Printf("Load test on %s: %d jobs, %d at a time, %s each\n\n",
+		report.Target, report.Jobs, report.Concurrency, report.JobDuration)
+	fmt.Printf("Completed %d, failed %d in %s (%.2f jobs/s)\n\n",
+		report.Succeeded, report.Failed, report.Duration.Round(time.Millisecond), report.Throughput)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PHASE\tJOBS\tMEAN\tP50\tP95\tP99\tMAX")
+	for _, p := range report.Phases {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n", p.Name, p.Count,
+			p.Mean.Round(time.Millisecond), p.P50.Round(time.Millisecond), p.P95.Round(time.Millisecond),
+			p.P99.Round(time.Millisecond), p.Max.Round(time.Millisecond))
+	}
+	w.Flush()
+
+	fmt.Printf("\nJobs: %.2f CPU seconds, peak memory %.1f MB, %.1f MB network, %.1f MB disk (%d measured)\n",
+		report.Usage.CPUSeconds, float64(report.Usage.PeakMemory)/1e6,
+		float64(report.Usage.Network)/1e6, float64(report.Usage.Disk)/1e6, report.Usage.Measured)
+	fmt.Printf("Orchestrator: %.2f CPU seconds, max RSS %.1f MB, %d goroutines\n",
+		report.Orchestrator.CPUSeconds, float64(report.Orchestrator.MaxRSS)/1e6, report.Orchestrator.Goroutines)
+
+	if len(report.Errors) > 0 {
+		fmt.Println("\nFailures:")
+		for _, e := range report.Errors {
+			fmt.Printf("  %4d  %s\n", e.Count, e.Message)
+		}
+	}
+}
@@ -7,12 +7,18 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"runtime/debug"
 	"syscall"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/admin"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/health"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/jitter"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/metrics"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/agent"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
@@ -25,18 +31,30 @@ var (
 )
 
 var (
-	cfgFile string
-	cfg     *config.Config
-	log     *logrus.Logger
+	cfgFile    string
+	forceStart bool
+	cfg        *config.Config
+	log        *logrus.Logger
 )
 
 func main() {
+	defer redactPanic()
+
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", redact.String(err.Error()))
 		os.Exit(1)
 	}
 }
 
+// redactPanic prints a panic and its stack with registered secrets removed,
+// instead of the runtime's dump which would include them verbatim
+func redactPanic() {
+	if r := recover(); r != nil {
+		fmt.Fprintf(os.Stderr, "panic: %s\n\n%s", redact.String(fmt.Sprint(r)), redact.String(string(debug.Stack())))
+		os.Exit(2)
+	}
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "cronium-orchestrator",
 	Short: "Cronium orchestrator agent for secure job execution",
@@ -53,6 +71,9 @@ in isolated Docker containers with real-time log streaming and comprehensive mon
 			return fmt.Errorf("failed to load configuration: %w", err)
 		}
 
+		// Keep configured secrets out of logs, reports and error output
+		redact.Register(cfg)
+
 		// Configure logger with loaded config
 		logger.Configure(log, cfg.Logging)
 
@@ -63,6 +84,7 @@ in isolated Docker containers with real-time log streaming and comprehensive mon
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "config file (default is cronium-orchestrator.yaml)")
+	rootCmd.Flags().BoolVar(&forceStart, "force", false, "start even if the Docker daemon or backend version is incompatible")
 
 	// Add subcommands
 	rootCmd.AddCommand(versionCmd)
@@ -117,7 +139,8 @@ func runAgent(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Create health checker
-	healthChecker := health.NewChecker(cfg.Monitoring, log)
+	healthChecker := health.NewChecker(cfg.Monitoring, log).
+		WithJitter(jitter.New(cfg.Jitter, fmt.Sprintf("orchestrator-%s", cfg.Orchestrator.ID)), cfg.Jitter.Health)
 	go healthChecker.Start(ctx)
 
 	// Create and start health server
@@ -140,18 +163,63 @@ func runAgent(cmd *cobra.Command, args []string) error {
 		}()
 	}
 
+	// Keep a local metrics history for deployments nothing scrapes
+	go metrics.NewHistory(cfg.Monitoring.History, prometheus.DefaultGatherer, log).Run(ctx)
+
 	// Create and start the orchestrator
-	orch, err := NewSimpleOrchestrator(cfg, log)
+	orch, err := agent.New(cfg, agent.WithLogger(log))
 	if err != nil {
 		return fmt.Errorf("failed to create orchestrator: %w", err)
 	}
 
+	// Refuse to run against a Docker daemon or backend this build cannot use
+	if err := orch.CheckCompatibility(ctx); err != nil {
+		if !forceStart {
+			return fmt.Errorf("%w (use --force to start anyway)", err)
+		}
+		log.WithError(err).Warn("Starting despite incompatibility because --force was given")
+	}
+
+	// Create and start admin API server
+	adminServer := admin.NewServer(cfg.Admin, orch, log).
+		WithFeatures(orch.Features()).
+		WithSandbox(orch.SandboxProfiles()).
+		WithLogStreamer(orch.LogStreamer()).
+		WithLogLevels(logger.NewLevels(log)).
+		WithMetrics(orch.Metrics()).
+		WithConfig(cfg).
+		WithQuarantine(orch.Quarantine()).
+		WithJobTrees(orch).
+		WithJobMessenger(orch).
+		WithRunnerCache(orch.RunnerCache()).
+		WithDeadLetters(orch.DeadLetters())
+	healthChecker.WithFeatures(orch.Features()).WithDrainer(orch)
+	healthServer.WithJobLogs(orch.LogStreamer().Store(), cfg.Logging.Jobs.Token).
+		WithDrain(orch, cfg.Jobs.Drain.Token)
+	if cfg.Admin.Enabled {
+		go func() {
+			if err := adminServer.Start(); err != nil && err != http.ErrServerClosed {
+				log.WithError(err).Error("Admin API server failed")
+			}
+		}()
+	}
+
 	// Start orchestrator in background
 	orchDone := make(chan error, 1)
 	go func() {
 		orchDone <- orch.Run(ctx)
 	}()
 
+	// SIGUSR1 drains the orchestrator; Run returns once it is empty
+	drainChan := make(chan os.Signal, 1)
+	signal.Notify(drainChan, syscall.SIGUSR1)
+	go func() {
+		for range drainChan {
+			log.Info("Received drain signal")
+			orch.Drain()
+		}
+	}()
+
 	// Wait for shutdown signal or orchestrator error
 	select {
 	case sig := <-sigChan:
@@ -162,16 +230,7 @@ func runAgent(cmd *cobra.Command, args []string) error {
 		if err := <-orchDone; err != nil {
 			log.WithError(err).Error("Orchestrator shutdown error")
 		}
-
-		// Shutdown health server
-		if err := healthServer.Shutdown(context.Background()); err != nil {
-			log.WithError(err).Error("Failed to shutdown health server")
-		}
-
-		// Shutdown metrics server
-		if err := metricsServer.Shutdown(context.Background()); err != nil {
-			log.WithError(err).Error("Failed to shutdown metrics server")
-		}
+		shutdownServers(healthServer, metricsServer, adminServer)
 
 		log.Info("Cronium Agent stopped")
 		return nil
@@ -181,7 +240,30 @@ func runAgent(cmd *cobra.Command, args []string) error {
 			log.WithError(err).Error("Orchestrator failed")
 			return fmt.Errorf("orchestrator error: %w", err)
 		}
+		if orch.Draining() {
+			shutdownServers(healthServer, metricsServer, adminServer)
+			log.Info("Cronium Agent drained and stopped")
+			return nil
+		}
 		log.Info("Orchestrator stopped")
 		return nil
 	}
 }
+
+// shutdownServers stops the health, metrics and admin API servers
+func shutdownServers(healthServer *health.Server, metricsServer *metrics.Server, adminServer *admin.Server) {
+	// Shutdown health server
+	if err := healthServer.Shutdown(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to shutdown health server")
+	}
+
+	// Shutdown metrics server
+	if err := metricsServer.Shutdown(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to shutdown metrics server")
+	}
+
+	// Shutdown admin API server
+	if err := adminServer.Shutdown(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to shutdown admin API server")
+	}
+}
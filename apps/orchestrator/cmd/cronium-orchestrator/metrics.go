@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/metrics"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metricsDir    string
+	metricsSince  time.Duration
+	metricsUntil  time.Duration
+	metricsStep   time.Duration
+	metricsLabels []string
+	queryFormat   string
+	exportFormat  string
+	metricsOutput string
+)
+
+var metricsCmd = &cobra.Command{
+	Use:   "metrics",
+	Short: "Query the local metrics history",
+	Long: `Reads the metrics history kept in monitoring.history.dir when
+monitoring.history.enabled is set, for deployments without a Prometheus server.
+
+Recent days come from the raw samples, older days from the downsampled ones.
+Counters are stored as their running totals; histograms as their _sum and
+_count series.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Only the history directory is needed, so API settings may be missing
+		if metricsDir != "" {
+			return nil
+		}
+		c, err := config.Read(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		metricsDir = c.Monitoring.History.Dir
+		return nil
+	},
+}
+
+var metricsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the recorded series",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		points, err := readMetrics("")
+		if err != nil {
+			return err
+		}
+		counts := make(map[string]int)
+		for _, p := range points {
+			counts[p.Series()]++
+		}
+		series := make([]string, 0, len(counts))
+		for s := range counts {
+			series = append(series, s)
+		}
+		sort.Strings(series)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SERIES\tPOINTS")
+		for _, s := range series {
+			fmt.Fprintf(w, "%s\t%d\n", s, counts[s])
+		}
+		return w.Flush()
+	},
+}
+
+var metricsQueryCmd = &cobra.Command{
+	Use:   "query <name|prefix*>",
+	Short: "Print the history of a metric",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		points, err := readMetrics(args[0])
+		if err != nil {
+			return err
+		}
+		return writeMetrics(os.Stdout, points, queryFormat)
+	},
+}
+
+var metricsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the history of all metrics",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		points, err := readMetrics("")
+		if err != nil {
+			return err
+		}
+		if metricsOutput == "" || metricsOutput == "-" {
+			return writeMetrics(os.Stdout, points, exportFormat)
+		}
+		f, err := os.Create(metricsOutput)
+		if err != nil {
+			return err
+		}
+		if err := writeMetrics(f, points, exportFormat); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	},
+}
+
+func init() {
+	metricsCmd.PersistentFlags().StringVar(&metricsDir, "dir", "", "history directory (default monitoring.history.dir)")
+	metricsCmd.PersistentFlags().DurationVar(&metricsSince, "since", 24*time.Hour, "how far back to read")
+	metricsCmd.PersistentFlags().DurationVar(&metricsUntil, "until", 0, "how far back to stop reading (0 reads up to now)")
+	metricsCmd.PersistentFlags().DurationVar(&metricsStep, "step", 0, "merge points into steps of this length")
+	metricsCmd.PersistentFlags().StringArrayVar(&metricsLabels, "label", nil, "only series with this label value (name=value, repeatable)")
+
+	metricsQueryCmd.Flags().StringVar(&queryFormat, "format", "table", "output format (table, csv or json)")
+	metricsExportCmd.Flags().StringVar(&exportFormat, "format", "csv", "output format (csv or json)")
+	metricsExportCmd.Flags().StringVarP(&metricsOutput, "output", "o", "", "file to write instead of stdout")
+
+	metricsCmd.AddCommand(metricsListCmd, metricsQueryCmd, metricsExportCmd)
+	rootCmd.AddCommand(metricsCmd)
+}
+
+// readMetrics reads the points selected by the flags
+func readMetrics(name string) ([]metrics.Point, error) {
+	q := metrics.HistoryQuery{Name: name, Since: time.Now().Add(-metricsSince)}
+	if metricsUntil > 0 {
+		q.Until = time.Now().Add(-metricsUntil)
+	}
+	for _, label := range metricsLabels {
+		k, v, ok := strings.Cut(label, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --label %q (name=value)", label)
+		}
+		if q.Labels == nil {
+			q.Labels = make(map[string]string)
+		}
+		q.Labels[k] = v
+	}
+
+	points, err := metrics.ReadHistory(metricsDir, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics history: %w", err)
+	}
+	if metricsStep > 0 {
+		points = metrics.Downsample(points, metricsStep)
+	}
+	return points, nil
+}
+
+// writeMetrics writes points in the given format
+func writeMetrics(out io.Writer, points []metrics.Point, format string) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if points == nil {
+			points = []metrics.Point{}
+		}
+		return encoder.Encode(points)
+
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"time", "series", "min", "max", "avg", "last", "count"})
+		for _, p := range points {
+			w.Write([]string{
+				p.Time.UTC().Format(time.RFC3339), p.Series(),
+				formatValue(p.Min), formatValue(p.Max), formatValue(p.Avg), formatValue(p.Last),
+				strconv.Itoa(p.Count),
+			})
+		}
+		w.Flush()
+		return w.Error()
+
+	case "table":
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tSERIES\tMIN\tMAX\tAVG\tLAST")
+		for _, p := range points {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				p.Time.Local().Format("2006-01-02 15:04"), p.Series(),
+				formatValue(p.Min), formatValue(p.Max), formatValue(p.Avg), formatValue(p.Last))
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
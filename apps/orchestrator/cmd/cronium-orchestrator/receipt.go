@@ -0,0 +1,120 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/receipt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyPublicKey string
+	verifyScript    string
+	verifyStdout    string
+	verifyStderr    string
+)
+
+var verifyReceiptCmd = &cobra.Command{
+	Use:   "verify-receipt <receipt.json|->",
+	Short: "Verify a signed execution receipt",
+	Long: `Checks the signature of an execution receipt and prints what it records.
+
+Without --public-key only the signature is checked against the key embedded in
+the receipt, which proves the receipt is intact but not who made it. Pass the
+orchestrator's public key (logged at startup) to also check the signer. The
+--script, --stdout and --stderr flags compare files against the hashes in the
+receipt.`,
+	Args: cobra.ExactArgs(1),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Verification is offline and needs no configuration
+		return nil
+	},
+	RunE: runVerifyReceipt,
+}
+
+func init() {
+	verifyReceiptCmd.Flags().StringVar(&verifyPublicKey, "public-key", "", "trusted public key file or base64 key")
+	verifyReceiptCmd.Flags().StringVar(&verifyScript, "script", "", "script file to compare with the script hash")
+	verifyReceiptCmd.Flags().StringVar(&verifyStdout, "stdout", "", "file to compare with the stdout hash")
+	verifyReceiptCmd.Flags().StringVar(&verifyStderr, "stderr", "", "file to compare with the stderr hash")
+
+	rootCmd.AddCommand(verifyReceiptCmd)
+}
+
+func runVerifyReceipt(cmd *cobra.Command, args []string) error {
+	var data []byte
+	var err error
+	if args[0] == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read receipt: %w", err)
+	}
+
+	var signed receipt.Signed
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return fmt.Errorf("failed to parse receipt: %w", err)
+	}
+
+	var trusted []ed25519.PublicKey
+	if verifyPublicKey != "" {
+		keyData, err := os.ReadFile(verifyPublicKey)
+		if err != nil {
+			keyData = []byte(verifyPublicKey)
+		}
+		key, err := receipt.ParsePublicKey(keyData)
+		if err != nil {
+			return err
+		}
+		trusted = append(trusted, key)
+	}
+
+	if err := receipt.Verify(&signed, trusted...); err != nil {
+		return fmt.Errorf("receipt is NOT valid: %w", err)
+	}
+
+	r := signed.Receipt
+	checks := []struct {
+		name, path, expected string
+	}{
+		{"script", verifyScript, r.ScriptHash},
+		{"stdout", verifyStdout, r.StdoutHash},
+		{"stderr", verifyStderr, r.StderrHash},
+	}
+	for _, check := range checks {
+		if check.path == "" {
+			continue
+		}
+		content, err := os.ReadFile(check.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s file: %w", check.name, err)
+		}
+		if receipt.Hash(content) != check.expected {
+			return fmt.Errorf("receipt is valid but %s does not match: got %s, receipt has %s", check.name, receipt.Hash(content), check.expected)
+		}
+	}
+
+	fmt.Printf("Receipt signature valid (key %s", signed.KeyID)
+	if len(trusted) > 0 {
+		fmt.Print(", trusted")
+	} else {
+		fmt.Print(", signer not checked")
+	}
+	fmt.Println(")")
+	fmt.Printf("  Job:          %s (%s)\n", r.JobID, r.JobType)
+	fmt.Printf("  Orchestrator: %s\n", r.OrchestratorID)
+	fmt.Printf("  Target:       %s\n", r.Target)
+	fmt.Printf("  Started:      %s\n", r.StartedAt.Format("2006-01-02T15:04:05.000Z07:00"))
+	fmt.Printf("  Completed:    %s\n", r.CompletedAt.Format("2006-01-02T15:04:05.000Z07:00"))
+	fmt.Printf("  Status:       %s (exit code %d)\n", r.Status, r.ExitCode)
+	fmt.Printf("  Script:       %s\n", r.ScriptHash)
+	fmt.Printf("  Stdout:       %s\n", r.StdoutHash)
+	fmt.Printf("  Stderr:       %s\n", r.StderrHash)
+	return nil
+}
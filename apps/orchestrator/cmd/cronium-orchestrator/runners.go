@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runnerdist"
+	"github.com/spf13/cobra"
+)
+
+var runnersCmd = &cobra.Command{
+	Use:   "runners",
+	Short: "Manage the runner builds deployed to SSH servers",
+	Long: `Lists and downloads the cronium-runner builds in RUNNER_ARTIFACTS_DIR.
+
+Builds are fetched from the release channel in ssh.runner.releases, checked
+against their published checksum and, when publicKeys is set, their Ed25519
+signature.`,
+}
+
+var runnersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the installed runner versions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		installed, err := runnerdist.ListInstalled(runnerdist.ArtifactsDir())
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tARCHITECTURES\tSOURCE\tINSTALLED")
+		for _, v := range installed {
+			source, when := "bundled", "-"
+			if v.Downloaded {
+				source = "release"
+				if !v.InstalledAt.IsZero() {
+					when = v.InstalledAt.Local().Format("2006-01-02 15:04")
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Version, strings.Join(v.Architectures, ","), source, when)
+		}
+		return w.Flush()
+	},
+}
+
+var runnersSyncCmd = &cobra.Command{
+	Use:   "sync [version[@arch]...]",
+	Short: "Download runner releases now",
+	Long: `Downloads the channel's latest release and the pinned and rolled out
+versions, or only the given versions, for the configured architectures.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Read(cfgFile)
+		if err != nil {
+			return fmt.Errorf("failed to load configuration: %w", err)
+		}
+		if !cfg.SSH.Runner.Releases.Enabled {
+			return fmt.Errorf("runner releases are disabled (ssh.runner.releases.enabled)")
+		}
+		log := logger.New()
+		logger.Configure(log, cfg.Logging)
+		dist, err := runnerdist.New(cfg.SSH.Runner, log)
+		if err != nil {
+			return err
+		}
+
+		ctx := context.Background()
+		if len(args) == 0 {
+			if err := dist.Sync(ctx); err != nil {
+				return err
+			}
+			fmt.Printf("Latest %s release: %s\n", cfg.SSH.Runner.Releases.Channel, dist.Latest())
+			return nil
+		}
+		for _, arg := range args {
+			version, arch, ok := strings.Cut(arg, "@")
+			archs := cfg.SSH.Runner.Releases.Architectures
+			if ok {
+				archs = []string{arch}
+			}
+			for _, arch := range archs {
+				if err := dist.Ensure(ctx, version, arch); err != nil {
+					return err
+				}
+				fmt.Printf("Installed %s (%s)\n", version, arch)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	runnersCmd.AddCommand(runnersListCmd, runnersSyncCmd)
+	rootCmd.AddCommand(runnersCmd)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	previewTZ    string
+	previewCount int
+	previewFrom  string
+	previewJSON  bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Check cron schedules",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Schedules are checked offline and need no configuration
+		return nil
+	},
+}
+
+var schedulePreviewCmd = &cobra.Command{
+	Use:   `preview "<cron expression>"`,
+	Short: "Show when a cron schedule will run",
+	Long: `Validates a cron expression and lists its next runs in a time zone.
+
+Runs that daylight saving time moves are marked: a time skipped when clocks go
+forward runs at the end of the gap, and a time repeated when they go back runs
+the first time only, unless the schedule runs every hour, in which case it
+runs in both.`,
+	Example: `  cronium-orchestrator schedule preview "30 2 * * *" --tz Europe/Berlin --count 10`,
+	Args:    cobra.ExactArgs(1),
+	RunE:    runSchedulePreview,
+}
+
+func init() {
+	schedulePreviewCmd.Flags().StringVar(&previewTZ, "tz", "", "IANA time zone (default local time)")
+	schedulePreviewCmd.Flags().IntVar(&previewCount, "count", 10, "number of runs to show")
+	schedulePreviewCmd.Flags().StringVar(&previewFrom, "from", "", "show runs after this RFC 3339 time (default now)")
+	schedulePreviewCmd.Flags().BoolVar(&previewJSON, "json", false, "print the runs as JSON")
+
+	scheduleCmd.AddCommand(schedulePreviewCmd)
+	rootCmd.AddCommand(scheduleCmd)
+}
+
+func runSchedulePreview(cmd *cobra.Command, args []string) error {
+	s, err := schedule.Parse(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid cron expression: %w", err)
+	}
+	loc := time.Local
+	if previewTZ != "" {
+		if loc, err = time.LoadLocation(previewTZ); err != nil {
+			return fmt.Errorf("unknown time zone %q: %w", previewTZ, err)
+		}
+	}
+	from := time.Now()
+	if previewFrom != "" {
+		if from, err = time.Parse(time.RFC3339, previewFrom); err != nil {
+			return fmt.Errorf("invalid --from time: %w", err)
+		}
+	}
+
+	runs := s.Preview(from, loc, previewCount)
+	if previewJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(runs)
+	}
+
+	fmt.Printf("Schedule %q in %s\n", s.String(), loc)
+	if len(runs) == 0 {
+		fmt.Println("  never runs")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, run := range runs {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", run.Time.Format("Mon 2006-01-02 15:04:05 MST"), run.Time.UTC().Format("15:04Z"), run.Describe())
+	}
+	return w.Flush()
+}
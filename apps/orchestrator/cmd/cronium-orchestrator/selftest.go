@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/ssh"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/selftest"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selftestServerID string
+	selftestJSON     bool
+	selftestTimeout  time.Duration
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run a diagnostic job and report the result of each execution stage",
+	Long: `Runs a built-in diagnostic job through the full execution path and prints a
+pass/fail report per stage. With --server the job runs on that server over SSH
+(payload build, runner deployment, bundled helper round trips, log streaming
+and cleanup); otherwise it runs in a local container.
+
+Self-test executions are not recorded in the backend.`,
+	RunE: runSelftest,
+}
+
+func init() {
+	selftestCmd.Flags().StringVar(&selftestServerID, "server", "", "server ID to test over SSH")
+	selftestCmd.Flags().BoolVar(&selftestJSON, "json", false, "print the report as JSON")
+	selftestCmd.Flags().DurationVar(&selftestTimeout, "timeout", 5*time.Minute, "maximum duration of the self-test")
+
+	rootCmd.AddCommand(selftestCmd)
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	var (
+		executor executors.Executor
+		job      *types.Job
+		target   string
+		opts     = selftest.Options{Timeout: selftestTimeout}
+		err      error
+	)
+
+	if selftestServerID != "" {
+		apiClient, err := api.NewClient(cfg.API, log)
+		if err != nil {
+			return fmt.Errorf("failed to create API client: %w", err)
+		}
+		server, err := apiClient.GetServer(ctx, selftestServerID)
+		if err != nil {
+			return err
+		}
+
+		// No API client or runtime settings: the execution is not reported to
+		// the backend and helpers run in bundled mode
+		executor, err = ssh.NewExecutor(cfg.SSH, nil, "", 0, "", log)
+		if err != nil {
+			return fmt.Errorf("failed to create SSH executor: %w", err)
+		}
+
+		serverID := server.ID
+		job, _, err = selftest.NewJob(types.JobTypeSSH, types.Target{
+			Type:          types.TargetTypeServer,
+			ServerID:      &serverID,
+			ServerDetails: server,
+		}, true)
+		if err != nil {
+			return err
+		}
+
+		target = fmt.Sprintf("ssh:%s (%s)", server.Name, server.Host)
+		opts.Helpers = true
+		opts.SetupStages = []string{selftest.StageConnect, selftest.StagePayload, selftest.StageDeploy}
+	} else {
+		executor, err = container.NewExecutor(cfg.Container, nil, log)
+		if err != nil {
+			return fmt.Errorf("failed to create container executor: %w", err)
+		}
+
+		// Container helpers go through the runtime service, which needs an
+		// execution known to the backend, so only the container path is tested
+		job, _, err = selftest.NewJob(types.JobTypeContainer, types.Target{
+			Type: types.TargetTypeLocal,
+		}, false)
+		if err != nil {
+			return err
+		}
+
+		target = "container:local"
+		opts.SetupStages = []string{selftest.StageStart}
+	}
+
+	report := selftest.Run(ctx, executor, job, target, opts, log)
+
+	if selftestJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return fmt.Errorf("failed to encode report: %w", err)
+		}
+	} else {
+		printSelftestReport(report)
+	}
+
+	if !report.Passed {
+		return fmt.Errorf("self-test failed")
+	}
+	return nil
+}
+
+// printSelftestReport writes a human-readable report to stdout
+func printSelftestReport(report *selftest.Report) {
+	fmt.Printf("Self-test %s on %s\n\n", report.JobID, report.Target)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tRESULT\tDETAILS")
+	for _, stage := range report.Stages {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", stage.Name, stage.Status, stage.Message)
+	}
+	w.Flush()
+
+	result := "PASSED"
+	if !report.Passed {
+		result = "FAILED"
+	}
+	fmt.Printf("\n%s in %s\n", result, report.Duration.Round(time.Millisecond))
+}
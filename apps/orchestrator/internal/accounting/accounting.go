@@ -0,0 +1,293 @@
+// Package accounting reports the resources jobs use, for chargeback of
+// shared orchestrators. Running jobs are sampled for CPU, memory and network
+// use; finished jobs are added up per tenant and event, and every period the
+// totals are written to report files and posted to the backend.
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Reports kept for another attempt while the backend is unreachable
+	maxPending = 168
+	// Time the final report gets when the orchestrator stops
+	stopTimeout = 30 * time.Second
+	// Prefix of report file names
+	filePrefix = "usage-"
+)
+
+// Reporter posts usage reports to the backend
+type Reporter interface {
+	ReportUsage(ctx context.Context, report *api.UsageReport) error
+}
+
+type entryKey struct {
+	tenant  string
+	eventID string
+}
+
+// Accountant meters jobs and writes the usage reports. A nil Accountant
+// meters nothing.
+type Accountant struct {
+	cfg            config.AccountingConfig
+	orchestratorID string
+	reporter       Reporter
+	log            *logrus.Logger
+
+	mu          sync.Mutex
+	periodStart time.Time
+	entries     map[entryKey]*api.UsageEntry
+	pending     []*api.UsageReport
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates the accountant. It returns nil when accounting is disabled.
+func New(cfg config.AccountingConfig, orchestratorID string, reporter Reporter, log *logrus.Logger) *Accountant {
+	if !cfg.Enabled {
+		return nil
+	}
+	if !cfg.PostToBackend {
+		reporter = nil
+	}
+	return &Accountant{
+		cfg:            cfg,
+		orchestratorID: orchestratorID,
+		reporter:       reporter,
+		log:            log,
+		periodStart:    time.Now().UTC().Truncate(cfg.Period),
+		entries:        make(map[entryKey]*api.UsageEntry),
+	}
+}
+
+// Meter starts sampling a job that was just started. It returns nil when
+// the accountant is nil.
+func (a *Accountant) Meter(ctx context.Context, job *types.Job, sample SampleFunc) *Meter {
+	if a == nil {
+		return nil
+	}
+	return StartMeter(ctx, job, sample, a.cfg.SampleInterval)
+}
+
+// Record adds a finished job to the current period
+func (a *Accountant) Record(job *types.Job, status types.JobStatus, duration time.Duration, usage *Usage) {
+	if a == nil {
+		return
+	}
+	key := entryKey{}
+	key.tenant, _ = job.Metadata["userId"].(string)
+	if v, ok := job.Metadata["eventId"]; ok {
+		key.eventID = fmt.Sprintf("%v", v)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.entries[key]
+	if !ok {
+		entry = &api.UsageEntry{Tenant: key.tenant, EventID: key.eventID}
+		a.entries[key] = entry
+	}
+	entry.Executions++
+	if status != types.JobStatusCompleted {
+		entry.Failures++
+	}
+	entry.DurationSeconds += duration.Seconds()
+	if usage != nil {
+		entry.CPUSeconds += usage.CPUSeconds
+		entry.MemoryGBHours += usage.MemoryGBHours
+		entry.TransferBytes += usage.TransferBytes
+	}
+}
+
+// Start starts closing a report at the end of every period
+func (a *Accountant) Start(ctx context.Context) {
+	if a == nil {
+		return
+	}
+	ctx, a.cancel = context.WithCancel(ctx)
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		for {
+			a.mu.Lock()
+			end := a.periodStart.Add(a.cfg.Period)
+			a.mu.Unlock()
+
+			timer := time.NewTimer(time.Until(end))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			a.flush(ctx, end)
+		}
+	}()
+}
+
+// Stop stops the period loop and reports the current, partial period
+func (a *Accountant) Stop() {
+	if a == nil || a.cancel == nil {
+		return
+	}
+	a.cancel()
+	a.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	a.flush(ctx, time.Now().UTC())
+}
+
+// flush closes the current period at end, writes its report and posts it
+// together with the reports earlier attempts failed to post
+func (a *Accountant) flush(ctx context.Context, end time.Time) {
+	a.mu.Lock()
+	report := &api.UsageReport{
+		OrchestratorID: a.orchestratorID,
+		PeriodStart:    a.periodStart,
+		PeriodEnd:      end,
+	}
+	for _, entry := range a.entries {
+		report.Entries = append(report.Entries, *entry)
+	}
+	a.periodStart = end
+	a.entries = make(map[entryKey]*api.UsageEntry)
+	a.mu.Unlock()
+
+	if len(report.Entries) > 0 {
+		sort.Slice(report.Entries, func(i, j int) bool {
+			if report.Entries[i].Tenant != report.Entries[j].Tenant {
+				return report.Entries[i].Tenant < report.Entries[j].Tenant
+			}
+			return report.Entries[i].EventID < report.Entries[j].EventID
+		})
+		if err := a.write(report); err != nil {
+			a.log.WithError(err).Error("Failed to write usage report")
+		}
+		if a.reporter != nil {
+			a.pending = append(a.pending, report)
+		}
+	}
+	if len(a.pending) > maxPending {
+		a.log.WithField("dropped", len(a.pending)-maxPending).Warn("Dropping usage reports the backend did not accept")
+		a.pending = a.pending[len(a.pending)-maxPending:]
+	}
+
+	for len(a.pending) > 0 {
+		if err := a.reporter.ReportUsage(ctx, a.pending[0]); err != nil {
+			a.log.WithError(err).WithField("pending", len(a.pending)).Warn("Failed to post usage report; retrying next period")
+			break
+		}
+		a.pending = a.pending[1:]
+	}
+
+	if err := a.prune(end); err != nil {
+		a.log.WithError(err).Warn("Failed to remove old usage reports")
+	}
+}
+
+// write writes a report in the configured formats
+func (a *Accountant) write(report *api.UsageReport) error {
+	if err := os.MkdirAll(a.cfg.Dir, 0o750); err != nil {
+		return err
+	}
+	name := filePrefix + report.PeriodStart.UTC().Format("20060102T150405Z")
+	for _, format := range a.cfg.Formats {
+		var data []byte
+		var err error
+		switch format {
+		case "json":
+			data, err = json.MarshalIndent(report, "", "  ")
+		case "csv":
+			data, err = encodeCSV(report)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(a.cfg.Dir, name+"."+format), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeCSV writes a report as one row per entry
+func encodeCSV(report *api.UsageReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{
+		"orchestrator_id", "period_start", "period_end", "tenant", "event_id", "executions",
+		"failures", "duration_seconds", "cpu_seconds", "memory_gb_hours", "transfer_bytes",
+	})
+	start := report.PeriodStart.UTC().Format(time.RFC3339)
+	end := report.PeriodEnd.UTC().Format(time.RFC3339)
+	for _, e := range report.Entries {
+		w.Write([]string{
+			report.OrchestratorID, start, end, e.Tenant, e.EventID,
+			strconv.Itoa(e.Executions),
+			strconv.Itoa(e.Failures),
+			strconv.FormatFloat(e.DurationSeconds, 'f', 3, 64),
+			strconv.FormatFloat(e.CPUSeconds, 'f', 3, 64),
+			strconv.FormatFloat(e.MemoryGBHours, 'f', 6, 64),
+			strconv.FormatInt(e.TransferBytes, 10),
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// writeFile replaces a file so that readers never see a partial report
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// prune removes report files older than the retention
+func (a *Accountant) prune(now time.Time) error {
+	if a.cfg.Retention == 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(a.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), filePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || now.Sub(info.ModTime()) <= a.cfg.Retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(a.cfg.Dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
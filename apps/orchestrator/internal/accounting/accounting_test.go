@@ -0,0 +1,163 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReporter struct {
+	err     error
+	reports []*api.UsageReport
+}
+
+func (r *fakeReporter) ReportUsage(ctx context.Context, report *api.UsageReport) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.reports = append(r.reports, report)
+	return nil
+}
+
+func newTestAccountant(t *testing.T, reporter Reporter) *Accountant {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return New(config.AccountingConfig{
+		Enabled:        true,
+		Period:         time.Hour,
+		SampleInterval: time.Second,
+		Dir:            t.TempDir(),
+		Formats:        []string{"json", "csv"},
+		Retention:      24 * time.Hour,
+		PostToBackend:  true,
+	}, "orch-1", reporter, log)
+}
+
+func testJob(tenant string, eventID any) *types.Job {
+	return &types.Job{ID: "job_1", Metadata: map[string]interface{}{"userId": tenant, "eventId": eventID}}
+}
+
+func TestMeterIntegratesSamples(t *testing.T) {
+	start := time.Now()
+	m := newMeter(start)
+	m.add(&types.ResourceSample{CPUPercent: 50, MemoryBytes: 2e9, NetworkRx: 100}, start.Add(10*time.Second))
+	m.add(&types.ResourceSample{CPUPercent: 200, MemoryBytes: 1e9, NetworkRx: 300, NetworkTx: 50}, start.Add(40*time.Second))
+
+	assert.InDelta(t, 5+60, m.usage.CPUSeconds, 1e-9)
+	assert.InDelta(t, (2*10+1*30)/3600.0, m.usage.MemoryGBHours, 1e-9)
+	assert.Equal(t, 200.0, m.usage.Peak.PeakCPU)
+	assert.Equal(t, int64(2e9), m.usage.Peak.PeakMemory)
+	assert.Equal(t, int64(300), m.usage.Peak.NetworkRx)
+}
+
+func TestMeterUsesCPUCounter(t *testing.T) {
+	start := time.Now()
+	m := newMeter(start)
+	m.add(&types.ResourceSample{CPUPercent: 50, CPUSeconds: 12}, start.Add(10*time.Second))
+	m.add(&types.ResourceSample{CPUPercent: 50, CPUSeconds: 20}, start.Add(20*time.Second))
+	assert.InDelta(t, 20, m.usage.CPUSeconds, 1e-9)
+}
+
+func TestCombine(t *testing.T) {
+	sampled := &Usage{CPUSeconds: 4, Peak: types.ResourceUsage{PeakCPU: 30, PeakMemory: 100}}
+	u := Combine(sampled, &types.ResourceUsage{CPUSeconds: 6, PeakMemory: 250, DiskWrite: 4096}, 10*time.Second)
+	assert.Equal(t, 6.0, u.CPUSeconds)
+	assert.InDelta(t, 60, u.Peak.PeakCPU, 1e-9)
+	assert.Equal(t, int64(250), u.Peak.PeakMemory)
+	assert.Equal(t, int64(4096), u.ResourceUsage().DiskWrite)
+
+	// A job that was never sampled still reports the measured totals
+	u = Combine(nil, &types.ResourceUsage{CPUSeconds: 1}, 4*time.Second)
+	assert.InDelta(t, 25, u.ResourceUsage().PeakCPU, 1e-9)
+	assert.Nil(t, Combine(nil, nil, time.Second))
+}
+
+func TestMeterSamplesUntilStopped(t *testing.T) {
+	a := newTestAccountant(t, nil)
+	sample := func(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+		return &types.ResourceSample{CPUPercent: 100, NetworkRx: 10, NetworkTx: 5}, nil
+	}
+	m := a.Meter(context.Background(), testJob("t", 1), sample)
+	time.Sleep(50 * time.Millisecond)
+	usage := m.Stop()
+
+	assert.Greater(t, usage.CPUSeconds, 0.0)
+	assert.Equal(t, int64(15), usage.TransferBytes)
+	assert.Equal(t, int64(10), usage.ResourceUsage().NetworkRx)
+
+	// Without accounting nothing is metered
+	var off *Accountant
+	assert.Nil(t, off.Meter(context.Background(), testJob("t", 1), sample).Stop())
+}
+
+func TestFlushWritesAndPostsReport(t *testing.T) {
+	reporter := &fakeReporter{}
+	a := newTestAccountant(t, reporter)
+	usage := &Usage{CPUSeconds: 1.5, MemoryGBHours: 0.25, TransferBytes: 1000}
+	a.Record(testJob("tenant_b", 7), types.JobStatusCompleted, 2*time.Second, usage)
+	a.Record(testJob("tenant_a", 3), types.JobStatusFailed, time.Second, usage)
+	a.Record(testJob("tenant_b", 7), types.JobStatusCompleted, 3*time.Second, nil)
+
+	start := a.periodStart
+	a.flush(context.Background(), start.Add(time.Hour))
+
+	require.Len(t, reporter.reports, 1)
+	report := reporter.reports[0]
+	assert.Equal(t, "orch-1", report.OrchestratorID)
+	require.Len(t, report.Entries, 2)
+	assert.Equal(t, api.UsageEntry{
+		Tenant: "tenant_a", EventID: "3", Executions: 1, Failures: 1,
+		DurationSeconds: 1, CPUSeconds: 1.5, MemoryGBHours: 0.25, TransferBytes: 1000,
+	}, report.Entries[0])
+	assert.Equal(t, 2, report.Entries[1].Executions)
+	assert.Equal(t, 5.0, report.Entries[1].DurationSeconds)
+
+	name := filepath.Join(a.cfg.Dir, filePrefix+start.Format("20060102T150405Z"))
+	data, err := os.ReadFile(name + ".json")
+	require.NoError(t, err)
+	var written api.UsageReport
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Equal(t, report.Entries, written.Entries)
+
+	data, err = os.ReadFile(name + ".csv")
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[1], "orch-1,"))
+	assert.Contains(t, lines[1], ",tenant_a,3,1,1,1.000,1.500,0.250000,1000")
+
+	// The next period starts empty
+	a.flush(context.Background(), start.Add(2*time.Hour))
+	assert.Len(t, reporter.reports, 1)
+}
+
+func TestFlushRetriesFailedPosts(t *testing.T) {
+	reporter := &fakeReporter{err: errors.New("backend unavailable")}
+	a := newTestAccountant(t, reporter)
+	start := a.periodStart
+
+	a.Record(testJob("tenant_a", 1), types.JobStatusCompleted, time.Second, nil)
+	a.flush(context.Background(), start.Add(time.Hour))
+	a.Record(testJob("tenant_a", 1), types.JobStatusCompleted, time.Second, nil)
+	a.flush(context.Background(), start.Add(2*time.Hour))
+	assert.Len(t, a.pending, 2)
+
+	reporter.err = nil
+	a.flush(context.Background(), start.Add(3*time.Hour))
+	require.Len(t, reporter.reports, 2)
+	assert.Equal(t, start, reporter.reports[0].PeriodStart)
+	assert.Empty(t, a.pending)
+}
@@ -0,0 +1,153 @@
+package accounting
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// SampleFunc reads the current resource usage of a running job
+type SampleFunc func(ctx context.Context, job *types.Job) (*types.ResourceSample, error)
+
+// Usage is what a job used while it ran
+type Usage struct {
+	CPUSeconds    float64
+	MemoryGBHours float64
+	TransferBytes int64
+	// Peak readings and the final network and disk counters
+	Peak types.ResourceUsage
+}
+
+// ResourceUsage returns the usage in the form reported with a completed job
+func (u *Usage) ResourceUsage() *types.ResourceUsage {
+	if u == nil {
+		return nil
+	}
+	peak := u.Peak
+	peak.CPUSeconds = u.CPUSeconds
+	return &peak
+}
+
+// Combine adds the totals an executor measured when the job ended to the
+// sampled usage, which may be nil when the job was not sampled. Measured
+// totals are exact, so they replace the integrated CPU time; a job too short
+// to be sampled gets its average CPU use as its peak.
+func Combine(u *Usage, final *types.ResourceUsage, elapsed time.Duration) *Usage {
+	if final == nil {
+		return u
+	}
+	if u == nil {
+		u = &Usage{}
+	}
+	if final.CPUSeconds > 0 {
+		u.CPUSeconds = final.CPUSeconds
+		if elapsed > 0 {
+			u.Peak.PeakCPU = max(u.Peak.PeakCPU, final.CPUSeconds/elapsed.Seconds()*100)
+		}
+	}
+	u.Peak.PeakCPU = max(u.Peak.PeakCPU, final.PeakCPU)
+	u.Peak.PeakMemory = max(u.Peak.PeakMemory, final.PeakMemory)
+	u.Peak.NetworkRx = max(u.Peak.NetworkRx, final.NetworkRx)
+	u.Peak.NetworkTx = max(u.Peak.NetworkTx, final.NetworkTx)
+	u.Peak.DiskRead = max(u.Peak.DiskRead, final.DiskRead)
+	u.Peak.DiskWrite = max(u.Peak.DiskWrite, final.DiskWrite)
+	u.TransferBytes = u.Peak.NetworkRx + u.Peak.NetworkTx
+	return u
+}
+
+// Meter samples a running job and integrates its CPU and memory use over
+// time. Each sample stands for the time since the one before it.
+type Meter struct {
+	mu      sync.Mutex
+	usage   Usage
+	last    *types.ResourceSample
+	sampled time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newMeter(started time.Time) *Meter {
+	return &Meter{sampled: started, done: make(chan struct{})}
+}
+
+// StartMeter starts sampling a job that was just started every interval
+func StartMeter(ctx context.Context, job *types.Job, sample SampleFunc, interval time.Duration) *Meter {
+	m := newMeter(time.Now())
+	ctx, m.cancel = context.WithCancel(ctx)
+	go m.run(ctx, job, sample, interval)
+	return m
+}
+
+// run samples the job until the meter is stopped. Samples fail while the
+// job's container or process is not up yet; those are skipped.
+func (m *Meter) run(ctx context.Context, job *types.Job, sample SampleFunc, interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if s, err := sample(ctx, job); err == nil && s != nil {
+			at := s.Timestamp
+			if at.IsZero() {
+				at = time.Now()
+			}
+			m.add(s, at)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// add accounts for a sample taken at the given time
+func (m *Meter) add(s *types.ResourceSample, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elapsed := at.Sub(m.sampled).Seconds(); elapsed > 0 {
+		// Sources that count CPU time give it exactly
+		if s.CPUSeconds > 0 {
+			m.usage.CPUSeconds = max(m.usage.CPUSeconds, s.CPUSeconds)
+		} else {
+			m.usage.CPUSeconds += s.CPUPercent / 100 * elapsed
+		}
+		m.usage.MemoryGBHours += float64(s.MemoryBytes) / 1e9 * elapsed / 3600
+		m.sampled = at
+	}
+	m.last = s
+
+	peak := &m.usage.Peak
+	peak.PeakCPU = max(peak.PeakCPU, s.CPUPercent)
+	peak.PeakMemory = max(peak.PeakMemory, s.MemoryBytes)
+	// Network and disk readings are counters since the job started
+	peak.NetworkRx = max(peak.NetworkRx, s.NetworkRx)
+	peak.NetworkTx = max(peak.NetworkTx, s.NetworkTx)
+	peak.DiskRead = max(peak.DiskRead, s.DiskRead)
+	peak.DiskWrite = max(peak.DiskWrite, s.DiskWrite)
+}
+
+// Stop stops sampling and returns the job's usage. The last sample also
+// stands for the time from it until the job ended.
+func (m *Meter) Stop() *Usage {
+	if m == nil {
+		return nil
+	}
+	m.cancel()
+	<-m.done
+
+	if m.last != nil {
+		m.add(m.last, time.Now())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := m.usage
+	usage.TransferBytes = usage.Peak.NetworkRx + usage.Peak.NetworkTx
+	return &usage
+}
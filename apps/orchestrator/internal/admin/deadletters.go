@@ -0,0 +1,43 @@
+package admin
+
+import (
+	"net/http"
+)
+
+// handleListDeadLetters returns the jobs refused after repeated failed
+// attempts
+func (s *Server) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, http.StatusNotFound, "dead-lettering is not enabled")
+		return
+	}
+
+	entries := s.deadLetters.Entries()
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs":  entries,
+		"count": len(entries),
+	})
+}
+
+// handleClearDeadLetter forgets a job's failed attempts so it runs again the
+// next time it is polled
+func (s *Server) handleClearDeadLetter(w http.ResponseWriter, r *http.Request) {
+	if s.deadLetters == nil {
+		s.writeError(w, http.StatusNotFound, "dead-lettering is not enabled")
+		return
+	}
+
+	jobID := r.PathValue("jobId")
+	if !s.deadLetters.Clear(jobID) {
+		s.writeError(w, http.StatusNotFound, "no attempts are recorded for job")
+		return
+	}
+	if s.metrics != nil {
+		s.metrics.SetDeadLetteredJobs(float64(len(s.deadLetters.Entries())))
+	}
+
+	s.log.WithField("jobID", jobID).Warn("Dead-lettered job cleared through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cleared": jobID,
+	})
+}
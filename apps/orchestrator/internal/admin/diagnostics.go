@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+)
+
+// LogLevelRequest is the body of a runtime log level change
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// MetricsResetRequest names the metrics to zero
+type MetricsResetRequest struct {
+	Metrics []string `json:"metrics"`
+}
+
+// handleGetLogLevels returns the global log level and component overrides
+func (s *Server) handleGetLogLevels(w http.ResponseWriter, r *http.Request) {
+	if s.levels == nil {
+		s.writeError(w, http.StatusNotFound, "runtime log levels are not available")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, s.levels.State())
+}
+
+// handleSetLogLevel changes the global log level, or a component's when the
+// path names one, until the orchestrator restarts
+func (s *Server) handleSetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.levels == nil {
+		s.writeError(w, http.StatusNotFound, "runtime log levels are not available")
+		return
+	}
+
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, `body must be {"level": "<level>"}`)
+		return
+	}
+	level, err := logrus.ParseLevel(req.Level)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	component := r.PathValue("component")
+	if component == "" {
+		s.levels.SetGlobal(level)
+	} else {
+		s.levels.SetComponent(component, level)
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"level":     level.String(),
+		"component": component,
+	}).Warn("Log level changed at runtime")
+	s.writeJSON(w, http.StatusOK, s.levels.State())
+}
+
+// handleResetLogLevel makes a component follow the global log level again
+func (s *Server) handleResetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if s.levels == nil {
+		s.writeError(w, http.StatusNotFound, "runtime log levels are not available")
+		return
+	}
+
+	component := r.PathValue("component")
+	s.levels.ResetComponent(component)
+
+	s.log.WithField("component", component).Info("Component log level reset to global level")
+	s.writeJSON(w, http.StatusOK, s.levels.State())
+}
+
+// handleResetMetrics zeroes the named counters and histograms. It is meant
+// for testing; dashboards see the reset as a counter restart.
+func (s *Server) handleResetMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.metrics == nil {
+		s.writeError(w, http.StatusNotFound, "metrics are not available")
+		return
+	}
+
+	var req MetricsResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Metrics) == 0 {
+		s.writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error":      http.StatusText(http.StatusBadRequest),
+			"message":    `body must be {"metrics": ["<name>", ...]}`,
+			"resettable": s.metrics.ResettableMetrics(),
+		})
+		return
+	}
+	if err := s.metrics.ResetMetrics(req.Metrics); err != nil {
+		s.writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"error":      http.StatusText(http.StatusNotFound),
+			"message":    err.Error(),
+			"resettable": s.metrics.ResettableMetrics(),
+		})
+		return
+	}
+
+	s.log.WithField("metrics", req.Metrics).Warn("Metrics reset through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"reset": req.Metrics,
+	})
+}
+
+// handleGetConfig returns the running configuration as YAML, with secrets
+// hidden
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	if s.cfg == nil {
+		s.writeError(w, http.StatusNotFound, "configuration is not available")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := s.cfg.Print(&buf); err != nil {
+		s.writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// FeatureToggleRequest is the body of a runtime feature flag toggle
+type FeatureToggleRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// handleListFeatures returns the orchestrator-wide state of every feature flag
+func (s *Server) handleListFeatures(w http.ResponseWriter, r *http.Request) {
+	if s.features == nil {
+		s.writeError(w, http.StatusNotFound, "feature flags are not available")
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"features": s.features.States(),
+	})
+}
+
+// handleSetFeature toggles a feature flag until it is reset or the
+// orchestrator restarts. Job and tenant overrides still take precedence.
+func (s *Server) handleSetFeature(w http.ResponseWriter, r *http.Request) {
+	if s.features == nil {
+		s.writeError(w, http.StatusNotFound, "feature flags are not available")
+		return
+	}
+
+	var req FeatureToggleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		s.writeError(w, http.StatusBadRequest, `body must be {"enabled": true|false}`)
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := s.features.Set(name, *req.Enabled); err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.log.WithField("feature", name).WithField("enabled", *req.Enabled).Info("Feature flag toggled at runtime")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"features": s.features.States(),
+	})
+}
+
+// handleResetFeature drops a runtime toggle so the configured value applies
+func (s *Server) handleResetFeature(w http.ResponseWriter, r *http.Request) {
+	if s.features == nil {
+		s.writeError(w, http.StatusNotFound, "feature flags are not available")
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := s.features.Reset(name); err != nil {
+		s.writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	s.log.WithField("feature", name).Info("Feature flag reset to configured value")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"features": s.features.States(),
+	})
+}
@@ -0,0 +1,55 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
+)
+
+// logsWriteTimeout bounds each write to a log subscription stream
+const logsWriteTimeout = 10 * time.Second
+
+// logStreamIDs numbers admin log stream connections
+var logStreamIDs atomic.Uint64
+
+// handleLogStream lets an admin client manage filtered log subscriptions and
+// replays over a WebSocket, using the same control messages as the backend
+func (s *Server) handleLogStream(w http.ResponseWriter, r *http.Request) {
+	if s.logs == nil {
+		s.writeError(w, http.StatusNotFound, "log streaming is not available")
+		return
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to upgrade log stream")
+		return
+	}
+	defer conn.Close()
+
+	// The server read timeout would otherwise close the stream
+	conn.SetReadDeadline(time.Time{})
+
+	owner := fmt.Sprintf("admin-%d", logStreamIDs.Add(1))
+	defer s.logs.RemoveSubscriptions(owner)
+
+	var writeMu sync.Mutex
+	sink := func(v any) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+		return conn.WriteJSON(v)
+	}
+
+	for {
+		var msg logger.ControlMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		s.logs.HandleControl(owner, msg, sink)
+	}
+}
@@ -0,0 +1,57 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
+)
+
+// JobMessenger pushes operator messages to the scripts of running jobs
+type JobMessenger interface {
+	// SendJobMessage returns how the message reached the job, or false when
+	// the job is not running here
+	SendJobMessage(ctx context.Context, jobID, message string, data any) (*runtimecache.Delivery, bool, error)
+}
+
+// JobMessageRequest is the body of an operator message. Data carries
+// structured values such as an updated parameter or a confirmation token.
+type JobMessageRequest struct {
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// handleSendJobMessage pushes a message to a running job's scripts
+func (s *Server) handleSendJobMessage(w http.ResponseWriter, r *http.Request) {
+	if s.messenger == nil {
+		s.writeError(w, http.StatusNotFound, "job messages are not available")
+		return
+	}
+
+	var req JobMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Message == "" && req.Data == nil) {
+		s.writeError(w, http.StatusBadRequest, `body must be {"message": "...", "data": ...}`)
+		return
+	}
+
+	jobID := r.PathValue("id")
+	delivery, ok, err := s.messenger.SendJobMessage(r.Context(), jobID, req.Message, req.Data)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job is not running on this orchestrator")
+		return
+	}
+	if err != nil {
+		s.log.WithError(err).WithField("jobID", jobID).Error("Failed to send job message")
+		s.writeError(w, http.StatusBadGateway, "failed to send message: "+err.Error())
+		return
+	}
+
+	s.log.WithField("jobID", jobID).WithField("messageID", delivery.ID).Info("Message sent to job through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobId":    jobID,
+		"id":       delivery.ID,
+		"file":     delivery.File,
+		"channels": delivery.Channels,
+	})
+}
@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"net/http"
+)
+
+// handleListQuarantine returns the events quarantined on this orchestrator
+func (s *Server) handleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	if s.quarantine == nil {
+		s.writeError(w, http.StatusNotFound, "event quarantine is not enabled")
+		return
+	}
+
+	entries := s.quarantine.Entries()
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"events": entries,
+		"count":  len(entries),
+	})
+}
+
+// handleClearQuarantine lets a quarantined event run again
+func (s *Server) handleClearQuarantine(w http.ResponseWriter, r *http.Request) {
+	if s.quarantine == nil {
+		s.writeError(w, http.StatusNotFound, "event quarantine is not enabled")
+		return
+	}
+
+	eventID := r.PathValue("eventId")
+	if !s.quarantine.Clear(eventID) {
+		s.writeError(w, http.StatusNotFound, "event is not quarantined")
+		return
+	}
+	if s.metrics != nil {
+		s.metrics.SetQuarantinedEvents(float64(len(s.quarantine.Entries())))
+	}
+
+	s.log.WithField("eventID", eventID).Warn("Event quarantine cleared through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cleared": eventID,
+	})
+}
@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+)
+
+// runnerSummary describes a cached runner deployment in admin responses
+type runnerSummary struct {
+	ServerID     string    `json:"serverId"`
+	RunnerPath   string    `json:"runnerPath"`
+	Version      string    `json:"version"`
+	Checksum     string    `json:"checksum,omitempty"`
+	Wanted       string    `json:"wanted,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Reported     string    `json:"reported,omitempty"`
+	DeployedAt   time.Time `json:"deployedAt"`
+	LastVerified time.Time `json:"lastVerified"`
+	// Fresh runners are used without verifying them again
+	Fresh bool `json:"fresh"`
+}
+
+// handleListRunners returns the runners known to be deployed on SSH servers
+func (s *Server) handleListRunners(w http.ResponseWriter, r *http.Request) {
+	if s.runners == nil {
+		s.writeError(w, http.StatusNotFound, "runner cache is not enabled")
+		return
+	}
+
+	entries := s.runners.Entries()
+	runners := make([]runnerSummary, 0, len(entries))
+	for _, e := range entries {
+		runners = append(runners, runnerSummary{
+			ServerID:     e.ServerID,
+			RunnerPath:   e.RunnerPath,
+			Version:      e.Version,
+			Checksum:     e.Checksum,
+			Wanted:       e.Wanted,
+			Source:       e.Source,
+			Reported:     e.Reported,
+			DeployedAt:   e.DeployedAt,
+			LastVerified: e.LastVerified,
+			Fresh:        s.runners.Fresh(e),
+		})
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"runners": runners,
+		"count":   len(runners),
+	})
+}
+
+// handleForgetRunner drops a server's cached runner so the next job on it
+// checks the runner again and redeploys it when needed
+func (s *Server) handleForgetRunner(w http.ResponseWriter, r *http.Request) {
+	if s.runners == nil {
+		s.writeError(w, http.StatusNotFound, "runner cache is not enabled")
+		return
+	}
+
+	serverID := r.PathValue("serverId")
+	if !s.runners.Remove(serverID) {
+		s.writeError(w, http.StatusNotFound, "no runner is cached for server")
+		return
+	}
+
+	s.log.WithField("serverID", serverID).Warn("Cached runner forgotten through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"forgotten": serverID,
+	})
+}
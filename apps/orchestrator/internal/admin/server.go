@@ -0,0 +1,285 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/deadletter"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/ssh"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/metrics"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/quarantine"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// JobSource gives the admin API access to the jobs running on this orchestrator
+type JobSource interface {
+	// ActiveJobs returns the jobs currently being executed
+	ActiveJobs() []*types.Job
+
+	// GetActiveJob returns a running job by ID
+	GetActiveJob(jobID string) (*types.Job, bool)
+
+	// SampleJobStats returns the current resource usage of a running job
+	SampleJobStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error)
+
+	// JobState returns a job's local status and, while it is held before
+	// execution, the gate or approval it is waiting on
+	JobState(jobID string) (types.JobStatus, string)
+}
+
+// Server serves the operator admin API
+type Server struct {
+	config      config.AdminConfig
+	jobs        JobSource
+	log         *logrus.Logger
+	server      *http.Server
+	upgrader    websocket.Upgrader
+	features    *features.Registry
+	sandbox     *sandbox.Catalog
+	logs        *logger.Streamer
+	levels      *logger.Levels
+	metrics     *metrics.Collector
+	cfg         *config.Config
+	quarantine  *quarantine.List
+	trees       JobTrees
+	messenger   JobMessenger
+	runners     *ssh.RunnerCache
+	deadLetters *deadletter.List
+}
+
+// JobSummary describes a running job in admin responses
+type JobSummary struct {
+	ID        string          `json:"id"`
+	Type      types.JobType   `json:"type"`
+	Status    types.JobStatus `json:"status"`
+	WaitingOn string          `json:"waitingOn,omitempty"`
+	StartedAt *time.Time      `json:"startedAt,omitempty"`
+	Server    string          `json:"server,omitempty"`
+}
+
+// NewServer creates a new admin API server
+func NewServer(cfg config.AdminConfig, jobs JobSource, log *logrus.Logger) *Server {
+	return &Server{
+		config: cfg,
+		jobs:   jobs,
+		log:    log,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+		},
+	}
+}
+
+// WithFeatures enables the feature flag endpoints
+func (s *Server) WithFeatures(reg *features.Registry) *Server {
+	s.features = reg
+	return s
+}
+
+// WithSandbox enables the sandbox profile endpoint
+func (s *Server) WithSandbox(catalog *sandbox.Catalog) *Server {
+	s.sandbox = catalog
+	return s
+}
+
+// WithLogStreamer enables the log subscription stream
+func (s *Server) WithLogStreamer(streamer *logger.Streamer) *Server {
+	s.logs = streamer
+	return s
+}
+
+// WithLogLevels enables changing log levels at runtime
+func (s *Server) WithLogLevels(levels *logger.Levels) *Server {
+	s.levels = levels
+	return s
+}
+
+// WithMetrics enables resetting metrics
+func (s *Server) WithMetrics(collector *metrics.Collector) *Server {
+	s.metrics = collector
+	return s
+}
+
+// WithConfig enables the configuration dump
+func (s *Server) WithConfig(cfg *config.Config) *Server {
+	s.cfg = cfg
+	return s
+}
+
+// WithQuarantine enables listing and clearing quarantined events
+func (s *Server) WithQuarantine(list *quarantine.List) *Server {
+	s.quarantine = list
+	return s
+}
+
+// WithJobTrees enables the job tree and cancellation endpoints
+func (s *Server) WithJobTrees(trees JobTrees) *Server {
+	s.trees = trees
+	return s
+}
+
+// WithJobMessenger enables sending messages to running jobs
+func (s *Server) WithJobMessenger(messenger JobMessenger) *Server {
+	s.messenger = messenger
+	return s
+}
+
+// WithRunnerCache enables listing and forgetting deployed SSH runners
+func (s *Server) WithRunnerCache(cache *ssh.RunnerCache) *Server {
+	s.runners = cache
+	return s
+}
+
+// WithDeadLetters enables listing and clearing dead-lettered jobs
+func (s *Server) WithDeadLetters(list *deadletter.List) *Server {
+	s.deadLetters = list
+	return s
+}
+
+// Start starts the admin API HTTP server
+func (s *Server) Start() error {
+	if !s.config.Enabled {
+		s.log.Info("Admin API disabled")
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/jobs", s.handleListJobs)
+	mux.HandleFunc("GET /admin/jobs/{id}/stats", s.handleJobStats)
+	mux.HandleFunc("GET /admin/jobs/{id}/stats/stream", s.handleJobStatsStream)
+	mux.HandleFunc("GET /admin/jobs/{id}/tree", s.handleJobTree)
+	mux.HandleFunc("POST /admin/jobs/{id}/cancel", s.handleCancelJob)
+	mux.HandleFunc("POST /admin/jobs/{id}/messages", s.handleSendJobMessage)
+	mux.HandleFunc("GET /admin/features", s.handleListFeatures)
+	mux.HandleFunc("PUT /admin/features/{name}", s.handleSetFeature)
+	mux.HandleFunc("DELETE /admin/features/{name}", s.handleResetFeature)
+	mux.HandleFunc("GET /admin/sandbox/profiles", s.handleListSandboxProfiles)
+	mux.HandleFunc("GET /admin/logs/stream", s.handleLogStream)
+	mux.HandleFunc("GET /admin/log-level", s.handleGetLogLevels)
+	mux.HandleFunc("PUT /admin/log-level", s.handleSetLogLevel)
+	mux.HandleFunc("PUT /admin/log-level/{component}", s.handleSetLogLevel)
+	mux.HandleFunc("DELETE /admin/log-level/{component}", s.handleResetLogLevel)
+	mux.HandleFunc("POST /admin/metrics/reset", s.handleResetMetrics)
+	mux.HandleFunc("GET /admin/config", s.handleGetConfig)
+	mux.HandleFunc("GET /admin/quarantine", s.handleListQuarantine)
+	mux.HandleFunc("DELETE /admin/quarantine/{eventId}", s.handleClearQuarantine)
+	mux.HandleFunc("GET /admin/runners", s.handleListRunners)
+	mux.HandleFunc("DELETE /admin/runners/{serverId}", s.handleForgetRunner)
+	mux.HandleFunc("GET /admin/dead-letters", s.handleListDeadLetters)
+	mux.HandleFunc("DELETE /admin/dead-letters/{jobId}", s.handleClearDeadLetter)
+
+	s.server = &http.Server{
+		Addr:        fmt.Sprintf(":%d", s.config.Port),
+		Handler:     s.authenticate(mux),
+		ReadTimeout: 10 * time.Second,
+	}
+
+	s.log.WithField("port", s.config.Port).Info("Starting admin API server")
+	return s.server.ListenAndServe()
+}
+
+// Shutdown stops the admin API server
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	return s.server.Shutdown(ctx)
+}
+
+// authenticate rejects requests without the configured bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+			s.writeError(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleListJobs returns the jobs currently running on this orchestrator
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs := s.jobs.ActiveJobs()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].ID < jobs[j].ID })
+
+	summaries := make([]JobSummary, 0, len(jobs))
+	for _, job := range jobs {
+		summary := summarizeJob(job)
+		summary.Status, summary.WaitingOn = s.jobs.JobState(job.ID)
+		summaries = append(summaries, summary)
+	}
+
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobs":  summaries,
+		"count": len(summaries),
+	})
+}
+
+// handleListSandboxProfiles returns the sandbox profiles and, when a tenant
+// query parameter is given, whether that tenant may use each of them
+func (s *Server) handleListSandboxProfiles(w http.ResponseWriter, r *http.Request) {
+	if s.sandbox == nil {
+		s.writeError(w, http.StatusNotFound, "container execution is not available")
+		return
+	}
+
+	profiles := s.sandbox.Profiles()
+	response := map[string]interface{}{
+		"profiles": profiles,
+	}
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		allowed := make([]string, 0, len(profiles))
+		for _, p := range profiles {
+			if s.sandbox.Allowed(tenant, p.Name) {
+				allowed = append(allowed, p.Name)
+			}
+		}
+		response["tenant"] = tenant
+		response["allowed"] = allowed
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+// summarizeJob builds the admin view of a job
+func summarizeJob(job *types.Job) JobSummary {
+	summary := JobSummary{
+		ID:        job.ID,
+		Type:      job.Type,
+		StartedAt: job.StartedAt,
+	}
+	if job.Execution.Target.ServerDetails != nil {
+		summary.Server = job.Execution.Target.ServerDetails.Name
+	}
+	return summary
+}
+
+// writeJSON writes a JSON response
+func (s *Server) writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		s.log.WithError(err).Error("Failed to encode admin response")
+	}
+}
+
+// writeError writes an error response
+func (s *Server) writeError(w http.ResponseWriter, status int, message string) {
+	s.writeJSON(w, status, map[string]string{
+		"error":   http.StatusText(status),
+		"message": message,
+	})
+}
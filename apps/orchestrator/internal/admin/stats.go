@@ -0,0 +1,127 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// minStatsInterval bounds how often a stream may sample a job
+	minStatsInterval = time.Second
+	// statsWriteTimeout bounds each write to a stats stream
+	statsWriteTimeout = 10 * time.Second
+)
+
+// StatsMessage is sent over the live stats stream
+type StatsMessage struct {
+	Type    string                `json:"type"` // sample, error or complete
+	JobID   string                `json:"jobId"`
+	Sample  *types.ResourceSample `json:"sample,omitempty"`
+	Message string                `json:"message,omitempty"`
+}
+
+// handleJobStats returns a single resource usage sample for a running job
+func (s *Server) handleJobStats(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.jobs.GetActiveJob(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job is not running on this orchestrator")
+		return
+	}
+
+	sample, err := s.jobs.SampleJobStats(r.Context(), job)
+	if err != nil {
+		s.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to sample job stats")
+		s.writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	s.writeJSON(w, http.StatusOK, sample)
+}
+
+// handleJobStatsStream streams resource usage samples over a WebSocket until
+// the job finishes or the client disconnects
+func (s *Server) handleJobStatsStream(w http.ResponseWriter, r *http.Request) {
+	jobID := r.PathValue("id")
+	if _, ok := s.jobs.GetActiveJob(jobID); !ok {
+		s.writeError(w, http.StatusNotFound, "job is not running on this orchestrator")
+		return
+	}
+
+	interval := s.config.StatsInterval
+	if v := r.URL.Query().Get("interval"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "invalid interval")
+			return
+		}
+		interval = parsed
+	}
+	if interval < minStatsInterval {
+		interval = minStatsInterval
+	}
+
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to upgrade stats stream")
+		return
+	}
+	defer conn.Close()
+
+	// The server read timeout would otherwise close the stream
+	conn.SetReadDeadline(time.Time{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Drain client messages so close frames are processed
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, ok := s.jobs.GetActiveJob(jobID)
+		if !ok {
+			s.writeStatsMessage(conn, StatsMessage{Type: "complete", JobID: jobID})
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "job finished"),
+				time.Now().Add(statsWriteTimeout))
+			return
+		}
+
+		sampleCtx, sampleCancel := context.WithTimeout(ctx, interval+statsWriteTimeout)
+		sample, err := s.jobs.SampleJobStats(sampleCtx, job)
+		sampleCancel()
+
+		msg := StatsMessage{Type: "sample", JobID: jobID, Sample: sample}
+		if err != nil {
+			msg = StatsMessage{Type: "error", JobID: jobID, Message: err.Error()}
+		}
+		if err := s.writeStatsMessage(conn, msg); err != nil {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeStatsMessage writes a message to a stats stream
+func (s *Server) writeStatsMessage(conn *websocket.Conn, msg StatsMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(statsWriteTimeout))
+	return conn.WriteJSON(msg)
+}
@@ -0,0 +1,76 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/lineage"
+)
+
+// JobTrees gives the admin API access to the trees formed by jobs that
+// submit child jobs
+type JobTrees interface {
+	// JobTree returns the tree a job belongs to, from its root
+	JobTree(jobID string) (*lineage.Node, bool)
+
+	// CancelJob cancels a job and its descendants and returns the IDs of the
+	// jobs stopped on this orchestrator
+	CancelJob(ctx context.Context, jobID, reason string) ([]string, bool)
+}
+
+// CancelJobRequest is the optional body of a job cancellation
+type CancelJobRequest struct {
+	Reason string `json:"reason"`
+}
+
+// handleJobTree returns the tree of a job with the rollup status of every
+// subtree
+func (s *Server) handleJobTree(w http.ResponseWriter, r *http.Request) {
+	if s.trees == nil {
+		s.writeError(w, http.StatusNotFound, "job trees are not available")
+		return
+	}
+
+	tree, ok := s.trees.JobTree(r.PathValue("id"))
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job is not known to this orchestrator")
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tree": tree,
+	})
+}
+
+// handleCancelJob cancels a job together with the jobs below it
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	if s.trees == nil {
+		s.writeError(w, http.StatusNotFound, "job trees are not available")
+		return
+	}
+
+	var req CancelJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		s.writeError(w, http.StatusBadRequest, `body must be empty or {"reason": "..."}`)
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "cancelled through the admin API"
+	}
+
+	jobID := r.PathValue("id")
+	stopped, ok := s.trees.CancelJob(r.Context(), jobID, req.Reason)
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "job is not known to this orchestrator")
+		return
+	}
+
+	s.log.WithField("jobID", jobID).WithField("reason", req.Reason).Warn("Job cancelled through the admin API")
+	s.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jobId":   jobID,
+		"stopped": stopped,
+		"count":   len(stopped),
+	})
+}
@@ -0,0 +1,232 @@
+// Package analysis runs linters and scanners against job scripts before they
+// execute. Tools run in a tooling container; their findings are normalised
+// into a report that either travels with the execution (warn) or stops it
+// (block).
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// scriptEnvVar carries the script into the tooling container
+const scriptEnvVar = "CRONIUM_ANALYSIS_SCRIPT"
+
+// ToolRunner runs a command in a throwaway tooling container
+type ToolRunner interface {
+	RunTool(ctx context.Context, job *types.Job, name, image string, cmd, env []string) (stdout, stderr string, exitCode int, err error)
+}
+
+// tool describes how to run one linter and read its output
+type tool struct {
+	// Script types the tool understands; empty means all
+	scripts []types.ScriptType
+	command func(cfg config.AnalysisConfig, file string) string
+	parse   func(stdout string) ([]types.AnalysisFinding, error)
+}
+
+var tools = map[string]tool{
+	"shellcheck": {
+		scripts: []types.ScriptType{types.ScriptTypeBash},
+		command: func(_ config.AnalysisConfig, file string) string {
+			return "shellcheck -f json " + file
+		},
+		parse: parseShellcheck,
+	},
+	"bandit": {
+		scripts: []types.ScriptType{types.ScriptTypePython},
+		command: func(_ config.AnalysisConfig, file string) string {
+			return "bandit -f json -q " + file
+		},
+		parse: parseBandit,
+	},
+	"semgrep": {
+		command: func(cfg config.AnalysisConfig, file string) string {
+			return fmt.Sprintf("semgrep scan --json --quiet --metrics=off --config %s %s", shellQuote(cfg.SemgrepRules), file)
+		},
+		parse: parseSemgrep,
+	},
+}
+
+// Analyzer runs the configured tools against job scripts
+type Analyzer struct {
+	config config.AnalysisConfig
+	runner ToolRunner
+	log    *logrus.Logger
+}
+
+// NewAnalyzer creates an analyzer
+func NewAnalyzer(cfg config.AnalysisConfig, runner ToolRunner, log *logrus.Logger) *Analyzer {
+	return &Analyzer{
+		config: cfg,
+		runner: runner,
+		log:    log,
+	}
+}
+
+// modeStrictness orders the analysis modes from least to most strict
+var modeStrictness = map[string]int{
+	types.AnalysisModeOff:   0,
+	types.AnalysisModeWarn:  1,
+	types.AnalysisModeBlock: 2,
+}
+
+// Mode returns the analysis mode for a job: the configured mode if analysis
+// is enabled and off otherwise, unless the job asks for a stricter one. A
+// job cannot weaken the configured mode; an unknown mode is returned as is
+// so that Analyze rejects it.
+func (a *Analyzer) Mode(job *types.Job) string {
+	if job.Execution.Script == nil {
+		return types.AnalysisModeOff
+	}
+
+	mode := types.AnalysisModeOff
+	if a.config.Enabled {
+		mode = a.config.Mode
+	}
+	if job.Execution.Analysis != nil && job.Execution.Analysis.Mode != "" {
+		override := job.Execution.Analysis.Mode
+		strictness, known := modeStrictness[override]
+		if !known || strictness > modeStrictness[mode] {
+			return override
+		}
+	}
+	return mode
+}
+
+// Analyze runs every applicable tool against the job's script. It returns a
+// nil report when analysis is off for the job, and an ANALYSIS_BLOCKED error
+// alongside the report when block mode finds an issue at or above the
+// configured severity. Tools that fail to run are recorded in the report but
+// do not block.
+func (a *Analyzer) Analyze(ctx context.Context, job *types.Job) (*types.AnalysisReport, error) {
+	mode := a.Mode(job)
+	switch mode {
+	case types.AnalysisModeOff:
+		return nil, nil
+	case types.AnalysisModeWarn, types.AnalysisModeBlock:
+	default:
+		return nil, types.NewExecutionError("validation", "INVALID_ANALYSIS_MODE",
+			fmt.Sprintf("unknown analysis mode %q", mode), false)
+	}
+
+	if a.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.config.Timeout)
+		defer cancel()
+	}
+
+	script := job.Execution.Script
+	report := &types.AnalysisReport{
+		Mode:     mode,
+		Tools:    []string{},
+		Findings: []types.AnalysisFinding{},
+	}
+
+	for _, name := range a.config.Tools {
+		t, ok := tools[name]
+		if !ok || !t.supports(script.Type) {
+			continue
+		}
+		report.Tools = append(report.Tools, name)
+
+		findings, err := a.runTool(ctx, job, name, t)
+		if err != nil {
+			a.log.WithError(err).WithFields(logrus.Fields{"jobID": job.ID, "tool": name}).Warn("Analysis tool failed")
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	if mode != types.AnalysisModeBlock {
+		return report, nil
+	}
+
+	threshold := types.AnalysisSeverityRank(a.config.BlockSeverity)
+	var blocking []types.AnalysisFinding
+	for _, finding := range report.Findings {
+		if types.AnalysisSeverityRank(finding.Severity) >= threshold {
+			blocking = append(blocking, finding)
+		}
+	}
+	if len(blocking) == 0 {
+		return report, nil
+	}
+
+	report.Blocked = true
+	execErr := types.NewExecutionError("analysis", "ANALYSIS_BLOCKED",
+		fmt.Sprintf("static analysis found %d issue(s) at or above %s severity, first: %s", len(blocking), a.config.BlockSeverity, blocking[0].Describe()), false)
+	execErr.Details["analysis"] = report
+	return report, execErr
+}
+
+// runTool runs one tool in the tooling container
+func (a *Analyzer) runTool(ctx context.Context, job *types.Job, name string, t tool) ([]types.AnalysisFinding, error) {
+	if a.runner == nil {
+		return nil, fmt.Errorf("no container executor to run analysis tools")
+	}
+
+	file := "/tmp/script" + scriptExtension(job.Execution.Script.Type)
+	cmd := []string{"sh", "-c", fmt.Sprintf(`printf '%%s' "$%s" > %s && %s`, scriptEnvVar, file, t.command(a.config, file))}
+	env := []string{scriptEnvVar + "=" + job.Execution.Script.Content}
+
+	// Linters exit non-zero when they find something, so the exit code alone
+	// says nothing; output that does not parse is the failure signal
+	stdout, stderr, _, err := a.runner.RunTool(ctx, job, "analysis-"+name, a.config.Image, cmd, env)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := t.parse(stdout)
+	if err != nil {
+		return nil, fmt.Errorf("unreadable output: %w (stderr: %s)", err, truncate(strings.TrimSpace(stderr), 200))
+	}
+	for i := range findings {
+		findings[i].Tool = name
+	}
+	return findings, nil
+}
+
+// supports reports whether the tool handles a script type
+func (t tool) supports(scriptType types.ScriptType) bool {
+	if len(t.scripts) == 0 {
+		return true
+	}
+	for _, s := range t.scripts {
+		if s == scriptType {
+			return true
+		}
+	}
+	return false
+}
+
+// scriptExtension returns the file extension tools expect for a script type
+func scriptExtension(scriptType types.ScriptType) string {
+	switch scriptType {
+	case types.ScriptTypePython:
+		return ".py"
+	case types.ScriptTypeNode:
+		return ".js"
+	default:
+		return ".sh"
+	}
+}
+
+// shellQuote single-quotes a value for sh
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
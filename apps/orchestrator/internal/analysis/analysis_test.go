@@ -0,0 +1,38 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModeOverrideOnlyTightens(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		config   string
+		override string
+		want     string
+	}{
+		{"configured mode", true, types.AnalysisModeWarn, "", types.AnalysisModeWarn},
+		{"disabled", false, types.AnalysisModeBlock, "", types.AnalysisModeOff},
+		{"stricter override", true, types.AnalysisModeWarn, types.AnalysisModeBlock, types.AnalysisModeBlock},
+		{"override when disabled", false, types.AnalysisModeWarn, types.AnalysisModeWarn, types.AnalysisModeWarn},
+		{"off does not weaken block", true, types.AnalysisModeBlock, types.AnalysisModeOff, types.AnalysisModeBlock},
+		{"warn does not weaken block", true, types.AnalysisModeBlock, types.AnalysisModeWarn, types.AnalysisModeBlock},
+		{"unknown override", true, types.AnalysisModeWarn, "audit", "audit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalyzer(config.AnalysisConfig{Enabled: tt.enabled, Mode: tt.config}, nil, nil)
+			job := &types.Job{Execution: types.ExecutionConfig{Script: &types.Script{}}}
+			if tt.override != "" {
+				job.Execution.Analysis = &types.AnalysisPolicy{Mode: tt.override}
+			}
+			assert.Equal(t, tt.want, a.Mode(job))
+		})
+	}
+}
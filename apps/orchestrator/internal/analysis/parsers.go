@@ -0,0 +1,116 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// parseShellcheck reads `shellcheck -f json` output
+func parseShellcheck(stdout string) ([]types.AnalysisFinding, error) {
+	var results []struct {
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Level   string `json:"level"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		return nil, err
+	}
+
+	findings := make([]types.AnalysisFinding, 0, len(results))
+	for _, r := range results {
+		severity := types.AnalysisSeverityInfo
+		switch r.Level {
+		case "error":
+			severity = types.AnalysisSeverityError
+		case "warning":
+			severity = types.AnalysisSeverityWarning
+		}
+		findings = append(findings, types.AnalysisFinding{
+			RuleID:   fmt.Sprintf("SC%d", r.Code),
+			Severity: severity,
+			Message:  r.Message,
+			Line:     r.Line,
+			Column:   r.Column,
+		})
+	}
+	return findings, nil
+}
+
+// parseBandit reads `bandit -f json` output
+func parseBandit(stdout string) ([]types.AnalysisFinding, error) {
+	var output struct {
+		Results []struct {
+			TestID    string `json:"test_id"`
+			Severity  string `json:"issue_severity"`
+			Text      string `json:"issue_text"`
+			Line      int    `json:"line_number"`
+			ColOffset int    `json:"col_offset"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		return nil, err
+	}
+
+	findings := make([]types.AnalysisFinding, 0, len(output.Results))
+	for _, r := range output.Results {
+		severity := types.AnalysisSeverityInfo
+		switch strings.ToUpper(r.Severity) {
+		case "HIGH":
+			severity = types.AnalysisSeverityError
+		case "MEDIUM":
+			severity = types.AnalysisSeverityWarning
+		}
+		findings = append(findings, types.AnalysisFinding{
+			RuleID:   r.TestID,
+			Severity: severity,
+			Message:  r.Text,
+			Line:     r.Line,
+			Column:   r.ColOffset + 1,
+		})
+	}
+	return findings, nil
+}
+
+// parseSemgrep reads `semgrep --json` output
+func parseSemgrep(stdout string) ([]types.AnalysisFinding, error) {
+	var output struct {
+		Results []struct {
+			CheckID string `json:"check_id"`
+			Start   struct {
+				Line int `json:"line"`
+				Col  int `json:"col"`
+			} `json:"start"`
+			Extra struct {
+				Message  string `json:"message"`
+				Severity string `json:"severity"`
+			} `json:"extra"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		return nil, err
+	}
+
+	findings := make([]types.AnalysisFinding, 0, len(output.Results))
+	for _, r := range output.Results {
+		severity := types.AnalysisSeverityInfo
+		switch strings.ToUpper(r.Extra.Severity) {
+		case "ERROR":
+			severity = types.AnalysisSeverityError
+		case "WARNING":
+			severity = types.AnalysisSeverityWarning
+		}
+		findings = append(findings, types.AnalysisFinding{
+			RuleID:   r.CheckID,
+			Severity: severity,
+			Message:  r.Extra.Message,
+			Line:     r.Start.Line,
+			Column:   r.Start.Col,
+		})
+	}
+	return findings, nil
+}
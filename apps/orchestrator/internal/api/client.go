@@ -11,7 +11,10 @@ import (
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/masking"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/resolver"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/retry"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/sirupsen/logrus"
@@ -26,6 +29,9 @@ type Client struct {
 	token      string
 	log        *logrus.Logger
 
+	// Masks sensitive data in execution output before it is stored
+	masker *masking.Masker
+
 	// Deduplication for concurrent requests
 	requestGroup singleflight.Group
 }
@@ -57,8 +63,19 @@ func NewClient(cfg config.APIConfig, log *logrus.Logger) (*Client, error) {
 	}, nil
 }
 
+// WithResolver resolves the backend host through the caching resolver. It
+// must be called before WithMetrics wraps the transport.
+func (c *Client) WithResolver(r *resolver.Resolver) {
+	if r == nil {
+		return
+	}
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		transport.DialContext = r.DialContext
+	}
+}
+
 // PollJobs retrieves pending jobs from the queue
-func (c *Client) PollJobs(ctx context.Context, limit int) ([]*types.Job, error) {
+func (c *Client) PollJobs(ctx context.Context, limit int) (*PollResult, error) {
 	params := url.Values{}
 	params.Set("batchSize", fmt.Sprintf("%d", limit))
 
@@ -73,7 +90,10 @@ func (c *Client) PollJobs(ctx context.Context, limit int) ([]*types.Job, error)
 		jobs[i] = convertQueuedJob(qj)
 	}
 
-	return jobs, nil
+	return &PollResult{
+		Jobs:      jobs,
+		QueueSize: response.Metadata.QueueSize,
+	}, nil
 }
 
 // AcknowledgeJob confirms receipt of a job
@@ -105,9 +125,9 @@ func (c *Client) UpdateJobStatus(ctx context.Context, jobID string, status types
 
 	if details != nil {
 		req.Details = &StatusDetails{
-			Message:  details.Message,
+			Message:  redact.String(details.Message),
 			ExitCode: details.ExitCode,
-			Error:    details.Error,
+			Error:    redactErrorDetails(details.Error),
 		}
 	}
 
@@ -126,15 +146,33 @@ func (c *Client) UpdateJobStatus(ctx context.Context, jobID string, status types
 // CompleteJob marks a job as completed
 func (c *Client) CompleteJob(ctx context.Context, jobID string, req *CompleteJobRequest) error {
 	req.Timestamp = time.Now().Format(time.RFC3339)
+	req.Error = redactErrorDetails(req.Error)
 
 	var response interface{}
 	return c.post(ctx, fmt.Sprintf("/api/internal/jobs/%s/complete", jobID), req, &response)
 }
 
+// redactErrorDetails returns a copy of details with registered secrets
+// removed from the message
+func redactErrorDetails(details *types.ErrorDetails) *types.ErrorDetails {
+	if details == nil {
+		return nil
+	}
+	redacted := *details
+	redacted.Message = redact.String(details.Message)
+	return &redacted
+}
+
 // CreateExecution creates a new execution record
-func (c *Client) CreateExecution(ctx context.Context, executionID, jobID string, serverID *string, serverName *string) error {
+func (c *Client) CreateExecution(ctx context.Context, executionID string, job *types.Job, serverID *string, serverName *string) error {
 	req := map[string]interface{}{
-		"jobId": jobID,
+		"jobId": job.RecordJobID(),
+	}
+
+	// Links the execution to the job tree it belongs to
+	if parentID, rootID := job.SubmittedBy(); parentID != "" {
+		req["parentJobId"] = parentID
+		req["rootJobId"] = rootID
 	}
 
 	if serverID != nil {
@@ -177,6 +215,10 @@ func (c *Client) UpdateExecution(ctx context.Context, executionID string, status
 		"status": status,
 	}
 
+	if details != nil && c.masker != nil {
+		details = c.maskExecutionOutput(details)
+	}
+
 	if details != nil {
 		if details.StartedAt != nil {
 			req["startedAt"] = details.StartedAt.Format(time.RFC3339)
@@ -233,6 +275,40 @@ func (c *Client) UpdateExecution(ctx context.Context, executionID string, status
 	return c.put(ctx, fmt.Sprintf("/api/internal/executions/%s/update", executionID), req, &response)
 }
 
+// WithOutputMasker masks sensitive data in execution output sent to the
+// backend
+func (c *Client) WithOutputMasker(masker *masking.Masker) {
+	c.masker = masker
+}
+
+// maskExecutionOutput returns a copy of details with output and error masked,
+// flagged in the execution metadata when anything was found
+func (c *Client) maskExecutionOutput(details *ExecutionStatusUpdate) *ExecutionStatusUpdate {
+	masked := *details
+	detections := masking.Detections{}
+
+	if details.Output != nil {
+		output, found := c.masker.Mask(*details.Output)
+		masked.Output = &output
+		detections.Add(found)
+	}
+	if details.Error != nil {
+		errorStr, found := c.masker.Mask(*details.Error)
+		masked.Error = &errorStr
+		detections.Add(found)
+	}
+
+	if len(detections) > 0 {
+		metadata := make(map[string]interface{}, len(details.ExecutionMetadata)+1)
+		for k, v := range details.ExecutionMetadata {
+			metadata[k] = v
+		}
+		metadata["sensitiveDataDetected"] = detections.Names()
+		masked.ExecutionMetadata = metadata
+	}
+	return &masked
+}
+
 // ReportHealth sends a health report to the backend
 func (c *Client) ReportHealth(ctx context.Context, report *HealthReport) error {
 	report.Timestamp = time.Now().Format(time.RFC3339)
@@ -241,6 +317,92 @@ func (c *Client) ReportHealth(ctx context.Context, report *HealthReport) error {
 	return c.post(ctx, "/api/internal/orchestrator/health", report, &response)
 }
 
+// ReportUsage sends a usage report to the backend
+func (c *Client) ReportUsage(ctx context.Context, report *UsageReport) error {
+	var response interface{}
+	return c.post(ctx, "/api/internal/orchestrator/usage", report, &response)
+}
+
+// RegisterAgent registers this orchestrator's capacity with the backend and
+// returns the other orchestrators registered in the same region
+func (c *Client) RegisterAgent(ctx context.Context, reg *AgentRegistration) ([]Peer, error) {
+	reg.OrchestratorID = c.config.OrchestratorID
+	reg.Timestamp = time.Now().Format(time.RFC3339)
+
+	var response RegisterAgentResponse
+	if err := c.post(ctx, "/api/internal/orchestrator/register", reg, &response); err != nil {
+		return nil, fmt.Errorf("failed to register agent: %w", err)
+	}
+
+	return response.Peers, nil
+}
+
+// HandoffJob releases an acknowledged but unstarted job so that the target
+// orchestrator receives it on its next poll
+func (c *Client) HandoffJob(ctx context.Context, jobID, targetOrchestratorID, reason string) error {
+	req := HandoffRequest{
+		FromOrchestratorID:   c.config.OrchestratorID,
+		TargetOrchestratorID: targetOrchestratorID,
+		Reason:               reason,
+		Timestamp:            time.Now().Format(time.RFC3339),
+	}
+
+	var response interface{}
+	return c.post(ctx, fmt.Sprintf("/api/internal/jobs/%s/handoff", jobID), req, &response)
+}
+
+// RequestApproval asks the backend for a decision on a job and returns the
+// current state of the request
+func (c *Client) RequestApproval(ctx context.Context, jobID string, approval *types.Approval, expiresAt time.Time) (*types.ApprovalDecision, error) {
+	req := ApprovalRequest{
+		OrchestratorID: c.config.OrchestratorID,
+		Message:        approval.Message,
+		Approvers:      approval.Approvers,
+		ExpiresAt:      expiresAt.Format(time.RFC3339),
+		Timestamp:      time.Now().Format(time.RFC3339),
+	}
+
+	var decision types.ApprovalDecision
+	if err := c.post(ctx, fmt.Sprintf("/api/internal/jobs/%s/approval", jobID), req, &decision); err != nil {
+		return nil, fmt.Errorf("failed to request approval: %w", err)
+	}
+
+	return &decision, nil
+}
+
+// GetApproval fetches the current decision on a job's approval request
+func (c *Client) GetApproval(ctx context.Context, jobID string) (*types.ApprovalDecision, error) {
+	var decision types.ApprovalDecision
+	if err := c.get(ctx, fmt.Sprintf("/api/internal/jobs/%s/approval", jobID), nil, &decision); err != nil {
+		return nil, fmt.Errorf("failed to get approval: %w", err)
+	}
+
+	return &decision, nil
+}
+
+// GetServer fetches the connection details of a server
+func (c *Client) GetServer(ctx context.Context, serverID string) (*types.ServerDetails, error) {
+	var server types.ServerDetails
+	if err := c.get(ctx, fmt.Sprintf("/api/internal/servers/%s", serverID), nil, &server); err != nil {
+		return nil, fmt.Errorf("failed to get server: %w", err)
+	}
+
+	return &server, nil
+}
+
+// GetVariable fetches the current value of a user variable
+func (c *Client) GetVariable(ctx context.Context, userID, key string) (interface{}, error) {
+	var variable struct {
+		Key   string      `json:"key"`
+		Value interface{} `json:"value"`
+	}
+	if err := c.get(ctx, fmt.Sprintf("/api/internal/variables/%s/%s", userID, key), nil, &variable); err != nil {
+		return nil, fmt.Errorf("failed to get variable: %w", err)
+	}
+
+	return variable.Value, nil
+}
+
 // HealthCheck performs a health check on the API
 func (c *Client) HealthCheck(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -411,6 +573,29 @@ func convertQueuedJob(qj QueuedJob) *types.Job {
 		Timeout:     time.Duration(qj.Execution.Timeout) * time.Second,
 		InputData:   qj.Execution.InputData,
 		Variables:   qj.Execution.Variables,
+
+		SensitiveVariables: qj.Execution.SensitiveVariables,
+
+		StopSignal:             qj.Execution.StopSignal,
+		TerminationGracePeriod: time.Duration(qj.Execution.TerminationGracePeriod) * time.Second,
+
+		Parameters:      qj.Execution.Parameters,
+		ParameterValues: qj.Execution.ParameterValues,
+		Matrix:          qj.Execution.Matrix,
+
+		Analysis: qj.Execution.Analysis,
+
+		SandboxProfile:    qj.Execution.SandboxProfile,
+		WritablePaths:     qj.Execution.WritablePaths,
+		ImageScanOverride: qj.Execution.ImageScanOverride,
+		ImagePullPolicy:   types.ImagePullPolicy(qj.Execution.ImagePullPolicy),
+		SnapshotOnFailure: qj.Execution.SnapshotOnFailure,
+		Locale:            qj.Execution.Locale,
+		Resume:            qj.Execution.Resume,
+		DryRun:            qj.Execution.DryRun,
+		ConcurrencyPolicy: types.ConcurrencyPolicy(qj.Execution.ConcurrencyPolicy),
+		Files:             qj.Execution.Files,
+		InputRefs:         qj.Execution.InputRefs,
 	}
 
 	// Set target
@@ -429,6 +614,19 @@ func convertQueuedJob(qj QueuedJob) *types.Job {
 		}
 	}
 
+	// Set HTTP request if present
+	if h := qj.Execution.HTTP; h != nil {
+		job.Execution.HTTP = &types.HTTPConfig{
+			Method:       h.Method,
+			URL:          h.URL,
+			Headers:      h.Headers,
+			Body:         h.Body,
+			ExpectStatus: h.ExpectStatus,
+			Retries:      h.Retries,
+			RetryDelay:   time.Duration(h.RetryDelay) * time.Second,
+		}
+	}
+
 	// Set resources if present
 	if qj.Execution.Resources != nil {
 		job.Execution.Resources = &types.Resources{
@@ -448,6 +646,57 @@ func convertQueuedJob(qj QueuedJob) *types.Job {
 		}
 	}
 
+	// Set gates if present
+	for _, g := range qj.Execution.Gates {
+		job.Execution.Gates = append(job.Execution.Gates, types.Gate{
+			Type:         types.GateType(g.Type),
+			Name:         g.Name,
+			URL:          g.URL,
+			ExpectStatus: g.ExpectStatus,
+			Variable:     g.Variable,
+			Equals:       g.Equals,
+			Path:         g.Path,
+			Interval:     time.Duration(g.Interval) * time.Second,
+			Timeout:      time.Duration(g.Timeout) * time.Second,
+		})
+	}
+
+	// Set approval if present
+	if a := qj.Execution.Approval; a != nil {
+		job.Execution.Approval = &types.Approval{
+			Message:   a.Message,
+			Approvers: a.Approvers,
+			Expiry:    time.Duration(a.Expiry) * time.Second,
+		}
+	}
+
+	// Set deadline if present
+	if d := qj.Execution.Deadline; d != nil {
+		job.Execution.Deadline = &types.Deadline{
+			At:          d.At,
+			Time:        d.Time,
+			TZ:          d.TZ,
+			MinDuration: time.Duration(d.MinDuration) * time.Second,
+		}
+	}
+
+	// Set fan-out if present
+	if f := qj.Execution.FanOut; f != nil {
+		job.Execution.FanOut = &types.FanOut{
+			MaxParallel:      f.MaxParallel,
+			BatchSize:        f.BatchSize,
+			BatchPause:       time.Duration(f.BatchPause) * time.Second,
+			FailureThreshold: f.FailureThreshold,
+		}
+	}
+
+	// Set sticky target if present
+	if s := qj.Execution.StickyTarget; s != nil {
+		job.Execution.StickyTarget = &types.StickyTarget{
+			TTL: time.Duration(s.TTL) * time.Second,
+		}
+	}
+
 	// Set timeout from config
 	job.Timeout = job.GetTimeout()
 
@@ -471,6 +720,37 @@ func convertServerDetails(sd *ServerDetails) *types.ServerDetails {
 	}
 }
 
+// PollCancellations returns the jobs of this orchestrator the backend has
+// cancelled since the given backend time, and the time to ask from next. An
+// empty since asks for the recent cancellations. The backend repeats a
+// cancellation on a few polls, so callers handle each job once.
+func (c *Client) PollCancellations(ctx context.Context, since string) ([]CancelRequest, string, error) {
+	params := url.Values{}
+	params.Set("orchestratorId", c.config.OrchestratorID)
+	if since != "" {
+		params.Set("since", since)
+	}
+
+	var response CancellationsResponse
+	if err := c.get(ctx, "/api/internal/jobs/cancellations", params, &response); err != nil {
+		return nil, since, fmt.Errorf("failed to poll cancellations: %w", err)
+	}
+
+	requests := make([]CancelRequest, 0, len(response.Cancellations))
+	for _, cancellation := range response.Cancellations {
+		reason := cancellation.Reason
+		if reason == "" {
+			reason = backendCancelReason
+		}
+		requests = append(requests, CancelRequest{JobID: cancellation.JobID, Reason: reason})
+	}
+	next := response.AsOf
+	if next == "" {
+		next = since
+	}
+	return requests, next, nil
+}
+
 // GetOrphanedJobs gets jobs that were claimed by a specific orchestrator
 func (c *Client) GetOrphanedJobs(ctx context.Context, orchestratorID string) ([]*types.Job, error) {
 	params := url.Values{}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertQueuedJobSensitiveVariables(t *testing.T) {
+	var queued QueuedJob
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": "job-1",
+		"type": "ssh",
+		"execution": {
+			"variables": {"region": "eu", "db_password": "hunter2"},
+			"sensitiveVariables": ["db_password"]
+		}
+	}`), &queued))
+	assert.Equal(t, []string{"db_password"}, convertQueuedJob(queued).Execution.SensitiveVariables)
+
+	// An empty list survives the job's own encoding; a missing one stays nil
+	for _, list := range [][]string{{}, nil} {
+		queued.Execution.SensitiveVariables = list
+		data, err := json.Marshal(convertQueuedJob(queued))
+		require.NoError(t, err)
+		var job types.Job
+		require.NoError(t, json.Unmarshal(data, &job))
+		assert.Equal(t, list == nil, job.Execution.SensitiveVariables == nil)
+	}
+}
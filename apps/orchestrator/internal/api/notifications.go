@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"time"
+)
+
+// Notification severities
+const (
+	NotificationInfo     = "info"
+	NotificationWarning  = "warning"
+	NotificationCritical = "critical"
+)
+
+// Notification is delivered to an event's owner through the backend's
+// notification channels
+type Notification struct {
+	Type           string                 `json:"type"`
+	Severity       string                 `json:"severity"`
+	Title          string                 `json:"title"`
+	Message        string                 `json:"message"`
+	EventID        string                 `json:"eventId,omitempty"`
+	JobID          string                 `json:"jobId,omitempty"`
+	OrchestratorID string                 `json:"orchestratorId"`
+	Details        map[string]interface{} `json:"details,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+}
+
+// Notify sends a notification through the backend
+func (c *Client) Notify(ctx context.Context, n *Notification) error {
+	var response interface{}
+	return c.post(ctx, "/api/internal/notifications", n, &response)
+}
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+)
+
+// Push channel timing
+const (
+	pushHandshakeTimeout = 10 * time.Second
+	pushWriteTimeout     = 10 * time.Second
+	pushPingInterval     = 30 * time.Second
+	pushReadTimeout      = 2 * pushPingInterval
+	// Pushed jobs waiting to be taken; the backend pushes no more than the
+	// announced capacity, so the buffer only fills if it ignores that
+	pushBuffer = 100
+)
+
+// PushMessage is a message on the job push channel. The orchestrator sends
+// hello once connected, ready when its free capacity changes and ack for
+// each pushed job it takes; the backend sends job messages and answers each
+// ack with an ack carrying the outcome. The backend cancels a job with a
+// cancel message, which the orchestrator answers with a cancel message whose
+// success tells whether it knew the job.
+type PushMessage struct {
+	Type           string     `json:"type"`
+	OrchestratorID string     `json:"orchestratorId,omitempty"`
+	Capacity       *int       `json:"capacity,omitempty"`
+	JobID          string     `json:"jobId,omitempty"`
+	Job            *QueuedJob `json:"job,omitempty"`
+	Success        bool       `json:"success,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	Reason         string     `json:"reason,omitempty"`
+	Stopped        []string   `json:"stopped,omitempty"`
+	Timestamp      string     `json:"timestamp,omitempty"`
+}
+
+// JobPush keeps the WebSocket on which the backend pushes jobs connected
+type JobPush struct {
+	client  *Client
+	cfg     config.JobPushConfig
+	log     *logrus.Logger
+	jobs    chan *types.Job
+	cancels chan CancelRequest
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	capacity  int
+	announced int
+	acks      map[string]chan PushMessage
+}
+
+// NewJobPush creates the push channel. It returns nil when push is
+// disabled; a nil JobPush never connects and delivers no jobs.
+func (c *Client) NewJobPush(cfg config.JobPushConfig) *JobPush {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &JobPush{
+		client:    c,
+		cfg:       cfg,
+		log:       c.log,
+		jobs:      make(chan *types.Job, pushBuffer),
+		cancels:   make(chan CancelRequest, pushBuffer),
+		announced: -1,
+		acks:      make(map[string]chan PushMessage),
+	}
+}
+
+// Jobs returns the pushed jobs. Each must be acknowledged with Acknowledge
+// before it runs.
+func (p *JobPush) Jobs() <-chan *types.Job {
+	if p == nil {
+		return nil
+	}
+	return p.jobs
+}
+
+// backendCancelReason is the reason of backend cancellations that give none
+const backendCancelReason = "cancelled by the backend"
+
+// CancelRequest asks the orchestrator to cancel a job
+type CancelRequest struct {
+	JobID  string
+	Reason string
+}
+
+// Cancels returns the backend's job cancellations. Each must be answered
+// with CancelResult.
+func (p *JobPush) Cancels() <-chan CancelRequest {
+	if p == nil {
+		return nil
+	}
+	return p.cancels
+}
+
+// CancelResult tells the backend the outcome of a cancellation: the jobs
+// stopped here, or that the job is not known to this orchestrator
+func (p *JobPush) CancelResult(jobID string, stopped []string, known bool) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	conn := p.conn
+	p.mu.Unlock()
+	if conn == nil {
+		return
+	}
+	err := p.write(conn, PushMessage{
+		Type:           "cancel",
+		OrchestratorID: p.client.config.OrchestratorID,
+		JobID:          jobID,
+		Success:        known,
+		Stopped:        stopped,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		p.log.WithError(err).WithField("jobID", jobID).Debug("Failed to send cancellation result on job push channel")
+	}
+}
+
+// Connected reports whether the channel is up
+func (p *JobPush) Connected() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn != nil
+}
+
+// Run connects the channel and reconnects it with backoff until ctx is done
+func (p *JobPush) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+	delay := p.cfg.ReconnectDelay
+	for {
+		conn, err := p.dial(ctx)
+		if err == nil {
+			delay = p.cfg.ReconnectDelay
+			p.log.Info("Job push channel connected")
+			err = p.serve(ctx, conn)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		p.log.WithError(err).WithField("retryIn", delay).Warn("Job push channel down; polling until it reconnects")
+
+		// Up to a fifth of the delay is added so that orchestrators do not
+		// reconnect in lockstep after a backend restart
+		wait := delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, p.cfg.MaxReconnectDelay)
+	}
+}
+
+// dial opens the WebSocket and announces the orchestrator
+func (p *JobPush) dial(ctx context.Context) (*websocket.Conn, error) {
+	u, err := url.Parse(p.cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid push endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("orchestratorId", p.client.config.OrchestratorID)
+	u.RawQuery = q.Encode()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+p.client.token)
+	header.Set("X-Service-Name", "cronium-orchestrator")
+	header.Set("X-Orchestrator-ID", p.client.config.OrchestratorID)
+
+	dialer := websocket.Dialer{HandshakeTimeout: pushHandshakeTimeout}
+	conn, _, err := dialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect push channel: %w", err)
+	}
+
+	p.mu.Lock()
+	capacity := p.capacity
+	p.mu.Unlock()
+	if err := p.write(conn, PushMessage{Type: "hello", OrchestratorID: p.client.config.OrchestratorID, Capacity: &capacity}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.conn = conn
+	p.announced = capacity
+	p.mu.Unlock()
+	return conn, nil
+}
+
+// serve reads messages until the connection fails or ctx is done
+func (p *JobPush) serve(ctx context.Context, conn *websocket.Conn) error {
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		p.mu.Lock()
+		p.conn = nil
+		// Waiting acknowledgements fall back to HTTP
+		for id, ch := range p.acks {
+			close(ch)
+			delete(p.acks, id)
+		}
+		p.mu.Unlock()
+		conn.Close()
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(pushReadTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pushReadTimeout))
+	})
+	go func() {
+		ticker := time.NewTicker(pushPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				p.writeMu.Lock()
+				conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pushWriteTimeout))
+				p.writeMu.Unlock()
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		var msg PushMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			p.log.WithError(err).Warn("Ignoring malformed job push message")
+			continue
+		}
+
+		switch msg.Type {
+		case "job":
+			if msg.Job == nil {
+				continue
+			}
+			// Reading must go on while jobs wait, as their acknowledgements
+			// are answered on this connection. A job that does not fit is
+			// left unacknowledged for the backend to hand out again.
+			select {
+			case p.jobs <- convertQueuedJob(*msg.Job):
+			default:
+				p.log.WithField("jobID", msg.Job.ID).Warn("Job push buffer full; leaving pushed job unacknowledged")
+			}
+		case "cancel":
+			if msg.JobID == "" {
+				continue
+			}
+			reason := msg.Reason
+			if reason == "" {
+				reason = backendCancelReason
+			}
+			select {
+			case p.cancels <- CancelRequest{JobID: msg.JobID, Reason: reason}:
+			default:
+				p.log.WithField("jobID", msg.JobID).Warn("Job push buffer full; dropping cancellation")
+			}
+		case "ack":
+			p.mu.Lock()
+			ch, ok := p.acks[msg.JobID]
+			delete(p.acks, msg.JobID)
+			p.mu.Unlock()
+			if ok {
+				ch <- msg
+			}
+		default:
+			p.log.WithField("type", msg.Type).Debug("Ignoring unknown job push message")
+		}
+	}
+}
+
+// Ready tells the backend how many more jobs the orchestrator can take. It
+// is sent only when the number changed.
+func (p *JobPush) Ready(capacity int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.capacity = capacity
+	conn := p.conn
+	if conn == nil || p.announced == capacity {
+		p.mu.Unlock()
+		return
+	}
+	p.announced = capacity
+	p.mu.Unlock()
+
+	if err := p.write(conn, PushMessage{Type: "ready", Capacity: &capacity}); err != nil {
+		p.log.WithError(err).Debug("Failed to send capacity on job push channel")
+	}
+}
+
+// Acknowledge confirms receipt of a pushed job on the channel. When the
+// channel is down or the backend does not answer within the ack timeout,
+// the job is acknowledged over HTTP instead.
+func (p *JobPush) Acknowledge(ctx context.Context, jobID string) error {
+	if reply, ok := p.acknowledge(ctx, jobID); ok {
+		if !reply.Success {
+			return fmt.Errorf("failed to acknowledge job: %s", reply.Error)
+		}
+		return nil
+	}
+	return p.client.AcknowledgeJob(ctx, jobID)
+}
+
+// acknowledge sends an ack on the channel and waits for the answer; ok is
+// false when there was none
+func (p *JobPush) acknowledge(ctx context.Context, jobID string) (reply PushMessage, ok bool) {
+	p.mu.Lock()
+	conn := p.conn
+	if conn == nil {
+		p.mu.Unlock()
+		return reply, false
+	}
+	ch := make(chan PushMessage, 1)
+	p.acks[jobID] = ch
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		delete(p.acks, jobID)
+		p.mu.Unlock()
+	}()
+
+	err := p.write(conn, PushMessage{
+		Type:           "ack",
+		OrchestratorID: p.client.config.OrchestratorID,
+		JobID:          jobID,
+		Timestamp:      time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return reply, false
+	}
+
+	timer := time.NewTimer(p.cfg.AckTimeout)
+	defer timer.Stop()
+	select {
+	case reply, ok = <-ch:
+		return reply, ok
+	case <-timer.C:
+		p.log.WithField("jobID", jobID).Warn("No acknowledgement answer on job push channel; acknowledging over HTTP")
+		return reply, false
+	case <-ctx.Done():
+		return reply, false
+	}
+}
+
+// write sends a message; writes to a connection must not run concurrently
+func (p *JobPush) write(conn *websocket.Conn, msg PushMessage) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(pushWriteTimeout))
+	return conn.WriteJSON(msg)
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobPush(t *testing.T) {
+	received := make(chan PushMessage, 10)
+	var httpAcks int
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/acknowledge") {
+			httpAcks++
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		assert.Equal(t, "orch-1", r.URL.Query().Get("orchestratorId"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		conn.WriteJSON(PushMessage{Type: "job", Job: &QueuedJob{ID: "job_1", Type: "container"}})
+		conn.WriteJSON(PushMessage{Type: "cancel", JobID: "job_1"})
+		for {
+			var msg PushMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			received <- msg
+			if msg.Type == "ack" {
+				conn.WriteJSON(PushMessage{Type: "ack", JobID: msg.JobID, Success: msg.JobID == "job_1", Error: "taken"})
+			}
+		}
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	client, err := NewClient(config.APIConfig{Endpoint: server.URL, Token: "token", OrchestratorID: "orch-1"}, log)
+	require.NoError(t, err)
+	push := client.NewJobPush(config.JobPushConfig{
+		Enabled:           true,
+		Endpoint:          "ws" + strings.TrimPrefix(server.URL, "http") + "/socket/jobs",
+		ReconnectDelay:    10 * time.Millisecond,
+		MaxReconnectDelay: 10 * time.Millisecond,
+		AckTimeout:        time.Second,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	push.Ready(3)
+	go push.Run(ctx)
+
+	hello := <-received
+	assert.Equal(t, "hello", hello.Type)
+	assert.Equal(t, 3, *hello.Capacity)
+
+	job := <-push.Jobs()
+	assert.Equal(t, "job_1", job.ID)
+	require.NoError(t, push.Acknowledge(ctx, job.ID))
+	assert.Equal(t, "ack", (<-received).Type)
+	assert.Error(t, push.Acknowledge(ctx, "job_2"))
+	<-received
+
+	cancelReq := <-push.Cancels()
+	assert.Equal(t, CancelRequest{JobID: "job_1", Reason: "cancelled by the backend"}, cancelReq)
+	push.CancelResult(cancelReq.JobID, []string{"job_1"}, true)
+	result := <-received
+	assert.Equal(t, "cancel", result.Type)
+	assert.True(t, result.Success)
+	assert.Equal(t, []string{"job_1"}, result.Stopped)
+
+	// Capacity is only sent when it changes
+	push.Ready(2)
+	push.Ready(2)
+	ready := <-received
+	assert.Equal(t, "ready", ready.Type)
+	assert.Equal(t, 2, *ready.Capacity)
+	assert.Empty(t, received)
+	assert.True(t, push.Connected())
+	assert.Zero(t, httpAcks)
+
+	// Without the channel jobs are acknowledged over HTTP
+	var disabled *JobPush
+	assert.Nil(t, disabled.Jobs())
+	cancel()
+	require.Eventually(t, func() bool { return !push.Connected() }, time.Second, 10*time.Millisecond)
+	require.NoError(t, push.Acknowledge(context.Background(), "job_3"))
+	assert.Equal(t, 1, httpAcks)
+
+	data, _ := json.Marshal(PushMessage{Type: "ready", Capacity: new(int)})
+	assert.JSONEq(t, `{"type": "ready", "capacity": 0}`, string(data))
+}
+
+func TestPollCancellations(t *testing.T) {
+	var sinces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/internal/jobs/cancellations", r.URL.Path)
+		assert.Equal(t, "orch-1", r.URL.Query().Get("orchestratorId"))
+		sinces = append(sinces, r.URL.Query().Get("since"))
+		w.Write([]byte(`{"cancellations": [{"jobId": "job_1", "cancelledAt": "2026-10-16T12:00:00Z"},
+			{"jobId": "job_2", "reason": "stopped by ops", "cancelledAt": "2026-10-16T12:00:01Z"}],
+			"asOf": "2026-10-16T12:00:05Z"}`))
+	}))
+	defer server.Close()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	client, err := NewClient(config.APIConfig{Endpoint: server.URL, Token: "token", OrchestratorID: "orch-1"}, log)
+	require.NoError(t, err)
+
+	requests, next, err := client.PollCancellations(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []CancelRequest{
+		{JobID: "job_1", Reason: "cancelled by the backend"},
+		{JobID: "job_2", Reason: "stopped by ops"},
+	}, requests)
+	assert.Equal(t, "2026-10-16T12:00:05Z", next)
+
+	_, _, err = client.PollCancellations(context.Background(), next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "2026-10-16T12:00:05Z"}, sinces)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"context"
+	"fmt"
+)
+
+// TriggerRequest asks the backend to queue a job for an event fired by a
+// local trigger source
+type TriggerRequest struct {
+	Source  string                 `json:"source"`
+	Trigger string                 `json:"trigger"`
+	Input   map[string]interface{} `json:"input,omitempty"`
+}
+
+// TriggerResponse identifies the job queued for a trigger
+type TriggerResponse struct {
+	JobID string `json:"jobId"`
+	LogID int64  `json:"logId"`
+}
+
+// TriggerEvent queues a job for an event with the trigger payload as its
+// input data
+func (c *Client) TriggerEvent(ctx context.Context, eventID string, req *TriggerRequest) (*TriggerResponse, error) {
+	var resp TriggerResponse
+	path := fmt.Sprintf("/api/internal/events/%s/trigger", eventID)
+	if err := c.post(ctx, path, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/receipt"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 )
 
@@ -18,6 +19,23 @@ type PollJobsResponse struct {
 	} `json:"metadata"`
 }
 
+// CancellationsResponse lists the jobs of an orchestrator the backend has
+// cancelled, and the backend time to ask from on the next poll
+type CancellationsResponse struct {
+	Cancellations []struct {
+		JobID       string    `json:"jobId"`
+		Reason      string    `json:"reason,omitempty"`
+		CancelledAt time.Time `json:"cancelledAt"`
+	} `json:"cancellations"`
+	AsOf string `json:"asOf"`
+}
+
+// PollResult contains the jobs returned by a poll and the backend's queue state
+type PollResult struct {
+	Jobs      []*types.Job
+	QueueSize int
+}
+
 // QueuedJob represents a job from the API
 type QueuedJob struct {
 	ID           string                 `json:"id"`
@@ -37,10 +55,110 @@ type ExecutionConfig struct {
 	HTTP        *HTTPConfig            `json:"http,omitempty"`
 	Environment map[string]string      `json:"environment"`
 	Timeout     int                    `json:"timeout"` // seconds
+	Deadline    *Deadline              `json:"deadline,omitempty"`
 	Resources   *Resources             `json:"resources,omitempty"`
 	RetryPolicy *RetryPolicy           `json:"retryPolicy,omitempty"`
 	InputData   map[string]interface{} `json:"inputData,omitempty"`
 	Variables   map[string]interface{} `json:"variables,omitempty"`
+	// Keys of the user's variables flagged sensitive; absent when the
+	// backend did not send the list
+	SensitiveVariables []string `json:"sensitiveVariables,omitempty"`
+
+	// Stop behaviour (container jobs)
+	StopSignal             string `json:"stopSignal,omitempty"`
+	TerminationGracePeriod int    `json:"terminationGracePeriod,omitempty"` // seconds
+
+	// Job parameters
+	Parameters      []types.Parameter      `json:"parameters,omitempty"`
+	ParameterValues map[string]interface{} `json:"parameterValues,omitempty"`
+	Matrix          *types.Matrix          `json:"matrix,omitempty"`
+
+	// Pre-execution gates
+	Gates    []Gate    `json:"gates,omitempty"`
+	Approval *Approval `json:"approval,omitempty"`
+
+	// Static analysis mode override
+	Analysis *types.AnalysisPolicy `json:"analysis,omitempty"`
+
+	// Named sandbox profile (container jobs)
+	SandboxProfile string `json:"sandboxProfile,omitempty"`
+
+	// Writable paths under a read-only root filesystem (container jobs)
+	WritablePaths []types.WritablePath `json:"writablePaths,omitempty"`
+
+	// Reason for running a blocked image (container jobs)
+	ImageScanOverride string `json:"imageScanOverride,omitempty"`
+
+	// Always, IfNotPresent or Never (container jobs)
+	ImagePullPolicy string `json:"imagePullPolicy,omitempty"`
+
+	// Keep the workspace of a failed run (SSH jobs)
+	SnapshotOnFailure *bool `json:"snapshotOnFailure,omitempty"`
+
+	// Clock and locale overrides
+	Locale *types.Locale `json:"locale,omitempty"`
+
+	// Checkpoint to resume from (SSH jobs)
+	Resume *types.ResumeHints `json:"resume,omitempty"`
+
+	// Syntax check only (SSH jobs)
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Batching and fail-fast across servers (multi-server SSH jobs)
+	FanOut *FanOut `json:"fanOut,omitempty"`
+
+	// Prefer the server the event last ran on (multi-server SSH jobs)
+	StickyTarget *StickyTarget `json:"stickyTarget,omitempty"`
+
+	// allow, forbid or replace overlapping runs of the event
+	ConcurrencyPolicy string `json:"concurrencyPolicy,omitempty"`
+
+	// Extra payload files and artifacts (SSH jobs)
+	Files *types.PayloadFiles `json:"files,omitempty"`
+
+	// Large input objects fetched by the runner (SSH jobs)
+	InputRefs []types.InputRef `json:"inputRefs,omitempty"`
+}
+
+// Gate from API
+type Gate struct {
+	Type         string      `json:"type"`
+	Name         string      `json:"name,omitempty"`
+	URL          string      `json:"url,omitempty"`
+	ExpectStatus int         `json:"expectStatus,omitempty"`
+	Variable     string      `json:"variable,omitempty"`
+	Equals       interface{} `json:"equals,omitempty"`
+	Path         string      `json:"path,omitempty"`
+	Interval     int         `json:"interval,omitempty"` // seconds
+	Timeout      int         `json:"timeout,omitempty"`  // seconds
+}
+
+// Deadline from API
+type Deadline struct {
+	At          *time.Time `json:"at,omitempty"`
+	Time        string     `json:"time,omitempty"`
+	TZ          string     `json:"tz,omitempty"`
+	MinDuration int        `json:"minDuration,omitempty"` // seconds
+}
+
+// FanOut from API
+type FanOut struct {
+	MaxParallel      int `json:"maxParallel,omitempty"`
+	BatchSize        int `json:"batchSize,omitempty"`
+	BatchPause       int `json:"batchPause,omitempty"` // seconds
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+}
+
+// StickyTarget from API
+type StickyTarget struct {
+	TTL int `json:"ttl,omitempty"` // seconds
+}
+
+// Approval from API
+type Approval struct {
+	Message   string   `json:"message,omitempty"`
+	Approvers []string `json:"approvers,omitempty"`
+	Expiry    int      `json:"expiry,omitempty"` // seconds
 }
 
 // Target from API
@@ -71,10 +189,13 @@ type Script struct {
 
 // HTTPConfig from API
 type HTTPConfig struct {
-	Method  string            `json:"method"`
-	URL     string            `json:"url"`
-	Headers map[string]string `json:"headers,omitempty"`
-	Body    interface{}       `json:"body,omitempty"`
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         interface{}       `json:"body,omitempty"`
+	ExpectStatus []int             `json:"expectStatus,omitempty"`
+	Retries      int               `json:"retries,omitempty"`
+	RetryDelay   int               `json:"retryDelay,omitempty"` // seconds
 }
 
 // Resources from API
@@ -151,7 +272,15 @@ type CompleteJobRequest struct {
 	Output    Output                 `json:"output"`
 	Artifacts *Artifacts             `json:"artifacts,omitempty"`
 	Metrics   types.ExecutionMetrics `json:"metrics"`
-	Timestamp string                 `json:"timestamp"`
+	Receipt   *receipt.Signed        `json:"receipt,omitempty"`
+	Error     *types.ErrorDetails    `json:"error,omitempty"`
+	// Where an interrupted job can be resumed from
+	Resume *types.ResumeHints `json:"resume,omitempty"`
+	// Output detectors that matched; the output was masked
+	SensitiveDataDetected []string `json:"sensitiveDataDetected,omitempty"`
+	// Added to the execution record's metadata, such as hook results
+	ExecutionMetadata map[string]interface{} `json:"executionMetadata,omitempty"`
+	Timestamp         string                 `json:"timestamp"`
 }
 
 // Output contains job output
@@ -187,6 +316,76 @@ type HealthReport struct {
 	Metrics        map[string]interface{}     `json:"metrics"`
 }
 
+// UsageReport is the resource usage of the jobs that finished on this
+// orchestrator during a report period, per tenant and event
+type UsageReport struct {
+	OrchestratorID string       `json:"orchestratorId"`
+	PeriodStart    time.Time    `json:"periodStart"`
+	PeriodEnd      time.Time    `json:"periodEnd"`
+	Entries        []UsageEntry `json:"entries"`
+}
+
+// UsageEntry is the usage of one tenant's jobs for one event
+type UsageEntry struct {
+	Tenant          string  `json:"tenant"`
+	EventID         string  `json:"eventId,omitempty"`
+	Executions      int     `json:"executions"`
+	Failures        int     `json:"failures"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	CPUSeconds      float64 `json:"cpuSeconds"`
+	MemoryGBHours   float64 `json:"memoryGbHours"`
+	TransferBytes   int64   `json:"transferBytes"`
+}
+
+// AgentRegistration announces an orchestrator and its capacity to the backend
+type AgentRegistration struct {
+	OrchestratorID string `json:"orchestratorId"`
+	Region         string `json:"region"`
+	MaxConcurrent  int    `json:"maxConcurrent"`
+	ActiveJobs     int    `json:"activeJobs"`
+	PendingJobs    int    `json:"pendingJobs"`
+	WorkStealing   bool   `json:"workStealing"`
+	Timestamp      string `json:"timestamp"`
+}
+
+// RegisterAgentResponse lists the peers registered in the same region
+type RegisterAgentResponse struct {
+	Peers []Peer `json:"peers"`
+}
+
+// Peer is another orchestrator known to the backend
+type Peer struct {
+	OrchestratorID string    `json:"orchestratorId"`
+	Region         string    `json:"region"`
+	MaxConcurrent  int       `json:"maxConcurrent"`
+	ActiveJobs     int       `json:"activeJobs"`
+	PendingJobs    int       `json:"pendingJobs"`
+	WorkStealing   bool      `json:"workStealing"`
+	LastSeen       time.Time `json:"lastSeen"`
+}
+
+// FreeSlots returns how many more jobs the peer can start right away
+func (p Peer) FreeSlots() int {
+	return max(p.MaxConcurrent-p.ActiveJobs-p.PendingJobs, 0)
+}
+
+// ApprovalRequest asks the backend for a human decision on a job
+type ApprovalRequest struct {
+	OrchestratorID string   `json:"orchestratorId"`
+	Message        string   `json:"message,omitempty"`
+	Approvers      []string `json:"approvers,omitempty"`
+	ExpiresAt      string   `json:"expiresAt"`
+	Timestamp      string   `json:"timestamp"`
+}
+
+// HandoffRequest releases an unstarted job to a specific peer
+type HandoffRequest struct {
+	FromOrchestratorID   string `json:"fromOrchestratorId"`
+	TargetOrchestratorID string `json:"targetOrchestratorId"`
+	Reason               string `json:"reason"`
+	Timestamp            string `json:"timestamp"`
+}
+
 // ComponentHealth represents health of a component
 type ComponentHealth struct {
 	Status    string                 `json:"status"`
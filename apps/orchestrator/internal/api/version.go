@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+)
+
+// Internal API contract versions this orchestrator can talk to. The backend
+// bumps its contract version when a change to the internal routes breaks
+// older orchestrators.
+const (
+	MinContractVersion = 1
+	MaxContractVersion = 1
+)
+
+// VersionInfo is the backend's answer to the version handshake
+type VersionInfo struct {
+	ContractVersion int    `json:"contractVersion"`
+	Version         string `json:"version"`
+}
+
+// GetVersion fetches the backend version and internal API contract version
+func (c *Client) GetVersion(ctx context.Context) (*VersionInfo, error) {
+	var info VersionInfo
+	if err := c.get(ctx, "/api/internal/version", nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// CheckCompatibility performs the version handshake and returns an error if
+// the backend's contract version is outside the supported range. A backend
+// without the version endpoint predates versioning and is incompatible.
+func (c *Client) CheckCompatibility(ctx context.Context) (*VersionInfo, error) {
+	info, err := c.GetVersion(ctx)
+	if err != nil {
+		var apiErr *errors.APIError
+		if stderrors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("backend does not support the version handshake; it predates contract version %d", MinContractVersion)
+		}
+		return nil, fmt.Errorf("version handshake failed: %w", err)
+	}
+
+	if info.ContractVersion < MinContractVersion || info.ContractVersion > MaxContractVersion {
+		return info, fmt.Errorf("backend %s uses internal API contract version %d, this orchestrator supports %d to %d",
+			info.Version, info.ContractVersion, MinContractVersion, MaxContractVersion)
+	}
+	return info, nil
+}
@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// resultUploadKeyInfo is the HKDF purpose label of the result upload key.
+// The runtime derives the key with the same label.
+const resultUploadKeyInfo = "cronium result upload url v1"
+
+// resultUploadKey derives the key that signs result upload URLs from the
+// shared secret, so the HMAC key is never the one that signs JWTs
+func resultUploadKey(secret string) []byte {
+	key := make([]byte, sha256.Size)
+	io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(resultUploadKeyInfo)), key)
+	return key
+}
+
+// ResultUploadURL builds a signed one-shot URL for pushing the results of an
+// execution to the runtime service. The runtime verifies the signature with
+// a key derived from the same shared secret.
+func ResultUploadURL(secret, baseURL, executionID string, expiresAt time.Time) (string, error) {
+	base, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil || base.Host == "" {
+		return "", fmt.Errorf("invalid result upload base URL: %s", baseURL)
+	}
+
+	expires := expiresAt.Unix()
+	mac := hmac.New(sha256.New, resultUploadKey(secret))
+	mac.Write([]byte("results:" + executionID + ":" + strconv.FormatInt(expires, 10)))
+
+	base.Path += "/results/" + url.PathEscape(executionID)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", hex.EncodeToString(mac.Sum(nil)))
+	base.RawQuery = query.Encode()
+
+	return base.String(), nil
+}
@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultUploadURL(t *testing.T) {
+	got, err := ResultUploadURL("secret", "https://runtime.example.com/api/", "exec_1", time.Unix(1760000000, 0))
+	require.NoError(t, err)
+
+	u, err := url.Parse(got)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/results/exec_1", u.Path)
+	assert.Equal(t, "1760000000", u.Query().Get("expires"))
+	// The runtime's tests check the same signature
+	assert.Equal(t, "44bffe11fd4e69777ea76b0bd156bcc85503af8abfaf0016707a4e0e63afc856", u.Query().Get("sig"))
+
+	_, err = ResultUploadURL("secret", "not a url", "exec_1", time.Now())
+	assert.Error(t, err)
+}
@@ -0,0 +1,425 @@
+// Package completion reports finished jobs to the backend from its own
+// workers. A job gives up its concurrency slot as soon as its report is
+// queued; the report is retried with exponential backoff until the backend
+// accepts it, rejects it outright or it grows too old. Reports are written to
+// disk before they are queued, so undelivered ones are resumed after a
+// restart.
+package completion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	pkgerrors "github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Delivery timing
+const (
+	initialRetryDelay = time.Second
+	deliveryTimeout   = 30 * time.Second
+)
+
+// Results of a delivery attempt, as recorded in metrics
+const (
+	ResultDelivered = "delivered"
+	ResultRetried   = "retried"
+	ResultRejected  = "rejected"
+	ResultExpired   = "expired"
+)
+
+// Reporter sends a completion report to the backend
+type Reporter interface {
+	CompleteJob(ctx context.Context, jobID string, req *api.CompleteJobRequest) error
+}
+
+// MetricsRecorder receives completion pipeline metrics
+type MetricsRecorder interface {
+	SetCompletionQueueDepth(depth float64)
+	RecordCompletionReport(result string)
+}
+
+// Report is a queued completion report
+type Report struct {
+	JobID    string                  `json:"jobId"`
+	JobType  string                  `json:"jobType"`
+	Request  *api.CompleteJobRequest `json:"request"`
+	QueuedAt time.Time               `json:"queuedAt"`
+	Attempts int                     `json:"attempts"`
+
+	nextAttempt time.Time
+	inFlight    bool
+}
+
+// Pipeline queues completion reports and delivers them from a pool of
+// workers
+type Pipeline struct {
+	cfg      config.CompletionConfig
+	reporter Reporter
+	log      *logrus.Logger
+	metrics  MetricsRecorder
+
+	mu      sync.Mutex
+	reports map[string]*Report // jobID -> queued report
+	wake    chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a pipeline, creating its queue directory if one is configured
+func New(cfg config.CompletionConfig, reporter Reporter, log *logrus.Logger) (*Pipeline, error) {
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create completion queue directory: %w", err)
+		}
+	}
+	return &Pipeline{
+		cfg:      cfg,
+		reporter: reporter,
+		log:      log,
+		reports:  make(map[string]*Report),
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// WithMetrics records the queue depth and the result of every delivery
+// attempt
+func (p *Pipeline) WithMetrics(recorder MetricsRecorder) {
+	p.metrics = recorder
+}
+
+// Start resumes reports left by a previous run and starts the workers
+func (p *Pipeline) Start(ctx context.Context) {
+	p.load()
+
+	ctx, p.cancel = context.WithCancel(ctx)
+	for i := 0; i < max(p.cfg.Workers, 1); i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.worker(ctx)
+		}()
+	}
+}
+
+// Stop makes one more attempt at every queued report within ctx, then stops
+// the workers. Reports still undelivered stay on disk for the next start.
+func (p *Pipeline) Stop(ctx context.Context) {
+	if p.cancel == nil {
+		return
+	}
+
+	start := time.Now()
+	p.mu.Lock()
+	for _, r := range p.reports {
+		r.nextAttempt = time.Time{}
+	}
+	p.mu.Unlock()
+	p.notify()
+
+	// Wait until every report has had its attempt or ctx ends
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for !p.attemptedSince(start) && ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	p.cancel()
+	p.wg.Wait()
+
+	if left := p.Len(); left > 0 {
+		entry := p.log.WithField("reports", left)
+		if p.cfg.Dir == "" {
+			entry.Error("Completion reports lost on shutdown; no queue directory is configured")
+		} else {
+			entry.Warn("Completion reports left queued until the next start")
+		}
+	}
+}
+
+// Enqueue queues a job's completion report. A report queued again for the
+// same job replaces the first.
+func (p *Pipeline) Enqueue(jobID, jobType string, req *api.CompleteJobRequest) {
+	r := &Report{
+		JobID:    jobID,
+		JobType:  jobType,
+		Request:  req,
+		QueuedAt: time.Now(),
+	}
+
+	p.mu.Lock()
+	if err := p.persist(r); err != nil {
+		p.log.WithError(err).WithField("jobID", jobID).Warn("Completion report kept in memory only")
+	}
+	p.reports[jobID] = r
+	depth := len(p.reports)
+	p.mu.Unlock()
+
+	p.setDepth(depth)
+	p.notify()
+}
+
+// attemptedSince reports whether every queued report has been attempted
+// since t
+func (p *Pipeline) attemptedSince(t time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range p.reports {
+		if r.inFlight || !r.nextAttempt.After(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// Len returns the number of queued reports
+func (p *Pipeline) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.reports)
+}
+
+// worker delivers due reports until ctx ends
+func (p *Pipeline) worker(ctx context.Context) {
+	for {
+		r, wait := p.next()
+		if r != nil {
+			p.deliver(ctx, r)
+			continue
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// next claims the report due soonest. When none is due it returns how long
+// until one is, or zero when nothing is queued.
+func (p *Pipeline) next() (*Report, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var due *Report
+	var wait time.Duration
+	for _, r := range p.reports {
+		if r.inFlight {
+			continue
+		}
+		if !r.nextAttempt.After(now) {
+			if due == nil || r.QueuedAt.Before(due.QueuedAt) {
+				due = r
+			}
+			continue
+		}
+		if until := r.nextAttempt.Sub(now); wait == 0 || until < wait {
+			wait = until
+		}
+	}
+	if due != nil {
+		due.inFlight = true
+	}
+	return due, wait
+}
+
+// deliver makes one attempt at a report
+func (p *Pipeline) deliver(ctx context.Context, r *Report) {
+	deliverCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+	err := p.reporter.CompleteJob(deliverCtx, r.JobID, r.Request)
+	cancel()
+
+	log := p.log.WithFields(logrus.Fields{
+		"jobID":    r.JobID,
+		"attempts": r.Attempts + 1,
+	})
+
+	p.mu.Lock()
+	r.inFlight = false
+	r.Attempts++
+	var result string
+	var retryAt time.Time
+	switch {
+	case err == nil:
+		result = ResultDelivered
+	case !retryable(err):
+		result = ResultRejected
+	case time.Since(r.QueuedAt) > p.cfg.MaxAge:
+		result = ResultExpired
+	default:
+		result = ResultRetried
+		r.nextAttempt = time.Now().Add(p.retryDelay(r.Attempts))
+		retryAt = r.nextAttempt
+	}
+	if result != ResultRetried && p.reports[r.JobID] == r {
+		// A report queued again for the job keeps its file
+		delete(p.reports, r.JobID)
+		p.unpersist(r.JobID)
+	}
+	depth := len(p.reports)
+	p.mu.Unlock()
+
+	switch result {
+	case ResultDelivered:
+		log.Debug("Completion report delivered")
+	case ResultRejected:
+		log.WithError(err).Error("Backend rejected completion report")
+	case ResultExpired:
+		log.WithError(err).Error("Dropping completion report that could not be delivered in time")
+	default:
+		log.WithError(err).WithField("retryAt", retryAt).Warn("Failed to deliver completion report")
+	}
+
+	p.setDepth(depth)
+	if p.metrics != nil {
+		p.metrics.RecordCompletionReport(result)
+	}
+}
+
+// retryDelay doubles the delay with every attempt up to the configured
+// maximum
+func (p *Pipeline) retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts && delay < p.cfg.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.cfg.MaxRetryDelay)
+}
+
+// retryable reports whether a delivery error may succeed later. Client errors
+// other than timeouts and rate limiting mean the backend will never accept
+// the report.
+func retryable(err error) bool {
+	var apiErr *pkgerrors.APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
+
+// load queues the reports left in the queue directory, keeping their
+// original queue time
+func (p *Pipeline) load() {
+	if p.cfg.Dir == "" {
+		return
+	}
+	files, err := filepath.Glob(filepath.Join(p.cfg.Dir, "*.json"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	sort.Strings(files)
+
+	p.mu.Lock()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			p.log.WithError(err).WithField("file", file).Warn("Failed to read queued completion report")
+			continue
+		}
+		var r Report
+		if err := json.Unmarshal(data, &r); err != nil || r.JobID == "" || r.Request == nil {
+			p.log.WithField("file", file).Warn("Discarding corrupt completion report")
+			os.Remove(file)
+			continue
+		}
+		if _, ok := p.reports[r.JobID]; !ok {
+			p.reports[r.JobID] = &r
+		}
+	}
+	depth := len(p.reports)
+	p.mu.Unlock()
+
+	p.setDepth(depth)
+	if depth > 0 {
+		p.log.WithField("reports", depth).Info("Resuming queued completion reports")
+	}
+}
+
+// persist writes a report to the queue directory; p.mu must be held
+func (p *Pipeline) persist(r *Report) error {
+	if p.cfg.Dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode completion report: %w", err)
+	}
+	path := p.path(r.JobID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write completion report: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// unpersist deletes a finished report from the queue directory; p.mu must
+// be held
+func (p *Pipeline) unpersist(jobID string) {
+	if p.cfg.Dir == "" {
+		return
+	}
+	if err := os.Remove(p.path(jobID)); err != nil && !os.IsNotExist(err) {
+		p.log.WithError(err).WithField("jobID", jobID).Warn("Failed to remove finished completion report")
+	}
+}
+
+// path returns the queue file for a job, keeping job IDs from escaping the
+// queue directory
+func (p *Pipeline) path(jobID string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, jobID)
+	return filepath.Join(p.cfg.Dir, safe+".json")
+}
+
+// notify wakes a worker
+func (p *Pipeline) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// setDepth publishes the queue depth
+func (p *Pipeline) setDepth(depth int) {
+	if p.metrics != nil {
+		p.metrics.SetCompletionQueueDepth(float64(depth))
+	}
+}
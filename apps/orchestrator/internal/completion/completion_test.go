@@ -0,0 +1,106 @@
+package completion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	pkgerrors "github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeReporter fails each job's first failures[jobID] attempts with err
+type fakeReporter struct {
+	mu        sync.Mutex
+	failures  map[string]int
+	err       error
+	delivered []string
+}
+
+func (f *fakeReporter) CompleteJob(ctx context.Context, jobID string, req *api.CompleteJobRequest) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.failures[jobID] > 0 {
+		f.failures[jobID]--
+		return f.err
+	}
+	f.delivered = append(f.delivered, jobID)
+	return nil
+}
+
+func (f *fakeReporter) deliveredJobs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.delivered...)
+}
+
+func testLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestPipelineRetriesAndRejects(t *testing.T) {
+	dir := t.TempDir()
+	reporter := &fakeReporter{
+		failures: map[string]int{"job-retry": 1, "job-rejected": 1},
+		err:      fmt.Errorf("backend unavailable"),
+	}
+	cfg := config.CompletionConfig{Workers: 2, Dir: dir, MaxRetryDelay: time.Second, MaxAge: time.Hour}
+	p, err := New(cfg, reporter, testLogger())
+	require.NoError(t, err)
+	p.Start(context.Background())
+
+	p.Enqueue("job-ok", "container", &api.CompleteJobRequest{ExitCode: 0})
+	p.Enqueue("job-retry", "container", &api.CompleteJobRequest{ExitCode: 1})
+	assert.Eventually(t, func() bool { return p.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"job-ok", "job-retry"}, reporter.deliveredJobs())
+
+	// The backend refusing a report is final
+	reporter.mu.Lock()
+	reporter.err = pkgerrors.NewAPIError(404, "NOT_FOUND", "job not found")
+	reporter.mu.Unlock()
+	p.Enqueue("job-rejected", "container", &api.CompleteJobRequest{})
+	assert.Eventually(t, func() bool { return p.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.NotContains(t, reporter.deliveredJobs(), "job-rejected")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p.Stop(ctx)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	assert.Empty(t, files)
+}
+
+func TestPipelineResumesQueuedReports(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.CompletionConfig{Workers: 1, Dir: dir, MaxRetryDelay: time.Minute, MaxAge: time.Hour}
+
+	// The backend is down for the whole first run
+	down := &fakeReporter{failures: map[string]int{"job-1": 100}, err: fmt.Errorf("connection refused")}
+	p, err := New(cfg, down, testLogger())
+	require.NoError(t, err)
+	p.Start(context.Background())
+	p.Enqueue("job-1", "ssh", &api.CompleteJobRequest{ExitCode: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	p.Stop(ctx)
+	assert.Equal(t, 1, p.Len())
+
+	up := &fakeReporter{}
+	p, err = New(cfg, up, testLogger())
+	require.NoError(t, err)
+	p.Start(context.Background())
+	assert.Eventually(t, func() bool { return p.Len() == 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"job-1"}, up.deliveredJobs())
+	p.Stop(ctx)
+}
@@ -1,13 +1,22 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base32"
+	"encoding/base64"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/schedule"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
@@ -17,13 +26,22 @@ import (
 type Config struct {
 	Orchestrator OrchestratorConfig `yaml:"orchestrator" envconfig:"ORCHESTRATOR"`
 	API          APIConfig          `yaml:"api" envconfig:"API"`
+	DNS          DNSConfig          `yaml:"dns" envconfig:"DNS"`
 	Jobs         JobsConfig         `yaml:"jobs" envconfig:"JOBS"`
 	Container    ContainerConfig    `yaml:"container" envconfig:"CONTAINER"`
 	SSH          SSHConfig          `yaml:"ssh" envconfig:"SSH"`
+	HTTP         HTTPConfig         `yaml:"http" envconfig:"HTTP"`
 	Logging      LoggingConfig      `yaml:"logging" envconfig:"LOGGING"`
 	Monitoring   MonitoringConfig   `yaml:"monitoring" envconfig:"MONITORING"`
+	Admin        AdminConfig        `yaml:"admin" envconfig:"ADMIN"`
 	Security     SecurityConfig     `yaml:"security" envconfig:"SECURITY"`
 	Features     FeatureFlags       `yaml:"features" envconfig:"FEATURES"`
+	Triggers     TriggersConfig     `yaml:"triggers" envconfig:"TRIGGERS"`
+	Exports      ExportConfig       `yaml:"exports" envconfig:"EXPORTS"`
+	Accounting   AccountingConfig   `yaml:"accounting" envconfig:"ACCOUNTING"`
+	Jitter       JitterConfig       `yaml:"jitter" envconfig:"JITTER"`
+	Plugins      PluginsConfig      `yaml:"plugins" envconfig:"PLUGINS"`
+	Hooks        HooksConfig        `yaml:"hooks" envconfig:"HOOKS"`
 }
 
 // OrchestratorConfig defines orchestrator identity and behavior
@@ -38,7 +56,7 @@ type OrchestratorConfig struct {
 // APIConfig defines backend API settings
 type APIConfig struct {
 	Endpoint       string          `yaml:"endpoint" envconfig:"ENDPOINT" required:"true"`
-	Token          string          `yaml:"token" envconfig:"TOKEN" required:"true"`
+	Token          string          `yaml:"token" envconfig:"TOKEN" required:"true" secret:"true"`
 	WSEndpoint     string          `yaml:"wsEndpoint" envconfig:"WS_ENDPOINT"`
 	Timeout        time.Duration   `yaml:"timeout" envconfig:"TIMEOUT" default:"5m"`
 	RetryConfig    RetryConfig     `yaml:"retry" envconfig:"RETRY"`
@@ -46,6 +64,22 @@ type APIConfig struct {
 	OrchestratorID string          `yaml:"-"` // Set from OrchestratorConfig.ID
 }
 
+// DNSConfig defines the caching resolver used for SSH targets and API hosts.
+// Answers are cached for their record TTL clamped to [MinTTL, MaxTTL]; names
+// answered by the system resolver instead (hosts file, search domains) are
+// cached for DefaultTTL.
+type DNSConfig struct {
+	Enabled     bool              `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	Nameservers []string          `yaml:"nameservers" envconfig:"NAMESERVERS"`
+	Timeout     time.Duration     `yaml:"timeout" envconfig:"TIMEOUT" default:"2s"`
+	MinTTL      time.Duration     `yaml:"minTTL" envconfig:"MIN_TTL" default:"5s"`
+	MaxTTL      time.Duration     `yaml:"maxTTL" envconfig:"MAX_TTL" default:"5m"`
+	DefaultTTL  time.Duration     `yaml:"defaultTTL" envconfig:"DEFAULT_TTL" default:"30s"`
+	NegativeTTL time.Duration     `yaml:"negativeTTL" envconfig:"NEGATIVE_TTL" default:"10s"`
+	StaleFor    time.Duration     `yaml:"staleFor" envconfig:"STALE_FOR" default:"1m"`
+	Static      map[string]string `yaml:"static" envconfig:"STATIC"`
+}
+
 // JobsConfig defines job processing settings
 type JobsConfig struct {
 	PollInterval   time.Duration `yaml:"pollInterval" envconfig:"POLL_INTERVAL" default:"1s"`
@@ -53,7 +87,175 @@ type JobsConfig struct {
 	MaxConcurrent  int           `yaml:"maxConcurrent" envconfig:"MAX_CONCURRENT" default:"5"`
 	DefaultTimeout time.Duration `yaml:"defaultTimeout" envconfig:"DEFAULT_TIMEOUT" default:"3600s"`
 	QueueStrategy  string        `yaml:"queueStrategy" envconfig:"QUEUE_STRATEGY" default:"priority"`
-	LeaseRenewal   time.Duration `yaml:"leaseRenewal" envconfig:"LEASE_RENEWAL" default:"30s"`
+	// Default for events without a concurrency policy: allow, forbid or
+	// replace overlapping runs
+	ConcurrencyPolicy string        `yaml:"concurrencyPolicy" envconfig:"CONCURRENCY_POLICY" default:"allow"`
+	LeaseRenewal      time.Duration `yaml:"leaseRenewal" envconfig:"LEASE_RENEWAL" default:"30s"`
+	// How often the backend is asked for jobs cancelled while they run or
+	// wait here; 0 leaves cancellation to the push channel and admin API
+	CancelPollInterval time.Duration      `yaml:"cancelPollInterval" envconfig:"CANCEL_POLL_INTERVAL" default:"5s"`
+	WorkStealing       WorkStealingConfig `yaml:"workStealing" envconfig:"WORK_STEALING"`
+	Matrix             MatrixConfig       `yaml:"matrix" envconfig:"MATRIX"`
+	Gates              GatesConfig        `yaml:"gates" envconfig:"GATES"`
+	Analysis           AnalysisConfig     `yaml:"analysis" envconfig:"ANALYSIS"`
+	Locale             LocaleConfig       `yaml:"locale" envconfig:"LOCALE"`
+	Quarantine         QuarantineConfig   `yaml:"quarantine" envconfig:"QUARANTINE"`
+	DeadLetter         DeadLetterConfig   `yaml:"deadLetter" envconfig:"DEAD_LETTER"`
+	Durations          DurationsConfig    `yaml:"durations" envconfig:"DURATIONS"`
+	Completion         CompletionConfig   `yaml:"completion" envconfig:"COMPLETION"`
+	Lineage            LineageConfig      `yaml:"lineage" envconfig:"LINEAGE"`
+	Push               JobPushConfig      `yaml:"push" envconfig:"PUSH"`
+	Drain              DrainConfig        `yaml:"drain" envconfig:"DRAIN"`
+}
+
+// DrainConfig defines drain mode, started by SIGUSR1 or a POST to /drain on
+// the health server. A draining orchestrator takes no new jobs, finishes the
+// ones it accepted and exits once none are left, or after Timeout with the
+// remaining jobs stopped as in a shutdown. /drain requires Token as a bearer
+// token and is disabled while it is empty; SIGUSR1 always works. Token is
+// only read from CRONIUM_JOBS_DRAIN_TOKEN, never from a bare TOKEN variable.
+type DrainConfig struct {
+	Timeout time.Duration `yaml:"timeout" envconfig:"TIMEOUT" default:"1h"`
+	Token   string        `yaml:"token" split_words:"true" secret:"true"`
+}
+
+// JobPushConfig defines the WebSocket channel on which the backend pushes
+// new jobs instead of waiting for a poll. The orchestrator tells the backend
+// how many jobs it can take and acknowledges pushed jobs on the channel.
+// While it is connected, polling only runs every PollInterval to pick up
+// jobs a push missed; while it is down, polling runs at the jobs poll
+// interval and the channel is reconnected with backoff. It is off by default
+// because the backend does not serve the channel yet.
+type JobPushConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Defaults to the API WebSocket endpoint with /jobs appended
+	Endpoint          string        `yaml:"endpoint" envconfig:"ENDPOINT"`
+	PollInterval      time.Duration `yaml:"pollInterval" envconfig:"POLL_INTERVAL" default:"30s"`
+	ReconnectDelay    time.Duration `yaml:"reconnectDelay" envconfig:"RECONNECT_DELAY" default:"1s"`
+	MaxReconnectDelay time.Duration `yaml:"maxReconnectDelay" envconfig:"MAX_RECONNECT_DELAY" default:"1m"`
+	// Time to wait for the backend to confirm an acknowledgement before it
+	// is sent over HTTP instead
+	AckTimeout time.Duration `yaml:"ackTimeout" envconfig:"ACK_TIMEOUT" default:"10s"`
+}
+
+// LineageConfig defines the job trees kept for jobs submitted by other jobs.
+// A tree is forgotten once all its jobs have finished and none has changed
+// for Retention.
+type LineageConfig struct {
+	Retention time.Duration `yaml:"retention" envconfig:"RETENTION" default:"1h"`
+}
+
+// CompletionConfig defines how finished jobs are reported to the backend.
+// Reports are queued and sent by their own workers, so a slow backend does
+// not keep a job's concurrency slot taken. Queued reports are written to Dir
+// and survive a restart; an empty Dir keeps them in memory only. A report
+// still undelivered after MaxAge is dropped.
+type CompletionConfig struct {
+	Workers       int           `yaml:"workers" envconfig:"WORKERS" default:"2"`
+	Dir           string        `yaml:"dir" envconfig:"DIR" default:"/var/lib/cronium/completions"`
+	MaxRetryDelay time.Duration `yaml:"maxRetryDelay" envconfig:"MAX_RETRY_DELAY" default:"1m"`
+	MaxAge        time.Duration `yaml:"maxAge" envconfig:"MAX_AGE" default:"24h"`
+}
+
+// DurationsConfig defines how long runs of an event are expected to take.
+// The durations of completed runs are smoothed exponentially, weighting the
+// latest run by Alpha, and kept in File across restarts. Once an event has
+// MinSamples completed runs, a run still going after OverrunFactor times the
+// expected duration, and at least MinOverrun, gets a warning in its log and
+// status, and a notification when Notify is set.
+type DurationsConfig struct {
+	Enabled       bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	File          string        `yaml:"file" envconfig:"FILE" default:"/var/lib/cronium/durations.json"`
+	Alpha         float64       `yaml:"alpha" envconfig:"ALPHA" default:"0.3"`
+	OverrunFactor float64       `yaml:"overrunFactor" envconfig:"OVERRUN_FACTOR" default:"2"`
+	MinSamples    int           `yaml:"minSamples" envconfig:"MIN_SAMPLES" default:"3"`
+	MinOverrun    time.Duration `yaml:"minOverrun" envconfig:"MIN_OVERRUN" default:"1m"`
+	Notify        bool          `yaml:"notify" envconfig:"NOTIFY" default:"false"`
+}
+
+// QuarantineConfig defines the quarantine of events that keep failing. After
+// Threshold consecutive failed executions, jobs of the event are released
+// back to the backend instead of run on this orchestrator, and a notification
+// is sent. The quarantine lasts for Duration, or until it is cleared through
+// the admin API when Duration is zero. It is kept in memory only.
+type QuarantineConfig struct {
+	Enabled   bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Threshold int           `yaml:"threshold" envconfig:"THRESHOLD" default:"5"`
+	Duration  time.Duration `yaml:"duration" envconfig:"DURATION" default:"0s"`
+}
+
+// DeadLetterConfig defines the handling of poison jobs, which fail or take
+// the orchestrator down on every attempt. Each attempt is written to File
+// before the job starts, so attempts that crashed the process count too.
+// A job polled again after MaxAttempts consecutive failed attempts is
+// reported as dead-lettered instead of acknowledged. Jobs not attempted for
+// Retention are forgotten.
+type DeadLetterConfig struct {
+	Enabled     bool          `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	MaxAttempts int           `yaml:"maxAttempts" envconfig:"MAX_ATTEMPTS" default:"3"`
+	File        string        `yaml:"file" envconfig:"FILE" default:"/var/lib/cronium/dead-letters.json"`
+	Retention   time.Duration `yaml:"retention" envconfig:"RETENTION" default:"168h"`
+}
+
+// LocaleConfig defines the clock and locale environment injected into every
+// execution so scripts behave the same on every host. Jobs with a locale of
+// their own are normalized even when this is disabled. The environment
+// names are prefixed because envconfig falls back to the bare tag, which
+// would pick up the orchestrator host's own TZ, LANG and LC_ALL.
+type LocaleConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	TZ      string `yaml:"tz" envconfig:"LOCALE_TZ" default:"UTC"`
+	Lang    string `yaml:"lang" envconfig:"LOCALE_LANG" default:"C.UTF-8"`
+	LCAll   string `yaml:"lcAll" envconfig:"LOCALE_LC_ALL" default:"C.UTF-8"`
+	// libfaketime time specification applied to every job, for testing
+	FakeTime string `yaml:"fakeTime" envconfig:"FAKE_TIME"`
+	// libfaketime library preloaded when a fake time is set; it must exist
+	// on the target
+	FakeTimeLibrary string `yaml:"fakeTimeLibrary" envconfig:"FAKE_TIME_LIBRARY" default:"/usr/lib/x86_64-linux-gnu/faketime/libfaketime.so.1"`
+}
+
+// AnalysisConfig defines the pre-execution static analysis stage. Tools run
+// in a throwaway container of Image without network access.
+type AnalysisConfig struct {
+	Enabled       bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Mode          string        `yaml:"mode" envconfig:"MODE" default:"warn"`
+	Image         string        `yaml:"image" envconfig:"IMAGE" default:"cronium/analysis:latest"`
+	Tools         []string      `yaml:"tools" envconfig:"TOOLS" default:"shellcheck,bandit,semgrep"`
+	SemgrepRules  string        `yaml:"semgrepRules" envconfig:"SEMGREP_RULES" default:"/rules"`
+	BlockSeverity string        `yaml:"blockSeverity" envconfig:"BLOCK_SEVERITY" default:"error"`
+	Timeout       time.Duration `yaml:"timeout" envconfig:"TIMEOUT" default:"60s"`
+}
+
+// GatesConfig defines defaults for pre-execution gates
+type GatesConfig struct {
+	DefaultInterval time.Duration `yaml:"defaultInterval" envconfig:"DEFAULT_INTERVAL" default:"10s"`
+	DefaultTimeout  time.Duration `yaml:"defaultTimeout" envconfig:"DEFAULT_TIMEOUT" default:"30m"`
+	MaxTimeout      time.Duration `yaml:"maxTimeout" envconfig:"MAX_TIMEOUT" default:"24h"`
+	MinInterval     time.Duration `yaml:"minInterval" envconfig:"MIN_INTERVAL" default:"1s"`
+
+	// Approval gates
+	ApprovalInterval  time.Duration `yaml:"approvalInterval" envconfig:"APPROVAL_INTERVAL" default:"15s"`
+	ApprovalExpiry    time.Duration `yaml:"approvalExpiry" envconfig:"APPROVAL_EXPIRY" default:"1h"`
+	MaxApprovalExpiry time.Duration `yaml:"maxApprovalExpiry" envconfig:"MAX_APPROVAL_EXPIRY" default:"72h"`
+	// Notified of every approval request; decisions are only taken from
+	// the backend
+	ApprovalWebhookURL string `yaml:"approvalWebhookUrl" envconfig:"APPROVAL_WEBHOOK_URL"`
+}
+
+// MatrixConfig limits how matrix jobs are expanded and run
+type MatrixConfig struct {
+	// Largest number of combinations a single matrix job may expand to
+	MaxCombinations int `yaml:"maxCombinations" envconfig:"MAX_COMBINATIONS" default:"64"`
+	// Combinations running at once across all matrix jobs
+	MaxParallel int `yaml:"maxParallel" envconfig:"MAX_PARALLEL" default:"4"`
+}
+
+// WorkStealingConfig defines how jobs are handed off between agents in the same region
+type WorkStealingConfig struct {
+	Enabled           bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval" envconfig:"HEARTBEAT_INTERVAL" default:"15s"`
+	PrefetchLimit     int           `yaml:"prefetchLimit" envconfig:"PREFETCH_LIMIT" default:"5"`
+	HandoffAfter      time.Duration `yaml:"handoffAfter" envconfig:"HANDOFF_AFTER" default:"10s"`
 }
 
 // ContainerConfig defines Docker container settings
@@ -65,6 +267,15 @@ type ContainerConfig struct {
 	Volumes   VolumeConfig            `yaml:"volumes" envconfig:"VOLUMES"`
 	Network   NetworkConfig           `yaml:"network" envconfig:"NETWORK"`
 	Runtime   RuntimeConfig           `yaml:"runtime" envconfig:"RUNTIME"`
+	Stop      ContainerStopConfig     `yaml:"stop" envconfig:"STOP"`
+	Cleanup   ContainerCleanupConfig  `yaml:"cleanup" envconfig:"CLEANUP"`
+	Sandbox   SandboxConfig           `yaml:"sandbox" envconfig:"SANDBOX"`
+	// Runs container jobs as Kubernetes pods instead of Docker containers
+	Kubernetes KubernetesConfig `yaml:"kubernetes" envconfig:"KUBERNETES"`
+	ImageScan  ImageScanConfig  `yaml:"imageScan" envconfig:"IMAGE_SCAN"`
+	Pull       ImagePullConfig  `yaml:"pull" envconfig:"PULL"`
+	// Credentials for private registries
+	Registries []RegistryConfig `yaml:"registries" ignored:"true"`
 }
 
 // SSHConfig defines SSH execution settings
@@ -73,6 +284,149 @@ type SSHConfig struct {
 	Execution      SSHExecutionConfig   `yaml:"execution" envconfig:"EXECUTION"`
 	CircuitBreaker CircuitBreakerConfig `yaml:"circuitBreaker" envconfig:"CIRCUIT_BREAKER"`
 	Security       SSHSecurityConfig    `yaml:"security" envconfig:"SECURITY"`
+	Runner         RunnerRolloutConfig  `yaml:"runner" envconfig:"RUNNER"`
+	Certificates   SSHCertificateConfig `yaml:"certificates" envconfig:"CERTIFICATES"`
+	FanOut         FanOutConfig         `yaml:"fanOut" envconfig:"FAN_OUT"`
+	StickyTarget   StickyTargetConfig   `yaml:"stickyTarget" envconfig:"STICKY_TARGET"`
+	// Servers that require keyboard-interactive authentication
+	KeyboardInteractive []KeyboardInteractiveConfig `yaml:"keyboardInteractive" ignored:"true"`
+}
+
+// FanOutConfig defines how a job targeting several servers is spread across
+// them. Servers run in batches of BatchSize, each batch finishing and
+// BatchPause passing before the next starts, with at most MaxParallel servers
+// running at once. Once FailureThreshold servers have failed, the running
+// ones are cancelled and the rest are not started. Jobs can override each
+// setting.
+type FanOutConfig struct {
+	// 0 runs every server of a batch at once
+	MaxParallel int `yaml:"maxParallel" envconfig:"MAX_PARALLEL" default:"0"`
+	// 0 runs all servers in one batch
+	BatchSize  int           `yaml:"batchSize" envconfig:"BATCH_SIZE" default:"0"`
+	BatchPause time.Duration `yaml:"batchPause" envconfig:"BATCH_PAUSE" default:"0s"`
+	// 0 never cancels
+	FailureThreshold int `yaml:"failureThreshold" envconfig:"FAILURE_THRESHOLD" default:"0"`
+}
+
+// StickyTargetConfig defines how jobs with a sticky target pick their
+// server. The server each event last ran on is kept in File and preferred
+// for TTL after the run, as long as it accepts a connection within
+// ProbeTimeout. Jobs can override the TTL.
+type StickyTargetConfig struct {
+	TTL          time.Duration `yaml:"ttl" envconfig:"TTL" default:"24h"`
+	File         string        `yaml:"file" envconfig:"FILE" default:"/var/lib/cronium/sticky-targets.json"`
+	ProbeTimeout time.Duration `yaml:"probeTimeout" envconfig:"PROBE_TIMEOUT" default:"10s"`
+}
+
+// KeyboardInteractiveConfig defines how the prompts of a server's
+// keyboard-interactive authentication, such as a PAM password and OTP, are
+// answered. Each prompt gets the answer of the first responder whose pattern
+// matches it; a prompt no responder matches fails the authentication.
+type KeyboardInteractiveConfig struct {
+	// Server ID or name
+	Server     string                  `yaml:"server"`
+	Responders []PromptResponderConfig `yaml:"responders"`
+}
+
+// PromptResponderConfig answers keyboard-interactive prompts
+type PromptResponderConfig struct {
+	// Case-insensitive regular expression matched against the prompt
+	Prompt string `yaml:"prompt"`
+	// static answers with Answer, password with the server's password and
+	// totp with a time-based one-time code
+	Type   string `yaml:"type"`
+	Answer string `yaml:"answer" secret:"true"`
+	// Base32 TOTP seed, as in an otpauth:// URI
+	TOTPSecret string        `yaml:"totpSecret" secret:"true"`
+	TOTPDigits int           `yaml:"totpDigits"` // default 6
+	TOTPPeriod time.Duration `yaml:"totpPeriod"` // default 30s
+}
+
+// SSHCertificateConfig defines OpenSSH certificate authentication. The
+// connection pool generates a key, has it signed as a short-lived user
+// certificate by a local CA key or the Vault SSH secrets engine, and offers
+// it before any key or password in the server details, which servers then no
+// longer need. Servers must trust the CA through TrustedUserCAKeys.
+type SSHCertificateConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// local signs with CAKeyFile, vault with the Vault SSH secrets engine
+	Source          string `yaml:"source" envconfig:"SOURCE" default:"local"`
+	CAKeyFile       string `yaml:"caKeyFile" envconfig:"CA_KEY_FILE"`
+	CAKeyPassphrase string `yaml:"caKeyPassphrase" envconfig:"CA_KEY_PASSPHRASE" secret:"true"`
+	// Principals the certificate is valid for; empty uses the server's
+	// username
+	Principals []string       `yaml:"principals" envconfig:"PRINCIPALS"`
+	TTL        time.Duration  `yaml:"ttl" envconfig:"TTL" default:"5m"`
+	Vault      SSHVaultConfig `yaml:"vault" envconfig:"VAULT"`
+}
+
+// SSHVaultConfig is the Vault role that signs user certificates, at
+// <mount>/sign/<role>. Token is only read from
+// CRONIUM_SSH_CERTIFICATES_VAULT_TOKEN, never from a bare TOKEN variable.
+type SSHVaultConfig struct {
+	Address   string `yaml:"address" envconfig:"ADDRESS"`
+	Token     string `yaml:"token" split_words:"true" secret:"true"`
+	Namespace string `yaml:"namespace" envconfig:"NAMESPACE"`
+	Mount     string `yaml:"mount" envconfig:"MOUNT" default:"ssh"`
+	Role      string `yaml:"role" envconfig:"ROLE"`
+}
+
+// RunnerRolloutConfig pins servers to runner versions and stages new builds.
+// Servers without a pin run the orchestrator's default runner version, or the
+// candidate version if they fall inside the rollout percentage.
+type RunnerRolloutConfig struct {
+	Candidate      string              `yaml:"candidate" envconfig:"CANDIDATE"`
+	RolloutPercent int                 `yaml:"rolloutPercent" envconfig:"ROLLOUT_PERCENT" default:"0"`
+	Pins           map[string]string   `yaml:"pins" envconfig:"PINS"` // server ID or name -> version
+	Groups         []RunnerPinGroup    `yaml:"groups" ignored:"true"`
+	Releases       RunnerReleaseConfig `yaml:"releases" envconfig:"RELEASES"`
+	Cache          RunnerCacheConfig   `yaml:"cache" envconfig:"CACHE"`
+}
+
+// RunnerCacheConfig defines the record of runners deployed to servers. The
+// record is kept in File so a restarted orchestrator does not verify or
+// redeploy every runner; entries older than Freshness are verified again,
+// by checksum, before their runner is used. An empty File keeps the record
+// in memory only.
+type RunnerCacheConfig struct {
+	File      string        `yaml:"file" envconfig:"FILE" default:"/app/data/runner-cache.json"`
+	Freshness time.Duration `yaml:"freshness" envconfig:"FRESHNESS" default:"1h"`
+}
+
+// RunnerReleaseConfig downloads signed runner releases from a release channel
+// into the runner artifacts directory, next to the bundled build. Versions
+// that are pinned, grouped or rolled out are fetched along with the
+// channel's latest release, for every listed architecture.
+type RunnerReleaseConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Channel manifest base URL (https://...), whose <channel>.json lists the
+	// releases, or an OCI repository (oci://registry/repository) tagged with
+	// versions and the channel name
+	URL      string `yaml:"url" envconfig:"URL"`
+	Channel  string `yaml:"channel" envconfig:"CHANNEL" default:"stable"`
+	Username string `yaml:"username" envconfig:"USERNAME"`
+	Password string `yaml:"password" envconfig:"PASSWORD" secret:"true"`
+	// Base64 Ed25519 public keys; every artifact must carry a signature by
+	// one of them
+	PublicKeys []string `yaml:"publicKeys" envconfig:"PUBLIC_KEYS"`
+	// Accept releases verified only by their checksums when no public keys
+	// are set
+	InsecureSkipVerify bool          `yaml:"insecureSkipVerify" envconfig:"INSECURE_SKIP_VERIFY" default:"false"`
+	Architectures      []string      `yaml:"architectures" envconfig:"ARCHITECTURES"`
+	CheckInterval      time.Duration `yaml:"checkInterval" envconfig:"CHECK_INTERVAL" default:"1h"`
+	// Downloaded versions kept besides the ones in use
+	Keep int `yaml:"keep" envconfig:"KEEP" default:"3"`
+	// Use the channel's latest release instead of RUNNER_VERSION as the
+	// default runner
+	AutoUpdate bool          `yaml:"autoUpdate" envconfig:"AUTO_UPDATE" default:"false"`
+	Timeout    time.Duration `yaml:"timeout" envconfig:"TIMEOUT" default:"5m"`
+}
+
+// RunnerPinGroup pins a set of servers to one runner version
+type RunnerPinGroup struct {
+	Name    string   `yaml:"name"`
+	Version string   `yaml:"version"`
+	Servers []string `yaml:"servers"` // server IDs or names
 }
 
 // LoggingConfig defines logging settings
@@ -82,6 +436,8 @@ type LoggingConfig struct {
 	Output    string        `yaml:"output" envconfig:"OUTPUT" default:"stdout"`
 	File      FileLogConfig `yaml:"file" envconfig:"FILE"`
 	WebSocket WSLogConfig   `yaml:"websocket" envconfig:"WEBSOCKET"`
+	// Per-job log files kept for operators
+	Jobs JobLogStoreConfig `yaml:"jobs" envconfig:"JOBS"`
 }
 
 // MonitoringConfig defines monitoring settings
@@ -91,6 +447,28 @@ type MonitoringConfig struct {
 	HealthPort  int             `yaml:"healthPort" envconfig:"HEALTH_PORT" default:"8080"`
 	Tracing     TracingConfig   `yaml:"tracing" envconfig:"TRACING"`
 	Profiling   ProfilingConfig `yaml:"profiling" envconfig:"PROFILING"`
+	History     HistoryConfig   `yaml:"history" envconfig:"HISTORY"`
+	// Resource usage of each execution
+	ResourceUsage ResourceUsageConfig `yaml:"resourceUsage" envconfig:"RESOURCE_USAGE"`
+}
+
+// ResourceUsageConfig defines how the resource usage of running jobs is
+// measured for the completed execution's metrics and the job resource
+// metrics. Container jobs are read from docker stats and SSH jobs probed on
+// their server every SampleInterval; with accounting enabled its sample
+// interval is used instead.
+type ResourceUsageConfig struct {
+	Enabled        bool          `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	SampleInterval time.Duration `yaml:"sampleInterval" envconfig:"SAMPLE_INTERVAL" default:"15s"`
+}
+
+// AdminConfig defines the operator admin API settings. Token is only read
+// from CRONIUM_ADMIN_TOKEN, never from a bare TOKEN variable.
+type AdminConfig struct {
+	Enabled       bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Port          int           `yaml:"port" envconfig:"PORT" default:"9091"`
+	Token         string        `yaml:"token" split_words:"true" secret:"true"`
+	StatsInterval time.Duration `yaml:"statsInterval" envconfig:"STATS_INTERVAL" default:"2s"`
 }
 
 // SecurityConfig defines security settings
@@ -98,6 +476,8 @@ type SecurityConfig struct {
 	TLS            TLSConfig            `yaml:"tls" envconfig:"TLS"`
 	Authentication AuthenticationConfig `yaml:"authentication" envconfig:"AUTHENTICATION"`
 	Encryption     EncryptionConfig     `yaml:"encryption" envconfig:"ENCRYPTION"`
+	Receipts       ReceiptsConfig       `yaml:"receipts" envconfig:"RECEIPTS"`
+	OutputScanning OutputScanningConfig `yaml:"outputScanning" envconfig:"OUTPUT_SCANNING"`
 }
 
 // FeatureFlags defines feature toggles
@@ -106,6 +486,289 @@ type FeatureFlags struct {
 	AdvancedScheduling bool `yaml:"advancedScheduling" envconfig:"ADVANCED_SCHEDULING" default:"false"`
 	DistributedTracing bool `yaml:"distributedTracing" envconfig:"DISTRIBUTED_TRACING" default:"false"`
 	ExperimentalSSH    bool `yaml:"experimentalSSH" envconfig:"EXPERIMENTAL_SSH" default:"false"`
+
+	// Rollout switches for optional SSH features. Each only applies where
+	// its own configuration turns the feature on.
+	SSHScriptCache bool `yaml:"sshScriptCache" envconfig:"SSH_SCRIPT_CACHE" default:"true"`
+	SSHSnapshots   bool `yaml:"sshSnapshots" envconfig:"SSH_SNAPSHOTS" default:"true"`
+	SSHCheckpoints bool `yaml:"sshCheckpoints" envconfig:"SSH_CHECKPOINTS" default:"true"`
+}
+
+// TriggersConfig defines local trigger sources. Each trigger queues a job
+// for a backend event with the trigger payload as the job's input data.
+type TriggersConfig struct {
+	RestartDelay time.Duration           `yaml:"restartDelay" envconfig:"RESTART_DELAY" default:"5s"`
+	StateDir     string                  `yaml:"stateDir" envconfig:"STATE_DIR" default:"/app/data/triggers"`
+	Webhook      WebhookConfig           `yaml:"webhook" envconfig:"WEBHOOK"`
+	Schedules    []ScheduleTriggerConfig `yaml:"schedules" ignored:"true"`
+	Filesystem   []FileTriggerConfig     `yaml:"filesystem" ignored:"true"`
+	NATS         []NATSTriggerConfig     `yaml:"nats" ignored:"true"`
+	Streams      []StreamTriggerConfig   `yaml:"streams" ignored:"true"`
+	AMQP         []AMQPTriggerConfig     `yaml:"amqp" ignored:"true"`
+	Kafka        []KafkaTriggerConfig    `yaml:"kafka" ignored:"true"`
+}
+
+// HooksConfig defines commands and webhooks run on the agent host around
+// every job, for example to open a firewall rule to the target and close it
+// again, or to register the run in a CMDB. Pre hooks run in order before the
+// job starts; post hooks run in order after it finishes, and also after a
+// job that a pre hook blocked, so they can undo what earlier hooks did.
+type HooksConfig struct {
+	Pre  []HookConfig `yaml:"pre" ignored:"true"`
+	Post []HookConfig `yaml:"post" ignored:"true"`
+}
+
+// HookConfig is a command, run with the job as JSON on stdin and CRONIUM_*
+// variables, or a webhook the same JSON is posted to. A failing hook with
+// OnFailure "block" fails the job: before it starts for a pre hook, after
+// it finished for a post hook. With "warn" the failure is only recorded.
+type HookConfig struct {
+	Name      string            `yaml:"name"`
+	Command   []string          `yaml:"command"`
+	URL       string            `yaml:"url"`
+	Secret    string            `yaml:"secret" secret:"true"`
+	Headers   map[string]string `yaml:"headers"`
+	JobTypes  []string          `yaml:"jobTypes"`
+	Timeout   time.Duration     `yaml:"timeout"`
+	OnFailure string            `yaml:"onFailure"` // block or warn
+}
+
+// WebhookConfig defines the inbound webhook listener, which serves
+// POST /triggers/{name} for each configured endpoint
+type WebhookConfig struct {
+	Enabled     bool  `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Port        int   `yaml:"port" envconfig:"PORT" default:"9092"`
+	MaxBodySize int64 `yaml:"maxBodySize" envconfig:"MAX_BODY_SIZE" default:"1048576"`
+	// How far a request's timestamp may be from the agent's clock; signed
+	// requests are also rejected when replayed within this window
+	Tolerance time.Duration          `yaml:"tolerance" envconfig:"TOLERANCE" default:"5m"`
+	Endpoints []WebhookTriggerConfig `yaml:"endpoints" ignored:"true"`
+}
+
+// WebhookTriggerConfig maps a webhook endpoint to an event. Requests must
+// carry the Unix time they were sent in TimestampHeader and an
+// HMAC-SHA256 of "<timestamp>.<body>", made with Secret, in SignatureHeader
+// as "sha256=<hex>" or plain hex.
+type WebhookTriggerConfig struct {
+	Name            string `yaml:"name"`
+	EventID         string `yaml:"eventId"`
+	Secret          string `yaml:"secret" secret:"true"`
+	SignatureHeader string `yaml:"signatureHeader"`
+	TimestampHeader string `yaml:"timestampHeader"`
+}
+
+// ScheduleTriggerConfig fires an event on a cron schedule in TZ, or local
+// time when it is empty. The last run is recorded in the triggers state
+// directory, and runs missed while the orchestrator was down are handled on
+// startup by CatchUp: "none" drops them, "once" runs the latest of them and
+// "all" runs each of them in order, at most MaxCatchUp of the latest.
+type ScheduleTriggerConfig struct {
+	Name       string `yaml:"name"`
+	EventID    string `yaml:"eventId"`
+	Cron       string `yaml:"cron"`
+	TZ         string `yaml:"tz"`
+	CatchUp    string `yaml:"catchUp"` // none, once or all
+	MaxCatchUp int    `yaml:"maxCatchUp"`
+}
+
+// FileTriggerConfig watches a directory for new files. Files are picked up
+// once they stop changing for SettleTime and are moved to ProcessedDir, or
+// deleted when it is empty, after the job is queued.
+type FileTriggerConfig struct {
+	Name           string        `yaml:"name"`
+	EventID        string        `yaml:"eventId"`
+	Dir            string        `yaml:"dir"`
+	Pattern        string        `yaml:"pattern"`
+	IncludeContent bool          `yaml:"includeContent"`
+	MaxContentSize int64         `yaml:"maxContentSize"`
+	ProcessedDir   string        `yaml:"processedDir"`
+	SettleTime     time.Duration `yaml:"settleTime"`
+}
+
+// NATSTriggerConfig consumes messages from a NATS subject
+type NATSTriggerConfig struct {
+	Name       string `yaml:"name"`
+	EventID    string `yaml:"eventId"`
+	URL        string `yaml:"url"`
+	Subject    string `yaml:"subject"`
+	QueueGroup string `yaml:"queueGroup"`
+	Token      string `yaml:"token" secret:"true"`
+	User       string `yaml:"user"`
+	Password   string `yaml:"password" secret:"true"`
+}
+
+// StreamTriggerConfig consumes entries from a Valkey/Redis stream through a
+// consumer group. Entries are acknowledged once their job is queued.
+type StreamTriggerConfig struct {
+	Name     string `yaml:"name"`
+	EventID  string `yaml:"eventId"`
+	URL      string `yaml:"url" secret:"true"`
+	Stream   string `yaml:"stream"`
+	Group    string `yaml:"group"`
+	Consumer string `yaml:"consumer"`
+}
+
+// AMQPTriggerConfig consumes messages from an AMQP 0-9-1 queue, such as a
+// RabbitMQ queue. Messages are acknowledged once their job is queued.
+type AMQPTriggerConfig struct {
+	Name    string `yaml:"name"`
+	EventID string `yaml:"eventId"`
+	// amqp:// or amqps:// URL, with credentials and virtual host
+	URL   string `yaml:"url" secret:"true"`
+	Queue string `yaml:"queue"`
+	// Declare the queue and bind it to this exchange when set
+	Exchange   string `yaml:"exchange"`
+	RoutingKey string `yaml:"routingKey"`
+	// Unacknowledged messages delivered at once
+	Prefetch int `yaml:"prefetch"`
+}
+
+// KafkaTriggerConfig consumes messages from a Kafka topic through a consumer
+// group. Offsets are committed once a message's job is queued.
+type KafkaTriggerConfig struct {
+	Name    string   `yaml:"name"`
+	EventID string   `yaml:"eventId"`
+	Brokers []string `yaml:"brokers"`
+	Topic   string   `yaml:"topic"`
+	Group   string   `yaml:"group"`
+	TLS     bool     `yaml:"tls"`
+	// SASL/PLAIN credentials, used when User is set
+	User     string `yaml:"user"`
+	Password string `yaml:"password" secret:"true"`
+}
+
+// PluginsConfig configures executor plugins: executables in Dir that declare
+// the job types they run and are started once per job
+type PluginsConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Dir     string `yaml:"dir" envconfig:"DIR" default:"/etc/cronium/plugins"`
+	// Time limit for the describe, validate and cleanup calls
+	CallTimeout time.Duration `yaml:"callTimeout" envconfig:"CALL_TIMEOUT" default:"10s"`
+	// Time a cancelled plugin gets to exit after SIGTERM before it is killed
+	StopGracePeriod time.Duration `yaml:"stopGracePeriod" envconfig:"STOP_GRACE_PERIOD" default:"10s"`
+	// Consecutive crashes after which a plugin is taken out of service
+	MaxFailures int           `yaml:"maxFailures" envconfig:"MAX_FAILURES" default:"3"`
+	Cooldown    time.Duration `yaml:"cooldown" envconfig:"COOLDOWN" default:"5m"`
+	// Orchestrator environment variables passed to plugins; they otherwise
+	// only get PATH, HOME and the CRONIUM_* protocol variables
+	Env []string `yaml:"env" envconfig:"ENV"`
+}
+
+// HTTPConfig defines the executor of HTTP request jobs, which send a
+// request from the orchestrator and stream the response as job output
+type HTTPConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	// Time limit for one attempt; the job timeout covers all attempts
+	RequestTimeout time.Duration `yaml:"requestTimeout" envconfig:"REQUEST_TIMEOUT" default:"30s"`
+	// Response bodies are streamed in full but only this much is kept as
+	// the job's output
+	MaxResponseSize int64 `yaml:"maxResponseSize" envconfig:"MAX_RESPONSE_SIZE" default:"1048576"`
+	// Upper bound for the retries a job asks for
+	MaxRetries int    `yaml:"maxRetries" envconfig:"MAX_RETRIES" default:"5"`
+	UserAgent  string `yaml:"userAgent" envconfig:"USER_AGENT" default:"cronium-orchestrator"`
+	// Hosts requests may go to, exactly or as subdomains of "*." entries,
+	// also after redirects; empty allows any host
+	AllowedHosts []string `yaml:"allowedHosts" envconfig:"ALLOWED_HOSTS"`
+	// Let requests reach loopback, private, link-local and other internal
+	// addresses of the orchestrator's network, which are refused after DNS
+	// resolution otherwise
+	AllowPrivateNetworks bool `yaml:"allowPrivateNetworks" envconfig:"ALLOW_PRIVATE_NETWORKS" default:"false"`
+}
+
+// JitterConfig spreads periodic work across orchestrators. Each loop starts
+// at a fixed per-orchestrator offset within its interval and every later
+// interval is moved randomly by up to the loop's factor (0.1 = ±10%).
+type JitterConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	// Seed for offsets and randomness; defaults to the orchestrator ID, or
+	// the hostname when the ID is generated
+	Seed    string  `yaml:"seed" envconfig:"SEED"`
+	Poll    float64 `yaml:"poll" envconfig:"POLL" default:"0.1"`
+	Health  float64 `yaml:"health" envconfig:"HEALTH" default:"0.2"`
+	Cleanup float64 `yaml:"cleanup" envconfig:"CLEANUP" default:"0.2"`
+}
+
+// ExportConfig defines delivery of execution records to external systems.
+// Each completed job produces one normalized record, which is sent to every
+// sink whose filters match it.
+type ExportConfig struct {
+	Extractors []OutputExtractor  `yaml:"extractors" ignored:"true"`
+	Sinks      []ExportSinkConfig `yaml:"sinks" ignored:"true"`
+}
+
+// AccountingConfig defines per-tenant usage reports. Running jobs are
+// sampled for CPU, memory and network use, and every period the totals per
+// tenant and event are written to Dir and posted to the backend.
+type AccountingConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Length of a report period; periods are aligned to multiples of it
+	Period         time.Duration `yaml:"period" envconfig:"PERIOD" default:"1h"`
+	SampleInterval time.Duration `yaml:"sampleInterval" envconfig:"SAMPLE_INTERVAL" default:"15s"`
+	Dir            string        `yaml:"dir" envconfig:"DIR" default:"/var/lib/cronium/usage"`
+	// Report files written per period: json, csv or both
+	Formats []string `yaml:"formats" envconfig:"FORMATS" default:"json,csv"`
+	// Age after which report files are deleted; 0 keeps them
+	Retention     time.Duration `yaml:"retention" envconfig:"RETENTION" default:"720h"`
+	PostToBackend bool          `yaml:"postToBackend" envconfig:"POST_TO_BACKEND" default:"true"`
+}
+
+// OutputExtractor pulls a named value out of a job's stdout. The pattern's
+// first capture group, or the whole match without one, of the last matching
+// line becomes the value.
+type OutputExtractor struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// ExportSinkConfig defines an export sink. Records are queued per sink,
+// delivered in batches and retried with backoff; empty filters match all
+// records.
+type ExportSinkConfig struct {
+	Name          string             `yaml:"name"`
+	Type          string             `yaml:"type"` // webhook, s3 or bigquery
+	JobTypes      []string           `yaml:"jobTypes"`
+	Tags          []string           `yaml:"tags"`
+	Statuses      []string           `yaml:"statuses"`
+	BatchSize     int                `yaml:"batchSize"`
+	FlushInterval time.Duration      `yaml:"flushInterval"`
+	QueueSize     int                `yaml:"queueSize"`
+	MaxAttempts   int                `yaml:"maxAttempts"`
+	Webhook       WebhookSinkConfig  `yaml:"webhook"`
+	S3            S3SinkConfig       `yaml:"s3"`
+	BigQuery      BigQuerySinkConfig `yaml:"bigquery"`
+}
+
+// WebhookSinkConfig posts batches of records as a JSON array. With a secret
+// set, the body's HMAC-SHA256 is sent as "sha256=<hex>" in
+// X-Cronium-Signature.
+type WebhookSinkConfig struct {
+	URL     string            `yaml:"url"`
+	Secret  string            `yaml:"secret" secret:"true"`
+	Headers map[string]string `yaml:"headers"`
+	Timeout time.Duration     `yaml:"timeout"`
+}
+
+// S3SinkConfig writes each batch as a JSONL object. Endpoint selects an
+// S3-compatible service; credentials fall back to the AWS_* environment
+// variables.
+type S3SinkConfig struct {
+	Bucket          string `yaml:"bucket"`
+	Region          string `yaml:"region"`
+	Endpoint        string `yaml:"endpoint"`
+	Prefix          string `yaml:"prefix"`
+	PathStyle       bool   `yaml:"pathStyle"`
+	AccessKeyID     string `yaml:"accessKeyId"`
+	SecretAccessKey string `yaml:"secretAccessKey" secret:"true"`
+	SessionToken    string `yaml:"sessionToken" secret:"true"`
+}
+
+// BigQuerySinkConfig streams records into a BigQuery table using a service
+// account key file
+type BigQuerySinkConfig struct {
+	Project         string `yaml:"project"`
+	Dataset         string `yaml:"dataset"`
+	Table           string `yaml:"table"`
+	CredentialsFile string `yaml:"credentialsFile"`
 }
 
 // Sub-configurations
@@ -126,10 +789,13 @@ type RateLimitConfig struct {
 
 // DockerConfig defines Docker daemon settings
 type DockerConfig struct {
-	Endpoint  string `yaml:"endpoint" envconfig:"ENDPOINT" default:"unix:///var/run/docker.sock"`
-	Version   string `yaml:"version" envconfig:"VERSION" default:"1.41"`
+	Endpoint string `yaml:"endpoint" envconfig:"ENDPOINT" default:"unix:///var/run/docker.sock"`
+	// API version to pin; empty negotiates with the daemon
+	Version   string `yaml:"version" envconfig:"VERSION"`
 	TLSVerify bool   `yaml:"tlsVerify" envconfig:"TLS_VERIFY" default:"false"`
 	CertPath  string `yaml:"certPath" envconfig:"CERT_PATH"`
+	// Pings before giving up on a daemon that went away mid-job
+	ReconnectAttempts int `yaml:"reconnectAttempts" envconfig:"RECONNECT_ATTEMPTS" default:"10"`
 }
 
 // ResourceConfig defines resource limits
@@ -151,8 +817,57 @@ type ContainerSecurityConfig struct {
 	User             string   `yaml:"user" envconfig:"USER" default:"1000:1000"`
 	NoNewPrivileges  bool     `yaml:"noNewPrivileges" envconfig:"NO_NEW_PRIVILEGES" default:"true"`
 	DropCapabilities []string `yaml:"dropCapabilities" envconfig:"DROP_CAPABILITIES"`
-	ReadOnlyRootfs   bool     `yaml:"readOnlyRootfs" envconfig:"READ_ONLY_ROOTFS" default:"false"`
+	ReadOnlyRootfs   bool     `yaml:"readOnlyRootfs" envconfig:"READ_ONLY_ROOTFS" default:"true"`
 	SeccompProfile   string   `yaml:"seccompProfile" envconfig:"SECCOMP_PROFILE" default:"default"`
+	// Images that keep a writable root filesystem for legacy jobs, by
+	// reference or a prefix ending in *
+	WritableRootfsImages []string `yaml:"writableRootfsImages" envconfig:"WRITABLE_ROOTFS_IMAGES"`
+}
+
+// SandboxConfig defines the sandbox profiles container jobs can select. The
+// built-in strict, standard and trusted profiles can be redefined in Profiles.
+type SandboxConfig struct {
+	DefaultProfile string `yaml:"defaultProfile" envconfig:"DEFAULT_PROFILE" default:"standard"`
+	// Profiles every tenant may use
+	AllowedProfiles []string `yaml:"allowedProfiles" envconfig:"ALLOWED_PROFILES" default:"strict,standard"`
+	// Tenant ID -> profiles that tenant may use, replacing AllowedProfiles
+	TenantProfiles map[string][]string       `yaml:"tenantProfiles" ignored:"true"`
+	Profiles       map[string]SandboxProfile `yaml:"profiles" ignored:"true"`
+	// A host firewall applies the cronium.egress label of job networks.
+	// Without one, jobs under profiles with egress rules are rejected.
+	EgressEnforced bool `yaml:"egressEnforced" envconfig:"EGRESS_ENFORCED" default:"false"`
+}
+
+// SandboxProfile bundles the security settings applied to a job container
+type SandboxProfile struct {
+	DropCapabilities []string `yaml:"dropCapabilities"`
+	AddCapabilities  []string `yaml:"addCapabilities"`
+	SeccompProfile   string   `yaml:"seccompProfile"`
+	NoNewPrivileges  bool     `yaml:"noNewPrivileges"`
+	ReadOnlyRootfs   bool     `yaml:"readOnlyRootfs"`
+	// Create the job network without an external route
+	IsolateNetwork bool `yaml:"isolateNetwork"`
+	// Destinations (CIDRs or host:port) the host firewall lets the job reach
+	// when the network is not isolated; empty allows all. Requires
+	// egressEnforced.
+	Egress []string `yaml:"egress"`
+	// Ceilings applied on top of the job's requested resources
+	MaxResources ResourceLimits `yaml:"maxResources"`
+}
+
+// ContainerStopConfig defines how job containers are stopped
+type ContainerStopConfig struct {
+	DefaultSignal      string        `yaml:"defaultSignal" envconfig:"DEFAULT_SIGNAL" default:"SIGTERM"`
+	DefaultGracePeriod time.Duration `yaml:"defaultGracePeriod" envconfig:"DEFAULT_GRACE_PERIOD" default:"10s"`
+	MaxGracePeriod     time.Duration `yaml:"maxGracePeriod" envconfig:"MAX_GRACE_PERIOD" default:"5m"`
+}
+
+// ContainerCleanupConfig defines how a job's Docker resources are removed.
+// Resources are removed in dependency order (container, sidecar, network,
+// volumes), each with up to Attempts tries RetryDelay apart.
+type ContainerCleanupConfig struct {
+	Attempts   int           `yaml:"attempts" envconfig:"ATTEMPTS" default:"3"`
+	RetryDelay time.Duration `yaml:"retryDelay" envconfig:"RETRY_DELAY" default:"1s"`
 }
 
 // VolumeConfig defines volume settings
@@ -174,8 +889,110 @@ type RuntimeConfig struct {
 	Image          string `yaml:"image" envconfig:"IMAGE" default:"cronium/runtime-api:latest"`
 	BackendURL     string `yaml:"backendURL" envconfig:"BACKEND_URL"`
 	ValkeyURL      string `yaml:"valkeyURL" envconfig:"VALKEY_URL" default:"valkey://valkey:6379"`
-	JWTSecret      string `yaml:"jwtSecret" envconfig:"JWT_SECRET"`
+	JWTSecret      string `yaml:"jwtSecret" envconfig:"JWT_SECRET" secret:"true"`
 	IsolateNetwork bool   `yaml:"isolateNetwork" envconfig:"ISOLATE_NETWORK" default:"true"`
+
+	// Push execution context, input and variables into the runtime cache at dispatch
+	Prewarm    bool          `yaml:"prewarm" envconfig:"PREWARM" default:"true"`
+	PrewarmTTL time.Duration `yaml:"prewarmTTL" envconfig:"PREWARM_TTL" default:"30m"`
+
+	// Publish cancellation notices and operator messages to running scripts
+	Messages bool `yaml:"messages" envconfig:"MESSAGES" default:"true"`
+}
+
+// ImagePullConfig defines how container images are pulled. Policy is
+// Always, IfNotPresent or Never and jobs can set their own. Images in
+// PrePull are pulled in the background at startup so the first jobs do not
+// wait for them.
+type ImagePullConfig struct {
+	Policy  string   `yaml:"policy" envconfig:"POLICY" default:"IfNotPresent"`
+	PrePull []string `yaml:"prePull" envconfig:"PRE_PULL"`
+	// How often pull progress is written to the job's log
+	ProgressInterval time.Duration `yaml:"progressInterval" envconfig:"PROGRESS_INTERVAL" default:"2s"`
+	// Docker daemon's registry certificate directory, where the CAs of
+	// private registries are installed; it must be shared with the daemon
+	CertsDir string `yaml:"certsDir" envconfig:"CERTS_DIR" default:"/etc/docker/certs.d"`
+}
+
+// RegistryConfig holds the credentials used to pull job and runtime images
+// from a registry host such as ghcr.io or registry.example.com:5000;
+// docker.io covers Docker Hub. Credentials are static (Username with
+// Password or Token), come from a Docker credential helper, or are printed
+// by TokenCommand, for short-lived tokens such as ECR's. Helper and command
+// credentials are fetched again once TokenTTL has passed or the registry
+// rejects them. CAFile is the CA of a registry with a private certificate.
+type RegistryConfig struct {
+	Host     string `yaml:"host"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"`
+	// Identity token, used instead of a password
+	Token string `yaml:"token" secret:"true"`
+	// Name of a docker-credential-<name> helper on the PATH, e.g. ecr-login
+	CredentialHelper string `yaml:"credentialHelper"`
+	// Command printing a password for Username, e.g.
+	// [aws, ecr, get-login-password, --region, eu-west-1]
+	TokenCommand []string      `yaml:"tokenCommand"`
+	TokenTTL     time.Duration `yaml:"tokenTTL"`
+	CAFile       string        `yaml:"caFile"`
+}
+
+// ImageScanConfig defines the vulnerability gate for container job images.
+// Before a job starts, its image is scanned or the registry's scan results
+// are read, and the job is blocked when the image has a vulnerability at or
+// above Severity. Verdicts are cached by image digest for CacheTTL.
+type ImageScanConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// trivy runs the Trivy client against a Trivy server; registry reads
+	// the scan results of a Harbor-compatible registry
+	Scanner  string        `yaml:"scanner" envconfig:"SCANNER" default:"trivy"`
+	Severity string        `yaml:"severity" envconfig:"SEVERITY" default:"CRITICAL"`
+	CacheTTL time.Duration `yaml:"cacheTTL" envconfig:"CACHE_TTL" default:"6h"`
+	Timeout  time.Duration `yaml:"timeout" envconfig:"TIMEOUT" default:"5m"`
+	// Run jobs whose image could not be scanned instead of failing them
+	FailOpen bool `yaml:"failOpen" envconfig:"FAIL_OPEN" default:"false"`
+	// Tenant ID -> severity for that tenant's jobs; off skips the gate
+	TenantSeverity map[string]string `yaml:"tenantSeverity" ignored:"true"`
+	// Let jobs run a blocked image by setting imageScanOverride; every
+	// override is recorded in AuditLog
+	AllowOverride bool               `yaml:"allowOverride" envconfig:"ALLOW_OVERRIDE" default:"true"`
+	AuditLog      string             `yaml:"auditLog" envconfig:"AUDIT_LOG" default:"/var/lib/cronium/audit/image-scan.jsonl"`
+	Trivy         TrivyScanConfig    `yaml:"trivy" envconfig:"TRIVY"`
+	Registry      RegistryScanConfig `yaml:"registry" envconfig:"REGISTRY"`
+}
+
+// TrivyScanConfig defines the Trivy client used in client/server mode.
+// Token is only read from CRONIUM_CONTAINER_IMAGE_SCAN_TRIVY_TOKEN, never
+// from a bare TOKEN variable.
+type TrivyScanConfig struct {
+	Binary string `yaml:"binary" envconfig:"BINARY" default:"trivy"`
+	Server string `yaml:"server" envconfig:"SERVER"`
+	Token  string `yaml:"token" split_words:"true" secret:"true"`
+}
+
+// RegistryScanConfig defines a Harbor-compatible registry whose scan
+// results are read through its v2.0 API
+type RegistryScanConfig struct {
+	URL      string `yaml:"url" envconfig:"URL"`
+	Username string `yaml:"username" envconfig:"USERNAME"`
+	Password string `yaml:"password" envconfig:"PASSWORD" secret:"true"`
+}
+
+// KubernetesConfig defines how container jobs run on Kubernetes. Each job
+// becomes a pod with the runtime API as a native sidecar; the API server and
+// credentials default to the pod's service account when running in-cluster.
+type KubernetesConfig struct {
+	Enabled   bool   `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	APIServer string `yaml:"apiServer" envconfig:"API_SERVER"`
+	Namespace string `yaml:"namespace" envconfig:"NAMESPACE" default:"cronium-jobs"`
+	TokenFile string `yaml:"tokenFile" envconfig:"TOKEN_FILE" default:"/var/run/secrets/kubernetes.io/serviceaccount/token"`
+	CAFile    string `yaml:"caFile" envconfig:"CA_FILE" default:"/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"`
+	// Service account the job pods run as
+	ServiceAccount  string            `yaml:"serviceAccount" envconfig:"SERVICE_ACCOUNT"`
+	NodeSelector    map[string]string `yaml:"nodeSelector" envconfig:"NODE_SELECTOR"`
+	ImagePullSecret string            `yaml:"imagePullSecret" envconfig:"IMAGE_PULL_SECRET"`
+	// How long a pod may stay pending before the job fails
+	StartTimeout time.Duration `yaml:"startTimeout" envconfig:"START_TIMEOUT" default:"5m"`
+	PollInterval time.Duration `yaml:"pollInterval" envconfig:"POLL_INTERVAL" default:"2s"`
 }
 
 // ConnectionPoolConfig defines connection pool settings
@@ -189,14 +1006,197 @@ type ConnectionPoolConfig struct {
 
 // SSHExecutionConfig defines SSH execution settings
 type SSHExecutionConfig struct {
-	DefaultShell           string        `yaml:"defaultShell" envconfig:"DEFAULT_SHELL" default:"/bin/bash"`
-	TempDir                string        `yaml:"tempDir" envconfig:"TEMP_DIR" default:"/tmp/cronium"`
-	CleanupAfter           bool          `yaml:"cleanupAfter" envconfig:"CLEANUP_AFTER" default:"true"`
-	PTYMode                bool          `yaml:"ptyMode" envconfig:"PTY_MODE" default:"false"`
-	PayloadStorageDir      string        `yaml:"payloadStorageDir" envconfig:"PAYLOAD_STORAGE_DIR" default:"/app/data/payloads"`
-	CleanupPayloads        bool          `yaml:"cleanupPayloads" envconfig:"CLEANUP_PAYLOADS" default:"false"`
-	PayloadRetentionPeriod time.Duration `yaml:"payloadRetentionPeriod" envconfig:"PAYLOAD_RETENTION_PERIOD" default:"24h"`
-	PayloadCleanupInterval time.Duration `yaml:"payloadCleanupInterval" envconfig:"PAYLOAD_CLEANUP_INTERVAL" default:"1h"`
+	DefaultShell           string                `yaml:"defaultShell" envconfig:"DEFAULT_SHELL" default:"/bin/bash"`
+	TempDir                string                `yaml:"tempDir" envconfig:"TEMP_DIR" default:"/tmp/cronium"`
+	CleanupAfter           bool                  `yaml:"cleanupAfter" envconfig:"CLEANUP_AFTER" default:"true"`
+	PTYMode                bool                  `yaml:"ptyMode" envconfig:"PTY_MODE" default:"false"`
+	PayloadStorageDir      string                `yaml:"payloadStorageDir" envconfig:"PAYLOAD_STORAGE_DIR" default:"/app/data/payloads"`
+	CleanupPayloads        bool                  `yaml:"cleanupPayloads" envconfig:"CLEANUP_PAYLOADS" default:"false"`
+	PayloadRetentionPeriod time.Duration         `yaml:"payloadRetentionPeriod" envconfig:"PAYLOAD_RETENTION_PERIOD" default:"24h"`
+	PayloadCleanupInterval time.Duration         `yaml:"payloadCleanupInterval" envconfig:"PAYLOAD_CLEANUP_INTERVAL" default:"1h"`
+	CancelGracePeriod      time.Duration         `yaml:"cancelGracePeriod" envconfig:"CANCEL_GRACE_PERIOD" default:"5s"`
+	ResultUpload           ResultUploadConfig    `yaml:"resultUpload" envconfig:"RESULT_UPLOAD"`
+	ScriptCache            ScriptCacheConfig     `yaml:"scriptCache" envconfig:"SCRIPT_CACHE"`
+	FailureSnapshot        FailureSnapshotConfig `yaml:"failureSnapshot" envconfig:"FAILURE_SNAPSHOT"`
+	DiskCheck              DiskCheckConfig       `yaml:"diskCheck" envconfig:"DISK_CHECK"`
+	PayloadStorage         PayloadStorageConfig  `yaml:"payloadStorage" envconfig:"PAYLOAD_STORAGE"`
+	PayloadSigning         PayloadSigningConfig  `yaml:"payloadSigning" envconfig:"PAYLOAD_SIGNING"`
+	PayloadFiles           PayloadFilesConfig    `yaml:"payloadFiles" envconfig:"PAYLOAD_FILES"`
+	InputRefs              InputRefsConfig       `yaml:"inputRefs" envconfig:"INPUT_REFS"`
+	Checkpoint             CheckpointConfig      `yaml:"checkpoint" envconfig:"CHECKPOINT"`
+	Recording              RecordingConfig       `yaml:"recording" envconfig:"RECORDING"`
+	Transfer               TransferConfig        `yaml:"transfer" envconfig:"TRANSFER"`
+	// Run the runner under /usr/bin/time, where the server has it, to report
+	// the job's CPU time, peak memory and block I/O when it ends
+	MeasureUsage bool `yaml:"measureUsage" envconfig:"MEASURE_USAGE" default:"true"`
+}
+
+// TransferConfig defines how payloads and the runner are copied to servers.
+// The sftp method uploads through the SFTP subsystem in chunks to a
+// temporary file, resumes it on retry and, with Verify, reads it back to
+// compare its SHA-256 before moving it into place. The cat method pipes the
+// file through `cat` on servers without SFTP.
+type TransferConfig struct {
+	Method string `yaml:"method" envconfig:"METHOD" default:"sftp"`
+	// Bytes per SFTP read or write; every server accepts 32768
+	ChunkSize  int           `yaml:"chunkSize" envconfig:"CHUNK_SIZE" default:"32768"`
+	Retries    int           `yaml:"retries" envconfig:"RETRIES" default:"3"`
+	RetryDelay time.Duration `yaml:"retryDelay" envconfig:"RETRY_DELAY" default:"1s"`
+	Verify     bool          `yaml:"verify" envconfig:"VERIFY" default:"true"`
+}
+
+// RecordingConfig defines session recordings of SSH jobs for audit. The
+// command sent to the server and the job's output are written to Dir in the
+// asciicast v2 format, with secrets masked, and referenced in the execution
+// record. Finished recordings are read-only and deleted after Retention.
+type RecordingConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Dir     string `yaml:"dir" envconfig:"DIR" default:"/var/lib/cronium/recordings"`
+	// User IDs whose jobs are recorded; empty records every job
+	Tenants   []string      `yaml:"tenants" envconfig:"TENANTS"`
+	Retention time.Duration `yaml:"retention" envconfig:"RETENTION" default:"2160h"`
+	// Largest size of a recording; later output is left out. Zero means no
+	// limit.
+	MaxSize int64 `yaml:"maxSize" envconfig:"MAX_SIZE" default:"10485760"`
+}
+
+// CheckpointConfig defines checkpoints of SSH jobs interrupted by an
+// orchestrator shutdown. The runner flushes the job's output and variables
+// and writes a progress marker to TempDir/checkpoints/<execution ID> before
+// the job is stopped; the job is reported as interrupted with hints for
+// resuming it from there. Requires a runner that supports --checkpoint-dir.
+type CheckpointConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// How long the runner gets to confirm the checkpoint before the job is
+	// stopped anyway
+	Timeout time.Duration `yaml:"timeout" envconfig:"TIMEOUT" default:"10s"`
+}
+
+// PayloadStorageConfig defines where payloads are kept after they have been
+// sent to the server, until cleanup removes them. Payloads are staged for
+// transfer under PayloadStorageDir whatever the backend. When the quota is
+// reached the least recently used payloads are evicted.
+type PayloadStorageConfig struct {
+	// local keeps payloads in PayloadStorageDir, tmpfs in TmpfsDir and s3 in
+	// an S3 bucket
+	Backend  string `yaml:"backend" envconfig:"BACKEND" default:"local"`
+	TmpfsDir string `yaml:"tmpfsDir" envconfig:"TMPFS_DIR" default:"/dev/shm/cronium-payloads"`
+	// Base64-encoded 32-byte AES key; stored payloads are encrypted with
+	// AES-256-GCM when set
+	EncryptionKey string `yaml:"encryptionKey" envconfig:"ENCRYPTION_KEY" secret:"true"`
+	// Total size and number of stored payloads; zero means no limit
+	MaxBytes    int64           `yaml:"maxBytes" envconfig:"MAX_BYTES" default:"1073741824"`
+	MaxPayloads int             `yaml:"maxPayloads" envconfig:"MAX_PAYLOADS" default:"0"`
+	S3          PayloadS3Config `yaml:"s3" envconfig:"S3"`
+}
+
+// PayloadSigningConfig defines the Ed25519 key payloads are signed with.
+// The signature is uploaded next to each payload as <payload>.sig, and
+// runners that trust the key's public half require it. A missing KeyFile is
+// generated; its public key is logged at startup.
+type PayloadSigningConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	KeyFile string `yaml:"keyFile" envconfig:"KEY_FILE" default:"/app/data/payload-signing.key"`
+}
+
+// PayloadFilesConfig defines where the extra files a job bundles into its
+// payload come from. Include globs only match files under SourceDir, and
+// artifacts are only fetched from URLs starting with one of
+// AllowedURLPrefixes; with either empty, jobs cannot use that kind of file.
+type PayloadFilesConfig struct {
+	SourceDir          string   `yaml:"sourceDir" envconfig:"SOURCE_DIR"`
+	AllowedURLPrefixes []string `yaml:"allowedUrlPrefixes" envconfig:"ALLOWED_URL_PREFIXES"`
+	// Limits on the files bundled into one payload, templates rendered and
+	// artifacts fetched
+	MaxFiles     int           `yaml:"maxFiles" envconfig:"MAX_FILES" default:"1000"`
+	MaxBytes     int64         `yaml:"maxBytes" envconfig:"MAX_BYTES" default:"52428800"`
+	FetchTimeout time.Duration `yaml:"fetchTimeout" envconfig:"FETCH_TIMEOUT" default:"1m"`
+}
+
+// InputRefsConfig defines the large input objects jobs reference instead of
+// inlining them. The runner streams each object into the job's workspace, so
+// it never passes through the orchestrator. https URLs must start with one
+// of AllowedURLPrefixes. s3:// URLs are presigned for PresignExpiry with the
+// S3 credentials, which stay on the orchestrator. Objects are limited to
+// MaxSize bytes unless a job sets a smaller limit.
+type InputRefsConfig struct {
+	AllowedURLPrefixes []string         `yaml:"allowedUrlPrefixes" envconfig:"ALLOWED_URL_PREFIXES"`
+	MaxSize            int64            `yaml:"maxSize" envconfig:"MAX_SIZE" default:"10737418240"`
+	PresignExpiry      time.Duration    `yaml:"presignExpiry" envconfig:"PRESIGN_EXPIRY" default:"1h"`
+	S3                 InputRefS3Config `yaml:"s3" envconfig:"S3"`
+}
+
+// InputRefS3Config is the S3 account input objects are presigned with.
+// Endpoint selects an S3-compatible service; credentials fall back to the
+// AWS_* environment variables.
+type InputRefS3Config struct {
+	Region          string `yaml:"region" envconfig:"REGION" default:"us-east-1"`
+	Endpoint        string `yaml:"endpoint" envconfig:"ENDPOINT"`
+	PathStyle       bool   `yaml:"pathStyle" envconfig:"PATH_STYLE"`
+	AccessKeyID     string `yaml:"accessKeyId" envconfig:"ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" envconfig:"SECRET_ACCESS_KEY" secret:"true"`
+	SessionToken    string `yaml:"sessionToken" envconfig:"SESSION_TOKEN" secret:"true"`
+}
+
+// PayloadS3Config is the bucket of the s3 payload backend. Endpoint selects
+// an S3-compatible service; credentials fall back to the AWS_* environment
+// variables.
+type PayloadS3Config struct {
+	Bucket          string `yaml:"bucket" envconfig:"BUCKET"`
+	Region          string `yaml:"region" envconfig:"REGION"`
+	Endpoint        string `yaml:"endpoint" envconfig:"ENDPOINT"`
+	Prefix          string `yaml:"prefix" envconfig:"PREFIX"`
+	PathStyle       bool   `yaml:"pathStyle" envconfig:"PATH_STYLE"`
+	AccessKeyID     string `yaml:"accessKeyId" envconfig:"ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" envconfig:"SECRET_ACCESS_KEY" secret:"true"`
+	SessionToken    string `yaml:"sessionToken" envconfig:"SESSION_TOKEN" secret:"true"`
+}
+
+// DiskCheckConfig defines the free space check on remote servers before the
+// payload is transferred. A job fails early when /tmp cannot hold the payload
+// and its extracted files plus Headroom, or has too few inodes.
+type DiskCheckConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	// Bytes that must remain free besides the payload
+	Headroom int64 `yaml:"headroom" envconfig:"HEADROOM" default:"67108864"`
+	// Inodes that must remain free besides the payload's files
+	MinFreeInodes int64 `yaml:"minFreeInodes" envconfig:"MIN_FREE_INODES" default:"1000"`
+}
+
+// FailureSnapshotConfig defines snapshots of the workspace of failed jobs.
+// The runner archives the work directory, with secrets scrubbed, to
+// TempDir/snapshots on the server before removing it. Jobs can override
+// Enabled with snapshotOnFailure. Requires a runner that supports
+// --snapshot-dir.
+type FailureSnapshotConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Largest total size of the files in a snapshot; larger files are left out
+	MaxSize int64 `yaml:"maxSize" envconfig:"MAX_SIZE" default:"52428800"`
+	// Snapshots older than this are pruned by the runner
+	Retention time.Duration `yaml:"retention" envconfig:"RETENTION" default:"72h"`
+	// Also upload snapshots as execution artifacts for jobs in API mode
+	Upload bool `yaml:"upload" envconfig:"UPLOAD" default:"false"`
+}
+
+// ScriptCacheConfig defines the content-addressed script cache on remote
+// servers. Cached scripts are stored by SHA-256 under TempDir/scripts, so a
+// script that has not changed is not transferred again. Requires a runner
+// that supports --script-cache.
+type ScriptCacheConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Smaller scripts are always sent with the payload
+	MinSize int `yaml:"minSize" envconfig:"MIN_SIZE" default:"1024"`
+	// Cached scripts unused for this long are pruned by the runner
+	MaxAge time.Duration `yaml:"maxAge" envconfig:"MAX_AGE" default:"168h"`
+}
+
+// ResultUploadConfig defines signed result upload URLs for bundled-mode runners
+type ResultUploadConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	// Runtime URL reachable from the remote servers
+	BaseURL string `yaml:"baseURL" envconfig:"BASE_URL"`
+	// How long the URL stays valid after the job timeout
+	GracePeriod time.Duration `yaml:"gracePeriod" envconfig:"GRACE_PERIOD" default:"10m"`
 }
 
 // CircuitBreakerConfig defines circuit breaker settings
@@ -214,6 +1214,21 @@ type SSHSecurityConfig struct {
 	KnownHostsFile        string   `yaml:"knownHostsFile" envconfig:"KNOWN_HOSTS_FILE" default:"/etc/cronium/known_hosts"`
 	AllowedCiphers        []string `yaml:"allowedCiphers" envconfig:"ALLOWED_CIPHERS"`
 	AllowedKeyExchanges   []string `yaml:"allowedKeyExchanges" envconfig:"ALLOWED_KEY_EXCHANGES"`
+	// Setup commands the executor may run on servers
+	CommandPolicy CommandPolicyConfig `yaml:"commandPolicy" envconfig:"COMMAND_POLICY"`
+}
+
+// CommandPolicyConfig restricts the setup commands the SSH executor runs on
+// servers (deploying the runner, copying and removing payloads) to a fixed
+// set of programs and flags whose paths lie under the allowed paths, so a
+// tampered job payload cannot turn the setup channel into a remote shell.
+type CommandPolicyConfig struct {
+	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	// Directories or path globs setup commands may touch; the execution
+	// temp directory is always allowed
+	AllowedPaths []string `yaml:"allowedPaths" envconfig:"ALLOWED_PATHS" default:"/tmp/cronium,/tmp/cronium-*"`
+	// Log violations without blocking them
+	AuditOnly bool `yaml:"auditOnly" envconfig:"AUDIT_ONLY" default:"false"`
 }
 
 // FileLogConfig defines file logging settings
@@ -232,6 +1247,31 @@ type WSLogConfig struct {
 	FlushInterval time.Duration `yaml:"flushInterval" envconfig:"FLUSH_INTERVAL" default:"100ms"`
 	BatchSize     int           `yaml:"batchSize" envconfig:"BATCH_SIZE" default:"50"`
 	Compression   bool          `yaml:"compression" envconfig:"COMPRESSION" default:"true"`
+	// Local copy of job output that subscribers can replay
+	WAL LogWALConfig `yaml:"wal" envconfig:"WAL"`
+}
+
+// LogWALConfig defines the local write-ahead log of job output
+type LogWALConfig struct {
+	Enabled   bool          `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	Dir       string        `yaml:"dir" envconfig:"DIR" default:"/var/lib/cronium/logs"`
+	Retention time.Duration `yaml:"retention" envconfig:"RETENTION" default:"24h"`
+}
+
+// JobLogStoreConfig defines the on-disk store of job logs
+type JobLogStoreConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Dir     string `yaml:"dir" envconfig:"DIR" default:"/var/log/cronium/jobs"`
+	// Size at which a job's log file is rotated, in bytes
+	MaxFileSize int64 `yaml:"maxFileSize" envconfig:"MAX_FILE_SIZE" default:"10485760"`
+	// Rotated files kept per job; older ones are deleted
+	MaxFiles  int           `yaml:"maxFiles" envconfig:"MAX_FILES" default:"5"`
+	Compress  bool          `yaml:"compress" envconfig:"COMPRESS" default:"true"`
+	Retention time.Duration `yaml:"retention" envconfig:"RETENTION" default:"168h"`
+	// Bearer token required by the health server's job log endpoints; when
+	// empty the endpoints are disabled and logs are only kept on disk. Only
+	// read from CRONIUM_LOGGING_JOBS_TOKEN, never from a bare TOKEN variable.
+	Token string `yaml:"token" split_words:"true" secret:"true"`
 }
 
 // TracingConfig defines tracing settings
@@ -242,6 +1282,21 @@ type TracingConfig struct {
 	SamplingRate float64 `yaml:"samplingRate" envconfig:"SAMPLING_RATE" default:"0.1"`
 }
 
+// HistoryConfig defines local retention of metrics for orchestrators that
+// nothing scrapes. Every Interval the metrics whose names start with Prefix
+// are appended to a file per day in Dir. Once a day is over its samples are
+// downsampled to Resolution; the raw samples are kept for RawRetention and
+// the downsampled ones for Retention.
+type HistoryConfig struct {
+	Enabled      bool          `yaml:"enabled" envconfig:"ENABLED" default:"false"`
+	Dir          string        `yaml:"dir" envconfig:"DIR" default:"/var/lib/cronium/metrics"`
+	Interval     time.Duration `yaml:"interval" envconfig:"INTERVAL" default:"1m"`
+	Prefix       string        `yaml:"prefix" envconfig:"PREFIX" default:"cronium_"`
+	Resolution   time.Duration `yaml:"resolution" envconfig:"RESOLUTION" default:"1h"`
+	RawRetention time.Duration `yaml:"rawRetention" envconfig:"RAW_RETENTION" default:"48h"`
+	Retention    time.Duration `yaml:"retention" envconfig:"RETENTION" default:"2160h"`
+}
+
 // ProfilingConfig defines profiling settings
 type ProfilingConfig struct {
 	Enabled bool `yaml:"enabled" envconfig:"ENABLED" default:"false"`
@@ -268,8 +1323,75 @@ type EncryptionConfig struct {
 	KeyDerivation string `yaml:"keyDerivation" envconfig:"KEY_DERIVATION" default:"pbkdf2"`
 }
 
+// ReceiptsConfig defines signed execution receipts
+type ReceiptsConfig struct {
+	Enabled bool   `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	KeyFile string `yaml:"keyFile" envconfig:"KEY_FILE" default:"/app/data/receipt-signing.key"`
+}
+
+// OutputScanningConfig defines masking of sensitive data in job output
+type OutputScanningConfig struct {
+	Enabled   bool                   `yaml:"enabled" envconfig:"ENABLED" default:"true"`
+	Detectors []string               `yaml:"detectors" envconfig:"DETECTORS" default:"credit_card,aws_access_key,aws_secret_key,jwt"`
+	Custom    []CustomDetectorConfig `yaml:"custom"`
+}
+
+// CustomDetectorConfig is a named regular expression to mask in job output
+type CustomDetectorConfig struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
 // Load loads configuration from file and environment
 func Load(configPath string) (*Config, error) {
+	config, err := readFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply environment variables
+	if err := envconfig.Process("CRONIUM", config); err != nil {
+		return nil, fmt.Errorf("failed to process environment variables: %w", err)
+	}
+
+	// Process special values
+	if err := processConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to process config: %w", err)
+	}
+
+	// Validate configuration
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// Read loads configuration without validating it, for commands such as lint
+// that only consult job and container settings and must work without API
+// credentials. Environment variables apply to those two sections only.
+func Read(configPath string) (*Config, error) {
+	config, err := readFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := envconfig.Process("CRONIUM_JOBS", &config.Jobs); err != nil {
+		return nil, fmt.Errorf("failed to process environment variables: %w", err)
+	}
+	if err := envconfig.Process("CRONIUM_CONTAINER", &config.Container); err != nil {
+		return nil, fmt.Errorf("failed to process environment variables: %w", err)
+	}
+
+	if err := processConfig(config); err != nil {
+		return nil, fmt.Errorf("failed to process config: %w", err)
+	}
+
+	return config, nil
+}
+
+// readFile applies the defaults and the configuration file
+func readFile(configPath string) (*Config, error) {
 	config := &Config{}
 
 	// Set defaults
@@ -300,21 +1422,6 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
 
-	// Apply environment variables
-	if err := envconfig.Process("CRONIUM", config); err != nil {
-		return nil, fmt.Errorf("failed to process environment variables: %w", err)
-	}
-
-	// Process special values
-	if err := processConfig(config); err != nil {
-		return nil, fmt.Errorf("failed to process config: %w", err)
-	}
-
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("configuration validation failed: %w", err)
-	}
-
 	return config, nil
 }
 
@@ -325,15 +1432,116 @@ func setDefaults() {
 	viper.SetDefault("orchestrator.environment", "production")
 	viper.SetDefault("orchestrator.region", "default")
 
+	viper.SetDefault("dns.enabled", true)
+	viper.SetDefault("dns.timeout", "2s")
+	viper.SetDefault("dns.minTTL", "5s")
+	viper.SetDefault("dns.maxTTL", "5m")
+	viper.SetDefault("dns.defaultTTL", "30s")
+	viper.SetDefault("dns.negativeTTL", "10s")
+	viper.SetDefault("dns.staleFor", "1m")
+
 	viper.SetDefault("jobs.pollInterval", "1s")
 	viper.SetDefault("jobs.pollBatchSize", 10)
 	viper.SetDefault("jobs.maxConcurrent", 5)
 	viper.SetDefault("jobs.defaultTimeout", "1h")
 	viper.SetDefault("jobs.queueStrategy", "priority")
+	viper.SetDefault("jobs.concurrencyPolicy", "allow")
 	viper.SetDefault("jobs.leaseRenewal", "30s")
+	viper.SetDefault("jobs.push.enabled", false)
+	viper.SetDefault("jobs.push.pollInterval", "30s")
+	viper.SetDefault("jobs.push.reconnectDelay", "1s")
+	viper.SetDefault("jobs.push.maxReconnectDelay", "1m")
+	viper.SetDefault("jobs.push.ackTimeout", "10s")
+	viper.SetDefault("jobs.workStealing.enabled", false)
+	viper.SetDefault("jobs.workStealing.heartbeatInterval", "15s")
+	viper.SetDefault("jobs.workStealing.prefetchLimit", 5)
+	viper.SetDefault("jobs.workStealing.handoffAfter", "10s")
+	viper.SetDefault("features.sshScriptCache", true)
+	viper.SetDefault("features.sshSnapshots", true)
+	viper.SetDefault("features.sshCheckpoints", true)
+	viper.SetDefault("jobs.matrix.maxCombinations", 64)
+	viper.SetDefault("jobs.matrix.maxParallel", 4)
+	viper.SetDefault("jobs.gates.defaultInterval", "10s")
+	viper.SetDefault("jobs.gates.defaultTimeout", "30m")
+	viper.SetDefault("jobs.gates.maxTimeout", "24h")
+	viper.SetDefault("jobs.gates.minInterval", "1s")
+	viper.SetDefault("jobs.gates.approvalInterval", "15s")
+	viper.SetDefault("jobs.gates.approvalExpiry", "1h")
+	viper.SetDefault("jobs.gates.maxApprovalExpiry", "72h")
+	viper.SetDefault("jobs.analysis.enabled", false)
+	viper.SetDefault("jobs.analysis.mode", "warn")
+	viper.SetDefault("jobs.analysis.image", "cronium/analysis:latest")
+	viper.SetDefault("jobs.analysis.tools", []string{"shellcheck", "bandit", "semgrep"})
+	viper.SetDefault("jobs.analysis.semgrepRules", "/rules")
+	viper.SetDefault("jobs.analysis.blockSeverity", "error")
+	viper.SetDefault("jobs.analysis.timeout", "60s")
+	viper.SetDefault("jobs.quarantine.enabled", false)
+	viper.SetDefault("jobs.quarantine.threshold", 5)
+	viper.SetDefault("jobs.quarantine.duration", "0s")
+	viper.SetDefault("jobs.deadLetter.enabled", true)
+	viper.SetDefault("jobs.deadLetter.maxAttempts", 3)
+	viper.SetDefault("jobs.deadLetter.file", "/var/lib/cronium/dead-letters.json")
+	viper.SetDefault("jobs.deadLetter.retention", "168h")
+	viper.SetDefault("jobs.drain.timeout", "1h")
+	viper.SetDefault("jobs.durations.enabled", false)
+	viper.SetDefault("jobs.durations.file", "/var/lib/cronium/durations.json")
+	viper.SetDefault("jobs.durations.alpha", 0.3)
+	viper.SetDefault("jobs.durations.overrunFactor", 2)
+	viper.SetDefault("jobs.durations.minSamples", 3)
+	viper.SetDefault("jobs.durations.minOverrun", "1m")
+	viper.SetDefault("jobs.durations.notify", false)
+	viper.SetDefault("jobs.completion.workers", 2)
+	viper.SetDefault("jobs.completion.dir", "/var/lib/cronium/completions")
+	viper.SetDefault("jobs.completion.maxRetryDelay", "1m")
+	viper.SetDefault("jobs.completion.maxAge", "24h")
+
+	viper.SetDefault("ssh.runner.rolloutPercent", 0)
+	viper.SetDefault("ssh.runner.releases.enabled", false)
+	viper.SetDefault("ssh.runner.releases.channel", "stable")
+	viper.SetDefault("ssh.runner.releases.insecureSkipVerify", false)
+	viper.SetDefault("ssh.runner.releases.architectures", []string{"linux-amd64", "linux-arm64"})
+	viper.SetDefault("ssh.runner.releases.checkInterval", "1h")
+	viper.SetDefault("ssh.runner.releases.keep", 3)
+	viper.SetDefault("ssh.runner.releases.autoUpdate", false)
+	viper.SetDefault("ssh.runner.releases.timeout", "5m")
+	viper.SetDefault("ssh.runner.cache.file", "/app/data/runner-cache.json")
+	viper.SetDefault("ssh.runner.cache.freshness", "1h")
+	viper.SetDefault("ssh.execution.cancelGracePeriod", "5s")
+	viper.SetDefault("ssh.execution.resultUpload.enabled", false)
+	viper.SetDefault("ssh.execution.resultUpload.gracePeriod", "10m")
+	viper.SetDefault("ssh.execution.scriptCache.enabled", false)
+	viper.SetDefault("ssh.execution.scriptCache.minSize", 1024)
+	viper.SetDefault("ssh.execution.scriptCache.maxAge", "168h")
+	viper.SetDefault("ssh.execution.payloadStorage.backend", "local")
+	viper.SetDefault("ssh.execution.payloadStorage.tmpfsDir", "/dev/shm/cronium-payloads")
+	viper.SetDefault("ssh.execution.payloadStorage.maxBytes", 1073741824)
+	viper.SetDefault("ssh.execution.payloadSigning.enabled", true)
+	viper.SetDefault("ssh.execution.payloadSigning.keyFile", "/app/data/payload-signing.key")
+	viper.SetDefault("ssh.execution.measureUsage", true)
+	viper.SetDefault("ssh.execution.payloadFiles.maxFiles", 1000)
+	viper.SetDefault("ssh.execution.payloadFiles.maxBytes", 52428800)
+	viper.SetDefault("ssh.execution.payloadFiles.fetchTimeout", "1m")
+	viper.SetDefault("ssh.execution.inputRefs.maxSize", 10737418240)
+	viper.SetDefault("ssh.execution.inputRefs.presignExpiry", "1h")
+	viper.SetDefault("ssh.execution.inputRefs.s3.region", "us-east-1")
+	viper.SetDefault("ssh.execution.checkpoint.enabled", false)
+	viper.SetDefault("ssh.execution.checkpoint.timeout", "10s")
+	viper.SetDefault("ssh.fanOut.maxParallel", 0)
+	viper.SetDefault("ssh.fanOut.batchSize", 0)
+	viper.SetDefault("ssh.fanOut.batchPause", "0s")
+	viper.SetDefault("ssh.fanOut.failureThreshold", 0)
+	viper.SetDefault("ssh.stickyTarget.ttl", "24h")
+	viper.SetDefault("ssh.stickyTarget.file", "/var/lib/cronium/sticky-targets.json")
+	viper.SetDefault("ssh.stickyTarget.probeTimeout", "10s")
+
+	viper.SetDefault("http.enabled", true)
+	viper.SetDefault("http.requestTimeout", "30s")
+	viper.SetDefault("http.maxResponseSize", 1048576)
+	viper.SetDefault("http.maxRetries", 5)
+	viper.SetDefault("http.userAgent", "cronium-orchestrator")
 
 	viper.SetDefault("container.docker.endpoint", "unix:///var/run/docker.sock")
-	viper.SetDefault("container.docker.version", "1.41")
+	viper.SetDefault("container.docker.reconnectAttempts", 10)
 	viper.SetDefault("container.resources.defaults.cpu", 0.5)
 	viper.SetDefault("container.resources.defaults.memory", "512MB")
 	viper.SetDefault("container.resources.defaults.disk", "1GB")
@@ -341,14 +1549,82 @@ func setDefaults() {
 	viper.SetDefault("container.security.user", "1000:1000")
 	viper.SetDefault("container.security.noNewPrivileges", true)
 	viper.SetDefault("container.security.dropCapabilities", []string{"ALL"})
+	viper.SetDefault("container.security.readOnlyRootfs", true)
+	viper.SetDefault("container.stop.defaultSignal", "SIGTERM")
+	viper.SetDefault("container.runtime.prewarm", true)
+	viper.SetDefault("container.runtime.prewarmTTL", "30m")
+	viper.SetDefault("container.runtime.messages", true)
+	viper.SetDefault("container.stop.defaultGracePeriod", "10s")
+	viper.SetDefault("container.stop.maxGracePeriod", "5m")
+	viper.SetDefault("container.cleanup.attempts", 3)
+	viper.SetDefault("container.cleanup.retryDelay", "1s")
+	viper.SetDefault("container.sandbox.defaultProfile", "standard")
+	viper.SetDefault("container.sandbox.allowedProfiles", []string{"strict", "standard"})
+	viper.SetDefault("container.pull.policy", "IfNotPresent")
+	viper.SetDefault("container.pull.progressInterval", "2s")
+	viper.SetDefault("container.pull.certsDir", "/etc/docker/certs.d")
 
 	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.format", "json")
 	viper.SetDefault("logging.output", "stdout")
+	viper.SetDefault("logging.websocket.wal.enabled", true)
+	viper.SetDefault("logging.websocket.wal.dir", "/var/lib/cronium/logs")
+	viper.SetDefault("logging.websocket.wal.retention", "24h")
+	viper.SetDefault("logging.jobs.enabled", false)
+	viper.SetDefault("logging.jobs.dir", "/var/log/cronium/jobs")
+	viper.SetDefault("logging.jobs.maxFileSize", 10485760)
+	viper.SetDefault("logging.jobs.maxFiles", 5)
+	viper.SetDefault("logging.jobs.compress", true)
+	viper.SetDefault("logging.jobs.retention", "168h")
 
 	viper.SetDefault("monitoring.enabled", true)
 	viper.SetDefault("monitoring.metricsPort", 9090)
 	viper.SetDefault("monitoring.healthPort", 8080)
+	viper.SetDefault("monitoring.resourceUsage.enabled", true)
+	viper.SetDefault("monitoring.resourceUsage.sampleInterval", "15s")
+	viper.SetDefault("monitoring.history.enabled", false)
+	viper.SetDefault("monitoring.history.dir", "/var/lib/cronium/metrics")
+	viper.SetDefault("monitoring.history.interval", "1m")
+	viper.SetDefault("monitoring.history.prefix", "cronium_")
+	viper.SetDefault("monitoring.history.resolution", "1h")
+	viper.SetDefault("monitoring.history.rawRetention", "48h")
+	viper.SetDefault("monitoring.history.retention", "2160h")
+
+	viper.SetDefault("admin.enabled", false)
+	viper.SetDefault("admin.port", 9091)
+	viper.SetDefault("admin.statsInterval", "2s")
+
+	viper.SetDefault("triggers.restartDelay", "5s")
+	viper.SetDefault("triggers.stateDir", "/app/data/triggers")
+	viper.SetDefault("triggers.webhook.enabled", false)
+	viper.SetDefault("triggers.webhook.port", 9092)
+	viper.SetDefault("triggers.webhook.maxBodySize", 1048576)
+	viper.SetDefault("triggers.webhook.tolerance", "5m")
+
+	viper.SetDefault("plugins.enabled", false)
+	viper.SetDefault("plugins.dir", "/etc/cronium/plugins")
+	viper.SetDefault("plugins.callTimeout", "10s")
+	viper.SetDefault("plugins.stopGracePeriod", "10s")
+	viper.SetDefault("plugins.maxFailures", 3)
+	viper.SetDefault("plugins.cooldown", "5m")
+
+	viper.SetDefault("accounting.enabled", false)
+	viper.SetDefault("accounting.period", "1h")
+	viper.SetDefault("accounting.sampleInterval", "15s")
+	viper.SetDefault("accounting.dir", "/var/lib/cronium/usage")
+	viper.SetDefault("accounting.formats", []string{"json", "csv"})
+	viper.SetDefault("accounting.retention", "720h")
+	viper.SetDefault("accounting.postToBackend", true)
+
+	viper.SetDefault("jitter.enabled", true)
+	viper.SetDefault("jitter.poll", 0.1)
+	viper.SetDefault("jitter.health", 0.2)
+	viper.SetDefault("jitter.cleanup", 0.2)
+
+	viper.SetDefault("security.receipts.enabled", true)
+	viper.SetDefault("security.receipts.keyFile", "/app/data/receipt-signing.key")
+	viper.SetDefault("security.outputScanning.enabled", true)
+	viper.SetDefault("security.outputScanning.detectors", []string{"credit_card", "aws_access_key", "aws_secret_key", "jwt"})
 }
 
 // processConfig processes special configuration values
@@ -357,6 +1633,12 @@ func processConfig(config *Config) error {
 	if config.Orchestrator.ID == "auto" || config.Orchestrator.ID == "" {
 		hostname, _ := os.Hostname()
 		config.Orchestrator.ID = fmt.Sprintf("orch-%s-%d", hostname, time.Now().Unix())
+
+		// The generated ID changes on every start; seed jitter from the
+		// host so the agent keeps its phase across restarts
+		if config.Jitter.Seed == "" {
+			config.Jitter.Seed = "host-" + hostname
+		}
 	}
 
 	// Set orchestrator ID in API config
@@ -368,6 +1650,9 @@ func processConfig(config *Config) error {
 		wsEndpoint = strings.Replace(wsEndpoint, "https://", "wss://", 1)
 		config.API.WSEndpoint = wsEndpoint + "/socket"
 	}
+	if config.Jobs.Push.Endpoint == "" && config.API.WSEndpoint != "" {
+		config.Jobs.Push.Endpoint = strings.TrimSuffix(config.API.WSEndpoint, "/") + "/jobs"
+	}
 
 	// Set default drop capabilities if empty
 	if len(config.Container.Security.DropCapabilities) == 0 {
@@ -404,11 +1689,127 @@ func (c *Config) Validate() error {
 	if c.Jobs.PollBatchSize < 1 || c.Jobs.PollBatchSize > 50 {
 		errors = append(errors, "jobs.pollBatchSize must be between 1 and 50")
 	}
+	switch c.Jobs.ConcurrencyPolicy {
+	case "allow", "forbid", "replace":
+	default:
+		errors = append(errors, "jobs.concurrencyPolicy must be 'allow', 'forbid' or 'replace'")
+	}
+
+	if c.DNS.Enabled {
+		if c.DNS.Timeout <= 0 {
+			errors = append(errors, "dns.timeout must be positive")
+		}
+		if c.DNS.MinTTL < 0 || c.DNS.MaxTTL < c.DNS.MinTTL {
+			errors = append(errors, "dns.maxTTL must not be less than dns.minTTL")
+		}
+		if c.DNS.NegativeTTL < 0 || c.DNS.StaleFor < 0 {
+			errors = append(errors, "dns.negativeTTL and dns.staleFor must not be negative")
+		}
+		for host, ip := range c.DNS.Static {
+			if net.ParseIP(ip) == nil {
+				errors = append(errors, fmt.Sprintf("dns.static[%s] must be an IP address", host))
+			}
+		}
+	}
 
 	// Validate resource limits
 	if c.Container.Resources.Defaults.CPU > c.Container.Resources.Limits.CPU {
 		errors = append(errors, "container default CPU exceeds limit")
 	}
+	if c.Container.Docker.ReconnectAttempts < 1 {
+		errors = append(errors, "container.docker.reconnectAttempts must be at least 1")
+	}
+	if c.Container.Stop.DefaultGracePeriod > c.Container.Stop.MaxGracePeriod {
+		errors = append(errors, "container.stop.defaultGracePeriod exceeds maxGracePeriod")
+	}
+	if c.Container.Cleanup.Attempts < 1 {
+		errors = append(errors, "container.cleanup.attempts must be at least 1")
+	}
+	if c.Container.Cleanup.RetryDelay < 0 {
+		errors = append(errors, "container.cleanup.retryDelay must not be negative")
+	}
+	if k := c.Container.Kubernetes; k.Enabled {
+		if k.Namespace == "" {
+			errors = append(errors, "container.kubernetes.namespace is required")
+		}
+		if k.APIServer != "" && !strings.HasPrefix(k.APIServer, "https://") && !strings.HasPrefix(k.APIServer, "http://") {
+			errors = append(errors, "container.kubernetes.apiServer must be an http or https URL")
+		}
+		if k.StartTimeout <= 0 || k.PollInterval <= 0 {
+			errors = append(errors, "container.kubernetes.startTimeout and pollInterval must be positive")
+		}
+	}
+	if scan := c.Container.ImageScan; scan.Enabled {
+		switch scan.Scanner {
+		case "trivy":
+			if scan.Trivy.Server == "" {
+				errors = append(errors, "container.imageScan.trivy.server is required for the trivy scanner")
+			}
+		case "registry":
+			if !strings.HasPrefix(scan.Registry.URL, "https://") && !strings.HasPrefix(scan.Registry.URL, "http://") {
+				errors = append(errors, "container.imageScan.registry.url must be an http or https URL")
+			}
+		default:
+			errors = append(errors, "container.imageScan.scanner must be 'trivy' or 'registry'")
+		}
+		severities := map[string]bool{"LOW": true, "MEDIUM": true, "HIGH": true, "CRITICAL": true}
+		if !severities[strings.ToUpper(scan.Severity)] {
+			errors = append(errors, "container.imageScan.severity must be LOW, MEDIUM, HIGH or CRITICAL")
+		}
+		for tenant, severity := range scan.TenantSeverity {
+			if !severities[strings.ToUpper(severity)] && severity != "off" {
+				errors = append(errors, fmt.Sprintf("container.imageScan.tenantSeverity[%s] must be LOW, MEDIUM, HIGH, CRITICAL or off", tenant))
+			}
+		}
+		if scan.CacheTTL < 0 || scan.Timeout <= 0 {
+			errors = append(errors, "container.imageScan.cacheTTL must not be negative and timeout must be positive")
+		}
+	}
+	switch c.Container.Pull.Policy {
+	case "Always", "IfNotPresent", "Never":
+	default:
+		errors = append(errors, "container.pull.policy must be Always, IfNotPresent or Never")
+	}
+	registryHosts := make(map[string]bool)
+	for i, registry := range c.Container.Registries {
+		if registry.Host == "" {
+			errors = append(errors, fmt.Sprintf("container.registries[%d] must set a host", i))
+			continue
+		}
+		if registryHosts[registry.Host] {
+			errors = append(errors, fmt.Sprintf("container.registries[%s]: duplicate host", registry.Host))
+		}
+		registryHosts[registry.Host] = true
+		sources := 0
+		if registry.Password != "" || registry.Token != "" {
+			sources++
+		}
+		if registry.CredentialHelper != "" {
+			sources++
+		}
+		if len(registry.TokenCommand) > 0 {
+			sources++
+		}
+		switch {
+		case sources > 1:
+			errors = append(errors, fmt.Sprintf("container.registries[%s] must set only one of password or token, credentialHelper and tokenCommand", registry.Host))
+		case sources == 0 && registry.CAFile == "":
+			errors = append(errors, fmt.Sprintf("container.registries[%s] must set credentials or a caFile", registry.Host))
+		case registry.Password != "" && registry.Username == "", len(registry.TokenCommand) > 0 && registry.Username == "":
+			errors = append(errors, fmt.Sprintf("container.registries[%s] must set a username", registry.Host))
+		}
+		if registry.TokenTTL < 0 {
+			errors = append(errors, fmt.Sprintf("container.registries[%s].tokenTTL must not be negative", registry.Host))
+		}
+	}
+	for name, profile := range c.Container.Sandbox.Profiles {
+		if profile.MaxResources.CPU < 0 || profile.MaxResources.Pids < 0 {
+			errors = append(errors, fmt.Sprintf("container.sandbox.profiles[%s].maxResources must not be negative", name))
+		}
+		if profile.IsolateNetwork && len(profile.Egress) > 0 {
+			errors = append(errors, fmt.Sprintf("container.sandbox.profiles[%s] cannot set egress rules on an isolated network", name))
+		}
+	}
 
 	// Validate ports
 	if c.Monitoring.MetricsPort < 1 || c.Monitoring.MetricsPort > 65535 {
@@ -417,6 +1818,321 @@ func (c *Config) Validate() error {
 	if c.Monitoring.HealthPort < 1 || c.Monitoring.HealthPort > 65535 {
 		errors = append(errors, "monitoring.healthPort must be a valid port number")
 	}
+	if c.Jobs.WorkStealing.Enabled {
+		if c.Jobs.WorkStealing.HeartbeatInterval <= 0 {
+			errors = append(errors, "jobs.workStealing.heartbeatInterval must be positive")
+		}
+		if c.Jobs.WorkStealing.PrefetchLimit < 1 {
+			errors = append(errors, "jobs.workStealing.prefetchLimit must be at least 1")
+		}
+	}
+	if c.Jobs.Matrix.MaxCombinations < 1 {
+		errors = append(errors, "jobs.matrix.maxCombinations must be at least 1")
+	}
+	if c.Jobs.Matrix.MaxParallel < 1 {
+		errors = append(errors, "jobs.matrix.maxParallel must be at least 1")
+	}
+	if c.Jobs.Gates.DefaultInterval <= 0 || c.Jobs.Gates.DefaultTimeout <= 0 {
+		errors = append(errors, "jobs.gates.defaultInterval and jobs.gates.defaultTimeout must be positive")
+	}
+	if c.Jobs.Gates.MaxTimeout < c.Jobs.Gates.DefaultTimeout {
+		errors = append(errors, "jobs.gates.maxTimeout must not be less than jobs.gates.defaultTimeout")
+	}
+	if c.Jobs.Gates.ApprovalInterval <= 0 || c.Jobs.Gates.ApprovalExpiry <= 0 {
+		errors = append(errors, "jobs.gates.approvalInterval and jobs.gates.approvalExpiry must be positive")
+	}
+	if c.Jobs.Gates.MaxApprovalExpiry < c.Jobs.Gates.ApprovalExpiry {
+		errors = append(errors, "jobs.gates.maxApprovalExpiry must not be less than jobs.gates.approvalExpiry")
+	}
+	if c.Jobs.Analysis.Mode != "warn" && c.Jobs.Analysis.Mode != "block" {
+		errors = append(errors, "jobs.analysis.mode must be 'warn' or 'block'")
+	}
+	switch c.Jobs.Analysis.BlockSeverity {
+	case "error", "warning", "info":
+	default:
+		errors = append(errors, "jobs.analysis.blockSeverity must be 'error', 'warning' or 'info'")
+	}
+	for _, tool := range c.Jobs.Analysis.Tools {
+		if tool != "shellcheck" && tool != "bandit" && tool != "semgrep" {
+			errors = append(errors, fmt.Sprintf("jobs.analysis.tools: unknown tool %q", tool))
+		}
+	}
+	if c.Jobs.Quarantine.Enabled && c.Jobs.Quarantine.Threshold < 1 {
+		errors = append(errors, "jobs.quarantine.threshold must be at least 1")
+	}
+	if c.Jobs.Quarantine.Duration < 0 {
+		errors = append(errors, "jobs.quarantine.duration must not be negative")
+	}
+	if dl := c.Jobs.DeadLetter; dl.Enabled && (dl.MaxAttempts < 1 || dl.Retention <= 0) {
+		errors = append(errors, "jobs.deadLetter.maxAttempts must be at least 1 and retention positive")
+	}
+	if c.Jobs.Drain.Timeout <= 0 {
+		errors = append(errors, "jobs.drain.timeout must be positive")
+	}
+	if d := c.Jobs.Durations; d.Enabled {
+		if d.Alpha <= 0 || d.Alpha > 1 {
+			errors = append(errors, "jobs.durations.alpha must be greater than 0 and at most 1")
+		}
+		if d.OverrunFactor <= 1 {
+			errors = append(errors, "jobs.durations.overrunFactor must be greater than 1")
+		}
+		if d.MinSamples < 1 || d.MinOverrun < 0 {
+			errors = append(errors, "jobs.durations.minSamples must be at least 1 and minOverrun must not be negative")
+		}
+	}
+	if c.Jobs.Lineage.Retention <= 0 {
+		errors = append(errors, "jobs.lineage.retention must be positive")
+	}
+	if c.Jobs.Completion.Workers < 1 {
+		errors = append(errors, "jobs.completion.workers must be at least 1")
+	}
+	if c.Jobs.Completion.MaxRetryDelay <= 0 || c.Jobs.Completion.MaxAge <= 0 {
+		errors = append(errors, "jobs.completion.maxRetryDelay and maxAge must be positive")
+	}
+	for i, custom := range c.Security.OutputScanning.Custom {
+		if custom.Name == "" || custom.Pattern == "" {
+			errors = append(errors, fmt.Sprintf("security.outputScanning.custom[%d] must set a name and pattern", i))
+		} else if _, err := regexp.Compile(custom.Pattern); err != nil {
+			errors = append(errors, fmt.Sprintf("security.outputScanning.custom[%s] has an invalid pattern: %v", custom.Name, err))
+		}
+	}
+
+	if c.SSH.Runner.RolloutPercent < 0 || c.SSH.Runner.RolloutPercent > 100 {
+		errors = append(errors, "ssh.runner.rolloutPercent must be between 0 and 100")
+	}
+	if c.SSH.Runner.RolloutPercent > 0 && c.SSH.Runner.Candidate == "" {
+		errors = append(errors, "ssh.runner.candidate is required for a percentage rollout")
+	}
+	for _, group := range c.SSH.Runner.Groups {
+		if group.Version == "" {
+			errors = append(errors, fmt.Sprintf("ssh.runner.groups[%s] must set a version", group.Name))
+		}
+	}
+	if releases := c.SSH.Runner.Releases; releases.Enabled {
+		if u, err := url.Parse(releases.URL); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "oci") {
+			errors = append(errors, "ssh.runner.releases.url must be an http(s):// or oci:// URL")
+		}
+		if releases.Channel == "" {
+			errors = append(errors, "ssh.runner.releases.channel is required")
+		}
+		if len(releases.Architectures) == 0 {
+			errors = append(errors, "ssh.runner.releases.architectures must list at least one architecture")
+		}
+		for i, key := range releases.PublicKeys {
+			if raw, err := base64.StdEncoding.DecodeString(key); err != nil || len(raw) != ed25519.PublicKeySize {
+				errors = append(errors, fmt.Sprintf("ssh.runner.releases.publicKeys[%d] must be a base64 Ed25519 public key", i))
+			}
+		}
+		if len(releases.PublicKeys) == 0 && !releases.InsecureSkipVerify {
+			errors = append(errors, "ssh.runner.releases.publicKeys is required unless ssh.runner.releases.insecureSkipVerify is set")
+		}
+		if releases.CheckInterval <= 0 {
+			errors = append(errors, "ssh.runner.releases.checkInterval must be positive")
+		}
+		if releases.Keep < 0 {
+			errors = append(errors, "ssh.runner.releases.keep must not be negative")
+		}
+	}
+	if c.SSH.Runner.Cache.Freshness <= 0 {
+		errors = append(errors, "ssh.runner.cache.freshness must be positive")
+	}
+
+	if c.SSH.Execution.ResultUpload.Enabled {
+		if c.SSH.Execution.ResultUpload.BaseURL == "" {
+			errors = append(errors, "ssh.execution.resultUpload.baseURL is required when result upload is enabled")
+		}
+		if c.Container.Runtime.JWTSecret == "" {
+			errors = append(errors, "container.runtime.jwtSecret is required when result upload is enabled")
+		}
+	}
+
+	storage := c.SSH.Execution.PayloadStorage
+	switch storage.Backend {
+	case "local", "tmpfs":
+	case "s3":
+		if storage.S3.Bucket == "" || storage.S3.Region == "" {
+			errors = append(errors, "ssh.execution.payloadStorage.s3.bucket and region are required for the s3 backend")
+		}
+	default:
+		errors = append(errors, "ssh.execution.payloadStorage.backend must be 'local', 'tmpfs' or 's3'")
+	}
+	if storage.MaxBytes < 0 || storage.MaxPayloads < 0 {
+		errors = append(errors, "ssh.execution.payloadStorage.maxBytes and maxPayloads must not be negative")
+	}
+	if files := c.SSH.Execution.PayloadFiles; files.MaxFiles <= 0 || files.MaxBytes <= 0 || files.FetchTimeout <= 0 {
+		errors = append(errors, "ssh.execution.payloadFiles.maxFiles, maxBytes and fetchTimeout must be positive")
+	} else if files.SourceDir != "" && !filepath.IsAbs(files.SourceDir) {
+		errors = append(errors, "ssh.execution.payloadFiles.sourceDir must be an absolute path")
+	}
+	if refs := c.SSH.Execution.InputRefs; refs.MaxSize <= 0 || refs.PresignExpiry <= 0 {
+		errors = append(errors, "ssh.execution.inputRefs.maxSize and presignExpiry must be positive")
+	}
+	if c.SSH.Execution.Checkpoint.Enabled && c.SSH.Execution.Checkpoint.Timeout <= 0 {
+		errors = append(errors, "ssh.execution.checkpoint.timeout must be positive when checkpoints are enabled")
+	}
+	if recording := c.SSH.Execution.Recording; recording.Enabled {
+		if recording.Dir == "" {
+			errors = append(errors, "ssh.execution.recording.dir is required when recording is enabled")
+		}
+		if recording.Retention <= 0 {
+			errors = append(errors, "ssh.execution.recording.retention must be positive when recording is enabled")
+		}
+		if recording.MaxSize < 0 {
+			errors = append(errors, "ssh.execution.recording.maxSize must not be negative")
+		}
+	}
+
+	switch transfer := c.SSH.Execution.Transfer; transfer.Method {
+	case "sftp":
+		if transfer.ChunkSize < 1024 || transfer.ChunkSize > 32768 {
+			errors = append(errors, "ssh.execution.transfer.chunkSize must be between 1024 and 32768")
+		}
+		if transfer.Retries < 0 {
+			errors = append(errors, "ssh.execution.transfer.retries must not be negative")
+		}
+	case "cat":
+	default:
+		errors = append(errors, "ssh.execution.transfer.method must be 'sftp' or 'cat'")
+	}
+
+	if certs := c.SSH.Certificates; certs.Enabled {
+		switch certs.Source {
+		case "local":
+			if certs.CAKeyFile == "" {
+				errors = append(errors, "ssh.certificates.caKeyFile is required for the local source")
+			}
+		case "vault":
+			if certs.Vault.Address == "" || certs.Vault.Token == "" || certs.Vault.Role == "" {
+				errors = append(errors, "ssh.certificates.vault.address, token and role are required for the vault source")
+			}
+		default:
+			errors = append(errors, "ssh.certificates.source must be 'local' or 'vault'")
+		}
+		if certs.TTL < time.Minute {
+			errors = append(errors, "ssh.certificates.ttl must be at least 1m")
+		}
+	}
+
+	if f := c.SSH.FanOut; f.MaxParallel < 0 || f.BatchSize < 0 || f.BatchPause < 0 || f.FailureThreshold < 0 {
+		errors = append(errors, "ssh.fanOut settings must not be negative")
+	}
+	if s := c.SSH.StickyTarget; s.TTL <= 0 || s.ProbeTimeout <= 0 {
+		errors = append(errors, "ssh.stickyTarget.ttl and probeTimeout must be positive")
+	}
+
+	if c.Admin.Enabled {
+		if c.Admin.Port < 1 || c.Admin.Port > 65535 {
+			errors = append(errors, "admin.port must be a valid port number")
+		}
+		if c.Admin.Token == "" {
+			errors = append(errors, "admin.token is required when the admin API is enabled")
+		}
+	}
+
+	errors = append(errors, c.Triggers.validate()...)
+	if c.Jobs.Push.Enabled {
+		if c.Jobs.Push.PollInterval < c.Jobs.PollInterval {
+			errors = append(errors, "jobs.push.pollInterval must be at least jobs.pollInterval")
+		}
+		if c.Jobs.Push.ReconnectDelay <= 0 || c.Jobs.Push.MaxReconnectDelay < c.Jobs.Push.ReconnectDelay {
+			errors = append(errors, "jobs.push.reconnectDelay must be positive and at most jobs.push.maxReconnectDelay")
+		}
+		if c.Jobs.Push.AckTimeout <= 0 {
+			errors = append(errors, "jobs.push.ackTimeout must be positive")
+		}
+	}
+
+	if j := c.Logging.Jobs; j.Enabled {
+		if j.Dir == "" {
+			errors = append(errors, "logging.jobs.dir is required when job log files are enabled")
+		}
+		if j.MaxFileSize < 1024 {
+			errors = append(errors, "logging.jobs.maxFileSize must be at least 1024 bytes")
+		}
+		if j.MaxFiles < 1 {
+			errors = append(errors, "logging.jobs.maxFiles must be at least 1")
+		}
+		if j.Retention <= 0 {
+			errors = append(errors, "logging.jobs.retention must be positive")
+		}
+	}
+
+	if h := c.Monitoring.History; h.Enabled {
+		if h.Dir == "" {
+			errors = append(errors, "monitoring.history.dir is required when metrics history is enabled")
+		}
+		if h.Interval < time.Second {
+			errors = append(errors, "monitoring.history.interval must be at least 1s")
+		}
+		if h.Resolution < h.Interval || 24*time.Hour%h.Resolution != 0 {
+			errors = append(errors, "monitoring.history.resolution must be at least the interval and divide a day")
+		}
+		if h.RawRetention < 24*time.Hour || h.Retention < h.RawRetention {
+			errors = append(errors, "monitoring.history.rawRetention must be at least 24h and at most monitoring.history.retention")
+		}
+	}
+
+	if u := c.Monitoring.ResourceUsage; u.Enabled && u.SampleInterval < time.Second {
+		errors = append(errors, "monitoring.resourceUsage.sampleInterval must be at least 1s")
+	}
+
+	errors = append(errors, c.Exports.validate()...)
+	if c.Accounting.Enabled {
+		if c.Accounting.Period < time.Minute {
+			errors = append(errors, "accounting.period must be at least 1m")
+		}
+		if c.Accounting.SampleInterval < time.Second || c.Accounting.SampleInterval > c.Accounting.Period {
+			errors = append(errors, "accounting.sampleInterval must be at least 1s and at most the period")
+		}
+		if c.Accounting.Dir == "" {
+			errors = append(errors, "accounting.dir is required when accounting is enabled")
+		}
+		if len(c.Accounting.Formats) == 0 {
+			errors = append(errors, "accounting.formats must list at least one format")
+		}
+		for _, format := range c.Accounting.Formats {
+			if format != "json" && format != "csv" {
+				errors = append(errors, fmt.Sprintf("accounting.formats entry %q must be 'json' or 'csv'", format))
+			}
+		}
+		if c.Accounting.Retention < 0 {
+			errors = append(errors, "accounting.retention must not be negative")
+		}
+	}
+	errors = append(errors, c.Hooks.validate()...)
+	errors = append(errors, c.SSH.validateKeyboardInteractive()...)
+	for _, allowed := range c.SSH.Security.CommandPolicy.AllowedPaths {
+		if _, err := path.Match(allowed, ""); err != nil || !path.IsAbs(allowed) {
+			errors = append(errors, fmt.Sprintf("ssh.security.commandPolicy.allowedPaths entry %q must be an absolute path or glob", allowed))
+		}
+	}
+
+	for name, factor := range map[string]float64{"poll": c.Jitter.Poll, "health": c.Jitter.Health, "cleanup": c.Jitter.Cleanup} {
+		if factor < 0 || factor >= 1 {
+			errors = append(errors, fmt.Sprintf("jitter.%s must be at least 0 and less than 1", name))
+		}
+	}
+
+	if c.Plugins.Enabled {
+		if c.Plugins.Dir == "" {
+			errors = append(errors, "plugins.dir is required when plugins are enabled")
+		}
+		if c.Plugins.MaxFailures < 1 {
+			errors = append(errors, "plugins.maxFailures must be at least 1")
+		}
+	}
+
+	if c.HTTP.Enabled {
+		if c.HTTP.RequestTimeout <= 0 {
+			errors = append(errors, "http.requestTimeout must be positive")
+		}
+		if c.HTTP.MaxResponseSize <= 0 {
+			errors = append(errors, "http.maxResponseSize must be positive")
+		}
+		if c.HTTP.MaxRetries < 0 {
+			errors = append(errors, "http.maxRetries must not be negative")
+		}
+	}
 
 	if len(errors) > 0 {
 		return fmt.Errorf("validation errors: %s", strings.Join(errors, "; "))
@@ -425,11 +2141,216 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// validate checks that every trigger is named uniquely and fully configured
+func (t *TriggersConfig) validate() []string {
+	var errors []string
+	names := make(map[string]bool)
+	// fields alternates field names and values
+	check := func(kind, name, eventID string, fields ...string) {
+		if name == "" {
+			errors = append(errors, fmt.Sprintf("triggers.%s: every trigger must set a name", kind))
+			return
+		}
+		if names[name] {
+			errors = append(errors, fmt.Sprintf("triggers.%s[%s]: duplicate trigger name", kind, name))
+		}
+		names[name] = true
+		if eventID == "" {
+			errors = append(errors, fmt.Sprintf("triggers.%s[%s] must set an eventId", kind, name))
+		}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i+1] == "" {
+				errors = append(errors, fmt.Sprintf("triggers.%s[%s] must set %s", kind, name, fields[i]))
+			}
+		}
+	}
+
+	if t.Webhook.Enabled && (t.Webhook.Port < 1 || t.Webhook.Port > 65535) {
+		errors = append(errors, "triggers.webhook.port must be a valid port number")
+	}
+	if t.Webhook.Enabled && t.Webhook.Tolerance <= 0 {
+		errors = append(errors, "triggers.webhook.tolerance must be positive")
+	}
+	for _, w := range t.Webhook.Endpoints {
+		check("webhook.endpoints", w.Name, w.EventID, "secret", w.Secret)
+		if strings.ContainsAny(w.Name, "/?#% ") {
+			errors = append(errors, fmt.Sprintf("triggers.webhook.endpoints[%s]: name must be usable as a URL path segment", w.Name))
+		}
+	}
+	for _, sc := range t.Schedules {
+		check("schedules", sc.Name, sc.EventID, "cron", sc.Cron)
+		if sc.Cron != "" {
+			if err := schedule.Validate(sc.Cron); err != nil {
+				errors = append(errors, fmt.Sprintf("triggers.schedules[%s] has an invalid cron expression: %v", sc.Name, err))
+			}
+		}
+		if sc.TZ != "" {
+			if _, err := time.LoadLocation(sc.TZ); err != nil {
+				errors = append(errors, fmt.Sprintf("triggers.schedules[%s] has an unknown time zone %q", sc.Name, sc.TZ))
+			}
+		}
+		switch sc.CatchUp {
+		case "", "none", "once", "all":
+		default:
+			errors = append(errors, fmt.Sprintf("triggers.schedules[%s].catchUp must be none, once or all", sc.Name))
+		}
+		if sc.MaxCatchUp < 0 {
+			errors = append(errors, fmt.Sprintf("triggers.schedules[%s].maxCatchUp must not be negative", sc.Name))
+		}
+	}
+	if len(t.Schedules) > 0 && t.StateDir == "" {
+		errors = append(errors, "triggers.stateDir must be set when schedules are configured")
+	}
+	for _, f := range t.Filesystem {
+		check("filesystem", f.Name, f.EventID, "dir", f.Dir)
+		if f.Pattern != "" {
+			if _, err := filepath.Match(f.Pattern, ""); err != nil {
+				errors = append(errors, fmt.Sprintf("triggers.filesystem[%s] has an invalid pattern: %v", f.Name, err))
+			}
+		}
+	}
+	for _, n := range t.NATS {
+		check("nats", n.Name, n.EventID, "url", n.URL, "subject", n.Subject)
+	}
+	for _, s := range t.Streams {
+		check("streams", s.Name, s.EventID, "url", s.URL, "stream", s.Stream, "group", s.Group)
+	}
+	for _, a := range t.AMQP {
+		check("amqp", a.Name, a.EventID, "url", a.URL, "queue", a.Queue)
+		if a.Prefetch < 0 {
+			errors = append(errors, fmt.Sprintf("triggers.amqp[%s].prefetch must not be negative", a.Name))
+		}
+	}
+	for _, k := range t.Kafka {
+		check("kafka", k.Name, k.EventID, "brokers", strings.Join(k.Brokers, ","), "topic", k.Topic, "group", k.Group)
+	}
+	return errors
+}
+
+// validate checks the export extractors and sinks
+func (h *HooksConfig) validate() []string {
+	var errors []string
+	names := make(map[string]bool)
+	check := func(phase string, hooks []HookConfig) {
+		for i, hook := range hooks {
+			if hook.Name == "" {
+				errors = append(errors, fmt.Sprintf("hooks.%s[%d] must set a name", phase, i))
+				continue
+			}
+			if names[phase+"/"+hook.Name] {
+				errors = append(errors, fmt.Sprintf("hooks.%s[%s]: duplicate hook name", phase, hook.Name))
+			}
+			names[phase+"/"+hook.Name] = true
+			if (len(hook.Command) == 0) == (hook.URL == "") {
+				errors = append(errors, fmt.Sprintf("hooks.%s[%s] must set either command or url", phase, hook.Name))
+			}
+			if hook.Timeout < 0 {
+				errors = append(errors, fmt.Sprintf("hooks.%s[%s].timeout must not be negative", phase, hook.Name))
+			}
+			switch hook.OnFailure {
+			case "", "block", "warn":
+			default:
+				errors = append(errors, fmt.Sprintf("hooks.%s[%s].onFailure must be 'block' or 'warn'", phase, hook.Name))
+			}
+		}
+	}
+	check("pre", h.Pre)
+	check("post", h.Post)
+	return errors
+}
+
+func (s *SSHConfig) validateKeyboardInteractive() []string {
+	var errors []string
+	servers := make(map[string]bool)
+	for i, server := range s.KeyboardInteractive {
+		if server.Server == "" {
+			errors = append(errors, fmt.Sprintf("ssh.keyboardInteractive[%d] must set a server", i))
+			continue
+		}
+		if servers[server.Server] {
+			errors = append(errors, fmt.Sprintf("ssh.keyboardInteractive[%s]: duplicate server", server.Server))
+		}
+		servers[server.Server] = true
+		if len(server.Responders) == 0 {
+			errors = append(errors, fmt.Sprintf("ssh.keyboardInteractive[%s] must set responders", server.Server))
+		}
+		for j, responder := range server.Responders {
+			field := fmt.Sprintf("ssh.keyboardInteractive[%s].responders[%d]", server.Server, j)
+			if _, err := regexp.Compile(responder.Prompt); err != nil {
+				errors = append(errors, fmt.Sprintf("%s has an invalid prompt pattern: %v", field, err))
+			}
+			switch responder.Type {
+			case "static":
+				if responder.Answer == "" {
+					errors = append(errors, field+" must set an answer")
+				}
+			case "password":
+			case "totp":
+				if secret := strings.ToUpper(strings.Trim(strings.ReplaceAll(responder.TOTPSecret, " ", ""), "=")); secret == "" {
+					errors = append(errors, field+" must set a totpSecret")
+				} else if _, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret); err != nil {
+					errors = append(errors, field+".totpSecret is not valid base32")
+				}
+				if responder.TOTPDigits != 0 && (responder.TOTPDigits < 6 || responder.TOTPDigits > 8) {
+					errors = append(errors, field+".totpDigits must be between 6 and 8")
+				}
+				if responder.TOTPPeriod < 0 {
+					errors = append(errors, field+".totpPeriod must not be negative")
+				}
+			default:
+				errors = append(errors, field+".type must be 'static', 'password' or 'totp'")
+			}
+		}
+	}
+	return errors
+}
+
+func (e *ExportConfig) validate() []string {
+	var errors []string
+	for i, extractor := range e.Extractors {
+		if extractor.Name == "" || extractor.Pattern == "" {
+			errors = append(errors, fmt.Sprintf("exports.extractors[%d] must set a name and pattern", i))
+		} else if _, err := regexp.Compile(extractor.Pattern); err != nil {
+			errors = append(errors, fmt.Sprintf("exports.extractors[%s] has an invalid pattern: %v", extractor.Name, err))
+		}
+	}
+
+	names := make(map[string]bool)
+	for i, sink := range e.Sinks {
+		if sink.Name == "" {
+			errors = append(errors, fmt.Sprintf("exports.sinks[%d] must set a name", i))
+			continue
+		}
+		if names[sink.Name] {
+			errors = append(errors, fmt.Sprintf("exports.sinks[%s]: duplicate sink name", sink.Name))
+		}
+		names[sink.Name] = true
+
+		switch sink.Type {
+		case "webhook":
+			if sink.Webhook.URL == "" {
+				errors = append(errors, fmt.Sprintf("exports.sinks[%s] must set webhook.url", sink.Name))
+			}
+		case "s3":
+			if sink.S3.Bucket == "" || sink.S3.Region == "" {
+				errors = append(errors, fmt.Sprintf("exports.sinks[%s] must set s3.bucket and s3.region", sink.Name))
+			}
+		case "bigquery":
+			bq := sink.BigQuery
+			if bq.Project == "" || bq.Dataset == "" || bq.Table == "" || bq.CredentialsFile == "" {
+				errors = append(errors, fmt.Sprintf("exports.sinks[%s] must set bigquery.project, dataset, table and credentialsFile", sink.Name))
+			}
+		default:
+			errors = append(errors, fmt.Sprintf("exports.sinks[%s]: type must be 'webhook', 's3' or 'bigquery'", sink.Name))
+		}
+	}
+	return errors
+}
+
 // Print prints the configuration (with secrets hidden)
 func (c *Config) Print(w io.Writer) error {
 	// Create a copy with secrets hidden
-	safeCfg := *c
-	safeCfg.API.Token = "***hidden***"
+	safeCfg := redact.Copy(*c)
 
 	// Marshal to YAML
 	data, err := yaml.Marshal(&safeCfg)
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processEnv applies the environment as Load does, with the required API
+// settings set
+func processEnv(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	t.Setenv("CRONIUM_API_ENDPOINT", "http://localhost:5001/api/internal")
+	t.Setenv("CRONIUM_API_TOKEN", "api-token")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	var cfg Config
+	require.NoError(t, envconfig.Process("CRONIUM", &cfg))
+	return &cfg
+}
+
+func TestJobPushDisabledByDefault(t *testing.T) {
+	cfg := processEnv(t, nil)
+
+	assert.False(t, cfg.Jobs.Push.Enabled)
+}
+
+func TestTokensIgnoreBareTokenVariable(t *testing.T) {
+	cfg := processEnv(t, map[string]string{"TOKEN": "host-token"})
+
+	assert.Empty(t, cfg.Jobs.Drain.Token)
+	assert.Empty(t, cfg.Admin.Token)
+	assert.Empty(t, cfg.Logging.Jobs.Token)
+	assert.Empty(t, cfg.SSH.Certificates.Vault.Token)
+	assert.Empty(t, cfg.Container.ImageScan.Trivy.Token)
+}
+
+func TestTokensReadPrefixedVariables(t *testing.T) {
+	cfg := processEnv(t, map[string]string{
+		"CRONIUM_JOBS_DRAIN_TOKEN":                 "drain-token",
+		"CRONIUM_ADMIN_TOKEN":                      "admin-token",
+		"CRONIUM_LOGGING_JOBS_TOKEN":               "log-token",
+		"CRONIUM_SSH_CERTIFICATES_VAULT_TOKEN":     "vault-token",
+		"CRONIUM_CONTAINER_IMAGE_SCAN_TRIVY_TOKEN": "trivy-token",
+	})
+
+	assert.Equal(t, "drain-token", cfg.Jobs.Drain.Token)
+	assert.Equal(t, "admin-token", cfg.Admin.Token)
+	assert.Equal(t, "log-token", cfg.Logging.Jobs.Token)
+	assert.Equal(t, "vault-token", cfg.SSH.Certificates.Vault.Token)
+	assert.Equal(t, "trivy-token", cfg.Container.ImageScan.Trivy.Token)
+}
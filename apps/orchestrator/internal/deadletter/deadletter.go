@@ -0,0 +1,237 @@
+// Package deadletter stops re-running poison jobs. Every attempt at a job
+// is counted per job ID in a local file before the job starts, so an attempt
+// that takes the orchestrator down still counts once it restarts. A job that
+// comes back after the configured number of consecutive failed attempts is
+// dead-lettered: reported to the backend instead of acknowledged and run,
+// until an operator clears it.
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/quarantine"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// crashedAttempt is the error recorded for an attempt that never finished
+const crashedAttempt = "attempt did not finish; the orchestrator stopped while running it"
+
+// Entry is the attempt record of a job
+type Entry struct {
+	JobID    string        `json:"jobId"`
+	JobType  types.JobType `json:"jobType"`
+	EventID  string        `json:"eventId,omitempty"`
+	Attempts int           `json:"attempts"`
+	// Error of the last failed attempt
+	LastError      string    `json:"lastError,omitempty"`
+	FirstAttemptAt time.Time `json:"firstAttemptAt"`
+	LastAttemptAt  time.Time `json:"lastAttemptAt"`
+	// Set once the job was refused for the first time
+	DeadLetteredAt *time.Time `json:"deadLetteredAt,omitempty"`
+	// An attempt was started and has not finished
+	Running bool `json:"running,omitempty"`
+}
+
+// List tracks the attempts of jobs. A nil List dead-letters nothing.
+type List struct {
+	cfg config.DeadLetterConfig
+	log *logrus.Logger
+
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// New creates a list with the attempts saved in the configured file. It
+// returns nil when dead-lettering is disabled.
+func New(cfg config.DeadLetterConfig, log *logrus.Logger) *List {
+	if !cfg.Enabled {
+		return nil
+	}
+	l := &List{cfg: cfg, log: log, entries: make(map[string]*Entry)}
+	if err := l.load(); err != nil {
+		log.WithError(err).Warn("Failed to load job attempts, starting afresh")
+	}
+	return l
+}
+
+// Start records an attempt at a job about to run and returns the number of
+// consecutive attempts including it
+func (l *List) Start(job *types.Job) int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now().UTC()
+	e, ok := l.entries[job.ID]
+	if !ok {
+		e = &Entry{JobID: job.ID, JobType: job.Type, EventID: quarantine.EventID(job), FirstAttemptAt: now}
+		l.entries[job.ID] = e
+	}
+	e.Attempts++
+	e.LastAttemptAt = now
+	e.Running = true
+	l.persist()
+	return e.Attempts
+}
+
+// Finish records the outcome of a job's attempt. A successful attempt
+// forgets the job; a failed one keeps counting.
+func (l *List) Finish(jobID string, failed bool, detail string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[jobID]
+	if !ok {
+		return
+	}
+	if !failed {
+		delete(l.entries, jobID)
+	} else {
+		e.Running = false
+		e.LastError = detail
+	}
+	l.persist()
+}
+
+// Check reports whether a job has failed too often to be run again
+func (l *List) Check(jobID string) (Entry, bool) {
+	if l == nil {
+		return Entry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.entries[jobID]
+	if !ok || e.Attempts < l.cfg.MaxAttempts {
+		return Entry{}, false
+	}
+	if e.DeadLetteredAt == nil {
+		now := time.Now().UTC()
+		e.DeadLetteredAt = &now
+		l.persist()
+	}
+	return *e, true
+}
+
+// Clear forgets the attempts of a job so it runs again when it is next
+// polled, and reports whether any were recorded
+func (l *List) Clear(jobID string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.entries[jobID]
+	if ok {
+		delete(l.entries, jobID)
+		l.persist()
+	}
+	return ok
+}
+
+// Entries returns the jobs that reached the attempt limit, most recently
+// attempted first
+func (l *List) Entries() []Entry {
+	if l == nil {
+		return []Entry{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := make([]Entry, 0)
+	for _, e := range l.entries {
+		if e.Attempts >= l.cfg.MaxAttempts {
+			entries = append(entries, *e)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastAttemptAt.After(entries[j].LastAttemptAt)
+	})
+	return entries
+}
+
+// persist saves the entries when they are kept in a file, logging failures;
+// the caller holds the lock
+func (l *List) persist() {
+	if l.cfg.File == "" {
+		return
+	}
+	if err := l.save(); err != nil {
+		l.log.WithError(err).Warn("Failed to save job attempts")
+	}
+}
+
+// load reads the saved entries, of which there are none on the first start.
+// Attempts still running when the orchestrator stopped failed with it.
+func (l *List) load() error {
+	if l.cfg.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(l.cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", l.cfg.File, err)
+	}
+
+	cutoff := time.Now().Add(-l.cfg.Retention)
+	for _, e := range entries {
+		if e.LastAttemptAt.Before(cutoff) {
+			continue
+		}
+		if e.Running {
+			e.Running = false
+			e.LastError = crashedAttempt
+		}
+		l.entries[e.JobID] = e
+	}
+	return nil
+}
+
+// save replaces the attempts file, dropping jobs not attempted within the
+// retention; the caller holds the lock
+func (l *List) save() error {
+	cutoff := time.Now().Add(-l.cfg.Retention)
+	entries := make([]*Entry, 0, len(l.entries))
+	for jobID, e := range l.entries {
+		if e.LastAttemptAt.Before(cutoff) {
+			delete(l.entries, jobID)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].JobID < entries[j].JobID })
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.cfg.File), 0o750); err != nil {
+		return err
+	}
+	tmp := l.cfg.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.cfg.File)
+}
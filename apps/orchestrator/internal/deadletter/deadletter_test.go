@@ -0,0 +1,65 @@
+package deadletter
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestList(t *testing.T, file string) *List {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return New(config.DeadLetterConfig{Enabled: true, MaxAttempts: 3, File: file, Retention: time.Hour}, log)
+}
+
+func TestDeadLetter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dead-letters.json")
+	l := newTestList(t, file)
+	job := &types.Job{ID: "job_1", Type: types.JobTypeContainer}
+
+	for i := 1; i <= 2; i++ {
+		assert.Equal(t, i, l.Start(job))
+		l.Finish(job.ID, true, "exit code 1")
+		_, dead := l.Check(job.ID)
+		assert.False(t, dead)
+	}
+
+	// A success resets the count
+	l.Start(job)
+	l.Finish(job.ID, false, "")
+	assert.Equal(t, 1, l.Start(job))
+	l.Finish(job.ID, true, "exit code 1")
+	l.Start(job)
+	l.Finish(job.ID, true, "exit code 1")
+
+	// The third attempt takes the orchestrator down
+	l.Start(job)
+	reloaded := newTestList(t, file)
+	entry, dead := reloaded.Check(job.ID)
+	require.True(t, dead)
+	assert.Equal(t, 3, entry.Attempts)
+	assert.Equal(t, crashedAttempt, entry.LastError)
+	assert.NotNil(t, entry.DeadLetteredAt)
+	require.Len(t, reloaded.Entries(), 1)
+
+	assert.True(t, reloaded.Clear(job.ID))
+	assert.False(t, reloaded.Clear(job.ID))
+	_, dead = newTestList(t, file).Check(job.ID)
+	assert.False(t, dead)
+}
+
+func TestDisabled(t *testing.T) {
+	l := New(config.DeadLetterConfig{}, logrus.New())
+	assert.Nil(t, l)
+	assert.Equal(t, 0, l.Start(&types.Job{ID: "job_1"}))
+	_, dead := l.Check("job_1")
+	assert.False(t, dead)
+	assert.Empty(t, l.Entries())
+}
@@ -0,0 +1,167 @@
+// Package durations predicts how long runs of an event take and spots runs
+// that take far longer. The duration of each completed run is folded into an
+// exponentially smoothed average per event, kept in a local file so the
+// prediction survives restarts. A run still going after a multiple of its
+// expected duration is likely stuck, and is reported long before its timeout.
+package durations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Estimate is the expected duration of an event's runs
+type Estimate struct {
+	EventID  string        `json:"eventId"`
+	Expected time.Duration `json:"expected"`
+	Last     time.Duration `json:"last"`
+	// Completed runs folded into Expected
+	Samples   int       `json:"samples"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Overrun describes a run that has gone on longer than expected
+type Overrun struct {
+	Estimate
+	// Time after which the run was reported
+	Limit time.Duration `json:"limit"`
+}
+
+// Predictor keeps the estimates of all events. A nil Predictor predicts
+// nothing.
+type Predictor struct {
+	cfg config.DurationsConfig
+	log *logrus.Logger
+
+	mu        sync.Mutex
+	estimates map[string]*Estimate
+}
+
+// New creates a predictor with the estimates saved in the configured file.
+// It returns nil when prediction is disabled.
+func New(cfg config.DurationsConfig, log *logrus.Logger) *Predictor {
+	if !cfg.Enabled {
+		return nil
+	}
+	p := &Predictor{cfg: cfg, log: log, estimates: make(map[string]*Estimate)}
+	if err := p.load(); err != nil {
+		log.WithError(err).Warn("Failed to load run duration estimates, starting afresh")
+	}
+	return p
+}
+
+// Expect returns the estimate for an event once it has enough completed
+// runs to be trusted
+func (p *Predictor) Expect(eventID string) (Estimate, bool) {
+	if p == nil || eventID == "" {
+		return Estimate{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.estimates[eventID]
+	if !ok || e.Samples < p.cfg.MinSamples {
+		return Estimate{}, false
+	}
+	return *e, true
+}
+
+// Limit returns how long a run with the estimate may go before it is
+// reported as overrunning
+func (p *Predictor) Limit(e Estimate) time.Duration {
+	limit := time.Duration(float64(e.Expected) * p.cfg.OverrunFactor)
+	return max(limit, p.cfg.MinOverrun)
+}
+
+// Record folds the duration of a completed run into its event's estimate
+func (p *Predictor) Record(eventID string, d time.Duration) {
+	if p == nil || eventID == "" || d <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.estimates[eventID]
+	if !ok {
+		e = &Estimate{EventID: eventID, Expected: d}
+		p.estimates[eventID] = e
+	} else {
+		e.Expected = smooth(e.Expected, d, p.cfg.Alpha)
+	}
+	e.Last = d
+	e.Samples++
+	e.UpdatedAt = time.Now().UTC()
+
+	if err := p.save(); err != nil {
+		p.log.WithError(err).Warn("Failed to save run duration estimates")
+	}
+}
+
+// Watch calls onOverrun if the run of an event is still going once its
+// limit has passed. The returned function stops watching and must be called
+// when the run finishes.
+func (p *Predictor) Watch(eventID string, onOverrun func(Overrun)) (stop func()) {
+	e, ok := p.Expect(eventID)
+	if !ok {
+		return func() {}
+	}
+	overrun := Overrun{Estimate: e, Limit: p.Limit(e)}
+	timer := time.AfterFunc(overrun.Limit, func() { onOverrun(overrun) })
+	return func() { timer.Stop() }
+}
+
+// smooth weights the latest duration by alpha against the previous average
+func smooth(previous, latest time.Duration, alpha float64) time.Duration {
+	return time.Duration(alpha*float64(latest) + (1-alpha)*float64(previous))
+}
+
+// load reads the saved estimates, of which there are none before the first
+// run
+func (p *Predictor) load() error {
+	data, err := os.ReadFile(p.cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var estimates []*Estimate
+	if err := json.Unmarshal(data, &estimates); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", p.cfg.File, err)
+	}
+	for _, e := range estimates {
+		p.estimates[e.EventID] = e
+	}
+	return nil
+}
+
+// save replaces the estimates file; the caller holds the lock
+func (p *Predictor) save() error {
+	estimates := make([]*Estimate, 0, len(p.estimates))
+	for _, e := range p.estimates {
+		estimates = append(estimates, e)
+	}
+	sort.Slice(estimates, func(i, j int) bool { return estimates[i].EventID < estimates[j].EventID })
+
+	data, err := json.MarshalIndent(estimates, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.cfg.File), 0o750); err != nil {
+		return err
+	}
+	tmp := p.cfg.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.cfg.File)
+}
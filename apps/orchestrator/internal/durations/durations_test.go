@@ -0,0 +1,78 @@
+package durations
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(t *testing.T) config.DurationsConfig {
+	return config.DurationsConfig{
+		Enabled:       true,
+		File:          filepath.Join(t.TempDir(), "durations.json"),
+		Alpha:         0.5,
+		OverrunFactor: 2,
+		MinSamples:    3,
+		MinOverrun:    time.Minute,
+	}
+}
+
+func TestPredict(t *testing.T) {
+	cfg := testConfig(t)
+	p := New(cfg, logrus.New())
+
+	p.Record("7", 10*time.Minute)
+	p.Record("7", 20*time.Minute)
+	_, ok := p.Expect("7")
+	assert.False(t, ok, "too few runs")
+
+	p.Record("7", 5*time.Minute)
+	e, ok := p.Expect("7")
+	require.True(t, ok)
+	// 10m, then (20+10)/2 = 15m, then (5+15)/2 = 10m
+	assert.Equal(t, 10*time.Minute, e.Expected)
+	assert.Equal(t, 5*time.Minute, e.Last)
+	assert.Equal(t, 3, e.Samples)
+	assert.Equal(t, 20*time.Minute, p.Limit(e))
+
+	// Short runs are not reported before the minimum
+	assert.Equal(t, time.Minute, p.Limit(Estimate{Expected: 5 * time.Second}))
+
+	// Estimates survive a restart
+	e2, ok := New(cfg, logrus.New()).Expect("7")
+	require.True(t, ok)
+	assert.Equal(t, e.Expected, e2.Expected)
+}
+
+func TestWatch(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MinSamples = 1
+	cfg.MinOverrun = 0
+	p := New(cfg, logrus.New())
+	p.Record("7", 10*time.Millisecond)
+
+	fired := make(chan Overrun, 1)
+	stop := p.Watch("7", func(o Overrun) { fired <- o })
+	defer stop()
+	select {
+	case o := <-fired:
+		assert.Equal(t, 20*time.Millisecond, o.Limit)
+	case <-time.After(time.Second):
+		t.Fatal("overrun not reported")
+	}
+
+	// A run that finishes in time is not reported
+	stop = p.Watch("7", func(o Overrun) { fired <- o })
+	stop()
+	time.Sleep(40 * time.Millisecond)
+	assert.Empty(t, fired)
+
+	// Events without an estimate are not watched
+	var nilPredictor *Predictor
+	nilPredictor.Watch("7", func(Overrun) { t.Fatal("unexpected overrun") })()
+}
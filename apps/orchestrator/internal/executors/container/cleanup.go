@@ -3,21 +3,58 @@ package container
 import (
 	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/jitter"
 	containertypes "github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	networktypes "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 )
 
+// Kinds of job resource, in the order they are removed
+const (
+	resourceContainer = "container"
+	resourceSidecar   = "sidecar"
+	resourceNetwork   = "network"
+	resourceVolume    = "volume"
+)
+
 // CleanupManager handles cleanup of Docker resources
 type CleanupManager struct {
 	executor     *Executor
 	log          *logrus.Logger
 	cleanupMutex sync.Mutex
+
+	jitter       *jitter.Jitter
+	jitterFactor float64
+
+	// Job cleanups in progress, closed when they finish
+	jobsMu sync.Mutex
+	jobs   map[string]chan struct{}
+}
+
+// JobResources are the Docker resources created for one job. Empty IDs are
+// skipped.
+type JobResources struct {
+	JobID       string
+	ContainerID string
+	SidecarID   string
+	NetworkID   string
+	Volumes     []string
+}
+
+// cleanupStep removes one resource of a job
+type cleanupStep struct {
+	kind   string
+	id     string
+	remove func(ctx context.Context) error
+	exists func(ctx context.Context) (bool, error)
 }
 
 // NewCleanupManager creates a new cleanup manager
@@ -25,9 +62,17 @@ func NewCleanupManager(executor *Executor, log *logrus.Logger) *CleanupManager {
 	return &CleanupManager{
 		executor: executor,
 		log:      log,
+		jobs:     make(map[string]chan struct{}),
 	}
 }
 
+// WithJitter spreads periodic cleanups by up to factor of the interval
+func (cm *CleanupManager) WithJitter(j *jitter.Jitter, factor float64) *CleanupManager {
+	cm.jitter = j
+	cm.jitterFactor = factor
+	return cm
+}
+
 // CleanupOrphanedResources performs a full cleanup of orphaned resources
 func (cm *CleanupManager) CleanupOrphanedResources(ctx context.Context) error {
 	cm.cleanupMutex.Lock()
@@ -94,17 +139,16 @@ func (cm *CleanupManager) cleanupOrphanedContainers(ctx context.Context) error {
 
 				// Stop container if running
 				if container.State == "running" {
-					timeout := 10
-					if err := cm.executor.dockerClient.ContainerStop(ctx, container.ID, containertypes.StopOptions{
-						Timeout: &timeout,
-					}); err != nil {
+					// Docker applies the stop signal and timeout stored on the container
+					if err := cm.executor.dockerClient.ContainerStop(ctx, container.ID, containertypes.StopOptions{}); err != nil {
 						cm.log.WithError(err).Warn("Failed to stop orphaned container")
 					}
 				}
 
-				// Remove container
+				// Remove container, and a sidecar's helper socket volume
 				if err := cm.executor.dockerClient.ContainerRemove(ctx, container.ID, containertypes.RemoveOptions{
-					Force: true,
+					Force:         true,
+					RemoveVolumes: true,
 				}); err != nil {
 					cm.log.WithError(err).Error("Failed to remove orphaned container")
 				}
@@ -198,6 +242,246 @@ func (cm *CleanupManager) CleanupJobResources(ctx context.Context, jobID string)
 	return nil
 }
 
+// CleanupJob removes a job's resources in dependency order: the job
+// container, the sidecar, the network once nothing is attached to it, and
+// the volumes the containers used. Each resource is retried until it is
+// verified gone, and resources that are already gone count as removed, so
+// cleaning up a job again is harmless. A final check reports every resource
+// still left behind.
+func (cm *CleanupManager) CleanupJob(ctx context.Context, res JobResources) error {
+	// A second cleanup of the same job waits for the first, then finds
+	// little or nothing left to do
+	if err := cm.acquireJob(ctx, res.JobID); err != nil {
+		return err
+	}
+	defer cm.releaseJob(res.JobID)
+
+	// Volumes are only known while the containers using them exist
+	for _, id := range []string{res.ContainerID, res.SidecarID} {
+		for _, name := range cm.containerVolumes(ctx, id) {
+			if !slices.Contains(res.Volumes, name) {
+				res.Volumes = append(res.Volumes, name)
+			}
+		}
+	}
+
+	log := cm.log.WithField("jobID", res.JobID)
+	steps := cm.cleanupSteps(res)
+	for _, step := range steps {
+		if err := cm.runCleanupStep(ctx, step); err != nil {
+			log.WithError(err).WithFields(logrus.Fields{
+				"resource": step.kind,
+				"id":       shortID(step.id),
+			}).Warn("Failed to remove job resource")
+		}
+	}
+
+	// Consistency check over everything, including steps that looked done
+	var leftovers []string
+	for _, step := range steps {
+		exists, err := step.exists(ctx)
+		switch {
+		case err != nil:
+			leftovers = append(leftovers, fmt.Sprintf("%s %s (unverified: %v)", step.kind, shortID(step.id), err))
+		case exists:
+			leftovers = append(leftovers, fmt.Sprintf("%s %s", step.kind, shortID(step.id)))
+		}
+	}
+	if len(leftovers) > 0 {
+		log.WithField("leftovers", leftovers).Error("Job cleanup left resources behind")
+		return fmt.Errorf("job cleanup left %d resource(s) behind: %s", len(leftovers), strings.Join(leftovers, ", "))
+	}
+
+	log.WithField("resources", len(steps)).Debug("Job resources removed")
+	return nil
+}
+
+// cleanupSteps lists the removal of a job's resources in dependency order
+func (cm *CleanupManager) cleanupSteps(res JobResources) []cleanupStep {
+	docker := cm.executor.dockerClient
+	var steps []cleanupStep
+
+	if res.ContainerID != "" {
+		id := res.ContainerID
+		steps = append(steps, cleanupStep{
+			kind: resourceContainer,
+			id:   id,
+			remove: func(ctx context.Context) error {
+				return docker.ContainerRemove(ctx, id, containertypes.RemoveOptions{
+					Force:         true,
+					RemoveVolumes: true,
+				})
+			},
+			exists: cm.containerExists(id),
+		})
+	}
+	if res.SidecarID != "" {
+		id := res.SidecarID
+		steps = append(steps, cleanupStep{
+			kind: resourceSidecar,
+			id:   id,
+			remove: func(ctx context.Context) error {
+				return cm.executor.sidecar.StopSidecar(ctx, id)
+			},
+			exists: cm.containerExists(id),
+		})
+	}
+	if res.NetworkID != "" {
+		id := res.NetworkID
+		steps = append(steps, cleanupStep{
+			kind: resourceNetwork,
+			id:   id,
+			remove: func(ctx context.Context) error {
+				cm.detachNetwork(ctx, id)
+				return cm.executor.sidecar.RemoveJobNetwork(ctx, id)
+			},
+			exists: func(ctx context.Context) (bool, error) {
+				_, err := docker.NetworkInspect(ctx, id, networktypes.InspectOptions{})
+				return resourceExists(err)
+			},
+		})
+	}
+	for _, name := range res.Volumes {
+		steps = append(steps, cleanupStep{
+			kind: resourceVolume,
+			id:   name,
+			remove: func(ctx context.Context) error {
+				return docker.VolumeRemove(ctx, name, false)
+			},
+			exists: func(ctx context.Context) (bool, error) {
+				_, err := docker.VolumeInspect(ctx, name)
+				return resourceExists(err)
+			},
+		})
+	}
+
+	return steps
+}
+
+// runCleanupStep removes a resource, retrying until it is verified gone or
+// the attempts run out
+func (cm *CleanupManager) runCleanupStep(ctx context.Context, step cleanupStep) error {
+	cfg := cm.executor.config.Cleanup
+	attempts := max(cfg.Attempts, 1)
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-time.After(cfg.RetryDelay):
+			case <-ctx.Done():
+				return fmt.Errorf("%w (last error: %v)", ctx.Err(), lastErr)
+			}
+		}
+
+		// The removal error only matters if the resource is still there;
+		// a resource that is already gone fails to remove
+		removeErr := step.remove(ctx)
+		exists, err := step.exists(ctx)
+		switch {
+		case err != nil:
+			lastErr = fmt.Errorf("failed to verify removal: %w", err)
+		case !exists:
+			return nil
+		case removeErr != nil:
+			lastErr = removeErr
+		default:
+			lastErr = fmt.Errorf("%s still exists after removal", step.kind)
+		}
+
+		cm.log.WithError(lastErr).WithFields(logrus.Fields{
+			"resource": step.kind,
+			"id":       shortID(step.id),
+			"attempt":  attempt,
+		}).Debug("Job resource not removed yet")
+	}
+	return lastErr
+}
+
+// detachNetwork force-disconnects whatever is still attached to a job
+// network, so leftover endpoints do not block its removal
+func (cm *CleanupManager) detachNetwork(ctx context.Context, networkID string) {
+	info, err := cm.executor.dockerClient.NetworkInspect(ctx, networkID, networktypes.InspectOptions{})
+	if err != nil {
+		return
+	}
+	for containerID := range info.Containers {
+		if err := cm.executor.dockerClient.NetworkDisconnect(ctx, networkID, containerID, true); err != nil {
+			cm.log.WithError(err).WithFields(logrus.Fields{
+				"networkID":   shortID(networkID),
+				"containerID": shortID(containerID),
+			}).Debug("Failed to disconnect container from job network")
+		}
+	}
+}
+
+// containerVolumes returns the named and anonymous volumes a container uses
+func (cm *CleanupManager) containerVolumes(ctx context.Context, containerID string) []string {
+	if containerID == "" {
+		return nil
+	}
+	info, err := cm.executor.dockerClient.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil
+	}
+	var volumes []string
+	for _, m := range info.Mounts {
+		if m.Type == mount.TypeVolume && m.Name != "" {
+			volumes = append(volumes, m.Name)
+		}
+	}
+	return volumes
+}
+
+// containerExists checks whether a container is still there
+func (cm *CleanupManager) containerExists(containerID string) func(ctx context.Context) (bool, error) {
+	return func(ctx context.Context) (bool, error) {
+		_, err := cm.executor.dockerClient.ContainerInspect(ctx, containerID)
+		return resourceExists(err)
+	}
+}
+
+// resourceExists interprets the error of inspecting a resource
+func resourceExists(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case client.IsErrNotFound(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// acquireJob waits for any cleanup of the same job to finish and marks one
+// as in progress
+func (cm *CleanupManager) acquireJob(ctx context.Context, jobID string) error {
+	for {
+		cm.jobsMu.Lock()
+		running, ok := cm.jobs[jobID]
+		if !ok {
+			cm.jobs[jobID] = make(chan struct{})
+			cm.jobsMu.Unlock()
+			return nil
+		}
+		cm.jobsMu.Unlock()
+
+		select {
+		case <-running:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// releaseJob marks a job's cleanup as finished
+func (cm *CleanupManager) releaseJob(jobID string) {
+	cm.jobsMu.Lock()
+	close(cm.jobs[jobID])
+	delete(cm.jobs, jobID)
+	cm.jobsMu.Unlock()
+}
+
 // cleanupContainersByPattern removes containers matching a pattern
 func (cm *CleanupManager) cleanupContainersByPattern(ctx context.Context, pattern string) error {
 	filters := filters.NewArgs()
@@ -219,17 +503,15 @@ func (cm *CleanupManager) cleanupContainersByPattern(ctx context.Context, patter
 
 		// Stop if running
 		if container.State == "running" {
-			timeout := 10
-			if err := cm.executor.dockerClient.ContainerStop(ctx, container.ID, containertypes.StopOptions{
-				Timeout: &timeout,
-			}); err != nil && !strings.Contains(err.Error(), "not running") {
+			if err := cm.executor.dockerClient.ContainerStop(ctx, container.ID, containertypes.StopOptions{}); err != nil && !strings.Contains(err.Error(), "not running") {
 				cm.log.WithError(err).Warn("Failed to stop container")
 			}
 		}
 
 		// Remove container
 		if err := cm.executor.dockerClient.ContainerRemove(ctx, container.ID, containertypes.RemoveOptions{
-			Force: true,
+			Force:         true,
+			RemoveVolumes: true,
 		}); err != nil && !strings.Contains(err.Error(), "No such container") {
 			cm.log.WithError(err).Error("Failed to remove container")
 		}
@@ -269,7 +551,7 @@ func (cm *CleanupManager) cleanupNetworksByPattern(ctx context.Context, pattern
 // StartPeriodicCleanup starts a goroutine that periodically cleans up orphaned resources
 func (cm *CleanupManager) StartPeriodicCleanup(ctx context.Context, interval time.Duration) {
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := cm.jitter.NewTicker("container-cleanup", interval, cm.jitterFactor)
 		defer ticker.Stop()
 
 		for {
@@ -0,0 +1,237 @@
+package container
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/retry"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/sirupsen/logrus"
+)
+
+// maxDaemonResumes bounds how often one job re-attaches to its container
+// after losing the daemon connection
+const maxDaemonResumes = 3
+
+// isDaemonDisconnect reports whether err means the connection to the Docker
+// daemon was lost, as happens when dockerd restarts
+func isDaemonDisconnect(err error) bool {
+	if err == nil {
+		return false
+	}
+	if client.IsErrConnectionFailed(err) {
+		return true
+	}
+	if stderrors.Is(err, io.EOF) || stderrors.Is(err, io.ErrUnexpectedEOF) ||
+		stderrors.Is(err, syscall.ECONNRESET) || stderrors.Is(err, syscall.ECONNREFUSED) ||
+		stderrors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	return stderrors.As(err, &opErr)
+}
+
+// newDaemonError builds the retryable infrastructure error reported for a job
+// that lost its container to a daemon restart
+func newDaemonError(job *types.Job, containerID, message string) *types.ExecutionError {
+	execErr := types.NewExecutionError("infrastructure", "DOCKER_DAEMON_RESTARTED", message, true)
+	execErr.JobID = job.ID
+	if containerID != "" {
+		execErr.Details["containerId"] = shortID(containerID)
+	}
+	return execErr
+}
+
+// daemonAware converts a lost daemon connection into a retryable
+// infrastructure error and starts reconnecting in the background. Other
+// errors are returned unchanged.
+func (e *Executor) daemonAware(job *types.Job, err error) error {
+	if !isDaemonDisconnect(err) {
+		return err
+	}
+	go func() {
+		if recoverErr := e.recoverDaemon(context.Background()); recoverErr != nil {
+			e.log.WithError(recoverErr).Error("Failed to reconnect to Docker daemon")
+		}
+	}()
+	return newDaemonError(job, "", fmt.Sprintf("lost connection to the Docker daemon: %v", err))
+}
+
+// recoverDaemon waits for the Docker daemon to answer again after a
+// disconnect, then resynchronises the tracked containers and runs an orphan
+// cleanup pass. Jobs that lose the daemon at the same time share one recovery.
+func (e *Executor) recoverDaemon(ctx context.Context) error {
+	_, err, _ := e.daemonRecovery.Do("recover", func() (interface{}, error) {
+		e.log.Warn("Lost connection to Docker daemon, reconnecting")
+
+		// Pooled connections point at the old daemon
+		e.dockerClient.Close()
+
+		retryCfg := retry.Config{
+			MaxAttempts:  e.config.Docker.ReconnectAttempts,
+			InitialDelay: time.Second,
+			MaxDelay:     10 * time.Second,
+			Multiplier:   2.0,
+		}
+		err := retry.WithRetry(ctx, retryCfg, func() error {
+			pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			if _, err := e.dockerClient.Ping(pingCtx); err != nil {
+				dockerErr := errors.NewDockerError("DAEMON_UNAVAILABLE", err.Error(), "Ping")
+				dockerErr.Retryable = true
+				return dockerErr
+			}
+			return nil
+		}, logrus.NewEntry(e.log).WithField("component", "docker-reconnect"))
+		if err != nil {
+			return nil, fmt.Errorf("Docker daemon did not come back: %w", err)
+		}
+
+		e.log.Info("Reconnected to Docker daemon")
+		e.resyncTracked(ctx)
+
+		// Containers from jobs that died with the old daemon are orphans now
+		go func() {
+			cleanupCtx, cancel := context.WithTimeout(context.Background(), e.timeoutConfig.CleanupTimeout)
+			defer cancel()
+			if err := e.cleanup.CleanupOrphanedResources(cleanupCtx); err != nil {
+				e.log.WithError(err).Warn("Orphan cleanup after daemon restart failed")
+			}
+		}()
+		return nil, nil
+	})
+	return err
+}
+
+// resyncTracked re-inspects every tracked container and network and stops
+// tracking those that no longer exist after a daemon restart
+func (e *Executor) resyncTracked(ctx context.Context) {
+	e.mu.RLock()
+	containers := make(map[string]string, len(e.containers))
+	for jobID, id := range e.containers {
+		containers[jobID] = id
+	}
+	sidecars := make(map[string]string, len(e.sidecars))
+	for jobID, id := range e.sidecars {
+		sidecars[jobID] = id
+	}
+	networks := make(map[string]string, len(e.networks))
+	for jobID, id := range e.networks {
+		networks[jobID] = id
+	}
+	e.mu.RUnlock()
+
+	gone := func(err error) bool {
+		return err != nil && client.IsErrNotFound(err)
+	}
+
+	var dropped int
+	for jobID, id := range containers {
+		if _, err := e.dockerClient.ContainerInspect(ctx, id); gone(err) {
+			e.mu.Lock()
+			delete(e.containers, jobID)
+			e.mu.Unlock()
+			dropped++
+		}
+	}
+	for jobID, id := range sidecars {
+		if _, err := e.dockerClient.ContainerInspect(ctx, id); gone(err) {
+			e.mu.Lock()
+			delete(e.sidecars, jobID)
+			e.mu.Unlock()
+			dropped++
+		}
+	}
+	for jobID, id := range networks {
+		if _, err := e.dockerClient.NetworkInspect(ctx, id, network.InspectOptions{}); gone(err) {
+			e.mu.Lock()
+			delete(e.networks, jobID)
+			e.mu.Unlock()
+			dropped++
+		}
+	}
+
+	e.log.WithFields(logrus.Fields{
+		"containers": len(containers),
+		"sidecars":   len(sidecars),
+		"networks":   len(networks),
+		"dropped":    dropped,
+	}).Info("Resynchronised tracked Docker resources")
+}
+
+// resumeAfterDaemonRestart reconnects after the wait on a job's container was
+// cut off and picks the container back up if it survived the restart, as it
+// does with live-restore. It returns the container's exit code, or a
+// retryable infrastructure error when the container did not survive or the
+// daemon did not come back.
+func (e *Executor) resumeAfterDaemonRestart(ctx context.Context, containerID string, job *types.Job, updates chan types.ExecutionUpdate, lostAt time.Time) (int, error) {
+	for attempt := 1; attempt <= maxDaemonResumes; attempt++ {
+		if err := e.recoverDaemon(ctx); err != nil {
+			return 0, newDaemonError(job, containerID, err.Error())
+		}
+
+		inspect, err := e.dockerClient.ContainerInspect(ctx, containerID)
+		if err != nil {
+			if client.IsErrNotFound(err) {
+				return 0, newDaemonError(job, containerID, "container was lost in a Docker daemon restart")
+			}
+			return 0, newDaemonError(job, containerID, fmt.Sprintf("failed to inspect container after Docker daemon restart: %v", err))
+		}
+
+		if !inspect.State.Running {
+			// Without live-restore the daemon kills containers on shutdown, so
+			// only a clean exit can be trusted as the script's own result
+			if inspect.State.ExitCode == 0 && !inspect.State.OOMKilled {
+				return 0, nil
+			}
+			return 0, newDaemonError(job, containerID, fmt.Sprintf("container stopped during a Docker daemon restart (exit code %d)", inspect.State.ExitCode))
+		}
+
+		e.log.WithFields(logrus.Fields{
+			"jobID":       job.ID,
+			"containerID": shortID(containerID),
+		}).Info("Container survived Docker daemon restart, resuming")
+
+		var logWg sync.WaitGroup
+		logWg.Add(1)
+		go func(since time.Time) {
+			defer logWg.Done()
+			e.streamLogs(ctx, containerID, updates, since)
+		}(lostAt)
+
+		statusCh, errCh := e.dockerClient.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+		select {
+		case <-ctx.Done():
+			logWg.Wait()
+			return 0, ctx.Err()
+		case status := <-statusCh:
+			logWg.Wait()
+			return int(status.StatusCode), nil
+		case err := <-errCh:
+			logWg.Wait()
+			if !isDaemonDisconnect(err) {
+				return 0, fmt.Errorf("container wait error: %w", err)
+			}
+			lostAt = time.Now()
+		}
+	}
+	return 0, newDaemonError(job, containerID, "lost the Docker daemon connection too many times")
+}
+
+// shortID shortens a Docker ID for logs and error details
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
@@ -0,0 +1,111 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// DryRunPlan describes the container a job would run in: its image, the
+// resolved sandbox profile and the limits and security settings that
+// profile produces
+type DryRunPlan struct {
+	Image           string                `json:"image"`
+	PullPolicy      types.ImagePullPolicy `json:"pullPolicy"`
+	SandboxProfile  *sandbox.Profile      `json:"sandboxProfile"`
+	CPU             float64               `json:"cpu,omitempty"`
+	MemoryBytes     int64                 `json:"memoryBytes,omitempty"`
+	Pids            int64                 `json:"pids,omitempty"`
+	SecurityOptions []string              `json:"securityOptions,omitempty"`
+	ReadOnlyRootfs  bool                  `json:"readOnlyRootfs"`
+	IsolateNetwork  bool                  `json:"isolateNetwork"`
+	Egress          []string              `json:"egress,omitempty"`
+	StopSignal      string                `json:"stopSignal"`
+	StopGrace       string                `json:"stopGrace"`
+}
+
+// dryRunPlan builds the plan for a job without touching the Docker daemon
+func (e *Executor) dryRunPlan(job *types.Job, profile *sandbox.Profile) *DryRunPlan {
+	image := e.getImageForScript(job.Execution.Script.Type)
+	resources := e.buildResourceLimits(job, profile)
+	stopSignal, stopGrace := e.stopSettings(job)
+
+	plan := &DryRunPlan{
+		Image:           image,
+		PullPolicy:      PullPolicy(e.config.Pull, job),
+		SandboxProfile:  profile,
+		CPU:             float64(resources.NanoCPUs) / 1e9,
+		MemoryBytes:     resources.Memory,
+		SecurityOptions: e.buildSecurityOptions(profile),
+		ReadOnlyRootfs:  ReadOnlyRootfs(e.config, profile, image),
+		IsolateNetwork:  profile.IsolateNetwork,
+		Egress:          profile.Egress,
+		StopSignal:      stopSignal,
+		StopGrace:       stopGrace.String(),
+	}
+	if resources.PidsLimit != nil {
+		plan.Pids = *resources.PidsLimit
+	}
+	return plan
+}
+
+// executeDryRun reports the job's plan as its output and completes it. No
+// image is pulled and no network, sidecar or container is created.
+func (e *Executor) executeDryRun(job *types.Job, profile *sandbox.Profile, updates chan<- types.ExecutionUpdate, executionID string, timing *ExecutionTiming) {
+	plan := e.dryRunPlan(job, profile)
+
+	network := "external route"
+	if plan.IsolateNetwork {
+		network = "isolated"
+	} else if len(plan.Egress) > 0 {
+		network = "egress " + strings.Join(plan.Egress, ", ")
+	}
+	for _, line := range []string{
+		fmt.Sprintf("Image: %s (pull %s)", plan.Image, plan.PullPolicy),
+		fmt.Sprintf("Sandbox profile: %s", profile.Name),
+		fmt.Sprintf("Resources: cpu=%g memory=%d pids=%d", plan.CPU, plan.MemoryBytes, plan.Pids),
+		fmt.Sprintf("Security: options=%v capDrop=%v capAdd=%v readOnlyRootfs=%t", plan.SecurityOptions, profile.DropCapabilities, profile.AddCapabilities, plan.ReadOnlyRootfs),
+		fmt.Sprintf("Network: %s", network),
+		fmt.Sprintf("Stop: %s after %s", plan.StopSignal, plan.StopGrace),
+	} {
+		e.sendUpdate(updates, types.UpdateTypeLog, &types.LogEntry{
+			Stream:    "system",
+			Line:      line,
+			Timestamp: time.Now(),
+		})
+	}
+
+	timing.MarkSetupComplete()
+	timing.MarkExecutionComplete()
+	timing.MarkCleanupComplete()
+	exitCode := 0
+
+	if e.apiClient != nil {
+		updateData := timing.ToExecutionStatusUpdate()
+		updateData.ExitCode = &exitCode
+		updateData.ExecutionMetadata["dryRun"] = true
+		updateData.ExecutionMetadata["plan"] = plan
+		if encoded, err := json.Marshal(plan); err == nil {
+			output := string(encoded)
+			updateData.Output = &output
+		}
+
+		apiCtx, apiCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer apiCancel()
+		if err := e.apiClient.UpdateExecution(apiCtx, executionID, types.JobStatusCompleted, updateData); err != nil {
+			e.log.WithError(err).Warn("Failed to update execution with dry run plan")
+		}
+	}
+
+	e.sendUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   types.JobStatusCompleted,
+		ExitCode: &exitCode,
+		Message:  "Dry run planned",
+		Output:   &types.OutputData{Data: plan},
+	})
+}
@@ -1,7 +1,6 @@
 package container
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -12,15 +11,18 @@ import (
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/imagescan"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/docker/docker/api/types/container"
-	dockerimage "github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
 // Executor implements container-based job execution
@@ -32,6 +34,10 @@ type Executor struct {
 	apiClient      *api.Client
 	sidecar        *SidecarManager
 	cleanup        *CleanupManager
+	prewarmer      *runtimecache.Prewarmer
+	sandbox        *sandbox.Catalog
+	imageScan      *imagescan.Gate
+	images         *ImageManager
 
 	// Track active containers and resources
 	mu         sync.RWMutex
@@ -39,13 +45,18 @@ type Executor struct {
 	sidecars   map[string]string // jobID -> sidecarContainerID
 	networks   map[string]string // jobID -> networkID
 	tokens     map[string]string // jobID -> executionToken
+
+	// Shares one reconnect among jobs that lose the daemon together
+	daemonRecovery singleflight.Group
 }
 
 // NewExecutor creates a new container executor
 func NewExecutor(cfg config.ContainerConfig, apiClient *api.Client, log *logrus.Logger) (*Executor, error) {
-	// Create Docker client
+	// Create Docker client; the API version is negotiated with the daemon
+	// unless pinned in the configuration
 	dockerClient, err := client.NewClientWithOpts(
 		client.WithHost(cfg.Docker.Endpoint),
+		client.WithAPIVersionNegotiation(),
 		client.WithVersion(cfg.Docker.Version),
 	)
 	if err != nil {
@@ -60,12 +71,20 @@ func NewExecutor(cfg config.ContainerConfig, apiClient *api.Client, log *logrus.
 		return nil, fmt.Errorf("failed to connect to Docker daemon: %w", err)
 	}
 
+	profiles, err := sandbox.NewCatalog(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox profiles: %w", err)
+	}
+
 	executor := &Executor{
 		config:        cfg,
 		timeoutConfig: config.LoadTimeoutConfig(),
 		dockerClient:  dockerClient,
 		log:           log,
 		apiClient:     apiClient,
+		sandbox:       profiles,
+		imageScan:     imagescan.New(cfg.ImageScan, log),
+		images:        NewImageManager(cfg.Pull, cfg.Registries, dockerClient, log),
 		containers:    make(map[string]string),
 		sidecars:      make(map[string]string),
 		networks:      make(map[string]string),
@@ -81,6 +100,18 @@ func NewExecutor(cfg config.ContainerConfig, apiClient *api.Client, log *logrus.
 	return executor, nil
 }
 
+// WithPrewarmer enables pushing execution data into the runtime cache when a
+// sidecar is created
+func (e *Executor) WithPrewarmer(p *runtimecache.Prewarmer) {
+	e.prewarmer = p
+}
+
+// PrePullImages pulls the configured images so the first jobs using them
+// do not wait for a pull
+func (e *Executor) PrePullImages(ctx context.Context) {
+	e.images.PrePull(ctx)
+}
+
 // Type returns the executor type
 func (e *Executor) Type() types.JobType {
 	return types.JobTypeContainer
@@ -108,11 +139,38 @@ func (e *Executor) Validate(job *types.Job) error {
 		)
 	}
 
-	return nil
+	switch job.Execution.ImagePullPolicy {
+	case "", types.PullAlways, types.PullIfNotPresent, types.PullNever:
+	default:
+		return errors.NewValidationError(
+			"imagePullPolicy",
+			"enum",
+			fmt.Sprintf("unsupported image pull policy: %s", job.Execution.ImagePullPolicy),
+		)
+	}
+
+	if _, err := e.sandbox.Resolve(job); err != nil {
+		return err
+	}
+	if err := ValidateWritablePaths(job); err != nil {
+		return err
+	}
+
+	return ValidateStopSettings(job)
+}
+
+// Sandbox returns the sandbox profile catalog
+func (e *Executor) Sandbox() *sandbox.Catalog {
+	return e.sandbox
 }
 
 // Execute runs the job in a container with phase-based timeouts
 func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	profile, err := e.sandbox.Resolve(job)
+	if err != nil {
+		return nil, err
+	}
+
 	updates := make(chan types.ExecutionUpdate, 100)
 
 	// Generate execution ID
@@ -131,10 +189,11 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 
 		// Initialize phase timing
 		timing := NewExecutionTiming()
+		timing.Analysis = job.Metadata["analysis"]
 
 		// Create execution record in the database
 		if e.apiClient != nil {
-			if err := e.apiClient.CreateExecution(ctx, executionID, job.ID, nil, nil); err != nil {
+			if err := e.apiClient.CreateExecution(ctx, executionID, job, nil, nil); err != nil {
 				e.log.WithError(err).Warn("Failed to create execution record")
 			}
 
@@ -144,16 +203,22 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 			}
 		}
 
+		if job.Execution.DryRun {
+			e.executeDryRun(job, profile, updates, executionID, timing)
+			return
+		}
+
 		// Log phase timeouts being used
 		e.log.WithFields(logrus.Fields{
 			"jobID":            job.ID,
 			"setupTimeout":     e.timeoutConfig.SetupTimeout.String(),
 			"executionTimeout": job.GetTimeout().String(),
 			"cleanupTimeout":   e.timeoutConfig.CleanupTimeout.String(),
+			"sandboxProfile":   profile.Name,
 		}).Info("Starting job execution with phase-based timeouts")
 
 		// Execute with phase-based timeouts
-		e.executeWithPhaseTimeouts(ctx, job, updates, executionID, timing)
+		e.executeWithPhaseTimeouts(ctx, job, profile, updates, executionID, timing)
 	}()
 
 	return updates, nil
@@ -177,45 +242,32 @@ func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
 
 	if hasContainer {
 		// Stop container if still running
-		timeout := 10
-		if err := e.dockerClient.ContainerStop(ctx, containerID, container.StopOptions{
-			Timeout: &timeout,
-		}); err != nil {
+		if err := e.stopContainer(ctx, containerID, job, "cancelled"); err != nil {
 			e.log.WithError(err).Warn("Failed to stop container")
 		}
-
-		// Remove container
-		if err := e.removeContainer(ctx, containerID); err != nil {
-			errs = append(errs, fmt.Errorf("failed to remove container: %w", err))
-		}
-
-		e.mu.Lock()
-		delete(e.containers, job.ID)
-		e.mu.Unlock()
 	}
 
-	// Clean up sidecar
+	res := JobResources{JobID: job.ID}
+	if hasContainer {
+		res.ContainerID = containerID
+	}
 	if hasSidecar {
-		if err := e.sidecar.StopSidecar(ctx, sidecarID); err != nil {
-			errs = append(errs, fmt.Errorf("failed to stop sidecar: %w", err))
-		}
-
-		e.mu.Lock()
-		delete(e.sidecars, job.ID)
-		e.mu.Unlock()
+		res.SidecarID = sidecarID
 	}
-
-	// Clean up network
 	if hasNetwork {
-		if err := e.sidecar.RemoveJobNetwork(ctx, networkID); err != nil {
-			errs = append(errs, fmt.Errorf("failed to remove network: %w", err))
-		}
-
-		e.mu.Lock()
-		delete(e.networks, job.ID)
-		e.mu.Unlock()
+		res.NetworkID = networkID
+	}
+	if err := e.cleanup.CleanupJob(ctx, res); err != nil {
+		errs = append(errs, err)
 	}
 
+	// Leftovers were reported; orphan cleanup picks them up once untracked
+	e.mu.Lock()
+	delete(e.containers, job.ID)
+	delete(e.sidecars, job.ID)
+	delete(e.networks, job.ID)
+	e.mu.Unlock()
+
 	// Clean up token
 	e.mu.Lock()
 	delete(e.tokens, job.ID)
@@ -230,15 +282,23 @@ func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
 }
 
 // createContainer creates a new container for the job
-func (e *Executor) createContainer(ctx context.Context, job *types.Job, networkID string, timing *ExecutionTiming) (string, error) {
+func (e *Executor) createContainer(ctx context.Context, job *types.Job, profile *sandbox.Profile, networkID string, timing *ExecutionTiming, updates chan<- types.ExecutionUpdate) (string, error) {
 	// Select image based on script type
 	image := e.getImageForScript(job.Execution.Script.Type)
 
-	// Try to pull the image first (in case it's not available locally)
+	// Make the image available under the job's pull policy, reporting pull
+	// progress in the job's log
 	if timing != nil {
 		timing.ContainerPullStart = time.Now()
 	}
-	if err := e.ensureImage(ctx, image); err != nil {
+	progress := func(line string) {
+		e.sendUpdate(updates, types.UpdateTypeLog, &types.LogEntry{
+			Stream:    "system",
+			Line:      line,
+			Timestamp: time.Now(),
+		})
+	}
+	if err := e.images.Ensure(ctx, image, PullPolicy(e.config.Pull, job), progress); err != nil {
 		dockerErr := errors.NewDockerError(
 			"IMAGE_PULL_FAILED",
 			fmt.Sprintf("failed to ensure image %s: %v", image, err),
@@ -251,6 +311,23 @@ func (e *Executor) createContainer(ctx context.Context, job *types.Job, networkI
 		timing.ContainerPullEnd = time.Now()
 	}
 
+	// Vulnerability gate; the pulled image's digest keys the verdict cache
+	if e.imageScan != nil {
+		verdict, err := e.imageScan.Check(ctx, job, image, e.imageDigest(ctx, image))
+		if timing != nil && verdict != nil {
+			timing.ImageScan = verdict
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// Stop signal and grace period are stored on the container so that any
+	// stop, including orphan cleanup, honours them
+	stopSignal, stopGrace := e.stopSettings(job)
+	stopTimeout := int(stopGrace.Seconds())
+	readOnly := ReadOnlyRootfs(e.config, profile, image)
+
 	// Build container configuration
 	containerConfig := &container.Config{
 		Image:        image,
@@ -261,15 +338,21 @@ func (e *Executor) createContainer(ctx context.Context, job *types.Job, networkI
 		AttachStderr: true,
 		Tty:          false,
 		User:         e.config.Security.User,
+		StopSignal:   stopSignal,
+		StopTimeout:  &stopTimeout,
 	}
 
 	// Build host configuration with resource limits
 	hostConfig := &container.HostConfig{
-		AutoRemove:  false,
-		NetworkMode: container.NetworkMode(networkID),
-		Resources:   e.buildResourceLimits(job),
-		Mounts:      e.buildMounts(job),
-		SecurityOpt: e.buildSecurityOptions(),
+		AutoRemove:     false,
+		NetworkMode:    container.NetworkMode(networkID),
+		Resources:      e.buildResourceLimits(job, profile),
+		Mounts:         e.buildMounts(job, readOnly),
+		VolumesFrom:    e.helperSocketVolumes(job.ID),
+		SecurityOpt:    e.buildSecurityOptions(profile),
+		CapDrop:        profile.DropCapabilities,
+		CapAdd:         profile.AddCapabilities,
+		ReadonlyRootfs: readOnly,
 	}
 
 	// Network configuration
@@ -312,6 +395,12 @@ func (e *Executor) createContainer(ctx context.Context, job *types.Job, networkI
 
 // getImageForScript returns the appropriate image for the script type
 func (e *Executor) getImageForScript(scriptType types.ScriptType) string {
+	return ImageForScript(e.config, scriptType)
+}
+
+// ImageForScript returns the configured image for a script type, or the
+// default runner image
+func ImageForScript(cfg config.ContainerConfig, scriptType types.ScriptType) string {
 	// Default images if not configured
 	defaults := map[string]string{
 		"BASH":   "cronium/runner:bash-alpine",
@@ -321,7 +410,7 @@ func (e *Executor) getImageForScript(scriptType types.ScriptType) string {
 
 	// Get configured image or use default
 	imageKey := string(scriptType)
-	if image, ok := e.config.Images[strings.ToLower(imageKey)]; ok && image != "" {
+	if image, ok := cfg.Images[strings.ToLower(imageKey)]; ok && image != "" {
 		return image
 	}
 
@@ -381,13 +470,25 @@ func (e *Executor) buildEnvironment(job *types.Job) []string {
 		fmt.Sprintf("CRONIUM_EXECUTION_ID=%s", executionID),
 		fmt.Sprintf("CRONIUM_EXECUTION_TOKEN=%s", token),
 		"CRONIUM_RUNTIME_API=http://runtime-api:8081",
+		fmt.Sprintf("CRONIUM_HELPER_SOCKET=%s", helperSocketPath),
+	)
+
+	// Attempt number and idempotency key for retry-safe side effects
+	env = append(env, job.RetryEnvironment()...)
+
+	// Cooperative cancellation: scripts poll the file or trap the stop signal
+	_, grace := e.stopSettings(job)
+	env = append(env,
+		fmt.Sprintf("CRONIUM_CANCEL_FILE=%s", containerCancelFile),
+		fmt.Sprintf("CRONIUM_CANCEL_GRACE_PERIOD=%d", int(grace.Seconds())),
+		fmt.Sprintf("CRONIUM_MESSAGES_FILE=%s", containerMessagesFile),
 	)
 
 	return env
 }
 
 // buildResourceLimits builds container resource limits
-func (e *Executor) buildResourceLimits(job *types.Job) container.Resources {
+func (e *Executor) buildResourceLimits(job *types.Job, profile *sandbox.Profile) container.Resources {
 	resources := container.Resources{}
 
 	// Use job-specific limits or defaults
@@ -405,18 +506,31 @@ func (e *Executor) buildResourceLimits(job *types.Job) container.Resources {
 		// Use defaults
 		resources.NanoCPUs = int64(e.config.Resources.Defaults.CPU * 1e9)
 		// Parse memory string (e.g., "512MB" -> bytes)
-		if memBytes, err := parseMemory(e.config.Resources.Defaults.Memory); err == nil {
+		if memBytes, err := ParseMemory(e.config.Resources.Defaults.Memory); err == nil {
 			resources.Memory = memBytes
 		}
 		pidsLimit := e.config.Resources.Defaults.Pids
 		resources.PidsLimit = &pidsLimit
 	}
 
+	// Clamp to the sandbox profile's ceilings
+	if maxCPU := int64(profile.MaxCPU * 1e9); maxCPU > 0 && (resources.NanoCPUs == 0 || resources.NanoCPUs > maxCPU) {
+		resources.NanoCPUs = maxCPU
+	}
+	if maxMemory, err := ParseMemory(profile.MaxMemory); err == nil && maxMemory > 0 && (resources.Memory == 0 || resources.Memory > maxMemory) {
+		resources.Memory = maxMemory
+	}
+	if profile.MaxPids > 0 && (resources.PidsLimit == nil || *resources.PidsLimit > profile.MaxPids) {
+		maxPids := profile.MaxPids
+		resources.PidsLimit = &maxPids
+	}
+
 	return resources
 }
 
-// buildMounts builds container mounts
-func (e *Executor) buildMounts(job *types.Job) []mount.Mount {
+// buildMounts builds container mounts: /tmp, the job's declared writable
+// paths and, with a read-only root filesystem, /workspace
+func (e *Executor) buildMounts(job *types.Job, readOnly bool) []mount.Mount {
 	mounts := []mount.Mount{
 		{
 			Type:   mount.TypeTmpfs,
@@ -429,47 +543,60 @@ func (e *Executor) buildMounts(job *types.Job) []mount.Mount {
 	}
 
 	// Add workspace mount if needed
-	if job.Execution.Script.WorkingDirectory != "" {
+	if job.Execution.Script.WorkingDirectory != "" || readOnly {
 		// In production, this would mount from a secure location
-		// For now, we'll just use tmpfs
+		// For now, we'll just use tmpfs; the job user must be able to
+		// write to it
 		mounts = append(mounts, mount.Mount{
 			Type:   mount.TypeTmpfs,
 			Target: "/workspace",
 			TmpfsOptions: &mount.TmpfsOptions{
 				SizeBytes: 500 * 1024 * 1024, // 500MB
-				Mode:      0o755,
+				Mode:      0o1777,
 			},
 		})
 	}
 
-	return mounts
+	return append(mounts, writableMounts(job)...)
 }
 
-// buildSecurityOptions builds container security options
-func (e *Executor) buildSecurityOptions() []string {
+// buildSecurityOptions builds container security options from a sandbox
+// profile, or from the container security settings when profile is nil
+func (e *Executor) buildSecurityOptions(profile *sandbox.Profile) []string {
 	opts := []string{}
 
-	if e.config.Security.NoNewPrivileges {
+	noNewPrivileges := e.config.Security.NoNewPrivileges
+	seccompProfile := e.config.Security.SeccompProfile
+	if profile != nil {
+		noNewPrivileges = profile.NoNewPrivileges
+		seccompProfile = profile.SeccompProfile
+	}
+
+	if noNewPrivileges {
 		opts = append(opts, "no-new-privileges")
 	}
 
 	// Add seccomp profile
-	if e.config.Security.SeccompProfile != "" && e.config.Security.SeccompProfile != "default" {
+	if seccompProfile != "" && seccompProfile != "default" {
 		// Only add custom seccomp profiles, let Docker use its default
-		opts = append(opts, fmt.Sprintf("seccomp=%s", e.config.Security.SeccompProfile))
+		opts = append(opts, fmt.Sprintf("seccomp=%s", seccompProfile))
 	}
 
 	return opts
 }
 
-// streamLogs streams container logs to the updates channel
-func (e *Executor) streamLogs(ctx context.Context, containerID string, updates chan<- types.ExecutionUpdate) {
+// streamLogs streams container logs to the updates channel, starting at since
+// when it is set
+func (e *Executor) streamLogs(ctx context.Context, containerID string, updates chan<- types.ExecutionUpdate, since time.Time) {
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
 		Timestamps: true,
 	}
+	if !since.IsZero() {
+		options.Since = since.Format(time.RFC3339Nano)
+	}
 
 	logs, err := e.dockerClient.ContainerLogs(ctx, containerID, options)
 	if err != nil {
@@ -581,8 +708,8 @@ func (e *Executor) sendError(updates chan<- types.ExecutionUpdate, err error, fa
 	})
 }
 
-// parseMemory parses memory strings like "512MB", "1GB" to bytes
-func parseMemory(mem string) (int64, error) {
+// ParseMemory parses memory strings like "512MB", "1GB" to bytes
+func ParseMemory(mem string) (int64, error) {
 	if mem == "" {
 		return 0, fmt.Errorf("empty memory string")
 	}
@@ -590,14 +717,19 @@ func parseMemory(mem string) (int64, error) {
 	// Simple parser for common units
 	mem = strings.ToUpper(strings.TrimSpace(mem))
 
-	multipliers := map[string]int64{
-		"B":  1,
-		"KB": 1024,
-		"MB": 1024 * 1024,
-		"GB": 1024 * 1024 * 1024,
+	// Longest suffixes first so "MB" is not read as "B"
+	multipliers := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"KB", 1024},
+		{"MB", 1024 * 1024},
+		{"GB", 1024 * 1024 * 1024},
+		{"B", 1},
 	}
 
-	for suffix, multiplier := range multipliers {
+	for _, m := range multipliers {
+		suffix, multiplier := m.suffix, m.multiplier
 		if strings.HasSuffix(mem, suffix) {
 			valueStr := strings.TrimSuffix(mem, suffix)
 			value, err := strconv.ParseFloat(valueStr, 64)
@@ -617,32 +749,15 @@ func parseMemory(mem string) (int64, error) {
 	return value, nil
 }
 
-// ensureImage ensures the image is available locally
-func (e *Executor) ensureImage(ctx context.Context, image string) error {
-	// First check if image exists locally
-	_, _, err := e.dockerClient.ImageInspectWithRaw(ctx, image)
-	if err == nil {
-		// Image exists locally
-		return nil
+// imageDigest returns the registry digest of a local image, or an empty
+// string for images that were not pulled from a registry
+func (e *Executor) imageDigest(ctx context.Context, image string) string {
+	inspect, _, err := e.dockerClient.ImageInspectWithRaw(ctx, image)
+	if err != nil || len(inspect.RepoDigests) == 0 {
+		return ""
 	}
-
-	// Image doesn't exist, try to pull it
-	e.log.WithField("image", image).Info("Pulling Docker image")
-
-	reader, err := e.dockerClient.ImagePull(ctx, image, dockerimage.PullOptions{})
-	if err != nil {
-		return fmt.Errorf("failed to pull image: %w", err)
-	}
-	defer reader.Close()
-
-	// Read the output to ensure the pull completes
-	var buf bytes.Buffer
-	if _, err := io.Copy(&buf, reader); err != nil {
-		return fmt.Errorf("failed to read pull output: %w", err)
-	}
-
-	e.log.WithField("image", image).Info("Successfully pulled Docker image")
-	return nil
+	_, digest, _ := strings.Cut(inspect.RepoDigests[0], "@")
+	return digest
 }
 
 // updateExecutionError updates the execution record with error details
@@ -0,0 +1,39 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		mem     string
+		want    int64
+		wantErr bool
+	}{
+		{"512MB", 512 << 20, false},
+		{"1GB", 1 << 30, false},
+		{"1.5gb", 3 << 29, false},
+		{"64KB", 64 << 10, false},
+		{"100B", 100, false},
+		{" 256mb ", 256 << 20, false},
+		{"1048576", 1 << 20, false},
+		{"", 0, true},
+		{"lots", 0, true},
+		{"12XB", 0, true},
+	}
+	// Each case runs several times: suffixes used to be tried in map order,
+	// so "512MB" was sometimes read as "512M" bytes and failed
+	for range 20 {
+		for _, tt := range tests {
+			got, err := ParseMemory(tt.mem)
+			if tt.wantErr {
+				assert.Error(t, err, tt.mem)
+				continue
+			}
+			assert.NoError(t, err, tt.mem)
+			assert.Equal(t, tt.want, got, tt.mem)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	dockerimage "github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// ImageManager makes container images available according to a pull
+// policy. Concurrent pulls of the same image are shared, so jobs starting
+// together wait for a single pull.
+type ImageManager struct {
+	cfg         config.ImagePullConfig
+	registries  []config.RegistryConfig
+	credentials *registryCredentials
+	docker      *client.Client
+	log         *logrus.Logger
+	pulls       singleflight.Group
+}
+
+// NewImageManager creates an image manager using the Docker client and the
+// credentials of the configured registries
+func NewImageManager(cfg config.ImagePullConfig, registries []config.RegistryConfig, docker *client.Client, log *logrus.Logger) *ImageManager {
+	if cfg.ProgressInterval <= 0 {
+		cfg.ProgressInterval = 2 * time.Second
+	}
+	return &ImageManager{
+		cfg:         cfg,
+		registries:  registries,
+		credentials: newRegistryCredentials(registries),
+		docker:      docker,
+		log:         log,
+	}
+}
+
+// PullPolicy returns the job's image pull policy, or the configured one when
+// the job does not set it
+func PullPolicy(cfg config.ImagePullConfig, job *types.Job) types.ImagePullPolicy {
+	if job != nil && job.Execution.ImagePullPolicy != "" {
+		return job.Execution.ImagePullPolicy
+	}
+	if cfg.Policy != "" {
+		return types.ImagePullPolicy(cfg.Policy)
+	}
+	return types.PullIfNotPresent
+}
+
+// Ensure makes image available under policy. progress, when set, receives
+// a line describing the pull every progress interval and when it finishes.
+func (m *ImageManager) Ensure(ctx context.Context, image string, policy types.ImagePullPolicy, progress func(string)) error {
+	switch policy {
+	case types.PullAlways:
+	case types.PullIfNotPresent, types.PullNever:
+		_, _, err := m.docker.ImageInspectWithRaw(ctx, image)
+		if err == nil {
+			return nil
+		}
+		if !client.IsErrNotFound(err) {
+			return fmt.Errorf("failed to inspect image: %w", err)
+		}
+		if policy == types.PullNever {
+			return fmt.Errorf("image is not present locally and the pull policy is %s", types.PullNever)
+		}
+	default:
+		return fmt.Errorf("unknown image pull policy %q", policy)
+	}
+
+	result := m.pulls.DoChan(image, func() (interface{}, error) {
+		return nil, m.pull(ctx, image, progress)
+	})
+	select {
+	case r := <-result:
+		if r.Shared && progress != nil {
+			progress(fmt.Sprintf("Image %s was pulled for another job", image))
+		}
+		return r.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PrePull installs the CAs of private registries and pulls the configured
+// images under the configured policy, logging failures instead of returning
+// them
+func (m *ImageManager) PrePull(ctx context.Context) {
+	if err := installCAs(m.cfg.CertsDir, m.registries); err != nil {
+		m.log.WithError(err).Warn("Failed to install registry CA")
+	}
+
+	policy := PullPolicy(m.cfg, nil)
+	for _, image := range m.cfg.PrePull {
+		if ctx.Err() != nil {
+			return
+		}
+		log := m.log.WithField("image", image)
+		if err := m.Ensure(ctx, image, policy, nil); err != nil {
+			log.WithError(err).Warn("Failed to pre-pull image")
+			continue
+		}
+		log.Debug("Image ready")
+	}
+}
+
+// pull pulls image, reporting progress as it goes. Fetched credentials the
+// registry rejects, such as an expired token, are fetched again once.
+func (m *ImageManager) pull(ctx context.Context, image string, progress func(string)) error {
+	host := registryHost(image)
+	err := m.pullWithAuth(ctx, image, host, progress)
+	if err != nil && isAuthError(err) && m.credentials.forget(host) {
+		m.log.WithField("registry", host).Info("Registry rejected its credentials, fetching new ones")
+		err = m.pullWithAuth(ctx, image, host, progress)
+	}
+	return err
+}
+
+// pullWithAuth pulls image with the current credentials of its registry
+func (m *ImageManager) pullWithAuth(ctx context.Context, image, host string, progress func(string)) error {
+	log := m.log.WithField("image", image)
+	log.Info("Pulling Docker image")
+
+	options := dockerimage.PullOptions{}
+	auth, err := m.credentials.lookup(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials for registry %s: %w", host, err)
+	}
+	if auth != nil {
+		encoded, err := registry.EncodeAuthConfig(*auth)
+		if err != nil {
+			return fmt.Errorf("failed to encode registry credentials: %w", err)
+		}
+		options.RegistryAuth = encoded
+	}
+
+	start := time.Now()
+	reader, err := m.docker.ImagePull(ctx, image, options)
+	if err != nil {
+		return fmt.Errorf("failed to pull image: %w", err)
+	}
+	defer reader.Close()
+
+	state := newPullProgress()
+	reported := start
+	decoder := json.NewDecoder(reader)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read pull output: %w", err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("failed to pull image: %s", msg.Error.Message)
+		}
+		state.update(msg)
+		if progress != nil && time.Since(reported) >= m.cfg.ProgressInterval {
+			progress(fmt.Sprintf("Pulling image %s: %s", image, state))
+			reported = time.Now()
+		}
+	}
+
+	elapsed := time.Since(start).Round(100 * time.Millisecond)
+	log.WithField("duration", elapsed.String()).Info("Successfully pulled Docker image")
+	if progress != nil {
+		line := fmt.Sprintf("Pulled image %s in %s", image, elapsed)
+		if state.digest != "" {
+			line += " (" + state.digest + ")"
+		}
+		progress(line)
+	}
+	return nil
+}
+
+// layerProgress is the download state of one image layer
+type layerProgress struct {
+	current, total int64
+	done           bool
+}
+
+// pullProgress sums the per-layer messages of a pull
+type pullProgress struct {
+	layers map[string]*layerProgress
+	order  []string
+	digest string
+}
+
+func newPullProgress() *pullProgress {
+	return &pullProgress{layers: make(map[string]*layerProgress)}
+}
+
+// update applies one message of the pull output
+func (p *pullProgress) update(msg jsonmessage.JSONMessage) {
+	if digest, ok := strings.CutPrefix(msg.Status, "Digest: "); ok {
+		p.digest = digest
+		return
+	}
+	// Messages without an ID, or for the tag itself, are not about a layer
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from") {
+		return
+	}
+
+	layer, ok := p.layers[msg.ID]
+	if !ok {
+		layer = &layerProgress{}
+		p.layers[msg.ID] = layer
+		p.order = append(p.order, msg.ID)
+	}
+	switch msg.Status {
+	case "Downloading":
+		if msg.Progress != nil {
+			layer.current, layer.total = msg.Progress.Current, msg.Progress.Total
+		}
+	case "Download complete":
+		layer.current = layer.total
+	case "Pull complete", "Already exists":
+		layer.current = layer.total
+		layer.done = true
+	}
+}
+
+// String summarises the pull, for example "2/5 layers, 12.4 MB of 48.0 MB"
+func (p *pullProgress) String() string {
+	var done int
+	var current, total int64
+	for _, id := range p.order {
+		layer := p.layers[id]
+		if layer.done {
+			done++
+		}
+		current += layer.current
+		total += layer.total
+	}
+	summary := fmt.Sprintf("%d/%d layers", done, len(p.order))
+	if total > 0 {
+		summary += fmt.Sprintf(", %.1f MB of %.1f MB", float64(current)/1e6, float64(total)/1e6)
+	}
+	return summary
+}
@@ -0,0 +1,41 @@
+package container
+
+import (
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPullPolicy(t *testing.T) {
+	cfg := config.ImagePullConfig{Policy: "Always"}
+	job := &types.Job{}
+	assert.Equal(t, types.PullAlways, PullPolicy(cfg, job))
+	assert.Equal(t, types.PullIfNotPresent, PullPolicy(config.ImagePullConfig{}, nil))
+
+	job.Execution.ImagePullPolicy = types.PullNever
+	assert.Equal(t, types.PullNever, PullPolicy(cfg, job))
+}
+
+func TestPullProgress(t *testing.T) {
+	p := newPullProgress()
+	for _, msg := range []jsonmessage.JSONMessage{
+		{Status: "Pulling from cronium/runner", ID: "python-alpine"},
+		{Status: "Already exists", ID: "a1"},
+		{Status: "Pulling fs layer", ID: "b2"},
+		{Status: "Pulling fs layer", ID: "c3"},
+		{Status: "Downloading", ID: "b2", Progress: &jsonmessage.JSONProgress{Current: 5_000_000, Total: 10_000_000}},
+		{Status: "Downloading", ID: "c3", Progress: &jsonmessage.JSONProgress{Current: 1_000_000, Total: 30_000_000}},
+	} {
+		p.update(msg)
+	}
+	assert.Equal(t, "1/3 layers, 6.0 MB of 40.0 MB", p.String())
+
+	p.update(jsonmessage.JSONMessage{Status: "Download complete", ID: "b2"})
+	p.update(jsonmessage.JSONMessage{Status: "Pull complete", ID: "b2"})
+	p.update(jsonmessage.JSONMessage{Status: "Digest: sha256:abc"})
+	assert.Equal(t, "2/3 layers, 11.0 MB of 40.0 MB", p.String())
+	assert.Equal(t, "sha256:abc", p.digest)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -17,6 +18,28 @@ type ExecutionClaims struct {
 	jwt.RegisteredClaims
 }
 
+// ExecutionToken generates the runtime API token of a job, taking the user
+// and event from its metadata
+func ExecutionToken(job *types.Job, secret string) (string, error) {
+	userID := ""
+	eventID := ""
+
+	if job.Metadata != nil {
+		if uid, ok := job.Metadata["userId"].(string); ok {
+			userID = uid
+		}
+		if eid, ok := job.Metadata["eventId"].(float64); ok {
+			eventID = fmt.Sprintf("%d", int(eid))
+		} else if eid, ok := job.Metadata["eventId"].(int); ok {
+			eventID = fmt.Sprintf("%d", eid)
+		} else if eid, ok := job.Metadata["eventId"].(string); ok {
+			eventID = eid
+		}
+	}
+
+	return generateJWT(job.ID, secret, userID, eventID)
+}
+
 // generateJWT generates a JWT token for the execution
 func generateJWT(jobID string, secret string, userID string, eventID string) (string, error) {
 	if secret == "" {
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/pkg/stdcopy"
@@ -15,7 +16,7 @@ import (
 )
 
 // executeWithPhaseTimeouts executes the job with separate timeouts for each phase
-func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job, updates chan types.ExecutionUpdate, executionID string, timing *ExecutionTiming) {
+func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job, profile *sandbox.Profile, updates chan types.ExecutionUpdate, executionID string, timing *ExecutionTiming) {
 	// PHASE 1: Setup (network, sidecar, container creation)
 	setupCtx, setupCancel := context.WithTimeout(ctx, e.timeoutConfig.SetupTimeout)
 	defer setupCancel()
@@ -30,35 +31,21 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 
 		timing.MarkCleanupComplete()
 
-		// Clean up container
-		if containerID != "" {
-			e.mu.Lock()
-			delete(e.containers, job.ID)
-			e.mu.Unlock()
-			if err := e.removeContainer(cleanupCtx, containerID); err != nil {
-				e.log.WithError(err).WithField("containerID", containerID).Error("Failed to remove container")
-			}
-		}
-
-		// Clean up sidecar
-		if sidecarID != "" {
-			e.mu.Lock()
-			delete(e.sidecars, job.ID)
-			e.mu.Unlock()
-			if err := e.sidecar.StopSidecar(cleanupCtx, sidecarID); err != nil {
-				e.log.WithError(err).WithField("sidecarID", sidecarID).Error("Failed to stop sidecar")
-			}
-		}
-
-		// Clean up network
-		if networkID != "" {
-			e.mu.Lock()
-			delete(e.networks, job.ID)
-			e.mu.Unlock()
-			if err := e.sidecar.RemoveJobNetwork(cleanupCtx, networkID); err != nil {
-				e.log.WithError(err).WithField("networkID", networkID).Error("Failed to remove job network")
-			}
+		// Remove container, sidecar, network and volumes in that order
+		err := e.cleanup.CleanupJob(cleanupCtx, JobResources{
+			JobID:       job.ID,
+			ContainerID: containerID,
+			SidecarID:   sidecarID,
+			NetworkID:   networkID,
+		})
+		if err != nil {
+			e.log.WithError(err).WithField("jobID", job.ID).Error("Failed to clean up job resources")
 		}
+		e.mu.Lock()
+		delete(e.containers, job.ID)
+		delete(e.sidecars, job.ID)
+		delete(e.networks, job.ID)
+		e.mu.Unlock()
 
 		// Update final timing
 		if e.apiClient != nil {
@@ -78,8 +65,9 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 	// SETUP PHASE: Create isolated network
 	timing.NetworkCreateStart = time.Now()
 	var err error
-	networkID, err = e.sidecar.CreateJobNetwork(setupCtx, job.ID)
+	networkID, err = e.sidecar.CreateJobNetwork(setupCtx, job.ID, profile)
 	timing.NetworkCreateEnd = time.Now()
+	err = e.daemonAware(job, err)
 	
 	if err != nil {
 		if setupCtx.Err() == context.DeadlineExceeded {
@@ -104,6 +92,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 	timing.SidecarCreateStart = time.Now()
 	sidecarID, err = e.sidecar.CreateRuntimeSidecar(setupCtx, job, networkID)
 	timing.SidecarCreateEnd = time.Now()
+	err = e.daemonAware(job, err)
 	
 	if err != nil {
 		if setupCtx.Err() == context.DeadlineExceeded {
@@ -126,8 +115,9 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 
 	// SETUP PHASE: Create container
 	timing.ContainerCreateStart = time.Now()
-	containerID, err = e.createContainer(setupCtx, job, networkID, timing)
+	containerID, err = e.createContainer(setupCtx, job, profile, networkID, timing, updates)
 	timing.ContainerCreateEnd = time.Now()
+	err = e.daemonAware(job, err)
 	
 	if err != nil {
 		if setupCtx.Err() == context.DeadlineExceeded {
@@ -172,6 +162,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 func (e *Executor) runContainer(ctx context.Context, containerID string, job *types.Job, updates chan types.ExecutionUpdate, executionID string, timing *ExecutionTiming) {
 	// Start the container
 	if err := e.dockerClient.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		err = e.daemonAware(job, err)
 		e.sendError(updates, fmt.Errorf("failed to start container: %w", err), true)
 		e.updateExecutionError(ctx, executionID, err)
 		e.sendUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
@@ -186,13 +177,14 @@ func (e *Executor) runContainer(ctx context.Context, containerID string, job *ty
 	logWg.Add(1)
 	go func() {
 		defer logWg.Done()
-		e.streamLogs(ctx, containerID, updates)
+		e.streamLogs(ctx, containerID, updates, time.Time{})
 	}()
 
 	// Wait for container to finish with execution timeout
 	statusCh, errCh := e.dockerClient.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
 	var exitCode int
 	var timedOut bool
+	var infraErr error
 
 	select {
 	case <-ctx.Done():
@@ -206,10 +198,7 @@ func (e *Executor) runContainer(ctx context.Context, containerID string, job *ty
 			}).Info("Script execution timed out")
 			
 			// Try to stop the container gracefully
-			stopTimeout := 10
-			e.dockerClient.ContainerStop(context.Background(), containerID, container.StopOptions{
-				Timeout: &stopTimeout,
-			})
+			e.stopContainer(context.Background(), containerID, job, "timeout")
 			
 			// Get container info for exit code
 			if inspect, err := e.dockerClient.ContainerInspect(context.Background(), containerID); err == nil {
@@ -228,11 +217,32 @@ func (e *Executor) runContainer(ctx context.Context, containerID string, job *ty
 		logWg.Wait()
 		
 	case err := <-errCh:
-		if err != nil {
-			e.sendError(updates, fmt.Errorf("container wait error: %w", err), true)
-			e.updateExecutionError(ctx, executionID, err)
+		if isDaemonDisconnect(err) {
+			// The log stream ends with the connection too
+			lostAt := time.Now()
+			logWg.Wait()
+			exitCode, err = e.resumeAfterDaemonRestart(ctx, containerID, job, updates, lostAt)
+			switch {
+			case err == nil:
+			case ctx.Err() == context.DeadlineExceeded:
+				timedOut = true
+				exitCode = -1
+				e.sendError(updates, fmt.Errorf("script execution timeout exceeded"), true)
+				e.stopContainer(context.Background(), containerID, job, "timeout")
+			case ctx.Err() != nil:
+				timedOut = true
+				exitCode = -2
+				e.sendError(updates, fmt.Errorf("script execution cancelled"), true)
+			default:
+				infraErr = err
+			}
+		} else {
+			if err != nil {
+				e.sendError(updates, fmt.Errorf("container wait error: %w", err), true)
+				e.updateExecutionError(ctx, executionID, err)
+			}
+			logWg.Wait()
 		}
-		logWg.Wait()
 		
 	case status := <-statusCh:
 		exitCode = int(status.StatusCode)
@@ -263,11 +273,18 @@ func (e *Executor) runContainer(ctx context.Context, containerID string, job *ty
 		}
 	}
 
+	e.reportRootfsViolations(logsCtx, job, containerID, outputStr+errorStr, updates, timing)
+
 	// Determine final status
 	var finalStatus types.JobStatus
 	var statusMessage string
 
-	if timedOut {
+	if infraErr != nil {
+		finalStatus = types.JobStatusFailed
+		statusMessage = infraErr.Error()
+		e.sendError(updates, infraErr, true)
+		e.updateExecutionError(ctx, executionID, infraErr)
+	} else if timedOut {
 		finalStatus = types.JobStatusFailed
 		if exitCode == -1 {
 			statusMessage = "Script execution timed out"
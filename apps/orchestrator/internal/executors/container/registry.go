@@ -0,0 +1,212 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/docker/docker/api/types/registry"
+)
+
+const (
+	// How long helper and command credentials are used before they are
+	// fetched again when the registry sets no tokenTTL
+	defaultTokenTTL = time.Hour
+	// Bounds a credential helper or token command
+	credentialTimeout = 30 * time.Second
+	// Username a credential helper returns for an identity token
+	identityTokenUser = "<token>"
+)
+
+// registryCredentials resolves the credentials of the configured
+// registries. Static credentials are used as configured; those from a
+// credential helper or token command are cached until their TTL runs out or
+// they are forgotten after the registry rejected them.
+type registryCredentials struct {
+	registries map[string]config.RegistryConfig
+	// Runs a helper or command with stdin and returns its output
+	run func(ctx context.Context, stdin string, command []string) ([]byte, error)
+
+	mu     sync.Mutex
+	cached map[string]cachedCredentials
+}
+
+type cachedCredentials struct {
+	auth    registry.AuthConfig
+	expires time.Time
+}
+
+// newRegistryCredentials creates the credentials of the configured registries
+func newRegistryCredentials(registries []config.RegistryConfig) *registryCredentials {
+	c := &registryCredentials{
+		registries: make(map[string]config.RegistryConfig, len(registries)),
+		run:        runCredentialCommand,
+		cached:     make(map[string]cachedCredentials),
+	}
+	for _, r := range registries {
+		c.registries[strings.ToLower(r.Host)] = r
+	}
+	return c
+}
+
+// lookup returns the credentials for a registry host, or nil when none are
+// configured
+func (c *registryCredentials) lookup(ctx context.Context, host string) (*registry.AuthConfig, error) {
+	host = strings.ToLower(host)
+	r, ok := c.registries[host]
+	if !ok {
+		return nil, nil
+	}
+
+	switch {
+	case r.Password != "" || r.Token != "":
+		return &registry.AuthConfig{
+			Username:      r.Username,
+			Password:      r.Password,
+			IdentityToken: r.Token,
+			ServerAddress: r.Host,
+		}, nil
+	case r.CredentialHelper == "" && len(r.TokenCommand) == 0:
+		// Only a CA is configured
+		return nil, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.cached[host]; ok && time.Now().Before(cached.expires) {
+		auth := cached.auth
+		return &auth, nil
+	}
+
+	auth, err := c.fetch(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	ttl := r.TokenTTL
+	if ttl <= 0 {
+		ttl = defaultTokenTTL
+	}
+	c.cached[host] = cachedCredentials{auth: auth, expires: time.Now().Add(ttl)}
+	return &auth, nil
+}
+
+// forget drops the cached credentials of a host so the next lookup fetches
+// new ones, and reports whether there were any
+func (c *registryCredentials) forget(host string) bool {
+	host = strings.ToLower(host)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.cached[host]
+	delete(c.cached, host)
+	return ok
+}
+
+// fetch gets credentials from the registry's helper or token command
+func (c *registryCredentials) fetch(ctx context.Context, r config.RegistryConfig) (registry.AuthConfig, error) {
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+
+	if r.CredentialHelper != "" {
+		helper := "docker-credential-" + r.CredentialHelper
+		out, err := c.run(ctx, r.Host, []string{helper, "get"})
+		if err != nil {
+			return registry.AuthConfig{}, fmt.Errorf("credential helper %s failed: %w", helper, err)
+		}
+		var creds struct {
+			Username string `json:"Username"`
+			Secret   string `json:"Secret"`
+		}
+		if err := json.Unmarshal(out, &creds); err != nil {
+			return registry.AuthConfig{}, fmt.Errorf("credential helper %s returned invalid output: %w", helper, err)
+		}
+		if creds.Username == identityTokenUser {
+			return registry.AuthConfig{IdentityToken: creds.Secret, ServerAddress: r.Host}, nil
+		}
+		return registry.AuthConfig{Username: creds.Username, Password: creds.Secret, ServerAddress: r.Host}, nil
+	}
+
+	out, err := c.run(ctx, "", r.TokenCommand)
+	if err != nil {
+		return registry.AuthConfig{}, fmt.Errorf("token command %s failed: %w", r.TokenCommand[0], err)
+	}
+	password := strings.TrimSpace(string(out))
+	if password == "" {
+		return registry.AuthConfig{}, fmt.Errorf("token command %s printed no token", r.TokenCommand[0])
+	}
+	return registry.AuthConfig{Username: r.Username, Password: password, ServerAddress: r.Host}, nil
+}
+
+// runCredentialCommand runs command with stdin, returning its output, or its
+// error output in the error when it fails
+func runCredentialCommand(ctx context.Context, stdin string, command []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// registryHost returns the registry host of an image reference; references
+// without one, such as cronium/runner:bash-alpine, are on Docker Hub
+func registryHost(image string) string {
+	first, _, ok := strings.Cut(image, "/")
+	if !ok || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		return "docker.io"
+	}
+	if first == "index.docker.io" || first == "registry-1.docker.io" {
+		return "docker.io"
+	}
+	return first
+}
+
+// isAuthError reports whether a pull failed because the registry rejected
+// the credentials, for example an expired token
+func isAuthError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"unauthorized", "authentication required", "no basic auth credentials", "access denied", "denied: "} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// installCAs copies the CA of each registry that has one to
+// <dir>/<host>/ca.crt, where the Docker daemon trusts it for that registry
+func installCAs(dir string, registries []config.RegistryConfig) error {
+	for _, r := range registries {
+		if r.CAFile == "" {
+			continue
+		}
+		ca, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA of registry %s: %w", r.Host, err)
+		}
+		path := filepath.Join(dir, r.Host, "ca.crt")
+		if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, ca) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to install CA of registry %s: %w", r.Host, err)
+		}
+		if err := os.WriteFile(path, ca, 0o644); err != nil {
+			return fmt.Errorf("failed to install CA of registry %s: %w", r.Host, err)
+		}
+	}
+	return nil
+}
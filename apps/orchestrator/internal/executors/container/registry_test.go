@@ -0,0 +1,107 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryHost(t *testing.T) {
+	for image, host := range map[string]string{
+		"cronium/runner:bash-alpine":            "docker.io",
+		"alpine":                                "docker.io",
+		"index.docker.io/library/alpine":        "docker.io",
+		"ghcr.io/acme/runner:1.2":               "ghcr.io",
+		"registry.example.com:5000/team/runner": "registry.example.com:5000",
+		"localhost/runner":                      "localhost",
+	} {
+		assert.Equal(t, host, registryHost(image), image)
+	}
+}
+
+func TestRegistryCredentials(t *testing.T) {
+	c := newRegistryCredentials([]config.RegistryConfig{
+		{Host: "ghcr.io", Username: "bot", Password: "secret"},
+		{Host: "ecr.example.com", Username: "AWS", TokenCommand: []string{"aws", "ecr", "get-login-password"}},
+		{Host: "helper.example.com", CredentialHelper: "acme"},
+		{Host: "ca.example.com", CAFile: "/etc/ca.crt"},
+	})
+	calls := 0
+	c.run = func(ctx context.Context, stdin string, command []string) ([]byte, error) {
+		calls++
+		if command[0] == "docker-credential-acme" {
+			assert.Equal(t, "helper.example.com", stdin)
+			return []byte(`{"ServerURL":"helper.example.com","Username":"<token>","Secret":"refresh"}`), nil
+		}
+		return []byte(fmt.Sprintf("token-%d\n", calls)), nil
+	}
+	ctx := context.Background()
+
+	auth, err := c.lookup(ctx, "GHCR.io")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", auth.Password)
+
+	auth, err = c.lookup(ctx, "ca.example.com")
+	require.NoError(t, err)
+	assert.Nil(t, auth)
+	auth, err = c.lookup(ctx, "docker.io")
+	require.NoError(t, err)
+	assert.Nil(t, auth)
+
+	auth, err = c.lookup(ctx, "helper.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "refresh", auth.IdentityToken)
+
+	// Fetched tokens are cached until forgotten
+	auth, err = c.lookup(ctx, "ecr.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "AWS", auth.Username)
+	assert.Equal(t, "token-2", auth.Password)
+	auth, _ = c.lookup(ctx, "ecr.example.com")
+	assert.Equal(t, "token-2", auth.Password)
+	assert.True(t, c.forget("ecr.example.com"))
+	assert.False(t, c.forget("ghcr.io"))
+	auth, _ = c.lookup(ctx, "ecr.example.com")
+	assert.Equal(t, "token-3", auth.Password)
+
+	// And until their TTL runs out
+	c.cached["ecr.example.com"] = cachedCredentials{auth: *auth, expires: time.Now().Add(-time.Second)}
+	auth, _ = c.lookup(ctx, "ecr.example.com")
+	assert.Equal(t, "token-4", auth.Password)
+
+	c.run = func(ctx context.Context, stdin string, command []string) ([]byte, error) {
+		return nil, errors.New("exit status 255: expired session")
+	}
+	c.forget("ecr.example.com")
+	_, err = c.lookup(ctx, "ecr.example.com")
+	assert.EqualError(t, err, "token command aws failed: exit status 255: expired session")
+}
+
+func TestIsAuthError(t *testing.T) {
+	assert.True(t, isAuthError(errors.New("failed to pull image: Head \"https://x/v2/a/manifests/1\": unauthorized: authentication required")))
+	assert.True(t, isAuthError(errors.New("failed to pull image: denied: Your authorization token has expired")))
+	assert.False(t, isAuthError(errors.New("failed to pull image: manifest unknown")))
+}
+
+func TestInstallCAs(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, []byte("CA"), 0o600))
+
+	certs := filepath.Join(dir, "certs.d")
+	registries := []config.RegistryConfig{{Host: "registry.internal:5000", CAFile: caFile}, {Host: "ghcr.io"}}
+	require.NoError(t, installCAs(certs, registries))
+
+	data, err := os.ReadFile(filepath.Join(certs, "registry.internal:5000", "ca.crt"))
+	require.NoError(t, err)
+	assert.Equal(t, "CA", string(data))
+	assert.NoDirExists(t, filepath.Join(certs, "ghcr.io"))
+}
@@ -0,0 +1,221 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Default size of a writable path declared without one
+	defaultWritableSize = 100 * 1024 * 1024 // 100MB
+	// Most writable paths a job may declare
+	maxWritablePaths = 8
+	// Most root filesystem writes reported per execution
+	maxRootfsViolations = 20
+)
+
+var (
+	// Paths that cannot be declared writable: the container's own mounts and
+	// the directories every job can already write to
+	reservedPaths = []string{"/", "/proc", "/sys", "/dev", helperSocketDir, "/tmp", "/workspace"}
+
+	// Error output of a write to a read-only filesystem, as printed by
+	// coreutils and shells (EROFS), Python ([Errno 30]) and Node (EROFS)
+	readOnlyWrite = regexp.MustCompile(`(?i)read-only file system`)
+	firstPath     = regexp.MustCompile(`(?:^|[\s'"])(/[^\s'":,]*)`)
+)
+
+// ValidateWritablePaths checks the writable paths a job declares
+func ValidateWritablePaths(job *types.Job) error {
+	paths := job.Execution.WritablePaths
+	if len(paths) > maxWritablePaths {
+		return errors.NewValidationError(
+			"writablePaths",
+			"max",
+			fmt.Sprintf("at most %d writable paths may be declared", maxWritablePaths),
+		)
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range paths {
+		if !path.IsAbs(p.Path) || path.Clean(p.Path) != p.Path {
+			return errors.NewValidationError(
+				"writablePaths",
+				"path",
+				fmt.Sprintf("writable path %q must be a clean absolute path", p.Path),
+			)
+		}
+		for _, reserved := range reservedPaths {
+			if p.Path == reserved || strings.HasPrefix(p.Path, reserved+"/") && reserved != "/" {
+				return errors.NewValidationError(
+					"writablePaths",
+					"reserved",
+					fmt.Sprintf("writable path %q is reserved or already writable", p.Path),
+				)
+			}
+		}
+		if seen[p.Path] {
+			return errors.NewValidationError(
+				"writablePaths",
+				"unique",
+				fmt.Sprintf("writable path %q is declared twice", p.Path),
+			)
+		}
+		seen[p.Path] = true
+
+		switch p.Type {
+		case "", "tmpfs", "volume":
+		default:
+			return errors.NewValidationError(
+				"writablePaths",
+				"enum",
+				fmt.Sprintf("writable path %q has unsupported type %q", p.Path, p.Type),
+			)
+		}
+		if p.Size < 0 {
+			return errors.NewValidationError(
+				"writablePaths",
+				"min",
+				fmt.Sprintf("writable path %q has a negative size", p.Path),
+			)
+		}
+	}
+	return nil
+}
+
+// WritableSize returns the size limit of a writable path
+func WritableSize(p types.WritablePath) int64 {
+	if p.Size > 0 {
+		return p.Size
+	}
+	return defaultWritableSize
+}
+
+// ReadOnlyRootfs reports whether a job container running image under
+// profile gets a read-only root filesystem. Images listed for compatibility
+// keep a writable one.
+func ReadOnlyRootfs(cfg config.ContainerConfig, profile *sandbox.Profile, image string) bool {
+	if !profile.ReadOnlyRootfs {
+		return false
+	}
+	for _, legacy := range cfg.Security.WritableRootfsImages {
+		if prefix, ok := strings.CutSuffix(legacy, "*"); ok && strings.HasPrefix(image, prefix) || legacy == image {
+			return false
+		}
+	}
+	return true
+}
+
+// writableMounts returns the mounts for a job's declared writable paths
+func writableMounts(job *types.Job) []mount.Mount {
+	var mounts []mount.Mount
+	for _, p := range job.Execution.WritablePaths {
+		if p.Type == "volume" {
+			// Anonymous volume, removed with the container
+			mounts = append(mounts, mount.Mount{Type: mount.TypeVolume, Target: p.Path})
+			continue
+		}
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeTmpfs,
+			Target: p.Path,
+			TmpfsOptions: &mount.TmpfsOptions{
+				SizeBytes: WritableSize(p),
+				Mode:      0o1777,
+			},
+		})
+	}
+	return mounts
+}
+
+// RootfsViolations returns the paths a job failed to write because the root
+// filesystem is read-only, as far as its error output shows them
+func RootfsViolations(output string) []string {
+	var violations []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if !readOnlyWrite.MatchString(line) {
+			continue
+		}
+		violation := strings.TrimSpace(line)
+		if m := firstPath.FindStringSubmatch(line); m != nil {
+			violation = m[1]
+		}
+		if seen[violation] {
+			continue
+		}
+		seen[violation] = true
+		violations = append(violations, violation)
+		if len(violations) == maxRootfsViolations {
+			break
+		}
+	}
+	return violations
+}
+
+// rootfsWrites returns the files a container with a writable root filesystem
+// added, changed or deleted, which would be violations with a read-only one.
+// Directories are left out when a change below them is listed.
+func (e *Executor) rootfsWrites(ctx context.Context, containerID string) ([]string, error) {
+	changes, err := e.dockerClient.ContainerDiff(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var writes []string
+	for i, change := range changes {
+		if change.Kind == container.ChangeModify && i+1 < len(changes) && strings.HasPrefix(changes[i+1].Path, change.Path+"/") {
+			continue
+		}
+		writes = append(writes, change.Path)
+		if len(writes) == maxRootfsViolations {
+			break
+		}
+	}
+	return writes, nil
+}
+
+// reportRootfsViolations records a finished job's writes to its root
+// filesystem on the execution timing and warns about them in the job's
+// output. A read-only root filesystem shows them in the job's error output;
+// for images exempted for compatibility they are read from the container's
+// changes.
+func (e *Executor) reportRootfsViolations(ctx context.Context, job *types.Job, containerID, output string, updates chan<- types.ExecutionUpdate, timing *ExecutionTiming) {
+	profile, err := e.sandbox.Resolve(job)
+	if err != nil || !profile.ReadOnlyRootfs {
+		return
+	}
+
+	var violations []string
+	what := "tried to write to its read-only root filesystem"
+	if image := e.getImageForScript(job.Execution.Script.Type); ReadOnlyRootfs(e.config, profile, image) {
+		violations = RootfsViolations(output)
+	} else {
+		if violations, err = e.rootfsWrites(ctx, containerID); err != nil {
+			e.log.WithError(err).WithField("jobID", job.ID).Debug("Failed to read container changes")
+			return
+		}
+		what = fmt.Sprintf("wrote to its root filesystem, which fails once image %s runs read-only,", image)
+	}
+	if len(violations) == 0 {
+		return
+	}
+
+	timing.RootfsViolations = violations
+	e.log.WithFields(logrus.Fields{
+		"jobID":      job.ID,
+		"violations": violations,
+	}).Warn("Job wrote to its root filesystem")
+	e.sendError(updates, fmt.Errorf("job %s at %s; declare writable paths in execution.writablePaths",
+		what, strings.Join(violations, ", ")), false)
+}
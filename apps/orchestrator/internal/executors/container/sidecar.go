@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
@@ -14,6 +16,13 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// The sidecar serves the helper protocol on a Unix socket in a volume that the
+// job container mounts too, so scripts can call it without HTTP
+const (
+	helperSocketDir  = "/run/cronium"
+	helperSocketPath = helperSocketDir + "/helper.sock"
+)
+
 // SidecarManager manages runtime API sidecar containers
 type SidecarManager struct {
 	executor *Executor
@@ -36,9 +45,22 @@ func (sm *SidecarManager) CreateRuntimeSidecar(ctx context.Context, job *types.J
 		return "", fmt.Errorf("failed to generate execution token: %w", err)
 	}
 
+	// Seed the runtime cache so the first helper calls are hits; on failure
+	// the runtime falls back to fetching from the backend
+	prewarmCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := sm.executor.prewarmer.Prewarm(prewarmCtx, job.ID, job); err != nil {
+		sm.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to pre-warm runtime cache")
+	}
+	cancel()
+
+	image := sm.getRuntimeImage()
+	if err := sm.executor.images.Ensure(ctx, image, PullPolicy(sm.executor.config.Pull, nil), nil); err != nil {
+		return "", fmt.Errorf("failed to ensure runtime image %s: %w", image, err)
+	}
+
 	// Build container configuration
 	containerConfig := &container.Config{
-		Image: sm.getRuntimeImage(),
+		Image: image,
 		Env: []string{
 			"EXECUTION_ID=" + job.ID,
 			"JWT_SECRET=" + sm.executor.config.Runtime.JWTSecret,
@@ -46,6 +68,7 @@ func (sm *SidecarManager) CreateRuntimeSidecar(ctx context.Context, job *types.J
 			"BACKEND_TOKEN=" + os.Getenv("CRONIUM_API_TOKEN"),
 			"VALKEY_URL=" + sm.executor.config.Runtime.ValkeyURL,
 			"PORT=8081",
+			"SOCKET_PATH=" + helperSocketPath,
 			"LOG_LEVEL=info",
 		},
 		ExposedPorts: nat.PortSet{
@@ -87,6 +110,11 @@ func (sm *SidecarManager) CreateRuntimeSidecar(ctx context.Context, job *types.J
 					Mode:      0o1777,
 				},
 			},
+			{
+				// Anonymous volume, removed with the sidecar
+				Type:   mount.TypeVolume,
+				Target: helperSocketDir,
+			},
 		},
 	}
 
@@ -115,7 +143,7 @@ func (sm *SidecarManager) CreateRuntimeSidecar(ctx context.Context, job *types.J
 	// Start container
 	if err := sm.executor.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
 		// Clean up on failure
-		_ = sm.executor.dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+		_ = sm.executor.dockerClient.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
 		return "", fmt.Errorf("failed to start runtime sidecar: %w", err)
 	}
 
@@ -168,7 +196,8 @@ func (sm *SidecarManager) StopSidecar(ctx context.Context, containerID string) e
 
 	// Remove container
 	if err := sm.executor.dockerClient.ContainerRemove(ctx, containerID, container.RemoveOptions{
-		Force: true,
+		Force:         true,
+		RemoveVolumes: true,
 	}); err != nil {
 		return fmt.Errorf("failed to remove sidecar: %w", err)
 	}
@@ -222,24 +251,7 @@ func (sm *SidecarManager) waitForHealth(ctx context.Context, containerID string)
 
 // generateExecutionToken generates a JWT token for the execution
 func (sm *SidecarManager) generateExecutionToken(job *types.Job) (string, error) {
-	// Extract user and event IDs from metadata
-	userID := ""
-	eventID := ""
-
-	if job.Metadata != nil {
-		if uid, ok := job.Metadata["userId"].(string); ok {
-			userID = uid
-		}
-		if eid, ok := job.Metadata["eventId"].(float64); ok {
-			eventID = fmt.Sprintf("%d", int(eid))
-		} else if eid, ok := job.Metadata["eventId"].(int); ok {
-			eventID = fmt.Sprintf("%d", eid)
-		} else if eid, ok := job.Metadata["eventId"].(string); ok {
-			eventID = eid
-		}
-	}
-
-	return generateJWT(job.ID, sm.executor.config.Runtime.JWTSecret, userID, eventID)
+	return ExecutionToken(job, sm.executor.config.Runtime.JWTSecret)
 }
 
 // storeExecutionToken stores the token for use by the main container
@@ -275,18 +287,26 @@ func (sm *SidecarManager) getRuntimeImage() string {
 	return "cronium/runtime-api:latest"
 }
 
-// CreateJobNetwork creates an isolated network for a job
-func (sm *SidecarManager) CreateJobNetwork(ctx context.Context, jobID string) (string, error) {
+// CreateJobNetwork creates an isolated network for a job. The sandbox
+// profile decides whether it has an external route; its egress rules are
+// recorded as a label for the host firewall to enforce.
+func (sm *SidecarManager) CreateJobNetwork(ctx context.Context, jobID string, profile *sandbox.Profile) (string, error) {
 	networkName := fmt.Sprintf("cronium-job-%s", jobID)
 
+	labels := map[string]string{
+		"cronium.job.id":          jobID,
+		"cronium.managed":         "true",
+		"cronium.sandbox.profile": profile.Name,
+	}
+	if len(profile.Egress) > 0 {
+		labels["cronium.egress"] = strings.Join(profile.Egress, ",")
+	}
+
 	// Create network with specific configuration
 	resp, err := sm.executor.dockerClient.NetworkCreate(ctx, networkName, network.CreateOptions{
-		Driver: "bridge",
-		Labels: map[string]string{
-			"cronium.job.id":  jobID,
-			"cronium.managed": "true",
-		},
-		Internal: sm.executor.config.Runtime.IsolateNetwork, // No external access if configured
+		Driver:   "bridge",
+		Labels:   labels,
+		Internal: profile.IsolateNetwork, // No external access for isolated profiles
 		Options: map[string]string{
 			"com.docker.network.bridge.enable_icc": "true", // Enable inter-container communication
 		},
@@ -305,3 +325,16 @@ func (sm *SidecarManager) RemoveJobNetwork(ctx context.Context, networkID string
 	}
 	return nil
 }
+
+// helperSocketVolumes mounts the sidecar's helper socket directory into the
+// job container
+func (e *Executor) helperSocketVolumes(jobID string) []string {
+	e.mu.RLock()
+	sidecarID := e.sidecars[jobID]
+	e.mu.RUnlock()
+
+	if sidecarID == "" {
+		return nil
+	}
+	return []string{sidecarID + ":rw"}
+}
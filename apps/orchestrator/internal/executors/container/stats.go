@@ -0,0 +1,89 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// SampleStats reads the current resource usage of a job's container from the Docker daemon
+func (e *Executor) SampleStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+	e.mu.RLock()
+	containerID, exists := e.containers[job.ID]
+	e.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("no running container for job %s", job.ID)
+	}
+
+	// A non-streaming request waits for two readings so that precpu_stats is
+	// populated and a CPU percentage can be derived
+	resp, err := e.dockerClient.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get container stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var stats container.StatsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode container stats: %w", err)
+	}
+
+	sample := &types.ResourceSample{
+		JobID:       job.ID,
+		Timestamp:   time.Now(),
+		Source:      "docker",
+		CPUPercent:  calculateCPUPercent(&stats),
+		MemoryBytes: int64(stats.MemoryStats.Usage),
+		MemoryLimit: int64(stats.MemoryStats.Limit),
+		Processes:   int(stats.PidsStats.Current),
+		CPUSeconds:  float64(stats.CPUStats.CPUUsage.TotalUsage) / 1e9,
+	}
+
+	// Page cache is not process memory; docker stats reports usage without it
+	if cache, ok := stats.MemoryStats.Stats["inactive_file"]; ok && cache < stats.MemoryStats.Usage {
+		sample.MemoryBytes -= int64(cache)
+	}
+	// cgroup v1 also keeps the peak since the container started, which
+	// catches spikes between samples
+	if peak := int64(stats.MemoryStats.MaxUsage); peak > sample.MemoryBytes {
+		sample.MemoryBytes = peak
+	}
+
+	for _, netStats := range stats.Networks {
+		sample.NetworkRx += int64(netStats.RxBytes)
+		sample.NetworkTx += int64(netStats.TxBytes)
+	}
+
+	for _, entry := range stats.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			sample.DiskRead += int64(entry.Value)
+		case "write":
+			sample.DiskWrite += int64(entry.Value)
+		}
+	}
+
+	return sample, nil
+}
+
+// calculateCPUPercent derives CPU usage the same way as the docker stats command
+func calculateCPUPercent(stats *container.StatsResponse) float64 {
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	onlineCPUs := float64(stats.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return (cpuDelta / systemDelta) * onlineCPUs * 100.0
+}
@@ -0,0 +1,162 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/api/types/container"
+)
+
+// supportedStopSignals lists the signals a job may request as its stop signal
+var supportedStopSignals = map[string]bool{
+	"SIGTERM": true,
+	"SIGINT":  true,
+	"SIGQUIT": true,
+	"SIGHUP":  true,
+	"SIGUSR1": true,
+	"SIGUSR2": true,
+	"SIGKILL": true,
+}
+
+// normalizeStopSignal converts a signal name such as "term" to "SIGTERM"
+func normalizeStopSignal(signal string) string {
+	signal = strings.ToUpper(strings.TrimSpace(signal))
+	if signal != "" && !strings.HasPrefix(signal, "SIG") {
+		signal = "SIG" + signal
+	}
+	return signal
+}
+
+// ValidateStopSettings checks the job's stop signal and grace period
+func ValidateStopSettings(job *types.Job) error {
+	if job.Execution.StopSignal != "" && !supportedStopSignals[normalizeStopSignal(job.Execution.StopSignal)] {
+		return errors.NewValidationError(
+			"stopSignal",
+			"enum",
+			fmt.Sprintf("unsupported stop signal: %s", job.Execution.StopSignal),
+		)
+	}
+
+	if job.Execution.TerminationGracePeriod < 0 {
+		return errors.NewValidationError(
+			"terminationGracePeriod",
+			"min",
+			"termination grace period must not be negative",
+		)
+	}
+
+	return nil
+}
+
+// stopSettings returns the stop signal and grace period for a job, falling
+// back to the configured defaults and capping the grace period at the maximum
+func (e *Executor) stopSettings(job *types.Job) (string, time.Duration) {
+	signal := normalizeStopSignal(e.config.Stop.DefaultSignal)
+	grace := e.config.Stop.DefaultGracePeriod
+	if job == nil {
+		return signal, grace
+	}
+
+	if job.Execution.StopSignal != "" {
+		signal = normalizeStopSignal(job.Execution.StopSignal)
+	}
+
+	if job.Execution.TerminationGracePeriod > 0 {
+		grace = job.Execution.TerminationGracePeriod
+		if e.config.Stop.MaxGracePeriod > 0 && grace > e.config.Stop.MaxGracePeriod {
+			e.log.WithField("requestedGracePeriod", grace).
+				WithField("maxGracePeriod", e.config.Stop.MaxGracePeriod).
+				Info("Capping termination grace period to maximum allowed")
+			grace = e.config.Stop.MaxGracePeriod
+		}
+	}
+
+	return signal, grace
+}
+
+// containerCancelFile is where the cancel request is written inside job
+// containers; scripts see it as CRONIUM_CANCEL_FILE
+const containerCancelFile = "/tmp/.cronium-cancel"
+
+// requestCancel writes the cancel file inside the container so scripts
+// polling cronium.cancelled() notice before the stop signal arrives. It is
+// best effort: a container that already exited simply gets stopped.
+func (e *Executor) requestCancel(ctx context.Context, containerID string, job *types.Job, reason string) {
+	_, grace := e.stopSettings(job)
+	content := fmt.Sprintf(`{"reason":%q,"gracePeriod":%d,"requestedAt":%q}`,
+		reason, int(grace.Seconds()), time.Now().UTC().Format(time.RFC3339))
+
+	execResp, err := e.dockerClient.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", `[ -e "$1" ] || printf '%s' "$2" > "$1"`, "sh", containerCancelFile, content},
+	})
+	if err != nil {
+		e.log.WithError(err).Debug("Failed to create cancel file exec")
+		return
+	}
+	if err := e.dockerClient.ContainerExecStart(ctx, execResp.ID, container.ExecStartOptions{}); err != nil {
+		e.log.WithError(err).Debug("Failed to write cancel file")
+	}
+}
+
+// containerMessagesFile is where operator messages are appended inside job
+// containers; scripts see it as CRONIUM_MESSAGES_FILE
+const containerMessagesFile = "/tmp/.cronium-messages"
+
+// DeliverMessage appends an operator message to the messages file inside
+// the job's container
+func (e *Executor) DeliverMessage(ctx context.Context, job *types.Job, message []byte) error {
+	e.mu.RLock()
+	containerID, exists := e.containers[job.ID]
+	e.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no running container for job %s", job.ID)
+	}
+
+	execResp, err := e.dockerClient.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd: []string{"sh", "-c", `printf '%s\n' "$2" >> "$1"`, "sh", containerMessagesFile, string(message)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create messages file exec: %w", err)
+	}
+	if err := e.dockerClient.ContainerExecStart(ctx, execResp.ID, container.ExecStartOptions{}); err != nil {
+		return fmt.Errorf("failed to write messages file: %w", err)
+	}
+	return nil
+}
+
+// stopContainer asks the script to cancel and then stops the container with
+// the job's stop signal and grace period
+func (e *Executor) stopContainer(ctx context.Context, containerID string, job *types.Job, reason string) error {
+	e.requestCancel(ctx, containerID, job, reason)
+	return e.dockerClient.ContainerStop(ctx, containerID, e.stopOptions(job))
+}
+
+// Cancel stops a running job's container with the job's stop signal and
+// kills it once the grace period has passed
+func (e *Executor) Cancel(ctx context.Context, job *types.Job, reason string) error {
+	e.mu.RLock()
+	containerID, exists := e.containers[job.ID]
+	e.mu.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("no running container for job %s", job.ID)
+	}
+
+	e.log.WithField("jobID", job.ID).WithField("reason", reason).Info("Stopping container of cancelled job")
+	return e.stopContainer(ctx, containerID, job, reason)
+}
+
+// stopOptions builds the Docker stop options for a job
+func (e *Executor) stopOptions(job *types.Job) container.StopOptions {
+	signal, grace := e.stopSettings(job)
+	timeout := int(grace.Seconds())
+	return container.StopOptions{
+		Signal:  signal,
+		Timeout: &timeout,
+	}
+}
@@ -31,6 +31,16 @@ type ExecutionTiming struct {
 	// Cleanup phase
 	CleanupStart time.Time
 	CleanupEnd   time.Time
+
+	// Pre-execution analysis report, if the job was analysed
+	Analysis interface{}
+
+	// Writes to the root filesystem, refused or, for legacy images,
+	// allowed
+	RootfsViolations []string
+
+	// Vulnerability gate verdict for the job's image
+	ImageScan interface{}
 }
 
 // NewExecutionTiming creates a new timing tracker
@@ -126,6 +136,15 @@ func (t *ExecutionTiming) ToExecutionStatusUpdate() *api.ExecutionStatusUpdate {
 			"containerCreateTime": t.ContainerCreateEnd.Sub(t.ContainerCreateStart).Milliseconds(),
 		},
 	}
+	if t.Analysis != nil {
+		update.ExecutionMetadata["analysis"] = t.Analysis
+	}
+	if t.ImageScan != nil {
+		update.ExecutionMetadata["imageScan"] = t.ImageScan
+	}
+	if len(t.RootfsViolations) > 0 {
+		update.ExecutionMetadata["rootfsViolations"] = t.RootfsViolations
+	}
 
 	// Only set completed times if they're not zero
 	if t.SetupEnd.IsZero() {
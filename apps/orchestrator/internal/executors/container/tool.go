@@ -0,0 +1,89 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// toolMemoryLimit caps the memory of tooling containers
+const toolMemoryLimit = 512 * 1024 * 1024
+
+// RunTool runs cmd in a throwaway container of image with no network and
+// returns its output and exit code. It is used for tooling such as the
+// pre-execution analysis stage, not for user scripts. The container is
+// named after the job so orphan cleanup picks it up if the orchestrator dies.
+func (e *Executor) RunTool(ctx context.Context, job *types.Job, name, image string, cmd, env []string) (string, string, int, error) {
+	if err := e.images.Ensure(ctx, image, PullPolicy(e.config.Pull, nil), nil); err != nil {
+		return "", "", 0, fmt.Errorf("failed to ensure image %s: %w", image, err)
+	}
+
+	pids := e.config.Resources.Defaults.Pids
+	resp, err := e.dockerClient.ContainerCreate(
+		ctx,
+		&container.Config{
+			Image:        image,
+			Cmd:          cmd,
+			Env:          env,
+			AttachStdout: true,
+			AttachStderr: true,
+			User:         e.config.Security.User,
+		},
+		&container.HostConfig{
+			NetworkMode: "none",
+			Resources: container.Resources{
+				Memory:    toolMemoryLimit,
+				PidsLimit: &pids,
+			},
+			SecurityOpt: e.buildSecurityOptions(nil),
+		},
+		nil,
+		nil,
+		fmt.Sprintf("cronium-job-%s-%s", job.ID, name),
+	)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("failed to create %s container: %w", name, err)
+	}
+	defer func() {
+		// Remove with a fresh context so a cancelled run is still cleaned up
+		if err := e.removeContainer(context.Background(), resp.ID); err != nil {
+			e.log.WithError(err).WithField("containerID", resp.ID[:12]).Warn("Failed to remove tool container")
+		}
+	}()
+
+	if err := e.dockerClient.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return "", "", 0, fmt.Errorf("failed to start %s container: %w", name, err)
+	}
+
+	var exitCode int
+	statusCh, errCh := e.dockerClient.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return "", "", 0, fmt.Errorf("failed waiting for %s container: %w", name, err)
+		}
+	case status := <-statusCh:
+		exitCode = int(status.StatusCode)
+	case <-ctx.Done():
+		return "", "", 0, ctx.Err()
+	}
+
+	logs, err := e.dockerClient.ContainerLogs(ctx, resp.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", "", exitCode, fmt.Errorf("failed to read %s output: %w", name, err)
+	}
+	defer logs.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, logs); err != nil {
+		return "", "", exitCode, fmt.Errorf("failed to read %s output: %w", name, err)
+	}
+	return stdout.String(), stderr.String(), exitCode, nil
+}
@@ -0,0 +1,32 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/versions"
+)
+
+// MinDockerAPIVersion is the oldest Docker Engine API the executor relies on
+const MinDockerAPIVersion = "1.41"
+
+// CheckAPIVersion verifies that the Docker API version in use, negotiated or
+// pinned in the configuration, is accepted by the daemon and new enough for
+// the executor. It returns the version in use.
+func (e *Executor) CheckAPIVersion(ctx context.Context) (string, error) {
+	server, err := e.dockerClient.ServerVersion(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to query Docker version: %w", err)
+	}
+
+	version := e.dockerClient.ClientVersion()
+	switch {
+	case versions.GreaterThan(version, server.APIVersion):
+		return version, fmt.Errorf("Docker API version %s is newer than Docker %s supports (%s)", version, server.Version, server.APIVersion)
+	case server.MinAPIVersion != "" && versions.LessThan(version, server.MinAPIVersion):
+		return version, fmt.Errorf("Docker API version %s is older than Docker %s accepts (%s)", version, server.Version, server.MinAPIVersion)
+	case versions.LessThan(version, MinDockerAPIVersion):
+		return version, fmt.Errorf("Docker %s only supports API version %s, this orchestrator needs %s or newer", server.Version, version, MinDockerAPIVersion)
+	}
+	return version, nil
+}
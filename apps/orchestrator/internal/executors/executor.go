@@ -2,7 +2,9 @@ package executors
 
 import (
 	"context"
+	"errors"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/sirupsen/logrus"
 )
@@ -22,16 +24,73 @@ type Executor interface {
 	Type() types.JobType
 }
 
+// StatsSampler is implemented by executors that can report live resource
+// usage for a running job
+type StatsSampler interface {
+	// SampleStats returns the current resource usage of a running job
+	SampleStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error)
+}
+
+// Canceller is implemented by executors that can stop a running job
+// gracefully: the job is sent its stop signal and killed once its grace
+// period has passed
+type Canceller interface {
+	// Cancel stops a running job and returns once it has been stopped
+	Cancel(ctx context.Context, job *types.Job, reason string) error
+}
+
+// FileChecker is implemented by executors that can check for a file on a
+// job's target
+type FileChecker interface {
+	// FileExists reports whether path exists on the job's target
+	FileExists(ctx context.Context, job *types.Job, path string) (bool, error)
+}
+
+// MessageDeliverer is implemented by executors that can hand operator
+// messages to a running job's script through its messages file
+type MessageDeliverer interface {
+	// DeliverMessage appends a JSON-encoded message as one line to the
+	// job's messages file
+	DeliverMessage(ctx context.Context, job *types.Job, message []byte) error
+}
+
 // Manager manages multiple executors
 type Manager struct {
 	executors map[types.JobType]Executor
+	matrix    *matrixRunner
+	locale    config.LocaleConfig
 }
 
 // NewManager creates a new executor manager
 func NewManager() *Manager {
 	return &Manager{
 		executors: make(map[types.JobType]Executor),
+		matrix:    newMatrixRunner(config.MatrixConfig{MaxCombinations: 64, MaxParallel: 4}),
+	}
+}
+
+// WithMatrixLimits sets the limits applied to matrix jobs
+func (m *Manager) WithMatrixLimits(cfg config.MatrixConfig) {
+	m.matrix = newMatrixRunner(cfg)
+}
+
+// WithLocale sets the clock and locale environment applied to jobs
+func (m *Manager) WithLocale(cfg config.LocaleConfig) {
+	m.locale = cfg
+}
+
+// applyLocale normalizes the job's clock and locale environment when enabled
+// for all jobs or requested by the job
+func (m *Manager) applyLocale(job *types.Job) {
+	if !m.locale.Enabled && job.Execution.Locale == nil {
+		return
 	}
+	job.ApplyLocale(types.Locale{
+		TZ:       m.locale.TZ,
+		Lang:     m.locale.Lang,
+		LCAll:    m.locale.LCAll,
+		FakeTime: m.locale.FakeTime,
+	}, m.locale.FakeTimeLibrary)
 }
 
 // Register adds an executor for a specific job type
@@ -65,6 +124,27 @@ func (m *Manager) Execute(ctx context.Context, job *types.Job) (<-chan types.Exe
 		)
 	}
 
+	// Normalize the environment before matrix children copy it
+	m.applyLocale(job)
+
+	// Matrix jobs run as one child execution per combination
+	if job.Execution.Matrix != nil {
+		children, err := m.matrix.expand(executor, job)
+		if err != nil {
+			return nil, err
+		}
+		return m.matrix.run(ctx, executor, job, children), nil
+	}
+
+	// Resolve parameters before the executor sees the environment and input
+	if err := job.ApplyParameters(); err != nil {
+		return nil, err
+	}
+
+	if err := validateSSHOptions(job); err != nil {
+		return nil, err
+	}
+
 	// Validate the job
 	if err := executor.Validate(job); err != nil {
 		return nil, err
@@ -73,3 +153,204 @@ func (m *Manager) Execute(ctx context.Context, job *types.Job) (<-chan types.Exe
 	// Execute the job
 	return executor.Execute(ctx, job)
 }
+
+// validateSSHOptions rejects options only the SSH runner implements, input
+// references and failure snapshots, for jobs of other types instead of
+// silently ignoring them
+func validateSSHOptions(job *types.Job) error {
+	if job.Type == types.JobTypeSSH {
+		return nil
+	}
+	if len(job.Execution.InputRefs) > 0 {
+		return types.NewExecutionError(
+			"validation",
+			"INPUT_REFS_UNSUPPORTED",
+			"Input references are only supported for SSH jobs",
+			false,
+		)
+	}
+	if job.Execution.SnapshotOnFailure != nil && *job.Execution.SnapshotOnFailure {
+		return types.NewExecutionError(
+			"validation",
+			"SNAPSHOT_UNSUPPORTED",
+			"Failure snapshots are only supported for SSH jobs",
+			false,
+		)
+	}
+	return nil
+}
+
+// SampleStats returns live resource usage for a running job if its executor
+// supports it. For a matrix job the usage of its running combinations is
+// added up.
+func (m *Manager) SampleStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+	if children, ok := m.matrix.children(job.ID); ok {
+		return m.sampleMatrixStats(ctx, job, children)
+	}
+
+	executor, ok := m.GetExecutor(job.Type)
+	if !ok {
+		return nil, types.NewExecutionError(
+			"unsupported",
+			"UNSUPPORTED_JOB_TYPE",
+			"No executor available for job type: "+string(job.Type),
+			false,
+		)
+	}
+
+	sampler, ok := executor.(StatsSampler)
+	if !ok {
+		return nil, types.NewExecutionError(
+			"unsupported",
+			"STATS_UNSUPPORTED",
+			"Executor does not support resource sampling: "+string(job.Type),
+			false,
+		)
+	}
+
+	return sampler.SampleStats(ctx, job)
+}
+
+// FileExists checks for a file on the job's target if its executor supports it
+func (m *Manager) FileExists(ctx context.Context, job *types.Job, path string) (bool, error) {
+	executor, ok := m.GetExecutor(job.Type)
+	if !ok {
+		return false, types.NewExecutionError(
+			"unsupported",
+			"UNSUPPORTED_JOB_TYPE",
+			"No executor available for job type: "+string(job.Type),
+			false,
+		)
+	}
+
+	checker, ok := executor.(FileChecker)
+	if !ok {
+		return false, types.NewExecutionError(
+			"unsupported",
+			"FILE_CHECK_UNSUPPORTED",
+			"Executor does not support file checks: "+string(job.Type),
+			false,
+		)
+	}
+
+	return checker.FileExists(ctx, job, path)
+}
+
+// sampleMatrixStats adds up the usage of a matrix job's running
+// combinations. Counters since the start are left out because combinations
+// that finished drop out of the sum.
+func (m *Manager) sampleMatrixStats(ctx context.Context, job *types.Job, children []*types.Job) (*types.ResourceSample, error) {
+	var total *types.ResourceSample
+	var errs []error
+	for _, child := range children {
+		sample, err := m.SampleStats(ctx, child)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if total == nil {
+			total = &types.ResourceSample{JobID: job.ID, Timestamp: sample.Timestamp, Source: sample.Source}
+		}
+		total.CPUPercent += sample.CPUPercent
+		total.MemoryBytes += sample.MemoryBytes
+		total.MemoryLimit += sample.MemoryLimit
+		total.Processes += sample.Processes
+	}
+	if total == nil {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, types.NewExecutionError(
+			"unsupported",
+			"STATS_UNAVAILABLE",
+			"No matrix combinations are running",
+			false,
+		)
+	}
+	return total, nil
+}
+
+// Cancel stops a running job through its executor if the executor supports
+// it. Cancelling a matrix job stops its running combinations; the caller
+// ends the job's context so that no further combinations start.
+func (m *Manager) Cancel(ctx context.Context, job *types.Job, reason string) error {
+	if children, ok := m.matrix.children(job.ID); ok {
+		var errs []error
+		for _, child := range children {
+			if err := m.Cancel(ctx, child, reason); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	executor, ok := m.GetExecutor(job.Type)
+	if !ok {
+		return types.NewExecutionError(
+			"unsupported",
+			"UNSUPPORTED_JOB_TYPE",
+			"No executor available for job type: "+string(job.Type),
+			false,
+		)
+	}
+
+	canceller, ok := executor.(Canceller)
+	if !ok {
+		return types.NewExecutionError(
+			"unsupported",
+			"CANCEL_UNSUPPORTED",
+			"Executor does not support cancellation: "+string(job.Type),
+			false,
+		)
+	}
+
+	return canceller.Cancel(ctx, job, reason)
+}
+
+// DeliverMessage writes an operator message to a running job's messages
+// file if its executor supports it. A matrix job's message goes to each of
+// its running combinations and is delivered if any of them got it.
+func (m *Manager) DeliverMessage(ctx context.Context, job *types.Job, message []byte) error {
+	if children, ok := m.matrix.children(job.ID); ok {
+		var errs []error
+		for _, child := range children {
+			if err := m.DeliverMessage(ctx, child, message); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(errs) < len(children) {
+			return nil
+		}
+		if len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+		return types.NewExecutionError(
+			"unsupported",
+			"MESSAGES_UNAVAILABLE",
+			"No matrix combinations are running",
+			false,
+		)
+	}
+
+	executor, ok := m.GetExecutor(job.Type)
+	if !ok {
+		return types.NewExecutionError(
+			"unsupported",
+			"UNSUPPORTED_JOB_TYPE",
+			"No executor available for job type: "+string(job.Type),
+			false,
+		)
+	}
+
+	deliverer, ok := executor.(MessageDeliverer)
+	if !ok {
+		return types.NewExecutionError(
+			"unsupported",
+			"MESSAGES_UNSUPPORTED",
+			"Executor does not support message files: "+string(job.Type),
+			false,
+		)
+	}
+
+	return deliverer.DeliverMessage(ctx, job, message)
+}
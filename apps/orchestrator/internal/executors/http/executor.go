@@ -0,0 +1,508 @@
+// Package http runs HTTP request jobs. The request is built from the job's
+// HTTPConfig, with the URL, header values and body rendered as templates,
+// sent from the orchestrator and retried on connection errors, 429 and 5xx
+// responses. The response status and body are streamed as job output.
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultRetryDelay = time.Second
+	// Longest Retry-After a server can ask for before the job's own delay
+	// is used instead
+	maxRetryAfter = 5 * time.Minute
+	// Longest response line streamed as one log entry
+	maxLine = 64 * 1024
+)
+
+var methods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// Executor runs HTTP request jobs
+type Executor struct {
+	cfg       config.HTTPConfig
+	hosts     []string
+	client    *http.Client
+	apiClient *api.Client
+	log       *logrus.Logger
+}
+
+// Response is the output of an HTTP job
+type Response struct {
+	StatusCode int                 `json:"statusCode"`
+	Status     string              `json:"status"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	// Decoded when the response is JSON, otherwise the text
+	Body      interface{} `json:"body,omitempty"`
+	Truncated bool        `json:"truncated,omitempty"`
+	Attempts  int         `json:"attempts"`
+}
+
+// request is a rendered HTTP request
+type request struct {
+	method  string
+	url     string
+	headers map[string]string
+	body    []byte
+}
+
+// NewExecutor creates an HTTP executor
+func NewExecutor(cfg config.HTTPConfig, apiClient *api.Client, log *logrus.Logger) *Executor {
+	return &Executor{
+		cfg:       cfg,
+		hosts:     allowedHosts(cfg.AllowedHosts),
+		client:    newClient(cfg),
+		apiClient: apiClient,
+		log:       log,
+	}
+}
+
+// Type returns the executor type
+func (e *Executor) Type() types.JobType {
+	return types.JobTypeHTTP
+}
+
+// Validate checks that the job's request can be built and goes to an
+// allowed host
+func (e *Executor) Validate(job *types.Job) error {
+	req, err := render(job, 1)
+	if err != nil {
+		return err
+	}
+	if err := e.checkURL(req.url); err != nil {
+		return types.NewExecutionError("validation", "HTTP_HOST_NOT_ALLOWED", err.Error(), false)
+	}
+	return nil
+}
+
+// Cleanup has nothing to release; requests do not outlive Execute
+func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
+	return nil
+}
+
+// Execute sends the job's request and streams the response
+func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	updates := make(chan types.ExecutionUpdate, 100)
+	go e.run(ctx, job, updates)
+	return updates, nil
+}
+
+// run sends the request, retrying transient failures, and reports the
+// outcome
+func (e *Executor) run(ctx context.Context, job *types.Job, updates chan<- types.ExecutionUpdate) {
+	defer close(updates)
+
+	log := e.log.WithField("jobID", job.ID)
+
+	executionID := fmt.Sprintf("exec_%s_%d", job.ID, time.Now().Unix())
+	if e.apiClient != nil {
+		if err := e.apiClient.CreateExecution(ctx, executionID, job, nil, nil); err != nil {
+			log.WithError(err).Warn("Failed to create execution record")
+		}
+	}
+
+	startedAt := time.Now()
+	e.updateExecution(executionID, types.JobStatusRunning, &api.ExecutionStatusUpdate{StartedAt: &startedAt})
+
+	retries := min(job.Execution.HTTP.Retries, e.cfg.MaxRetries)
+	delay := job.Execution.HTTP.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	var sequence int64
+	logLine := func(stream, line string) {
+		sequence++
+		e.send(updates, types.UpdateTypeLog, types.NewLogEntry(stream, line, sequence))
+	}
+
+	var resp *Response
+	var lastErr error
+	for attempt := 1; attempt <= retries+1; attempt++ {
+		req, err := render(job, attempt)
+		if err != nil {
+			e.fail(updates, executionID, err)
+			return
+		}
+
+		e.send(updates, types.UpdateTypeStatus, types.NewStatusUpdate(types.JobStatusRunning,
+			redact.String(fmt.Sprintf("Sending %s %s (attempt %d of %d)", req.method, req.url, attempt, retries+1))))
+
+		var retryAfter time.Duration
+		resp, retryAfter, lastErr = e.do(ctx, req, logLine)
+		if ctx.Err() != nil {
+			break
+		}
+		if resp != nil {
+			resp.Attempts = attempt
+		}
+		if !retryable(resp, lastErr) || attempt > retries {
+			break
+		}
+
+		wait := delay
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+		logLine("stderr", fmt.Sprintf("Retrying in %s", wait))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		delay *= 2
+	}
+
+	completedAt := time.Now()
+	record := &api.ExecutionStatusUpdate{CompletedAt: &completedAt}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		e.complete(updates, types.JobStatusFailed, -1, "HTTP request timed out", nil)
+		record.ExitCode = intPtr(-1)
+		e.updateExecution(executionID, types.JobStatusTimeout, record)
+
+	case ctx.Err() != nil:
+		e.complete(updates, types.JobStatusFailed, -2, "HTTP request cancelled", nil)
+		record.ExitCode = intPtr(-2)
+		e.updateExecution(executionID, types.JobStatusCancelled, record)
+
+	case resp == nil:
+		e.fail(updates, executionID, types.NewExecutionError("http", "HTTP_REQUEST_FAILED",
+			redact.String(fmt.Sprintf("HTTP request failed: %v", lastErr)), !errors.Is(lastErr, errBlocked)))
+
+	default:
+		exitCode := 0
+		status := types.JobStatusCompleted
+		message := "HTTP " + resp.Status
+		if !expected(job.Execution.HTTP.ExpectStatus, resp.StatusCode) {
+			exitCode = 1
+			status = types.JobStatusFailed
+			message = fmt.Sprintf("HTTP %s is not an expected status", resp.Status)
+			record.Error = &message
+		}
+		e.complete(updates, status, exitCode, message, resp)
+
+		record.ExitCode = &exitCode
+		if resp.Body != nil {
+			text, ok := resp.Body.(string)
+			if !ok {
+				data, _ := json.Marshal(resp.Body)
+				text = string(data)
+			}
+			record.Output = &text
+		}
+		record.ExecutionMetadata = map[string]interface{}{
+			"executionType": "http",
+			"statusCode":    resp.StatusCode,
+			"attempts":      resp.Attempts,
+		}
+		e.updateExecution(executionID, status, record)
+	}
+}
+
+// do sends one attempt and streams the response body line by line. It also
+// returns the delay a 429 or 503 response asked for.
+func (e *Executor) do(ctx context.Context, req *request, logLine func(stream, line string)) (*Response, time.Duration, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, req.url, bytes.NewReader(req.body))
+	if err != nil {
+		return nil, 0, err
+	}
+	httpReq.Header.Set("User-Agent", e.cfg.UserAgent)
+	if req.body != nil && json.Valid(req.body) {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range req.headers {
+		httpReq.Header.Set(key, value)
+	}
+	if err := checkHost(e.hosts, httpReq.URL); err != nil {
+		logLine("stderr", redact.String(fmt.Sprintf("Request failed: %v", err)))
+		return nil, 0, err
+	}
+
+	httpResp, err := e.client.Do(httpReq)
+	if err != nil {
+		logLine("stderr", redact.String(fmt.Sprintf("Request failed: %v", err)))
+		return nil, 0, err
+	}
+	defer httpResp.Body.Close()
+
+	logLine("stdout", fmt.Sprintf("%s %s", httpResp.Proto, httpResp.Status))
+
+	var body bytes.Buffer
+	truncated := false
+	scanner := bufio.NewScanner(httpResp.Body)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		logLine("stdout", redact.String(string(line)))
+		if room := e.cfg.MaxResponseSize - int64(body.Len()); room > int64(len(line)) {
+			body.Write(line)
+			body.WriteByte('\n')
+		} else {
+			truncated = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logLine("stderr", fmt.Sprintf("Failed to read response: %v", err))
+		truncated = true
+		io.Copy(io.Discard, httpResp.Body)
+	}
+
+	resp := &Response{
+		StatusCode: httpResp.StatusCode,
+		Status:     httpResp.Status,
+		Headers:    httpResp.Header,
+		Truncated:  truncated,
+	}
+	text := strings.TrimSuffix(body.String(), "\n")
+	mediaType, _, _ := mime.ParseMediaType(httpResp.Header.Get("Content-Type"))
+	var decoded interface{}
+	if !truncated && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) && json.Unmarshal(body.Bytes(), &decoded) == nil {
+		resp.Body = decoded
+	} else if text != "" {
+		resp.Body = redact.String(text)
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(httpResp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		retryAfter = min(time.Duration(seconds)*time.Second, maxRetryAfter)
+	}
+	return resp, retryAfter, nil
+}
+
+// checkURL refuses a request URL whose host is not allowed
+func (e *Executor) checkURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	return checkHost(e.hosts, u)
+}
+
+// retryable reports whether an attempt failed in a way worth retrying
+func retryable(resp *Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, errBlocked)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// expected reports whether a response status counts as success
+func expected(statuses []int, code int) bool {
+	if len(statuses) == 0 {
+		return code >= 200 && code < 300
+	}
+	return slices.Contains(statuses, code)
+}
+
+// complete sends the completion update
+func (e *Executor) complete(updates chan<- types.ExecutionUpdate, status types.JobStatus, exitCode int, message string, resp *Response) {
+	update := &types.StatusUpdate{
+		Status:   status,
+		Message:  message,
+		ExitCode: &exitCode,
+	}
+	if resp != nil {
+		update.Output = &types.OutputData{Data: resp}
+	}
+	e.send(updates, types.UpdateTypeComplete, update)
+}
+
+// fail sends an error and a failed completion and records the failure
+func (e *Executor) fail(updates chan<- types.ExecutionUpdate, executionID string, err error) {
+	message := err.Error()
+	e.send(updates, types.UpdateTypeError, &types.StatusUpdate{
+		Status:  types.JobStatusFailed,
+		Message: message,
+		Error:   types.ErrorDetailsFromError(err),
+	})
+	e.complete(updates, types.JobStatusFailed, 1, message, nil)
+
+	completedAt := time.Now()
+	e.updateExecution(executionID, types.JobStatusFailed, &api.ExecutionStatusUpdate{
+		CompletedAt: &completedAt,
+		ExitCode:    intPtr(1),
+		Error:       &message,
+	})
+}
+
+// send delivers an update
+func (e *Executor) send(updates chan<- types.ExecutionUpdate, updateType types.UpdateType, data interface{}) {
+	updates <- types.ExecutionUpdate{
+		Type:      updateType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// updateExecution records the execution's status in the backend
+func (e *Executor) updateExecution(executionID string, status types.JobStatus, details *api.ExecutionStatusUpdate) {
+	if e.apiClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.apiClient.UpdateExecution(ctx, executionID, status, details); err != nil {
+		e.log.WithError(err).WithField("executionID", executionID).Warn("Failed to update execution")
+	}
+}
+
+// templateData is what the request templates can refer to
+type templateData struct {
+	JobID     string
+	Attempt   int
+	Env       map[string]string
+	Input     map[string]any
+	Variables map[string]any
+	Params    map[string]any
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// render builds the request of a job for an attempt
+func render(job *types.Job, attempt int) (*request, error) {
+	h := job.Execution.HTTP
+	if h == nil {
+		return nil, types.NewExecutionError("validation", "HTTP_CONFIG_MISSING", "HTTP jobs require an http request", false)
+	}
+
+	req := &request{method: strings.ToUpper(h.Method), headers: make(map[string]string, len(h.Headers))}
+	if req.method == "" {
+		req.method = http.MethodGet
+	}
+	if !slices.Contains(methods, req.method) {
+		return nil, invalid("unsupported HTTP method %q", h.Method)
+	}
+	if h.Retries < 0 || h.RetryDelay < 0 {
+		return nil, invalid("retries and retryDelay must not be negative")
+	}
+
+	data := templateData{
+		JobID:     job.ID,
+		Attempt:   attempt,
+		Env:       job.Execution.Environment,
+		Input:     job.Execution.InputData,
+		Variables: job.Execution.Variables,
+		Params:    job.Execution.ParameterValues,
+	}
+
+	var err error
+	if req.url, err = renderString("url", h.URL, data); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(req.url, "http://") && !strings.HasPrefix(req.url, "https://") {
+		return nil, invalid("url must be an absolute http or https URL")
+	}
+	for key, value := range h.Headers {
+		if req.headers[key], err = renderString("header "+key, value, data); err != nil {
+			return nil, err
+		}
+	}
+
+	switch body := h.Body.(type) {
+	case nil:
+	case string:
+		text, err := renderString("body", body, data)
+		if err != nil {
+			return nil, err
+		}
+		req.body = []byte(text)
+	default:
+		rendered, err := renderValue(body, data)
+		if err != nil {
+			return nil, err
+		}
+		if req.body, err = json.Marshal(rendered); err != nil {
+			return nil, invalid("body cannot be encoded as JSON: %v", err)
+		}
+	}
+	return req, nil
+}
+
+// renderValue renders the strings in a JSON body
+func renderValue(v any, data templateData) (any, error) {
+	switch v := v.(type) {
+	case string:
+		return renderString("body", v, data)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			rendered, err := renderValue(value, data)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = rendered
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			rendered, err := renderValue(value, data)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// renderString renders one template. Unknown keys are errors so a typo does
+// not send an empty value.
+func renderString(name, text string, data templateData) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", invalid("invalid %s template: %v", name, err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", invalid("failed to render %s: %v", name, err)
+	}
+	return out.String(), nil
+}
+
+// invalid returns a validation error for a job's request
+func invalid(format string, args ...any) error {
+	return types.NewExecutionError("validation", "INVALID_HTTP_REQUEST", fmt.Sprintf(format, args...), false)
+}
+
+// intPtr returns a pointer to v
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig() config.HTTPConfig {
+	return config.HTTPConfig{
+		Enabled:         true,
+		RequestTimeout:  5 * time.Second,
+		MaxResponseSize: 1024,
+		MaxRetries:      3,
+		UserAgent:       "cronium-test",
+		// Test servers listen on loopback
+		AllowPrivateNetworks: true,
+	}
+}
+
+func newTestExecutor() *Executor {
+	return newExecutorWith(testConfig())
+}
+
+func newExecutorWith(cfg config.HTTPConfig) *Executor {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewExecutor(cfg, nil, log)
+}
+
+func httpJob(h *types.HTTPConfig) *types.Job {
+	return &types.Job{
+		ID:   "job_1",
+		Type: types.JobTypeHTTP,
+		Execution: types.ExecutionConfig{
+			HTTP:            h,
+			Environment:     map[string]string{"REGION": "eu-west-1"},
+			ParameterValues: map[string]any{"count": 3},
+		},
+	}
+}
+
+// collect runs a job and returns its log lines and final status update
+func collect(t *testing.T, e *Executor, ctx context.Context, job *types.Job) ([]string, *types.StatusUpdate) {
+	require.NoError(t, e.Validate(job))
+	updates, err := e.Execute(ctx, job)
+	require.NoError(t, err)
+
+	var lines []string
+	var final *types.StatusUpdate
+	for update := range updates {
+		switch update.Type {
+		case types.UpdateTypeLog:
+			lines = append(lines, update.Data.(*types.LogEntry).Line)
+		case types.UpdateTypeComplete:
+			final = update.Data.(*types.StatusUpdate)
+		}
+	}
+	require.NotNil(t, final)
+	return lines, final
+}
+
+func TestRequestIsTemplated(t *testing.T) {
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "/regions/eu-west-1", r.URL.Path)
+		assert.Equal(t, "job_1", r.Header.Get("X-Job"))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "cronium-test", r.Header.Get("User-Agent"))
+		json.NewDecoder(r.Body).Decode(&received)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{\"accepted\":true}\n"))
+	}))
+	defer server.Close()
+
+	lines, final := collect(t, newTestExecutor(), context.Background(), httpJob(&types.HTTPConfig{
+		Method:  "post",
+		URL:     server.URL + "/regions/{{ .Env.REGION }}",
+		Headers: map[string]string{"X-Job": "{{ .JobID }}"},
+		Body:    map[string]any{"count": "{{ .Params.count }}", "tags": []any{"a", "{{ .Attempt }}"}},
+	}))
+
+	assert.Equal(t, map[string]any{"count": "3", "tags": []any{"a", "1"}}, received)
+	assert.Equal(t, types.JobStatusCompleted, final.Status)
+	assert.Equal(t, 0, *final.ExitCode)
+	assert.Equal(t, []string{"HTTP/1.1 200 OK", `{"accepted":true}`}, lines)
+
+	resp := final.Output.Data.(*Response)
+	assert.Equal(t, map[string]any{"accepted": true}, resp.Body)
+	assert.Equal(t, 1, resp.Attempts)
+}
+
+func TestRetriesTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	_, final := collect(t, newTestExecutor(), context.Background(), httpJob(&types.HTTPConfig{
+		URL:        server.URL,
+		Retries:    5,
+		RetryDelay: time.Millisecond,
+	}))
+
+	assert.Equal(t, int32(3), calls.Load())
+	assert.Equal(t, types.JobStatusCompleted, final.Status)
+	resp := final.Output.Data.(*Response)
+	assert.Equal(t, 3, resp.Attempts)
+	assert.Equal(t, "done", resp.Body)
+}
+
+func TestUnexpectedStatusFails(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, final := collect(t, newTestExecutor(), context.Background(), httpJob(&types.HTTPConfig{
+		URL:     server.URL,
+		Retries: 2,
+	}))
+	// Client errors are not retried
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+	assert.Equal(t, 1, *final.ExitCode)
+
+	_, final = collect(t, newTestExecutor(), context.Background(), httpJob(&types.HTTPConfig{
+		URL:          server.URL,
+		ExpectStatus: []int{http.StatusNotFound},
+	}))
+	assert.Equal(t, types.JobStatusCompleted, final.Status)
+}
+
+func TestCancelledWhileWaitingToRetry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, final := collect(t, newTestExecutor(), ctx, httpJob(&types.HTTPConfig{
+		URL:        server.URL,
+		Retries:    3,
+		RetryDelay: time.Minute,
+	}))
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+	assert.Equal(t, -1, *final.ExitCode)
+}
+
+func TestValidate(t *testing.T) {
+	e := newTestExecutor()
+
+	for name, h := range map[string]*types.HTTPConfig{
+		"missing":     nil,
+		"method":      {Method: "TRACE", URL: "https://example.com"},
+		"relative":    {URL: "/path"},
+		"unknown key": {URL: "https://example.com/{{ .Env.MISSING }}"},
+		"syntax":      {URL: "https://example.com/{{ .Env.REGION"},
+		"negative":    {URL: "https://example.com", Retries: -1},
+	} {
+		err := e.Validate(httpJob(h))
+		var execErr *types.ExecutionError
+		require.True(t, errors.As(err, &execErr), name)
+		assert.Equal(t, "validation", execErr.Type, name)
+	}
+}
+
+func TestInternalAddressesBlocked(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.AllowPrivateNetworks = false
+	lines, final := collect(t, newExecutorWith(cfg), context.Background(), httpJob(&types.HTTPConfig{
+		URL:        server.URL,
+		Retries:    2,
+		RetryDelay: time.Millisecond,
+	}))
+
+	assert.Equal(t, int32(0), calls.Load())
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+	// Blocked requests are not retried
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "internal address")
+
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "169.254.169.254", "100.64.0.1", "::1", "fd00::1", "::ffff:192.168.0.1"} {
+		assert.True(t, blockedAddr(netip.MustParseAddr(addr)), addr)
+	}
+	assert.False(t, blockedAddr(netip.MustParseAddr("93.184.216.34")))
+}
+
+func TestHostAllowlist(t *testing.T) {
+	redirected := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("redirect to a host outside the allowlist was followed")
+	}))
+	defer redirected.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, strings.Replace(redirected.URL, "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer server.Close()
+
+	cfg := testConfig()
+	cfg.AllowedHosts = []string{"127.0.0.1", "*.example.com"}
+	e := newExecutorWith(cfg)
+
+	var execErr *types.ExecutionError
+	require.True(t, errors.As(e.Validate(httpJob(&types.HTTPConfig{URL: "https://internal.test/"})), &execErr))
+	assert.Equal(t, "HTTP_HOST_NOT_ALLOWED", execErr.Code)
+	assert.NoError(t, e.Validate(httpJob(&types.HTTPConfig{URL: "https://api.example.com/"})))
+
+	_, final := collect(t, e, context.Background(), httpJob(&types.HTTPConfig{URL: server.URL}))
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+}
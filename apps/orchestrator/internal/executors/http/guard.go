@@ -0,0 +1,101 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// errBlocked is returned for requests to destinations the executor may not
+// reach; they are not retried
+var errBlocked = errors.New("destination not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range, internal like the
+// private ranges
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// newClient returns the client for job requests. Unless private networks
+// are allowed, connections to loopback, private, link-local and other
+// internal addresses are refused when dialing, after DNS resolution, so a
+// name that resolves or rebinds to such an address is caught too. Proxies
+// from the environment are then not used since only the proxy's address
+// would be checked.
+func newClient(cfg config.HTTPConfig) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateNetworks {
+		dialer.Control = func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", errBlocked, address)
+			}
+			if blockedAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s is an internal address", errBlocked, addrPort.Addr())
+			}
+			return nil
+		}
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	hosts := allowedHosts(cfg.AllowedHosts)
+	return &http.Client{
+		Timeout:   cfg.RequestTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return checkHost(hosts, req.URL)
+		},
+	}
+}
+
+// blockedAddr reports whether an address is internal to the orchestrator's
+// network or host
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() || addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// allowedHosts normalizes the configured host allowlist
+func allowedHosts(hosts []string) []string {
+	normalized := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			normalized = append(normalized, host)
+		}
+	}
+	return normalized
+}
+
+// checkHost refuses a URL whose host is neither an allowed host nor a
+// subdomain of an allowed "*." entry; an empty allowlist allows any host
+func checkHost(hosts []string, u *url.URL) error {
+	if len(hosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return nil
+			}
+			continue
+		}
+		if host == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s is not in the allowlist", errBlocked, u.Hostname())
+}
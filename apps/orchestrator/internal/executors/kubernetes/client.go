@@ -0,0 +1,63 @@
+package kubernetes
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// newClient creates a clientset for the configured API server, or for the
+// in-cluster one when none is configured. client-go re-reads the service
+// account token file as the kubelet rotates it.
+func newClient(cfg config.KubernetesConfig) (clientset.Interface, error) {
+	server := cfg.APIServer
+	if server == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return nil, fmt.Errorf("not running in a Kubernetes cluster and no API server configured")
+		}
+		server = "https://" + net.JoinHostPort(host, port)
+	}
+
+	// Every running job polls its pod, so the default client-side rate
+	// limit of 5 requests a second is too low
+	restCfg := &rest.Config{
+		Host:      server,
+		UserAgent: "cronium-orchestrator",
+		QPS:       50,
+		Burst:     100,
+	}
+	// The token and CA files are optional outside a cluster
+	if ok, err := fileExists(cfg.TokenFile); err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	} else if ok {
+		restCfg.BearerTokenFile = cfg.TokenFile
+	}
+	if ok, err := fileExists(cfg.CAFile); err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	} else if ok {
+		restCfg.TLSClientConfig.CAFile = cfg.CAFile
+	}
+
+	return clientset.NewForConfig(restCfg)
+}
+
+// fileExists reports whether a configured file is present
+func fileExists(path string) (bool, error) {
+	if path == "" {
+		return false, nil
+	}
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
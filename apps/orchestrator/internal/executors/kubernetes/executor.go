@@ -0,0 +1,525 @@
+// Package kubernetes runs container jobs as Kubernetes pods. Each execution
+// gets a pod with the job container and the runtime API as a native sidecar,
+// a config map holding the script and a secret holding the credentials and
+// environment, all removed when the execution ends. Jobs use the same images,
+// resource limits and sandbox profiles as Docker jobs; logs are streamed from
+// the API server.
+//
+// The executor talks to the API server through client-go with the service
+// account of the orchestrator's pod, which needs create, get and delete on
+// pods, pods/log, secrets and configmaps in the jobs namespace.
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/imagescan"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	cerrors "github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Output kept for the execution record; logs are streamed in full
+	maxOutput = 1 << 20
+	// Longest log line streamed as one entry
+	maxLine = 1 << 20
+)
+
+// Waiting reasons after which a container will not start without changes
+var fatalWaitReasons = map[string]bool{
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// Executor runs container jobs as Kubernetes pods
+type Executor struct {
+	cfg       config.ContainerConfig
+	kube      config.KubernetesConfig
+	client    clientset.Interface
+	apiClient *api.Client
+	log       *logrus.Logger
+	sandbox   *sandbox.Catalog
+	prewarmer *runtimecache.Prewarmer
+	imageScan *imagescan.Gate
+}
+
+// NewExecutor creates a Kubernetes executor from the container settings
+func NewExecutor(cfg config.ContainerConfig, apiClient *api.Client, log *logrus.Logger) (*Executor, error) {
+	kubeClient, err := newClient(cfg.Kubernetes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client: %w", err)
+	}
+
+	// Egress labels are only applied to Docker job networks, so pods never
+	// run under profiles with egress rules
+	sandboxCfg := cfg
+	sandboxCfg.Sandbox.EgressEnforced = false
+	profiles, err := sandbox.NewCatalog(sandboxCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sandbox profiles: %w", err)
+	}
+
+	return &Executor{
+		cfg:       cfg,
+		kube:      cfg.Kubernetes,
+		client:    kubeClient,
+		apiClient: apiClient,
+		log:       log,
+		sandbox:   profiles,
+		imageScan: imagescan.New(cfg.ImageScan, log),
+	}, nil
+}
+
+// WithPrewarmer enables pushing execution data into the runtime cache before
+// the pod is created
+func (e *Executor) WithPrewarmer(p *runtimecache.Prewarmer) {
+	e.prewarmer = p
+}
+
+// Type returns the executor type
+func (e *Executor) Type() types.JobType {
+	return types.JobTypeContainer
+}
+
+// Sandbox returns the sandbox profile catalog
+func (e *Executor) Sandbox() *sandbox.Catalog {
+	return e.sandbox
+}
+
+// Validate checks if the job can be executed
+func (e *Executor) Validate(job *types.Job) error {
+	if job.Execution.Script == nil {
+		return cerrors.NewValidationError("script", "required", "container job missing script configuration")
+	}
+
+	switch job.Execution.Script.Type {
+	case types.ScriptTypeBash, types.ScriptTypePython, types.ScriptTypeNode:
+	default:
+		return cerrors.NewValidationError("scriptType", "enum",
+			fmt.Sprintf("unsupported script type: %s", job.Execution.Script.Type))
+	}
+
+	if job.Execution.DryRun {
+		return cerrors.NewValidationError("dryRun", "unsupported", "dry runs are only supported for SSH and Docker container jobs")
+	}
+
+	if _, err := e.sandbox.Resolve(job); err != nil {
+		return err
+	}
+	if err := container.ValidateWritablePaths(job); err != nil {
+		return err
+	}
+
+	return container.ValidateStopSettings(job)
+}
+
+// Cleanup has nothing to release; each execution removes its resources
+// when it ends
+func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
+	return nil
+}
+
+// Execute runs the job in a pod and streams its logs
+func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	profile, err := e.sandbox.Resolve(job)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(chan types.ExecutionUpdate, 100)
+	go e.run(ctx, job, profile, updates)
+	return updates, nil
+}
+
+// run creates the execution's resources, follows the pod to completion and
+// reports the outcome
+func (e *Executor) run(ctx context.Context, job *types.Job, profile *sandbox.Profile, updates chan<- types.ExecutionUpdate) {
+	defer close(updates)
+
+	executionID := fmt.Sprintf("exec_%s_%d", job.ID, time.Now().Unix())
+	name := resourceName(executionID)
+	log := e.log.WithFields(logrus.Fields{"jobID": job.ID, "pod": name})
+
+	if e.apiClient != nil {
+		if err := e.apiClient.CreateExecution(ctx, executionID, job, nil, nil); err != nil {
+			log.WithError(err).Warn("Failed to create execution record")
+		}
+	}
+	startedAt := time.Now()
+	e.updateExecution(executionID, types.JobStatusRunning, &api.ExecutionStatusUpdate{StartedAt: &startedAt})
+
+	// The image is pulled on the node, so the scan cache keys on its
+	// reference
+	image := container.ImageForScript(e.cfg, job.Execution.Script.Type)
+	verdict, err := e.imageScan.Check(ctx, job, image, "")
+	if err != nil {
+		e.fail(updates, executionID, err)
+		return
+	}
+
+	defer e.deleteResources(name, job)
+
+	if err := e.createResources(ctx, name, executionID, job, profile); err != nil {
+		e.fail(updates, executionID, types.NewExecutionError("kubernetes", "POD_CREATE_FAILED",
+			fmt.Sprintf("failed to create pod: %v", err), true))
+		return
+	}
+	log.WithField("sandboxProfile", profile.Name).Info("Created job pod")
+	e.send(updates, types.UpdateTypeStatus, types.NewStatusUpdate(types.JobStatusRunning,
+		fmt.Sprintf("Created pod %s/%s", e.kube.Namespace, name)))
+
+	if err := e.waitForStart(ctx, name); err != nil {
+		if ctx.Err() != nil {
+			e.stopped(ctx, updates, executionID)
+			return
+		}
+		e.fail(updates, executionID, err)
+		return
+	}
+
+	output := e.streamLogs(ctx, name, updates, log)
+
+	terminated, err := e.waitForExit(ctx, name)
+	if ctx.Err() != nil {
+		e.stopped(ctx, updates, executionID)
+		return
+	}
+	if err != nil {
+		e.fail(updates, executionID, err)
+		return
+	}
+
+	violations := e.rootfsViolations(job, profile, output, updates)
+
+	exitCode := int(terminated.ExitCode)
+	status := types.JobStatusCompleted
+	message := "Job completed successfully"
+	if exitCode != 0 {
+		status = types.JobStatusFailed
+		message = fmt.Sprintf("Job failed with exit code %d", exitCode)
+		if terminated.Reason == "OOMKilled" {
+			message = "Job was killed for exceeding its memory limit"
+		}
+	}
+	e.send(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   status,
+		Message:  message,
+		ExitCode: &exitCode,
+	})
+
+	completedAt := time.Now()
+	record := &api.ExecutionStatusUpdate{
+		CompletedAt: &completedAt,
+		ExitCode:    &exitCode,
+		Output:      &output,
+		ExecutionMetadata: map[string]interface{}{
+			"executionType":  "kubernetes",
+			"namespace":      e.kube.Namespace,
+			"pod":            name,
+			"sandboxProfile": profile.Name,
+		},
+	}
+	if verdict != nil {
+		record.ExecutionMetadata["imageScan"] = verdict
+	}
+	if len(violations) > 0 {
+		record.ExecutionMetadata["rootfsViolations"] = violations
+	}
+	if status == types.JobStatusFailed {
+		record.Error = &message
+	}
+	e.updateExecution(executionID, status, record)
+}
+
+// rootfsViolations returns the writes a job's output shows its read-only
+// root filesystem refused, and warns about them in the job's output
+func (e *Executor) rootfsViolations(job *types.Job, profile *sandbox.Profile, output string, updates chan<- types.ExecutionUpdate) []string {
+	image := container.ImageForScript(e.cfg, job.Execution.Script.Type)
+	if !container.ReadOnlyRootfs(e.cfg, profile, image) {
+		return nil
+	}
+	violations := container.RootfsViolations(output)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	e.log.WithFields(logrus.Fields{
+		"jobID":      job.ID,
+		"violations": violations,
+	}).Warn("Job wrote to its root filesystem")
+	e.send(updates, types.UpdateTypeError, &types.StatusUpdate{
+		Status: types.JobStatusRunning,
+		Message: fmt.Sprintf("job tried to write to its read-only root filesystem at %s; declare writable paths in execution.writablePaths",
+			strings.Join(violations, ", ")),
+	})
+	return violations
+}
+
+// createResources creates the config map, secret and pod of an execution
+func (e *Executor) createResources(ctx context.Context, name, executionID string, job *types.Job, profile *sandbox.Profile) error {
+	token, err := container.ExecutionToken(job, e.cfg.Runtime.JWTSecret)
+	if err != nil {
+		return fmt.Errorf("failed to generate execution token: %w", err)
+	}
+
+	// Seed the runtime cache so the first helper calls are hits
+	prewarmCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	if err := e.prewarmer.Prewarm(prewarmCtx, job.ID, job); err != nil {
+		e.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to pre-warm runtime cache")
+	}
+	cancel()
+
+	core := e.client.CoreV1()
+	if _, err := core.ConfigMaps(e.kube.Namespace).Create(ctx, e.buildConfigMap(name, job), metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	if _, err := core.Secrets(e.kube.Namespace).Create(ctx, e.buildSecret(name, token, job), metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	_, err = core.Pods(e.kube.Namespace).Create(ctx, e.buildPod(name, executionID, job, profile), metav1.CreateOptions{})
+	return err
+}
+
+// deleteResources removes an execution's pod, secret and config map. The
+// pod gets the job's grace period to stop.
+func (e *Executor) deleteResources(name string, job *types.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	core := e.client.CoreV1()
+	grace := int64(e.gracePeriod(job).Seconds())
+	background := metav1.DeletePropagationBackground
+	podOpts := metav1.DeleteOptions{GracePeriodSeconds: &grace, PropagationPolicy: &background}
+	opts := metav1.DeleteOptions{PropagationPolicy: &background}
+
+	deletes := []struct {
+		resource string
+		delete   func() error
+	}{
+		{"pods", func() error { return core.Pods(e.kube.Namespace).Delete(ctx, name, podOpts) }},
+		{"secrets", func() error { return core.Secrets(e.kube.Namespace).Delete(ctx, name, opts) }},
+		{"configmaps", func() error { return core.ConfigMaps(e.kube.Namespace).Delete(ctx, name, opts) }},
+	}
+	for _, d := range deletes {
+		// A resource that is already gone is not an error
+		if err := d.delete(); err != nil && !apierrors.IsNotFound(err) {
+			e.log.WithError(err).WithFields(logrus.Fields{
+				"jobID":    job.ID,
+				"resource": d.resource,
+				"name":     name,
+			}).Warn("Failed to delete Kubernetes resource")
+		}
+	}
+}
+
+// waitForStart waits until the job container is running or has exited. It
+// fails early when a container cannot start and after the start timeout.
+func (e *Executor) waitForStart(ctx context.Context, name string) error {
+	deadline := time.Now().Add(e.kube.StartTimeout)
+	ticker := time.NewTicker(e.kube.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		p, err := e.client.CoreV1().Pods(e.kube.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil && ctx.Err() == nil {
+			e.log.WithError(err).WithField("pod", name).Debug("Failed to get pod")
+		}
+		if err == nil {
+			if state := containerStateOf(p.Status.ContainerStatuses, jobContainer); state != nil && (state.Running != nil || state.Terminated != nil) {
+				return nil
+			}
+			for _, s := range append(p.Status.InitContainerStatuses, p.Status.ContainerStatuses...) {
+				if w := s.State.Waiting; w != nil && fatalWaitReasons[w.Reason] {
+					return types.NewExecutionError("kubernetes", "CONTAINER_START_FAILED",
+						fmt.Sprintf("container %s cannot start: %s: %s", s.Name, w.Reason, w.Message), false)
+				}
+			}
+			if p.Status.Phase == corev1.PodFailed {
+				return types.NewExecutionError("kubernetes", "POD_FAILED",
+					fmt.Sprintf("pod failed before the job started: %s %s", p.Status.Reason, p.Status.Message), true)
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return types.NewExecutionError("kubernetes", "POD_START_TIMEOUT",
+				fmt.Sprintf("pod did not start within %s", e.kube.StartTimeout), true)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitForExit waits until the job container has exited
+func (e *Executor) waitForExit(ctx context.Context, name string) (*corev1.ContainerStateTerminated, error) {
+	ticker := time.NewTicker(e.kube.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		p, err := e.client.CoreV1().Pods(e.kube.Namespace).Get(ctx, name, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			return nil, types.NewExecutionError("kubernetes", "POD_DELETED", "pod was deleted before the job finished", true)
+		case err != nil:
+			if ctx.Err() == nil {
+				e.log.WithError(err).WithField("pod", name).Debug("Failed to get pod")
+			}
+		default:
+			if state := containerStateOf(p.Status.ContainerStatuses, jobContainer); state != nil && state.Terminated != nil {
+				return state.Terminated, nil
+			}
+			if p.Status.Phase == corev1.PodFailed {
+				return nil, types.NewExecutionError("kubernetes", "POD_FAILED",
+					fmt.Sprintf("pod failed: %s %s", p.Status.Reason, p.Status.Message), false)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// streamLogs sends the job container's log lines as updates until it exits
+// and returns the output kept for the execution record. The API server
+// merges stdout and stderr into one stream.
+func (e *Executor) streamLogs(ctx context.Context, name string, updates chan<- types.ExecutionUpdate, log *logrus.Entry) string {
+	logs, err := e.client.CoreV1().Pods(e.kube.Namespace).GetLogs(name, &corev1.PodLogOptions{
+		Container: jobContainer,
+		Follow:    true,
+	}).Stream(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.WithError(err).Warn("Failed to stream pod logs")
+		}
+		return ""
+	}
+	defer logs.Close()
+
+	var output strings.Builder
+	var sequence int64
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 4096), maxLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+		sequence++
+		e.send(updates, types.UpdateTypeLog, types.NewLogEntry("stdout", line, sequence))
+		if output.Len()+len(line) < maxOutput {
+			output.WriteString(line)
+			output.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		log.WithError(err).Warn("Pod log stream ended early")
+	}
+	return output.String()
+}
+
+// stopped reports an execution that timed out or was cancelled; the
+// deferred cleanup stops the pod
+func (e *Executor) stopped(ctx context.Context, updates chan<- types.ExecutionUpdate, executionID string) {
+	exitCode, status, message := -2, types.JobStatusCancelled, "Job cancelled"
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		exitCode, status, message = -1, types.JobStatusTimeout, "Job timed out"
+	}
+	e.send(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   types.JobStatusFailed,
+		Message:  message,
+		ExitCode: &exitCode,
+	})
+
+	completedAt := time.Now()
+	e.updateExecution(executionID, status, &api.ExecutionStatusUpdate{
+		CompletedAt: &completedAt,
+		ExitCode:    &exitCode,
+	})
+}
+
+// fail sends an error and a failed completion and records the failure
+func (e *Executor) fail(updates chan<- types.ExecutionUpdate, executionID string, err error) {
+	message := err.Error()
+	e.send(updates, types.UpdateTypeError, &types.StatusUpdate{
+		Status:  types.JobStatusFailed,
+		Message: message,
+		Error:   types.ErrorDetailsFromError(err),
+	})
+	exitCode := 1
+	e.send(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   types.JobStatusFailed,
+		Message:  message,
+		ExitCode: &exitCode,
+	})
+
+	completedAt := time.Now()
+	e.updateExecution(executionID, types.JobStatusFailed, &api.ExecutionStatusUpdate{
+		CompletedAt: &completedAt,
+		ExitCode:    &exitCode,
+		Error:       &message,
+	})
+}
+
+// send delivers an update
+func (e *Executor) send(updates chan<- types.ExecutionUpdate, updateType types.UpdateType, data interface{}) {
+	updates <- types.ExecutionUpdate{
+		Type:      updateType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// updateExecution records the execution's status in the backend
+func (e *Executor) updateExecution(executionID string, status types.JobStatus, details *api.ExecutionStatusUpdate) {
+	if e.apiClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.apiClient.UpdateExecution(ctx, executionID, status, details); err != nil {
+		e.log.WithError(err).WithField("executionID", executionID).Warn("Failed to update execution")
+	}
+}
+
+// gracePeriod returns how long the job container has to stop after SIGTERM,
+// capped at the configured maximum. Pods are always stopped with SIGTERM.
+func (e *Executor) gracePeriod(job *types.Job) time.Duration {
+	grace := e.cfg.Stop.DefaultGracePeriod
+	if job.Execution.TerminationGracePeriod > 0 {
+		grace = job.Execution.TerminationGracePeriod
+		if e.cfg.Stop.MaxGracePeriod > 0 && grace > e.cfg.Stop.MaxGracePeriod {
+			grace = e.cfg.Stop.MaxGracePeriod
+		}
+	}
+	return grace
+}
+
+// containerStateOf returns the state of the named container
+func containerStateOf(statuses []corev1.ContainerStatus, name string) *corev1.ContainerState {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i].State
+		}
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeCluster is an API server that keeps created objects and runs pods by
+// reporting the job container as exited after the first status poll
+type fakeCluster struct {
+	t        *testing.T
+	mu       sync.Mutex
+	objects  map[string]json.RawMessage
+	pods     []json.RawMessage
+	deleted  []string
+	polls    int
+	waiting  string
+	exitCode int32
+	logs     string
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	assert.Equal(c.t, "Bearer sa-token", r.Header.Get("Authorization"))
+	w.Header().Set("Content-Type", "application/json")
+
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/jobs/")
+	switch {
+	case r.Method == http.MethodPost:
+		var obj struct {
+			Metadata metav1.ObjectMeta `json:"metadata"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		require.NoError(c.t, json.Unmarshal(data, &obj))
+		c.objects[path+"/"+obj.Metadata.Name] = data
+		if path == "pods" {
+			c.pods = append(c.pods, data)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(data)
+
+	case r.Method == http.MethodDelete:
+		if _, ok := c.objects[path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(metav1.Status{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonNotFound,
+				Message:  path + " not found",
+				Code:     http.StatusNotFound,
+			})
+			return
+		}
+		delete(c.objects, path)
+		c.deleted = append(c.deleted, path)
+		w.Write([]byte(`{}`))
+
+	case strings.HasSuffix(path, "/log"):
+		assert.Equal(c.t, "job", r.URL.Query().Get("container"))
+		assert.Equal(c.t, "true", r.URL.Query().Get("follow"))
+		w.Write([]byte(c.logs))
+
+	default:
+		if _, ok := c.objects[path]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		c.polls++
+		state := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}
+		switch {
+		case c.waiting != "":
+			state.Waiting = &corev1.ContainerStateWaiting{Reason: c.waiting, Message: "back-off pulling image"}
+		case c.polls > 1:
+			state = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: c.exitCode}}
+		}
+		json.NewEncoder(w).Encode(corev1.Pod{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: jobContainer, State: state}},
+			},
+		})
+	}
+}
+
+func newTestExecutor(t *testing.T, cluster *fakeCluster) *Executor {
+	cluster.t = t
+	cluster.objects = make(map[string]json.RawMessage)
+	server := httptest.NewServer(cluster)
+	t.Cleanup(server.Close)
+
+	tokenFile := t.TempDir() + "/token"
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sa-token\n"), 0o600))
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	e, err := NewExecutor(config.ContainerConfig{
+		Images:    map[string]string{"python": "python:3.12-slim"},
+		Resources: config.ResourceConfig{Defaults: config.ResourceLimits{CPU: 1, Memory: "512MB", Pids: 100}},
+		Security:  config.ContainerSecurityConfig{User: "1000:1000", NoNewPrivileges: true, DropCapabilities: []string{"ALL"}},
+		Runtime:   config.RuntimeConfig{JWTSecret: "secret", Image: "cronium/runtime-api:test"},
+		Stop:      config.ContainerStopConfig{DefaultGracePeriod: 10 * time.Second, MaxGracePeriod: time.Minute},
+		Sandbox:   config.SandboxConfig{AllowedProfiles: []string{"strict", "standard"}},
+		Kubernetes: config.KubernetesConfig{
+			Enabled:      true,
+			APIServer:    server.URL,
+			Namespace:    "jobs",
+			TokenFile:    tokenFile,
+			StartTimeout: time.Second,
+			PollInterval: time.Millisecond,
+		},
+	}, nil, log)
+	require.NoError(t, err)
+	return e
+}
+
+func scriptJob() *types.Job {
+	return &types.Job{
+		ID:   "job_42",
+		Type: types.JobTypeContainer,
+		Execution: types.ExecutionConfig{
+			Script:      &types.Script{Type: types.ScriptTypePython, Content: "print('hi')"},
+			Environment: map[string]string{"API_KEY": "k"},
+			Timeout:     time.Minute,
+		},
+		Metadata: map[string]interface{}{"userId": "user_1"},
+	}
+}
+
+// collect runs a job and returns its log lines and final status update
+func collect(t *testing.T, e *Executor, job *types.Job) ([]string, *types.StatusUpdate) {
+	require.NoError(t, e.Validate(job))
+	updates, err := e.Execute(context.Background(), job)
+	require.NoError(t, err)
+
+	var lines []string
+	var final *types.StatusUpdate
+	for update := range updates {
+		switch update.Type {
+		case types.UpdateTypeLog:
+			lines = append(lines, update.Data.(*types.LogEntry).Line)
+		case types.UpdateTypeComplete:
+			final = update.Data.(*types.StatusUpdate)
+		}
+	}
+	require.NotNil(t, final)
+	return lines, final
+}
+
+func TestRunsJobAsPod(t *testing.T) {
+	cluster := &fakeCluster{exitCode: 3, logs: "hello\nworld\n"}
+	e := newTestExecutor(t, cluster)
+
+	lines, final := collect(t, e, scriptJob())
+	assert.Equal(t, []string{"hello", "world"}, lines)
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+	assert.Equal(t, 3, *final.ExitCode)
+
+	require.Len(t, cluster.pods, 1)
+	var created corev1.Pod
+	require.NoError(t, json.Unmarshal(cluster.pods[0], &created))
+	name := created.Name
+	assert.True(t, strings.HasPrefix(name, "cronium-exec-job-42-"), name)
+	assert.Equal(t, "job_42", created.Labels["cronium.job.id"])
+
+	// The runtime API runs as a native sidecar
+	require.Len(t, created.Spec.InitContainers, 1)
+	sidecar := created.Spec.InitContainers[0]
+	assert.Equal(t, corev1.ContainerRestartPolicyAlways, *sidecar.RestartPolicy)
+	assert.Equal(t, "cronium/runtime-api:test", sidecar.Image)
+
+	job := created.Spec.Containers[0]
+	assert.Equal(t, "python:3.12-slim", job.Image)
+	assert.Equal(t, []string{"python", "/cronium/script/script"}, job.Command)
+	assert.Equal(t, map[string]string{"cpu": "1", "memory": "512Mi"}, limits(job))
+	assert.False(t, *job.SecurityContext.AllowPrivilegeEscalation)
+	assert.Equal(t, []corev1.Capability{"ALL"}, job.SecurityContext.Capabilities.Drop)
+	assert.Equal(t, int64(1000), *created.Spec.SecurityContext.RunAsUser)
+	assert.Equal(t, int64(10), *created.Spec.TerminationGracePeriodSeconds)
+
+	// Credentials come from the execution's secret, not the pod spec
+	assert.Contains(t, job.Env, secretEnv("API_KEY", name, "env.API_KEY"))
+	assert.Contains(t, job.Env, secretEnv("CRONIUM_EXECUTION_TOKEN", name, "token"))
+
+	assert.ElementsMatch(t, []string{"pods/" + name, "secrets/" + name, "configmaps/" + name}, cluster.deleted)
+	assert.Empty(t, cluster.objects)
+}
+
+func TestImagePullFailureFailsJob(t *testing.T) {
+	cluster := &fakeCluster{waiting: "ImagePullBackOff"}
+	e := newTestExecutor(t, cluster)
+
+	_, final := collect(t, e, scriptJob())
+	assert.Equal(t, types.JobStatusFailed, final.Status)
+	assert.Contains(t, final.Message, "ImagePullBackOff")
+	assert.Len(t, cluster.deleted, 3)
+}
+
+func TestStrictProfileClampsResources(t *testing.T) {
+	e := newTestExecutor(t, &fakeCluster{})
+	job := scriptJob()
+	job.Execution.SandboxProfile = "strict"
+	job.Execution.Resources = &types.Resources{CPULimit: 4, MemoryLimit: 4 << 30}
+
+	profile, err := e.sandbox.Resolve(job)
+	require.NoError(t, err)
+	p := e.buildPod("cronium-test", "exec_1", job, profile)
+
+	c := p.Spec.Containers[0]
+	assert.Equal(t, map[string]string{"cpu": "500m", "memory": "256Mi"}, limits(c))
+	assert.True(t, *c.SecurityContext.ReadOnlyRootFilesystem)
+	assert.Equal(t, corev1.SeccompProfileTypeRuntimeDefault, p.Spec.SecurityContext.SeccompProfile.Type)
+}
+
+// limits returns a container's limits in their canonical form
+func limits(c corev1.Container) map[string]string {
+	out := map[string]string{}
+	for name, q := range c.Resources.Limits {
+		out[string(name)] = q.String()
+	}
+	return out
+}
+
+func TestResourceName(t *testing.T) {
+	assert.Equal(t, "cronium-exec-job-1-1700000000", resourceName("exec_job_1_1700000000"))
+	long := resourceName("exec_" + strings.Repeat("x", 100) + "_1")
+	assert.LessOrEqual(t, len(long), 63)
+}
+
+func TestReadOnlyRootfs(t *testing.T) {
+	cluster := &fakeCluster{exitCode: 1, logs: "touch: cannot touch '/var/cache/app/x': Read-only file system\n"}
+	e := newTestExecutor(t, cluster)
+	job := scriptJob()
+	job.Execution.SandboxProfile = "strict"
+	job.Execution.WritablePaths = []types.WritablePath{{Path: "/var/lib/app", Type: "volume"}, {Path: "/home/app"}}
+
+	require.NoError(t, e.Validate(job))
+	updates, err := e.Execute(context.Background(), job)
+	require.NoError(t, err)
+	var warnings []string
+	for update := range updates {
+		if update.Type == types.UpdateTypeError {
+			warnings = append(warnings, update.Data.(*types.StatusUpdate).Message)
+		}
+	}
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "/var/cache/app/x")
+
+	var created corev1.Pod
+	require.NoError(t, json.Unmarshal(cluster.pods[0], &created))
+	c := created.Spec.Containers[0]
+	assert.True(t, *c.SecurityContext.ReadOnlyRootFilesystem)
+	assert.Contains(t, c.VolumeMounts, corev1.VolumeMount{Name: "writable-0", MountPath: "/var/lib/app"})
+	assert.Contains(t, c.VolumeMounts, corev1.VolumeMount{Name: "writable-1", MountPath: "/home/app"})
+	var tmpfs *corev1.EmptyDirVolumeSource
+	for _, v := range created.Spec.Volumes {
+		if v.Name == "writable-1" {
+			tmpfs = v.EmptyDir
+		}
+	}
+	require.NotNil(t, tmpfs)
+	assert.Equal(t, corev1.StorageMediumMemory, tmpfs.Medium)
+	assert.Equal(t, "100Mi", tmpfs.SizeLimit.String())
+
+	// Images listed for compatibility keep a writable root filesystem
+	e.cfg.Security.WritableRootfsImages = []string{"python:3.12*"}
+	profile, err := e.sandbox.Resolve(job)
+	require.NoError(t, err)
+	p := e.buildPod("cronium-test", "exec_1", job, profile)
+	assert.False(t, *p.Spec.Containers[0].SecurityContext.ReadOnlyRootFilesystem)
+
+	for _, path := range []string{"/tmp/x", "/", "relative", "/var/../etc", "/proc/self"} {
+		job.Execution.WritablePaths = []types.WritablePath{{Path: path}}
+		assert.Error(t, e.Validate(job), path)
+	}
+}
@@ -0,0 +1,367 @@
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	jobContainer     = "job"
+	sidecarContainer = "runtime-api"
+
+	// The sidecar serves the helper protocol on a Unix socket in a volume
+	// both containers mount, as it does for Docker jobs
+	helperSocketDir  = "/run/cronium"
+	helperSocketPath = helperSocketDir + "/helper.sock"
+
+	// The script is mounted from the execution's config map rather than
+	// passed on the command line, which would put it in the pod spec
+	scriptDir  = "/cronium/script"
+	scriptKey  = "script"
+	sidecarUID = int64(1000)
+)
+
+var (
+	invalidNameChars  = regexp.MustCompile(`[^a-z0-9-]+`)
+	invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// resourceName returns the name shared by an execution's pod, secret and
+// config map: a DNS label derived from the execution ID
+func resourceName(executionID string) string {
+	name := "cronium-" + invalidNameChars.ReplaceAllString(strings.ToLower(executionID), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// labelValue makes s a valid label value
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-._")
+}
+
+// labels returns the labels of an execution's resources. Network policies
+// can select job pods by cronium.type.
+func labels(job *types.Job) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "cronium-orchestrator",
+		"cronium.managed":              "true",
+		"cronium.type":                 "job",
+		"cronium.job.id":               labelValue(job.ID),
+	}
+}
+
+// buildConfigMap holds the job's script
+func (e *Executor) buildConfigMap(name string, job *types.Job) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: e.objectMeta(name, job),
+		Data:       map[string]string{scriptKey: job.Execution.Script.Content},
+	}
+}
+
+// buildSecret holds the execution token, the runtime credentials and the
+// job's environment, which may carry secrets of its own
+func (e *Executor) buildSecret(name, token string, job *types.Job) *corev1.Secret {
+	data := map[string]string{
+		"token":         token,
+		"jwt-secret":    e.cfg.Runtime.JWTSecret,
+		"backend-token": os.Getenv("CRONIUM_API_TOKEN"),
+	}
+	for k, v := range job.Execution.Environment {
+		data["env."+k] = v
+	}
+	return &corev1.Secret{
+		ObjectMeta: e.objectMeta(name, job),
+		Type:       corev1.SecretTypeOpaque,
+		StringData: data,
+	}
+}
+
+// buildPod builds the job pod: the job container and the runtime API as a
+// native sidecar, which Kubernetes starts first and stops once the job
+// container exits
+func (e *Executor) buildPod(name, executionID string, job *types.Job, profile *sandbox.Profile) *corev1.Pod {
+	grace := int64(e.gracePeriod(job).Seconds())
+	deadline := int64((job.GetTimeout() + e.kube.StartTimeout).Seconds())
+	uid, gid := parseUser(e.cfg.Security.User)
+
+	spec := corev1.PodSpec{
+		RestartPolicy:                 corev1.RestartPolicyNever,
+		ServiceAccountName:            e.kube.ServiceAccount,
+		AutomountServiceAccountToken:  boolPtr(false),
+		EnableServiceLinks:            boolPtr(false),
+		NodeSelector:                  e.kube.NodeSelector,
+		ActiveDeadlineSeconds:         &deadline,
+		TerminationGracePeriodSeconds: &grace,
+		SecurityContext: &corev1.PodSecurityContext{
+			RunAsUser:      uid,
+			RunAsGroup:     gid,
+			FSGroup:        gid,
+			SeccompProfile: seccomp(profile.SeccompProfile),
+		},
+		InitContainers: []corev1.Container{e.buildSidecar(name, job)},
+		Containers:     []corev1.Container{e.buildJobContainer(name, executionID, job, profile)},
+		Volumes: []corev1.Volume{
+			emptyDirVolume("helper", nil),
+			{Name: "script", VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+			}},
+			emptyDirVolume("workspace", nil),
+			emptyDirVolume("tmp", nil),
+			emptyDirVolume("runtime-tmp", &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: quantityPtr(resource.MustParse("64Mi")),
+			}),
+		},
+	}
+	// Declared writable paths; tmpfs paths are kept in memory
+	for i, p := range job.Execution.WritablePaths {
+		source := &corev1.EmptyDirVolumeSource{SizeLimit: resource.NewQuantity(container.WritableSize(p), resource.BinarySI)}
+		if p.Type != "volume" {
+			source.Medium = corev1.StorageMediumMemory
+		}
+		spec.Volumes = append(spec.Volumes, emptyDirVolume(fmt.Sprintf("writable-%d", i), source))
+	}
+	if e.kube.ImagePullSecret != "" {
+		spec.ImagePullSecrets = []corev1.LocalObjectReference{{Name: e.kube.ImagePullSecret}}
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: e.objectMeta(name, job),
+		Spec:       spec,
+	}
+}
+
+// buildJobContainer builds the container that runs the script under the
+// job's sandbox profile
+func (e *Executor) buildJobContainer(name, executionID string, job *types.Job, profile *sandbox.Profile) corev1.Container {
+	script := scriptDir + "/" + scriptKey
+	var command []string
+	switch job.Execution.Script.Type {
+	case types.ScriptTypePython:
+		command = []string{"python", script}
+	case types.ScriptTypeNode:
+		command = []string{"node", script}
+	default:
+		command = []string{"/bin/bash", script}
+	}
+
+	env := []corev1.EnvVar{}
+	keys := make([]string, 0, len(job.Execution.Environment))
+	for k := range job.Execution.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, secretEnv(k, name, "env."+k))
+	}
+	env = append(env,
+		corev1.EnvVar{Name: "CRONIUM_JOB_ID", Value: job.ID},
+		corev1.EnvVar{Name: "CRONIUM_JOB_TYPE", Value: string(job.Type)},
+		corev1.EnvVar{Name: "CRONIUM_EXECUTION_MODE", Value: "container"},
+		corev1.EnvVar{Name: "CRONIUM_EXECUTION_ID", Value: executionID},
+		secretEnv("CRONIUM_EXECUTION_TOKEN", name, "token"),
+		corev1.EnvVar{Name: "CRONIUM_RUNTIME_API", Value: "http://localhost:8081"},
+		corev1.EnvVar{Name: "CRONIUM_HELPER_SOCKET", Value: helperSocketPath},
+		corev1.EnvVar{Name: "CRONIUM_CANCEL_GRACE_PERIOD", Value: strconv.Itoa(int(e.gracePeriod(job).Seconds()))},
+	)
+	for _, kv := range job.RetryEnvironment() {
+		k, v, _ := strings.Cut(kv, "=")
+		env = append(env, corev1.EnvVar{Name: k, Value: v})
+	}
+
+	mounts := []corev1.VolumeMount{
+		{Name: "helper", MountPath: helperSocketDir},
+		{Name: "script", MountPath: scriptDir, ReadOnly: true},
+		{Name: "workspace", MountPath: "/workspace"},
+		{Name: "tmp", MountPath: "/tmp"},
+	}
+	for i, p := range job.Execution.WritablePaths {
+		mounts = append(mounts, corev1.VolumeMount{Name: fmt.Sprintf("writable-%d", i), MountPath: p.Path})
+	}
+
+	image := container.ImageForScript(e.cfg, job.Execution.Script.Type)
+	return corev1.Container{
+		Name:            jobContainer,
+		Image:           image,
+		ImagePullPolicy: corev1.PullPolicy(container.PullPolicy(e.cfg.Pull, job)),
+		Command:         command,
+		WorkingDir:      "/workspace",
+		Env:             env,
+		Resources:       e.buildResources(job, profile),
+		SecurityContext: &corev1.SecurityContext{
+			AllowPrivilegeEscalation: boolPtr(!profile.NoNewPrivileges),
+			ReadOnlyRootFilesystem:   boolPtr(container.ReadOnlyRootfs(e.cfg, profile, image)),
+			Capabilities: &corev1.Capabilities{
+				Drop: capabilityNames(profile.DropCapabilities),
+				Add:  capabilityNames(profile.AddCapabilities),
+			},
+		},
+		VolumeMounts: mounts,
+	}
+}
+
+// buildSidecar builds the runtime API sidecar with the same limits and
+// hardening as the Docker sidecar. The job container starts once its
+// health check passes.
+func (e *Executor) buildSidecar(name string, job *types.Job) corev1.Container {
+	uid := sidecarUID
+	always := corev1.ContainerRestartPolicyAlways
+	return corev1.Container{
+		Name:          sidecarContainer,
+		Image:         e.runtimeImage(),
+		RestartPolicy: &always,
+		Env: []corev1.EnvVar{
+			{Name: "EXECUTION_ID", Value: job.ID},
+			secretEnv("JWT_SECRET", name, "jwt-secret"),
+			{Name: "BACKEND_URL", Value: e.cfg.Runtime.BackendURL},
+			secretEnv("BACKEND_TOKEN", name, "backend-token"),
+			{Name: "VALKEY_URL", Value: e.cfg.Runtime.ValkeyURL},
+			{Name: "PORT", Value: "8081"},
+			{Name: "SOCKET_PATH", Value: helperSocketPath},
+			{Name: "LOG_LEVEL", Value: "info"},
+		},
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("256Mi"),
+			},
+		},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsUser:                &uid,
+			RunAsGroup:               &uid,
+			AllowPrivilegeEscalation: boolPtr(false),
+			ReadOnlyRootFilesystem:   boolPtr(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "helper", MountPath: helperSocketDir},
+			{Name: "runtime-tmp", MountPath: "/tmp"},
+		},
+		StartupProbe: &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				HTTPGet: &corev1.HTTPGetAction{Path: "/health", Port: intstr.FromInt32(8081)},
+			},
+			PeriodSeconds:    1,
+			FailureThreshold: 30,
+		},
+	}
+}
+
+// buildResources converts the job's limits, or the defaults, to container
+// limits clamped to the sandbox profile's ceilings. Kubernetes has no
+// per-container pid limit; the kubelet's podPidsLimit applies instead.
+func (e *Executor) buildResources(job *types.Job, profile *sandbox.Profile) corev1.ResourceRequirements {
+	cpu := e.cfg.Resources.Defaults.CPU
+	memory, _ := container.ParseMemory(e.cfg.Resources.Defaults.Memory)
+	if r := job.Execution.Resources; r != nil {
+		cpu, memory = r.CPULimit, r.MemoryLimit
+	}
+
+	if profile.MaxCPU > 0 && (cpu <= 0 || cpu > profile.MaxCPU) {
+		cpu = profile.MaxCPU
+	}
+	if maxMemory, err := container.ParseMemory(profile.MaxMemory); err == nil && maxMemory > 0 && (memory <= 0 || memory > maxMemory) {
+		memory = maxMemory
+	}
+
+	limits := corev1.ResourceList{}
+	if cpu > 0 {
+		limits[corev1.ResourceCPU] = *resource.NewMilliQuantity(int64(cpu*1000), resource.DecimalSI)
+	}
+	if memory > 0 {
+		limits[corev1.ResourceMemory] = *resource.NewQuantity(memory, resource.BinarySI)
+	}
+	return corev1.ResourceRequirements{Limits: limits}
+}
+
+// runtimeImage returns the runtime API sidecar image
+func (e *Executor) runtimeImage() string {
+	if e.cfg.Runtime.Image != "" {
+		return e.cfg.Runtime.Image
+	}
+	return "cronium/runtime-api:latest"
+}
+
+// objectMeta returns the metadata shared by an execution's resources
+func (e *Executor) objectMeta(name string, job *types.Job) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: name, Namespace: e.kube.Namespace, Labels: labels(job)}
+}
+
+// emptyDirVolume is a scratch volume; source may be nil for the defaults
+func emptyDirVolume(name string, source *corev1.EmptyDirVolumeSource) corev1.Volume {
+	if source == nil {
+		source = &corev1.EmptyDirVolumeSource{}
+	}
+	return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{EmptyDir: source}}
+}
+
+// secretEnv is a variable read from the execution's secret
+func secretEnv(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+		Key:                  key,
+	}}}
+}
+
+// seccomp maps a sandbox seccomp setting to a pod seccomp profile. Custom
+// profiles are paths relative to the kubelet's seccomp directory.
+func seccomp(profile string) *corev1.SeccompProfile {
+	switch profile {
+	case "", "default":
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+	case "unconfined":
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+	default:
+		return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &profile}
+	}
+}
+
+// capabilityNames strips the CAP_ prefix Docker accepts but Kubernetes does
+// not
+func capabilityNames(caps []string) []corev1.Capability {
+	names := make([]corev1.Capability, 0, len(caps))
+	for _, c := range caps {
+		names = append(names, corev1.Capability(strings.TrimPrefix(strings.ToUpper(c), "CAP_")))
+	}
+	return names
+}
+
+// parseUser parses a Docker "uid[:gid]" user. Names cannot be resolved
+// outside the image, so they leave the image's user in place.
+func parseUser(user string) (*int64, *int64) {
+	uidText, gidText, _ := strings.Cut(user, ":")
+	uid, err := strconv.ParseInt(uidText, 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	if gid, err := strconv.ParseInt(gidText, 10, 64); err == nil {
+		return &uid, &gid
+	}
+	return &uid, nil
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func quantityPtr(q resource.Quantity) *resource.Quantity {
+	return &q
+}
@@ -0,0 +1,414 @@
+package executors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// matrixRunner expands matrix jobs and runs their combinations. The slot
+// semaphore is shared by every matrix job on this orchestrator.
+type matrixRunner struct {
+	maxCombinations int
+	slots           chan struct{}
+
+	mu sync.Mutex
+	// Combinations running now by ID, under the ID of their matrix job
+	running map[string]map[string]*types.Job
+}
+
+// newMatrixRunner creates a runner with the given limits
+func newMatrixRunner(cfg config.MatrixConfig) *matrixRunner {
+	maxParallel := cfg.MaxParallel
+	if maxParallel < 1 {
+		maxParallel = 1
+	}
+	return &matrixRunner{
+		maxCombinations: cfg.MaxCombinations,
+		slots:           make(chan struct{}, maxParallel),
+		running:         make(map[string]map[string]*types.Job),
+	}
+}
+
+// children returns the combinations of a matrix job running now, and false
+// when the job is not a matrix job running on this orchestrator
+func (r *matrixRunner) children(jobID string) ([]*types.Job, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	running, ok := r.running[jobID]
+	if !ok {
+		return nil, false
+	}
+	children := make([]*types.Job, 0, len(running))
+	for _, child := range running {
+		children = append(children, child)
+	}
+	return children, true
+}
+
+// track records a combination as running under its matrix job; a nil child
+// only records the matrix job
+func (r *matrixRunner) track(jobID string, child *types.Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	running, ok := r.running[jobID]
+	if !ok {
+		running = make(map[string]*types.Job)
+		r.running[jobID] = running
+	}
+	if child != nil {
+		running[child.ID] = child
+	}
+}
+
+// untrack removes a finished combination, or with an empty child ID the
+// matrix job itself
+func (r *matrixRunner) untrack(jobID, childID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if childID == "" {
+		delete(r.running, jobID)
+		return
+	}
+	delete(r.running[jobID], childID)
+}
+
+// matrixResult is the outcome of one combination
+type matrixResult struct {
+	Index       int
+	JobID       string
+	Combination types.MatrixCombination
+	Status      types.JobStatus
+	ExitCode    int
+	Error       string
+	Output      any
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// matrixChild is the job prepared for one combination
+type matrixChild struct {
+	job   *types.Job
+	combo types.MatrixCombination
+}
+
+// expand validates the matrix and prepares one child job per combination.
+// Every child is validated up front, as a plain job would be, so a bad
+// combination rejects the whole job before anything runs.
+func (r *matrixRunner) expand(executor Executor, job *types.Job) ([]matrixChild, error) {
+	matrix := job.Execution.Matrix
+	if err := matrix.Validate(); err != nil {
+		return nil, types.NewExecutionError("validation", "INVALID_MATRIX", err.Error(), false)
+	}
+	if size := matrix.Size(); r.maxCombinations > 0 && size > r.maxCombinations {
+		return nil, types.NewExecutionError("validation", "INVALID_MATRIX",
+			fmt.Sprintf("matrix expands to %d combinations, the limit is %d", size, r.maxCombinations), false)
+	}
+
+	combos := matrix.Combinations()
+	if len(combos) == 0 {
+		return nil, types.NewExecutionError("validation", "INVALID_MATRIX", "matrix excludes every combination", false)
+	}
+
+	children := make([]matrixChild, len(combos))
+	for i, combo := range combos {
+		child := job.MatrixChild(i, combo)
+		if err := child.ApplyParameters(); err != nil {
+			return nil, fmt.Errorf("matrix combination %s: %w", combo.Label(), err)
+		}
+		if err := validateSSHOptions(child); err != nil {
+			return nil, fmt.Errorf("matrix combination %s: %w", combo.Label(), err)
+		}
+		if err := executor.Validate(child); err != nil {
+			return nil, fmt.Errorf("matrix combination %s: %w", combo.Label(), err)
+		}
+		children[i] = matrixChild{job: child, combo: combo}
+	}
+	return children, nil
+}
+
+// run executes the children and finishes with an aggregated completion update
+func (r *matrixRunner) run(ctx context.Context, executor Executor, job *types.Job, children []matrixChild) <-chan types.ExecutionUpdate {
+	updates := make(chan types.ExecutionUpdate, 100*len(children))
+	log := logrus.WithField("jobID", job.ID)
+
+	maxParallel := job.Execution.Matrix.MaxParallel
+	if maxParallel <= 0 || maxParallel > len(children) {
+		maxParallel = len(children)
+	}
+	failFast := job.Execution.Matrix.FailFast
+
+	// Register the matrix job before returning so control operations find
+	// it even before its first combination starts
+	r.track(job.ID, nil)
+
+	go func() {
+		defer close(updates)
+		defer r.untrack(job.ID, "")
+
+		sendMatrixUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
+			Status:  types.JobStatusRunning,
+			Message: fmt.Sprintf("Starting matrix execution of %d combinations", len(children)),
+		})
+
+		results := make([]*matrixResult, len(children))
+		jobSlots := make(chan struct{}, maxParallel)
+		var (
+			wg     sync.WaitGroup
+			mu     sync.Mutex
+			failed bool
+		)
+
+		for i, child := range children {
+			results[i] = &matrixResult{
+				Index:       i,
+				JobID:       child.job.ID,
+				Combination: child.combo,
+				Status:      types.JobStatusPending,
+			}
+
+			if !acquireMatrixSlots(ctx, jobSlots, r.slots) {
+				results[i].Status = types.JobStatusCancelled
+				results[i].Error = "not started"
+				continue
+			}
+
+			mu.Lock()
+			skip := failFast && failed
+			mu.Unlock()
+			if skip {
+				results[i].Status = types.JobStatusCancelled
+				results[i].Error = "not started after an earlier combination failed"
+				<-r.slots
+				<-jobSlots
+				continue
+			}
+
+			wg.Add(1)
+			go func(child *types.Job, result *matrixResult) {
+				defer wg.Done()
+				defer func() {
+					<-r.slots
+					<-jobSlots
+				}()
+
+				r.track(job.ID, child)
+				defer r.untrack(job.ID, child.ID)
+				runMatrixChild(ctx, executor, child, result, updates, log)
+
+				if result.Status != types.JobStatusCompleted || result.ExitCode != 0 {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}(child.job, results[i])
+		}
+
+		wg.Wait()
+		sendMatrixSummary(updates, results)
+	}()
+
+	return updates
+}
+
+// acquireMatrixSlots takes a slot from the job's own limit and then from the
+// shared limit, or neither if the context ends first
+func acquireMatrixSlots(ctx context.Context, local, shared chan struct{}) bool {
+	select {
+	case local <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	select {
+	case shared <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		<-local
+		return false
+	}
+}
+
+// runMatrixChild executes one combination and forwards its updates
+func runMatrixChild(ctx context.Context, executor Executor, child *types.Job, result *matrixResult, updates chan<- types.ExecutionUpdate, log *logrus.Entry) {
+	label := result.Combination.Label()
+	result.StartTime = time.Now()
+	result.Status = types.JobStatusRunning
+
+	sendMatrixUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
+		Status:  types.JobStatusRunning,
+		Message: fmt.Sprintf("[%s] started", label),
+	})
+
+	childUpdates, err := executor.Execute(ctx, child)
+	if err != nil {
+		log.WithError(err).WithField("combination", label).Warn("Failed to start matrix combination")
+		result.Status = types.JobStatusFailed
+		result.Error = err.Error()
+		result.EndTime = time.Now()
+		return
+	}
+
+	for update := range childUpdates {
+		switch data := update.Data.(type) {
+		case *types.LogEntry:
+			entry := *data
+			entry.Line = fmt.Sprintf("[%s] %s", label, data.Line)
+			update.Data = &entry
+		case *types.StatusUpdate:
+			status := *data
+			status.Message = fmt.Sprintf("[%s] %s", label, data.Message)
+			update.Data = &status
+
+			switch update.Type {
+			case types.UpdateTypeComplete:
+				result.Status = data.Status
+				if data.ExitCode != nil {
+					result.ExitCode = *data.ExitCode
+				}
+				if data.Output != nil && data.Output.Data != nil {
+					result.Output = data.Output.Data
+				}
+			case types.UpdateTypeError:
+				result.Error = data.Message
+				if data.Status == types.JobStatusFailed {
+					result.Status = types.JobStatusFailed
+				}
+			}
+
+			// Only the matrix as a whole reports a final job status; a finished
+			// combination is recorded in the log instead
+			if update.Type == types.UpdateTypeComplete ||
+				(update.Type == types.UpdateTypeStatus && status.Status != types.JobStatusRunning) {
+				update.Type = types.UpdateTypeLog
+				update.Data = types.NewLogEntry("system", fmt.Sprintf("%s (%s)", status.Message, status.Status), 0)
+			}
+		}
+		sendMatrixUpdate(updates, update.Type, update.Data)
+	}
+
+	if result.Status == types.JobStatusRunning {
+		result.Status = types.JobStatusFailed
+		if result.Error == "" {
+			result.Error = "execution ended without a result"
+		}
+	}
+	result.EndTime = time.Now()
+}
+
+// sendMatrixSummary sends the aggregated completion update. Exit codes follow
+// the multi-server convention: 0 when everything succeeded, 100+N when N
+// combinations failed alongside successes.
+func sendMatrixSummary(updates chan<- types.ExecutionUpdate, results []*matrixResult) {
+	var successCount, failureCount, timeoutCount, skippedCount, lastExitCode int
+	var summary strings.Builder
+	summary.WriteString("=== Matrix Execution Summary ===\n\n")
+
+	formatted := make([]map[string]any, 0, len(results))
+	for _, result := range results {
+		switch {
+		case result.Status == types.JobStatusCancelled:
+			skippedCount++
+			failureCount++
+		case result.Status == types.JobStatusCompleted && result.ExitCode == 0:
+			successCount++
+		case result.Status == types.JobStatusTimeout || result.ExitCode == -1:
+			timeoutCount++
+			failureCount++
+			lastExitCode = result.ExitCode
+		default:
+			failureCount++
+			if result.ExitCode != 0 {
+				lastExitCode = result.ExitCode
+			}
+		}
+
+		fmt.Fprintf(&summary, "[%s]\n  Status: %s (exit code: %d)\n", result.Combination.Label(), strings.ToUpper(string(result.Status)), result.ExitCode)
+		if result.Error != "" {
+			fmt.Fprintf(&summary, "  Error: %s\n", result.Error)
+		}
+		summary.WriteString("\n")
+
+		entry := map[string]any{
+			"index":       result.Index,
+			"jobId":       result.JobID,
+			"combination": map[string]any(result.Combination),
+			"label":       result.Combination.Label(),
+			"status":      string(result.Status),
+			"exitCode":    result.ExitCode,
+		}
+		if !result.StartTime.IsZero() {
+			entry["startTime"] = result.StartTime.Format(time.RFC3339)
+			entry["endTime"] = result.EndTime.Format(time.RFC3339)
+			entry["duration"] = result.EndTime.Sub(result.StartTime).Seconds()
+		}
+		if result.Error != "" {
+			entry["error"] = result.Error
+		}
+		if result.Output != nil {
+			entry["output"] = result.Output
+		}
+		formatted = append(formatted, entry)
+	}
+
+	total := len(results)
+	var status types.JobStatus
+	var message string
+	exitCode := 0
+	switch {
+	case failureCount == 0:
+		status = types.JobStatusCompleted
+		message = fmt.Sprintf("Matrix succeeded for all %d combinations", total)
+	case successCount == 0:
+		status = types.JobStatusFailed
+		message = fmt.Sprintf("Matrix failed for all %d combinations", total)
+		exitCode = lastExitCode
+		if exitCode == 0 {
+			exitCode = 1
+		}
+	default:
+		status = types.JobStatusCompleted
+		message = fmt.Sprintf("PARTIAL SUCCESS: %d succeeded, %d failed (including %d timeouts, %d not started) out of %d combinations",
+			successCount, failureCount, timeoutCount, skippedCount, total)
+		exitCode = 100 + failureCount
+	}
+	fmt.Fprintf(&summary, "\n=== Final Summary ===\n%s\n", message)
+
+	sendMatrixUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   status,
+		ExitCode: &exitCode,
+		Message:  message,
+		Output: &types.OutputData{
+			Data: map[string]any{
+				"successCount":      successCount,
+				"failureCount":      failureCount,
+				"timeoutCount":      timeoutCount,
+				"skippedCount":      skippedCount,
+				"totalCombinations": total,
+				"results":           formatted,
+				"summary":           summary.String(),
+			},
+		},
+	})
+}
+
+// sendMatrixUpdate sends an update without blocking on a full channel
+func sendMatrixUpdate(updates chan<- types.ExecutionUpdate, updateType types.UpdateType, data any) {
+	select {
+	case updates <- types.ExecutionUpdate{
+		Type:      updateType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}:
+	default:
+		logrus.Warn("Matrix updates channel full, dropping update")
+	}
+}
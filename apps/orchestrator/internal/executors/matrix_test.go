@@ -0,0 +1,108 @@
+package executors
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingExecutor runs every job until it is cancelled
+type blockingExecutor struct {
+	mu        sync.Mutex
+	started   map[string]chan struct{}
+	cancelled []string
+}
+
+func (e *blockingExecutor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	stop := make(chan struct{})
+	e.mu.Lock()
+	e.started[job.ID] = stop
+	e.mu.Unlock()
+
+	updates := make(chan types.ExecutionUpdate)
+	go func() {
+		defer close(updates)
+		select {
+		case <-stop:
+		case <-ctx.Done():
+		}
+	}()
+	return updates, nil
+}
+
+func (e *blockingExecutor) Cancel(ctx context.Context, job *types.Job, reason string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cancelled = append(e.cancelled, job.ID)
+	if stop, ok := e.started[job.ID]; ok {
+		close(stop)
+		delete(e.started, job.ID)
+	}
+	return nil
+}
+
+func (e *blockingExecutor) running() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.started)
+}
+
+func (e *blockingExecutor) Validate(job *types.Job) error                   { return nil }
+func (e *blockingExecutor) Cleanup(ctx context.Context, j *types.Job) error { return nil }
+func (e *blockingExecutor) Type() types.JobType                             { return types.JobTypeSSH }
+
+func matrixJob(jobType types.JobType) *types.Job {
+	return &types.Job{
+		ID:   "job-1",
+		Type: jobType,
+		Execution: types.ExecutionConfig{
+			Matrix: &types.Matrix{Axes: []types.MatrixAxis{{Name: "region", Values: []any{"eu", "us"}}}},
+		},
+	}
+}
+
+func TestCancelReachesMatrixCombinations(t *testing.T) {
+	executor := &blockingExecutor{started: make(map[string]chan struct{})}
+	m := NewManager()
+	m.Register(types.JobTypeSSH, executor)
+
+	job := matrixJob(types.JobTypeSSH)
+	updates, err := m.Execute(context.Background(), job)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return executor.running() == 2 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, m.Cancel(context.Background(), job, "test"))
+	for range updates {
+	}
+	assert.ElementsMatch(t, []string{"job-1-m0", "job-1-m1"}, executor.cancelled)
+
+	_, tracked := m.matrix.children(job.ID)
+	assert.False(t, tracked, "finished matrix jobs are no longer tracked")
+}
+
+func TestMatrixCombinationsValidateInputRefs(t *testing.T) {
+	m := NewManager()
+	m.Register(types.JobTypeSSH, &blockingExecutor{started: make(map[string]chan struct{})})
+	m.Register(types.JobTypeContainer, &blockingExecutor{started: make(map[string]chan struct{})})
+
+	job := matrixJob(types.JobTypeContainer)
+	job.Execution.InputRefs = []types.InputRef{{Name: "data", URL: "https://example.com/data"}}
+	_, err := m.Execute(context.Background(), job)
+	assert.ErrorContains(t, err, "only supported for SSH jobs")
+}
+
+func TestSnapshotsRejectedForOtherJobTypes(t *testing.T) {
+	m := NewManager()
+	m.Register(types.JobTypeContainer, &blockingExecutor{started: make(map[string]chan struct{})})
+
+	snapshot := true
+	job := &types.Job{ID: "job-1", Type: types.JobTypeContainer}
+	job.Execution.SnapshotOnFailure = &snapshot
+	_, err := m.Execute(context.Background(), job)
+	assert.ErrorContains(t, err, "only supported for SSH jobs")
+}
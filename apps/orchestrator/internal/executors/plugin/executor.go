@@ -0,0 +1,313 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxEventLine is the longest event line read from a plugin
+const maxEventLine = 1024 * 1024
+
+// event is one line of a plugin's execute output
+type event struct {
+	Type       string          `json:"type"`
+	Stream     string          `json:"stream,omitempty"`
+	Line       string          `json:"line,omitempty"`
+	Percentage int             `json:"percentage,omitempty"`
+	Status     types.JobStatus `json:"status,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	ExitCode   *int            `json:"exitCode,omitempty"`
+	Output     interface{}     `json:"output,omitempty"`
+	Code       string          `json:"code,omitempty"`
+	Retryable  bool            `json:"retryable,omitempty"`
+}
+
+// Executor runs one job type through a plugin
+type Executor struct {
+	plugin    *Plugin
+	jobType   types.JobType
+	apiClient *api.Client
+	log       *logrus.Logger
+}
+
+// Plugin returns the plugin behind the executor
+func (e *Executor) Plugin() *Plugin {
+	return e.plugin
+}
+
+// Type returns the job type this executor handles
+func (e *Executor) Type() types.JobType {
+	return e.jobType
+}
+
+// Validate asks the plugin whether it can run the job, if it supports
+// validation
+func (e *Executor) Validate(job *types.Job) error {
+	if !e.plugin.manifest.Capabilities.Validate {
+		return nil
+	}
+	if _, err := e.plugin.call(context.Background(), "validate", &request{ProtocolVersion: ProtocolVersion, Job: job}); err != nil {
+		return types.NewExecutionError("validation", "PLUGIN_VALIDATION_FAILED", err.Error(), false)
+	}
+	return nil
+}
+
+// Cleanup lets the plugin release what it holds for the job, if it supports
+// cleanup
+func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
+	if !e.plugin.manifest.Capabilities.Cleanup {
+		return nil
+	}
+	_, err := e.plugin.call(ctx, "cleanup", &request{ProtocolVersion: ProtocolVersion, Job: job})
+	return err
+}
+
+// Execute starts the plugin for a job and relays its events
+func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	if !e.plugin.available() {
+		return nil, types.NewExecutionError("plugin", "PLUGIN_UNAVAILABLE",
+			fmt.Sprintf("executor plugin %s is out of service after repeated crashes", e.plugin.manifest.Name), true)
+	}
+
+	updates := make(chan types.ExecutionUpdate, 100)
+	go e.run(ctx, job, updates)
+	return updates, nil
+}
+
+// run executes the job in the plugin and reports the outcome
+func (e *Executor) run(ctx context.Context, job *types.Job, updates chan<- types.ExecutionUpdate) {
+	defer close(updates)
+
+	log := e.log.WithFields(logrus.Fields{"jobID": job.ID, "plugin": e.plugin.manifest.Name})
+
+	// Wait for a free slot if the plugin limits concurrency
+	if e.plugin.slots != nil {
+		select {
+		case e.plugin.slots <- struct{}{}:
+			defer func() { <-e.plugin.slots }()
+		case <-ctx.Done():
+			e.complete(updates, types.JobStatusFailed, -2, "Job cancelled while waiting for the plugin", nil)
+			return
+		}
+	}
+
+	executionID := fmt.Sprintf("exec_%s_%d", job.ID, time.Now().Unix())
+	if e.apiClient != nil {
+		if err := e.apiClient.CreateExecution(ctx, executionID, job, nil, nil); err != nil {
+			log.WithError(err).Warn("Failed to create execution record")
+		}
+	}
+
+	workDir, err := os.MkdirTemp("", "cronium-plugin-")
+	if err != nil {
+		e.fail(updates, "PLUGIN_SETUP_FAILED", fmt.Sprintf("failed to create work directory: %v", err), true)
+		return
+	}
+	defer os.RemoveAll(workDir)
+
+	data, err := json.Marshal(request{ProtocolVersion: ProtocolVersion, ExecutionID: executionID, Job: job})
+	if err != nil {
+		e.fail(updates, "PLUGIN_SETUP_FAILED", fmt.Sprintf("failed to encode job: %v", err), false)
+		return
+	}
+
+	cmd := e.plugin.command(ctx, "execute", workDir,
+		"CRONIUM_JOB_ID="+job.ID,
+		"CRONIUM_EXECUTION_ID="+executionID,
+	)
+	cmd.Stdin = strings.NewReader(string(data))
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		e.fail(updates, "PLUGIN_SETUP_FAILED", err.Error(), true)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		e.fail(updates, "PLUGIN_SETUP_FAILED", err.Error(), true)
+		return
+	}
+
+	startedAt := time.Now()
+	if err := cmd.Start(); err != nil {
+		e.plugin.recordResult(true)
+		e.fail(updates, "PLUGIN_START_FAILED", fmt.Sprintf("failed to start plugin: %v", err), true)
+		return
+	}
+	e.send(updates, types.UpdateTypeStatus, types.NewStatusUpdate(types.JobStatusRunning, "Plugin started"))
+	e.updateExecution(executionID, types.JobStatusRunning, &api.ExecutionStatusUpdate{StartedAt: &startedAt})
+
+	var sequence atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 64*1024), maxEventLine)
+		for scanner.Scan() {
+			e.send(updates, types.UpdateTypeLog, types.NewLogEntry("stderr", scanner.Text(), sequence.Add(1)))
+		}
+		io.Copy(io.Discard, stderr)
+	}()
+
+	final := e.relay(stdout, updates, &sequence)
+	wg.Wait()
+	waitErr := cmd.Wait()
+
+	completedAt := time.Now()
+	record := &api.ExecutionStatusUpdate{CompletedAt: &completedAt}
+
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		e.plugin.recordResult(false)
+		e.complete(updates, types.JobStatusFailed, -1, "Plugin execution timed out", nil)
+		record.ExitCode = intPtr(-1)
+		e.updateExecution(executionID, types.JobStatusTimeout, record)
+
+	case ctx.Err() != nil:
+		e.plugin.recordResult(false)
+		e.complete(updates, types.JobStatusFailed, -2, "Plugin execution cancelled", nil)
+		record.ExitCode = intPtr(-2)
+		e.updateExecution(executionID, types.JobStatusCancelled, record)
+
+	case final == nil:
+		// Exited without saying how the job went
+		e.plugin.recordResult(true)
+		message := "plugin exited without reporting a result"
+		if waitErr != nil {
+			message = fmt.Sprintf("plugin crashed: %v", waitErr)
+		}
+		log.Warn(message)
+		e.fail(updates, "PLUGIN_CRASHED", message, true)
+		record.Error = &message
+		e.updateExecution(executionID, types.JobStatusFailed, record)
+
+	case final.Type == "error":
+		e.plugin.recordResult(false)
+		e.fail(updates, final.Code, final.Message, final.Retryable)
+		record.Error = &final.Message
+		e.updateExecution(executionID, types.JobStatusFailed, record)
+
+	default:
+		e.plugin.recordResult(false)
+		exitCode := 0
+		if final.ExitCode != nil {
+			exitCode = *final.ExitCode
+		}
+		status := types.JobStatusCompleted
+		if exitCode != 0 {
+			status = types.JobStatusFailed
+		}
+		e.complete(updates, status, exitCode, final.Message, final.Output)
+		record.ExitCode = &exitCode
+		e.updateExecution(executionID, status, record)
+	}
+}
+
+// relay turns the plugin's stdout into updates and returns its final
+// complete or error event, if it sent one
+func (e *Executor) relay(stdout io.Reader, updates chan<- types.ExecutionUpdate, sequence *atomic.Int64) *event {
+	var final *event
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), maxEventLine)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var ev event
+		if !strings.HasPrefix(strings.TrimSpace(line), "{") || json.Unmarshal([]byte(line), &ev) != nil || ev.Type == "" {
+			e.send(updates, types.UpdateTypeLog, types.NewLogEntry("stdout", line, sequence.Add(1)))
+			continue
+		}
+
+		switch ev.Type {
+		case "log":
+			stream := ev.Stream
+			if stream != "stderr" {
+				stream = "stdout"
+			}
+			e.send(updates, types.UpdateTypeLog, types.NewLogEntry(stream, ev.Line, sequence.Add(1)))
+		case "progress":
+			e.send(updates, types.UpdateTypeProgress, types.NewProgressUpdate(ev.Percentage, ev.Message))
+		case "status":
+			if ev.Status != "" {
+				e.send(updates, types.UpdateTypeStatus, types.NewStatusUpdate(ev.Status, ev.Message))
+			}
+		case "complete", "error":
+			final = &ev
+		default:
+			e.send(updates, types.UpdateTypeLog, types.NewLogEntry("stdout", line, sequence.Add(1)))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		e.log.WithError(err).WithField("plugin", e.plugin.manifest.Name).Warn("Failed to read plugin output")
+		// Drain so the plugin is not blocked writing to a full pipe
+		io.Copy(io.Discard, stdout)
+	}
+	return final
+}
+
+// complete sends the completion update
+func (e *Executor) complete(updates chan<- types.ExecutionUpdate, status types.JobStatus, exitCode int, message string, output interface{}) {
+	update := &types.StatusUpdate{
+		Status:   status,
+		Message:  message,
+		ExitCode: &exitCode,
+	}
+	if output != nil {
+		update.Output = &types.OutputData{Data: output}
+	}
+	e.send(updates, types.UpdateTypeComplete, update)
+}
+
+// fail sends an error and a failed completion
+func (e *Executor) fail(updates chan<- types.ExecutionUpdate, code, message string, retryable bool) {
+	if code == "" {
+		code = "PLUGIN_ERROR"
+	}
+	err := types.NewExecutionError("plugin", code, message, retryable)
+	e.send(updates, types.UpdateTypeError, &types.StatusUpdate{
+		Status:  types.JobStatusFailed,
+		Message: message,
+		Error:   types.ErrorDetailsFromError(err),
+	})
+	e.complete(updates, types.JobStatusFailed, 1, message, nil)
+}
+
+// send delivers an update. It blocks rather than dropping updates, which
+// only slows a plugin that writes faster than its output is consumed.
+func (e *Executor) send(updates chan<- types.ExecutionUpdate, updateType types.UpdateType, data interface{}) {
+	updates <- types.ExecutionUpdate{
+		Type:      updateType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+}
+
+// updateExecution records the execution's status in the backend
+func (e *Executor) updateExecution(executionID string, status types.JobStatus, details *api.ExecutionStatusUpdate) {
+	if e.apiClient == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := e.apiClient.UpdateExecution(ctx, executionID, status, details); err != nil {
+		e.log.WithError(err).WithField("executionID", executionID).Warn("Failed to update execution")
+	}
+}
+
+// intPtr returns a pointer to v
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,298 @@
+// Package plugin runs jobs with executor plugins: standalone executables in
+// the plugins directory, so third parties can add job types (a Lambda
+// invoker, a Nomad dispatcher) without rebuilding the orchestrator.
+//
+// A plugin is started once per call with the call name as its argument, and
+// only sees PATH, HOME, the CRONIUM_* protocol variables and any variables
+// the operator passes through:
+//
+//	describe   prints the plugin's manifest as JSON on stdout
+//	execute    reads {"protocolVersion", "executionId", "job"} on stdin and
+//	           writes events as JSON lines on stdout
+//	validate   reads the same request; a non-zero exit rejects the job with
+//	           stderr as the reason (only if the validate capability is set)
+//	cleanup    reads the same request after the job (cleanup capability)
+//
+// Execute events are {"type": "log", "stream", "line"}, {"type": "progress",
+// "percentage", "message"}, {"type": "status", "status", "message"},
+// {"type": "complete", "exitCode", "message", "output"} and {"type": "error",
+// "code", "message", "retryable"}. Stdout lines that are not JSON and
+// everything on stderr are kept as job log lines.
+//
+// Each call is a separate process, so a crashing plugin fails only its own
+// job. A plugin that exits without a complete or error event counts as
+// crashed; after MaxFailures crashes in a row it is taken out of service for
+// the cooldown and its jobs fail as retryable.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ProtocolVersion is the plugin protocol this orchestrator speaks
+const ProtocolVersion = 1
+
+// Manifest is a plugin's answer to describe
+type Manifest struct {
+	Name            string          `json:"name"`
+	Version         string          `json:"version"`
+	ProtocolVersion int             `json:"protocolVersion"`
+	JobTypes        []types.JobType `json:"jobTypes"`
+	Capabilities    Capabilities    `json:"capabilities"`
+}
+
+// Capabilities are the optional parts of the protocol a plugin supports
+type Capabilities struct {
+	Validate bool `json:"validate,omitempty"`
+	Cleanup  bool `json:"cleanup,omitempty"`
+	// Jobs the plugin may run at once; 0 means no limit
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+}
+
+// request is the job description sent to execute, validate and cleanup
+type request struct {
+	ProtocolVersion int        `json:"protocolVersion"`
+	ExecutionID     string     `json:"executionId,omitempty"`
+	Job             *types.Job `json:"job"`
+}
+
+// builtinTypes cannot be taken over by plugins
+var builtinTypes = map[types.JobType]bool{
+	types.JobTypeContainer: true,
+	types.JobTypeSSH:       true,
+}
+
+// Plugin is a described plugin executable
+type Plugin struct {
+	path     string
+	manifest Manifest
+	config   config.PluginsConfig
+	log      *logrus.Logger
+
+	slots chan struct{} // nil without a concurrency limit
+
+	mu            sync.Mutex
+	failures      int
+	disabledUntil time.Time
+}
+
+// Load describes every executable in the plugins directory and returns an
+// executor for each job type they declare. Plugins that fail to describe
+// themselves, speak another protocol version or claim a job type that is
+// already taken are skipped with a warning. It returns nil when plugins are
+// disabled.
+func Load(cfg config.PluginsConfig, apiClient *api.Client, log *logrus.Logger) ([]*Executor, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+
+	var executors []*Executor
+	claimed := make(map[types.JobType]string)
+	for _, entry := range entries {
+		path := filepath.Join(cfg.Dir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") || !isExecutable(path) {
+			continue
+		}
+		pluginLog := log.WithField("plugin", entry.Name())
+
+		p, err := describe(path, cfg, log)
+		if err != nil {
+			pluginLog.WithError(err).Warn("Skipping executor plugin")
+			continue
+		}
+
+		for _, jobType := range p.manifest.JobTypes {
+			if builtinTypes[jobType] {
+				pluginLog.WithField("jobType", jobType).Warn("Plugin may not replace a built-in job type")
+				continue
+			}
+			if owner, ok := claimed[jobType]; ok {
+				pluginLog.WithFields(logrus.Fields{"jobType": jobType, "owner": owner}).Warn("Job type already provided by another plugin")
+				continue
+			}
+			claimed[jobType] = p.manifest.Name
+			executors = append(executors, &Executor{plugin: p, jobType: jobType, apiClient: apiClient, log: log})
+		}
+
+		pluginLog.WithFields(logrus.Fields{
+			"name":          p.manifest.Name,
+			"version":       p.manifest.Version,
+			"jobTypes":      p.manifest.JobTypes,
+			"validate":      p.manifest.Capabilities.Validate,
+			"cleanup":       p.manifest.Capabilities.Cleanup,
+			"maxConcurrent": p.manifest.Capabilities.MaxConcurrent,
+		}).Info("Loaded executor plugin")
+	}
+	return executors, nil
+}
+
+// describe runs a plugin's describe call and checks its manifest
+func describe(path string, cfg config.PluginsConfig, log *logrus.Logger) (*Plugin, error) {
+	p := &Plugin{path: path, config: cfg, log: log}
+
+	stdout, err := p.call(context.Background(), "describe", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stdout, &p.manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	m := &p.manifest
+	if m.ProtocolVersion != ProtocolVersion {
+		return nil, fmt.Errorf("plugin speaks protocol version %d, expected %d", m.ProtocolVersion, ProtocolVersion)
+	}
+	if m.Name == "" {
+		m.Name = filepath.Base(path)
+	}
+	if len(m.JobTypes) == 0 {
+		return nil, fmt.Errorf("manifest declares no job types")
+	}
+	if m.Capabilities.MaxConcurrent > 0 {
+		p.slots = make(chan struct{}, m.Capabilities.MaxConcurrent)
+	}
+	return p, nil
+}
+
+// Manifest returns the plugin's manifest
+func (p *Plugin) Manifest() Manifest {
+	return p.manifest
+}
+
+// call runs a short plugin call (describe, validate, cleanup) and returns
+// its stdout. A non-zero exit is an error carrying stderr.
+func (p *Plugin) call(ctx context.Context, name string, req *request) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.CallTimeout)
+	defer cancel()
+
+	cmd := p.command(ctx, name, "")
+	if req != nil {
+		data, err := json.Marshal(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+		cmd.Stdin = bytes.NewReader(data)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("%s timed out after %v", name, p.config.CallTimeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s failed: %s", name, truncate(msg, 500))
+		}
+		return nil, fmt.Errorf("%s failed: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// command prepares a plugin process with a minimal environment. Cancelling
+// ctx sends SIGTERM and kills the process after the stop grace period.
+func (p *Plugin) command(ctx context.Context, name, workDir string, extraEnv ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, p.path, name)
+	cmd.Dir = workDir
+	cmd.Cancel = func() error {
+		return terminate(cmd.Process)
+	}
+	cmd.WaitDelay = p.config.StopGracePeriod
+
+	env := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + os.Getenv("HOME"),
+		fmt.Sprintf("CRONIUM_PLUGIN_PROTOCOL=%d", ProtocolVersion),
+	}
+	if workDir != "" {
+		env = append(env, "TMPDIR="+workDir)
+	}
+	for _, key := range p.config.Env {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	cmd.Env = append(env, extraEnv...)
+	return cmd
+}
+
+// available reports whether the plugin is in service, returning it to
+// service once its cooldown has passed
+func (p *Plugin) available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disabledUntil.IsZero() {
+		return true
+	}
+	if time.Now().Before(p.disabledUntil) {
+		return false
+	}
+	p.disabledUntil = time.Time{}
+	p.failures = 0
+	p.log.WithField("plugin", p.manifest.Name).Info("Executor plugin back in service")
+	return true
+}
+
+// recordResult counts consecutive crashes and takes the plugin out of
+// service once they reach the limit
+func (p *Plugin) recordResult(crashed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !crashed {
+		p.failures = 0
+		return
+	}
+	p.failures++
+	if p.failures >= p.config.MaxFailures && p.disabledUntil.IsZero() {
+		p.disabledUntil = time.Now().Add(p.config.Cooldown)
+		p.log.WithFields(logrus.Fields{
+			"plugin":   p.manifest.Name,
+			"failures": p.failures,
+			"cooldown": p.config.Cooldown,
+		}).Error("Executor plugin keeps crashing, taking it out of service")
+	}
+}
+
+// isExecutable reports whether path is a regular file with an execute bit
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
+
+// terminate asks a plugin process to stop
+func terminate(process *os.Process) error {
+	if process == nil {
+		return nil
+	}
+	return process.Signal(syscall.SIGTERM)
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,230 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// certClockSkew backdates certificates so servers with a slow clock accept
+// them
+const certClockSkew = time.Minute
+
+// certificateAuthority mints short-lived user certificates for connecting
+// to servers. Certificates are cached per set of principals and replaced
+// when a fifth of their lifetime is left.
+type certificateAuthority struct {
+	cfg        config.SSHCertificateConfig
+	log        *logrus.Logger
+	keyID      string
+	caSigner   ssh.Signer // local source
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	certs map[string]*mintedCertificate
+}
+
+// mintedCertificate is a certificate with the key it certifies
+type mintedCertificate struct {
+	signer  ssh.Signer
+	renewAt time.Time
+}
+
+// newCertificateAuthority creates the certificate authority. It returns nil
+// when certificate authentication is disabled.
+func newCertificateAuthority(cfg config.SSHCertificateConfig, log *logrus.Logger) (*certificateAuthority, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	hostname, _ := os.Hostname()
+	a := &certificateAuthority{
+		cfg:   cfg,
+		log:   log,
+		keyID: "cronium-orchestrator@" + hostname,
+		certs: make(map[string]*mintedCertificate),
+	}
+
+	switch cfg.Source {
+	case "vault":
+		a.httpClient = &http.Client{Timeout: 30 * time.Second}
+	default:
+		data, err := os.ReadFile(cfg.CAKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SSH CA key: %w", err)
+		}
+		if cfg.CAKeyPassphrase != "" {
+			a.caSigner, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(cfg.CAKeyPassphrase))
+		} else {
+			a.caSigner, err = ssh.ParsePrivateKey(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH CA key: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// AuthMethod returns certificate authentication for a server
+func (a *certificateAuthority) AuthMethod(ctx context.Context, server *types.ServerDetails) (ssh.AuthMethod, error) {
+	principals := a.cfg.Principals
+	if len(principals) == 0 {
+		principals = []string{server.Username}
+	}
+	signer, err := a.certificate(ctx, principals)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.PublicKeys(signer), nil
+}
+
+// certificate returns a signer presenting a valid certificate for the
+// principals, minting one when the cached certificate is due for renewal
+func (a *certificateAuthority) certificate(ctx context.Context, principals []string) (ssh.Signer, error) {
+	key := strings.Join(principals, ",")
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if cached, ok := a.certs[key]; ok && time.Now().Before(cached.renewAt) {
+		return cached.signer, nil
+	}
+
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate certificate key: %w", err)
+	}
+	keySigner, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	var cert *ssh.Certificate
+	if a.cfg.Source == "vault" {
+		cert, err = a.signVault(ctx, keySigner.PublicKey(), principals)
+	} else {
+		cert, err = a.signLocal(keySigner.PublicKey(), principals)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.NewCertSigner(cert, keySigner)
+	if err != nil {
+		return nil, fmt.Errorf("certificate does not match its key: %w", err)
+	}
+	now := time.Now()
+	validBefore := time.Unix(int64(cert.ValidBefore), 0)
+	a.certs[key] = &mintedCertificate{
+		signer:  signer,
+		renewAt: validBefore.Add(-validBefore.Sub(now) / 5),
+	}
+
+	a.log.WithFields(logrus.Fields{
+		"principals": principals,
+		"serial":     cert.Serial,
+		"expiresAt":  validBefore,
+	}).Debug("Minted SSH user certificate")
+	return signer, nil
+}
+
+// signLocal signs a user certificate with the local CA key
+func (a *certificateAuthority) signLocal(key ssh.PublicKey, principals []string) (*ssh.Certificate, error) {
+	var serial [8]byte
+	if _, err := rand.Read(serial[:]); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.UserCert,
+		KeyId:           a.keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-certClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(a.cfg.TTL).Unix()),
+		Permissions: ssh.Permissions{Extensions: map[string]string{
+			"permit-pty":             "",
+			"permit-port-forwarding": "",
+		}},
+	}
+	if err := cert.SignCert(rand.Reader, a.caSigner); err != nil {
+		return nil, fmt.Errorf("failed to sign SSH certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// vaultSignResponse is the part of a Vault SSH sign response the authority
+// uses
+type vaultSignResponse struct {
+	Data struct {
+		SignedKey string `json:"signed_key"`
+	} `json:"data"`
+}
+
+// signVault has the Vault SSH secrets engine sign a user certificate. The
+// role decides the extensions and may shorten the TTL.
+func (a *certificateAuthority) signVault(ctx context.Context, key ssh.PublicKey, principals []string) (*ssh.Certificate, error) {
+	vault := a.cfg.Vault
+	body, err := json.Marshal(map[string]string{
+		"public_key":       string(ssh.MarshalAuthorizedKey(key)),
+		"valid_principals": strings.Join(principals, ","),
+		"ttl":              a.cfg.TTL.String(),
+		"cert_type":        "user",
+		"key_id":           a.keyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/sign/%s", strings.TrimSuffix(vault.Address, "/"), strings.Trim(vault.Mount, "/"), vault.Role)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", vault.Token)
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Vault error: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var signed vaultSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("failed to decode Vault response: %w", err)
+	}
+
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(signed.Data.SignedKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate from Vault: %w", err)
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("Vault did not return a certificate")
+	}
+	return cert, nil
+}
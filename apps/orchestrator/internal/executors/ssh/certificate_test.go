@@ -0,0 +1,142 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// writeCAKey writes a new CA key in OpenSSH format and returns its path and
+// signer
+func writeCAKey(t *testing.T) (string, ssh.Signer) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(private, "")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "ca")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(block), 0o600))
+
+	signer, err := ssh.NewSignerFromKey(private)
+	require.NoError(t, err)
+	return path, signer
+}
+
+// checkCert verifies that signer presents a user certificate from ca that is
+// valid for principal
+func checkCert(t *testing.T, signer ssh.Signer, ca ssh.PublicKey, principal string) *ssh.Certificate {
+	cert, ok := signer.PublicKey().(*ssh.Certificate)
+	require.True(t, ok)
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.Marshal())
+		},
+	}
+	require.NoError(t, checker.CheckCert(principal, cert))
+	return cert
+}
+
+func testLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func TestLocalCertificates(t *testing.T) {
+	caFile, ca := writeCAKey(t)
+	authority, err := newCertificateAuthority(config.SSHCertificateConfig{
+		Enabled:   true,
+		Source:    "local",
+		CAKeyFile: caFile,
+		TTL:       5 * time.Minute,
+	}, testLogger())
+	require.NoError(t, err)
+
+	signer, err := authority.certificate(context.Background(), []string{"deploy"})
+	require.NoError(t, err)
+	cert := checkCert(t, signer, ca.PublicKey(), "deploy")
+	assert.Equal(t, uint32(ssh.UserCert), cert.CertType)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), time.Unix(int64(cert.ValidBefore), 0), 5*time.Second)
+
+	// Cached until it is due for renewal
+	again, err := authority.certificate(context.Background(), []string{"deploy"})
+	require.NoError(t, err)
+	assert.Same(t, signer, again)
+
+	authority.certs["deploy"].renewAt = time.Now()
+	renewed, err := authority.certificate(context.Background(), []string{"deploy"})
+	require.NoError(t, err)
+	assert.NotSame(t, signer, renewed)
+
+	// Without configured principals the server's username is used
+	_, err = authority.AuthMethod(context.Background(), &types.ServerDetails{Username: "ops"})
+	require.NoError(t, err)
+	checkCert(t, authority.certs["ops"].signer, ca.PublicKey(), "ops")
+}
+
+func TestVaultCertificates(t *testing.T) {
+	caFile, ca := writeCAKey(t)
+	local, err := newCertificateAuthority(config.SSHCertificateConfig{
+		Enabled: true, Source: "local", CAKeyFile: caFile, TTL: time.Minute,
+	}, testLogger())
+	require.NoError(t, err)
+
+	// Vault signs with the same CA
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/ssh-client/sign/orchestrator", r.URL.Path)
+		assert.Equal(t, "vault-token", r.Header.Get("X-Vault-Token"))
+
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "deploy,backup", req["valid_principals"])
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req["public_key"]))
+		require.NoError(t, err)
+
+		cert, err := local.signLocal(key, strings.Split(req["valid_principals"], ","))
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]string{"signed_key": string(ssh.MarshalAuthorizedKey(cert))},
+		})
+	}))
+	defer server.Close()
+
+	authority, err := newCertificateAuthority(config.SSHCertificateConfig{
+		Enabled:    true,
+		Source:     "vault",
+		Principals: []string{"deploy", "backup"},
+		TTL:        time.Minute,
+		Vault: config.SSHVaultConfig{
+			Address: server.URL,
+			Token:   "vault-token",
+			Mount:   "ssh-client",
+			Role:    "orchestrator",
+		},
+	}, testLogger())
+	require.NoError(t, err)
+
+	signer, err := authority.certificate(context.Background(), []string{"deploy", "backup"})
+	require.NoError(t, err)
+	checkCert(t, signer, ca.PublicKey(), "backup")
+}
+
+func TestCertificatesDisabled(t *testing.T) {
+	authority, err := newCertificateAuthority(config.SSHCertificateConfig{}, testLogger())
+	require.NoError(t, err)
+	assert.Nil(t, authority)
+}
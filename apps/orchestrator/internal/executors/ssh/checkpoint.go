@@ -0,0 +1,166 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// interruptedExitCode is reported for executions stopped by an orchestrator
+// shutdown
+const interruptedExitCode = -8
+
+// checkpointPollInterval is how often the runner's progress marker is looked
+// for while waiting for a checkpoint
+const checkpointPollInterval = 500 * time.Millisecond
+
+// remoteProgress is the progress marker the runner writes once a checkpoint
+// is complete
+type remoteProgress struct {
+	CheckpointedAt time.Time `json:"checkpointedAt"`
+	Progress       any       `json:"progress,omitempty"`
+}
+
+// checkpointDir returns the directory on remote servers where the runner
+// checkpoints an execution
+func (e *Executor) checkpointDir(executionID string) string {
+	return path.Join(e.config.Execution.TempDir, "checkpoints", executionID)
+}
+
+// checkpointArgs returns the runner flags for checkpointing the execution on
+// request and for resuming it from an earlier checkpoint, with a leading
+// space, or an empty string
+func (e *Executor) checkpointArgs(job *types.Job, executionID string) string {
+	if !e.config.Execution.Checkpoint.Enabled || !e.flagOn(job, features.SSHCheckpoints) {
+		return ""
+	}
+	args := " --checkpoint-dir " + e.checkpointDir(executionID)
+	if r := job.Execution.Resume; r != nil && r.CheckpointDir != "" {
+		serverID := job.Execution.Target.ServerDetails.ID
+		if r.ServerID == "" || r.ServerID == serverID {
+			args += " --resume-from " + r.CheckpointDir
+		} else {
+			e.log.WithFields(logrus.Fields{
+				"jobID":    job.ID,
+				"serverID": serverID,
+			}).Warn("Checkpoint is on another server, starting the job from scratch")
+		}
+	}
+	return args
+}
+
+// Checkpoint asks the runner of every active session to checkpoint its job,
+// then stops the sessions. They report their jobs as interrupted with hints
+// for resuming them. It does nothing unless checkpoints are enabled.
+func (e *Executor) Checkpoint(ctx context.Context) {
+	if !e.config.Execution.Checkpoint.Enabled {
+		return
+	}
+
+	e.mu.RLock()
+	sessions := make([]*Session, 0, len(e.sessions))
+	for _, sess := range e.sessions {
+		sessions = append(sessions, sess)
+	}
+	e.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, sess := range sessions {
+		wg.Add(1)
+		go func(sess *Session) {
+			defer wg.Done()
+			hints := e.checkpointSession(ctx, sess)
+
+			sess.mu.Lock()
+			sess.resume = hints
+			sess.mu.Unlock()
+			sess.cancelFunc()
+		}(sess)
+	}
+	wg.Wait()
+}
+
+// checkpointSession requests a checkpoint from the session's runner and
+// waits for its progress marker. The hints lack CheckpointedAt when the
+// runner does not confirm the checkpoint within the timeout.
+func (e *Executor) checkpointSession(ctx context.Context, sess *Session) *types.ResumeHints {
+	dir := e.checkpointDir(sess.executionID)
+	hints := &types.ResumeHints{
+		ExecutionID:   sess.executionID,
+		ServerID:      sess.serverID,
+		CheckpointDir: dir,
+	}
+	log := e.log.WithFields(logrus.Fields{
+		"jobID":       sess.jobID,
+		"executionID": sess.executionID,
+	})
+
+	if _, err := e.runRemote(sess, fmt.Sprintf("mkdir -p %[1]s && cat > %[1]s/request", dir)); err != nil {
+		log.WithError(err).Warn("Failed to request checkpoint")
+		return hints
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.Execution.Checkpoint.Timeout)
+	defer cancel()
+	ticker := time.NewTicker(checkpointPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Warn("Runner did not confirm checkpoint in time")
+			return hints
+		case <-ticker.C:
+		}
+
+		// cat fails until the runner writes the marker
+		output, err := e.runRemote(sess, fmt.Sprintf("cat %s/progress.json", dir))
+		if err != nil || len(output) == 0 {
+			continue
+		}
+		var progress remoteProgress
+		if err := json.Unmarshal(output, &progress); err != nil {
+			continue
+		}
+
+		checkpointedAt := progress.CheckpointedAt
+		hints.CheckpointedAt = &checkpointedAt
+		hints.Progress = progress.Progress
+		log.Info("Runner checkpointed execution")
+		return hints
+	}
+}
+
+// interrupted returns the resume hints of a session stopped for a
+// checkpoint, or nil
+func (s *Session) interrupted() *types.ResumeHints {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resume
+}
+
+// runRemote runs a command on the session's connection once the command
+// policy allows it. The command's stdin is empty.
+func (e *Executor) runRemote(sess *Session, cmd string) ([]byte, error) {
+	if err := e.policy.Check(sess.conn.RemoteAddr().String(), cmd); err != nil {
+		return nil, err
+	}
+	session, err := sess.conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+	return session.Output(cmd)
+}
+
+// Checkpoint checkpoints and stops the jobs running on all servers
+func (m *MultiServerExecutor) Checkpoint(ctx context.Context) {
+	m.executor.Checkpoint(ctx)
+}
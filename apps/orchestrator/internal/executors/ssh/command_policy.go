@@ -0,0 +1,316 @@
+package ssh
+
+import (
+	stderrors "errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// errCommandBlocked is returned for setup commands the policy does not allow
+var errCommandBlocked = stderrors.New("remote command blocked by command policy")
+
+var (
+	// Characters a word may not contain: no quoting, expansion, globbing or
+	// subshells, so the words the policy checks are the words the shell runs
+	unsafeWordChars = "$`'\"\\*?[]()<>!#~&|;\n\r\t"
+	safeArg         = regexp.MustCompile(`^[\w.+:-]+$`)
+	safePath        = regexp.MustCompile(`^/[\w./+-]+$`)
+	exitStatus      = regexp.MustCompile(`^[0-9]{1,3}$`)
+)
+
+// commandPolicy checks the commands the executor runs on servers to set up
+// and clean up executions. A command may chain simple commands with &&, ||,
+// |, ; and { } groups; each simple command must be one of the allowed
+// programs with its fixed flags, and every path it names must lie under an
+// allowed path.
+//
+// A few built-in helper scripts need shell features the grammar rejects and
+// are exempt from it; their text is fixed in the executor:
+//   - the process group termination script and the resource stats probe,
+//     whose job-derived paths are checked by CheckHelper
+//   - the disk space probe, which only reads the fixed work directory
+//   - the file existence check, whose path is single-quoted and only tested
+//   - the dry-run syntax check, which works in a fresh mktemp directory and
+//     gets the script on stdin
+type commandPolicy struct {
+	enabled   bool
+	auditOnly bool
+	paths     []string
+	log       *logrus.Logger
+}
+
+// newCommandPolicy creates the policy; tempDir, where the executor keeps its
+// remote files, is always allowed
+func newCommandPolicy(cfg config.CommandPolicyConfig, tempDir string, log *logrus.Logger) *commandPolicy {
+	paths := append([]string{}, cfg.AllowedPaths...)
+	if tempDir != "" {
+		paths = append(paths, path.Clean(tempDir))
+	}
+	return &commandPolicy{
+		enabled:   cfg.Enabled,
+		auditOnly: cfg.AuditOnly,
+		paths:     paths,
+		log:       log,
+	}
+}
+
+// Check returns an error when cmd may not run on serverID. Violations are
+// logged; in audit-only mode they are not blocked.
+func (p *commandPolicy) Check(serverID, cmd string) error {
+	if p == nil || !p.enabled {
+		return nil
+	}
+	return p.report(serverID, "command", cmd, p.violation(cmd))
+}
+
+// CheckHelper returns an error when a built-in helper script may not run
+// on serverID because a path substituted into it fails the path checks, as
+// a job ID carrying shell syntax would
+func (p *commandPolicy) CheckHelper(serverID, helper string, paths ...string) error {
+	if p == nil || !p.enabled {
+		return nil
+	}
+	for _, target := range paths {
+		if reason := p.checkPath(target); reason != "" {
+			return p.report(serverID, "helper", helper, reason)
+		}
+	}
+	return nil
+}
+
+// CheckPath returns an error when a file transfer may not write target on
+// serverID
+func (p *commandPolicy) CheckPath(serverID, target string) error {
+	if p == nil || !p.enabled {
+		return nil
+	}
+	return p.report(serverID, "path", target, p.checkPath(target))
+}
+
+// report logs a violation and returns the error for it unless the policy is
+// audit-only
+func (p *commandPolicy) report(serverID, field, value, reason string) error {
+	if reason == "" {
+		return nil
+	}
+
+	p.log.WithFields(logrus.Fields{
+		"serverID":  serverID,
+		field:       value,
+		"reason":    reason,
+		"auditOnly": p.auditOnly,
+	}).Warn("Remote setup command violates command policy")
+	if p.auditOnly {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", errCommandBlocked, reason)
+}
+
+// violation returns why cmd is not allowed, or an empty string
+func (p *commandPolicy) violation(cmd string) string {
+	tokens, reason := tokenize(cmd)
+	if reason != "" {
+		return reason
+	}
+
+	var simple []string
+	for i, token := range append(tokens, ";") {
+		switch token {
+		case "&&", "||", "|", ";", "{", "}":
+			if len(simple) > 0 {
+				if reason := p.checkSimple(simple); reason != "" {
+					return reason
+				}
+				simple = simple[:0]
+			} else if token != "{" && token != "}" && i != len(tokens) {
+				return fmt.Sprintf("empty command before %q", token)
+			}
+		default:
+			simple = append(simple, token)
+		}
+	}
+	return ""
+}
+
+// checkSimple checks one program invocation
+func (p *commandPolicy) checkSimple(words []string) string {
+	program, args := words[0], words[1:]
+
+	// Output redirection is only allowed for cat, which receives uploads and
+	// appends operator messages
+	var redirect string
+	if n := len(args); n >= 2 && (args[n-2] == ">" || args[n-2] == ">>") {
+		redirect, args = args[n-1], args[:n-2]
+		if program != "cat" {
+			return fmt.Sprintf("%s may not redirect output", program)
+		}
+		if reason := p.checkPath(redirect); reason != "" {
+			return reason
+		}
+	}
+	for _, arg := range args {
+		if arg == ">" || arg == ">>" {
+			return "misplaced redirection"
+		}
+	}
+
+	flagAndPaths := func(flags []string, min, max int) string {
+		if len(args) < 1+min || len(args) > 1+max || !slices.Contains(flags, args[0]) {
+			return fmt.Sprintf("%s is only allowed as %s %s <path>", program, program, strings.Join(flags, "|"))
+		}
+		for _, arg := range args[1:] {
+			if reason := p.checkPath(arg); reason != "" {
+				return reason
+			}
+		}
+		return ""
+	}
+
+	switch program {
+	case "mkdir":
+		return flagAndPaths([]string{"-p"}, 1, 1)
+	case "chmod":
+		return flagAndPaths([]string{"+x"}, 1, 1)
+	case "rm":
+		return flagAndPaths([]string{"-f"}, 1, 8)
+	case "mv":
+		return flagAndPaths([]string{"-f"}, 2, 2)
+	case "touch":
+		return flagAndPaths([]string{"-c"}, 1, 1)
+	case "test":
+		return flagAndPaths([]string{"-e", "-f", "-d", "-x"}, 1, 1)
+	case "[":
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			return "[ is missing its closing ]"
+		}
+		args = args[:len(args)-1]
+		return flagAndPaths([]string{"-e", "-f", "-d", "-x"}, 1, 1)
+	case "cat":
+		// Reads files the runner leaves, such as checkpoint progress
+		if redirect == "" && len(args) == 1 {
+			return p.checkPath(args[0])
+		}
+		if len(args) > 0 || redirect == "" {
+			return "cat is only allowed as cat <path> or cat >|>> <path>"
+		}
+		return ""
+	case "grep":
+		if len(args) != 2 || args[0] != "-q" || !safeArg.MatchString(args[1]) {
+			return "grep is only allowed as grep -q <version|checksum>"
+		}
+		return ""
+	case "sha256sum":
+		// Checksums a cached runner before it is trusted again
+		if len(args) != 1 {
+			return "sha256sum is only allowed as sha256sum <path>"
+		}
+		return p.checkPath(args[0])
+	case "exit":
+		if len(args) != 1 || !exitStatus.MatchString(args[0]) {
+			return "exit is only allowed with a status"
+		}
+		return ""
+	}
+
+	// The runner binary may report its version
+	if strings.HasPrefix(program, "/") {
+		if reason := p.checkPath(program); reason != "" {
+			return reason
+		}
+		if len(args) != 1 || args[0] != "version" {
+			return fmt.Sprintf("%s may only be run as %s version", program, program)
+		}
+		return ""
+	}
+	return fmt.Sprintf("program %q is not allowed", program)
+}
+
+// checkPath checks that a path is clean and under an allowed path
+func (p *commandPolicy) checkPath(target string) string {
+	if !safePath.MatchString(target) || path.Clean(target) != target {
+		return fmt.Sprintf("path %q is not a clean absolute path", target)
+	}
+	for _, allowed := range p.paths {
+		// The allowed path itself, a path it matches as a glob, or anything
+		// below either
+		for dir := target; dir != "/"; dir = path.Dir(dir) {
+			if ok, _ := path.Match(allowed, dir); ok || dir == allowed {
+				return ""
+			}
+		}
+	}
+	return fmt.Sprintf("path %q is outside the allowed paths", target)
+}
+
+// tokenize splits a command into words and the operators &&, ||, |, ;, >
+// and >>. Any quoting or expansion makes the command unsafe to check.
+func tokenize(cmd string) ([]string, string) {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	for i := 0; i < len(cmd); i++ {
+		c := cmd[i]
+		switch {
+		case c == ' ':
+			flush()
+		case c == ';':
+			flush()
+			tokens = append(tokens, ";")
+		case c == '>':
+			flush()
+			if i+1 < len(cmd) && cmd[i+1] == '>' {
+				tokens = append(tokens, ">>")
+				i++
+			} else {
+				tokens = append(tokens, ">")
+			}
+		case c == '&' || c == '|':
+			flush()
+			if i+1 < len(cmd) && cmd[i+1] == c {
+				tokens = append(tokens, string([]byte{c, c}))
+				i++
+			} else if c == '|' {
+				tokens = append(tokens, "|")
+			} else {
+				return nil, "background commands are not allowed"
+			}
+		case strings.IndexByte(unsafeWordChars, c) >= 0:
+			// [ and ] are allowed as whole words for test brackets
+			if (c == '[' || c == ']') && word.Len() == 0 && (i+1 == len(cmd) || cmd[i+1] == ' ' || cmd[i+1] == ';') {
+				tokens = append(tokens, string(c))
+				continue
+			}
+			return nil, fmt.Sprintf("character %q is not allowed", c)
+		default:
+			word.WriteByte(c)
+		}
+	}
+	flush()
+
+	if len(tokens) == 0 {
+		return nil, "empty command"
+	}
+	return tokens, ""
+}
+
+// runSetup runs a setup command in session once the command policy allows
+// it
+func (e *Executor) runSetup(conn *ssh.Client, session *ssh.Session, cmd string) error {
+	if err := e.policy.Check(conn.RemoteAddr().String(), cmd); err != nil {
+		return err
+	}
+	return session.Run(cmd)
+}
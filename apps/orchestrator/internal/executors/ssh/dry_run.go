@@ -0,0 +1,161 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// interpreterMissingStatus is the shell's exit status when the interpreter
+// of a syntax check is not installed on the server
+const interpreterMissingStatus = 127
+
+// syntaxCheck returns the file name a script is uploaded as and the command
+// that checks its syntax without running it
+func syntaxCheck(scriptType types.ScriptType) (string, string, error) {
+	switch scriptType {
+	case types.ScriptTypeBash:
+		return "script.sh", "bash -n script.sh", nil
+	case types.ScriptTypePython:
+		// py_compile writes bytecode next to the script, inside the
+		// temporary directory
+		return "script.py", "$(command -v python3 || echo python) -m py_compile script.py", nil
+	case types.ScriptTypeNode:
+		return "script.js", "node --check script.js", nil
+	}
+	return "", "", fmt.Errorf("dry run is not supported for script type %s", scriptType)
+}
+
+// executeDryRun uploads the script to a temporary directory on the server
+// and runs the interpreter's syntax check on it. Nothing the script does is
+// executed; the checker's diagnostics are reported as the job's output.
+func (e *Executor) executeDryRun(ctx context.Context, sess *Session, job *types.Job, updates chan<- types.ExecutionUpdate, timing *ExecutionTiming, executionID string) {
+	script := job.Execution.Script
+	file, check, err := syntaxCheck(script.Type)
+	if err != nil {
+		e.finishDryRun(job, updates, timing, executionID, -5, err.Error(), "", err)
+		return
+	}
+
+	e.sendUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
+		Status:  types.JobStatusRunning,
+		Message: "Checking script syntax",
+	})
+
+	// The script arrives on stdin; the directory goes whatever the result.
+	// The command is fixed apart from the script type, so it is exempt from
+	// the command policy grammar.
+	cmd := fmt.Sprintf(`dir=$(mktemp -d) || exit 1; cd "$dir" && cat > %s && %s; status=$?; cd /; rm -rf "$dir"; exit $status`, file, check)
+
+	var stdout, stderr bytes.Buffer
+	sess.session.Stdin = strings.NewReader(script.Content)
+	sess.session.Stdout = &stdout
+	sess.session.Stderr = &stderr
+
+	timing.MarkSetupComplete()
+	done := make(chan error, 1)
+	go func() {
+		done <- sess.session.Run(cmd)
+	}()
+
+	select {
+	case <-ctx.Done():
+		sess.session.Signal(ssh.SIGKILL)
+		timing.MarkExecutionComplete()
+		err := fmt.Errorf("syntax check did not finish: %w", ctx.Err())
+		e.finishDryRun(job, updates, timing, executionID, -2, err.Error(), stderr.String(), err)
+		return
+	case err = <-done:
+	}
+	timing.MarkExecutionComplete()
+
+	exitCode := 0
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitStatus()
+	} else if err != nil {
+		err = fmt.Errorf("syntax check failed to run: %w", err)
+		e.finishDryRun(job, updates, timing, executionID, -4, err.Error(), stderr.String(), err)
+		return
+	}
+
+	// Diagnostics are reported as output; a clean check prints nothing
+	sequence := int64(0)
+	for _, out := range []struct {
+		stream string
+		text   string
+	}{{"stdout", stdout.String()}, {"stderr", stderr.String()}} {
+		for _, line := range strings.Split(strings.TrimRight(out.text, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			sequence++
+			e.sendUpdate(updates, types.UpdateTypeLog, &types.LogEntry{
+				Stream:    out.stream,
+				Line:      line,
+				Timestamp: time.Now(),
+				Sequence:  sequence,
+			})
+		}
+	}
+
+	diagnostics := strings.TrimSpace(stderr.String() + stdout.String())
+	switch {
+	case exitCode == 0:
+		e.finishDryRun(job, updates, timing, executionID, 0, "Syntax check passed", diagnostics, nil)
+	case exitCode == interpreterMissingStatus:
+		err := types.NewExecutionError("environment", "INTERPRETER_NOT_FOUND",
+			fmt.Sprintf("the interpreter for %s scripts is not installed on server %s", script.Type, job.Execution.Target.ServerDetails.Name), false)
+		e.finishDryRun(job, updates, timing, executionID, exitCode, err.Error(), diagnostics, err)
+	default:
+		err := types.NewExecutionError("validation", "SYNTAX_ERROR", "script has syntax errors", false)
+		err.Details = map[string]interface{}{
+			"diagnostics": strings.Split(diagnostics, "\n"),
+			"check":       check,
+		}
+		e.finishDryRun(job, updates, timing, executionID, exitCode, "Syntax check failed", diagnostics, err)
+	}
+}
+
+// finishDryRun records the result of a syntax check on the execution and
+// completes the job. A nil err is a passed check.
+func (e *Executor) finishDryRun(job *types.Job, updates chan<- types.ExecutionUpdate, timing *ExecutionTiming, executionID string, exitCode int, message, diagnostics string, err error) {
+	status := types.JobStatusCompleted
+	if err != nil {
+		status = types.JobStatusFailed
+		e.sendError(updates, err, true)
+	}
+
+	totalDuration := time.Duration(timing.GetTotalDuration()) * time.Millisecond
+	e.metrics.RecordExecution(job.ID, err == nil, totalDuration, false)
+
+	if e.apiClient != nil {
+		timing.MarkCleanupComplete()
+		updateData := timing.ToExecutionStatusUpdate()
+		updateData.ExitCode = &exitCode
+		updateData.ExecutionMetadata["dryRun"] = true
+		if diagnostics != "" {
+			updateData.Error = &diagnostics
+		} else if err != nil {
+			updateData.Error = &message
+		}
+
+		apiCtx, apiCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer apiCancel()
+		if err := e.apiClient.UpdateExecution(apiCtx, executionID, status, updateData); err != nil {
+			e.log.WithError(err).Warn("Failed to update execution with syntax check result")
+		}
+	}
+
+	e.sendUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+		Status:   status,
+		ExitCode: &exitCode,
+		Message:  message,
+	})
+}
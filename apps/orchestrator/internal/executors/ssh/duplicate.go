@@ -0,0 +1,19 @@
+package ssh
+
+import (
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// duplicateExecutionStatus is the runner's exit status when it refuses to
+// start because the execution, or another execution of the same job, is
+// already running on the server
+const duplicateExecutionStatus = 73
+
+// duplicateExecutionError reports a runner that refused a duplicate
+// execution. It is not retried: the job is still running elsewhere.
+func duplicateExecutionError(job *types.Job) error {
+	return types.NewExecutionError("conflict", "DUPLICATE_EXECUTION",
+		fmt.Sprintf("job %s is already running on server %s", job.ID, job.Execution.Target.ServerDetails.Name), false)
+}
@@ -3,11 +3,11 @@ package ssh
 import (
 	"bufio"
 	"context"
+	"encoding/base64"
 	stderrors "errors"
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +15,12 @@ import (
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/auth"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/inputref"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/payload"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/recording"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runnerdist"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/retry"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
@@ -25,6 +31,7 @@ import (
 // RunnerInfo contains information about the runner binary
 type RunnerInfo struct {
 	Version  string
+	Arch     string
 	Path     string
 	Checksum string
 }
@@ -45,6 +52,12 @@ type Executor struct {
 	// Runner cache
 	runnerCache *RunnerCache
 
+	// Per-server runner version pins and staged rollout
+	runnerVersions *RunnerVersionResolver
+
+	// Detected runner architecture by server ID
+	runnerArches sync.Map
+
 	// Runtime API settings
 	runtimeHost string
 	runtimePort int
@@ -56,14 +69,40 @@ type Executor struct {
 
 	// Metrics
 	metrics *ExecutorMetrics
+
+	// Runtime cache pre-warming for API mode
+	prewarmer *runtimecache.Prewarmer
+
+	// Payload creation and storage
+	payloads *payload.Service
+	// Gathers the extra files jobs bundle into their payloads
+	payloadFiles *payload.FileCollector
+	// Checks and presigns the input objects the runner downloads
+	inputRefs *inputref.Resolver
+
+	// Session recordings for audit; nil when disabled
+	recordings *recording.Store
+
+	// Restricts the setup commands run on servers
+	policy *commandPolicy
+
+	// Feature flags switching optional features per job; nil leaves each
+	// to its configuration
+	flags *features.Registry
 }
 
 // Session represents an active SSH session
 type Session struct {
-	jobID      string
-	conn       *ssh.Client
-	session    *ssh.Session
-	cancelFunc context.CancelFunc
+	jobID       string
+	executionID string
+	serverID    string
+	conn        *ssh.Client
+	session     *ssh.Session
+	cancelFunc  context.CancelFunc
+
+	// Set when the session is stopped for a checkpoint
+	mu     sync.Mutex
+	resume *types.ResumeHints
 }
 
 // NewExecutor creates a new SSH executor
@@ -74,32 +113,70 @@ func NewExecutor(cfg config.SSHConfig, apiClient *api.Client, runtimeHost string
 	// Get runner binary info
 	runnerInfo := RunnerInfo{
 		Version:  getRunnerVersion(),
+		Arch:     defaultRunnerArch(),
 		Path:     getRunnerPath(),
 		Checksum: getRunnerChecksum(),
 	}
 
-	// Create runner cache
-	runnerCache := NewRunnerCache(log)
+	// Create runner cache, reloading what was verified before a restart
+	runnerCache := OpenRunnerCache(cfg.Runner.Cache, log)
 
 	// Create metrics tracker
 	metrics := NewExecutorMetrics(logrus.NewEntry(log).WithField("component", "ssh-executor"))
 
+	payloads, err := payload.NewService(cfg.Execution.PayloadStorageDir, cfg.Execution.PayloadStorage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure payload storage: %w", err)
+	}
+	if signing := cfg.Execution.PayloadSigning; signing.Enabled {
+		if err := payloads.WithSigningKey(signing.KeyFile); err != nil {
+			return nil, err
+		}
+		log.WithField("publicKey", base64.StdEncoding.EncodeToString(payloads.PublicKey())).
+			Info("Signing payloads; runners verify them when given this key in CRONIUM_PUBLIC_KEY")
+	}
+
+	recordings, err := recording.NewStore(cfg.Execution.Recording, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure session recording: %w", err)
+	}
+
+	pool.certs, err = newCertificateAuthority(cfg.Certificates, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure SSH certificates: %w", err)
+	}
+	pool.interactive, err = newInteractiveAuth(cfg.KeyboardInteractive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure keyboard-interactive authentication: %w", err)
+	}
+
 	return &Executor{
-		config:        cfg,
-		timeoutConfig: config.LoadTimeoutConfig(),
-		log:           log,
-		apiClient:     apiClient,
-		pool:          pool,
-		runnerInfo:    runnerInfo,
-		runnerCache:   runnerCache,
-		runtimeHost:   runtimeHost,
-		runtimePort:   runtimePort,
-		jwtSecret:     jwtSecret,
-		sessions:      make(map[string]*Session),
-		metrics:       metrics,
+		config:         cfg,
+		timeoutConfig:  config.LoadTimeoutConfig(),
+		log:            log,
+		apiClient:      apiClient,
+		pool:           pool,
+		runnerInfo:     runnerInfo,
+		runnerCache:    runnerCache,
+		runnerVersions: NewRunnerVersionResolver(cfg.Runner, runnerInfo, log),
+		runtimeHost:    runtimeHost,
+		runtimePort:    runtimePort,
+		jwtSecret:      jwtSecret,
+		sessions:       make(map[string]*Session),
+		metrics:        metrics,
+		payloads:       payloads,
+		payloadFiles:   payload.NewFileCollector(cfg.Execution.PayloadFiles),
+		inputRefs:      inputref.New(cfg.Execution.InputRefs),
+		recordings:     recordings,
+		policy:         newCommandPolicy(cfg.Security.CommandPolicy, cfg.Execution.TempDir, log),
 	}, nil
 }
 
+// flagOn reports whether a feature flag is on for a job
+func (e *Executor) flagOn(job *types.Job, name string) bool {
+	return e.flags == nil || e.flags.EnabledFor(job, name)
+}
+
 // Type returns the executor type
 func (e *Executor) Type() types.JobType {
 	return types.JobTypeSSH
@@ -123,6 +200,15 @@ func (e *Executor) Validate(job *types.Job) error {
 		}
 	}
 
+	if job.Execution.DryRun {
+		if job.Execution.Script == nil || job.Execution.Script.Content == "" {
+			return fmt.Errorf("dry run requires script content")
+		}
+		if _, _, err := syntaxCheck(job.Execution.Script.Type); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -136,6 +222,7 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 		// Initialize phase timing
 		timing := NewExecutionTiming()
 		timing.ServerName = job.Execution.Target.ServerDetails.Name
+		timing.Analysis = job.Metadata["analysis"]
 
 		// Send initial status
 		e.sendUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
@@ -163,7 +250,7 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 			if !executionExists {
 				serverID := job.Execution.Target.ServerDetails.ID
 				serverName := job.Execution.Target.ServerDetails.Name
-				if err := e.apiClient.CreateExecution(ctx, executionID, job.ID, &serverID, &serverName); err != nil {
+				if err := e.apiClient.CreateExecution(ctx, executionID, job, &serverID, &serverName); err != nil {
 					e.log.WithError(err).Warn("Failed to create execution record")
 					// Continue anyway - execution tracking is not critical for job success
 				}
@@ -259,10 +346,12 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 
 		// Track session
 		sess := &Session{
-			jobID:      job.ID,
-			conn:       conn,
-			session:    session,
-			cancelFunc: cancel,
+			jobID:       job.ID,
+			executionID: executionID,
+			serverID:    job.Execution.Target.ServerDetails.ID,
+			conn:        conn,
+			session:     session,
+			cancelFunc:  cancel,
 		}
 		e.trackSession(job.ID, sess)
 		defer e.untrackSession(job.ID)
@@ -273,7 +362,11 @@ func (e *Executor) Execute(ctx context.Context, job *types.Job) (<-chan types.Ex
 			e.pool.Put(serverKey, conn, true) // Return connection as healthy
 		}()
 
-		// Execute with runner
+		// Check the script's syntax only, or execute with runner
+		if job.Execution.DryRun {
+			e.executeDryRun(execCtx, sess, job, updates, timing, executionID)
+			return
+		}
 		e.executeWithRunner(execCtx, sess, job, updates, timing, timeout, executionID)
 	}()
 
@@ -285,7 +378,7 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 
 	// SETUP PHASE: Create or get payload path
 	timing.PayloadCreateStart = time.Now()
-	payloadPath, err := e.createPayloadForJob(job, executionID)
+	payloadPath, err := e.createPayloadForJob(ctx, job, executionID)
 	timing.PayloadCreateEnd = time.Now()
 	if err != nil {
 		payloadError := fmt.Errorf("failed to create payload: %w", err)
@@ -318,7 +411,9 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 
 	// SETUP PHASE: Ensure runner is deployed (create a new session for deployment)
 	timing.RunnerDeployStart = time.Now()
-	runnerPath := fmt.Sprintf("/tmp/cronium-runner-%s", e.runnerInfo.Version)
+	server := job.Execution.Target.ServerDetails
+	selection := e.runnerVersions.Resolve(ctx, server, e.runnerArch(sess.conn, server))
+	runnerPath := fmt.Sprintf("/tmp/cronium-runner-%s", selection.Runner.Version)
 	deploySession, err := sess.conn.NewSession()
 	if err != nil {
 		e.sendError(updates, fmt.Errorf("failed to create deployment session: %w", err), true)
@@ -326,7 +421,7 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 	}
 	defer deploySession.Close()
 
-	if err := e.ensureRunnerDeployed(ctx, deploySession, sess.conn, job.Execution.Target.ServerDetails, runnerPath); err != nil {
+	if err := e.ensureRunnerDeployed(ctx, deploySession, sess.conn, server, selection, runnerPath); err != nil {
 		timing.RunnerDeployEnd = time.Now()
 		deployError := fmt.Errorf("failed to deploy runner: %w", err)
 		e.sendError(updates, deployError, true)
@@ -365,16 +460,25 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 	}
 	defer verifySession.Close()
 	
-	if err := verifySession.Run(fmt.Sprintf("%s version", runnerPath)); err != nil {
+	versionCmd := fmt.Sprintf("%s version", runnerPath)
+	if err := e.policy.Check(sess.conn.RemoteAddr().String(), versionCmd); err != nil {
+		e.sendError(updates, fmt.Errorf("failed to verify runner: %w", err), true)
+		return
+	}
+	versionOutput, err := verifySession.Output(versionCmd)
+	if err != nil {
 		e.sendError(updates, fmt.Errorf("failed to verify runner: %w", err), true)
 		return
 	}
 	timing.RunnerVerifyEnd = time.Now()
+	features := parseRunnerFeatures(string(versionOutput))
+	e.checkRunnerVersion(server, selection, parseRunnerVersion(string(versionOutput)), features)
+	e.logMissingFeatures(job, server, features)
 
 	// Log runner version
 	e.sendUpdate(updates, types.UpdateTypeLog, &types.LogEntry{
 		Stream:    "system",
-		Line:      fmt.Sprintf("Using Cronium Runner version %s", e.runnerInfo.Version),
+		Line:      fmt.Sprintf("Using Cronium Runner version %s", selection.Runner.Version),
 		Timestamp: time.Now(),
 		Sequence:  1,
 	})
@@ -421,6 +525,13 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			} else {
 				apiEndpoint = tunnelManager.GetRemoteEndpoint()
 				apiToken = token
+
+				// Seed the runtime cache before the script starts calling helpers
+				prewarmCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+				if err := e.prewarmer.Prewarm(prewarmCtx, executionID, job); err != nil {
+					e.log.WithError(err).WithField("executionId", executionID).Warn("Failed to pre-warm runtime cache")
+				}
+				cancel()
 				e.log.WithFields(logrus.Fields{
 					"endpoint":    apiEndpoint,
 					"executionId": executionID,
@@ -434,17 +545,38 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 		defer tunnelManager.Stop()
 	}
 
-	// SETUP PHASE: Copy payload to server (create a new session for file transfer)
-	timing.PayloadTransferStart = time.Now()
-	remotePayloadPath := fmt.Sprintf("/tmp/cronium-payload-%s.tar.gz", job.ID)
-	copySession, err := sess.conn.NewSession()
-	if err != nil {
-		e.sendError(updates, fmt.Errorf("failed to create copy session: %w", err), true)
+	// SETUP PHASE: Check the server has room for the payload
+	if err := e.checkDiskSpace(sess.conn, job, payloadPath, timing); err != nil {
+		e.sendError(updates, err, true)
+
+		// Update execution record with the free space found
+		if e.apiClient != nil {
+			timing.MarkCleanupComplete() // Mark all phases as complete on error
+			updateData := timing.ToExecutionStatusUpdate()
+			exitCode := -7 // Indicate insufficient disk space
+			errorMsg := err.Error()
+			updateData.ExitCode = &exitCode
+			updateData.Error = &errorMsg
+
+			apiCtx, apiCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer apiCancel()
+			if err := e.apiClient.UpdateExecution(apiCtx, executionID, types.JobStatusFailed, updateData); err != nil {
+				e.log.WithError(err).Warn("Failed to update execution with disk space failure")
+			}
+		}
+
+		e.sendUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
+			Status:   types.JobStatusFailed,
+			ExitCode: intPtr(-7),
+			Message:  err.Error(),
+		})
 		return
 	}
-	defer copySession.Close()
 
-	if err := e.copyPayloadToServer(copySession, sess.conn, payloadPath, remotePayloadPath); err != nil {
+	// SETUP PHASE: Copy payload to server (create a new session for file transfer)
+	timing.PayloadTransferStart = time.Now()
+	remotePayloadPath := fmt.Sprintf("/tmp/cronium-payload-%s.tar.gz", job.ID)
+	if err := e.transferPayload(sess.conn, job, payloadPath, remotePayloadPath); err != nil {
 		timing.PayloadTransferEnd = time.Now()
 		e.sendError(updates, fmt.Errorf("failed to copy payload: %w", err), true)
 		return
@@ -455,7 +587,7 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 	defer func() {
 		cleanupSession, _ := sess.conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", remotePayloadPath))
+			e.runSetup(sess.conn, cleanupSession, fmt.Sprintf("rm -f %s %s.sig %s %s", remotePayloadPath, remotePayloadPath, remoteMessagesFile(job.ID), remoteUsageFile(job.ID)))
 			cleanupSession.Close()
 		}
 	}()
@@ -492,11 +624,13 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 
 	// Build the command with environment variables
 	var cmd string
+	runArgs := "run" + e.runArgs(job, sess.conn.User(), executionID, features)
 	if e.log.GetLevel() == logrus.DebugLevel {
-		cmd = fmt.Sprintf("%s --log-level=debug run %s", runnerPath, remotePayloadPath)
+		cmd = fmt.Sprintf("%s --log-level=debug %s %s", runnerPath, runArgs, remotePayloadPath)
 	} else {
-		cmd = fmt.Sprintf("%s run %s", runnerPath, remotePayloadPath)
+		cmd = fmt.Sprintf("%s %s %s", runnerPath, runArgs, remotePayloadPath)
 	}
+	cmd = e.withUsage(job.ID, cmd)
 
 	// Add environment variables using export
 	if len(envVars) > 0 {
@@ -507,6 +641,12 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 		cmd = fmt.Sprintf("%s && %s", strings.Join(exports, " && "), cmd)
 	}
 
+	// Record the session for audit; the token is only valid for this job
+	// but is masked like every other secret
+	rec := e.startRecording(job, executionID, apiToken)
+	defer rec.Close()
+	rec.Input(cmd)
+
 	// EXECUTION PHASE: Mark setup complete and start execution
 	timing.MarkSetupComplete()
 	if err := sess.session.Start(cmd); err != nil {
@@ -532,13 +672,13 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 	// Read stdout
 	go func() {
 		defer wg.Done()
-		e.streamOutputWithContextAndCollect(streamCtx, stdout, "stdout", updates, &sequence, &sequenceMu, &stdoutBuf, &outputMu)
+		e.streamOutputWithContextAndCollect(streamCtx, rec.Tee(stdout), "stdout", updates, &sequence, &sequenceMu, &stdoutBuf, &outputMu)
 	}()
 
 	// Read stderr
 	go func() {
 		defer wg.Done()
-		e.streamOutputWithContextAndCollect(streamCtx, stderr, "stderr", updates, &sequence, &sequenceMu, &stderrBuf, &outputMu)
+		e.streamOutputWithContextAndCollect(streamCtx, rec.Tee(stderr), "stderr", updates, &sequence, &sequenceMu, &stderrBuf, &outputMu)
 	}()
 
 	// Wait for command to complete or context cancellation
@@ -557,9 +697,26 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 		// First cancel the streaming goroutines
 		cancelStream()
 
-		// Then terminate the SSH session
+		// Then terminate the script's process group. The cancel file is
+		// written first so the script can see why it is being stopped.
+		// Signals on the session only reach the runner.
+		resume := sess.interrupted()
+		reason := "cancelled"
+		if resume != nil {
+			reason = "interrupted"
+		} else if stderrors.Is(ctx.Err(), context.DeadlineExceeded) {
+			reason = "timeout"
+		}
+		survivors, err := e.terminateRemoteProcessGroup(sess.conn, job.ID, reason)
 		sess.session.Signal(ssh.SIGTERM)
-		time.Sleep(5 * time.Second)
+		if err != nil {
+			e.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to terminate remote process group")
+		} else if len(survivors) > 0 {
+			e.log.WithFields(logrus.Fields{
+				"jobID":     job.ID,
+				"survivors": survivors,
+			}).Warn("Processes survived remote process group termination")
+		}
 		sess.session.Signal(ssh.SIGKILL)
 
 		// Determine if it was a timeout or cancellation
@@ -567,7 +724,17 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 		var finalStatus types.JobStatus
 		var statusMessage string
 
-		if stderrors.Is(ctx.Err(), context.DeadlineExceeded) {
+		if resume != nil {
+			e.log.WithFields(logrus.Fields{
+				"jobID":        job.ID,
+				"checkpointed": resume.CheckpointedAt != nil,
+			}).Warn("Execution interrupted by shutdown")
+			totalDuration := time.Duration(timing.GetTotalDuration()) * time.Millisecond
+			e.metrics.RecordExecution(job.ID, false, totalDuration, false)
+			exitCode = interruptedExitCode
+			finalStatus = types.JobStatusInterrupted
+			statusMessage = "SSH execution interrupted by orchestrator shutdown"
+		} else if stderrors.Is(ctx.Err(), context.DeadlineExceeded) {
 			e.log.WithField("jobID", job.ID).Warn("Execution timed out")
 			e.sendError(updates, fmt.Errorf("execution timed out after %v", timeout), true)
 			totalDuration := time.Duration(timing.GetTotalDuration()) * time.Millisecond
@@ -589,6 +756,13 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			timing.MarkCleanupComplete()
 			updateData := timing.ToExecutionStatusUpdate()
 			updateData.ExitCode = &exitCode
+			if len(survivors) > 0 {
+				updateData.ExecutionMetadata["survivingProcesses"] = survivors
+			}
+			if resume != nil {
+				updateData.ExecutionMetadata["resume"] = resume
+			}
+			e.finishRecording(rec, job, updateData.ExecutionMetadata)
 
 			// Include output collected so far
 			outputMu.Lock()
@@ -619,6 +793,7 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			Status:   finalStatus,
 			ExitCode: &exitCode,
 			Message:  statusMessage,
+			Resume:   resume,
 		})
 
 	case err := <-done:
@@ -637,6 +812,10 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			}
 		}
 
+		if exitCode == duplicateExecutionStatus {
+			e.sendError(updates, duplicateExecutionError(job), true)
+		}
+
 		// Record execution metrics
 		totalSeconds := time.Duration(timing.GetTotalDuration()) * time.Millisecond
 		e.metrics.RecordExecution(job.ID, exitCode == 0, totalSeconds, false)
@@ -652,6 +831,7 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			timing.MarkCleanupComplete()
 			updateData := timing.ToExecutionStatusUpdate()
 			updateData.ExitCode = &exitCode
+			e.finishRecording(rec, job, updateData.ExecutionMetadata)
 
 			// Include output if available
 			outputMu.Lock()
@@ -678,12 +858,13 @@ func (e *Executor) executeWithRunner(ctx context.Context, sess *Session, job *ty
 			Status:   status,
 			ExitCode: &exitCode,
 			Message:  fmt.Sprintf("Runner exited with code %d", exitCode),
+			Usage:    e.readRemoteUsage(sess.conn, job.ID),
 		})
 	}
 }
 
 // ensureRunnerDeployed checks if the runner is deployed and deploys it if necessary
-func (e *Executor) ensureRunnerDeployed(ctx context.Context, session *ssh.Session, conn *ssh.Client, server *types.ServerDetails, runnerPath string) error {
+func (e *Executor) ensureRunnerDeployed(ctx context.Context, session *ssh.Session, conn *ssh.Client, server *types.ServerDetails, selection RunnerSelection, runnerPath string) error {
 	// Configure retry for deployment
 	retryCfg := retry.Config{
 		MaxAttempts:  3,
@@ -699,7 +880,7 @@ func (e *Executor) ensureRunnerDeployed(ctx context.Context, session *ssh.Sessio
 
 	// Use retry utility for deployment attempts
 	err := retry.WithRetry(ctx, retryCfg, func() error {
-		deployErr := e.deployRunnerWithRetry(ctx, session, conn, server, runnerPath)
+		deployErr := e.deployRunnerWithRetry(ctx, session, conn, server, selection, runnerPath)
 		if deployErr != nil {
 			// Create typed SSH error for deployment failures
 			sshErr := errors.NewSSHError(
@@ -723,13 +904,14 @@ func (e *Executor) ensureRunnerDeployed(ctx context.Context, session *ssh.Sessio
 }
 
 // deployRunnerWithRetry performs a single deployment attempt
-func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Session, conn *ssh.Client, server *types.ServerDetails, runnerPath string) error {
+func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Session, conn *ssh.Client, server *types.ServerDetails, selection RunnerSelection, runnerPath string) error {
 	deployStart := time.Now()
+	runner := selection.Runner
 
 	// Check cache first
 	cachedEntry, isValid := e.runnerCache.Get(server.ID)
 	// In dev mode, always redeploy to ensure we have the latest runner
-	if isValid && cachedEntry.Version == e.runnerInfo.Version && e.runnerInfo.Version != "dev" {
+	if isValid && cachedEntry.Matches(&runner, runnerPath) && runner.Version != "dev" {
 		e.log.WithFields(logrus.Fields{
 			"serverID": server.ID,
 			"version":  cachedEntry.Version,
@@ -740,10 +922,13 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 
 	// If we have a cached entry but it needs verification
 	// Skip verification in dev mode to always redeploy
-	if cachedEntry != nil && cachedEntry.Version == e.runnerInfo.Version && e.runnerInfo.Version != "dev" {
-		// Quick version check
-		checkCmd := fmt.Sprintf("test -f %s && %s version | grep -q %s", runnerPath, runnerPath, e.runnerInfo.Version)
-		if err := session.Run(checkCmd); err == nil {
+	if cachedEntry != nil && cachedEntry.Matches(&runner, runnerPath) && runner.Version != "dev" {
+		// Compare the binary's checksum where known, else its version
+		checkCmd := fmt.Sprintf("test -f %s && %s version | grep -q %s", runnerPath, runnerPath, runner.Version)
+		if cachedEntry.Checksum != "" {
+			checkCmd = fmt.Sprintf("sha256sum %s | grep -q %s", runnerPath, cachedEntry.Checksum)
+		}
+		if err := e.runSetup(conn, session, checkCmd); err == nil {
 			// Runner still valid, update cache
 			e.runnerCache.UpdateVerified(server.ID)
 			e.log.Debug("Runner verified from cache")
@@ -755,15 +940,17 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 
 	// Check if runner exists and has correct version
 	// In dev mode, always redeploy
-	if e.runnerInfo.Version != "dev" {
-		checkCmd := fmt.Sprintf("test -f %s && %s version | grep -q %s", runnerPath, runnerPath, e.runnerInfo.Version)
-		if err := session.Run(checkCmd); err == nil {
+	if runner.Version != "dev" {
+		checkCmd := fmt.Sprintf("test -f %s && %s version | grep -q %s", runnerPath, runnerPath, runner.Version)
+		if err := e.runSetup(conn, session, checkCmd); err == nil {
 			// Runner exists and has correct version, add to cache
 			e.runnerCache.Set(server.ID, &RunnerCacheEntry{
 				ServerID:     server.ID,
 				RunnerPath:   runnerPath,
-				Version:      e.runnerInfo.Version,
-				Checksum:     e.runnerInfo.Checksum,
+				Version:      runner.Version,
+				Checksum:     runner.Checksum,
+				Wanted:       selection.Wanted,
+				Source:       selection.Source,
 				DeployedAt:   time.Now(),
 				LastVerified: time.Now(),
 			})
@@ -775,7 +962,7 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	// Need to deploy runner
 	e.log.WithFields(logrus.Fields{
 		"serverID": server.ID,
-		"version":  e.runnerInfo.Version,
+		"version":  runner.Version,
 	}).Info("Deploying runner to server")
 
 	// Ensure deployment directory exists
@@ -786,7 +973,7 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	defer mkdirSession.Close()
 
 	runnerDir := "/tmp/cronium"
-	if err := mkdirSession.Run(fmt.Sprintf("mkdir -p %s", runnerDir)); err != nil {
+	if err := e.runSetup(conn, mkdirSession, fmt.Sprintf("mkdir -p %s", runnerDir)); err != nil {
 		return fmt.Errorf("failed to create runner directory: %w", err)
 	}
 
@@ -798,12 +985,12 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	defer deploySession.Close()
 
 	// Copy runner binary with cleanup on failure
-	localRunnerPath := e.runnerInfo.Path
+	localRunnerPath := runner.Path
 	if err := e.copyFileToServer(deploySession, conn, localRunnerPath, runnerPath); err != nil {
 		// Clean up partial deployment
 		cleanupSession, _ := conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", runnerPath))
+			e.runSetup(conn, cleanupSession, fmt.Sprintf("rm -f %s", runnerPath))
 			cleanupSession.Close()
 		}
 		return fmt.Errorf("failed to copy runner binary: %w", err)
@@ -815,18 +1002,18 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 		// Clean up partial deployment
 		cleanupSession, _ := conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", runnerPath))
+			e.runSetup(conn, cleanupSession, fmt.Sprintf("rm -f %s", runnerPath))
 			cleanupSession.Close()
 		}
 		return fmt.Errorf("failed to create chmod session: %w", err)
 	}
 	defer chmodSession.Close()
 
-	if err := chmodSession.Run(fmt.Sprintf("chmod +x %s", runnerPath)); err != nil {
+	if err := e.runSetup(conn, chmodSession, fmt.Sprintf("chmod +x %s", runnerPath)); err != nil {
 		// Clean up partial deployment
 		cleanupSession, _ := conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", runnerPath))
+			e.runSetup(conn, cleanupSession, fmt.Sprintf("rm -f %s", runnerPath))
 			cleanupSession.Close()
 		}
 		return fmt.Errorf("failed to make runner executable: %w", err)
@@ -840,11 +1027,11 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	defer verifySession.Close()
 
 	verifyCmd := fmt.Sprintf("test -x %s && %s version", runnerPath, runnerPath)
-	if err := verifySession.Run(verifyCmd); err != nil {
+	if err := e.runSetup(conn, verifySession, verifyCmd); err != nil {
 		// Clean up failed deployment
 		cleanupSession, _ := conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", runnerPath))
+			e.runSetup(conn, cleanupSession, fmt.Sprintf("rm -f %s", runnerPath))
 			cleanupSession.Close()
 		}
 		return fmt.Errorf("failed to verify runner deployment: %w", err)
@@ -854,8 +1041,10 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	e.runnerCache.Set(server.ID, &RunnerCacheEntry{
 		ServerID:     server.ID,
 		RunnerPath:   runnerPath,
-		Version:      e.runnerInfo.Version,
-		Checksum:     e.runnerInfo.Checksum,
+		Version:      runner.Version,
+		Checksum:     runner.Checksum,
+		Wanted:       selection.Wanted,
+		Source:       selection.Source,
 		DeployedAt:   time.Now(),
 		LastVerified: time.Now(),
 	})
@@ -865,6 +1054,29 @@ func (e *Executor) deployRunnerWithRetry(ctx context.Context, session *ssh.Sessi
 	return nil
 }
 
+// checkRunnerVersion records the version and features a runner reports and
+// warns when the version differs from the one the server is pinned or rolled
+// out to
+func (e *Executor) checkRunnerVersion(server *types.ServerDetails, selection RunnerSelection, reported string, features runnerFeatures) {
+	if reported != "" {
+		e.runnerCache.SetReported(server.ID, reported, features)
+	}
+
+	if !selection.Mismatch() && (reported == "" || reported == selection.Runner.Version) {
+		return
+	}
+
+	e.metrics.RecordVersionMismatch()
+	e.log.WithFields(logrus.Fields{
+		"serverID": server.ID,
+		"server":   server.Name,
+		"wanted":   selection.Wanted,
+		"deployed": selection.Runner.Version,
+		"reported": reported,
+		"source":   selection.Source,
+	}).Warn("Runner version does not match configured version")
+}
+
 // copyPayloadToServer copies a payload file to the server
 func (e *Executor) copyPayloadToServer(session *ssh.Session, conn *ssh.Client, localPath, remotePath string) error {
 	// Read local file
@@ -873,36 +1085,7 @@ func (e *Executor) copyPayloadToServer(session *ssh.Session, conn *ssh.Client, l
 		return fmt.Errorf("failed to read payload file: %w", err)
 	}
 
-	// Create new session for copy
-	copySession, err := conn.NewSession()
-	if err != nil {
-		return fmt.Errorf("failed to create copy session: %w", err)
-	}
-	defer copySession.Close()
-
-	// Set up stdin pipe
-	stdin, err := copySession.StdinPipe()
-	if err != nil {
-		return fmt.Errorf("failed to create stdin pipe: %w", err)
-	}
-
-	// Start cat command
-	if err := copySession.Start(fmt.Sprintf("cat > %s", remotePath)); err != nil {
-		return fmt.Errorf("failed to start cat command: %w", err)
-	}
-
-	// Write data
-	if _, err := stdin.Write(data); err != nil {
-		return fmt.Errorf("failed to write data: %w", err)
-	}
-	stdin.Close()
-
-	// Wait for completion
-	if err := copySession.Wait(); err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
-	}
-
-	return nil
+	return e.uploadFile(conn, data, remotePath)
 }
 
 // copyFileToServer copies a file to the server using scp-like functionality
@@ -1008,12 +1191,23 @@ func (e *Executor) Cleanup(ctx context.Context, job *types.Job) error {
 			if err == nil {
 				e.log.WithField("jobID", job.ID).Debug("Cleaning up remote payload file")
 				cleanupCmd := fmt.Sprintf("rm -f %s", payloadPath)
-				if err := cleanupSession.Run(cleanupCmd); err != nil {
+				if err := e.runSetup(sess.conn, cleanupSession, cleanupCmd); err != nil {
 					e.log.WithError(err).Warn("Failed to clean up payload file")
 				}
 				cleanupSession.Close()
 			}
 
+			// Kill anything the runner left behind in the script's process group
+			survivors, err := e.terminateRemoteProcessGroup(sess.conn, job.ID, "cancelled")
+			if err != nil {
+				e.log.WithError(err).Warn("Failed to terminate remote process group")
+			} else if len(survivors) > 0 {
+				e.log.WithFields(logrus.Fields{
+					"jobID":     job.ID,
+					"survivors": survivors,
+				}).Warn("Processes survived remote process group termination")
+			}
+
 			// Return connection to pool
 			serverKey := fmt.Sprintf("%s:%d",
 				job.Execution.Target.ServerDetails.Host,
@@ -1084,24 +1278,31 @@ func getRunnerVersion() string {
 }
 
 func getRunnerPath() string {
-	// Get runner path based on architecture
-	arch := "linux-amd64" // Default
-	if runtime := os.Getenv("RUNNER_ARCH"); runtime != "" {
-		arch = runtime
-	}
+	return runnerPathForVersion(getRunnerVersion(), defaultRunnerArch())
+}
 
-	runnerDir := os.Getenv("RUNNER_ARTIFACTS_DIR")
-	if runnerDir == "" {
-		runnerDir = "/app/artifacts/runners"
+// defaultRunnerArch is the architecture deployed when a server's can't be
+// detected
+func defaultRunnerArch() string {
+	if arch := os.Getenv("RUNNER_ARCH"); arch != "" {
+		return arch
 	}
+	return runnerdist.DefaultArch
+}
 
-	version := getRunnerVersion()
-	return filepath.Join(runnerDir, version, fmt.Sprintf("cronium-runner-%s", arch))
+// runnerPathForVersion returns the local artifact path of a runner build
+func runnerPathForVersion(version, arch string) string {
+	return runnerdist.ArtifactPath(version, arch)
 }
 
 func getRunnerChecksum() string {
+	return runnerChecksum(getRunnerPath())
+}
+
+// runnerChecksum reads the checksum file next to a runner artifact
+func runnerChecksum(runnerPath string) string {
 	// In production, this would read the checksum file
-	checksumPath := getRunnerPath() + ".sha256"
+	checksumPath := runnerPath + ".sha256"
 	data, err := os.ReadFile(checksumPath)
 	if err != nil {
 		return ""
@@ -1113,6 +1314,17 @@ func getRunnerChecksum() string {
 	return ""
 }
 
+// runnerInfoForVersion describes the local artifact of a runner build
+func runnerInfoForVersion(version, arch string) RunnerInfo {
+	path := runnerPathForVersion(version, arch)
+	return RunnerInfo{
+		Version:  version,
+		Arch:     arch,
+		Path:     path,
+		Checksum: runnerChecksum(path),
+	}
+}
+
 // intPtr returns a pointer to an int value
 func intPtr(i int) *int {
 	return &i
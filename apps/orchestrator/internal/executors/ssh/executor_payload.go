@@ -1,7 +1,9 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/auth"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/payload"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"os"
@@ -9,7 +11,7 @@ import (
 )
 
 // createPayloadForJob creates a payload file for the job if it doesn't exist
-func (e *Executor) createPayloadForJob(job *types.Job, executionID string) (string, error) {
+func (e *Executor) createPayloadForJob(ctx context.Context, job *types.Job, executionID string) (string, error) {
 	// Check if payload already exists (for backwards compatibility)
 	if existingPath, ok := job.Metadata["payloadPath"].(string); ok && existingPath != "" {
 		// Legacy mode: payload created by cronium-app
@@ -17,9 +19,6 @@ func (e *Executor) createPayloadForJob(job *types.Job, executionID string) (stri
 		return existingPath, nil
 	}
 
-	// Create payload service
-	payloadService := payload.NewService(e.config.Execution.PayloadStorageDir)
-
 	// Extract script content from job
 	scriptContent := ""
 	scriptType := "BASH" // default
@@ -63,8 +62,48 @@ func (e *Executor) createPayloadForJob(job *types.Job, executionID string) (stri
 		metadata["userId"] = fmt.Sprintf("%v", userId)
 	}
 	metadata["timestamp"] = time.Now().Format(time.RFC3339)
+	metadata["attempt"] = job.Attempt()
+	metadata["maxAttempts"] = job.MaxAttempts()
+	metadata["idempotencyKey"] = job.IdempotencyKey()
+
+	// Bundled helpers serve input from the manifest
+	if len(job.Execution.InputData) > 0 {
+		metadata["inputData"] = job.Execution.InputData
+	}
+
+	// Large inputs are downloaded by the runner rather than bundled
+	if len(job.Execution.InputRefs) > 0 {
+		refs, err := e.inputRefs.Resolve(job.Execution.InputRefs)
+		if err != nil {
+			return "", err
+		}
+		metadata["inputRefs"] = refs
+		// Bounds the downloads, which run before the script's own timeout
+		metadata["timeout"] = job.GetTimeout().String()
+	}
+
+	// Let the runner push its results back if it ends up in bundled mode
+	if uploadURL, err := e.resultUploadURL(job, executionID); err != nil {
+		e.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to sign result upload URL")
+	} else if uploadURL != "" {
+		metadata["resultUploadUrl"] = uploadURL
+	}
+
+	// Extra files are rendered with what the script sees at run time
+	files, err := e.payloadFiles.Collect(ctx, job.Execution.Files, payload.TemplateData{
+		JobID:       job.ID,
+		ExecutionID: executionID,
+		Env:         environment,
+		Input:       job.Execution.InputData,
+		Parameters:  job.Execution.ParameterValues,
+	})
+	if err != nil {
+		return "", err
+	}
 
-	// Create payload data
+	// Create payload data; scripts sent by hash are restored from the
+	// server's script cache
+	scriptHash := e.scriptCacheHash(job)
 	payloadData := &payload.PayloadData{
 		JobID:         job.ID,
 		ExecutionID:   executionID,
@@ -72,10 +111,13 @@ func (e *Executor) createPayloadForJob(job *types.Job, executionID string) (stri
 		ScriptType:    scriptType,
 		Environment:   environment,
 		Metadata:      metadata,
+		ScriptHash:    scriptHash,
+		OmitScript:    scriptHash != "",
+		Files:         files,
 	}
 
 	// Create payload file
-	payloadPath, err := payloadService.CreatePayload(payloadData)
+	payloadPath, err := e.payloads.CreatePayload(payloadData)
 	if err != nil {
 		return "", fmt.Errorf("failed to create payload: %w", err)
 	}
@@ -83,11 +125,48 @@ func (e *Executor) createPayloadForJob(job *types.Job, executionID string) (stri
 	e.log.WithFields(map[string]interface{}{
 		"jobID":       job.ID,
 		"payloadPath": payloadPath,
+		"files":       len(files),
 	}).Debug("Created payload for job")
 
 	return payloadPath, nil
 }
 
+// readPayload returns the payload archive to transfer. Payloads created here
+// are read back from storage and decrypted, so the server receives the copy
+// that was stored and counted against the quota; legacy payloads from
+// cronium-app are read from their path.
+func (e *Executor) readPayload(job *types.Job, payloadPath string) ([]byte, error) {
+	if _, legacy := job.Metadata["payloadPath"]; legacy {
+		data, err := os.ReadFile(payloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payload file: %w", err)
+		}
+		return data, nil
+	}
+
+	data, err := e.payloads.ReadPayload(context.Background(), job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored payload: %w", err)
+	}
+	return data, nil
+}
+
+// resultUploadURL returns a signed result upload URL valid for the job's
+// timeout plus a grace period, or an empty string when uploads are disabled
+func (e *Executor) resultUploadURL(job *types.Job, executionID string) (string, error) {
+	cfg := e.config.Execution.ResultUpload
+	if !cfg.Enabled || e.jwtSecret == "" {
+		return "", nil
+	}
+
+	timeout := job.Execution.Timeout
+	if timeout <= 0 {
+		timeout = time.Hour
+	}
+
+	return auth.ResultUploadURL(e.jwtSecret, cfg.BaseURL, executionID, time.Now().Add(timeout+cfg.GracePeriod))
+}
+
 // cleanupPayload removes the payload file after job completion
 func (e *Executor) cleanupPayload(payloadPath string, job *types.Job) {
 	// Only cleanup if it's a local payload (not from cronium-app)
@@ -103,21 +182,17 @@ func (e *Executor) cleanupPayload(payloadPath string, job *types.Job) {
 		}
 	}
 
-	// Clean up based on configuration
+	// The staged copy is only needed for the transfer
+	e.payloads.ReleasePayload(payloadPath)
+
+	// Clean up the stored copy based on configuration
 	if e.config.Execution.CleanupPayloads {
-		// Actually remove the payload file
-		if err := os.Remove(payloadPath); err != nil && !os.IsNotExist(err) {
-			e.log.WithError(err).WithField("payloadPath", payloadPath).Warn("Failed to cleanup payload file")
+		if err := e.payloads.DeletePayload(context.Background(), job.ID); err != nil {
+			e.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to cleanup stored payload")
 		} else {
-			e.log.WithField("payloadPath", payloadPath).Debug("Cleaned up payload file")
-		}
-
-		// Also remove the checksum file
-		checksumPath := payloadPath + ".sha256"
-		if err := os.Remove(checksumPath); err != nil && !os.IsNotExist(err) {
-			e.log.WithError(err).WithField("checksumPath", checksumPath).Debug("Failed to cleanup checksum file")
+			e.log.WithField("jobID", job.ID).Debug("Cleaned up stored payload")
 		}
 	} else {
-		e.log.WithField("payloadPath", payloadPath).Debug("Keeping payload (cleanup disabled)")
+		e.log.WithField("jobID", job.ID).Debug("Keeping stored payload (cleanup disabled)")
 	}
 }
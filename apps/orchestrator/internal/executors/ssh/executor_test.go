@@ -1,8 +1,10 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -290,6 +292,48 @@ func TestRunnerCache(t *testing.T) {
 	assert.Nil(t, cached)
 }
 
+func TestRunnerCachePersistence(t *testing.T) {
+	log := logrus.New()
+	cfg := config.RunnerCacheConfig{File: filepath.Join(t.TempDir(), "runner-cache.json"), Freshness: time.Hour}
+
+	cache := OpenRunnerCache(cfg, log)
+	cache.Set("server1", &RunnerCacheEntry{
+		ServerID:     "server1",
+		RunnerPath:   "/tmp/cronium/cronium-runner-1.0.0",
+		Version:      "1.0.0",
+		Checksum:     "abc123",
+		DeployedAt:   time.Now(),
+		LastVerified: time.Now(),
+	})
+	cache.Set("server2", &RunnerCacheEntry{
+		ServerID:     "server2",
+		RunnerPath:   "/tmp/cronium/cronium-runner-1.0.0",
+		Version:      "1.0.0",
+		LastVerified: time.Now().Add(-2 * time.Hour),
+	})
+
+	// A restarted agent trusts fresh entries and verifies stale ones
+	reloaded := OpenRunnerCache(cfg, log)
+	require.Len(t, reloaded.Entries(), 2)
+	cached, valid := reloaded.Get("server1")
+	assert.True(t, valid)
+	assert.Equal(t, "abc123", cached.Checksum)
+	assert.True(t, cached.Matches(&RunnerInfo{Version: "1.0.0", Checksum: "abc123"}, "/tmp/cronium/cronium-runner-1.0.0"))
+	assert.False(t, cached.Matches(&RunnerInfo{Version: "1.0.0", Checksum: "def456"}, "/tmp/cronium/cronium-runner-1.0.0"))
+	_, valid = reloaded.Get("server2")
+	assert.False(t, valid)
+
+	assert.True(t, reloaded.Remove("server2"))
+	assert.False(t, reloaded.Remove("server2"))
+	assert.Len(t, OpenRunnerCache(cfg, log).Entries(), 1)
+
+	// An edited file is discarded rather than trusted
+	data, err := os.ReadFile(cfg.File)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(cfg.File, bytes.Replace(data, []byte("abc123"), []byte("def456"), 1), 0o640))
+	assert.Empty(t, OpenRunnerCache(cfg, log).Entries())
+}
+
 // TestMultiServerExecution tests parallel execution on multiple servers
 func TestMultiServerExecution(t *testing.T) {
 	// This test would require actual SSH servers or mocks
@@ -327,3 +371,34 @@ func createTestServer(id, host string) *types.ServerDetails {
 		PrivateKey: "test-key",
 	}
 }
+
+func TestParseStatsProbe(t *testing.T) {
+	output := "hz 100\nt0 250\nt1 300\np 2048 4096 8192\np 1024\n"
+
+	sample := parseStatsProbe(output)
+
+	assert.Equal(t, "remote", sample.Source)
+	assert.Equal(t, 2, sample.Processes)
+	assert.Equal(t, int64(3072*1024), sample.MemoryBytes)
+	assert.Equal(t, int64(4096), sample.DiskRead)
+	assert.Equal(t, int64(8192), sample.DiskWrite)
+	assert.InDelta(t, 50.0, sample.CPUPercent, 0.001)
+}
+
+func TestParseUsage(t *testing.T) {
+	usage, err := parseUsage("Command exited with non-zero status 3\ncronium-usage 4.00 1.50 0.50 20480 16 2048\n")
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, usage.CPUSeconds)
+	assert.InDelta(t, 50.0, usage.PeakCPU, 0.001)
+	assert.Equal(t, int64(20480*1024), usage.PeakMemory)
+	assert.Equal(t, int64(16*512), usage.DiskRead)
+	assert.Equal(t, int64(2048*512), usage.DiskWrite)
+
+	_, err = parseUsage("")
+	assert.Error(t, err)
+}
+
+func TestShellSafeReason(t *testing.T) {
+	assert.Equal(t, "cancelled by ops: INC-42", shellSafeReason("cancelled by ops: INC-42"))
+	assert.Equal(t, "x__ rm -rf __ __", shellSafeReason(`x'; rm -rf /; "$`))
+}
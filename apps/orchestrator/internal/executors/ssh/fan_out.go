@@ -0,0 +1,162 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// batchResult is the outcome of one batch of servers
+type batchResult struct {
+	Batch     int      `json:"batch"`
+	Of        int      `json:"of"`
+	Servers   []string `json:"servers"`
+	Succeeded int      `json:"succeeded"`
+	Failed    int      `json:"failed"`
+	Skipped   int      `json:"skipped"`
+}
+
+// serverRun runs the job on one server, returning once it has finished
+type serverRun func(ctx context.Context, index int, server *types.ServerDetails) *ServerResult
+
+// fanOutPlan returns the orchestrator's fan-out settings with the job's
+// overrides applied
+func fanOutPlan(defaults config.FanOutConfig, job *types.Job) config.FanOutConfig {
+	plan := defaults
+	f := job.Execution.FanOut
+	if f == nil {
+		return plan
+	}
+	if f.MaxParallel > 0 {
+		plan.MaxParallel = f.MaxParallel
+	}
+	if f.BatchSize > 0 {
+		plan.BatchSize = f.BatchSize
+	}
+	if f.BatchPause > 0 {
+		plan.BatchPause = f.BatchPause
+	}
+	if f.FailureThreshold > 0 {
+		plan.FailureThreshold = f.FailureThreshold
+	}
+	return plan
+}
+
+// fanOut runs servers in the plan's batches, calling onBatch as each batch
+// finishes, and returns the result of every server. Servers not started
+// because the job was cancelled or too many servers failed get a cancelled
+// result.
+func fanOut(ctx context.Context, plan config.FanOutConfig, servers []*types.ServerDetails, run serverRun, onBatch func(batchResult)) map[string]*ServerResult {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(map[string]*ServerResult, len(servers))
+	var mu sync.Mutex
+	failures := 0
+	tripped := false
+
+	size := plan.BatchSize
+	if size <= 0 || size > len(servers) {
+		size = len(servers)
+	}
+	total := 0
+	if size > 0 {
+		total = (len(servers) + size - 1) / size
+	}
+
+	for b := 0; b < total; b++ {
+		batch := servers[b*size : min((b+1)*size, len(servers))]
+		if b > 0 && plan.BatchPause > 0 {
+			select {
+			case <-runCtx.Done():
+			case <-time.After(plan.BatchPause):
+			}
+		}
+
+		parallel := plan.MaxParallel
+		if parallel <= 0 || parallel > len(batch) {
+			parallel = len(batch)
+		}
+		slots := make(chan struct{}, parallel)
+		var wg sync.WaitGroup
+		for i, server := range batch {
+			if runCtx.Err() == nil {
+				select {
+				case slots <- struct{}{}:
+				case <-runCtx.Done():
+				}
+			}
+			if runCtx.Err() != nil {
+				mu.Lock()
+				reason := "job cancelled"
+				if tripped {
+					reason = fmt.Sprintf("failure threshold of %d reached", plan.FailureThreshold)
+				}
+				results[server.ID] = skippedResult(server, reason)
+				mu.Unlock()
+				continue
+			}
+
+			wg.Add(1)
+			go func(index int, server *types.ServerDetails) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				result := run(runCtx, index, server)
+
+				mu.Lock()
+				defer mu.Unlock()
+				results[server.ID] = result
+				if result.succeeded() {
+					return
+				}
+				failures++
+				if plan.FailureThreshold > 0 && failures >= plan.FailureThreshold && !tripped {
+					tripped = true
+					cancel()
+				}
+			}(b*size+i, server)
+		}
+		wg.Wait()
+
+		summary := batchResult{Batch: b + 1, Of: total}
+		for _, server := range batch {
+			summary.Servers = append(summary.Servers, server.ID)
+			switch result := results[server.ID]; {
+			case result.skipped:
+				summary.Skipped++
+			case result.succeeded():
+				summary.Succeeded++
+			default:
+				summary.Failed++
+			}
+		}
+		if onBatch != nil {
+			onBatch(summary)
+		}
+	}
+	return results
+}
+
+// skippedResult is the result of a server that was never started
+func skippedResult(server *types.ServerDetails, reason string) *ServerResult {
+	now := time.Now()
+	return &ServerResult{
+		ServerID:   server.ID,
+		ServerName: server.Name,
+		Status:     types.JobStatusCancelled,
+		Error:      fmt.Errorf("not started: %s", reason),
+		StartTime:  now,
+		EndTime:    now,
+		skipped:    true,
+	}
+}
+
+// succeeded reports whether the server ran the job successfully
+func (r *ServerResult) succeeded() bool {
+	return r.Status == types.JobStatusCompleted && r.ExitCode == 0
+}
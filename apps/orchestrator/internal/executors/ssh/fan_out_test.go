@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fanOutServers(n int) []*types.ServerDetails {
+	servers := make([]*types.ServerDetails, n)
+	for i := range servers {
+		servers[i] = createTestServer(fmt.Sprintf("s%d", i), "localhost")
+	}
+	return servers
+}
+
+func TestFanOutMaxParallel(t *testing.T) {
+	var running, peak atomic.Int32
+	run := func(ctx context.Context, index int, server *types.ServerDetails) *ServerResult {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+		return &ServerResult{ServerID: server.ID, Status: types.JobStatusCompleted}
+	}
+
+	var batches []batchResult
+	results := fanOut(context.Background(), config.FanOutConfig{MaxParallel: 3}, fanOutServers(10), run, func(b batchResult) {
+		batches = append(batches, b)
+	})
+
+	assert.Len(t, results, 10)
+	assert.Equal(t, int32(3), peak.Load())
+	require.Len(t, batches, 1)
+	assert.Equal(t, 10, batches[0].Succeeded)
+}
+
+func TestFanOutBatches(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	run := func(ctx context.Context, index int, server *types.ServerDetails) *ServerResult {
+		mu.Lock()
+		order = append(order, server.ID)
+		mu.Unlock()
+		result := &ServerResult{ServerID: server.ID, Status: types.JobStatusCompleted}
+		if server.ID == "s3" {
+			result.Status = types.JobStatusFailed
+			result.ExitCode = 1
+		}
+		return result
+	}
+
+	var batches []batchResult
+	start := time.Now()
+	fanOut(context.Background(), config.FanOutConfig{BatchSize: 2, BatchPause: 20 * time.Millisecond}, fanOutServers(5), run, func(b batchResult) {
+		mu.Lock()
+		// A batch only starts once the previous one has finished
+		assert.Len(t, order, min(b.Batch*2, 5))
+		mu.Unlock()
+		batches = append(batches, b)
+	})
+
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.Equal(t, []batchResult{
+		{Batch: 1, Of: 3, Servers: []string{"s0", "s1"}, Succeeded: 2},
+		{Batch: 2, Of: 3, Servers: []string{"s2", "s3"}, Succeeded: 1, Failed: 1},
+		{Batch: 3, Of: 3, Servers: []string{"s4"}, Succeeded: 1},
+	}, batches)
+}
+
+func TestFanOutFailureThreshold(t *testing.T) {
+	var started atomic.Int32
+	run := func(ctx context.Context, index int, server *types.ServerDetails) *ServerResult {
+		started.Add(1)
+		if index < 2 {
+			return &ServerResult{ServerID: server.ID, Status: types.JobStatusFailed, ExitCode: 1}
+		}
+		// Later servers run until cancelled
+		<-ctx.Done()
+		return &ServerResult{ServerID: server.ID, Status: types.JobStatusCancelled}
+	}
+
+	var batches []batchResult
+	results := fanOut(context.Background(), config.FanOutConfig{MaxParallel: 3, BatchSize: 4, FailureThreshold: 2}, fanOutServers(8), run, func(b batchResult) {
+		batches = append(batches, b)
+	})
+
+	assert.Len(t, results, 8)
+	assert.LessOrEqual(t, started.Load(), int32(4))
+	assert.True(t, results["s7"].skipped)
+	assert.EqualError(t, results["s7"].Error, "not started: failure threshold of 2 reached")
+	require.Len(t, batches, 2)
+	assert.Equal(t, 4, batches[1].Skipped)
+	assert.Equal(t, 0, batches[0].Succeeded)
+}
+
+func TestFanOutPlan(t *testing.T) {
+	defaults := config.FanOutConfig{MaxParallel: 10, BatchPause: time.Second}
+	job := &types.Job{}
+	assert.Equal(t, defaults, fanOutPlan(defaults, job))
+
+	job.Execution.FanOut = &types.FanOut{BatchSize: 5, FailureThreshold: 1}
+	assert.Equal(t, config.FanOutConfig{MaxParallel: 10, BatchSize: 5, BatchPause: time.Second, FailureThreshold: 1}, fanOutPlan(defaults, job))
+}
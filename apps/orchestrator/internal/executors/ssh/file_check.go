@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// FileExists reports whether path exists on the job's target server
+func (e *Executor) FileExists(ctx context.Context, job *types.Job, path string) (bool, error) {
+	server := job.Execution.Target.ServerDetails
+	if server == nil {
+		return false, fmt.Errorf("job has no target server")
+	}
+
+	serverKey := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	conn, err := e.pool.Get(ctx, serverKey, server)
+	if err != nil {
+		return false, fmt.Errorf("SSH connection failed to %s: %w", serverKey, err)
+	}
+
+	exists, err := remoteFileExists(conn, path)
+	e.pool.Put(serverKey, conn, err == nil)
+	return exists, err
+}
+
+// FileExists reports whether path exists on every server of a multi-server
+// job, or on the single target otherwise
+func (m *MultiServerExecutor) FileExists(ctx context.Context, job *types.Job, path string) (bool, error) {
+	servers, ok := job.Metadata["servers"].([]interface{})
+	if !ok || len(servers) == 0 {
+		return m.executor.FileExists(ctx, job, path)
+	}
+
+	for _, serverData := range servers {
+		serverMap, ok := serverData.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		server, err := m.extractServerDetails(serverMap)
+		if err != nil {
+			return false, err
+		}
+
+		serverJob := *job
+		serverJob.Execution.Target.ServerDetails = server
+		exists, err := m.executor.FileExists(ctx, &serverJob, path)
+		if err != nil || !exists {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// remoteFileExists runs `test -e` on the server. The path may lie anywhere,
+// so the check is exempt from the command policy's allowed paths; it is
+// single-quoted and only tested for existence.
+func remoteFileExists(conn *ssh.Client, path string) (bool, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return false, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	quoted := "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	err = session.Run("test -e " + quoted)
+
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitStatus() == 1 {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check file: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,161 @@
+package ssh
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// promptResponder answers the keyboard-interactive prompts matching its
+// pattern
+type promptResponder struct {
+	prompt *regexp.Regexp
+	answer func(server *types.ServerDetails) (string, error)
+}
+
+// interactiveAuth answers keyboard-interactive prompts for the servers that
+// have responders configured, by server ID or name
+type interactiveAuth struct {
+	servers map[string][]promptResponder
+
+	// Replaced in tests
+	now func() time.Time
+}
+
+// newInteractiveAuth builds the responders of each server. It returns nil
+// when no server uses keyboard-interactive authentication.
+func newInteractiveAuth(cfgs []config.KeyboardInteractiveConfig) (*interactiveAuth, error) {
+	if len(cfgs) == 0 {
+		return nil, nil
+	}
+	a := &interactiveAuth{servers: make(map[string][]promptResponder), now: time.Now}
+	for _, cfg := range cfgs {
+		for _, rc := range cfg.Responders {
+			responder, err := a.newResponder(rc)
+			if err != nil {
+				return nil, fmt.Errorf("keyboard-interactive responder for %s: %w", cfg.Server, err)
+			}
+			a.servers[cfg.Server] = append(a.servers[cfg.Server], responder)
+		}
+	}
+	return a, nil
+}
+
+// newResponder builds a responder from its configuration
+func (a *interactiveAuth) newResponder(cfg config.PromptResponderConfig) (promptResponder, error) {
+	prompt, err := regexp.Compile("(?i)" + cfg.Prompt)
+	if err != nil {
+		return promptResponder{}, err
+	}
+	responder := promptResponder{prompt: prompt}
+
+	switch cfg.Type {
+	case "static":
+		responder.answer = func(*types.ServerDetails) (string, error) {
+			return cfg.Answer, nil
+		}
+	case "password":
+		responder.answer = func(server *types.ServerDetails) (string, error) {
+			if server.Password == "" {
+				return "", fmt.Errorf("server has no password")
+			}
+			return server.Password, nil
+		}
+	case "totp":
+		secret := strings.ToUpper(strings.Trim(strings.ReplaceAll(cfg.TOTPSecret, " ", ""), "="))
+		key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+		if err != nil {
+			return promptResponder{}, fmt.Errorf("invalid TOTP secret: %w", err)
+		}
+		digits, period := cfg.TOTPDigits, cfg.TOTPPeriod
+		if digits == 0 {
+			digits = 6
+		}
+		if period <= 0 {
+			period = 30 * time.Second
+		}
+		responder.answer = func(*types.ServerDetails) (string, error) {
+			return totp(key, a.now(), period, digits), nil
+		}
+	default:
+		return promptResponder{}, fmt.Errorf("unknown responder type %q", cfg.Type)
+	}
+	return responder, nil
+}
+
+// Has reports whether a server has responders configured
+func (a *interactiveAuth) Has(server *types.ServerDetails) bool {
+	return a.responders(server) != nil
+}
+
+// AuthMethod returns keyboard-interactive authentication for a server, or
+// nil when it has no responders
+func (a *interactiveAuth) AuthMethod(server *types.ServerDetails) ssh.AuthMethod {
+	responders := a.responders(server)
+	if responders == nil {
+		return nil
+	}
+	return ssh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			answered := false
+			for _, responder := range responders {
+				if !responder.prompt.MatchString(question) {
+					continue
+				}
+				answer, err := responder.answer(server)
+				if err != nil {
+					return nil, fmt.Errorf("failed to answer prompt %q: %w", question, err)
+				}
+				answers[i] = answer
+				answered = true
+				break
+			}
+			if !answered {
+				return nil, fmt.Errorf("no responder for keyboard-interactive prompt %q", question)
+			}
+		}
+		return answers, nil
+	})
+}
+
+// responders returns a server's responders by ID, then by name
+func (a *interactiveAuth) responders(server *types.ServerDetails) []promptResponder {
+	if a == nil {
+		return nil
+	}
+	if responders, ok := a.servers[server.ID]; ok && server.ID != "" {
+		return responders
+	}
+	if responders, ok := a.servers[server.Name]; ok && server.Name != "" {
+		return responders
+	}
+	return nil
+}
+
+// totp computes a time-based one-time password (RFC 6238) with HMAC-SHA1
+func totp(key []byte, now time.Time, period time.Duration, digits int) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(now.Unix()/int64(period/time.Second)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
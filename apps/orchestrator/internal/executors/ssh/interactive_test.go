@@ -0,0 +1,58 @@
+package ssh
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestTOTP(t *testing.T) {
+	// Test vectors from RFC 6238 for HMAC-SHA1
+	key := []byte("12345678901234567890")
+	for unix, code := range map[int64]string{
+		59:         "94287082",
+		1111111109: "07081804",
+		1234567890: "89005924",
+		2000000000: "69279037",
+	} {
+		assert.Equal(t, code, totp(key, time.Unix(unix, 0), 30*time.Second, 8))
+	}
+	assert.Equal(t, "287082", totp(key, time.Unix(59, 0), 30*time.Second, 6))
+}
+
+func TestKeyboardInteractiveResponders(t *testing.T) {
+	seed := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	auth, err := newInteractiveAuth([]config.KeyboardInteractiveConfig{{
+		Server: "bastion",
+		Responders: []config.PromptResponderConfig{
+			{Prompt: "^password:", Type: "password"},
+			{Prompt: "verification code", Type: "totp", TOTPSecret: seed},
+			{Prompt: "realm", Type: "static", Answer: "CORP"},
+		},
+	}})
+	require.NoError(t, err)
+	auth.now = func() time.Time { return time.Unix(59, 0) }
+
+	server := &types.ServerDetails{ID: "srv_1", Name: "bastion", Password: "pam-password"}
+	require.True(t, auth.Has(server))
+	assert.False(t, auth.Has(&types.ServerDetails{ID: "srv_2", Name: "web"}))
+
+	challenge, ok := auth.AuthMethod(server).(ssh.KeyboardInteractiveChallenge)
+	require.True(t, ok)
+
+	answers, err := challenge("", "", []string{"Password: ", "Verification code: ", "Realm: "}, []bool{false, true, true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pam-password", "287082", "CORP"}, answers)
+
+	_, err = challenge("", "", []string{"Security question: "}, []bool{true})
+	assert.ErrorContains(t, err, "no responder")
+
+	var disabled *interactiveAuth
+	assert.Nil(t, disabled.AuthMethod(server))
+}
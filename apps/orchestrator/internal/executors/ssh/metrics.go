@@ -19,6 +19,7 @@ type ExecutorMetrics struct {
 	successfulDeployments int64
 	failedDeployments     int64
 	cachedDeployments     int64
+	versionMismatches     int64
 
 	executionDurations  sync.Map // map[string]time.Duration
 	deploymentDurations sync.Map // map[string]time.Duration
@@ -72,6 +73,12 @@ func (m *ExecutorMetrics) RecordDeployment(serverID string, success bool, cached
 	m.deploymentDurations.Store(serverID, duration)
 }
 
+// RecordVersionMismatch records a server running a different runner version
+// than it is configured for
+func (m *ExecutorMetrics) RecordVersionMismatch() {
+	atomic.AddInt64(&m.versionMismatches, 1)
+}
+
 // GetStats returns current metrics
 func (m *ExecutorMetrics) GetStats() map[string]interface{} {
 	total := atomic.LoadInt64(&m.totalExecutions)
@@ -83,6 +90,7 @@ func (m *ExecutorMetrics) GetStats() map[string]interface{} {
 	successDeploy := atomic.LoadInt64(&m.successfulDeployments)
 	failedDeploy := atomic.LoadInt64(&m.failedDeployments)
 	cachedDeploy := atomic.LoadInt64(&m.cachedDeployments)
+	mismatches := atomic.LoadInt64(&m.versionMismatches)
 
 	successRate := float64(0)
 	if total > 0 {
@@ -108,6 +116,7 @@ func (m *ExecutorMetrics) GetStats() map[string]interface{} {
 			"failed":       failedDeploy,
 			"cached":       cachedDeploy,
 			"cacheHitRate": cacheHitRate,
+			"mismatches":   mismatches,
 		},
 	}
 }
@@ -132,6 +141,7 @@ func (m *ExecutorMetrics) Reset() {
 	atomic.StoreInt64(&m.successfulDeployments, 0)
 	atomic.StoreInt64(&m.failedDeployments, 0)
 	atomic.StoreInt64(&m.cachedDeployments, 0)
+	atomic.StoreInt64(&m.versionMismatches, 0)
 
 	m.executionDurations = sync.Map{}
 	m.deploymentDurations = sync.Map{}
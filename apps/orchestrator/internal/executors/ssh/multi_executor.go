@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/payload"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/resolver"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runnerdist"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/sirupsen/logrus"
 )
@@ -18,6 +22,10 @@ type MultiServerExecutor struct {
 	executor  *Executor
 	log       *logrus.Logger
 	apiClient *api.Client
+	fanOut    config.FanOutConfig
+	sticky    config.StickyTargetConfig
+	// Server each event with a sticky target last ran on
+	lastServers *stickyTargets
 }
 
 // NewMultiServerExecutor creates a new multi-server SSH executor
@@ -28,12 +36,47 @@ func NewMultiServerExecutor(cfg config.SSHConfig, apiClient *api.Client, runtime
 	}
 
 	return &MultiServerExecutor{
-		executor:  executor,
-		log:       log,
-		apiClient: apiClient,
+		executor:    executor,
+		log:         log,
+		apiClient:   apiClient,
+		fanOut:      cfg.FanOut,
+		sticky:      cfg.StickyTarget,
+		lastServers: newStickyTargets(cfg.StickyTarget, log),
 	}, nil
 }
 
+// WithPrewarmer enables pushing execution data into the runtime cache before
+// scripts start in API mode
+func (m *MultiServerExecutor) WithPrewarmer(p *runtimecache.Prewarmer) {
+	m.executor.prewarmer = p
+}
+
+// WithRunnerReleases fetches runner builds missing locally, and with
+// auto-update the default version, from the release channel
+func (m *MultiServerExecutor) WithRunnerReleases(d *runnerdist.Distributor) {
+	m.executor.runnerVersions.releases = d
+}
+
+// WithFeatures lets feature flags switch optional features off per job
+func (m *MultiServerExecutor) WithFeatures(r *features.Registry) {
+	m.executor.flags = r
+}
+
+// WithResolver resolves server hosts through the caching resolver
+func (m *MultiServerExecutor) WithResolver(r *resolver.Resolver) {
+	m.executor.pool.resolver = r
+}
+
+// Payloads returns the service creating and storing job payloads
+func (m *MultiServerExecutor) Payloads() *payload.Service {
+	return m.executor.payloads
+}
+
+// RunnerCache returns the runners known to be deployed on servers
+func (m *MultiServerExecutor) RunnerCache() *RunnerCache {
+	return m.executor.runnerCache
+}
+
 // Type returns the executor type
 func (m *MultiServerExecutor) Type() types.JobType {
 	return types.JobTypeSSH
@@ -68,87 +111,167 @@ func (m *MultiServerExecutor) Execute(ctx context.Context, job *types.Job) (<-ch
 		return m.executor.Execute(ctx, job)
 	}
 
+	// A job with a sticky target runs on one of its servers
+	if job.Execution.StickyTarget != nil {
+		return m.executeSticky(ctx, job, m.targetServers(servers))
+	}
+
 	// Create aggregated updates channel
 	updates := make(chan types.ExecutionUpdate, 100*len(servers))
+	plan := fanOutPlan(m.fanOut, job)
 
-	// Create wait group for parallel execution
-	var wg sync.WaitGroup
-
-	// Track results from each server
-	results := make(map[string]*ServerResult)
-	resultsMu := sync.Mutex{}
-
-	// Execute on all servers in parallel
 	go func() {
 		defer close(updates)
 
+		targets := m.targetServers(servers)
+
 		// Send initial status
+		message := fmt.Sprintf("Starting execution on %d servers", len(targets))
+		if plan.BatchSize > 0 && plan.BatchSize < len(targets) {
+			message += fmt.Sprintf(" in batches of %d", plan.BatchSize)
+		}
+		if plan.MaxParallel > 0 {
+			message += fmt.Sprintf(", at most %d at a time", plan.MaxParallel)
+		}
 		m.sendUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
 			Status:  types.JobStatusRunning,
-			Message: fmt.Sprintf("Starting execution on %d servers", len(servers)),
+			Message: message,
 		})
 
-		// Start execution on each server
-		for i, serverData := range servers {
-			serverMap, ok := serverData.(map[string]interface{})
-			if !ok {
-				m.log.Warn("Invalid server data in job metadata")
-				continue
-			}
+		run := func(ctx context.Context, idx int, server *types.ServerDetails) *ServerResult {
+			// Generate unique execution ID for this server
+			executionID := fmt.Sprintf("exec_%s_%s_%d", job.ID, server.ID, time.Now().Unix())
 
-			// Extract server details
-			serverDetails, err := m.extractServerDetails(serverMap)
-			if err != nil {
-				m.log.WithError(err).Warn("Failed to extract server details")
-				continue
+			// Create execution record for this server
+			if m.apiClient != nil {
+				if err := m.apiClient.CreateExecution(ctx, executionID, job, &server.ID, &server.Name); err != nil {
+					m.log.WithError(err).WithField("serverID", server.ID).Warn("Failed to create execution record")
+				}
 			}
 
-			wg.Add(1)
-			go func(idx int, server *types.ServerDetails) {
-				defer wg.Done()
+			// Create a copy of the job for this server
+			serverJob := *job
+			serverJob.Execution.Target.ServerDetails = server
 
-				// Generate unique execution ID for this server
-				executionID := fmt.Sprintf("exec_%s_%s_%d", job.ID, server.ID, time.Now().Unix())
+			// Pass execution ID in metadata to prevent duplicate creation
+			serverJob.Metadata = make(map[string]any, len(job.Metadata)+1)
+			for k, v := range job.Metadata {
+				serverJob.Metadata[k] = v
+			}
+			serverJob.Metadata["executionId"] = executionID
 
-				// Create execution record for this server
-				if m.apiClient != nil {
-					if err := m.apiClient.CreateExecution(ctx, executionID, job.ID, &server.ID, &server.Name); err != nil {
-						m.log.WithError(err).WithField("serverID", server.ID).Warn("Failed to create execution record")
-					}
-				}
+			// Execute on this server
+			serverResult := m.executeOnServer(ctx, &serverJob, idx, len(targets), executionID)
 
-				// Create a copy of the job for this server
-				serverJob := *job
-				serverJob.Execution.Target.ServerDetails = server
+			// Forward updates with server prefix
+			for update := range serverResult.Updates {
+				m.forwardUpdate(updates, update, server)
+			}
+			return serverResult
+		}
 
-				// Pass execution ID in metadata to prevent duplicate creation
-				if serverJob.Metadata == nil {
-					serverJob.Metadata = make(map[string]any)
-				}
-				serverJob.Metadata["executionId"] = executionID
+		var batches []batchResult
+		results := fanOut(ctx, plan, targets, run, func(b batchResult) {
+			batches = append(batches, b)
+			if b.Of > 1 {
+				m.sendUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
+					Status: types.JobStatusRunning,
+					Message: fmt.Sprintf("Batch %d/%d finished: %d succeeded, %d failed, %d not started",
+						b.Batch, b.Of, b.Succeeded, b.Failed, b.Skipped),
+				})
+			}
+		})
 
-				// Execute on this server
-				serverResult := m.executeOnServer(ctx, &serverJob, idx, len(servers), executionID)
+		// Aggregate results
+		m.aggregateResults(updates, results, batches)
+	}()
 
-				// Store result
-				resultsMu.Lock()
-				results[server.ID] = serverResult
-				resultsMu.Unlock()
+	return updates, nil
+}
 
-				// Forward updates with server prefix
-				for update := range serverResult.Updates {
-					m.forwardUpdate(updates, update, server)
-				}
-			}(i, serverDetails)
+// targetServers extracts the servers of a multi-server job from its
+// metadata, skipping invalid ones
+func (m *MultiServerExecutor) targetServers(servers []interface{}) []*types.ServerDetails {
+	var targets []*types.ServerDetails
+	for _, serverData := range servers {
+		serverMap, ok := serverData.(map[string]interface{})
+		if !ok {
+			m.log.Warn("Invalid server data in job metadata")
+			continue
 		}
 
-		// Wait for all executions to complete
-		wg.Wait()
+		// Extract server details
+		serverDetails, err := m.extractServerDetails(serverMap)
+		if err != nil {
+			m.log.WithError(err).Warn("Failed to extract server details")
+			continue
+		}
+		targets = append(targets, serverDetails)
+	}
+	return targets
+}
 
-		// Aggregate results
-		m.aggregateResults(updates, results)
-	}()
+// executeSticky runs a job with a sticky target on the server its event
+// last ran on, or on the first reachable server when that one is unknown,
+// expired or unreachable
+func (m *MultiServerExecutor) executeSticky(ctx context.Context, job *types.Job, targets []*types.ServerDetails) (<-chan types.ExecutionUpdate, error) {
+	eventID := ""
+	if v, ok := job.Metadata["eventId"]; ok && v != nil {
+		eventID = fmt.Sprintf("%v", v)
+	}
+	ttl := m.sticky.TTL
+	if job.Execution.StickyTarget.TTL > 0 {
+		ttl = job.Execution.StickyTarget.TTL
+	}
+
+	preferred := ""
+	if eventID != "" {
+		preferred, _ = m.lastServers.Get(eventID, ttl)
+	}
+	probe := func(ctx context.Context, server *types.ServerDetails) error {
+		probeCtx, cancel := context.WithTimeout(ctx, m.sticky.ProbeTimeout)
+		defer cancel()
+		return m.executor.pool.Probe(probeCtx, server)
+	}
+	server, fellBack, err := pickSticky(ctx, targets, preferred, probe)
+	if err != nil {
+		return nil, err
+	}
 
+	var warning string
+	if fellBack {
+		warning = fmt.Sprintf("Server %s this event last ran on is unreachable, running on %s instead", preferred, server.Name)
+		m.log.WithFields(logrus.Fields{
+			"jobID":     job.ID,
+			"eventID":   eventID,
+			"preferred": preferred,
+			"serverID":  server.ID,
+		}).Warn("Sticky server unreachable, falling back to another server")
+	}
+	if eventID != "" {
+		m.lastServers.Record(eventID, server.ID, ttl)
+	}
+
+	serverJob := *job
+	serverID := server.ID
+	serverJob.Execution.Target = types.Target{Type: types.TargetTypeServer, ServerID: &serverID, ServerDetails: server}
+	serverUpdates, err := m.executor.Execute(ctx, &serverJob)
+	if err != nil || warning == "" {
+		return serverUpdates, err
+	}
+
+	// Put the fallback warning in front of the server's updates
+	updates := make(chan types.ExecutionUpdate, 100)
+	go func() {
+		defer close(updates)
+		m.sendUpdate(updates, types.UpdateTypeStatus, &types.StatusUpdate{
+			Status:  types.JobStatusRunning,
+			Message: warning,
+		})
+		for update := range serverUpdates {
+			updates <- update
+		}
+	}()
 	return updates, nil
 }
 
@@ -164,6 +287,11 @@ type ServerResult struct {
 	Error       error
 	StartTime   time.Time
 	EndTime     time.Time
+	// Checkpoint left by an execution interrupted by shutdown
+	Resume *types.ResumeHints
+	// Never started, because the job was cancelled or too many servers
+	// failed first
+	skipped bool
 }
 
 // executeOnServer executes the job on a single server
@@ -236,6 +364,7 @@ func (m *MultiServerExecutor) executeOnServer(ctx context.Context, job *types.Jo
 					if status.ExitCode != nil {
 						result.ExitCode = *status.ExitCode
 					}
+					result.Resume = status.Resume
 					result.EndTime = time.Now()
 
 					// Update execution record with final status
@@ -316,7 +445,7 @@ func (m *MultiServerExecutor) extractServerDetails(serverMap map[string]interfac
 	}
 
 	// Ensure at least one auth method is provided
-	if details.PrivateKey == "" && details.Password == "" {
+	if details.PrivateKey == "" && details.Password == "" && m.executor.pool.certs == nil && !m.executor.pool.interactive.Has(details) {
 		return nil, fmt.Errorf("missing server authentication: neither privateKey nor password provided")
 	}
 
@@ -351,9 +480,9 @@ func (m *MultiServerExecutor) forwardUpdate(updates chan<- types.ExecutionUpdate
 }
 
 // aggregateResults aggregates results from all servers
-func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpdate, results map[string]*ServerResult) {
+func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpdate, results map[string]*ServerResult, batches []batchResult) {
 	// Count successes and failures
-	var successCount, failureCount, timeoutCount int
+	var successCount, failureCount, timeoutCount, skippedCount int
 	var totalExitCode int
 	var aggregatedOutput strings.Builder
 
@@ -366,6 +495,10 @@ func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpd
 		if result.Status == types.JobStatusCompleted && result.ExitCode == 0 {
 			successCount++
 			aggregatedOutput.WriteString(fmt.Sprintf("  Status: SUCCESS (exit code: %d)\n", result.ExitCode))
+		} else if result.skipped {
+			skippedCount++
+			failureCount++
+			aggregatedOutput.WriteString(fmt.Sprintf("  Status: NOT STARTED (%v)\n", result.Error))
 		} else if result.Status == types.JobStatusTimeout || result.ExitCode == -1 {
 			timeoutCount++
 			failureCount++
@@ -373,6 +506,10 @@ func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpd
 			if result.ExitCode != 0 {
 				totalExitCode = result.ExitCode
 			}
+		} else if result.Status == types.JobStatusInterrupted {
+			failureCount++
+			aggregatedOutput.WriteString("  Status: INTERRUPTED\n")
+			totalExitCode = result.ExitCode
 		} else {
 			failureCount++
 			aggregatedOutput.WriteString(fmt.Sprintf("  Status: FAILED (exit code: %d)\n", result.ExitCode))
@@ -418,6 +555,9 @@ func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpd
 		overallStatus = types.JobStatusCompleted
 		statusMessage = fmt.Sprintf("PARTIAL SUCCESS: %d succeeded, %d failed (including %d timeouts) out of %d servers",
 			successCount, failureCount, timeoutCount, len(results))
+		if skippedCount > 0 {
+			statusMessage += fmt.Sprintf(", %d not started", skippedCount)
+		}
 		// Use a special exit code to indicate partial success
 		totalExitCode = 100 + failureCount // e.g., 101 means 1 server failed
 	}
@@ -434,7 +574,9 @@ func (m *MultiServerExecutor) aggregateResults(updates chan<- types.ExecutionUpd
 				"successCount": successCount,
 				"failureCount": failureCount,
 				"timeoutCount": timeoutCount,
+				"skippedCount": skippedCount,
 				"totalServers": len(results),
+				"batches":      batches,
 				"results":      m.formatResults(results),
 				"summary":      aggregatedOutput.String(),
 			},
@@ -461,6 +603,9 @@ func (m *MultiServerExecutor) formatResults(results map[string]*ServerResult) []
 		if result.Error != nil {
 			entry["error"] = result.Error.Error()
 		}
+		if result.Resume != nil {
+			entry["resume"] = result.Resume
+		}
 
 		formatted = append(formatted, entry)
 	}
@@ -0,0 +1,155 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/payload"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// catServer is an SSH server that only runs "cat > <path>", keeping the
+// files it receives in memory
+type catServer struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func (s *catServer) file(path string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[path]
+}
+
+// startCatServer listens on a local port and returns a client connected to it
+func startCatServer(t *testing.T) (*catServer, *ssh.Client) {
+	t.Helper()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(hostKey)
+	require.NoError(t, err)
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	server := &catServer{files: make(map[string][]byte)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, serverConfig)
+		}
+	}()
+
+	client, err := ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func (s *catServer) serve(conn net.Conn, serverConfig *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, serverConfig)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				cmd := string(req.Payload[4:])
+				target, ok := strings.CutPrefix(cmd, "cat > ")
+				req.Reply(ok, nil)
+				if !ok {
+					return
+				}
+				data, _ := io.ReadAll(channel)
+				s.mu.Lock()
+				s.files[target] = data
+				s.mu.Unlock()
+				channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				return
+			}
+		}()
+	}
+}
+
+func TestTransferPayloadUploadsSignature(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	dir := t.TempDir()
+
+	payloads, err := payload.NewService(dir, config.PayloadStorageConfig{Backend: "local"})
+	require.NoError(t, err)
+	require.NoError(t, payloads.WithSigningKey(filepath.Join(dir, "keys", "payload-signing.key")))
+
+	cfg := config.SSHConfig{}
+	cfg.Execution.Transfer.Method = "cat"
+	e := &Executor{
+		config:   cfg,
+		log:      log,
+		payloads: payloads,
+		policy:   newCommandPolicy(config.CommandPolicyConfig{Enabled: true}, "/tmp", log),
+	}
+
+	job := &types.Job{ID: "job-1", Type: types.JobTypeSSH}
+	payloadPath, err := payloads.CreatePayload(&payload.PayloadData{
+		JobID:         job.ID,
+		ExecutionID:   "exec-1",
+		ScriptContent: "echo signed",
+		ScriptType:    "BASH",
+	})
+	require.NoError(t, err)
+	assert.FileExists(t, payloadPath+".sig")
+
+	server, client := startCatServer(t)
+	remotePath := "/tmp/cronium-payload-job-1.tar.gz"
+	require.NoError(t, e.transferPayload(client, job, payloadPath, remotePath))
+
+	// The server holds what a runner trusting the public key verifies
+	archive := server.file(remotePath)
+	require.NotEmpty(t, archive)
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(server.file(remotePath + ".sig"))))
+	require.NoError(t, err)
+	assert.True(t, ed25519.Verify(payloads.PublicKey(), archive, sig))
+
+	tampered := append([]byte{}, archive...)
+	tampered[len(tampered)-1] ^= 0xff
+	assert.False(t, ed25519.Verify(payloads.PublicKey(), tampered, sig))
+
+	// A restarted orchestrator keeps signing with the same key
+	reloaded, err := payload.NewService(dir, config.PayloadStorageConfig{Backend: "local"})
+	require.NoError(t, err)
+	require.NoError(t, reloaded.WithSigningKey(filepath.Join(dir, "keys", "payload-signing.key")))
+	assert.Equal(t, payloads.PublicKey(), reloaded.PublicKey())
+}
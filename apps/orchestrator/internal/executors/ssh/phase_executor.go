@@ -73,7 +73,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 
 	// SETUP PHASE: Create payload
 	timing.PayloadCreateStart = time.Now()
-	payloadPath, err := e.createPayloadForJob(job, executionID)
+	payloadPath, err := e.createPayloadForJob(setupCtx, job, executionID)
 	timing.PayloadCreateEnd = time.Now()
 
 	if err != nil {
@@ -92,7 +92,8 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 
 	// SETUP PHASE: Deploy runner
 	timing.RunnerDeployStart = time.Now()
-	runnerPath := fmt.Sprintf("/tmp/cronium-runner-%s", e.runnerInfo.Version)
+	selection := e.runnerVersions.Resolve(setupCtx, server, e.runnerArch(conn, server))
+	runnerPath := fmt.Sprintf("/tmp/cronium-runner-%s", selection.Runner.Version)
 	deploySession, err := conn.NewSession()
 	if err != nil {
 		e.sendError(updates, fmt.Errorf("failed to create deployment session: %w", err), true)
@@ -104,7 +105,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 	}
 	defer deploySession.Close()
 
-	if err := e.ensureRunnerDeployed(setupCtx, deploySession, conn, server, runnerPath); err != nil {
+	if err := e.ensureRunnerDeployed(setupCtx, deploySession, conn, server, selection, runnerPath); err != nil {
 		timing.RunnerDeployEnd = time.Now()
 		if setupCtx.Err() == context.DeadlineExceeded {
 			e.sendError(updates, fmt.Errorf("setup timeout exceeded while deploying runner"), true)
@@ -118,22 +119,23 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 		return
 	}
 	timing.RunnerDeployEnd = time.Now()
+	features := e.runnerFeaturesFor(conn, server, selection, runnerPath)
 
-	// SETUP PHASE: Transfer payload
-	timing.PayloadTransferStart = time.Now()
-	remotePayloadPath := fmt.Sprintf("/tmp/cronium-payload-%s.tar.gz", job.ID)
-	copySession, err := conn.NewSession()
-	if err != nil {
-		e.sendError(updates, fmt.Errorf("failed to create copy session: %w", err), true)
+	// SETUP PHASE: Check the server has room for the payload
+	if err := e.checkDiskSpace(conn, job, payloadPath, timing); err != nil {
+		e.sendError(updates, err, true)
 		e.sendUpdate(updates, types.UpdateTypeComplete, &types.StatusUpdate{
-			Status:  types.JobStatusFailed,
-			Message: "Setup phase failed: copy session",
+			Status:   types.JobStatusFailed,
+			ExitCode: intPtr(-7),
+			Message:  "Setup phase failed: insufficient disk space on target",
 		})
 		return
 	}
-	defer copySession.Close()
 
-	if err := e.copyPayloadToServer(copySession, conn, payloadPath, remotePayloadPath); err != nil {
+	// SETUP PHASE: Transfer payload
+	timing.PayloadTransferStart = time.Now()
+	remotePayloadPath := fmt.Sprintf("/tmp/cronium-payload-%s.tar.gz", job.ID)
+	if err := e.transferPayload(conn, job, payloadPath, remotePayloadPath); err != nil {
 		timing.PayloadTransferEnd = time.Now()
 		if setupCtx.Err() == context.DeadlineExceeded {
 			e.sendError(updates, fmt.Errorf("setup timeout exceeded while transferring payload"), true)
@@ -155,7 +157,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 		
 		cleanupSession, _ := conn.NewSession()
 		if cleanupSession != nil {
-			cleanupSession.Run(fmt.Sprintf("rm -f %s", remotePayloadPath))
+			e.runSetup(conn, cleanupSession, fmt.Sprintf("rm -f %s %s.sig", remotePayloadPath, remotePayloadPath))
 			cleanupSession.Close()
 		}
 		
@@ -195,7 +197,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 		"payload":    remotePayloadPath,
 	}).Info("Starting script execution phase")
 
-	exitCode := e.runScriptWithTimeout(execCtx, session, conn, runnerPath, remotePayloadPath, job, updates, executionID, timing, execTimeout)
+	exitCode := e.runScriptWithTimeout(execCtx, session, conn, runnerPath, remotePayloadPath, job, features, updates, executionID, timing, execTimeout)
 
 	// Mark execution as complete
 	timing.MarkExecutionComplete()
@@ -211,6 +213,11 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 	} else if exitCode == 0 {
 		finalStatus = types.JobStatusCompleted
 		statusMessage = "Script executed successfully"
+	} else if exitCode == duplicateExecutionStatus {
+		err := duplicateExecutionError(job)
+		e.sendError(updates, err, true)
+		finalStatus = types.JobStatusFailed
+		statusMessage = err.Error()
 	} else {
 		finalStatus = types.JobStatusFailed
 		statusMessage = fmt.Sprintf("Script exited with code %d", exitCode)
@@ -235,7 +242,7 @@ func (e *Executor) executeWithPhaseTimeouts(ctx context.Context, job *types.Job,
 }
 
 // runScriptWithTimeout executes the script with the given timeout
-func (e *Executor) runScriptWithTimeout(ctx context.Context, session *ssh.Session, conn *ssh.Client, runnerPath, payloadPath string, job *types.Job, updates chan types.ExecutionUpdate, executionID string, timing *ExecutionTiming, timeout time.Duration) int {
+func (e *Executor) runScriptWithTimeout(ctx context.Context, session *ssh.Session, conn *ssh.Client, runnerPath, payloadPath string, job *types.Job, features runnerFeatures, updates chan types.ExecutionUpdate, executionID string, timing *ExecutionTiming, timeout time.Duration) int {
 	// Set up pipes for stdout and stderr
 	stdout, err := session.StdoutPipe()
 	if err != nil {
@@ -308,10 +315,17 @@ func (e *Executor) runScriptWithTimeout(ctx context.Context, session *ssh.Sessio
 
 	// Build the command with environment variables
 	var cmd string
+	var runArgs string
+	if features[featureScriptCache] {
+		runArgs += e.scriptCacheArgs(job)
+	}
+	if features[featureSnapshot] {
+		runArgs += e.snapshotArgs(job)
+	}
 	if e.log.GetLevel() == logrus.DebugLevel {
-		cmd = fmt.Sprintf("%s --log-level=debug run %s", runnerPath, payloadPath)
+		cmd = fmt.Sprintf("%s --log-level=debug run%s %s", runnerPath, runArgs, payloadPath)
 	} else {
-		cmd = fmt.Sprintf("%s run %s", runnerPath, payloadPath)
+		cmd = fmt.Sprintf("%s run%s %s", runnerPath, runArgs, payloadPath)
 	}
 
 	// Add environment variables using export
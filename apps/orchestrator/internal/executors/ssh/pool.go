@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/resolver"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/retry"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
@@ -19,6 +20,15 @@ type ConnectionPool struct {
 	config config.ConnectionPoolConfig
 	log    *logrus.Logger
 
+	// Resolves server hosts; nil uses the system resolver
+	resolver *resolver.Resolver
+
+	// Mints user certificates; nil when certificate authentication is off
+	certs *certificateAuthority
+
+	// Answers keyboard-interactive prompts; nil when no server needs it
+	interactive *interactiveAuth
+
 	mu          sync.RWMutex
 	connections map[string]*poolEntry
 
@@ -81,6 +91,18 @@ func (p *ConnectionPool) Get(ctx context.Context, serverKey string, server *type
 	return conn, nil
 }
 
+// Probe checks that a server accepts a connection, leaving it pooled for
+// the job that follows
+func (p *ConnectionPool) Probe(ctx context.Context, server *types.ServerDetails) error {
+	serverKey := fmt.Sprintf("%s:%d", server.Host, server.Port)
+	conn, err := p.Get(ctx, serverKey, server)
+	if err != nil {
+		return err
+	}
+	p.Put(serverKey, conn, true)
+	return nil
+}
+
 // Put returns a connection to the pool
 func (p *ConnectionPool) Put(serverKey string, conn *ssh.Client, healthy bool) {
 	p.mu.Lock()
@@ -118,6 +140,19 @@ func (p *ConnectionPool) createConnection(ctx context.Context, server *types.Ser
 	// Build auth methods based on available credentials
 	var authMethods []ssh.AuthMethod
 
+	// Try a CA-signed certificate first; the server's own credentials are
+	// the fallback
+	if p.certs != nil {
+		method, err := p.certs.AuthMethod(ctx, server)
+		if err == nil {
+			authMethods = append(authMethods, method)
+		} else if server.Password == "" && server.PrivateKey == "" && !p.interactive.Has(server) {
+			return nil, fmt.Errorf("failed to get SSH certificate: %w", err)
+		} else {
+			p.log.WithError(err).WithField("server", server.Name).Warn("Failed to get SSH certificate, falling back to server credentials")
+		}
+	}
+
 	// Try password authentication if password is provided
 	if server.Password != "" {
 		authMethods = append(authMethods, ssh.Password(server.Password))
@@ -149,9 +184,14 @@ func (p *ConnectionPool) createConnection(ctx context.Context, server *types.Ser
 		}
 	}
 
+	// Answer keyboard-interactive prompts, such as an OTP after the key
+	if method := p.interactive.AuthMethod(server); method != nil {
+		authMethods = append(authMethods, method)
+	}
+
 	// Ensure we have at least one auth method
 	if len(authMethods) == 0 {
-		return nil, fmt.Errorf("no authentication method available: neither password nor private key provided")
+		return nil, fmt.Errorf("no authentication method available: neither certificate, password nor private key provided")
 	}
 
 	// SSH client configuration
@@ -191,7 +231,7 @@ func (p *ConnectionPool) createConnection(ctx context.Context, server *types.Ser
 
 		resChan := make(chan result, 1)
 		go func() {
-			c, e := ssh.Dial("tcp", addr, config)
+			c, e := p.dial(addr, config)
 			resChan <- result{c, e}
 		}()
 
@@ -222,6 +262,29 @@ func (p *ConnectionPool) createConnection(ctx context.Context, server *types.Ser
 	return conn, nil
 }
 
+// dial opens an SSH connection to addr, resolving the host through the
+// caching resolver
+func (p *ConnectionPool) dial(addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+
+	conn, err := p.resolver.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
 // addConnection adds a connection to the pool
 func (p *ConnectionPool) addConnection(serverKey string, conn *ssh.Client) {
 	p.mu.Lock()
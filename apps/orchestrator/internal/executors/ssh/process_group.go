@@ -0,0 +1,174 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// defaultCancelGracePeriod is how long the remote process group gets to exit
+// after SIGTERM before SIGKILL is sent, unless configured otherwise
+const defaultCancelGracePeriod = 5 * time.Second
+
+// remotePGIDFile returns the path where the runner records the script's
+// process group ID. It lives in a directory of the SSH user's that the runner
+// creates 0700, so other users on the host cannot plant or replace it.
+func remotePGIDFile(user, jobID string) string {
+	return fmt.Sprintf("/tmp/cronium-runner-%s/%s.pgid", user, jobID)
+}
+
+// pgidFileCheck is the shell test the helper scripts run before trusting a
+// process group file: a regular file owned by the SSH user in a directory
+// owned by the SSH user
+const pgidFileCheck = `[ -f %[1]s ] && [ ! -L %[1]s ] && [ -O %[1]s ] && [ -O %[2]s ] && [ ! -L %[2]s ]`
+
+// remoteCancelFile returns the path of the file that tells the script it is
+// being cancelled; the runner exports it as CRONIUM_CANCEL_FILE
+func remoteCancelFile(jobID string) string {
+	return fmt.Sprintf("/tmp/cronium-runner-%s.cancel", jobID)
+}
+
+// remoteMessagesFile returns the path of the file operator messages are
+// appended to; the runner exports it as CRONIUM_MESSAGES_FILE
+func remoteMessagesFile(jobID string) string {
+	return fmt.Sprintf("/tmp/cronium-runner-%s.messages", jobID)
+}
+
+// cancelGracePeriod returns the time scripts get between SIGTERM and SIGKILL
+func (e *Executor) cancelGracePeriod() time.Duration {
+	if e.config.Execution.CancelGracePeriod > 0 {
+		return e.config.Execution.CancelGracePeriod
+	}
+	return defaultCancelGracePeriod
+}
+
+// Cancel stops a job's script on the remote host
+func (m *MultiServerExecutor) Cancel(ctx context.Context, job *types.Job, reason string) error {
+	return m.executor.Cancel(ctx, job, reason)
+}
+
+// Cancel sends SIGTERM to the job's remote process group and SIGKILL once
+// the cancel grace period has passed
+func (e *Executor) Cancel(ctx context.Context, job *types.Job, reason string) error {
+	e.mu.RLock()
+	sess, exists := e.sessions[job.ID]
+	e.mu.RUnlock()
+
+	if !exists || sess.conn == nil {
+		return fmt.Errorf("no active SSH session for job %s", job.ID)
+	}
+
+	// A caller that gives up leaves the termination running; the job's own
+	// cleanup terminates whatever is left of the group again
+	result := make(chan error, 1)
+	go func() {
+		survivors, err := e.terminateRemoteProcessGroup(sess.conn, job.ID, reason)
+		if err == nil && len(survivors) > 0 {
+			err = fmt.Errorf("processes %v survived SIGKILL", survivors)
+		}
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DeliverMessage appends an operator message to a job's messages file
+func (m *MultiServerExecutor) DeliverMessage(ctx context.Context, job *types.Job, message []byte) error {
+	return m.executor.DeliverMessage(ctx, job, message)
+}
+
+// DeliverMessage appends an operator message to the job's messages file on
+// the remote host. The message goes through stdin, so it needs no quoting.
+func (e *Executor) DeliverMessage(ctx context.Context, job *types.Job, message []byte) error {
+	e.mu.RLock()
+	sess, exists := e.sessions[job.ID]
+	e.mu.RUnlock()
+
+	if !exists || sess.conn == nil {
+		return fmt.Errorf("no active SSH session for job %s", job.ID)
+	}
+
+	if err := e.runWithInput(sess.conn, "cat >> "+remoteMessagesFile(job.ID), append(message, '\n')); err != nil {
+		return fmt.Errorf("failed to write messages file: %w", err)
+	}
+	return nil
+}
+
+// terminateRemoteProcessGroup kills the script's process group on the remote
+// host and returns the PIDs of any processes that survived SIGKILL. The cancel
+// file is written before SIGTERM so the script can tell why it is stopping. A
+// process group file the SSH user does not own is ignored.
+func (e *Executor) terminateRemoteProcessGroup(conn *ssh.Client, jobID, reason string) ([]int, error) {
+	pgidFile := remotePGIDFile(conn.User(), jobID)
+	cancelFile := remoteCancelFile(jobID)
+	if err := e.policy.CheckHelper(conn.RemoteAddr().String(), "terminate", pgidFile, cancelFile); err != nil {
+		return nil, err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	// The grace loop exits early once no process in the group is left, and
+	// pgrep lists whatever is still alive after SIGKILL
+	reason = shellSafeReason(reason)
+	grace := int(e.cancelGracePeriod().Seconds())
+	script := fmt.Sprintf(`%[5]s || { rm -f %[3]s; exit 0; }
+pgid=$(cat %[1]s 2>/dev/null)
+case "$pgid" in ''|*[!0-9]*|0|1) rm -f %[3]s; exit 0;; esac
+printf '{"reason":"%[4]s","gracePeriod":%[2]d,"requestedAt":"%%s"}' "$(date -u +%%Y-%%m-%%dT%%H:%%M:%%SZ)" > %[3]s
+kill -TERM -- -$pgid 2>/dev/null
+i=0
+while [ $i -lt %[2]d ] && kill -0 -- -$pgid 2>/dev/null; do sleep 1; i=$((i+1)); done
+kill -KILL -- -$pgid 2>/dev/null && sleep 1
+pgrep -g $pgid 2>/dev/null
+rm -f %[1]s %[3]s
+exit 0`, pgidFile, grace, cancelFile, reason, fmt.Sprintf(pgidFileCheck, pgidFile, path.Dir(pgidFile)))
+
+	output, err := session.Output(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to terminate process group: %w", err)
+	}
+
+	return parseSurvivorPIDs(string(output)), nil
+}
+
+// shellSafeReason reduces a cancel reason, which may come from the backend
+// or an operator, to characters that need no quoting in the termination
+// script or the cancel file's JSON
+func shellSafeReason(reason string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune(" _.,:-", r):
+			return r
+		}
+		return '_'
+	}, reason)
+}
+
+// parseSurvivorPIDs parses newline-separated PIDs from pgrep output
+func parseSurvivorPIDs(output string) []int {
+	var pids []int
+	for _, line := range strings.Split(output, "\n") {
+		pid, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || pid <= 0 {
+			continue
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
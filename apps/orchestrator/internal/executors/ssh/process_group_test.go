@@ -0,0 +1,54 @@
+package ssh
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPGIDFileCheck(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cronium-runner-deploy")
+	require.NoError(t, os.Mkdir(dir, 0700))
+	pgidFile := filepath.Join(dir, "job_1.pgid")
+	require.NoError(t, os.WriteFile(pgidFile, []byte("4242"), 0600))
+
+	trusted := func(file string) bool {
+		return exec.Command("sh", "-c", fmt.Sprintf(pgidFileCheck, file, filepath.Dir(file))).Run() == nil
+	}
+	assert.True(t, trusted(pgidFile))
+	assert.False(t, trusted(filepath.Join(dir, "missing.pgid")))
+
+	// A planted link could point the kill at any process group
+	link := filepath.Join(dir, "job_2.pgid")
+	require.NoError(t, os.Symlink(pgidFile, link))
+	assert.False(t, trusted(link))
+
+	if os.Geteuid() == 0 {
+		require.NoError(t, os.Chown(pgidFile, 65534, 65534))
+		assert.False(t, trusted(pgidFile))
+	}
+}
+
+func TestParseSurvivorPIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []int
+	}{
+		{"empty", "", nil},
+		{"blank lines", "\n\n", nil},
+		{"single", "4242\n", []int{4242}},
+		{"multiple", "101\n102\r\n 103 \n", []int{101, 102, 103}},
+		{"malformed lines skipped", "pgrep: warning\n17\nabc\n-5\n0\n18", []int{17, 18}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseSurvivorPIDs(tt.output))
+		})
+	}
+}
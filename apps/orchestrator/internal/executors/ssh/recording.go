@@ -0,0 +1,43 @@
+package ssh
+
+import (
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/recording"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// startRecording starts the session recording of a job when its tenant is
+// recorded. Values in secrets are masked in the recording. A recording that
+// cannot be started is logged and the job runs unrecorded.
+func (e *Executor) startRecording(job *types.Job, executionID string, secrets ...string) *recording.Recorder {
+	if !e.recordings.Enabled(job) {
+		return nil
+	}
+	rec, err := e.recordings.Start(job, executionID, secrets...)
+	if err != nil {
+		e.log.WithError(err).WithField("jobID", job.ID).Error("Failed to start session recording")
+		return nil
+	}
+	return rec
+}
+
+// finishRecording closes a job's session recording and adds it to the
+// execution metadata
+func (e *Executor) finishRecording(rec *recording.Recorder, job *types.Job, metadata map[string]interface{}) {
+	info, err := rec.Close()
+	if err != nil {
+		e.log.WithError(err).WithField("jobID", job.ID).Error("Failed to finish session recording")
+		return
+	}
+	if info == nil {
+		return
+	}
+	e.log.WithFields(logrus.Fields{
+		"jobID":     job.ID,
+		"recording": info.Path,
+		"size":      info.Size,
+	}).Debug("Session recording finished")
+	if metadata != nil {
+		metadata["recording"] = info
+	}
+}
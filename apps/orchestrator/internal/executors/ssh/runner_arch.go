@@ -0,0 +1,75 @@
+package ssh
+
+import (
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// unameMachines maps `uname -m` output to Go architecture names
+var unameMachines = map[string]string{
+	"x86_64":  "amd64",
+	"amd64":   "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"armv7l":  "arm",
+	"armv6l":  "arm",
+	"i686":    "386",
+	"i386":    "386",
+	"ppc64le": "ppc64le",
+	"s390x":   "s390x",
+	"riscv64": "riscv64",
+}
+
+// runnerArch returns the runner architecture of a server, detected with uname
+// on first use. Servers whose platform can't be detected get the default
+// architecture.
+func (e *Executor) runnerArch(conn *ssh.Client, server *types.ServerDetails) string {
+	if arch, ok := e.runnerArches.Load(server.ID); ok {
+		return arch.(string)
+	}
+
+	arch, err := e.detectRunnerArch(conn)
+	if err != nil {
+		e.log.WithError(err).WithField("serverID", server.ID).Debug("Failed to detect server architecture, using default runner architecture")
+		return e.runnerInfo.Arch
+	}
+	if arch == "" {
+		e.log.WithField("serverID", server.ID).Warn("Unsupported server architecture, using default runner architecture")
+		arch = e.runnerInfo.Arch
+	}
+	e.runnerArches.Store(server.ID, arch)
+	return arch
+}
+
+func (e *Executor) detectRunnerArch(conn *ssh.Client) (string, error) {
+	cmd := "uname -sm"
+	if err := e.policy.Check(conn.RemoteAddr().String(), cmd); err != nil {
+		return "", err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	out, err := session.Output(cmd)
+	if err != nil {
+		return "", err
+	}
+	return parseUname(string(out)), nil
+}
+
+// parseUname turns `uname -sm` output such as "Linux aarch64" into a runner
+// architecture such as linux-arm64, or "" for unsupported platforms
+func parseUname(output string) string {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return ""
+	}
+	machine, ok := unameMachines[strings.ToLower(fields[1])]
+	if !ok {
+		return ""
+	}
+	return strings.ToLower(fields[0]) + "-" + machine
+}
@@ -1,39 +1,105 @@
 package ssh
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
 	"github.com/sirupsen/logrus"
 )
 
+// defaultRunnerFreshness is how long a verified runner is trusted when no
+// freshness is configured
+const defaultRunnerFreshness = time.Hour
+
 // RunnerCacheEntry represents a cached runner deployment
 type RunnerCacheEntry struct {
-	ServerID     string
-	RunnerPath   string
-	Version      string
-	Checksum     string
-	DeployedAt   time.Time
-	LastVerified time.Time
+	ServerID     string    `json:"serverId"`
+	RunnerPath   string    `json:"runnerPath"`
+	Version      string    `json:"version"`
+	Checksum     string    `json:"checksum,omitempty"` // SHA-256 of the deployed binary
+	Wanted       string    `json:"wanted,omitempty"`   // version the server is configured to run
+	Source       string    `json:"source,omitempty"`   // why Wanted was chosen (pin, group, rollout, default)
+	Reported     string    `json:"reported,omitempty"` // version reported by the runner itself
+	Features     []string  `json:"features,omitempty"` // optional run flags the runner reported
+	DeployedAt   time.Time `json:"deployedAt"`
+	LastVerified time.Time `json:"lastVerified"`
+}
+
+// Matches reports whether the entry records the given runner build at path.
+// Builds are told apart by checksum where both sides know it.
+func (e *RunnerCacheEntry) Matches(runner *RunnerInfo, path string) bool {
+	if e.Version != runner.Version || e.RunnerPath != path {
+		return false
+	}
+	return e.Checksum == "" || runner.Checksum == "" || e.Checksum == runner.Checksum
+}
+
+// Mismatch reports whether the deployed or reported version differs from the
+// configured one
+func (e *RunnerCacheEntry) Mismatch() bool {
+	if e.Wanted != "" && e.Version != e.Wanted {
+		return true
+	}
+	return e.Reported != "" && e.Reported != e.Version
 }
 
 // RunnerCache manages runner deployments across servers
 type RunnerCache struct {
-	mu      sync.RWMutex
-	entries map[string]*RunnerCacheEntry // key: serverID
-	log     *logrus.Logger
+	mu        sync.RWMutex
+	entries   map[string]*RunnerCacheEntry // key: serverID
+	log       *logrus.Logger
+	file      string // empty keeps the cache in memory only
+	freshness time.Duration
+}
+
+// runnerCacheFile is the saved cache. SHA256 covers Entries so a truncated
+// or edited file is discarded instead of trusted.
+type runnerCacheFile struct {
+	Entries json.RawMessage `json:"entries"`
+	SHA256  string          `json:"sha256"`
 }
 
 // NewRunnerCache creates a new runner cache
 func NewRunnerCache(log *logrus.Logger) *RunnerCache {
 	return &RunnerCache{
-		entries: make(map[string]*RunnerCacheEntry),
-		log:     log,
+		entries:   make(map[string]*RunnerCacheEntry),
+		log:       log,
+		freshness: defaultRunnerFreshness,
 	}
 }
 
+// OpenRunnerCache creates a runner cache kept in the configured file,
+// loading the entries saved before a restart. A missing or damaged file
+// starts an empty cache.
+func OpenRunnerCache(cfg config.RunnerCacheConfig, log *logrus.Logger) *RunnerCache {
+	rc := NewRunnerCache(log)
+	rc.file = cfg.File
+	if cfg.Freshness > 0 {
+		rc.freshness = cfg.Freshness
+	}
+	if rc.file == "" {
+		return rc
+	}
+	if err := rc.load(); err != nil {
+		log.WithError(err).Warn("Failed to load runner cache, verifying runners afresh")
+		rc.entries = make(map[string]*RunnerCacheEntry)
+	} else if len(rc.entries) > 0 {
+		log.WithField("entries", len(rc.entries)).Info("Loaded runner cache")
+	}
+	return rc
+}
+
 // Get retrieves a cached runner entry
 func (rc *RunnerCache) Get(serverID string) (*RunnerCacheEntry, bool) {
 	rc.mu.RLock()
@@ -44,8 +110,8 @@ func (rc *RunnerCache) Get(serverID string) (*RunnerCacheEntry, bool) {
 		return nil, false
 	}
 
-	// Check if entry is stale (not verified in last hour)
-	if time.Since(entry.LastVerified) > time.Hour {
+	// Check if entry is stale (not verified within the freshness period)
+	if time.Since(entry.LastVerified) > rc.freshness {
 		return entry, false // Return entry but indicate it needs verification
 	}
 
@@ -58,6 +124,7 @@ func (rc *RunnerCache) Set(serverID string, entry *RunnerCacheEntry) {
 	defer rc.mu.Unlock()
 
 	rc.entries[serverID] = entry
+	rc.persist()
 	rc.log.WithFields(logrus.Fields{
 		"serverID": serverID,
 		"version":  entry.Version,
@@ -72,16 +139,74 @@ func (rc *RunnerCache) UpdateVerified(serverID string) {
 
 	if entry, exists := rc.entries[serverID]; exists {
 		entry.LastVerified = time.Now()
+		rc.persist()
 	}
 }
 
-// Remove removes a cached entry
-func (rc *RunnerCache) Remove(serverID string) {
+// SetReported records the version and features the runner on a server
+// reports
+func (rc *RunnerCache) SetReported(serverID, version string, features runnerFeatures) {
 	rc.mu.Lock()
 	defer rc.mu.Unlock()
 
+	entry, exists := rc.entries[serverID]
+	if !exists {
+		return
+	}
+	list := features.list()
+	if entry.Reported == version && entry.Features != nil && strings.Join(entry.Features, " ") == strings.Join(list, " ") {
+		return
+	}
+	entry.Reported = version
+	entry.Features = list
+	rc.persist()
+}
+
+// Features returns the features reported by the given runner build on a
+// server, if it reported them
+func (rc *RunnerCache) Features(serverID, version string) (runnerFeatures, bool) {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	entry, exists := rc.entries[serverID]
+	if !exists || entry.Version != version || entry.Features == nil {
+		return nil, false
+	}
+	features := make(runnerFeatures, len(entry.Features))
+	for _, f := range entry.Features {
+		features[f] = true
+	}
+	return features, true
+}
+
+// Supports reports whether the runner last deployed on a server reported a
+// feature
+func (rc *RunnerCache) Supports(serverID, feature string) bool {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+
+	entry, exists := rc.entries[serverID]
+	if !exists {
+		return false
+	}
+	for _, f := range entry.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// Remove removes a cached entry and reports whether there was one
+func (rc *RunnerCache) Remove(serverID string) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	_, exists := rc.entries[serverID]
 	delete(rc.entries, serverID)
+	rc.persist()
 	rc.log.WithField("serverID", serverID).Debug("Removed runner from cache")
+	return exists
 }
 
 // Clear removes all cached entries
@@ -90,9 +215,23 @@ func (rc *RunnerCache) Clear() {
 	defer rc.mu.Unlock()
 
 	rc.entries = make(map[string]*RunnerCacheEntry)
+	rc.persist()
 	rc.log.Info("Cleared runner cache")
 }
 
+// Entries returns copies of the cached entries, sorted by server ID
+func (rc *RunnerCache) Entries() []RunnerCacheEntry {
+	rc.mu.RLock()
+	defer rc.mu.RUnlock()
+	return rc.sorted()
+}
+
+// Fresh reports whether an entry was verified recently enough to be used
+// without verifying it again
+func (rc *RunnerCache) Fresh(entry RunnerCacheEntry) bool {
+	return time.Since(entry.LastVerified) <= rc.freshness
+}
+
 // GetStats returns cache statistics
 func (rc *RunnerCache) GetStats() map[string]interface{} {
 	rc.mu.RLock()
@@ -107,6 +246,10 @@ func (rc *RunnerCache) GetStats() map[string]interface{} {
 		stats["servers"] = append(stats["servers"].([]map[string]interface{}), map[string]interface{}{
 			"server_id":     serverID,
 			"version":       entry.Version,
+			"wanted":        entry.Wanted,
+			"source":        entry.Source,
+			"reported":      entry.Reported,
+			"mismatch":      entry.Mismatch(),
 			"deployed_at":   entry.DeployedAt,
 			"last_verified": entry.LastVerified,
 		})
@@ -120,3 +263,76 @@ func CalculateChecksum(data []byte) string {
 	hash := sha256.Sum256(data)
 	return hex.EncodeToString(hash[:])
 }
+
+// sorted returns copies of the entries sorted by server ID; the caller holds
+// the lock
+func (rc *RunnerCache) sorted() []RunnerCacheEntry {
+	entries := make([]RunnerCacheEntry, 0, len(rc.entries))
+	for _, entry := range rc.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ServerID < entries[j].ServerID })
+	return entries
+}
+
+// persist saves the cache when it is kept in a file, logging failures; the
+// caller holds the lock
+func (rc *RunnerCache) persist() {
+	if rc.file == "" {
+		return
+	}
+	if err := rc.save(); err != nil {
+		rc.log.WithError(err).Warn("Failed to save runner cache")
+	}
+}
+
+// save replaces the cache file with the current entries
+func (rc *RunnerCache) save() error {
+	entries, err := json.Marshal(rc.sorted())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(runnerCacheFile{Entries: entries, SHA256: CalculateChecksum(entries)}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(rc.file), 0o750); err != nil {
+		return err
+	}
+	tmp := rc.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, rc.file)
+}
+
+// load reads the saved entries, of which there are none on the first start
+func (rc *RunnerCache) load() error {
+	data, err := os.ReadFile(rc.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved runnerCacheFile
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", rc.file, err)
+	}
+	// Indentation changes the raw entries, so hash them compacted as saved
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, saved.Entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", rc.file, err)
+	}
+	if CalculateChecksum(compact.Bytes()) != saved.SHA256 {
+		return fmt.Errorf("%s does not match its checksum", rc.file)
+	}
+	var entries []RunnerCacheEntry
+	if err := json.Unmarshal(compact.Bytes(), &entries); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", rc.file, err)
+	}
+	for i := range entries {
+		rc.entries[entries[i].ServerID] = &entries[i]
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package ssh
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// Optional run flags a runner build may understand. A runner lists the ones
+// it supports on the Features line of its version output; builds older than
+// that line support none, so a server pinned to one still gets a plain
+// `run <payload>` it can parse.
+const (
+	featurePIDFile     = "pid-file"
+	featureCancelFile  = "cancel-file"
+	featureMessages    = "messages-file"
+	featureScriptCache = "script-cache"
+	featureSnapshot    = "snapshot"
+	featureCheckpoint  = "checkpoint"
+)
+
+// runnerFeaturesPattern extracts the feature list from `cronium-runner version`
+var runnerFeaturesPattern = regexp.MustCompile(`(?m)^Features:[ \t]*(.*)$`)
+
+// runnerFeatures is the set of optional flags a runner supports
+type runnerFeatures map[string]bool
+
+// parseRunnerFeatures extracts the features from the runner's version output
+func parseRunnerFeatures(output string) runnerFeatures {
+	features := runnerFeatures{}
+	if m := runnerFeaturesPattern.FindStringSubmatch(output); m != nil {
+		for _, f := range strings.Fields(m[1]) {
+			features[f] = true
+		}
+	}
+	return features
+}
+
+// list returns the features in a stable order
+func (f runnerFeatures) list() []string {
+	list := make([]string, 0, len(f))
+	for feature := range f {
+		list = append(list, feature)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// runArgs returns the run flags for a job run as the SSH user, leaving out
+// those the runner does not support
+func (e *Executor) runArgs(job *types.Job, user, executionID string, features runnerFeatures) string {
+	var b strings.Builder
+	if features[featurePIDFile] {
+		fmt.Fprintf(&b, " --pid-file %s", remotePGIDFile(user, job.ID))
+	}
+	if features[featureCancelFile] {
+		fmt.Fprintf(&b, " --cancel-file %s --grace-period %s", remoteCancelFile(job.ID), e.cancelGracePeriod())
+	}
+	if features[featureMessages] {
+		fmt.Fprintf(&b, " --messages-file %s", remoteMessagesFile(job.ID))
+	}
+	if features[featureScriptCache] {
+		b.WriteString(e.scriptCacheArgs(job))
+	}
+	if features[featureSnapshot] {
+		b.WriteString(e.snapshotArgs(job))
+	}
+	if features[featureCheckpoint] {
+		b.WriteString(e.checkpointArgs(job, executionID))
+	}
+	return b.String()
+}
+
+// runnerFeaturesFor returns the features of the runner build deployed on a
+// server, asking the runner when they were not reported for this build yet
+func (e *Executor) runnerFeaturesFor(conn *ssh.Client, server *types.ServerDetails, selection RunnerSelection, runnerPath string) runnerFeatures {
+	if features, ok := e.runnerCache.Features(server.ID, selection.Runner.Version); ok {
+		e.checkRunnerVersion(server, selection, "", nil)
+		return features
+	}
+
+	versionCmd := fmt.Sprintf("%s version", runnerPath)
+	output, err := e.remoteOutput(conn, versionCmd)
+	if err != nil {
+		e.log.WithError(err).WithField("serverID", server.ID).Warn("Failed to read runner features, running without optional flags")
+		e.checkRunnerVersion(server, selection, "", nil)
+		return runnerFeatures{}
+	}
+	features := parseRunnerFeatures(output)
+	e.checkRunnerVersion(server, selection, parseRunnerVersion(output), features)
+	return features
+}
+
+// remoteOutput runs a command allowed by the command policy and returns its
+// output
+func (e *Executor) remoteOutput(conn *ssh.Client, cmd string) (string, error) {
+	if err := e.policy.Check(conn.RemoteAddr().String(), cmd); err != nil {
+		return "", err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	output, err := session.Output(cmd)
+	return string(output), err
+}
+
+// logMissingFeatures warns once per job when the runner lacks features the
+// job's configuration relies on
+func (e *Executor) logMissingFeatures(job *types.Job, server *types.ServerDetails, features runnerFeatures) {
+	var missing []string
+	for _, feature := range []string{featurePIDFile, featureCancelFile, featureMessages} {
+		if !features[feature] {
+			missing = append(missing, feature)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	e.log.WithFields(logrus.Fields{
+		"jobID":    job.ID,
+		"serverID": server.ID,
+		"missing":  strings.Join(missing, ","),
+	}).Warn("Runner predates some run flags; cancellation and operator messages are limited for this job")
+}
@@ -0,0 +1,67 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunArgsFollowRunnerFeatures(t *testing.T) {
+	e := &Executor{
+		config:      config.SSHConfig{Execution: config.SSHExecutionConfig{TempDir: "/tmp/cronium"}},
+		log:         testLogger(),
+		runnerCache: NewRunnerCache(testLogger()),
+	}
+	job := &types.Job{ID: "job_1"}
+
+	// A runner from before the Features line gets a plain run command
+	legacy := parseRunnerFeatures("Cronium Runner v1.2.0\nBuilt: 2025-01-01\nCommit: abc123\n")
+	assert.Empty(t, legacy)
+	assert.Equal(t, "", e.runArgs(job, "deploy", "exec_1", legacy))
+
+	current := parseRunnerFeatures("Cronium Runner dev\nFeatures: pid-file cancel-file messages-file script-cache snapshot checkpoint\n")
+	args := e.runArgs(job, "deploy", "exec_1", current)
+	assert.Contains(t, args, "--pid-file ")
+	assert.Contains(t, args, "--cancel-file ")
+	assert.Contains(t, args, "--grace-period ")
+	assert.Contains(t, args, "--messages-file ")
+
+	// Features are remembered per deployed build
+	e.runnerCache.Set("srv_1", &RunnerCacheEntry{ServerID: "srv_1", Version: "v1.2.0"})
+	_, ok := e.runnerCache.Features("srv_1", "v1.2.0")
+	assert.False(t, ok)
+	e.runnerCache.SetReported("srv_1", "v1.2.0", legacy)
+	features, ok := e.runnerCache.Features("srv_1", "v1.2.0")
+	assert.True(t, ok)
+	assert.Empty(t, features)
+	assert.False(t, e.runnerCache.Supports("srv_1", featureScriptCache))
+	_, ok = e.runnerCache.Features("srv_1", "v1.3.0")
+	assert.False(t, ok)
+}
+
+func TestRunArgsFollowFeatureFlags(t *testing.T) {
+	e := &Executor{
+		config: config.SSHConfig{Execution: config.SSHExecutionConfig{
+			TempDir:    "/tmp/cronium",
+			Checkpoint: config.CheckpointConfig{Enabled: true},
+		}},
+		log:   testLogger(),
+		flags: features.NewRegistry(config.FeatureFlags{SSHCheckpoints: true}),
+	}
+	supported := runnerFeatures{featureCheckpoint: true}
+
+	job := &types.Job{ID: "job_1"}
+	assert.Contains(t, e.runArgs(job, "deploy", "exec_1", supported), "--checkpoint-dir ")
+
+	// A job override switches the feature off for that job only
+	job.Metadata = map[string]any{features.JobOverridesKey: map[string]any{features.SSHCheckpoints: false}}
+	assert.NotContains(t, e.runArgs(job, "deploy", "exec_1", supported), "--checkpoint-dir ")
+
+	// A runtime toggle switches it off everywhere
+	require.NoError(t, e.flags.Set(features.SSHCheckpoints, false))
+	assert.NotContains(t, e.runArgs(&types.Job{ID: "job_2"}, "deploy", "exec_2", supported), "--checkpoint-dir ")
+}
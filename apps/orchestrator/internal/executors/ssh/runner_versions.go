@@ -0,0 +1,152 @@
+package ssh
+
+import (
+	"context"
+	"hash/fnv"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runnerdist"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Reasons a runner version was selected for a server
+const (
+	RunnerSourceDefault  = "default"
+	RunnerSourcePin      = "pin"
+	RunnerSourceGroup    = "group"
+	RunnerSourceRollout  = "rollout"
+	RunnerSourceFallback = "fallback"
+)
+
+// runnerVersionPattern extracts the version from `cronium-runner version`
+var runnerVersionPattern = regexp.MustCompile(`Cronium Runner (\S+)`)
+
+// RunnerSelection is the runner build chosen for a server
+type RunnerSelection struct {
+	Runner RunnerInfo
+	// Version the server should be running according to config
+	Wanted string
+	Source string
+}
+
+// Mismatch reports whether the selected build differs from the configured one
+func (s RunnerSelection) Mismatch() bool {
+	return s.Runner.Version != s.Wanted
+}
+
+// RunnerVersionResolver decides which runner build each server runs. Pins win
+// over groups, groups over the percentage rollout, and everything else gets
+// the default build.
+type RunnerVersionResolver struct {
+	defaultRunner  RunnerInfo
+	releases       *runnerdist.Distributor
+	candidate      string
+	rolloutPercent int
+	pins           map[string]string
+	groups         map[string]string // server -> version
+	groupNames     map[string]string // server -> group name
+	log            *logrus.Logger
+}
+
+// NewRunnerVersionResolver creates a resolver from the rollout config
+func NewRunnerVersionResolver(cfg config.RunnerRolloutConfig, defaultRunner RunnerInfo, log *logrus.Logger) *RunnerVersionResolver {
+	r := &RunnerVersionResolver{
+		defaultRunner:  defaultRunner,
+		candidate:      cfg.Candidate,
+		rolloutPercent: cfg.RolloutPercent,
+		pins:           make(map[string]string, len(cfg.Pins)),
+		groups:         make(map[string]string),
+		groupNames:     make(map[string]string),
+		log:            log,
+	}
+
+	// Config keys are case-folded when loaded from YAML, so match the same way
+	for server, version := range cfg.Pins {
+		r.pins[strings.ToLower(server)] = version
+	}
+	for _, group := range cfg.Groups {
+		for _, server := range group.Servers {
+			r.groups[strings.ToLower(server)] = group.Version
+			r.groupNames[strings.ToLower(server)] = group.Name
+		}
+	}
+
+	return r
+}
+
+// Resolve returns the runner build for a server's architecture. A build
+// missing locally is downloaded from the release channel when releases are
+// enabled. If the configured version still has no artifact the default build
+// is used and the selection is reported as a mismatch.
+func (r *RunnerVersionResolver) Resolve(ctx context.Context, server *types.ServerDetails, arch string) RunnerSelection {
+	defaultVersion := r.releases.DefaultVersion(r.defaultRunner.Version)
+	wanted, source := r.wantedVersion(server, defaultVersion)
+
+	runner, err := r.artifact(ctx, wanted, arch)
+	if err == nil || wanted == defaultVersion {
+		return RunnerSelection{Runner: runner, Wanted: wanted, Source: source}
+	}
+
+	r.log.WithError(err).WithFields(logrus.Fields{
+		"serverID": server.ID,
+		"wanted":   wanted,
+		"arch":     arch,
+		"source":   source,
+		"fallback": defaultVersion,
+	}).Warn("Runner artifact for configured version not found, using default runner")
+	runner, _ = r.artifact(ctx, defaultVersion, arch)
+	return RunnerSelection{Runner: runner, Wanted: wanted, Source: RunnerSourceFallback}
+}
+
+// artifact returns the local build of a version, fetching it from the
+// release channel if needed
+func (r *RunnerVersionResolver) artifact(ctx context.Context, version, arch string) (RunnerInfo, error) {
+	runner := runnerInfoForVersion(version, arch)
+	_, err := os.Stat(runner.Path)
+	if err == nil || r.releases == nil {
+		return runner, err
+	}
+	if err := r.releases.Ensure(ctx, version, arch); err != nil {
+		return runner, err
+	}
+	return runnerInfoForVersion(version, arch), nil
+}
+
+// wantedVersion returns the configured version for a server and where it
+// came from
+func (r *RunnerVersionResolver) wantedVersion(server *types.ServerDetails, defaultVersion string) (string, string) {
+	for _, key := range []string{server.ID, server.Name} {
+		if version, ok := r.pins[strings.ToLower(key)]; ok && version != "" {
+			return version, RunnerSourcePin
+		}
+	}
+	for _, key := range []string{server.ID, server.Name} {
+		if version, ok := r.groups[strings.ToLower(key)]; ok {
+			return version, RunnerSourceGroup + ":" + r.groupNames[strings.ToLower(key)]
+		}
+	}
+	if r.candidate != "" && rolloutBucket(server.ID) < r.rolloutPercent {
+		return r.candidate, RunnerSourceRollout
+	}
+	return defaultVersion, RunnerSourceDefault
+}
+
+// rolloutBucket maps a server to a stable bucket in [0, 100) so raising the
+// rollout percentage only ever adds servers
+func rolloutBucket(serverID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(serverID))
+	return int(h.Sum32() % 100)
+}
+
+// parseRunnerVersion extracts the version from the runner's version output
+func parseRunnerVersion(output string) string {
+	if m := runnerVersionPattern.FindStringSubmatch(output); m != nil {
+		return m[1]
+	}
+	return ""
+}
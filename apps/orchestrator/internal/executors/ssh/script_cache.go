@@ -0,0 +1,176 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stderrors "errors"
+	"fmt"
+	"path"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// scriptCacheMissStatus is the exit status of the payload copy command when
+// the server does not have the job's script cached
+const scriptCacheMissStatus = 75
+
+// scriptCacheHash returns the SHA-256 of a job's script when it should be
+// sent by hash instead of inline, or an empty string. Legacy payloads built
+// by cronium-app and scripts under the minimum size are always sent inline.
+func (e *Executor) scriptCacheHash(job *types.Job) string {
+	cfg := e.config.Execution.ScriptCache
+	if !cfg.Enabled || job.Execution.Script == nil || !e.flagOn(job, features.SSHScriptCache) {
+		return ""
+	}
+	if _, legacy := job.Metadata["payloadPath"]; legacy {
+		return ""
+	}
+	if len(job.Execution.Script.Content) < cfg.MinSize {
+		return ""
+	}
+	// Only runners that restore scripts from the cache get a payload
+	// without its script
+	if server := job.Execution.Target.ServerDetails; server == nil || !e.runnerCache.Supports(server.ID, featureScriptCache) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(job.Execution.Script.Content))
+	return hex.EncodeToString(sum[:])
+}
+
+// scriptCacheDir returns the script cache directory on remote servers
+func (e *Executor) scriptCacheDir() string {
+	return path.Join(e.config.Execution.TempDir, "scripts")
+}
+
+// scriptCacheArgs returns the runner flags for restoring a job's script from
+// the cache, with a leading space, or an empty string
+func (e *Executor) scriptCacheArgs(job *types.Job) string {
+	if e.scriptCacheHash(job) == "" {
+		return ""
+	}
+	return fmt.Sprintf(" --script-cache %s --script-cache-max-age %s",
+		e.scriptCacheDir(), e.config.Execution.ScriptCache.MaxAge)
+}
+
+// transferPayload copies a job's payload to the server, preceded by its
+// signature when payloads are signed. When the script is sent by hash, the
+// copy also checks the server's script cache and the script is uploaded to
+// the cache only on a miss.
+func (e *Executor) transferPayload(conn *ssh.Client, job *types.Job, localPath, remotePath string) error {
+	data, err := e.readPayload(job, localPath)
+	if err != nil {
+		return err
+	}
+	if sig := e.payloads.Sign(data); sig != nil {
+		if err := e.uploadFile(conn, sig, remotePath+".sig"); err != nil {
+			return fmt.Errorf("failed to upload payload signature: %w", err)
+		}
+	}
+
+	hash := e.scriptCacheHash(job)
+	if hash == "" {
+		return e.uploadFile(conn, data, remotePath)
+	}
+
+	// Touch the cached script so the runner does not prune it before use
+	cached := path.Join(e.scriptCacheDir(), hash)
+	if e.config.Execution.Transfer.Method != "cat" {
+		return e.transferPayloadSFTP(conn, job, data, remotePath, cached)
+	}
+	cmd := fmt.Sprintf("cat > %s && { touch -c %s; [ -f %s ] || exit %d; }",
+		remotePath, cached, cached, scriptCacheMissStatus)
+	err = e.runWithInput(conn, cmd, data)
+	if err == nil {
+		e.log.WithFields(map[string]interface{}{
+			"jobID": job.ID,
+			"hash":  hash,
+		}).Debug("Reusing cached script on server")
+		return nil
+	}
+	var exitErr *ssh.ExitError
+	if !stderrors.As(err, &exitErr) || exitErr.ExitStatus() != scriptCacheMissStatus {
+		return err
+	}
+
+	// Write under a job-specific name first so concurrent jobs never see a
+	// partial script
+	tmp := fmt.Sprintf("%s.tmp-%s", cached, job.ID)
+	cmd = fmt.Sprintf("mkdir -p %s && cat > %s && mv -f %s %s", e.scriptCacheDir(), tmp, tmp, cached)
+	if err := e.runWithInput(conn, cmd, []byte(job.Execution.Script.Content)); err != nil {
+		return fmt.Errorf("failed to upload script to cache: %w", err)
+	}
+	e.log.WithFields(map[string]interface{}{
+		"jobID": job.ID,
+		"hash":  hash,
+	}).Debug("Uploaded script to server cache")
+	return nil
+}
+
+// transferPayloadSFTP is transferPayload for the sftp transfer method
+func (e *Executor) transferPayloadSFTP(conn *ssh.Client, job *types.Job, data []byte, remotePath, cached string) error {
+	if err := e.uploadSFTP(conn, data, remotePath); err != nil {
+		return err
+	}
+
+	client, err := openSFTP(conn)
+	if err != nil {
+		return err
+	}
+	err = client.touch(cached)
+	if err == nil || !isSFTPNotExist(err) {
+		client.Close()
+		if err != nil {
+			return fmt.Errorf("failed to check script cache: %w", err)
+		}
+		e.log.WithFields(map[string]interface{}{
+			"jobID": job.ID,
+			"hash":  path.Base(cached),
+		}).Debug("Reusing cached script on server")
+		return nil
+	}
+	err = client.mkdirAll(e.scriptCacheDir())
+	client.Close()
+	if err != nil {
+		return fmt.Errorf("failed to create script cache: %w", err)
+	}
+
+	// Uploads are moved into place only when complete, so concurrent jobs
+	// never see a partial script
+	if err := e.uploadSFTP(conn, []byte(job.Execution.Script.Content), cached); err != nil {
+		return fmt.Errorf("failed to upload script to cache: %w", err)
+	}
+	e.log.WithFields(map[string]interface{}{
+		"jobID": job.ID,
+		"hash":  path.Base(cached),
+	}).Debug("Uploaded script to server cache")
+	return nil
+}
+
+// runWithInput runs a setup command on the server with data as its stdin
+func (e *Executor) runWithInput(conn *ssh.Client, cmd string, data []byte) error {
+	if err := e.policy.Check(conn.RemoteAddr().String(), cmd); err != nil {
+		return err
+	}
+
+	session, err := conn.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to create copy session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	if err := session.Start(cmd); err != nil {
+		return fmt.Errorf("failed to start copy command: %w", err)
+	}
+	if _, err := stdin.Write(data); err != nil {
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	stdin.Close()
+
+	return session.Wait()
+}
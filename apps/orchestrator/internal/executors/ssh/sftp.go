@@ -0,0 +1,552 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Packet types, open flags, attribute flags and status codes of SFTP version
+// 3 (draft-ietf-secsh-filexfer-02), which every server supports
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpRead    = 5
+	sftpWrite   = 6
+	sftpSetstat = 9
+	sftpRemove  = 13
+	sftpMkdir   = 14
+	sftpStat    = 17
+	sftpRename  = 18
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpData    = 103
+	sftpAttrs   = 105
+
+	sftpOpenRead  = 0x01
+	sftpOpenWrite = 0x02
+	sftpOpenCreat = 0x08
+	sftpOpenTrunc = 0x10
+
+	sftpAttrSize        = 0x01
+	sftpAttrUIDGID      = 0x02
+	sftpAttrPermissions = 0x04
+	sftpAttrACModTime   = 0x08
+	sftpAttrExtended    = 0x80000000
+
+	sftpStatusOK         = 0
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+
+	// Reads and writes kept in flight at once
+	sftpWindow = 16
+	// Largest packet accepted from the server
+	sftpMaxPacket = 256 * 1024
+)
+
+var (
+	// errSFTPUnavailable is returned when a server does not offer the SFTP
+	// subsystem
+	errSFTPUnavailable = stderrors.New("sftp subsystem unavailable")
+	// errChecksumMismatch is returned when an uploaded file does not match
+	// the local file
+	errChecksumMismatch = stderrors.New("uploaded file checksum mismatch")
+)
+
+// sftpStatusError is a failure status returned by the server
+type sftpStatusError struct {
+	Code    uint32
+	Message string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (status %d)", e.Message, e.Code)
+}
+
+func isSFTPNotExist(err error) bool {
+	var status *sftpStatusError
+	return stderrors.As(err, &status) && status.Code == sftpStatusNoSuchFile
+}
+
+// sftpFileAttrs is the part of a file's attributes the client uses
+type sftpFileAttrs struct {
+	Size    int64
+	IsDir   bool
+	ModTime time.Time
+}
+
+// sftpClient is a minimal SFTP version 3 client for uploading files. It is
+// not safe for concurrent use.
+type sftpClient struct {
+	r      io.Reader
+	w      io.Writer
+	closer func() error
+	nextID uint32
+}
+
+// openSFTP starts the SFTP subsystem in a new session on conn
+func openSFTP(conn *ssh.Client) (*sftpClient, error) {
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sftp session: %w", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("%w: %v", errSFTPUnavailable, err)
+	}
+
+	c, err := newSFTPClient(stdout, stdin, session.Close)
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newSFTPClient negotiates the protocol version over r and w
+func newSFTPClient(r io.Reader, w io.Writer, closer func() error) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w, closer: closer}
+	if err := c.send(sftpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		return nil, fmt.Errorf("failed to send sftp init: %w", err)
+	}
+	typ, data, err := c.recvPacket()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sftp version: %w", err)
+	}
+	if typ != sftpVersion || len(data) < 4 {
+		return nil, fmt.Errorf("unexpected sftp packet %d during init", typ)
+	}
+	if version := binary.BigEndian.Uint32(data); version < 3 {
+		return nil, fmt.Errorf("unsupported sftp version %d", version)
+	}
+	return c, nil
+}
+
+// Close ends the session
+func (c *sftpClient) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer()
+}
+
+// send writes one packet
+func (c *sftpClient) send(typ byte, payload []byte) error {
+	packet := make([]byte, 0, 5+len(payload))
+	packet = binary.BigEndian.AppendUint32(packet, uint32(1+len(payload)))
+	packet = append(packet, typ)
+	packet = append(packet, payload...)
+	_, err := c.w.Write(packet)
+	return err
+}
+
+// recvPacket reads one packet
+func (c *sftpClient) recvPacket() (byte, []byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n == 0 || n > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", n)
+	}
+	packet := make([]byte, n)
+	if _, err := io.ReadFull(c.r, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+// start sends a request and returns its ID. Fields are encoded by type:
+// uint32, uint64, string, []byte and *sftpFileAttrs.
+func (c *sftpClient) start(typ byte, fields ...any) (uint32, error) {
+	c.nextID++
+	id := c.nextID
+	payload := binary.BigEndian.AppendUint32(nil, id)
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint32:
+			payload = binary.BigEndian.AppendUint32(payload, v)
+		case uint64:
+			payload = binary.BigEndian.AppendUint64(payload, v)
+		case string:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(v)))
+			payload = append(payload, v...)
+		case []byte:
+			payload = binary.BigEndian.AppendUint32(payload, uint32(len(v)))
+			payload = append(payload, v...)
+		case *sftpFileAttrs:
+			if v == nil {
+				payload = binary.BigEndian.AppendUint32(payload, 0)
+				continue
+			}
+			// Only times are ever set
+			payload = binary.BigEndian.AppendUint32(payload, sftpAttrACModTime)
+			payload = binary.BigEndian.AppendUint32(payload, uint32(v.ModTime.Unix()))
+			payload = binary.BigEndian.AppendUint32(payload, uint32(v.ModTime.Unix()))
+		default:
+			panic(fmt.Sprintf("sftp: unsupported field type %T", field))
+		}
+	}
+	return id, c.send(typ, payload)
+}
+
+// recv reads a response and returns its request ID, type and body
+func (c *sftpClient) recv() (uint32, byte, []byte, error) {
+	typ, data, err := c.recvPacket()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if len(data) < 4 {
+		return 0, 0, nil, fmt.Errorf("short sftp packet %d", typ)
+	}
+	return binary.BigEndian.Uint32(data), typ, data[4:], nil
+}
+
+// call sends a request and waits for its response
+func (c *sftpClient) call(typ byte, fields ...any) (byte, []byte, error) {
+	id, err := c.start(typ, fields...)
+	if err != nil {
+		return 0, nil, err
+	}
+	respID, respType, body, err := c.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if respID != id {
+		return 0, nil, fmt.Errorf("sftp response for request %d, expected %d", respID, id)
+	}
+	return respType, body, nil
+}
+
+// statusOf returns the error a response stands for when it is a status, or
+// an error for any other type than want
+func statusOf(typ byte, body []byte, want byte) error {
+	if typ == want {
+		return nil
+	}
+	if typ != sftpStatus {
+		return fmt.Errorf("unexpected sftp packet %d", typ)
+	}
+	if len(body) < 4 {
+		return fmt.Errorf("short sftp status")
+	}
+	code := binary.BigEndian.Uint32(body)
+	if code == sftpStatusOK {
+		if want == sftpStatus {
+			return nil
+		}
+		return fmt.Errorf("unexpected sftp status ok")
+	}
+	message, _, _ := readSFTPString(body[4:])
+	if message == "" {
+		message = "request failed"
+	}
+	return &sftpStatusError{Code: code, Message: message}
+}
+
+func readSFTPString(data []byte) (string, []byte, bool) {
+	if len(data) < 4 {
+		return "", nil, false
+	}
+	n := binary.BigEndian.Uint32(data)
+	if uint32(len(data)-4) < n {
+		return "", nil, false
+	}
+	return string(data[4 : 4+n]), data[4+n:], true
+}
+
+// simple sends a request answered by a status
+func (c *sftpClient) simple(typ byte, fields ...any) error {
+	respType, body, err := c.call(typ, fields...)
+	if err != nil {
+		return err
+	}
+	return statusOf(respType, body, sftpStatus)
+}
+
+func (c *sftpClient) open(name string, flags uint32) (string, error) {
+	typ, body, err := c.call(sftpOpen, name, flags, (*sftpFileAttrs)(nil))
+	if err != nil {
+		return "", err
+	}
+	if err := statusOf(typ, body, sftpHandle); err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	handle, _, ok := readSFTPString(body)
+	if !ok {
+		return "", fmt.Errorf("short sftp handle")
+	}
+	return handle, nil
+}
+
+func (c *sftpClient) closeHandle(handle string) error {
+	return c.simple(sftpClose, handle)
+}
+
+func (c *sftpClient) stat(name string) (*sftpFileAttrs, error) {
+	typ, body, err := c.call(sftpStat, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := statusOf(typ, body, sftpAttrs); err != nil {
+		return nil, err
+	}
+	return parseSFTPAttrs(body)
+}
+
+func parseSFTPAttrs(data []byte) (*sftpFileAttrs, error) {
+	next := func(n int) ([]byte, error) {
+		if len(data) < n {
+			return nil, fmt.Errorf("short sftp attributes")
+		}
+		field := data[:n]
+		data = data[n:]
+		return field, nil
+	}
+
+	raw, err := next(4)
+	if err != nil {
+		return nil, err
+	}
+	flags := binary.BigEndian.Uint32(raw)
+	attrs := &sftpFileAttrs{}
+	if flags&sftpAttrSize != 0 {
+		if raw, err = next(8); err != nil {
+			return nil, err
+		}
+		attrs.Size = int64(binary.BigEndian.Uint64(raw))
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		if _, err = next(8); err != nil {
+			return nil, err
+		}
+	}
+	if flags&sftpAttrPermissions != 0 {
+		if raw, err = next(4); err != nil {
+			return nil, err
+		}
+		// S_IFDIR
+		attrs.IsDir = binary.BigEndian.Uint32(raw)&0o170000 == 0o040000
+	}
+	if flags&sftpAttrACModTime != 0 {
+		if raw, err = next(8); err != nil {
+			return nil, err
+		}
+		attrs.ModTime = time.Unix(int64(binary.BigEndian.Uint32(raw[4:])), 0)
+	}
+	return attrs, nil
+}
+
+// touch sets a file's access and modification times to now
+func (c *sftpClient) touch(name string) error {
+	return c.simple(sftpSetstat, name, &sftpFileAttrs{ModTime: time.Now()})
+}
+
+func (c *sftpClient) remove(name string) error {
+	return c.simple(sftpRemove, name)
+}
+
+func (c *sftpClient) rename(from, to string) error {
+	return c.simple(sftpRename, from, to)
+}
+
+// mkdirAll creates dir and any missing parents
+func (c *sftpClient) mkdirAll(dir string) error {
+	attrs, err := c.stat(dir)
+	if err == nil {
+		if !attrs.IsDir {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	}
+	if !isSFTPNotExist(err) {
+		return err
+	}
+	if parent := path.Dir(dir); parent != dir {
+		if err := c.mkdirAll(parent); err != nil {
+			return err
+		}
+	}
+	if err := c.simple(sftpMkdir, dir, (*sftpFileAttrs)(nil)); err != nil {
+		// Created concurrently
+		if attrs, statErr := c.stat(dir); statErr == nil && attrs.IsDir {
+			return nil
+		}
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	return nil
+}
+
+// writeAt writes data to an open file starting at offset, in chunks of at
+// most chunk bytes with several writes in flight
+func (c *sftpClient) writeAt(handle string, data []byte, offset, chunk int) error {
+	inflight := make(map[uint32]bool)
+	for pos := offset; pos < len(data) || len(inflight) > 0; {
+		for pos < len(data) && len(inflight) < sftpWindow {
+			end := min(pos+chunk, len(data))
+			id, err := c.start(sftpWrite, handle, uint64(pos), data[pos:end])
+			if err != nil {
+				return err
+			}
+			inflight[id] = true
+			pos = end
+		}
+
+		id, typ, body, err := c.recv()
+		if err != nil {
+			return err
+		}
+		if !inflight[id] {
+			return fmt.Errorf("sftp response for unknown request %d", id)
+		}
+		delete(inflight, id)
+		if err := statusOf(typ, body, sftpStatus); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFull reads len(buf) bytes from the start of an open file, in chunks of
+// at most chunk bytes with several reads in flight
+func (c *sftpClient) readFull(handle string, buf []byte, chunk int) error {
+	type span struct{ offset, length int }
+	var queue []span
+	for offset := 0; offset < len(buf); offset += chunk {
+		queue = append(queue, span{offset, min(chunk, len(buf)-offset)})
+	}
+
+	inflight := make(map[uint32]span)
+	for len(queue) > 0 || len(inflight) > 0 {
+		for len(queue) > 0 && len(inflight) < sftpWindow {
+			s := queue[0]
+			queue = queue[1:]
+			id, err := c.start(sftpRead, handle, uint64(s.offset), uint32(s.length))
+			if err != nil {
+				return err
+			}
+			inflight[id] = s
+		}
+
+		id, typ, body, err := c.recv()
+		if err != nil {
+			return err
+		}
+		s, ok := inflight[id]
+		if !ok {
+			return fmt.Errorf("sftp response for unknown request %d", id)
+		}
+		delete(inflight, id)
+		if err := statusOf(typ, body, sftpData); err != nil {
+			var status *sftpStatusError
+			if stderrors.As(err, &status) && status.Code == sftpStatusEOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		data, _, ok := readSFTPString(body)
+		if !ok {
+			return fmt.Errorf("short sftp data")
+		}
+		n := copy(buf[s.offset:s.offset+s.length], data)
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		// Servers may return less than requested
+		if n < s.length {
+			queue = append(queue, span{s.offset + n, s.length - n})
+		}
+	}
+	return nil
+}
+
+// upload writes data to target through the temporary file part. A part left
+// by an earlier attempt is resumed from its size. With verify, the part is
+// read back and compared by SHA-256 before it is renamed to target; a part
+// that does not match is removed. It returns the offset the upload resumed
+// from.
+func (c *sftpClient) upload(data []byte, part, target string, chunk int, verify bool) (int, error) {
+	offset := 0
+	if attrs, err := c.stat(part); err == nil && attrs.Size <= int64(len(data)) {
+		offset = int(attrs.Size)
+	} else if err != nil && !isSFTPNotExist(err) {
+		return 0, err
+	}
+
+	flags := uint32(sftpOpenWrite | sftpOpenCreat)
+	if offset == 0 {
+		flags |= sftpOpenTrunc
+	}
+	handle, err := c.open(part, flags)
+	if err != nil {
+		return offset, err
+	}
+	if err := c.writeAt(handle, data, offset, chunk); err != nil {
+		c.closeHandle(handle)
+		return offset, fmt.Errorf("failed to write %s: %w", part, err)
+	}
+	if err := c.closeHandle(handle); err != nil {
+		return offset, fmt.Errorf("failed to close %s: %w", part, err)
+	}
+
+	if verify {
+		if err := c.verify(data, part, chunk); err != nil {
+			c.remove(part)
+			return offset, err
+		}
+	}
+
+	// Version 3 servers may refuse to rename over an existing file
+	if err := c.remove(target); err != nil && !isSFTPNotExist(err) {
+		return offset, fmt.Errorf("failed to replace %s: %w", target, err)
+	}
+	if err := c.rename(part, target); err != nil {
+		return offset, fmt.Errorf("failed to move %s into place: %w", part, err)
+	}
+	return offset, nil
+}
+
+// verify reads a file back and compares it with data
+func (c *sftpClient) verify(data []byte, name string, chunk int) error {
+	attrs, err := c.stat(name)
+	if err != nil {
+		return err
+	}
+	if attrs.Size != int64(len(data)) {
+		return fmt.Errorf("%w: %s has %d bytes, expected %d", errChecksumMismatch, name, attrs.Size, len(data))
+	}
+
+	handle, err := c.open(name, sftpOpenRead)
+	if err != nil {
+		return err
+	}
+	defer c.closeHandle(handle)
+
+	remote := make([]byte, len(data))
+	if err := c.readFull(handle, remote, chunk); err != nil {
+		return fmt.Errorf("failed to read back %s: %w", name, err)
+	}
+	if sha256.Sum256(remote) != sha256.Sum256(data) {
+		return fmt.Errorf("%w: %s", errChecksumMismatch, name)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSFTPServer serves SFTP requests from memory
+type fakeSFTPServer struct {
+	files   map[string][]byte
+	handles map[string]string
+	written int
+	// Bytes each read returns at most, to exercise short reads
+	maxRead int
+}
+
+func (s *fakeSFTPServer) serve(r io.Reader, w io.Writer) {
+	responses := make(chan []byte, 64)
+	go func() {
+		for packet := range responses {
+			w.Write(packet)
+		}
+	}()
+	defer close(responses)
+
+	reply := func(typ byte, id uint32, body []byte) {
+		payload := binary.BigEndian.AppendUint32(nil, id)
+		payload = append(payload, body...)
+		packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)))
+		responses <- append(append(packet, typ), payload...)
+	}
+	status := func(id, code uint32) {
+		reply(sftpStatus, id, append(binary.BigEndian.AppendUint32(nil, code), 0, 0, 0, 0, 0, 0, 0, 0))
+	}
+	str := func(s string) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+	}
+
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return
+		}
+		packet := make([]byte, binary.BigEndian.Uint32(length[:]))
+		io.ReadFull(r, packet)
+		typ, data := packet[0], packet[1:]
+		if typ == sftpInit {
+			responses <- []byte{0, 0, 0, 5, sftpVersion, 0, 0, 0, 3}
+			continue
+		}
+
+		id := binary.BigEndian.Uint32(data)
+		name, rest, _ := readSFTPString(data[4:])
+		switch typ {
+		case sftpOpen:
+			flags := binary.BigEndian.Uint32(rest)
+			if _, ok := s.files[name]; !ok && flags&sftpOpenCreat == 0 {
+				status(id, sftpStatusNoSuchFile)
+				continue
+			}
+			if flags&sftpOpenTrunc != 0 || s.files[name] == nil {
+				s.files[name] = []byte{}
+			}
+			handle := strconv.Itoa(len(s.handles))
+			s.handles[handle] = name
+			reply(sftpHandle, id, str(handle))
+		case sftpWrite:
+			offset := int(binary.BigEndian.Uint64(rest))
+			chunk, _, _ := readSFTPString(rest[8:])
+			file := s.files[s.handles[name]]
+			if len(file) < offset+len(chunk) {
+				file = append(file, make([]byte, offset+len(chunk)-len(file))...)
+			}
+			copy(file[offset:], chunk)
+			s.files[s.handles[name]] = file
+			s.written += len(chunk)
+			status(id, sftpStatusOK)
+		case sftpRead:
+			offset := int(binary.BigEndian.Uint64(rest))
+			n := int(binary.BigEndian.Uint32(rest[8:]))
+			file := s.files[s.handles[name]]
+			if offset >= len(file) {
+				status(id, sftpStatusEOF)
+				continue
+			}
+			n = min(n, s.maxRead, len(file)-offset)
+			reply(sftpData, id, str(string(file[offset:offset+n])))
+		case sftpStat:
+			file, ok := s.files[name]
+			if !ok {
+				status(id, sftpStatusNoSuchFile)
+				continue
+			}
+			attrs := binary.BigEndian.AppendUint32(nil, sftpAttrSize)
+			reply(sftpAttrs, id, binary.BigEndian.AppendUint64(attrs, uint64(len(file))))
+		case sftpRemove:
+			if _, ok := s.files[name]; !ok {
+				status(id, sftpStatusNoSuchFile)
+				continue
+			}
+			delete(s.files, name)
+			status(id, sftpStatusOK)
+		case sftpRename:
+			to, _, _ := readSFTPString(rest)
+			s.files[to] = s.files[name]
+			delete(s.files, name)
+			status(id, sftpStatusOK)
+		default:
+			status(id, sftpStatusOK)
+		}
+	}
+}
+
+func newFakeSFTP(t *testing.T, server *fakeSFTPServer) *sftpClient {
+	server.handles = make(map[string]string)
+	if server.maxRead == 0 {
+		server.maxRead = 1 << 20
+	}
+	requests, requestsW := io.Pipe()
+	responses, responsesW := io.Pipe()
+	go server.serve(requests, responsesW)
+
+	c, err := newSFTPClient(responses, requestsW, requestsW.Close)
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestSFTPUpload(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	server := &fakeSFTPServer{files: map[string][]byte{"/tmp/cronium-runner": []byte("old")}, maxRead: 1000}
+	c := newFakeSFTP(t, server)
+
+	offset, err := c.upload(data, "/tmp/cronium-runner.part-1", "/tmp/cronium-runner", 4096, true)
+	require.NoError(t, err)
+	assert.Zero(t, offset)
+	assert.Equal(t, data, server.files["/tmp/cronium-runner"])
+	assert.NotContains(t, server.files, "/tmp/cronium-runner.part-1")
+}
+
+func TestSFTPUploadResumesPart(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	server := &fakeSFTPServer{files: map[string][]byte{"/tmp/p.part-1": data[:60000]}}
+	c := newFakeSFTP(t, server)
+
+	offset, err := c.upload(data, "/tmp/p.part-1", "/tmp/p", 32768, true)
+	require.NoError(t, err)
+	assert.Equal(t, 60000, offset)
+	assert.Equal(t, 40000, server.written)
+	assert.Equal(t, data, server.files["/tmp/p"])
+}
+
+func TestSFTPUploadRejectsCorruptPart(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	server := &fakeSFTPServer{files: map[string][]byte{"/tmp/p.part-1": []byte("garbage")}}
+	c := newFakeSFTP(t, server)
+
+	_, err := c.upload(data, "/tmp/p.part-1", "/tmp/p", 32768, true)
+	assert.ErrorIs(t, err, errChecksumMismatch)
+	assert.Empty(t, server.files, "the corrupt part is removed")
+
+	// The retry starts over
+	offset, err := c.upload(data, "/tmp/p.part-1", "/tmp/p", 32768, true)
+	require.NoError(t, err)
+	assert.Zero(t, offset)
+	assert.Equal(t, data, server.files["/tmp/p"])
+}
@@ -0,0 +1,41 @@
+package ssh
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// snapshotOnFailure reports whether the workspace of a failed job is kept.
+// The job's own setting wins over the orchestrator's default.
+func (e *Executor) snapshotOnFailure(job *types.Job) bool {
+	if !e.flagOn(job, features.SSHSnapshots) {
+		return false
+	}
+	if job.Execution.SnapshotOnFailure != nil {
+		return *job.Execution.SnapshotOnFailure
+	}
+	return e.config.Execution.FailureSnapshot.Enabled
+}
+
+// snapshotDir returns the directory of workspace snapshots on remote servers
+func (e *Executor) snapshotDir() string {
+	return path.Join(e.config.Execution.TempDir, "snapshots")
+}
+
+// snapshotArgs returns the runner flags for keeping the workspace of a
+// failed job, with a leading space, or an empty string
+func (e *Executor) snapshotArgs(job *types.Job) string {
+	if !e.snapshotOnFailure(job) {
+		return ""
+	}
+	cfg := e.config.Execution.FailureSnapshot
+	args := fmt.Sprintf(" --snapshot-dir %s --snapshot-max-size %d --snapshot-retention %s",
+		e.snapshotDir(), cfg.MaxSize, cfg.Retention)
+	if cfg.Upload {
+		args += " --snapshot-upload"
+	}
+	return args
+}
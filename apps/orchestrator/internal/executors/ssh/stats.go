@@ -0,0 +1,137 @@
+package ssh
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// statsProbeScript samples CPU ticks of the script's process group twice, one
+// second apart, then reports RSS (KiB) and IO counters for each process
+const statsProbeScript = `{{PGID_CHECK}} || exit 3
+pgid=$(cat {{PGID_FILE}} 2>/dev/null)
+case "$pgid" in ''|*[!0-9]*) exit 3;; esac
+pids=$(ps -eo pid=,pgid= | awk -v g="$pgid" '$2 == g {print $1}')
+ticks() {
+  t=0
+  for pid in $pids; do
+    s=$(sed 's/.*) //' /proc/$pid/stat 2>/dev/null) || continue
+    [ -z "$s" ] && continue
+    set -- $s
+    t=$((t + ${12} + ${13}))
+  done
+  echo $t
+}
+echo "hz $(getconf CLK_TCK 2>/dev/null || echo 100)"
+echo "t0 $(ticks)"
+sleep 1
+echo "t1 $(ticks)"
+for pid in $pids; do
+  rss=$(ps -o rss= -p $pid 2>/dev/null) || continue
+  io=$(awk '/^(read|write)_bytes/ {printf " %s", $2}' /proc/$pid/io 2>/dev/null)
+  echo "p $rss$io"
+done`
+
+// SampleStats probes the remote host for the resource usage of a job's script
+func (m *MultiServerExecutor) SampleStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+	return m.executor.SampleStats(ctx, job)
+}
+
+// SampleStats probes the remote host for the resource usage of a job's script
+func (e *Executor) SampleStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+	e.mu.RLock()
+	sess, exists := e.sessions[job.ID]
+	e.mu.RUnlock()
+
+	if !exists || sess.conn == nil {
+		return nil, fmt.Errorf("no active SSH session for job %s", job.ID)
+	}
+
+	pgidFile := remotePGIDFile(sess.conn.User(), job.ID)
+	if err := e.policy.CheckHelper(sess.conn.RemoteAddr().String(), "stats", pgidFile); err != nil {
+		return nil, err
+	}
+
+	session, err := sess.conn.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	defer session.Close()
+
+	// Closing the session aborts the probe if the caller gives up
+	stop := context.AfterFunc(ctx, func() { session.Close() })
+	defer stop()
+
+	script := strings.NewReplacer(
+		"{{PGID_CHECK}}", fmt.Sprintf(pgidFileCheck, pgidFile, path.Dir(pgidFile)),
+		"{{PGID_FILE}}", pgidFile,
+	).Replace(statsProbeScript)
+	output, err := session.Output(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to probe remote process group: %w", err)
+	}
+
+	sample := parseStatsProbe(string(output))
+	sample.JobID = job.ID
+	sample.Timestamp = time.Now()
+	return sample, nil
+}
+
+// parseStatsProbe converts the output of statsProbeScript into a sample
+func parseStatsProbe(output string) *types.ResourceSample {
+	sample := &types.ResourceSample{Source: "remote"}
+
+	hz := int64(100)
+	var t0, t1 int64
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		values := make([]int64, 0, len(fields)-1)
+		for _, f := range fields[1:] {
+			v, err := strconv.ParseInt(f, 10, 64)
+			if err != nil {
+				break
+			}
+			values = append(values, v)
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "hz":
+			if values[0] > 0 {
+				hz = values[0]
+			}
+		case "t0":
+			t0 = values[0]
+		case "t1":
+			t1 = values[0]
+		case "p":
+			sample.Processes++
+			sample.MemoryBytes += values[0] * 1024
+			if len(values) >= 3 {
+				sample.DiskRead += values[1]
+				sample.DiskWrite += values[2]
+			}
+		}
+	}
+
+	// Ticks were measured over a one second window
+	if t1 > t0 {
+		sample.CPUPercent = float64(t1-t0) / float64(hz) * 100.0
+	}
+
+	return sample
+}
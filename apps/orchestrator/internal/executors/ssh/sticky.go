@@ -0,0 +1,159 @@
+package ssh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// stickyServer is the server an event last ran on
+type stickyServer struct {
+	EventID  string    `json:"eventId"`
+	ServerID string    `json:"serverId"`
+	RanAt    time.Time `json:"ranAt"`
+	// When the entry is dropped, after the TTL of the job that recorded it
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// expired reports whether the entry is past its expiry. Entries saved
+// without one expire after the default TTL.
+func (s *stickyServer) expired(defaultTTL time.Duration) bool {
+	expiresAt := s.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = s.RanAt.Add(defaultTTL)
+	}
+	return !time.Now().Before(expiresAt)
+}
+
+// stickyTargets remembers the server each event with a sticky target last
+// ran on, in a local file so the preference survives restarts
+type stickyTargets struct {
+	cfg config.StickyTargetConfig
+	log *logrus.Logger
+
+	mu      sync.Mutex
+	servers map[string]*stickyServer
+}
+
+func newStickyTargets(cfg config.StickyTargetConfig, log *logrus.Logger) *stickyTargets {
+	s := &stickyTargets{cfg: cfg, log: log, servers: make(map[string]*stickyServer)}
+	if err := s.load(); err != nil {
+		log.WithError(err).Warn("Failed to load sticky targets, starting afresh")
+	}
+	return s
+}
+
+// Get returns the server an event last ran on, when that was less than ttl
+// ago
+func (s *stickyTargets) Get(eventID string, ttl time.Duration) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, ok := s.servers[eventID]
+	if !ok || time.Since(last.RanAt) >= ttl {
+		return "", false
+	}
+	return last.ServerID, true
+}
+
+// Record notes that an event runs on a server, keeping the entry for the
+// job's ttl
+func (s *stickyTargets) Record(eventID, serverID string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.servers[eventID] = &stickyServer{EventID: eventID, ServerID: serverID, RanAt: now, ExpiresAt: now.Add(ttl)}
+	if s.cfg.File == "" {
+		return
+	}
+	if err := s.save(); err != nil {
+		s.log.WithError(err).Warn("Failed to save sticky targets")
+	}
+}
+
+// load reads the saved servers, of which there are none on the first start
+func (s *stickyTargets) load() error {
+	if s.cfg.File == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var servers []*stickyServer
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", s.cfg.File, err)
+	}
+	for _, server := range servers {
+		s.servers[server.EventID] = server
+	}
+	return nil
+}
+
+// save replaces the sticky targets file, dropping expired entries; the
+// caller holds the lock
+func (s *stickyTargets) save() error {
+	servers := make([]*stickyServer, 0, len(s.servers))
+	for eventID, server := range s.servers {
+		if server.expired(s.cfg.TTL) {
+			delete(s.servers, eventID)
+			continue
+		}
+		servers = append(servers, server)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].EventID < servers[j].EventID })
+
+	data, err := json.MarshalIndent(servers, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.cfg.File), 0o750); err != nil {
+		return err
+	}
+	tmp := s.cfg.File + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.cfg.File)
+}
+
+// pickSticky returns the first of servers that probe reaches, trying the
+// preferred server first. fellBack is set when the preferred server is one
+// of them and was not reached.
+func pickSticky(ctx context.Context, servers []*types.ServerDetails, preferred string, probe func(context.Context, *types.ServerDetails) error) (server *types.ServerDetails, fellBack bool, err error) {
+	ordered := make([]*types.ServerDetails, 0, len(servers))
+	for _, s := range servers {
+		if s.ID == preferred {
+			ordered = append([]*types.ServerDetails{s}, ordered...)
+		} else {
+			ordered = append(ordered, s)
+		}
+	}
+
+	var errs []error
+	for i, s := range ordered {
+		if err := probe(ctx, s); err != nil {
+			if i == 0 && s.ID == preferred {
+				fellBack = true
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			continue
+		}
+		return s, fellBack, nil
+	}
+	return nil, fellBack, fmt.Errorf("no server of the job is reachable: %w", errors.Join(errs...))
+}
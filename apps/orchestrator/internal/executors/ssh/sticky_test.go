@@ -0,0 +1,85 @@
+package ssh
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickSticky(t *testing.T) {
+	servers := fanOutServers(3)
+	down := map[string]bool{}
+	probe := func(ctx context.Context, server *types.ServerDetails) error {
+		if down[server.ID] {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	server, fellBack, err := pickSticky(context.Background(), servers, "", probe)
+	require.NoError(t, err)
+	assert.Equal(t, "s0", server.ID)
+	assert.False(t, fellBack)
+
+	server, fellBack, err = pickSticky(context.Background(), servers, "s2", probe)
+	require.NoError(t, err)
+	assert.Equal(t, "s2", server.ID)
+	assert.False(t, fellBack)
+
+	down["s2"] = true
+	server, fellBack, err = pickSticky(context.Background(), servers, "s2", probe)
+	require.NoError(t, err)
+	assert.Equal(t, "s0", server.ID)
+	assert.True(t, fellBack)
+
+	down["s0"], down["s1"] = true, true
+	_, _, err = pickSticky(context.Background(), servers, "s2", probe)
+	assert.ErrorContains(t, err, "no server of the job is reachable")
+}
+
+func TestStickyTargetsPersistence(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := config.StickyTargetConfig{TTL: time.Hour, File: filepath.Join(t.TempDir(), "sticky-targets.json")}
+
+	newStickyTargets(cfg, log).Record("evt_1", "s1", time.Hour)
+
+	targets := newStickyTargets(cfg, log)
+	serverID, ok := targets.Get("evt_1", time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, "s1", serverID)
+
+	_, ok = targets.Get("evt_1", time.Nanosecond)
+	assert.False(t, ok, "a run older than the job's TTL is not preferred")
+	_, ok = targets.Get("evt_2", time.Hour)
+	assert.False(t, ok)
+}
+
+func TestStickyTargetsKeepEntriesForTheirTTL(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg := config.StickyTargetConfig{TTL: time.Millisecond, File: filepath.Join(t.TempDir(), "sticky-targets.json")}
+
+	targets := newStickyTargets(cfg, log)
+	targets.Record("evt_long", "s1", 24*time.Hour)
+	targets.Record("evt_short", "s2", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	// Saving prunes by each entry's own expiry, not the default TTL
+	targets.Record("evt_other", "s3", time.Hour)
+
+	reloaded := newStickyTargets(cfg, log)
+	serverID, ok := reloaded.Get("evt_long", 24*time.Hour)
+	assert.True(t, ok)
+	assert.Equal(t, "s1", serverID)
+	_, ok = reloaded.servers["evt_short"]
+	assert.False(t, ok, "an entry past its TTL is dropped")
+}
@@ -39,6 +39,13 @@ type ExecutionTiming struct {
 	// Multi-server specific
 	ServerName string
 	IsParallel bool
+
+	// Pre-execution analysis report, if the job was analysed
+	Analysis interface{}
+
+	// Free space in the server's work directory before the payload transfer
+	RemoteDiskAvailable   *int64
+	RemoteInodesAvailable *int64
 }
 
 // NewExecutionTiming creates a new timing tracker
@@ -217,6 +224,18 @@ func (t *ExecutionTiming) buildMetadata() map[string]interface{} {
 		metadata["parallelExecution"] = true
 	}
 
+	if t.Analysis != nil {
+		metadata["analysis"] = t.Analysis
+	}
+
+	// Add free space on the server if it was checked
+	if t.RemoteDiskAvailable != nil {
+		metadata["remoteDiskAvailable"] = *t.RemoteDiskAvailable
+	}
+	if t.RemoteInodesAvailable != nil {
+		metadata["remoteInodesAvailable"] = *t.RemoteInodesAvailable
+	}
+
 	return metadata
 }
 
@@ -245,5 +264,9 @@ func (t *ExecutionTiming) Clone() *ExecutionTiming {
 		CleanupEnd:           t.CleanupEnd,
 		ServerName:           t.ServerName,
 		IsParallel:           t.IsParallel,
+		Analysis:             t.Analysis,
+
+		RemoteDiskAvailable:   t.RemoteDiskAvailable,
+		RemoteInodesAvailable: t.RemoteInodesAvailable,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,78 @@
+package ssh
+
+import (
+	stderrors "errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// uploadFile writes data to remotePath on the server with the configured
+// transfer method
+func (e *Executor) uploadFile(conn *ssh.Client, data []byte, remotePath string) error {
+	if e.config.Execution.Transfer.Method == "cat" {
+		return e.runWithInput(conn, fmt.Sprintf("cat > %s", remotePath), data)
+	}
+	return e.uploadSFTP(conn, data, remotePath)
+}
+
+// uploadSFTP uploads data over SFTP, retrying failed attempts from where the
+// previous attempt stopped
+func (e *Executor) uploadSFTP(conn *ssh.Client, data []byte, remotePath string) error {
+	cfg := e.config.Execution.Transfer
+	serverID := conn.RemoteAddr().String()
+	if err := e.policy.CheckPath(serverID, remotePath); err != nil {
+		return err
+	}
+
+	// The part name is unique to this upload so concurrent uploads of the
+	// same file never resume each other's parts
+	part := fmt.Sprintf("%s.part-%s", remotePath, strconv.FormatInt(time.Now().UnixNano(), 36))
+
+	var err error
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(cfg.RetryDelay)
+		}
+
+		var client *sftpClient
+		client, err = openSFTP(conn)
+		if stderrors.Is(err, errSFTPUnavailable) {
+			return fmt.Errorf("%w; set ssh.execution.transfer.method to cat for this server", err)
+		}
+		if err != nil {
+			continue
+		}
+
+		var offset int
+		offset, err = client.upload(data, part, remotePath, cfg.ChunkSize, cfg.Verify)
+		client.Close()
+		if err == nil {
+			if offset > 0 {
+				e.log.WithFields(logrus.Fields{
+					"serverID": serverID,
+					"path":     remotePath,
+					"offset":   offset,
+				}).Debug("Resumed upload")
+			}
+			return nil
+		}
+
+		e.log.WithFields(logrus.Fields{
+			"serverID": serverID,
+			"path":     remotePath,
+			"attempt":  attempt + 1,
+			"error":    err,
+		}).Warn("SFTP upload failed")
+	}
+
+	// Leave no partial file behind
+	if client, openErr := openSFTP(conn); openErr == nil {
+		client.remove(part)
+		client.Close()
+	}
+	return fmt.Errorf("failed to upload %s: %w", remotePath, err)
+}
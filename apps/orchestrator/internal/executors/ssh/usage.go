@@ -0,0 +1,98 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"golang.org/x/crypto/ssh"
+)
+
+// usagePrefix marks the line /usr/bin/time writes; GNU time puts a note
+// about a non-zero exit status before it
+const usagePrefix = "cronium-usage"
+
+// usageFormat has /usr/bin/time report elapsed seconds, user and system CPU
+// seconds, peak RSS in KiB and blocks read and written
+const usageFormat = usagePrefix + " %e %U %S %M %I %O"
+
+// Block I/O from rusage is counted in 512-byte blocks
+const usageBlockSize = 512
+
+// remoteUsageFile returns the path where /usr/bin/time writes the runner's
+// resource usage
+func remoteUsageFile(jobID string) string {
+	return fmt.Sprintf("/tmp/cronium-runner-%s.usage", jobID)
+}
+
+// withUsage wraps the runner command in /usr/bin/time when measuring usage
+// is enabled and the server has a time that writes formatted output to a
+// file, such as GNU time. Elsewhere the runner is started as before.
+func (e *Executor) withUsage(jobID, cmd string) string {
+	if !e.config.Execution.MeasureUsage {
+		return cmd
+	}
+	return fmt.Sprintf("if /usr/bin/time -o /dev/null -f %%M true >/dev/null 2>&1; then /usr/bin/time -o %s -f '%s' %s; else %s; fi",
+		remoteUsageFile(jobID), usageFormat, cmd, cmd)
+}
+
+// readRemoteUsage returns the usage /usr/bin/time recorded for the job's
+// runner, or nil when it was not measured
+func (e *Executor) readRemoteUsage(conn *ssh.Client, jobID string) *types.ResourceUsage {
+	if !e.config.Execution.MeasureUsage {
+		return nil
+	}
+	cmd := "cat " + remoteUsageFile(jobID)
+	if err := e.policy.Check(conn.RemoteAddr().String(), cmd); err != nil {
+		return nil
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		return nil
+	}
+	defer session.Close()
+
+	output, err := session.Output(cmd)
+	if err != nil {
+		return nil
+	}
+	usage, err := parseUsage(string(output))
+	if err != nil {
+		e.log.WithError(err).WithField("jobID", jobID).Debug("Failed to parse runner resource usage")
+		return nil
+	}
+	return usage
+}
+
+// parseUsage converts the output of /usr/bin/time in usageFormat
+func parseUsage(output string) (*types.ResourceUsage, error) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 7 || fields[0] != usagePrefix {
+			continue
+		}
+		values := make([]float64, 0, 6)
+		for _, f := range fields[1:] {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid usage value %q", f)
+			}
+			values = append(values, v)
+		}
+		elapsed, cpu := values[0], values[1]+values[2]
+		usage := &types.ResourceUsage{
+			CPUSeconds: cpu,
+			PeakMemory: int64(values[3]) * 1024,
+			DiskRead:   int64(values[4]) * usageBlockSize,
+			DiskWrite:  int64(values[5]) * usageBlockSize,
+		}
+		if elapsed > 0 {
+			usage.PeakCPU = cpu / elapsed * 100
+		}
+		return usage, nil
+	}
+	return nil, fmt.Errorf("no usage line in output")
+}
@@ -0,0 +1,227 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// BigQuery endpoints and scope
+const (
+	bigQueryEndpoint = "https://bigquery.googleapis.com/bigquery/v2"
+	bigQueryScope    = "https://www.googleapis.com/auth/bigquery.insertdata"
+	googleTokenURL   = "https://oauth2.googleapis.com/token"
+)
+
+// serviceAccount is the part of a Google service account key file the sink
+// uses
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// bigQuerySink streams records into a table with tabledata.insertAll. The
+// job ID is the insert ID, so BigQuery drops rows repeated by retries.
+type bigQuerySink struct {
+	cfg     config.BigQuerySinkConfig
+	account serviceAccount
+	client  *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// newBigQuerySink creates a BigQuery sink from a service account key file
+func newBigQuerySink(cfg config.BigQuerySinkConfig) (*bigQuerySink, error) {
+	data, err := os.ReadFile(cfg.CredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid credentials file: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("credentials file is not a service account key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURL
+	}
+	if _, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(account.PrivateKey)); err != nil {
+		return nil, fmt.Errorf("invalid service account private key: %w", err)
+	}
+
+	return &bigQuerySink{
+		cfg:     cfg,
+		account: account,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// Deliver inserts the batch, failing if BigQuery rejects any row
+func (s *bigQuerySink) Deliver(ctx context.Context, records []Record) error {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	type row struct {
+		InsertID string         `json:"insertId"`
+		JSON     map[string]any `json:"json"`
+	}
+	rows := make([]row, 0, len(records))
+	for _, rec := range records {
+		values, err := bigQueryRow(rec)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row{InsertID: rec.JobID, JSON: values})
+	}
+	body, err := json.Marshal(map[string]any{"rows": rows})
+	if err != nil {
+		return fmt.Errorf("failed to encode rows: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", bigQueryEndpoint,
+		url.PathEscape(s.cfg.Project), url.PathEscape(s.cfg.Dataset), url.PathEscape(s.cfg.Table))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("BigQuery returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	var result struct {
+		InsertErrors []struct {
+			Index  int `json:"index"`
+			Errors []struct {
+				Reason  string `json:"reason"`
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"insertErrors"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("invalid BigQuery response: %w", err)
+	}
+	if len(result.InsertErrors) > 0 {
+		first := result.InsertErrors[0]
+		msg := "unknown error"
+		if len(first.Errors) > 0 {
+			msg = first.Errors[0].Reason + ": " + first.Errors[0].Message
+		}
+		return fmt.Errorf("BigQuery rejected %d rows, first at index %d: %s", len(result.InsertErrors), first.Index, msg)
+	}
+	return nil
+}
+
+// accessToken returns a cached OAuth token, exchanging a signed assertion
+// for a new one shortly before it expires
+func (s *bigQuerySink) accessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Until(s.tokenExpiry) > time.Minute {
+		return s.token, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(s.account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid service account private key: %w", err)
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   s.account.ClientEmail,
+		"scope": bigQueryScope,
+		"aud":   s.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("token request returned %s: %s", resp.Status, token.Error)
+	}
+
+	s.token = token.AccessToken
+	s.tokenExpiry = now.Add(time.Duration(token.ExpiresIn) * time.Second)
+	return s.token, nil
+}
+
+// bigQueryRow converts a record to a row. Maps and lists of objects have no
+// fixed schema, so they are stored as JSON strings.
+func bigQueryRow(rec Record) (map[string]any, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+
+	for key, value := range values {
+		switch v := value.(type) {
+		case map[string]any:
+			encoded, _ := json.Marshal(v)
+			values[key] = string(encoded)
+		case []any:
+			if len(v) > 0 {
+				if _, isObject := v[0].(map[string]any); isObject {
+					encoded, _ := json.Marshal(v)
+					values[key] = string(encoded)
+				}
+			}
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,373 @@
+// Package export delivers a normalized record of each completed job to
+// external systems. Every sink has its own in-memory queue; records are sent
+// in batches and failed batches are retried with exponential backoff before
+// they are dropped.
+package export
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Queue defaults for sinks that do not set their own
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 10 * time.Second
+	defaultQueueSize     = 1000
+	defaultMaxAttempts   = 5
+	initialRetryDelay    = time.Second
+	maxRetryDelay        = time.Minute
+	deliveryTimeout      = 30 * time.Second
+)
+
+// Record is the normalized execution record sent to sinks
+type Record struct {
+	JobID          string              `json:"jobId"`
+	ParentJobID    string              `json:"parentJobId,omitempty"`
+	RootJobID      string              `json:"rootJobId,omitempty"`
+	EventID        string              `json:"eventId,omitempty"`
+	UserID         string              `json:"userId,omitempty"`
+	OrchestratorID string              `json:"orchestratorId"`
+	JobType        string              `json:"jobType"`
+	Tags           []string            `json:"tags,omitempty"`
+	Status         string              `json:"status"`
+	ExitCode       int                 `json:"exitCode"`
+	Attempt        int                 `json:"attempt"`
+	ScheduledFor   *time.Time          `json:"scheduledFor,omitempty"`
+	StartedAt      time.Time           `json:"startedAt"`
+	CompletedAt    time.Time           `json:"completedAt"`
+	DurationMs     int64               `json:"durationMs"`
+	Outputs        map[string]string   `json:"outputs,omitempty"`
+	Artifacts      []Artifact          `json:"artifacts,omitempty"`
+	Error          *types.ErrorDetails `json:"error,omitempty"`
+}
+
+// Artifact describes a file produced by a job
+type Artifact struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// NewRecord fills the job-derived fields of a record
+func NewRecord(job *types.Job, orchestratorID string) Record {
+	rec := Record{
+		JobID:          job.ID,
+		OrchestratorID: orchestratorID,
+		JobType:        string(job.Type),
+		Attempt:        job.Attempt(),
+		ScheduledFor:   job.ScheduledFor,
+	}
+	if v, ok := job.Metadata["eventId"]; ok {
+		rec.EventID = fmt.Sprintf("%v", v)
+	}
+	rec.UserID, _ = job.Metadata["userId"].(string)
+	rec.ParentJobID, rec.RootJobID = job.SubmittedBy()
+	if tags, ok := job.Metadata["tags"].([]any); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				rec.Tags = append(rec.Tags, s)
+			}
+		}
+	}
+	return rec
+}
+
+// ArtifactsFrom describes the files in a job's artifacts
+func ArtifactsFrom(artifacts *api.Artifacts) []Artifact {
+	if artifacts == nil {
+		return nil
+	}
+	files := make([]Artifact, 0, len(artifacts.Files))
+	for _, f := range artifacts.Files {
+		files = append(files, Artifact{Name: f.Name, Path: f.Path, Size: f.Size, MimeType: f.MimeType})
+	}
+	return files
+}
+
+// Sink delivers batches of records to an external system
+type Sink interface {
+	Deliver(ctx context.Context, records []Record) error
+}
+
+// extractor is a compiled output extractor
+type extractor struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Exporter queues records for the configured sinks
+type Exporter struct {
+	extractors []extractor
+	queues     []*queue
+	log        *logrus.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewExporter creates the configured sinks. It returns nil when no sinks
+// are configured; a nil exporter drops records.
+func NewExporter(cfg config.ExportConfig, log *logrus.Logger) (*Exporter, error) {
+	if len(cfg.Sinks) == 0 {
+		return nil, nil
+	}
+
+	e := &Exporter{log: log}
+	for _, ex := range cfg.Extractors {
+		pattern, err := regexp.Compile(ex.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for extractor %s: %w", ex.Name, err)
+		}
+		e.extractors = append(e.extractors, extractor{name: ex.Name, pattern: pattern})
+	}
+
+	for _, sinkCfg := range cfg.Sinks {
+		sink, err := newSink(sinkCfg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create export sink %s: %w", sinkCfg.Name, err)
+		}
+		e.queues = append(e.queues, newQueue(sinkCfg, sink, log))
+	}
+	return e, nil
+}
+
+// newSink creates a sink by type
+func newSink(cfg config.ExportSinkConfig) (Sink, error) {
+	switch cfg.Type {
+	case "webhook":
+		return newWebhookSink(cfg.Webhook), nil
+	case "s3":
+		return newS3Sink(cfg.S3)
+	case "bigquery":
+		return newBigQuerySink(cfg.BigQuery)
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+// Start starts delivering queued records
+func (e *Exporter) Start(ctx context.Context) {
+	if e == nil {
+		return
+	}
+
+	ctx, e.cancel = context.WithCancel(ctx)
+	for _, q := range e.queues {
+		e.wg.Add(1)
+		go func(q *queue) {
+			defer e.wg.Done()
+			q.run(ctx)
+		}(q)
+	}
+}
+
+// Stop delivers what is queued, making one attempt per batch, and stops
+func (e *Exporter) Stop() {
+	if e == nil || e.cancel == nil {
+		return
+	}
+	e.cancel()
+	e.wg.Wait()
+}
+
+// Export applies the output extractors to stdout and queues the record for
+// every sink whose filters match
+func (e *Exporter) Export(rec Record, stdout string) {
+	if e == nil {
+		return
+	}
+
+	if len(e.extractors) > 0 {
+		rec.Outputs = e.extract(stdout)
+	}
+	for _, q := range e.queues {
+		if q.matches(&rec) {
+			q.add(rec)
+		}
+	}
+}
+
+// extract returns the extractor values found in the output
+func (e *Exporter) extract(output string) map[string]string {
+	values := make(map[string]string)
+	lines := strings.Split(output, "\n")
+	for _, ex := range e.extractors {
+		for i := len(lines) - 1; i >= 0; i-- {
+			match := ex.pattern.FindStringSubmatch(lines[i])
+			if match == nil {
+				continue
+			}
+			if len(match) > 1 {
+				values[ex.name] = match[1]
+			} else {
+				values[ex.name] = match[0]
+			}
+			break
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// queue batches and delivers records to one sink
+type queue struct {
+	name          string
+	sink          Sink
+	jobTypes      []string
+	tags          []string
+	statuses      []string
+	batchSize     int
+	flushInterval time.Duration
+	maxAttempts   int
+	records       chan Record
+	log           *logrus.Entry
+}
+
+// newQueue creates a sink's queue, applying defaults
+func newQueue(cfg config.ExportSinkConfig, sink Sink, log *logrus.Logger) *queue {
+	q := &queue{
+		name:          cfg.Name,
+		sink:          sink,
+		jobTypes:      cfg.JobTypes,
+		tags:          cfg.Tags,
+		statuses:      cfg.Statuses,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		maxAttempts:   cfg.MaxAttempts,
+		log:           log.WithField("sink", cfg.Name),
+	}
+	if q.batchSize <= 0 {
+		q.batchSize = defaultBatchSize
+	}
+	if q.flushInterval <= 0 {
+		q.flushInterval = defaultFlushInterval
+	}
+	if q.maxAttempts <= 0 {
+		q.maxAttempts = defaultMaxAttempts
+	}
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = defaultQueueSize
+	}
+	q.records = make(chan Record, size)
+	return q
+}
+
+// matches reports whether a record passes the sink's filters
+func (q *queue) matches(rec *Record) bool {
+	if len(q.jobTypes) > 0 && !contains(q.jobTypes, rec.JobType) {
+		return false
+	}
+	if len(q.statuses) > 0 && !contains(q.statuses, rec.Status) {
+		return false
+	}
+	if len(q.tags) > 0 {
+		for _, tag := range rec.Tags {
+			if contains(q.tags, tag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// add queues a record, dropping it when the queue is full
+func (q *queue) add(rec Record) {
+	select {
+	case q.records <- rec:
+	default:
+		q.log.WithField("jobID", rec.JobID).Warn("Export queue full, dropping record")
+	}
+}
+
+// run sends batches until ctx is cancelled, then flushes what is left
+func (q *queue) run(ctx context.Context) {
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, q.batchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case rec := <-q.records:
+					batch = append(batch, rec)
+					if len(batch) >= q.batchSize {
+						q.deliver(context.Background(), batch, 1)
+						batch = batch[:0]
+					}
+				default:
+					q.deliver(context.Background(), batch, 1)
+					return
+				}
+			}
+
+		case rec := <-q.records:
+			batch = append(batch, rec)
+			if len(batch) >= q.batchSize {
+				q.deliver(ctx, batch, q.maxAttempts)
+				batch = batch[:0]
+			}
+
+		case <-ticker.C:
+			q.deliver(ctx, batch, q.maxAttempts)
+			batch = batch[:0]
+		}
+	}
+}
+
+// deliver sends a batch, retrying with exponential backoff
+func (q *queue) deliver(ctx context.Context, batch []Record, attempts int) {
+	if len(batch) == 0 {
+		return
+	}
+
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		deliverCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		err := q.sink.Deliver(deliverCtx, batch)
+		cancel()
+		if err == nil {
+			q.log.WithField("records", len(batch)).Debug("Exported execution records")
+			return
+		}
+		if attempt >= attempts {
+			q.log.WithError(err).WithField("records", len(batch)).Error("Failed to export execution records, dropping batch")
+			return
+		}
+		q.log.WithError(err).WithField("attempt", attempt).Warn("Failed to export execution records, retrying")
+
+		select {
+		case <-ctx.Done():
+			// Shutting down: one last attempt without the cancelled context
+			ctx = context.Background()
+			attempts = attempt + 1
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
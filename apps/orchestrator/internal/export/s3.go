@@ -0,0 +1,108 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sigv4"
+)
+
+// s3Sink writes each batch as a JSONL object, signing requests with AWS
+// Signature Version 4
+type s3Sink struct {
+	cfg    config.S3SinkConfig
+	signer sigv4.Signer
+	host   string
+	scheme string
+	client *http.Client
+	seq    atomic.Int64
+}
+
+// newS3Sink creates an S3 sink, taking missing credentials from the
+// environment
+func newS3Sink(cfg config.S3SinkConfig) (*s3Sink, error) {
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no S3 credentials configured")
+	}
+
+	s := &s3Sink{
+		cfg:    cfg,
+		scheme: "https",
+		client: &http.Client{Timeout: 30 * time.Second},
+		signer: sigv4.Signer{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+			Region:          cfg.Region,
+			Service:         "s3",
+		},
+	}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+		}
+		s.scheme = u.Scheme
+		s.host = u.Host
+	} else {
+		s.host = fmt.Sprintf("s3.%s.amazonaws.com", cfg.Region)
+	}
+	if !cfg.PathStyle {
+		s.host = cfg.Bucket + "." + s.host
+	}
+	return s, nil
+}
+
+// Deliver uploads the batch as one object named by date, orchestrator and
+// time
+func (s *s3Sink) Deliver(ctx context.Context, records []Record) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, rec := range records {
+		if err := encoder.Encode(rec); err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+	}
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s%s/%s-%d-%d.jsonl",
+		s.cfg.Prefix, now.Format("2006/01/02"), records[0].OrchestratorID, now.UnixNano(), s.seq.Add(1))
+	path := "/" + sigv4.EscapePath(key)
+	if s.cfg.PathStyle {
+		path = "/" + sigv4.EscapePath(s.cfg.Bucket) + path
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.scheme+"://"+s.host+path, bytes.NewReader(body.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	s.signer.Sign(req, sigv4.PayloadHash(body.Bytes()), now)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// webhookSink posts batches of records as a JSON array
+type webhookSink struct {
+	cfg    config.WebhookSinkConfig
+	client *http.Client
+}
+
+// newWebhookSink creates a webhook sink
+func newWebhookSink(cfg config.WebhookSinkConfig) *webhookSink {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &webhookSink{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Deliver posts the batch; any non-2xx response is a failure
+func (s *webhookSink) Deliver(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to encode records: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	if s.cfg.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.cfg.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Cronium-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package features evaluates feature flags. The configured values are the
+// defaults; operators can toggle flags at runtime through the admin API, and
+// the backend can override them for a tenant or a single job in the job
+// metadata. The most specific setting wins: job, then tenant, then runtime
+// toggle, then configuration.
+package features
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Flag names, matching the keys of the features configuration section
+const (
+	ContainerPooling   = "containerPooling"
+	AdvancedScheduling = "advancedScheduling"
+	DistributedTracing = "distributedTracing"
+	ExperimentalSSH    = "experimentalSSH"
+
+	// Rollout switches read by the SSH executor
+	SSHScriptCache = "sshScriptCache"
+	SSHSnapshots   = "sshSnapshots"
+	SSHCheckpoints = "sshCheckpoints"
+)
+
+// Job metadata keys carrying flag overrides from the backend
+const (
+	JobOverridesKey    = "featureFlags"
+	TenantOverridesKey = "tenantFeatureFlags"
+)
+
+// Sources of an orchestrator-wide flag value
+const (
+	SourceConfig  = "config"
+	SourceRuntime = "runtime"
+)
+
+// State describes a flag's current value on this orchestrator
+type State struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Default bool   `json:"default"`
+	Source  string `json:"source"`
+}
+
+// Registry holds the configured flags and runtime toggles
+type Registry struct {
+	mu       sync.RWMutex
+	defaults map[string]bool
+	runtime  map[string]bool
+}
+
+// NewRegistry creates a registry with the configured flag values as defaults
+func NewRegistry(cfg config.FeatureFlags) *Registry {
+	return &Registry{
+		defaults: map[string]bool{
+			ContainerPooling:   cfg.ContainerPooling,
+			AdvancedScheduling: cfg.AdvancedScheduling,
+			DistributedTracing: cfg.DistributedTracing,
+			ExperimentalSSH:    cfg.ExperimentalSSH,
+			SSHScriptCache:     cfg.SSHScriptCache,
+			SSHSnapshots:       cfg.SSHSnapshots,
+			SSHCheckpoints:     cfg.SSHCheckpoints,
+		},
+		runtime: make(map[string]bool),
+	}
+}
+
+// Enabled reports whether a flag is on for this orchestrator, ignoring job
+// and tenant overrides
+func (r *Registry) Enabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if enabled, ok := r.runtime[name]; ok {
+		return enabled
+	}
+	return r.defaults[name]
+}
+
+// EnabledFor reports whether a flag is on for a job
+func (r *Registry) EnabledFor(job *types.Job, name string) bool {
+	return r.evaluate(job, name)
+}
+
+// ForJob returns every flag's value for a job
+func (r *Registry) ForJob(job *types.Job) map[string]bool {
+	flags := make(map[string]bool)
+	for _, name := range r.Names() {
+		flags[name] = r.evaluate(job, name)
+	}
+	return flags
+}
+
+// Set toggles a flag at runtime until it is reset or the orchestrator restarts
+func (r *Registry) Set(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.defaults[name]; !ok {
+		return fmt.Errorf("unknown feature flag %q", name)
+	}
+	r.runtime[name] = enabled
+	return nil
+}
+
+// Reset removes a runtime toggle so the configured value applies again
+func (r *Registry) Reset(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.defaults[name]; !ok {
+		return fmt.Errorf("unknown feature flag %q", name)
+	}
+	delete(r.runtime, name)
+	return nil
+}
+
+// Names returns the known flags, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.defaults))
+	for name := range r.defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// States returns the orchestrator-wide value of every flag
+func (r *Registry) States() []State {
+	names := r.Names()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]State, 0, len(names))
+	for _, name := range names {
+		state := State{
+			Name:    name,
+			Enabled: r.defaults[name],
+			Default: r.defaults[name],
+			Source:  SourceConfig,
+		}
+		if enabled, ok := r.runtime[name]; ok {
+			state.Enabled = enabled
+			state.Source = SourceRuntime
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+// evaluate resolves a flag for a job, most specific setting first
+func (r *Registry) evaluate(job *types.Job, name string) bool {
+	if job != nil {
+		if enabled, ok := override(job.Metadata, JobOverridesKey, name); ok {
+			return enabled
+		}
+		if enabled, ok := override(job.Metadata, TenantOverridesKey, name); ok {
+			return enabled
+		}
+	}
+	return r.Enabled(name)
+}
+
+// override reads a boolean flag override from a metadata map
+func override(metadata map[string]any, key, name string) (bool, bool) {
+	overrides, ok := metadata[key].(map[string]any)
+	if !ok {
+		return false, false
+	}
+	enabled, ok := overrides[name].(bool)
+	return enabled, ok
+}
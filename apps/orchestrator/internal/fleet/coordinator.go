@@ -0,0 +1,128 @@
+package fleet
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// peerStaleAfter is the number of missed heartbeats after which a peer is ignored
+const peerStaleAfter = 3
+
+// PeerAPI is the subset of the backend API used for fleet coordination
+type PeerAPI interface {
+	RegisterAgent(ctx context.Context, reg *api.AgentRegistration) ([]api.Peer, error)
+	HandoffJob(ctx context.Context, jobID, targetOrchestratorID, reason string) error
+}
+
+// Load reports the local job counts included in each registration
+type Load func() (active, pending int)
+
+// Coordinator keeps track of peer orchestrators in the same region and hands
+// unstarted jobs to the ones with free capacity
+type Coordinator struct {
+	config        config.WorkStealingConfig
+	selfID        string
+	region        string
+	maxConcurrent int
+	api           PeerAPI
+	load          Load
+	log           *logrus.Logger
+
+	mu       sync.Mutex
+	peers    []api.Peer
+	reserved map[string]int // handoffs sent to each peer since the last refresh
+}
+
+// NewCoordinator creates a new fleet coordinator
+func NewCoordinator(cfg *config.Config, peerAPI PeerAPI, load Load, log *logrus.Logger) *Coordinator {
+	return &Coordinator{
+		config:        cfg.Jobs.WorkStealing,
+		selfID:        cfg.Orchestrator.ID,
+		region:        cfg.Orchestrator.Region,
+		maxConcurrent: cfg.Jobs.MaxConcurrent,
+		api:           peerAPI,
+		load:          load,
+		log:           log,
+		reserved:      make(map[string]int),
+	}
+}
+
+// Refresh registers this orchestrator with the backend and updates the peer list
+func (c *Coordinator) Refresh(ctx context.Context) error {
+	active, pending := c.load()
+
+	peers, err := c.api.RegisterAgent(ctx, &api.AgentRegistration{
+		Region:        c.region,
+		MaxConcurrent: c.maxConcurrent,
+		ActiveJobs:    active,
+		PendingJobs:   pending,
+		WorkStealing:  c.config.Enabled,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.peers = peers
+	c.reserved = make(map[string]int)
+	c.mu.Unlock()
+
+	c.log.WithField("peers", len(peers)).Debug("Refreshed fleet peers")
+	return nil
+}
+
+// IdlePeer returns the peer in this region with the most free capacity and
+// reserves one of its slots until the next refresh
+func (c *Coordinator) IdlePeer() (api.Peer, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	staleBefore := time.Now().Add(-peerStaleAfter * c.config.HeartbeatInterval)
+
+	candidates := make([]api.Peer, 0, len(c.peers))
+	for _, peer := range c.peers {
+		if peer.OrchestratorID == c.selfID || peer.Region != c.region || !peer.WorkStealing {
+			continue
+		}
+		if peer.LastSeen.Before(staleBefore) {
+			continue
+		}
+		if peer.FreeSlots()-c.reserved[peer.OrchestratorID] <= 0 {
+			continue
+		}
+		candidates = append(candidates, peer)
+	}
+	if len(candidates) == 0 {
+		return api.Peer{}, false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		fi := candidates[i].FreeSlots() - c.reserved[candidates[i].OrchestratorID]
+		fj := candidates[j].FreeSlots() - c.reserved[candidates[j].OrchestratorID]
+		return fi > fj
+	})
+
+	peer := candidates[0]
+	c.reserved[peer.OrchestratorID]++
+	return peer, true
+}
+
+// Handoff releases a job to a peer
+func (c *Coordinator) Handoff(ctx context.Context, jobID string, peer api.Peer) error {
+	err := c.api.HandoffJob(ctx, jobID, peer.OrchestratorID, "capacity")
+	if err != nil {
+		// Give the slot back so another job can try this peer
+		c.mu.Lock()
+		if c.reserved[peer.OrchestratorID] > 0 {
+			c.reserved[peer.OrchestratorID]--
+		}
+		c.mu.Unlock()
+	}
+	return err
+}
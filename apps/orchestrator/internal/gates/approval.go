@@ -0,0 +1,149 @@
+package gates
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// ApprovalSource requests approval decisions and reports their state
+type ApprovalSource interface {
+	RequestApproval(ctx context.Context, jobID string, approval *types.Approval, expiresAt time.Time) (*types.ApprovalDecision, error)
+	GetApproval(ctx context.Context, jobID string) (*types.ApprovalDecision, error)
+}
+
+// approvalWebhookPayload is posted to the configured approval webhook
+type approvalWebhookPayload struct {
+	Event     string         `json:"event"`
+	JobID     string         `json:"jobId"`
+	Message   string         `json:"message,omitempty"`
+	Approvers []string       `json:"approvers,omitempty"`
+	ExpiresAt string         `json:"expiresAt"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+}
+
+// WithApprovals sets where approval requests are sent
+func (w *Waiter) WithApprovals(source ApprovalSource) *Waiter {
+	w.approvals = source
+	return w
+}
+
+// WaitForApproval requests approval for the job and blocks until it is
+// approved. The backend holds the request and enforces who may decide it;
+// the configured webhook is only notified, since the job's author must not
+// be able to answer for the approvers. Rejection and expiry return
+// APPROVAL_REJECTED and APPROVAL_EXPIRED errors.
+func (w *Waiter) WaitForApproval(ctx context.Context, job *types.Job) (*types.ApprovalDecision, error) {
+	approval := job.Execution.Approval
+	if err := approval.Validate(); err != nil {
+		return nil, types.NewExecutionError("validation", "INVALID_APPROVAL", err.Error(), false)
+	}
+
+	expiry := approval.Expiry
+	if expiry <= 0 {
+		expiry = w.config.ApprovalExpiry
+	}
+	if w.config.MaxApprovalExpiry > 0 && expiry > w.config.MaxApprovalExpiry {
+		expiry = w.config.MaxApprovalExpiry
+	}
+	expiresAt := time.Now().Add(expiry)
+	log := w.log.WithField("jobID", job.ID)
+
+	if w.approvals == nil {
+		return nil, types.NewExecutionError("approval", "APPROVAL_UNAVAILABLE",
+			"approval could not be requested: approvals are not supported", true)
+	}
+	decision, err := w.approvals.RequestApproval(ctx, job.ID, approval, expiresAt)
+	if err != nil {
+		return nil, types.NewExecutionError("approval", "APPROVAL_UNAVAILABLE",
+			fmt.Sprintf("approval could not be requested: %v", err), true)
+	}
+
+	if w.config.ApprovalWebhookURL != "" {
+		if err := w.notifyApprovalWebhook(ctx, job, expiresAt); err != nil {
+			log.WithError(err).Warn("Failed to notify approval webhook")
+		}
+	}
+
+	for !decision.Decided() {
+		wait := time.Until(expiresAt)
+		if wait <= 0 {
+			decision = &types.ApprovalDecision{Status: types.ApprovalStatusExpired}
+			break
+		}
+		if wait > w.config.ApprovalInterval {
+			wait = w.config.ApprovalInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		current, err := w.approvals.GetApproval(ctx, job.ID)
+		if err != nil {
+			log.WithError(err).Debug("Failed to check approval")
+			continue
+		}
+		decision = current
+	}
+
+	switch decision.Status {
+	case types.ApprovalStatusApproved:
+		log.WithField("decidedBy", decision.DecidedBy).Info("Job approved")
+		return decision, nil
+	case types.ApprovalStatusExpired:
+		return decision, types.NewExecutionError("approval", "APPROVAL_EXPIRED",
+			fmt.Sprintf("approval not given within %s", expiry), false)
+	default:
+		message := "approval rejected"
+		if decision.DecidedBy != "" {
+			message += " by " + decision.DecidedBy
+		}
+		if decision.Comment != "" {
+			message += ": " + decision.Comment
+		}
+		return decision, types.NewExecutionError("approval", "APPROVAL_REJECTED", message, false)
+	}
+}
+
+// notifyApprovalWebhook posts the approval request to the configured webhook
+func (w *Waiter) notifyApprovalWebhook(ctx context.Context, job *types.Job, expiresAt time.Time) error {
+	approval := job.Execution.Approval
+	body, err := json.Marshal(approvalWebhookPayload{
+		Event:     "approval.requested",
+		JobID:     job.ID,
+		Message:   approval.Message,
+		Approvers: approval.Approvers,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+		Metadata:  job.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.ApprovalWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
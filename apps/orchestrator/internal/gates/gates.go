@@ -0,0 +1,188 @@
+package gates
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// FileChecker reports whether a path exists on a job's target
+type FileChecker interface {
+	FileExists(ctx context.Context, job *types.Job, path string) (bool, error)
+}
+
+// VariableSource returns the current value of a user variable
+type VariableSource interface {
+	GetVariable(ctx context.Context, userID, key string) (interface{}, error)
+}
+
+// Observer is told about every gate check so callers can surface progress
+type Observer func(gate types.Gate, satisfied bool, err error)
+
+// Waiter blocks until all of a job's gates hold
+type Waiter struct {
+	config    config.GatesConfig
+	files     FileChecker
+	variables VariableSource
+	approvals ApprovalSource
+	client    *http.Client
+	log       *logrus.Logger
+}
+
+// NewWaiter creates a gate waiter
+func NewWaiter(cfg config.GatesConfig, files FileChecker, variables VariableSource, log *logrus.Logger) *Waiter {
+	return &Waiter{
+		config:    cfg,
+		files:     files,
+		variables: variables,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		log:       log,
+	}
+}
+
+// Validate checks every gate of a job
+func (w *Waiter) Validate(job *types.Job) error {
+	for i, gate := range job.Execution.Gates {
+		if err := gate.Validate(); err != nil {
+			return types.NewExecutionError("validation", "INVALID_GATE", fmt.Sprintf("gate %d: %v", i, err), false)
+		}
+	}
+	return nil
+}
+
+// Wait checks the job's gates in order and returns once all of them hold. A
+// gate that does not hold within its timeout fails the wait with a
+// GATE_TIMEOUT error.
+func (w *Waiter) Wait(ctx context.Context, job *types.Job, observe Observer) error {
+	if err := w.Validate(job); err != nil {
+		return err
+	}
+
+	for _, gate := range job.Execution.Gates {
+		if err := w.waitFor(ctx, job, gate, observe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitFor polls one gate until it holds, it times out or ctx ends
+func (w *Waiter) waitFor(ctx context.Context, job *types.Job, gate types.Gate, observe Observer) error {
+	interval, timeout := w.limits(gate)
+	deadline := time.Now().Add(timeout)
+	log := w.log.WithField("jobID", job.ID).WithField("gate", gate.Describe())
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, interval+10*time.Second)
+		satisfied, err := w.check(checkCtx, job, gate)
+		cancel()
+
+		if observe != nil {
+			observe(gate, satisfied, err)
+		}
+		if satisfied {
+			log.Info("Gate satisfied")
+			return nil
+		}
+		if err != nil {
+			log.WithError(err).Debug("Gate check failed")
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			execErr := types.NewExecutionError("gate", "GATE_TIMEOUT",
+				fmt.Sprintf("gate %s not satisfied within %s", gate.Describe(), timeout), false)
+			if err != nil {
+				execErr.Details["lastError"] = err.Error()
+			}
+			return execErr
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// limits applies the configured defaults and bounds to a gate
+func (w *Waiter) limits(gate types.Gate) (time.Duration, time.Duration) {
+	interval := gate.Interval
+	if interval <= 0 {
+		interval = w.config.DefaultInterval
+	}
+	if interval < w.config.MinInterval {
+		interval = w.config.MinInterval
+	}
+
+	timeout := gate.Timeout
+	if timeout <= 0 {
+		timeout = w.config.DefaultTimeout
+	}
+	if w.config.MaxTimeout > 0 && timeout > w.config.MaxTimeout {
+		timeout = w.config.MaxTimeout
+	}
+	return interval, timeout
+}
+
+// check evaluates a gate once
+func (w *Waiter) check(ctx context.Context, job *types.Job, gate types.Gate) (bool, error) {
+	switch gate.Type {
+	case types.GateTypeHTTP:
+		return w.checkHTTP(ctx, gate)
+	case types.GateTypeVariable:
+		return w.checkVariable(ctx, job, gate)
+	case types.GateTypeFile:
+		if w.files == nil {
+			return false, fmt.Errorf("file gates are not supported")
+		}
+		return w.files.FileExists(ctx, job, gate.Path)
+	}
+	return false, fmt.Errorf("unsupported gate type %q", gate.Type)
+}
+
+// checkHTTP requests the gate URL and compares the status code
+func (w *Waiter) checkHTTP(ctx context.Context, gate types.Gate) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gate.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+
+	if resp.StatusCode != gate.ExpectedStatus() {
+		return false, fmt.Errorf("got status %d", resp.StatusCode)
+	}
+	return true, nil
+}
+
+// checkVariable compares the current variable value with the expected one.
+// Values are compared in their printed form so "1" matches 1.
+func (w *Waiter) checkVariable(ctx context.Context, job *types.Job, gate types.Gate) (bool, error) {
+	if w.variables == nil {
+		return false, fmt.Errorf("variable gates are not supported")
+	}
+	userID, _ := job.Metadata["userId"].(string)
+	if userID == "" {
+		return false, fmt.Errorf("job has no user ID to look up variables for")
+	}
+
+	value, err := w.variables.GetVariable(ctx, userID, gate.Variable)
+	if err != nil {
+		return false, err
+	}
+	if fmt.Sprint(value) != fmt.Sprint(gate.Equals) {
+		return false, fmt.Errorf("value is %v", value)
+	}
+	return true, nil
+}
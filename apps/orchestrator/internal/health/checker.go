@@ -2,13 +2,20 @@ package health
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/jitter"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
 	"github.com/docker/docker/client"
 	"github.com/sirupsen/logrus"
 )
@@ -20,8 +27,20 @@ const (
 	StatusHealthy   Status = "healthy"
 	StatusUnhealthy Status = "unhealthy"
 	StatusDegraded  Status = "degraded"
+	// Taking no new jobs and shutting down once the running ones finish
+	StatusDraining Status = "draining"
 )
 
+// Drainer is an orchestrator that can be drained before a shutdown
+type Drainer interface {
+	// Drain stops taking new jobs and shuts down once the running ones
+	// finished
+	Drain()
+
+	// Draining reports whether drain mode has started
+	Draining() bool
+}
+
 // Checker performs health checks on system components
 type Checker struct {
 	config       config.MonitoringConfig
@@ -31,6 +50,12 @@ type Checker struct {
 	mu         sync.RWMutex
 	lastCheck  time.Time
 	components map[string]ComponentStatus
+	features   *features.Registry
+
+	jitter       *jitter.Jitter
+	jitterFactor float64
+
+	drainer Drainer
 }
 
 // ComponentStatus represents the health of a component
@@ -46,6 +71,7 @@ type HealthResponse struct {
 	Status     Status                     `json:"status"`
 	Timestamp  time.Time                  `json:"timestamp"`
 	Components map[string]ComponentStatus `json:"components"`
+	Features   []features.State           `json:"features,omitempty"`
 }
 
 // NewChecker creates a new health checker
@@ -57,13 +83,36 @@ func NewChecker(cfg config.MonitoringConfig, log *logrus.Logger) *Checker {
 	}
 }
 
+// WithFeatures includes the feature flag states in the health report
+func (c *Checker) WithFeatures(reg *features.Registry) *Checker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.features = reg
+	return c
+}
+
+// WithDrainer reports the orchestrator as draining once drain mode started
+func (c *Checker) WithDrainer(d Drainer) *Checker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drainer = d
+	return c
+}
+
+// WithJitter spreads periodic checks by up to factor of the interval
+func (c *Checker) WithJitter(j *jitter.Jitter, factor float64) *Checker {
+	c.jitter = j
+	c.jitterFactor = factor
+	return c
+}
+
 // Start begins periodic health checks
 func (c *Checker) Start(ctx context.Context) {
 	// Initial check
 	c.checkAll(ctx)
 
 	// Periodic checks
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := c.jitter.NewTicker("health", 30*time.Second, c.jitterFactor)
 	defer ticker.Stop()
 
 	for {
@@ -81,9 +130,10 @@ func (c *Checker) GetHealth() *HealthResponse {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	// Copy components
+	// Copy components, keeping secrets out of error messages
 	components := make(map[string]ComponentStatus)
 	for k, v := range c.components {
+		v.Message = redact.String(v.Message)
 		components[k] = v
 	}
 
@@ -97,12 +147,19 @@ func (c *Checker) GetHealth() *HealthResponse {
 			status = StatusDegraded
 		}
 	}
+	if c.drainer != nil && c.drainer.Draining() && status != StatusUnhealthy {
+		status = StatusDraining
+	}
 
-	return &HealthResponse{
+	response := &HealthResponse{
 		Status:     status,
 		Timestamp:  time.Now(),
 		Components: components,
 	}
+	if c.features != nil {
+		response.Features = c.features.States()
+	}
+	return response
 }
 
 // checkAll performs all health checks
@@ -126,7 +183,7 @@ func (c *Checker) checkDocker(ctx context.Context) {
 	if c.dockerClient == nil {
 		// Try to create client
 		var err error
-		c.dockerClient, err = client.NewClientWithOpts(client.FromEnv)
+		c.dockerClient, err = client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 		if err != nil {
 			c.components["docker"] = ComponentStatus{
 				Status:    StatusUnhealthy,
@@ -175,12 +232,27 @@ func (c *Checker) checkAPI(ctx context.Context) {
 	}
 }
 
+// Job log tail limits for the job log endpoint
+const (
+	defaultLogTail = 200
+	maxLogTail     = 10000
+)
+
 // Server handles HTTP health check requests
 type Server struct {
 	config  config.MonitoringConfig
 	checker *Checker
 	log     *logrus.Logger
 	server  *http.Server
+
+	// Job logs on disk, served for debugging while the backend is down
+	mu          sync.RWMutex
+	jobLogs     *logger.LogStore
+	jobLogToken string
+
+	// Drained by POST /drain
+	drainer    Drainer
+	drainToken string
 }
 
 // NewServer creates a new health check server
@@ -192,6 +264,34 @@ func NewServer(cfg config.MonitoringConfig, checker *Checker, log *logrus.Logger
 	}
 }
 
+// WithJobLogs serves the stored job logs, requiring token as a bearer
+// token. Without a token the endpoints stay disabled.
+func (s *Server) WithJobLogs(store *logger.LogStore, token string) *Server {
+	if store != nil && token == "" {
+		s.log.Warn("Job log endpoints disabled: logging.jobs.token is not set")
+		store = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobLogs = store
+	s.jobLogToken = token
+	return s
+}
+
+// WithDrain lets POST /drain start drain mode, requiring token as a bearer
+// token. Without a token the endpoint stays disabled.
+func (s *Server) WithDrain(d Drainer, token string) *Server {
+	if d != nil && token == "" {
+		s.log.Info("POST /drain disabled: jobs.drain.token is not set")
+		d = nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainer = d
+	s.drainToken = token
+	return s
+}
+
 // Start starts the health check HTTP server
 func (s *Server) Start() error {
 	if !s.config.Enabled {
@@ -203,6 +303,9 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/health", s.handleHealth)
 	mux.HandleFunc("/ready", s.handleReady)
 	mux.HandleFunc("/live", s.handleLive)
+	mux.HandleFunc("GET /jobs/logs", s.handleListJobLogs)
+	mux.HandleFunc("GET /jobs/{id}/logs", s.handleJobLogs)
+	mux.HandleFunc("POST /drain", s.handleDrain)
 
 	s.server = &http.Server{
 		Addr:         fmt.Sprintf(":%d", s.config.HealthPort),
@@ -238,10 +341,48 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(health)
 }
 
-// handleReady returns readiness status
+// handleReady returns readiness status. A draining orchestrator is not
+// ready, so load balancers and deploy tooling move on to its replacement.
 func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
-	// For now, same as health
-	s.handleHealth(w, r)
+	health := s.checker.GetHealth()
+
+	statusCode := http.StatusOK
+	if health.Status == StatusUnhealthy || health.Status == StatusDraining {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(health)
+}
+
+// handleDrain starts drain mode
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	drainer, token := s.drainer, s.drainToken
+	s.mu.RUnlock()
+
+	if drainer == nil || token == "" {
+		writeJSONError(w, http.StatusNotFound, "drain is not available")
+		return
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "invalid or missing drain token")
+		return
+	}
+
+	if !drainer.Draining() {
+		s.log.WithField("remote", r.RemoteAddr).Info("Drain requested through the health server")
+	}
+	drainer.Drain()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    StatusDraining,
+		"timestamp": time.Now(),
+	})
 }
 
 // handleLive returns liveness status
@@ -254,3 +395,94 @@ func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
 		"timestamp": time.Now(),
 	})
 }
+
+// jobLogStore returns the job log store after checking the request's token,
+// writing the error response when the request cannot be served
+func (s *Server) jobLogStore(w http.ResponseWriter, r *http.Request) *logger.LogStore {
+	s.mu.RLock()
+	store, token := s.jobLogs, s.jobLogToken
+	s.mu.RUnlock()
+
+	if store == nil || token == "" {
+		writeJSONError(w, http.StatusNotFound, "job log files are not enabled")
+		return nil
+	}
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		writeJSONError(w, http.StatusUnauthorized, "invalid or missing job log token")
+		return nil
+	}
+	return store
+}
+
+// handleListJobLogs lists the jobs with stored logs
+func (s *Server) handleListJobLogs(w http.ResponseWriter, r *http.Request) {
+	store := s.jobLogStore(w, r)
+	if store == nil {
+		return
+	}
+
+	jobs, err := store.Jobs()
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to list job logs")
+		writeJSONError(w, http.StatusInternalServerError, "failed to list job logs")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobs":  jobs,
+		"count": len(jobs),
+	})
+}
+
+// handleJobLogs returns a job's most recent log lines. The tail query
+// parameter sets how many (default 200) and stream limits them to stdout,
+// stderr or system.
+func (s *Server) handleJobLogs(w http.ResponseWriter, r *http.Request) {
+	store := s.jobLogStore(w, r)
+	if store == nil {
+		return
+	}
+
+	tail := defaultLogTail
+	if v := r.URL.Query().Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSONError(w, http.StatusBadRequest, "tail must be a positive number")
+			return
+		}
+		tail = min(n, maxLogTail)
+	}
+
+	jobID := r.PathValue("id")
+	logs, found, err := store.Tail(jobID, tail, r.URL.Query().Get("stream"))
+	if err != nil {
+		s.log.WithError(err).WithField("jobID", jobID).Warn("Failed to read job log")
+		writeJSONError(w, http.StatusInternalServerError, "failed to read job log")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "no logs stored for job")
+		return
+	}
+	if logs == nil {
+		logs = []logger.LogMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId": jobID,
+		"logs":  logs,
+		"count": len(logs),
+	})
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":   http.StatusText(status),
+		"message": message,
+	})
+}
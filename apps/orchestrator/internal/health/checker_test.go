@@ -0,0 +1,56 @@
+package health
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDrainer struct {
+	drained bool
+}
+
+func (d *fakeDrainer) Drain()         { d.drained = true }
+func (d *fakeDrainer) Draining() bool { return d.drained }
+
+func newTestServer() *Server {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewServer(config.MonitoringConfig{}, nil, log)
+}
+
+func postDrain(s *Server, authorization string) int {
+	req := httptest.NewRequest(http.MethodPost, "/drain", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	s.handleDrain(rec, req)
+	return rec.Code
+}
+
+func TestDrainRejectedWithoutToken(t *testing.T) {
+	drainer := &fakeDrainer{}
+	s := newTestServer().WithDrain(drainer, "")
+
+	assert.Equal(t, http.StatusNotFound, postDrain(s, ""))
+	assert.Equal(t, http.StatusNotFound, postDrain(s, "Bearer "))
+	assert.False(t, drainer.drained)
+}
+
+func TestDrainRequiresToken(t *testing.T) {
+	drainer := &fakeDrainer{}
+	s := newTestServer().WithDrain(drainer, "drain-token")
+
+	assert.Equal(t, http.StatusUnauthorized, postDrain(s, ""))
+	assert.Equal(t, http.StatusUnauthorized, postDrain(s, "Bearer wrong"))
+	assert.False(t, drainer.drained)
+
+	assert.Equal(t, http.StatusAccepted, postDrain(s, "Bearer drain-token"))
+	assert.True(t, drainer.drained)
+}
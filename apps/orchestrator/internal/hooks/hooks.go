@@ -0,0 +1,310 @@
+// Package hooks runs operator-defined commands and webhooks on the agent
+// host before a job starts and after it finishes. Hooks prepare the host or
+// the network for a job, such as opening a firewall rule to its target, and
+// clean up or report afterwards. Their results are kept with the execution.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// PhasePre hooks run before a job starts
+	PhasePre = "pre"
+	// PhasePost hooks run after a job finished
+	PhasePost = "post"
+
+	defaultTimeout = 30 * time.Second
+	maxOutput      = 4096
+)
+
+// Event is what a hook is told about the job, as JSON on a command's stdin
+// or as a webhook's body
+type Event struct {
+	Phase          string          `json:"phase"`
+	JobID          string          `json:"jobId"`
+	JobType        types.JobType   `json:"jobType"`
+	EventID        string          `json:"eventId,omitempty"`
+	OrchestratorID string          `json:"orchestratorId"`
+	TargetHost     string          `json:"targetHost,omitempty"`
+	TargetPort     int             `json:"targetPort,omitempty"`
+	Status         types.JobStatus `json:"status,omitempty"`
+	ExitCode       *int            `json:"exitCode,omitempty"`
+	Timestamp      time.Time       `json:"timestamp"`
+}
+
+// Result is the outcome of one hook
+type Result struct {
+	Name       string `json:"name"`
+	Phase      string `json:"phase"`
+	Success    bool   `json:"success"`
+	Blocking   bool   `json:"blocking,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	ExitCode   *int   `json:"exitCode,omitempty"`
+	StatusCode int    `json:"statusCode,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Runner runs the configured hooks. A nil Runner has no hooks.
+type Runner struct {
+	cfg            config.HooksConfig
+	orchestratorID string
+	client         *http.Client
+	log            *logrus.Logger
+	onResult       func(Result)
+}
+
+// New creates a hook runner. It returns nil when no hooks are configured.
+func New(cfg config.HooksConfig, orchestratorID string, log *logrus.Logger) *Runner {
+	if len(cfg.Pre) == 0 && len(cfg.Post) == 0 {
+		return nil
+	}
+	return &Runner{
+		cfg:            cfg,
+		orchestratorID: orchestratorID,
+		client:         &http.Client{},
+		log:            log,
+	}
+}
+
+// OnResult sets a function called with every hook result
+func (r *Runner) OnResult(fn func(Result)) *Runner {
+	if r != nil {
+		r.onResult = fn
+	}
+	return r
+}
+
+// Pre runs the pre hooks for a job. It stops at the first failing blocking
+// hook and returns an error that fails the job, with the results so far in
+// its details.
+func (r *Runner) Pre(ctx context.Context, job *types.Job) ([]Result, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return r.run(ctx, r.cfg.Pre, r.event(PhasePre, job))
+}
+
+// Post runs the post hooks for a job that finished with status and exitCode.
+// All hooks run; the error reports the first failing blocking hook.
+func (r *Runner) Post(ctx context.Context, job *types.Job, status types.JobStatus, exitCode int) ([]Result, error) {
+	if r == nil {
+		return nil, nil
+	}
+	event := r.event(PhasePost, job)
+	event.Status = status
+	event.ExitCode = &exitCode
+	return r.run(ctx, r.cfg.Post, event)
+}
+
+// event describes a job to its hooks
+func (r *Runner) event(phase string, job *types.Job) Event {
+	event := Event{
+		Phase:          phase,
+		JobID:          job.ID,
+		JobType:        job.Type,
+		OrchestratorID: r.orchestratorID,
+		Timestamp:      time.Now(),
+	}
+	if v, ok := job.Metadata["eventId"]; ok && v != nil {
+		event.EventID = fmt.Sprintf("%v", v)
+	}
+	if server := job.Execution.Target.ServerDetails; server != nil {
+		event.TargetHost = server.Host
+		event.TargetPort = server.Port
+	}
+	return event
+}
+
+// run runs the hooks that apply to the job in order
+func (r *Runner) run(ctx context.Context, hooks []config.HookConfig, event Event) ([]Result, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode hook event: %w", err)
+	}
+
+	var results []Result
+	var blocked *types.ExecutionError
+	for _, hook := range hooks {
+		if len(hook.JobTypes) > 0 && !slices.Contains(hook.JobTypes, string(event.JobType)) {
+			continue
+		}
+
+		result := r.runHook(ctx, hook, event, body)
+		results = append(results, result)
+		if r.onResult != nil {
+			r.onResult(result)
+		}
+
+		log := r.log.WithFields(logrus.Fields{
+			"jobID":    event.JobID,
+			"hook":     hook.Name,
+			"phase":    event.Phase,
+			"duration": result.DurationMs,
+		})
+		if result.Success {
+			log.Debug("Job hook succeeded")
+			continue
+		}
+		log.WithField("error", result.Error).Warn("Job hook failed")
+
+		if result.Blocking && blocked == nil {
+			blocked = types.NewExecutionError("hook", "HOOK_FAILED",
+				fmt.Sprintf("%s hook %s failed: %s", event.Phase, hook.Name, result.Error), false)
+			// Later pre hooks would prepare for a job that does not run
+			if event.Phase == PhasePre {
+				break
+			}
+		}
+	}
+
+	if blocked != nil {
+		blocked.Details["hooks"] = results
+		return results, blocked
+	}
+	return results, nil
+}
+
+// runHook runs one hook with its timeout
+func (r *Runner) runHook(ctx context.Context, hook config.HookConfig, event Event, body []byte) Result {
+	timeout := hook.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := Result{
+		Name:     hook.Name,
+		Phase:    event.Phase,
+		Blocking: hook.OnFailure == "block",
+	}
+	start := time.Now()
+	var err error
+	if len(hook.Command) > 0 {
+		err = r.runCommand(ctx, hook, event, body, &result)
+	} else {
+		err = r.callWebhook(ctx, hook, body, &result)
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if err != nil {
+		result.Error = redact.String(err.Error())
+	} else {
+		result.Success = true
+	}
+	return result
+}
+
+// runCommand runs a command hook. The job is passed as JSON on stdin and
+// in CRONIUM_* variables.
+func (r *Runner) runCommand(ctx context.Context, hook config.HookConfig, event Event, body []byte, result *Result) error {
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.WaitDelay = time.Second
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"CRONIUM_HOOK_PHASE="+event.Phase,
+		"CRONIUM_JOB_ID="+event.JobID,
+		"CRONIUM_JOB_TYPE="+string(event.JobType),
+		"CRONIUM_EVENT_ID="+event.EventID,
+		"CRONIUM_ORCHESTRATOR_ID="+event.OrchestratorID,
+		"CRONIUM_TARGET_HOST="+event.TargetHost,
+		"CRONIUM_TARGET_PORT="+strconv.Itoa(event.TargetPort),
+	)
+	if event.Phase == PhasePost {
+		cmd.Env = append(cmd.Env,
+			"CRONIUM_JOB_STATUS="+string(event.Status),
+			"CRONIUM_EXIT_CODE="+strconv.Itoa(*event.ExitCode),
+		)
+	}
+
+	output := &limitedBuffer{max: maxOutput}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	result.Output = redact.String(output.String())
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		code := 0
+		result.ExitCode = &code
+	case errors.As(err, &exitErr):
+		code := exitErr.ExitCode()
+		result.ExitCode = &code
+		return fmt.Errorf("exited with code %d", code)
+	}
+	return err
+}
+
+// callWebhook posts the job to a webhook hook, signed like export webhooks
+// when the hook has a secret. Any non-2xx response is a failure.
+func (r *Runner) callWebhook(ctx context.Context, hook config.HookConfig, body []byte, result *Result) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range hook.Headers {
+		req.Header.Set(key, value)
+	}
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Cronium-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	output, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+	result.StatusCode = resp.StatusCode
+	result.Output = redact.String(string(output))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	buf bytes.Buffer
+	max int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
@@ -0,0 +1,105 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRunner(cfg config.HooksConfig) *Runner {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return New(cfg, "orchestrator-test", log)
+}
+
+func testJob() *types.Job {
+	return &types.Job{
+		ID:       "job_1",
+		Type:     types.JobTypeSSH,
+		Metadata: map[string]any{"eventId": 42},
+		Execution: types.ExecutionConfig{Target: types.Target{
+			ServerDetails: &types.ServerDetails{Host: "db.internal", Port: 22},
+		}},
+	}
+}
+
+func TestPreHooksStopAtBlockingFailure(t *testing.T) {
+	r := newTestRunner(config.HooksConfig{Pre: []config.HookConfig{
+		{Name: "open-firewall", Command: []string{"sh", "-c", `echo "opening $CRONIUM_TARGET_HOST for $CRONIUM_JOB_ID"`}},
+		{Name: "warn-only", Command: []string{"sh", "-c", "exit 3"}, OnFailure: "warn"},
+		{Name: "cmdb", Command: []string{"sh", "-c", "echo down >&2; exit 1"}, OnFailure: "block"},
+		{Name: "never-runs", Command: []string{"true"}},
+	}})
+
+	results, err := r.Pre(context.Background(), testJob())
+	require.Error(t, err)
+	var execErr *types.ExecutionError
+	require.True(t, errors.As(err, &execErr))
+	assert.Equal(t, "HOOK_FAILED", execErr.Code)
+
+	require.Len(t, results, 3)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, "opening db.internal for job_1\n", results[0].Output)
+	assert.False(t, results[1].Success)
+	assert.Equal(t, 3, *results[1].ExitCode)
+	assert.False(t, results[2].Success)
+	assert.True(t, results[2].Blocking)
+	assert.Equal(t, "down\n", results[2].Output)
+}
+
+func TestPostHookWebhook(t *testing.T) {
+	var received Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.NotEmpty(t, r.Header.Get("X-Cronium-Signature"))
+		json.NewDecoder(r.Body).Decode(&received)
+		w.Write([]byte("registered"))
+	}))
+	defer server.Close()
+
+	r := newTestRunner(config.HooksConfig{Post: []config.HookConfig{
+		{Name: "cmdb", URL: server.URL, Secret: "s3cret"},
+		{Name: "containers-only", Command: []string{"false"}, JobTypes: []string{"container"}, OnFailure: "block"},
+	}})
+
+	results, err := r.Post(context.Background(), testJob(), types.JobStatusFailed, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Success)
+	assert.Equal(t, http.StatusOK, results[0].StatusCode)
+	assert.Equal(t, "registered", results[0].Output)
+
+	assert.Equal(t, PhasePost, received.Phase)
+	assert.Equal(t, "42", received.EventID)
+	assert.Equal(t, types.JobStatusFailed, received.Status)
+	assert.Equal(t, 2, *received.ExitCode)
+}
+
+func TestHookTimeout(t *testing.T) {
+	r := newTestRunner(config.HooksConfig{Pre: []config.HookConfig{
+		{Name: "slow", Command: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond, OnFailure: "block"},
+	}})
+
+	results, err := r.Pre(context.Background(), testJob())
+	require.Error(t, err)
+	assert.Contains(t, results[0].Error, "timed out")
+}
+
+func TestNoHooks(t *testing.T) {
+	r := newTestRunner(config.HooksConfig{})
+	assert.Nil(t, r)
+
+	results, err := r.Pre(context.Background(), testJob())
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
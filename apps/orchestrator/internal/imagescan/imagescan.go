@@ -0,0 +1,273 @@
+// Package imagescan gates container jobs on the vulnerabilities of their
+// image. A scanner reports the image's vulnerabilities by severity; jobs
+// whose image has one at or above the tenant's threshold are blocked unless
+// they carry an emergency override, which is written to an audit log.
+package imagescan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Severities from lowest to highest
+var severities = []string{"UNKNOWN", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// severityRank returns a severity's position in severities, or -1
+func severityRank(severity string) int {
+	for i, s := range severities {
+		if strings.EqualFold(s, severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Report is a scanner's result for an image
+type Report struct {
+	// Digest of the scanned image, when the scanner reports it
+	Digest string `json:"digest,omitempty"`
+	// Vulnerability count per severity
+	Counts map[string]int `json:"counts"`
+	// IDs of the most severe vulnerabilities
+	Top []string `json:"top,omitempty"`
+}
+
+// Scanner reports an image's vulnerabilities. Digest is empty when the
+// image is not available locally.
+type Scanner interface {
+	Scan(ctx context.Context, image, digest string) (*Report, error)
+}
+
+// Verdict is the gate's decision for a job, attached to its execution
+type Verdict struct {
+	Image     string         `json:"image"`
+	Digest    string         `json:"digest,omitempty"`
+	Scanner   string         `json:"scanner"`
+	Severity  string         `json:"severity"`
+	Counts    map[string]int `json:"counts,omitempty"`
+	Top       []string       `json:"top,omitempty"`
+	Blocking  int            `json:"blocking"`
+	Blocked   bool           `json:"blocked"`
+	Cached    bool           `json:"cached"`
+	ScannedAt time.Time      `json:"scannedAt"`
+	// Override reason when a blocked image ran anyway
+	Override string `json:"override,omitempty"`
+	// Scanner error when the gate failed open
+	Error string `json:"error,omitempty"`
+}
+
+type cacheEntry struct {
+	report    *Report
+	scannedAt time.Time
+}
+
+// Gate checks job images before they run. A nil Gate allows every image.
+type Gate struct {
+	cfg     config.ImageScanConfig
+	scanner Scanner
+	log     *logrus.Logger
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+	scans singleflight.Group
+	audit sync.Mutex
+}
+
+// New creates the gate for the configured scanner. It returns nil when the
+// gate is disabled.
+func New(cfg config.ImageScanConfig, log *logrus.Logger) *Gate {
+	if !cfg.Enabled {
+		return nil
+	}
+	var scanner Scanner
+	switch cfg.Scanner {
+	case "registry":
+		scanner = newRegistryScanner(cfg.Registry)
+	default:
+		scanner = newTrivyScanner(cfg.Trivy)
+	}
+	return NewWithScanner(cfg, scanner, log)
+}
+
+// NewWithScanner creates the gate with a custom scanner
+func NewWithScanner(cfg config.ImageScanConfig, scanner Scanner, log *logrus.Logger) *Gate {
+	return &Gate{
+		cfg:     cfg,
+		scanner: scanner,
+		log:     log,
+		cache:   make(map[string]cacheEntry),
+	}
+}
+
+// Check returns the verdict for the image a job runs, and an
+// IMAGE_SCAN_BLOCKED error when the job may not run it. The digest, when
+// known, keys the verdict cache; otherwise the image reference does. It
+// returns a nil verdict when the gate is off for the job's tenant.
+func (g *Gate) Check(ctx context.Context, job *types.Job, image, digest string) (*Verdict, error) {
+	if g == nil {
+		return nil, nil
+	}
+	tenant, _ := job.Metadata["userId"].(string)
+	severity := g.cfg.Severity
+	if s, ok := g.cfg.TenantSeverity[tenant]; ok {
+		severity = s
+	}
+	if severity == "off" {
+		return nil, nil
+	}
+
+	verdict := &Verdict{
+		Image:    image,
+		Digest:   digest,
+		Scanner:  g.cfg.Scanner,
+		Severity: strings.ToUpper(severity),
+	}
+	report, scannedAt, cached, err := g.report(ctx, image, digest)
+	if err != nil {
+		if g.cfg.FailOpen {
+			g.log.WithError(err).WithFields(logrus.Fields{"jobID": job.ID, "image": image}).Warn("Image scan failed; running job unscanned")
+			verdict.Error = err.Error()
+			return verdict, nil
+		}
+		return verdict, types.NewExecutionError("image_scan", "IMAGE_SCAN_FAILED",
+			fmt.Sprintf("failed to scan image %s: %v", image, err), true)
+	}
+
+	if verdict.Digest == "" {
+		verdict.Digest = report.Digest
+	}
+	verdict.Counts = report.Counts
+	verdict.Top = report.Top
+	verdict.Cached = cached
+	verdict.ScannedAt = scannedAt
+	threshold := severityRank(severity)
+	for s, n := range report.Counts {
+		if severityRank(s) >= threshold {
+			verdict.Blocking += n
+		}
+	}
+	if verdict.Blocking == 0 {
+		return verdict, nil
+	}
+
+	if reason := strings.TrimSpace(job.Execution.ImageScanOverride); reason != "" && g.cfg.AllowOverride {
+		verdict.Override = reason
+		if err := g.recordOverride(job, tenant, verdict); err != nil {
+			// An override that cannot be audited is not honoured
+			return verdict, types.NewExecutionError("image_scan", "IMAGE_SCAN_AUDIT_FAILED",
+				fmt.Sprintf("image %s is blocked and the override could not be recorded: %v", image, err), false)
+		}
+		g.log.WithFields(logrus.Fields{
+			"jobID":    job.ID,
+			"tenant":   tenant,
+			"image":    image,
+			"blocking": verdict.Blocking,
+			"reason":   reason,
+		}).Warn("Running blocked image under emergency override")
+		return verdict, nil
+	}
+
+	verdict.Blocked = true
+	execErr := types.NewExecutionError("image_scan", "IMAGE_SCAN_BLOCKED",
+		fmt.Sprintf("image %s has %d vulnerabilities at or above %s severity", image, verdict.Blocking, verdict.Severity), false)
+	execErr.Details["imageScan"] = verdict
+	return verdict, execErr
+}
+
+// report returns the cached report for an image or scans it. Concurrent
+// checks of the same image share one scan.
+func (g *Gate) report(ctx context.Context, image, digest string) (*Report, time.Time, bool, error) {
+	key := digest
+	if key == "" {
+		key = image
+	}
+
+	g.mu.Lock()
+	entry, ok := g.cache[key]
+	if ok && time.Since(entry.scannedAt) > g.cfg.CacheTTL {
+		delete(g.cache, key)
+		ok = false
+	}
+	g.mu.Unlock()
+	if ok {
+		return entry.report, entry.scannedAt, true, nil
+	}
+
+	result, err, _ := g.scans.Do(key, func() (interface{}, error) {
+		scanCtx, cancel := context.WithTimeout(ctx, g.cfg.Timeout)
+		defer cancel()
+		report, err := g.scanner.Scan(scanCtx, image, digest)
+		if err != nil {
+			return nil, err
+		}
+		entry := cacheEntry{report: report, scannedAt: time.Now()}
+		g.mu.Lock()
+		g.cache[key] = entry
+		g.mu.Unlock()
+		return entry, nil
+	})
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	entry = result.(cacheEntry)
+	return entry.report, entry.scannedAt, false, nil
+}
+
+// auditRecord is one line of the audit log
+type auditRecord struct {
+	Time     time.Time      `json:"time"`
+	Event    string         `json:"event"`
+	JobID    string         `json:"jobId"`
+	EventID  any            `json:"eventId,omitempty"`
+	Tenant   string         `json:"tenant,omitempty"`
+	Image    string         `json:"image"`
+	Digest   string         `json:"digest,omitempty"`
+	Severity string         `json:"severity"`
+	Counts   map[string]int `json:"counts"`
+	Reason   string         `json:"reason"`
+}
+
+// recordOverride appends an override to the audit log
+func (g *Gate) recordOverride(job *types.Job, tenant string, verdict *Verdict) error {
+	line, err := json.Marshal(auditRecord{
+		Time:     time.Now().UTC(),
+		Event:    "image_scan_override",
+		JobID:    job.ID,
+		EventID:  job.Metadata["eventId"],
+		Tenant:   tenant,
+		Image:    verdict.Image,
+		Digest:   verdict.Digest,
+		Severity: verdict.Severity,
+		Counts:   verdict.Counts,
+		Reason:   verdict.Override,
+	})
+	if err != nil {
+		return err
+	}
+
+	g.audit.Lock()
+	defer g.audit.Unlock()
+	if err := os.MkdirAll(filepath.Dir(g.cfg.AuditLog), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(g.cfg.AuditLog, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
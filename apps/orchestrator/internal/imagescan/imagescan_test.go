@@ -0,0 +1,164 @@
+package imagescan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeScanner struct {
+	report *Report
+	err    error
+	scans  int
+}
+
+func (s *fakeScanner) Scan(ctx context.Context, image, digest string) (*Report, error) {
+	s.scans++
+	return s.report, s.err
+}
+
+func newTestGate(t *testing.T, scanner Scanner) (*Gate, string) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	audit := filepath.Join(t.TempDir(), "audit", "image-scan.jsonl")
+	return NewWithScanner(config.ImageScanConfig{
+		Enabled:        true,
+		Scanner:        "trivy",
+		Severity:       "HIGH",
+		CacheTTL:       time.Hour,
+		Timeout:        time.Minute,
+		TenantSeverity: map[string]string{"relaxed": "CRITICAL", "exempt": "off"},
+		AllowOverride:  true,
+		AuditLog:       audit,
+	}, scanner, log), audit
+}
+
+func testJob(tenant string) *types.Job {
+	return &types.Job{ID: "job_1", Metadata: map[string]interface{}{"userId": tenant}}
+}
+
+func TestGateBlocksAtThreshold(t *testing.T) {
+	scanner := &fakeScanner{report: &Report{Counts: map[string]int{"HIGH": 2, "LOW": 5}, Top: []string{"CVE-1"}}}
+	gate, _ := newTestGate(t, scanner)
+
+	verdict, err := gate.Check(context.Background(), testJob("user_1"), "app:1.0", "sha256:abc")
+	var execErr *types.ExecutionError
+	require.ErrorAs(t, err, &execErr)
+	assert.Equal(t, "IMAGE_SCAN_BLOCKED", execErr.Code)
+	assert.True(t, verdict.Blocked)
+	assert.Equal(t, 2, verdict.Blocking)
+
+	// The verdict for the digest is cached; a tenant with a higher
+	// threshold may run the image
+	verdict, err = gate.Check(context.Background(), testJob("relaxed"), "app:1.0", "sha256:abc")
+	require.NoError(t, err)
+	assert.False(t, verdict.Blocked)
+	assert.True(t, verdict.Cached)
+	assert.Equal(t, 1, scanner.scans)
+
+	verdict, err = gate.Check(context.Background(), testJob("exempt"), "app:1.0", "sha256:abc")
+	require.NoError(t, err)
+	assert.Nil(t, verdict)
+}
+
+func TestGateOverrideIsAudited(t *testing.T) {
+	gate, audit := newTestGate(t, &fakeScanner{report: &Report{Counts: map[string]int{"CRITICAL": 1}}})
+	job := testJob("user_1")
+	job.Execution.ImageScanOverride = "INC-42 hotfix"
+
+	verdict, err := gate.Check(context.Background(), job, "app:1.0", "sha256:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "INC-42 hotfix", verdict.Override)
+
+	data, err := os.ReadFile(audit)
+	require.NoError(t, err)
+	var record auditRecord
+	require.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, "image_scan_override", record.Event)
+	assert.Equal(t, "job_1", record.JobID)
+	assert.Equal(t, "user_1", record.Tenant)
+	assert.Equal(t, "sha256:abc", record.Digest)
+	assert.Equal(t, "INC-42 hotfix", record.Reason)
+
+	// Without permission to override the job stays blocked
+	gate.cfg.AllowOverride = false
+	_, err = gate.Check(context.Background(), job, "app:1.0", "sha256:abc")
+	assert.Error(t, err)
+}
+
+func TestGateScanFailure(t *testing.T) {
+	scanner := &fakeScanner{err: errors.New("server unreachable")}
+	gate, _ := newTestGate(t, scanner)
+
+	_, err := gate.Check(context.Background(), testJob("user_1"), "app:1.0", "")
+	var execErr *types.ExecutionError
+	require.ErrorAs(t, err, &execErr)
+	assert.Equal(t, "IMAGE_SCAN_FAILED", execErr.Code)
+
+	// Failures are not cached
+	gate.cfg.FailOpen = true
+	verdict, err := gate.Check(context.Background(), testJob("user_1"), "app:1.0", "")
+	require.NoError(t, err)
+	assert.Contains(t, verdict.Error, "server unreachable")
+	assert.Equal(t, 2, scanner.scans)
+}
+
+func TestParseTrivy(t *testing.T) {
+	report, err := parseTrivy([]byte(`{
+		"Metadata": {"RepoDigests": ["python@sha256:abc"]},
+		"Results": [
+			{"Vulnerabilities": [{"VulnerabilityID": "CVE-1", "Severity": "LOW"}, {"VulnerabilityID": "CVE-2", "Severity": "CRITICAL"}]},
+			{"Vulnerabilities": [{"VulnerabilityID": "CVE-3", "Severity": "weird"}]}
+		]
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:abc", report.Digest)
+	assert.Equal(t, map[string]int{"LOW": 1, "CRITICAL": 1, "UNKNOWN": 1}, report.Counts)
+	assert.Equal(t, "CVE-2", report.Top[0])
+}
+
+func TestParseHarbor(t *testing.T) {
+	report, err := parseHarbor([]byte(`{
+		"digest": "sha256:def",
+		"scan_overview": {"application/vnd.security.vulnerability.report; version=1.1": {
+			"scan_status": "Success",
+			"summary": {"total": 3, "summary": {"Critical": 1, "High": 2, "None": 0}}
+		}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:def", report.Digest)
+	assert.Equal(t, map[string]int{"CRITICAL": 1, "HIGH": 2}, report.Counts)
+
+	_, err = parseHarbor([]byte(`{"scan_overview": {"x": {"scan_status": "Running"}}}`))
+	assert.Error(t, err)
+	_, err = parseHarbor([]byte(`{}`))
+	assert.Error(t, err)
+}
+
+func TestSplitImage(t *testing.T) {
+	host, project, repo, ref, err := splitImage("harbor.example.com:8443/team/tools/runner:1.2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"harbor.example.com:8443", "team", "tools/runner", "1.2"}, []string{host, project, repo, ref})
+
+	_, _, _, ref, err = splitImage("harbor.example.com/team/app@sha256:abc")
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:abc", ref)
+
+	_, _, _, ref, _ = splitImage("harbor.example.com/team/app")
+	assert.Equal(t, "latest", ref)
+
+	_, _, _, _, err = splitImage("python:3.12")
+	assert.Error(t, err)
+	assert.Equal(t, "localhost:5000/app", repository("localhost:5000/app:1.0"))
+}
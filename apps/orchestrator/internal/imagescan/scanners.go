@@ -0,0 +1,229 @@
+package imagescan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// Vulnerability IDs kept in a report
+const maxTop = 5
+
+// trivyScanner runs the Trivy client against a Trivy server, which keeps the
+// vulnerability database
+type trivyScanner struct {
+	cfg config.TrivyScanConfig
+}
+
+func newTrivyScanner(cfg config.TrivyScanConfig) *trivyScanner {
+	return &trivyScanner{cfg: cfg}
+}
+
+// trivyOutput is the part of Trivy's JSON report the gate reads
+type trivyOutput struct {
+	Metadata struct {
+		RepoDigests []string `json:"RepoDigests"`
+	} `json:"Metadata"`
+	Results []struct {
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+func (s *trivyScanner) Scan(ctx context.Context, image, digest string) (*Report, error) {
+	target := image
+	if digest != "" {
+		target = repository(image) + "@" + digest
+	}
+
+	cmd := exec.CommandContext(ctx, s.cfg.Binary, "image",
+		"--server", s.cfg.Server,
+		"--format", "json",
+		"--scanners", "vuln",
+		"--quiet",
+		target)
+	// The token stays out of the process list
+	cmd.Env = append(os.Environ(), "TRIVY_TOKEN="+s.cfg.Token)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("trivy: %w: %s", err, truncate(strings.TrimSpace(stderr.String()), 300))
+	}
+	return parseTrivy(stdout.Bytes())
+}
+
+func parseTrivy(data []byte) (*Report, error) {
+	var out trivyOutput
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("unreadable trivy output: %w", err)
+	}
+
+	report := &Report{Counts: make(map[string]int)}
+	if len(out.Metadata.RepoDigests) > 0 {
+		if _, digest, ok := strings.Cut(out.Metadata.RepoDigests[0], "@"); ok {
+			report.Digest = digest
+		}
+	}
+
+	type finding struct {
+		id   string
+		rank int
+	}
+	var findings []finding
+	for _, result := range out.Results {
+		for _, v := range result.Vulnerabilities {
+			severity := strings.ToUpper(v.Severity)
+			if severityRank(severity) < 0 {
+				severity = "UNKNOWN"
+			}
+			report.Counts[severity]++
+			findings = append(findings, finding{v.VulnerabilityID, severityRank(severity)})
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].rank > findings[j].rank })
+	for _, f := range findings {
+		if len(report.Top) == maxTop {
+			break
+		}
+		report.Top = append(report.Top, f.id)
+	}
+	return report, nil
+}
+
+// registryScanner reads the scan results a Harbor-compatible registry keeps
+// for its artifacts
+type registryScanner struct {
+	cfg    config.RegistryScanConfig
+	client *http.Client
+}
+
+func newRegistryScanner(cfg config.RegistryScanConfig) *registryScanner {
+	return &registryScanner{cfg: cfg, client: &http.Client{}}
+}
+
+// harborArtifact is the part of a Harbor artifact the gate reads
+type harborArtifact struct {
+	Digest       string `json:"digest"`
+	ScanOverview map[string]struct {
+		ScanStatus string `json:"scan_status"`
+		Summary    struct {
+			Summary map[string]int `json:"summary"`
+		} `json:"summary"`
+	} `json:"scan_overview"`
+}
+
+func (s *registryScanner) Scan(ctx context.Context, image, digest string) (*Report, error) {
+	base, err := url.Parse(s.cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	host, project, repo, reference, err := splitImage(image)
+	if err != nil {
+		return nil, err
+	}
+	if host != base.Host {
+		return nil, fmt.Errorf("image %s is not in registry %s", image, base.Host)
+	}
+	if digest != "" {
+		reference = digest
+	}
+
+	// Harbor expects the repository name escaped twice
+	endpoint := fmt.Sprintf("%s/api/v2.0/projects/%s/repositories/%s/artifacts/%s?with_scan_overview=true",
+		strings.TrimSuffix(s.cfg.URL, "/"), url.PathEscape(project),
+		url.PathEscape(url.PathEscape(repo)), url.PathEscape(reference))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s: %s", resp.Status, truncate(strings.TrimSpace(string(body)), 300))
+	}
+	return parseHarbor(body)
+}
+
+func parseHarbor(data []byte) (*Report, error) {
+	var artifact harborArtifact
+	if err := json.Unmarshal(data, &artifact); err != nil {
+		return nil, fmt.Errorf("unreadable registry response: %w", err)
+	}
+	for _, overview := range artifact.ScanOverview {
+		if overview.ScanStatus != "Success" {
+			return nil, fmt.Errorf("registry scan status is %q", overview.ScanStatus)
+		}
+		report := &Report{Digest: artifact.Digest, Counts: make(map[string]int)}
+		for severity, n := range overview.Summary.Summary {
+			severity = strings.ToUpper(severity)
+			if severity == "NONE" {
+				continue
+			}
+			if severityRank(severity) < 0 {
+				severity = "UNKNOWN"
+			}
+			report.Counts[severity] += n
+		}
+		return report, nil
+	}
+	return nil, fmt.Errorf("registry has no scan results for the image")
+}
+
+// repository returns an image reference without its tag or digest
+func repository(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image = image[:i]
+	}
+	return image
+}
+
+// splitImage splits a registry image reference into the registry host, the
+// project, the repository within the project and the tag or digest
+func splitImage(image string) (host, project, repo, reference string, err error) {
+	name := repository(image)
+	reference = "latest"
+	if _, digest, ok := strings.Cut(image, "@"); ok {
+		reference = digest
+	} else if len(name) < len(image) {
+		reference = image[len(name)+1:]
+	}
+
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 3 || !strings.ContainsAny(parts[0], ".:") {
+		return "", "", "", "", fmt.Errorf("image %s is not a registry/project/repository reference", image)
+	}
+	return parts[0], parts[1], parts[2], reference, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
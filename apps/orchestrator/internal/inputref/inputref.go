@@ -0,0 +1,152 @@
+// Package inputref prepares the large input objects jobs reference for the
+// runner. References are checked against the configured limits and allowed
+// URLs, and s3:// references are turned into presigned https URLs, so the
+// runner downloads every object directly and the S3 credentials never leave
+// the orchestrator.
+package inputref
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sigv4"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// validName matches the names input objects are stored under in the
+// workspace's inputs directory
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// validSHA256 matches a hex SHA-256 digest
+var validSHA256 = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// Ref is an input object as the runner fetches it
+type Ref struct {
+	Name    string            `json:"name" yaml:"name"`
+	URL     string            `json:"url" yaml:"url"`
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	SHA256  string            `json:"sha256,omitempty" yaml:"sha256,omitempty"`
+	MaxSize int64             `json:"maxSize" yaml:"maxSize"`
+}
+
+// Resolver checks references and presigns the S3 ones
+type Resolver struct {
+	cfg config.InputRefsConfig
+}
+
+// New creates a resolver, taking missing S3 credentials from the
+// environment
+func New(cfg config.InputRefsConfig) *Resolver {
+	if cfg.S3.AccessKeyID == "" {
+		cfg.S3.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.S3.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.S3.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	return &Resolver{cfg: cfg}
+}
+
+// Resolve returns the references of a job as the runner fetches them
+func (r *Resolver) Resolve(refs []types.InputRef) ([]Ref, error) {
+	return r.resolve(refs, time.Now().UTC())
+}
+
+func (r *Resolver) resolve(refs []types.InputRef, now time.Time) ([]Ref, error) {
+	resolved := make([]Ref, 0, len(refs))
+	seen := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		if !validName.MatchString(ref.Name) {
+			return nil, fmt.Errorf("invalid input name %q: use letters, digits, '.', '_' and '-'", ref.Name)
+		}
+		if seen[ref.Name] {
+			return nil, fmt.Errorf("input %s is referenced twice", ref.Name)
+		}
+		seen[ref.Name] = true
+		if ref.SHA256 != "" && !validSHA256.MatchString(ref.SHA256) {
+			return nil, fmt.Errorf("input %s has an invalid SHA-256", ref.Name)
+		}
+		if ref.MaxSize < 0 {
+			return nil, fmt.Errorf("input %s has a negative size limit", ref.Name)
+		}
+
+		out := Ref{
+			Name:    ref.Name,
+			SHA256:  strings.ToLower(ref.SHA256),
+			MaxSize: r.cfg.MaxSize,
+		}
+		if ref.MaxSize > 0 && ref.MaxSize < out.MaxSize {
+			out.MaxSize = ref.MaxSize
+		}
+
+		if strings.HasPrefix(ref.URL, "s3://") {
+			presigned, err := r.presign(ref.URL, now)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", ref.Name, err)
+			}
+			out.URL = presigned
+		} else {
+			if !r.allowedURL(ref.URL) {
+				return nil, fmt.Errorf("input %s: URL %s is not allowed", ref.Name, ref.URL)
+			}
+			out.URL = ref.URL
+			out.Headers = ref.Headers
+		}
+		resolved = append(resolved, out)
+	}
+	return resolved, nil
+}
+
+// allowedURL reports whether an https URL starts with an allowed prefix
+func (r *Resolver) allowedURL(rawURL string) bool {
+	if !strings.HasPrefix(rawURL, "https://") {
+		return false
+	}
+	for _, prefix := range r.cfg.AllowedURLPrefixes {
+		if prefix != "" && strings.HasPrefix(rawURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// presign turns s3://bucket/key into a GET URL signed with AWS Signature
+// Version 4 in its query, valid for the presign expiry
+func (r *Resolver) presign(rawURL string, now time.Time) (string, error) {
+	s3 := r.cfg.S3
+	if s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+		return "", fmt.Errorf("no S3 credentials configured")
+	}
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(rawURL, "s3://"), "/")
+	if !ok || bucket == "" || key == "" {
+		return "", fmt.Errorf("invalid S3 URL %s: expected s3://bucket/key", rawURL)
+	}
+
+	u := &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", s3.Region)}
+	if s3.Endpoint != "" {
+		endpoint, err := url.Parse(s3.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return "", fmt.Errorf("invalid S3 endpoint %q", s3.Endpoint)
+		}
+		u.Scheme, u.Host = endpoint.Scheme, endpoint.Host
+	}
+	u.Path = "/" + key
+	if s3.PathStyle {
+		u.Path = "/" + bucket + u.Path
+	} else {
+		u.Host = bucket + "." + u.Host
+	}
+
+	signer := sigv4.Signer{
+		AccessKeyID:     s3.AccessKeyID,
+		SecretAccessKey: s3.SecretAccessKey,
+		SessionToken:    s3.SessionToken,
+		Region:          s3.Region,
+		Service:         "s3",
+	}
+	return signer.Presign(http.MethodGet, u, r.cfg.PresignExpiry, now), nil
+}
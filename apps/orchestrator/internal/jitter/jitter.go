@@ -0,0 +1,111 @@
+// Package jitter spreads periodic work across orchestrators. Each
+// orchestrator derives a fixed phase offset per loop and a random source from
+// its seed, so agents started together do not poll, report and clean up in
+// lockstep. The seed is the configured one, the orchestrator ID, or the
+// hostname when the ID is generated at startup, so a given agent keeps the
+// same phase across restarts.
+package jitter
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// Jitter produces jittered tickers. A nil Jitter produces plain tickers.
+type Jitter struct {
+	seed string
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates a jitter source seeded from the configured seed or the
+// orchestrator ID. It returns nil when jitter is disabled.
+func New(cfg config.JitterConfig, orchestratorID string) *Jitter {
+	if !cfg.Enabled {
+		return nil
+	}
+	seed := cfg.Seed
+	if seed == "" {
+		seed = orchestratorID
+	}
+	return &Jitter{
+		seed: seed,
+		rng:  rand.New(rand.NewSource(int64(hash(seed)))),
+	}
+}
+
+// Offset returns the fixed offset of a named loop within period
+func (j *Jitter) Offset(name string, period time.Duration) time.Duration {
+	if j == nil || period <= 0 {
+		return 0
+	}
+	return time.Duration(hash(j.seed+"/"+name) % uint64(period))
+}
+
+// Spread returns d moved randomly by up to factor*d either way
+func (j *Jitter) Spread(d time.Duration, factor float64) time.Duration {
+	if j == nil || factor <= 0 || d <= 0 {
+		return d
+	}
+	j.mu.Lock()
+	r := j.rng.Float64()*2 - 1
+	j.mu.Unlock()
+	return d + time.Duration(r*factor*float64(d))
+}
+
+// Ticker delivers ticks like time.Ticker, with the first tick at the loop's
+// offset within the interval and each later one after a spread interval.
+// Like time.Ticker it drops ticks a slow receiver misses.
+type Ticker struct {
+	C <-chan time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewTicker starts a ticker for a named loop. With a nil Jitter or a zero
+// factor and no offset it ticks at a fixed interval.
+func (j *Jitter) NewTicker(name string, interval time.Duration, factor float64) *Ticker {
+	c := make(chan time.Time, 1)
+	t := &Ticker{C: c, stop: make(chan struct{})}
+
+	go func() {
+		wait := interval
+		if j != nil {
+			wait = j.Offset(name, interval)
+		}
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-t.stop:
+				return
+			case now := <-timer.C:
+				select {
+				case c <- now:
+				default:
+				}
+				timer.Reset(j.Spread(interval, factor))
+			}
+		}
+	}()
+	return t
+}
+
+// Stop stops the ticker
+func (t *Ticker) Stop() {
+	t.stopOnce.Do(func() { close(t.stop) })
+}
+
+// hash returns the FNV-1a hash of s
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
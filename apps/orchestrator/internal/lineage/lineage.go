@@ -0,0 +1,264 @@
+// Package lineage tracks the trees formed by jobs that submit child jobs.
+// Every job this orchestrator runs is linked to the job that submitted it, so
+// an operator can see how a whole tree is doing and cancel a job together
+// with everything below it. Jobs of a tree that ran elsewhere appear without
+// a status and do not count towards the rollup.
+package lineage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Node is a job in a tree with the rollup status of its subtree
+type Node struct {
+	JobID string `json:"jobId"`
+	// Empty for jobs that did not run on this orchestrator
+	Status types.JobStatus `json:"status,omitempty"`
+	// running while any job below is unfinished, otherwise failed when any
+	// failed, cancelled when any was cancelled, and completed
+	Rollup          types.JobStatus `json:"rollup,omitempty"`
+	CancelledReason string          `json:"cancelledReason,omitempty"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	Children        []*Node         `json:"children,omitempty"`
+}
+
+// entry is a tracked job
+type entry struct {
+	parentID  string
+	status    types.JobStatus
+	cancelled string
+	updatedAt time.Time
+	children  []string
+}
+
+// Store holds the job trees of this orchestrator
+type Store struct {
+	cfg config.LineageConfig
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// New creates an empty store
+func New(cfg config.LineageConfig) *Store {
+	return &Store{
+		cfg:     cfg,
+		entries: make(map[string]*entry),
+	}
+}
+
+// Track adds a job to its tree. When the job or one above it has been
+// cancelled, it returns the reason and true; the job should then not run.
+func (s *Store) Track(job *types.Job) (string, bool) {
+	parentID, rootID := job.SubmittedBy()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.pruneLocked(now)
+
+	e := s.entryLocked(job.ID, now)
+	e.status = types.JobStatusAcknowledged
+	e.updatedAt = now
+	if parentID != "" && s.linkLocked(job.ID, parentID, now) && rootID != parentID {
+		// The root links the tree even when the jobs between ran elsewhere
+		s.linkLocked(parentID, rootID, now)
+	}
+
+	for id := job.ID; id != ""; id = s.entries[id].parentID {
+		if reason := s.entries[id].cancelled; reason != "" {
+			e.cancelled = reason
+			return reason, true
+		}
+	}
+	return "", false
+}
+
+// SetStatus records a tracked job's status
+func (s *Store) SetStatus(jobID string, status types.JobStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[jobID]; ok {
+		e.status = status
+		e.updatedAt = time.Now()
+	}
+}
+
+// Cancel marks a job and all jobs below it as cancelled, so that children
+// submitted later are not run either. It returns the IDs of the job and its
+// descendants, or false when the job is unknown.
+func (s *Store) Cancel(jobID, reason string) ([]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[jobID]; !ok {
+		return nil, false
+	}
+	now := time.Now()
+	ids := s.subtreeLocked(jobID)
+	for _, id := range ids {
+		e := s.entries[id]
+		if e.cancelled == "" {
+			e.cancelled = reason
+			e.updatedAt = now
+		}
+	}
+	return ids, true
+}
+
+// Tree returns the whole tree a job belongs to, starting at its root
+func (s *Store) Tree(jobID string) (*Node, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[jobID]; !ok {
+		return nil, false
+	}
+	return s.nodeLocked(s.rootLocked(jobID)), true
+}
+
+// entryLocked returns a job's entry, adding an empty one for unknown jobs;
+// s.mu must be held
+func (s *Store) entryLocked(jobID string, now time.Time) *entry {
+	e, ok := s.entries[jobID]
+	if !ok {
+		e = &entry{updatedAt: now}
+		s.entries[jobID] = e
+	}
+	return e
+}
+
+// linkLocked makes a job a child of parentID unless it already has a parent
+// or the link would form a cycle. It reports whether the job has a parent
+// afterwards; s.mu must be held.
+func (s *Store) linkLocked(jobID, parentID string, now time.Time) bool {
+	e := s.entryLocked(jobID, now)
+	if e.parentID != "" {
+		return true
+	}
+	if _, ok := s.entries[parentID]; ok {
+		for _, id := range s.subtreeLocked(jobID) {
+			if id == parentID {
+				return false
+			}
+		}
+	}
+	e.parentID = parentID
+	parent := s.entryLocked(parentID, now)
+	parent.children = append(parent.children, jobID)
+	return true
+}
+
+// rootLocked returns the root of a job's tree; s.mu must be held
+func (s *Store) rootLocked(jobID string) string {
+	for s.entries[jobID].parentID != "" {
+		jobID = s.entries[jobID].parentID
+	}
+	return jobID
+}
+
+// subtreeLocked returns a job and its descendants; s.mu must be held
+func (s *Store) subtreeLocked(jobID string) []string {
+	ids := []string{jobID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, s.entries[ids[i]].children...)
+	}
+	return ids
+}
+
+// nodeLocked builds the tree below a job and its rollup; s.mu must be held
+func (s *Store) nodeLocked(jobID string) *Node {
+	e := s.entries[jobID]
+	node := &Node{
+		JobID:           jobID,
+		Status:          e.status,
+		Rollup:          e.status,
+		CancelledReason: e.cancelled,
+		UpdatedAt:       e.updatedAt,
+	}
+	statuses := []types.JobStatus{e.status}
+	for _, childID := range e.children {
+		child := s.nodeLocked(childID)
+		node.Children = append(node.Children, child)
+		statuses = append(statuses, child.Rollup)
+	}
+	sort.Slice(node.Children, func(i, j int) bool { return node.Children[i].JobID < node.Children[j].JobID })
+	node.Rollup = Rollup(statuses)
+	return node
+}
+
+// pruneLocked forgets trees whose jobs have all finished and that have not
+// changed for the retention period; s.mu must be held
+func (s *Store) pruneLocked(now time.Time) {
+	cutoff := now.Add(-s.cfg.Retention)
+	for id, e := range s.entries {
+		if e.parentID != "" {
+			continue
+		}
+		ids := s.subtreeLocked(id)
+		settled := true
+		for _, memberID := range ids {
+			member := s.entries[memberID]
+			if member.updatedAt.After(cutoff) || (member.status != "" && !finished(member.status)) {
+				settled = false
+				break
+			}
+		}
+		if settled {
+			for _, memberID := range ids {
+				delete(s.entries, memberID)
+			}
+		}
+	}
+}
+
+// Rollup combines the statuses of the jobs in a tree. Empty statuses, of
+// jobs that ran elsewhere, are ignored.
+func Rollup(statuses []types.JobStatus) types.JobStatus {
+	var failed, cancelled, interrupted, known bool
+	for _, status := range statuses {
+		switch status {
+		case "":
+			continue
+		case types.JobStatusCompleted:
+		case types.JobStatusFailed, types.JobStatusTimeout, types.JobStatusDeadlineExceeded:
+			failed = true
+		case types.JobStatusCancelled, types.JobStatusSkipped, types.JobStatusReplaced:
+			cancelled = true
+		case types.JobStatusInterrupted:
+			interrupted = true
+		default:
+			return types.JobStatusRunning
+		}
+		known = true
+	}
+	switch {
+	case !known:
+		return ""
+	case failed:
+		return types.JobStatusFailed
+	case cancelled:
+		return types.JobStatusCancelled
+	case interrupted:
+		return types.JobStatusInterrupted
+	}
+	return types.JobStatusCompleted
+}
+
+// finished reports whether a job has stopped on this orchestrator
+func finished(status types.JobStatus) bool {
+	switch status {
+	case types.JobStatusCompleted, types.JobStatusFailed, types.JobStatusTimeout,
+		types.JobStatusDeadlineExceeded, types.JobStatusCancelled, types.JobStatusInterrupted,
+		types.JobStatusSkipped, types.JobStatusReplaced:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package lineage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func child(id, parentID, rootID string) *types.Job {
+	return &types.Job{ID: id, Metadata: map[string]any{"parentJobId": parentID, "rootJobId": rootID}}
+}
+
+func TestTreeRollup(t *testing.T) {
+	s := New(config.LineageConfig{Retention: time.Hour})
+
+	s.Track(&types.Job{ID: "root"})
+	s.Track(child("a", "root", "root"))
+	s.Track(child("b", "root", "root"))
+	// The parent of c ran on another orchestrator
+	s.Track(child("c", "remote", "root"))
+
+	tree, ok := s.Tree("c")
+	require.True(t, ok)
+	assert.Equal(t, "root", tree.JobID)
+	require.Len(t, tree.Children, 3)
+	assert.Equal(t, "remote", tree.Children[2].JobID)
+	assert.Empty(t, tree.Children[2].Status)
+	assert.Equal(t, types.JobStatusRunning, tree.Rollup)
+
+	for _, id := range []string{"root", "a", "b", "c"} {
+		s.SetStatus(id, types.JobStatusCompleted)
+	}
+	tree, _ = s.Tree("root")
+	assert.Equal(t, types.JobStatusCompleted, tree.Rollup)
+
+	s.SetStatus("c", types.JobStatusFailed)
+	tree, _ = s.Tree("root")
+	assert.Equal(t, types.JobStatusFailed, tree.Rollup)
+	assert.Equal(t, types.JobStatusCompleted, tree.Children[0].Rollup)
+	assert.Equal(t, types.JobStatusFailed, tree.Children[2].Rollup)
+
+	_, ok = s.Tree("missing")
+	assert.False(t, ok)
+}
+
+func TestCancelPropagatesToDescendants(t *testing.T) {
+	s := New(config.LineageConfig{Retention: time.Hour})
+
+	s.Track(&types.Job{ID: "root"})
+	s.Track(child("a", "root", "root"))
+	s.Track(child("a1", "a", "root"))
+	s.Track(child("b", "root", "root"))
+
+	ids, ok := s.Cancel("a", "operator")
+	require.True(t, ok)
+	assert.ElementsMatch(t, []string{"a", "a1"}, ids)
+
+	// Children submitted after the cancellation are cancelled too
+	reason, cancelled := s.Track(child("a2", "a", "root"))
+	assert.True(t, cancelled)
+	assert.Equal(t, "operator", reason)
+	_, cancelled = s.Track(child("b1", "b", "root"))
+	assert.False(t, cancelled)
+
+	_, ok = s.Cancel("missing", "operator")
+	assert.False(t, ok)
+}
+
+func TestCyclesAreNotLinked(t *testing.T) {
+	s := New(config.LineageConfig{Retention: time.Hour})
+
+	s.Track(child("a", "a", "a"))
+	s.Track(child("b", "a", "a"))
+	s.Track(child("a", "b", "b"))
+
+	tree, ok := s.Tree("b")
+	require.True(t, ok)
+	assert.Equal(t, "a", tree.JobID)
+	require.Len(t, tree.Children, 1)
+	assert.Equal(t, "b", tree.Children[0].JobID)
+}
+
+func TestFinishedTreesArePruned(t *testing.T) {
+	s := New(config.LineageConfig{Retention: time.Millisecond})
+
+	s.Track(&types.Job{ID: "done"})
+	s.Track(child("done-1", "done", "done"))
+	s.SetStatus("done", types.JobStatusCompleted)
+	s.SetStatus("done-1", types.JobStatusFailed)
+	s.Track(&types.Job{ID: "running"})
+	time.Sleep(5 * time.Millisecond)
+
+	s.Track(&types.Job{ID: "next"})
+	_, ok := s.Tree("done-1")
+	assert.False(t, ok)
+	_, ok = s.Tree("running")
+	assert.True(t, ok)
+}
+
+func TestRollup(t *testing.T) {
+	assert.Equal(t, types.JobStatus(""), Rollup(nil))
+	assert.Equal(t, types.JobStatusCompleted, Rollup([]types.JobStatus{"", types.JobStatusCompleted}))
+	assert.Equal(t, types.JobStatusCancelled, Rollup([]types.JobStatus{types.JobStatusCompleted, types.JobStatusCancelled}))
+	assert.Equal(t, types.JobStatusFailed, Rollup([]types.JobStatus{types.JobStatusCancelled, types.JobStatusTimeout}))
+	assert.Equal(t, types.JobStatusRunning, Rollup([]types.JobStatus{types.JobStatusFailed, types.JobStatusWaiting}))
+}
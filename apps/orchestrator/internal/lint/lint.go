@@ -0,0 +1,489 @@
+// Package lint checks job and event specification files before they are
+// deployed. A spec is checked against the same types, executor rules and
+// configured limits the orchestrator applies at run time, and every problem is
+// reported as a diagnostic with its position in the file, so CI pipelines can
+// reject broken definitions instead of finding out when the job first runs.
+package lint
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	pkgerrors "github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/schedule"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic severities
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Diagnostic is one problem found in a spec
+type Diagnostic struct {
+	File     string `json:"file"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Field    string `json:"field,omitempty"`
+	Message  string `json:"message"`
+}
+
+// String formats the diagnostic as file:line:column: severity: message [code]
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, d.Line)
+	}
+	if d.Column > 0 {
+		pos = fmt.Sprintf("%s:%d", pos, d.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s [%s]", pos, d.Severity, d.Message, d.Code)
+}
+
+var (
+	envNamePattern   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	unknownFieldLine = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+$`)
+	yamlErrorLine    = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+)
+
+// Linter checks specs against an orchestrator configuration
+type Linter struct {
+	config  *config.Config
+	catalog *sandbox.Catalog
+}
+
+// New creates a linter for the limits and sandbox profiles in cfg
+func New(cfg *config.Config) (*Linter, error) {
+	catalog, err := sandbox.NewCatalog(cfg.Container)
+	if err != nil {
+		return nil, err
+	}
+	return &Linter{config: cfg, catalog: catalog}, nil
+}
+
+// LintFile reads and checks a spec file
+func (l *Linter) LintFile(path string) []Diagnostic {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []Diagnostic{{File: path, Severity: SeverityError, Code: "read", Message: err.Error()}}
+	}
+	return l.Lint(path, data)
+}
+
+// Lint checks a spec. Script files are resolved relative to the spec's
+// directory.
+func (l *Linter) Lint(path string, data []byte) []Diagnostic {
+	c := &checker{file: path}
+
+	if err := yaml.Unmarshal(data, &c.root); err != nil {
+		c.parseError(err)
+		return c.diags
+	}
+
+	var spec Spec
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&spec); err != nil {
+		// Type errors still decode every other field, so keep checking
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			c.parseError(err)
+			return c.diags
+		}
+		for _, msg := range typeErr.Errors {
+			c.parseError(errors.New(msg))
+		}
+	}
+
+	job := spec.Job(filepath.Dir(path))
+	l.checkSchedule(c, &spec)
+	l.checkTarget(c, &spec)
+	l.checkScript(c, &spec, filepath.Dir(path))
+	l.checkEnvironment(c, &spec)
+	l.checkLimits(c, &spec, job)
+	l.checkTypes(c, job)
+	return c.diags
+}
+
+// checkSchedule checks the cron schedule and, in a job time zone, warns of
+// runs daylight saving time moves in the coming year
+func (l *Linter) checkSchedule(c *checker, spec *Spec) {
+	if spec.Schedule == "" {
+		return
+	}
+	s, err := schedule.Parse(spec.Schedule)
+	if err != nil {
+		c.errorf("schedule", "cron", "invalid cron expression: %v", err)
+		return
+	}
+	if spec.Locale == nil || spec.Locale.TZ == "" {
+		return
+	}
+	loc, err := time.LoadLocation(spec.Locale.TZ)
+	if err != nil {
+		c.errorf("locale.tz", "locale", "unknown time zone %q", spec.Locale.TZ)
+		return
+	}
+	now := time.Now()
+	for _, run := range s.DSTRuns(now, now.AddDate(1, 0, 0), loc) {
+		c.warnf("schedule", "dst", "daylight saving time in %s on %s: %s", spec.Locale.TZ, run.Time.Format("2006-01-02"), run.Describe())
+	}
+}
+
+// checkTarget checks the job has a well-formed target
+func (l *Linter) checkTarget(c *checker, spec *Spec) {
+	switch spec.Target.Type {
+	case types.TargetTypeLocal:
+		if spec.Target.ServerID != "" {
+			c.warnf("target.serverId", "target", "serverId is ignored for local targets")
+		}
+	case types.TargetTypeServer:
+		if strings.TrimSpace(spec.Target.ServerID) == "" {
+			c.errorf("target", "target", "server targets require a serverId")
+		}
+	case "":
+		c.errorf("target", "target", "target.type is required (local or server)")
+	default:
+		c.errorf("target.type", "target", "unsupported target type %q (local or server)", spec.Target.Type)
+	}
+}
+
+// checkScript checks the script or HTTP request the job runs
+func (l *Linter) checkScript(c *checker, spec *Spec, baseDir string) {
+	if spec.HTTP != nil {
+		l.checkHTTP(c, spec.HTTP)
+	}
+	if spec.Script == nil {
+		if spec.HTTP == nil {
+			c.errorf("", "script", "script is required")
+		}
+		return
+	}
+
+	switch spec.Script.Type {
+	case types.ScriptTypeBash, types.ScriptTypePython, types.ScriptTypeNode:
+	default:
+		message := fmt.Sprintf("unsupported script type %q (BASH, PYTHON or NODEJS)", spec.Script.Type)
+		if upper := types.ScriptType(strings.ToUpper(string(spec.Script.Type))); upper != spec.Script.Type {
+			switch upper {
+			case types.ScriptTypeBash, types.ScriptTypePython, types.ScriptTypeNode:
+				message = fmt.Sprintf("script type must be upper case: %s", upper)
+			}
+		}
+		c.errorf("script.type", "script-type", "%s", message)
+	}
+
+	switch {
+	case spec.Script.Content != "" && spec.Script.File != "":
+		c.errorf("script.file", "script", "set either script.content or script.file, not both")
+	case spec.Script.File != "":
+		info, err := os.Stat(filepath.Join(baseDir, spec.Script.File))
+		switch {
+		case err != nil:
+			c.errorf("script.file", "script", "script file is not readable: %v", err)
+		case info.Size() == 0:
+			c.errorf("script.file", "script", "script file is empty")
+		}
+	case strings.TrimSpace(spec.Script.Content) == "":
+		c.errorf("script", "script", "script content is empty")
+	}
+}
+
+// checkHTTP checks an HTTP request job
+func (l *Linter) checkHTTP(c *checker, h *HTTPSpec) {
+	switch strings.ToUpper(h.Method) {
+	case "", "GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS":
+	default:
+		c.errorf("http.method", "http", "unsupported HTTP method %q", h.Method)
+	}
+	// Templated URLs are only known at run time
+	if !strings.Contains(h.URL, "{{") {
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.errorf("http.url", "http", "http.url must be an absolute http or https URL")
+		}
+	}
+	switch {
+	case h.Retries < 0 || h.RetryDelay < 0:
+		c.errorf("http.retries", "http", "http.retries and retryDelay must not be negative")
+	case h.Retries > l.config.HTTP.MaxRetries:
+		c.warnf("http.retries", "http", "http.retries is capped at %d by the orchestrator", l.config.HTTP.MaxRetries)
+	}
+}
+
+// checkEnvironment checks environment variable names
+func (l *Linter) checkEnvironment(c *checker, spec *Spec) {
+	names := make([]string, 0, len(spec.Environment))
+	for name := range spec.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := "environment." + name
+		switch {
+		case !envNamePattern.MatchString(name):
+			c.errorf(field, "env-name", "invalid environment variable name %q", name)
+		case strings.HasPrefix(strings.ToUpper(name), "CRONIUM_"):
+			c.warnf(field, "env-name", "%s may be overwritten: CRONIUM_ variables are set by the orchestrator", name)
+		}
+	}
+}
+
+// checkLimits checks durations and resources against the configured limits
+func (l *Linter) checkLimits(c *checker, spec *Spec, job *types.Job) {
+	if spec.Timeout < 0 {
+		c.errorf("timeout", "timeout", "timeout must not be negative")
+	}
+
+	if p := spec.RetryPolicy; p != nil {
+		if p.MaxAttempts < 0 {
+			c.errorf("retryPolicy.maxAttempts", "retry", "maxAttempts must not be negative")
+		}
+		switch p.BackoffType {
+		case "", "fixed", "linear", "exponential":
+		default:
+			c.warnf("retryPolicy.backoffType", "retry", "unknown backoff type %q (fixed, linear or exponential)", p.BackoffType)
+		}
+		if p.BackoffDelay < 0 {
+			c.errorf("retryPolicy.backoffDelay", "retry", "backoffDelay must not be negative")
+		}
+	}
+
+	if r := spec.Resources; r != nil {
+		if spec.Target.Type == types.TargetTypeServer {
+			c.warnf("resources", "resources", "resources only apply to local targets and are ignored on servers")
+		}
+		if r.CPULimit < 0 {
+			c.errorf("resources.cpuLimit", "resources", "cpuLimit must not be negative")
+		}
+		if r.PidsLimit < 0 {
+			c.errorf("resources.pidsLimit", "resources", "pidsLimit must not be negative")
+		}
+		if _, err := parseSize(r.MemoryLimit); err != nil {
+			c.errorf("resources.memoryLimit", "resources", "%v", err)
+		}
+		if _, err := parseSize(r.DiskLimit); err != nil {
+			c.errorf("resources.diskLimit", "resources", "%v", err)
+		}
+
+		if spec.Target.Type == types.TargetTypeLocal {
+			l.checkResourceCeilings(c, job)
+		}
+	}
+
+	gates := l.config.Jobs.Gates
+	for i, g := range spec.Gates {
+		field := fmt.Sprintf("gates[%d]", i)
+		if gates.MaxTimeout > 0 && g.Timeout > gates.MaxTimeout {
+			c.warnf(field+".timeout", "gate", "timeout %v exceeds the maximum %v and will be shortened", g.Timeout, gates.MaxTimeout)
+		}
+		if g.Interval > 0 && g.Interval < gates.MinInterval {
+			c.warnf(field+".interval", "gate", "interval %v is below the minimum %v and will be raised", g.Interval, gates.MinInterval)
+		}
+	}
+	if a := spec.Approval; a != nil && gates.MaxApprovalExpiry > 0 && a.Expiry > gates.MaxApprovalExpiry {
+		c.warnf("approval.expiry", "approval", "expiry %v exceeds the maximum %v and will be shortened", a.Expiry, gates.MaxApprovalExpiry)
+	}
+
+	if m := job.Execution.Matrix; m != nil && l.config.Jobs.Matrix.MaxCombinations > 0 && m.Size() > l.config.Jobs.Matrix.MaxCombinations {
+		c.errorf("matrix", "matrix", "matrix expands to %d combinations, more than the limit of %d", m.Size(), l.config.Jobs.Matrix.MaxCombinations)
+	}
+}
+
+// checkResourceCeilings checks a container job's resources against its
+// sandbox profile, which would otherwise silently clamp them
+func (l *Linter) checkResourceCeilings(c *checker, job *types.Job) {
+	profile, err := l.catalog.Resolve(job)
+	if err != nil {
+		return // reported by checkTypes
+	}
+	r := job.Execution.Resources
+
+	if profile.MaxCPU > 0 && r.CPULimit > profile.MaxCPU {
+		c.errorf("resources.cpuLimit", "resources", "cpuLimit %v exceeds the %s profile limit of %v", r.CPULimit, profile.Name, profile.MaxCPU)
+	}
+	if maxMemory, err := parseSize(profile.MaxMemory); err == nil && maxMemory > 0 && r.MemoryLimit > maxMemory {
+		c.errorf("resources.memoryLimit", "resources", "memoryLimit exceeds the %s profile limit of %s", profile.Name, profile.MaxMemory)
+	}
+	if maxDisk, err := parseSize(l.config.Container.Resources.Limits.Disk); err == nil && maxDisk > 0 && r.DiskLimit > maxDisk {
+		c.errorf("resources.diskLimit", "resources", "diskLimit exceeds the limit of %s", l.config.Container.Resources.Limits.Disk)
+	}
+	if profile.MaxPids > 0 && r.PidsLimit > profile.MaxPids {
+		c.errorf("resources.pidsLimit", "resources", "pidsLimit %d exceeds the %s profile limit of %d", r.PidsLimit, profile.Name, profile.MaxPids)
+	}
+}
+
+// checkTypes runs the validation the job types and executors apply at run
+// time
+func (l *Linter) checkTypes(c *checker, job *types.Job) {
+	exec := &job.Execution
+
+	if job.Type == types.JobTypeContainer {
+		if _, err := l.catalog.Resolve(job); err != nil {
+			c.validationError(err, "sandbox")
+		}
+		if err := container.ValidateStopSettings(job); err != nil {
+			c.validationError(err, "stop")
+		}
+	}
+
+	if len(exec.Parameters) > 0 || len(exec.ParameterValues) > 0 {
+		if _, err := types.ResolveParameters(exec.Parameters, exec.ParameterValues); err != nil {
+			var execErr *types.ExecutionError
+			problems, ok := []types.ParameterError(nil), false
+			if errors.As(err, &execErr) {
+				problems, ok = execErr.Details["parameters"].([]types.ParameterError)
+			}
+			if !ok {
+				c.errorf("parameters", "parameters", "%v", err)
+			}
+			for _, p := range problems {
+				c.errorf("parameters", "parameters", "%s: %s", p.Parameter, p.Message)
+			}
+		}
+	}
+	switch exec.ConcurrencyPolicy {
+	case "", types.ConcurrencyAllow, types.ConcurrencyForbid, types.ConcurrencyReplace:
+	default:
+		c.errorf("concurrencyPolicy", "concurrency", "concurrency policy %q must be allow, forbid or replace", exec.ConcurrencyPolicy)
+	}
+	switch exec.ImagePullPolicy {
+	case "", types.PullAlways, types.PullIfNotPresent, types.PullNever:
+	default:
+		c.errorf("imagePullPolicy", "image", "image pull policy %q must be Always, IfNotPresent or Never", exec.ImagePullPolicy)
+	}
+	if exec.Matrix != nil {
+		if err := exec.Matrix.Validate(); err != nil {
+			c.errorf("matrix", "matrix", "%v", err)
+		}
+	}
+	for i, g := range exec.Gates {
+		if err := g.Validate(); err != nil {
+			c.errorf(fmt.Sprintf("gates[%d]", i), "gate", "%v", err)
+		}
+	}
+	if exec.Approval != nil {
+		if err := exec.Approval.Validate(); err != nil {
+			c.errorf("approval", "approval", "%v", err)
+		}
+	}
+	if exec.Analysis != nil {
+		switch exec.Analysis.Mode {
+		case "", types.AnalysisModeOff, types.AnalysisModeWarn, types.AnalysisModeBlock:
+		default:
+			c.errorf("analysis.mode", "analysis", "unsupported analysis mode %q (off, warn or block)", exec.Analysis.Mode)
+		}
+	}
+}
+
+// checker collects the diagnostics of one spec
+type checker struct {
+	file  string
+	root  yaml.Node
+	diags []Diagnostic
+}
+
+func (c *checker) errorf(field, code, format string, args ...any) {
+	c.add(SeverityError, field, code, fmt.Sprintf(format, args...))
+}
+
+func (c *checker) warnf(field, code, format string, args ...any) {
+	c.add(SeverityWarning, field, code, fmt.Sprintf(format, args...))
+}
+
+// add records a diagnostic at the position of field, or of the nearest
+// enclosing field present in the file
+func (c *checker) add(severity, field, code, message string) {
+	d := Diagnostic{File: c.file, Severity: severity, Code: code, Field: field, Message: message}
+	if node := c.locate(field); node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	c.diags = append(c.diags, d)
+}
+
+// parseError records a YAML syntax or type error
+func (c *checker) parseError(err error) {
+	d := Diagnostic{File: c.file, Severity: SeverityError, Code: "parse", Message: err.Error()}
+	if m := unknownFieldLine.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Code = "unknown-field"
+		d.Message = fmt.Sprintf("unknown field %q", m[2])
+	} else if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		d.Line, _ = strconv.Atoi(m[1])
+		d.Message = m[2]
+	}
+	c.diags = append(c.diags, d)
+}
+
+// validationError records an executor validation error
+func (c *checker) validationError(err error, code string) {
+	var ve *pkgerrors.ValidationError
+	if errors.As(err, &ve) {
+		c.errorf(ve.Field, code, "%s", ve.Message)
+		return
+	}
+	c.errorf("", code, "%v", err)
+}
+
+// locate finds the node of a dotted field path such as "gates[1].url",
+// stopping at the deepest part that exists
+func (c *checker) locate(field string) *yaml.Node {
+	if len(c.root.Content) == 0 {
+		return nil
+	}
+	node := c.root.Content[0]
+	if field == "" {
+		return node
+	}
+
+	for _, part := range strings.Split(field, ".") {
+		key, index := part, -1
+		if open := strings.Index(part, "["); open >= 0 && strings.HasSuffix(part, "]") {
+			key = part[:open]
+			index, _ = strconv.Atoi(part[open+1 : len(part)-1])
+		}
+
+		next := mappingValue(node, key)
+		if next == nil {
+			return node
+		}
+		node = next
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return node
+			}
+			node = node.Content[index]
+		}
+	}
+	return node
+}
+
+// mappingValue returns the value of key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
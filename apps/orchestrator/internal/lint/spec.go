@@ -0,0 +1,298 @@
+package lint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Spec is a job or event specification as authors write it. It mirrors the
+// job's execution settings with durations such as "30s" and sizes such as
+// "512MB", plus the event's name and cron schedule.
+type Spec struct {
+	Name                   string            `yaml:"name"`
+	Schedule               string            `yaml:"schedule"`
+	Target                 TargetSpec        `yaml:"target"`
+	Script                 *ScriptSpec       `yaml:"script"`
+	HTTP                   *HTTPSpec         `yaml:"http"`
+	Environment            map[string]string `yaml:"environment"`
+	Timeout                time.Duration     `yaml:"timeout"`
+	Resources              *ResourcesSpec    `yaml:"resources"`
+	RetryPolicy            *RetryPolicySpec  `yaml:"retryPolicy"`
+	StopSignal             string            `yaml:"stopSignal"`
+	TerminationGracePeriod time.Duration     `yaml:"terminationGracePeriod"`
+	Parameters             []ParameterSpec   `yaml:"parameters"`
+	ParameterValues        map[string]any    `yaml:"parameterValues"`
+	Matrix                 *MatrixSpec       `yaml:"matrix"`
+	Gates                  []GateSpec        `yaml:"gates"`
+	Approval               *ApprovalSpec     `yaml:"approval"`
+	Analysis               *AnalysisSpec     `yaml:"analysis"`
+	SandboxProfile         string            `yaml:"sandboxProfile"`
+	SnapshotOnFailure      *bool             `yaml:"snapshotOnFailure"`
+	Locale                 *LocaleSpec       `yaml:"locale"`
+	ConcurrencyPolicy      string            `yaml:"concurrencyPolicy"`
+	ImagePullPolicy        string            `yaml:"imagePullPolicy"`
+}
+
+// TargetSpec selects where the job runs
+type TargetSpec struct {
+	Type     types.TargetType `yaml:"type"`
+	ServerID string           `yaml:"serverId"`
+}
+
+// ScriptSpec is the script, given inline or as a file next to the spec
+type ScriptSpec struct {
+	Type             types.ScriptType `yaml:"type"`
+	Content          string           `yaml:"content"`
+	File             string           `yaml:"file"`
+	WorkingDirectory string           `yaml:"workingDirectory"`
+}
+
+// HTTPSpec is an HTTP request job
+type HTTPSpec struct {
+	Method       string            `yaml:"method"`
+	URL          string            `yaml:"url"`
+	Headers      map[string]string `yaml:"headers"`
+	Body         any               `yaml:"body"`
+	ExpectStatus []int             `yaml:"expectStatus"`
+	Retries      int               `yaml:"retries"`
+	RetryDelay   time.Duration     `yaml:"retryDelay"`
+}
+
+// ResourcesSpec are the job's resource limits
+type ResourcesSpec struct {
+	CPULimit    float64 `yaml:"cpuLimit"`
+	MemoryLimit string  `yaml:"memoryLimit"`
+	DiskLimit   string  `yaml:"diskLimit"`
+	PidsLimit   int64   `yaml:"pidsLimit"`
+}
+
+// RetryPolicySpec is the job's retry behaviour
+type RetryPolicySpec struct {
+	MaxAttempts  int           `yaml:"maxAttempts"`
+	BackoffType  string        `yaml:"backoffType"`
+	BackoffDelay time.Duration `yaml:"backoffDelay"`
+}
+
+// ParameterSpec declares a job parameter
+type ParameterSpec struct {
+	Name        string              `yaml:"name"`
+	Type        types.ParameterType `yaml:"type"`
+	Description string              `yaml:"description"`
+	Required    bool                `yaml:"required"`
+	Default     any                 `yaml:"default"`
+	Options     []string            `yaml:"options"`
+	Pattern     string              `yaml:"pattern"`
+	MinLength   *int                `yaml:"minLength"`
+	MaxLength   *int                `yaml:"maxLength"`
+	Min         *int64              `yaml:"min"`
+	Max         *int64              `yaml:"max"`
+}
+
+// MatrixSpec expands the job over parameter axes
+type MatrixSpec struct {
+	Axes        []types.MatrixAxis `yaml:"axes"`
+	Exclude     []map[string]any   `yaml:"exclude"`
+	MaxParallel int                `yaml:"maxParallel"`
+	FailFast    bool               `yaml:"failFast"`
+}
+
+// GateSpec is a pre-execution gate
+type GateSpec struct {
+	Type         types.GateType `yaml:"type"`
+	Name         string         `yaml:"name"`
+	URL          string         `yaml:"url"`
+	ExpectStatus int            `yaml:"expectStatus"`
+	Variable     string         `yaml:"variable"`
+	Equals       any            `yaml:"equals"`
+	Path         string         `yaml:"path"`
+	Interval     time.Duration  `yaml:"interval"`
+	Timeout      time.Duration  `yaml:"timeout"`
+}
+
+// ApprovalSpec requires a human decision before the job runs
+type ApprovalSpec struct {
+	Message   string        `yaml:"message"`
+	Approvers []string      `yaml:"approvers"`
+	Expiry    time.Duration `yaml:"expiry"`
+}
+
+// LocaleSpec overrides the clock and locale environment
+type LocaleSpec struct {
+	TZ       string `yaml:"tz"`
+	Lang     string `yaml:"lang"`
+	LCAll    string `yaml:"lcAll"`
+	FakeTime string `yaml:"fakeTime"`
+}
+
+// AnalysisSpec overrides the static analysis mode
+type AnalysisSpec struct {
+	Mode string `yaml:"mode"`
+}
+
+// Job converts the spec into the job the orchestrator would receive. Sizes
+// that do not parse are left unset; the linter reports them separately.
+func (s *Spec) Job(baseDir string) *types.Job {
+	job := &types.Job{
+		ID:   "lint",
+		Type: types.JobTypeSSH,
+		Execution: types.ExecutionConfig{
+			Target:                 types.Target{Type: s.Target.Type},
+			Environment:            s.Environment,
+			Timeout:                s.Timeout,
+			StopSignal:             s.StopSignal,
+			TerminationGracePeriod: s.TerminationGracePeriod,
+			ParameterValues:        s.ParameterValues,
+			SandboxProfile:         s.SandboxProfile,
+			SnapshotOnFailure:      s.SnapshotOnFailure,
+			ConcurrencyPolicy:      types.ConcurrencyPolicy(s.ConcurrencyPolicy),
+			ImagePullPolicy:        types.ImagePullPolicy(s.ImagePullPolicy),
+		},
+	}
+	switch {
+	case s.HTTP != nil && s.Script == nil:
+		job.Type = types.JobTypeHTTP
+	case s.Target.Type == types.TargetTypeLocal:
+		job.Type = types.JobTypeContainer
+	}
+	if s.Target.ServerID != "" {
+		serverID := s.Target.ServerID
+		job.Execution.Target.ServerID = &serverID
+	}
+
+	if s.Script != nil {
+		content := s.Script.Content
+		if s.Script.File != "" {
+			if data, err := os.ReadFile(filepath.Join(baseDir, s.Script.File)); err == nil {
+				content = string(data)
+			}
+		}
+		job.Execution.Script = &types.Script{
+			Type:             s.Script.Type,
+			Content:          content,
+			WorkingDirectory: s.Script.WorkingDirectory,
+		}
+	}
+	if s.Locale != nil {
+		job.Execution.Locale = &types.Locale{
+			TZ:       s.Locale.TZ,
+			Lang:     s.Locale.Lang,
+			LCAll:    s.Locale.LCAll,
+			FakeTime: s.Locale.FakeTime,
+		}
+	}
+	if s.HTTP != nil {
+		job.Execution.HTTP = &types.HTTPConfig{
+			Method:       s.HTTP.Method,
+			URL:          s.HTTP.URL,
+			Headers:      s.HTTP.Headers,
+			Body:         s.HTTP.Body,
+			ExpectStatus: s.HTTP.ExpectStatus,
+			Retries:      s.HTTP.Retries,
+			RetryDelay:   s.HTTP.RetryDelay,
+		}
+	}
+	if s.Resources != nil {
+		memory, _ := parseSize(s.Resources.MemoryLimit)
+		disk, _ := parseSize(s.Resources.DiskLimit)
+		job.Execution.Resources = &types.Resources{
+			CPULimit:    s.Resources.CPULimit,
+			MemoryLimit: memory,
+			DiskLimit:   disk,
+			PidsLimit:   s.Resources.PidsLimit,
+		}
+	}
+	if s.RetryPolicy != nil {
+		job.Execution.RetryPolicy = &types.RetryPolicy{
+			MaxAttempts:  s.RetryPolicy.MaxAttempts,
+			BackoffType:  s.RetryPolicy.BackoffType,
+			BackoffDelay: s.RetryPolicy.BackoffDelay,
+		}
+	}
+	for _, p := range s.Parameters {
+		job.Execution.Parameters = append(job.Execution.Parameters, types.Parameter{
+			Name:        p.Name,
+			Type:        p.Type,
+			Description: p.Description,
+			Required:    p.Required,
+			Default:     p.Default,
+			Options:     p.Options,
+			Pattern:     p.Pattern,
+			MinLength:   p.MinLength,
+			MaxLength:   p.MaxLength,
+			Min:         p.Min,
+			Max:         p.Max,
+		})
+	}
+	if s.Matrix != nil {
+		job.Execution.Matrix = &types.Matrix{
+			Axes:        s.Matrix.Axes,
+			Exclude:     s.Matrix.Exclude,
+			MaxParallel: s.Matrix.MaxParallel,
+			FailFast:    s.Matrix.FailFast,
+		}
+	}
+	for _, g := range s.Gates {
+		job.Execution.Gates = append(job.Execution.Gates, types.Gate{
+			Type:         g.Type,
+			Name:         g.Name,
+			URL:          g.URL,
+			ExpectStatus: g.ExpectStatus,
+			Variable:     g.Variable,
+			Equals:       g.Equals,
+			Path:         g.Path,
+			Interval:     g.Interval,
+			Timeout:      g.Timeout,
+		})
+	}
+	if s.Approval != nil {
+		job.Execution.Approval = &types.Approval{
+			Message:   s.Approval.Message,
+			Approvers: s.Approval.Approvers,
+			Expiry:    s.Approval.Expiry,
+		}
+	}
+	if s.Analysis != nil {
+		job.Execution.Analysis = &types.AnalysisPolicy{Mode: s.Analysis.Mode}
+	}
+	return job
+}
+
+// parseSize parses sizes such as "512MB", "1GB" or a plain byte count. An
+// empty size is zero.
+func parseSize(size string) (int64, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"GB", 1024 * 1024 * 1024},
+		{"MB", 1024 * 1024},
+		{"KB", 1024},
+		{"B", 1},
+	}
+	for _, unit := range units {
+		if value, ok := strings.CutSuffix(size, unit.suffix); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid size %q", size)
+			}
+			return int64(n * float64(unit.multiplier)), nil
+		}
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return n, nil
+}
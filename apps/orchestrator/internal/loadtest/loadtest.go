@@ -0,0 +1,360 @@
+package loadtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/accounting"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Phase names, in the order they are reported
+const (
+	PhaseSetup   = "setup"   // Execute until the script printed its start marker
+	PhaseRun     = "run"     // start marker until the job completed
+	PhaseCleanup = "cleanup" // executor cleanup
+	PhaseTotal   = "total"
+)
+
+// startMarker is the first line a synthetic job prints, telling setup from
+// the script's own run time
+const startMarker = "CRONIUM_LOADTEST start"
+
+// maxErrors bounds the distinct failure messages kept in a report
+const maxErrors = 10
+
+// Options controls a load test
+type Options struct {
+	// Jobs is the number of synthetic jobs to run
+	Jobs int
+	// Concurrency is how many jobs run at once
+	Concurrency int
+	// Duration is how long each job's script sleeps; zero runs a no-op
+	Duration time.Duration
+	// Timeout bounds each job
+	Timeout time.Duration
+	// SampleInterval is how often running jobs are sampled for their
+	// resource usage; zero only uses what executors report at the end
+	SampleInterval time.Duration
+}
+
+// PhaseStats summarises the latency of one phase over the jobs that
+// reached it
+type PhaseStats struct {
+	Name  string        `json:"name"`
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P95   time.Duration `json:"p95"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// UsageStats sums the resource usage of the jobs it was measured for
+type UsageStats struct {
+	Measured   int     `json:"measured"`
+	CPUSeconds float64 `json:"cpuSeconds"`
+	PeakMemory int64   `json:"peakMemory"` // largest of any job, bytes
+	Network    int64   `json:"network"`    // bytes
+	Disk       int64   `json:"disk"`       // bytes
+}
+
+// ErrorCount is a failure message and how many jobs failed with it
+type ErrorCount struct {
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// Report is the result of a load test
+type Report struct {
+	Target      string        `json:"target"`
+	Jobs        int           `json:"jobs"`
+	Concurrency int           `json:"concurrency"`
+	JobDuration time.Duration `json:"jobDuration"`
+	StartedAt   time.Time     `json:"startedAt"`
+	Duration    time.Duration `json:"duration"`
+	Succeeded   int           `json:"succeeded"`
+	Failed      int           `json:"failed"`
+	// Throughput is completed jobs per second of wall time
+	Throughput   float64      `json:"throughput"`
+	Phases       []PhaseStats `json:"phases"`
+	Usage        UsageStats   `json:"usage"`
+	Orchestrator ProcessUsage `json:"orchestrator"`
+	Errors       []ErrorCount `json:"errors,omitempty"`
+}
+
+// JobFactory builds the i-th synthetic job of a run
+type JobFactory func(i int) *types.Job
+
+// NewJobFactory returns a factory of jobs of the given type and target whose
+// script prints the start marker and sleeps for duration
+func NewJobFactory(jobType types.JobType, target types.Target, duration time.Duration) (JobFactory, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate load test ID: %w", err)
+	}
+	runID := hex.EncodeToString(b)
+	script := Script(duration)
+
+	return func(i int) *types.Job {
+		return &types.Job{
+			ID:        fmt.Sprintf("loadtest-%s-%d", runID, i),
+			Type:      jobType,
+			CreatedAt: time.Now(),
+			Execution: types.ExecutionConfig{
+				Target: target,
+				Script: &types.Script{
+					Type:    types.ScriptTypeBash,
+					Content: script,
+				},
+				Environment: map[string]string{},
+			},
+			Metadata: map[string]any{
+				"loadtest": true,
+			},
+		}
+	}, nil
+}
+
+// Script returns the bash script of a synthetic job
+func Script(duration time.Duration) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n")
+	fmt.Fprintf(&b, "echo %q\n", startMarker)
+	if duration > 0 {
+		fmt.Fprintf(&b, "sleep %s\n", strconv.FormatFloat(duration.Seconds(), 'f', -1, 64))
+	}
+	b.WriteString("exit 0\n")
+	return b.String()
+}
+
+// jobResult is what one synthetic job measured
+type jobResult struct {
+	err     error
+	started bool
+	setup   time.Duration
+	run     time.Duration
+	cleanup time.Duration
+	total   time.Duration
+	usage   *accounting.Usage
+}
+
+// Run executes opts.Jobs synthetic jobs through executor, opts.Concurrency at
+// a time, and reports throughput, phase latencies and resource usage
+func Run(ctx context.Context, executor executors.Executor, newJob JobFactory, target string, opts Options, log *logrus.Logger) *Report {
+	report := &Report{
+		Target:      target,
+		Jobs:        opts.Jobs,
+		Concurrency: opts.Concurrency,
+		JobDuration: opts.Duration,
+		StartedAt:   time.Now(),
+	}
+	process := startProcessUsage()
+
+	indexes := make(chan int)
+	results := make(chan jobResult)
+	var wg sync.WaitGroup
+	for w := 0; w < max(opts.Concurrency, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results <- runJob(ctx, executor, newJob(i), opts, log)
+			}
+		}()
+	}
+	go func() {
+		defer close(indexes)
+		for i := 0; i < opts.Jobs; i++ {
+			select {
+			case indexes <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var collected []jobResult
+	for result := range results {
+		collected = append(collected, result)
+		if len(collected)%max(opts.Jobs/10, 1) == 0 {
+			log.WithField("completed", len(collected)).Info("Load test progress")
+		}
+	}
+
+	report.Duration = time.Since(report.StartedAt)
+	report.Orchestrator = process.stop()
+	report.summarize(collected)
+	return report
+}
+
+// runJob runs one job to completion and cleans it up
+func runJob(ctx context.Context, executor executors.Executor, job *types.Job, opts Options, log *logrus.Logger) jobResult {
+	var result jobResult
+	start := time.Now()
+	defer func() {
+		// Cleanup runs even when execution failed part way
+		cleanupStart := time.Now()
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := executor.Cleanup(cleanupCtx, job); err != nil {
+			log.WithError(err).WithField("jobID", job.ID).Warn("Failed to clean up load test job")
+		}
+		result.cleanup = time.Since(cleanupStart)
+		result.total = time.Since(start)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	if err := executor.Validate(job); err != nil {
+		result.err = err
+		return result
+	}
+	updates, err := executor.Execute(ctx, job)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	var meter *accounting.Meter
+	if sampler, ok := executor.(executors.StatsSampler); ok && opts.SampleInterval > 0 {
+		meter = accounting.StartMeter(ctx, job, sampler.SampleStats, opts.SampleInterval)
+	}
+
+	var markerAt time.Time
+	var final *types.ResourceUsage
+	completed := false
+	for update := range updates {
+		status, _ := update.Data.(*types.StatusUpdate)
+		switch update.Type {
+		case types.UpdateTypeLog:
+			entry, ok := update.Data.(*types.LogEntry)
+			if ok && markerAt.IsZero() && entry.Stream == "stdout" && strings.TrimSpace(entry.Line) == startMarker {
+				markerAt = time.Now()
+			}
+		case types.UpdateTypeError:
+			if status != nil && status.Status == types.JobStatusFailed && result.err == nil {
+				result.err = errors.New(status.Message)
+			}
+		case types.UpdateTypeComplete:
+			if status == nil {
+				continue
+			}
+			completed = true
+			final = status.Usage
+			exitCode := 0
+			if status.ExitCode != nil {
+				exitCode = *status.ExitCode
+			}
+			if result.err == nil && (status.Status != types.JobStatusCompleted || exitCode != 0) {
+				result.err = fmt.Errorf("job ended %s with exit code %d: %s", status.Status, exitCode, status.Message)
+			}
+		}
+	}
+	end := time.Now()
+
+	if result.err == nil && !completed {
+		if ctx.Err() != nil {
+			result.err = fmt.Errorf("job did not complete: %w", ctx.Err())
+		} else {
+			result.err = errors.New("job did not complete")
+		}
+	}
+	if !markerAt.IsZero() {
+		result.started = true
+		result.setup = markerAt.Sub(start)
+		result.run = end.Sub(markerAt)
+	}
+	result.usage = accounting.Combine(meter.Stop(), final, end.Sub(start))
+	return result
+}
+
+// summarize fills in the counts, latencies, usage and errors of the results
+func (r *Report) summarize(results []jobResult) {
+	phases := map[string][]time.Duration{}
+	errorCounts := map[string]int{}
+	for _, res := range results {
+		if res.err != nil {
+			r.Failed++
+			errorCounts[res.err.Error()]++
+		} else {
+			r.Succeeded++
+		}
+		if res.started {
+			phases[PhaseSetup] = append(phases[PhaseSetup], res.setup)
+			phases[PhaseRun] = append(phases[PhaseRun], res.run)
+		}
+		phases[PhaseCleanup] = append(phases[PhaseCleanup], res.cleanup)
+		phases[PhaseTotal] = append(phases[PhaseTotal], res.total)
+
+		if u := res.usage; u != nil {
+			r.Usage.Measured++
+			r.Usage.CPUSeconds += u.CPUSeconds
+			r.Usage.PeakMemory = max(r.Usage.PeakMemory, u.Peak.PeakMemory)
+			r.Usage.Network += u.Peak.NetworkRx + u.Peak.NetworkTx
+			r.Usage.Disk += u.Peak.DiskRead + u.Peak.DiskWrite
+		}
+	}
+
+	if r.Duration > 0 {
+		r.Throughput = float64(r.Succeeded) / r.Duration.Seconds()
+	}
+	for _, name := range []string{PhaseSetup, PhaseRun, PhaseCleanup, PhaseTotal} {
+		r.Phases = append(r.Phases, phaseStats(name, phases[name]))
+	}
+
+	for message, count := range errorCounts {
+		r.Errors = append(r.Errors, ErrorCount{Message: message, Count: count})
+	}
+	sort.Slice(r.Errors, func(i, j int) bool {
+		if r.Errors[i].Count != r.Errors[j].Count {
+			return r.Errors[i].Count > r.Errors[j].Count
+		}
+		return r.Errors[i].Message < r.Errors[j].Message
+	})
+	if len(r.Errors) > maxErrors {
+		r.Errors = r.Errors[:maxErrors]
+	}
+}
+
+// phaseStats summarises the latencies of a phase
+func phaseStats(name string, latencies []time.Duration) PhaseStats {
+	stats := PhaseStats{Name: name, Count: len(latencies)}
+	if len(latencies) == 0 {
+		return stats
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	stats.Mean = total / time.Duration(len(latencies))
+	stats.P50 = percentile(latencies, 0.50)
+	stats.P95 = percentile(latencies, 0.95)
+	stats.P99 = percentile(latencies, 0.99)
+	stats.Max = latencies[len(latencies)-1]
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted latencies by nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
@@ -0,0 +1,103 @@
+package loadtest
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExecutor prints the start marker and completes, failing the jobs
+// whose index ends in 0 when failing is set
+type fakeExecutor struct {
+	failing bool
+	running atomic.Int32
+	peak    atomic.Int32
+	cleaned atomic.Int32
+}
+
+func (f *fakeExecutor) Type() types.JobType           { return types.JobTypeContainer }
+func (f *fakeExecutor) Validate(job *types.Job) error { return nil }
+
+func (f *fakeExecutor) Cleanup(ctx context.Context, job *types.Job) error {
+	f.cleaned.Add(1)
+	return nil
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, job *types.Job) (<-chan types.ExecutionUpdate, error) {
+	updates := make(chan types.ExecutionUpdate, 4)
+	go func() {
+		defer close(updates)
+		n := f.running.Add(1)
+		defer f.running.Add(-1)
+		for peak := f.peak.Load(); n > peak && !f.peak.CompareAndSwap(peak, n); peak = f.peak.Load() {
+		}
+
+		time.Sleep(time.Millisecond)
+		updates <- types.ExecutionUpdate{Type: types.UpdateTypeLog, Data: &types.LogEntry{Stream: "stdout", Line: startMarker}}
+		time.Sleep(2 * time.Millisecond)
+
+		exitCode := 0
+		if f.failing && strings.HasSuffix(job.ID, "0") {
+			exitCode = 1
+		}
+		status := types.JobStatusCompleted
+		if exitCode != 0 {
+			status = types.JobStatusFailed
+		}
+		updates <- types.ExecutionUpdate{Type: types.UpdateTypeComplete, Data: &types.StatusUpdate{
+			Status:   status,
+			ExitCode: &exitCode,
+			Message:  "script exited",
+			Usage:    &types.ResourceUsage{CPUSeconds: 0.5, PeakMemory: 1 << 20, DiskWrite: 4096},
+		}}
+	}()
+	return updates, nil
+}
+
+func TestRun(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	newJob, err := NewJobFactory(types.JobTypeContainer, types.Target{Type: types.TargetTypeLocal}, 0)
+	require.NoError(t, err)
+
+	executor := &fakeExecutor{failing: true}
+	report := Run(context.Background(), executor, newJob, "fake", Options{Jobs: 20, Concurrency: 4, Timeout: time.Second}, log)
+
+	assert.Equal(t, 18, report.Succeeded)
+	assert.Equal(t, 2, report.Failed)
+	assert.LessOrEqual(t, executor.peak.Load(), int32(4))
+	assert.Equal(t, int32(20), executor.cleaned.Load())
+	assert.Greater(t, report.Throughput, 0.0)
+
+	require.Len(t, report.Phases, 4)
+	for _, phase := range report.Phases {
+		assert.Equal(t, 20, phase.Count, phase.Name)
+		assert.LessOrEqual(t, phase.P50, phase.P95, phase.Name)
+		assert.LessOrEqual(t, phase.P99, phase.Max, phase.Name)
+	}
+	assert.GreaterOrEqual(t, report.Phases[1].P50, 2*time.Millisecond)
+
+	assert.Equal(t, 20, report.Usage.Measured)
+	assert.InDelta(t, 10.0, report.Usage.CPUSeconds, 1e-9)
+	assert.Equal(t, int64(1<<20), report.Usage.PeakMemory)
+	assert.Equal(t, int64(20*4096), report.Usage.Disk)
+
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Count)
+	assert.Contains(t, report.Errors[0].Message, "exit code 1")
+}
+
+func TestScript(t *testing.T) {
+	assert.NotContains(t, Script(0), "sleep")
+	assert.Contains(t, Script(1500*time.Millisecond), "sleep 1.5\n")
+	assert.Contains(t, Script(0), startMarker)
+}
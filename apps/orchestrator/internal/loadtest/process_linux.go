@@ -0,0 +1,46 @@
+//go:build linux
+
+package loadtest
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// ProcessUsage is what the orchestrator process itself used during a load
+// test
+type ProcessUsage struct {
+	CPUSeconds float64 `json:"cpuSeconds"`
+	// MaxRSS is the largest resident set of the process so far, bytes
+	MaxRSS     int64 `json:"maxRss"`
+	Goroutines int   `json:"goroutines"` // when the test ended
+}
+
+type processUsage struct {
+	cpu float64
+}
+
+func startProcessUsage() *processUsage {
+	return &processUsage{cpu: cpuSeconds(rusage())}
+}
+
+// stop returns the usage since the test started
+func (p *processUsage) stop() ProcessUsage {
+	ru := rusage()
+	return ProcessUsage{
+		CPUSeconds: cpuSeconds(ru) - p.cpu,
+		MaxRSS:     ru.Maxrss * 1024, // KiB on Linux
+		Goroutines: runtime.NumGoroutine(),
+	}
+}
+
+func rusage() syscall.Rusage {
+	var ru syscall.Rusage
+	_ = syscall.Getrusage(syscall.RUSAGE_SELF, &ru)
+	return ru
+}
+
+func cpuSeconds(ru syscall.Rusage) float64 {
+	return time.Duration(syscall.TimevalToNsec(ru.Utime) + syscall.TimevalToNsec(ru.Stime)).Seconds()
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package loadtest
+
+import (
+	"runtime"
+)
+
+// ProcessUsage is what the orchestrator process itself used during a load
+// test. Only the goroutine count is known outside Linux.
+type ProcessUsage struct {
+	CPUSeconds float64 `json:"cpuSeconds"`
+	MaxRSS     int64   `json:"maxRss"`
+	Goroutines int     `json:"goroutines"`
+}
+
+type processUsage struct{}
+
+func startProcessUsage() *processUsage {
+	return &processUsage{}
+}
+
+func (p *processUsage) stop() ProcessUsage {
+	return ProcessUsage{Goroutines: runtime.NumGoroutine()}
+}
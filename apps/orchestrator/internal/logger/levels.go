@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ComponentField is the log field naming the component an entry comes from
+const ComponentField = "component"
+
+// Levels changes log levels at runtime, for the whole logger and for single
+// components. The logger runs at the most verbose level in use and entries
+// above their component's level are dropped when they are formatted.
+type Levels struct {
+	log        *logrus.Logger
+	mu         sync.RWMutex
+	global     logrus.Level
+	components map[string]logrus.Level
+}
+
+// LevelState is the current log level configuration
+type LevelState struct {
+	Global     string            `json:"global"`
+	Components map[string]string `json:"components"`
+}
+
+// NewLevels takes over the level of log, starting from its current level
+func NewLevels(log *logrus.Logger) *Levels {
+	l := &Levels{
+		log:        log,
+		global:     log.GetLevel(),
+		components: make(map[string]logrus.Level),
+	}
+	log.SetFormatter(&levelFormatter{Formatter: log.Formatter, levels: l})
+	return l
+}
+
+// SetGlobal sets the level of entries without a component level
+func (l *Levels) SetGlobal(level logrus.Level) {
+	l.mu.Lock()
+	l.global = level
+	l.mu.Unlock()
+	l.apply()
+}
+
+// SetComponent sets the level of one component's entries
+func (l *Levels) SetComponent(component string, level logrus.Level) {
+	l.mu.Lock()
+	l.components[component] = level
+	l.mu.Unlock()
+	l.apply()
+}
+
+// ResetComponent makes a component follow the global level again
+func (l *Levels) ResetComponent(component string) {
+	l.mu.Lock()
+	delete(l.components, component)
+	l.mu.Unlock()
+	l.apply()
+}
+
+// State returns the global level and the component overrides
+func (l *Levels) State() LevelState {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	state := LevelState{
+		Global:     l.global.String(),
+		Components: make(map[string]string, len(l.components)),
+	}
+	for component, level := range l.components {
+		state.Components[component] = level.String()
+	}
+	return state
+}
+
+// apply runs the logger at the most verbose level in use
+func (l *Levels) apply() {
+	l.mu.RLock()
+	level := l.global
+	for _, componentLevel := range l.components {
+		if componentLevel > level {
+			level = componentLevel
+		}
+	}
+	l.mu.RUnlock()
+	l.log.SetLevel(level)
+}
+
+// enabled reports whether an entry is written
+func (l *Levels) enabled(entry *logrus.Entry) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	level := l.global
+	if component, ok := entry.Data[ComponentField].(string); ok {
+		if componentLevel, ok := l.components[component]; ok {
+			level = componentLevel
+		}
+	}
+	return entry.Level <= level
+}
+
+// levelFormatter drops entries above their component's level; logrus writes
+// nothing for an empty result
+type levelFormatter struct {
+	logrus.Formatter
+	levels *Levels
+}
+
+// Format formats entries that are enabled
+func (f *levelFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if !f.levels.enabled(entry) {
+		return nil, nil
+	}
+	return f.Formatter.Format(entry)
+}
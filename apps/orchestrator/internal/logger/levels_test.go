@@ -0,0 +1,43 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelsFilterByComponent(t *testing.T) {
+	var out bytes.Buffer
+	log := logrus.New()
+	log.SetOutput(&out)
+	log.SetLevel(logrus.InfoLevel)
+	log.SetFormatter(&logrus.TextFormatter{DisableTimestamp: true})
+
+	levels := NewLevels(log)
+	levels.SetComponent("ssh-executor", logrus.DebugLevel)
+
+	log.Debug("global debug")
+	log.WithField(ComponentField, "ssh-executor").Debug("component debug")
+	log.WithField(ComponentField, "docker-reconnect").Debug("other debug")
+	log.Info("global info")
+
+	assert.NotContains(t, out.String(), "global debug")
+	assert.Contains(t, out.String(), "component debug")
+	assert.NotContains(t, out.String(), "other debug")
+	assert.Contains(t, out.String(), "global info")
+
+	out.Reset()
+	levels.ResetComponent("ssh-executor")
+	levels.SetGlobal(logrus.WarnLevel)
+	log.WithField(ComponentField, "ssh-executor").Debug("component debug")
+	log.Info("global info")
+	log.Warn("global warn")
+
+	assert.Equal(t, logrus.WarnLevel, log.GetLevel())
+	assert.NotContains(t, out.String(), "component debug")
+	assert.NotContains(t, out.String(), "global info")
+	assert.Contains(t, out.String(), "global warn")
+	assert.Equal(t, LevelState{Global: "warning", Components: map[string]string{}}, levels.State())
+}
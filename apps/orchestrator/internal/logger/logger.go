@@ -4,6 +4,7 @@ import (
 	"os"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
 	"github.com/sirupsen/logrus"
 )
 
@@ -46,6 +47,9 @@ func Configure(log *logrus.Logger, cfg config.LoggingConfig) {
 		})
 	}
 
+	// Never write registered secrets to the log
+	log.SetFormatter(redact.NewFormatter(log.Formatter))
+
 	// Set output
 	switch cfg.Output {
 	case "stdout":
@@ -0,0 +1,475 @@
+package logger
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// currentSegment is the file a job's logs are appended to. Rotated segments
+// are numbered in the order they were written: 000001.log, 000002.log.gz...
+const currentSegment = "current.log"
+
+// LogStore persists each job's log messages under its own directory as JSON
+// lines, rotating the file at the size limit and compressing rotated files.
+// Unlike the WAL it is meant for operators: logs outlive the job by the
+// retention period and can be read without the backend.
+type LogStore struct {
+	cfg config.JobLogStoreConfig
+	log *logrus.Logger
+
+	mu   sync.Mutex
+	jobs map[string]*storedJob // jobID -> open segment
+
+	// Compressions in flight
+	wg sync.WaitGroup
+}
+
+type storedJob struct {
+	dir  string
+	file *os.File
+	size int64
+	next int // number of the next rotated segment
+}
+
+// JobLogInfo describes the stored logs of a job
+type JobLogInfo struct {
+	JobID    string    `json:"jobId"`
+	Files    int       `json:"files"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Active   bool      `json:"active"`
+}
+
+// NewLogStore creates the store directory. It returns nil when the store is
+// disabled; a nil LogStore accepts writes and holds nothing.
+func NewLogStore(cfg config.JobLogStoreConfig, log *logrus.Logger) (*LogStore, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create job log directory: %w", err)
+	}
+	return &LogStore{
+		cfg:  cfg,
+		log:  log,
+		jobs: make(map[string]*storedJob),
+	}, nil
+}
+
+// Write appends a message to its job's current segment, rotating it first
+// when the message would take it past the size limit
+func (s *LogStore) Write(msg LogMessage) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[msg.JobID]
+	if !ok {
+		if job, err = s.open(msg.JobID); err != nil {
+			return err
+		}
+		s.jobs[msg.JobID] = job
+	}
+	if job.size > 0 && job.size+int64(len(data)) > s.cfg.MaxFileSize {
+		if err := s.rotate(job); err != nil {
+			delete(s.jobs, msg.JobID)
+			return err
+		}
+		if job.file, err = os.OpenFile(filepath.Join(job.dir, currentSegment), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640); err != nil {
+			delete(s.jobs, msg.JobID)
+			return err
+		}
+		job.size = 0
+	}
+
+	n, err := job.file.Write(data)
+	job.size += int64(n)
+	return err
+}
+
+// open opens a job's current segment, continuing after the segments a
+// previous run left behind
+func (s *LogStore) open(jobID string) (*storedJob, error) {
+	dir := s.jobDir(jobID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, currentSegment), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	job := &storedJob{dir: dir, file: file, size: info.Size(), next: 1}
+	if segments := segmentsIn(dir); len(segments) > 0 {
+		job.next = segments[len(segments)-1].number + 1
+	}
+	return job, nil
+}
+
+// rotate closes the job's current segment, renames it to the next number
+// and drops the oldest segments beyond the limit. Compression happens in
+// the background so writers are not held up.
+func (s *LogStore) rotate(job *storedJob) error {
+	if err := job.file.Close(); err != nil {
+		return err
+	}
+	job.file = nil
+
+	rotated := filepath.Join(job.dir, fmt.Sprintf("%06d.log", job.next))
+	if err := os.Rename(filepath.Join(job.dir, currentSegment), rotated); err != nil {
+		return err
+	}
+	job.next++
+	if s.cfg.Compress {
+		s.wg.Add(1)
+		go s.compress(rotated)
+	}
+
+	segments := segmentsIn(job.dir)
+	for len(segments) > s.cfg.MaxFiles {
+		for _, path := range segments[0].paths {
+			os.Remove(path)
+		}
+		segments = segments[1:]
+	}
+	return nil
+}
+
+// compress replaces a rotated segment with its gzipped copy
+func (s *LogStore) compress(path string) {
+	defer s.wg.Done()
+	if err := gzipFile(path); err != nil && !os.IsNotExist(err) {
+		s.log.WithError(err).WithField("file", path).Warn("Failed to compress job log")
+	}
+}
+
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// CloseJob closes a finished job's log. Its current segment is rotated so
+// it gets compressed with the rest; the files stay until pruned.
+func (s *LogStore) CloseJob(jobID string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[jobID]
+	if !ok {
+		return
+	}
+	delete(s.jobs, jobID)
+	if job.size == 0 {
+		job.file.Close()
+		os.Remove(filepath.Join(job.dir, currentSegment))
+		return
+	}
+	if err := s.rotate(job); err != nil {
+		s.log.WithError(err).WithField("jobID", jobID).Warn("Failed to rotate job log")
+	}
+}
+
+// Prune removes the logs of finished jobs not written to within the
+// retention period
+func (s *LogStore) Prune() {
+	if s == nil {
+		return
+	}
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		s.log.WithError(err).Warn("Failed to read job log directory")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	open := make(map[string]bool, len(s.jobs))
+	for _, job := range s.jobs {
+		open[job.dir] = true
+	}
+
+	cutoff := time.Now().Add(-s.cfg.Retention)
+	for _, entry := range entries {
+		dir := filepath.Join(s.cfg.Dir, entry.Name())
+		if !entry.IsDir() || open[dir] {
+			continue
+		}
+		if _, _, modified := dirUsage(dir); modified.After(cutoff) {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			s.log.WithError(err).WithField("dir", dir).Warn("Failed to prune job log")
+		}
+	}
+}
+
+// Close closes every open job log and waits for pending compressions
+func (s *LogStore) Close() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	for jobID, job := range s.jobs {
+		job.file.Close()
+		delete(s.jobs, jobID)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Jobs lists the jobs with stored logs, most recently written first
+func (s *LogStore) Jobs() ([]JobLogInfo, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	open := make(map[string]bool, len(s.jobs))
+	for _, job := range s.jobs {
+		open[job.dir] = true
+	}
+	s.mu.Unlock()
+
+	jobs := make([]JobLogInfo, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.cfg.Dir, entry.Name())
+		files, size, modified := dirUsage(dir)
+		jobs = append(jobs, JobLogInfo{
+			JobID:    entry.Name(),
+			Files:    files,
+			Size:     size,
+			Modified: modified,
+			Active:   open[dir],
+		})
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Modified.After(jobs[j].Modified) })
+	return jobs, nil
+}
+
+// Tail returns up to n of a job's most recent messages, oldest first,
+// optionally only those of one stream. ok is false when nothing is stored
+// for the job.
+func (s *LogStore) Tail(jobID string, n int, stream string) (msgs []LogMessage, ok bool, err error) {
+	if s == nil || n <= 0 {
+		return nil, false, nil
+	}
+
+	dir := s.jobDir(jobID)
+	if _, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	// Keep the last n messages in a ring while reading oldest to newest
+	ring := make([]LogMessage, 0, n)
+	start := 0
+	add := func(msg LogMessage) {
+		if stream != "" && msg.Stream != stream {
+			return
+		}
+		if len(ring) < n {
+			ring = append(ring, msg)
+			return
+		}
+		ring[start] = msg
+		start = (start + 1) % n
+	}
+
+	for _, segment := range segmentsIn(dir) {
+		if err := readSegment(segment.paths, add); err != nil {
+			return nil, true, err
+		}
+	}
+	if err := readSegment([]string{filepath.Join(dir, currentSegment)}, add); err != nil {
+		return nil, true, err
+	}
+	return append(ring[start:], ring[:start]...), true, nil
+}
+
+// readSegment reads the messages of the first of paths that still exists;
+// a segment may be compressed while it is being read
+func readSegment(paths []string, fn func(LogMessage)) error {
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		defer file.Close()
+
+		var r io.Reader = file
+		if strings.HasSuffix(path, ".gz") {
+			zr, err := gzip.NewReader(file)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			r = zr
+		}
+
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), maxWALLine)
+		for scanner.Scan() {
+			var msg LogMessage
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				// A torn final line from a crash or a write in progress
+				continue
+			}
+			fn(msg)
+		}
+		return scanner.Err()
+	}
+	return nil
+}
+
+// segment is a rotated file, compressed or not yet
+type segment struct {
+	number int
+	paths  []string // compressed copy first
+}
+
+// segmentsIn returns the rotated segments in a job directory, oldest first
+func segmentsIn(dir string) []segment {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	byNumber := make(map[int]*segment)
+	for _, entry := range entries {
+		name := entry.Name()
+		base, compressed := strings.CutSuffix(name, ".gz")
+		base, ok := strings.CutSuffix(base, ".log")
+		if !ok {
+			continue
+		}
+		number, err := strconv.Atoi(base)
+		if err != nil {
+			continue
+		}
+		seg, ok := byNumber[number]
+		if !ok {
+			seg = &segment{number: number}
+			byNumber[number] = seg
+		}
+		path := filepath.Join(dir, name)
+		if compressed {
+			seg.paths = append([]string{path}, seg.paths...)
+		} else {
+			seg.paths = append(seg.paths, path)
+		}
+	}
+
+	segments := make([]segment, 0, len(byNumber))
+	for _, seg := range byNumber {
+		segments = append(segments, *seg)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].number < segments[j].number })
+	return segments
+}
+
+// dirUsage returns the number of files in a job directory, their total size
+// and the latest modification
+func dirUsage(dir string) (files int, size int64, modified time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, 0, time.Time{}
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files++
+		size += info.Size()
+		if info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return files, size, modified
+}
+
+// jobDir returns the directory of a job's logs, keeping job IDs from
+// escaping the store directory
+func (s *LogStore) jobDir(jobID string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, jobID)
+	return filepath.Join(s.cfg.Dir, safe)
+}
@@ -0,0 +1,140 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogStoreRotation(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	dir := t.TempDir()
+	store, err := NewLogStore(config.JobLogStoreConfig{
+		Enabled:     true,
+		Dir:         dir,
+		MaxFileSize: 1024,
+		MaxFiles:    3,
+		Compress:    true,
+		Retention:   time.Hour,
+	}, log)
+	require.NoError(t, err)
+
+	line := strings.Repeat("x", 100)
+	for i := 1; i <= 60; i++ {
+		stream := "stdout"
+		if i%10 == 0 {
+			stream = "stderr"
+		}
+		require.NoError(t, store.Write(LogMessage{JobID: "job/1", Stream: stream, Line: line, Sequence: int64(i)}))
+	}
+
+	// Only the newest segments are kept, so the tail reaches back that far
+	msgs, found, err := store.Tail("job/1", 1000, "")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Less(t, len(msgs), 60)
+	assert.Equal(t, int64(60), msgs[len(msgs)-1].Sequence)
+	for i := 1; i < len(msgs); i++ {
+		assert.Equal(t, msgs[i-1].Sequence+1, msgs[i].Sequence)
+	}
+
+	msgs, _, err = store.Tail("job/1", 2, "stderr")
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, int64(50), msgs[0].Sequence)
+	assert.Equal(t, int64(60), msgs[1].Sequence)
+
+	// A finished job's log is rotated and compressed whole
+	store.CloseJob("job/1")
+	store.Close()
+	files, err := filepath.Glob(filepath.Join(dir, "job_1", "*"))
+	require.NoError(t, err)
+	assert.Len(t, files, 3)
+	for _, f := range files {
+		assert.True(t, strings.HasSuffix(f, ".log.gz"), f)
+	}
+	msgs, _, err = store.Tail("job/1", 5, "")
+	require.NoError(t, err)
+	require.Len(t, msgs, 5)
+	assert.Equal(t, int64(60), msgs[4].Sequence)
+
+	jobs, err := store.Jobs()
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, "job_1", jobs[0].JobID)
+	assert.False(t, jobs[0].Active)
+
+	_, found, err = store.Tail("job-2", 5, "")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestLogStorePrune(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	dir := t.TempDir()
+	store, err := NewLogStore(config.JobLogStoreConfig{
+		Enabled: true, Dir: dir, MaxFileSize: 1 << 20, MaxFiles: 1, Retention: time.Hour,
+	}, log)
+	require.NoError(t, err)
+
+	for _, jobID := range []string{"old", "recent", "running"} {
+		require.NoError(t, store.Write(LogMessage{JobID: jobID, Line: fmt.Sprintf("%s line", jobID)}))
+	}
+	store.CloseJob("old")
+	store.CloseJob("recent")
+
+	// Age everything; the running job is kept because its log is open
+	past := time.Now().Add(-2 * time.Hour)
+	for _, jobID := range []string{"old", "running"} {
+		files, _ := filepath.Glob(filepath.Join(dir, jobID, "*"))
+		for _, f := range files {
+			require.NoError(t, os.Chtimes(f, past, past))
+		}
+	}
+
+	store.Prune()
+	assert.NoDirExists(t, filepath.Join(dir, "old"))
+	assert.DirExists(t, filepath.Join(dir, "recent"))
+	assert.DirExists(t, filepath.Join(dir, "running"))
+
+	var disabled *LogStore
+	assert.NoError(t, disabled.Write(LogMessage{JobID: "x"}))
+}
+
+func TestLogStoreMasksSecrets(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	store, err := NewLogStore(config.JobLogStoreConfig{
+		Enabled:     true,
+		Dir:         t.TempDir(),
+		MaxFileSize: 1024,
+		MaxFiles:    1,
+		Retention:   time.Hour,
+	}, log)
+	require.NoError(t, err)
+	redact.Add("store-test-secret")
+
+	s := newTestStreamer(t, 10).WithStore(store)
+	jl := s.StartJob("job-1")
+	jl.AddLog(types.NewLogEntry("stdout", "password=store-test-secret", 0))
+	s.StopJob("job-1")
+
+	msgs, found, err := store.Tail("job-1", 10, "")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, msgs, 1)
+	assert.NotContains(t, msgs[0].Line, "store-test-secret")
+}
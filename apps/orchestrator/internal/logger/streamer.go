@@ -2,38 +2,69 @@ package logger
 
 import (
 	"context"
+	"encoding/json"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
 	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
+// backendOwner owns the subscriptions made over the backend connection
+const backendOwner = "backend"
+
+// walPruneInterval is how often expired job logs are removed from the WAL
+const walPruneInterval = time.Hour
+
+// sendTimeout bounds how long a job waits for room in the WebSocket send
+// buffer before the rest of its batch is dropped
+const sendTimeout = 5 * time.Second
+
 // Streamer handles log streaming for multiple jobs
 type Streamer struct {
 	config   config.WSLogConfig
 	wsClient *WebSocketClient
+	wal      *WAL
+	store    *LogStore
 	log      *logrus.Logger
 
 	// Job tracking
 	mu         sync.RWMutex
 	activeJobs map[string]*JobLogger
 
+	// Filtered subscriptions from the backend and admin clients; while the
+	// backend has any, they replace the unfiltered stream
+	subMu         sync.RWMutex
+	subscriptions map[string]*subscription
+
 	// Control
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
-// JobLogger tracks logging for a specific job
+// JobLogger tracks logging for a specific job. Each job has its own bounded
+// queue drained by its own goroutine, so a job that logs heavily or whose
+// flush fails cannot hold up the others.
 type JobLogger struct {
-	jobID     string
-	streamer  *Streamer
-	buffer    []LogMessage
-	bufferMu  sync.Mutex
-	lastFlush time.Time
-	sequence  int64
+	jobID    string
+	streamer *Streamer
+
+	// Guards the sequence so messages are queued and written to the WAL in
+	// sequence order
+	mu       sync.Mutex
+	sequence int64
+	stopped  bool
+
+	queue   chan LogMessage
+	flushCh chan chan struct{}
+	dropped atomic.Int64
+	stop    chan struct{}
+	done    chan struct{}
 }
 
 // NewStreamer creates a new log streamer
@@ -41,12 +72,19 @@ func NewStreamer(cfg config.WSLogConfig, wsURL, token string, log *logrus.Logger
 	ctx, cancel := context.WithCancel(context.Background())
 
 	s := &Streamer{
-		config:     cfg,
-		log:        log,
-		activeJobs: make(map[string]*JobLogger),
-		ctx:        ctx,
-		cancel:     cancel,
+		config:        cfg,
+		log:           log,
+		activeJobs:    make(map[string]*JobLogger),
+		subscriptions: make(map[string]*subscription),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+
+	wal, err := NewWAL(cfg.WAL, log)
+	if err != nil {
+		log.WithError(err).Warn("Log history disabled, replays will be unavailable")
 	}
+	s.wal = wal
 
 	// Create WebSocket client if enabled
 	if cfg.Enabled && wsURL != "" {
@@ -55,24 +93,44 @@ func NewStreamer(cfg config.WSLogConfig, wsURL, token string, log *logrus.Logger
 			func() { log.Info("Log streaming connected") },
 			func(err error) {
 				log.WithError(err).Warn("Log streaming disconnected")
+				// The backend subscribes again after reconnecting
+				s.RemoveSubscriptions(backendOwner)
 				// Attempt reconnection
 				go s.wsClient.Reconnect(ctx)
 			},
 		)
+		s.wsClient.SetMessageHandler(s.handleBackendMessage)
 	}
 
 	return s
 }
 
+// WithStore also writes job logs to the on-disk log store
+func (s *Streamer) WithStore(store *LogStore) *Streamer {
+	s.store = store
+	return s
+}
+
+// Store returns the on-disk job log store, nil when disabled
+func (s *Streamer) Store() *LogStore {
+	return s.store
+}
+
+// handleBackendMessage applies a control message received from the backend
+func (s *Streamer) handleBackendMessage(data []byte) {
+	var msg ControlMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+		return
+	}
+	s.HandleControl(backendOwner, msg, s.wsClient.Send)
+}
+
 // Start begins the log streaming service
 func (s *Streamer) Start(ctx context.Context) error {
 	if s.wsClient == nil {
+		// Admin subscriptions and the WAL still work without the backend
 		s.log.Info("Log streaming disabled")
-		return nil
-	}
-
-	// Connect WebSocket
-	if err := s.wsClient.Connect(ctx); err != nil {
+	} else if err := s.wsClient.Connect(ctx); err != nil {
 		s.log.WithError(err).Warn("Failed to connect log streaming, will retry")
 		// Start reconnection in background
 		go s.wsClient.Reconnect(s.ctx)
@@ -87,10 +145,28 @@ func (s *Streamer) Start(ctx context.Context) error {
 
 // Stop stops the log streaming service
 func (s *Streamer) Stop() error {
-	s.cancel()
-
 	// Flush all pending logs
-	s.flushAll()
+	s.mu.Lock()
+	jobs := make([]*JobLogger, 0, len(s.activeJobs))
+	for jobID, jl := range s.activeJobs {
+		jobs = append(jobs, jl)
+		delete(s.activeJobs, jobID)
+	}
+	s.mu.Unlock()
+	for _, jl := range jobs {
+		jl.close()
+	}
+
+	s.cancel()
+	s.subMu.RLock()
+	for _, sub := range s.subscriptions {
+		sub.mu.Lock()
+		sub.flushLocked()
+		sub.mu.Unlock()
+	}
+	s.subMu.RUnlock()
+	s.wal.Close()
+	s.store.Close()
 
 	// Disconnect WebSocket
 	if s.wsClient != nil {
@@ -108,29 +184,38 @@ func (s *Streamer) StartJob(jobID string) *JobLogger {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if jl, exists := s.activeJobs[jobID]; exists {
+		return jl
+	}
+
 	jl := &JobLogger{
-		jobID:     jobID,
-		streamer:  s,
-		buffer:    make([]LogMessage, 0, s.config.BufferSize),
-		lastFlush: time.Now(),
+		jobID:    jobID,
+		streamer: s,
+		queue:    make(chan LogMessage, max(s.config.BufferSize, 1)),
+		flushCh:  make(chan chan struct{}),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
 	}
 
 	s.activeJobs[jobID] = jl
+	s.wg.Add(1)
+	go jl.run()
 	s.log.WithField("jobID", jobID).Debug("Started job logging")
 
 	return jl
 }
 
-// StopJob stops logging for a job
+// StopJob stops logging for a job once its remaining logs are flushed
 func (s *Streamer) StopJob(jobID string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if jl, exists := s.activeJobs[jobID]; exists {
-		// Flush any remaining logs
-		jl.Flush()
+	jl, exists := s.activeJobs[jobID]
+	delete(s.activeJobs, jobID)
+	s.mu.Unlock()
 
-		delete(s.activeJobs, jobID)
+	if exists {
+		jl.close()
+		s.wal.CloseJob(jobID)
+		s.store.CloseJob(jobID)
 		s.log.WithField("jobID", jobID).Debug("Stopped job logging")
 	}
 }
@@ -151,113 +236,218 @@ func (s *Streamer) StreamLog(jobID string, logEntry *types.LogEntry) {
 	} else {
 		// Job not tracked, send directly if connected
 		if s.wsClient != nil && s.wsClient.IsConnected() {
-			s.wsClient.SendLog(jobID, logEntry)
+			entry := *logEntry
+			entry.Line = redact.String(entry.Line)
+			s.wsClient.SendLog(jobID, &entry)
 		}
 	}
 }
 
-// flushLoop periodically flushes buffered logs
+// flushLoop periodically flushes subscription batches and prunes the WAL
+// and log store.
+// Job logs are flushed by each job's own goroutine.
 func (s *Streamer) flushLoop() {
 	defer s.wg.Done()
 
 	ticker := time.NewTicker(s.config.FlushInterval)
 	defer ticker.Stop()
 
+	pruneTicker := time.NewTicker(walPruneInterval)
+	defer pruneTicker.Stop()
+	s.wal.Prune()
+	s.store.Prune()
+
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
 		case <-ticker.C:
-			s.flushAll()
+			s.flushSubscriptionsSafely()
+		case <-pruneTicker.C:
+			s.wal.Prune()
+			s.store.Prune()
 		}
 	}
 }
 
-// flushAll flushes logs for all active jobs
-func (s *Streamer) flushAll() {
-	s.mu.RLock()
-	jobs := make([]*JobLogger, 0, len(s.activeJobs))
-	for _, jl := range s.activeJobs {
-		jobs = append(jobs, jl)
+// flushSubscriptionsSafely flushes subscriptions, surviving a failing sink
+func (s *Streamer) flushSubscriptionsSafely() {
+	defer s.recoverFlush("")
+	s.flushSubscriptions()
+}
+
+// recoverFlush logs a panic raised while flushing instead of letting it
+// stop log streaming for every job
+func (s *Streamer) recoverFlush(jobID string) {
+	if r := recover(); r != nil {
+		s.log.WithFields(logrus.Fields{
+			"jobID": jobID,
+			"panic": r,
+			"stack": string(debug.Stack()),
+		}).Error("Recovered from panic while flushing logs")
 	}
-	s.mu.RUnlock()
+}
 
-	for _, jl := range jobs {
-		jl.FlushIfNeeded()
+// publish hands a job's batch to the subscriptions, or to the backend when
+// it has none. Jobs waiting for room in the send buffer are served in turn,
+// so a heavy job cannot starve the others; each wait is bounded so a stalled
+// connection drops logs instead of stalling the job's pipeline.
+func (s *Streamer) publish(jobID string, batch []LogMessage) {
+	s.deliver(batch)
+
+	// Send to WebSocket if connected, unless the backend asked for filtered
+	// subscriptions instead
+	if s.backendSubscribed() {
+		s.log.WithField("jobID", jobID).Debug("Delivered log buffer to subscriptions")
+		return
+	}
+	if s.wsClient == nil || !s.wsClient.IsConnected() {
+		s.log.WithField("jobID", jobID).Debug("WebSocket not connected, dropping logs")
+		return
+	}
+
+	timer := time.NewTimer(sendTimeout)
+	defer timer.Stop()
+	for i, msg := range batch {
+		select {
+		case s.wsClient.send <- msg:
+		case <-timer.C:
+			s.log.WithFields(logrus.Fields{
+				"jobID":   jobID,
+				"dropped": len(batch) - i,
+			}).Warn("Log send buffer full, dropping logs")
+			return
+		}
 	}
+
+	s.log.WithFields(logrus.Fields{
+		"jobID": jobID,
+		"count": len(batch),
+	}).Debug("Flushed log buffer")
 }
 
 // JobLogger methods
 
-// AddLog adds a log entry to the buffer
+// AddLog queues a log entry for the job's pipeline. It never blocks: when
+// the queue is full the entry is dropped from the live stream, though it is
+// still written to the WAL for replays and to the log store. Known secrets
+// are masked before the line goes anywhere.
 func (jl *JobLogger) AddLog(logEntry *types.LogEntry) {
-	jl.bufferMu.Lock()
-	defer jl.bufferMu.Unlock()
+	jl.mu.Lock()
+	defer jl.mu.Unlock()
 
+	if jl.stopped {
+		return
+	}
 	jl.sequence++
 
 	msg := LogMessage{
 		JobID:     jl.jobID,
 		Timestamp: logEntry.Timestamp,
 		Stream:    logEntry.Stream,
-		Line:      logEntry.Line,
+		Level:     detectLevel(logEntry.Stream, logEntry.Line),
+		Line:      redact.String(logEntry.Line),
 		Sequence:  jl.sequence,
 	}
 
-	jl.buffer = append(jl.buffer, msg)
-
-	// Check if we should flush
-	shouldFlush := len(jl.buffer) >= jl.streamer.config.BatchSize ||
-		time.Since(jl.lastFlush) > jl.streamer.config.FlushInterval
-
-	if shouldFlush {
-		jl.flushLocked()
+	if err := jl.streamer.wal.Append(msg); err != nil {
+		jl.streamer.log.WithError(err).WithField("jobID", jl.jobID).Debug("Failed to write log WAL")
+	}
+	if err := jl.streamer.store.Write(msg); err != nil {
+		jl.streamer.log.WithError(err).WithField("jobID", jl.jobID).Debug("Failed to write job log file")
 	}
-}
-
-// FlushIfNeeded flushes the buffer if needed
-func (jl *JobLogger) FlushIfNeeded() {
-	jl.bufferMu.Lock()
-	defer jl.bufferMu.Unlock()
 
-	if len(jl.buffer) > 0 && time.Since(jl.lastFlush) > jl.streamer.config.FlushInterval {
-		jl.flushLocked()
+	select {
+	case jl.queue <- msg:
+	default:
+		jl.dropped.Add(1)
 	}
 }
 
-// Flush forces a flush of the buffer
+// Flush sends the logs queued so far and waits until they are handed off
 func (jl *JobLogger) Flush() {
-	jl.bufferMu.Lock()
-	defer jl.bufferMu.Unlock()
+	ack := make(chan struct{})
+	select {
+	case jl.flushCh <- ack:
+		<-ack
+	case <-jl.done:
+	}
+}
 
-	if len(jl.buffer) > 0 {
-		jl.flushLocked()
+// close stops the job's pipeline after flushing what it has queued
+func (jl *JobLogger) close() {
+	jl.mu.Lock()
+	if !jl.stopped {
+		jl.stopped = true
+		close(jl.stop)
 	}
+	jl.mu.Unlock()
+	<-jl.done
 }
 
-// flushLocked flushes the buffer (must be called with lock held)
-func (jl *JobLogger) flushLocked() {
-	if len(jl.buffer) == 0 {
-		return
+// run batches the job's queued logs and flushes them when the batch is full
+// or the flush interval passes
+func (jl *JobLogger) run() {
+	defer jl.streamer.wg.Done()
+	defer close(jl.done)
+
+	cfg := jl.streamer.config
+	batchSize := max(cfg.BatchSize, 1)
+	ticker := time.NewTicker(cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]LogMessage, 0, batchSize)
+	for {
+		select {
+		case msg := <-jl.queue:
+			batch = append(batch, msg)
+			if len(batch) >= batchSize {
+				batch = jl.flush(batch)
+			}
+		case <-ticker.C:
+			batch = jl.flush(batch)
+		case ack := <-jl.flushCh:
+			batch = jl.drain(batch, batchSize)
+			close(ack)
+		case <-jl.stop:
+			jl.drain(batch, batchSize)
+			return
+		}
 	}
+}
 
-	// Send to WebSocket if connected
-	if jl.streamer.wsClient != nil && jl.streamer.wsClient.IsConnected() {
-		for _, msg := range jl.buffer {
-			jl.streamer.wsClient.send <- msg
+// drain flushes the batch and everything queued behind it
+func (jl *JobLogger) drain(batch []LogMessage, batchSize int) []LogMessage {
+	for {
+		select {
+		case msg := <-jl.queue:
+			batch = append(batch, msg)
+			if len(batch) >= batchSize {
+				batch = jl.flush(batch)
+			}
+		default:
+			return jl.flush(batch)
 		}
+	}
+}
 
+// flush publishes a batch and returns it emptied for reuse. A panic is
+// recovered and loses only this batch.
+func (jl *JobLogger) flush(batch []LogMessage) (empty []LogMessage) {
+	empty = batch[:0]
+	if dropped := jl.dropped.Swap(0); dropped > 0 {
 		jl.streamer.log.WithFields(logrus.Fields{
-			"jobID": jl.jobID,
-			"count": len(jl.buffer),
-		}).Debug("Flushed log buffer")
-	} else {
-		jl.streamer.log.WithField("jobID", jl.jobID).Debug("WebSocket not connected, dropping logs")
+			"jobID":   jl.jobID,
+			"dropped": dropped,
+		}).Warn("Job log queue full, dropped logs from the live stream")
+	}
+	if len(batch) == 0 {
+		return empty
 	}
 
-	// Clear buffer
-	jl.buffer = jl.buffer[:0]
-	jl.lastFlush = time.Now()
+	defer jl.streamer.recoverFlush(jl.jobID)
+	jl.streamer.publish(jl.jobID, batch)
+	return empty
 }
 
 // LogFromUpdate logs from an execution update
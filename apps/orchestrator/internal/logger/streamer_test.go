@@ -0,0 +1,181 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStreamer(t *testing.T, bufferSize int) *Streamer {
+	t.Helper()
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	cfg := config.WSLogConfig{
+		BufferSize:    bufferSize,
+		FlushInterval: 5 * time.Millisecond,
+		BatchSize:     10,
+	}
+	s := NewStreamer(cfg, "", "", log)
+	require.NoError(t, s.Start(context.Background()))
+	t.Cleanup(func() { s.Stop() })
+	return s
+}
+
+// collector is a subscription sink recording the logs it receives per job
+type collector struct {
+	mu   sync.Mutex
+	logs map[string][]LogMessage
+}
+
+func (c *collector) sink(v any) error {
+	batch, ok := v.(LogBatch)
+	if !ok {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, msg := range batch.Logs {
+		c.logs[msg.JobID] = append(c.logs[msg.JobID], msg)
+	}
+	return nil
+}
+
+func (c *collector) received(jobID string) []LogMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]LogMessage(nil), c.logs[jobID]...)
+}
+
+func (c *collector) count(jobID string) int {
+	return len(c.received(jobID))
+}
+
+func subscribe(s *Streamer, id string, filter LogFilter, size int, sink Sink) {
+	s.HandleControl("test", ControlMessage{
+		Type:           ControlSubscribe,
+		SubscriptionID: id,
+		Filter:         filter,
+		Batch:          &BatchSettings{Size: size},
+	}, sink)
+}
+
+func TestStreamerConcurrentJobs(t *testing.T) {
+	const (
+		jobs    = 8
+		writers = 4
+		lines   = 250
+	)
+	s := newTestStreamer(t, jobs*writers*lines)
+
+	c := &collector{logs: make(map[string][]LogMessage)}
+	subscribe(s, "all", LogFilter{}, 1, c.sink)
+
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		jobID := fmt.Sprintf("job-%d", j)
+		jl := s.StartJob(jobID)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < lines; i++ {
+					jl.AddLog(types.NewLogEntry("stdout", fmt.Sprintf("line %d", i), 0))
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	for j := 0; j < jobs; j++ {
+		jobID := fmt.Sprintf("job-%d", j)
+		s.StopJob(jobID)
+
+		logs := c.received(jobID)
+		require.Len(t, logs, writers*lines, jobID)
+		for i, msg := range logs {
+			assert.Equal(t, int64(i+1), msg.Sequence, "%s delivered out of order", jobID)
+		}
+	}
+}
+
+func TestStreamerIsolatesFailingFlush(t *testing.T) {
+	s := newTestStreamer(t, 100)
+
+	subscribe(s, "bad", LogFilter{JobIDs: []string{"bad"}}, 1, func(v any) error {
+		if _, ok := v.(LogBatch); ok {
+			panic("sink failed")
+		}
+		return nil
+	})
+	c := &collector{logs: make(map[string][]LogMessage)}
+	subscribe(s, "good", LogFilter{JobIDs: []string{"good"}}, 1, c.sink)
+
+	bad := s.StartJob("bad")
+	good := s.StartJob("good")
+	for i := 0; i < 20; i++ {
+		bad.AddLog(types.NewLogEntry("stdout", "boom", 0))
+		good.AddLog(types.NewLogEntry("stdout", "ok", 0))
+	}
+	bad.Flush()
+	good.Flush()
+
+	assert.Equal(t, 20, c.count("good"))
+
+	// The failing job's pipeline keeps running after the panic
+	bad.AddLog(types.NewLogEntry("stdout", "again", 0))
+	bad.Flush()
+	s.StopJob("bad")
+	s.StopJob("good")
+}
+
+func TestStreamerHeavyJobDoesNotBlockOthers(t *testing.T) {
+	s := newTestStreamer(t, 100)
+
+	release := make(chan struct{})
+	subscribe(s, "heavy", LogFilter{JobIDs: []string{"heavy"}}, 1, func(v any) error {
+		if _, ok := v.(LogBatch); ok {
+			<-release
+		}
+		return nil
+	})
+	c := &collector{logs: make(map[string][]LogMessage)}
+	subscribe(s, "light", LogFilter{JobIDs: []string{"light"}}, 1, c.sink)
+
+	heavy := s.StartJob("heavy")
+	light := s.StartJob("light")
+
+	// The heavy job's pipeline is stuck; its producer must not block
+	added := make(chan struct{})
+	go func() {
+		for i := 0; i < 1000; i++ {
+			heavy.AddLog(types.NewLogEntry("stdout", "flood", 0))
+		}
+		close(added)
+	}()
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddLog blocked on a full queue")
+	}
+
+	for i := 0; i < 50; i++ {
+		light.AddLog(types.NewLogEntry("stdout", "ok", 0))
+	}
+	light.Flush()
+	assert.Equal(t, 50, c.count("light"))
+
+	close(release)
+	s.StopJob("heavy")
+	s.StopJob("light")
+}
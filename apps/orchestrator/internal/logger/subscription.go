@@ -0,0 +1,395 @@
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Control message types sent by subscribers
+const (
+	ControlSubscribe   = "subscribe"
+	ControlUnsubscribe = "unsubscribe"
+	ControlConfigure   = "configure"
+	ControlReplay      = "replay"
+)
+
+// Message types sent to subscribers
+const (
+	MessageLogs           = "logs"
+	MessageAck            = "ack"
+	MessageError          = "error"
+	MessageReplayComplete = "replayComplete"
+)
+
+// Bounds for per-subscription batching
+const (
+	maxSubscriptionBatch    = 1000
+	minSubscriptionInterval = 10 * time.Millisecond
+	replayBatchSize         = 200
+)
+
+// levelRank orders the log levels a subscription can filter on
+var levelRank = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+// LogFilter selects the messages a subscription receives. Empty fields match
+// everything. SinceSequence applies to each job's own sequence numbers.
+type LogFilter struct {
+	JobIDs        []string `json:"jobIds,omitempty"`
+	Streams       []string `json:"streams,omitempty"`
+	MinLevel      string   `json:"minLevel,omitempty"`
+	SinceSequence int64    `json:"sinceSequence,omitempty"`
+}
+
+// BatchSettings controls how often a subscription's messages are sent
+type BatchSettings struct {
+	Size            int `json:"size,omitempty"`
+	FlushIntervalMs int `json:"flushIntervalMs,omitempty"`
+}
+
+// ControlMessage is a request from the backend or an admin client
+type ControlMessage struct {
+	Type           string         `json:"type"`
+	SubscriptionID string         `json:"subscriptionId"`
+	Filter         LogFilter      `json:"filter"`
+	Batch          *BatchSettings `json:"batch,omitempty"`
+}
+
+// ControlResponse answers a control message
+type ControlResponse struct {
+	Type           string `json:"type"`
+	Request        string `json:"request"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	Replayed       int    `json:"replayed,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// LogBatch carries log messages matching a subscription
+type LogBatch struct {
+	Type           string       `json:"type"`
+	SubscriptionID string       `json:"subscriptionId"`
+	Logs           []LogMessage `json:"logs"`
+	Replay         bool         `json:"replay,omitempty"`
+}
+
+// Sink delivers a message to a subscriber's connection
+type Sink func(v any) error
+
+// subscription is a filtered, separately batched view of the job logs
+type subscription struct {
+	id     string
+	owner  string
+	filter LogFilter
+	sink   Sink
+
+	mu        sync.Mutex
+	size      int
+	interval  time.Duration
+	buffer    []LogMessage
+	lastFlush time.Time
+}
+
+// Matches reports whether a message passes the filter, ignoring
+// SinceSequence, which only limits replays
+func (f *LogFilter) Matches(msg LogMessage) bool {
+	if len(f.JobIDs) > 0 && !contains(f.JobIDs, msg.JobID) {
+		return false
+	}
+	if len(f.Streams) > 0 && !contains(f.Streams, msg.Stream) {
+		return false
+	}
+	if f.MinLevel != "" && levelRank[msg.Level] < levelRank[f.MinLevel] {
+		return false
+	}
+	return true
+}
+
+// validate checks a filter's level and replay settings
+func (f *LogFilter) validate() error {
+	if f.MinLevel != "" {
+		if _, ok := levelRank[f.MinLevel]; !ok {
+			return fmt.Errorf("unknown level %q", f.MinLevel)
+		}
+	}
+	if f.SinceSequence < 0 {
+		return fmt.Errorf("sinceSequence must not be negative")
+	}
+	return nil
+}
+
+// detectLevel derives a level from a line's prefix such as "ERROR:",
+// "[warn]" or "level=debug", falling back to error for stderr and info
+// otherwise
+func detectLevel(stream, line string) string {
+	head := strings.ToLower(strings.TrimLeft(line, " \t[<"))
+	head = strings.TrimPrefix(head, "level=")
+	switch {
+	case strings.HasPrefix(head, "error"), strings.HasPrefix(head, "fatal"), strings.HasPrefix(head, "crit"):
+		return "error"
+	case strings.HasPrefix(head, "warn"):
+		return "warn"
+	case strings.HasPrefix(head, "debug"), strings.HasPrefix(head, "trace"):
+		return "debug"
+	case strings.HasPrefix(head, "info"):
+		return "info"
+	}
+	if stream == "stderr" {
+		return "error"
+	}
+	return "info"
+}
+
+// configure applies batch settings, falling back to the streamer's
+func (sub *subscription) configure(batch *BatchSettings, defaults *Streamer) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.size = defaults.config.BatchSize
+	sub.interval = defaults.config.FlushInterval
+	if batch == nil {
+		return
+	}
+	if batch.Size > 0 {
+		sub.size = min(batch.Size, maxSubscriptionBatch)
+	}
+	if batch.FlushIntervalMs > 0 {
+		sub.interval = max(time.Duration(batch.FlushIntervalMs)*time.Millisecond, minSubscriptionInterval)
+	}
+}
+
+// add buffers a message and sends the batch once it is full
+func (sub *subscription) add(msg LogMessage) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.buffer = append(sub.buffer, msg)
+	if len(sub.buffer) >= sub.size {
+		sub.flushLocked()
+	}
+}
+
+// flushIfDue sends buffered messages once the flush interval has passed
+func (sub *subscription) flushIfDue() {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if len(sub.buffer) > 0 && time.Since(sub.lastFlush) >= sub.interval {
+		sub.flushLocked()
+	}
+}
+
+// flushLocked sends the buffer (must be called with lock held)
+func (sub *subscription) flushLocked() {
+	if len(sub.buffer) == 0 {
+		return
+	}
+	logs := make([]LogMessage, len(sub.buffer))
+	copy(logs, sub.buffer)
+	sub.buffer = sub.buffer[:0]
+	sub.lastFlush = time.Now()
+
+	sub.sink(LogBatch{Type: MessageLogs, SubscriptionID: sub.id, Logs: logs})
+}
+
+// HandleControl applies a control message from a subscriber. owner
+// identifies the subscriber's connection; its subscriptions are dropped with
+// RemoveSubscriptions when it goes away. Responses are sent through sink.
+func (s *Streamer) HandleControl(owner string, msg ControlMessage, sink Sink) {
+	respond := func(err error, replayed int) {
+		resp := ControlResponse{
+			Type:           MessageAck,
+			Request:        msg.Type,
+			SubscriptionID: msg.SubscriptionID,
+			Replayed:       replayed,
+		}
+		if err != nil {
+			resp.Type = MessageError
+			resp.Error = err.Error()
+		}
+		sink(resp)
+	}
+
+	if msg.SubscriptionID == "" {
+		respond(fmt.Errorf("subscriptionId is required"), 0)
+		return
+	}
+	key := owner + "/" + msg.SubscriptionID
+
+	switch msg.Type {
+	case ControlSubscribe:
+		if err := msg.Filter.validate(); err != nil {
+			respond(err, 0)
+			return
+		}
+		sub := &subscription{
+			id:        msg.SubscriptionID,
+			owner:     owner,
+			filter:    msg.Filter,
+			sink:      sink,
+			lastFlush: time.Now(),
+		}
+		sub.configure(msg.Batch, s)
+
+		s.subMu.Lock()
+		s.subscriptions[key] = sub
+		s.subMu.Unlock()
+
+		// Live messages flow from here on; the replay may overlap them, so
+		// subscribers dedupe by job ID and sequence
+		replayed := 0
+		var err error
+		if msg.Filter.SinceSequence > 0 {
+			replayed, err = s.replay(msg.SubscriptionID, msg.Filter, sink)
+		}
+		respond(err, replayed)
+
+	case ControlUnsubscribe:
+		s.subMu.Lock()
+		sub, ok := s.subscriptions[key]
+		delete(s.subscriptions, key)
+		s.subMu.Unlock()
+		if !ok {
+			respond(fmt.Errorf("unknown subscription"), 0)
+			return
+		}
+		sub.mu.Lock()
+		sub.flushLocked()
+		sub.mu.Unlock()
+		respond(nil, 0)
+
+	case ControlConfigure:
+		s.subMu.RLock()
+		sub, ok := s.subscriptions[key]
+		s.subMu.RUnlock()
+		if !ok {
+			respond(fmt.Errorf("unknown subscription"), 0)
+			return
+		}
+		sub.configure(msg.Batch, s)
+		respond(nil, 0)
+
+	case ControlReplay:
+		if err := msg.Filter.validate(); err != nil {
+			respond(err, 0)
+			return
+		}
+		replayed, err := s.replay(msg.SubscriptionID, msg.Filter, sink)
+		if err != nil {
+			respond(err, replayed)
+			return
+		}
+		sink(ControlResponse{Type: MessageReplayComplete, Request: msg.Type, SubscriptionID: msg.SubscriptionID, Replayed: replayed})
+
+	default:
+		respond(fmt.Errorf("unknown control message type %q", msg.Type), 0)
+	}
+}
+
+// RemoveSubscriptions drops every subscription of a disconnected subscriber
+func (s *Streamer) RemoveSubscriptions(owner string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+
+	for key, sub := range s.subscriptions {
+		if sub.owner == owner {
+			delete(s.subscriptions, key)
+		}
+	}
+}
+
+// replay sends the WAL history of the filter's jobs in batches
+func (s *Streamer) replay(subscriptionID string, filter LogFilter, sink Sink) (int, error) {
+	if len(filter.JobIDs) == 0 {
+		return 0, fmt.Errorf("replay requires jobIds")
+	}
+	if s.wal == nil {
+		return 0, fmt.Errorf("log history is not kept on this orchestrator")
+	}
+
+	replayed := 0
+	batch := make([]LogMessage, 0, replayBatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := sink(LogBatch{Type: MessageLogs, SubscriptionID: subscriptionID, Logs: batch, Replay: true})
+		replayed += len(batch)
+		batch = make([]LogMessage, 0, replayBatchSize)
+		return err
+	}
+
+	for _, jobID := range filter.JobIDs {
+		var sendErr error
+		err := s.wal.Replay(jobID, filter.SinceSequence, func(msg LogMessage) bool {
+			if !filter.Matches(msg) {
+				return true
+			}
+			batch = append(batch, msg)
+			if len(batch) >= replayBatchSize {
+				sendErr = send()
+			}
+			return sendErr == nil
+		})
+		if sendErr != nil {
+			return replayed, sendErr
+		}
+		if err != nil {
+			return replayed, fmt.Errorf("failed to read log history for job %s: %w", jobID, err)
+		}
+	}
+	return replayed, send()
+}
+
+// deliver hands messages to every matching subscription
+func (s *Streamer) deliver(msgs []LogMessage) {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+
+	for _, sub := range s.subscriptions {
+		for _, msg := range msgs {
+			if sub.filter.Matches(msg) {
+				sub.add(msg)
+			}
+		}
+	}
+}
+
+// flushSubscriptions sends subscription batches whose interval has passed
+func (s *Streamer) flushSubscriptions() {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+
+	for _, sub := range s.subscriptions {
+		sub.flushIfDue()
+	}
+}
+
+// backendSubscribed reports whether the backend has subscriptions of its
+// own, which replace the unfiltered stream
+func (s *Streamer) backendSubscribed() bool {
+	s.subMu.RLock()
+	defer s.subMu.RUnlock()
+
+	for _, sub := range s.subscriptions {
+		if sub.owner == backendOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list holds value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// maxWALLine is the longest log message read back from the WAL
+const maxWALLine = 1024 * 1024
+
+// WAL keeps a local append-only copy of each job's log messages, one JSON
+// line per message, so subscribers can replay history they missed
+type WAL struct {
+	dir       string
+	retention time.Duration
+	log       *logrus.Logger
+
+	mu    sync.Mutex
+	files map[string]*os.File // jobID -> open log file
+}
+
+// NewWAL creates the WAL directory. It returns nil when the WAL is disabled;
+// a nil WAL accepts appends and replays nothing.
+func NewWAL(cfg config.LogWALConfig, log *logrus.Logger) (*WAL, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create log WAL directory: %w", err)
+	}
+	return &WAL{
+		dir:       cfg.Dir,
+		retention: cfg.Retention,
+		log:       log,
+		files:     make(map[string]*os.File),
+	}, nil
+}
+
+// Append writes a message to its job's log
+func (w *WAL) Append(msg LogMessage) error {
+	if w == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, ok := w.files[msg.JobID]
+	if !ok {
+		file, err = os.OpenFile(w.path(msg.JobID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+		if err != nil {
+			return err
+		}
+		w.files[msg.JobID] = file
+	}
+	_, err = file.Write(data)
+	return err
+}
+
+// CloseJob closes a finished job's log; it stays on disk until pruned
+func (w *WAL) CloseJob(jobID string) {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if file, ok := w.files[jobID]; ok {
+		file.Close()
+		delete(w.files, jobID)
+	}
+}
+
+// Replay calls fn for each logged message of a job with a sequence above
+// since, in order, until fn returns false
+func (w *WAL) Replay(jobID string, since int64, fn func(LogMessage) bool) error {
+	if w == nil {
+		return nil
+	}
+
+	file, err := os.Open(w.path(jobID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxWALLine)
+	for scanner.Scan() {
+		var msg LogMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// A torn final line from a crash; everything before it is intact
+			continue
+		}
+		if msg.Sequence <= since {
+			continue
+		}
+		if !fn(msg) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Prune removes the logs of finished jobs older than the retention period
+func (w *WAL) Prune() {
+	if w == nil {
+		return
+	}
+
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		w.log.WithError(err).Warn("Failed to read log WAL directory")
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	open := make(map[string]bool, len(w.files))
+	for jobID := range w.files {
+		open[filepath.Base(w.path(jobID))] = true
+	}
+
+	cutoff := time.Now().Add(-w.retention)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".log") || open[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(w.dir, entry.Name())); err != nil {
+			w.log.WithError(err).WithField("file", entry.Name()).Warn("Failed to prune log WAL file")
+		}
+	}
+}
+
+// Close closes every open job log
+func (w *WAL) Close() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for jobID, file := range w.files {
+		file.Close()
+		delete(w.files, jobID)
+	}
+}
+
+// path returns the log file for a job, keeping job IDs from escaping the
+// WAL directory
+func (w *WAL) path(jobID string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, jobID)
+	return filepath.Join(w.dir, safe+".log")
+}
@@ -24,12 +24,13 @@ type WebSocketClient struct {
 	maxReconnectDelay time.Duration
 
 	// Channels
-	send chan LogMessage
+	send chan any
 	done chan struct{}
 
 	// Callbacks
 	onConnect    func()
 	onDisconnect func(error)
+	onMessage    func([]byte)
 }
 
 // LogMessage represents a log message to be sent
@@ -37,6 +38,7 @@ type LogMessage struct {
 	JobID     string    `json:"jobId"`
 	Timestamp time.Time `json:"timestamp"`
 	Stream    string    `json:"stream"`
+	Level     string    `json:"level,omitempty"`
 	Line      string    `json:"line"`
 	Sequence  int64     `json:"sequence"`
 }
@@ -49,7 +51,7 @@ func NewWebSocketClient(wsURL, token string, log *logrus.Logger) *WebSocketClien
 		log:               log,
 		reconnectDelay:    time.Second,
 		maxReconnectDelay: 30 * time.Second,
-		send:              make(chan LogMessage, 1000),
+		send:              make(chan any, 1000),
 		done:              make(chan struct{}),
 	}
 }
@@ -128,6 +130,7 @@ func (c *WebSocketClient) SendLog(jobID string, logEntry *types.LogEntry) {
 		JobID:     jobID,
 		Timestamp: logEntry.Timestamp,
 		Stream:    logEntry.Stream,
+		Level:     detectLevel(logEntry.Stream, logEntry.Line),
 		Line:      logEntry.Line,
 		Sequence:  logEntry.Sequence,
 	}
@@ -139,6 +142,19 @@ func (c *WebSocketClient) SendLog(jobID string, logEntry *types.LogEntry) {
 	}
 }
 
+// Send queues any message for the backend without blocking
+func (c *WebSocketClient) Send(v any) error {
+	if !c.IsConnected() {
+		return fmt.Errorf("WebSocket not connected")
+	}
+	select {
+	case c.send <- v:
+		return nil
+	default:
+		return fmt.Errorf("send buffer full")
+	}
+}
+
 // IsConnected returns the connection status
 func (c *WebSocketClient) IsConnected() bool {
 	c.mu.RLock()
@@ -152,6 +168,11 @@ func (c *WebSocketClient) SetCallbacks(onConnect func(), onDisconnect func(error
 	c.onDisconnect = onDisconnect
 }
 
+// SetMessageHandler sets the handler for messages from the backend
+func (c *WebSocketClient) SetMessageHandler(onMessage func([]byte)) {
+	c.onMessage = onMessage
+}
+
 // readPump handles incoming messages
 func (c *WebSocketClient) readPump() {
 	defer func() {
@@ -179,8 +200,11 @@ func (c *WebSocketClient) readPump() {
 			return
 		}
 
-		// Handle control messages from server if needed
+		// Handle control messages from server
 		c.log.WithField("message", string(message)).Debug("Received WebSocket message")
+		if c.onMessage != nil {
+			c.onMessage(message)
+		}
 	}
 }
 
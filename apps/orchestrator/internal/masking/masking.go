@@ -0,0 +1,226 @@
+// Package masking scans job output for sensitive data such as card numbers,
+// cloud credentials and tokens, and replaces matches before the output is
+// streamed or stored.
+package masking
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// detector finds one kind of sensitive data
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	// Optional check on each match to cut false positives
+	valid func(match string) bool
+}
+
+// builtinDetectors are available by name in the configuration
+var builtinDetectors = map[string]detector{
+	"credit_card": {
+		name:    "credit_card",
+		pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid:   cardNumber,
+	},
+	"aws_access_key": {
+		name:    "aws_access_key",
+		pattern: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA)[0-9A-Z]{16}\b`),
+	},
+	"aws_secret_key": {
+		name:    "aws_secret_key",
+		pattern: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`),
+	},
+	"jwt": {
+		name:    "jwt",
+		pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+	},
+}
+
+// Masker masks sensitive data in text
+type Masker struct {
+	detectors []detector
+}
+
+// NewMasker builds a masker from the enabled built-in detectors and custom
+// patterns. It returns nil when output scanning is disabled; a nil Masker
+// leaves text unchanged.
+func NewMasker(cfg config.OutputScanningConfig) (*Masker, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	m := &Masker{}
+	for _, name := range cfg.Detectors {
+		d, ok := builtinDetectors[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown output detector %q", name)
+		}
+		m.detectors = append(m.detectors, d)
+	}
+	for _, custom := range cfg.Custom {
+		pattern, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for output detector %q: %w", custom.Name, err)
+		}
+		m.detectors = append(m.detectors, detector{name: custom.Name, pattern: pattern})
+	}
+	return m, nil
+}
+
+// Mask replaces every detected value in text with [MASKED:<detector>] and
+// returns the names of the detectors that matched
+func (m *Masker) Mask(text string) (string, []string) {
+	if m == nil || text == "" {
+		return text, nil
+	}
+
+	var found []string
+	for _, d := range m.detectors {
+		matched := false
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			matched = true
+			return "[MASKED:" + d.name + "]"
+		})
+		if matched {
+			found = append(found, d.name)
+		}
+	}
+	return text, found
+}
+
+// MaskError returns a copy of an error with the detected values in its
+// message and string details masked, and the names of the detectors that
+// matched
+func (m *Masker) MaskError(details *types.ErrorDetails) (*types.ErrorDetails, []string) {
+	if m == nil || details == nil {
+		return details, nil
+	}
+
+	masked := *details
+	var found []string
+	message, names := m.Mask(details.Message)
+	masked.Message = message
+	found = append(found, names...)
+	if len(details.Details) > 0 {
+		masked.Details = make(map[string]interface{}, len(details.Details))
+		for k, v := range details.Details {
+			if s, ok := v.(string); ok {
+				s, names = m.Mask(s)
+				found = append(found, names...)
+				v = s
+			}
+			masked.Details[k] = v
+		}
+	}
+	return &masked, found
+}
+
+// Detections accumulates the detectors that matched over a job's output
+type Detections map[string]int
+
+// Add records the detectors returned by Mask
+func (d Detections) Add(names []string) {
+	for _, name := range names {
+		d[name]++
+	}
+}
+
+// Names returns the detectors that matched, sorted
+func (d Detections) Names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Warning returns the message used to flag an execution
+func (d Detections) Warning() string {
+	return fmt.Sprintf("Sensitive data detected in output (%s); matches were masked", strings.Join(d.Names(), ", "))
+}
+
+// cardNumber reports whether a candidate looks like a card number: grouped
+// the way cards are printed, or a run of digits starting with a known
+// issuer prefix, and passing the Luhn checksum. Plain digit runs such as
+// epoch-millisecond timestamps are left alone.
+func cardNumber(match string) bool {
+	if !luhnValid(match) {
+		return false
+	}
+	groups := strings.FieldsFunc(match, func(r rune) bool { return r == ' ' || r == '-' })
+	if len(groups) == 1 {
+		return knownIssuer(match)
+	}
+	if strings.ContainsRune(match, ' ') && strings.ContainsRune(match, '-') {
+		return false
+	}
+	return cardGrouping(groups)
+}
+
+// cardGrouping reports whether digit groups are laid out as on a card:
+// fours with a shorter last group, or the 4-6-5 and 4-6-4 layouts of Amex
+// and Diners Club
+func cardGrouping(groups []string) bool {
+	lengths := make([]int, len(groups))
+	for i, g := range groups {
+		lengths[i] = len(g)
+	}
+	if len(lengths) == 3 && lengths[0] == 4 && lengths[1] == 6 && (lengths[2] == 5 || lengths[2] == 4) {
+		return true
+	}
+	for i, n := range lengths {
+		last := i == len(lengths)-1
+		if n != 4 && !(last && n >= 1 && n < 4) {
+			return false
+		}
+	}
+	return len(lengths) >= 4
+}
+
+// issuerPrefixes are the leading digits of the major card networks: Visa,
+// Mastercard, Amex, Discover, Diners Club, JCB and UnionPay
+var issuerPrefixes = regexp.MustCompile(`^(?:4|5[1-5]|2(?:22[1-9]|2[3-9]\d|[3-6]\d\d|7[01]\d|720)|3[47]|6011|64[4-9]|65|3(?:0[0-5]|[689])|35(?:2[89]|[3-8]\d)|62)`)
+
+// knownIssuer reports whether an unseparated number starts with a card
+// network's prefix
+func knownIssuer(digits string) bool {
+	return issuerPrefixes.MatchString(digits)
+}
+
+// luhnValid checks a candidate card number with the Luhn checksum
+func luhnValid(match string) bool {
+	var digits []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := digits[i]
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"sync"
 
 	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
@@ -23,15 +26,56 @@ type Collector struct {
 	jobsFailed    *prometheus.CounterVec
 	jobDuration   *prometheus.HistogramVec
 	jobsActive    prometheus.Gauge
+	jobsWaiting   *prometheus.GaugeVec
+	gateChecks    *prometheus.CounterVec
+	approvals     *prometheus.CounterVec
+	analysisRuns  *prometheus.CounterVec
+	hookRuns      *prometheus.CounterVec
+
+	// Job resource usage metrics
+	jobCPU     *prometheus.HistogramVec
+	jobMemory  *prometheus.HistogramVec
+	jobNetwork *prometheus.CounterVec
+	jobDisk    *prometheus.CounterVec
+
+	// Queue and concurrency metrics
+	queueDepth    prometheus.Gauge
+	slotsTotal    prometheus.Gauge
+	slotsOccupied prometheus.Gauge
+	slotJobAge    *prometheus.GaugeVec
+	pollsDeferred *prometheus.CounterVec
+	jobsPending   prometheus.Gauge
+	jobsHandedOff prometheus.Counter
+
+	// Quarantine metrics
+	eventsQuarantined prometheus.Gauge
+	jobsQuarantined   *prometheus.CounterVec
+
+	// Dead-letter metrics
+	jobsDeadLettered *prometheus.CounterVec
+	deadLetteredJobs prometheus.Gauge
+
+	// Completion pipeline metrics
+	completionQueue   prometheus.Gauge
+	completionReports *prometheus.CounterVec
 
 	// API metrics
 	apiRequests *prometheus.CounterVec
 	apiDuration *prometheus.HistogramVec
 	apiErrors   *prometheus.CounterVec
 
+	// DNS metrics
+	dnsLookups *prometheus.CounterVec
+	dnsLatency *prometheus.HistogramVec
+
 	// Resource metrics
 	connectionPool *prometheus.GaugeVec
 
+	// Payload storage metrics
+	payloadsStored   *prometheus.GaugeVec
+	payloadBytes     *prometheus.GaugeVec
+	payloadEvictions *prometheus.CounterVec
+
 	mu sync.RWMutex
 }
 
@@ -77,6 +121,164 @@ func NewCollector(cfg config.MonitoringConfig, log *logrus.Logger) *Collector {
 				Help: "Number of currently executing jobs",
 			},
 		),
+		jobsWaiting: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cronium_jobs_waiting",
+				Help: "Number of jobs held before execution by gates or approval",
+			},
+			[]string{"state"},
+		),
+		gateChecks: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_gate_checks_total",
+				Help: "Total number of pre-execution gate checks",
+			},
+			[]string{"type", "result"},
+		),
+		approvals: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_approvals_total",
+				Help: "Total number of approval requests by outcome",
+			},
+			[]string{"result"},
+		),
+		analysisRuns: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_analysis_runs_total",
+				Help: "Total number of pre-execution static analysis runs",
+			},
+			[]string{"mode", "result"},
+		),
+		hookRuns: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_hook_runs_total",
+				Help: "Total number of job hooks run on the agent host",
+			},
+			[]string{"phase", "hook", "result"},
+		),
+
+		// Job resource usage metrics
+		jobCPU: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cronium_job_cpu_seconds",
+				Help:    "CPU time used per job execution in seconds",
+				Buckets: prometheus.ExponentialBuckets(0.1, 4, 9), // 0.1s to ~1.8h
+			},
+			[]string{"type"},
+		),
+		jobMemory: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cronium_job_peak_memory_bytes",
+				Help:    "Peak memory used per job execution in bytes",
+				Buckets: prometheus.ExponentialBuckets(16<<20, 2, 10), // 16MiB to 8GiB
+			},
+			[]string{"type"},
+		),
+		jobNetwork: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_job_network_bytes_total",
+				Help: "Total bytes sent and received by jobs",
+			},
+			[]string{"type", "direction"},
+		),
+		jobDisk: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_job_disk_bytes_total",
+				Help: "Total bytes read from and written to disk by jobs",
+			},
+			[]string{"type", "op"},
+		),
+
+		// Queue and concurrency metrics
+		queueDepth: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_queue_depth",
+				Help: "Number of queued jobs reported by the backend on the last poll",
+			},
+		),
+		slotsTotal: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_concurrency_slots",
+				Help: "Number of concurrency slots on this orchestrator",
+			},
+		),
+		slotsOccupied: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_concurrency_slots_occupied",
+				Help: "Number of concurrency slots running a job",
+			},
+		),
+		slotJobAge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cronium_concurrency_slot_job_age_seconds",
+				Help: "Age of the job occupying each concurrency slot (0 when free)",
+			},
+			[]string{"slot"},
+		),
+		pollsDeferred: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_polls_deferred_total",
+				Help: "Total number of job polls skipped",
+			},
+			[]string{"reason"},
+		),
+		jobsPending: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_jobs_pending_local",
+				Help: "Number of accepted jobs waiting locally for a free slot",
+			},
+		),
+		jobsHandedOff: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "cronium_jobs_handed_off_total",
+				Help: "Total number of unstarted jobs handed off to peer orchestrators",
+			},
+		),
+
+		// Quarantine metrics
+		eventsQuarantined: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_events_quarantined",
+				Help: "Number of events quarantined after repeated failures",
+			},
+		),
+		jobsQuarantined: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_jobs_quarantined_total",
+				Help: "Total number of jobs released because their event is quarantined",
+			},
+			[]string{"job_type"},
+		),
+
+		// Dead-letter metrics
+		jobsDeadLettered: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_jobs_dead_lettered_total",
+				Help: "Total number of jobs refused after repeated failed attempts",
+			},
+			[]string{"job_type"},
+		),
+		deadLetteredJobs: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_jobs_dead_lettered",
+				Help: "Number of jobs that reached the attempt limit and are not run again until cleared",
+			},
+		),
+
+		// Completion pipeline metrics
+		completionQueue: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "cronium_completion_queue_depth",
+				Help: "Number of job completion reports waiting to be delivered",
+			},
+		),
+		completionReports: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_completion_reports_total",
+				Help: "Total number of job completion report delivery attempts",
+			},
+			[]string{"result"},
+		),
 
 		// API metrics
 		apiRequests: prometheus.NewCounterVec(
@@ -102,6 +304,23 @@ func NewCollector(cfg config.MonitoringConfig, log *logrus.Logger) *Collector {
 			[]string{"endpoint", "method", "code"},
 		),
 
+		// DNS metrics
+		dnsLookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_dns_lookups_total",
+				Help: "Total number of DNS lookups by result",
+			},
+			[]string{"result"},
+		),
+		dnsLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "cronium_dns_lookup_duration_seconds",
+				Help:    "Upstream DNS lookup duration in seconds",
+				Buckets: []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+			},
+			[]string{"source"},
+		),
+
 		// Resource metrics
 		connectionPool: prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
@@ -110,6 +329,29 @@ func NewCollector(cfg config.MonitoringConfig, log *logrus.Logger) *Collector {
 			},
 			[]string{"server", "state"},
 		),
+
+		// Payload storage metrics
+		payloadsStored: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cronium_payloads_stored",
+				Help: "Number of job payloads in payload storage",
+			},
+			[]string{"backend"},
+		),
+		payloadBytes: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "cronium_payload_storage_bytes",
+				Help: "Total size in bytes of the job payloads in payload storage",
+			},
+			[]string{"backend"},
+		),
+		payloadEvictions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "cronium_payload_evictions_total",
+				Help: "Total number of payloads evicted to stay within the storage quota",
+			},
+			[]string{"backend"},
+		),
 	}
 
 	// Register metrics
@@ -126,10 +368,37 @@ func (c *Collector) registerMetrics() {
 		c.jobsFailed,
 		c.jobDuration,
 		c.jobsActive,
+		c.jobsWaiting,
+		c.gateChecks,
+		c.approvals,
+		c.analysisRuns,
+		c.hookRuns,
+		c.jobCPU,
+		c.jobMemory,
+		c.jobNetwork,
+		c.jobDisk,
+		c.queueDepth,
+		c.slotsTotal,
+		c.slotsOccupied,
+		c.slotJobAge,
+		c.pollsDeferred,
+		c.jobsPending,
+		c.jobsHandedOff,
+		c.eventsQuarantined,
+		c.jobsQuarantined,
+		c.jobsDeadLettered,
+		c.deadLetteredJobs,
+		c.completionQueue,
+		c.completionReports,
 		c.apiRequests,
 		c.apiDuration,
 		c.apiErrors,
+		c.dnsLookups,
+		c.dnsLatency,
 		c.connectionPool,
+		c.payloadsStored,
+		c.payloadBytes,
+		c.payloadEvictions,
 	)
 }
 
@@ -151,6 +420,24 @@ func (c *Collector) RecordJobFailed(jobType, reason string) {
 	c.jobsFailed.WithLabelValues(jobType, reason).Inc()
 }
 
+// RecordJobResources records the resource usage of a finished job; readings
+// a job's executor did not report are left out
+func (c *Collector) RecordJobResources(jobType string, usage *types.ResourceUsage) {
+	if usage == nil {
+		return
+	}
+	if usage.CPUSeconds > 0 {
+		c.jobCPU.WithLabelValues(jobType).Observe(usage.CPUSeconds)
+	}
+	if usage.PeakMemory > 0 {
+		c.jobMemory.WithLabelValues(jobType).Observe(float64(usage.PeakMemory))
+	}
+	c.jobNetwork.WithLabelValues(jobType, "rx").Add(float64(usage.NetworkRx))
+	c.jobNetwork.WithLabelValues(jobType, "tx").Add(float64(usage.NetworkTx))
+	c.jobDisk.WithLabelValues(jobType, "read").Add(float64(usage.DiskRead))
+	c.jobDisk.WithLabelValues(jobType, "write").Add(float64(usage.DiskWrite))
+}
+
 // SetActiveJobs sets the number of active jobs
 func (c *Collector) SetActiveJobs(count float64) {
 	c.jobsActive.Set(count)
@@ -166,6 +453,107 @@ func (c *Collector) DecActiveJobs() {
 	c.jobsActive.Dec()
 }
 
+// IncWaitingJobs increments jobs held in a waiting state
+func (c *Collector) IncWaitingJobs(state string) {
+	c.jobsWaiting.WithLabelValues(state).Inc()
+}
+
+// DecWaitingJobs decrements jobs held in a waiting state
+func (c *Collector) DecWaitingJobs(state string) {
+	c.jobsWaiting.WithLabelValues(state).Dec()
+}
+
+// RecordGateCheck records the result of a gate check
+func (c *Collector) RecordGateCheck(gateType, result string) {
+	c.gateChecks.WithLabelValues(gateType, result).Inc()
+}
+
+// RecordApproval records the outcome of an approval request
+func (c *Collector) RecordApproval(result string) {
+	c.approvals.WithLabelValues(result).Inc()
+}
+
+// RecordAnalysis records the outcome of a static analysis run
+func (c *Collector) RecordAnalysis(mode, result string) {
+	c.analysisRuns.WithLabelValues(mode, result).Inc()
+}
+
+// RecordHook records the result of a job hook
+func (c *Collector) RecordHook(phase, hook, result string) {
+	c.hookRuns.WithLabelValues(phase, hook, result).Inc()
+}
+
+// Queue and concurrency metrics
+
+// SetQueueDepth sets the backend-reported queue depth
+func (c *Collector) SetQueueDepth(depth float64) {
+	c.queueDepth.Set(depth)
+}
+
+// SetSlotOccupancy records the number of total and occupied concurrency slots
+func (c *Collector) SetSlotOccupancy(total, occupied float64) {
+	c.slotsTotal.Set(total)
+	c.slotsOccupied.Set(occupied)
+}
+
+// SetSlotJobAge sets the age of the job in a concurrency slot
+func (c *Collector) SetSlotJobAge(slot int, ageSeconds float64) {
+	c.slotJobAge.WithLabelValues(strconv.Itoa(slot)).Set(ageSeconds)
+}
+
+// RecordPollDeferred records a poll that was skipped
+func (c *Collector) RecordPollDeferred(reason string) {
+	c.pollsDeferred.WithLabelValues(reason).Inc()
+}
+
+// SetPendingJobs sets the number of jobs waiting locally for a slot
+func (c *Collector) SetPendingJobs(count float64) {
+	c.jobsPending.Set(count)
+}
+
+// RecordJobHandedOff records a job handed off to a peer orchestrator
+func (c *Collector) RecordJobHandedOff() {
+	c.jobsHandedOff.Inc()
+}
+
+// Quarantine metrics
+
+// SetQuarantinedEvents sets the number of quarantined events
+func (c *Collector) SetQuarantinedEvents(count float64) {
+	c.eventsQuarantined.Set(count)
+}
+
+// RecordJobQuarantined records a job released because its event is
+// quarantined
+func (c *Collector) RecordJobQuarantined(jobType string) {
+	c.jobsQuarantined.WithLabelValues(jobType).Inc()
+}
+
+// Dead-letter metrics
+
+// RecordJobDeadLettered records a job refused after repeated failed attempts
+func (c *Collector) RecordJobDeadLettered(jobType string) {
+	c.jobsDeadLettered.WithLabelValues(jobType).Inc()
+}
+
+// SetDeadLetteredJobs sets the number of jobs that reached the attempt limit
+func (c *Collector) SetDeadLetteredJobs(count float64) {
+	c.deadLetteredJobs.Set(count)
+}
+
+// Completion pipeline metrics
+
+// SetCompletionQueueDepth sets the number of completion reports waiting to
+// be delivered
+func (c *Collector) SetCompletionQueueDepth(depth float64) {
+	c.completionQueue.Set(depth)
+}
+
+// RecordCompletionReport records a completion report delivery attempt
+func (c *Collector) RecordCompletionReport(result string) {
+	c.completionReports.WithLabelValues(result).Inc()
+}
+
 // API metrics
 
 // RecordAPIRequest records an API request
@@ -179,6 +567,18 @@ func (c *Collector) RecordAPIError(endpoint, method, code string) {
 	c.apiErrors.WithLabelValues(endpoint, method, code).Inc()
 }
 
+// DNS metrics
+
+// RecordDNSLookup records a lookup through the caching resolver
+func (c *Collector) RecordDNSLookup(result string) {
+	c.dnsLookups.WithLabelValues(result).Inc()
+}
+
+// RecordDNSLatency records the duration of an upstream DNS lookup
+func (c *Collector) RecordDNSLatency(source string, duration float64) {
+	c.dnsLatency.WithLabelValues(source).Observe(duration)
+}
+
 // Resource metrics
 
 // SetConnectionPoolSize sets the SSH connection pool size
@@ -186,6 +586,75 @@ func (c *Collector) SetConnectionPoolSize(server, state string, count float64) {
 	c.connectionPool.WithLabelValues(server, state).Set(count)
 }
 
+// Payload storage metrics
+
+// SetPayloadStorage sets the number and total size of stored payloads
+func (c *Collector) SetPayloadStorage(backend string, count, bytes float64) {
+	c.payloadsStored.WithLabelValues(backend).Set(count)
+	c.payloadBytes.WithLabelValues(backend).Set(bytes)
+}
+
+// RecordPayloadEviction records a payload evicted to stay within the quota
+func (c *Collector) RecordPayloadEviction(backend string) {
+	c.payloadEvictions.WithLabelValues(backend).Inc()
+}
+
+// Resetting metrics
+
+// resettable returns the counters and histograms that can be reset, by
+// metric name. Gauges are left out since they track live state.
+func (c *Collector) resettable() map[string]interface{ Reset() } {
+	return map[string]interface{ Reset() }{
+		"cronium_jobs_received_total":         c.jobsReceived,
+		"cronium_jobs_completed_total":        c.jobsCompleted,
+		"cronium_jobs_failed_total":           c.jobsFailed,
+		"cronium_job_duration_seconds":        c.jobDuration,
+		"cronium_gate_checks_total":           c.gateChecks,
+		"cronium_approvals_total":             c.approvals,
+		"cronium_analysis_runs_total":         c.analysisRuns,
+		"cronium_hook_runs_total":             c.hookRuns,
+		"cronium_job_cpu_seconds":             c.jobCPU,
+		"cronium_job_peak_memory_bytes":       c.jobMemory,
+		"cronium_job_network_bytes_total":     c.jobNetwork,
+		"cronium_job_disk_bytes_total":        c.jobDisk,
+		"cronium_polls_deferred_total":        c.pollsDeferred,
+		"cronium_jobs_quarantined_total":      c.jobsQuarantined,
+		"cronium_jobs_dead_lettered_total":    c.jobsDeadLettered,
+		"cronium_completion_reports_total":    c.completionReports,
+		"cronium_api_requests_total":          c.apiRequests,
+		"cronium_api_duration_seconds":        c.apiDuration,
+		"cronium_api_errors_total":            c.apiErrors,
+		"cronium_dns_lookups_total":           c.dnsLookups,
+		"cronium_dns_lookup_duration_seconds": c.dnsLatency,
+		"cronium_payload_evictions_total":     c.payloadEvictions,
+	}
+}
+
+// ResettableMetrics returns the names of the metrics ResetMetrics accepts
+func (c *Collector) ResettableMetrics() []string {
+	names := make([]string, 0)
+	for name := range c.resettable() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResetMetrics zeroes the named counters and histograms, for testing. Nothing
+// is reset when any name is unknown.
+func (c *Collector) ResetMetrics(names []string) error {
+	resettable := c.resettable()
+	for _, name := range names {
+		if _, ok := resettable[name]; !ok {
+			return fmt.Errorf("metric %s cannot be reset", name)
+		}
+	}
+	for _, name := range names {
+		resettable[name].Reset()
+	}
+	return nil
+}
+
 // Server handles the metrics HTTP endpoint
 type Server struct {
 	config config.MonitoringConfig
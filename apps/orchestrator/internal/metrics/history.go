@@ -0,0 +1,436 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+)
+
+// History file names: raw-<day>.jsonl holds one snapshot per line and
+// downsampled-<day>.jsonl one point per series and step
+const (
+	rawPrefix         = "raw-"
+	downsampledPrefix = "downsampled-"
+	dayFormat         = "20060102"
+)
+
+// Point is a series' value over a step. Raw samples are points with a count
+// of one.
+type Point struct {
+	Time   time.Time         `json:"time"`
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Min    float64           `json:"min"`
+	Max    float64           `json:"max"`
+	Avg    float64           `json:"avg"`
+	Last   float64           `json:"last"`
+	Count  int               `json:"count"`
+}
+
+// Series returns the point's name with its labels in Prometheus notation
+func (p Point) Series() string {
+	if len(p.Labels) == 0 {
+		return p.Name
+	}
+	keys := make([]string, 0, len(p.Labels))
+	for k := range p.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf("%s=%q", k, p.Labels[k])
+	}
+	return p.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// snapshot is one line of a raw history file
+type snapshot struct {
+	Time    time.Time `json:"time"`
+	Samples []sample  `json:"samples"`
+}
+
+type sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// History appends snapshots of the registered metrics to local files
+type History struct {
+	cfg      config.HistoryConfig
+	gatherer prometheus.Gatherer
+	log      *logrus.Logger
+}
+
+// NewHistory creates the metrics history. It returns nil when the history is
+// disabled; a nil History records nothing.
+func NewHistory(cfg config.HistoryConfig, gatherer prometheus.Gatherer, log *logrus.Logger) *History {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &History{cfg: cfg, gatherer: gatherer, log: log}
+}
+
+// Run records a snapshot every interval and compacts finished days until
+// ctx is done
+func (h *History) Run(ctx context.Context) {
+	if h == nil {
+		return
+	}
+	if err := os.MkdirAll(h.cfg.Dir, 0o750); err != nil {
+		h.log.WithError(err).Error("Metrics history disabled")
+		return
+	}
+
+	ticker := time.NewTicker(h.cfg.Interval)
+	defer ticker.Stop()
+	compacted := ""
+	for {
+		now := time.Now().UTC()
+		if day := now.Format(dayFormat); day != compacted {
+			if err := h.compact(now); err != nil {
+				h.log.WithError(err).Warn("Failed to compact metrics history")
+			}
+			compacted = day
+		}
+		if err := h.record(now); err != nil {
+			h.log.WithError(err).Warn("Failed to record metrics history")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record appends a snapshot of the metrics to the day's raw file
+func (h *History) record(now time.Time) error {
+	families, err := h.gatherer.Gather()
+	if err != nil {
+		return err
+	}
+	snap := snapshot{Time: now, Samples: samplesOf(families, h.cfg.Prefix)}
+	line, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(h.cfg.Dir, rawPrefix+now.Format(dayFormat)+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// samplesOf flattens metric families into samples. Histograms and summaries
+// are kept as their _sum and _count series.
+func samplesOf(families []*dto.MetricFamily, prefix string) []sample {
+	var samples []sample
+	for _, family := range families {
+		name := family.GetName()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			var labels map[string]string
+			if len(m.GetLabel()) > 0 {
+				labels = make(map[string]string, len(m.GetLabel()))
+				for _, l := range m.GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+			}
+			add := func(name string, value float64) {
+				samples = append(samples, sample{Name: name, Labels: labels, Value: value})
+			}
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				add(name, m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(name, m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(name, m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM:
+				add(name+"_sum", m.GetHistogram().GetSampleSum())
+				add(name+"_count", float64(m.GetHistogram().GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				add(name+"_sum", m.GetSummary().GetSampleSum())
+				add(name+"_count", float64(m.GetSummary().GetSampleCount()))
+			}
+		}
+	}
+	return samples
+}
+
+// compact downsamples the raw files of finished days and removes files past
+// their retention
+func (h *History) compact(now time.Time) error {
+	entries, err := os.ReadDir(h.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	today := now.Format(dayFormat)
+	for _, entry := range entries {
+		prefix, day, ok := historyFile(entry.Name())
+		if !ok {
+			continue
+		}
+		path := filepath.Join(h.cfg.Dir, entry.Name())
+		start, _ := time.Parse(dayFormat, day)
+		age := now.Sub(start.Add(24 * time.Hour))
+
+		switch prefix {
+		case rawPrefix:
+			if day == today {
+				continue
+			}
+			downsampled := filepath.Join(h.cfg.Dir, downsampledPrefix+day+".jsonl")
+			if _, err := os.Stat(downsampled); os.IsNotExist(err) {
+				points, err := readRaw(path)
+				if err != nil {
+					return err
+				}
+				if err := writePoints(downsampled, Downsample(points, h.cfg.Resolution)); err != nil {
+					return err
+				}
+			}
+			if age > h.cfg.RawRetention {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		case downsampledPrefix:
+			if age > h.cfg.Retention {
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// historyFile splits a history file name into its prefix and day
+func historyFile(name string) (prefix, day string, ok bool) {
+	base, ok := strings.CutSuffix(name, ".jsonl")
+	if !ok {
+		return "", "", false
+	}
+	for _, prefix := range []string{rawPrefix, downsampledPrefix} {
+		if day, ok := strings.CutPrefix(base, prefix); ok {
+			if _, err := time.Parse(dayFormat, day); err == nil {
+				return prefix, day, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Downsample merges points into one point per series and step. Points are
+// returned ordered by series and time.
+func Downsample(points []Point, step time.Duration) []Point {
+	type bucket struct {
+		series string
+		time   time.Time
+	}
+	merged := make(map[bucket]*Point)
+	for _, p := range points {
+		key := bucket{series: p.Series(), time: p.Time.Truncate(step)}
+		m, ok := merged[key]
+		if !ok {
+			q := p
+			q.Time = key.time
+			merged[key] = &q
+			continue
+		}
+		m.Avg = (m.Avg*float64(m.Count) + p.Avg*float64(p.Count)) / float64(m.Count+p.Count)
+		m.Count += p.Count
+		m.Min = min(m.Min, p.Min)
+		m.Max = max(m.Max, p.Max)
+		// Points arrive in time order, so the later one holds the last value
+		m.Last = p.Last
+	}
+
+	result := make([]Point, 0, len(merged))
+	for _, p := range merged {
+		result = append(result, *p)
+	}
+	sortPoints(result)
+	return result
+}
+
+func sortPoints(points []Point) {
+	sort.SliceStable(points, func(i, j int) bool {
+		si, sj := points[i].Series(), points[j].Series()
+		if si != sj {
+			return si < sj
+		}
+		return points[i].Time.Before(points[j].Time)
+	})
+}
+
+// readRaw reads the snapshots of a raw file as points
+func readRaw(path string) ([]Point, error) {
+	var points []Point
+	err := readLines(path, func(line []byte) error {
+		var snap snapshot
+		if err := json.Unmarshal(line, &snap); err != nil {
+			return err
+		}
+		for _, s := range snap.Samples {
+			points = append(points, Point{
+				Time: snap.Time, Name: s.Name, Labels: s.Labels,
+				Min: s.Value, Max: s.Value, Avg: s.Value, Last: s.Value, Count: 1,
+			})
+		}
+		return nil
+	})
+	return points, err
+}
+
+// readLines calls fn for every line of a file. A torn last line, left by a
+// crash during a write, is skipped.
+func readLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var pending error
+	for scanner.Scan() {
+		if pending != nil {
+			return pending
+		}
+		pending = fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// writePoints writes points to a file, one per line, replacing it whole
+func writePoints(path string, points []Point) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	encoder := json.NewEncoder(w)
+	for _, p := range points {
+		if err := encoder.Encode(p); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// HistoryQuery selects points from the history
+type HistoryQuery struct {
+	// Metric name, or a prefix ending in *; empty selects all
+	Name   string
+	Labels map[string]string
+	Since  time.Time
+	Until  time.Time
+}
+
+func (q HistoryQuery) matches(p Point) bool {
+	if p.Time.Before(q.Since) || !q.Until.IsZero() && p.Time.After(q.Until) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(q.Name, "*"); ok {
+		if !strings.HasPrefix(p.Name, prefix) {
+			return false
+		}
+	} else if q.Name != "" && p.Name != q.Name {
+		return false
+	}
+	for k, v := range q.Labels {
+		if p.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadHistory returns the points in dir that match the query, ordered by
+// series and time. Days that still have their raw file are read from it;
+// older days from their downsampled file.
+func ReadHistory(dir string, q HistoryQuery) ([]Point, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		prefix, day, ok := historyFile(entry.Name())
+		if !ok {
+			continue
+		}
+		start, _ := time.Parse(dayFormat, day)
+		if start.Add(24*time.Hour).Before(q.Since) || !q.Until.IsZero() && start.After(q.Until) {
+			continue
+		}
+		if prefix == rawPrefix || files[day] == "" {
+			files[day] = entry.Name()
+		}
+	}
+
+	var points []Point
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if strings.HasPrefix(name, rawPrefix) {
+			raw, err := readRaw(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			for _, p := range raw {
+				if q.matches(p) {
+					points = append(points, p)
+				}
+			}
+			continue
+		}
+		err := readLines(path, func(line []byte) error {
+			var p Point
+			if err := json.Unmarshal(line, &p); err != nil {
+				return err
+			}
+			if q.matches(p) {
+				points = append(points, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	sortPoints(points)
+	return points, nil
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHistory(t *testing.T) (*History, *prometheus.CounterVec, prometheus.Histogram) {
+	registry := prometheus.NewRegistry()
+	jobs := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "cronium_jobs_total"}, []string{"type"})
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "cronium_job_seconds"})
+	other := prometheus.NewGauge(prometheus.GaugeOpts{Name: "go_threads"})
+	registry.MustRegister(jobs, duration, other)
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return NewHistory(config.HistoryConfig{
+		Enabled:      true,
+		Dir:          t.TempDir(),
+		Interval:     time.Minute,
+		Prefix:       "cronium_",
+		Resolution:   time.Hour,
+		RawRetention: 48 * time.Hour,
+		Retention:    30 * 24 * time.Hour,
+	}, registry, log), jobs, duration
+}
+
+func TestHistoryRecordAndRead(t *testing.T) {
+	h, jobs, duration := newTestHistory(t)
+	day := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+
+	jobs.WithLabelValues("container").Add(2)
+	duration.Observe(3)
+	require.NoError(t, h.record(day))
+	jobs.WithLabelValues("container").Add(4)
+	require.NoError(t, h.record(day.Add(30*time.Minute)))
+
+	points, err := ReadHistory(h.cfg.Dir, HistoryQuery{Name: "cronium_jobs_total", Since: day})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, `cronium_jobs_total{type="container"}`, points[0].Series())
+	assert.Equal(t, 6.0, points[1].Last)
+
+	points, err = ReadHistory(h.cfg.Dir, HistoryQuery{Name: "cronium_job_seconds*", Since: day})
+	require.NoError(t, err)
+	assert.Len(t, points, 4, "histograms keep their sum and count")
+
+	points, err = ReadHistory(h.cfg.Dir, HistoryQuery{Labels: map[string]string{"type": "ssh"}, Since: day})
+	require.NoError(t, err)
+	assert.Empty(t, points)
+	points, err = ReadHistory(h.cfg.Dir, HistoryQuery{Name: "go_threads", Since: day})
+	require.NoError(t, err)
+	assert.Empty(t, points, "metrics without the prefix are not recorded")
+}
+
+func TestHistoryCompaction(t *testing.T) {
+	h, jobs, _ := newTestHistory(t)
+	day := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		jobs.WithLabelValues("container").Inc()
+		require.NoError(t, h.record(day.Add(time.Duration(i)*20*time.Minute)))
+	}
+
+	// The next day the finished day is downsampled but its raw file kept
+	require.NoError(t, h.compact(day.Add(24*time.Hour)))
+	assert.FileExists(t, filepath.Join(h.cfg.Dir, "raw-20261014.jsonl"))
+	assert.FileExists(t, filepath.Join(h.cfg.Dir, "downsampled-20261014.jsonl"))
+
+	// Past the raw retention the day is read from the downsampled file
+	require.NoError(t, h.compact(day.Add(72*time.Hour)))
+	assert.NoFileExists(t, filepath.Join(h.cfg.Dir, "raw-20261014.jsonl"))
+	points, err := ReadHistory(h.cfg.Dir, HistoryQuery{Name: "cronium_jobs_total", Since: day.Add(-time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, points, 2)
+	assert.Equal(t, Point{
+		Time: day, Name: "cronium_jobs_total", Labels: map[string]string{"type": "container"},
+		Min: 1, Max: 3, Avg: 2, Last: 3, Count: 3,
+	}, points[0])
+	assert.Equal(t, 4.0, points[1].Last)
+
+	require.NoError(t, h.compact(day.Add(40*24*time.Hour)))
+	entries, err := os.ReadDir(h.cfg.Dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReadHistorySkipsTornLine(t *testing.T) {
+	h, jobs, _ := newTestHistory(t)
+	day := time.Date(2026, 10, 14, 10, 0, 0, 0, time.UTC)
+	jobs.WithLabelValues("container").Inc()
+	require.NoError(t, h.record(day))
+
+	f, err := os.OpenFile(filepath.Join(h.cfg.Dir, "raw-20261014.jsonl"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	f.WriteString(`{"time":"2026-10-14T10:01:00Z","samp`)
+	f.Close()
+
+	points, err := ReadHistory(h.cfg.Dir, HistoryQuery{Name: "cronium_jobs_total", Since: day})
+	require.NoError(t, err)
+	assert.Len(t, points, 1)
+}
@@ -0,0 +1,67 @@
+package payload
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// encryptedMagic starts every payload encrypted at rest
+const encryptedMagic = "CRONIUM-ENC1"
+
+// encryptedSuffix is appended to the keys of encrypted payloads so a change
+// of configuration never mixes them up with plaintext ones
+const encryptedSuffix = ".enc"
+
+// newCipher creates the AES-256-GCM cipher for a base64-encoded key. An
+// empty key disables encryption.
+func newCipher(encodedKey string) (cipher.AEAD, error) {
+	if encodedKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+	if err != nil {
+		return nil, fmt.Errorf("payload encryption key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("payload encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals data as magic, nonce and ciphertext. The magic is also
+// authenticated.
+func encrypt(aead cipher.AEAD, data []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := make([]byte, 0, len(encryptedMagic)+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, encryptedMagic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, []byte(encryptedMagic)), nil
+}
+
+// decrypt opens data sealed by encrypt
+func decrypt(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) {
+		return nil, fmt.Errorf("payload is not encrypted")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted payload is truncated")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(encryptedMagic))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt payload: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,357 @@
+package payload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Sources of the files in a payload, as listed in its manifest
+const (
+	SourceScript   = "script"
+	SourceFile     = "file"
+	SourceTemplate = "template"
+	SourceArtifact = "artifact"
+)
+
+// templateSuffix marks included files rendered before they are bundled
+const templateSuffix = ".tmpl"
+
+// File is a file bundled into a payload besides the script
+type File struct {
+	// Slash-separated path relative to the payload root
+	Path    string
+	Content []byte
+	Mode    os.FileMode
+	Source  string
+}
+
+// ManifestFile lists a bundled file in the payload manifest
+type ManifestFile struct {
+	Path   string `yaml:"path"`
+	Size   int64  `yaml:"size"`
+	SHA256 string `yaml:"sha256"`
+	Source string `yaml:"source"`
+}
+
+// TemplateData is what templates among a job's included files are rendered
+// with
+type TemplateData struct {
+	JobID       string
+	ExecutionID string
+	Env         map[string]string
+	Input       map[string]any
+	Parameters  map[string]any
+}
+
+// FileCollector gathers the extra files of a job's payload from the
+// configured source directory and allowed artifact URLs
+type FileCollector struct {
+	cfg    config.PayloadFilesConfig
+	client *http.Client
+}
+
+// NewFileCollector creates a collector for the configured payload files
+func NewFileCollector(cfg config.PayloadFilesConfig) *FileCollector {
+	return &FileCollector{cfg: cfg, client: &http.Client{}}
+}
+
+// Collect returns the files a job bundles into its payload, sorted by path.
+// An include that matches no file is an error, so a typo does not go
+// unnoticed until the script misses the file.
+func (c *FileCollector) Collect(ctx context.Context, spec *types.PayloadFiles, data TemplateData) ([]File, error) {
+	if spec == nil {
+		return nil, nil
+	}
+	for _, pattern := range append(append([]string{}, spec.Include...), spec.Exclude...) {
+		if err := validGlob(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []File
+	budget := &fileBudget{maxFiles: c.cfg.MaxFiles, maxBytes: c.cfg.MaxBytes}
+	if len(spec.Include) > 0 {
+		included, err := c.collectIncluded(spec, data, budget)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, included...)
+	}
+	for _, artifact := range spec.Artifacts {
+		file, err := c.fetchArtifact(ctx, artifact, budget)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for i := 1; i < len(files); i++ {
+		if files[i].Path == files[i-1].Path {
+			return nil, fmt.Errorf("payload file %s is included more than once", files[i].Path)
+		}
+	}
+	return files, nil
+}
+
+// collectIncluded reads the files under the source directory matching an
+// include and no exclude. Symbolic links and special files are skipped so
+// nothing outside the directory is bundled.
+func (c *FileCollector) collectIncluded(spec *types.PayloadFiles, data TemplateData, budget *fileBudget) ([]File, error) {
+	if c.cfg.SourceDir == "" {
+		return nil, fmt.Errorf("payload files cannot be included: no source directory is configured")
+	}
+
+	matched := make([]bool, len(spec.Include))
+	var files []File
+	err := filepath.WalkDir(c.cfg.SourceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(c.cfg.SourceDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		include := false
+		for i, pattern := range spec.Include {
+			if matchGlob(pattern, rel) {
+				matched[i] = true
+				include = true
+			}
+		}
+		for _, pattern := range spec.Exclude {
+			if matchGlob(pattern, rel) {
+				include = false
+			}
+		}
+		if !include {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if err := budget.take(info.Size()); err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		file := File{Path: rel, Content: content, Mode: fileMode(info.Mode()), Source: SourceFile}
+		if strings.HasSuffix(rel, templateSuffix) {
+			rendered, err := renderTemplate(rel, content, data)
+			if err != nil {
+				return err
+			}
+			if err := budget.grow(int64(len(rendered) - len(content))); err != nil {
+				return err
+			}
+			file.Path = strings.TrimSuffix(rel, templateSuffix)
+			file.Content = rendered
+			file.Source = SourceTemplate
+		}
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect payload files: %w", err)
+	}
+
+	for i, pattern := range spec.Include {
+		if !matched[i] {
+			return nil, fmt.Errorf("payload include %q matched no files", pattern)
+		}
+	}
+	return files, nil
+}
+
+// fetchArtifact downloads an artifact from an allowed URL, checking its
+// hash when the job gives one
+func (c *FileCollector) fetchArtifact(ctx context.Context, artifact types.PayloadArtifact, budget *fileBudget) (File, error) {
+	if err := validPath(artifact.Path); err != nil {
+		return File{}, err
+	}
+	if !c.allowedURL(artifact.URL) {
+		return File{}, fmt.Errorf("artifact URL %s is not allowed", artifact.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.FetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, artifact.URL, nil)
+	if err != nil {
+		return File{}, fmt.Errorf("invalid artifact URL %s: %w", artifact.URL, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to fetch artifact %s: %w", artifact.Path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return File{}, fmt.Errorf("failed to fetch artifact %s: %s", artifact.Path, resp.Status)
+	}
+
+	// Read one byte past the budget to tell a full budget from a larger file
+	content, err := io.ReadAll(io.LimitReader(resp.Body, budget.remaining()+1))
+	if err != nil {
+		return File{}, fmt.Errorf("failed to fetch artifact %s: %w", artifact.Path, err)
+	}
+	if err := budget.take(int64(len(content))); err != nil {
+		return File{}, err
+	}
+	if artifact.SHA256 != "" {
+		sum := sha256.Sum256(content)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, artifact.SHA256) {
+			return File{}, fmt.Errorf("artifact %s has SHA-256 %s, expected %s", artifact.Path, got, artifact.SHA256)
+		}
+	}
+	return File{Path: artifact.Path, Content: content, Mode: 0o644, Source: SourceArtifact}, nil
+}
+
+// allowedURL reports whether an artifact URL starts with an allowed prefix
+func (c *FileCollector) allowedURL(rawURL string) bool {
+	for _, prefix := range c.cfg.AllowedURLPrefixes {
+		if prefix != "" && strings.HasPrefix(rawURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileBudget enforces the limits on the files of one payload
+type fileBudget struct {
+	maxFiles int
+	maxBytes int64
+	files    int
+	bytes    int64
+}
+
+// take counts a file of size bytes
+func (b *fileBudget) take(size int64) error {
+	b.files++
+	if b.files > b.maxFiles {
+		return fmt.Errorf("payload has more than %d files", b.maxFiles)
+	}
+	return b.grow(size)
+}
+
+// grow counts size more bytes
+func (b *fileBudget) grow(size int64) error {
+	b.bytes += size
+	if b.bytes > b.maxBytes {
+		return fmt.Errorf("payload files exceed %d bytes", b.maxBytes)
+	}
+	return nil
+}
+
+// remaining returns how many bytes may still be added
+func (b *fileBudget) remaining() int64 {
+	return max(b.maxBytes-b.bytes, 0)
+}
+
+// renderTemplate renders an included template, failing on missing keys
+func renderTemplate(name string, content []byte, data TemplateData) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fileMode keeps only whether a file is executable, so payloads do not
+// depend on the umask of whoever created the source files
+func fileMode(mode os.FileMode) os.FileMode {
+	if mode&0o111 != 0 {
+		return 0o755
+	}
+	return 0o644
+}
+
+// validPath rejects payload paths that are absolute, not clean or leave the
+// payload root
+func validPath(p string) error {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") || strings.Contains(p, `\`) {
+		return fmt.Errorf("invalid payload file path %q", p)
+	}
+	return nil
+}
+
+// validGlob rejects globs that could never match a payload path
+func validGlob(pattern string) error {
+	if err := validPath(pattern); err != nil {
+		return fmt.Errorf("invalid payload glob %q", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid payload glob %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchGlob matches a slash-separated path against a glob where each
+// segment is matched with path.Match and ** matches any number of segments
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// manifestFiles lists files in the manifest, sorted by path
+func manifestFiles(files []File) []ManifestFile {
+	listed := make([]ManifestFile, 0, len(files))
+	for _, f := range files {
+		sum := sha256.Sum256(f.Content)
+		listed = append(listed, ManifestFile{
+			Path:   f.Path,
+			Size:   int64(len(f.Content)),
+			SHA256: hex.EncodeToString(sum[:]),
+			Source: f.Source,
+		})
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Path < listed[j].Path })
+	return listed
+}
@@ -0,0 +1,193 @@
+package payload
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.sql", "init.sql", true},
+		{"*.sql", "db/init.sql", false},
+		{"db/*.sql", "db/init.sql", true},
+		{"**/*.sql", "init.sql", true},
+		{"**/*.sql", "db/migrations/001.sql", true},
+		{"db/**", "db/migrations/001.sql", true},
+		{"db/**", "dbx/init.sql", false},
+		{"**/testdata/**", "a/testdata/b/c.json", true},
+		{"config.yaml", "config.yml", false},
+	} {
+		assert.Equal(t, tc.want, matchGlob(tc.pattern, tc.name), "%s ~ %s", tc.pattern, tc.name)
+	}
+}
+
+func writeSource(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0o644))
+	}
+	return dir
+}
+
+func testFilesConfig(dir string) config.PayloadFilesConfig {
+	return config.PayloadFilesConfig{SourceDir: dir, MaxFiles: 10, MaxBytes: 1 << 20, FetchTimeout: time.Second}
+}
+
+func TestCollect(t *testing.T) {
+	dir := writeSource(t, map[string]string{
+		"db/init.sql":        "create table t;",
+		"db/seed_test.sql":   "insert;",
+		"conf/app.yaml.tmpl": "job: {{.JobID}}\nenv: {{.Env.STAGE}}\nregion: {{.Parameters.region}}\n",
+		"README.md":          "not included",
+	})
+	require.NoError(t, os.Symlink("/etc/passwd", filepath.Join(dir, "db", "passwd.sql")))
+
+	c := NewFileCollector(testFilesConfig(dir))
+	spec := &types.PayloadFiles{
+		Include: []string{"db/*.sql", "conf/**"},
+		Exclude: []string{"**/*_test.sql"},
+	}
+	data := TemplateData{JobID: "job_1", Env: map[string]string{"STAGE": "prod"}, Parameters: map[string]any{"region": "eu"}}
+	files, err := c.Collect(context.Background(), spec, data)
+	require.NoError(t, err)
+
+	require.Len(t, files, 2)
+	assert.Equal(t, "conf/app.yaml", files[0].Path)
+	assert.Equal(t, SourceTemplate, files[0].Source)
+	assert.Equal(t, "job: job_1\nenv: prod\nregion: eu\n", string(files[0].Content))
+	assert.Equal(t, "db/init.sql", files[1].Path)
+	assert.Equal(t, SourceFile, files[1].Source)
+
+	// A template referring to a missing key fails the job
+	_, err = c.Collect(context.Background(), spec, TemplateData{JobID: "job_1"})
+	assert.Error(t, err)
+
+	// So does an include matching nothing
+	_, err = c.Collect(context.Background(), &types.PayloadFiles{Include: []string{"*.sh"}}, data)
+	assert.ErrorContains(t, err, "matched no files")
+
+	// And globs leaving the source directory
+	_, err = c.Collect(context.Background(), &types.PayloadFiles{Include: []string{"../*"}}, data)
+	assert.ErrorContains(t, err, "invalid payload glob")
+
+	cfg := testFilesConfig(dir)
+	cfg.MaxFiles = 1
+	_, err = NewFileCollector(cfg).Collect(context.Background(), spec, data)
+	assert.ErrorContains(t, err, "more than 1 files")
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "artifact body")
+	}))
+	defer server.Close()
+	sum := sha256.Sum256([]byte("artifact body"))
+
+	cfg := testFilesConfig("")
+	cfg.AllowedURLPrefixes = []string{server.URL + "/builds/"}
+	c := NewFileCollector(cfg)
+
+	files, err := c.Collect(context.Background(), &types.PayloadFiles{Artifacts: []types.PayloadArtifact{
+		{Path: "bin/tool", URL: server.URL + "/builds/tool", SHA256: hex.EncodeToString(sum[:])},
+	}}, TemplateData{})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "artifact body", string(files[0].Content))
+
+	_, err = c.Collect(context.Background(), &types.PayloadFiles{Artifacts: []types.PayloadArtifact{
+		{Path: "bin/tool", URL: server.URL + "/other/tool"},
+	}}, TemplateData{})
+	assert.ErrorContains(t, err, "not allowed")
+
+	_, err = c.Collect(context.Background(), &types.PayloadFiles{Artifacts: []types.PayloadArtifact{
+		{Path: "bin/tool", URL: server.URL + "/builds/tool", SHA256: "00"},
+	}}, TemplateData{})
+	assert.ErrorContains(t, err, "expected 00")
+
+	// Includes need a source directory
+	_, err = c.Collect(context.Background(), &types.PayloadFiles{Include: []string{"*"}}, TemplateData{})
+	assert.ErrorContains(t, err, "no source directory")
+}
+
+func TestCreatePayloadManifest(t *testing.T) {
+	s, err := NewService(t.TempDir(), config.PayloadStorageConfig{Backend: "local"})
+	require.NoError(t, err)
+
+	payloadPath, err := s.CreatePayload(&PayloadData{
+		JobID:         "job_1",
+		ScriptContent: "echo hi",
+		ScriptType:    "BASH",
+		Files: []File{
+			{Path: "db/init.sql", Content: []byte("create table t;"), Mode: 0o644, Source: SourceFile},
+			{Path: "bin/tool", Content: []byte("tool"), Mode: 0o755, Source: SourceArtifact},
+		},
+	})
+	require.NoError(t, err)
+
+	contents := readArchive(t, payloadPath)
+	assert.Equal(t, "create table t;", contents["db/init.sql"])
+	assert.Equal(t, "tool", contents["bin/tool"])
+
+	var manifest PayloadManifest
+	require.NoError(t, yaml.Unmarshal([]byte(contents["manifest.yaml"]), &manifest))
+	var paths []string
+	for _, f := range manifest.Files {
+		paths = append(paths, f.Path)
+		sum := sha256.Sum256([]byte(contents[f.Path]))
+		assert.Equal(t, hex.EncodeToString(sum[:]), f.SHA256, f.Path)
+	}
+	assert.Equal(t, []string{"bin/tool", "db/init.sql", "script.sh"}, paths)
+
+	_, err = s.CreatePayload(&PayloadData{
+		JobID:         "job_2",
+		ScriptContent: "echo hi",
+		Files:         []File{{Path: "manifest.yaml", Content: []byte("x")}},
+	})
+	assert.ErrorContains(t, err, "reserved")
+}
+
+// readArchive returns the regular files of a payload by path
+func readArchive(t *testing.T, payloadPath string) map[string]string {
+	f, err := os.Open(payloadPath)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return contents
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			contents[header.Name] = string(data)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package payload
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sigv4"
+)
+
+// s3Storage keeps payloads in an S3-compatible bucket, signing requests with
+// AWS Signature Version 4
+type s3Storage struct {
+	cfg      config.PayloadS3Config
+	signer   sigv4.Signer
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// newS3Storage creates an S3 backend, taking missing credentials from the
+// environment
+func newS3Storage(cfg config.PayloadS3Config) (*s3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	if cfg.AccessKeyID == "" {
+		cfg.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		cfg.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		cfg.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("no S3 credentials configured")
+	}
+
+	endpoint := &url.URL{Scheme: "https", Host: fmt.Sprintf("s3.%s.amazonaws.com", cfg.Region)}
+	if cfg.Endpoint != "" {
+		u, err := url.Parse(cfg.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+		}
+		endpoint = u
+	}
+
+	return &s3Storage{
+		cfg: cfg,
+		signer: sigv4.Signer{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+			Region:          cfg.Region,
+			Service:         "s3",
+		},
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Name returns the backend name
+func (s *s3Storage) Name() string {
+	return "s3"
+}
+
+// Put uploads an object
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(s.cfg.Prefix+key, nil), r)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload payload: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Open downloads an object; the caller must close the returned reader
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(s.cfg.Prefix+key, nil), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download payload: %w", err)
+	}
+	return resp.Body, nil
+}
+
+// Delete removes an object; S3 does not report missing objects
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.url(s.cfg.Prefix+key, nil), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete payload: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listResult is the part of a ListObjectsV2 response that is used
+type listResult struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the objects under the prefix, following continuation tokens
+func (s *s3Storage) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url("", query), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list payloads: %w", err)
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode object list: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			key := strings.TrimPrefix(c.Key, s.cfg.Prefix)
+			if key == "" || strings.Contains(key, "/") {
+				continue
+			}
+			objects = append(objects, Object{Key: key, Size: c.Size, ModTime: c.LastModified})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// url builds the URL of an object, or of the bucket for an empty key, using
+// path or virtual-hosted style
+func (s *s3Storage) url(key string, query url.Values) string {
+	u := *s.endpoint
+	objectPath := "/" + key
+	if s.cfg.PathStyle {
+		u.Path = "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path = objectPath
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// do signs and sends a request, turning error responses into errors
+func (s *s3Storage) do(req *http.Request) (*http.Response, error) {
+	s.signer.Sign(req, sigv4.UnsignedPayload, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
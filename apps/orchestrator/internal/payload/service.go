@@ -2,16 +2,23 @@ package payload
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/cipher"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
 	"gopkg.in/yaml.v2"
 )
 
@@ -20,8 +27,11 @@ type PayloadManifest struct {
 	Version     string                 `yaml:"version"`
 	Interpreter string                 `yaml:"interpreter"`
 	Entrypoint  string                 `yaml:"entrypoint"`
+	ScriptHash  string                 `yaml:"scriptHash,omitempty"`
 	Environment map[string]string      `yaml:"environment,omitempty"`
 	Metadata    map[string]interface{} `yaml:"metadata"`
+	// Every file in the payload besides the manifest, sorted by path
+	Files []ManifestFile `yaml:"files,omitempty"`
 }
 
 // PayloadData represents the data needed to create a payload
@@ -32,28 +42,72 @@ type PayloadData struct {
 	ScriptType    string                 `json:"scriptType"`
 	Environment   map[string]string      `json:"environment"`
 	Metadata      map[string]interface{} `json:"metadata"`
+	// ScriptHash is the SHA-256 of ScriptContent. With OmitScript set the
+	// script is left out of the payload and the runner restores it from
+	// its script cache by hash.
+	ScriptHash string `json:"scriptHash,omitempty"`
+	OmitScript bool   `json:"omitScript,omitempty"`
+	// Extra files bundled next to the script
+	Files []File `json:"-"`
 }
 
-// Service manages payload creation and storage
+// MetricsRecorder receives payload storage metrics
+type MetricsRecorder interface {
+	SetPayloadStorage(backend string, count, bytes float64)
+	RecordPayloadEviction(backend string)
+}
+
+// Service creates payloads and keeps them in the configured storage until
+// cleanup, within the storage quota
 type Service struct {
-	storageDir string
+	storageDir  string
+	storage     Storage
+	aead        cipher.AEAD
+	maxBytes    int64
+	maxPayloads int
+	metrics     MetricsRecorder
+	// Signs payloads for the runner; nil leaves them unsigned
+	signingKey ed25519.PrivateKey
+
+	// Serializes quota checks with the writes they make room for
+	mu sync.Mutex
 }
 
-// NewService creates a new payload service
-func NewService(storageDir string) *Service {
+// NewService creates a new payload service. Payloads are staged for
+// transfer under storageDir and kept in the configured backend.
+func NewService(storageDir string, cfg config.PayloadStorageConfig) (*Service, error) {
 	if storageDir == "" {
 		storageDir = "/app/data/payloads"
 	}
-	return &Service{
-		storageDir: storageDir,
+	storage, err := NewStorage(cfg, storageDir)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCipher(cfg.EncryptionKey)
+	if err != nil {
+		return nil, err
 	}
+	return &Service{
+		storageDir:  storageDir,
+		storage:     storage,
+		aead:        aead,
+		maxBytes:    cfg.MaxBytes,
+		maxPayloads: cfg.MaxPayloads,
+	}, nil
+}
+
+// WithMetrics records the number and size of stored payloads and evictions
+func (s *Service) WithMetrics(recorder MetricsRecorder) {
+	s.metrics = recorder
 }
 
-// CreatePayload creates a new payload tar.gz file
+// CreatePayload creates a new payload tar.gz file and stores a copy, which
+// ReadPayload returns for transfer. The returned path is the staged
+// plaintext copy, kept for inspection until ReleasePayload removes it.
 func (s *Service) CreatePayload(data *PayloadData) (string, error) {
-	// Ensure storage directory exists
-	if err := os.MkdirAll(s.storageDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	stagingDir := s.stagingDir()
+	if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
 	}
 
 	// Create temp directory for payload contents
@@ -65,9 +119,30 @@ func (s *Service) CreatePayload(data *PayloadData) (string, error) {
 
 	// Write script file
 	scriptFilename := s.getScriptFilename(data.ScriptType)
-	scriptPath := filepath.Join(tempDir, scriptFilename)
-	if err := os.WriteFile(scriptPath, []byte(data.ScriptContent), 0755); err != nil {
-		return "", fmt.Errorf("failed to write script file: %w", err)
+	files := data.Files
+	if !data.OmitScript {
+		scriptPath := filepath.Join(tempDir, scriptFilename)
+		if err := os.WriteFile(scriptPath, []byte(data.ScriptContent), 0755); err != nil {
+			return "", fmt.Errorf("failed to write script file: %w", err)
+		}
+		files = append([]File{{Path: scriptFilename, Content: []byte(data.ScriptContent), Source: SourceScript}}, files...)
+	}
+
+	// Write extra files, which must not replace the script or manifest
+	for _, f := range data.Files {
+		if err := validPath(f.Path); err != nil {
+			return "", err
+		}
+		if f.Path == scriptFilename || f.Path == "manifest.yaml" {
+			return "", fmt.Errorf("payload file %s is reserved", f.Path)
+		}
+		filePath := filepath.Join(tempDir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return "", fmt.Errorf("failed to write payload file %s: %w", f.Path, err)
+		}
+		if err := os.WriteFile(filePath, f.Content, f.Mode); err != nil {
+			return "", fmt.Errorf("failed to write payload file %s: %w", f.Path, err)
+		}
 	}
 
 	// Create manifest
@@ -75,8 +150,10 @@ func (s *Service) CreatePayload(data *PayloadData) (string, error) {
 		Version:     "v1",
 		Interpreter: s.getInterpreter(data.ScriptType),
 		Entrypoint:  scriptFilename,
+		ScriptHash:  data.ScriptHash,
 		Environment: data.Environment,
 		Metadata:    data.Metadata,
+		Files:       manifestFiles(files),
 	}
 
 	// Add job-specific metadata
@@ -98,8 +175,7 @@ func (s *Service) CreatePayload(data *PayloadData) (string, error) {
 	}
 
 	// Create tar.gz archive
-	payloadFilename := fmt.Sprintf("job-%s.tar.gz", data.JobID)
-	payloadPath := filepath.Join(s.storageDir, payloadFilename)
+	payloadPath := filepath.Join(stagingDir, payloadFilename(data.JobID))
 
 	if err := s.createTarGz(tempDir, payloadPath); err != nil {
 		return "", fmt.Errorf("failed to create archive: %w", err)
@@ -108,6 +184,7 @@ func (s *Service) CreatePayload(data *PayloadData) (string, error) {
 	// Calculate checksum
 	checksum, err := s.calculateChecksum(payloadPath)
 	if err != nil {
+		s.ReleasePayload(payloadPath)
 		return "", fmt.Errorf("failed to calculate checksum: %w", err)
 	}
 
@@ -115,45 +192,242 @@ func (s *Service) CreatePayload(data *PayloadData) (string, error) {
 	checksumPath := payloadPath + ".sha256"
 	checksumData := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(payloadPath))
 	if err := os.WriteFile(checksumPath, []byte(checksumData), 0644); err != nil {
+		s.ReleasePayload(payloadPath)
 		return "", fmt.Errorf("failed to write checksum: %w", err)
 	}
 
+	// Write the signature next to the staged copy for inspection; the
+	// transfer signs the bytes it sends
+	if s.signingKey != nil {
+		data, err := os.ReadFile(payloadPath)
+		if err == nil {
+			err = os.WriteFile(payloadPath+signatureSuffix, s.Sign(data), 0644)
+		}
+		if err != nil {
+			s.ReleasePayload(payloadPath)
+			return "", fmt.Errorf("failed to sign payload: %w", err)
+		}
+	}
+
+	if err := s.store(context.Background(), data.JobID, payloadPath); err != nil {
+		s.ReleasePayload(payloadPath)
+		return "", fmt.Errorf("failed to store payload: %w", err)
+	}
+
 	return payloadPath, nil
 }
 
-// GetPayloadPath returns the path to a payload file
+// GetPayloadPath returns the path to the staged copy of a payload
 func (s *Service) GetPayloadPath(jobID string) string {
-	return filepath.Join(s.storageDir, fmt.Sprintf("job-%s.tar.gz", jobID))
+	return filepath.Join(s.stagingDir(), payloadFilename(jobID))
+}
+
+// ReadPayload returns a stored payload archive, decrypted
+func (s *Service) ReadPayload(ctx context.Context, jobID string) ([]byte, error) {
+	r, err := s.storage.Open(ctx, s.key(jobID))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	if s.aead != nil {
+		return decrypt(s.aead, data)
+	}
+	return data, nil
+}
+
+// ReleasePayload removes the staged copy of a payload once the job is done
+// with it
+func (s *Service) ReleasePayload(payloadPath string) {
+	if filepath.Dir(payloadPath) != s.stagingDir() {
+		return
+	}
+	os.Remove(payloadPath)
+	os.Remove(payloadPath + ".sha256")
+	os.Remove(payloadPath + signatureSuffix)
+}
+
+// DeletePayload removes the stored copy of a job's payload
+func (s *Service) DeletePayload(ctx context.Context, jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Delete both forms in case encryption was turned on or off since
+	name := payloadFilename(jobID)
+	if err := s.storage.Delete(ctx, name); err != nil {
+		return err
+	}
+	if err := s.storage.Delete(ctx, name+encryptedSuffix); err != nil {
+		return err
+	}
+	_, err := s.usage(ctx)
+	return err
 }
 
 // CleanupOldPayloads removes payloads older than the specified duration
 func (s *Service) CleanupOldPayloads(maxAge time.Duration) error {
-	entries, err := os.ReadDir(s.storageDir)
+	ctx := context.Background()
+	cutoff := time.Now().Add(-maxAge)
+
+	// Staged copies outlive their job only when the orchestrator stopped
+	// before releasing them
+	if entries, err := os.ReadDir(s.stagingDir()); err == nil {
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+				os.Remove(filepath.Join(s.stagingDir(), entry.Name()))
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects, err := s.list(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read storage directory: %w", err)
+		return err
+	}
+	for _, obj := range objects {
+		if obj.ModTime.Before(cutoff) {
+			if err := s.storage.Delete(ctx, obj.Key); err != nil {
+				return fmt.Errorf("failed to delete payload %s: %w", obj.Key, err)
+			}
+		}
 	}
+	_, err = s.usage(ctx)
+	return err
+}
 
-	cutoff := time.Now().Add(-maxAge)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+// ReportUsage publishes the number and size of stored payloads
+func (s *Service) ReportUsage(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.usage(ctx)
+	return err
+}
+
+// store writes a payload to storage, encrypted when a key is configured,
+// evicting the least recently used payloads to stay within the quota
+func (s *Service) store(ctx context.Context, jobID, payloadPath string) error {
+	data, err := os.ReadFile(payloadPath)
+	if err != nil {
+		return err
+	}
+	if s.aead != nil {
+		if data, err = encrypt(s.aead, data); err != nil {
+			return err
 		}
+	}
+	size := int64(len(data))
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return fmt.Errorf("payload of %d bytes exceeds the storage quota of %d bytes", size, s.maxBytes)
+	}
 
-		info, err := entry.Info()
-		if err != nil {
-			continue
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.key(jobID)
+	objects, err := s.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	// A retried job's payload replaces its previous one
+	var (
+		total int64
+		kept  []Object
+	)
+	for _, obj := range objects {
+		if obj.Key != key {
+			kept = append(kept, obj)
+			total += obj.Size
 		}
+	}
 
-		if info.ModTime().Before(cutoff) {
-			path := filepath.Join(s.storageDir, entry.Name())
-			os.Remove(path)
-			os.Remove(path + ".sha256") // Also remove checksum file
+	sort.Slice(kept, func(i, j int) bool { return kept[i].ModTime.Before(kept[j].ModTime) })
+	for len(kept) > 0 && s.overQuota(len(kept)+1, total+size) {
+		oldest := kept[0]
+		if err := s.storage.Delete(ctx, oldest.Key); err != nil {
+			return fmt.Errorf("failed to evict payload %s: %w", oldest.Key, err)
 		}
+		if s.metrics != nil {
+			s.metrics.RecordPayloadEviction(s.storage.Name())
+		}
+		kept = kept[1:]
+		total -= oldest.Size
 	}
 
+	if err := s.storage.Put(ctx, key, bytes.NewReader(data), size); err != nil {
+		return err
+	}
+	s.report(len(kept)+1, total+size)
 	return nil
 }
 
+// overQuota reports whether count payloads totalling size bytes exceed the
+// quota
+func (s *Service) overQuota(count int, size int64) bool {
+	return (s.maxPayloads > 0 && count > s.maxPayloads) || (s.maxBytes > 0 && size > s.maxBytes)
+}
+
+// usage lists the stored payloads and reports their number and size
+func (s *Service) usage(ctx context.Context) ([]Object, error) {
+	objects, err := s.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, obj := range objects {
+		total += obj.Size
+	}
+	s.report(len(objects), total)
+	return objects, nil
+}
+
+// report publishes storage usage to the metrics recorder
+func (s *Service) report(count int, size int64) {
+	if s.metrics != nil {
+		s.metrics.SetPayloadStorage(s.storage.Name(), float64(count), float64(size))
+	}
+}
+
+// list returns the stored payloads, leaving out other objects sharing the
+// storage
+func (s *Service) list(ctx context.Context) ([]Object, error) {
+	objects, err := s.storage.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payloads: %w", err)
+	}
+	payloads := objects[:0]
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Key, "job-") {
+			payloads = append(payloads, obj)
+		}
+	}
+	return payloads, nil
+}
+
+// key returns the storage key of a job's payload
+func (s *Service) key(jobID string) string {
+	if s.aead != nil {
+		return payloadFilename(jobID) + encryptedSuffix
+	}
+	return payloadFilename(jobID)
+}
+
+// stagingDir holds payloads between creation and transfer
+func (s *Service) stagingDir() string {
+	return filepath.Join(s.storageDir, "staging")
+}
+
+// payloadFilename returns the file name of a job's payload
+func payloadFilename(jobID string) string {
+	return fmt.Sprintf("job-%s.tar.gz", jobID)
+}
+
 func (s *Service) getScriptFilename(scriptType string) string {
 	// Normalize to uppercase for comparison
 	upperType := strings.ToUpper(scriptType)
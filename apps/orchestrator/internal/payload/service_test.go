@@ -0,0 +1,38 @@
+package payload
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPayloadDecryptsStoredCopy(t *testing.T) {
+	dir := t.TempDir()
+	svc, err := NewService(dir, config.PayloadStorageConfig{
+		Backend:       "local",
+		EncryptionKey: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)),
+	})
+	require.NoError(t, err)
+
+	staged, err := svc.CreatePayload(&PayloadData{JobID: "job-1", ScriptContent: "echo hi", ScriptType: "BASH"})
+	require.NoError(t, err)
+	want, err := os.ReadFile(staged)
+	require.NoError(t, err)
+
+	// The stored copy is sealed at rest
+	stored, err := os.ReadFile(filepath.Join(dir, svc.key("job-1")))
+	require.NoError(t, err)
+	assert.NotEqual(t, want, stored)
+
+	svc.ReleasePayload(staged)
+	got, err := svc.ReadPayload(context.Background(), "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
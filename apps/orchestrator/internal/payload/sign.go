@@ -0,0 +1,95 @@
+package payload
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// signatureSuffix names a payload's detached signature, which the runner
+// looks for next to the payload
+const signatureSuffix = ".sig"
+
+// WithSigningKey signs payloads with the PEM encoded Ed25519 key at path,
+// generating and saving a new key if the file does not exist
+func (s *Service) WithSigningKey(path string) error {
+	key, err := loadOrCreateSigningKey(path)
+	if err != nil {
+		return err
+	}
+	s.signingKey = key
+	return nil
+}
+
+// PublicKey returns the public key payloads are signed with, or nil when
+// they are not signed
+func (s *Service) PublicKey() ed25519.PublicKey {
+	if s.signingKey == nil {
+		return nil
+	}
+	return s.signingKey.Public().(ed25519.PublicKey)
+}
+
+// Sign returns the detached signature of a payload archive in the runner's
+// format, a base64 Ed25519 signature on one line, or nil when payloads are
+// not signed
+func (s *Service) Sign(data []byte) []byte {
+	if s.signingKey == nil {
+		return nil
+	}
+	sig := ed25519.Sign(s.signingKey, data)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// loadOrCreateSigningKey reads the signing key at path, generating it when
+// the file does not exist
+func loadOrCreateSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createSigningKey(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("payload signing key %s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse payload signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("payload signing key %s is not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// createSigningKey generates a signing key and writes it to path
+func createSigningKey(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate payload signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload signing key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create payload signing key directory: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write payload signing key: %w", err)
+	}
+	return key, nil
+}
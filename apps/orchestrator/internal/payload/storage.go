@@ -0,0 +1,153 @@
+package payload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// Storage keeps payloads once they have been created
+type Storage interface {
+	// Name identifies the backend in metrics
+	Name() string
+
+	// Put writes size bytes from r under key, replacing any previous object
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Open returns a reader for the object stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the object stored under key; missing objects are not
+	// an error
+	Delete(ctx context.Context, key string) error
+
+	// List returns every stored object
+	List(ctx context.Context) ([]Object, error)
+}
+
+// Object is a stored payload. ModTime is when the payload was last written
+// or, for local backends, read.
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// NewStorage creates the configured payload backend. The local backend keeps
+// payloads in storageDir.
+func NewStorage(cfg config.PayloadStorageConfig, storageDir string) (Storage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return &localStorage{name: "local", dir: storageDir}, nil
+	case "tmpfs":
+		return &localStorage{name: "tmpfs", dir: cfg.TmpfsDir}, nil
+	case "s3":
+		return newS3Storage(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown payload storage backend: %s", cfg.Backend)
+	}
+}
+
+// localStorage keeps payloads as files in a directory, readable only by the
+// orchestrator's user. A tmpfs mount keeps them off disk.
+type localStorage struct {
+	name string
+	dir  string
+}
+
+// Name returns the backend name
+func (s *localStorage) Name() string {
+	return s.name
+}
+
+// Put writes an object, replacing any previous version atomically
+func (s *localStorage) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write payload: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write payload: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens an object and marks it as recently used
+func (s *localStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open payload: %w", err)
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return f, nil
+}
+
+// Delete removes an object
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the regular files in the directory, leaving out
+// subdirectories and partial writes
+func (s *localStorage) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Key: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// path maps a key to a file directly in the directory
+func (s *localStorage) path(key string) (string, error) {
+	if key == "" || strings.ContainsAny(key, `/\`) || strings.HasPrefix(key, ".") {
+		return "", fmt.Errorf("invalid payload key: %s", key)
+	}
+	return filepath.Join(s.dir, key), nil
+}
@@ -0,0 +1,162 @@
+// Package quarantine stops running events that keep failing. After a
+// configured number of consecutive failed executions an event is
+// quarantined on this orchestrator: its jobs are released instead of run
+// until an operator clears the quarantine or it expires.
+package quarantine
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Entry is a quarantined event
+type Entry struct {
+	EventID       string    `json:"eventId"`
+	Failures      int       `json:"failures"`
+	LastJobID     string    `json:"lastJobId"`
+	LastError     string    `json:"lastError,omitempty"`
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+	// Nil when the quarantine lasts until it is cleared
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Jobs released since the event was quarantined
+	Skipped int `json:"skipped"`
+}
+
+// expired reports whether the quarantine has run out
+func (e *Entry) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && now.After(*e.ExpiresAt)
+}
+
+// List tracks consecutive failures per event and the quarantined events. A
+// nil List quarantines nothing.
+type List struct {
+	cfg config.QuarantineConfig
+
+	mu       sync.Mutex
+	failures map[string]int
+	entries  map[string]*Entry
+}
+
+// New creates a quarantine list. It returns nil when quarantine is disabled.
+func New(cfg config.QuarantineConfig) *List {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &List{
+		cfg:      cfg,
+		failures: make(map[string]int),
+		entries:  make(map[string]*Entry),
+	}
+}
+
+// EventID returns the event a job runs, or an empty string for jobs without
+// one
+func EventID(job *types.Job) string {
+	if v, ok := job.Metadata["eventId"]; ok && v != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return ""
+}
+
+// Check reports whether an event is quarantined, counting the job as skipped
+// when it is
+func (l *List) Check(eventID string) (Entry, bool) {
+	if l == nil || eventID == "" {
+		return Entry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[eventID]
+	if !ok {
+		return Entry{}, false
+	}
+	if entry.expired(time.Now()) {
+		delete(l.entries, eventID)
+		return Entry{}, false
+	}
+	entry.Skipped++
+	return *entry, true
+}
+
+// Record records the outcome of an execution of an event. It returns the
+// entry and true when the failure quarantines the event.
+func (l *List) Record(eventID, jobID string, failed bool, detail string) (Entry, bool) {
+	if l == nil || eventID == "" {
+		return Entry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !failed {
+		delete(l.failures, eventID)
+		return Entry{}, false
+	}
+
+	l.failures[eventID]++
+	failures := l.failures[eventID]
+	if failures < l.cfg.Threshold {
+		return Entry{}, false
+	}
+	if _, ok := l.entries[eventID]; ok {
+		return Entry{}, false
+	}
+
+	entry := &Entry{
+		EventID:       eventID,
+		Failures:      failures,
+		LastJobID:     jobID,
+		LastError:     detail,
+		QuarantinedAt: time.Now(),
+	}
+	if l.cfg.Duration > 0 {
+		expiresAt := entry.QuarantinedAt.Add(l.cfg.Duration)
+		entry.ExpiresAt = &expiresAt
+	}
+	l.entries[eventID] = entry
+	delete(l.failures, eventID)
+	return *entry, true
+}
+
+// Clear lifts the quarantine of an event and reports whether it was
+// quarantined
+func (l *List) Clear(eventID string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, ok := l.entries[eventID]
+	delete(l.entries, eventID)
+	delete(l.failures, eventID)
+	return ok
+}
+
+// Entries returns the quarantined events, oldest first
+func (l *List) Entries() []Entry {
+	if l == nil {
+		return []Entry{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]Entry, 0, len(l.entries))
+	for eventID, entry := range l.entries {
+		if entry.expired(now) {
+			delete(l.entries, eventID)
+			continue
+		}
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].QuarantinedAt.Before(entries[j].QuarantinedAt)
+	})
+	return entries
+}
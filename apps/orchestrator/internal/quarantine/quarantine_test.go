@@ -0,0 +1,62 @@
+package quarantine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuarantineAfterConsecutiveFailures(t *testing.T) {
+	l := New(config.QuarantineConfig{Enabled: true, Threshold: 3})
+
+	l.Record("evt-1", "job-1", true, "exit 1")
+	l.Record("evt-1", "job-2", true, "exit 1")
+	// A success resets the streak
+	l.Record("evt-1", "job-3", false, "")
+	l.Record("evt-1", "job-4", true, "exit 1")
+	_, quarantined := l.Record("evt-1", "job-5", true, "exit 1")
+	assert.False(t, quarantined)
+
+	entry, quarantined := l.Record("evt-1", "job-6", true, "exit 2")
+	assert.True(t, quarantined)
+	assert.Equal(t, 3, entry.Failures)
+	assert.Equal(t, "job-6", entry.LastJobID)
+	assert.Equal(t, "exit 2", entry.LastError)
+	assert.Nil(t, entry.ExpiresAt)
+
+	entry, ok := l.Check("evt-1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, entry.Skipped)
+	_, ok = l.Check("evt-2")
+	assert.False(t, ok)
+
+	assert.True(t, l.Clear("evt-1"))
+	assert.False(t, l.Clear("evt-1"))
+	_, ok = l.Check("evt-1")
+	assert.False(t, ok)
+}
+
+func TestQuarantineExpires(t *testing.T) {
+	l := New(config.QuarantineConfig{Enabled: true, Threshold: 1, Duration: time.Millisecond})
+
+	_, quarantined := l.Record("evt-1", "job-1", true, "exit 1")
+	assert.True(t, quarantined)
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := l.Check("evt-1")
+	assert.False(t, ok)
+	assert.Empty(t, l.Entries())
+}
+
+func TestDisabledQuarantine(t *testing.T) {
+	l := New(config.QuarantineConfig{Threshold: 1})
+	assert.Nil(t, l)
+
+	_, quarantined := l.Record("evt-1", "job-1", true, "exit 1")
+	assert.False(t, quarantined)
+	_, ok := l.Check("evt-1")
+	assert.False(t, ok)
+	assert.Empty(t, l.Entries())
+}
@@ -0,0 +1,241 @@
+// Package receipt creates and verifies signed execution receipts. A receipt
+// records what ran where and how it ended, signed with the orchestrator's
+// Ed25519 key so it can be checked later without trusting the backend.
+package receipt
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Version is the receipt format version
+const Version = 1
+
+// Algorithm is the signature algorithm used for receipts
+const Algorithm = "ed25519"
+
+// Receipt is the signed record of one execution
+type Receipt struct {
+	Version        int       `json:"version"`
+	JobID          string    `json:"jobId"`
+	OrchestratorID string    `json:"orchestratorId"`
+	JobType        string    `json:"jobType"`
+	Target         string    `json:"target"`
+	ScriptHash     string    `json:"scriptHash"`
+	StartedAt      time.Time `json:"startedAt"`
+	CompletedAt    time.Time `json:"completedAt"`
+	Status         string    `json:"status"`
+	ExitCode       int       `json:"exitCode"`
+	StdoutHash     string    `json:"stdoutHash"`
+	StderrHash     string    `json:"stderrHash"`
+}
+
+// Signed is a receipt with its signature and the public key that made it
+type Signed struct {
+	Receipt   Receipt `json:"receipt"`
+	Algorithm string  `json:"algorithm"`
+	PublicKey string  `json:"publicKey"` // base64
+	KeyID     string  `json:"keyId"`
+	Signature string  `json:"signature"` // base64
+}
+
+// Hash returns the hex SHA-256 of data in the form used by receipts
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// KeyID returns a short fingerprint of a public key
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ForJob fills in the parts of a receipt that come from the job itself
+func ForJob(job *types.Job, orchestratorID string) Receipt {
+	r := Receipt{
+		JobID:          job.ID,
+		OrchestratorID: orchestratorID,
+		JobType:        string(job.Type),
+		Target:         describeTarget(job),
+	}
+	if job.Execution.Script != nil {
+		r.ScriptHash = Hash([]byte(job.Execution.Script.Content))
+	}
+	return r
+}
+
+// describeTarget names where a job ran
+func describeTarget(job *types.Job) string {
+	if servers, ok := job.Metadata["servers"].([]interface{}); ok && len(servers) > 0 {
+		ids := make([]string, 0, len(servers))
+		for _, server := range servers {
+			if serverMap, ok := server.(map[string]interface{}); ok {
+				ids = append(ids, fmt.Sprint(serverMap["id"]))
+			}
+		}
+		return "servers:" + strings.Join(ids, ",")
+	}
+
+	if server := job.Execution.Target.ServerDetails; server != nil {
+		return fmt.Sprintf("server:%s (%s@%s:%d)", server.ID, server.Username, server.Host, server.Port)
+	}
+	return string(job.Execution.Target.Type)
+}
+
+// Signer signs receipts with a private key
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// LoadOrCreateSigner loads the PEM encoded key at path, generating and saving
+// a new key if the file does not exist
+func LoadOrCreateSigner(path string) (*Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return createSigner(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("receipt key %s is not PEM encoded", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse receipt key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("receipt key %s is not an Ed25519 key", path)
+	}
+	return &Signer{key: key}, nil
+}
+
+// createSigner generates a key and writes it to path
+func createSigner(path string) (*Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate receipt key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode receipt key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create receipt key directory: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write receipt key: %w", err)
+	}
+	return &Signer{key: key}, nil
+}
+
+// PublicKey returns the signer's public key
+func (s *Signer) PublicKey() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// Sign signs a receipt
+func (s *Signer) Sign(r Receipt) (*Signed, error) {
+	// Times are normalised so the receipt encodes the same after a round trip
+	r.Version = Version
+	r.StartedAt = r.StartedAt.UTC().Truncate(time.Millisecond)
+	r.CompletedAt = r.CompletedAt.UTC().Truncate(time.Millisecond)
+	message, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode receipt: %w", err)
+	}
+
+	pub := s.PublicKey()
+	return &Signed{
+		Receipt:   r,
+		Algorithm: Algorithm,
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		KeyID:     KeyID(pub),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, message)),
+	}, nil
+}
+
+// Verify checks the receipt signature against the embedded public key. When
+// trusted is not empty the embedded key must also be one of the trusted keys,
+// otherwise anyone could sign a receipt with their own key.
+func Verify(signed *Signed, trusted ...ed25519.PublicKey) error {
+	if signed.Algorithm != Algorithm {
+		return fmt.Errorf("unsupported receipt algorithm %q", signed.Algorithm)
+	}
+	if signed.Receipt.Version != Version {
+		return fmt.Errorf("unsupported receipt version %d", signed.Receipt.Version)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(signed.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid receipt public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid receipt signature encoding")
+	}
+
+	if len(trusted) > 0 {
+		known := false
+		for _, key := range trusted {
+			if key.Equal(ed25519.PublicKey(pub)) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("receipt was signed by untrusted key %s", KeyID(pub))
+		}
+	}
+
+	message, err := json.Marshal(signed.Receipt)
+	if err != nil {
+		return fmt.Errorf("failed to encode receipt: %w", err)
+	}
+	if !ed25519.Verify(pub, message, sig) {
+		return fmt.Errorf("receipt signature does not match")
+	}
+	return nil
+}
+
+// ParsePublicKey parses a base64 or PEM encoded Ed25519 public key
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse public key: %w", err)
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is not an Ed25519 key")
+		}
+		return key, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key is neither PEM nor a base64 Ed25519 key")
+	}
+	return ed25519.PublicKey(raw), nil
+}
@@ -0,0 +1,124 @@
+package receipt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	signer, err := LoadOrCreateSigner(filepath.Join(t.TempDir(), "receipt.key"))
+	require.NoError(t, err)
+	return signer
+}
+
+func testReceipt() Receipt {
+	return Receipt{
+		JobID:          "job_1",
+		OrchestratorID: "orch-1",
+		JobType:        "SSH",
+		Target:         "server:srv_1 (deploy@web-1:22)",
+		ScriptHash:     Hash([]byte("echo hi")),
+		StartedAt:      time.Date(2026, 10, 16, 12, 0, 0, 123456789, time.FixedZone("CEST", 2*3600)),
+		CompletedAt:    time.Date(2026, 10, 16, 12, 0, 5, 0, time.UTC),
+		Status:         "completed",
+		ExitCode:       0,
+		StdoutHash:     Hash([]byte("hi\n")),
+		StderrHash:     Hash(nil),
+	}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	signer := newTestSigner(t)
+	signed, err := signer.Sign(testReceipt())
+	require.NoError(t, err)
+	assert.NoError(t, Verify(signed))
+	assert.NoError(t, Verify(signed, signer.PublicKey()))
+
+	// Receipts are stored and checked later as JSON
+	data, err := json.Marshal(signed)
+	require.NoError(t, err)
+	var decoded Signed
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.NoError(t, Verify(&decoded, signer.PublicKey()))
+
+	// A restarted orchestrator signs with the same key
+	dir := t.TempDir()
+	first, err := LoadOrCreateSigner(filepath.Join(dir, "receipt.key"))
+	require.NoError(t, err)
+	second, err := LoadOrCreateSigner(filepath.Join(dir, "receipt.key"))
+	require.NoError(t, err)
+	assert.Equal(t, first.PublicKey(), second.PublicKey())
+}
+
+func TestVerifyRejectsTamperedReceipt(t *testing.T) {
+	signer := newTestSigner(t)
+
+	tamper := map[string]func(s *Signed){
+		"exit code":   func(s *Signed) { s.Receipt.ExitCode = 1 },
+		"status":      func(s *Signed) { s.Receipt.Status = "failed" },
+		"script hash": func(s *Signed) { s.Receipt.ScriptHash = Hash([]byte("rm -rf /")) },
+		"target":      func(s *Signed) { s.Receipt.Target = "server:srv_2" },
+		"completed":   func(s *Signed) { s.Receipt.CompletedAt = s.Receipt.CompletedAt.Add(time.Hour) },
+		"signature": func(s *Signed) {
+			sig, _ := base64.StdEncoding.DecodeString(s.Signature)
+			sig[0] ^= 0xff
+			s.Signature = base64.StdEncoding.EncodeToString(sig)
+		},
+		"algorithm": func(s *Signed) { s.Algorithm = "rsa" },
+		"version":   func(s *Signed) { s.Receipt.Version = 2 },
+	}
+	for name, change := range tamper {
+		t.Run(name, func(t *testing.T) {
+			signed, err := signer.Sign(testReceipt())
+			require.NoError(t, err)
+			change(signed)
+			assert.Error(t, Verify(signed, signer.PublicKey()))
+		})
+	}
+}
+
+func TestVerifyRejectsWrongKey(t *testing.T) {
+	signer := newTestSigner(t)
+	other := newTestSigner(t)
+
+	signed, err := signer.Sign(testReceipt())
+	require.NoError(t, err)
+
+	// A key the auditor does not trust
+	err = Verify(signed, other.PublicKey())
+	assert.ErrorContains(t, err, "untrusted key")
+
+	// Another key swapped in for the embedded one
+	forged := *signed
+	forged.PublicKey = base64.StdEncoding.EncodeToString(other.PublicKey())
+	assert.ErrorContains(t, Verify(&forged), "does not match")
+
+	// A receipt re-signed by someone else's key
+	_, attackerKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	resigned, err := (&Signer{key: attackerKey}).Sign(testReceipt())
+	require.NoError(t, err)
+	assert.NoError(t, Verify(resigned))
+	assert.Error(t, Verify(resigned, signer.PublicKey()))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	signer := newTestSigner(t)
+	encoded := base64.StdEncoding.EncodeToString(signer.PublicKey())
+
+	key, err := ParsePublicKey([]byte(encoded + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, signer.PublicKey(), key)
+
+	_, err = ParsePublicKey([]byte("not a key"))
+	assert.Error(t, err)
+}
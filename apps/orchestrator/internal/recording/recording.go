@@ -0,0 +1,315 @@
+// Package recording records SSH job sessions for audit. A recording holds
+// the command sent to the server and everything the job printed, with
+// timings, in the asciicast v2 format, so it can be replayed with asciinema
+// or any compatible player. Secrets are masked before anything is written,
+// and finished recordings are made read-only and identified by their
+// SHA-256 digest.
+package recording
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Format identifies the recording format in execution metadata
+	Format = "asciicast-v2"
+
+	// Terminal size written to the header; jobs run without a terminal
+	width  = 120
+	height = 40
+
+	pruneInterval = time.Hour
+)
+
+// Info describes a finished recording in the execution record
+type Info struct {
+	Format    string `json:"format"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	Truncated bool   `json:"truncated,omitempty"`
+	// Recordings are deleted after this time
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Store keeps recordings in a directory and deletes them after the
+// retention period. A nil Store records nothing.
+type Store struct {
+	cfg config.RecordingConfig
+	log *logrus.Logger
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewStore creates the recording store. It returns nil when recording is
+// disabled.
+func NewStore(cfg config.RecordingConfig, log *logrus.Logger) (*Store, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+	return &Store{cfg: cfg, log: log}, nil
+}
+
+// Enabled reports whether a job's session is recorded. With a tenant list,
+// only the jobs of those tenants are.
+func (s *Store) Enabled(job *types.Job) bool {
+	if s == nil {
+		return false
+	}
+	if len(s.cfg.Tenants) == 0 {
+		return true
+	}
+	tenant, _ := job.Metadata["userId"].(string)
+	return slices.Contains(s.cfg.Tenants, tenant)
+}
+
+// Start starts the recording of an execution. Values in secrets are masked
+// along with every registered secret.
+func (s *Store) Start(job *types.Job, executionID string, secrets ...string) (*Recorder, error) {
+	s.maybePrune()
+
+	path := filepath.Join(s.cfg.Dir, filepath.Base(executionID)+".cast")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	r := &Recorder{
+		file:      f,
+		path:      path,
+		start:     time.Now(),
+		maxSize:   s.cfg.MaxSize,
+		retention: s.cfg.Retention,
+		secrets:   slices.DeleteFunc(secrets, func(v string) bool { return v == "" }),
+	}
+	r.hash = sha256.New()
+	r.out = bufio.NewWriter(io.MultiWriter(f, r.hash))
+
+	title := "Cronium job " + job.ID
+	if server := job.Execution.Target.ServerDetails; server != nil {
+		title += " on " + server.Name
+	}
+	header, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": r.start.Unix(),
+		"title":     title,
+		"env":       map[string]string{"TERM": "dumb"},
+	})
+	r.writeLine(header)
+	return r, nil
+}
+
+// maybePrune deletes expired recordings at most once per prune interval
+func (s *Store) maybePrune() {
+	s.mu.Lock()
+	if time.Since(s.lastPruned) < pruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPruned = time.Now()
+	s.mu.Unlock()
+
+	go func() {
+		if err := s.Prune(time.Now()); err != nil {
+			s.log.WithError(err).Warn("Failed to prune session recordings")
+		}
+	}()
+}
+
+// Prune deletes recordings older than the retention period
+func (s *Store) Prune(now time.Time) error {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	cutoff := now.Add(-s.cfg.Retention)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".cast") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.cfg.Dir, entry.Name())); err != nil {
+			s.log.WithError(err).WithField("recording", entry.Name()).Warn("Failed to delete expired session recording")
+		}
+	}
+	return nil
+}
+
+// Recorder writes one session. Output is recorded a line at a time so a
+// secret split across reads is still masked. A nil Recorder records nothing.
+type Recorder struct {
+	path      string
+	start     time.Time
+	maxSize   int64
+	retention time.Duration
+	secrets   []string
+
+	mu        sync.Mutex
+	file      *os.File
+	out       *bufio.Writer
+	hash      hash.Hash
+	size      int64
+	truncated bool
+	partial   map[*streamWriter]string
+	info      *Info
+	err       error
+}
+
+// Input records text sent to the server, echoed as output so a player
+// shows it
+func (r *Recorder) Input(text string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	text = r.mask(text)
+	r.event("i", text+"\n")
+	r.event("o", "$ "+terminalText(text)+"\r\n")
+}
+
+// Tee records everything read from reader as output
+func (r *Recorder) Tee(reader io.Reader) io.Reader {
+	if r == nil {
+		return reader
+	}
+	return io.TeeReader(reader, &streamWriter{recorder: r})
+}
+
+// Close finishes the recording, makes it read-only and describes it. It
+// can be called more than once.
+func (r *Recorder) Close() (*Info, error) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.info != nil || r.err != nil {
+		return r.info, r.err
+	}
+	for _, rest := range r.partial {
+		if rest != "" {
+			r.event("o", terminalText(r.mask(rest)))
+		}
+	}
+	if err := r.out.Flush(); err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = fmt.Errorf("failed to close recording: %w", err)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if err := os.Chmod(r.path, 0o400); err != nil {
+		r.err = fmt.Errorf("failed to make recording read-only: %w", err)
+		return nil, r.err
+	}
+
+	r.info = &Info{
+		Format:    Format,
+		Path:      r.path,
+		Size:      r.size,
+		SHA256:    hex.EncodeToString(r.hash.Sum(nil)),
+		Truncated: r.truncated,
+		ExpiresAt: time.Now().Add(r.retention),
+	}
+	return r.info, nil
+}
+
+// event writes an asciicast event; r.mu must be held
+func (r *Recorder) event(code, data string) {
+	line, _ := json.Marshal([]interface{}{
+		float64(time.Since(r.start).Microseconds()) / 1e6,
+		code,
+		data,
+	})
+	r.writeLine(line)
+}
+
+// writeLine writes a line unless the recording is full; the marker written
+// when it fills up is allowed past the limit
+func (r *Recorder) writeLine(line []byte) {
+	if r.truncated || r.err != nil || r.info != nil {
+		return
+	}
+	if r.maxSize > 0 && r.size+int64(len(line))+1 > r.maxSize {
+		r.truncated = true
+		line, _ = json.Marshal([]interface{}{
+			float64(time.Since(r.start).Microseconds()) / 1e6,
+			"o",
+			"\r\n[recording truncated at size limit]\r\n",
+		})
+	}
+	n, err := r.out.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		r.err = fmt.Errorf("failed to write recording: %w", err)
+	}
+}
+
+// mask replaces secrets in text
+func (r *Recorder) mask(text string) string {
+	text = redact.String(text)
+	for _, secret := range r.secrets {
+		text = strings.ReplaceAll(text, secret, redact.Mask)
+	}
+	return text
+}
+
+// streamWriter records one output stream, buffering incomplete lines
+type streamWriter struct {
+	recorder *Recorder
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	r := w.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.partial == nil {
+		r.partial = make(map[*streamWriter]string)
+	}
+	text := r.partial[w] + string(p)
+	end := strings.LastIndexByte(text, '\n')
+	if end < 0 {
+		r.partial[w] = text
+		return len(p), nil
+	}
+	r.partial[w] = text[end+1:]
+	r.event("o", terminalText(r.mask(text[:end+1])))
+	return len(p), nil
+}
+
+// terminalText converts line endings for terminal playback
+func terminalText(text string) string {
+	return strings.ReplaceAll(text, "\n", "\r\n")
+}
@@ -0,0 +1,165 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T, cfg config.RecordingConfig) *Store {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	cfg.Enabled = true
+	cfg.Dir = t.TempDir()
+	if cfg.Retention == 0 {
+		cfg.Retention = time.Hour
+	}
+	s, err := NewStore(cfg, log)
+	require.NoError(t, err)
+	return s
+}
+
+func testJob(userID string) *types.Job {
+	return &types.Job{
+		ID:       "job_1",
+		Metadata: map[string]any{"userId": userID},
+		Execution: types.ExecutionConfig{Target: types.Target{
+			ServerDetails: &types.ServerDetails{Name: "db-1"},
+		}},
+	}
+}
+
+// readCast returns the header and events of a recording
+func readCast(t *testing.T, path string) (map[string]any, [][]any) {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	require.True(t, scanner.Scan())
+	var header map[string]any
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	var events [][]any
+	for scanner.Scan() {
+		var event []any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, event)
+	}
+	return header, events
+}
+
+func TestRecordingMasksSecrets(t *testing.T) {
+	redact.Add("registered-password")
+	s := newTestStore(t, config.RecordingConfig{})
+
+	rec, err := s.Start(testJob("user_1"), "exec_1", "job-token-123")
+	require.NoError(t, err)
+	rec.Input("export CRONIUM_API_TOKEN=job-token-123 && cronium-runner run")
+
+	// The secret arrives split across two reads
+	stdout := rec.Tee(io.MultiReader(
+		strings.NewReader("line one\npassword is regis"),
+		strings.NewReader("tered-password\nunfinished"),
+	))
+	stderr := rec.Tee(strings.NewReader("warning\n"))
+	_, err = io.ReadAll(stdout)
+	require.NoError(t, err)
+	_, err = io.ReadAll(stderr)
+	require.NoError(t, err)
+
+	// The unfinished last line is written on close
+	info, err := rec.Close()
+	require.NoError(t, err)
+	assert.Equal(t, Format, info.Format)
+	assert.Len(t, info.SHA256, 64)
+	assert.False(t, info.Truncated)
+
+	again, err := rec.Close()
+	require.NoError(t, err)
+	assert.Same(t, info, again)
+
+	stat, err := os.Stat(info.Path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o400), stat.Mode().Perm())
+	assert.Equal(t, info.Size, stat.Size())
+
+	header, events := readCast(t, info.Path)
+	assert.EqualValues(t, 2, header["version"])
+	assert.Equal(t, "Cronium job job_1 on db-1", header["title"])
+
+	raw, err := os.ReadFile(info.Path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "job-token-123")
+	assert.NotContains(t, string(raw), "registered-password")
+
+	require.Len(t, events, 6)
+	assert.Equal(t, "i", events[0][1])
+	assert.Equal(t, "$ export CRONIUM_API_TOKEN=***hidden*** && cronium-runner run\r\n", events[1][2])
+	assert.Equal(t, "line one\r\n", events[2][2])
+	assert.Equal(t, "password is ***hidden***\r\n", events[3][2])
+	assert.Equal(t, "warning\r\n", events[4][2])
+	assert.Equal(t, "unfinished", events[5][2])
+}
+
+func TestRecordingTruncatesAtMaxSize(t *testing.T) {
+	s := newTestStore(t, config.RecordingConfig{MaxSize: 512})
+
+	rec, err := s.Start(testJob("user_1"), "exec_1")
+	require.NoError(t, err)
+	_, err = io.ReadAll(rec.Tee(strings.NewReader(strings.Repeat("output line\n", 100))))
+	require.NoError(t, err)
+
+	info, err := rec.Close()
+	require.NoError(t, err)
+	assert.True(t, info.Truncated)
+	assert.Less(t, info.Size, int64(600))
+
+	_, events := readCast(t, info.Path)
+	assert.Contains(t, events[len(events)-1][2], "recording truncated")
+}
+
+func TestTenantGate(t *testing.T) {
+	s := newTestStore(t, config.RecordingConfig{Tenants: []string{"regulated"}})
+	assert.True(t, s.Enabled(testJob("regulated")))
+	assert.False(t, s.Enabled(testJob("other")))
+
+	var disabled *Store
+	assert.False(t, disabled.Enabled(testJob("regulated")))
+
+	var rec *Recorder
+	rec.Input("ignored")
+	info, err := rec.Close()
+	assert.NoError(t, err)
+	assert.Nil(t, info)
+}
+
+func TestPruneDeletesExpiredRecordings(t *testing.T) {
+	s := newTestStore(t, config.RecordingConfig{Retention: time.Hour})
+
+	old := filepath.Join(s.cfg.Dir, "exec_old.cast")
+	recent := filepath.Join(s.cfg.Dir, "exec_recent.cast")
+	other := filepath.Join(s.cfg.Dir, "notes.txt")
+	for _, path := range []string{old, recent, other} {
+		require.NoError(t, os.WriteFile(path, nil, 0o400))
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(old, past, past))
+	require.NoError(t, os.Chtimes(other, past, past))
+
+	require.NoError(t, s.Prune(time.Now()))
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+	assert.FileExists(t, other)
+}
@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostsPath is the hosts file consulted before the nameservers
+const hostsPath = "/etc/hosts"
+
+// hostsFile holds the entries of a hosts file and reloads them when the file
+// changes, so lookups see edits without a restart
+type hostsFile struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	names   map[string][]string
+}
+
+// lookup returns the addresses the hosts file lists for name
+func (h *hostsFile) lookup(name string) []string {
+	info, err := os.Stat(h.path)
+	if err != nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.names == nil || !info.ModTime().Equal(h.modTime) || info.Size() != h.size {
+		h.names = readHosts(h.path)
+		h.modTime = info.ModTime()
+		h.size = info.Size()
+	}
+	return h.names[name]
+}
+
+// readHosts parses a hosts file into addresses by normalized name
+func readHosts(path string) map[string][]string {
+	names := make(map[string][]string)
+	file, err := os.Open(path)
+	if err != nil {
+		return names
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		addr, _, _ := strings.Cut(fields[0], "%")
+		if net.ParseIP(addr) == nil {
+			continue
+		}
+		for _, host := range fields[1:] {
+			name := normalize(host)
+			names[name] = append(names[name], fields[0])
+		}
+	}
+	return names
+}
@@ -0,0 +1,32 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostsFileLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(path, []byte(`# static entries
+127.0.0.1	localhost
+10.0.0.5	db.internal db  # primary
+fe80::1%eth0	link.internal
+not-an-ip	broken.internal
+`), 0o644))
+
+	h := &hostsFile{path: path}
+	assert.Equal(t, []string{"10.0.0.5"}, h.lookup("db.internal"))
+	assert.Equal(t, []string{"10.0.0.5"}, h.lookup("db"))
+	assert.Equal(t, []string{"fe80::1%eth0"}, h.lookup("link.internal"))
+	assert.Empty(t, h.lookup("broken.internal"))
+	assert.Empty(t, h.lookup("primary"))
+
+	// Edits are picked up without a restart
+	require.NoError(t, os.WriteFile(path, []byte("10.0.0.9 db.internal DB.Example.\n"), 0o644))
+	assert.Equal(t, []string{"10.0.0.9"}, h.lookup("db.internal"))
+	assert.Equal(t, []string{"10.0.0.9"}, h.lookup("db.example"))
+}
@@ -0,0 +1,195 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxUDPSize is the largest response read over UDP
+const maxUDPSize = 1232
+
+// errTruncated means the answer did not fit in a UDP response; the system
+// resolver handles those names instead
+var errTruncated = errors.New("DNS response truncated")
+
+// query resolves A and AAAA records for name against the nameservers in turn
+// and returns the addresses with the lowest TTL among the answers
+func (r *Resolver) query(ctx context.Context, name string) ([]string, time.Duration, error) {
+	fqdn, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var lastErr error
+	for _, server := range r.nameservers {
+		addrs, ttl, err := r.queryServer(ctx, server, fqdn)
+		if err == nil {
+			return addrs, ttl, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, 0, lastErr
+}
+
+// queryServer asks one nameserver for both address families
+func (r *Resolver) queryServer(ctx context.Context, server string, name dnsmessage.Name) ([]string, time.Duration, error) {
+	var addrs []string
+	var ttl time.Duration
+	found := false
+
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answer, answerTTL, err := r.exchange(ctx, server, name, qtype)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(answer) == 0 {
+			continue
+		}
+		addrs = append(addrs, answer...)
+		if !found || answerTTL < ttl {
+			ttl = answerTTL
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, 0, notFoundError(name.String(), server)
+	}
+	return addrs, ttl, nil
+}
+
+// exchange sends a single question over UDP and reads the answer records
+func (r *Resolver) exchange(ctx context.Context, server string, name dnsmessage.Name, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	id := uint16(rand.Uint32())
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, 0, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, 0, err
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	conn, err := r.dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(msg); err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, maxUDPSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil || header.ID != id || !header.Response {
+			// Not the answer to our question; keep waiting
+			continue
+		}
+		if header.Truncated {
+			return nil, 0, errTruncated
+		}
+		switch header.RCode {
+		case dnsmessage.RCodeSuccess:
+		case dnsmessage.RCodeNameError:
+			return nil, 0, notFoundError(name.String(), server)
+		default:
+			return nil, 0, fmt.Errorf("DNS server %s answered %s", server, header.RCode)
+		}
+
+		if err := parser.SkipAllQuestions(); err != nil {
+			return nil, 0, err
+		}
+		return readAnswers(&parser, qtype)
+	}
+}
+
+// readAnswers collects the addresses of qtype and the lowest TTL in the
+// answer section, including any CNAME records leading to them
+func readAnswers(parser *dnsmessage.Parser, qtype dnsmessage.Type) ([]string, time.Duration, error) {
+	var addrs []string
+	var minTTL uint32
+	first := true
+
+	for {
+		header, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if first || header.TTL < minTTL {
+			minTTL = header.TTL
+			first = false
+		}
+
+		switch {
+		case header.Type == dnsmessage.TypeA && qtype == dnsmessage.TypeA:
+			res, err := parser.AResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			addrs = append(addrs, net.IP(res.A[:]).String())
+		case header.Type == dnsmessage.TypeAAAA && qtype == dnsmessage.TypeAAAA:
+			res, err := parser.AAAAResource()
+			if err != nil {
+				return nil, 0, err
+			}
+			addrs = append(addrs, net.IP(res.AAAA[:]).String())
+		default:
+			if err := parser.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	return addrs, time.Duration(minTTL) * time.Second, nil
+}
+
+// systemNameservers reads the nameservers from /etc/resolv.conf
+func systemNameservers() []string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var servers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
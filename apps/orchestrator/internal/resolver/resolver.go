@@ -0,0 +1,290 @@
+// Package resolver provides a caching DNS resolver for SSH targets and API
+// hosts. Bursts of connections to the same host share one lookup, answers are
+// kept for their record TTL, and a recently expired answer is reused when the
+// upstream resolver is having trouble.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Lookup results reported to metrics
+const (
+	resultStatic      = "static"
+	resultHit         = "hit"
+	resultNegativeHit = "negative_hit"
+	resultResolved    = "resolved"
+	resultNotFound    = "not_found"
+	resultStale       = "stale"
+	resultError       = "error"
+)
+
+// sweepThreshold is the cache size above which expired entries are dropped
+const sweepThreshold = 1024
+
+// MetricsRecorder receives resolver metrics
+type MetricsRecorder interface {
+	RecordDNSLookup(result string)
+	RecordDNSLatency(source string, duration float64)
+}
+
+// entry is a cached answer or failure
+type entry struct {
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+// Resolver resolves host names through a TTL-respecting cache. A nil
+// Resolver falls back to the system resolver without caching.
+type Resolver struct {
+	config      config.DNSConfig
+	log         *logrus.Logger
+	nameservers []string
+	static      map[string]string
+	hosts       *hostsFile
+	system      *net.Resolver
+	dialer      net.Dialer
+	metrics     MetricsRecorder
+
+	mu    sync.Mutex
+	cache map[string]*entry
+	group singleflight.Group
+}
+
+// NewResolver creates a caching resolver. It returns nil when the resolver is
+// disabled.
+func NewResolver(cfg config.DNSConfig, log *logrus.Logger) *Resolver {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	nameservers := append([]string(nil), cfg.Nameservers...)
+	if len(nameservers) == 0 {
+		nameservers = systemNameservers()
+	}
+	for i, ns := range nameservers {
+		if _, _, err := net.SplitHostPort(ns); err != nil {
+			nameservers[i] = net.JoinHostPort(ns, "53")
+		}
+	}
+
+	static := make(map[string]string, len(cfg.Static))
+	for host, ip := range cfg.Static {
+		static[normalize(host)] = ip
+	}
+
+	return &Resolver{
+		config:      cfg,
+		log:         log,
+		nameservers: nameservers,
+		static:      static,
+		hosts:       &hostsFile{path: hostsPath},
+		system:      net.DefaultResolver,
+		cache:       make(map[string]*entry),
+	}
+}
+
+// WithMetrics records lookup results and upstream latency
+func (r *Resolver) WithMetrics(recorder MetricsRecorder) {
+	if r == nil {
+		return
+	}
+	r.metrics = recorder
+}
+
+// LookupHost returns the addresses for host, from the cache when possible
+func (r *Resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if r == nil {
+		return net.DefaultResolver.LookupHost(ctx, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+
+	name := normalize(host)
+	if ip, ok := r.static[name]; ok {
+		r.record(resultStatic)
+		return []string{ip}, nil
+	}
+
+	now := time.Now()
+	r.mu.Lock()
+	cached := r.cache[name]
+	r.mu.Unlock()
+	if cached != nil && now.Before(cached.expires) {
+		if cached.err != nil {
+			r.record(resultNegativeHit)
+			return nil, cached.err
+		}
+		r.record(resultHit)
+		return cached.addrs, nil
+	}
+
+	// Concurrent lookups for the same name share one upstream query
+	result, err, _ := r.group.Do(name, func() (interface{}, error) {
+		return r.resolve(ctx, name, cached)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// DialContext resolves the host in address through the cache and connects to
+// the first address that accepts. It has the signature of
+// net.Dialer.DialContext so it can back HTTP transports.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if r == nil {
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := r.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// resolve looks name up upstream and caches the outcome. previous is the
+// expired entry for name, if any, and is served when the upstream fails.
+func (r *Resolver) resolve(ctx context.Context, name string, previous *entry) ([]string, error) {
+	addrs, ttl, err := r.lookup(ctx, name)
+	now := time.Now()
+
+	if err == nil {
+		r.store(name, &entry{addrs: addrs, expires: now.Add(ttl)})
+		r.record(resultResolved)
+		return addrs, nil
+	}
+
+	var dnsErr *net.DNSError
+	notFound := errors.As(err, &dnsErr) && dnsErr.IsNotFound
+	if notFound {
+		if r.config.NegativeTTL > 0 {
+			r.store(name, &entry{err: err, expires: now.Add(r.config.NegativeTTL)})
+		}
+		r.record(resultNotFound)
+		return nil, err
+	}
+
+	// The resolver is failing rather than saying the name is gone, so keep
+	// using a recent answer for a while
+	if previous != nil && previous.err == nil && now.Before(previous.expires.Add(r.config.StaleFor)) {
+		r.log.WithError(err).WithField("host", name).Debug("DNS lookup failed, serving stale answer")
+		r.record(resultStale)
+		return previous.addrs, nil
+	}
+
+	r.record(resultError)
+	return nil, err
+}
+
+// lookup checks the hosts file first, as the system resolver does, then
+// queries the nameservers directly so the record TTL is known, and falls back
+// to the system resolver for anything they cannot answer, such as names that
+// need search domains
+func (r *Resolver) lookup(ctx context.Context, name string) ([]string, time.Duration, error) {
+	if addrs := r.hosts.lookup(name); len(addrs) > 0 {
+		return addrs, r.config.DefaultTTL, nil
+	}
+
+	if len(r.nameservers) > 0 && strings.Contains(name, ".") {
+		start := time.Now()
+		addrs, ttl, err := r.query(ctx, name)
+		r.observe("direct", time.Since(start))
+		if err == nil {
+			return addrs, r.clamp(ttl), nil
+		}
+		r.log.WithError(err).WithField("host", name).Debug("Direct DNS query failed, using system resolver")
+	}
+
+	start := time.Now()
+	addrs, err := r.system.LookupHost(ctx, name)
+	r.observe("system", time.Since(start))
+	if err != nil {
+		return nil, 0, err
+	}
+	return addrs, r.config.DefaultTTL, nil
+}
+
+// store caches an entry, dropping expired entries once the cache grows
+func (r *Resolver) store(name string, e *entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cache[name] = e
+	if len(r.cache) <= sweepThreshold {
+		return
+	}
+	cutoff := time.Now().Add(-r.config.StaleFor)
+	for key, cached := range r.cache {
+		if cached.expires.Before(cutoff) {
+			delete(r.cache, key)
+		}
+	}
+}
+
+// clamp keeps a record TTL within the configured bounds
+func (r *Resolver) clamp(ttl time.Duration) time.Duration {
+	if ttl < r.config.MinTTL {
+		return r.config.MinTTL
+	}
+	if r.config.MaxTTL > 0 && ttl > r.config.MaxTTL {
+		return r.config.MaxTTL
+	}
+	return ttl
+}
+
+func (r *Resolver) record(result string) {
+	if r.metrics != nil {
+		r.metrics.RecordDNSLookup(result)
+	}
+}
+
+func (r *Resolver) observe(source string, duration time.Duration) {
+	if r.metrics != nil {
+		r.metrics.RecordDNSLatency(source, duration.Seconds())
+	}
+}
+
+// normalize lower-cases a host name and strips the trailing dot
+func normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// notFoundError builds the error returned for names that do not exist
+func notFoundError(name, server string) error {
+	return &net.DNSError{
+		Err:        "no such host",
+		Name:       name,
+		Server:     server,
+		IsNotFound: true,
+	}
+}
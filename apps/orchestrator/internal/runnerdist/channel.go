@@ -0,0 +1,86 @@
+package runnerdist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// channelManifest is a channel's <channel>.json. Artifact URLs may be
+// relative to the manifest.
+type channelManifest struct {
+	Channel  string    `json:"channel"`
+	Latest   string    `json:"latest"`
+	Releases []Release `json:"releases"`
+}
+
+// channelSource reads releases from a channel manifest served over HTTP
+type channelSource struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func (s *channelSource) release(ctx context.Context, version string) (*Release, error) {
+	body, err := s.get(ctx, s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var manifest channelManifest
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("invalid channel manifest: %w", err)
+	}
+	if version == "" {
+		version = manifest.Latest
+		if version == "" && len(manifest.Releases) > 0 {
+			version = manifest.Releases[0].Version
+		}
+	}
+
+	base, _ := url.Parse(s.url)
+	for _, release := range manifest.Releases {
+		if release.Version != version {
+			continue
+		}
+		for arch, artifact := range release.Artifacts {
+			ref, err := url.Parse(artifact.URL)
+			if err != nil {
+				return nil, fmt.Errorf("invalid URL for %s build: %w", arch, err)
+			}
+			artifact.URL = base.ResolveReference(ref).String()
+			release.Artifacts[arch] = artifact
+		}
+		return &release, nil
+	}
+	return nil, fmt.Errorf("release %q is not in the channel", version)
+}
+
+func (s *channelSource) open(ctx context.Context, artifact Artifact) (io.ReadCloser, error) {
+	return s.get(ctx, artifact.URL)
+}
+
+func (s *channelSource) get(ctx context.Context, rawURL string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Credentials only go to the manifest's host, not to a CDN it links to
+	if manifest, _ := url.Parse(s.url); s.username != "" && manifest.Host == req.URL.Host {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,227 @@
+package runnerdist
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+)
+
+// Manifest media types accepted from the registry, and the annotations
+// runner images carry
+const (
+	acceptIndex = "application/vnd.oci.image.index.v1+json, application/vnd.docker.distribution.manifest.list.v2+json"
+	acceptImage = "application/vnd.oci.image.manifest.v1+json, application/vnd.docker.distribution.manifest.v2+json"
+
+	versionAnnotation   = "org.opencontainers.image.version"
+	signatureAnnotation = "dev.cronium.runner.signature"
+
+	maxManifestSize = 4 << 20
+)
+
+// challengeParam matches the key="value" pairs of an auth challenge
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		OS           string `json:"os"`
+		Architecture string `json:"architecture"`
+	} `json:"platform,omitempty"`
+}
+
+// ociManifest is an image index or an image manifest
+type ociManifest struct {
+	MediaType   string            `json:"mediaType"`
+	Manifests   []ociDescriptor   `json:"manifests,omitempty"`
+	Layers      []ociDescriptor   `json:"layers,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// registrySource reads releases from an OCI registry. A release is an image
+// index tagged with its version, and the channel name tags the latest one.
+// Each platform's image holds the runner binary as its only layer, signed
+// through the dev.cronium.runner.signature annotation.
+type registrySource struct {
+	// https://<registry>/v2/<repository>
+	base     string
+	channel  string
+	username string
+	password string
+	client   *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+func newRegistrySource(u *url.URL, cfg config.RunnerReleaseConfig, client *http.Client) *registrySource {
+	return &registrySource{
+		base:     "https://" + u.Host + "/v2/" + strings.Trim(u.Path, "/"),
+		channel:  cfg.Channel,
+		username: cfg.Username,
+		password: cfg.Password,
+		client:   client,
+	}
+}
+
+func (s *registrySource) release(ctx context.Context, version string) (*Release, error) {
+	tag := version
+	if tag == "" {
+		tag = s.channel
+	}
+	var index ociManifest
+	if err := s.getJSON(ctx, s.base+"/manifests/"+url.PathEscape(tag), acceptIndex, &index); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		if version = index.Annotations[versionAnnotation]; version == "" {
+			return nil, fmt.Errorf("the %s tag has no %s annotation", tag, versionAnnotation)
+		}
+	}
+
+	release := &Release{Version: version, Artifacts: make(map[string]Artifact)}
+	for _, desc := range index.Manifests {
+		if desc.Platform == nil {
+			continue
+		}
+		var image ociManifest
+		if err := s.getJSON(ctx, s.base+"/manifests/"+desc.Digest, acceptImage, &image); err != nil {
+			return nil, err
+		}
+		if len(image.Layers) != 1 {
+			return nil, fmt.Errorf("image %s has %d layers, expected the runner binary only", desc.Digest, len(image.Layers))
+		}
+		layer := image.Layers[0]
+		sum, ok := strings.CutPrefix(layer.Digest, "sha256:")
+		if !ok {
+			return nil, fmt.Errorf("unsupported layer digest %q", layer.Digest)
+		}
+		signature := layer.Annotations[signatureAnnotation]
+		if signature == "" {
+			signature = image.Annotations[signatureAnnotation]
+		}
+		arch := desc.Platform.OS + "-" + desc.Platform.Architecture
+		release.Artifacts[arch] = Artifact{URL: s.base + "/blobs/" + layer.Digest, SHA256: sum, Signature: signature}
+	}
+	return release, nil
+}
+
+func (s *registrySource) open(ctx context.Context, artifact Artifact) (io.ReadCloser, error) {
+	return s.get(ctx, artifact.URL, "")
+}
+
+func (s *registrySource) getJSON(ctx context.Context, rawURL, accept string, v interface{}) error {
+	body, err := s.get(ctx, rawURL, accept)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(io.LimitReader(body, maxManifestSize)).Decode(v); err != nil {
+		return fmt.Errorf("invalid manifest from %s: %w", rawURL, err)
+	}
+	return nil
+}
+
+// get requests a registry URL, fetching a bearer token when the registry
+// challenges for one
+func (s *registrySource) get(ctx context.Context, rawURL, accept string) (io.ReadCloser, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		s.mu.Lock()
+		token := s.token
+		s.mu.Unlock()
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if s.username != "" {
+			req.SetBasicAuth(s.username, s.password)
+		}
+
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp.Body, nil
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return nil, fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+		}
+		if err := s.authenticate(ctx, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// authenticate answers a registry's Bearer challenge with a token from its
+// auth service
+func (s *registrySource) authenticate(ctx context.Context, challenge string) error {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		if s.username == "" {
+			return fmt.Errorf("registry requires credentials")
+		}
+		return fmt.Errorf("registry rejected the credentials")
+	}
+	values := make(map[string]string)
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	realm, err := url.Parse(values["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("registry challenge has no token realm")
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if values[key] != "" {
+			query.Set(key, values[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch registry token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch registry token: %s", resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("invalid registry token response: %w", err)
+	}
+	s.mu.Lock()
+	s.token = token.Token
+	if s.token == "" {
+		s.token = token.AccessToken
+	}
+	s.mu.Unlock()
+	return nil
+}
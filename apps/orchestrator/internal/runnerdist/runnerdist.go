@@ -0,0 +1,521 @@
+// Package runnerdist downloads signed cronium-runner releases from a release
+// channel into the runner artifacts directory. Builds are laid out as
+// <dir>/<version>/cronium-runner-<arch> with a .sha256 file next to each
+// binary, the same way the bundled build is, so the SSH executor deploys
+// downloaded and bundled runners alike.
+package runnerdist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultArch is the architecture used when a server's can't be detected
+	DefaultArch = "linux-amd64"
+
+	binaryPrefix = "cronium-runner-"
+	// releaseFile marks a version directory as downloaded, so pruning never
+	// touches the bundled build
+	releaseFile     = "release.json"
+	maxArtifactSize = 512 << 20
+)
+
+// ArtifactsDir returns the directory holding the runner builds
+func ArtifactsDir() string {
+	if dir := os.Getenv("RUNNER_ARTIFACTS_DIR"); dir != "" {
+		return dir
+	}
+	return "/app/artifacts/runners"
+}
+
+// ArtifactPath returns the local path of a runner build for an architecture
+func ArtifactPath(version, arch string) string {
+	return filepath.Join(ArtifactsDir(), version, binaryPrefix+arch)
+}
+
+// Release is a runner version and its binaries by architecture
+type Release struct {
+	Version   string              `json:"version"`
+	Artifacts map[string]Artifact `json:"artifacts"`
+}
+
+// Artifact is the runner binary of a release for one architecture
+type Artifact struct {
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256"`
+	// Base64 Ed25519 signature of the binary
+	Signature string `json:"signature,omitempty"`
+}
+
+// source reads releases from a release channel
+type source interface {
+	// release returns a release by version; an empty version returns the
+	// channel's latest release
+	release(ctx context.Context, version string) (*Release, error)
+	open(ctx context.Context, artifact Artifact) (io.ReadCloser, error)
+}
+
+// Installed is a runner version present in the artifacts directory
+type Installed struct {
+	Version       string    `json:"version"`
+	Architectures []string  `json:"architectures"`
+	Downloaded    bool      `json:"downloaded"`
+	InstalledAt   time.Time `json:"installedAt,omitempty"`
+}
+
+// releaseMarker is the content of a downloaded version's release.json
+type releaseMarker struct {
+	Version     string    `json:"version"`
+	Channel     string    `json:"channel"`
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// Distributor keeps the runner releases the SSH executor needs installed
+type Distributor struct {
+	cfg    config.RunnerReleaseConfig
+	dir    string
+	source source
+	keys   []ed25519.PublicKey
+	// Pinned, grouped and candidate versions
+	wanted []string
+	group  singleflight.Group
+	log    *logrus.Logger
+
+	mu     sync.RWMutex
+	latest string
+}
+
+// New creates the distributor for the rollout config's releases. It returns
+// nil when releases are disabled; a nil Distributor installs nothing.
+func New(cfg config.RunnerRolloutConfig, log *logrus.Logger) (*Distributor, error) {
+	if !cfg.Releases.Enabled {
+		return nil, nil
+	}
+	return newDistributor(cfg, &http.Client{Timeout: cfg.Releases.Timeout}, log)
+}
+
+func newDistributor(cfg config.RunnerRolloutConfig, client *http.Client, log *logrus.Logger) (*Distributor, error) {
+	rc := cfg.Releases
+	src, err := newSource(rc, client)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Distributor{cfg: rc, dir: ArtifactsDir(), source: src, log: log}
+	for _, key := range rc.PublicKeys {
+		raw, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid runner release public key %q", key)
+		}
+		d.keys = append(d.keys, ed25519.PublicKey(raw))
+	}
+	if len(d.keys) == 0 {
+		if !rc.InsecureSkipVerify {
+			return nil, fmt.Errorf("runner releases require public keys unless insecureSkipVerify is set")
+		}
+		log.Warn("No runner release public keys configured, only checksums are verified")
+	}
+
+	seen := make(map[string]bool)
+	want := func(version string) {
+		if version != "" && !seen[version] {
+			seen[version] = true
+			d.wanted = append(d.wanted, version)
+		}
+	}
+	want(cfg.Candidate)
+	for _, version := range cfg.Pins {
+		want(version)
+	}
+	for _, group := range cfg.Groups {
+		want(group.Version)
+	}
+	sort.Strings(d.wanted)
+
+	// The latest release survives restarts, so servers don't fall back to the
+	// bundled runner while the channel is unreachable
+	if data, err := os.ReadFile(d.latestFile()); err == nil {
+		d.latest = strings.TrimSpace(string(data))
+	}
+	return d, nil
+}
+
+// newSource picks the channel reader for the release URL
+func newSource(cfg config.RunnerReleaseConfig, client *http.Client) (source, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid runner release URL: %w", err)
+	}
+	switch u.Scheme {
+	case "oci":
+		return newRegistrySource(u, cfg, client), nil
+	case "http", "https":
+		return &channelSource{
+			url:      strings.TrimSuffix(cfg.URL, "/") + "/" + url.PathEscape(cfg.Channel) + ".json",
+			username: cfg.Username,
+			password: cfg.Password,
+			client:   client,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported runner release URL scheme %q", u.Scheme)
+}
+
+func (d *Distributor) latestFile() string {
+	return filepath.Join(d.dir, "."+d.cfg.Channel+".latest")
+}
+
+// Latest returns the channel's latest release once it is installed for every
+// architecture
+func (d *Distributor) Latest() string {
+	if d == nil {
+		return ""
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.latest
+}
+
+// DefaultVersion returns the version servers without a pin or rollout run:
+// the channel's latest release with auto-update on, otherwise fallback
+func (d *Distributor) DefaultVersion(fallback string) string {
+	if d == nil || !d.cfg.AutoUpdate {
+		return fallback
+	}
+	if latest := d.Latest(); latest != "" {
+		return latest
+	}
+	return fallback
+}
+
+func (d *Distributor) setLatest(version string) {
+	d.mu.Lock()
+	previous := d.latest
+	d.latest = version
+	d.mu.Unlock()
+
+	if err := os.WriteFile(d.latestFile(), []byte(version+"\n"), 0o644); err != nil {
+		d.log.WithError(err).Warn("Failed to record latest runner release")
+	}
+	d.log.WithFields(logrus.Fields{
+		"channel":    d.cfg.Channel,
+		"version":    version,
+		"previous":   previous,
+		"autoUpdate": d.cfg.AutoUpdate,
+	}).Info("New runner release available")
+}
+
+// Run syncs the releases every check interval until ctx is done
+func (d *Distributor) Run(ctx context.Context) {
+	if d == nil {
+		return
+	}
+	ticker := time.NewTicker(d.cfg.CheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := d.Sync(ctx); err != nil && ctx.Err() == nil {
+			d.log.WithError(err).Warn("Failed to sync runner releases")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync installs the channel's latest release and the wanted versions for
+// every configured architecture, then prunes old downloads. A new latest
+// release is only taken up once all its architectures are installed.
+func (d *Distributor) Sync(ctx context.Context) error {
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		return err
+	}
+	latest, err := d.source.release(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to read the %s channel: %w", d.cfg.Channel, err)
+	}
+
+	var errs []error
+	if err := d.installRelease(ctx, latest); err != nil {
+		errs = append(errs, err)
+	} else if d.Latest() != latest.Version {
+		d.setLatest(latest.Version)
+	}
+	for _, version := range d.wanted {
+		if version == latest.Version || d.installed(version) {
+			continue
+		}
+		release, err := d.source.release(ctx, version)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read runner release %s: %w", version, err))
+			continue
+		}
+		if err := d.installRelease(ctx, release); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := d.prune(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Ensure installs a version's build for one architecture if it is missing,
+// for servers whose version or architecture the last sync didn't cover
+func (d *Distributor) Ensure(ctx context.Context, version, arch string) error {
+	if d == nil {
+		return fmt.Errorf("runner releases are disabled")
+	}
+	if _, err := os.Stat(d.path(version, arch)); err == nil {
+		return nil
+	}
+	release, err := d.source.release(ctx, version)
+	if err != nil {
+		return fmt.Errorf("failed to read runner release %s: %w", version, err)
+	}
+	artifact, ok := release.Artifacts[arch]
+	if !ok {
+		return fmt.Errorf("runner release %s has no %s build", version, arch)
+	}
+	return d.install(ctx, version, arch, artifact)
+}
+
+// installed reports whether a version is installed for every architecture
+func (d *Distributor) installed(version string) bool {
+	for _, arch := range d.cfg.Architectures {
+		if _, err := os.Stat(d.path(version, arch)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Distributor) installRelease(ctx context.Context, release *Release) error {
+	var errs []error
+	for _, arch := range d.cfg.Architectures {
+		artifact, ok := release.Artifacts[arch]
+		if !ok {
+			errs = append(errs, fmt.Errorf("runner release %s has no %s build", release.Version, arch))
+			continue
+		}
+		if err := d.install(ctx, release.Version, arch, artifact); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Distributor) path(version, arch string) string {
+	return filepath.Join(d.dir, version, binaryPrefix+arch)
+}
+
+// install downloads and verifies a build unless it is already present.
+// Concurrent deploys needing the same build share one download.
+func (d *Distributor) install(ctx context.Context, version, arch string, artifact Artifact) error {
+	path := d.path(version, arch)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	_, err, _ := d.group.Do(path, func() (interface{}, error) {
+		if _, err := os.Stat(path); err == nil {
+			return nil, nil
+		}
+		return nil, d.download(ctx, version, arch, artifact, path)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to install runner %s (%s): %w", version, arch, err)
+	}
+	return nil
+}
+
+// download fetches a build next to its final path and moves it there once
+// its checksum and signature check out
+func (d *Distributor) download(ctx context.Context, version, arch string, artifact Artifact, path string) error {
+	if artifact.SHA256 == "" {
+		return fmt.Errorf("release lists no checksum")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	body, err := d.source.open(ctx, artifact)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(body, maxArtifactSize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if n > maxArtifactSize {
+		return fmt.Errorf("binary exceeds %d bytes", maxArtifactSize)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(sum, artifact.SHA256) {
+		return fmt.Errorf("checksum mismatch: got %s, release lists %s", sum, artifact.SHA256)
+	}
+	if err := d.verify(tmp.Name(), artifact.Signature); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".sha256", []byte(sum+"  "+filepath.Base(path)+"\n"), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	d.markDownloaded(version)
+
+	d.log.WithFields(logrus.Fields{
+		"version": version,
+		"arch":    arch,
+		"sha256":  sum,
+		"signed":  len(d.keys) > 0,
+	}).Info("Installed runner release")
+	return nil
+}
+
+// verify checks a downloaded binary's signature against the trusted keys.
+// Only a distributor created with InsecureSkipVerify has none.
+func (d *Distributor) verify(path, signature string) error {
+	if len(d.keys) == 0 && d.cfg.InsecureSkipVerify {
+		return nil
+	}
+	if signature == "" {
+		return fmt.Errorf("release is not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for _, key := range d.keys {
+		if ed25519.Verify(key, data, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature does not match any trusted key")
+}
+
+func (d *Distributor) markDownloaded(version string) {
+	path := filepath.Join(d.dir, version, releaseFile)
+	if _, err := os.Stat(path); err == nil {
+		return
+	}
+	data, _ := json.Marshal(releaseMarker{Version: version, Channel: d.cfg.Channel, InstalledAt: time.Now().UTC()})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		d.log.WithError(err).Warn("Failed to mark runner release as downloaded")
+	}
+}
+
+// prune removes downloaded versions beyond the newest Keep. The latest
+// release, wanted versions and the bundled build are never removed.
+func (d *Distributor) prune() error {
+	installed, err := ListInstalled(d.dir)
+	if err != nil {
+		return err
+	}
+	protected := map[string]bool{d.Latest(): true, os.Getenv("RUNNER_VERSION"): true}
+	for _, version := range d.wanted {
+		protected[version] = true
+	}
+
+	var downloads []Installed
+	for _, v := range installed {
+		if v.Downloaded && !protected[v.Version] {
+			downloads = append(downloads, v)
+		}
+	}
+	sort.Slice(downloads, func(i, j int) bool {
+		return downloads[i].InstalledAt.After(downloads[j].InstalledAt)
+	})
+	for i, v := range downloads {
+		if i < d.cfg.Keep {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(d.dir, v.Version)); err != nil {
+			return err
+		}
+		d.log.WithField("version", v.Version).Info("Removed old runner release")
+	}
+	return nil
+}
+
+// ListInstalled returns the runner versions in an artifacts directory
+func ListInstalled(dir string) ([]Installed, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var installed []Installed
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		v := Installed{Version: entry.Name()}
+		for _, f := range files {
+			arch, ok := strings.CutPrefix(f.Name(), binaryPrefix)
+			if ok && !strings.HasSuffix(arch, ".sha256") {
+				v.Architectures = append(v.Architectures, arch)
+			}
+		}
+		if len(v.Architectures) == 0 {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, entry.Name(), releaseFile)); err == nil {
+			var marker releaseMarker
+			if json.Unmarshal(data, &marker) == nil {
+				v.Downloaded = true
+				v.InstalledAt = marker.InstalledAt
+			}
+		}
+		installed = append(installed, v)
+	}
+	return installed, nil
+}
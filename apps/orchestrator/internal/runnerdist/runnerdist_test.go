@@ -0,0 +1,201 @@
+package runnerdist
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testLogger() *logrus.Logger {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	return log
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestSyncChannel(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("RUNNER_ARTIFACTS_DIR", dir)
+	t.Setenv("RUNNER_VERSION", "1.0.0")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	binaries := map[string][]byte{
+		"1.4.0/linux-amd64": []byte("runner 1.4.0 amd64"),
+		"1.4.0/linux-arm64": []byte("runner 1.4.0 arm64"),
+		"1.3.0/linux-amd64": []byte("runner 1.3.0 amd64"),
+		"1.3.0/linux-arm64": []byte("runner 1.3.0 arm64"),
+	}
+	artifact := func(version, arch string) Artifact {
+		data := binaries[version+"/"+arch]
+		return Artifact{
+			URL:       version + "/cronium-runner-" + arch,
+			SHA256:    sha256Hex(data),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)),
+		}
+	}
+	manifest := channelManifest{Channel: "stable", Latest: "1.4.0"}
+	for _, version := range []string{"1.4.0", "1.3.0"} {
+		manifest.Releases = append(manifest.Releases, Release{Version: version, Artifacts: map[string]Artifact{
+			"linux-amd64": artifact(version, "linux-amd64"),
+			"linux-arm64": artifact(version, "linux-arm64"),
+		}})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/runner/stable.json" {
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+		for key, data := range binaries {
+			if r.URL.Path == "/runner/"+filepath.Dir(key)+"/cronium-runner-"+filepath.Base(key) {
+				w.Write(data)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	// An old download and the bundled build sit in the directory already
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.2.0"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.2.0", "cronium-runner-linux-amd64"), []byte("old"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.2.0", releaseFile), []byte(`{"version":"1.2.0"}`), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "1.0.0"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.0.0", "cronium-runner-linux-amd64"), []byte("bundled"), 0o755))
+
+	cfg := config.RunnerRolloutConfig{
+		Pins: map[string]string{"build-01": "1.3.0"},
+		Releases: config.RunnerReleaseConfig{
+			Enabled:       true,
+			URL:           srv.URL + "/runner/",
+			Channel:       "stable",
+			PublicKeys:    []string{base64.StdEncoding.EncodeToString(pub)},
+			Architectures: []string{"linux-amd64", "linux-arm64"},
+			CheckInterval: time.Hour,
+			AutoUpdate:    true,
+		},
+	}
+	d, err := newDistributor(cfg, srv.Client(), testLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", d.DefaultVersion("1.0.0"))
+
+	require.NoError(t, d.Sync(context.Background()))
+	assert.Equal(t, "1.4.0", d.Latest())
+	assert.Equal(t, "1.4.0", d.DefaultVersion("1.0.0"))
+	for key, data := range binaries {
+		path := filepath.Join(dir, filepath.Dir(key), "cronium-runner-"+filepath.Base(key))
+		got, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, data, got)
+		checksum, err := os.ReadFile(path + ".sha256")
+		require.NoError(t, err)
+		assert.Contains(t, string(checksum), sha256Hex(data))
+	}
+
+	// Keep is zero, so the old download goes but the bundled build stays
+	assert.NoDirExists(t, filepath.Join(dir, "1.2.0"))
+	installed, err := ListInstalled(dir)
+	require.NoError(t, err)
+	require.Len(t, installed, 3)
+	assert.Equal(t, Installed{Version: "1.0.0", Architectures: []string{"linux-amd64"}}, installed[0])
+	assert.True(t, installed[2].Downloaded)
+
+	// The latest release is remembered across restarts
+	restarted, err := newDistributor(cfg, srv.Client(), testLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "1.4.0", restarted.Latest())
+
+	// A release whose binary wasn't signed by a trusted key is refused
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	binaries["1.5.0/linux-amd64"] = []byte("runner 1.5.0 amd64")
+	bad := artifact("1.5.0", "linux-amd64")
+	bad.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(other, binaries["1.5.0/linux-amd64"]))
+	manifest.Latest = "1.5.0"
+	manifest.Releases = append(manifest.Releases, Release{Version: "1.5.0", Artifacts: map[string]Artifact{"linux-amd64": bad}})
+
+	err = d.Ensure(context.Background(), "1.5.0", "linux-amd64")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signature")
+	assert.NoFileExists(t, filepath.Join(dir, "1.5.0", "cronium-runner-linux-amd64"))
+	require.Error(t, d.Sync(context.Background()))
+	assert.Equal(t, "1.4.0", d.Latest())
+}
+
+func TestRegistryRelease(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("RUNNER_ARTIFACTS_DIR", dir)
+	binary := []byte("runner 2.0.0 arm64")
+	digest := "sha256:" + sha256Hex(binary)
+
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			assert.Equal(t, "repository:cronium/runner:pull", r.URL.Query().Get("scope"))
+			json.NewEncoder(w).Encode(map[string]string{"token": "t0ken"})
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+srv.URL+`/token",service="registry",scope="repository:cronium/runner:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/cronium/runner/manifests/2.0.0":
+			w.Write([]byte(`{"manifests":[{"digest":"sha256:img","platform":{"os":"linux","architecture":"arm64"}}]}`))
+		case "/v2/cronium/runner/manifests/sha256:img":
+			w.Write([]byte(`{"layers":[{"digest":"` + digest + `"}]}`))
+		case "/v2/cronium/runner/blobs/" + digest:
+			w.Write(binary)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	d, err := newDistributor(config.RunnerRolloutConfig{Releases: config.RunnerReleaseConfig{
+		Enabled:       true,
+		URL:           "oci://" + srv.Listener.Addr().String() + "/cronium/runner",
+		Channel:       "stable",
+		Architectures: []string{"linux-arm64"},
+	}}, srv.Client(), testLogger())
+	assert.ErrorContains(t, err, "require public keys")
+
+	d, err = newDistributor(config.RunnerRolloutConfig{Releases: config.RunnerReleaseConfig{
+		Enabled:            true,
+		URL:                "oci://" + srv.Listener.Addr().String() + "/cronium/runner",
+		Channel:            "stable",
+		InsecureSkipVerify: true,
+		Architectures:      []string{"linux-arm64"},
+	}}, srv.Client(), testLogger())
+	require.NoError(t, err)
+
+	require.NoError(t, d.Ensure(context.Background(), "2.0.0", "linux-arm64"))
+	got, err := os.ReadFile(ArtifactPath("2.0.0", "linux-arm64"))
+	require.NoError(t, err)
+	assert.Equal(t, binary, got)
+
+	err = d.Ensure(context.Background(), "2.0.0", "linux-amd64")
+	assert.ErrorContains(t, err, "no linux-amd64 build")
+}
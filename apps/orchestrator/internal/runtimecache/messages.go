@@ -0,0 +1,85 @@
+package runtimecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Message types the runtime pushes to scripts
+const (
+	MessageCancel   = "cancel"
+	MessageOperator = "message"
+)
+
+// Message mirrors the runtime's push message
+type Message struct {
+	Type string `json:"type"`
+	// Set on operator messages, which may arrive both through the channel
+	// and the messages file
+	ID        string    `json:"id,omitempty"`
+	JobID     string    `json:"jobId,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Data      any       `json:"data,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Delivery tells how an operator message reached a job
+type Delivery struct {
+	ID string `json:"id"`
+	// Whether it was written to the job's messages file
+	File bool `json:"file"`
+	// Message channels that received it
+	Channels int64 `json:"channels"`
+}
+
+// Messenger publishes messages to the scripts of running jobs. Runtime
+// sidecars sit on the jobs' isolated networks, so messages go through the
+// runtime's Valkey, where every runtime serving the job is subscribed.
+type Messenger struct {
+	client *redis.Client
+	log    *logrus.Logger
+}
+
+// NewMessenger creates a messenger for the runtime's Valkey instance
+func NewMessenger(cfg config.RuntimeConfig, log *logrus.Logger) (*Messenger, error) {
+	opt, err := redis.ParseURL(redisURL(cfg.ValkeyURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Valkey URL: %w", err)
+	}
+	return &Messenger{client: redis.NewClient(opt), log: log}, nil
+}
+
+// Send publishes a message to a job's scripts and returns how many message
+// channels received it. Messages are not stored, so scripts that are not
+// connected miss them. A nil Messenger delivers nothing.
+func (m *Messenger) Send(ctx context.Context, jobID string, msg Message) (int64, error) {
+	if m == nil {
+		return 0, nil
+	}
+	msg.JobID = jobID
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode message: %w", err)
+	}
+	// Same channel name as the runtime's cache.JobMessageChannel
+	return m.client.Publish(ctx, "messages:job:"+jobID, payload).Result()
+}
+
+// Close releases the Valkey connection
+func (m *Messenger) Close() error {
+	if m == nil {
+		return nil
+	}
+	return m.client.Close()
+}
@@ -0,0 +1,164 @@
+package runtimecache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// The structs below mirror the runtime service's cached types so that entries
+// written here are read back as cache hits.
+
+type executionContext struct {
+	ExecutionID string         `json:"executionId"`
+	EventID     string         `json:"eventId"`
+	EventName   string         `json:"eventName"`
+	EventType   string         `json:"eventType"`
+	UserID      string         `json:"userId"`
+	StartTime   time.Time      `json:"startTime"`
+	Metadata    map[string]any `json:"metadata"`
+}
+
+type inputData struct {
+	Data      any       `json:"data"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type variable struct {
+	Key       string    `json:"key"`
+	Value     any       `json:"value"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Prewarmer pushes execution data into the runtime cache before a job starts
+// so the first helper calls do not have to wait for the backend
+type Prewarmer struct {
+	client *redis.Client
+	ttl    time.Duration
+	log    *logrus.Logger
+}
+
+// NewPrewarmer creates a prewarmer for the runtime's Valkey instance
+func NewPrewarmer(cfg config.RuntimeConfig, log *logrus.Logger) (*Prewarmer, error) {
+	opt, err := redis.ParseURL(redisURL(cfg.ValkeyURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Valkey URL: %w", err)
+	}
+
+	return &Prewarmer{
+		client: redis.NewClient(opt),
+		ttl:    cfg.PrewarmTTL,
+		log:    log,
+	}, nil
+}
+
+// redisURL rewrites valkey:// URLs, which are redis:// compatible
+func redisURL(url string) string {
+	if strings.HasPrefix(url, "valkey://") {
+		return "redis://" + strings.TrimPrefix(url, "valkey://")
+	}
+	return url
+}
+
+// Prewarm caches the execution context, input data and job variables for an
+// execution. Helpers fall back to the backend for anything that is missing,
+// so callers should treat errors as non-fatal.
+func (p *Prewarmer) Prewarm(ctx context.Context, executionID string, job *types.Job) error {
+	if p == nil {
+		return nil
+	}
+
+	entries := cacheEntries(executionID, job, time.Now())
+	pipe := p.client.Pipeline()
+	for key, value := range entries {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", key, err)
+		}
+		pipe.Set(ctx, key, data, p.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to write runtime cache: %w", err)
+	}
+
+	p.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"entries":     len(entries),
+	}).Debug("Pre-warmed runtime cache")
+
+	return nil
+}
+
+// cacheEntries returns the runtime cache entries for an execution by key.
+// Sensitive variables are left out: the runtime caches them only sealed
+// with the execution's data key, which the orchestrator does not hold.
+// Without the backend's list of sensitive variables no variable is
+// included, as any of them may be sensitive.
+func cacheEntries(executionID string, job *types.Job, now time.Time) map[string]any {
+	metadata := make(map[string]any, len(job.Metadata)+4)
+	for k, v := range job.Metadata {
+		metadata[k] = v
+	}
+	if job.Execution.InputData != nil {
+		metadata["input"] = job.Execution.InputData
+	}
+	metadata["attempt"] = job.Attempt()
+	metadata["maxAttempts"] = job.MaxAttempts()
+	metadata["idempotencyKey"] = job.IdempotencyKey()
+
+	execCtx := executionContext{
+		ExecutionID: executionID,
+		EventID:     metadataString(job.Metadata, "eventId"),
+		EventName:   metadataString(job.Metadata, "eventName"),
+		EventType:   metadataString(job.Metadata, "eventType"),
+		UserID:      metadataString(job.Metadata, "userId"),
+		StartTime:   now,
+		Metadata:    metadata,
+	}
+
+	entries := map[string]any{
+		"context:" + executionID: execCtx,
+		"input:" + executionID:   inputData{Data: job.Execution.InputData, Timestamp: now},
+	}
+	if job.Execution.SensitiveVariables == nil {
+		return entries
+	}
+	sensitive := make(map[string]bool, len(job.Execution.SensitiveVariables))
+	for _, key := range job.Execution.SensitiveVariables {
+		sensitive[key] = true
+	}
+	for key, value := range job.Execution.Variables {
+		if sensitive[key] {
+			continue
+		}
+		entries["variable:"+executionID+":"+key] = variable{Key: key, Value: value, UpdatedAt: now}
+	}
+	return entries
+}
+
+// Close closes the Valkey connection
+func (p *Prewarmer) Close() error {
+	if p == nil {
+		return nil
+	}
+	return p.client.Close()
+}
+
+// metadataString returns a metadata value formatted as a string
+func metadataString(metadata map[string]any, key string) string {
+	v, ok := metadata[key]
+	if !ok || v == nil {
+		return ""
+	}
+	if f, ok := v.(float64); ok {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%v", v)
+}
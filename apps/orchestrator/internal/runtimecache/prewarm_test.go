@@ -0,0 +1,31 @@
+package runtimecache
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheEntriesLeaveOutSensitiveVariables(t *testing.T) {
+	job := &types.Job{ID: "job-1"}
+	job.Execution.Variables = map[string]any{"region": "eu", "db_password": "hunter2"}
+
+	// Without the backend's list any variable may be sensitive
+	entries := cacheEntries("exec-1", job, time.Now())
+	assert.NotContains(t, entries, "variable:exec-1:region")
+	assert.NotContains(t, entries, "variable:exec-1:db_password")
+	assert.Contains(t, entries, "context:exec-1")
+
+	job.Execution.SensitiveVariables = []string{"db_password"}
+	entries = cacheEntries("exec-1", job, time.Now())
+	assert.Contains(t, entries, "variable:exec-1:region")
+	assert.NotContains(t, entries, "variable:exec-1:db_password")
+
+	data, err := json.Marshal(entries)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "hunter2")
+}
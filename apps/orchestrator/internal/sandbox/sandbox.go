@@ -0,0 +1,199 @@
+// Package sandbox resolves the sandbox profile a container job runs under. A
+// profile bundles capabilities, seccomp, filesystem, network and resource
+// ceiling settings; jobs pick one by name, and tenants may only use the
+// profiles an administrator allowed for them.
+package sandbox
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/errors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+)
+
+// Built-in profile names
+const (
+	Strict   = "strict"
+	Standard = "standard"
+	Trusted  = "trusted"
+)
+
+// Profile is a resolved sandbox profile
+type Profile struct {
+	Name             string   `json:"name"`
+	DropCapabilities []string `json:"dropCapabilities,omitempty"`
+	AddCapabilities  []string `json:"addCapabilities,omitempty"`
+	SeccompProfile   string   `json:"seccompProfile,omitempty"`
+	NoNewPrivileges  bool     `json:"noNewPrivileges"`
+	ReadOnlyRootfs   bool     `json:"readOnlyRootfs"`
+	IsolateNetwork   bool     `json:"isolateNetwork"`
+	Egress           []string `json:"egress,omitempty"`
+	MaxCPU           float64  `json:"maxCpu,omitempty"`
+	MaxMemory        string   `json:"maxMemory,omitempty"`
+	MaxPids          int64    `json:"maxPids,omitempty"`
+}
+
+// Catalog holds the available profiles and which tenants may use them
+type Catalog struct {
+	profiles       map[string]*Profile
+	defaultProfile string
+	allowed        []string
+	tenants        map[string][]string
+	egressEnforced bool
+}
+
+// NewCatalog builds the profile catalog from the built-in profiles and the
+// configured ones, which replace built-ins of the same name
+func NewCatalog(cfg config.ContainerConfig) (*Catalog, error) {
+	c := &Catalog{
+		profiles:       builtins(cfg),
+		defaultProfile: cfg.Sandbox.DefaultProfile,
+		allowed:        cfg.Sandbox.AllowedProfiles,
+		tenants:        cfg.Sandbox.TenantProfiles,
+		egressEnforced: cfg.Sandbox.EgressEnforced,
+	}
+	if c.defaultProfile == "" {
+		c.defaultProfile = Standard
+	}
+
+	for name, p := range cfg.Sandbox.Profiles {
+		c.profiles[name] = &Profile{
+			Name:             name,
+			DropCapabilities: p.DropCapabilities,
+			AddCapabilities:  p.AddCapabilities,
+			SeccompProfile:   p.SeccompProfile,
+			NoNewPrivileges:  p.NoNewPrivileges,
+			ReadOnlyRootfs:   p.ReadOnlyRootfs,
+			IsolateNetwork:   p.IsolateNetwork,
+			Egress:           p.Egress,
+			MaxCPU:           p.MaxResources.CPU,
+			MaxMemory:        p.MaxResources.Memory,
+			MaxPids:          p.MaxResources.Pids,
+		}
+	}
+
+	if p, ok := c.profiles[c.defaultProfile]; !ok {
+		return nil, fmt.Errorf("default sandbox profile %q is not defined", c.defaultProfile)
+	} else if len(p.Egress) > 0 && !c.egressEnforced {
+		return nil, fmt.Errorf("default sandbox profile %q has egress rules but egressEnforced is off", c.defaultProfile)
+	}
+	for _, name := range c.allowed {
+		if _, ok := c.profiles[name]; !ok {
+			return nil, fmt.Errorf("allowed sandbox profile %q is not defined", name)
+		}
+	}
+	for tenant, names := range c.tenants {
+		for _, name := range names {
+			if _, ok := c.profiles[name]; !ok {
+				return nil, fmt.Errorf("sandbox profile %q allowed for tenant %s is not defined", name, tenant)
+			}
+		}
+	}
+	return c, nil
+}
+
+// builtins returns the strict, standard and trusted profiles. Standard keeps
+// the container security settings, so jobs that do not pick a profile run as
+// they did before profiles existed.
+func builtins(cfg config.ContainerConfig) map[string]*Profile {
+	return map[string]*Profile{
+		Strict: {
+			Name:             Strict,
+			DropCapabilities: []string{"ALL"},
+			SeccompProfile:   cfg.Security.SeccompProfile,
+			NoNewPrivileges:  true,
+			ReadOnlyRootfs:   true,
+			IsolateNetwork:   true,
+			MaxCPU:           0.5,
+			MaxMemory:        "256MB",
+			MaxPids:          64,
+		},
+		Standard: {
+			Name:             Standard,
+			DropCapabilities: cfg.Security.DropCapabilities,
+			SeccompProfile:   cfg.Security.SeccompProfile,
+			NoNewPrivileges:  cfg.Security.NoNewPrivileges,
+			ReadOnlyRootfs:   cfg.Security.ReadOnlyRootfs,
+			IsolateNetwork:   cfg.Runtime.IsolateNetwork,
+			MaxCPU:           cfg.Resources.Limits.CPU,
+			MaxMemory:        cfg.Resources.Limits.Memory,
+			MaxPids:          cfg.Resources.Limits.Pids,
+		},
+		Trusted: {
+			Name:           Trusted,
+			SeccompProfile: cfg.Security.SeccompProfile,
+			MaxCPU:         cfg.Resources.Limits.CPU,
+			MaxMemory:      cfg.Resources.Limits.Memory,
+			MaxPids:        cfg.Resources.Limits.Pids,
+		},
+	}
+}
+
+// Resolve returns the profile a job runs under, rejecting unknown profiles,
+// profiles whose egress rules nothing enforces and profiles the job's tenant
+// may not use
+func (c *Catalog) Resolve(job *types.Job) (*Profile, error) {
+	name := job.Execution.SandboxProfile
+	if name == "" {
+		name = c.defaultProfile
+	}
+
+	profile, ok := c.profiles[name]
+	if !ok {
+		return nil, errors.NewValidationError(
+			"sandboxProfile",
+			"exists",
+			fmt.Sprintf("unknown sandbox profile %q", name),
+		)
+	}
+
+	// Egress rules are only a network label; running the job without the
+	// firewall that applies it would leave its network unrestricted
+	if len(profile.Egress) > 0 && !c.egressEnforced {
+		return nil, errors.NewValidationError(
+			"sandboxProfile",
+			"egress",
+			fmt.Sprintf("sandbox profile %q has egress rules but no host firewall enforces them", name),
+		)
+	}
+
+	tenant, _ := job.Metadata["userId"].(string)
+	if !c.Allowed(tenant, name) {
+		return nil, errors.NewValidationError(
+			"sandboxProfile",
+			"allowed",
+			fmt.Sprintf("sandbox profile %q is not allowed for this tenant", name),
+		)
+	}
+	return profile, nil
+}
+
+// Allowed reports whether a tenant may use a profile. The default profile is
+// always allowed.
+func (c *Catalog) Allowed(tenant, name string) bool {
+	if name == c.defaultProfile {
+		return true
+	}
+	allowed, ok := c.tenants[tenant]
+	if !ok {
+		allowed = c.allowed
+	}
+	for _, n := range allowed {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Profiles returns every profile, sorted by name
+func (c *Catalog) Profiles() []*Profile {
+	profiles := make([]*Profile, 0, len(c.profiles))
+	for _, p := range c.profiles {
+		profiles = append(profiles, p)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles
+}
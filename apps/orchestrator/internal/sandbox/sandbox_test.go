@@ -0,0 +1,31 @@
+package sandbox
+
+import (
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRejectsUnenforcedEgress(t *testing.T) {
+	cfg := config.ContainerConfig{}
+	cfg.Sandbox.AllowedProfiles = []string{"limited"}
+	cfg.Sandbox.Profiles = map[string]config.SandboxProfile{
+		"limited": {Egress: []string{"10.0.0.0/8"}},
+	}
+	job := &types.Job{Execution: types.ExecutionConfig{SandboxProfile: "limited"}}
+
+	catalog, err := NewCatalog(cfg)
+	require.NoError(t, err)
+	_, err = catalog.Resolve(job)
+	assert.ErrorContains(t, err, "no host firewall enforces them")
+
+	cfg.Sandbox.EgressEnforced = true
+	catalog, err = NewCatalog(cfg)
+	require.NoError(t, err)
+	profile, err := catalog.Resolve(job)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8"}, profile.Egress)
+}
@@ -0,0 +1,324 @@
+package selftest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Stage names, in the order they are reported
+const (
+	StageConnect     = "connect"
+	StageStart       = "start"
+	StagePayload     = "payload"
+	StageDeploy      = "deploy"
+	StageLogs        = "logs"
+	StageSetVariable = "set_variable"
+	StageGetVariable = "get_variable"
+	StageOutput      = "output"
+	StageExit        = "exit"
+	StageCleanup     = "cleanup"
+)
+
+// Status is the outcome of a stage
+type Status string
+
+const (
+	StatusPass    Status = "pass"
+	StatusFail    Status = "fail"
+	StatusSkipped Status = "skipped"
+)
+
+// marker prefixes the lines the self-test script prints for each check
+const marker = "CRONIUM_SELFTEST"
+
+// StageResult is the outcome of one stage
+type StageResult struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Report is the structured result of a self-test run
+type Report struct {
+	Target    string        `json:"target"`
+	JobID     string        `json:"jobId"`
+	StartedAt time.Time     `json:"startedAt"`
+	Duration  time.Duration `json:"duration"`
+	Passed    bool          `json:"passed"`
+	Stages    []StageResult `json:"stages"`
+
+	stageIndex map[string]int
+}
+
+// Options controls which stages a self-test covers
+type Options struct {
+	// Helpers runs the set/get variable and output round trips
+	Helpers bool
+	// SetupStages are reported before the script runs, in execution order
+	SetupStages []string
+	// Timeout bounds the whole run
+	Timeout time.Duration
+}
+
+// NewJob builds a diagnostic job for the given target
+func NewJob(jobType types.JobType, target types.Target, helpers bool) (*types.Job, string, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	jobID := "selftest-" + token
+	return &types.Job{
+		ID:        jobID,
+		Type:      jobType,
+		CreatedAt: time.Now(),
+		Execution: types.ExecutionConfig{
+			Target: target,
+			Script: &types.Script{
+				Type:    types.ScriptTypeBash,
+				Content: Script(token, helpers),
+			},
+			Environment: map[string]string{},
+			Timeout:     2 * time.Minute,
+		},
+		Metadata: map[string]any{
+			"selftest": true,
+		},
+	}, token, nil
+}
+
+// Script returns the bash script that exercises logging and, optionally,
+// the runtime helpers. Each check prints a marker line with its result.
+func Script(token string, helpers bool) string {
+	var b strings.Builder
+	b.WriteString("#!/bin/bash\n")
+	fmt.Fprintf(&b, "echo \"%s %s stdout\"\n", marker, StageLogs)
+	fmt.Fprintf(&b, "echo \"%s %s stderr\" >&2\n", marker, StageLogs)
+
+	if helpers {
+		fmt.Fprintf(&b, `if cronium.setVariable selftest_token %[2]q; then echo "%[1]s %[3]s pass"; else echo "%[1]s %[3]s fail"; fi
+value=$(cronium.getVariable selftest_token 2>&1)
+if [ "$value" = '"%[2]s"' ]; then echo "%[1]s %[4]s pass"; else echo "%[1]s %[4]s fail got $value"; fi
+if echo '{"selftest":"%[2]s"}' | cronium.output; then echo "%[1]s %[5]s pass"; else echo "%[1]s %[5]s fail"; fi
+`, marker, token, StageSetVariable, StageGetVariable, StageOutput)
+	}
+
+	b.WriteString("exit 0\n")
+	return b.String()
+}
+
+// Run executes a diagnostic job and reports the result of every stage
+func Run(ctx context.Context, executor executors.Executor, job *types.Job, target string, opts Options, log *logrus.Logger) *Report {
+	report := newReport(target, job.ID, opts)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	defer func() {
+		// Cleanup runs even when execution failed part way
+		cleanupCtx, cleanupCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cleanupCancel()
+		if err := executor.Cleanup(cleanupCtx, job); err != nil {
+			report.set(StageCleanup, StatusFail, err.Error())
+		} else {
+			report.set(StageCleanup, StatusPass, "")
+		}
+		report.finish()
+	}()
+
+	if err := executor.Validate(job); err != nil {
+		report.failSetup(opts.SetupStages, 0, err.Error())
+		return report
+	}
+
+	updates, err := executor.Execute(ctx, job)
+	if err != nil {
+		report.failSetup(opts.SetupStages, 0, err.Error())
+		return report
+	}
+
+	var sawStdout, sawStderr, setupDone, setupFailed bool
+	for update := range updates {
+		switch update.Type {
+		case types.UpdateTypeLog:
+			entry, ok := update.Data.(*types.LogEntry)
+			if !ok {
+				continue
+			}
+			if entry.Stream == "system" {
+				// The executor reports the runner version once setup succeeded
+				if !setupDone {
+					report.passSetup(opts.SetupStages)
+					setupDone = true
+				}
+				continue
+			}
+			if !setupDone {
+				report.passSetup(opts.SetupStages)
+				setupDone = true
+			}
+
+			stage, result, detail, ok := parseMarker(entry.Line)
+			if !ok {
+				log.WithField("stream", entry.Stream).Debug(entry.Line)
+				continue
+			}
+			if stage == StageLogs {
+				sawStdout = sawStdout || (entry.Stream == "stdout" && result == "stdout")
+				sawStderr = sawStderr || (entry.Stream == "stderr" && result == "stderr")
+				continue
+			}
+			report.set(stage, Status(result), detail)
+
+		case types.UpdateTypeError:
+			status, ok := update.Data.(*types.StatusUpdate)
+			if !ok || status.Status != types.JobStatusFailed {
+				continue
+			}
+			if !setupDone {
+				report.failSetup(opts.SetupStages, setupStageFor(opts.SetupStages, status.Message), status.Message)
+				setupDone = true
+				setupFailed = true
+			}
+
+		case types.UpdateTypeComplete:
+			status, ok := update.Data.(*types.StatusUpdate)
+			if !ok || setupFailed {
+				continue
+			}
+			if status.ExitCode != nil && *status.ExitCode == 0 && status.Status == types.JobStatusCompleted {
+				report.set(StageExit, StatusPass, "")
+			} else {
+				report.set(StageExit, StatusFail, status.Message)
+			}
+		}
+	}
+
+	if setupDone && !setupFailed {
+		switch {
+		case sawStdout && sawStderr:
+			report.set(StageLogs, StatusPass, "")
+		case sawStdout:
+			report.set(StageLogs, StatusFail, "stderr line not received")
+		case sawStderr:
+			report.set(StageLogs, StatusFail, "stdout line not received")
+		default:
+			report.set(StageLogs, StatusFail, "no script output received")
+		}
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		report.set(StageExit, StatusFail, "self-test timed out")
+	}
+
+	return report
+}
+
+// newReport creates a report with every stage initially skipped
+func newReport(target, jobID string, opts Options) *Report {
+	stages := append([]string{}, opts.SetupStages...)
+	stages = append(stages, StageLogs)
+	if opts.Helpers {
+		stages = append(stages, StageSetVariable, StageGetVariable, StageOutput)
+	}
+	stages = append(stages, StageExit, StageCleanup)
+
+	report := &Report{
+		Target:     target,
+		JobID:      jobID,
+		StartedAt:  time.Now(),
+		Stages:     make([]StageResult, len(stages)),
+		stageIndex: make(map[string]int, len(stages)),
+	}
+	for i, name := range stages {
+		report.Stages[i] = StageResult{Name: name, Status: StatusSkipped}
+		report.stageIndex[name] = i
+	}
+	return report
+}
+
+// set records the result of a stage
+func (r *Report) set(stage string, status Status, message string) {
+	i, ok := r.stageIndex[stage]
+	if !ok {
+		return
+	}
+	if status != StatusPass && status != StatusFail {
+		status = StatusFail
+	}
+	r.Stages[i].Status = status
+	r.Stages[i].Message = message
+}
+
+// passSetup marks every setup stage as passed
+func (r *Report) passSetup(stages []string) {
+	for _, stage := range stages {
+		r.set(stage, StatusPass, "")
+	}
+}
+
+// failSetup marks the setup stages before failed as passed and failed itself
+// as failed; the rest stay skipped
+func (r *Report) failSetup(stages []string, failed int, message string) {
+	for i, stage := range stages {
+		if i < failed {
+			r.set(stage, StatusPass, "")
+		} else if i == failed {
+			r.set(stage, StatusFail, message)
+		}
+	}
+}
+
+// finish computes the overall result
+func (r *Report) finish() {
+	r.Duration = time.Since(r.StartedAt)
+	r.Passed = true
+	for _, stage := range r.Stages {
+		if stage.Status != StatusPass {
+			r.Passed = false
+		}
+	}
+}
+
+// setupStageFor maps an executor error to the setup stage that produced it
+func setupStageFor(stages []string, message string) int {
+	keywords := map[string]string{
+		StagePayload: "payload",
+		StageDeploy:  "runner",
+	}
+	for i, stage := range stages {
+		if keyword, ok := keywords[stage]; ok && strings.Contains(message, keyword) {
+			return i
+		}
+	}
+	return 0
+}
+
+// parseMarker splits a marker line into stage, result and detail
+func parseMarker(line string) (string, string, string, bool) {
+	fields := strings.SplitN(strings.TrimSpace(line), " ", 4)
+	if len(fields) < 3 || fields[0] != marker {
+		return "", "", "", false
+	}
+	detail := ""
+	if len(fields) == 4 {
+		detail = fields[3]
+	}
+	return fields[1], fields[2], detail, true
+}
+
+// randomToken returns a short random identifier
+func randomToken() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate self-test token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,106 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// amqpDefaultPrefetch bounds the unacknowledged deliveries when the trigger
+// does not set a prefetch
+const amqpDefaultPrefetch = 10
+
+// amqpSource consumes an AMQP queue. A message is acknowledged once its job
+// is queued; one that cannot be queued is returned to the queue and the
+// source restarts.
+type amqpSource struct {
+	cfg config.AMQPTriggerConfig
+	log *logrus.Logger
+}
+
+// newAMQPSource creates an AMQP trigger source
+func newAMQPSource(cfg config.AMQPTriggerConfig, log *logrus.Logger) *amqpSource {
+	if cfg.Prefetch == 0 {
+		cfg.Prefetch = amqpDefaultPrefetch
+	}
+	return &amqpSource{cfg: cfg, log: log}
+}
+
+// Run consumes messages until ctx is cancelled or the connection fails
+func (s *amqpSource) Run(ctx context.Context, fire FireFunc) error {
+	conn, err := amqp.DialConfig(s.cfg.URL, amqp.Config{
+		Properties: amqp.Table{"connection_name": "cronium-orchestrator"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to AMQP broker: %w", err)
+	}
+	defer conn.Close()
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer ch.Close()
+
+	if err := ch.Qos(s.cfg.Prefetch, 0, false); err != nil {
+		return fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	if s.cfg.Exchange != "" {
+		if _, err := ch.QueueDeclare(s.cfg.Queue, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("failed to declare queue: %w", err)
+		}
+		if err := ch.QueueBind(s.cfg.Queue, s.cfg.RoutingKey, s.cfg.Exchange, false, nil); err != nil {
+			return fmt.Errorf("failed to bind queue: %w", err)
+		}
+	}
+
+	deliveries, err := ch.ConsumeWithContext(ctx, s.cfg.Queue, "cronium-"+s.cfg.Name, false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume queue: %w", err)
+	}
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case amqpErr := <-closed:
+			return fmt.Errorf("connection lost: %v", amqpErr)
+		case d, ok := <-deliveries:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("delivery channel closed")
+			}
+
+			input := map[string]interface{}{
+				"queue":      s.cfg.Queue,
+				"exchange":   d.Exchange,
+				"routingKey": d.RoutingKey,
+				"data":       decodePayload(d.Body),
+			}
+			if d.MessageId != "" {
+				input["messageId"] = d.MessageId
+			}
+			if len(d.Headers) > 0 {
+				input["headers"] = map[string]interface{}(d.Headers)
+			}
+
+			if err := fire(ctx, input); err != nil {
+				// Back on the queue for the next consumer or restart
+				if nackErr := d.Nack(false, true); nackErr != nil {
+					s.log.WithError(nackErr).Warn("Failed to requeue AMQP message")
+				}
+				return err
+			}
+			if err := d.Ack(false); err != nil {
+				s.log.WithError(err).WithField("deliveryTag", d.DeliveryTag).Warn("Failed to acknowledge AMQP message")
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for directory triggers
+const (
+	defaultSettleTime     = 2 * time.Second
+	defaultMaxContentSize = 1024 * 1024
+)
+
+// fileSource fires for each new file in a directory. Files still being
+// written are picked up once they stop changing, and files already present
+// at startup are treated as new, so nothing dropped while the orchestrator
+// was down is missed.
+type fileSource struct {
+	cfg config.FileTriggerConfig
+	log *logrus.Logger
+}
+
+// newFileSource creates a directory trigger source
+func newFileSource(cfg config.FileTriggerConfig, log *logrus.Logger) *fileSource {
+	if cfg.SettleTime <= 0 {
+		cfg.SettleTime = defaultSettleTime
+	}
+	if cfg.MaxContentSize <= 0 {
+		cfg.MaxContentSize = defaultMaxContentSize
+	}
+	return &fileSource{cfg: cfg, log: log}
+}
+
+// Run watches the directory until ctx is cancelled
+func (s *fileSource) Run(ctx context.Context, fire FireFunc) error {
+	if s.cfg.ProcessedDir != "" {
+		if err := os.MkdirAll(s.cfg.ProcessedDir, 0o750); err != nil {
+			return fmt.Errorf("failed to create processed directory: %w", err)
+		}
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(s.cfg.Dir); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", s.cfg.Dir, err)
+	}
+
+	// Last change seen per file, picked up once it is older than SettleTime
+	pending := make(map[string]time.Time)
+
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.cfg.Dir, err)
+	}
+	for _, entry := range entries {
+		if s.matches(entry.Name()) {
+			pending[filepath.Join(s.cfg.Dir, entry.Name())] = time.Time{}
+		}
+	}
+
+	ticker := time.NewTicker(s.cfg.SettleTime / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			if !s.matches(filepath.Base(event.Name)) {
+				continue
+			}
+			switch {
+			case event.Has(fsnotify.Create), event.Has(fsnotify.Write):
+				pending[event.Name] = time.Now()
+			case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+				delete(pending, event.Name)
+			}
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return fmt.Errorf("watcher closed")
+			}
+			return fmt.Errorf("watcher failed: %w", err)
+
+		case <-ticker.C:
+			for path, changed := range pending {
+				if time.Since(changed) < s.cfg.SettleTime {
+					continue
+				}
+				if err := s.process(ctx, path, fire); err != nil {
+					s.log.WithError(err).WithField("file", path).Warn("Failed to process triggered file, will retry")
+					pending[path] = time.Now()
+					continue
+				}
+				delete(pending, path)
+			}
+		}
+	}
+}
+
+// matches reports whether a file name should fire the trigger. Hidden files
+// are skipped, as writers commonly use them for partial uploads.
+func (s *fileSource) matches(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	if s.cfg.Pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(s.cfg.Pattern, name)
+	return ok
+}
+
+// process fires the trigger for a file and moves it out of the directory
+func (s *fileSource) process(ctx context.Context, path string, fire FireFunc) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	input := map[string]interface{}{
+		"file": map[string]interface{}{
+			"name":    info.Name(),
+			"path":    path,
+			"size":    info.Size(),
+			"modTime": info.ModTime().UTC().Format(time.RFC3339),
+		},
+	}
+	if s.cfg.IncludeContent {
+		if info.Size() > s.cfg.MaxContentSize {
+			input["contentOmitted"] = true
+		} else {
+			content, err := readFile(path, s.cfg.MaxContentSize)
+			if err != nil {
+				return fmt.Errorf("failed to read file: %w", err)
+			}
+			input["content"] = content
+		}
+	}
+
+	if err := fire(ctx, input); err != nil {
+		return err
+	}
+
+	// The job is queued; a file that cannot be moved away is only logged,
+	// since retrying would queue it again
+	if s.cfg.ProcessedDir != "" {
+		err = os.Rename(path, filepath.Join(s.cfg.ProcessedDir, info.Name()))
+	} else {
+		err = os.Remove(path)
+	}
+	if err != nil {
+		s.log.WithError(err).WithField("file", path).Error("Failed to clear triggered file")
+	}
+	return nil
+}
+
+// readFile reads up to limit bytes of a file
+func readFile(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, limit))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
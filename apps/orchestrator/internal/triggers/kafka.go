@@ -0,0 +1,95 @@
+package triggers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/sirupsen/logrus"
+)
+
+// kafkaDialTimeout bounds connecting to a broker
+const kafkaDialTimeout = 10 * time.Second
+
+// kafkaSource consumes a Kafka topic through a consumer group. A message's
+// offset is committed once its job is queued; a message that cannot be
+// queued is read again when the source restarts.
+type kafkaSource struct {
+	cfg config.KafkaTriggerConfig
+	log *logrus.Logger
+}
+
+// newKafkaSource creates a Kafka trigger source
+func newKafkaSource(cfg config.KafkaTriggerConfig, log *logrus.Logger) *kafkaSource {
+	return &kafkaSource{cfg: cfg, log: log}
+}
+
+// Run reads messages until ctx is cancelled or a read fails
+func (s *kafkaSource) Run(ctx context.Context, fire FireFunc) error {
+	dialer := &kafka.Dialer{
+		Timeout:   kafkaDialTimeout,
+		DualStack: true,
+		ClientID:  "cronium-orchestrator",
+	}
+	if s.cfg.TLS {
+		dialer.TLS = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	if s.cfg.User != "" {
+		dialer.SASLMechanism = plain.Mechanism{Username: s.cfg.User, Password: s.cfg.Password}
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: s.cfg.Brokers,
+		Topic:   s.cfg.Topic,
+		GroupID: s.cfg.Group,
+		Dialer:  dialer,
+		// Offsets are committed explicitly once the job is queued
+		CommitInterval: 0,
+	})
+	defer reader.Close()
+
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return fmt.Errorf("failed to read topic: %w", err)
+		}
+
+		input := map[string]interface{}{
+			"topic":     msg.Topic,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+			"data":      decodePayload(msg.Value),
+		}
+		if len(msg.Key) > 0 {
+			input["key"] = string(msg.Key)
+		}
+		if len(msg.Headers) > 0 {
+			headers := make(map[string]string, len(msg.Headers))
+			for _, h := range msg.Headers {
+				headers[h.Key] = string(h.Value)
+			}
+			input["headers"] = headers
+		}
+
+		if err := fire(ctx, input); err != nil {
+			return err
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			s.log.WithError(err).WithFields(logrus.Fields{
+				"partition": msg.Partition,
+				"offset":    msg.Offset,
+			}).Warn("Failed to commit Kafka offset")
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package triggers
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// NATS connection settings
+const (
+	natsDefaultPort  = "4222"
+	natsDialTimeout  = 10 * time.Second
+	natsMaxControl   = 4096
+	natsSubscription = "1"
+)
+
+// natsSource subscribes to a NATS subject over the core NATS protocol.
+// Core NATS delivers at most once: a message whose job cannot be queued is
+// logged and dropped.
+type natsSource struct {
+	cfg config.NATSTriggerConfig
+	log *logrus.Logger
+}
+
+// natsInfo is the part of the server's INFO message the source uses
+type natsInfo struct {
+	TLSRequired  bool `json:"tls_required"`
+	AuthRequired bool `json:"auth_required"`
+}
+
+// natsConnect is the client's CONNECT message
+type natsConnect struct {
+	Verbose   bool   `json:"verbose"`
+	Pedantic  bool   `json:"pedantic"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang"`
+	Version   string `json:"version"`
+	Protocol  int    `json:"protocol"`
+	AuthToken string `json:"auth_token,omitempty"`
+	User      string `json:"user,omitempty"`
+	Pass      string `json:"pass,omitempty"`
+}
+
+// newNATSSource creates a NATS trigger source
+func newNATSSource(cfg config.NATSTriggerConfig, log *logrus.Logger) *natsSource {
+	return &natsSource{cfg: cfg, log: log}
+}
+
+// Run subscribes and fires for each message until ctx is cancelled or the
+// connection fails
+func (s *natsSource) Run(ctx context.Context, fire FireFunc) error {
+	conn, reader, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Unblock reads on shutdown
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	write := func(format string, args ...interface{}) error {
+		_, err := fmt.Fprintf(conn, format, args...)
+		return err
+	}
+
+	if s.cfg.QueueGroup != "" {
+		err = write("SUB %s %s %s\r\n", s.cfg.Subject, s.cfg.QueueGroup, natsSubscription)
+	} else {
+		err = write("SUB %s %s\r\n", s.cfg.Subject, natsSubscription)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("connection lost: %w", err)
+		}
+
+		switch op, args, _ := strings.Cut(line, " "); strings.ToUpper(op) {
+		case "MSG":
+			subject, replyTo, payload, err := readMessage(reader, args)
+			if err != nil {
+				return err
+			}
+			input := map[string]interface{}{
+				"subject": subject,
+				"data":    decodePayload(payload),
+			}
+			if replyTo != "" {
+				input["replyTo"] = replyTo
+			}
+			if err := fire(ctx, input); err != nil && ctx.Err() == nil {
+				s.log.WithError(err).WithField("subject", subject).Error("Dropped NATS message")
+			}
+
+		case "PING":
+			if err := write("PONG\r\n"); err != nil {
+				return fmt.Errorf("failed to answer ping: %w", err)
+			}
+
+		case "-ERR":
+			return fmt.Errorf("server error: %s", args)
+
+		case "+OK", "PONG", "INFO":
+		}
+	}
+}
+
+// connect dials the server, upgrading to TLS for tls:// URLs or when the
+// server requires it, and sends CONNECT
+func (s *natsSource) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	u, err := url.Parse(s.cfg.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid NATS URL: %w", err)
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = natsDefaultPort
+	}
+
+	dialer := net.Dialer{Timeout: natsDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(natsDialTimeout))
+
+	reader := bufio.NewReader(conn)
+	line, err := readLine(reader)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to read server info: %w", err)
+	}
+	op, args, _ := strings.Cut(line, " ")
+	if op != "INFO" {
+		conn.Close()
+		return nil, nil, fmt.Errorf("unexpected greeting %q", op)
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(args), &info); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("invalid server info: %w", err)
+	}
+
+	if u.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("TLS handshake failed: %w", err)
+		}
+		conn = tlsConn
+		reader = bufio.NewReader(conn)
+	}
+
+	connect := natsConnect{
+		Name:      "cronium-orchestrator",
+		Lang:      "go",
+		Version:   "1.0.0",
+		Protocol:  0,
+		AuthToken: s.cfg.Token,
+		User:      s.cfg.User,
+		Pass:      s.cfg.Password,
+	}
+	if u.User != nil && connect.User == "" {
+		connect.User = u.User.Username()
+		connect.Pass, _ = u.User.Password()
+	}
+	data, _ := json.Marshal(connect)
+
+	// PING after CONNECT: the PONG confirms the server accepted it
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+	for {
+		line, err := readLine(reader)
+		if err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("failed to connect to NATS: %w", err)
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			conn.Close()
+			return nil, nil, fmt.Errorf("NATS rejected connection: %s", strings.TrimPrefix(line, "-ERR "))
+		}
+		if line == "PONG" {
+			break
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, reader, nil
+}
+
+// readLine reads a CRLF-terminated protocol line
+func readLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := reader.ReadLine()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, chunk...)
+		if len(line) > natsMaxControl {
+			return "", fmt.Errorf("protocol line too long")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+// readMessage reads the payload of a MSG whose arguments are
+// "<subject> <sid> [reply-to] <#bytes>"
+func readMessage(reader *bufio.Reader, args string) (string, string, []byte, error) {
+	fields := strings.Fields(args)
+	if len(fields) != 3 && len(fields) != 4 {
+		return "", "", nil, fmt.Errorf("malformed MSG %q", args)
+	}
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil || size < 0 {
+		return "", "", nil, fmt.Errorf("malformed MSG size %q", fields[len(fields)-1])
+	}
+	replyTo := ""
+	if len(fields) == 4 {
+		replyTo = fields[2]
+	}
+
+	payload := make([]byte, size+2) // payload and trailing CRLF
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return "", "", nil, fmt.Errorf("failed to read message: %w", err)
+	}
+	return fields[0], replyTo, payload[:size], nil
+}
+
+// decodePayload returns a JSON payload decoded, or anything else as a string
+func decodePayload(payload []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err == nil {
+		return v
+	}
+	return string(payload)
+}
@@ -0,0 +1,258 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/schedule"
+	"github.com/sirupsen/logrus"
+)
+
+// Catch-up policies for runs missed while the orchestrator was down
+const (
+	CatchUpNone = "none"
+	CatchUpOnce = "once"
+	CatchUpAll  = "all"
+)
+
+// defaultMaxCatchUp bounds the runs caught up by the "all" policy
+const defaultMaxCatchUp = 10
+
+// scheduleSource fires on a cron schedule. The last run is kept in a state
+// file, so on startup the runs missed since then are known and caught up
+// according to the trigger's policy.
+type scheduleSource struct {
+	cfg      config.ScheduleTriggerConfig
+	schedule *schedule.Schedule
+	loc      *time.Location
+	state    *scheduleState
+	log      *logrus.Logger
+	now      func() time.Time
+}
+
+// newScheduleSource creates a cron trigger source. The configuration has
+// been validated, so the expression and time zone parse.
+func newScheduleSource(cfg config.ScheduleTriggerConfig, state *scheduleState, log *logrus.Logger) *scheduleSource {
+	if cfg.CatchUp == "" {
+		cfg.CatchUp = CatchUpNone
+	}
+	if cfg.MaxCatchUp <= 0 {
+		cfg.MaxCatchUp = defaultMaxCatchUp
+	}
+	s := &scheduleSource{cfg: cfg, state: state, log: log, loc: time.Local, now: time.Now}
+	s.schedule, _ = schedule.Parse(cfg.Cron)
+	if cfg.TZ != "" {
+		if loc, err := time.LoadLocation(cfg.TZ); err == nil {
+			s.loc = loc
+		}
+	}
+	return s
+}
+
+// Run catches up missed runs, then fires at each scheduled time until ctx is
+// cancelled
+func (s *scheduleSource) Run(ctx context.Context, fire FireFunc) error {
+	if s.schedule == nil {
+		return fmt.Errorf("invalid cron expression %q", s.cfg.Cron)
+	}
+
+	last, ok, err := s.state.lastRun(s.cfg.Name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Nothing can have been missed before the first start
+		last = s.now()
+		if err := s.state.setLastRun(s.cfg.Name, last); err != nil {
+			return err
+		}
+	}
+
+	if last, err = s.catchUp(ctx, fire, last); err != nil {
+		return err
+	}
+
+	for {
+		run, ok := s.schedule.Next(last, s.loc)
+		if !ok {
+			s.log.WithField("trigger", s.cfg.Name).Warn("Schedule never runs again")
+			<-ctx.Done()
+			return nil
+		}
+
+		timer := time.NewTimer(time.Until(run.Time))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if err := fire(ctx, s.input(run, 0)); err != nil {
+			return err
+		}
+		last = run.Time
+		if err := s.state.setLastRun(s.cfg.Name, last); err != nil {
+			return err
+		}
+	}
+}
+
+// catchUp fires the runs missed since last according to the catch-up policy
+// and returns the time runs are now recorded up to
+func (s *scheduleSource) catchUp(ctx context.Context, fire FireFunc, last time.Time) (time.Time, error) {
+	now := s.now()
+	runs, missed := s.missedRuns(last, now)
+	if missed == 0 {
+		return last, nil
+	}
+
+	log := s.log.WithFields(logrus.Fields{
+		"trigger": s.cfg.Name,
+		"missed":  missed,
+		"since":   last.Format(time.RFC3339),
+		"policy":  s.cfg.CatchUp,
+	})
+	if len(runs) == 0 {
+		log.Warn("Skipping scheduled runs missed while the orchestrator was down")
+		return now, s.state.setLastRun(s.cfg.Name, now)
+	}
+	log.WithField("runs", len(runs)).Info("Catching up scheduled runs missed while the orchestrator was down")
+
+	for _, run := range runs {
+		if err := fire(ctx, s.input(run, missed)); err != nil {
+			return last, err
+		}
+		last = run.Time
+		if err := s.state.setLastRun(s.cfg.Name, last); err != nil {
+			return last, err
+		}
+	}
+	// Runs dropped by the policy are not caught up on a later start either
+	return now, s.state.setLastRun(s.cfg.Name, now)
+}
+
+// missedRuns returns the runs after last and up to now that the catch-up
+// policy fires, and how many runs were missed in total
+func (s *scheduleSource) missedRuns(last, now time.Time) ([]schedule.Run, int) {
+	limit := 0
+	switch s.cfg.CatchUp {
+	case CatchUpOnce:
+		limit = 1
+	case CatchUpAll:
+		limit = s.cfg.MaxCatchUp
+	}
+
+	// Only the latest runs are kept
+	var runs []schedule.Run
+	missed := 0
+	for from := last; ; {
+		run, ok := s.schedule.Next(from, s.loc)
+		if !ok || run.Time.After(now) {
+			break
+		}
+		missed++
+		if limit > 0 {
+			if len(runs) == limit {
+				runs = runs[1:]
+			}
+			runs = append(runs, run)
+		}
+		from = run.Time
+	}
+	return runs, missed
+}
+
+// input is the job input for a run. Caught-up runs carry catchUp and the
+// number of runs missed.
+func (s *scheduleSource) input(run schedule.Run, missed int) map[string]interface{} {
+	info := map[string]interface{}{
+		"cron":         s.cfg.Cron,
+		"scheduledFor": run.Time.UTC().Format(time.RFC3339),
+		"catchUp":      missed > 0,
+	}
+	if missed > 0 {
+		info["missedRuns"] = missed
+		info["delay"] = s.now().Sub(run.Time).Round(time.Second).String()
+	}
+	if run.DST != schedule.DSTNone {
+		info["dst"] = string(run.DST)
+	}
+	return map[string]interface{}{"schedule": info}
+}
+
+// scheduleState records the last run of each schedule trigger in a JSON
+// file shared by all of them
+type scheduleState struct {
+	path string
+	mu   sync.Mutex
+}
+
+// newScheduleState creates the state kept in dir
+func newScheduleState(dir string) *scheduleState {
+	return &scheduleState{path: filepath.Join(dir, "schedules.json")}
+}
+
+// lastRun returns the recorded last run of a trigger
+func (st *scheduleState) lastRun(name string) (time.Time, bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	runs, err := st.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	last, ok := runs[name]
+	return last, ok, nil
+}
+
+// setLastRun records the last run of a trigger
+func (st *scheduleState) setLastRun(name string, last time.Time) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	runs, err := st.read()
+	if err != nil {
+		return err
+	}
+	runs[name] = last.UTC()
+
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(st.path), 0o750); err != nil {
+		return fmt.Errorf("failed to create trigger state directory: %w", err)
+	}
+	tmp := st.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o640); err != nil {
+		return fmt.Errorf("failed to write trigger state: %w", err)
+	}
+	if err := os.Rename(tmp, st.path); err != nil {
+		return fmt.Errorf("failed to write trigger state: %w", err)
+	}
+	return nil
+}
+
+// read loads the state file, which is empty before the first run
+func (st *scheduleState) read() (map[string]time.Time, error) {
+	runs := make(map[string]time.Time)
+	data, err := os.ReadFile(st.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return runs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trigger state: %w", err)
+	}
+	if err := json.Unmarshal(data, &runs); err != nil {
+		return nil, fmt.Errorf("failed to parse trigger state %s: %w", st.path, err)
+	}
+	return runs, nil
+}
@@ -0,0 +1,93 @@
+package triggers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testScheduleSource(t *testing.T, catchUp string, maxCatchUp int, now time.Time) *scheduleSource {
+	t.Helper()
+	s := newScheduleSource(config.ScheduleTriggerConfig{
+		Name:       "hourly",
+		EventID:    "7",
+		Cron:       "0 * * * *",
+		TZ:         "UTC",
+		CatchUp:    catchUp,
+		MaxCatchUp: maxCatchUp,
+	}, newScheduleState(t.TempDir()), logrus.New())
+	s.now = func() time.Time { return now }
+	return s
+}
+
+func TestMissedRuns(t *testing.T) {
+	last := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	now := last.Add(5*time.Hour + 30*time.Minute)
+
+	runs, missed := testScheduleSource(t, CatchUpNone, 0, now).missedRuns(last, now)
+	assert.Equal(t, 5, missed)
+	assert.Empty(t, runs)
+
+	runs, missed = testScheduleSource(t, CatchUpOnce, 0, now).missedRuns(last, now)
+	assert.Equal(t, 5, missed)
+	require.Len(t, runs, 1)
+	assert.Equal(t, last.Add(5*time.Hour), runs[0].Time)
+
+	runs, missed = testScheduleSource(t, CatchUpAll, 3, now).missedRuns(last, now)
+	assert.Equal(t, 5, missed)
+	require.Len(t, runs, 3)
+	assert.Equal(t, last.Add(3*time.Hour), runs[0].Time)
+	assert.Equal(t, last.Add(5*time.Hour), runs[2].Time)
+
+	_, missed = testScheduleSource(t, CatchUpAll, 0, now).missedRuns(now, now)
+	assert.Zero(t, missed)
+}
+
+func TestCatchUp(t *testing.T) {
+	last := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	now := last.Add(3*time.Hour + 30*time.Minute)
+	s := testScheduleSource(t, CatchUpAll, 0, now)
+
+	var inputs []map[string]interface{}
+	fail := true
+	fire := func(ctx context.Context, input map[string]interface{}) error {
+		// The second run fails to queue the first time
+		if len(inputs) == 1 && fail {
+			fail = false
+			return errors.New("backend unavailable")
+		}
+		inputs = append(inputs, input)
+		return nil
+	}
+
+	recorded, err := s.catchUp(context.Background(), fire, last)
+	require.Error(t, err)
+	assert.Equal(t, last.Add(time.Hour), recorded)
+	stored, ok, err := s.state.lastRun("hourly")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, last.Add(time.Hour), stored)
+
+	// A restart picks up where delivery stopped
+	recorded, err = s.catchUp(context.Background(), fire, stored)
+	require.NoError(t, err)
+	assert.Equal(t, now, recorded)
+	require.Len(t, inputs, 3)
+	assert.Equal(t, map[string]interface{}{
+		"cron":         "0 * * * *",
+		"scheduledFor": "2026-05-01T11:00:00Z",
+		"catchUp":      true,
+		"missedRuns":   2,
+		"delay":        "30m0s",
+	}, inputs[2]["schedule"])
+
+	stored, _, err = s.state.lastRun("hourly")
+	require.NoError(t, err)
+	assert.Equal(t, now, stored)
+}
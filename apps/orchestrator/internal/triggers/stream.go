@@ -0,0 +1,99 @@
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Stream read settings
+const (
+	streamBlock = 5 * time.Second
+	streamBatch = 10
+)
+
+// streamSource consumes a Valkey/Redis stream through a consumer group.
+// Entries are acknowledged once their job is queued; an entry that cannot be
+// queued stays pending and is read again when the source restarts.
+type streamSource struct {
+	cfg config.StreamTriggerConfig
+	log *logrus.Logger
+}
+
+// newStreamSource creates a stream trigger source
+func newStreamSource(cfg config.StreamTriggerConfig, log *logrus.Logger) *streamSource {
+	if cfg.Consumer == "" {
+		hostname, _ := os.Hostname()
+		cfg.Consumer = "cronium-" + hostname
+	}
+	return &streamSource{cfg: cfg, log: log}
+}
+
+// Run reads entries until ctx is cancelled or a read fails
+func (s *streamSource) Run(ctx context.Context, fire FireFunc) error {
+	// valkey:// URLs are redis:// compatible
+	url := s.cfg.URL
+	if strings.HasPrefix(url, "valkey://") {
+		url = "redis://" + strings.TrimPrefix(url, "valkey://")
+	}
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return fmt.Errorf("failed to parse stream URL: %w", err)
+	}
+	client := redis.NewClient(opt)
+	defer client.Close()
+
+	err = client.XGroupCreateMkStream(ctx, s.cfg.Stream, s.cfg.Group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	// Entries delivered to this consumer before a restart come first ("0"),
+	// then new ones (">")
+	start := "0"
+	for {
+		streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.cfg.Group,
+			Consumer: s.cfg.Consumer,
+			Streams:  []string{s.cfg.Stream, start},
+			Count:    streamBatch,
+			Block:    streamBlock,
+		}).Result()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		read := 0
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				read++
+				input := map[string]interface{}{
+					"stream": s.cfg.Stream,
+					"id":     msg.ID,
+					"values": msg.Values,
+				}
+				if err := fire(ctx, input); err != nil {
+					return err
+				}
+				if err := client.XAck(ctx, s.cfg.Stream, s.cfg.Group, msg.ID).Err(); err != nil {
+					s.log.WithError(err).WithField("id", msg.ID).Warn("Failed to acknowledge stream entry")
+				}
+			}
+		}
+		if start == "0" && read == 0 {
+			start = ">"
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package triggers runs local trigger sources: cron schedules, watched
+// directories, message queue subscriptions and inbound webhooks. Each trigger event queues a job for a backend
+// event with the trigger payload as the job's input data, so the orchestrator
+// can react to events without an external scheduler.
+package triggers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Trigger source kinds, reported to the backend as the job's triggeredBy
+const (
+	KindFilesystem = "filesystem"
+	KindAMQP       = "amqp"
+	KindKafka      = "kafka"
+	KindNATS       = "nats"
+	KindSchedule   = "schedule"
+	KindStream     = "stream"
+	KindWebhook    = "webhook"
+)
+
+// fireTimeout bounds a single call to the backend
+const fireTimeout = 30 * time.Second
+
+// Dispatcher queues jobs for trigger events
+type Dispatcher interface {
+	TriggerEvent(ctx context.Context, eventID string, req *api.TriggerRequest) (*api.TriggerResponse, error)
+}
+
+// FireFunc queues a job for a trigger event. A source treats an error as the
+// event not being delivered and keeps it for a later attempt where it can.
+type FireFunc func(ctx context.Context, input map[string]interface{}) error
+
+// Source produces trigger events until its context is cancelled
+type Source interface {
+	Run(ctx context.Context, fire FireFunc) error
+}
+
+// trigger is a configured source and the event it fires
+type trigger struct {
+	kind    string
+	name    string
+	eventID string
+	source  Source
+}
+
+// Manager runs the configured trigger sources, restarting failed ones
+type Manager struct {
+	dispatcher   Dispatcher
+	restartDelay time.Duration
+	log          *logrus.Logger
+	triggers     []trigger
+	webhook      *webhookServer
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewManager creates a manager for the configured triggers. It returns nil
+// when no triggers are configured; a nil manager does nothing.
+func NewManager(cfg config.TriggersConfig, dispatcher Dispatcher, log *logrus.Logger) *Manager {
+	m := &Manager{
+		dispatcher:   dispatcher,
+		restartDelay: cfg.RestartDelay,
+		log:          log,
+	}
+	if m.restartDelay <= 0 {
+		m.restartDelay = 5 * time.Second
+	}
+
+	if len(cfg.Schedules) > 0 {
+		state := newScheduleState(cfg.StateDir)
+		for _, sc := range cfg.Schedules {
+			m.triggers = append(m.triggers, trigger{KindSchedule, sc.Name, sc.EventID, newScheduleSource(sc, state, log)})
+		}
+	}
+	for _, f := range cfg.Filesystem {
+		m.triggers = append(m.triggers, trigger{KindFilesystem, f.Name, f.EventID, newFileSource(f, log)})
+	}
+	for _, n := range cfg.NATS {
+		m.triggers = append(m.triggers, trigger{KindNATS, n.Name, n.EventID, newNATSSource(n, log)})
+	}
+	for _, s := range cfg.Streams {
+		m.triggers = append(m.triggers, trigger{KindStream, s.Name, s.EventID, newStreamSource(s, log)})
+	}
+	for _, a := range cfg.AMQP {
+		m.triggers = append(m.triggers, trigger{KindAMQP, a.Name, a.EventID, newAMQPSource(a, log)})
+	}
+	for _, k := range cfg.Kafka {
+		m.triggers = append(m.triggers, trigger{KindKafka, k.Name, k.EventID, newKafkaSource(k, log)})
+	}
+
+	if cfg.Webhook.Enabled {
+		m.webhook = newWebhookServer(cfg.Webhook, m)
+	}
+
+	if len(m.triggers) == 0 && m.webhook == nil {
+		return nil
+	}
+	return m
+}
+
+// Start runs every trigger source in the background
+func (m *Manager) Start(ctx context.Context) {
+	if m == nil {
+		return
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, t := range m.triggers {
+		m.wg.Add(1)
+		go m.run(ctx, t)
+	}
+	m.log.WithField("count", len(m.triggers)).Info("Started trigger sources")
+
+	if m.webhook != nil {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.webhook.serve(ctx)
+		}()
+	}
+}
+
+// Stop stops the trigger sources and waits for them to finish
+func (m *Manager) Stop() {
+	if m == nil || m.cancel == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run runs a source until ctx is cancelled, restarting it after failures
+func (m *Manager) run(ctx context.Context, t trigger) {
+	defer m.wg.Done()
+
+	log := m.log.WithFields(logrus.Fields{
+		"trigger": t.name,
+		"kind":    t.kind,
+	})
+	fire := func(ctx context.Context, input map[string]interface{}) error {
+		_, err := m.fire(ctx, t, input)
+		return err
+	}
+
+	for {
+		err := t.source.Run(ctx, fire)
+		if ctx.Err() != nil {
+			return
+		}
+		log.WithError(err).Warn("Trigger source stopped, restarting")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.restartDelay):
+		}
+	}
+}
+
+// fire asks the backend to queue a job for a trigger event
+func (m *Manager) fire(ctx context.Context, t trigger, input map[string]interface{}) (*api.TriggerResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, fireTimeout)
+	defer cancel()
+
+	resp, err := m.dispatcher.TriggerEvent(ctx, t.eventID, &api.TriggerRequest{
+		Source:  t.kind,
+		Trigger: t.name,
+		Input:   input,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to trigger event %s: %w", t.eventID, err)
+	}
+
+	m.log.WithFields(logrus.Fields{
+		"trigger": t.name,
+		"eventID": t.eventID,
+		"jobID":   resp.JobID,
+	}).Info("Queued job for trigger")
+	return resp, nil
+}
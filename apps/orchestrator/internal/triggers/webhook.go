@@ -0,0 +1,212 @@
+package triggers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Headers carrying the signature and the signed send time unless an
+// endpoint names others
+const (
+	defaultSignatureHeader = "X-Cronium-Signature"
+	defaultTimestampHeader = "X-Cronium-Timestamp"
+)
+
+// webhookShutdownTimeout bounds how long in-flight webhooks may finish
+const webhookShutdownTimeout = 10 * time.Second
+
+// webhookServer serves POST /triggers/{name}, queuing a job for the
+// endpoint's event with the request body as input data. Requests are
+// authenticated by an HMAC-SHA256 signature of their timestamp and body;
+// stale timestamps and replayed signatures are rejected.
+type webhookServer struct {
+	cfg       config.WebhookConfig
+	manager   *Manager
+	endpoints map[string]config.WebhookTriggerConfig
+
+	mu sync.Mutex
+	// Signature -> when it stops being accepted anyway
+	seen map[string]time.Time
+}
+
+// newWebhookServer creates the webhook listener for the configured endpoints
+func newWebhookServer(cfg config.WebhookConfig, manager *Manager) *webhookServer {
+	w := &webhookServer{
+		cfg:       cfg,
+		manager:   manager,
+		endpoints: make(map[string]config.WebhookTriggerConfig, len(cfg.Endpoints)),
+		seen:      make(map[string]time.Time),
+	}
+	if w.cfg.Tolerance <= 0 {
+		w.cfg.Tolerance = 5 * time.Minute
+	}
+	for _, endpoint := range cfg.Endpoints {
+		if endpoint.SignatureHeader == "" {
+			endpoint.SignatureHeader = defaultSignatureHeader
+		}
+		if endpoint.TimestampHeader == "" {
+			endpoint.TimestampHeader = defaultTimestampHeader
+		}
+		w.endpoints[endpoint.Name] = endpoint
+	}
+	return w
+}
+
+// serve runs the listener until ctx is cancelled
+func (w *webhookServer) serve(ctx context.Context) {
+	log := w.manager.log
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /triggers/{name}", w.handleTrigger)
+
+	server := &http.Server{
+		Addr:        fmt.Sprintf(":%d", w.cfg.Port),
+		Handler:     mux,
+		ReadTimeout: 30 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), webhookShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.WithFields(logrus.Fields{
+		"port":      w.cfg.Port,
+		"endpoints": len(w.endpoints),
+	}).Info("Starting webhook trigger server")
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.WithError(err).Error("Webhook trigger server failed")
+	}
+}
+
+// handleTrigger verifies a webhook and queues its job
+func (w *webhookServer) handleTrigger(rw http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	endpoint, ok := w.endpoints[name]
+	if !ok {
+		writeJSON(rw, http.StatusNotFound, map[string]string{"error": "unknown trigger"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, w.cfg.MaxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(rw, http.StatusRequestEntityTooLarge, map[string]string{"error": "request body too large"})
+			return
+		}
+		writeJSON(rw, http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+		return
+	}
+
+	timestamp := r.Header.Get(endpoint.TimestampHeader)
+	signature := r.Header.Get(endpoint.SignatureHeader)
+	if !validSignature(endpoint.Secret, timestamp, body, signature) {
+		w.manager.log.WithField("trigger", name).Warn("Rejected webhook with invalid signature")
+		writeJSON(rw, http.StatusUnauthorized, map[string]string{"error": "invalid signature"})
+		return
+	}
+	if err := w.checkFresh(timestamp, signature, time.Now()); err != nil {
+		w.manager.log.WithError(err).WithField("trigger", name).Warn("Rejected stale webhook")
+		writeJSON(rw, http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// The query string is not signed, so it is not passed to the job
+	input := map[string]interface{}{
+		"body":    decodePayload(body),
+		"headers": webhookHeaders(r.Header, endpoint.SignatureHeader),
+	}
+
+	t := trigger{kind: KindWebhook, name: name, eventID: endpoint.EventID}
+	resp, err := w.manager.fire(r.Context(), t, input)
+	if err != nil {
+		w.manager.log.WithError(err).WithField("trigger", name).Error("Failed to queue webhook job")
+		writeJSON(rw, http.StatusBadGateway, map[string]string{"error": "failed to queue job"})
+		return
+	}
+	writeJSON(rw, http.StatusAccepted, resp)
+}
+
+// validSignature checks an HMAC-SHA256 signature of "<timestamp>.<body>"
+// given as "sha256=<hex>" or plain hex
+func validSignature(secret, timestamp string, body []byte, signature string) bool {
+	if timestamp == "" {
+		return false
+	}
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	got, err := hex.DecodeString(signature)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// checkFresh rejects a signed request whose timestamp is outside the
+// tolerance, or whose signature was already accepted. Signatures are
+// compared case-insensitively, as hex decoding is, and remembered until their timestamp leaves the tolerance, after which the
+// timestamp check rejects them.
+func (w *webhookServer) checkFresh(timestamp, signature string, now time.Time) error {
+	unix, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	sent := time.Unix(unix, 0)
+	if sent.Before(now.Add(-w.cfg.Tolerance)) || sent.After(now.Add(w.cfg.Tolerance)) {
+		return fmt.Errorf("timestamp outside tolerance")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for sig, expires := range w.seen {
+		if now.After(expires) {
+			delete(w.seen, sig)
+		}
+	}
+	key := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(signature), "sha256="))
+	if _, ok := w.seen[key]; ok {
+		return fmt.Errorf("request already delivered")
+	}
+	w.seen[key] = sent.Add(w.cfg.Tolerance)
+	return nil
+}
+
+// webhookHeaders returns the request headers passed to the job, leaving out
+// credentials and the signature
+func webhookHeaders(header http.Header, signatureHeader string) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key := range header {
+		switch strings.ToLower(key) {
+		case "authorization", "cookie", "proxy-authorization", strings.ToLower(signatureHeader):
+			continue
+		}
+		headers[key] = header.Get(key)
+	}
+	return headers
+}
+
+// writeJSON writes a JSON response
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(v)
+}
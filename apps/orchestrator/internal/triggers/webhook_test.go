@@ -0,0 +1,54 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookSignatureCoversTimestamp(t *testing.T) {
+	ts := "1760000000"
+	sig := sign("secret", ts, `{"a":1}`)
+
+	assert.True(t, validSignature("secret", ts, []byte(`{"a":1}`), sig))
+	assert.False(t, validSignature("secret", "1760000001", []byte(`{"a":1}`), sig))
+	assert.False(t, validSignature("secret", "", []byte(`{"a":1}`), sig))
+}
+
+func TestWebhookRejectsStaleAndReplayed(t *testing.T) {
+	w := newWebhookServer(config.WebhookConfig{Tolerance: 5 * time.Minute}, nil)
+	now := time.Unix(1760000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	assert.NoError(t, w.checkFresh(ts, "sha256=aa", now))
+	assert.EqualError(t, w.checkFresh(ts, "sha256=aa", now.Add(time.Minute)), "request already delivered")
+	assert.EqualError(t, w.checkFresh(ts, "sha256=bb", now.Add(6*time.Minute)), "timestamp outside tolerance")
+	assert.EqualError(t, w.checkFresh("soon", "sha256=cc", now), "invalid timestamp")
+}
+
+func TestWebhookRejectsReplayWithRecasedSignature(t *testing.T) {
+	w := newWebhookServer(config.WebhookConfig{Tolerance: 5 * time.Minute}, nil)
+	now := time.Unix(1760000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := sign("secret", ts, `{"a":1}`)
+	recased := "sha256=" + strings.ToUpper(strings.TrimPrefix(sig, "sha256="))
+
+	// Hex decoding ignores case, so both forms carry the same valid MAC
+	assert.True(t, validSignature("secret", ts, []byte(`{"a":1}`), recased))
+	assert.NoError(t, w.checkFresh(ts, sig, now))
+	assert.EqualError(t, w.checkFresh(ts, recased, now), "request already delivered")
+	assert.EqualError(t, w.checkFresh(ts, strings.TrimPrefix(recased, "sha256="), now), "request already delivered")
+}
@@ -0,0 +1,2192 @@
+// Package agent is the Cronium execution agent as a library. An Agent polls
+// the backend for jobs, runs them with the container, SSH and any custom
+// executors, streams their logs and reports the results, and recovers
+// orphaned work on startup. The cronium-orchestrator command is a thin
+// wrapper around it; other programs can embed it the same way:
+//
+//	cfg, err := agent.LoadConfig("cronium-orchestrator.yaml")
+//	if err != nil {
+//		return err
+//	}
+//	a, err := agent.New(cfg, agent.WithLogger(log), agent.WithoutContainers())
+//	if err != nil {
+//		return err
+//	}
+//	return a.Run(ctx)
+//
+// Run returns after a graceful shutdown, when ctx is cancelled or Shutdown is
+// called.
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/accounting"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/analysis"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/completion"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/deadletter"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/durations"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/container"
+	httpexec "github.com/addison-moore/cronium/apps/orchestrator/internal/executors/http"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/kubernetes"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/plugin"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors/ssh"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/export"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/features"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/fleet"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/gates"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/hooks"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/jitter"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/lineage"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/logger"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/masking"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/metrics"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/orchestrator"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/payload"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/quarantine"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/receipt"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/resolver"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runnerdist"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/runtimecache"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/sandbox"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/triggers"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/redact"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// cancellationMemory is how long a polled cancellation is remembered as
+// handled; the backend's first window is five minutes
+const cancellationMemory = 10 * time.Minute
+
+// Agent polls the backend for jobs and runs them with its executors
+type Agent struct {
+	config         *config.Config
+	log            *logrus.Logger
+	apiClient      *api.Client
+	executorMgr    *executors.Manager
+	logStreamer    *logger.Streamer
+	metrics        *metrics.Collector
+	recovery       *orchestrator.RecoveryManager
+	containerExec  *container.Executor
+	kubeExec       *kubernetes.Executor
+	fleet          *fleet.Coordinator
+	gates          *gates.Waiter
+	receipts       *receipt.Signer
+	analyzer       *analysis.Analyzer
+	masker         *masking.Masker
+	features       *features.Registry
+	triggers       *triggers.Manager
+	exporter       *export.Exporter
+	push           *api.JobPush
+	accounting     *accounting.Accountant
+	completions    *completion.Pipeline
+	payloads       *payload.Service
+	sshExec        *ssh.MultiServerExecutor
+	jitter         *jitter.Jitter
+	quarantine     *quarantine.List
+	deadLetters    *deadletter.List
+	durations      *durations.Predictor
+	lineage        *lineage.Store
+	messenger      *runtimecache.Messenger
+	runnerReleases *runnerdist.Distributor
+	hooks          *hooks.Runner
+	orchestratorID string
+
+	// Control channels
+	shutdown chan struct{}
+	done     chan struct{}
+	// Closed when drain mode starts
+	drain     chan struct{}
+	drainOnce sync.Once
+
+	// Running jobs are stopped through jobsCtx rather than Run's context
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+
+	// State
+	mu             sync.RWMutex
+	activeJobs     map[string]*types.Job
+	held           map[string]jobHold
+	isShuttingDown bool
+
+	// Stop a started job, and the reasons of jobs stopped that way
+	cancels   map[string]context.CancelFunc
+	cancelled map[string]string
+	// Runs cancelled by a newer run of their event, with the newer job's ID
+	replacedBy map[string]string
+
+	// Concurrency slots (job ID per slot, empty when free). Held jobs give
+	// their slot up; resuming counts those waiting to take one back, and
+	// slotFreed wakes them.
+	slots      []string
+	slotStarts []time.Time
+	resuming   int
+	slotFreed  chan struct{}
+
+	// Accepted jobs waiting for a slot (work stealing only)
+	pending []pendingJob
+}
+
+// jobHold is why a started job has not begun executing yet
+type jobHold struct {
+	status types.JobStatus
+	detail string
+}
+
+// pendingJob is an acknowledged job that has not started yet
+type pendingJob struct {
+	job        *types.Job
+	acceptedAt time.Time
+}
+
+// New creates an agent from a loaded configuration. Call Run to start
+// polling the backend for jobs.
+func New(cfg *Config, opts ...Option) (*Agent, error) {
+	o := &options{executors: make(map[types.JobType]Executor)}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return newAgent(cfg, o)
+}
+
+// newAgent wires the agent's components
+func newAgent(cfg *config.Config, opts *options) (*Agent, error) {
+	log := opts.log
+	if log == nil {
+		log = logger.New()
+		logger.Configure(log, cfg.Logging)
+	}
+	if opts.noContainers && cfg.Jobs.Analysis.Enabled {
+		return nil, fmt.Errorf("script analysis requires the container executor")
+	}
+
+	// Create API client
+	apiClient, err := api.NewClient(cfg.API, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API client: %w", err)
+	}
+
+	// Cache DNS lookups for the backend and SSH targets
+	dnsResolver := resolver.NewResolver(cfg.DNS, log)
+	apiClient.WithResolver(dnsResolver)
+
+	// Generate orchestrator ID
+	orchestratorID := fmt.Sprintf("orchestrator-%s", cfg.Orchestrator.ID)
+
+	// Create executor manager
+	executorMgr := executors.NewManager()
+	executorMgr.WithMatrixLimits(cfg.Jobs.Matrix)
+	executorMgr.WithLocale(cfg.Jobs.Locale)
+
+	// Register container executor, running jobs on Docker or Kubernetes
+	var containerExec *container.Executor
+	var kubeExec *kubernetes.Executor
+	switch {
+	case opts.noContainers:
+	case cfg.Container.Kubernetes.Enabled:
+		kubeExec, err = kubernetes.NewExecutor(cfg.Container, apiClient, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Kubernetes executor: %w", err)
+		}
+		executorMgr.Register(types.JobTypeContainer, kubeExec)
+	default:
+		containerExec, err = container.NewExecutor(cfg.Container, apiClient, log)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create container executor: %w", err)
+		}
+		executorMgr.Register(types.JobTypeContainer, containerExec)
+	}
+
+	// Register SSH executor (with multi-server support)
+	runtimeHost, runtimePort := opts.runtimeHost, opts.runtimePort
+	if runtimeHost == "" {
+		runtimeHost = os.Getenv("RUNTIME_HOST")
+	}
+	if runtimeHost == "" {
+		runtimeHost = "runtime-api" // Default to Docker service name
+	}
+	if runtimePort == 0 {
+		runtimePort = 8089 // Default runtime API port
+		if envPort := os.Getenv("RUNTIME_PORT"); envPort != "" {
+			if port, err := strconv.Atoi(envPort); err == nil {
+				runtimePort = port
+			}
+		}
+	}
+	jwtSecret := cfg.Container.Runtime.JWTSecret
+	sshExec, err := ssh.NewMultiServerExecutor(cfg.SSH, apiClient, runtimeHost, runtimePort, jwtSecret, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH executor: %w", err)
+	}
+	sshExec.WithResolver(dnsResolver)
+	featureFlags := features.NewRegistry(cfg.Features)
+	sshExec.WithFeatures(featureFlags)
+	executorMgr.Register(types.JobTypeSSH, sshExec)
+
+	// Download runner releases for servers' versions and architectures
+	runnerReleases, err := runnerdist.New(cfg.SSH.Runner, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure runner releases: %w", err)
+	}
+	sshExec.WithRunnerReleases(runnerReleases)
+
+	// Register HTTP request executor
+	if cfg.HTTP.Enabled {
+		executorMgr.Register(types.JobTypeHTTP, httpexec.NewExecutor(cfg.HTTP, apiClient, log))
+	}
+
+	// Pre-warm the runtime cache at dispatch so helper calls start as cache hits
+	if cfg.Container.Runtime.Prewarm {
+		prewarmer, err := runtimecache.NewPrewarmer(cfg.Container.Runtime, log)
+		if err != nil {
+			log.WithError(err).Warn("Runtime cache pre-warming disabled")
+		} else {
+			if containerExec != nil {
+				containerExec.WithPrewarmer(prewarmer)
+			}
+			if kubeExec != nil {
+				kubeExec.WithPrewarmer(prewarmer)
+			}
+			sshExec.WithPrewarmer(prewarmer)
+		}
+	}
+
+	// Scripts hear about cancellation and operator messages through the runtime
+	var messenger *runtimecache.Messenger
+	if cfg.Container.Runtime.Messages {
+		messenger, err = runtimecache.NewMessenger(cfg.Container.Runtime, log)
+		if err != nil {
+			log.WithError(err).Warn("Runtime push messages disabled")
+		}
+	}
+
+	// Executors for the job types declared by plugins
+	pluginExecs, err := plugin.Load(cfg.Plugins, apiClient, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load executor plugins: %w", err)
+	}
+	for _, executor := range pluginExecs {
+		executorMgr.Register(executor.Type(), executor)
+	}
+
+	// Executors supplied by the embedding program replace the built-ins
+	for jobType, executor := range opts.executors {
+		executorMgr.Register(jobType, executor)
+	}
+
+	// Create log streamer, keeping job logs on disk when configured
+	logStore, err := logger.NewLogStore(cfg.Logging.Jobs, log)
+	if err != nil {
+		log.WithError(err).Warn("Job log files disabled")
+	}
+	logStreamer := logger.NewStreamer(cfg.Logging.WebSocket, cfg.API.WSEndpoint, cfg.API.Token, log).
+		WithStore(logStore)
+
+	// Create metrics collector
+	metricsCollector := metrics.NewCollector(cfg.Monitoring, log)
+
+	// Connect metrics to API client
+	apiClient.WithMetrics(metricsCollector)
+	dnsResolver.WithMetrics(metricsCollector)
+	sshExec.Payloads().WithMetrics(metricsCollector)
+
+	// Mask sensitive data in job output before it is streamed or stored
+	masker, err := masking.NewMasker(cfg.Security.OutputScanning)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure output scanning: %w", err)
+	}
+	apiClient.WithOutputMasker(masker)
+
+	// Deliver execution records to the configured export sinks
+	exporter, err := export.NewExporter(cfg.Exports, log)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure exports: %w", err)
+	}
+
+	completions, err := completion.New(cfg.Jobs.Completion, apiClient, log)
+	if err != nil {
+		return nil, err
+	}
+	completions.WithMetrics(metricsCollector)
+
+	// Create recovery manager (use container executor's cleanup manager if available)
+	var cleanupMgr *container.CleanupManager
+	if containerExec != nil {
+		// Access the cleanup manager through a getter or make it public
+		cleanupMgr = container.NewCleanupManager(containerExec, log)
+	}
+	recovery := orchestrator.NewRecoveryManager(apiClient, cleanupMgr, log)
+
+	// Analysis tools run in containers
+	var toolRunner analysis.ToolRunner
+	if containerExec != nil {
+		toolRunner = containerExec
+	}
+
+	o := &Agent{
+		config:         cfg,
+		log:            log,
+		apiClient:      apiClient,
+		executorMgr:    executorMgr,
+		logStreamer:    logStreamer,
+		metrics:        metricsCollector,
+		recovery:       recovery,
+		containerExec:  containerExec,
+		kubeExec:       kubeExec,
+		gates:          gates.NewWaiter(cfg.Jobs.Gates, executorMgr, apiClient, log).WithApprovals(apiClient),
+		analyzer:       analysis.NewAnalyzer(cfg.Jobs.Analysis, toolRunner, log),
+		masker:         masker,
+		exporter:       exporter,
+		push:           apiClient.NewJobPush(cfg.Jobs.Push),
+		accounting:     accounting.New(cfg.Accounting, orchestratorID, apiClient, log),
+		completions:    completions,
+		payloads:       sshExec.Payloads(),
+		sshExec:        sshExec,
+		jitter:         jitter.New(cfg.Jitter, orchestratorID),
+		quarantine:     quarantine.New(cfg.Jobs.Quarantine),
+		deadLetters:    deadletter.New(cfg.Jobs.DeadLetter, log),
+		durations:      durations.New(cfg.Jobs.Durations, log),
+		lineage:        lineage.New(cfg.Jobs.Lineage),
+		messenger:      messenger,
+		runnerReleases: runnerReleases,
+		features:       featureFlags,
+		orchestratorID: orchestratorID,
+		shutdown:       make(chan struct{}),
+		drain:          make(chan struct{}),
+		done:           make(chan struct{}),
+		activeJobs:     make(map[string]*types.Job),
+		held:           make(map[string]jobHold),
+		cancels:        make(map[string]context.CancelFunc),
+		cancelled:      make(map[string]string),
+		replacedBy:     make(map[string]string),
+		slots:          make([]string, cfg.Jobs.MaxConcurrent),
+		slotStarts:     make([]time.Time, cfg.Jobs.MaxConcurrent),
+		slotFreed:      make(chan struct{}, 1),
+	}
+	o.jobsCtx, o.cancelJobs = context.WithCancel(context.Background())
+
+	// Jobs dead-lettered before a restart stay refused
+	metricsCollector.SetDeadLetteredJobs(float64(len(o.deadLetters.Entries())))
+
+	// Signing key for execution receipts
+	if cfg.Security.Receipts.Enabled {
+		signer, err := receipt.LoadOrCreateSigner(cfg.Security.Receipts.KeyFile)
+		if err != nil {
+			log.WithError(err).Warn("Execution receipts disabled")
+		} else {
+			o.receipts = signer
+			log.WithFields(logrus.Fields{
+				"keyId":     receipt.KeyID(signer.PublicKey()),
+				"publicKey": base64.StdEncoding.EncodeToString(signer.PublicKey()),
+			}).Info("Signing execution receipts")
+		}
+	}
+
+	// Commands and webhooks run on this host around every job
+	o.hooks = hooks.New(cfg.Hooks, orchestratorID, log).OnResult(func(r hooks.Result) {
+		result := "success"
+		if !r.Success {
+			result = "failure"
+		}
+		metricsCollector.RecordHook(r.Phase, r.Name, result)
+	})
+
+	// Local trigger sources (watched directories, message queues)
+	o.triggers = triggers.NewManager(cfg.Triggers, apiClient, log)
+
+	// Peer coordination for handing off jobs we cannot start yet
+	if cfg.Jobs.WorkStealing.Enabled {
+		o.fleet = fleet.NewCoordinator(cfg, apiClient, o.jobLoad, log)
+	}
+
+	return o, nil
+}
+
+// Run starts the orchestrator
+func (o *Agent) Run(ctx context.Context) error {
+	o.log.Info("Starting orchestrator")
+	defer close(o.done)
+
+	// Perform recovery on startup
+	if err := o.recovery.RecoverOnStartup(ctx, o.orchestratorID); err != nil {
+		o.log.WithError(err).Error("Recovery failed on startup")
+		// Continue anyway - recovery errors shouldn't prevent startup
+	}
+
+	// Start periodic cleanup if we have a container executor
+	if o.containerExec != nil {
+		go o.containerExec.PrePullImages(ctx)
+
+		cleanupMgr := o.containerExec.GetCleanupManager()
+		if cleanupMgr != nil {
+			cleanupMgr.WithJitter(o.jitter, o.config.Jitter.Cleanup).StartPeriodicCleanup(ctx, 30*time.Minute)
+		}
+	}
+
+	// Publish stored payload usage before the first job changes it
+	if err := o.payloads.ReportUsage(ctx); err != nil {
+		o.log.WithError(err).Warn("Failed to read payload storage usage")
+	}
+
+	// Start periodic payload cleanup if enabled
+	if o.config.SSH.Execution.CleanupPayloads {
+		go o.payloadCleanupLoop(ctx)
+	}
+
+	// Start log streamer
+	if err := o.logStreamer.Start(ctx); err != nil {
+		o.log.WithError(err).Warn("Failed to start log streamer")
+	}
+	defer o.logStreamer.Stop()
+
+	// Start API health check
+	go o.healthCheckLoop(ctx)
+
+	// Pick up jobs cancelled in the backend
+	go o.cancellationLoop(ctx)
+
+	// Start fleet coordination
+	if o.fleet != nil {
+		go o.fleetLoop(ctx)
+	}
+
+	// Start local trigger sources
+	o.triggers.Start(ctx)
+	defer o.triggers.Stop()
+
+	// Start record export; it outlives ctx so jobs finishing during the
+	// graceful shutdown are still exported
+	o.exporter.Start(context.Background())
+	defer o.exporter.Stop()
+
+	// Start usage reporting, which outlives ctx for the same reason
+	o.accounting.Start(context.Background())
+	defer o.accounting.Stop()
+	defer o.messenger.Close()
+
+	// Start completion reporting, which outlives ctx for the same reason
+	o.completions.Start(context.Background())
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		o.completions.Stop(ctx)
+	}()
+
+	// Receive pushed jobs; polling slows down while the channel is up
+	go o.push.Run(ctx)
+
+	// Keep runner releases up to date
+	go o.runnerReleases.Run(ctx)
+
+	// Start job polling loop
+	pollTicker := o.jitter.NewTicker("poll", o.config.Jobs.PollInterval, o.config.Jitter.Poll)
+	defer pollTicker.Stop()
+	var lastPoll time.Time
+
+	// Drain mode stops taking jobs and shuts down once the accepted ones
+	// finished, or when the drain timeout passes
+	drainStarted := o.drain
+	var drainTimeout <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			o.log.Info("Context cancelled, shutting down orchestrator")
+			return o.gracefulShutdown()
+
+		case <-o.shutdown:
+			o.log.Info("Shutdown requested")
+			return o.gracefulShutdown()
+
+		case <-drainStarted:
+			drainStarted = nil
+			drainTimeout = time.After(o.config.Jobs.Drain.Timeout)
+			active, pending := o.jobLoad()
+			o.log.WithFields(logrus.Fields{
+				"active":  active,
+				"pending": pending,
+				"timeout": o.config.Jobs.Drain.Timeout,
+			}).Info("Draining: no new jobs are taken")
+			o.push.Ready(0)
+
+		case <-drainTimeout:
+			active, pending := o.jobLoad()
+			o.log.WithField("remaining", active+pending).Warn("Drain timeout reached, shutting down")
+			return o.gracefulShutdown()
+
+		case <-pollTicker.C:
+			if o.Draining() {
+				o.updateSlotMetrics()
+				o.dispatchPending(ctx)
+				if active, pending := o.jobLoad(); active+pending == 0 {
+					o.log.Info("Drain complete, shutting down")
+					return o.gracefulShutdown()
+				}
+				o.metrics.RecordPollDeferred("draining")
+				continue
+			}
+			if o.push.Connected() && time.Since(lastPoll) < o.config.Jobs.Push.PollInterval {
+				o.updateSlotMetrics()
+				o.dispatchPending(ctx)
+				o.push.Ready(o.freeCapacity())
+				o.metrics.RecordPollDeferred("push")
+				continue
+			}
+			lastPoll = time.Now()
+			if err := o.pollAndProcessJobs(ctx); err != nil {
+				o.log.WithError(err).Error("Failed to poll jobs")
+			}
+			o.push.Ready(o.freeCapacity())
+
+		case req := <-o.push.Cancels():
+			stopped, known := o.CancelJob(ctx, req.JobID, req.Reason)
+			o.push.CancelResult(req.JobID, stopped, known)
+
+		case job := <-o.push.Jobs():
+			if o.Draining() {
+				// Left unacknowledged for another orchestrator to take
+				o.log.WithField("jobID", job.ID).Debug("Ignoring pushed job while draining")
+				o.push.Ready(0)
+				continue
+			}
+			o.log.WithField("jobID", job.ID).Debug("Received pushed job")
+			o.acceptJobs(ctx, []*types.Job{job}, o.push.Acknowledge)
+			o.push.Ready(o.freeCapacity())
+		}
+	}
+}
+
+// pollAndProcessJobs polls for new jobs and processes them
+func (o *Agent) pollAndProcessJobs(ctx context.Context) error {
+	// Refresh slot occupancy before deciding whether to poll
+	o.updateSlotMetrics()
+
+	// Start any jobs waiting for a slot
+	o.dispatchPending(ctx)
+
+	// Check if we're at capacity
+	free := o.freeCapacity()
+	if free == 0 {
+		o.log.Debug("At maximum concurrent jobs, skipping poll")
+		o.metrics.RecordPollDeferred("capacity")
+		return nil
+	}
+
+	// Calculate how many jobs we can accept
+	limit := min(free, o.config.Jobs.PollBatchSize)
+
+	// Poll for jobs (pass orchestrator ID)
+	result, err := o.apiClient.PollJobs(ctx, limit)
+	if err != nil {
+		return fmt.Errorf("failed to poll jobs: %w", err)
+	}
+	o.metrics.SetQueueDepth(float64(result.QueueSize))
+	jobs := result.Jobs
+
+	if len(jobs) == 0 {
+		o.log.Debug("No jobs available")
+		return nil
+	}
+
+	o.log.WithField("count", len(jobs)).Info("Received jobs from queue")
+	o.acceptJobs(ctx, jobs, o.apiClient.AcknowledgeJob)
+	return nil
+}
+
+// freeCapacity returns how many more jobs the orchestrator can accept
+func (o *Agent) freeCapacity() int {
+	o.mu.RLock()
+	accepted := o.busySlotsLocked() + o.resuming + len(o.pending)
+	o.mu.RUnlock()
+	if o.Draining() {
+		return 0
+	}
+
+	capacity := o.config.Jobs.MaxConcurrent
+	if o.fleet != nil {
+		capacity += o.config.Jobs.WorkStealing.PrefetchLimit
+	}
+	return max(capacity-accepted, 0)
+}
+
+// acceptJobs acknowledges polled or pushed jobs and starts them, or holds
+// them until a slot frees up
+func (o *Agent) acceptJobs(ctx context.Context, jobs []*types.Job, acknowledge func(ctx context.Context, jobID string) error) {
+	for _, job := range jobs {
+		// Record job received
+		o.metrics.RecordJobReceived(string(job.Type))
+
+		// A job that failed every attempt is reported instead of being
+		// acknowledged and run once more
+		if entry, ok := o.deadLetters.Check(job.ID); ok {
+			o.reportDeadLettered(ctx, job, entry)
+			continue
+		}
+
+		// Acknowledge the job
+		if err := acknowledge(ctx, job.ID); err != nil {
+			o.log.WithError(err).WithField("jobID", job.ID).Error("Failed to acknowledge job")
+			o.metrics.RecordJobFailed(string(job.Type), "acknowledge_failed")
+			continue
+		}
+
+		// Events that keep failing are not run again until cleared
+		if entry, ok := o.quarantine.Check(quarantine.EventID(job)); ok {
+			o.releaseQuarantined(ctx, job, entry)
+			continue
+		}
+
+		// Children of a cancelled job are cancelled without running
+		if reason, cancelled := o.lineage.Track(job); cancelled {
+			o.reportCancelled(ctx, job, reason)
+			continue
+		}
+
+		// A run of an event that is still running follows its concurrency
+		// policy
+		if o.applyConcurrencyPolicy(ctx, job) {
+			continue
+		}
+
+		// Start the job, or hold it until a slot frees up
+		o.mu.Lock()
+		started := o.admitJobLocked(job)
+		pendingCount := len(o.pending)
+		o.mu.Unlock()
+
+		if !started {
+			o.metrics.SetPendingJobs(float64(pendingCount))
+			continue
+		}
+
+		// Update active jobs metric
+		o.metrics.IncActiveJobs()
+
+		// Process job in goroutine
+		go o.processJob(ctx, job)
+	}
+}
+
+// admitJobLocked adds a job to the active set if a concurrency slot is free,
+// otherwise queues it locally; o.mu must be held. Reports whether the job
+// should be started now.
+func (o *Agent) admitJobLocked(job *types.Job) bool {
+	if o.busySlotsLocked()+o.resuming >= o.config.Jobs.MaxConcurrent {
+		o.pending = append(o.pending, pendingJob{job: job, acceptedAt: time.Now()})
+		return false
+	}
+
+	o.activeJobs[job.ID] = job
+	o.assignSlotLocked(job.ID)
+	return true
+}
+
+// dispatchPending starts queued jobs while there are free slots
+func (o *Agent) dispatchPending(ctx context.Context) {
+	for {
+		o.mu.Lock()
+		if o.isShuttingDown || len(o.pending) == 0 || o.busySlotsLocked()+o.resuming >= o.config.Jobs.MaxConcurrent {
+			o.mu.Unlock()
+			return
+		}
+		next := o.pending[0]
+		o.pending = o.pending[1:]
+		o.admitJobLocked(next.job)
+		pendingCount := len(o.pending)
+		o.mu.Unlock()
+
+		o.metrics.SetPendingJobs(float64(pendingCount))
+		o.metrics.IncActiveJobs()
+		go o.processJob(ctx, next.job)
+	}
+}
+
+// jobLoad reports active and locally queued job counts
+func (o *Agent) jobLoad() (int, int) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return len(o.activeJobs), len(o.pending)
+}
+
+// fleetLoop periodically refreshes peers and hands off jobs that have waited
+// too long for a local slot
+func (o *Agent) fleetLoop(ctx context.Context) {
+	ticker := o.jitter.NewTicker("fleet", o.config.Jobs.WorkStealing.HeartbeatInterval, o.config.Jitter.Health)
+	defer ticker.Stop()
+
+	for {
+		if err := o.fleet.Refresh(ctx); err != nil {
+			o.log.WithError(err).Warn("Failed to refresh fleet peers")
+		} else {
+			o.handoffPendingJobs(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handoffPendingJobs releases queued jobs to idle peers in the same region
+func (o *Agent) handoffPendingJobs(ctx context.Context) {
+	cutoff := time.Now().Add(-o.config.Jobs.WorkStealing.HandoffAfter)
+
+	for {
+		peer, ok := o.fleet.IdlePeer()
+		if !ok {
+			return
+		}
+
+		// Take the oldest job that has waited long enough
+		o.mu.Lock()
+		if o.isShuttingDown || len(o.pending) == 0 || o.pending[0].acceptedAt.After(cutoff) {
+			o.mu.Unlock()
+			return
+		}
+		candidate := o.pending[0]
+		o.pending = o.pending[1:]
+		pendingCount := len(o.pending)
+		o.mu.Unlock()
+
+		log := o.log.WithField("jobID", candidate.job.ID).WithField("peer", peer.OrchestratorID)
+
+		if err := o.fleet.Handoff(ctx, candidate.job.ID, peer); err != nil {
+			log.WithError(err).Warn("Failed to hand off job, keeping it local")
+			o.mu.Lock()
+			o.pending = append([]pendingJob{candidate}, o.pending...)
+			o.mu.Unlock()
+			return
+		}
+
+		log.Info("Handed off unstarted job to idle peer")
+		o.metrics.RecordJobHandedOff()
+		o.metrics.SetPendingJobs(float64(pendingCount))
+	}
+}
+
+// jobSecrets returns the values carrying a job's credentials: the job itself,
+// which holds its target server and request headers, and every server of a
+// multi-server job
+func jobSecrets(job *types.Job) []any {
+	secrets := []any{job}
+	servers, _ := job.Metadata["servers"].([]interface{})
+	for _, server := range servers {
+		data, err := json.Marshal(server)
+		if err != nil {
+			continue
+		}
+		var details types.ServerDetails
+		if err := json.Unmarshal(data, &details); err == nil {
+			secrets = append(secrets, details)
+		}
+	}
+	return secrets
+}
+
+// processJob handles a single job execution
+func (o *Agent) processJob(ctx context.Context, job *types.Job) {
+	log := o.log.WithField("jobID", job.ID)
+	log.Info("Starting job execution")
+	log.WithField("features", o.features.ForJob(job)).Debug("Evaluated feature flags")
+
+	// Keep the job's credentials out of logs and error messages while it
+	// runs; they stop being tracked when it ends
+	defer redact.Scope(jobSecrets(job)...)()
+
+	// CancelJob stops the job through cancelCtx, whether it is held or running
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	o.mu.Lock()
+	o.cancels[job.ID] = cancel
+	if _, ok := o.cancelled[job.ID]; ok {
+		cancel()
+	}
+	o.mu.Unlock()
+
+	// Remove from active jobs when done
+	defer func() {
+		o.mu.Lock()
+		delete(o.activeJobs, job.ID)
+		delete(o.cancels, job.ID)
+		delete(o.cancelled, job.ID)
+		delete(o.replacedBy, job.ID)
+		o.releaseSlotLocked(job.ID)
+		o.mu.Unlock()
+		o.metrics.DecActiveJobs()
+		o.updateSlotMetrics()
+		o.dispatchPending(ctx)
+	}()
+
+	// Hold the job until it is approved, then until its gates hold. A held
+	// job gives its concurrency slot to queued jobs meanwhile.
+	holdCtx, stopHold := context.WithCancel(ctx)
+	defer stopHold()
+	defer context.AfterFunc(cancelCtx, stopHold)()
+
+	held := job.Execution.Approval != nil || len(job.Execution.Gates) > 0
+	if held {
+		o.yieldSlot(ctx, job.ID)
+	}
+
+	if job.Execution.Approval != nil {
+		if err := o.waitForApproval(holdCtx, job); err != nil {
+			if reason, ok := o.cancelledReason(job.ID); ok {
+				o.reportCancelled(ctx, job, reason)
+				return
+			}
+			log.WithError(err).Warn("Job not approved")
+			o.metrics.RecordJobFailed(string(job.Type), "not_approved")
+			o.lineage.SetStatus(job.ID, types.JobStatusCancelled)
+
+			o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusCancelled, &types.StatusUpdate{
+				Status:  types.JobStatusCancelled,
+				Message: err.Error(),
+				Error:   types.ErrorDetailsFromError(err),
+			})
+			return
+		}
+	}
+	if len(job.Execution.Gates) > 0 {
+		if err := o.waitForGates(holdCtx, job); err != nil {
+			if reason, ok := o.cancelledReason(job.ID); ok {
+				o.reportCancelled(ctx, job, reason)
+				return
+			}
+			log.WithError(err).Warn("Job gates not satisfied")
+			o.metrics.RecordJobFailed(string(job.Type), "gate_failed")
+			o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+
+			o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+				Status:  types.JobStatusFailed,
+				Message: err.Error(),
+				Error:   types.ErrorDetailsFromError(err),
+			})
+			return
+		}
+	}
+	if held {
+		if err := o.reacquireSlot(holdCtx, job.ID); err != nil {
+			if reason, ok := o.cancelledReason(job.ID); ok {
+				o.reportCancelled(ctx, job, reason)
+				return
+			}
+			log.WithError(err).Warn("Job stopped while waiting for a slot")
+			o.metrics.RecordJobFailed(string(job.Type), "no_slot")
+			o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+
+			o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+				Status:  types.JobStatusFailed,
+				Message: err.Error(),
+				Error:   types.ErrorDetailsFromError(err),
+			})
+			return
+		}
+	}
+
+	// Lint and scan the script; in block mode findings stop the job here
+	if err := o.analyzeScript(ctx, job); err != nil {
+		log.WithError(err).Warn("Job blocked by static analysis")
+		o.metrics.RecordJobFailed(string(job.Type), "analysis_blocked")
+		o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+
+		o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+			Status:  types.JobStatusFailed,
+			Message: err.Error(),
+			Error:   types.ErrorDetailsFromError(err),
+		})
+		return
+	}
+
+	// The job outlives ctx so that the graceful shutdown decides how it
+	// stops, and its completion is still reported
+	runCtx, stopJob := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopJob()
+	defer context.AfterFunc(o.jobsCtx, stopJob)()
+	defer context.AfterFunc(cancelCtx, stopJob)()
+
+	if reason, ok := o.cancelledReason(job.ID); ok {
+		o.reportCancelled(runCtx, job, reason)
+		return
+	}
+
+	// A job that can't finish before its wall-clock deadline isn't started
+	deadline, err := o.jobDeadline(job)
+	if err != nil {
+		log.WithError(err).Warn("Job has an invalid deadline")
+		o.metrics.RecordJobFailed(string(job.Type), "invalid_deadline")
+		o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+
+		o.apiClient.UpdateJobStatus(runCtx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+			Status:  types.JobStatusFailed,
+			Message: err.Error(),
+			Error:   types.ErrorDetailsFromError(err),
+		})
+		return
+	}
+	if !deadline.IsZero() {
+		if left := time.Until(deadline); left <= 0 || left < job.Execution.Deadline.MinDuration {
+			o.reportDeadlineMissed(runCtx, job, deadline, left)
+			return
+		}
+	}
+
+	// Prepare the host for the job; a blocking pre hook failure fails it
+	hookResults, err := o.hooks.Pre(runCtx, job)
+	if err != nil {
+		log.WithError(err).Warn("Job blocked by pre hook")
+		o.metrics.RecordJobFailed(string(job.Type), "hook_blocked")
+		o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+		o.hooks.Post(runCtx, job, types.JobStatusFailed, -1)
+
+		o.apiClient.UpdateJobStatus(runCtx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+			Status:  types.JobStatusFailed,
+			Message: err.Error(),
+			Error:   types.ErrorDetailsFromError(err),
+		})
+		return
+	}
+	o.lineage.SetStatus(job.ID, types.JobStatusRunning)
+
+	// The time left before the deadline replaces a longer timeout
+	deadlineBound := false
+	if !deadline.IsZero() {
+		if left := time.Until(deadline); job.Timeout <= 0 || left < job.Timeout {
+			job.Timeout = max(left, time.Millisecond)
+			deadlineBound = true
+		}
+	}
+
+	// Create job context with timeout
+	jobCtx := runCtx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		jobCtx, cancel = context.WithTimeout(runCtx, job.Timeout)
+		defer cancel()
+	}
+
+	// Track job start time
+	jobStartTime := time.Now()
+	o.mu.Lock()
+	job.StartedAt = &jobStartTime
+	o.mu.Unlock()
+
+	// Count the attempt before it starts, so one that takes the
+	// orchestrator down counts too
+	o.deadLetters.Start(job)
+
+	// Execute job using executor manager
+	updates, err := o.executorMgr.Execute(jobCtx, job)
+	if err != nil {
+		if reason, ok := o.cancelledReason(job.ID); ok {
+			o.reportCancelled(runCtx, job, reason)
+			return
+		}
+		log.WithError(err).Error("Failed to start job execution")
+		o.metrics.RecordJobFailed(string(job.Type), "execution_failed")
+		o.lineage.SetStatus(job.ID, types.JobStatusFailed)
+		o.hooks.Post(runCtx, job, types.JobStatusFailed, -1)
+
+		// Update job status to failed
+		o.apiClient.UpdateJobStatus(runCtx, job.ID, types.JobStatusFailed, &types.StatusUpdate{
+			Status:  types.JobStatusFailed,
+			Message: err.Error(),
+			Error:   types.ErrorDetailsFromError(err),
+		})
+		o.recordOutcome(runCtx, job, true, err.Error())
+		o.deadLetters.Finish(job.ID, true, err.Error())
+		return
+	}
+
+	// Sample the job's resource usage for accounting and its metrics
+	meter := o.accounting.Meter(jobCtx, job, o.executorMgr.SampleStats)
+	if usageCfg := o.config.Monitoring.ResourceUsage; meter == nil && usageCfg.Enabled {
+		meter = accounting.StartMeter(jobCtx, job, o.executorMgr.SampleStats, usageCfg.SampleInterval)
+	}
+
+	// Start job logging
+	jobLogger := o.logStreamer.StartJob(job.ID)
+	defer o.logStreamer.StopJob(job.ID)
+
+	// Warn long before the timeout when the run takes far longer than the
+	// event's runs usually do
+	stopWatch := o.durations.Watch(quarantine.EventID(job), func(overrun durations.Overrun) {
+		o.reportOverrun(runCtx, job, jobLogger, overrun)
+	})
+
+	// Process execution updates
+	var exitCode int
+	var finalStatus types.JobStatus
+	var timedOut bool
+	var lastError *types.ErrorDetails
+	var resume *types.ResumeHints
+	var finalUsage *types.ResourceUsage
+	var stdout, stderr strings.Builder
+	detections := masking.Detections{}
+	startTime := time.Now()
+
+	for update := range updates {
+		switch update.Type {
+		case types.UpdateTypeLog:
+			if logEntry, ok := update.Data.(*types.LogEntry); ok {
+				if line, found := o.masker.Mask(logEntry.Line); len(found) > 0 {
+					masked := *logEntry
+					masked.Line = line
+					logEntry = &masked
+					detections.Add(found)
+				}
+				if logEntry.Stream == "stdout" {
+					stdout.WriteString(logEntry.Line + "\n")
+				} else {
+					stderr.WriteString(logEntry.Line + "\n")
+				}
+				// Stream logs via WebSocket
+				jobLogger.AddLog(logEntry)
+			}
+
+		case types.UpdateTypeStatus:
+			if status, ok := update.Data.(*types.StatusUpdate); ok {
+				if message, found := o.masker.Mask(status.Message); len(found) > 0 {
+					masked := *status
+					masked.Message = message
+					status = &masked
+					detections.Add(found)
+				}
+				o.apiClient.UpdateJobStatus(runCtx, job.ID, status.Status, status)
+			}
+
+		case types.UpdateTypeComplete:
+			if status, ok := update.Data.(*types.StatusUpdate); ok {
+				if status.ExitCode != nil {
+					exitCode = *status.ExitCode
+				}
+				finalStatus = status.Status
+				resume = status.Resume
+				finalUsage = status.Usage
+				// Check for timeout based on exit code
+				if exitCode == -1 {
+					timedOut = true
+				}
+			}
+
+		case types.UpdateTypeError:
+			if status, ok := update.Data.(*types.StatusUpdate); ok {
+				log.WithField("error", status.Message).Error("Execution error")
+				if status.Status == types.JobStatusFailed && status.Error != nil {
+					lastError = status.Error
+				}
+			}
+		}
+	}
+
+	stopWatch()
+
+	// Flag the execution if anything had to be masked
+	if len(detections) > 0 {
+		log.WithField("detectors", detections.Names()).Warn("Sensitive data detected in job output")
+		jobLogger.AddLog(types.NewLogEntry("system", detections.Warning(), 0))
+	}
+
+	// Calculate execution metrics
+	endTime := time.Now()
+	duration := endTime.Sub(startTime)
+	usage := accounting.Combine(meter.Stop(), finalUsage, duration)
+
+	// Determine final status
+	var jobStatus types.JobStatus
+	var statusMessage string
+
+	if reason, ok := o.cancelledReason(job.ID); ok {
+		// Stopped by CancelJob, or replaced by a newer run
+		jobStatus = o.cancelStatus(job.ID)
+		statusMessage = "Job cancelled: " + reason
+	} else if (timedOut || exitCode == -1) && deadlineBound {
+		// Ran into its deadline
+		jobStatus = types.JobStatusDeadlineExceeded
+		statusMessage = fmt.Sprintf("Job did not finish by its deadline of %s", deadline.Format(time.RFC3339))
+	} else if timedOut || exitCode == -1 {
+		// Timeout detected
+		jobStatus = types.JobStatusTimeout
+		statusMessage = fmt.Sprintf("Job execution timed out after %v", job.Timeout)
+	} else if finalStatus != "" {
+		// Use status from executor
+		jobStatus = finalStatus
+	} else if exitCode != 0 {
+		// Non-zero exit code
+		jobStatus = types.JobStatusFailed
+		statusMessage = fmt.Sprintf("Job failed with exit code %d", exitCode)
+	} else {
+		// Success
+		jobStatus = types.JobStatusCompleted
+		statusMessage = "Job completed successfully"
+	}
+
+	// A blocking post hook failure fails a job that otherwise completed
+	postResults, err := o.hooks.Post(runCtx, job, jobStatus, exitCode)
+	hookResults = append(hookResults, postResults...)
+	if err != nil && jobStatus == types.JobStatusCompleted {
+		jobStatus = types.JobStatusFailed
+		statusMessage = err.Error()
+		lastError = types.ErrorDetailsFromError(err)
+	}
+	o.lineage.SetStatus(job.ID, jobStatus)
+
+	// The error can quote the output or command it failed on
+	if lastError != nil {
+		masked, found := o.masker.MaskError(lastError)
+		lastError = masked
+		detections.Add(found)
+	}
+
+	// Mark job as completed
+	completeReq := &api.CompleteJobRequest{
+		Status:   jobStatus,
+		ExitCode: exitCode,
+		Output: api.Output{
+			Stdout: stdout.String(),
+			Stderr: stderr.String(),
+		},
+		Metrics: types.ExecutionMetrics{
+			StartTime:     startTime,
+			EndTime:       endTime,
+			Duration:      duration.Milliseconds(),
+			ResourceUsage: usage.ResourceUsage(),
+		},
+		Resume:                resume,
+		SensitiveDataDetected: detections.Names(),
+		Timestamp:             time.Now().Format(time.RFC3339),
+	}
+	if jobStatus != types.JobStatusCompleted {
+		// Tells the backend whether the failure is worth retrying
+		completeReq.Error = lastError
+	}
+	if len(hookResults) > 0 {
+		completeReq.ExecutionMetadata = map[string]interface{}{"hooks": hookResults}
+	}
+
+	// Sign a receipt of what ran where
+	if o.receipts != nil {
+		r := receipt.ForJob(job, o.orchestratorID)
+		r.StartedAt = startTime
+		r.CompletedAt = endTime
+		r.Status = string(jobStatus)
+		r.ExitCode = exitCode
+		r.StdoutHash = receipt.Hash([]byte(completeReq.Output.Stdout))
+		r.StderrHash = receipt.Hash([]byte(completeReq.Output.Stderr))
+
+		signed, err := o.receipts.Sign(r)
+		if err != nil {
+			log.WithError(err).Warn("Failed to sign execution receipt")
+		} else {
+			completeReq.Receipt = signed
+		}
+	}
+
+	// Export the execution record
+	record := export.NewRecord(job, o.orchestratorID)
+	record.Status = string(jobStatus)
+	record.ExitCode = exitCode
+	record.StartedAt = startTime
+	record.CompletedAt = endTime
+	record.DurationMs = duration.Milliseconds()
+	record.Artifacts = export.ArtifactsFrom(completeReq.Artifacts)
+	record.Error = completeReq.Error
+	o.exporter.Export(record, completeReq.Output.Stdout)
+	o.accounting.Record(job, jobStatus, duration, usage)
+	if jobStatus == types.JobStatusCompleted && !job.Execution.DryRun {
+		o.durations.Record(quarantine.EventID(job), duration)
+	}
+
+	// Record job completion metrics
+	jobDuration := time.Since(jobStartTime).Seconds()
+	o.metrics.RecordJobResources(string(job.Type), completeReq.Metrics.ResourceUsage)
+	switch completeReq.Status {
+	case types.JobStatusCompleted:
+		o.metrics.RecordJobCompleted(string(job.Type), jobDuration)
+	case types.JobStatusTimeout:
+		o.metrics.RecordJobFailed(string(job.Type), "timeout")
+	case types.JobStatusDeadlineExceeded:
+		o.metrics.RecordJobFailed(string(job.Type), "deadline_exceeded")
+	case types.JobStatusInterrupted:
+		o.metrics.RecordJobFailed(string(job.Type), "interrupted")
+	case types.JobStatusCancelled:
+		o.metrics.RecordJobFailed(string(job.Type), "cancelled")
+	case types.JobStatusReplaced:
+		o.metrics.RecordJobFailed(string(job.Type), "replaced")
+	case types.JobStatusFailed:
+		if exitCode >= 100 {
+			o.metrics.RecordJobFailed(string(job.Type), "partial_failure")
+		} else {
+			o.metrics.RecordJobFailed(string(job.Type), "non_zero_exit")
+		}
+	default:
+		o.metrics.RecordJobFailed(string(job.Type), "unknown")
+	}
+
+	// Reported by the completion workers so the slot frees up now
+	o.completions.Enqueue(job.ID, string(job.Type), completeReq)
+	log.WithFields(logrus.Fields{
+		"exitCode": exitCode,
+		"status":   jobStatus,
+		"duration": jobDuration,
+	}).Info(statusMessage)
+
+	// An interrupted job did not fail; it resumes after the restart. Nor did
+	// a cancelled or replaced one. A dry run's syntax errors say nothing
+	// about the event's scheduled runs.
+	detail := statusMessage
+	if lastError != nil && lastError.Message != "" {
+		detail = lastError.Message
+	}
+	failed := jobStatus != types.JobStatusCompleted && jobStatus != types.JobStatusInterrupted &&
+		jobStatus != types.JobStatusCancelled && jobStatus != types.JobStatusReplaced
+	o.deadLetters.Finish(job.ID, failed, detail)
+	if jobStatus != types.JobStatusInterrupted && jobStatus != types.JobStatusCancelled &&
+		jobStatus != types.JobStatusReplaced && !job.Execution.DryRun {
+		o.recordOutcome(runCtx, job, failed, detail)
+	}
+}
+
+// recordOutcome counts an execution towards its event's quarantine, and
+// notifies the event's owner when the failure quarantines the event
+func (o *Agent) recordOutcome(ctx context.Context, job *types.Job, failed bool, detail string) {
+	eventID := quarantine.EventID(job)
+	entry, quarantined := o.quarantine.Record(eventID, job.ID, failed, detail)
+	if !quarantined {
+		return
+	}
+
+	o.metrics.SetQuarantinedEvents(float64(len(o.quarantine.Entries())))
+	o.log.WithFields(logrus.Fields{
+		"eventID":   eventID,
+		"jobID":     job.ID,
+		"failures":  entry.Failures,
+		"expiresAt": entry.ExpiresAt,
+	}).Warn("Event quarantined after repeated failures")
+
+	message := fmt.Sprintf("Event %s failed %d consecutive times and is quarantined on orchestrator %s. Its jobs are released without running until the quarantine is cleared",
+		eventID, entry.Failures, o.orchestratorID)
+	if entry.ExpiresAt != nil {
+		message += fmt.Sprintf(" or expires at %s", entry.ExpiresAt.Format(time.RFC3339))
+	}
+	err := o.apiClient.Notify(ctx, &api.Notification{
+		Type:           "event_quarantined",
+		Severity:       api.NotificationWarning,
+		Title:          "Event quarantined",
+		Message:        message + ".",
+		EventID:        eventID,
+		JobID:          job.ID,
+		OrchestratorID: o.orchestratorID,
+		Details: map[string]interface{}{
+			"failures":  entry.Failures,
+			"lastError": entry.LastError,
+		},
+		Timestamp: entry.QuarantinedAt,
+	})
+	if err != nil {
+		o.log.WithError(err).WithField("eventID", eventID).Warn("Failed to send quarantine notification")
+	}
+}
+
+// reportOverrun warns in the job's log and status, and by notification when
+// configured, that a run has gone on far longer than its event's runs
+// usually take
+func (o *Agent) reportOverrun(ctx context.Context, job *types.Job, jobLogger *logger.JobLogger, overrun durations.Overrun) {
+	message := fmt.Sprintf("Still running after %s; runs of event %s usually take %s",
+		overrun.Limit.Round(time.Second), overrun.EventID, overrun.Expected.Round(time.Second))
+	o.log.WithFields(logrus.Fields{
+		"jobID":    job.ID,
+		"eventID":  overrun.EventID,
+		"expected": overrun.Expected.String(),
+		"limit":    overrun.Limit.String(),
+	}).Warn("Job is running longer than expected")
+
+	jobLogger.AddLog(types.NewLogEntry("system", "Warning: "+message, 0))
+	o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusRunning, &types.StatusUpdate{
+		Status:  types.JobStatusRunning,
+		Message: message,
+	})
+
+	if !o.config.Jobs.Durations.Notify {
+		return
+	}
+	err := o.apiClient.Notify(ctx, &api.Notification{
+		Type:           "job_overrun",
+		Severity:       api.NotificationWarning,
+		Title:          "Job running longer than expected",
+		Message:        fmt.Sprintf("Job %s on orchestrator %s: %s.", job.ID, o.orchestratorID, message),
+		EventID:        overrun.EventID,
+		JobID:          job.ID,
+		OrchestratorID: o.orchestratorID,
+		Details: map[string]interface{}{
+			"expectedSeconds": overrun.Expected.Seconds(),
+			"limitSeconds":    overrun.Limit.Seconds(),
+			"samples":         overrun.Samples,
+		},
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		o.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to send overrun notification")
+	}
+}
+
+// CancelJob cancels a job and the jobs below it in its tree. Those running
+// here are stopped and report themselves cancelled, those waiting for a slot
+// are reported cancelled without running, and children submitted later are
+// cancelled as they arrive. It returns the IDs of the jobs stopped here, or
+// false when the job is not known to this orchestrator.
+func (o *Agent) CancelJob(ctx context.Context, jobID, reason string) ([]string, bool) {
+	ids, ok := o.lineage.Cancel(jobID, reason)
+	if !ok {
+		return nil, false
+	}
+	inTree := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		inTree[id] = true
+	}
+
+	stopped := []string{}
+	var dropped []*types.Job
+	o.mu.Lock()
+	for _, id := range ids {
+		job, active := o.activeJobs[id]
+		if !active {
+			continue
+		}
+		o.cancelled[id] = reason
+		if cancel, ok := o.cancels[id]; ok {
+			go o.stopJob(job, reason, cancel)
+		}
+		stopped = append(stopped, id)
+	}
+	kept := o.pending[:0]
+	for _, p := range o.pending {
+		if inTree[p.job.ID] {
+			dropped = append(dropped, p.job)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	o.pending = kept
+	pendingCount := len(o.pending)
+	o.mu.Unlock()
+
+	if len(dropped) > 0 {
+		o.metrics.SetPendingJobs(float64(pendingCount))
+	}
+	for _, job := range dropped {
+		o.reportCancelled(ctx, job, reason)
+		stopped = append(stopped, job.ID)
+	}
+
+	o.log.WithFields(logrus.Fields{
+		"jobID":   jobID,
+		"reason":  reason,
+		"stopped": stopped,
+	}).Warn("Job cancelled with its descendants")
+	return stopped, true
+}
+
+// stopJob has the executor stop a cancelled job gracefully, so that the job
+// exits on its stop signal and its output up to then is kept, and then ends
+// the job's context. Jobs the executor cannot stop, including those not
+// started yet, are stopped through the context right away.
+func (o *Agent) stopJob(job *types.Job, reason string, cancel context.CancelFunc) {
+	defer cancel()
+	// Warn the script first so it can clean up before its stop signal
+	sendCtx, cancelSend := context.WithTimeout(o.jobsCtx, 5*time.Second)
+	_, err := o.messenger.Send(sendCtx, job.ID, runtimecache.Message{Type: runtimecache.MessageCancel, Reason: reason})
+	cancelSend()
+	if err != nil {
+		o.log.WithError(err).WithField("jobID", job.ID).Debug("Failed to send cancellation notice")
+	}
+	if err := o.executorMgr.Cancel(o.jobsCtx, job, reason); err != nil {
+		o.log.WithError(err).WithField("jobID", job.ID).Debug("Executor did not stop cancelled job")
+	}
+}
+
+// SendJobMessage hands an operator message to the script of a job running
+// here, or returns false when the job is not running on this orchestrator.
+// The message is appended to the job's messages file, where scripts find it
+// even if they look later, and pushed to the runtime channels open at the
+// time. It fails only when neither way reached the job.
+func (o *Agent) SendJobMessage(ctx context.Context, jobID, message string, data any) (*runtimecache.Delivery, bool, error) {
+	job, ok := o.GetActiveJob(jobID)
+	if !ok {
+		return nil, false, nil
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	msg := runtimecache.Message{
+		Type:      runtimecache.MessageOperator,
+		ID:        "msg_" + hex.EncodeToString(id),
+		JobID:     jobID,
+		Message:   message,
+		Data:      data,
+		Source:    o.orchestratorID,
+		Timestamp: time.Now(),
+	}
+	delivery := &runtimecache.Delivery{ID: msg.ID}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to encode message: %w", err)
+	}
+	fileErr := o.executorMgr.DeliverMessage(ctx, job, line)
+	delivery.File = fileErr == nil
+
+	var pushErr error
+	if o.messenger != nil {
+		delivery.Channels, pushErr = o.messenger.Send(ctx, jobID, msg)
+	}
+
+	if !delivery.File && delivery.Channels == 0 {
+		if pushErr != nil {
+			return nil, true, pushErr
+		}
+		return nil, true, fmt.Errorf("job cannot receive messages: %w", fileErr)
+	}
+	if fileErr != nil {
+		o.log.WithError(fileErr).WithField("jobID", jobID).Debug("Message not written to the messages file")
+	}
+	return delivery, true, nil
+}
+
+// cancellationLoop polls the backend for jobs cancelled while they run or
+// wait here and cancels them like the admin API does. It runs whether or
+// not the push channel is connected, since the backend only records
+// cancellations.
+func (o *Agent) cancellationLoop(ctx context.Context) {
+	interval := o.config.Jobs.CancelPollInterval
+	if interval <= 0 {
+		return
+	}
+	ticker := o.jitter.NewTicker("cancellations", interval, o.config.Jitter.Poll)
+	defer ticker.Stop()
+
+	var since string
+	// The backend returns a cancellation on a few polls in a row
+	handled := make(map[string]time.Time)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		requests, next, err := o.apiClient.PollCancellations(ctx, since)
+		if err != nil {
+			o.log.WithError(err).Debug("Failed to poll job cancellations")
+			continue
+		}
+		since = next
+
+		now := time.Now()
+		for _, req := range requests {
+			if _, ok := handled[req.JobID]; ok {
+				continue
+			}
+			handled[req.JobID] = now
+			o.CancelJob(ctx, req.JobID, req.Reason)
+		}
+		for id, at := range handled {
+			if now.Sub(at) > cancellationMemory {
+				delete(handled, id)
+			}
+		}
+	}
+}
+
+// cancelledReason returns why a job was cancelled, if it was
+func (o *Agent) cancelledReason(jobID string) (string, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	reason, ok := o.cancelled[jobID]
+	return reason, ok
+}
+
+// reportCancelled reports a job cancelled before it could run to the end
+func (o *Agent) reportCancelled(ctx context.Context, job *types.Job, reason string) {
+	status := o.cancelStatus(job.ID)
+	o.mu.Lock()
+	delete(o.replacedBy, job.ID)
+	o.mu.Unlock()
+
+	message := "Job cancelled: " + reason
+	o.log.WithField("jobID", job.ID).Warn(message)
+	o.metrics.RecordJobFailed(string(job.Type), string(status))
+	o.lineage.SetStatus(job.ID, status)
+
+	o.apiClient.UpdateJobStatus(ctx, job.ID, status, &types.StatusUpdate{
+		Status:  status,
+		Message: message,
+	})
+}
+
+// cancelStatus returns the status of a cancelled job: replaced when a newer
+// run of its event cancelled it
+func (o *Agent) cancelStatus(jobID string) types.JobStatus {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if _, ok := o.replacedBy[jobID]; ok {
+		return types.JobStatusReplaced
+	}
+	return types.JobStatusCancelled
+}
+
+// applyConcurrencyPolicy enforces the concurrency policy of a job whose
+// event has earlier runs here, running or waiting for a slot. Under forbid
+// the job is skipped and true is returned; under replace the earlier runs
+// are cancelled, with their children, and the job goes ahead.
+func (o *Agent) applyConcurrencyPolicy(ctx context.Context, job *types.Job) bool {
+	policy := job.Execution.ConcurrencyPolicy
+	if policy == "" {
+		policy = types.ConcurrencyPolicy(o.config.Jobs.ConcurrencyPolicy)
+	}
+	eventID := quarantine.EventID(job)
+	// Children belong to their parent's run
+	if parentID, _ := job.SubmittedBy(); parentID != "" || eventID == "" || policy == types.ConcurrencyAllow {
+		return false
+	}
+
+	var previous []string
+	o.mu.RLock()
+	runs := make([]*types.Job, 0, len(o.activeJobs)+len(o.pending))
+	for _, j := range o.activeJobs {
+		runs = append(runs, j)
+	}
+	for _, p := range o.pending {
+		runs = append(runs, p.job)
+	}
+	for _, j := range runs {
+		// Runs already being cancelled don't count
+		_, cancelling := o.cancelled[j.ID]
+		if parentID, _ := j.SubmittedBy(); j.ID != job.ID && parentID == "" && !cancelling && quarantine.EventID(j) == eventID {
+			previous = append(previous, j.ID)
+		}
+	}
+	o.mu.RUnlock()
+	if len(previous) == 0 {
+		return false
+	}
+	sort.Strings(previous)
+	log := o.log.WithFields(logrus.Fields{
+		"jobID":    job.ID,
+		"eventID":  eventID,
+		"previous": previous,
+	})
+
+	if policy == types.ConcurrencyForbid {
+		message := fmt.Sprintf("Job skipped: event %s is still running (job %s)", eventID, strings.Join(previous, ", "))
+		log.Warn("Skipped run of event that is still running")
+		o.metrics.RecordJobFailed(string(job.Type), "skipped")
+		o.lineage.SetStatus(job.ID, types.JobStatusSkipped)
+
+		o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusSkipped, &types.StatusUpdate{
+			Status:  types.JobStatusSkipped,
+			Message: message,
+			Error: &types.ErrorDetails{
+				Type:      "concurrency",
+				Code:      "CONCURRENT_RUN",
+				Message:   message,
+				Retryable: false,
+				Details: map[string]interface{}{
+					"eventId":     eventID,
+					"runningJobs": previous,
+				},
+			},
+		})
+		return true
+	}
+
+	log.Info("Replacing earlier runs of event")
+	for _, id := range previous {
+		o.mu.Lock()
+		o.replacedBy[id] = job.ID
+		o.mu.Unlock()
+		o.CancelJob(ctx, id, "replaced by job "+job.ID)
+	}
+	return false
+}
+
+// jobDeadline returns the wall-clock time a job must finish by, or the zero
+// time when it has none
+func (o *Agent) jobDeadline(job *types.Job) (time.Time, error) {
+	defaultTZ := ""
+	if o.config.Jobs.Locale.Enabled {
+		defaultTZ = o.config.Jobs.Locale.TZ
+	}
+	return job.ResolveDeadline(defaultTZ)
+}
+
+// reportDeadlineMissed fails a job that has too little time left before its
+// deadline to start
+func (o *Agent) reportDeadlineMissed(ctx context.Context, job *types.Job, deadline time.Time, left time.Duration) {
+	message := fmt.Sprintf("Job not started: its deadline of %s has passed", deadline.Format(time.RFC3339))
+	if left > 0 {
+		message = fmt.Sprintf("Job not started: %s left before its deadline of %s, it needs at least %s",
+			left.Round(time.Second), deadline.Format(time.RFC3339), job.Execution.Deadline.MinDuration)
+	}
+	o.log.WithField("jobID", job.ID).Warn(message)
+	o.metrics.RecordJobFailed(string(job.Type), "deadline_exceeded")
+	o.lineage.SetStatus(job.ID, types.JobStatusDeadlineExceeded)
+
+	o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusDeadlineExceeded, &types.StatusUpdate{
+		Status:  types.JobStatusDeadlineExceeded,
+		Message: message,
+		Error: &types.ErrorDetails{
+			Type:      "deadline",
+			Code:      "DEADLINE_EXCEEDED",
+			Message:   message,
+			Retryable: false,
+			Details:   map[string]interface{}{"deadline": deadline},
+		},
+	})
+}
+
+// releaseQuarantined returns an acknowledged job of a quarantined event to
+// the backend without running it
+func (o *Agent) releaseQuarantined(ctx context.Context, job *types.Job, entry quarantine.Entry) {
+	detail := fmt.Sprintf("quarantined: event %s failed %d consecutive times", entry.EventID, entry.Failures)
+	err := o.apiClient.ReleaseJob(ctx, job.ID, &types.StatusUpdate{
+		Status:  types.JobStatusPending,
+		Message: detail,
+		Error: &types.ErrorDetails{
+			Type:      "quarantine",
+			Code:      "EVENT_QUARANTINED",
+			Message:   detail,
+			Retryable: false,
+			Details: map[string]interface{}{
+				"eventId":       entry.EventID,
+				"quarantinedAt": entry.QuarantinedAt,
+				"lastError":     entry.LastError,
+			},
+		},
+	})
+	if err != nil {
+		o.log.WithError(err).WithField("jobID", job.ID).Warn("Failed to release quarantined job")
+		return
+	}
+
+	o.metrics.RecordJobQuarantined(string(job.Type))
+	o.log.WithFields(logrus.Fields{
+		"jobID":   job.ID,
+		"eventID": entry.EventID,
+	}).Info("Released job of quarantined event")
+}
+
+// reportDeadLettered reports a job that failed every attempt so far as dead
+// lettered, without acknowledging or running it
+func (o *Agent) reportDeadLettered(ctx context.Context, job *types.Job, entry deadletter.Entry) {
+	detail := fmt.Sprintf("dead-lettered: job failed %d consecutive attempts", entry.Attempts)
+	fields := logrus.Fields{
+		"jobID":     job.ID,
+		"jobType":   job.Type,
+		"eventID":   entry.EventID,
+		"attempts":  entry.Attempts,
+		"lastError": entry.LastError,
+	}
+
+	err := o.apiClient.UpdateJobStatus(ctx, job.ID, types.JobStatusDeadLettered, &types.StatusUpdate{
+		Status:  types.JobStatusDeadLettered,
+		Message: detail,
+		Error: &types.ErrorDetails{
+			Type:      "dead_letter",
+			Code:      "JOB_DEAD_LETTERED",
+			Message:   detail,
+			Retryable: false,
+			Details: map[string]interface{}{
+				"attempts":       entry.Attempts,
+				"firstAttemptAt": entry.FirstAttemptAt,
+				"lastAttemptAt":  entry.LastAttemptAt,
+				"lastError":      entry.LastError,
+			},
+		},
+	})
+	if err != nil {
+		o.log.WithError(err).WithFields(fields).Warn("Failed to report dead-lettered job")
+		return
+	}
+
+	o.metrics.RecordJobDeadLettered(string(job.Type))
+	o.metrics.SetDeadLetteredJobs(float64(len(o.deadLetters.Entries())))
+	o.log.WithFields(fields).Error("Job dead-lettered after repeated failed attempts")
+}
+
+// payloadCleanupLoop periodically cleans up old payload files
+func (o *Agent) payloadCleanupLoop(ctx context.Context) {
+	interval := o.config.SSH.Execution.PayloadCleanupInterval
+	if interval <= 0 {
+		interval = time.Hour // Default to 1 hour if not set
+	}
+
+	ticker := o.jitter.NewTicker("payload-cleanup", interval, o.config.Jitter.Cleanup)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			retention := o.config.SSH.Execution.PayloadRetentionPeriod
+			if retention <= 0 {
+				retention = 24 * time.Hour // Default to 24 hours if not set
+			}
+
+			o.log.WithFields(logrus.Fields{
+				"retention": retention,
+				"backend":   o.config.SSH.Execution.PayloadStorage.Backend,
+			}).Debug("Running payload cleanup")
+
+			if err := o.payloads.CleanupOldPayloads(retention); err != nil {
+				o.log.WithError(err).Warn("Failed to cleanup old payloads")
+			} else {
+				o.log.Debug("Payload cleanup completed")
+			}
+		}
+	}
+}
+
+// healthCheckLoop periodically checks API health
+func (o *Agent) healthCheckLoop(ctx context.Context) {
+	ticker := o.jitter.NewTicker("api-health", 30*time.Second, o.config.Jitter.Health)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.apiClient.HealthCheck(ctx); err != nil {
+				o.log.WithError(err).Warn("API health check failed")
+			} else {
+				o.log.Debug("API health check passed")
+			}
+		}
+	}
+}
+
+// gracefulShutdown performs a graceful shutdown
+func (o *Agent) gracefulShutdown() error {
+	o.log.Info("Starting graceful shutdown")
+
+	o.mu.Lock()
+	o.isShuttingDown = true
+	activeCount := len(o.activeJobs)
+	pending := o.pending
+	o.pending = nil
+	o.mu.Unlock()
+
+	// Return jobs that never started to the queue
+	for _, p := range pending {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := o.apiClient.ReleaseJob(ctx, p.job.ID, &types.StatusUpdate{
+			Status:  types.JobStatusPending,
+			Message: "Released unstarted job during orchestrator shutdown",
+		})
+		cancel()
+		if err != nil {
+			o.log.WithError(err).WithField("jobID", p.job.ID).Warn("Failed to release pending job")
+		}
+	}
+
+	// Jobs still running when the shutdown ends are stopped
+	defer o.cancelJobs()
+
+	if activeCount > 0 {
+		// Checkpoint SSH jobs so they can be resumed; they stop and report
+		// themselves as interrupted
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		o.sshExec.Checkpoint(ctx)
+		cancel()
+
+		o.log.WithField("count", activeCount).Info("Waiting for active jobs to complete")
+
+		// Wait for jobs to complete with timeout
+		timeout := time.After(30 * time.Second)
+		ticker := time.NewTicker(1 * time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-timeout:
+				o.log.Warn("Timeout waiting for jobs to complete, stopping them")
+				return nil
+			case <-ticker.C:
+				o.mu.RLock()
+				remaining := len(o.activeJobs)
+				o.mu.RUnlock()
+
+				if remaining == 0 {
+					o.log.Info("All jobs completed")
+					return nil
+				}
+				o.log.WithField("remaining", remaining).Debug("Waiting for jobs")
+			}
+		}
+	}
+
+	return nil
+}
+
+// Drain stops taking new jobs and lets Run return once the accepted jobs
+// finished or the drain timeout passed. Calling it again has no effect.
+func (o *Agent) Drain() {
+	o.drainOnce.Do(func() { close(o.drain) })
+}
+
+// Draining reports whether drain mode has started
+func (o *Agent) Draining() bool {
+	select {
+	case <-o.drain:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown initiates a graceful shutdown
+func (o *Agent) Shutdown() {
+	close(o.shutdown)
+	<-o.done
+}
+
+// assignSlotLocked places a job in the first free concurrency slot; o.mu must be held
+func (o *Agent) assignSlotLocked(jobID string) {
+	for i, id := range o.slots {
+		if id == "" {
+			o.slots[i] = jobID
+			o.slotStarts[i] = time.Now()
+			return
+		}
+	}
+}
+
+// releaseSlotLocked frees the concurrency slot held by a job; o.mu must be held
+func (o *Agent) releaseSlotLocked(jobID string) {
+	for i, id := range o.slots {
+		if id == jobID {
+			o.slots[i] = ""
+			o.slotStarts[i] = time.Time{}
+			select {
+			case o.slotFreed <- struct{}{}:
+			default:
+			}
+			return
+		}
+	}
+}
+
+// busySlotsLocked returns the number of occupied slots; o.mu must be held
+func (o *Agent) busySlotsLocked() int {
+	busy := 0
+	for _, id := range o.slots {
+		if id != "" {
+			busy++
+		}
+	}
+	return busy
+}
+
+// yieldSlot frees the slot of a job that is held before it runs, so queued
+// jobs can use it meanwhile
+func (o *Agent) yieldSlot(ctx context.Context, jobID string) {
+	o.mu.Lock()
+	o.releaseSlotLocked(jobID)
+	o.mu.Unlock()
+	o.updateSlotMetrics()
+	o.dispatchPending(ctx)
+}
+
+// reacquireSlot takes a slot back for a job whose hold ended. It goes ahead
+// of locally queued jobs and waits while every slot is busy.
+func (o *Agent) reacquireSlot(ctx context.Context, jobID string) error {
+	o.mu.Lock()
+	o.resuming++
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		o.resuming--
+		o.mu.Unlock()
+	}()
+
+	for {
+		o.mu.Lock()
+		if o.busySlotsLocked() < o.config.Jobs.MaxConcurrent {
+			o.assignSlotLocked(jobID)
+			o.mu.Unlock()
+			o.updateSlotMetrics()
+			return nil
+		}
+		o.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-o.slotFreed:
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// updateSlotMetrics publishes slot occupancy and the age of each slot's job
+func (o *Agent) updateSlotMetrics() {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	occupied := 0
+	for i, id := range o.slots {
+		age := 0.0
+		if id != "" {
+			occupied++
+			age = time.Since(o.slotStarts[i]).Seconds()
+		}
+		o.metrics.SetSlotJobAge(i, age)
+	}
+	o.metrics.SetSlotOccupancy(float64(len(o.slots)), float64(occupied))
+}
+
+// waitForGates blocks until the job's gates hold. The job stays claimed at
+// the backend; the waiting state is local to this orchestrator.
+func (o *Agent) waitForGates(ctx context.Context, job *types.Job) error {
+	if err := o.gates.Validate(job); err != nil {
+		return err
+	}
+
+	o.holdJob(job.ID, types.JobStatusWaiting, job.Execution.Gates[0].Describe())
+	defer o.releaseJob(job.ID, types.JobStatusWaiting)
+	o.reportHold(ctx, job.ID, types.JobStatusWaiting, "Waiting on "+job.Execution.Gates[0].Describe())
+
+	o.log.WithField("jobID", job.ID).Infof("Waiting on %d gate(s)", len(job.Execution.Gates))
+
+	return o.gates.Wait(ctx, job, func(gate types.Gate, satisfied bool, err error) {
+		result := "unsatisfied"
+		switch {
+		case satisfied:
+			result = "satisfied"
+		case err != nil:
+			result = "error"
+		}
+		o.metrics.RecordGateCheck(string(gate.Type), result)
+
+		o.holdJob(job.ID, types.JobStatusWaiting, gate.Describe())
+	})
+}
+
+// waitForApproval holds the job until a human approves it. Like gates, the
+// awaiting state is local; the backend keeps the approval request itself.
+func (o *Agent) waitForApproval(ctx context.Context, job *types.Job) error {
+	detail := "approval"
+	if job.Execution.Approval.Message != "" {
+		detail = job.Execution.Approval.Message
+	}
+	o.holdJob(job.ID, types.JobStatusAwaitingApproval, detail)
+	defer o.releaseJob(job.ID, types.JobStatusAwaitingApproval)
+	o.reportHold(ctx, job.ID, types.JobStatusAwaitingApproval, detail)
+
+	o.log.WithField("jobID", job.ID).Info("Awaiting approval")
+
+	decision, err := o.gates.WaitForApproval(ctx, job)
+	switch {
+	case decision != nil:
+		o.metrics.RecordApproval(string(decision.Status))
+	case err != nil:
+		o.metrics.RecordApproval("error")
+	}
+	return err
+}
+
+// analyzeScript runs the static analysis stage. The report is stored in the
+// job metadata so executors attach it to the execution record; a blocked job
+// never reaches an executor, so its record is written here.
+func (o *Agent) analyzeScript(ctx context.Context, job *types.Job) error {
+	report, err := o.analyzer.Analyze(ctx, job)
+	if report == nil {
+		return err
+	}
+
+	o.mu.Lock()
+	if job.Metadata == nil {
+		job.Metadata = make(map[string]interface{})
+	}
+	job.Metadata["analysis"] = report
+	o.mu.Unlock()
+
+	result := "clean"
+	switch {
+	case report.Blocked:
+		result = "blocked"
+	case len(report.Findings) > 0:
+		result = "findings"
+	case len(report.Errors) > 0:
+		result = "error"
+	}
+	o.metrics.RecordAnalysis(report.Mode, result)
+	o.log.WithFields(logrus.Fields{
+		"jobID":    job.ID,
+		"mode":     report.Mode,
+		"tools":    report.Tools,
+		"findings": len(report.Findings),
+		"blocked":  report.Blocked,
+	}).Info("Static analysis finished")
+
+	if err != nil && report.Blocked {
+		executionID := fmt.Sprintf("exec_%s_%d", job.ID, time.Now().Unix())
+		if createErr := o.apiClient.CreateExecution(ctx, executionID, job, nil, nil); createErr != nil {
+			o.log.WithError(createErr).Warn("Failed to create execution record")
+			return err
+		}
+		now := time.Now()
+		message := err.Error()
+		o.apiClient.UpdateExecution(ctx, executionID, types.JobStatusFailed, &api.ExecutionStatusUpdate{
+			StartedAt:         &now,
+			CompletedAt:       &now,
+			Error:             &message,
+			ExecutionMetadata: map[string]interface{}{"analysis": report},
+		})
+	}
+	return err
+}
+
+// holdJob records that a job is held before execution
+func (o *Agent) holdJob(jobID string, status types.JobStatus, detail string) {
+	o.mu.Lock()
+	_, exists := o.held[jobID]
+	o.held[jobID] = jobHold{status: status, detail: detail}
+	o.mu.Unlock()
+	o.lineage.SetStatus(jobID, status)
+	if !exists {
+		o.metrics.IncWaitingJobs(string(status))
+	}
+}
+
+// reportHold tells the backend what a claimed job is held for. The job stays
+// claimed there; a later running status clears the hold.
+func (o *Agent) reportHold(ctx context.Context, jobID string, status types.JobStatus, message string) {
+	if err := o.apiClient.UpdateJobStatus(ctx, jobID, status, &types.StatusUpdate{
+		Status:  status,
+		Message: message,
+	}); err != nil {
+		o.log.WithError(err).WithField("jobID", jobID).Debug("Failed to report job hold")
+	}
+}
+
+// releaseJob clears a job's hold
+func (o *Agent) releaseJob(jobID string, status types.JobStatus) {
+	o.mu.Lock()
+	delete(o.held, jobID)
+	o.mu.Unlock()
+	o.metrics.DecWaitingJobs(string(status))
+}
+
+// ID returns the agent's orchestrator ID
+func (o *Agent) ID() string {
+	return o.orchestratorID
+}
+
+// Features returns the feature flag registry
+func (o *Agent) Features() *features.Registry {
+	return o.features
+}
+
+// Metrics returns the metrics collector
+func (o *Agent) Metrics() *metrics.Collector {
+	return o.metrics
+}
+
+// Quarantine returns the list of quarantined events, or nil when quarantine
+// is disabled
+func (o *Agent) Quarantine() *quarantine.List {
+	return o.quarantine
+}
+
+// DeadLetters returns the jobs refused after repeated failed attempts, or nil
+// when dead-lettering is disabled
+func (o *Agent) DeadLetters() *deadletter.List {
+	return o.deadLetters
+}
+
+// RunnerCache returns the runners known to be deployed on SSH servers
+func (o *Agent) RunnerCache() *ssh.RunnerCache {
+	return o.sshExec.RunnerCache()
+}
+
+// JobTree returns the tree of jobs submitted by other jobs that a job
+// belongs to, as far as this orchestrator knows it
+func (o *Agent) JobTree(jobID string) (*lineage.Node, bool) {
+	return o.lineage.Tree(jobID)
+}
+
+// LogStreamer returns the job log streamer
+func (o *Agent) LogStreamer() *logger.Streamer {
+	return o.logStreamer
+}
+
+// SandboxProfiles returns the container sandbox profiles, or nil when
+// container execution is unavailable
+func (o *Agent) SandboxProfiles() *sandbox.Catalog {
+	if o.kubeExec != nil {
+		return o.kubeExec.Sandbox()
+	}
+	if o.containerExec == nil {
+		return nil
+	}
+	return o.containerExec.Sandbox()
+}
+
+// ActiveJobs returns the jobs currently being executed
+func (o *Agent) ActiveJobs() []*types.Job {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	jobs := make([]*types.Job, 0, len(o.activeJobs))
+	for _, job := range o.activeJobs {
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// GetActiveJob returns a running job by ID
+func (o *Agent) GetActiveJob(jobID string) (*types.Job, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	job, ok := o.activeJobs[jobID]
+	return job, ok
+}
+
+// JobState returns whether a job is held before execution or running, and
+// what it is waiting on
+func (o *Agent) JobState(jobID string) (types.JobStatus, string) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if hold, ok := o.held[jobID]; ok {
+		return hold.status, hold.detail
+	}
+	return types.JobStatusRunning, ""
+}
+
+// SampleJobStats returns the current resource usage of a running job
+func (o *Agent) SampleJobStats(ctx context.Context, job *types.Job) (*types.ResourceSample, error) {
+	return o.executorMgr.SampleStats(ctx, job)
+}
@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/api"
+	"github.com/sirupsen/logrus"
+)
+
+// CheckCompatibility verifies the Docker API version and performs the backend
+// version handshake. It returns one error describing every incompatibility.
+func (o *Agent) CheckCompatibility(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var problems []string
+	fields := logrus.Fields{}
+
+	if o.containerExec != nil {
+		version, err := o.containerExec.CheckAPIVersion(ctx)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		fields["dockerAPIVersion"] = version
+	}
+
+	info, err := o.apiClient.CheckCompatibility(ctx)
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	if info != nil {
+		fields["backendVersion"] = info.Version
+		fields["contractVersion"] = info.ContractVersion
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("incompatible environment: %s", strings.Join(problems, "; "))
+	}
+
+	fields["supportedContracts"] = fmt.Sprintf("%d-%d", api.MinContractVersion, api.MaxContractVersion)
+	o.log.WithFields(fields).Info("Compatibility checks passed")
+	return nil
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/config"
+	"github.com/addison-moore/cronium/apps/orchestrator/internal/executors"
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Config is the agent configuration, as read from cronium-orchestrator.yaml
+type Config = config.Config
+
+// Executor runs jobs of one type. Implementations registered with
+// WithExecutor receive jobs of that type from the agent.
+type Executor = executors.Executor
+
+// LoadConfig loads the configuration from a file (empty for the default
+// search path) and the environment, and validates it
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// Option configures an Agent
+type Option func(*options)
+
+// options collects the settings applied by Option values
+type options struct {
+	log          *logrus.Logger
+	executors    map[types.JobType]Executor
+	noContainers bool
+	runtimeHost  string
+	runtimePort  int
+}
+
+// WithLogger sets the logger. Without it the agent logs to stdout as
+// configured in the logging section.
+func WithLogger(log *logrus.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// WithExecutor registers an executor for a job type, replacing the built-in
+// one if there is one
+func WithExecutor(jobType types.JobType, executor Executor) Option {
+	return func(o *options) {
+		o.executors[jobType] = executor
+	}
+}
+
+// WithoutContainers skips the Docker executor, for agents that only run
+// SSH jobs or their own executors on hosts without a Docker daemon
+func WithoutContainers() Option {
+	return func(o *options) {
+		o.noContainers = true
+	}
+}
+
+// WithRuntimeAPI sets the runtime service address that SSH runners call back
+// to. It defaults to RUNTIME_HOST and RUNTIME_PORT, then runtime-api:8089.
+func WithRuntimeAPI(host string, port int) Option {
+	return func(o *options) {
+		o.runtimeHost = host
+		o.runtimePort = port
+	}
+}
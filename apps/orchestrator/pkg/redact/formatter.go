@@ -0,0 +1,26 @@
+package redact
+
+import "github.com/sirupsen/logrus"
+
+// Formatter wraps a logrus formatter and scrubs registered secrets from every
+// formatted entry, including fields and error messages
+type Formatter struct {
+	logrus.Formatter
+}
+
+// NewFormatter wraps f, unless it is already wrapped
+func NewFormatter(f logrus.Formatter) logrus.Formatter {
+	if _, ok := f.(*Formatter); ok {
+		return f
+	}
+	return &Formatter{Formatter: f}
+}
+
+// Format formats the entry with the wrapped formatter and masks secrets
+func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
+	data, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(String(string(data))), nil
+}
@@ -0,0 +1,254 @@
+// Package redact hides secrets before values are printed or logged. Fields are
+// marked as secret with a struct tag:
+//
+//	Token string `yaml:"token" secret:"true"`
+//
+// Copy returns a deep copy of a value with every secret field masked, for
+// printing whole structures. Register records the secret values of a
+// structure so String can scrub them from free-form text such as error
+// messages, log lines and panic dumps. Secrets that only live as long as a
+// job are registered with Scope and dropped again when the job ends.
+package redact
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask replaces secret values
+const Mask = "***hidden***"
+
+// minSecretLength keeps short values such as "1" or "yes" from being
+// scrubbed out of unrelated text
+const minSecretLength = 6
+
+var (
+	mu sync.RWMutex
+	// Secret value -> number of holders; permanent registrations hold a
+	// value forever
+	secrets   = make(map[string]int)
+	permanent = make(map[string]struct{})
+	sorted    []string
+)
+
+// Copy returns a deep copy of v with all fields tagged secret:"true" masked.
+// Empty secrets stay empty so that printed output still shows they are unset.
+func Copy[T any](v T) T {
+	out := copyValue(reflect.ValueOf(&v).Elem(), false)
+	return out.Interface().(T)
+}
+
+// Register records the values of the secret fields in v so String and the
+// log formatter hide them
+func Register(v any) {
+	var found []string
+	collect(reflect.ValueOf(v), false, &found)
+	Add(found...)
+}
+
+// Add records secret values directly
+func Add(values ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, value := range forms(values) {
+		if _, ok := permanent[value]; ok {
+			continue
+		}
+		permanent[value] = struct{}{}
+		secrets[value]++
+	}
+	resort()
+}
+
+// Scope records the secret values of vs until the returned release function
+// is called. Values registered by other scopes or permanently stay hidden
+// until their last holder releases them.
+func Scope(vs ...any) (release func()) {
+	var found []string
+	for _, v := range vs {
+		collect(reflect.ValueOf(v), false, &found)
+	}
+	values := forms(found)
+
+	mu.Lock()
+	for _, value := range values {
+		secrets[value]++
+	}
+	resort()
+	mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, value := range values {
+				if secrets[value]--; secrets[value] <= 0 {
+					delete(secrets, value)
+				}
+			}
+			resort()
+		})
+	}
+}
+
+// forms returns the distinct values worth hiding, with the escaped form JSON
+// log lines carry for secrets with quotes, backslashes or control characters
+func forms(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	var out []string
+	for _, value := range values {
+		if len(value) < minSecretLength {
+			continue
+		}
+		candidates := []string{value}
+		if quoted, err := json.Marshal(value); err == nil {
+			if escaped := string(quoted[1 : len(quoted)-1]); escaped != value {
+				candidates = append(candidates, escaped)
+			}
+		}
+		for _, c := range candidates {
+			if _, ok := seen[c]; !ok {
+				seen[c] = struct{}{}
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// resort rebuilds the replacement order, longest first so a secret
+// containing another is replaced whole. The caller holds mu.
+func resort() {
+	sorted = sorted[:0]
+	for value := range secrets {
+		sorted = append(sorted, value)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+}
+
+// String replaces every registered secret in s with Mask
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, secret := range sorted {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, Mask)
+		}
+	}
+	return s
+}
+
+// isSecret reports whether a struct field is tagged as secret
+func isSecret(field reflect.StructField) bool {
+	return field.Tag.Get("secret") == "true"
+}
+
+// copyValue deep-copies v, masking strings when secret is set
+func copyValue(v reflect.Value, secret bool) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+
+	switch v.Kind() {
+	case reflect.String:
+		if secret && v.Len() > 0 {
+			out.SetString(Mask)
+			return out
+		}
+		out.Set(v)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return out
+		}
+		elem := copyValue(v.Elem(), secret)
+		ptr := reflect.New(elem.Type())
+		ptr.Elem().Set(elem)
+		out.Set(ptr)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return out
+		}
+		out.Set(copyValue(v.Elem(), secret))
+
+	case reflect.Struct:
+		// Start from a shallow copy so unexported fields are kept
+		out.Set(v)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			out.Field(i).Set(copyValue(v.Field(i), secret || isSecret(field)))
+		}
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return out
+		}
+		out.Set(reflect.MakeSlice(v.Type(), v.Len(), v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copyValue(v.Index(i), secret))
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(copyValue(v.Index(i), secret))
+		}
+
+	case reflect.Map:
+		if v.IsNil() {
+			return out
+		}
+		out.Set(reflect.MakeMapWithSize(v.Type(), v.Len()))
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), copyValue(iter.Value(), secret))
+		}
+
+	default:
+		out.Set(v)
+	}
+	return out
+}
+
+// collect appends the non-empty secret strings reachable from v
+func collect(v reflect.Value, secret bool, found *[]string) {
+	switch v.Kind() {
+	case reflect.String:
+		if secret && v.Len() > 0 {
+			*found = append(*found, v.String())
+		}
+
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collect(v.Elem(), secret, found)
+		}
+
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.IsExported() {
+				collect(v.Field(i), secret || isSecret(field), found)
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collect(v.Index(i), secret, found)
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collect(iter.Value(), secret, found)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type credentials struct {
+	Password string `secret:"true"`
+}
+
+func TestScopeReleasesSecrets(t *testing.T) {
+	first := Scope(credentials{Password: "hunter2-first"})
+	second := Scope(credentials{Password: "hunter2-first"}, credentials{Password: "hunter2-second"})
+	assert.Equal(t, "pw="+Mask+" "+Mask, String("pw=hunter2-first hunter2-second"))
+
+	second()
+	assert.Equal(t, "pw="+Mask+" hunter2-second", String("pw=hunter2-first hunter2-second"),
+		"a secret stays hidden while another scope holds it")
+
+	first()
+	first()
+	assert.Equal(t, "pw=hunter2-first", String("pw=hunter2-first"))
+}
+
+func TestScopeKeepsPermanentSecrets(t *testing.T) {
+	Add("permanent-secret")
+	Scope(credentials{Password: "permanent-secret"})()
+	assert.Equal(t, Mask, String("permanent-secret"))
+}
@@ -0,0 +1,202 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cronField describes one field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names for min, min+1, ...
+	special  bool     // allows ?, L and the day-of-week # and L suffixes
+}
+
+var (
+	secondField = cronField{name: "second", min: 0, max: 59}
+	cronFields  = []cronField{
+		{name: "minute", min: 0, max: 59},
+		{name: "hour", min: 0, max: 23},
+		{name: "day of month", min: 1, max: 31, special: true},
+		{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+		{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}, special: true},
+	}
+)
+
+// bits is a set of field values
+type bits uint64
+
+func (b bits) has(n int) bool { return b&(1<<uint(n)) != 0 }
+
+// span returns the set of values from low to high in steps of step
+func span(low, high, step int) bits {
+	var b bits
+	for n := low; n <= high; n += step {
+		b |= 1 << uint(n)
+	}
+	return b
+}
+
+// Schedule is a parsed cron expression: five fields, or six with a leading
+// seconds field. Fields take values, names (JAN, MON), ranges, steps and
+// lists; day of month and day of week also take ?, L, 5L and 1#2.
+type Schedule struct {
+	expr string
+
+	second, minute, hour, dom, month, dow bits
+	// Day of month L
+	lastDay bool
+	// Day of week 5L, by weekday
+	lastWeekday bits
+	// Day of week 1#2, as a set of occurrences (1-5) by weekday
+	nthWeekday [7]bits
+	// A field starting with * or ?; days then match when both fields match,
+	// otherwise when either does
+	domStar, dowStar bool
+}
+
+// Parse parses a cron expression in the syntax the backend scheduler accepts
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	s := &Schedule{expr: expr, second: 1}
+	specs := cronFields
+	targets := []*bits{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	switch len(fields) {
+	case 5:
+	case 6:
+		specs = append([]cronField{secondField}, cronFields...)
+		targets = append([]*bits{&s.second}, targets...)
+	default:
+		return nil, fmt.Errorf("expected 5 or 6 fields, got %d", len(fields))
+	}
+
+	for i, field := range fields {
+		set, err := specs[i].parse(field, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s field %q: %w", specs[i].name, field, err)
+		}
+		*targets[i] = set
+	}
+	// Sunday is both 0 and 7
+	if s.dow.has(7) {
+		s.dow |= 1
+	}
+	s.domStar = strings.HasPrefix(fields[len(fields)-3], "*") || strings.HasPrefix(fields[len(fields)-3], "?")
+	s.dowStar = strings.HasPrefix(fields[len(fields)-1], "*") || strings.HasPrefix(fields[len(fields)-1], "?")
+	return s, nil
+}
+
+// Validate checks a cron expression
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// String returns the expression the schedule was parsed from
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// parse parses one field's comma-separated items, recording the day
+// specials on s
+func (f cronField) parse(field string, s *Schedule) (bits, error) {
+	var set bits
+	for _, item := range strings.Split(field, ",") {
+		b, err := f.parseItem(strings.ToUpper(item), s)
+		if err != nil {
+			return 0, err
+		}
+		set |= b
+	}
+	return set, nil
+}
+
+// parseItem parses a single value, range or step
+func (f cronField) parseItem(item string, s *Schedule) (bits, error) {
+	if item == "" {
+		return 0, fmt.Errorf("empty list item")
+	}
+	if f.special {
+		switch {
+		case item == "?":
+			return span(f.min, f.max, 1), nil
+		case item == "L":
+			if f.max == 7 {
+				// L alone in day of week is Saturday
+				return 1 << 6, nil
+			}
+			s.lastDay = true
+			return 0, nil
+		case f.max == 7 && strings.HasSuffix(item, "L"):
+			day, err := f.value(strings.TrimSuffix(item, "L"))
+			if err != nil {
+				return 0, err
+			}
+			s.lastWeekday |= 1 << uint(day%7)
+			return 0, nil
+		case f.max == 7 && strings.Contains(item, "#"):
+			day, nth, _ := strings.Cut(item, "#")
+			d, err := f.value(day)
+			if err != nil {
+				return 0, err
+			}
+			n, err := strconv.Atoi(nth)
+			if err != nil || n < 1 || n > 5 {
+				return 0, fmt.Errorf("occurrence %q must be between 1 and 5", nth)
+			}
+			s.nthWeekday[d%7] |= 1 << uint(n)
+			return 0, nil
+		}
+	}
+
+	rangePart, stepPart, hasStep := strings.Cut(item, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepPart)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid step %q", stepPart)
+		}
+		step = n
+	}
+
+	if rangePart == "*" {
+		return span(f.min, f.max, step), nil
+	}
+	low, high, isRange := strings.Cut(rangePart, "-")
+	start, err := f.value(low)
+	if err != nil {
+		return 0, err
+	}
+	end := start
+	if isRange {
+		if end, err = f.value(high); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("range %s is reversed", rangePart)
+		}
+	} else if hasStep {
+		// 5/15 runs from 5 to the end of the field
+		end = f.max
+	}
+	return span(start, end, step), nil
+}
+
+// value parses a number or name and checks it is in range
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if s == name {
+			return f.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if n < f.min || n > f.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", n, f.min, f.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,240 @@
+// Package schedule parses cron expressions and works out when they run in a
+// time zone, including across daylight saving time changes.
+//
+// Times are matched against the wall clock. When DST skips a wall-clock time,
+// a run at a fixed hour happens at the end of the gap instead, so a 02:30 job
+// runs at 03:00 the night clocks go forward, while jobs that run every hour
+// simply have no run in the missing hour. When DST repeats a wall-clock time,
+// a run at a fixed hour happens only the first time and jobs that run every
+// hour run in both, so an hourly job still runs once per elapsed hour.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// searchLimit bounds how far ahead a schedule that never matches, such as
+// 30 February, is searched
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// DST describes how a daylight saving time change affected a run
+type DST string
+
+const (
+	// DSTNone is a run at its scheduled wall-clock time
+	DSTNone DST = ""
+	// DSTShifted is a run whose wall-clock time was skipped, moved to the end
+	// of the gap
+	DSTShifted DST = "shifted"
+	// DSTOnce is a run whose wall-clock time occurs twice and that runs only
+	// the first time
+	DSTOnce DST = "once"
+	// DSTRepeated is the second run of a wall-clock time that occurs twice
+	DSTRepeated DST = "repeated"
+)
+
+// Run is one time a schedule fires
+type Run struct {
+	Time time.Time `json:"time"`
+	// Wall-clock time the expression names, as 2006-01-02 15:04:05; differs
+	// from Time for shifted runs
+	Scheduled string `json:"scheduled"`
+	DST       DST    `json:"dst,omitempty"`
+}
+
+// Next returns the first run strictly after after in loc, or false when the
+// schedule never fires again
+func (s *Schedule) Next(after time.Time, loc *time.Location) (Run, bool) {
+	after = after.In(loc)
+	start := wall(after).Truncate(time.Second)
+	// In the second pass through a repeated hour, earlier wall-clock times are
+	// still ahead
+	if begin, _ := after.ZoneBounds(); !begin.IsZero() {
+		_, before := begin.Add(-time.Nanosecond).Zone()
+		if _, offset := after.Zone(); before > offset {
+			start = start.Add(-time.Duration(before-offset) * time.Second)
+		}
+	}
+
+	var best Run
+	found := false
+	limit := start.Add(searchLimit)
+	for c := start; c.Before(limit); c = c.Add(time.Second) {
+		var ok bool
+		if c, ok = s.nextWall(c, limit); !ok {
+			break
+		}
+		runs := s.runsAt(c, loc)
+		later := len(runs) > 0
+		for _, run := range runs {
+			if !run.Time.After(after) {
+				later = false
+				continue
+			}
+			if !found || run.Time.Before(best.Time) {
+				best, found = run, true
+			}
+			if !run.Time.After(best.Time) {
+				later = false
+			}
+		}
+		// Past the best run no later wall-clock time can run sooner
+		if found && later {
+			break
+		}
+	}
+	return best, found
+}
+
+// Preview returns the next count runs after from in loc
+func (s *Schedule) Preview(from time.Time, loc *time.Location, count int) []Run {
+	var runs []Run
+	for len(runs) < count {
+		run, ok := s.Next(from, loc)
+		if !ok {
+			break
+		}
+		runs = append(runs, run)
+		from = run.Time
+	}
+	return runs
+}
+
+// DSTRuns returns the runs between from and to in loc that a DST change
+// shifted, ran once or repeated
+func (s *Schedule) DSTRuns(from, to time.Time, loc *time.Location) []Run {
+	var affected []Run
+	for t := from.In(loc); t.Before(to); {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		// Gaps and repeats last a few hours at most
+		for at := end.Add(-3 * time.Hour); ; {
+			run, ok := s.Next(at, loc)
+			if !ok || run.Time.After(end.Add(3*time.Hour)) {
+				break
+			}
+			if run.DST != DSTNone && !run.Time.Before(from) && run.Time.Before(to) {
+				affected = append(affected, run)
+			}
+			at = run.Time
+		}
+		t = end
+	}
+	return affected
+}
+
+// runsAt returns the runs of a matching wall-clock time, held as a UTC time
+func (s *Schedule) runsAt(c time.Time, loc *time.Location) []Run {
+	scheduled := c.Format(time.DateTime)
+	everyHour := s.hour == span(0, 23, 1)
+	times := instants(c, loc)
+	switch {
+	case len(times) == 1:
+		return []Run{{Time: times[0], Scheduled: scheduled}}
+	case len(times) == 0 && everyHour:
+		return nil
+	case len(times) == 0:
+		return []Run{{Time: gapEnd(c, loc), Scheduled: scheduled, DST: DSTShifted}}
+	case everyHour:
+		return []Run{
+			{Time: times[0], Scheduled: scheduled},
+			{Time: times[1], Scheduled: scheduled, DST: DSTRepeated},
+		}
+	default:
+		return []Run{{Time: times[0], Scheduled: scheduled, DST: DSTOnce}}
+	}
+}
+
+// nextWall returns the first matching wall-clock time from c, held as a UTC
+// time, searching up to limit
+func (s *Schedule) nextWall(c, limit time.Time) (time.Time, bool) {
+	for c.Before(limit) {
+		year, month, day := c.Date()
+		switch {
+		case !s.month.has(int(month)):
+			c = time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchDay(c):
+			c = time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+		case !s.hour.has(c.Hour()):
+			c = c.Truncate(time.Hour).Add(time.Hour)
+		case !s.minute.has(c.Minute()):
+			c = c.Truncate(time.Minute).Add(time.Minute)
+		case !s.second.has(c.Second()):
+			c = c.Add(time.Second)
+		default:
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchDay reports whether the day of month and day of week fields match
+func (s *Schedule) matchDay(c time.Time) bool {
+	day, weekday := c.Day(), int(c.Weekday())
+	last := time.Date(c.Year(), c.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	domMatch := s.dom.has(day) || s.lastDay && day == last
+	dowMatch := s.dow.has(weekday) ||
+		s.lastWeekday.has(weekday) && day+7 > last ||
+		s.nthWeekday[weekday].has((day-1)/7+1)
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// wall returns t's wall-clock time as a UTC time
+func wall(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// instants returns the times that have wall-clock time c in loc: none in a
+// DST gap, two in a repeated hour
+func instants(c time.Time, loc *time.Location) []time.Time {
+	var times []time.Time
+	seen := map[int]bool{}
+	for _, probe := range []time.Time{c.Add(-24 * time.Hour), c, c.Add(24 * time.Hour)} {
+		_, offset := probe.In(loc).Zone()
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		t := c.Add(-time.Duration(offset) * time.Second).In(loc)
+		if wall(t).Equal(c) {
+			times = append(times, t)
+		}
+	}
+	if len(times) == 2 && times[1].Before(times[0]) {
+		times[0], times[1] = times[1], times[0]
+	}
+	return times
+}
+
+// gapEnd returns the time a DST gap containing wall-clock time c ends
+func gapEnd(c time.Time, loc *time.Location) time.Time {
+	_, before := c.Add(-24 * time.Hour).In(loc).Zone()
+	begin, _ := c.Add(-time.Duration(before) * time.Second).In(loc).ZoneBounds()
+	return begin
+}
+
+// Describe explains how DST affected a run, or returns "" for a run at its
+// scheduled time
+func (r Run) Describe() string {
+	clock := r.Scheduled
+	if _, after, ok := strings.Cut(clock, " "); ok {
+		clock = after
+	}
+	switch r.DST {
+	case DSTShifted:
+		return fmt.Sprintf("%s does not exist, runs when clocks go forward", clock)
+	case DSTOnce:
+		return fmt.Sprintf("%s occurs twice, runs the first time only", clock)
+	case DSTRepeated:
+		return fmt.Sprintf("%s occurs twice, runs again", clock)
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	return loc
+}
+
+func preview(t *testing.T, expr string, from time.Time, count int) []Run {
+	t.Helper()
+	s, err := Parse(expr)
+	require.NoError(t, err)
+	return s.Preview(from, from.Location(), count)
+}
+
+func format(runs []Run) []string {
+	var out []string
+	for _, run := range runs {
+		out = append(out, run.Time.Format("2006-01-02 15:04 MST")+" "+string(run.DST))
+	}
+	return out
+}
+
+func TestParse(t *testing.T) {
+	for _, expr := range []string{
+		"* * * * *",
+		"*/15 9-17 * * MON-FRI",
+		"0 0 L * ?",
+		"0 0 ? * 5L",
+		"0 0 ? * 1#2",
+		"30 0 0 1 JAN,jul *",
+	} {
+		assert.NoError(t, Validate(expr), expr)
+	}
+
+	for expr, msg := range map[string]string{
+		"* * * *":       "expected 5 or 6 fields, got 4",
+		"60 * * * *":    `minute field "60": value 60 out of range 0-59`,
+		"* * * * 1#6":   `day of week field "1#6": occurrence "6" must be between 1 and 5`,
+		"* 5-1 * * *":   `hour field "5-1": range 5-1 is reversed`,
+		"* * * FOO * *": `day of month field "FOO": invalid value "FOO"`,
+		"*/0 * * * *":   `minute field "*/0": invalid step "0"`,
+	} {
+		assert.EqualError(t, Validate(expr), msg, expr)
+	}
+}
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, []string{
+		"2026-01-30 12:15 UTC ",
+		"2026-01-30 12:30 UTC ",
+	}, format(preview(t, "*/15 * * * *", from, 2)))
+	assert.Equal(t, []string{
+		"2026-01-31 00:00 UTC ",
+		"2026-02-28 00:00 UTC ",
+	}, format(preview(t, "0 0 L * *", from, 2)))
+	assert.Equal(t, []string{
+		"2026-02-09 00:00 UTC ",
+		"2026-03-09 00:00 UTC ",
+	}, format(preview(t, "0 0 ? * MON#2", from, 2)))
+	assert.Equal(t, []string{
+		"2026-01-30 00:00 UTC ",
+		"2026-02-27 00:00 UTC ",
+	}, format(preview(t, "0 0 * * 5L", from.Add(-24*time.Hour), 2)))
+	// Restricting both days matches either
+	assert.Equal(t, []string{
+		"2026-02-01 00:00 UTC ",
+		"2026-02-02 00:00 UTC ",
+		"2026-02-09 00:00 UTC ",
+	}, format(preview(t, "0 0 1 * MON", from, 3)))
+	assert.Equal(t, []string{
+		"2026-01-30 12:00 UTC ",
+	}, format(preview(t, "10 0 12 * * *", from, 1)))
+
+	s, err := Parse("0 0 30 FEB *")
+	require.NoError(t, err)
+	_, ok := s.Next(from, time.UTC)
+	assert.False(t, ok)
+}
+
+func TestSpringForward(t *testing.T) {
+	loc := berlin(t)
+	// Clocks go from 02:00 to 03:00 on 29 March 2026
+	from := time.Date(2026, 3, 28, 12, 0, 0, 0, loc)
+
+	runs := preview(t, "30 2 * * *", from, 2)
+	assert.Equal(t, []string{
+		"2026-03-29 03:00 CEST shifted",
+		"2026-03-30 02:30 CEST ",
+	}, format(runs))
+	assert.Equal(t, "2026-03-29 02:30:00", runs[0].Scheduled)
+
+	// Several skipped times run once at the end of the gap
+	assert.Equal(t, []string{
+		"2026-03-29 01:45 CET ",
+		"2026-03-29 03:00 CEST shifted",
+		"2026-03-29 03:15 CEST ",
+	}, format(preview(t, "*/15 1-3 * * *", time.Date(2026, 3, 29, 1, 30, 0, 0, loc), 3)))
+
+	// Hourly jobs skip the missing hour
+	assert.Equal(t, []string{
+		"2026-03-29 01:30 CET ",
+		"2026-03-29 03:30 CEST ",
+	}, format(preview(t, "30 * * * *", time.Date(2026, 3, 29, 1, 0, 0, 0, loc), 2)))
+}
+
+func TestFallBack(t *testing.T) {
+	loc := berlin(t)
+	// Clocks go from 03:00 back to 02:00 on 25 October 2026
+	from := time.Date(2026, 10, 24, 12, 0, 0, 0, loc)
+
+	assert.Equal(t, []string{
+		"2026-10-25 02:30 CEST once",
+		"2026-10-26 02:30 CET ",
+	}, format(preview(t, "30 2 * * *", from, 2)))
+
+	assert.Equal(t, []string{
+		"2026-10-25 01:30 CEST ",
+		"2026-10-25 02:30 CEST ",
+		"2026-10-25 02:30 CET repeated",
+		"2026-10-25 03:30 CET ",
+	}, format(preview(t, "30 * * * *", time.Date(2026, 10, 25, 1, 0, 0, 0, loc), 4)))
+
+	// From inside the repeated hour the earlier wall-clock times are still ahead
+	second := time.Date(2026, 10, 25, 1, 45, 0, 0, time.UTC).In(loc)
+	require.Equal(t, "02:45 CET", second.Format("15:04 MST"))
+	assert.Equal(t, []string{
+		"2026-10-25 03:00 CET ",
+	}, format(preview(t, "0 * * * *", second, 1)))
+	assert.Equal(t, []string{
+		"2026-10-25 02:50 CET repeated",
+	}, format(preview(t, "50 * * * *", second, 1)))
+}
+
+func TestDSTRuns(t *testing.T) {
+	loc := berlin(t)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(1, 0, 0)
+
+	s, err := Parse("30 2 * * *")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"2026-03-29 03:00 CEST shifted",
+		"2026-10-25 02:30 CEST once",
+	}, format(s.DSTRuns(from, to, loc)))
+
+	s, err = Parse("0 12 * * *")
+	require.NoError(t, err)
+	assert.Empty(t, s.DSTRuns(from, to, loc))
+	assert.Empty(t, s.DSTRuns(from, to, time.UTC))
+}
@@ -0,0 +1,61 @@
+package types
+
+import "fmt"
+
+// Analysis modes
+const (
+	AnalysisModeOff   = "off"
+	AnalysisModeWarn  = "warn"
+	AnalysisModeBlock = "block"
+)
+
+// Analysis finding severities, from most to least severe
+const (
+	AnalysisSeverityError   = "error"
+	AnalysisSeverityWarning = "warning"
+	AnalysisSeverityInfo    = "info"
+)
+
+// AnalysisPolicy overrides the configured static analysis mode for a job
+type AnalysisPolicy struct {
+	Mode string `json:"mode,omitempty"` // off, warn or block
+}
+
+// AnalysisFinding is one issue reported by a linter or scanner
+type AnalysisFinding struct {
+	Tool     string `json:"tool"`
+	RuleID   string `json:"ruleId"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+}
+
+// Describe returns a one-line summary such as "shellcheck SC2086 (warning) line 3: ..."
+func (f AnalysisFinding) Describe() string {
+	return fmt.Sprintf("%s %s (%s) line %d: %s", f.Tool, f.RuleID, f.Severity, f.Line, f.Message)
+}
+
+// AnalysisReport is the result of the pre-execution analysis stage
+type AnalysisReport struct {
+	Mode     string            `json:"mode"`
+	Tools    []string          `json:"tools"`
+	Findings []AnalysisFinding `json:"findings"`
+	Blocked  bool              `json:"blocked"`
+	// Tools that could not run; analysis fails open for them
+	Errors []string `json:"errors,omitempty"`
+}
+
+// AnalysisSeverityRank orders severities so they can be compared; unknown
+// severities rank lowest
+func AnalysisSeverityRank(severity string) int {
+	switch severity {
+	case AnalysisSeverityError:
+		return 3
+	case AnalysisSeverityWarning:
+		return 2
+	case AnalysisSeverityInfo:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// Approval requires a human decision before a job runs. The orchestrator
+// requests it from the backend, where one of Approvers other than the job's
+// owner decides; the job is cancelled if it is rejected or Expiry passes.
+type Approval struct {
+	Message   string        `json:"message,omitempty"`
+	Approvers []string      `json:"approvers,omitempty"`
+	Expiry    time.Duration `json:"expiry,omitempty"`
+}
+
+// ApprovalStatus is the state of an approval request
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending  ApprovalStatus = "pending"
+	ApprovalStatusApproved ApprovalStatus = "approved"
+	ApprovalStatusRejected ApprovalStatus = "rejected"
+	ApprovalStatusExpired  ApprovalStatus = "expired"
+)
+
+// ApprovalDecision is the current answer to an approval request
+type ApprovalDecision struct {
+	Status    ApprovalStatus `json:"status"`
+	DecidedBy string         `json:"decidedBy,omitempty"`
+	Comment   string         `json:"comment,omitempty"`
+	DecidedAt *time.Time     `json:"decidedAt,omitempty"`
+}
+
+// Decided reports whether the request has a final answer
+func (d *ApprovalDecision) Decided() bool {
+	if d == nil {
+		return false
+	}
+	switch d.Status {
+	case ApprovalStatusApproved, ApprovalStatusRejected, ApprovalStatusExpired:
+		return true
+	}
+	return false
+}
+
+// Validate checks the approval settings
+func (a *Approval) Validate() error {
+	if a.Expiry < 0 {
+		return fmt.Errorf("approval expiry must not be negative")
+	}
+	return nil
+}
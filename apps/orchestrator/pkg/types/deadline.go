@@ -0,0 +1,77 @@
+package types
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/orchestrator/pkg/schedule"
+)
+
+// Deadline is a wall-clock time a run must finish by. At is an absolute
+// time; Time is a time of day, such as 06:00, that applies to its first
+// occurrence after the run was scheduled.
+type Deadline struct {
+	At *time.Time `json:"at,omitempty"`
+	// HH:MM in TZ
+	Time string `json:"time,omitempty"`
+	// IANA time zone of Time; empty uses the job's TZ, then the
+	// orchestrator's
+	TZ string `json:"tz,omitempty"`
+	// Time a run needs at least; a job with less left before its deadline is
+	// not started
+	MinDuration time.Duration `json:"minDuration,omitempty"`
+}
+
+// ResolveDeadline returns the wall-clock time the job must finish by, or the
+// zero time when it has no deadline. A time-of-day deadline is read in the
+// deadline's time zone, the job's TZ or defaultTZ, in that order, falling
+// back to the orchestrator's local time.
+func (j *Job) ResolveDeadline(defaultTZ string) (time.Time, error) {
+	d := j.Execution.Deadline
+	if d == nil {
+		return time.Time{}, nil
+	}
+	if d.At != nil {
+		return *d.At, nil
+	}
+	if d.Time == "" {
+		return time.Time{}, fmt.Errorf("deadline needs a time or an at timestamp")
+	}
+
+	clock, err := time.Parse("15:04", d.Time)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid deadline time %q, expected HH:MM", d.Time)
+	}
+	tz := d.TZ
+	if tz == "" {
+		tz = j.Execution.Environment["TZ"]
+	}
+	if tz == "" && j.Execution.Locale != nil {
+		tz = j.Execution.Locale.TZ
+	}
+	if tz == "" {
+		tz = defaultTZ
+	}
+	loc := time.Local
+	if tz != "" {
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return time.Time{}, fmt.Errorf("invalid deadline time zone %q: %w", tz, err)
+		}
+	}
+
+	scheduled := j.CreatedAt
+	if j.ScheduledFor != nil {
+		scheduled = *j.ScheduledFor
+	}
+	if scheduled.IsZero() {
+		scheduled = time.Now()
+	}
+	// A time skipped by DST means the end of the gap, a repeated one its first
+	// occurrence
+	daily, err := schedule.Parse(fmt.Sprintf("%d %d * * *", clock.Minute(), clock.Hour()))
+	if err != nil {
+		return time.Time{}, err
+	}
+	run, _ := daily.Next(scheduled, loc)
+	return run.Time, nil
+}
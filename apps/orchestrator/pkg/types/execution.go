@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"time"
 )
@@ -38,6 +39,22 @@ type StatusUpdate struct {
 	ExitCode *int          `json:"exitCode,omitempty"`
 	Error    *ErrorDetails `json:"error,omitempty"`
 	Output   *OutputData   `json:"output,omitempty"`
+	Resume   *ResumeHints  `json:"resume,omitempty"`
+	// Totals measured by the executor when the job ended, such as the
+	// rusage of an SSH job's runner
+	Usage *ResourceUsage `json:"usage,omitempty"`
+}
+
+// ResumeHints describe the checkpoint an interrupted execution left on its
+// server. Sending them back with the job resumes it from the checkpoint.
+type ResumeHints struct {
+	ExecutionID   string `json:"executionId"`
+	ServerID      string `json:"serverId,omitempty"`
+	CheckpointDir string `json:"checkpointDir"`
+	// Progress marker the script recorded, if any
+	Progress any `json:"progress,omitempty"`
+	// Nil when the runner did not confirm the checkpoint in time
+	CheckpointedAt *time.Time `json:"checkpointedAt,omitempty"`
 }
 
 // ProgressUpdate represents execution progress
@@ -93,6 +110,24 @@ type ResourceUsage struct {
 	NetworkTx  int64   `json:"networkTx,omitempty"`  // bytes
 	DiskRead   int64   `json:"diskRead,omitempty"`   // bytes
 	DiskWrite  int64   `json:"diskWrite,omitempty"`  // bytes
+	CPUSeconds float64 `json:"cpuSeconds,omitempty"` // user plus system time
+}
+
+// ResourceSample is a point-in-time resource reading for a running job
+type ResourceSample struct {
+	JobID       string    `json:"jobId"`
+	Timestamp   time.Time `json:"timestamp"`
+	Source      string    `json:"source"`     // docker or remote
+	CPUPercent  float64   `json:"cpuPercent"` // percentage of one core
+	MemoryBytes int64     `json:"memoryBytes"`
+	MemoryLimit int64     `json:"memoryLimit,omitempty"`
+	Processes   int       `json:"processes,omitempty"`
+	NetworkRx   int64     `json:"networkRx,omitempty"` // bytes
+	NetworkTx   int64     `json:"networkTx,omitempty"` // bytes
+	DiskRead    int64     `json:"diskRead,omitempty"`  // bytes
+	DiskWrite   int64     `json:"diskWrite,omitempty"` // bytes
+	// CPU time used since the job started, when the source counts it
+	CPUSeconds float64 `json:"cpuSeconds,omitempty"`
 }
 
 // ErrorDetailsFromError creates ErrorDetails from an error
@@ -101,8 +136,9 @@ func ErrorDetailsFromError(err error) *ErrorDetails {
 		return nil
 	}
 
-	// Check if it's already an ExecutionError
-	if execErr, ok := err.(*ExecutionError); ok {
+	// Check if it is or wraps an ExecutionError
+	var execErr *ExecutionError
+	if errors.As(err, &execErr) {
 		return &execErr.ErrorDetails
 	}
 
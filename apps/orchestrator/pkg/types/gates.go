@@ -0,0 +1,87 @@
+package types
+
+import (
+	"fmt"
+	"time"
+)
+
+// GateType defines what a pre-execution gate waits for
+type GateType string
+
+const (
+	GateTypeHTTP     GateType = "http"
+	GateTypeVariable GateType = "variable"
+	GateTypeFile     GateType = "file"
+)
+
+// Gate is a condition that must hold before a job starts. The orchestrator
+// polls it every Interval until it holds or Timeout passes.
+type Gate struct {
+	Type GateType `json:"type"`
+	Name string   `json:"name,omitempty"`
+
+	// HTTP: wait until URL responds with ExpectStatus (200 by default)
+	URL          string `json:"url,omitempty"`
+	ExpectStatus int    `json:"expectStatus,omitempty"`
+
+	// Variable: wait until the user variable equals Equals
+	Variable string `json:"variable,omitempty"`
+	Equals   any    `json:"equals,omitempty"`
+
+	// File: wait until Path exists on the job's target
+	Path string `json:"path,omitempty"`
+
+	Interval time.Duration `json:"interval,omitempty"`
+	Timeout  time.Duration `json:"timeout,omitempty"`
+}
+
+// Describe returns a short description for logs and status messages
+func (g Gate) Describe() string {
+	var desc string
+	switch g.Type {
+	case GateTypeHTTP:
+		desc = fmt.Sprintf("http %s returns %d", g.URL, g.ExpectedStatus())
+	case GateTypeVariable:
+		desc = fmt.Sprintf("variable %s equals %v", g.Variable, g.Equals)
+	case GateTypeFile:
+		desc = fmt.Sprintf("file %s exists", g.Path)
+	default:
+		desc = string(g.Type)
+	}
+	if g.Name != "" {
+		return fmt.Sprintf("%s (%s)", g.Name, desc)
+	}
+	return desc
+}
+
+// ExpectedStatus returns the HTTP status the gate waits for
+func (g Gate) ExpectedStatus() int {
+	if g.ExpectStatus == 0 {
+		return 200
+	}
+	return g.ExpectStatus
+}
+
+// Validate checks the gate has the fields its type needs
+func (g Gate) Validate() error {
+	switch g.Type {
+	case GateTypeHTTP:
+		if g.URL == "" {
+			return fmt.Errorf("http gate requires a url")
+		}
+	case GateTypeVariable:
+		if g.Variable == "" {
+			return fmt.Errorf("variable gate requires a variable name")
+		}
+	case GateTypeFile:
+		if g.Path == "" {
+			return fmt.Errorf("file gate requires a path")
+		}
+	default:
+		return fmt.Errorf("unsupported gate type %q", g.Type)
+	}
+	if g.Interval < 0 || g.Timeout < 0 {
+		return fmt.Errorf("gate interval and timeout must not be negative")
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
 )
 
@@ -10,20 +13,33 @@ type JobType string
 const (
 	JobTypeContainer JobType = "container"
 	JobTypeSSH       JobType = "ssh"
+	JobTypeHTTP      JobType = "http"
 )
 
 // JobStatus represents the current status of a job
 type JobStatus string
 
 const (
-	JobStatusPending      JobStatus = "pending"
-	JobStatusAcknowledged JobStatus = "acknowledged"
-	JobStatusPreparing    JobStatus = "preparing"
-	JobStatusRunning      JobStatus = "running"
-	JobStatusCompleted    JobStatus = "completed"
-	JobStatusFailed       JobStatus = "failed"
-	JobStatusTimeout      JobStatus = "timeout"
-	JobStatusCancelled    JobStatus = "cancelled"
+	JobStatusPending          JobStatus = "pending"
+	JobStatusAcknowledged     JobStatus = "acknowledged"
+	JobStatusPreparing        JobStatus = "preparing"
+	JobStatusRunning          JobStatus = "running"
+	JobStatusCompleted        JobStatus = "completed"
+	JobStatusFailed           JobStatus = "failed"
+	JobStatusTimeout          JobStatus = "timeout"
+	JobStatusCancelled        JobStatus = "cancelled"
+	JobStatusWaiting          JobStatus = "waiting"
+	JobStatusAwaitingApproval JobStatus = "awaiting_approval"
+	JobStatusInterrupted      JobStatus = "interrupted"
+	// Stopped at, or not started because of, its wall-clock deadline
+	JobStatusDeadlineExceeded JobStatus = "deadline_exceeded"
+	// Not started because another run of its event was still going
+	JobStatusSkipped JobStatus = "skipped"
+	// Stopped because a newer run of its event replaced it
+	JobStatusReplaced JobStatus = "replaced"
+	// Not run again because its attempts kept failing or crashing the
+	// orchestrator
+	JobStatusDeadLettered JobStatus = "dead_lettered"
 )
 
 // Job represents a job to be executed
@@ -43,6 +59,9 @@ type Job struct {
 	CompletedAt    *time.Time    `json:"-"`
 	LeaseExpiry    *time.Time    `json:"-"`
 	Timeout        time.Duration `json:"-"`
+
+	// Set on matrix children; execution records belong to the parent
+	ParentJobID string `json:"-"`
 }
 
 // ExecutionConfig contains the job execution configuration
@@ -52,12 +71,163 @@ type ExecutionConfig struct {
 	HTTP        *HTTPConfig       `json:"http,omitempty"`
 	Environment map[string]string `json:"environment"`
 	Timeout     time.Duration     `json:"timeout"`
+	Deadline    *Deadline         `json:"deadline,omitempty"`
 	Resources   *Resources        `json:"resources,omitempty"`
 	RetryPolicy *RetryPolicy      `json:"retryPolicy,omitempty"`
 
+	// Stop behaviour (container jobs)
+	StopSignal             string        `json:"stopSignal,omitempty"`
+	TerminationGracePeriod time.Duration `json:"terminationGracePeriod,omitempty"`
+
 	// Workflow support
 	InputData map[string]any `json:"inputData,omitempty"`
 	Variables map[string]any `json:"variables,omitempty"`
+	// Keys of Variables the backend flags as sensitive; they are never
+	// written to the runtime cache in plaintext. Nil when the backend did not
+	// send the list, in which case no variable is cached.
+	SensitiveVariables []string `json:"sensitiveVariables"`
+
+	// Declared parameters and the values supplied for this run
+	Parameters      []Parameter    `json:"parameters,omitempty"`
+	ParameterValues map[string]any `json:"parameterValues,omitempty"`
+
+	// Expand the job into one child execution per parameter combination
+	Matrix *Matrix `json:"matrix,omitempty"`
+
+	// Conditions that must hold before the job starts
+	Gates []Gate `json:"gates,omitempty"`
+
+	// Human approval required before the job starts
+	Approval *Approval `json:"approval,omitempty"`
+
+	// Static analysis mode for this job's script
+	Analysis *AnalysisPolicy `json:"analysis,omitempty"`
+
+	// Named sandbox profile for container jobs; empty uses the default
+	SandboxProfile string `json:"sandboxProfile,omitempty"`
+
+	// Paths the container may write to besides /tmp and /workspace when its
+	// root filesystem is read-only (container jobs)
+	WritablePaths []WritablePath `json:"writablePaths,omitempty"`
+
+	// Reason for running the job although the vulnerability gate blocks its
+	// image; recorded in the audit log (container jobs)
+	ImageScanOverride string `json:"imageScanOverride,omitempty"`
+
+	// When the job's image is pulled (container jobs); empty uses the
+	// orchestrator's default
+	ImagePullPolicy ImagePullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// Keep the workspace when the job fails (SSH jobs; other job types
+	// reject true); nil uses the orchestrator's default
+	SnapshotOnFailure *bool `json:"snapshotOnFailure,omitempty"`
+
+	// Clock and locale overrides; enables normalization for this job
+	Locale *Locale `json:"locale,omitempty"`
+
+	// Checkpoint of an interrupted execution this run resumes from (SSH
+	// jobs)
+	Resume *ResumeHints `json:"resume,omitempty"`
+
+	// Only check the script's syntax on the target, without running it
+	// (SSH jobs), or report the container and sandbox settings the job
+	// would run with (Docker container jobs)
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// How the job is spread over its servers (multi-server SSH jobs); unset
+	// fields use the orchestrator's defaults
+	FanOut *FanOut `json:"fanOut,omitempty"`
+
+	// Run on a single one of the job's servers, preferring the one its
+	// event last ran on (multi-server SSH jobs)
+	StickyTarget *StickyTarget `json:"stickyTarget,omitempty"`
+
+	// What happens when a run of the job's event is still going; empty uses
+	// the orchestrator's default
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Files bundled into the payload next to the script (SSH jobs)
+	Files *PayloadFiles `json:"files,omitempty"`
+
+	// Large input objects downloaded into the workspace by the runner
+	// instead of being inlined in InputData (SSH jobs)
+	InputRefs []InputRef `json:"inputRefs,omitempty"`
+}
+
+// InputRef points at a large input object that the runner streams into
+// inputs/<Name> in the job's workspace before the script starts, so it
+// never passes through the queue or the runtime cache. URL is an https URL
+// or s3://bucket/key. The download fails when it is larger than MaxSize or
+// SHA256 is set and does not match.
+type InputRef struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	SHA256  string `json:"sha256,omitempty"`
+	MaxSize int64  `json:"maxSize,omitempty"`
+	// Sent with https downloads, e.g. Authorization
+	Headers map[string]string `json:"headers,omitempty" secret:"true"`
+}
+
+// PayloadFiles are the files bundled into an SSH job's payload besides its
+// script. Include and Exclude are slash-separated globs relative to the
+// orchestrator's payload source directory, where ** matches any number of
+// directories; a file is bundled when it matches an include and no exclude.
+// Included files ending in .tmpl are rendered as Go templates and bundled
+// without the suffix.
+type PayloadFiles struct {
+	Include   []string          `json:"include,omitempty"`
+	Exclude   []string          `json:"exclude,omitempty"`
+	Artifacts []PayloadArtifact `json:"artifacts,omitempty"`
+}
+
+// PayloadArtifact is a file fetched from an allowed URL and bundled at Path.
+// The download is rejected when SHA256 is set and does not match.
+type PayloadArtifact struct {
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ConcurrencyPolicy decides whether runs of the same event may overlap
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow runs the new run alongside the previous ones
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+	// ConcurrencyForbid skips the new run while a previous one is going
+	ConcurrencyForbid ConcurrencyPolicy = "forbid"
+	// ConcurrencyReplace cancels the previous runs and starts the new one
+	ConcurrencyReplace ConcurrencyPolicy = "replace"
+)
+
+// ImagePullPolicy decides when a container job's image is pulled
+type ImagePullPolicy string
+
+const (
+	// PullAlways pulls the image before every run, picking up a moved tag
+	PullAlways ImagePullPolicy = "Always"
+	// PullIfNotPresent pulls the image only when it is missing locally
+	PullIfNotPresent ImagePullPolicy = "IfNotPresent"
+	// PullNever only uses a local image and fails the job without one
+	PullNever ImagePullPolicy = "Never"
+)
+
+// FanOut runs a multi-server job in batches of BatchSize servers with at
+// most MaxParallel at once, pausing BatchPause between batches, and cancels
+// the remaining servers once FailureThreshold have failed
+type FanOut struct {
+	MaxParallel      int           `json:"maxParallel,omitempty"`
+	BatchSize        int           `json:"batchSize,omitempty"`
+	BatchPause       time.Duration `json:"batchPause,omitempty"`
+	FailureThreshold int           `json:"failureThreshold,omitempty"`
+}
+
+// StickyTarget runs a multi-server job on the server its event last ran on
+// while that run is less than TTL ago and the server is reachable, and on
+// the first reachable server otherwise. A zero TTL uses the orchestrator's
+// default.
+type StickyTarget struct {
+	TTL time.Duration `json:"ttl,omitempty"`
 }
 
 // Target defines where to execute the job
@@ -82,9 +252,9 @@ type ServerDetails struct {
 	Host       string `json:"host"`
 	Port       int    `json:"port"`
 	Username   string `json:"username"`
-	PrivateKey string `json:"privateKey,omitempty"` // Base64 encoded, optional
-	Password   string `json:"password,omitempty"`   // Password for authentication, optional
-	Passphrase string `json:"passphrase,omitempty"` // Passphrase for encrypted SSH keys
+	PrivateKey string `json:"privateKey,omitempty" secret:"true"` // Base64 encoded, optional
+	Password   string `json:"password,omitempty" secret:"true"`   // Password for authentication, optional
+	Passphrase string `json:"passphrase,omitempty" secret:"true"` // Passphrase for encrypted SSH keys
 }
 
 // Script contains the script to execute
@@ -103,12 +273,20 @@ const (
 	ScriptTypeNode   ScriptType = "NODEJS"
 )
 
-// HTTPConfig contains HTTP request configuration
+// HTTPConfig contains HTTP request configuration. The URL, header values
+// and string values in the body are Go templates over the job's
+// environment, input, variables and parameters.
 type HTTPConfig struct {
 	Method  string            `json:"method"`
 	URL     string            `json:"url"`
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    any               `json:"body,omitempty"`
+
+	// Response statuses that count as success; any 2xx when empty
+	ExpectStatus []int `json:"expectStatus,omitempty"`
+	// Further attempts after connection errors, 429 and 5xx responses
+	Retries    int           `json:"retries,omitempty"`
+	RetryDelay time.Duration `json:"retryDelay,omitempty"`
 }
 
 // Resources defines resource constraints
@@ -119,6 +297,15 @@ type Resources struct {
 	PidsLimit   int64   `json:"pidsLimit,omitempty"`   // Process count
 }
 
+// WritablePath is a writable mount in a job container
+type WritablePath struct {
+	Path string `json:"path"`
+	// tmpfs (default) keeps the files in memory, volume on disk
+	Type string `json:"type,omitempty"`
+	// Size limit in bytes; zero uses the default
+	Size int64 `json:"size,omitempty"`
+}
+
 // RetryPolicy defines retry behavior
 type RetryPolicy struct {
 	MaxAttempts  int           `json:"maxAttempts"`
@@ -155,3 +342,54 @@ func (j *Job) IsRetryable() bool {
 	}
 	return j.Attempts < j.Execution.RetryPolicy.MaxAttempts
 }
+
+// Attempt returns the 1-based number of the current execution attempt.
+// Attempts counts previous failed attempts.
+func (j *Job) Attempt() int {
+	return j.Attempts + 1
+}
+
+// SubmittedBy returns the job whose execution submitted this one and the
+// root of its job tree. Both are empty for a job that was not submitted by
+// another job.
+func (j *Job) SubmittedBy() (parentID, rootID string) {
+	parentID, _ = j.Metadata["parentJobId"].(string)
+	rootID, _ = j.Metadata["rootJobId"].(string)
+	if rootID == "" {
+		rootID = parentID
+	}
+	return parentID, rootID
+}
+
+// MaxAttempts returns how many attempts the job gets in total
+func (j *Job) MaxAttempts() int {
+	total := 1
+	if j.Execution.RetryPolicy != nil && j.Execution.RetryPolicy.MaxAttempts > total {
+		total = j.Execution.RetryPolicy.MaxAttempts
+	}
+	if attempt := j.Attempt(); attempt > total {
+		total = attempt
+	}
+	return total
+}
+
+// IdempotencyKey returns a key that stays the same across retries of the job.
+// The backend may supply one in the job metadata; otherwise it is derived
+// from the job ID.
+func (j *Job) IdempotencyKey() string {
+	if key, ok := j.Metadata["idempotencyKey"].(string); ok && key != "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte("cronium-job:" + j.ID))
+	return "idem_" + hex.EncodeToString(sum[:16])
+}
+
+// RetryEnvironment returns the attempt and idempotency key as environment
+// variables for the script
+func (j *Job) RetryEnvironment() []string {
+	return []string{
+		fmt.Sprintf("CRONIUM_ATTEMPT=%d", j.Attempt()),
+		fmt.Sprintf("CRONIUM_MAX_ATTEMPTS=%d", j.MaxAttempts()),
+		fmt.Sprintf("CRONIUM_IDEMPOTENCY_KEY=%s", j.IdempotencyKey()),
+	}
+}
@@ -0,0 +1,69 @@
+package types
+
+// Locale is the clock and locale environment of an execution. Empty fields
+// keep the orchestrator's defaults.
+type Locale struct {
+	TZ    string `json:"tz,omitempty"`
+	Lang  string `json:"lang,omitempty"`
+	LCAll string `json:"lcAll,omitempty"`
+	// libfaketime time specification, such as "+2d" or "@2030-01-01 00:00:00",
+	// for testing date-dependent scripts
+	FakeTime string `json:"fakeTime,omitempty"`
+}
+
+// ApplyLocale sets TZ, LANG and LC_ALL in the execution environment from
+// defaults, overridden field by field by the job's locale, and FAKETIME when
+// a fake time is set. fakeTimeLibrary is preloaded to make FAKETIME take
+// effect. Variables the job's environment sets itself are left alone. The
+// effective values are recorded in the metadata under "locale", which
+// becomes part of the execution context.
+func (j *Job) ApplyLocale(defaults Locale, fakeTimeLibrary string) {
+	locale := defaults
+	if override := j.Execution.Locale; override != nil {
+		if override.TZ != "" {
+			locale.TZ = override.TZ
+		}
+		if override.Lang != "" {
+			locale.Lang = override.Lang
+		}
+		if override.LCAll != "" {
+			locale.LCAll = override.LCAll
+		}
+		if override.FakeTime != "" {
+			locale.FakeTime = override.FakeTime
+		}
+	}
+
+	if j.Execution.Environment == nil {
+		j.Execution.Environment = make(map[string]string, 4)
+	}
+	env := j.Execution.Environment
+	set := func(name, value string) string {
+		if current, ok := env[name]; ok {
+			return current
+		}
+		if value != "" {
+			env[name] = value
+		}
+		return value
+	}
+
+	effective := map[string]any{
+		"tz":    set("TZ", locale.TZ),
+		"lang":  set("LANG", locale.Lang),
+		"lcAll": set("LC_ALL", locale.LCAll),
+	}
+	if fakeTime := set("FAKETIME", locale.FakeTime); fakeTime != "" {
+		effective["fakeTime"] = fakeTime
+		// Faking the monotonic clock as well makes sleeps hang
+		set("FAKETIME_DONT_FAKE_MONOTONIC", "1")
+		if fakeTimeLibrary != "" {
+			set("LD_PRELOAD", fakeTimeLibrary)
+		}
+	}
+
+	if j.Metadata == nil {
+		j.Metadata = make(map[string]any, 1)
+	}
+	j.Metadata["locale"] = effective
+}
@@ -0,0 +1,180 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Matrix declares parameter axes a job is expanded over. Every combination of
+// axis values runs as its own child execution.
+type Matrix struct {
+	Axes []MatrixAxis `json:"axes"`
+	// Combinations to skip; an entry matches when all its keys match
+	Exclude []map[string]any `json:"exclude,omitempty"`
+	// Combinations of this job running at once (0 uses the global limit)
+	MaxParallel int `json:"maxParallel,omitempty"`
+	// Stop starting new combinations after the first failure
+	FailFast bool `json:"failFast,omitempty"`
+}
+
+// MatrixAxis is one dimension of a matrix
+type MatrixAxis struct {
+	Name   string `json:"name"`
+	Values []any  `json:"values"`
+}
+
+// MatrixCombination is one set of axis values
+type MatrixCombination map[string]any
+
+// Label returns a stable, human-readable form such as "region=eu,version=2"
+func (c MatrixCombination) Label() string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", k, c[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// Validate checks the axes are well formed
+func (m *Matrix) Validate() error {
+	if len(m.Axes) == 0 {
+		return fmt.Errorf("matrix has no axes")
+	}
+	seen := make(map[string]bool, len(m.Axes))
+	for _, axis := range m.Axes {
+		if !parameterNamePattern.MatchString(axis.Name) {
+			return fmt.Errorf("invalid matrix axis name %q", axis.Name)
+		}
+		if seen[axis.Name] {
+			return fmt.Errorf("matrix axis %q declared more than once", axis.Name)
+		}
+		seen[axis.Name] = true
+		if len(axis.Values) == 0 {
+			return fmt.Errorf("matrix axis %q has no values", axis.Name)
+		}
+	}
+	if m.MaxParallel < 0 {
+		return fmt.Errorf("matrix maxParallel must not be negative")
+	}
+	return nil
+}
+
+// Size returns the number of combinations before exclusions
+func (m *Matrix) Size() int {
+	size := 1
+	for _, axis := range m.Axes {
+		size *= len(axis.Values)
+	}
+	return size
+}
+
+// Combinations expands the axes in declaration order, leaving out excluded
+// combinations
+func (m *Matrix) Combinations() []MatrixCombination {
+	combos := []MatrixCombination{{}}
+	for _, axis := range m.Axes {
+		next := make([]MatrixCombination, 0, len(combos)*len(axis.Values))
+		for _, combo := range combos {
+			for _, value := range axis.Values {
+				c := make(MatrixCombination, len(combo)+1)
+				for k, v := range combo {
+					c[k] = v
+				}
+				c[axis.Name] = value
+				next = append(next, c)
+			}
+		}
+		combos = next
+	}
+
+	result := combos[:0]
+	for _, combo := range combos {
+		if !m.excluded(combo) {
+			result = append(result, combo)
+		}
+	}
+	return result
+}
+
+// excluded reports whether a combination matches any exclude entry
+func (m *Matrix) excluded(combo MatrixCombination) bool {
+	for _, exclude := range m.Exclude {
+		if len(exclude) == 0 {
+			continue
+		}
+		match := true
+		for k, v := range exclude {
+			if fmt.Sprint(combo[k]) != fmt.Sprint(v) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// MatrixChild returns a copy of the job for one combination. The child gets
+// its own ID so executors can track it separately, while execution records
+// are still attached to the parent job. Axis values are exposed as
+// CRONIUM_MATRIX_<AXIS> variables and as input().matrix, and override
+// parameters of the same name.
+func (j *Job) MatrixChild(index int, combo MatrixCombination) *Job {
+	child := *j
+	child.ID = fmt.Sprintf("%s-m%d", j.ID, index)
+	child.ParentJobID = j.ID
+	child.Execution.Matrix = nil
+
+	child.Metadata = make(map[string]any, len(j.Metadata)+1)
+	for k, v := range j.Metadata {
+		child.Metadata[k] = v
+	}
+	child.Metadata["matrix"] = map[string]any(combo)
+
+	child.Execution.Environment = make(map[string]string, len(j.Execution.Environment)+len(combo))
+	for k, v := range j.Execution.Environment {
+		child.Execution.Environment[k] = v
+	}
+
+	child.Execution.InputData = make(map[string]any, len(j.Execution.InputData)+1)
+	for k, v := range j.Execution.InputData {
+		child.Execution.InputData[k] = v
+	}
+	child.Execution.InputData["matrix"] = map[string]any(combo)
+
+	declared := make(map[string]bool, len(j.Execution.Parameters))
+	for _, def := range j.Execution.Parameters {
+		declared[def.Name] = true
+	}
+	child.Execution.ParameterValues = make(map[string]any, len(j.Execution.ParameterValues)+len(combo))
+	for k, v := range j.Execution.ParameterValues {
+		child.Execution.ParameterValues[k] = v
+	}
+
+	for name, value := range combo {
+		child.Execution.Environment["CRONIUM_MATRIX_"+strings.ToUpper(name)] = fmt.Sprint(value)
+		if declared[name] {
+			child.Execution.ParameterValues[name] = value
+		}
+	}
+
+	return &child
+}
+
+// RecordJobID returns the job ID execution records belong to: the parent for
+// matrix children, otherwise the job itself
+func (j *Job) RecordJobID() string {
+	if j.ParentJobID != "" {
+		return j.ParentJobID
+	}
+	return j.ID
+}
@@ -0,0 +1,263 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParameterType defines the type of a job parameter
+type ParameterType string
+
+const (
+	ParameterTypeString ParameterType = "string"
+	ParameterTypeInt    ParameterType = "int"
+	ParameterTypeBool   ParameterType = "bool"
+	ParameterTypeEnum   ParameterType = "enum"
+	ParameterTypeSecret ParameterType = "secret"
+)
+
+// Parameter declares a typed input of a job or event
+type Parameter struct {
+	Name        string        `json:"name"`
+	Type        ParameterType `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Default     any           `json:"default,omitempty"`
+
+	// Validation rules
+	Options   []string `json:"options,omitempty"`   // enum
+	Pattern   string   `json:"pattern,omitempty"`   // string, secret
+	MinLength *int     `json:"minLength,omitempty"` // string, secret
+	MaxLength *int     `json:"maxLength,omitempty"` // string, secret
+	Min       *int64   `json:"min,omitempty"`       // int
+	Max       *int64   `json:"max,omitempty"`       // int
+}
+
+// ParameterError describes why one parameter value was rejected
+type ParameterError struct {
+	Parameter string `json:"parameter"`
+	Message   string `json:"message"`
+}
+
+// parameterNamePattern restricts names to ones that map cleanly to env vars
+var parameterNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ResolveParameters validates the supplied values against the declared
+// parameters and returns the typed value of every parameter that has one,
+// filling in defaults. Values for undeclared parameters are rejected. All
+// problems are reported together in a single validation error.
+func ResolveParameters(defs []Parameter, values map[string]any) (map[string]any, error) {
+	resolved := make(map[string]any, len(defs))
+	var problems []ParameterError
+
+	declared := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		if !parameterNamePattern.MatchString(def.Name) {
+			problems = append(problems, ParameterError{def.Name, "invalid parameter name"})
+			continue
+		}
+		if declared[def.Name] {
+			problems = append(problems, ParameterError{def.Name, "parameter declared more than once"})
+			continue
+		}
+		declared[def.Name] = true
+
+		raw, supplied := values[def.Name]
+		if !supplied || raw == nil {
+			raw = def.Default
+		}
+		if raw == nil {
+			if def.Required {
+				problems = append(problems, ParameterError{def.Name, "required parameter is missing"})
+			}
+			continue
+		}
+
+		value, err := def.coerce(raw)
+		if err != nil {
+			problems = append(problems, ParameterError{def.Name, err.Error()})
+			continue
+		}
+		resolved[def.Name] = value
+	}
+
+	var unknown []string
+	for name := range values {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		problems = append(problems, ParameterError{name, "unknown parameter"})
+	}
+
+	if len(problems) > 0 {
+		return nil, newParameterValidationError(problems)
+	}
+	return resolved, nil
+}
+
+// coerce converts a raw value to the parameter's type and applies its rules
+func (p Parameter) coerce(raw any) (any, error) {
+	switch p.Type {
+	case ParameterTypeString, ParameterTypeSecret, "":
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %T", raw)
+		}
+		return s, p.checkString(s)
+
+	case ParameterTypeInt:
+		n, err := toInt(raw)
+		if err != nil {
+			return nil, err
+		}
+		if p.Min != nil && n < *p.Min {
+			return nil, fmt.Errorf("must be at least %d, got %d", *p.Min, n)
+		}
+		if p.Max != nil && n > *p.Max {
+			return nil, fmt.Errorf("must be at most %d, got %d", *p.Max, n)
+		}
+		return n, nil
+
+	case ParameterTypeBool:
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("expected a boolean, got %q", v)
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("expected a boolean, got %T", raw)
+
+	case ParameterTypeEnum:
+		s, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected one of [%s], got %T", strings.Join(p.Options, ", "), raw)
+		}
+		for _, option := range p.Options {
+			if s == option {
+				return s, nil
+			}
+		}
+		return nil, fmt.Errorf("expected one of [%s], got %q", strings.Join(p.Options, ", "), s)
+	}
+
+	return nil, fmt.Errorf("unsupported parameter type %q", p.Type)
+}
+
+// checkString applies the length and pattern rules of string parameters.
+// Secret values are never echoed back in the error.
+func (p Parameter) checkString(s string) error {
+	if p.MinLength != nil && len(s) < *p.MinLength {
+		return fmt.Errorf("must be at least %d characters", *p.MinLength)
+	}
+	if p.MaxLength != nil && len(s) > *p.MaxLength {
+		return fmt.Errorf("must be at most %d characters", *p.MaxLength)
+	}
+	if p.Pattern != "" {
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", p.Pattern, err)
+		}
+		if !re.MatchString(s) {
+			if p.Type == ParameterTypeSecret {
+				return fmt.Errorf("does not match pattern %q", p.Pattern)
+			}
+			return fmt.Errorf("value %q does not match pattern %q", s, p.Pattern)
+		}
+	}
+	return nil
+}
+
+// toInt accepts JSON numbers and numeric strings
+func toInt(raw any) (int64, error) {
+	switch v := raw.(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("expected an integer, got %v", v)
+		}
+		return int64(v), nil
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", v)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("expected an integer, got %T", raw)
+}
+
+// newParameterValidationError wraps parameter problems in an execution error
+// that is reported to the backend as-is
+func newParameterValidationError(problems []ParameterError) *ExecutionError {
+	messages := make([]string, len(problems))
+	for i, problem := range problems {
+		messages[i] = fmt.Sprintf("%s: %s", problem.Parameter, problem.Message)
+	}
+
+	err := NewExecutionError(
+		"validation",
+		"INVALID_PARAMETERS",
+		"invalid job parameters: "+strings.Join(messages, "; "),
+		false,
+	)
+	err.Details["parameters"] = problems
+	return err
+}
+
+// ParameterEnvName returns the environment variable a parameter is exposed as
+func ParameterEnvName(name string) string {
+	return "CRONIUM_PARAM_" + strings.ToUpper(name)
+}
+
+// ApplyParameters resolves the job's parameters and injects them into the
+// execution: every value becomes a CRONIUM_PARAM_<NAME> environment variable,
+// and non-secret values are added to the input data under "parameters" so
+// helpers can read them.
+func (j *Job) ApplyParameters() error {
+	if len(j.Execution.Parameters) == 0 && len(j.Execution.ParameterValues) == 0 {
+		return nil
+	}
+
+	resolved, err := ResolveParameters(j.Execution.Parameters, j.Execution.ParameterValues)
+	if err != nil {
+		if execErr, ok := err.(*ExecutionError); ok {
+			execErr.JobID = j.ID
+		}
+		return err
+	}
+
+	if j.Execution.Environment == nil {
+		j.Execution.Environment = make(map[string]string, len(resolved))
+	}
+	inputParams := make(map[string]any, len(resolved))
+	for _, def := range j.Execution.Parameters {
+		value, ok := resolved[def.Name]
+		if !ok {
+			continue
+		}
+		j.Execution.Environment[ParameterEnvName(def.Name)] = fmt.Sprintf("%v", value)
+		if def.Type != ParameterTypeSecret {
+			inputParams[def.Name] = value
+		}
+	}
+
+	if j.Execution.InputData == nil {
+		j.Execution.InputData = make(map[string]any, 1)
+	}
+	j.Execution.InputData["parameters"] = inputParams
+	return nil
+}
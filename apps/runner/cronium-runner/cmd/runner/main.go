@@ -1,11 +1,15 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/executor"
 	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/logger"
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/payload"
 	"github.com/spf13/cobra"
 )
 
@@ -27,7 +31,9 @@ the contained scripts with proper isolation and cleanup.`,
 var runCmd = &cobra.Command{
 	Use:   "run [payload]",
 	Short: "Execute a script from a payload",
-	Args:  cobra.ExactArgs(1),
+	Long: `Execute a script from a payload: a tar.gz or zip archive, or for local
+development a directory, which is copied rather than run in place.`,
+	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		payloadPath := args[0]
 
@@ -36,6 +42,23 @@ var runCmd = &cobra.Command{
 
 		// Create executor
 		exec := executor.New(log)
+		if pidFile != "" {
+			exec.SetPIDFile(pidFile)
+		}
+		exec.SetCancellation(cancelFile, gracePeriod)
+		exec.SetMessagesFile(messagesFile)
+		exec.SetPayloadLimits(payload.Limits{MaxSize: maxPayloadSize, MaxFiles: maxPayloadFiles})
+		if scriptCacheDir != "" {
+			exec.SetScriptCache(payload.NewScriptCache(scriptCacheDir, scriptCacheMaxAge))
+		}
+		exec.SetLockDir(lockDir)
+		exec.SetCheckpoint(checkpointDir, resumeFrom)
+		exec.SetSnapshot(executor.SnapshotConfig{
+			Dir:       snapshotDir,
+			MaxSize:   snapshotMaxSize,
+			Retention: snapshotRetention,
+			Upload:    snapshotUpload,
+		})
 
 		// Set up cleanup handler
 		defer func() {
@@ -61,23 +84,69 @@ var versionCmd = &cobra.Command{
 		fmt.Printf("Cronium Runner %s\n", Version)
 		fmt.Printf("Built: %s\n", BuildTime)
 		fmt.Printf("Commit: %s\n", GitCommit)
+		fmt.Printf("Features: %s\n", strings.Join(features, " "))
 	},
 }
 
+// features are the optional run flags this build understands. The
+// orchestrator reads them from the version output and only passes flags a
+// runner lists, so older runners still get commands they accept.
+var features = []string{"pid-file", "cancel-file", "messages-file", "script-cache", "snapshot", "checkpoint"}
+
 var (
-	logLevel string
+	logLevel    string
+	pidFile     string
+	cancelFile  string
+	gracePeriod time.Duration
+
+	messagesFile string
+
+	scriptCacheDir    string
+	scriptCacheMaxAge time.Duration
+
+	maxPayloadSize  int64
+	maxPayloadFiles int
+
+	snapshotDir       string
+	snapshotMaxSize   int64
+	snapshotRetention time.Duration
+	snapshotUpload    bool
+
+	checkpointDir string
+	resumeFrom    string
+
+	lockDir string
 )
 
 func init() {
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(versionCmd)
 
+	runCmd.Flags().StringVar(&pidFile, "pid-file", "", "Write the script's process group ID to this file")
+	runCmd.Flags().StringVar(&cancelFile, "cancel-file", "", "File that signals cancellation to the script (exported as CRONIUM_CANCEL_FILE)")
+	runCmd.Flags().StringVar(&messagesFile, "messages-file", "", "File the orchestrator appends operator messages to (exported as CRONIUM_MESSAGES_FILE)")
+	runCmd.Flags().DurationVar(&gracePeriod, "grace-period", 5*time.Second, "Time the script gets to exit after SIGTERM before it is killed")
+	runCmd.Flags().StringVar(&scriptCacheDir, "script-cache", "", "Directory of cached scripts, by SHA-256, for payloads that reference a script by hash")
+	runCmd.Flags().DurationVar(&scriptCacheMaxAge, "script-cache-max-age", 7*24*time.Hour, "Prune cached scripts unused for this long")
+	runCmd.Flags().Int64Var(&maxPayloadSize, "max-payload-size", payload.DefaultLimits.MaxSize, "Largest total size in bytes of the files a payload may extract to")
+	runCmd.Flags().IntVar(&maxPayloadFiles, "max-payload-files", payload.DefaultLimits.MaxFiles, "Most files and directories a payload may contain")
+	runCmd.Flags().StringVar(&snapshotDir, "snapshot-dir", "", "Keep the workspace of a failed script in this directory as <execution ID>.tar.gz")
+	runCmd.Flags().Int64Var(&snapshotMaxSize, "snapshot-max-size", 50<<20, "Largest total size in bytes of the files in a workspace snapshot")
+	runCmd.Flags().DurationVar(&snapshotRetention, "snapshot-retention", 72*time.Hour, "Prune workspace snapshots older than this")
+	runCmd.Flags().StringVar(&lockDir, "lock-dir", executor.DefaultLockDir(), "Directory of execution locks and the execution registry, per user; empty disables the duplicate execution guard")
+	runCmd.Flags().BoolVar(&snapshotUpload, "snapshot-upload", false, "Also upload workspace snapshots as execution artifacts in API mode")
+	runCmd.Flags().StringVar(&checkpointDir, "checkpoint-dir", "", "Checkpoint the execution to this directory when a request file appears in it")
+	runCmd.Flags().StringVar(&resumeFrom, "resume-from", "", "Resume from the checkpoint of an interrupted execution in this directory")
+
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if errors.Is(err, executor.ErrDuplicateExecution) {
+			os.Exit(executor.DuplicateExecutionExitCode)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/manifest"
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/payload"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyKeys []string
+	verifyJSON bool
+)
+
+// verifyReport is what verify prints about a payload
+type verifyReport struct {
+	Payload        string          `json:"payload"`
+	Format         payload.Format  `json:"format"`
+	SHA256         string          `json:"sha256"`
+	Signer         *payload.Signer `json:"signer"`
+	ManifestSHA256 string          `json:"manifestSha256"`
+	JobID          string          `json:"jobId,omitempty"`
+	ExecutionID    string          `json:"executionId,omitempty"`
+}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [payload]",
+	Short: "Verify a payload's signature",
+	Long: `Verify a payload against its detached signature, <payload>.sig, and report
+the signer and the digest of its manifest.
+
+Signatures are minisign signatures or base64 Ed25519 signatures of the
+payload. They are checked against the keys built into the runner, the keys in
+CRONIUM_PUBLIC_KEY and the keys given with --key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		payloadPath := args[0]
+
+		keys, err := payload.LoadTrustedKeys()
+		if err != nil {
+			return err
+		}
+		for _, s := range verifyKeys {
+			key, err := payload.ParsePublicKey(s)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, key)
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no trusted keys: build them in, set %s or pass --key", payload.PublicKeyEnv)
+		}
+
+		signer, err := payload.Verify(payloadPath, keys)
+		if err != nil {
+			return err
+		}
+
+		report := verifyReport{Payload: payloadPath, Signer: signer}
+		if report.Format, err = payload.DetectFormat(payloadPath); err != nil {
+			return err
+		}
+		if report.SHA256, err = payload.GenerateChecksum(payloadPath); err != nil {
+			return err
+		}
+
+		workDir, err := payload.Extract(payloadPath)
+		if err != nil {
+			return fmt.Errorf("failed to extract payload: %w", err)
+		}
+		defer os.RemoveAll(workDir)
+		manifestPath, err := manifest.FindManifest(workDir)
+		if err != nil {
+			return err
+		}
+		if report.ManifestSHA256, err = payload.GenerateChecksum(manifestPath); err != nil {
+			return err
+		}
+		if m, err := manifest.Parse(manifestPath); err == nil {
+			report.JobID = m.Metadata.JobID
+			report.ExecutionID = m.Metadata.ExecutionID
+		}
+
+		if verifyJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+		fmt.Printf("Payload:   %s (%s)\n", report.Payload, report.Format)
+		fmt.Printf("SHA-256:   %s\n", report.SHA256)
+		fmt.Printf("Signed by: %s\n", signer.KeyID)
+		if signer.TrustedComment != "" {
+			fmt.Printf("Comment:   %s\n", signer.TrustedComment)
+		}
+		fmt.Printf("Manifest:  sha256:%s\n", report.ManifestSHA256)
+		if report.JobID != "" {
+			fmt.Printf("Job:       %s\n", report.JobID)
+		}
+		if report.ExecutionID != "" {
+			fmt.Printf("Execution: %s\n", report.ExecutionID)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().StringArrayVar(&verifyKeys, "key", nil, "Trusted public key, minisign or base64 Ed25519 (repeatable)")
+	verifyCmd.Flags().BoolVar(&verifyJSON, "json", false, "Print the report as JSON")
+}
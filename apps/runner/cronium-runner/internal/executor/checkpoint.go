@@ -0,0 +1,212 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/helpers"
+)
+
+// checkpointRequestFile appears in the checkpoint directory when the
+// orchestrator wants the execution checkpointed
+const checkpointRequestFile = "request"
+
+// checkpointProgressFile is written last and marks a complete checkpoint
+const checkpointProgressFile = "progress.json"
+
+// checkpointPollInterval is how often the checkpoint directory is checked for
+// a request while the script runs
+const checkpointPollInterval = 500 * time.Millisecond
+
+// checkpointedFiles are the bundled-mode results kept in a checkpoint
+var checkpointedFiles = []string{"output.json", "variables.json"}
+
+// Progress is the marker of a checkpoint. Progress is what the script last
+// wrote to CRONIUM_CHECKPOINT_FILE.
+type Progress struct {
+	ExecutionID    string      `json:"executionId"`
+	JobID          string      `json:"jobId"`
+	CheckpointedAt time.Time   `json:"checkpointedAt"`
+	ElapsedSeconds float64     `json:"elapsedSeconds"`
+	Progress       interface{} `json:"progress,omitempty"`
+}
+
+// SetCheckpoint sets the directory the execution is checkpointed to on
+// request, and the checkpoint of an interrupted execution to resume from.
+// Either may be empty.
+func (e *Executor) SetCheckpoint(dir, resumeFrom string) {
+	e.checkpointDir = dir
+	e.resumeDir = resumeFrom
+}
+
+// checkpointFile is where the script records its progress marker
+func (e *Executor) checkpointFile() string {
+	return filepath.Join(e.workDir, ".cronium", "checkpoint.json")
+}
+
+// resumeFile is where the checkpoint being resumed from is shown to the
+// script
+func (e *Executor) resumeFile() string {
+	return filepath.Join(e.workDir, ".cronium", "resume.json")
+}
+
+// checkpointEnv returns the environment telling the script where to record
+// progress and, when resuming, where the previous progress is
+func (e *Executor) checkpointEnv() []string {
+	if e.checkpointDir == "" && e.resumeDir == "" {
+		return nil
+	}
+	env := []string{fmt.Sprintf("CRONIUM_CHECKPOINT_FILE=%s", e.checkpointFile())}
+	if _, err := os.Stat(e.resumeFile()); err == nil {
+		env = append(env, fmt.Sprintf("CRONIUM_RESUME_FILE=%s", e.resumeFile()))
+	}
+	return env
+}
+
+// startCheckpointWatcher checkpoints the execution when the orchestrator
+// requests it while the script runs. The returned function stops watching.
+func (e *Executor) startCheckpointWatcher() func() {
+	if e.checkpointDir == "" {
+		return func() {}
+	}
+
+	started := time.Now()
+	requestPath := filepath.Join(e.checkpointDir, checkpointRequestFile)
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(checkpointPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := os.Stat(requestPath); err != nil {
+				continue
+			}
+			if err := e.checkpoint(started); err != nil {
+				e.log.WithError(err).Error("Failed to checkpoint execution")
+			} else {
+				e.log.WithField("dir", e.checkpointDir).Info("Checkpointed execution")
+				// The new checkpoint supersedes the one resumed from
+				e.discardResumedCheckpoint()
+			}
+			os.Remove(requestPath)
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// checkpoint flushes the results so far and keeps them, with the script's
+// progress marker, in the checkpoint directory. The progress file is written
+// last so the orchestrator only sees complete checkpoints.
+func (e *Executor) checkpoint(started time.Time) error {
+	// Push partial results so they are not lost when the script is stopped
+	if err := e.syncResults(); err != nil {
+		e.log.WithError(err).Warn("Failed to flush results for checkpoint")
+	}
+
+	configDir := filepath.Join(e.workDir, ".cronium")
+	for _, name := range checkpointedFiles {
+		data, err := os.ReadFile(filepath.Join(configDir, name))
+		if err != nil {
+			continue
+		}
+		if err := writeFileAtomic(filepath.Join(e.checkpointDir, name), data); err != nil {
+			return err
+		}
+	}
+
+	progress := Progress{
+		ExecutionID:    os.Getenv("CRONIUM_EXECUTION_ID"),
+		JobID:          e.manifest.Metadata.JobID,
+		CheckpointedAt: time.Now().UTC(),
+		ElapsedSeconds: time.Since(started).Seconds(),
+	}
+	if data, err := os.ReadFile(e.checkpointFile()); err == nil {
+		// Scripts may record any JSON, or plain text
+		var marker interface{}
+		if json.Unmarshal(data, &marker) == nil {
+			progress.Progress = marker
+		} else {
+			progress.Progress = string(data)
+		}
+	}
+
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal progress: %w", err)
+	}
+	return writeFileAtomic(filepath.Join(e.checkpointDir, checkpointProgressFile), data)
+}
+
+// restoreCheckpoint restores the bundled-mode results of the checkpoint being
+// resumed from and shows its progress to the script. A missing checkpoint
+// only logs a warning, and the script starts from scratch.
+func (e *Executor) restoreCheckpoint() error {
+	if e.resumeDir == "" {
+		return nil
+	}
+
+	var progress Progress
+	if err := helpers.ReadJSON(filepath.Join(e.resumeDir, checkpointProgressFile), &progress); err != nil {
+		e.log.WithError(err).Warn("No checkpoint to resume from, starting from scratch")
+		e.resumeDir = ""
+		return nil
+	}
+
+	configDir := filepath.Join(e.workDir, ".cronium")
+	for _, name := range checkpointedFiles {
+		data, err := os.ReadFile(filepath.Join(e.resumeDir, name))
+		if err != nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(configDir, name), data, 0644); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", name, err)
+		}
+	}
+	if err := helpers.WriteJSON(e.resumeFile(), progress); err != nil {
+		return fmt.Errorf("failed to write resume file: %w", err)
+	}
+
+	e.log.WithFields(map[string]interface{}{
+		"execution_id":    progress.ExecutionID,
+		"checkpointed_at": progress.CheckpointedAt,
+	}).Info("Resuming from checkpoint")
+	return nil
+}
+
+// discardResumedCheckpoint removes the checkpoint resumed from once the
+// execution has completed or been checkpointed again
+func (e *Executor) discardResumedCheckpoint() {
+	if e.resumeDir == "" {
+		return
+	}
+	if err := os.RemoveAll(e.resumeDir); err != nil {
+		e.log.WithError(err).Warn("Failed to remove resumed checkpoint")
+	}
+}
+
+// writeFileAtomic writes data through a temporary file in the same directory
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filepath.Base(path), err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -2,52 +2,155 @@ package executor
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"strconv"
 	"sync"
 	"syscall"
+	"time"
 
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/helpers"
 	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/manifest"
 	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/payload"
 	"github.com/addison-moore/cronium/apps/runner/cronium-runner/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
+// defaultGracePeriod is how long the script's process group gets to exit
+// after SIGTERM before it is killed, unless overridden
+const defaultGracePeriod = 5 * time.Second
+
+// defaultInputTimeout bounds input downloads for payloads that do not carry
+// the job's timeout
+const defaultInputTimeout = time.Hour
+
 // Executor handles payload execution
 type Executor struct {
 	log       *logrus.Logger
 	workDir   string
 	manifest  *types.Manifest
+	pidFile   string
 	cleanupMu sync.Mutex
 	cleaned   bool
+
+	// Signed URL for pushing results when running in bundled mode
+	resultUploadURL string
+
+	// Cooperative cancellation: the file scripts poll and the time they get
+	// between SIGTERM and SIGKILL
+	cancelFile  string
+	gracePeriod time.Duration
+
+	// Operator messages, one JSON object per line, appended while the script
+	// runs
+	messagesFile string
+
+	// Scripts the orchestrator sent by hash are restored from here
+	scriptCache *payload.ScriptCache
+
+	// Bounds on what a payload may extract to
+	payloadLimits payload.Limits
+
+	// Where the workspace of a failed script is kept
+	snapshot SnapshotConfig
+
+	// Checkpoints: where the execution is checkpointed on request, and the
+	// checkpoint it resumes from
+	checkpointDir string
+	resumeDir     string
+
+	// Duplicate execution guard: the directory of execution locks and the
+	// host's registry, and the lock held while running
+	lockDir   string
+	lockFile  *os.File
+	lockEntry RegistryEntry
+
+	// Serves the helpers to the script over a Unix socket
+	helperSocket *helpers.SocketServer
+
+	// Script process tracking for process-group termination
+	procMu   sync.Mutex
+	pgid     int
+	procDone chan struct{}
 }
 
 // New creates a new executor
 func New(log *logrus.Logger) *Executor {
 	return &Executor{
-		log: log,
+		log:           log,
+		gracePeriod:   defaultGracePeriod,
+		payloadLimits: payload.DefaultLimits,
 	}
 }
 
+// inputTimeout returns how long input downloads may take: the job's
+// timeout, or defaultInputTimeout when the orchestrator did not send one
+func inputTimeout(jobTimeout time.Duration) time.Duration {
+	if jobTimeout > 0 {
+		return jobTimeout
+	}
+	return defaultInputTimeout
+}
+
+// SetPIDFile sets the path where the script's process group ID is written so
+// the orchestrator can terminate the whole group remotely
+func (e *Executor) SetPIDFile(path string) {
+	e.pidFile = path
+}
+
+// SetCancellation sets the cancel file exported to the script and the grace
+// period between SIGTERM and SIGKILL. An empty cancel file uses a file in the
+// work directory.
+func (e *Executor) SetCancellation(cancelFile string, gracePeriod time.Duration) {
+	e.cancelFile = cancelFile
+	if gracePeriod > 0 {
+		e.gracePeriod = gracePeriod
+	}
+}
+
+// SetMessagesFile sets the file operator messages are appended to. An empty
+// file uses a file in the work directory.
+func (e *Executor) SetMessagesFile(path string) {
+	e.messagesFile = path
+}
+
+// SetScriptCache sets the cache that scripts sent by hash are restored from
+func (e *Executor) SetScriptCache(cache *payload.ScriptCache) {
+	e.scriptCache = cache
+}
+
+// SetPayloadLimits sets the size and file count limits for extracting the
+// payload
+func (e *Executor) SetPayloadLimits(limits payload.Limits) {
+	e.payloadLimits = limits
+}
+
 // Execute runs a payload
 func (e *Executor) Execute(payloadPath string) error {
 	// Set up signal handling for cleanup
 	e.setupSignalHandling()
 
-	// Verify payload signature (basic check for now)
+	// Verify payload signature against the trusted keys, if any
 	e.log.Info("Verifying payload")
-	if err := payload.VerifySignature(payloadPath); err != nil {
+	signer, err := payload.VerifySignature(payloadPath)
+	if err != nil {
 		return fmt.Errorf("payload verification failed: %w", err)
 	}
+	if signer != nil {
+		e.log.WithField("keyId", signer.KeyID).Info("Payload signature verified")
+	}
 
 	// Extract payload
 	e.log.Info("Extracting payload")
-	workDir, err := payload.Extract(payloadPath)
+	workDir, err := payload.ExtractWithLimits(payloadPath, e.payloadLimits)
 	if err != nil {
 		return fmt.Errorf("failed to extract payload: %w", err)
 	}
@@ -66,6 +169,31 @@ func (e *Executor) Execute(payloadPath string) error {
 	}
 	e.manifest = m
 
+	// Refuse to run alongside another runner executing the same job
+	if err := e.acquireGuard(); err != nil {
+		return err
+	}
+
+	// Restore a script sent by hash from the script cache
+	if m.ScriptHash != "" {
+		if err := e.restoreScript(); err != nil {
+			return err
+		}
+	}
+
+	// Download large inputs referenced instead of carried in the payload,
+	// within the job's timeout
+	if refs := m.Metadata.InputRefs; len(refs) > 0 {
+		e.log.WithField("count", len(refs)).Info("Downloading input objects")
+		timeout := inputTimeout(m.Metadata.Timeout)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := payload.FetchInputs(ctx, &http.Client{Timeout: timeout}, workDir, refs)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("failed to download inputs: %w", err)
+		}
+	}
+
 	// Log execution details
 	e.log.WithFields(logrus.Fields{
 		"job_id":        m.Metadata.JobID,
@@ -80,9 +208,29 @@ func (e *Executor) Execute(payloadPath string) error {
 		return fmt.Errorf("failed to setup helpers: %w", err)
 	}
 
-	// Execute script
-	if err := e.executeScript(); err != nil {
-		return fmt.Errorf("script execution failed: %w", err)
+	// Pick up where an interrupted execution left off
+	if err := e.restoreCheckpoint(); err != nil {
+		return fmt.Errorf("failed to restore checkpoint: %w", err)
+	}
+
+	// Execute script, pushing partial results and checkpointing on request
+	// while it runs
+	stopSync := e.startResultSync()
+	stopCheckpoints := e.startCheckpointWatcher()
+	scriptErr := e.executeScript()
+	stopCheckpoints()
+	stopSync()
+	e.closeHelperSocket()
+
+	// Push bundled-mode results back even if the script failed, since
+	// partial output and variables are still useful
+	if err := e.UploadResults(); err != nil {
+		e.log.WithError(err).Warn("Failed to upload results")
+	}
+
+	if scriptErr != nil {
+		e.snapshotWorkspace()
+		return fmt.Errorf("script execution failed: %w", scriptErr)
 	}
 
 	// Collect output data if in bundled mode
@@ -92,11 +240,33 @@ func (e *Executor) Execute(payloadPath string) error {
 		e.log.WithField("output", output).Info("Collected helper output")
 	}
 
+	e.discardResumedCheckpoint()
+
 	e.log.Info("Script execution completed successfully")
 	return nil
 }
 
 // executeScript runs the script based on the interpreter
+// restoreScript writes a script left out of the payload from the script cache
+func (e *Executor) restoreScript() error {
+	scriptPath := filepath.Join(e.workDir, e.manifest.Entrypoint)
+	if _, err := os.Stat(scriptPath); err == nil {
+		return nil
+	}
+	if e.scriptCache == nil {
+		return fmt.Errorf("payload references cached script %s but no script cache is configured", e.manifest.ScriptHash)
+	}
+
+	e.log.WithField("hash", e.manifest.ScriptHash).Info("Restoring script from cache")
+	if err := e.scriptCache.Restore(e.manifest.ScriptHash, scriptPath); err != nil {
+		return fmt.Errorf("failed to restore script: %w", err)
+	}
+	if err := e.scriptCache.Prune(); err != nil {
+		e.log.WithError(err).Warn("Failed to prune script cache")
+	}
+	return nil
+}
+
 func (e *Executor) executeScript() error {
 	scriptPath := filepath.Join(e.workDir, e.manifest.Entrypoint)
 
@@ -140,6 +310,10 @@ exec(open('%s').read())
 	// Set working directory
 	cmd.Dir = e.workDir
 
+	// Run the script in its own process group so that it and any children it
+	// spawns can be signalled together
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
 	// Set environment variables
 	cmd.Env = os.Environ()
 	
@@ -185,6 +359,39 @@ exec(open('%s').read())
 	}
 	
 	cmd.Env = append(cmd.Env, fmt.Sprintf("CRONIUM_WORK_DIR=%s", e.workDir))
+	if len(e.manifest.Metadata.InputRefs) > 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("CRONIUM_INPUTS_DIR=%s", filepath.Join(e.workDir, payload.InputsDir)))
+	}
+
+	// Retry information so scripts can avoid duplicating side effects
+	if meta := e.manifest.Metadata; meta.Attempt > 0 {
+		cmd.Env = append(cmd.Env,
+			fmt.Sprintf("CRONIUM_ATTEMPT=%d", meta.Attempt),
+			fmt.Sprintf("CRONIUM_MAX_ATTEMPTS=%d", meta.MaxAttempts),
+			fmt.Sprintf("CRONIUM_IDEMPOTENCY_KEY=%s", meta.IdempotencyKey),
+		)
+	}
+
+	// Cancellation contract: the cancel file appears before SIGTERM is sent
+	if e.cancelFile == "" {
+		e.cancelFile = filepath.Join(e.workDir, ".cronium", "cancel")
+	}
+	os.Remove(e.cancelFile)
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("CRONIUM_CANCEL_FILE=%s", e.cancelFile),
+		fmt.Sprintf("CRONIUM_CANCEL_GRACE_PERIOD=%d", int(e.gracePeriod.Seconds())),
+	)
+
+	// Messages contract: the file may not exist until the first message, and
+	// is only appended to, so scripts read it from the start or follow it
+	if e.messagesFile == "" {
+		e.messagesFile = filepath.Join(e.workDir, ".cronium", "messages")
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("CRONIUM_MESSAGES_FILE=%s", e.messagesFile))
+
+	// Checkpoint contract: progress recorded in the checkpoint file is kept
+	// when the orchestrator shuts down, and shown again on resume
+	cmd.Env = append(cmd.Env, e.checkpointEnv()...)
 	
 	// Pass through helper-related environment variables if they exist
 	if helperMode := os.Getenv("CRONIUM_HELPER_MODE"); helperMode != "" {
@@ -213,6 +420,8 @@ exec(open('%s').read())
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start command: %w", err)
 	}
+	e.trackProcessGroup(cmd.Process.Pid)
+	defer e.untrackProcessGroup()
 
 	// Stream output
 	var wg sync.WaitGroup
@@ -262,18 +471,123 @@ func (e *Executor) setupSignalHandling() {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	go func() {
-		<-sigChan
-		e.log.Warn("Received interrupt signal, cleaning up")
+		sig := <-sigChan
+		e.log.Warn("Received interrupt signal, terminating script process group")
+		e.writeCancelFile("signal: " + sig.String())
+		e.terminateProcessGroup()
 		e.Cleanup()
 		os.Exit(1)
 	}()
 }
 
+// trackProcessGroup records the script's process group and publishes it to
+// the PID file, if one is configured
+func (e *Executor) trackProcessGroup(pgid int) {
+	e.procMu.Lock()
+	e.pgid = pgid
+	e.procDone = make(chan struct{})
+	e.procMu.Unlock()
+
+	if e.pidFile != "" {
+		if err := writePIDFile(e.pidFile, pgid); err != nil {
+			e.log.WithError(err).Warn("Failed to write process group file")
+		}
+	}
+}
+
+// writePIDFile writes the process group ID to path, which the orchestrator
+// kills by. The directory is created 0700 and must belong to the current
+// user and not be writable by others, and the file is created afresh
+// without following links, so nobody else can choose the group that is
+// killed.
+func writePIDFile(path string, pgid int) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create process group file directory: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to check process group file directory: %w", err)
+	}
+	if !info.IsDir() || info.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("process group file directory %s is not a private directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("process group file directory %s is owned by uid %d", dir, stat.Uid)
+	}
+
+	// A file left by an earlier run of the job is ours, given the directory
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale process group file: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create process group file: %w", err)
+	}
+	if _, err := f.WriteString(strconv.Itoa(pgid)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write process group file: %w", err)
+	}
+	return f.Close()
+}
+
+// untrackProcessGroup kills any processes left in the script's group once the
+// script itself has exited and removes the PID file
+func (e *Executor) untrackProcessGroup() {
+	e.procMu.Lock()
+	pgid := e.pgid
+	done := e.procDone
+	e.pgid = 0
+	e.procDone = nil
+	e.procMu.Unlock()
+
+	if pgid > 0 {
+		// Background processes left behind by the script would otherwise be
+		// reparented to init and outlive the execution
+		if err := syscall.Kill(-pgid, syscall.SIGKILL); err == nil {
+			e.log.WithField("pgid", pgid).Warn("Killed leftover processes in script process group")
+		}
+	}
+	if done != nil {
+		close(done)
+	}
+	if e.pidFile != "" {
+		os.Remove(e.pidFile)
+	}
+}
+
+// terminateProcessGroup sends SIGTERM to the script's process group, waits
+// for the grace period and then sends SIGKILL to anything still running
+func (e *Executor) terminateProcessGroup() {
+	e.procMu.Lock()
+	pgid := e.pgid
+	done := e.procDone
+	e.procMu.Unlock()
+
+	if pgid <= 0 {
+		return
+	}
+
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(e.gracePeriod):
+		e.log.WithField("pgid", pgid).Warn("Script did not exit after SIGTERM, sending SIGKILL")
+	}
+
+	syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
 // Cleanup removes the working directory
 func (e *Executor) Cleanup() error {
 	e.cleanupMu.Lock()
 	defer e.cleanupMu.Unlock()
 
+	e.releaseGuard()
+
 	if e.cleaned || e.workDir == "" {
 		return nil
 	}
@@ -287,3 +601,25 @@ func (e *Executor) Cleanup() error {
 	return nil
 }
 
+
+// writeCancelFile tells the script why it is being cancelled. The file is
+// written before any signal so that scripts polling cronium.cancelled() can
+// wind down on their own.
+func (e *Executor) writeCancelFile(reason string) {
+	if e.cancelFile == "" {
+		return
+	}
+	// Don't overwrite a reason recorded by the orchestrator
+	if _, err := os.Stat(e.cancelFile); err == nil {
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"reason":      reason,
+		"gracePeriod": int(e.gracePeriod.Seconds()),
+		"requestedAt": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err := os.WriteFile(e.cancelFile, data, 0644); err != nil {
+		e.log.WithError(err).Warn("Failed to write cancel file")
+	}
+}
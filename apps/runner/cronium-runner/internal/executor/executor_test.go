@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWritePIDFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cronium-runner-deploy")
+	path := filepath.Join(dir, "job_1.pgid")
+
+	if err := writePIDFile(path, 4242); err != nil {
+		t.Fatalf("writePIDFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "4242" {
+		t.Errorf("process group file = %q, %v; want 4242", data, err)
+	}
+	for name, want := range map[string]os.FileMode{dir: 0700, path: 0600} {
+		if info, err := os.Stat(name); err != nil || info.Mode().Perm() != want {
+			t.Errorf("%s mode = %v, %v; want %v", name, info.Mode().Perm(), err, want)
+		}
+	}
+
+	// A file left by an earlier run is replaced
+	if err := writePIDFile(path, 4343); err != nil {
+		t.Fatalf("writePIDFile() over a stale file error = %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "4343" {
+		t.Errorf("process group file = %q, want 4343", data)
+	}
+}
+
+func TestWritePIDFileRefusesSharedDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(dir, 0777)
+
+	if err := writePIDFile(filepath.Join(dir, "job_1.pgid"), 4242); err == nil {
+		t.Error("writePIDFile() in a world-writable directory succeeded")
+	}
+}
+
+func TestWritePIDFileRefusesOtherUsersDirectory(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing a directory's owner needs root")
+	}
+	dir := filepath.Join(t.TempDir(), "cronium-runner-deploy")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(dir, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writePIDFile(filepath.Join(dir, "job_1.pgid"), 4242); err == nil {
+		t.Error("writePIDFile() in another user's directory succeeded")
+	}
+}
+
+func TestInputTimeout(t *testing.T) {
+	if got := inputTimeout(30 * time.Minute); got != 30*time.Minute {
+		t.Errorf("inputTimeout(30m) = %v, want the job timeout", got)
+	}
+	if got := inputTimeout(0); got != defaultInputTimeout {
+		t.Errorf("inputTimeout(0) = %v, want %v", got, defaultInputTimeout)
+	}
+}
@@ -0,0 +1,312 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+)
+
+// DefaultLockDir returns where the current user's runners keep execution
+// locks and the execution registry: under $XDG_RUNTIME_DIR when set,
+// otherwise in a per-user directory of the temp dir. A directory shared by
+// every user would be created 0700 by whoever ran first and lock the others
+// out.
+//
+// The guard therefore only sees executions run by the same user. Duplicates
+// started by orchestrators that log in as different users on one host are
+// not detected; they do not share a work directory either, since each
+// user's runner writes its own files.
+func DefaultLockDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "cronium", "locks")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("cronium-%d", os.Getuid()), "locks")
+}
+
+// DuplicateExecutionExitCode is the runner's exit status when it refuses to
+// start a duplicate execution
+const DuplicateExecutionExitCode = 73
+
+// registryFile lists the executions running on the host
+const registryFile = "registry.json"
+
+// ErrDuplicateExecution is wrapped by the error returned when the execution,
+// or another execution of the same job, is already running on this host
+var ErrDuplicateExecution = errors.New("duplicate execution")
+
+// unsafeLockName matches characters not allowed in lock file names
+var unsafeLockName = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// RegistryEntry is an execution running on the host
+type RegistryEntry struct {
+	ExecutionID string    `json:"executionId"`
+	JobID       string    `json:"jobId"`
+	PID         int       `json:"pid"`
+	StartedAt   time.Time `json:"startedAt"`
+}
+
+// DuplicateExecutionError reports the running execution a duplicate was
+// refused for
+type DuplicateExecutionError struct {
+	Running RegistryEntry
+}
+
+func (e *DuplicateExecutionError) Error() string {
+	return fmt.Sprintf("%s: execution %s of job %s is already running as PID %d since %s",
+		ErrDuplicateExecution, e.Running.ExecutionID, e.Running.JobID, e.Running.PID, e.Running.StartedAt.Format(time.RFC3339))
+}
+
+// Unwrap lets errors.Is match ErrDuplicateExecution
+func (e *DuplicateExecutionError) Unwrap() error {
+	return ErrDuplicateExecution
+}
+
+// SetLockDir sets the directory of execution locks and the registry; empty
+// disables the duplicate execution guard
+func (e *Executor) SetLockDir(dir string) {
+	e.lockDir = dir
+}
+
+// acquireGuard refuses to run when the execution is already running on this
+// host, or when another execution of the same job is, since they share the
+// job's payload, PID and cancel files. The execution holds an exclusive lock
+// on its lock file until Cleanup; the kernel drops the lock of a runner that
+// dies, so its files are detected as stale and taken over.
+func (e *Executor) acquireGuard() error {
+	if e.lockDir == "" || e.manifest == nil {
+		return nil
+	}
+	executionID := os.Getenv("CRONIUM_EXECUTION_ID")
+	if executionID == "" {
+		executionID = e.manifest.Metadata.ExecutionID
+	}
+	jobID := e.manifest.Metadata.JobID
+	if executionID == "" && jobID == "" {
+		return nil
+	}
+	if executionID == "" {
+		executionID = "job-" + jobID
+	}
+
+	if err := e.ensureLockDir(); err != nil {
+		// The guard protects against duplicates but is not needed to run
+		if errors.Is(err, fs.ErrPermission) {
+			e.log.WithError(err).Warn("Duplicate execution guard disabled")
+			return nil
+		}
+		return err
+	}
+
+	entry := RegistryEntry{ExecutionID: executionID, JobID: jobID, PID: os.Getpid(), StartedAt: time.Now().UTC()}
+
+	path := e.lockPath(executionID)
+	f, err := lockExclusive(path)
+	if err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			running := RegistryEntry{ExecutionID: executionID, JobID: jobID}
+			if data, err := os.ReadFile(path); err == nil {
+				json.Unmarshal(data, &running)
+			}
+			return &DuplicateExecutionError{Running: running}
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			e.log.WithError(err).Warn("Duplicate execution guard disabled")
+			return nil
+		}
+		return fmt.Errorf("failed to lock execution: %w", err)
+	}
+
+	var previous RegistryEntry
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &previous) == nil && previous.PID != 0 {
+		e.log.WithField("pid", previous.PID).Warn("Taking over stale execution lock")
+	}
+
+	release := func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+	if err := e.register(entry); err != nil {
+		release()
+		return err
+	}
+
+	data, _ := json.Marshal(entry)
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt(data, 0)
+	}
+	e.lockFile = f
+	e.lockEntry = entry
+	return nil
+}
+
+// ensureLockDir creates the lock directory, refusing one that belongs to
+// another user since its owner could tamper with the locks
+func (e *Executor) ensureLockDir() error {
+	if err := os.MkdirAll(e.lockDir, 0700); err != nil {
+		return fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	info, err := os.Stat(e.lockDir)
+	if err != nil {
+		return fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("lock directory %s is owned by uid %d: %w", e.lockDir, stat.Uid, fs.ErrPermission)
+	}
+	return nil
+}
+
+// releaseGuard removes the execution from the registry and drops its lock
+func (e *Executor) releaseGuard() {
+	if e.lockFile == nil {
+		return
+	}
+	if err := e.updateRegistry(func(entries []RegistryEntry) ([]RegistryEntry, error) {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.ExecutionID != e.lockEntry.ExecutionID || entry.PID != e.lockEntry.PID {
+				kept = append(kept, entry)
+			}
+		}
+		return kept, nil
+	}); err != nil {
+		e.log.WithError(err).Warn("Failed to remove execution from registry")
+	}
+
+	// Remove the file before unlocking it; a runner that opened it in the
+	// meantime notices and opens a new one
+	os.Remove(e.lockFile.Name())
+	syscall.Flock(int(e.lockFile.Fd()), syscall.LOCK_UN)
+	e.lockFile.Close()
+	e.lockFile = nil
+}
+
+// register adds an execution to the host's registry, refusing it when
+// another live execution of the same job is registered
+func (e *Executor) register(entry RegistryEntry) error {
+	return e.updateRegistry(func(entries []RegistryEntry) ([]RegistryEntry, error) {
+		if entry.JobID != "" {
+			for _, running := range entries {
+				if running.JobID == entry.JobID {
+					return nil, &DuplicateExecutionError{Running: running}
+				}
+			}
+		}
+		return append(entries, entry), nil
+	})
+}
+
+// updateRegistry applies update to the registry's live entries under an
+// exclusive lock. Entries of runners that are no longer alive are dropped.
+func (e *Executor) updateRegistry(update func([]RegistryEntry) ([]RegistryEntry, error)) error {
+	f, err := os.OpenFile(filepath.Join(e.lockDir, registryFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open execution registry: %w", err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return fmt.Errorf("failed to lock execution registry: %w", err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	var entries []RegistryEntry
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		if err := json.NewDecoder(f).Decode(&entries); err != nil {
+			e.log.WithError(err).Warn("Resetting unreadable execution registry")
+			entries = nil
+		}
+	}
+
+	live := entries[:0]
+	for _, entry := range entries {
+		if e.running(entry) {
+			live = append(live, entry)
+		} else {
+			e.log.WithFields(map[string]interface{}{
+				"execution_id": entry.ExecutionID,
+				"pid":          entry.PID,
+			}).Info("Removing stale execution from registry")
+		}
+	}
+
+	updated, err := update(live)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write execution registry: %w", err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write execution registry: %w", err)
+	}
+	return nil
+}
+
+// lockPath returns the lock file of an execution
+func (e *Executor) lockPath(executionID string) string {
+	return filepath.Join(e.lockDir, unsafeLockName.ReplaceAllString(executionID, "_")+".lock")
+}
+
+// running reports whether a registered execution is still running: its
+// process exists and its lock file is locked. Checking the lock as well
+// catches a PID reused by an unrelated process.
+func (e *Executor) running(entry RegistryEntry) bool {
+	if !processAlive(entry.PID) {
+		return false
+	}
+	f, err := os.Open(e.lockPath(entry.ExecutionID))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK)
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return false
+}
+
+// lockExclusive opens and locks a lock file without waiting. A file removed
+// by its previous holder between opening and locking is opened again.
+func lockExclusive(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		opened, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err == nil && os.SameFile(opened, current) {
+			return f, nil
+		}
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}
+}
+
+// processAlive reports whether a process with the given PID exists
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
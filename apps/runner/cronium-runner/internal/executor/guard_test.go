@@ -0,0 +1,175 @@
+package executor
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// newGuardedExecutor returns an executor for an execution of a job that
+// keeps its locks in dir
+func newGuardedExecutor(t *testing.T, dir, jobID, executionID string) *Executor {
+	t.Helper()
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	e := New(log)
+	e.SetLockDir(dir)
+	e.manifest = &types.Manifest{Metadata: types.Metadata{JobID: jobID, ExecutionID: executionID}}
+	return e
+}
+
+// readRegistry returns the entries of the registry in dir
+func readRegistry(t *testing.T, dir string) []RegistryEntry {
+	t.Helper()
+	var entries []RegistryEntry
+	data, err := os.ReadFile(filepath.Join(dir, registryFile))
+	if err != nil {
+		t.Fatalf("failed to read registry: %v", err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("failed to parse registry: %v", err)
+	}
+	return entries
+}
+
+// deadPID returns the PID of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("failed to run true: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestGuardAcquireRelease(t *testing.T) {
+	t.Setenv("CRONIUM_EXECUTION_ID", "")
+	dir := filepath.Join(t.TempDir(), "locks")
+	e := newGuardedExecutor(t, dir, "job_1", "exec_1")
+
+	if err := e.acquireGuard(); err != nil {
+		t.Fatalf("acquireGuard() error = %v", err)
+	}
+	entries := readRegistry(t, dir)
+	if len(entries) != 1 || entries[0].ExecutionID != "exec_1" || entries[0].PID != os.Getpid() {
+		t.Errorf("registry = %+v, want exec_1 as this process", entries)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("lock directory mode = %v, %v; want 0700", info.Mode().Perm(), err)
+	}
+
+	e.releaseGuard()
+	if entries := readRegistry(t, dir); len(entries) != 0 {
+		t.Errorf("registry after release = %+v, want empty", entries)
+	}
+	if _, err := os.Stat(e.lockPath("exec_1")); !os.IsNotExist(err) {
+		t.Errorf("lock file left after release: %v", err)
+	}
+
+	// The execution can run again once released
+	if err := newGuardedExecutor(t, dir, "job_1", "exec_1").acquireGuard(); err != nil {
+		t.Errorf("acquireGuard() after release error = %v", err)
+	}
+}
+
+func TestGuardRefusesDuplicates(t *testing.T) {
+	t.Setenv("CRONIUM_EXECUTION_ID", "")
+	dir := t.TempDir()
+	running := newGuardedExecutor(t, dir, "job_1", "exec_1")
+	if err := running.acquireGuard(); err != nil {
+		t.Fatalf("acquireGuard() error = %v", err)
+	}
+	defer running.releaseGuard()
+
+	tests := []struct {
+		name        string
+		jobID       string
+		executionID string
+	}{
+		{"same execution", "job_1", "exec_1"},
+		{"same job", "job_1", "exec_2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newGuardedExecutor(t, dir, tt.jobID, tt.executionID).acquireGuard()
+			var duplicate *DuplicateExecutionError
+			if !errors.As(err, &duplicate) || !errors.Is(err, ErrDuplicateExecution) {
+				t.Fatalf("acquireGuard() error = %v, want a duplicate execution", err)
+			}
+			if duplicate.Running.ExecutionID != "exec_1" || duplicate.Running.PID != os.Getpid() {
+				t.Errorf("running execution = %+v, want exec_1", duplicate.Running)
+			}
+		})
+	}
+
+	// Other jobs are not held up
+	other := newGuardedExecutor(t, dir, "job_2", "exec_3")
+	if err := other.acquireGuard(); err != nil {
+		t.Fatalf("acquireGuard() for another job error = %v", err)
+	}
+	other.releaseGuard()
+}
+
+func TestGuardTakesOverStaleLocks(t *testing.T) {
+	t.Setenv("CRONIUM_EXECUTION_ID", "")
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// A runner that died left its lock file and registry entry behind, and
+	// another entry's PID now belongs to a process that holds no lock
+	dead := RegistryEntry{ExecutionID: "exec_1", JobID: "job_1", PID: deadPID(t), StartedAt: time.Now().Add(-time.Hour)}
+	reused := RegistryEntry{ExecutionID: "exec_2", JobID: "job_2", PID: os.Getpid(), StartedAt: time.Now().Add(-time.Hour)}
+	data, _ := json.Marshal([]RegistryEntry{dead, reused})
+	if err := os.WriteFile(filepath.Join(dir, registryFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	lock, _ := json.Marshal(dead)
+	e := newGuardedExecutor(t, dir, "job_1", "exec_1")
+	if err := os.WriteFile(e.lockPath("exec_1"), lock, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.acquireGuard(); err != nil {
+		t.Fatalf("acquireGuard() over a stale lock error = %v", err)
+	}
+	defer e.releaseGuard()
+	entries := readRegistry(t, dir)
+	if len(entries) != 1 || entries[0].PID != os.Getpid() || entries[0].ExecutionID != "exec_1" {
+		t.Errorf("registry = %+v, want only this execution", entries)
+	}
+
+	// The lock file now names this runner
+	var holder RegistryEntry
+	if data, err := os.ReadFile(e.lockPath("exec_1")); err != nil || json.Unmarshal(data, &holder) != nil || holder.PID != os.Getpid() {
+		t.Errorf("lock file holder = %+v, %v; want this process", holder, err)
+	}
+}
+
+func TestGuardDisabledInOtherUsersDirectory(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing a directory's owner needs root")
+	}
+	t.Setenv("CRONIUM_EXECUTION_ID", "")
+	dir := t.TempDir()
+	if err := os.Chown(dir, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+
+	e := newGuardedExecutor(t, dir, "job_1", "exec_1")
+	if err := e.acquireGuard(); err != nil {
+		t.Fatalf("acquireGuard() error = %v, want the guard skipped", err)
+	}
+	if e.lockFile != nil {
+		t.Error("execution was locked in another user's directory")
+	}
+}
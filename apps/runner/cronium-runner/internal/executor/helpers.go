@@ -95,6 +95,11 @@ func (e *Executor) SetupHelpers(manifest *types.Manifest) error {
 		return fmt.Errorf("failed to extract helpers: %w", err)
 	}
 
+	// Socket wrappers for scripts that call helpers over the helper socket
+	if err := helpers.SetupSocketWrappers(e.workDir); err != nil {
+		return fmt.Errorf("failed to setup socket wrappers: %w", err)
+	}
+
 	// Add helpers directory to PATH
 	currentPath := os.Getenv("PATH")
 	newPath := fmt.Sprintf("%s%c%s", helpersDir, os.PathListSeparator, currentPath)
@@ -112,8 +117,22 @@ func (e *Executor) SetupHelpers(manifest *types.Manifest) error {
 		os.Setenv("CRONIUM_API_TOKEN", config.APIToken)
 	}
 
+	// Serve the helpers on a socket so scripts need not spawn a binary per
+	// call; the binaries keep working if the socket cannot be created
+	socketPath := filepath.Join(configDir, helpers.SocketName)
+	socketServer := helpers.NewSocketServer(&config, e.log)
+	if err := socketServer.Listen(socketPath); err != nil {
+		e.log.WithError(err).Warn("Helper socket unavailable, scripts will use helper binaries")
+		os.Unsetenv("CRONIUM_HELPER_SOCKET")
+	} else {
+		e.helperSocket = socketServer
+		os.Setenv("CRONIUM_HELPER_SOCKET", socketPath)
+	}
+
 	// For bundled mode, prepare initial data files
 	if config.Mode == helpers.BundledMode {
+		e.resultUploadURL = manifest.Metadata.ResultUploadURL
+
 		// Create event context
 		context := helpers.EventContext{
 			EventID:     manifest.Metadata.EventID,
@@ -124,6 +143,10 @@ func (e *Executor) SetupHelpers(manifest *types.Manifest) error {
 			StartTime:   manifest.Metadata.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
 			Environment: manifest.Environment,
 			Metadata:    manifest.Metadata.Extra,
+
+			Attempt:        manifest.Metadata.Attempt,
+			MaxAttempts:    manifest.Metadata.MaxAttempts,
+			IdempotencyKey: manifest.Metadata.IdempotencyKey,
 		}
 
 		contextPath := filepath.Join(configDir, "context.json")
@@ -175,4 +198,15 @@ func (e *Executor) CollectHelperOutput() (interface{}, error) {
 	}
 
 	return output.Data, nil
-}
\ No newline at end of file
+}
+
+// closeHelperSocket stops the helper socket once the script has exited
+func (e *Executor) closeHelperSocket() {
+	if e.helperSocket == nil {
+		return
+	}
+	if err := e.helperSocket.Close(); err != nil {
+		e.log.WithError(err).Debug("Failed to close helper socket")
+	}
+	e.helperSocket = nil
+}
@@ -0,0 +1,218 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/helpers"
+)
+
+// resultUploadAttempts is how many times pushing results is tried before
+// giving up; the signed URL only accepts one successful upload
+const resultUploadAttempts = 3
+
+// resultUpload is the body sent to the signed result upload URL
+type resultUpload struct {
+	Output    interface{}            `json:"output,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// resultSyncInterval is how often partial results are pushed while the
+// script runs
+const resultSyncInterval = 10 * time.Second
+
+// UploadResults pushes the bundled-mode output and variables through the
+// signed upload URL from the manifest. It does nothing in API mode, where
+// helpers already talk to the runtime directly.
+func (e *Executor) UploadResults() error {
+	if e.resultUploadURL == "" {
+		return nil
+	}
+
+	results, err := e.collectResults()
+	if err != nil {
+		return err
+	}
+
+	if results.Output == nil && len(results.Variables) == 0 {
+		e.log.Debug("No results to upload")
+		return nil
+	}
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for attempt := 1; ; attempt++ {
+		retry, err := postResults(client, e.resultUploadURL, body)
+		if err == nil {
+			e.log.WithField("variables", len(results.Variables)).Info("Uploaded results")
+			return nil
+		}
+		if !retry || attempt == resultUploadAttempts {
+			return err
+		}
+
+		e.log.WithError(err).WithField("attempt", attempt).Warn("Result upload failed, retrying")
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// startResultSync pushes partial results through the upload URL every
+// resultSyncInterval while the script runs, so that long jobs show progress
+// before they finish. Only an output or variables that changed since the
+// last push are sent. The returned function stops the sync; the final
+// upload still carries everything.
+func (e *Executor) startResultSync() func() {
+	if e.resultUploadURL == "" {
+		return func() {}
+	}
+
+	partialURL, err := e.partialResultURL()
+	if err != nil {
+		e.log.WithError(err).Warn("Invalid result upload URL, not syncing partial results")
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		client := &http.Client{Timeout: 30 * time.Second}
+		ticker := time.NewTicker(resultSyncInterval)
+		defer ticker.Stop()
+
+		var lastOutput []byte
+		lastVariables := make(map[string][]byte)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			// The helpers may be halfway through rewriting a file; the next
+			// tick picks up the change
+			results, err := e.collectResults()
+			if err != nil {
+				e.log.WithError(err).Debug("Skipping partial result sync")
+				continue
+			}
+
+			changed := &resultUpload{Variables: make(map[string]interface{})}
+			output, _ := json.Marshal(results.Output)
+			if results.Output != nil && !bytes.Equal(output, lastOutput) {
+				changed.Output = results.Output
+			}
+			variables := make(map[string][]byte, len(results.Variables))
+			for key, value := range results.Variables {
+				encoded, _ := json.Marshal(value)
+				variables[key] = encoded
+				if !bytes.Equal(encoded, lastVariables[key]) {
+					changed.Variables[key] = value
+				}
+			}
+			if changed.Output == nil && len(changed.Variables) == 0 {
+				continue
+			}
+
+			body, err := json.Marshal(changed)
+			if err != nil {
+				continue
+			}
+			if _, err := postResults(client, partialURL, body); err != nil {
+				e.log.WithError(err).Warn("Failed to sync partial results")
+				continue
+			}
+			lastOutput = output
+			lastVariables = variables
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+// syncResults pushes all results so far as a partial upload. It does nothing
+// in API mode.
+func (e *Executor) syncResults() error {
+	if e.resultUploadURL == "" {
+		return nil
+	}
+	partialURL, err := e.partialResultURL()
+	if err != nil {
+		return err
+	}
+
+	results, err := e.collectResults()
+	if err != nil {
+		return err
+	}
+	if results.Output == nil && len(results.Variables) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal results: %w", err)
+	}
+	_, err = postResults(&http.Client{Timeout: 30 * time.Second}, partialURL, body)
+	return err
+}
+
+// partialResultURL returns the upload URL for partial results
+func (e *Executor) partialResultURL() (string, error) {
+	partialURL, err := url.Parse(e.resultUploadURL)
+	if err != nil {
+		return "", err
+	}
+	query := partialURL.Query()
+	query.Set("partial", "true")
+	partialURL.RawQuery = query.Encode()
+	return partialURL.String(), nil
+}
+
+// collectResults reads the bundled-mode output and variables
+func (e *Executor) collectResults() (*resultUpload, error) {
+	output, err := e.CollectHelperOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	var variables map[string]interface{}
+	varsPath := filepath.Join(e.workDir, ".cronium", "variables.json")
+	if _, err := os.Stat(varsPath); err == nil {
+		if err := helpers.ReadJSON(varsPath, &variables); err != nil {
+			return nil, fmt.Errorf("failed to read variables: %w", err)
+		}
+	}
+
+	return &resultUpload{Output: output, Variables: variables}, nil
+}
+
+// postResults sends results once and reports whether a failure is worth
+// retrying
+func postResults(client *http.Client, url string, body []byte) (bool, error) {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, fmt.Errorf("failed to upload results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode >= 500, fmt.Errorf("result upload rejected: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return false, nil
+}
@@ -0,0 +1,323 @@
+package executor
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/internal/helpers"
+	"github.com/sirupsen/logrus"
+)
+
+// snapshotRedaction replaces secret values in snapshotted files
+const snapshotRedaction = "[REDACTED]"
+
+// snapshotMinSecretLen is the shortest value treated as a secret; shorter
+// values such as "1" or "true" would redact unrelated text
+const snapshotMinSecretLen = 6
+
+// snapshotSkippedFile lists the files left out of a snapshot
+const snapshotSkippedFile = "SNAPSHOT_SKIPPED.txt"
+
+// secretEnvName matches environment variables whose values are scrubbed
+var secretEnvName = regexp.MustCompile(`(?i)(token|secret|password|passwd|credential|api_?key|private_?key|auth)`)
+
+// SnapshotConfig defines workspace snapshots taken when the script fails
+type SnapshotConfig struct {
+	// Snapshots are kept here as <execution ID>.tar.gz; empty keeps none
+	Dir string
+	// Largest total size in bytes of the files in a snapshot; larger files
+	// are left out
+	MaxSize int64
+	// Snapshots older than this are pruned; zero keeps them forever
+	Retention time.Duration
+	// Also upload the snapshot as an execution artifact in API mode
+	Upload bool
+}
+
+// SetSnapshot enables workspace snapshots on failure
+func (e *Executor) SetSnapshot(cfg SnapshotConfig) {
+	e.snapshot = cfg
+}
+
+// snapshotEnabled reports whether a failed script's workspace is kept
+func (e *Executor) snapshotEnabled() bool {
+	return e.snapshot.Dir != "" || e.snapshot.Upload
+}
+
+// snapshotWorkspace archives the work directory of a failed script before
+// cleanup removes it. Failures are only logged so they never hide the
+// script's own error.
+func (e *Executor) snapshotWorkspace() {
+	if !e.snapshotEnabled() || e.workDir == "" {
+		return
+	}
+
+	executionID := os.Getenv("CRONIUM_EXECUTION_ID")
+	if executionID == "" && e.manifest != nil {
+		executionID = e.manifest.Metadata.ExecutionID
+	}
+	log := e.log.WithField("execution_id", executionID)
+
+	data, skipped, err := e.archiveWorkspace()
+	if err != nil {
+		log.WithError(err).Warn("Failed to snapshot workspace")
+		return
+	}
+	fields := logrus.Fields{"size": len(data), "skipped": len(skipped)}
+
+	if e.snapshot.Dir != "" {
+		e.pruneSnapshots()
+		path, err := e.saveSnapshot(executionID, data)
+		if err != nil {
+			log.WithError(err).Warn("Failed to save workspace snapshot")
+		} else {
+			fields["path"] = path
+		}
+	}
+
+	if e.snapshot.Upload {
+		if err := e.uploadSnapshot(executionID, data); err != nil {
+			log.WithError(err).Warn("Failed to upload workspace snapshot")
+		} else {
+			fields["uploaded"] = true
+		}
+	}
+
+	log.WithFields(fields).Info("Saved workspace snapshot")
+}
+
+// archiveWorkspace writes the work directory to a gzipped tar with secrets
+// scrubbed. Runner state under .cronium is left out apart from the script's
+// output and variables, and so are files that would exceed the size cap.
+func (e *Executor) archiveWorkspace() ([]byte, []string, error) {
+	secrets := e.snapshotSecrets()
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	var (
+		size    int64
+		skipped []string
+	)
+	err := filepath.WalkDir(e.workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(e.workDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !snapshotIncludes(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     rel + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeSymlink,
+				Name:     rel,
+				Linkname: link,
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		if e.snapshot.MaxSize > 0 && size+info.Size() > e.snapshot.MaxSize {
+			skipped = append(skipped, rel)
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		content = scrubSecrets(content, secrets)
+		size += int64(len(content))
+
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     rel,
+			Size:     int64(len(content)),
+			Mode:     int64(info.Mode().Perm()),
+			ModTime:  info.ModTime(),
+		}); err != nil {
+			return err
+		}
+		_, err = tw.Write(content)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(skipped) > 0 {
+		note := fmt.Sprintf("Files left out of this snapshot because it would exceed %d bytes:\n%s\n",
+			e.snapshot.MaxSize, strings.Join(skipped, "\n"))
+		if err := tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     snapshotSkippedFile,
+			Size:     int64(len(note)),
+			Mode:     0644,
+			ModTime:  time.Now(),
+		}); err != nil {
+			return nil, nil, err
+		}
+		if _, err := tw.Write([]byte(note)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+// snapshotIncludes reports whether a work directory entry belongs in a
+// snapshot. The helper config holds the API token, and the helper binaries
+// are the same for every execution.
+func snapshotIncludes(rel string, dir bool) bool {
+	if rel == ".cronium" {
+		return true
+	}
+	if !strings.HasPrefix(rel, ".cronium/") {
+		return true
+	}
+	switch strings.TrimPrefix(rel, ".cronium/") {
+	case "output.json", "variables.json":
+		return !dir
+	}
+	return false
+}
+
+// snapshotSecrets returns the values scrubbed from snapshots: the API token,
+// the result upload URL and environment values that look like secrets
+func (e *Executor) snapshotSecrets() []string {
+	candidates := []string{os.Getenv("CRONIUM_API_TOKEN"), e.resultUploadURL}
+	if e.manifest != nil {
+		candidates = append(candidates, e.manifest.Metadata.APIToken)
+		for name, value := range e.manifest.Environment {
+			if secretEnvName.MatchString(name) {
+				candidates = append(candidates, value)
+			}
+		}
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if secretEnvName.MatchString(name) {
+			candidates = append(candidates, value)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var secrets []string
+	for _, value := range candidates {
+		if len(value) < snapshotMinSecretLen || seen[value] {
+			continue
+		}
+		seen[value] = true
+		secrets = append(secrets, value)
+	}
+	// Longer values first so a secret containing another is fully redacted
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	return secrets
+}
+
+// scrubSecrets replaces every secret value in content
+func scrubSecrets(content []byte, secrets []string) []byte {
+	for _, secret := range secrets {
+		content = bytes.ReplaceAll(content, []byte(secret), []byte(snapshotRedaction))
+	}
+	return content
+}
+
+// saveSnapshot writes a snapshot to the snapshot directory, readable only by
+// the runner's user
+func (e *Executor) saveSnapshot(executionID string, data []byte) (string, error) {
+	if executionID == "" || strings.ContainsAny(executionID, `/\`) || executionID == ".." {
+		executionID = fmt.Sprintf("unknown-%d", time.Now().UnixNano())
+	}
+	if err := os.MkdirAll(e.snapshot.Dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	path := filepath.Join(e.snapshot.Dir, executionID+".tar.gz")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// pruneSnapshots removes snapshots older than the retention period
+func (e *Executor) pruneSnapshots() {
+	if e.snapshot.Retention <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(e.snapshot.Dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-e.snapshot.Retention)
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".tar.gz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(filepath.Join(e.snapshot.Dir, entry.Name()))
+	}
+}
+
+// uploadSnapshot registers a snapshot as an artifact of the execution. Only
+// the runtime API stores artifacts, so bundled mode keeps local copies only.
+func (e *Executor) uploadSnapshot(executionID string, data []byte) error {
+	endpoint := os.Getenv("CRONIUM_API_ENDPOINT")
+	token := os.Getenv("CRONIUM_API_TOKEN")
+	if os.Getenv("CRONIUM_HELPER_MODE") != string(helpers.APIMode) || endpoint == "" || token == "" {
+		return fmt.Errorf("snapshot upload requires API mode")
+	}
+
+	client := helpers.NewAPIClient(endpoint, token)
+	return client.UploadFile(executionID, "workspace-snapshot.tar.gz", "application/gzip", data)
+}
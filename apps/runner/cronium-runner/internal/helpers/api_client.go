@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -132,6 +133,30 @@ func (c *APIClient) GetContext(executionID string) (*EventContext, error) {
 	return result.Data, nil
 }
 
+// UploadFile stores data as a file artifact of the execution
+func (c *APIClient) UploadFile(executionID, name, mimeType string, data []byte) error {
+	endpoint := fmt.Sprintf("%s/executions/%s/files?name=%s", c.endpoint, executionID, url.QueryEscape(name))
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", mimeType)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
 // doRequest performs an HTTP request
 func (c *APIClient) doRequest(method, url string, body interface{}) ([]byte, error) {
 	var bodyReader io.Reader
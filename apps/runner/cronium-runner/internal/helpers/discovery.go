@@ -40,12 +40,46 @@ cronium.event() {
     "${CRONIUM_HELPERS_DIR}/cronium.event" "$@"
 }
 
+# cronium.call() - Call any helper method over the helper socket
+# Usage: cronium.call <method> [params-json]
+cronium.call() {
+    "${CRONIUM_HELPERS_DIR}/cronium-rpc" "$@"
+}
+
+# cronium.cancelled() - Succeeds once the execution has been cancelled
+cronium.cancelled() {
+    [ -n "${CRONIUM_CANCEL_FILE:-}" ] && [ -f "${CRONIUM_CANCEL_FILE}" ]
+}
+
+# cronium.onCancel() - Run a cleanup command when the execution is cancelled
+# The script exits after the command; it has CRONIUM_CANCEL_GRACE_PERIOD
+# seconds before it is killed
+cronium.onCancel() {
+    trap "$1; exit 143" TERM INT
+}
+
+# cronium.messages() - Print the operator messages sent to the execution as
+# JSON lines, oldest first; with --follow, keep printing new ones
+cronium.messages() {
+    local file="${CRONIUM_MESSAGES_FILE:-}"
+    [ -n "$file" ] || return 0
+    if [ "${1:-}" = "--follow" ]; then
+        touch "$file" && tail -n +1 -F "$file" 2>/dev/null
+    elif [ -f "$file" ]; then
+        cat "$file"
+    fi
+}
+
 # Export functions for use in subshells
 export -f cronium.input
 export -f cronium.output
 export -f cronium.getVariable
 export -f cronium.setVariable
 export -f cronium.event
+export -f cronium.call
+export -f cronium.cancelled
+export -f cronium.onCancel
+export -f cronium.messages
 `
 	return fmt.Sprintf(script, helperDir)
 }
@@ -56,18 +90,33 @@ func GeneratePythonDiscovery(helperDir string) string {
 import os
 import sys
 import json
+import time
 import subprocess
 
 # Helper binary directory
 CRONIUM_HELPERS_DIR = "%s"
 
+# The helper socket is used instead of the helper binaries when it is available
+sys.path.insert(0, os.path.join(os.path.dirname(CRONIUM_HELPERS_DIR), "lib"))
+try:
+    import cronium_rpc as _cronium_rpc
+    if not _cronium_rpc.available():
+        _cronium_rpc = None
+except ImportError:
+    _cronium_rpc = None
+
 
 class cronium:
     """Cronium runtime helper functions"""
+
+    _cancel_handlers = []
+    _cancel_requested = False
     
     @staticmethod
     def input():
         """Get input data"""
+        if _cronium_rpc:
+            return _cronium_rpc.call("input")
         result = subprocess.run(
             [os.path.join(CRONIUM_HELPERS_DIR, "cronium.input")],
             capture_output=True,
@@ -81,6 +130,9 @@ class cronium:
     @staticmethod
     def output(data):
         """Set output data"""
+        if _cronium_rpc:
+            _cronium_rpc.call("output", {"data": data})
+            return
         json_data = json.dumps(data)
         result = subprocess.run(
             [os.path.join(CRONIUM_HELPERS_DIR, "cronium.output")],
@@ -95,6 +147,8 @@ class cronium:
     @staticmethod
     def getVariable(key):
         """Get a variable value"""
+        if _cronium_rpc:
+            return _cronium_rpc.call("getVariable", {"key": key})
         result = subprocess.run(
             [os.path.join(CRONIUM_HELPERS_DIR, "cronium.getVariable"), key],
             capture_output=True,
@@ -108,6 +162,9 @@ class cronium:
     @staticmethod
     def setVariable(key, value):
         """Set a variable value"""
+        if _cronium_rpc:
+            _cronium_rpc.call("setVariable", {"key": key, "value": value})
+            return
         json_value = json.dumps(value)
         result = subprocess.run(
             [os.path.join(CRONIUM_HELPERS_DIR, "cronium.setVariable"), key],
@@ -122,6 +179,8 @@ class cronium:
     @staticmethod
     def event():
         """Get event context"""
+        if _cronium_rpc:
+            return _cronium_rpc.call("event") or {}
         result = subprocess.run(
             [os.path.join(CRONIUM_HELPERS_DIR, "cronium.event")],
             capture_output=True,
@@ -132,6 +191,63 @@ class cronium:
             raise RuntimeError(f"cronium.event failed: {result.stderr}")
         return json.loads(result.stdout) if result.stdout.strip() else {}
 
+    @staticmethod
+    def call(method, params=None):
+        """Call any helper method over the helper socket"""
+        if not _cronium_rpc:
+            raise RuntimeError("cronium.call requires the helper socket (CRONIUM_HELPER_SOCKET)")
+        return _cronium_rpc.call(method, params)
+
+    @staticmethod
+    def cancelled():
+        """Return True once the execution has been cancelled"""
+        cancel_file = os.environ.get("CRONIUM_CANCEL_FILE")
+        return cronium._cancel_requested or bool(cancel_file and os.path.exists(cancel_file))
+
+    @staticmethod
+    def onCancel(handler):
+        """Register a cleanup handler that runs when the execution is cancelled.
+        The script exits after the handlers have run."""
+        import signal
+        cronium._cancel_handlers.append(handler)
+        if len(cronium._cancel_handlers) > 1:
+            return
+
+        def _on_signal(signum, frame):
+            cronium._cancel_requested = True
+            for registered in reversed(cronium._cancel_handlers):
+                try:
+                    registered()
+                except Exception as e:
+                    print(f"cronium.onCancel handler failed: {e}", file=sys.stderr)
+            sys.exit(128 + signum)
+
+        signal.signal(signal.SIGTERM, _on_signal)
+        signal.signal(signal.SIGINT, _on_signal)
+
+    @staticmethod
+    def messages(follow=False, interval=0.5):
+        """Yield the operator messages sent to the execution, oldest first.
+        With follow=True, keep waiting for new ones."""
+        path = os.environ.get("CRONIUM_MESSAGES_FILE")
+        offset, partial = 0, ""
+        while True:
+            if path and os.path.exists(path):
+                with open(path) as f:
+                    f.seek(offset)
+                    partial += f.read()
+                    offset = f.tell()
+                *lines, partial = partial.split("\n")
+                for line in lines:
+                    if line.strip():
+                        try:
+                            yield json.loads(line)
+                        except ValueError:
+                            print(f"cronium.messages skipped an invalid message: {line}", file=sys.stderr)
+            if not follow:
+                return
+            time.sleep(interval)
+
 # Add to builtins so it's available without import
 import builtins
 builtins.cronium = cronium
@@ -141,11 +257,19 @@ builtins.cronium = cronium
 // GenerateNodeDiscovery generates Node.js code for helper discovery
 func GenerateNodeDiscovery(helperDir string) string {
 	return fmt.Sprintf(`const { execSync } = require('child_process');
+const fs = require('fs');
 const path = require('path');
 
 // Helper binary directory
 const CRONIUM_HELPERS_DIR = '%s';
 
+// Helper socket client for cronium.call()
+const rpc = require(path.join(path.dirname(CRONIUM_HELPERS_DIR), 'lib', 'cronium_rpc.js'));
+
+// Cancellation state
+const cancelHandlers = [];
+let cancelRequested = false;
+
 // Create global cronium object
 global.cronium = {
     input: function() {
@@ -197,8 +321,106 @@ global.cronium = {
         } catch (error) {
             throw new Error('cronium.event failed: ' + error.message);
         }
+    },
+
+    // Call any helper method over the helper socket; returns a promise
+    call: function(method, params) {
+        if (!rpc.available()) {
+            return Promise.reject(new Error('cronium.call requires the helper socket (CRONIUM_HELPER_SOCKET)'));
+        }
+        return rpc.call(method, params);
+    },
+
+    cancelled: function() {
+        const cancelFile = process.env.CRONIUM_CANCEL_FILE;
+        return cancelRequested || (!!cancelFile && fs.existsSync(cancelFile));
+    },
+
+    // Register a cleanup handler that runs when the execution is cancelled.
+    // The process exits after the handlers have run.
+    onCancel: function(handler) {
+        cancelHandlers.push(handler);
+        if (cancelHandlers.length > 1) {
+            return;
+        }
+
+        const onSignal = async (signal) => {
+            cancelRequested = true;
+            for (const registered of cancelHandlers.slice().reverse()) {
+                try {
+                    await registered(signal);
+                } catch (error) {
+                    console.error('cronium.onCancel handler failed: ' + error.message);
+                }
+            }
+            process.exit(signal === 'SIGINT' ? 130 : 143);
+        };
+        process.once('SIGTERM', onSignal);
+        process.once('SIGINT', onSignal);
+    },
+
+    // The operator messages sent to the execution so far, oldest first
+    messages: function() {
+        const file = process.env.CRONIUM_MESSAGES_FILE;
+        if (!file || !fs.existsSync(file)) {
+            return [];
+        }
+        return readMessages(fs.readFileSync(file, 'utf8'));
+    },
+
+    // Call a handler for every operator message, including those sent before;
+    // returns a function that stops watching. Watching does not keep the
+    // process alive.
+    onMessage: function(handler, intervalMs) {
+        const file = process.env.CRONIUM_MESSAGES_FILE;
+        let offset = 0;
+        let partial = '';
+        const poll = () => {
+            if (!file || !fs.existsSync(file)) {
+                return;
+            }
+            const size = fs.statSync(file).size;
+            if (size <= offset) {
+                return;
+            }
+            const fd = fs.openSync(file, 'r');
+            const buffer = Buffer.alloc(size - offset);
+            fs.readSync(fd, buffer, 0, buffer.length, offset);
+            fs.closeSync(fd);
+            offset = size;
+            const text = partial + buffer.toString('utf8');
+            const end = text.lastIndexOf('\n') + 1;
+            partial = text.slice(end);
+            for (const message of readMessages(text.slice(0, end))) {
+                try {
+                    handler(message);
+                } catch (error) {
+                    console.error('cronium.onMessage handler failed: ' + error.message);
+                }
+            }
+        };
+        poll();
+        const timer = setInterval(poll, intervalMs || 500);
+        timer.unref();
+        return () => clearInterval(timer);
     }
 };
+
+// Parse JSON lines, skipping a line still being written
+function readMessages(text) {
+    const messages = [];
+    for (const line of text.split('\n')) {
+        if (!line.trim()) {
+            continue;
+        }
+        try {
+            messages.push(JSON.parse(line));
+        } catch (error) {
+            // Incomplete or invalid line
+        }
+    }
+    return messages;
+}
 `, helperDir)
 }
 
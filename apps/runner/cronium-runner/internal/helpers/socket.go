@@ -0,0 +1,305 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SocketName is the helper socket's file name inside the .cronium directory
+const SocketName = "helper.sock"
+
+// maxSocketMessage bounds a single request line
+const maxSocketMessage = 16 * 1024 * 1024
+
+// JSON-RPC error codes used by the helper protocol
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// rpcRequest is one newline-delimited JSON-RPC 2.0 request. A token member is
+// accepted for compatibility with the runtime service's socket but not needed
+// here, since only the script's user can open the socket.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is one response line
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// SocketServer exposes the helpers on a Unix socket so that any language can
+// call them without spawning a helper binary per call. Calls are served in
+// either mode, through the same clients the helper binaries use.
+type SocketServer struct {
+	config *Config
+	api    *APIClient
+	local  *BundledClient
+	log    *logrus.Logger
+
+	// Bundled mode rewrites JSON files, so calls are serialized
+	callMu sync.Mutex
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewSocketServer creates a helper socket server for the given configuration
+func NewSocketServer(config *Config, log *logrus.Logger) *SocketServer {
+	s := &SocketServer{
+		config: config,
+		log:    log,
+		conns:  make(map[net.Conn]struct{}),
+	}
+	if config.Mode == APIMode {
+		s.api = NewAPIClient(config.APIEndpoint, config.APIToken)
+	} else {
+		s.local = NewBundledClient(config.WorkDir, config.ExecutionID)
+	}
+	return s
+}
+
+// Listen creates the socket at path, readable only by the current user, and
+// serves connections in the background until Close is called
+func (s *SocketServer) Listen(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	go s.accept(listener)
+	return nil
+}
+
+// Close stops the server and waits for in-flight calls to finish
+func (s *SocketServer) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *SocketServer) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.log.WithError(err).Warn("Helper socket stopped accepting connections")
+			}
+			return
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers requests on one connection in order
+func (s *SocketServer) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSocketMessage)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := encoder.Encode(s.handle(line)); err != nil {
+			s.log.WithError(err).Debug("Failed to write helper response")
+			return
+		}
+	}
+}
+
+// handle decodes and dispatches one request line
+func (s *SocketServer) handle(line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return rpcErrorResponse(nil, rpcParseError, "parse error")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return rpcErrorResponse(req.ID, rpcInvalidRequest, "invalid request")
+	}
+
+	s.callMu.Lock()
+	result, rpcErr := s.dispatch(req.Method, req.Params)
+	s.callMu.Unlock()
+	if rpcErr != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return rpcErrorResponse(req.ID, rpcServerError, "failed to encode result")
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: encoded}
+}
+
+// dispatch runs a helper method with the mode's client
+func (s *SocketServer) dispatch(method string, params json.RawMessage) (interface{}, *rpcError) {
+	executionID := s.config.ExecutionID
+
+	switch method {
+	case "input":
+		var input interface{}
+		var err error
+		if s.api != nil {
+			input, err = s.api.GetInput(executionID)
+		} else {
+			input, err = s.local.GetInput()
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return input, nil
+
+	case "output":
+		var p struct {
+			Data interface{} `json:"data"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return nil, err
+		}
+		var err error
+		if s.api != nil {
+			err = s.api.SetOutput(executionID, p.Data)
+		} else {
+			err = s.local.SetOutput(p.Data)
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return true, nil
+
+	case "getVariable":
+		var p struct {
+			Key string `json:"key"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "key is required"}
+		}
+		var value interface{}
+		var err error
+		if s.api != nil {
+			value, err = s.api.GetVariable(executionID, p.Key)
+		} else {
+			value, err = s.local.GetVariable(p.Key)
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return value, nil
+
+	case "setVariable":
+		var p struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := decodeRPCParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "key is required"}
+		}
+		var err error
+		if s.api != nil {
+			err = s.api.SetVariable(executionID, p.Key, p.Value)
+		} else {
+			err = s.local.SetVariable(p.Key, p.Value)
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return true, nil
+
+	case "event":
+		var context *EventContext
+		var err error
+		if s.api != nil {
+			context, err = s.api.GetContext(executionID)
+		} else {
+			context, err = s.local.GetContext()
+		}
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return context, nil
+
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// decodeRPCParams unmarshals named params; missing params decode as empty
+func decodeRPCParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, message string) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
@@ -0,0 +1,300 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RPCShellWrapper is the cronium-rpc command: it sends one request over the
+// helper socket and prints the result as JSON. It needs socat or python3 to
+// reach the socket, since bash cannot open Unix sockets itself.
+const RPCShellWrapper = `#!/bin/bash
+# cronium-rpc - call a Cronium helper over the helper socket
+#
+# Usage: cronium-rpc <method> [params-json]
+#   cronium-rpc getVariable '{"key":"counter"}'
+#   cronium-rpc setVariable '{"key":"counter","value":2}'
+
+set -euo pipefail
+
+if [ $# -lt 1 ]; then
+    echo "Usage: cronium-rpc <method> [params-json]" >&2
+    exit 2
+fi
+
+socket="${CRONIUM_HELPER_SOCKET:-}"
+if [ -z "$socket" ] || [ ! -S "$socket" ]; then
+    echo "cronium-rpc: helper socket not available (CRONIUM_HELPER_SOCKET)" >&2
+    exit 2
+fi
+
+params="${2:-}"
+[ -n "$params" ] || params='{}'
+
+request="{\"jsonrpc\":\"2.0\",\"id\":1,\"method\":\"$1\",\"params\":${params}"
+if [ -n "${CRONIUM_EXECUTION_TOKEN:-}" ]; then
+    request="${request},\"token\":\"${CRONIUM_EXECUTION_TOKEN}\""
+fi
+request="${request}}"
+
+if command -v socat >/dev/null 2>&1; then
+    response=$(printf '%s\n' "$request" | socat -t 60 - "UNIX-CONNECT:${socket}")
+elif command -v python3 >/dev/null 2>&1; then
+    response=$(printf '%s\n' "$request" | python3 -c '
+import socket, sys
+s = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+s.connect(sys.argv[1])
+s.sendall(sys.stdin.buffer.read())
+s.shutdown(socket.SHUT_WR)
+sys.stdout.buffer.write(s.makefile("rb").readline())
+' "$socket")
+else
+    echo "cronium-rpc: socat or python3 is required to reach the helper socket" >&2
+    exit 2
+fi
+
+# Responses are {"jsonrpc":"2.0","id":1,"result":...} or {...,"error":{...}}
+case "$response" in
+    *'"error":{'*)
+        message="${response#*\"message\":\"}"
+        echo "cronium-rpc: ${message%%\"*}" >&2
+        exit 1
+        ;;
+    *'"result":'*)
+        result="${response#*\"result\":}"
+        echo "${result%\}}"
+        ;;
+    *)
+        echo "cronium-rpc: no response from helper socket" >&2
+        exit 1
+        ;;
+esac
+`
+
+// RPCPythonWrapper is the cronium_rpc module for Python scripts. The client
+// keeps one connection open for all calls.
+const RPCPythonWrapper = `"""Cronium helper socket client.
+
+    import cronium_rpc
+    value = cronium_rpc.call("getVariable", {"key": "counter"})
+    cronium_rpc.call("setVariable", {"key": "counter", "value": value + 1})
+"""
+import json
+import os
+import socket
+import threading
+
+
+class CroniumRPCError(Exception):
+    """A helper call the socket answered with an error"""
+
+    def __init__(self, code, message):
+        super().__init__(message)
+        self.code = code
+
+
+class Client:
+    def __init__(self, path=None, token=None):
+        self.path = path or os.environ.get("CRONIUM_HELPER_SOCKET")
+        self.token = token or os.environ.get("CRONIUM_EXECUTION_TOKEN")
+        self._sock = None
+        self._reader = None
+        self._next_id = 0
+        self._lock = threading.Lock()
+
+    def _connect(self):
+        if not self.path:
+            raise CroniumRPCError(-32000, "helper socket not available (CRONIUM_HELPER_SOCKET)")
+        self._sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        self._sock.connect(self.path)
+        self._reader = self._sock.makefile("rb")
+
+    def call(self, method, params=None):
+        with self._lock:
+            if self._sock is None:
+                self._connect()
+            self._next_id += 1
+            request = {"jsonrpc": "2.0", "id": self._next_id, "method": method, "params": params or {}}
+            if self.token:
+                request["token"] = self.token
+            self._sock.sendall(json.dumps(request).encode() + b"\n")
+            line = self._reader.readline()
+            if not line:
+                self.close()
+                raise CroniumRPCError(-32000, "helper socket closed the connection")
+        response = json.loads(line)
+        if "error" in response:
+            raise CroniumRPCError(response["error"]["code"], response["error"]["message"])
+        return response.get("result")
+
+    def close(self):
+        if self._sock is not None:
+            self._sock.close()
+            self._sock = None
+            self._reader = None
+
+
+_client = None
+
+
+def available():
+    """True when the helper socket can be used"""
+    path = os.environ.get("CRONIUM_HELPER_SOCKET")
+    return bool(path) and os.path.exists(path)
+
+
+def call(method, params=None):
+    """Call a helper method on the shared connection"""
+    global _client
+    if _client is None:
+        _client = Client()
+    return _client.call(method, params)
+`
+
+// RPCNodeWrapper is the cronium_rpc module for Node.js scripts. Calls return
+// promises; the connection only keeps the process alive while calls are
+// pending.
+const RPCNodeWrapper = `// Cronium helper socket client.
+//
+//   const rpc = require('cronium_rpc');
+//   const value = await rpc.call('getVariable', { key: 'counter' });
+//   await rpc.call('setVariable', { key: 'counter', value: value + 1 });
+const net = require('net');
+const fs = require('fs');
+
+class CroniumRPCError extends Error {
+    constructor(code, message) {
+        super(message);
+        this.code = code;
+    }
+}
+
+class Client {
+    constructor(path, token) {
+        this.path = path || process.env.CRONIUM_HELPER_SOCKET;
+        this.token = token || process.env.CRONIUM_EXECUTION_TOKEN;
+        this.socket = null;
+        this.buffer = '';
+        this.nextId = 0;
+        this.pending = new Map();
+    }
+
+    connect() {
+        if (!this.path) {
+            throw new CroniumRPCError(-32000, 'helper socket not available (CRONIUM_HELPER_SOCKET)');
+        }
+        this.socket = net.createConnection(this.path);
+        this.socket.setEncoding('utf8');
+        this.socket.on('data', (chunk) => {
+            this.buffer += chunk;
+            let newline;
+            while ((newline = this.buffer.indexOf('\n')) >= 0) {
+                const line = this.buffer.slice(0, newline);
+                this.buffer = this.buffer.slice(newline + 1);
+                if (line.trim()) {
+                    this.receive(JSON.parse(line));
+                }
+            }
+        });
+        const fail = (error) => {
+            for (const { reject } of this.pending.values()) {
+                reject(error);
+            }
+            this.pending.clear();
+            this.socket = null;
+        };
+        this.socket.on('error', fail);
+        this.socket.on('close', () => fail(new CroniumRPCError(-32000, 'helper socket closed the connection')));
+    }
+
+    receive(response) {
+        const call = this.pending.get(response.id);
+        if (!call) {
+            return;
+        }
+        this.pending.delete(response.id);
+        if (this.pending.size === 0 && this.socket) {
+            this.socket.unref();
+        }
+        if (response.error) {
+            call.reject(new CroniumRPCError(response.error.code, response.error.message));
+        } else {
+            call.resolve(response.result);
+        }
+    }
+
+    call(method, params) {
+        return new Promise((resolve, reject) => {
+            if (!this.socket) {
+                this.connect();
+            }
+            const id = ++this.nextId;
+            const request = { jsonrpc: '2.0', id, method, params: params || {} };
+            if (this.token) {
+                request.token = this.token;
+            }
+            this.pending.set(id, { resolve, reject });
+            this.socket.ref();
+            this.socket.write(JSON.stringify(request) + '\n');
+        });
+    }
+
+    close() {
+        if (this.socket) {
+            this.socket.end();
+            this.socket = null;
+        }
+    }
+}
+
+let client = null;
+
+module.exports = {
+    Client,
+    CroniumRPCError,
+
+    // True when the helper socket can be used
+    available() {
+        const path = process.env.CRONIUM_HELPER_SOCKET;
+        return !!path && fs.existsSync(path);
+    },
+
+    // Call a helper method on the shared connection
+    call(method, params) {
+        if (!client) {
+            client = new Client();
+        }
+        return client.call(method, params);
+    },
+};
+`
+
+// SetupSocketWrappers writes the helper socket wrappers into the work
+// directory: cronium-rpc next to the helper binaries, and the Python and
+// Node.js modules in .cronium/lib
+func SetupSocketWrappers(workDir string) error {
+	binDir := filepath.Join(workDir, ".cronium", "bin")
+	libDir := filepath.Join(workDir, ".cronium", "lib")
+	for _, dir := range []string{binDir, libDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+	}
+
+	wrappers := []struct {
+		path    string
+		content string
+		mode    os.FileMode
+	}{
+		{filepath.Join(binDir, "cronium-rpc"), RPCShellWrapper, 0755},
+		{filepath.Join(libDir, "cronium_rpc.py"), RPCPythonWrapper, 0644},
+		{filepath.Join(libDir, "cronium_rpc.js"), RPCNodeWrapper, 0644},
+	}
+	for _, w := range wrappers {
+		if err := os.WriteFile(w.path, []byte(w.content), w.mode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(w.path), err)
+		}
+	}
+	return nil
+}
@@ -54,6 +54,10 @@ type EventContext struct {
 	StartTime   string                 `json:"startTime"`
 	Environment map[string]string      `json:"environment"`
 	Metadata    map[string]interface{} `json:"metadata"`
+
+	Attempt        int    `json:"attempt,omitempty"`
+	MaxAttempts    int    `json:"maxAttempts,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
 }
 
 // LoadConfig loads the helper configuration from environment or file
@@ -0,0 +1,76 @@
+package payload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScriptCache holds scripts by their SHA-256 hash so the orchestrator only
+// sends the hash of a script the server already has
+type ScriptCache struct {
+	dir    string
+	maxAge time.Duration
+}
+
+// NewScriptCache creates a script cache in dir. Entries unused for maxAge
+// are pruned; zero keeps them forever.
+func NewScriptCache(dir string, maxAge time.Duration) *ScriptCache {
+	return &ScriptCache{dir: dir, maxAge: maxAge}
+}
+
+// Restore verifies the cached script with the given hash and writes it to
+// target. A cached script that fails verification is removed so the
+// orchestrator uploads it again.
+func (c *ScriptCache) Restore(hash, target string) error {
+	if len(hash) != sha256.Size*2 || strings.ContainsAny(hash, "./") {
+		return fmt.Errorf("invalid script hash %q", hash)
+	}
+	path := filepath.Join(c.dir, hash)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("script %s is not cached: %w", hash, err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		os.Remove(path)
+		return fmt.Errorf("cached script %s is corrupt", hash)
+	}
+
+	if err := os.WriteFile(target, data, 0755); err != nil {
+		return fmt.Errorf("failed to write script: %w", err)
+	}
+
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return nil
+}
+
+// Prune removes cached scripts unused for longer than the maximum age
+func (c *ScriptCache) Prune() error {
+	if c.maxAge <= 0 {
+		return nil
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	cutoff := time.Now().Add(-c.maxAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(cutoff) {
+			continue
+		}
+		os.Remove(filepath.Join(c.dir, entry.Name()))
+	}
+	return nil
+}
@@ -2,22 +2,59 @@ package payload
 
 import (
 	"archive/tar"
+	"archive/zip"
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// Extract extracts a tar.gz payload to a temporary directory
+// Format is the packaging of a payload
+type Format string
+
+const (
+	FormatTarGz     Format = "tar.gz"
+	FormatZip       Format = "zip"
+	FormatDirectory Format = "directory"
+)
+
+// ErrUnsafePayload is wrapped by every extraction failure caused by the
+// payload's content rather than by I/O: entries escaping the work directory,
+// symlinks pointing outside it, device files and exceeded limits
+var ErrUnsafePayload = errors.New("unsafe payload")
+
+// Limits bound what a payload may extract to, whatever its format
+type Limits struct {
+	// MaxSize is the total number of bytes of all files
+	MaxSize int64
+	// MaxFiles is the number of files and directories
+	MaxFiles int
+}
+
+// DefaultLimits are used by Extract
+var DefaultLimits = Limits{
+	MaxSize:  1 << 30,
+	MaxFiles: 10000,
+}
+
+// Extract extracts a payload to a temporary directory within DefaultLimits
 func Extract(payloadPath string) (string, error) {
-	// Open the payload file
-	file, err := os.Open(payloadPath)
+	return ExtractWithLimits(payloadPath, DefaultLimits)
+}
+
+// ExtractWithLimits extracts a tar.gz or zip payload, or copies a payload
+// directory, to a temporary directory. The format is detected from the
+// payload itself; see DetectFormat.
+func ExtractWithLimits(payloadPath string, limits Limits) (string, error) {
+	format, err := DetectFormat(payloadPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to open payload: %w", err)
+		return "", err
 	}
-	defer file.Close()
 
 	// Create a temporary directory for extraction
 	tempDir, err := os.MkdirTemp("", "cronium-run-*")
@@ -25,80 +62,395 @@ func Extract(payloadPath string) (string, error) {
 		return "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
 
-	// Extract the payload
-	if err := extractTarGz(file, tempDir); err != nil {
+	// Entries are checked against the real path, which differs where the temp
+	// directory is reached through a symlink
+	dest, err := filepath.EvalSymlinks(tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", fmt.Errorf("failed to resolve temp directory: %w", err)
+	}
+
+	x := &extractor{dest: dest, limits: limits}
+	switch format {
+	case FormatTarGz:
+		err = x.extractTarGz(payloadPath)
+	case FormatZip:
+		err = x.extractZip(payloadPath)
+	case FormatDirectory:
+		err = x.copyDirectory(payloadPath)
+	}
+	if err != nil {
 		os.RemoveAll(tempDir)
-		return "", fmt.Errorf("failed to extract payload: %w", err)
+		return "", fmt.Errorf("failed to extract %s payload: %w", format, err)
 	}
 
 	return tempDir, nil
 }
 
-// extractTarGz extracts a tar.gz archive to a directory
-func extractTarGz(r io.Reader, dest string) error {
-	// Create gzip reader
-	gz, err := gzip.NewReader(r)
+// DetectFormat tells a payload's format by its magic bytes, falling back to
+// its extension. A directory is used as is, which is convenient for local
+// development.
+func DetectFormat(payloadPath string) (Format, error) {
+	info, err := os.Stat(payloadPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open payload: %w", err)
+	}
+	if info.IsDir() {
+		return FormatDirectory, nil
+	}
+
+	file, err := os.Open(payloadPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open payload: %w", err)
+	}
+	defer file.Close()
+
+	magic := make([]byte, 4)
+	n, _ := io.ReadFull(file, magic)
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return FormatTarGz, nil
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return FormatZip, nil
+	}
+
+	name := strings.ToLower(payloadPath)
+	switch {
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return FormatZip, nil
+	}
+	return "", fmt.Errorf("unrecognized payload format: %s", filepath.Base(payloadPath))
+}
+
+// extractor writes payload entries below dest, applying the same path checks
+// and limits to every format
+type extractor struct {
+	dest   string
+	limits Limits
+
+	size     int64
+	files    int
+	symlinks []string
+}
+
+// unsafe reports a rejected entry
+func unsafe(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrUnsafePayload, fmt.Sprintf(format, args...))
+}
+
+// target resolves an entry name inside dest, rejecting names that would
+// escape it either lexically or through a symlink extracted earlier
+func (x *extractor) target(name string) (string, error) {
+	// Archives made on Windows may use backslashes
+	name = strings.ReplaceAll(name, "\\", "/")
+	if filepath.IsAbs(name) || strings.HasPrefix(name, "/") {
+		return "", unsafe("entry %s has an absolute path", name)
+	}
+
+	target := filepath.Join(x.dest, filepath.FromSlash(name))
+	if !within(x.dest, target) {
+		return "", unsafe("entry %s escapes the work directory", name)
+	}
+
+	// The deepest existing ancestor must resolve inside dest too, or the
+	// directories created below it would end up elsewhere
+	parent := filepath.Dir(target)
+	for {
+		if _, err := os.Lstat(parent); err == nil {
+			break
+		}
+		parent = filepath.Dir(parent)
+	}
+	real, err := filepath.EvalSymlinks(parent)
+	if err != nil || !within(x.dest, real) {
+		return "", unsafe("entry %s is written through a symlink outside the work directory", name)
+	}
+
+	// Never write through a symlink at the entry's own path
+	if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		return "", unsafe("entry %s replaces a symlink", name)
+	}
+	return target, nil
+}
+
+// within reports whether path is dest or below it
+func within(dest, path string) bool {
+	rel, err := filepath.Rel(dest, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// count records one more entry against the file limit. Skipped entries count
+// as well, so that a payload cannot make extraction arbitrarily slow.
+func (x *extractor) count() error {
+	x.files++
+	if x.limits.MaxFiles > 0 && x.files > x.limits.MaxFiles {
+		return unsafe("more than %d files", x.limits.MaxFiles)
+	}
+	return nil
+}
+
+func (x *extractor) mkdir(name string, mode fs.FileMode) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if mode.Perm() == 0 {
+		mode = 0755
+	}
+	if err := os.MkdirAll(target, mode.Perm()); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", target, err)
+	}
+	return nil
+}
+
+// writeFile writes one file, failing once the payload exceeds its size limit
+// no matter what size the entry claims to have
+func (x *extractor) writeFile(name string, mode fs.FileMode, r io.Reader) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+	if mode.Perm() == 0 {
+		mode = 0644
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", target, err)
+	}
+	defer file.Close()
+
+	if x.limits.MaxSize > 0 {
+		r = io.LimitReader(r, x.limits.MaxSize-x.size+1)
+	}
+	n, err := io.Copy(file, r)
+	x.size += n
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", target, err)
+	}
+	if x.limits.MaxSize > 0 && x.size > x.limits.MaxSize {
+		return unsafe("larger than %d bytes", x.limits.MaxSize)
+	}
+	return nil
+}
+
+// symlink creates a relative symlink whose target stays inside dest
+func (x *extractor) symlink(name, linkname string) error {
+	target, err := x.target(name)
+	if err != nil {
+		return err
+	}
+
+	linkname = strings.ReplaceAll(linkname, "\\", "/")
+	if linkname == "" || filepath.IsAbs(linkname) || strings.HasPrefix(linkname, "/") {
+		return unsafe("symlink %s points outside the work directory", name)
+	}
+	resolved := filepath.Join(filepath.Dir(target), filepath.FromSlash(linkname))
+	if !within(x.dest, resolved) {
+		return unsafe("symlink %s points outside the work directory", name)
+	}
+
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	if err := os.Symlink(filepath.FromSlash(linkname), target); err != nil {
+		return fmt.Errorf("failed to create symlink %s: %w", target, err)
+	}
+	x.symlinks = append(x.symlinks, target)
+	return nil
+}
+
+// checkSymlinks resolves every extracted symlink once all entries exist, since
+// a link that was safe when created can point elsewhere through links that
+// came after it
+func (x *extractor) checkSymlinks() error {
+	for _, link := range x.symlinks {
+		real, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			// Dangling links cannot be followed out of dest
+			continue
+		}
+		if !within(x.dest, real) {
+			rel, _ := filepath.Rel(x.dest, link)
+			return unsafe("symlink %s points outside the work directory", filepath.ToSlash(rel))
+		}
+	}
+	return nil
+}
+
+// special rejects device files, pipes and sockets, which a script has no use
+// for and which could give it access to the host
+func special(name string) error {
+	return unsafe("entry %s is a device or special file", name)
+}
+
+// extractTarGz extracts a tar.gz archive
+func (x *extractor) extractTarGz(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open payload: %w", err)
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
 	if err != nil {
 		return fmt.Errorf("failed to create gzip reader: %w", err)
 	}
 	defer gz.Close()
 
-	// Create tar reader
 	tr := tar.NewReader(gz)
-
-	// Extract files
 	for {
 		header, err := tr.Next()
 		if err == io.EOF {
-			break
+			return x.checkSymlinks()
 		}
 		if err != nil {
 			return fmt.Errorf("failed to read tar header: %w", err)
 		}
 
-		// Clean the path
-		target := filepath.Join(dest, header.Name)
-
-		// Validate path to prevent directory traversal
-		if !strings.HasPrefix(filepath.Clean(target), filepath.Clean(dest)) {
-			return fmt.Errorf("invalid file path: %s", header.Name)
+		if err := x.count(); err != nil {
+			return err
 		}
 
-		// Process based on type
 		switch header.Typeflag {
 		case tar.TypeDir:
-			// Create directory
-			if err := os.MkdirAll(target, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", target, err)
-			}
-
+			err = x.mkdir(header.Name, fs.FileMode(header.Mode))
 		case tar.TypeReg:
-			// Create directory for file if needed
-			dir := filepath.Dir(target)
-			if err := os.MkdirAll(dir, 0755); err != nil {
-				return fmt.Errorf("failed to create directory %s: %w", dir, err)
-			}
+			err = x.writeFile(header.Name, fs.FileMode(header.Mode), tr)
+		case tar.TypeSymlink:
+			err = x.symlink(header.Name, header.Linkname)
+		case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+			err = special(header.Name)
+		default:
+			// Skip other types (hard links, extended headers, etc.)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
 
-			// Create file
-			file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode))
-			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", target, err)
-			}
+// extractZip extracts a zip archive
+func (x *extractor) extractZip(path string) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open zip: %w", err)
+	}
+	defer zr.Close()
 
-			// Copy file contents
-			if _, err := io.Copy(file, tr); err != nil {
-				file.Close()
-				return fmt.Errorf("failed to write file %s: %w", target, err)
-			}
-			file.Close()
+	for _, f := range zr.File {
+		if err := x.count(); err != nil {
+			return err
+		}
 
+		mode := f.Mode()
+		switch {
+		case mode.IsDir():
+			err = x.mkdir(f.Name, mode)
+		case mode&fs.ModeSymlink != 0:
+			err = x.extractZipSymlink(f)
+		case mode.IsRegular():
+			err = x.extractZipFile(f)
 		default:
-			// Skip other types (symlinks, etc.)
-			continue
+			err = special(f.Name)
+		}
+		if err != nil {
+			return err
 		}
 	}
+	return x.checkSymlinks()
+}
 
-	return nil
+func (x *extractor) extractZipFile(f *zip.File) error {
+	// Reject a declared size over the limit before inflating anything
+	if x.limits.MaxSize > 0 && f.UncompressedSize64 > uint64(x.limits.MaxSize) {
+		return unsafe("larger than %d bytes", x.limits.MaxSize)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return x.writeFile(f.Name, f.Mode(), rc)
+}
+
+// extractZipSymlink creates a symlink stored, as zip does, with its target
+// as the entry's content
+func (x *extractor) extractZipSymlink(f *zip.File) error {
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	linkname, err := io.ReadAll(io.LimitReader(rc, 4096))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	return x.symlink(f.Name, string(linkname))
+}
+
+// copyDirectory copies a payload directory so that the run never modifies
+// the source. Its entries are checked like those of an archive.
+func (x *extractor) copyDirectory(src string) error {
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		if err := x.count(); err != nil {
+			return err
+		}
+
+		name := filepath.ToSlash(rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		mode := info.Mode()
+		switch {
+		case mode.IsDir():
+			return x.mkdir(name, mode)
+		case mode&fs.ModeSymlink != 0:
+			linkname, err := os.Readlink(path)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", rel, err)
+			}
+			return x.symlink(name, linkname)
+		case mode.IsRegular():
+			file, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open %s: %w", rel, err)
+			}
+			defer file.Close()
+			return x.writeFile(name, mode, file)
+		default:
+			return special(name)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return x.checkSymlinks()
 }
 
 // Cleanup removes the extracted payload directory
@@ -108,4 +460,3 @@ func Cleanup(dir string) error {
 	}
 	return os.RemoveAll(dir)
 }
-
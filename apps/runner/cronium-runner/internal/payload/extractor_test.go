@@ -0,0 +1,257 @@
+package payload
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// entry is one crafted archive member
+type entry struct {
+	name     string
+	body     string
+	linkname string
+	typeflag byte
+	mode     fs.FileMode
+}
+
+func file(name, body string) entry {
+	return entry{name: name, body: body, typeflag: tar.TypeReg, mode: 0644}
+}
+
+func symlink(name, linkname string) entry {
+	return entry{name: name, linkname: linkname, typeflag: tar.TypeSymlink, mode: 0777 | fs.ModeSymlink}
+}
+
+func writeTarGz(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{
+			Name:     e.name,
+			Linkname: e.linkname,
+			Typeflag: e.typeflag,
+			Mode:     int64(e.mode.Perm()),
+			Size:     int64(len(e.body)),
+		}
+		if e.typeflag != tar.TypeReg {
+			header.Size = 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Size > 0 {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "payload.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeZip(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		header.SetMode(e.mode)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := e.body
+		if e.mode&fs.ModeSymlink != 0 {
+			body = e.linkname
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "payload.zip")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExtractRejectsUnsafePayloads(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name    string
+		payload func(t *testing.T) string
+		limits  Limits
+	}{
+		{
+			name:    "tar entry escaping with dot-dot",
+			payload: func(t *testing.T) string { return writeTarGz(t, file("../../evil.sh", "x")) },
+		},
+		{
+			name:    "tar entry with absolute path",
+			payload: func(t *testing.T) string { return writeTarGz(t, file("/tmp/evil.sh", "x")) },
+		},
+		{
+			name:    "tar symlink to absolute path",
+			payload: func(t *testing.T) string { return writeTarGz(t, symlink("etc", outside)) },
+		},
+		{
+			name:    "tar symlink escaping with dot-dot",
+			payload: func(t *testing.T) string { return writeTarGz(t, symlink("up", "../..")) },
+		},
+		{
+			name: "tar symlink escaping through a later symlink",
+			payload: func(t *testing.T) string {
+				return writeTarGz(t, symlink("here", "."), symlink("up", "here/.."))
+			},
+		},
+		{
+			name: "tar file written over a symlink",
+			payload: func(t *testing.T) string {
+				return writeTarGz(t, symlink("script.sh", "other.sh"), file("script.sh", "x"))
+			},
+		},
+		{
+			name: "tar character device",
+			payload: func(t *testing.T) string {
+				return writeTarGz(t, entry{name: "null", typeflag: tar.TypeChar, mode: 0666})
+			},
+		},
+		{
+			name: "tar named pipe",
+			payload: func(t *testing.T) string {
+				return writeTarGz(t, entry{name: "pipe", typeflag: tar.TypeFifo, mode: 0666})
+			},
+		},
+		{
+			name:    "tar larger than the size limit",
+			payload: func(t *testing.T) string { return writeTarGz(t, file("big", strings.Repeat("0", 4096))) },
+			limits:  Limits{MaxSize: 1024},
+		},
+		{
+			name: "tar with more files than the limit",
+			payload: func(t *testing.T) string {
+				return writeTarGz(t, file("a", "1"), file("b", "2"), file("c", "3"))
+			},
+			limits: Limits{MaxFiles: 2},
+		},
+		{
+			name:    "zip entry escaping with backslashes",
+			payload: func(t *testing.T) string { return writeZip(t, file("..\\..\\evil.bat", "x")) },
+		},
+		{
+			name:    "zip symlink escaping",
+			payload: func(t *testing.T) string { return writeZip(t, symlink("up", "../../..")) },
+		},
+		{
+			name: "zip device file",
+			payload: func(t *testing.T) string {
+				return writeZip(t, entry{name: "null", mode: 0666 | fs.ModeDevice | fs.ModeCharDevice})
+			},
+		},
+		{
+			name:    "zip larger than the size limit",
+			payload: func(t *testing.T) string { return writeZip(t, file("big", strings.Repeat("0", 1<<20))) },
+			limits:  Limits{MaxSize: 1024},
+		},
+		{
+			name: "directory with a symlink outside",
+			payload: func(t *testing.T) string {
+				dir := t.TempDir()
+				if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+					t.Fatal(err)
+				}
+				return dir
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := tt.limits
+			if limits == (Limits{}) {
+				limits = DefaultLimits
+			}
+
+			dir, err := ExtractWithLimits(tt.payload(t), limits)
+			if err == nil {
+				Cleanup(dir)
+				t.Fatal("expected extraction to fail")
+			}
+			if !errors.Is(err, ErrUnsafePayload) {
+				t.Fatalf("expected ErrUnsafePayload, got %v", err)
+			}
+		})
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("extraction wrote outside the work directory: %v", entries)
+	}
+}
+
+func TestExtractFormats(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "script.sh"), []byte("echo hi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	payloads := map[Format]string{
+		FormatTarGz:     writeTarGz(t, file("script.sh", "echo hi"), symlink("run.sh", "script.sh")),
+		FormatZip:       writeZip(t, file("lib\\script.sh", "echo hi"), symlink("run.sh", "lib/script.sh")),
+		FormatDirectory: src,
+	}
+
+	for format, path := range payloads {
+		t.Run(string(format), func(t *testing.T) {
+			detected, err := DetectFormat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detected != format {
+				t.Fatalf("detected %s, want %s", detected, format)
+			}
+
+			dir, err := Extract(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer Cleanup(dir)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) == 0 {
+				t.Fatal("nothing extracted")
+			}
+		})
+	}
+}
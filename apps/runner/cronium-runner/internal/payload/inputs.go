@@ -0,0 +1,95 @@
+package payload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/pkg/types"
+)
+
+// InputsDir is the directory of the workspace input objects are downloaded
+// to
+const InputsDir = "inputs"
+
+// FetchInputs streams each referenced input object into the inputs
+// directory of workDir, checking its size and SHA-256 as it is written. A
+// partial or rejected download is removed.
+func FetchInputs(ctx context.Context, client *http.Client, workDir string, refs []types.InputRef) error {
+	dir := filepath.Join(workDir, InputsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create inputs directory: %w", err)
+	}
+	for _, ref := range refs {
+		if err := fetchInput(ctx, client, dir, ref); err != nil {
+			return fmt.Errorf("input %s: %w", ref.Name, err)
+		}
+	}
+	return nil
+}
+
+func fetchInput(ctx context.Context, client *http.Client, dir string, ref types.InputRef) error {
+	if ref.Name == "" || strings.ContainsAny(ref.Name, `/\`) || ref.Name == "." || ref.Name == ".." {
+		return fmt.Errorf("invalid name")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ref.URL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	for k, v := range ref.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+	if ref.MaxSize > 0 && resp.ContentLength > ref.MaxSize {
+		return fmt.Errorf("object is %d bytes, larger than the limit of %d", resp.ContentLength, ref.MaxSize)
+	}
+
+	target := filepath.Join(dir, ref.Name)
+	tmp, err := os.CreateTemp(dir, "."+ref.Name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Read one byte past the limit to tell a full object from a larger one
+	var body io.Reader = resp.Body
+	if ref.MaxSize > 0 {
+		body = io.LimitReader(resp.Body, ref.MaxSize+1)
+	}
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, hash), body)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	if ref.MaxSize > 0 && n > ref.MaxSize {
+		return fmt.Errorf("object is larger than the limit of %d bytes", ref.MaxSize)
+	}
+	if ref.SHA256 != "" {
+		if got := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(got, ref.SHA256) {
+			return fmt.Errorf("object has SHA-256 %s, expected %s", got, ref.SHA256)
+		}
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,90 @@
+package payload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runner/cronium-runner/pkg/types"
+)
+
+func TestFetchInputs(t *testing.T) {
+	body := "id,value\n1,42\n"
+	sum := sha256.Sum256([]byte(body))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	headers := map[string]string{"Authorization": "Bearer secret"}
+
+	tests := []struct {
+		name    string
+		ref     types.InputRef
+		wantErr bool
+	}{
+		{"matching", types.InputRef{Name: "data.csv", URL: srv.URL, Headers: headers, SHA256: hex.EncodeToString(sum[:]), MaxSize: 1024}, false},
+		{"checksum mismatch", types.InputRef{Name: "data.csv", URL: srv.URL, Headers: headers, SHA256: hex.EncodeToString(make([]byte, 32)), MaxSize: 1024}, true},
+		{"too large", types.InputRef{Name: "data.csv", URL: srv.URL, Headers: headers, MaxSize: 4}, true},
+		{"http error", types.InputRef{Name: "data.csv", URL: srv.URL, MaxSize: 1024}, true},
+		{"path in name", types.InputRef{Name: "../data.csv", URL: srv.URL, Headers: headers, MaxSize: 1024}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			err := FetchInputs(context.Background(), srv.Client(), workDir, []types.InputRef{tt.ref})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchInputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			got, readErr := os.ReadFile(filepath.Join(workDir, InputsDir, "data.csv"))
+			if tt.wantErr {
+				if readErr == nil {
+					t.Fatal("rejected input was left in the workspace")
+				}
+				entries, _ := os.ReadDir(filepath.Join(workDir, InputsDir))
+				if len(entries) != 0 {
+					t.Fatalf("partial download left behind: %v", entries)
+				}
+				return
+			}
+			if string(got) != body {
+				t.Fatalf("input = %q, want %q", got, body)
+			}
+		})
+	}
+}
+
+func TestFetchInputsStopsAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := FetchInputs(ctx, srv.Client(), t.TempDir(), []types.InputRef{{Name: "data.csv", URL: srv.URL, MaxSize: 1024}})
+	if err == nil {
+		t.Fatal("FetchInputs() of a stalled download succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("FetchInputs() returned after %v, want the deadline", elapsed)
+	}
+}
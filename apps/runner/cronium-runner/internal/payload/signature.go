@@ -0,0 +1,238 @@
+package payload
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// TrustedKeys are the comma-separated public keys embedded at build time
+// with -ldflags "-X <module>/internal/payload.TrustedKeys=<key>,<key>"
+var TrustedKeys = ""
+
+// PublicKeyEnv holds further trusted public keys, comma-separated
+const PublicKeyEnv = "CRONIUM_PUBLIC_KEY"
+
+// ErrSignature is wrapped by every failure to verify a payload's signature
+var ErrSignature = errors.New("payload signature verification failed")
+
+// Minisign signature algorithms: Ed signs the file itself, ED its BLAKE2b-512
+// hash
+var (
+	algLegacy    = []byte("Ed")
+	algPrehashed = []byte("ED")
+)
+
+// PublicKey is a trusted Ed25519 key. Minisign keys carry the ID their
+// signatures name; raw keys have none.
+type PublicKey struct {
+	ID  []byte
+	Key ed25519.PublicKey
+}
+
+// KeyID returns the key ID the way minisign prints it, or a fingerprint for
+// raw keys
+func (k PublicKey) KeyID() string {
+	if len(k.ID) == 8 {
+		return formatKeyID(k.ID)
+	}
+	sum := sha256.Sum256(k.Key)
+	return fmt.Sprintf("%X", sum[:8])
+}
+
+func formatKeyID(id []byte) string {
+	return fmt.Sprintf("%016X", binary.LittleEndian.Uint64(id))
+}
+
+// ParsePublicKey parses a minisign public key, with or without its
+// untrusted comment line, or a base64 Ed25519 key
+func ParsePublicKey(s string) (PublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	encoded := strings.TrimSpace(lines[len(lines)-1])
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("invalid public key: %w", err)
+	}
+	switch {
+	case len(raw) == ed25519.PublicKeySize:
+		return PublicKey{Key: ed25519.PublicKey(raw)}, nil
+	case len(raw) == 2+8+ed25519.PublicKeySize && bytes.Equal(raw[:2], algLegacy):
+		return PublicKey{ID: raw[2:10], Key: ed25519.PublicKey(raw[10:])}, nil
+	}
+	return PublicKey{}, fmt.Errorf("invalid public key: not an Ed25519 or minisign key")
+}
+
+// LoadTrustedKeys returns the keys embedded at build time and those in
+// CRONIUM_PUBLIC_KEY
+func LoadTrustedKeys() ([]PublicKey, error) {
+	var keys []PublicKey
+	for _, list := range []string{TrustedKeys, os.Getenv(PublicKeyEnv)} {
+		for _, s := range strings.Split(list, ",") {
+			if strings.TrimSpace(s) == "" {
+				continue
+			}
+			key, err := ParsePublicKey(s)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// Signature is a detached payload signature: a minisign signature file or a
+// base64 Ed25519 signature of the payload
+type Signature struct {
+	KeyID          []byte
+	Prehashed      bool
+	Sig            []byte
+	TrustedComment string
+	// Signature of Sig and the trusted comment; minisign only
+	GlobalSig []byte
+}
+
+// ParseSignature parses a detached signature
+func ParseSignature(data []byte) (*Signature, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 1 {
+		sig, err := base64.StdEncoding.DecodeString(lines[0])
+		if err != nil || len(sig) != ed25519.SignatureSize {
+			return nil, fmt.Errorf("invalid signature")
+		}
+		return &Signature{Sig: sig}, nil
+	}
+
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") {
+		return nil, fmt.Errorf("invalid minisign signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid minisign signature")
+	}
+	comment, ok := strings.CutPrefix(lines[2], "trusted comment:")
+	comment = strings.TrimPrefix(comment, " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid minisign signature: missing trusted comment")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("invalid minisign signature: bad global signature")
+	}
+
+	sig := &Signature{KeyID: raw[2:10], Sig: raw[10:], TrustedComment: comment, GlobalSig: global}
+	switch {
+	case bytes.Equal(raw[:2], algPrehashed):
+		sig.Prehashed = true
+	case !bytes.Equal(raw[:2], algLegacy):
+		return nil, fmt.Errorf("unsupported minisign signature algorithm %q", raw[:2])
+	}
+	return sig, nil
+}
+
+// Signer is the key that signed a verified payload
+type Signer struct {
+	KeyID          string `json:"keyId"`
+	TrustedComment string `json:"trustedComment,omitempty"`
+}
+
+// VerifySignature checks a payload against its detached signature at
+// <payload>.sig with the trusted keys. Without trusted keys signatures are
+// not checked and nil is returned; with them an unsigned, tampered or
+// foreign-signed payload is rejected.
+func VerifySignature(payloadPath string) (*Signer, error) {
+	keys, err := LoadTrustedKeys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return Verify(payloadPath, keys)
+}
+
+// Verify checks a payload against its detached signature at <payload>.sig
+func Verify(payloadPath string, keys []PublicKey) (*Signer, error) {
+	info, err := os.Stat(payloadPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("%w: directory payloads can't be signed", ErrSignature)
+	}
+
+	data, err := os.ReadFile(payloadPath + ".sig")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: payload is not signed", ErrSignature)
+		}
+		return nil, fmt.Errorf("failed to read signature: %w", err)
+	}
+	sig, err := ParseSignature(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+
+	message, err := signedMessage(payloadPath, sig.Prehashed)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if sig.KeyID != nil && key.ID != nil && !bytes.Equal(sig.KeyID, key.ID) {
+			continue
+		}
+		if !ed25519.Verify(key.Key, message, sig.Sig) {
+			continue
+		}
+		// The trusted comment is only trusted once its own signature checks out
+		if sig.GlobalSig != nil && !ed25519.Verify(key.Key, append(append([]byte{}, sig.Sig...), sig.TrustedComment...), sig.GlobalSig) {
+			return nil, fmt.Errorf("%w: trusted comment was modified", ErrSignature)
+		}
+		return &Signer{KeyID: key.KeyID(), TrustedComment: sig.TrustedComment}, nil
+	}
+
+	if sig.KeyID != nil {
+		return nil, fmt.Errorf("%w: no trusted key %s produced this signature", ErrSignature, formatKeyID(sig.KeyID))
+	}
+	return nil, fmt.Errorf("%w: signature does not match any trusted key", ErrSignature)
+}
+
+// signedMessage returns what the signature covers: the payload itself, or
+// its BLAKE2b-512 hash for prehashed minisign signatures
+func signedMessage(payloadPath string, prehashed bool) ([]byte, error) {
+	if !prehashed {
+		data, err := os.ReadFile(payloadPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read payload: %w", err)
+		}
+		return data, nil
+	}
+
+	file, err := os.Open(payloadPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	defer file.Close()
+	hash, _ := blake2b.New512(nil)
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to hash payload: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,120 @@
+package payload
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisignKey returns a minisign key pair as the public key string and a
+// function writing minisign signatures
+func minisignKey(t *testing.T) (string, func(data []byte, prehashed bool, comment string) []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	public := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+
+	sign := func(data []byte, prehashed bool, comment string) []byte {
+		alg := []byte("Ed")
+		if prehashed {
+			alg = []byte("ED")
+			sum := blake2b.Sum512(data)
+			data = sum[:]
+		}
+		sig := ed25519.Sign(priv, data)
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+		return []byte("untrusted comment: signature\n" +
+			base64.StdEncoding.EncodeToString(append(append(alg, id...), sig...)) + "\n" +
+			"trusted comment: " + comment + "\n" +
+			base64.StdEncoding.EncodeToString(global) + "\n")
+	}
+	return public, sign
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	public, sign := minisignKey(t)
+	key, err := ParsePublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeTarGz(t, file("manifest.yaml", "version: v1\n"))
+	data, _ := os.ReadFile(path)
+
+	for _, prehashed := range []bool{false, true} {
+		os.WriteFile(path+".sig", sign(data, prehashed, "job-1"), 0644)
+		signer, err := Verify(path, []PublicKey{key})
+		if err != nil {
+			t.Fatalf("prehashed=%v: %v", prehashed, err)
+		}
+		if signer.KeyID != key.KeyID() || signer.TrustedComment != "job-1" {
+			t.Errorf("unexpected signer %+v", signer)
+		}
+	}
+
+	// A changed trusted comment is caught by the global signature
+	sig := strings.Replace(string(sign(data, true, "job-1")), "comment: job-1", "comment: job-2", 1)
+	os.WriteFile(path+".sig", []byte(sig), 0644)
+	if _, err := Verify(path, []PublicKey{key}); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature error for a modified comment, got %v", err)
+	}
+
+	// A tampered payload is rejected
+	os.WriteFile(path+".sig", sign(data, true, "job-1"), 0644)
+	os.WriteFile(path, append(data, 0), 0644)
+	if _, err := Verify(path, []PublicKey{key}); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature error for a tampered payload, got %v", err)
+	}
+
+	// So is a payload signed by another key
+	otherPublic, otherSign := minisignKey(t)
+	os.WriteFile(path+".sig", otherSign(append(data, 0), false, ""), 0644)
+	if _, err := Verify(path, []PublicKey{key}); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a signature error for a foreign key, got %v", err)
+	}
+	other, _ := ParsePublicKey(otherPublic)
+	if _, err := Verify(path, []PublicKey{key, other}); err != nil {
+		t.Errorf("expected the second key to verify: %v", err)
+	}
+}
+
+func TestVerifySignatureRawKey(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	t.Setenv(PublicKeyEnv, base64.StdEncoding.EncodeToString(pub))
+	path := writeTarGz(t, file("manifest.yaml", "version: v1\n"))
+
+	if _, err := VerifySignature(path); !errors.Is(err, ErrSignature) {
+		t.Fatalf("expected an unsigned payload to be rejected, got %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path+".sig", []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))+"\n"), 0644)
+	signer, err := VerifySignature(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signer.KeyID) != 16 {
+		t.Errorf("expected a key fingerprint, got %q", signer.KeyID)
+	}
+
+	if _, err := VerifySignature(filepath.Dir(path)); !errors.Is(err, ErrSignature) {
+		t.Errorf("expected a directory payload to be rejected, got %v", err)
+	}
+
+	// Without trusted keys nothing is checked
+	t.Setenv(PublicKeyEnv, "")
+	os.Remove(path + ".sig")
+	if signer, err := VerifySignature(path); err != nil || signer != nil {
+		t.Errorf("expected no verification without keys, got %v, %v", signer, err)
+	}
+}
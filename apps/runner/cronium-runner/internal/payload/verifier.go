@@ -35,24 +35,6 @@ func VerifyChecksum(payloadPath string, expectedChecksum string) error {
 	return nil
 }
 
-// VerifySignature verifies the signature of a payload (placeholder for now)
-func VerifySignature(payloadPath string) error {
-	// For Phase 1, we'll skip actual signature verification
-	// This will be implemented properly when we set up cosign
-	sigPath := payloadPath + ".sig"
-	if _, err := os.Stat(sigPath); err != nil {
-		if os.IsNotExist(err) {
-			// No signature file, skip verification
-			return nil
-		}
-		return fmt.Errorf("failed to check signature file: %w", err)
-	}
-
-	// TODO: Implement actual cosign verification
-	// For now, just check that signature file exists
-	return nil
-}
-
 // GenerateChecksum calculates the SHA256 checksum of a file
 func GenerateChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath)
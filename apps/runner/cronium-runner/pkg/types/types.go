@@ -16,6 +16,7 @@ type Manifest struct {
 	Version     string            `yaml:"version"`
 	Interpreter ScriptType        `yaml:"interpreter"`
 	Entrypoint  string            `yaml:"entrypoint"`
+	ScriptHash  string            `yaml:"scriptHash,omitempty"`
 	Environment map[string]string `yaml:"environment,omitempty"`
 	Metadata    Metadata          `yaml:"metadata"`
 }
@@ -33,5 +34,28 @@ type Metadata struct {
 	APIToken     string                 `yaml:"apiToken,omitempty"`
 	InputData    interface{}            `yaml:"inputData,omitempty"`
 	Extra        map[string]interface{} `yaml:"extra,omitempty"`
+
+	// Signed one-shot URL for pushing results back in bundled mode
+	ResultUploadURL string `yaml:"resultUploadUrl,omitempty"`
+
+	// Retry information; the idempotency key is stable across attempts
+	Attempt        int    `yaml:"attempt,omitempty"`
+	MaxAttempts    int    `yaml:"maxAttempts,omitempty"`
+	IdempotencyKey string `yaml:"idempotencyKey,omitempty"`
+
+	// Large input objects downloaded into the workspace before the script
+	// starts, within the job's timeout
+	InputRefs []InputRef    `yaml:"inputRefs,omitempty"`
+	Timeout   time.Duration `yaml:"timeout,omitempty"`
 }
 
+// InputRef is an input object downloaded to inputs/<Name> in the workspace.
+// The download fails when it exceeds MaxSize bytes or SHA256 is set and does
+// not match.
+type InputRef struct {
+	Name    string            `yaml:"name"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers,omitempty"`
+	SHA256  string            `yaml:"sha256,omitempty"`
+	MaxSize int64             `yaml:"maxSize"`
+}
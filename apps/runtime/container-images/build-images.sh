@@ -78,6 +78,9 @@ for lang in python nodejs bash; do
     fi
 done
 
+# Build the static analysis tooling image
+build_image "${SCRIPT_DIR}/analysis" "${IMAGE_PREFIX}/analysis:${TAG}"
+
 echo -e "${GREEN}All images built successfully!${NC}"
 
 # List built images
@@ -0,0 +1,113 @@
+// Cronium helper socket client.
+//
+//   const rpc = require('cronium_rpc');
+//   const value = await rpc.call('getVariable', { key: 'counter' });
+//   await rpc.call('setVariable', { key: 'counter', value: value + 1 });
+const net = require('net');
+const fs = require('fs');
+
+class CroniumRPCError extends Error {
+    constructor(code, message) {
+        super(message);
+        this.code = code;
+    }
+}
+
+class Client {
+    constructor(path, token) {
+        this.path = path || process.env.CRONIUM_HELPER_SOCKET;
+        this.token = token || process.env.CRONIUM_EXECUTION_TOKEN;
+        this.socket = null;
+        this.buffer = '';
+        this.nextId = 0;
+        this.pending = new Map();
+    }
+
+    connect() {
+        if (!this.path) {
+            throw new CroniumRPCError(-32000, 'helper socket not available (CRONIUM_HELPER_SOCKET)');
+        }
+        this.socket = net.createConnection(this.path);
+        this.socket.setEncoding('utf8');
+        this.socket.on('data', (chunk) => {
+            this.buffer += chunk;
+            let newline;
+            while ((newline = this.buffer.indexOf('\n')) >= 0) {
+                const line = this.buffer.slice(0, newline);
+                this.buffer = this.buffer.slice(newline + 1);
+                if (line.trim()) {
+                    this.receive(JSON.parse(line));
+                }
+            }
+        });
+        const fail = (error) => {
+            for (const { reject } of this.pending.values()) {
+                reject(error);
+            }
+            this.pending.clear();
+            this.socket = null;
+        };
+        this.socket.on('error', fail);
+        this.socket.on('close', () => fail(new CroniumRPCError(-32000, 'helper socket closed the connection')));
+    }
+
+    receive(response) {
+        const call = this.pending.get(response.id);
+        if (!call) {
+            return;
+        }
+        this.pending.delete(response.id);
+        if (this.pending.size === 0 && this.socket) {
+            this.socket.unref();
+        }
+        if (response.error) {
+            call.reject(new CroniumRPCError(response.error.code, response.error.message));
+        } else {
+            call.resolve(response.result);
+        }
+    }
+
+    call(method, params) {
+        return new Promise((resolve, reject) => {
+            if (!this.socket) {
+                this.connect();
+            }
+            const id = ++this.nextId;
+            const request = { jsonrpc: '2.0', id, method, params: params || {} };
+            if (this.token) {
+                request.token = this.token;
+            }
+            this.pending.set(id, { resolve, reject });
+            this.socket.ref();
+            this.socket.write(JSON.stringify(request) + '\n');
+        });
+    }
+
+    close() {
+        if (this.socket) {
+            this.socket.end();
+            this.socket = null;
+        }
+    }
+}
+
+let client = null;
+
+module.exports = {
+    Client,
+    CroniumRPCError,
+
+    // True when the helper socket can be used
+    available() {
+        const path = process.env.CRONIUM_HELPER_SOCKET;
+        return !!path && fs.existsSync(path);
+    },
+
+    // Call a helper method on the shared connection
+    call(method, params) {
+        if (!client) {
+            client = new Client();
+        }
+        return client.call(method, params);
+    },
+};
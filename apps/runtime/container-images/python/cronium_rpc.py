@@ -0,0 +1,76 @@
+"""Cronium helper socket client.
+
+    import cronium_rpc
+    value = cronium_rpc.call("getVariable", {"key": "counter"})
+    cronium_rpc.call("setVariable", {"key": "counter", "value": value + 1})
+"""
+import json
+import os
+import socket
+import threading
+
+
+class CroniumRPCError(Exception):
+    """A helper call the socket answered with an error"""
+
+    def __init__(self, code, message):
+        super().__init__(message)
+        self.code = code
+
+
+class Client:
+    def __init__(self, path=None, token=None):
+        self.path = path or os.environ.get("CRONIUM_HELPER_SOCKET")
+        self.token = token or os.environ.get("CRONIUM_EXECUTION_TOKEN")
+        self._sock = None
+        self._reader = None
+        self._next_id = 0
+        self._lock = threading.Lock()
+
+    def _connect(self):
+        if not self.path:
+            raise CroniumRPCError(-32000, "helper socket not available (CRONIUM_HELPER_SOCKET)")
+        self._sock = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        self._sock.connect(self.path)
+        self._reader = self._sock.makefile("rb")
+
+    def call(self, method, params=None):
+        with self._lock:
+            if self._sock is None:
+                self._connect()
+            self._next_id += 1
+            request = {"jsonrpc": "2.0", "id": self._next_id, "method": method, "params": params or {}}
+            if self.token:
+                request["token"] = self.token
+            self._sock.sendall(json.dumps(request).encode() + b"\n")
+            line = self._reader.readline()
+            if not line:
+                self.close()
+                raise CroniumRPCError(-32000, "helper socket closed the connection")
+        response = json.loads(line)
+        if "error" in response:
+            raise CroniumRPCError(response["error"]["code"], response["error"]["message"])
+        return response.get("result")
+
+    def close(self):
+        if self._sock is not None:
+            self._sock.close()
+            self._sock = None
+            self._reader = None
+
+
+_client = None
+
+
+def available():
+    """True when the helper socket can be used"""
+    path = os.environ.get("CRONIUM_HELPER_SOCKET")
+    return bool(path) and os.path.exists(path)
+
+
+def call(method, params=None):
+    """Call a helper method on the shared connection"""
+    global _client
+    if _client is None:
+        _client = Client()
+    return _client.call(method, params)
@@ -10,9 +10,16 @@ import (
 	"time"
 
 	"github.com/addison-moore/cronium/apps/runtime/internal/api"
+	"github.com/addison-moore/cronium/apps/runtime/internal/auth"
 	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
 	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/credentials"
+	"github.com/addison-moore/cronium/apps/runtime/internal/envelope"
+	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
+	"github.com/addison-moore/cronium/apps/runtime/internal/rpc"
 	"github.com/addison-moore/cronium/apps/runtime/internal/service"
+	"github.com/addison-moore/cronium/apps/runtime/internal/storage"
+	"github.com/addison-moore/cronium/apps/runtime/internal/tools"
 	"github.com/sirupsen/logrus"
 )
 
@@ -48,6 +55,12 @@ func main() {
 	}
 	defer cacheClient.Close()
 
+	// Initialize output storage
+	storageManager, err := storage.NewManager(cfg.Storage, cacheClient, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize storage")
+	}
+
 	// Initialize backend client
 	backendClient := service.NewBackendClient(cfg.Backend, log)
 
@@ -55,12 +68,48 @@ func main() {
 	runtimeService := service.NewRuntimeService(
 		backendClient,
 		cacheClient,
+		storageManager,
 		cfg,
 		log,
 	)
 
+	// Seal sensitive variables in the cache with per-execution data keys
+	masterKey, err := cfg.Encryption.Key()
+	if err != nil {
+		log.WithError(err).Fatal("Invalid encryption config")
+	}
+	if masterKey != nil {
+		wrapper, err := envelope.NewLocalWrapper(masterKey)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to initialize encryption")
+		}
+		runtimeService.WithKeyring(envelope.NewKeyring(wrapper, cacheClient))
+	} else {
+		log.Warn("No encryption master key configured; sensitive variables will not be cached")
+	}
+
+	// Mint short-lived credentials from the configured providers
+	runtimeService.WithCredentials(credentials.NewBroker(cfg.Credentials, cacheClient, log))
+
+	// Initialize local tools
+	toolRegistry, err := tools.NewRegistry(cfg.Tools, log)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to initialize tools")
+	}
+	runtimeService.WithTools(toolRegistry)
+
+	// Send partial results to the backend in the background
+	syncCtx, stopSync := context.WithCancel(context.Background())
+	syncDone := make(chan struct{})
+	go func() {
+		runtimeService.RunResultSync(syncCtx)
+		close(syncDone)
+	}()
+
 	// Create API router
-	router := api.NewRouter(runtimeService, cfg, log)
+	// One rate limit budget per execution across HTTP and the helper socket
+	rateLimiter := middleware.NewRateLimiter(cfg.Security.RateLimitPerMin, log)
+	router := api.NewRouter(runtimeService, cfg, rateLimiter, log)
 
 	// Create HTTP server
 	srv := &http.Server{
@@ -79,6 +128,18 @@ func main() {
 		}
 	}()
 
+	// Serve the helper protocol on a Unix socket for scripts sharing it
+	var socketServer *rpc.Server
+	if cfg.Server.SocketPath != "" {
+		socketServer = rpc.NewServer(runtimeService, auth.NewJWTManager(cfg.Auth), rateLimiter, cfg.Server.WriteTimeout, log)
+		go func() {
+			log.WithField("path", cfg.Server.SocketPath).Info("Helper socket starting")
+			if err := socketServer.ListenAndServe(cfg.Server.SocketPath); err != nil {
+				log.WithError(err).Fatal("Failed to start helper socket")
+			}
+		}()
+	}
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
@@ -93,6 +154,14 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.WithError(err).Error("Server forced to shutdown")
 	}
+	if socketServer != nil {
+		socketServer.Close()
+		os.Remove(cfg.Server.SocketPath)
+	}
+
+	// Flush partial results still pending
+	stopSync()
+	<-syncDone
 
 	log.Info("Server stopped")
 }
\ No newline at end of file
@@ -0,0 +1,496 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// Security schemes of the runtime API
+const (
+	securityNone      = ""
+	securityBearer    = "bearerAuth"
+	securitySignedURL = "signedUrl"
+	securityService   = "serviceToken"
+)
+
+// operation describes one route of the runtime API for the OpenAPI document.
+// Every route registered in NewRouter must have an operation; the contract
+// test fails when the two drift apart.
+type operation struct {
+	method   string
+	path     string
+	id       string
+	tag      string
+	summary  string
+	security string
+	query    []queryParam
+
+	// Request body; nil for none. contentTypes defaults to application/json.
+	request      any
+	contentTypes []string
+
+	// Response data, wrapped in {"success", "data"} unless raw is set
+	status   int
+	response any
+	raw      bool
+	rawType  string // content type of raw, non-JSON responses
+	errors   []int
+}
+
+// queryParam is a query string parameter of an operation
+type queryParam struct {
+	name        string
+	description string
+	required    bool
+}
+
+// Request and response bodies the handlers decode into anonymous structs
+type (
+	outputRequest struct {
+		Data   any               `json:"data"`
+		Render *types.RenderHint `json:"render,omitempty"`
+	}
+	variableRequest struct {
+		Value any `json:"value"`
+	}
+	variableResponse struct {
+		Key   string `json:"key"`
+		Value any    `json:"value"`
+	}
+	incrementRequest struct {
+		By *float64 `json:"by,omitempty"`
+	}
+	setMemberResponse struct {
+		Key   string `json:"key"`
+		Value []any  `json:"value"`
+		Added bool   `json:"added"`
+	}
+	invalidateResponse struct {
+		Removed int `json:"removed"`
+	}
+	revokeResponse struct {
+		Revoked int `json:"revoked"`
+	}
+	conditionRequest struct {
+		Condition bool `json:"condition"`
+	}
+	healthResponse struct {
+		Status string    `json:"status"`
+		Time   time.Time `json:"time"`
+	}
+)
+
+// operations lists the routes of the runtime API
+var operations = []operation{
+	{
+		method: http.MethodGet, path: "/health", id: "getHealth", tag: "service",
+		summary: "Report service health", status: http.StatusOK, response: healthResponse{}, raw: true,
+	},
+	{
+		method: http.MethodGet, path: "/metrics", id: "getMetrics", tag: "service",
+		summary: "Prometheus metrics", status: http.StatusOK, raw: true, rawType: "text/plain",
+	},
+	{
+		method: http.MethodGet, path: "/openapi.json", id: "getOpenAPI", tag: "service",
+		summary: "This OpenAPI document", status: http.StatusOK, raw: true, rawType: "application/json",
+	},
+	{
+		method: http.MethodPost, path: "/results/{id}", id: "submitResults", tag: "results",
+		summary:  "One-shot upload of final output and variables from a bundled-mode runner",
+		security: securitySignedURL, request: types.ResultUpload{},
+		query:  []queryParam{{name: "partial", description: "true for results so far, which may be sent repeatedly before the final upload"}},
+		status: http.StatusOK, errors: []int{400, 401, 409, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/input", id: "getInput", tag: "executions",
+		summary: "Get the execution's input data", security: securityBearer,
+		status: http.StatusOK, response: new(any), errors: []int{401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/output", id: "setOutput", tag: "executions",
+		summary: "Set the execution's output data, optionally with a rendering hint (table, json, markdown, metric)", security: securityBearer, request: outputRequest{},
+		status: http.StatusOK, errors: []int{400, 401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/files", id: "uploadFile", tag: "executions",
+		summary:  "Upload a file artifact as multipart form data, or as the raw body named by ?name=",
+		security: securityBearer, query: []queryParam{{name: "name", description: "file name for raw uploads"}},
+		request: []byte{}, contentTypes: []string{"multipart/form-data", "application/octet-stream"},
+		status: http.StatusCreated, response: types.Artifact{}, errors: []int{400, 401, 403, 413, 429, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/context", id: "getContext", tag: "executions",
+		summary: "Get the execution context", security: securityBearer,
+		status: http.StatusOK, response: types.ExecutionContext{}, errors: []int{401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/condition", id: "setCondition", tag: "executions",
+		summary: "Set the workflow condition result", security: securityBearer, request: conditionRequest{},
+		status: http.StatusOK, errors: []int{400, 401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/progress", id: "reportProgress", tag: "executions",
+		summary: "Report how far the execution has got", security: securityBearer, request: types.ProgressReport{},
+		status: http.StatusOK, errors: []int{400, 401, 403, 429, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/variables/{key}", id: "getVariable", tag: "variables",
+		summary: "Get a variable", security: securityBearer,
+		status: http.StatusOK, response: variableResponse{}, errors: []int{401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPut, path: "/executions/{id}/variables/{key}", id: "setVariable", tag: "variables",
+		summary: "Set a variable", security: securityBearer, request: variableRequest{},
+		status: http.StatusOK, errors: []int{400, 401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/variables/{key}/increment", id: "incrementVariable", tag: "variables",
+		summary: "Atomically add to a numeric variable (by defaults to 1)", security: securityBearer, request: incrementRequest{},
+		status: http.StatusOK, response: variableResponse{}, errors: []int{400, 401, 403, 409, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/variables/{key}/append", id: "appendVariable", tag: "variables",
+		summary: "Atomically append a value to a list variable", security: securityBearer, request: variableRequest{},
+		status: http.StatusOK, response: variableResponse{}, errors: []int{400, 401, 403, 409, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/variables/{key}/add-to-set", id: "addToSetVariable", tag: "variables",
+		summary: "Atomically add a value to a set variable", security: securityBearer, request: variableRequest{},
+		status: http.StatusOK, response: setMemberResponse{}, errors: []int{400, 401, 403, 409, 429, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/scratch/{key}", id: "getScratch", tag: "scratch",
+		summary: "Get a scratch value kept for the execution only", security: securityBearer,
+		status: http.StatusOK, response: variableResponse{}, errors: []int{401, 403, 429, 500},
+	},
+	{
+		method: http.MethodPut, path: "/executions/{id}/scratch/{key}", id: "setScratch", tag: "scratch",
+		summary: "Set a scratch value kept for the execution only (null removes it)", security: securityBearer, request: variableRequest{},
+		status: http.StatusOK, errors: []int{400, 401, 403, 413, 429, 500},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/credentials", id: "getCredential", tag: "credentials",
+		summary: "Mint short-lived credentials for a configured provider role", security: securityBearer,
+		request: types.CredentialRequest{}, status: http.StatusOK, response: types.Credential{},
+		errors: []int{400, 401, 403, 404, 429, 502},
+	},
+	{
+		method: http.MethodPost, path: "/executions/{id}/jobs", id: "submitJob", tag: "jobs",
+		summary: "Queue a child job that inherits the execution's user and metadata", security: securityBearer,
+		request: types.JobRequest{}, status: http.StatusCreated, response: types.Job{},
+		errors: []int{400, 401, 403, 404, 409, 429, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/jobs/{jobId}", id: "getJob", tag: "jobs",
+		summary: "Get the status of a child job, and its output once finished", security: securityBearer,
+		status: http.StatusOK, response: types.Job{}, errors: []int{401, 403, 404, 429, 500},
+	},
+	{
+		method: http.MethodGet, path: "/executions/{id}/messages", id: "subscribeMessages", tag: "executions",
+		summary:  "Open a WebSocket on which the execution receives push messages (cancel, variable, message) as JSON text frames",
+		security: securityBearer, status: http.StatusSwitchingProtocols, response: types.PushMessage{}, raw: true,
+		errors: []int{401, 403, 429, 500},
+	},
+	{
+		method: http.MethodDelete, path: "/internal/cache/executions/{id}", id: "invalidateExecutionCache", tag: "internal",
+		summary:  "Drop an execution's cached objects so they are reloaded from the backend",
+		security: securityService, query: []queryParam{{name: "types", description: "comma-separated types to drop (context, input, output, variable); all when omitted"}},
+		status: http.StatusOK, response: invalidateResponse{}, errors: []int{400, 401, 404, 500},
+	},
+	{
+		method: http.MethodDelete, path: "/internal/cache/users/{userId}/variables/{key}", id: "invalidateVariableCache", tag: "internal",
+		summary: "Drop every cached copy of a user's variable", security: securityService,
+		status: http.StatusOK, response: invalidateResponse{}, errors: []int{401, 404, 500},
+	},
+	{
+		method: http.MethodDelete, path: "/internal/executions/{id}/credentials", id: "revokeCredentials", tag: "internal",
+		summary: "Revoke the credentials an execution still holds", security: securityService,
+		status: http.StatusOK, response: revokeResponse{}, errors: []int{401, 404, 500},
+	},
+	{
+		method: http.MethodPost, path: "/tool-actions/execute", id: "executeToolAction", tag: "tools",
+		summary: "Execute a tool action for the token's execution", security: securityBearer,
+		request: types.ToolActionConfig{}, status: http.StatusOK, response: types.ToolActionResult{}, raw: true,
+		errors: []int{400, 401, 429, 500},
+	},
+}
+
+// pathParamPattern matches the {name} parameters of a path
+var pathParamPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPISpec builds the OpenAPI 3 document of the runtime API
+func OpenAPISpec(version string) map[string]any {
+	g := &schemaGenerator{schemas: make(map[string]any)}
+
+	paths := make(map[string]any)
+	for _, op := range operations {
+		item, ok := paths[op.path].(map[string]any)
+		if !ok {
+			item = make(map[string]any)
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = g.operation(op)
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Cronium Runtime API",
+			"version":     version,
+			"description": "API used by running scripts to read input, write output, manage variables and run tool actions.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]any{
+				securityBearer: map[string]any{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "Execution-scoped token issued by the orchestrator",
+				},
+				securityService: map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Service token shared with the backend for internal endpoints",
+				},
+				securitySignedURL: map[string]any{
+					"type":        "apiKey",
+					"in":          "query",
+					"name":        "sig",
+					"description": "Signature of a URL signed by the orchestrator; expires is passed alongside",
+				},
+			},
+		},
+	}
+}
+
+// serveOpenAPI returns a handler serving the OpenAPI document, which is built
+// once since the routes do not change at run time
+func serveOpenAPI(version string) http.HandlerFunc {
+	spec, err := json.Marshal(OpenAPISpec(version))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "failed to build OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// operation builds the OpenAPI operation object for a route
+func (g *schemaGenerator) operation(op operation) map[string]any {
+	var params []any
+	for _, match := range pathParamPattern.FindAllStringSubmatch(op.path, -1) {
+		params = append(params, map[string]any{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, q := range op.query {
+		params = append(params, map[string]any{
+			"name":        q.name,
+			"in":          "query",
+			"required":    q.required,
+			"description": q.description,
+			"schema":      map[string]any{"type": "string"},
+		})
+	}
+	if op.security == securitySignedURL {
+		params = append(params, map[string]any{
+			"name":     "expires",
+			"in":       "query",
+			"required": true,
+			"schema":   map[string]any{"type": "integer"},
+		})
+	}
+
+	result := map[string]any{
+		"operationId": op.id,
+		"summary":     op.summary,
+		"tags":        []string{op.tag},
+		"responses":   g.responses(op),
+	}
+	if len(params) > 0 {
+		result["parameters"] = params
+	}
+	if op.security == securityNone {
+		result["security"] = []any{}
+	} else {
+		result["security"] = []any{map[string]any{op.security: []string{}}}
+	}
+
+	if op.request != nil {
+		contentTypes := op.contentTypes
+		if len(contentTypes) == 0 {
+			contentTypes = []string{"application/json"}
+		}
+		content := make(map[string]any, len(contentTypes))
+		for _, contentType := range contentTypes {
+			schema := g.schema(reflect.TypeOf(op.request))
+			if contentType == "multipart/form-data" {
+				schema = map[string]any{
+					"type":       "object",
+					"properties": map[string]any{"file": map[string]any{"type": "string", "format": "binary"}},
+				}
+			}
+			content[contentType] = map[string]any{"schema": schema}
+		}
+		result["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+	return result
+}
+
+// responses builds the success and error responses of an operation
+func (g *schemaGenerator) responses(op operation) map[string]any {
+	var schema map[string]any
+	switch {
+	case op.raw && op.response == nil:
+		schema = map[string]any{}
+	case op.raw:
+		schema = g.schema(reflect.TypeOf(op.response))
+	default:
+		properties := map[string]any{"success": map[string]any{"type": "boolean"}}
+		if op.response != nil {
+			properties["data"] = g.schema(reflect.TypeOf(op.response))
+		}
+		schema = map[string]any{
+			"type":       "object",
+			"required":   []string{"success"},
+			"properties": properties,
+		}
+	}
+
+	contentType := "application/json"
+	if op.rawType != "" {
+		contentType = op.rawType
+	}
+	if contentType != "application/json" {
+		schema = map[string]any{"type": "string"}
+	}
+	success := map[string]any{
+		"description": http.StatusText(op.status),
+		"content":     map[string]any{contentType: map[string]any{"schema": schema}},
+	}
+
+	responses := map[string]any{strconv.Itoa(op.status): success}
+	for _, status := range op.errors {
+		responses[strconv.Itoa(status)] = map[string]any{
+			"description": http.StatusText(status),
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(types.ErrorResponse{}))},
+			},
+		}
+	}
+	return responses
+}
+
+// operationIDs maps "METHOD /path" to the operation ID
+var operationIDs = func() map[string]string {
+	ids := make(map[string]string, len(operations))
+	for _, op := range operations {
+		ids[op.method+" "+op.path] = op.id
+	}
+	return ids
+}()
+
+// operationName names a call by its operation ID, falling back to the
+// method and route pattern for undocumented routes
+func operationName(method, pattern string) string {
+	key := method + " " + strings.TrimSuffix(pattern, "/")
+	if id, ok := operationIDs[key]; ok {
+		return id
+	}
+	return key
+}
+
+// schemaGenerator turns Go types into JSON schemas, collecting named structs
+// from pkg/types as components
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of a Go type
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Pointer:
+		if t.Elem().Kind() == reflect.Interface {
+			return map[string]any{}
+		}
+		return g.schema(t.Elem())
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "binary"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.PkgPath() != reflect.TypeOf(types.ErrorResponse{}).PkgPath() {
+			return g.object(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = map[string]any{} // placeholder for recursive types
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]any{}
+}
+
+// object returns the schema of a struct from its JSON field tags
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Interface && field.Type.Kind() != reflect.Pointer {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
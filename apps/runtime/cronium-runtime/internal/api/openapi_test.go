@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// newTestRouter builds the router without a runtime service; only the route
+// table is exercised
+func newTestRouter(t *testing.T) chi.Routes {
+	t.Helper()
+
+	cfg := &config.Config{Version: "test"}
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.Security.RateLimitPerMin = 1000
+	cfg.Server.MetricsEnabled = true
+	cfg.Server.MetricsPath = "/metrics"
+
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	limiter := middleware.NewRateLimiter(cfg.Security.RateLimitPerMin, log)
+	routes, ok := NewRouter(nil, cfg, limiter, log).(chi.Routes)
+	if !ok {
+		t.Fatal("router does not expose its routes")
+	}
+	return routes
+}
+
+// TestOpenAPIMatchesRoutes fails when a route is added without documenting
+// it, or an operation is documented that the router does not serve
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	routes := make(map[string]bool)
+	err := chi.Walk(newTestRouter(t), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+strings.TrimSuffix(route, "/")] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walking routes: %v", err)
+	}
+
+	documented := make(map[string]bool)
+	for _, op := range operations {
+		key := op.method + " " + op.path
+		if documented[key] {
+			t.Errorf("%s is documented more than once", key)
+		}
+		documented[key] = true
+	}
+
+	for _, key := range sortedKeys(routes) {
+		if !documented[key] {
+			t.Errorf("%s is served but missing from the OpenAPI operations", key)
+		}
+	}
+	for _, key := range sortedKeys(documented) {
+		if !routes[key] {
+			t.Errorf("%s is documented but not served", key)
+		}
+	}
+}
+
+// TestOpenAPIDocument checks the served document is well formed: unique
+// operation IDs and only references to defined schemas
+func TestOpenAPIDocument(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(t).(http.Handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json returned %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                               `json:"openapi"`
+		Paths      map[string]map[string]map[string]any `json:"paths"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	body := rec.Body.Bytes()
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("invalid document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi version %q, want 3.x", doc.OpenAPI)
+	}
+
+	ids := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			id, _ := op["operationId"].(string)
+			if id == "" || ids[id] {
+				t.Errorf("%s %s has a missing or duplicate operationId %q", method, path, id)
+			}
+			ids[id] = true
+		}
+	}
+
+	for _, part := range strings.Split(string(body), `"$ref":"#/components/schemas/`)[1:] {
+		name := part[:strings.Index(part, `"`)]
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("reference to undefined schema %s", name)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -17,8 +17,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-// NewRouter creates a new HTTP router
-func NewRouter(runtime *service.RuntimeService, cfg *config.Config, log *logrus.Logger) http.Handler {
+// NewRouter creates a new HTTP router. limiter is shared with the helper
+// socket so an execution gets one budget across both.
+func NewRouter(runtime *service.RuntimeService, cfg *config.Config, limiter *middleware.RateLimiter, log *logrus.Logger) http.Handler {
 	r := chi.NewRouter()
 
 	// Basic middleware
@@ -26,6 +27,9 @@ func NewRouter(runtime *service.RuntimeService, cfg *config.Config, log *logrus.
 	r.Use(chimiddleware.RealIP)
 	r.Use(chimiddleware.Recoverer)
 	r.Use(middleware.LoggingMiddleware(log))
+	if cfg.Server.MetricsEnabled {
+		r.Use(middleware.MetricsMiddleware())
+	}
 
 	// Add request time to context
 	r.Use(func(next http.Handler) http.Handler {
@@ -53,7 +57,30 @@ func NewRouter(runtime *service.RuntimeService, cfg *config.Config, log *logrus.
 	// Public routes
 	r.Group(func(r chi.Router) {
 		r.Get("/health", h.Health)
-		r.Handle("/metrics", promhttp.Handler())
+		if cfg.Server.MetricsEnabled {
+			r.Method(http.MethodGet, cfg.Server.MetricsPath, promhttp.Handler())
+		}
+		r.Get("/openapi.json", serveOpenAPI(cfg.Version))
+	})
+
+	// Result uploads from bundled-mode runners, authenticated by signed URL
+	r.Group(func(r chi.Router) {
+		jwtManager := auth.NewJWTManager(cfg.Auth)
+		r.Use(middleware.SignedURLMiddleware(jwtManager, log))
+
+		r.Post("/results/{id}", h.SubmitResults)
+	})
+
+	// Cache invalidation and credential revocation by the backend,
+	// authenticated by the service token
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.ServiceTokenMiddleware(cfg.Auth.ServiceToken, log))
+
+		r.Route("/internal/cache", func(r chi.Router) {
+			r.Delete("/executions/{id}", h.InvalidateExecutionCache)
+			r.Delete("/users/{userId}/variables/{key}", h.InvalidateVariableCache)
+		})
+		r.Delete("/internal/executions/{id}/credentials", h.RevokeCredentials)
 	})
 
 	// Protected routes
@@ -62,27 +89,56 @@ func NewRouter(runtime *service.RuntimeService, cfg *config.Config, log *logrus.
 		jwtManager := auth.NewJWTManager(cfg.Auth)
 		r.Use(middleware.AuthMiddleware(jwtManager, log))
 
+		// Helper call counts and latencies for the execution record
+		r.Use(middleware.HelperStatsMiddleware(runtime, operationName))
+
 		// Rate limiting
-		rateLimiter := middleware.NewRateLimiter(cfg.Security.RateLimitPerMin, log)
-		r.Use(middleware.RateLimitMiddleware(rateLimiter))
+		r.Use(middleware.RateLimitMiddleware(limiter))
 
 		// Execution endpoints
 		r.Route("/executions/{id}", func(r chi.Router) {
 			r.Get("/input", h.GetInput)
 			r.Post("/output", h.SetOutput)
+			r.Post("/files", h.UploadFile)
 			r.Get("/context", h.GetContext)
 			r.Post("/condition", h.SetCondition)
+			r.Post("/progress", h.ReportProgress)
 			
 			// Variables
 			r.Route("/variables", func(r chi.Router) {
 				r.Get("/{key}", h.GetVariable)
 				r.Put("/{key}", h.SetVariable)
+				r.Post("/{key}/increment", h.IncrementVariable)
+				r.Post("/{key}/append", h.AppendVariable)
+				r.Post("/{key}/add-to-set", h.AddToSetVariable)
+			})
+
+			// Scratch values, kept for the execution only
+			r.Route("/scratch", func(r chi.Router) {
+				r.Get("/{key}", h.GetScratch)
+				r.Put("/{key}", h.SetScratch)
 			})
+
+			// Short-lived credentials from the configured providers
+			r.Post("/credentials", h.GetCredential)
+
+			// Child jobs
+			r.Post("/jobs", h.SubmitJob)
+			r.Get("/jobs/{jobId}", h.GetJob)
 		})
 
 		// Tool actions
 		r.Post("/tool-actions/execute", h.ExecuteToolAction)
 	})
 
+	// Push message channel. It stays open for the whole execution, so it is
+	// kept out of the helper call stats and the rate limit.
+	r.Group(func(r chi.Router) {
+		jwtManager := auth.NewJWTManager(cfg.Auth)
+		r.Use(middleware.AuthMiddleware(jwtManager, log))
+
+		r.Get("/executions/{id}/messages", h.Messages)
+	})
+
 	return r
 }
\ No newline at end of file
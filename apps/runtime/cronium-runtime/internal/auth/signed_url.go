@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// resultUploadKeyInfo is the HKDF purpose label of the result upload key.
+// The orchestrator derives the key with the same label.
+const resultUploadKeyInfo = "cronium result upload url v1"
+
+// resultUploadKey derives the key that signs result upload URLs from the
+// shared secret, so the HMAC key is never the one that signs JWTs
+func resultUploadKey(secret string) []byte {
+	key := make([]byte, sha256.Size)
+	io.ReadFull(hkdf.New(sha256.New, []byte(secret), nil, []byte(resultUploadKeyInfo)), key)
+	return key
+}
+
+// ResultUploadSignature computes the signature of a result upload URL. The
+// orchestrator signs URLs with the same derived key and message format.
+func ResultUploadSignature(secret, executionID string, expires int64) string {
+	mac := hmac.New(sha256.New, resultUploadKey(secret))
+	mac.Write([]byte("results:" + executionID + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyResultUpload checks the expiry and signature of a result upload URL
+// and returns its expiry time
+func (m *JWTManager) VerifyResultUpload(executionID, expires, signature string) (time.Time, error) {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry")
+	}
+
+	expected := ResultUploadSignature(string(m.secret), executionID, unix)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return time.Time{}, fmt.Errorf("invalid signature")
+	}
+
+	expiresAt := time.Unix(unix, 0)
+	if time.Now().After(expiresAt) {
+		return time.Time{}, fmt.Errorf("upload URL expired")
+	}
+
+	return expiresAt, nil
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+)
+
+func TestResultUploadSignature(t *testing.T) {
+	// The orchestrator's tests sign the same URL
+	want := "44bffe11fd4e69777ea76b0bd156bcc85503af8abfaf0016707a4e0e63afc856"
+	if got := ResultUploadSignature("secret", "exec_1", 1760000000); got != want {
+		t.Errorf("ResultUploadSignature() = %s, want %s", got, want)
+	}
+
+	// The JWT secret itself does not sign upload URLs
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("results:exec_1:1760000000"))
+	if hex.EncodeToString(mac.Sum(nil)) == want {
+		t.Error("result upload URLs are signed with the JWT secret")
+	}
+}
+
+func TestVerifyResultUpload(t *testing.T) {
+	m := NewJWTManager(config.AuthConfig{JWTSecret: "secret"})
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	sig := ResultUploadSignature("secret", "exec_1", expiresAt.Unix())
+
+	got, err := m.VerifyResultUpload("exec_1", expires, sig)
+	if err != nil {
+		t.Fatalf("VerifyResultUpload() error = %v", err)
+	}
+	if !got.Equal(expiresAt) {
+		t.Errorf("VerifyResultUpload() expiry = %v, want %v", got, expiresAt)
+	}
+
+	past := time.Now().Add(-time.Minute).Unix()
+	tampered := []byte(sig)
+	tampered[0] ^= 1
+	later := strconv.FormatInt(expiresAt.Add(time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name        string
+		executionID string
+		expires     string
+		sig         string
+	}{
+		{"expired", "exec_1", strconv.FormatInt(past, 10), ResultUploadSignature("secret", "exec_1", past)},
+		{"other execution", "exec_2", expires, sig},
+		{"tampered signature", "exec_1", expires, string(tampered)},
+		{"extended expiry", "exec_1", later, sig},
+		{"other secret", "exec_1", expires, ResultUploadSignature("other", "exec_1", expiresAt.Unix())},
+		{"invalid expiry", "exec_1", "soon", sig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.VerifyResultUpload(tt.executionID, tt.expires, tt.sig); err == nil {
+				t.Error("VerifyResultUpload() accepted the URL")
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNoSeed is returned by an atomic operation on a variable that is not in
+// the cache yet; the caller retries with the variable's stored value as seed
+var ErrNoSeed = errors.New("atomic variable not cached")
+
+// ErrWrongType is returned when an atomic operation does not match the
+// variable's current type, such as incrementing a list
+var ErrWrongType = errors.New("variable has the wrong type for this operation")
+
+// Atomic variables are shared by all of a user's executions, so unlike the
+// per-execution variable cache they are keyed by user. Each script seeds the
+// key when it is missing and applies the operation in one step.
+//
+// KEYS[2] is the operation's marker. A script that finds it has already run
+// for this call, whose reply was lost, and returns its result without
+// applying the operation twice.
+var (
+	incrementScript = redis.NewScript(`
+local done = redis.call('GET', KEYS[2])
+if done then return done end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  if ARGV[3] ~= '1' then return redis.error_reply('NOSEED') end
+  redis.call('SET', KEYS[1], ARGV[4])
+end
+local value = redis.call('INCRBYFLOAT', KEYS[1], ARGV[2])
+if tonumber(ARGV[1]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+redis.call('SET', KEYS[2], value, 'EX', 60)
+return value
+`)
+
+	appendScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[2]) == 1 then return redis.call('LRANGE', KEYS[1], 0, -1) end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  if ARGV[3] ~= '1' then return redis.error_reply('NOSEED') end
+  for i = 4, #ARGV do redis.call('RPUSH', KEYS[1], ARGV[i]) end
+end
+redis.call('RPUSH', KEYS[1], ARGV[2])
+if tonumber(ARGV[1]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+redis.call('SET', KEYS[2], 1, 'EX', 60)
+return redis.call('LRANGE', KEYS[1], 0, -1)
+`)
+
+	addToSetScript = redis.NewScript(`
+local done = redis.call('GET', KEYS[2])
+if done then return {tonumber(done), redis.call('SMEMBERS', KEYS[1])} end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+  if ARGV[3] ~= '1' then return redis.error_reply('NOSEED') end
+  for i = 4, #ARGV do redis.call('SADD', KEYS[1], ARGV[i]) end
+end
+local added = redis.call('SADD', KEYS[1], ARGV[2])
+if tonumber(ARGV[1]) > 0 then redis.call('PEXPIRE', KEYS[1], ARGV[1]) end
+redis.call('SET', KEYS[2], added, 'EX', 60)
+return {added, redis.call('SMEMBERS', KEYS[1])}
+`)
+)
+
+// atomicKey is the cache key of a user's atomic variable
+func atomicKey(userID, key string) string {
+	return fmt.Sprintf("atomic:%s:%s", userID, key)
+}
+
+// atomicKeys are the keys of an atomic operation: the variable and a new
+// marker for the operation, which outlives any retry of it
+func atomicKeys(userID, key string) []string {
+	return []string{atomicKey(userID, key), "atomic-op:" + newOperationID()}
+}
+
+// IncrementVariable adds by to a numeric variable and returns the new value.
+// seed is the stored value used when the variable is not cached; nil means
+// the caller has not loaded it yet.
+func (c *ValkeyClient) IncrementVariable(ctx context.Context, userID, key string, by float64, seed *float64) (float64, error) {
+	var initial []string
+	if seed != nil {
+		initial = []string{strconv.FormatFloat(*seed, 'f', -1, 64)}
+	}
+	args := seedArgs(c.ttl, strconv.FormatFloat(by, 'f', -1, 64), initial)
+
+	keys := atomicKeys(userID, key)
+	var result string
+	err := c.do(ctx, "increment_variable", func() (err error) {
+		result, err = incrementScript.Run(ctx, c.client, keys, args...).Text()
+		return err
+	})
+	if err != nil {
+		return 0, atomicError(err)
+	}
+	value, err := strconv.ParseFloat(result, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse counter: %w", err)
+	}
+	return value, nil
+}
+
+// AppendVariable appends an encoded item to a list variable and returns the
+// encoded items. seed is used as with IncrementVariable.
+func (c *ValkeyClient) AppendVariable(ctx context.Context, userID, key, item string, seed []string) ([]string, error) {
+	args := seedArgs(c.ttl, item, seed)
+	keys := atomicKeys(userID, key)
+	var items []string
+	err := c.do(ctx, "append_variable", func() (err error) {
+		items, err = appendScript.Run(ctx, c.client, keys, args...).StringSlice()
+		return err
+	})
+	if err != nil {
+		return nil, atomicError(err)
+	}
+	return items, nil
+}
+
+// AddToSetVariable adds an encoded member to a set variable. It reports
+// whether the member was new and returns the encoded members in sorted order.
+func (c *ValkeyClient) AddToSetVariable(ctx context.Context, userID, key, member string, seed []string) ([]string, bool, error) {
+	args := seedArgs(c.ttl, member, seed)
+	keys := atomicKeys(userID, key)
+	var result []interface{}
+	err := c.do(ctx, "add_to_set_variable", func() (err error) {
+		result, err = addToSetScript.Run(ctx, c.client, keys, args...).Slice()
+		return err
+	})
+	if err != nil {
+		return nil, false, atomicError(err)
+	}
+	if len(result) != 2 {
+		return nil, false, fmt.Errorf("unexpected set result")
+	}
+
+	added, _ := result[0].(int64)
+	raw, _ := result[1].([]interface{})
+	members := make([]string, 0, len(raw))
+	for _, m := range raw {
+		if s, ok := m.(string); ok {
+			members = append(members, s)
+		}
+	}
+	sort.Strings(members)
+	return members, added == 1, nil
+}
+
+// AtomicVariable reads an atomic variable as stored: a float64 counter, or the
+// encoded items of a list or set. ok is false when it is not cached.
+func (c *ValkeyClient) AtomicVariable(ctx context.Context, userID, key string) (value interface{}, ok bool, err error) {
+	cacheKey := atomicKey(userID, key)
+
+	var kind string
+	err = c.do(ctx, "get_atomic_variable", func() (err error) {
+		kind, err = c.client.Type(ctx, cacheKey).Result()
+		return err
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read atomic variable: %w", err)
+	}
+
+	var read func() (interface{}, error)
+	switch kind {
+	case "none":
+		return nil, false, nil
+	case "string":
+		read = func() (interface{}, error) { return c.client.Get(ctx, cacheKey).Float64() }
+	case "list":
+		read = func() (interface{}, error) { return c.client.LRange(ctx, cacheKey, 0, -1).Result() }
+	case "set":
+		read = func() (interface{}, error) {
+			members, err := c.client.SMembers(ctx, cacheKey).Result()
+			sort.Strings(members)
+			return members, err
+		}
+	default:
+		return nil, false, fmt.Errorf("unexpected atomic variable type %s", kind)
+	}
+	err = c.do(ctx, "get_atomic_variable", func() (err error) {
+		value, err = read()
+		return err
+	})
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read atomic variable: %w", err)
+	}
+	return value, true, nil
+}
+
+// DeleteAtomicVariable drops a cached atomic variable so that the next atomic
+// operation reseeds it from the stored value
+func (c *ValkeyClient) DeleteAtomicVariable(ctx context.Context, userID, key string) error {
+	err := c.do(ctx, "delete_atomic_variable", func() error {
+		return c.client.Del(ctx, atomicKey(userID, key)).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete atomic variable: %w", err)
+	}
+	return nil
+}
+
+// seedArgs builds the script arguments: TTL, operand, seeded flag and seed
+func seedArgs(ttl time.Duration, operand string, seed []string) []interface{} {
+	args := []interface{}{ttl.Milliseconds(), operand, "0"}
+	if seed != nil {
+		args[2] = "1"
+		for _, s := range seed {
+			args = append(args, s)
+		}
+	}
+	return args
+}
+
+// atomicError maps script errors to ErrNoSeed and ErrWrongType
+func atomicError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "NOSEED"):
+		return ErrNoSeed
+	case strings.Contains(msg, "WRONGTYPE"), strings.Contains(msg, "not a valid float"):
+		return ErrWrongType
+	}
+	return fmt.Errorf("atomic variable operation failed: %w", err)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// setDataKeyScript stores a data key unless one is stored, and returns the
+// stored key either way, so that a retry returns the key it stored
+var setDataKeyScript = redis.NewScript(`
+redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2])
+return redis.call('GET', KEYS[1])
+`)
+
+// dataKeyKey is the cache key of an execution's wrapped data key
+func dataKeyKey(executionID string) string {
+	return types.CacheKey{Type: "datakey", ExecutionID: executionID}.String()
+}
+
+// dataKeyTTL keeps a data key as long as anything sealed with it may be
+// cached; every use renews it
+func (c *ValkeyClient) dataKeyTTL() time.Duration {
+	return max(c.ttlFor("variable"), c.ttlFor("context"))
+}
+
+// GetDataKey returns an execution's wrapped data key, or nil when it has none
+func (c *ValkeyClient) GetDataKey(ctx context.Context, executionID string) ([]byte, error) {
+	var wrapped []byte
+	err := c.do(ctx, "get_data_key", func() (err error) {
+		wrapped, err = c.client.GetEx(ctx, dataKeyKey(executionID), c.dataKeyTTL()).Bytes()
+		return err
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get data key: %w", err)
+	}
+	return wrapped, nil
+}
+
+// SetDataKey stores an execution's wrapped data key unless it has one
+// already, and returns the one stored
+func (c *ValkeyClient) SetDataKey(ctx context.Context, executionID string, wrapped []byte) ([]byte, error) {
+	var stored string
+	err := c.do(ctx, "set_data_key", func() (err error) {
+		stored, err = setDataKeyScript.Run(ctx, c.client, []string{dataKeyKey(executionID)}, wrapped, c.dataKeyTTL().Milliseconds()).Text()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to set data key: %w", err)
+	}
+	return []byte(stored), nil
+}
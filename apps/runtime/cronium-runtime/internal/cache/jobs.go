@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// ErrChildJobsFull is returned when an execution has submitted as many
+	// child jobs as it may
+	ErrChildJobsFull = errors.New("child job limit reached")
+
+	// ErrChildJobPending is returned for a submission whose job is still
+	// being created by an earlier request with the same key
+	ErrChildJobPending = errors.New("child job submission in progress")
+)
+
+// An execution's child jobs are one hash from submission key to job ID.
+// Reserving a key returns the job already created for it, so a repeated
+// submission is answered with the same job; a new key is checked against the
+// limit in the same step. A reserved key holds "pending:<operation id>"
+// until its job exists, which lets a retried reservation recognise its own.
+var reserveChildJobScript = redis.NewScript(`
+local existing = redis.call('HGET', KEYS[1], ARGV[1])
+if existing then
+  if existing == 'pending:' .. ARGV[4] then
+    return ''
+  end
+  if string.sub(existing, 1, 8) == 'pending:' then
+    return redis.error_reply('PENDING')
+  end
+  return existing
+end
+if redis.call('HLEN', KEYS[1]) >= tonumber(ARGV[2]) then
+  return redis.error_reply('FULL')
+end
+redis.call('HSET', KEYS[1], ARGV[1], 'pending:' .. ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return ''
+`)
+
+// childJobsKey is the cache key of an execution's child jobs
+func childJobsKey(executionID string) string {
+	return types.CacheKey{Type: "jobs", ExecutionID: executionID}.String()
+}
+
+// ReserveChildJob reserves a submission key for a new child job of an
+// execution and returns "". When the key was used before it returns the ID
+// of the job created for it instead. At most maxJobs keys are kept for ttl
+// after the last reservation.
+func (c *ValkeyClient) ReserveChildJob(ctx context.Context, executionID, submissionKey string, maxJobs int, ttl time.Duration) (string, error) {
+	var jobID string
+	operationID := newOperationID()
+	err := c.do(ctx, "reserve_child_job", func() (err error) {
+		jobID, err = reserveChildJobScript.Run(ctx, c.client, []string{childJobsKey(executionID)}, submissionKey, maxJobs, ttl.Milliseconds(), operationID).Text()
+		return err
+	})
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "FULL"):
+			return "", ErrChildJobsFull
+		case strings.Contains(err.Error(), "PENDING"):
+			return "", ErrChildJobPending
+		}
+		return "", fmt.Errorf("failed to reserve child job: %w", err)
+	}
+	return jobID, nil
+}
+
+// SetChildJob records the job created for a reserved submission key
+func (c *ValkeyClient) SetChildJob(ctx context.Context, executionID, submissionKey, jobID string) error {
+	err := c.do(ctx, "set_child_job", func() error {
+		return c.client.HSet(ctx, childJobsKey(executionID), submissionKey, jobID).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set child job: %w", err)
+	}
+	return nil
+}
+
+// ReleaseChildJob frees a reserved submission key whose job was not created
+func (c *ValkeyClient) ReleaseChildJob(ctx context.Context, executionID, submissionKey string) error {
+	err := c.do(ctx, "release_child_job", func() error {
+		return c.client.HDel(ctx, childJobsKey(executionID), submissionKey).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release child job: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// addLeaseScript adds a lease to an execution's hash, which is kept until
+// its last lease expires
+var addLeaseScript = redis.NewScript(`
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+if redis.call('PEXPIRETIME', KEYS[1]) < tonumber(ARGV[3]) then
+  redis.call('PEXPIREAT', KEYS[1], ARGV[3])
+end
+return 1
+`)
+
+// leasesKey is the cache key of an execution's credential leases
+func leasesKey(executionID string) string {
+	return types.CacheKey{Type: "credentials", ExecutionID: executionID}.String()
+}
+
+// AddLease records a revocable credential lease of an execution
+func (c *ValkeyClient) AddLease(ctx context.Context, executionID, leaseKey string, lease []byte, expiresAt time.Time) error {
+	err := c.do(ctx, "add_lease", func() error {
+		return addLeaseScript.Run(ctx, c.client, []string{leasesKey(executionID)}, leaseKey, lease, expiresAt.UnixMilli()).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add lease: %w", err)
+	}
+	return nil
+}
+
+// Leases returns an execution's credential leases by lease key
+func (c *ValkeyClient) Leases(ctx context.Context, executionID string) (map[string]string, error) {
+	var leases map[string]string
+	err := c.do(ctx, "get_leases", func() (err error) {
+		leases, err = c.client.HGetAll(ctx, leasesKey(executionID)).Result()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leases: %w", err)
+	}
+	return leases, nil
+}
+
+// DeleteLease forgets a credential lease
+func (c *ValkeyClient) DeleteLease(ctx context.Context, executionID, leaseKey string) error {
+	err := c.do(ctx, "delete_lease", func() error {
+		return c.client.HDel(ctx, leasesKey(executionID), leaseKey).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete lease: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// Push messages travel over Valkey pub/sub, so the orchestrator and other
+// runtime instances can reach scripts without knowing which sidecar runs
+// them. Messages are not stored: a script only hears what is published while
+// it is subscribed.
+
+// JobMessageChannel carries the messages for one job
+func JobMessageChannel(jobID string) string {
+	return "messages:job:" + jobID
+}
+
+// UserMessageChannel carries the variable changes of one user
+func UserMessageChannel(userID string) string {
+	return "messages:user:" + userID
+}
+
+// PublishMessage publishes a push message and returns how many subscribers
+// received it
+func (c *ValkeyClient) PublishMessage(ctx context.Context, channel string, msg types.PushMessage) (int64, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+
+	var receivers int64
+	err = c.do(ctx, "publish_message", func() (err error) {
+		receivers, err = c.client.Publish(ctx, channel, data).Result()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to publish message: %w", err)
+	}
+	return receivers, nil
+}
+
+// SubscribeMessages subscribes to push message channels. Messages arrive on
+// the returned channel until ctx is done or close is called; malformed ones
+// are dropped.
+func (c *ValkeyClient) SubscribeMessages(ctx context.Context, channels ...string) (<-chan types.PushMessage, func() error, error) {
+	pubsub := c.client.Subscribe(ctx, channels...)
+	// Wait for the confirmation so nothing published after this returns is
+	// missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, nil, fmt.Errorf("failed to subscribe to messages: %w", err)
+	}
+
+	out := make(chan types.PushMessage)
+	go func() {
+		defer close(out)
+		for raw := range pubsub.Channel() {
+			var msg types.PushMessage
+			if err := json.Unmarshal([]byte(raw.Payload), &msg); err != nil {
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, pubsub.Close, nil
+}
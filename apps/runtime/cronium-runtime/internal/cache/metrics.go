@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	cacheRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_cache_retries_total",
+			Help: "Total number of Valkey calls retried after a transient failure",
+		},
+		[]string{"operation"},
+	)
+
+	cacheErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_cache_errors_total",
+			Help: "Total number of Valkey calls that failed, after any retries",
+		},
+		[]string{"operation", "class"},
+	)
+
+	cacheFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_cache_fallbacks_total",
+			Help: "Total number of requests served from the backend alone because Valkey failed",
+		},
+		[]string{"operation"},
+	)
+
+	cacheLookups = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_cache_lookups_total",
+			Help: "Total number of Valkey reads by object type and result (hit or miss)",
+		},
+		[]string{"kind", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(cacheRetries, cacheErrors, cacheFallbacks, cacheLookups)
+}
+
+// recordLookup counts a cache read as a hit or a miss. Failed reads are
+// counted in cacheErrors only.
+func recordLookup(kind string, err error) {
+	switch err {
+	case nil:
+		cacheLookups.WithLabelValues(kind, "hit").Inc()
+	case redis.Nil:
+		cacheLookups.WithLabelValues(kind, "miss").Inc()
+	}
+}
+
+// RecordFallback counts a request that went on without the cache after a
+// cache call failed, reading from or writing to the backend only
+func RecordFallback(operation string) {
+	cacheFallbacks.WithLabelValues(operation).Inc()
+}
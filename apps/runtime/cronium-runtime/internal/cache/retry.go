@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Error classes reported in the cache error metrics
+const (
+	classTransient = "transient"
+	classFatal     = "fatal"
+)
+
+// transientReplies are server replies to a command the server did not run
+// because it is starting up, failing over or out of connections
+var transientReplies = []string{"LOADING ", "READONLY ", "MASTERDOWN ", "CLUSTERDOWN ", "TRYAGAIN ", "ERR max number of clients reached"}
+
+// retryPolicy bounds the retries of a Valkey call. The client's own retries
+// are turned off so that every call goes through one policy.
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// delay returns the wait before a retry: exponential in the attempt, capped
+// at maxBackoff, with half of it jittered so that clients that failed
+// together do not retry together
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff << attempt
+	if d <= 0 || d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// errorClass classifies the error of a Valkey call. Replies such as
+// redis.Nil or a script error are outcomes of the call rather than failures
+// and have no class.
+func errorClass(err error) string {
+	if err == nil {
+		return ""
+	}
+	// A context deadline is also a net.Error, so it is checked first
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return classFatal
+	}
+
+	var reply redis.Error
+	if errors.As(err, &reply) {
+		for _, prefix := range transientReplies {
+			if strings.HasPrefix(err.Error(), prefix) {
+				return classTransient
+			}
+		}
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, redis.ErrPoolTimeout) {
+		return classTransient
+	}
+	return classFatal
+}
+
+// do runs a Valkey call, retrying it while it fails transiently. A network
+// failure can leave it unknown whether the server ran a command, so every
+// call made through do must be safe to repeat: plain reads and overwrites
+// are, and the atomic scripts and locks carry an operation ID for it.
+func (c *ValkeyClient) do(ctx context.Context, operation string, call func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := call()
+		class := errorClass(err)
+		if class == "" {
+			return err
+		}
+		if class == classFatal || attempt >= c.retry.maxRetries {
+			cacheErrors.WithLabelValues(operation, class).Inc()
+			return err
+		}
+
+		cacheRetries.WithLabelValues(operation).Inc()
+		timer := time.NewTimer(c.retry.delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			cacheErrors.WithLabelValues(operation, class).Inc()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// newOperationID returns an ID that marks one logical call across retries
+func newOperationID() string {
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		return time.Now().Format(time.RFC3339Nano)
+	}
+	return hex.EncodeToString(b)
+}
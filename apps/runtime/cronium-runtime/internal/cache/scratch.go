@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrScratchFull is returned when a new scratch key would exceed the
+// execution's key limit
+var ErrScratchFull = errors.New("scratch store is full")
+
+// An execution's scratch values are one hash, so they expire together and a
+// new key can be checked against the limit in the same step as it is set
+var setScratchScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 and redis.call('HLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+  return redis.error_reply('FULL')
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return 1
+`)
+
+// scratchKey is the cache key of an execution's scratch hash
+func scratchKey(executionID string) string {
+	return types.CacheKey{Type: "scratch", ExecutionID: executionID}.String()
+}
+
+// GetScratch reads an encoded scratch value. ok is false when it is not set.
+func (c *ValkeyClient) GetScratch(ctx context.Context, executionID, key string) (value string, ok bool, err error) {
+	err = c.do(ctx, "get_scratch", func() (err error) {
+		value, err = c.client.HGet(ctx, scratchKey(executionID), key).Result()
+		return err
+	})
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get scratch value: %w", err)
+	}
+	return value, true, nil
+}
+
+// SetScratch stores an encoded scratch value. The execution's scratch values
+// expire ttl after the last write; at most maxKeys of them are kept.
+func (c *ValkeyClient) SetScratch(ctx context.Context, executionID, key, value string, ttl time.Duration, maxKeys int) error {
+	err := c.do(ctx, "set_scratch", func() error {
+		return setScratchScript.Run(ctx, c.client, []string{scratchKey(executionID)}, key, value, maxKeys, ttl.Milliseconds()).Err()
+	})
+	if err != nil && strings.Contains(err.Error(), "FULL") {
+		return ErrScratchFull
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set scratch value: %w", err)
+	}
+	return nil
+}
+
+// DeleteScratch removes a scratch value
+func (c *ValkeyClient) DeleteScratch(ctx context.Context, executionID, key string) error {
+	err := c.do(ctx, "delete_scratch", func() error {
+		return c.client.HDel(ctx, scratchKey(executionID), key).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete scratch value: %w", err)
+	}
+	return nil
+}
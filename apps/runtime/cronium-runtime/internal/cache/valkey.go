@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/runtime/internal/config"
@@ -11,10 +12,21 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// lockScript takes a lock, or reports it taken when it already holds the
+// caller's token
+var lockScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then return 1 end
+if redis.call('GET', KEYS[1]) == ARGV[1] then return 1 end
+return 0
+`)
+
 // ValkeyClient wraps the Redis client for Valkey compatibility
 type ValkeyClient struct {
-	client *redis.Client
-	ttl    time.Duration
+	client  *redis.Client
+	ttl     time.Duration
+	ttls    map[string]time.Duration
+	sliding bool
+	retry   retryPolicy
 }
 
 // NewValkeyClient creates a new Valkey client
@@ -33,7 +45,8 @@ func NewValkeyClient(cfg config.CacheConfig) (*ValkeyClient, error) {
 	// Apply additional configuration
 	opt.Password = cfg.Password
 	opt.DB = cfg.DB
-	opt.MaxRetries = cfg.MaxRetries
+	// Retries are made by do, which knows which failures are worth retrying
+	opt.MaxRetries = -1
 	opt.DialTimeout = cfg.DialTimeout
 	opt.ReadTimeout = cfg.ReadTimeout
 	opt.WriteTimeout = cfg.WriteTimeout
@@ -54,6 +67,19 @@ func NewValkeyClient(cfg config.CacheConfig) (*ValkeyClient, error) {
 	return &ValkeyClient{
 		client: client,
 		ttl:    cfg.TTL,
+		ttls: map[string]time.Duration{
+			"context":  cfg.ContextTTL,
+			"input":    cfg.InputTTL,
+			"output":   cfg.OutputTTL,
+			"variable": cfg.VariableTTL,
+			"blob":     cfg.BlobTTL,
+		},
+		sliding: cfg.SlidingExpiration,
+		retry: retryPolicy{
+			maxRetries: cfg.MaxRetries,
+			backoff:    cfg.RetryBackoff,
+			maxBackoff: cfg.RetryMaxBackoff,
+		},
 	}, nil
 }
 
@@ -62,6 +88,38 @@ func (c *ValkeyClient) Close() error {
 	return c.client.Close()
 }
 
+// ttlFor returns the TTL of a cached object type; types without their own
+// TTL use the default one
+func (c *ValkeyClient) ttlFor(kind string) time.Duration {
+	if ttl := c.ttls[kind]; ttl > 0 {
+		return ttl
+	}
+	return c.ttl
+}
+
+// get reads a cached object. With sliding expiration the read also renews
+// the object's TTL, so objects in use stay cached while idle ones expire.
+func (c *ValkeyClient) get(ctx context.Context, kind, key string) (string, error) {
+	var data string
+	err := c.do(ctx, "get_"+kind, func() (err error) {
+		if c.sliding {
+			data, err = c.client.GetEx(ctx, key, c.ttlFor(kind)).Result()
+		} else {
+			data, err = c.client.Get(ctx, key).Result()
+		}
+		return err
+	})
+	recordLookup(kind, err)
+	return data, err
+}
+
+// set stores a cached object with its type's TTL
+func (c *ValkeyClient) set(ctx context.Context, kind, key string, data []byte) error {
+	return c.do(ctx, "set_"+kind, func() error {
+		return c.client.Set(ctx, key, data, c.ttlFor(kind)).Err()
+	})
+}
+
 // GetVariable retrieves a variable from cache
 func (c *ValkeyClient) GetVariable(ctx context.Context, executionID, key string) (*types.Variable, error) {
 	cacheKey := types.CacheKey{
@@ -70,7 +128,7 @@ func (c *ValkeyClient) GetVariable(ctx context.Context, executionID, key string)
 		Key:         key,
 	}
 
-	data, err := c.client.Get(ctx, cacheKey.String()).Result()
+	data, err := c.get(ctx, "variable", cacheKey.String())
 	if err == redis.Nil {
 		return nil, nil // Not found
 	}
@@ -99,7 +157,8 @@ func (c *ValkeyClient) SetVariable(ctx context.Context, executionID, key string,
 		return fmt.Errorf("failed to marshal variable: %w", err)
 	}
 
-	if err := c.client.Set(ctx, cacheKey.String(), data, c.ttl).Err(); err != nil {
+	err = c.set(ctx, "variable", cacheKey.String(), data)
+	if err != nil {
 		return fmt.Errorf("failed to set variable in cache: %w", err)
 	}
 
@@ -113,7 +172,7 @@ func (c *ValkeyClient) GetInput(ctx context.Context, executionID string) (*types
 		ExecutionID: executionID,
 	}
 
-	data, err := c.client.Get(ctx, cacheKey.String()).Result()
+	data, err := c.get(ctx, "input", cacheKey.String())
 	if err == redis.Nil {
 		return nil, nil // Not found
 	}
@@ -141,7 +200,8 @@ func (c *ValkeyClient) SetInput(ctx context.Context, executionID string, input *
 		return fmt.Errorf("failed to marshal input: %w", err)
 	}
 
-	if err := c.client.Set(ctx, cacheKey.String(), data, c.ttl).Err(); err != nil {
+	err = c.set(ctx, "input", cacheKey.String(), data)
+	if err != nil {
 		return fmt.Errorf("failed to set input in cache: %w", err)
 	}
 
@@ -155,7 +215,7 @@ func (c *ValkeyClient) GetOutput(ctx context.Context, executionID string) (*type
 		ExecutionID: executionID,
 	}
 
-	data, err := c.client.Get(ctx, cacheKey.String()).Result()
+	data, err := c.get(ctx, "output", cacheKey.String())
 	if err == redis.Nil {
 		return nil, nil // Not found
 	}
@@ -183,7 +243,8 @@ func (c *ValkeyClient) SetOutput(ctx context.Context, executionID string, output
 		return fmt.Errorf("failed to marshal output: %w", err)
 	}
 
-	if err := c.client.Set(ctx, cacheKey.String(), data, c.ttl).Err(); err != nil {
+	err = c.set(ctx, "output", cacheKey.String(), data)
+	if err != nil {
 		return fmt.Errorf("failed to set output in cache: %w", err)
 	}
 
@@ -197,7 +258,7 @@ func (c *ValkeyClient) GetContext(ctx context.Context, executionID string) (*typ
 		ExecutionID: executionID,
 	}
 
-	data, err := c.client.Get(ctx, cacheKey.String()).Result()
+	data, err := c.get(ctx, "context", cacheKey.String())
 	if err == redis.Nil {
 		return nil, nil // Not found
 	}
@@ -225,46 +286,160 @@ func (c *ValkeyClient) SetContext(ctx context.Context, executionID string, execC
 		return fmt.Errorf("failed to marshal context: %w", err)
 	}
 
-	if err := c.client.Set(ctx, cacheKey.String(), data, c.ttl).Err(); err != nil {
+	err = c.set(ctx, "context", cacheKey.String(), data)
+	if err != nil {
 		return fmt.Errorf("failed to set context in cache: %w", err)
 	}
 
 	return nil
 }
 
-// InvalidateExecution removes all cached data for an execution
-func (c *ValkeyClient) InvalidateExecution(ctx context.Context, executionID string) error {
-	// Use pattern matching to delete all keys for this execution
-	pattern := "*:" + executionID + "*"
-	
+// GetBlob retrieves raw bytes stored under a storage key
+func (c *ValkeyClient) GetBlob(ctx context.Context, key string) ([]byte, error) {
+	cacheKey := types.CacheKey{
+		Type: "blob",
+		Key:  key,
+	}
+
+	var data []byte
+	err := c.do(ctx, "get_blob", func() (err error) {
+		data, err = c.client.Get(ctx, cacheKey.String()).Bytes()
+		return err
+	})
+	recordLookup("blob", err)
+	if err == redis.Nil {
+		return nil, nil // Not found
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob from cache: %w", err)
+	}
+
+	return data, nil
+}
+
+// SetBlob stores raw bytes under a storage key
+func (c *ValkeyClient) SetBlob(ctx context.Context, key string, data []byte) error {
+	cacheKey := types.CacheKey{
+		Type: "blob",
+		Key:  key,
+	}
+
+	err := c.do(ctx, "set_blob", func() error {
+		return c.client.Set(ctx, cacheKey.String(), data, c.ttlFor("blob")).Err()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set blob in cache: %w", err)
+	}
+
+	return nil
+}
+
+// CachedTypes are the cached copies of backend data that can be
+// invalidated; scratch values and atomic variables are left alone since the
+// cache holds their only current copy
+var CachedTypes = []string{"context", "input", "output", "variable"}
+
+// InvalidateExecution removes an execution's cached objects of the given
+// types, or of all CachedTypes when none are given, so that the next read
+// loads them from the backend. It returns the number of keys removed.
+func (c *ValkeyClient) InvalidateExecution(ctx context.Context, executionID string, kinds ...string) (int, error) {
+	if len(kinds) == 0 {
+		kinds = CachedTypes
+	}
+
+	removed := 0
+	for _, kind := range kinds {
+		prefix := types.CacheKey{Type: kind, ExecutionID: escapePattern(executionID)}.String()
+		for _, pattern := range []string{prefix, prefix + ":*"} {
+			n, err := c.deleteMatching(ctx, pattern)
+			removed += n
+			if err != nil {
+				return removed, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// InvalidateVariable removes every cached copy of a user's variable: its
+// atomic state and the copies cached by executions. Executions of other
+// users with a variable of the same name lose their copy too, which only
+// costs them a read from the backend.
+func (c *ValkeyClient) InvalidateVariable(ctx context.Context, userID, key string) (int, error) {
+	var removed int64
+	err := c.do(ctx, "invalidate", func() (err error) {
+		removed, err = c.client.Del(ctx, atomicKey(userID, key)).Result()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete atomic variable: %w", err)
+	}
+
+	n, err := c.deleteMatching(ctx, "variable:*:"+escapePattern(key))
+	return int(removed) + n, err
+}
+
+// deleteMatching deletes the keys matching a pattern and returns how many
+// it deleted
+func (c *ValkeyClient) deleteMatching(ctx context.Context, pattern string) (int, error) {
+	removed := 0
 	var cursor uint64
 	for {
-		keys, nextCursor, err := c.client.Scan(ctx, cursor, pattern, 100).Result()
+		var keys []string
+		var nextCursor uint64
+		err := c.do(ctx, "invalidate", func() (err error) {
+			keys, nextCursor, err = c.client.Scan(ctx, cursor, pattern, 100).Result()
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("failed to scan keys: %w", err)
+			return removed, fmt.Errorf("failed to scan keys: %w", err)
 		}
 
 		if len(keys) > 0 {
-			if err := c.client.Del(ctx, keys...).Err(); err != nil {
-				return fmt.Errorf("failed to delete keys: %w", err)
+			var n int64
+			err := c.do(ctx, "invalidate", func() (err error) {
+				n, err = c.client.Del(ctx, keys...).Result()
+				return err
+			})
+			if err != nil {
+				return removed, fmt.Errorf("failed to delete keys: %w", err)
 			}
+			removed += int(n)
 		}
 
 		cursor = nextCursor
 		if cursor == 0 {
-			break
+			return removed, nil
 		}
 	}
+}
 
-	return nil
+// escapePattern escapes the glob characters of a key part so that it only
+// matches itself in a SCAN pattern
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 // Lock acquires a distributed lock for the given key
 func (c *ValkeyClient) Lock(ctx context.Context, key string, ttl time.Duration) (bool, error) {
 	lockKey := "lock:" + key
 	
-	// Try to set the lock with NX (only if not exists)
-	ok, err := c.client.SetNX(ctx, lockKey, "1", ttl).Result()
+	// The token lets a retried attempt recognise a lock its first attempt
+	// took before the reply was lost
+	token := newOperationID()
+	var ok bool
+	err := c.do(ctx, "lock", func() (err error) {
+		ok, err = lockScript.Run(ctx, c.client, []string{lockKey}, token, ttl.Milliseconds()).Bool()
+		return err
+	})
 	if err != nil {
 		return false, fmt.Errorf("failed to acquire lock: %w", err)
 	}
@@ -276,7 +451,10 @@ func (c *ValkeyClient) Lock(ctx context.Context, key string, ttl time.Duration)
 func (c *ValkeyClient) Unlock(ctx context.Context, key string) error {
 	lockKey := "lock:" + key
 	
-	if err := c.client.Del(ctx, lockKey).Err(); err != nil {
+	err := c.do(ctx, "unlock", func() error {
+		return c.client.Del(ctx, lockKey).Err()
+	})
+	if err != nil {
 		return fmt.Errorf("failed to release lock: %w", err)
 	}
 	
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"encoding/base64"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
@@ -13,12 +15,20 @@ import (
 type Config struct {
 	Version string `yaml:"version" envconfig:"VERSION" default:"1.0.0"`
 	
-	Server   ServerConfig   `yaml:"server"`
-	Cache    CacheConfig    `yaml:"cache"`
-	Backend  BackendConfig  `yaml:"backend"`
-	Auth     AuthConfig     `yaml:"auth"`
-	Logging  LoggingConfig  `yaml:"logging"`
-	Security SecurityConfig `yaml:"security"`
+	Server      ServerConfig      `yaml:"server"`
+	Cache       CacheConfig       `yaml:"cache"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Backend     BackendConfig     `yaml:"backend"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Logging     LoggingConfig     `yaml:"logging"`
+	Security    SecurityConfig    `yaml:"security"`
+	Tools       ToolsConfig       `yaml:"tools"`
+	Sync        SyncConfig        `yaml:"sync"`
+	Scratch     ScratchConfig     `yaml:"scratch"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Credentials CredentialsConfig `yaml:"credentials"`
+	Jobs        JobsConfig        `yaml:"jobs"`
+	Messages    MessagesConfig    `yaml:"messages"`
 }
 
 // ServerConfig defines HTTP server settings
@@ -27,21 +37,77 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `yaml:"readTimeout" envconfig:"READ_TIMEOUT" default:"30s"`
 	WriteTimeout time.Duration `yaml:"writeTimeout" envconfig:"WRITE_TIMEOUT" default:"30s"`
 	IdleTimeout  time.Duration `yaml:"idleTimeout" envconfig:"IDLE_TIMEOUT" default:"120s"`
+
+	// SocketPath, when set, also serves the helper protocol on a Unix socket
+	// so scripts can call the runtime without HTTP
+	SocketPath string `yaml:"socketPath" envconfig:"SOCKET_PATH"`
+
+	// Prometheus metrics of requests, the cache, backend calls and
+	// executions, served without authentication on MetricsPath
+	MetricsEnabled bool   `yaml:"metricsEnabled" envconfig:"METRICS_ENABLED" default:"true"`
+	MetricsPath    string `yaml:"metricsPath" envconfig:"METRICS_PATH" default:"/metrics"`
 }
 
 // CacheConfig defines Valkey cache settings
 type CacheConfig struct {
-	URL            string        `yaml:"url" envconfig:"VALKEY_URL" default:"valkey://localhost:6379"`
-	Password       string        `yaml:"password" envconfig:"VALKEY_PASSWORD"`
-	DB             int           `yaml:"db" envconfig:"VALKEY_DB" default:"0"`
-	MaxRetries     int           `yaml:"maxRetries" envconfig:"VALKEY_MAX_RETRIES" default:"3"`
-	DialTimeout    time.Duration `yaml:"dialTimeout" envconfig:"VALKEY_DIAL_TIMEOUT" default:"5s"`
-	ReadTimeout    time.Duration `yaml:"readTimeout" envconfig:"VALKEY_READ_TIMEOUT" default:"3s"`
-	WriteTimeout   time.Duration `yaml:"writeTimeout" envconfig:"VALKEY_WRITE_TIMEOUT" default:"3s"`
-	PoolSize       int           `yaml:"poolSize" envconfig:"VALKEY_POOL_SIZE" default:"10"`
-	MinIdleConns   int           `yaml:"minIdleConns" envconfig:"VALKEY_MIN_IDLE_CONNS" default:"2"`
-	MaxConnAge     time.Duration `yaml:"maxConnAge" envconfig:"VALKEY_MAX_CONN_AGE" default:"30m"`
-	TTL            time.Duration `yaml:"ttl" envconfig:"CACHE_TTL" default:"5m"`
+	URL             string        `yaml:"url" envconfig:"VALKEY_URL" default:"valkey://localhost:6379"`
+	Password        string        `yaml:"password" envconfig:"VALKEY_PASSWORD"`
+	DB              int           `yaml:"db" envconfig:"VALKEY_DB" default:"0"`
+	MaxRetries      int           `yaml:"maxRetries" envconfig:"VALKEY_MAX_RETRIES" default:"3"`
+	RetryBackoff    time.Duration `yaml:"retryBackoff" envconfig:"VALKEY_RETRY_BACKOFF" default:"50ms"`
+	RetryMaxBackoff time.Duration `yaml:"retryMaxBackoff" envconfig:"VALKEY_RETRY_MAX_BACKOFF" default:"1s"`
+	DialTimeout     time.Duration `yaml:"dialTimeout" envconfig:"VALKEY_DIAL_TIMEOUT" default:"5s"`
+	ReadTimeout     time.Duration `yaml:"readTimeout" envconfig:"VALKEY_READ_TIMEOUT" default:"3s"`
+	WriteTimeout    time.Duration `yaml:"writeTimeout" envconfig:"VALKEY_WRITE_TIMEOUT" default:"3s"`
+	PoolSize        int           `yaml:"poolSize" envconfig:"VALKEY_POOL_SIZE" default:"10"`
+	MinIdleConns    int           `yaml:"minIdleConns" envconfig:"VALKEY_MIN_IDLE_CONNS" default:"2"`
+	MaxConnAge      time.Duration `yaml:"maxConnAge" envconfig:"VALKEY_MAX_CONN_AGE" default:"30m"`
+	TTL             time.Duration `yaml:"ttl" envconfig:"CACHE_TTL" default:"5m"`
+
+	// Per-type TTLs; zero uses TTL. Contexts rarely change during an
+	// execution, while outputs are read back at most once or twice.
+	ContextTTL  time.Duration `yaml:"contextTTL" envconfig:"CACHE_CONTEXT_TTL" default:"30m"`
+	InputTTL    time.Duration `yaml:"inputTTL" envconfig:"CACHE_INPUT_TTL"`
+	OutputTTL   time.Duration `yaml:"outputTTL" envconfig:"CACHE_OUTPUT_TTL" default:"1m"`
+	VariableTTL time.Duration `yaml:"variableTTL" envconfig:"CACHE_VARIABLE_TTL"`
+
+	// BlobTTL is how long the valkey storage backend keeps stored outputs
+	// and artifacts. They have no other copy, so it is a retention rather
+	// than a cache lifetime.
+	BlobTTL time.Duration `yaml:"blobTTL" envconfig:"CACHE_BLOB_TTL" default:"168h"`
+
+	// SlidingExpiration renews an object's TTL whenever it is read
+	SlidingExpiration bool `yaml:"slidingExpiration" envconfig:"CACHE_SLIDING_EXPIRATION" default:"false"`
+}
+
+// StorageConfig defines where execution outputs are stored. Outputs up to
+// InlineThreshold bytes stay in the cache; larger ones go to Backend and the
+// cache only keeps a reference. Its variables are only read with the
+// RUNTIME_STORAGE_ prefix, since bare names such as PATH and REGION belong to
+// the host.
+type StorageConfig struct {
+	Backend         string                  `yaml:"backend" split_words:"true" default:"valkey"`
+	InlineThreshold int64                   `yaml:"inlineThreshold" split_words:"true" default:"262144"`
+	MaxArtifactSize int64                   `yaml:"maxArtifactSize" split_words:"true" default:"104857600"`
+	Filesystem      FilesystemStorageConfig `yaml:"filesystem"`
+	S3              S3StorageConfig         `yaml:"s3"`
+}
+
+// FilesystemStorageConfig defines local disk storage settings
+type FilesystemStorageConfig struct {
+	Path string `yaml:"path" split_words:"true" default:"/var/lib/cronium-runtime/outputs"`
+}
+
+// S3StorageConfig defines S3-compatible object storage settings
+type S3StorageConfig struct {
+	Endpoint        string `yaml:"endpoint" split_words:"true" default:"https://s3.amazonaws.com"`
+	Region          string `yaml:"region" split_words:"true" default:"us-east-1"`
+	Bucket          string `yaml:"bucket" split_words:"true"`
+	Prefix          string `yaml:"prefix" split_words:"true" default:"cronium/outputs/"`
+	AccessKeyID     string `yaml:"accessKeyId" split_words:"true"`
+	SecretAccessKey string `yaml:"secretAccessKey" split_words:"true"`
+	SessionToken    string `yaml:"sessionToken" split_words:"true"`
+	UsePathStyle    bool   `yaml:"usePathStyle" split_words:"true" default:"false"`
 }
 
 // BackendConfig defines backend API settings
@@ -58,6 +124,32 @@ type AuthConfig struct {
 	JWTSecret         string        `yaml:"jwtSecret" envconfig:"JWT_SECRET" required:"true"`
 	TokenExpiration   time.Duration `yaml:"tokenExpiration" envconfig:"TOKEN_EXPIRATION" default:"1h"`
 	RefreshExpiration time.Duration `yaml:"refreshExpiration" envconfig:"REFRESH_EXPIRATION" default:"24h"`
+
+	// ServiceToken authenticates the backend on the internal endpoints;
+	// they are disabled while it is empty
+	ServiceToken string `yaml:"serviceToken" envconfig:"SERVICE_TOKEN"`
+}
+
+// EncryptionConfig defines the envelope encryption of sensitive variables in
+// the cache. Without a master key sensitive variables are not cached.
+type EncryptionConfig struct {
+	// MasterKey is a base64-encoded 32-byte key that wraps the data keys
+	MasterKey string `yaml:"masterKey" envconfig:"MASTER_KEY"`
+}
+
+// Key decodes the master key; it is nil when none is configured
+func (c EncryptionConfig) Key() ([]byte, error) {
+	if c.MasterKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(c.MasterKey)
+	if err != nil {
+		return nil, fmt.Errorf("master key is not valid base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	return key, nil
 }
 
 // LoggingConfig defines logging settings
@@ -78,6 +170,141 @@ type SecurityConfig struct {
 	TLSKey          string   `yaml:"tlsKey" envconfig:"TLS_KEY"`
 }
 
+// SyncConfig defines how partial results pushed by bundled-mode runners are
+// batched before they are sent to the backend: every Interval, or as soon as
+// an execution has MaxPendingBytes waiting. Its variables are only read with
+// the RUNTIME_SYNC_ prefix.
+type SyncConfig struct {
+	Interval        time.Duration `yaml:"interval" split_words:"true" default:"5s"`
+	MaxPendingBytes int           `yaml:"maxPendingBytes" split_words:"true" default:"262144"`
+}
+
+// ScratchConfig limits the scratch values an execution keeps in the cache.
+// They expire TTL after the execution last writes one, so TTL should cover
+// the longest execution. Its variables are only read with the
+// RUNTIME_SCRATCH_ prefix, so a host TTL is never picked up.
+type ScratchConfig struct {
+	TTL          time.Duration `yaml:"ttl" split_words:"true" default:"1h"`
+	MaxKeys      int           `yaml:"maxKeys" split_words:"true" default:"1000"`
+	MaxValueSize int           `yaml:"maxValueSize" split_words:"true" default:"65536"`
+}
+
+// JobsConfig limits the child jobs scripts submit, so a script cannot fan
+// out without bound or spawn itself recursively forever. Its variables are
+// only read with the RUNTIME_JOBS_ prefix, so host variables cannot loosen
+// the limits.
+type JobsConfig struct {
+	MaxChildren int `yaml:"maxChildren" split_words:"true" default:"100"`
+	MaxDepth    int `yaml:"maxDepth" split_words:"true" default:"5"`
+	// How long submissions are remembered for idempotency and the limit
+	TTL time.Duration `yaml:"ttl" split_words:"true" default:"24h"`
+}
+
+// MessagesConfig defines the WebSocket channel on which scripts receive
+// push messages. Its variables are only read with the RUNTIME_MESSAGES_
+// prefix.
+type MessagesConfig struct {
+	// How often idle channels are pinged to detect dead connections
+	PingInterval time.Duration `yaml:"pingInterval" split_words:"true" default:"30s"`
+	// Channels one execution may have open at once
+	MaxConnections int `yaml:"maxConnections" split_words:"true" default:"4"`
+}
+
+// ToolsConfig defines the tools whose actions the runtime runs itself
+// instead of forwarding them to the backend. Tools not enabled here are still
+// executed by the backend. Unset sizes and timeouts fall back to defaults.
+// Its variables are only read with the RUNTIME_TOOLS_ prefix, since bare
+// names such as ENABLED and REGION belong to the host.
+type ToolsConfig struct {
+	Timeout time.Duration   `yaml:"timeout" split_words:"true"`
+	Slack   SlackToolConfig `yaml:"slack"`
+	HTTP    HTTPToolConfig  `yaml:"http"`
+	S3      S3ToolConfig    `yaml:"s3"`
+}
+
+// SlackToolConfig defines the Slack webhooks scripts may post to by name
+type SlackToolConfig struct {
+	Enabled        bool              `yaml:"enabled" split_words:"true"`
+	Webhooks       map[string]string `yaml:"webhooks" ignored:"true"`
+	DefaultWebhook string            `yaml:"defaultWebhook" split_words:"true"`
+	// Users the tool runs locally for; empty means every user
+	AllowedUsers []string `yaml:"allowedUsers" split_words:"true"`
+}
+
+// HTTPToolConfig defines the hosts scripts may call through the http tool.
+// Hosts are exact names or "*.example.com" for any subdomain.
+type HTTPToolConfig struct {
+	Enabled         bool     `yaml:"enabled" split_words:"true"`
+	AllowedHosts    []string `yaml:"allowedHosts" split_words:"true"`
+	AllowedMethods  []string `yaml:"allowedMethods" split_words:"true"`
+	MaxResponseSize int64    `yaml:"maxResponseSize" split_words:"true"`
+	AllowedUsers    []string `yaml:"allowedUsers" split_words:"true"`
+}
+
+// S3ToolConfig defines the buckets scripts may put objects into. Buckets are
+// "bucket" or "bucket/prefix/" to limit keys to a prefix.
+type S3ToolConfig struct {
+	Enabled         bool     `yaml:"enabled" split_words:"true"`
+	Endpoint        string   `yaml:"endpoint" split_words:"true"`
+	Region          string   `yaml:"region" split_words:"true"`
+	AccessKeyID     string   `yaml:"accessKeyId" split_words:"true"`
+	SecretAccessKey string   `yaml:"secretAccessKey" split_words:"true"`
+	SessionToken    string   `yaml:"sessionToken" split_words:"true"`
+	UsePathStyle    bool     `yaml:"usePathStyle" split_words:"true"`
+	AllowedBuckets  []string `yaml:"allowedBuckets" split_words:"true"`
+	MaxObjectSize   int64    `yaml:"maxObjectSize" split_words:"true"`
+	AllowedUsers    []string `yaml:"allowedUsers" split_words:"true"`
+}
+
+// CredentialsConfig defines the providers scripts can get short-lived
+// credentials from. Each provider offers named roles; a script asks for a
+// role and gets credentials that expire within the role's TTL. Its variables
+// are only read with the RUNTIME_CREDENTIALS_ prefix, so a host MAX_TTL
+// cannot lengthen credentials.
+type CredentialsConfig struct {
+	DefaultTTL time.Duration          `yaml:"defaultTTL" split_words:"true" default:"15m"`
+	MaxTTL     time.Duration          `yaml:"maxTTL" split_words:"true" default:"1h"`
+	AWS        AWSCredentialsConfig   `yaml:"aws"`
+	Vault      VaultCredentialsConfig `yaml:"vault"`
+}
+
+// CredentialRoleConfig defines a role scripts may ask credentials for
+type CredentialRoleConfig struct {
+	// Target is the ARN of the AWS role to assume, or the Vault path that
+	// issues the credentials, such as database/creds/reporting
+	Target string `yaml:"target"`
+	// Policy is an AWS session policy that further limits the role
+	Policy string        `yaml:"policy"`
+	MaxTTL time.Duration `yaml:"maxTTL"`
+	// Users the role is offered to; empty means every user
+	AllowedUsers []string `yaml:"allowedUsers"`
+}
+
+// AWSCredentialsConfig defines the AWS roles assumed through STS with the
+// runtime's own credentials. Its variables are only read with the
+// RUNTIME_CREDENTIALS_AWS_ prefix, since bare names such as REGION belong to
+// the host.
+type AWSCredentialsConfig struct {
+	Enabled         bool                            `yaml:"enabled" split_words:"true"`
+	Endpoint        string                          `yaml:"endpoint" split_words:"true" default:"https://sts.amazonaws.com"`
+	Region          string                          `yaml:"region" split_words:"true" default:"us-east-1"`
+	AccessKeyID     string                          `yaml:"accessKeyId" split_words:"true"`
+	SecretAccessKey string                          `yaml:"secretAccessKey" split_words:"true"`
+	SessionToken    string                          `yaml:"sessionToken" split_words:"true"`
+	Roles           map[string]CredentialRoleConfig `yaml:"roles" ignored:"true"`
+}
+
+// VaultCredentialsConfig defines the Vault dynamic secrets, such as database
+// users, that scripts can lease. Like AWSCredentialsConfig, its variables
+// are only read with their prefix, so a host TOKEN is never picked up.
+type VaultCredentialsConfig struct {
+	Enabled   bool                            `yaml:"enabled" split_words:"true"`
+	Address   string                          `yaml:"address" split_words:"true"`
+	Token     string                          `yaml:"token" split_words:"true"`
+	Namespace string                          `yaml:"namespace" split_words:"true"`
+	Roles     map[string]CredentialRoleConfig `yaml:"roles" ignored:"true"`
+}
+
 // Load loads configuration from file and environment variables
 func Load() (*Config, error) {
 	cfg := &Config{}
@@ -117,6 +344,9 @@ func (c *Config) Validate() error {
 	if c.Server.Port < 1 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
+	if c.Server.MetricsEnabled && !strings.HasPrefix(c.Server.MetricsPath, "/") {
+		return fmt.Errorf("invalid metrics path: %q", c.Server.MetricsPath)
+	}
 
 	if c.Auth.JWTSecret == "" {
 		return fmt.Errorf("JWT secret is required")
@@ -126,5 +356,99 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("backend URL is required")
 	}
 
+	switch c.Storage.Backend {
+	case "valkey", "filesystem":
+	case "s3":
+		if c.Storage.S3.Bucket == "" {
+			return fmt.Errorf("S3 bucket is required for s3 storage")
+		}
+		if c.Storage.S3.AccessKeyID == "" || c.Storage.S3.SecretAccessKey == "" {
+			return fmt.Errorf("S3 credentials are required for s3 storage")
+		}
+	default:
+		return fmt.Errorf("invalid storage backend: %s", c.Storage.Backend)
+	}
+
+	for name, ttl := range map[string]time.Duration{
+		"cache":    c.Cache.TTL,
+		"context":  c.Cache.ContextTTL,
+		"input":    c.Cache.InputTTL,
+		"output":   c.Cache.OutputTTL,
+		"variable": c.Cache.VariableTTL,
+	} {
+		if ttl < 0 {
+			return fmt.Errorf("invalid %s TTL: %s", name, ttl)
+		}
+	}
+	if c.Cache.MaxRetries < 0 {
+		return fmt.Errorf("invalid Valkey max retries: %d", c.Cache.MaxRetries)
+	}
+	if c.Cache.RetryBackoff <= 0 || c.Cache.RetryMaxBackoff < c.Cache.RetryBackoff {
+		return fmt.Errorf("Valkey retry backoff must be positive and at most the max backoff")
+	}
+
+	if c.Sync.Interval <= 0 {
+		return fmt.Errorf("invalid sync interval: %s", c.Sync.Interval)
+	}
+
+	if _, err := c.Encryption.Key(); err != nil {
+		return fmt.Errorf("invalid encryption config: %w", err)
+	}
+
+	if c.Scratch.TTL <= 0 {
+		return fmt.Errorf("invalid scratch TTL: %s", c.Scratch.TTL)
+	}
+	if c.Scratch.MaxKeys < 1 || c.Scratch.MaxValueSize < 1 {
+		return fmt.Errorf("scratch limits must be positive")
+	}
+
+	if c.Jobs.MaxChildren < 1 || c.Jobs.MaxDepth < 1 || c.Jobs.TTL <= 0 {
+		return fmt.Errorf("child job limits and TTL must be positive")
+	}
+
+	if c.Messages.PingInterval <= 0 || c.Messages.MaxConnections < 1 {
+		return fmt.Errorf("message channel ping interval and connection limit must be positive")
+	}
+
+	if c.Tools.Slack.Enabled && len(c.Tools.Slack.Webhooks) == 0 {
+		return fmt.Errorf("slack tool requires at least one webhook")
+	}
+	if c.Tools.Slack.DefaultWebhook != "" {
+		if _, ok := c.Tools.Slack.Webhooks[c.Tools.Slack.DefaultWebhook]; !ok {
+			return fmt.Errorf("slack default webhook %q is not configured", c.Tools.Slack.DefaultWebhook)
+		}
+	}
+	if c.Tools.HTTP.Enabled && len(c.Tools.HTTP.AllowedHosts) == 0 {
+		return fmt.Errorf("http tool requires allowed hosts")
+	}
+	if c.Tools.S3.Enabled {
+		if len(c.Tools.S3.AllowedBuckets) == 0 {
+			return fmt.Errorf("s3 tool requires allowed buckets")
+		}
+		if c.Tools.S3.AccessKeyID == "" || c.Tools.S3.SecretAccessKey == "" {
+			return fmt.Errorf("S3 credentials are required for the s3 tool")
+		}
+	}
+
+	if c.Credentials.DefaultTTL <= 0 || c.Credentials.MaxTTL < c.Credentials.DefaultTTL {
+		return fmt.Errorf("credential TTLs must be positive with the default at most the max")
+	}
+	if aws := c.Credentials.AWS; aws.Enabled {
+		if aws.AccessKeyID == "" || aws.SecretAccessKey == "" {
+			return fmt.Errorf("AWS credentials are required for the aws credential provider")
+		}
+		if len(aws.Roles) == 0 {
+			return fmt.Errorf("aws credential provider requires roles")
+		}
+	}
+	if vault := c.Credentials.Vault; vault.Enabled {
+		if vault.Address == "" || vault.Token == "" {
+			return fmt.Errorf("vault credential provider requires an address and a token")
+		}
+		if len(vault.Roles) == 0 {
+			return fmt.Errorf("vault credential provider requires roles")
+		}
+	}
+
 	return nil
 }
\ No newline at end of file
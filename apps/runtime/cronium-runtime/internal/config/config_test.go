@@ -0,0 +1,118 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// loadWithEnv loads the configuration with only the given variables set on
+// top of a minimal environment
+func loadWithEnv(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("RUNTIME_AUTH_JWT_SECRET", "test-secret")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	return cfg
+}
+
+func TestLoadIgnoresHostVariables(t *testing.T) {
+	cfg := loadWithEnv(t, map[string]string{
+		"PATH":   "/usr/local/bin:/usr/bin:/bin",
+		"REGION": "eu-west-1",
+		"BUCKET": "host-bucket",
+		"TOKEN":  "host-token",
+		// Would turn on every tool and fail to parse as a duration
+		"ENABLED":         "true",
+		"TIMEOUT":         "forever",
+		"ACCESS_KEY_ID":   "host-key",
+		"INTERVAL":        "often",
+		"MAX_KEYS":        "1",
+		"MAX_VALUE_SIZE":  "huge",
+		"TTL":             "a day",
+		"MAX_CHILDREN":    "100000",
+		"MAX_DEPTH":       "1000",
+		"PING_INTERVAL":   "sometimes",
+		"MAX_CONNECTIONS": "1000",
+		"DEFAULT_TTL":     "24h",
+		"MAX_TTL":         "720h",
+	})
+
+	if got, want := cfg.Storage.Filesystem.Path, "/var/lib/cronium-runtime/outputs"; got != want {
+		t.Errorf("Storage.Filesystem.Path = %q, want %q", got, want)
+	}
+	if got, want := cfg.Storage.S3.Region, "us-east-1"; got != want {
+		t.Errorf("Storage.S3.Region = %q, want %q", got, want)
+	}
+	if cfg.Storage.S3.Bucket != "" {
+		t.Errorf("Storage.S3.Bucket = %q, want empty", cfg.Storage.S3.Bucket)
+	}
+	if got, want := cfg.Credentials.AWS.Region, "us-east-1"; got != want {
+		t.Errorf("Credentials.AWS.Region = %q, want %q", got, want)
+	}
+	if cfg.Credentials.Vault.Token != "" {
+		t.Errorf("Credentials.Vault.Token = %q, want empty", cfg.Credentials.Vault.Token)
+	}
+	if cfg.Tools.Slack.Enabled || cfg.Tools.HTTP.Enabled || cfg.Tools.S3.Enabled {
+		t.Errorf("tools enabled by host ENABLED")
+	}
+	if cfg.Tools.Timeout != 0 || cfg.Tools.S3.Region != "" || cfg.Tools.S3.AccessKeyID != "" {
+		t.Errorf("Tools = %+v, want host variables ignored", cfg.Tools)
+	}
+	if got, want := cfg.Sync.Interval, 5*time.Second; got != want {
+		t.Errorf("Sync.Interval = %s, want %s", got, want)
+	}
+	if cfg.Scratch.MaxKeys != 1000 || cfg.Scratch.MaxValueSize != 65536 {
+		t.Errorf("Scratch = %+v, want the defaults", cfg.Scratch)
+	}
+	if cfg.Scratch.TTL != time.Hour {
+		t.Errorf("Scratch.TTL = %s, want 1h", cfg.Scratch.TTL)
+	}
+	if cfg.Jobs.MaxChildren != 100 || cfg.Jobs.MaxDepth != 5 || cfg.Jobs.TTL != 24*time.Hour {
+		t.Errorf("Jobs = %+v, want the defaults", cfg.Jobs)
+	}
+	if cfg.Messages.PingInterval != 30*time.Second || cfg.Messages.MaxConnections != 4 {
+		t.Errorf("Messages = %+v, want the defaults", cfg.Messages)
+	}
+	if cfg.Credentials.DefaultTTL != 15*time.Minute || cfg.Credentials.MaxTTL != time.Hour {
+		t.Errorf("Credentials TTLs = %s, %s, want the defaults", cfg.Credentials.DefaultTTL, cfg.Credentials.MaxTTL)
+	}
+}
+
+func TestLoadPrefixedVariables(t *testing.T) {
+	cfg := loadWithEnv(t, map[string]string{
+		"PATH":                            "/usr/bin:/bin",
+		"RUNTIME_STORAGE_FILESYSTEM_PATH": "/data/outputs",
+		"RUNTIME_STORAGE_S3_REGION":       "eu-west-1",
+		"RUNTIME_CREDENTIALS_VAULT_TOKEN": "vault-token",
+		"RUNTIME_CREDENTIALS_AWS_REGION":  "eu-central-1",
+		"RUNTIME_TOOLS_TIMEOUT":           "45s",
+		"RUNTIME_TOOLS_S3_REGION":         "us-west-2",
+	})
+
+	if got, want := cfg.Storage.Filesystem.Path, "/data/outputs"; got != want {
+		t.Errorf("Storage.Filesystem.Path = %q, want %q", got, want)
+	}
+	if got, want := cfg.Storage.S3.Region, "eu-west-1"; got != want {
+		t.Errorf("Storage.S3.Region = %q, want %q", got, want)
+	}
+	if got, want := cfg.Credentials.Vault.Token, "vault-token"; got != want {
+		t.Errorf("Credentials.Vault.Token = %q, want %q", got, want)
+	}
+	if got, want := cfg.Credentials.AWS.Region, "eu-central-1"; got != want {
+		t.Errorf("Credentials.AWS.Region = %q, want %q", got, want)
+	}
+	if got, want := cfg.Tools.Timeout, 45*time.Second; got != want {
+		t.Errorf("Tools.Timeout = %s, want %s", got, want)
+	}
+	if got, want := cfg.Tools.S3.Region, "us-west-2"; got != want {
+		t.Errorf("Tools.S3.Region = %q, want %q", got, want)
+	}
+}
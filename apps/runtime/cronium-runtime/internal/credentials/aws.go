@@ -0,0 +1,132 @@
+package credentials
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/sigv4"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// stsMinTTL is the shortest session STS issues
+const stsMinTTL = 15 * time.Minute
+
+// sessionNameInvalid matches the characters STS does not allow in a role
+// session name
+var sessionNameInvalid = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// awsProvider assumes AWS roles through STS. STS sessions cannot be revoked,
+// so they are only bounded by their TTL.
+type awsProvider struct {
+	config     config.AWSCredentialsConfig
+	signer     sigv4.Signer
+	httpClient *http.Client
+}
+
+func newAWSProvider(cfg config.AWSCredentialsConfig) *awsProvider {
+	return &awsProvider{
+		config: cfg,
+		signer: sigv4.Signer{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+			Region:          cfg.Region,
+			Service:         "sts",
+		},
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (p *awsProvider) Name() string {
+	return "aws"
+}
+
+// MinTTL returns the shortest session STS issues
+func (p *awsProvider) MinTTL() time.Duration {
+	return stsMinTTL
+}
+
+// assumeRoleResponse is the part of the AssumeRole response the provider uses
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+// Mint assumes the role. The session is named after the execution, so the
+// role's activity can be traced back to it in CloudTrail.
+func (p *awsProvider) Mint(ctx context.Context, req *Request) (*types.Credential, *Lease, error) {
+	sessionName := sessionNameInvalid.ReplaceAllString("cronium-"+req.ExecutionID, "-")
+	if len(sessionName) > 64 {
+		sessionName = sessionName[:64]
+	}
+
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {"2011-06-15"},
+		"RoleArn":         {req.Target},
+		"RoleSessionName": {sessionName},
+		"DurationSeconds": {strconv.Itoa(int(req.TTL.Seconds()))},
+	}
+	if req.Policy != "" {
+		form.Set("Policy", req.Policy)
+	}
+	body := form.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.Endpoint, strings.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	p.signer.Sign(httpReq, sigv4.PayloadHash([]byte(body)), time.Now())
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to call STS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read STS response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, nil, fmt.Errorf("STS error: %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var result assumeRoleResponse
+	if err := xml.Unmarshal(data, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse STS response: %w", err)
+	}
+	creds := result.Credentials
+	if creds.AccessKeyID == "" {
+		return nil, nil, fmt.Errorf("STS response has no credentials")
+	}
+
+	return &types.Credential{
+		Values: map[string]string{
+			"accessKeyId":     creds.AccessKeyID,
+			"secretAccessKey": creds.SecretAccessKey,
+			"sessionToken":    creds.SessionToken,
+		},
+		ExpiresAt: creds.Expiration,
+	}, nil, nil
+}
+
+// Revoke is not supported by STS; sessions expire on their own
+func (p *awsProvider) Revoke(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package credentials mints short-lived credentials for running scripts, so
+// that scripts do not need long-lived secrets. Providers such as AWS STS and
+// Vault offer named roles; a script asks for a role and gets credentials
+// that expire with a TTL bounded by the role. Credentials that can be
+// revoked are tracked per execution and revoked when the execution ends.
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnknownRole is returned for a provider or role that is not configured
+	ErrUnknownRole = errors.New("unknown credential role")
+
+	// ErrDenied is returned when a role is not offered to the user
+	ErrDenied = errors.New("credential role not allowed")
+)
+
+// Request is a request for credentials by an execution
+type Request struct {
+	ExecutionID string
+	Role        string
+	Target      string
+	Policy      string
+	TTL         time.Duration
+}
+
+// Lease is a minted credential that can be revoked before it expires
+type Lease struct {
+	Provider  string    `json:"provider"`
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Provider mints credentials of one kind. Mint returns a lease for
+// credentials that can be revoked, or nil for ones that only expire.
+type Provider interface {
+	Name() string
+	MinTTL() time.Duration
+	Mint(ctx context.Context, req *Request) (*types.Credential, *Lease, error)
+	Revoke(ctx context.Context, leaseID string) error
+}
+
+// LeaseStore keeps the revocable leases of executions until they end
+type LeaseStore interface {
+	AddLease(ctx context.Context, executionID, leaseKey string, lease []byte, expiresAt time.Time) error
+	Leases(ctx context.Context, executionID string) (map[string]string, error)
+	DeleteLease(ctx context.Context, executionID, leaseKey string) error
+}
+
+// provider is a configured provider and its roles
+type provider struct {
+	Provider
+	roles map[string]config.CredentialRoleConfig
+}
+
+// Broker mints credentials from the configured providers
+type Broker struct {
+	providers  map[string]*provider
+	leases     LeaseStore
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+	log        *logrus.Logger
+}
+
+// NewBroker creates a broker with the enabled providers. It returns nil when
+// no provider is enabled.
+func NewBroker(cfg config.CredentialsConfig, leases LeaseStore, log *logrus.Logger) *Broker {
+	b := &Broker{
+		providers:  make(map[string]*provider),
+		leases:     leases,
+		defaultTTL: cfg.DefaultTTL,
+		maxTTL:     cfg.MaxTTL,
+		log:        log,
+	}
+	if cfg.AWS.Enabled {
+		b.register(newAWSProvider(cfg.AWS), cfg.AWS.Roles)
+	}
+	if cfg.Vault.Enabled {
+		b.register(newVaultProvider(cfg.Vault), cfg.Vault.Roles)
+	}
+
+	if len(b.providers) == 0 {
+		return nil
+	}
+	for name, p := range b.providers {
+		log.WithFields(logrus.Fields{"provider": name, "roles": len(p.roles)}).Info("Credential provider enabled")
+	}
+	return b
+}
+
+// register adds a provider with its roles
+func (b *Broker) register(p Provider, roles map[string]config.CredentialRoleConfig) {
+	b.providers[p.Name()] = &provider{Provider: p, roles: roles}
+}
+
+// Mint mints credentials for an execution of a user. The TTL is the
+// requested one, or the default, bounded by the role's and the broker's
+// maximum.
+func (b *Broker) Mint(ctx context.Context, executionID, userID string, req types.CredentialRequest) (*types.Credential, error) {
+	if b == nil {
+		return nil, fmt.Errorf("%w: no credential providers are configured", ErrUnknownRole)
+	}
+	p, ok := b.providers[req.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: provider %q", ErrUnknownRole, req.Provider)
+	}
+	role, ok := p.roles[req.Role]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s role %q", ErrUnknownRole, req.Provider, req.Role)
+	}
+	if !allowed(role.AllowedUsers, userID) {
+		return nil, fmt.Errorf("%w: %s role %q", ErrDenied, req.Provider, req.Role)
+	}
+
+	ttl := b.defaultTTL
+	if req.TTL > 0 {
+		ttl = time.Duration(req.TTL) * time.Second
+	}
+	maxTTL := b.maxTTL
+	if role.MaxTTL > 0 {
+		maxTTL = min(maxTTL, role.MaxTTL)
+	}
+	ttl = max(min(ttl, maxTTL), p.MinTTL())
+
+	credential, lease, err := p.Mint(ctx, &Request{
+		ExecutionID: executionID,
+		Role:        req.Role,
+		Target:      role.Target,
+		Policy:      role.Policy,
+		TTL:         ttl,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mint %s credentials: %w", req.Provider, err)
+	}
+	credential.Provider = req.Provider
+	credential.Role = req.Role
+
+	if lease != nil {
+		b.track(ctx, executionID, lease, ttl)
+	}
+	return credential, nil
+}
+
+// track records a lease for revocation when the execution ends, and revokes
+// it once its TTL has passed in case the execution never reports its end.
+// A lease the provider gives a longer life than the TTL is cut short that
+// way; the provider's own expiry covers a runtime restart.
+func (b *Broker) track(ctx context.Context, executionID string, lease *Lease, ttl time.Duration) {
+	leaseKey := lease.Provider + ":" + lease.ID
+	data, err := json.Marshal(lease)
+	if err == nil {
+		err = b.leases.AddLease(ctx, executionID, leaseKey, data, lease.ExpiresAt)
+	}
+	if err != nil {
+		b.log.WithError(err).WithField("executionId", executionID).Warn("Failed to record credential lease")
+	}
+
+	time.AfterFunc(ttl, func() {
+		revokeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := b.revoke(revokeCtx, executionID, leaseKey, lease); err != nil {
+			b.log.WithError(err).WithField("executionId", executionID).Warn("Failed to revoke expired credential lease")
+		}
+	})
+}
+
+// RevokeExecution revokes the leases an execution still holds and returns
+// how many it revoked
+func (b *Broker) RevokeExecution(ctx context.Context, executionID string) (int, error) {
+	if b == nil {
+		return 0, nil
+	}
+	stored, err := b.leases.Leases(ctx, executionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load credential leases: %w", err)
+	}
+
+	revoked := 0
+	var errs []error
+	for leaseKey, data := range stored {
+		var lease Lease
+		if err := json.Unmarshal([]byte(data), &lease); err != nil {
+			errs = append(errs, fmt.Errorf("invalid lease %s: %w", leaseKey, err))
+			continue
+		}
+		if err := b.revoke(ctx, executionID, leaseKey, &lease); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		revoked++
+	}
+	return revoked, errors.Join(errs...)
+}
+
+// revoke revokes a lease with its provider and forgets it. Revoking a lease
+// twice is harmless, so the timer and the end of the execution may race.
+func (b *Broker) revoke(ctx context.Context, executionID, leaseKey string, lease *Lease) error {
+	p, ok := b.providers[lease.Provider]
+	if !ok {
+		return fmt.Errorf("lease of unknown provider %s", lease.Provider)
+	}
+	if err := p.Revoke(ctx, lease.ID); err != nil {
+		return fmt.Errorf("failed to revoke %s lease: %w", lease.Provider, err)
+	}
+	return b.leases.DeleteLease(ctx, executionID, leaseKey)
+}
+
+// allowed reports whether a role is offered to a user
+func allowed(users []string, userID string) bool {
+	if len(users) == 0 {
+		return true
+	}
+	for _, u := range users {
+		if u == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// memoryLeases keeps leases in memory
+type memoryLeases struct {
+	mu     sync.Mutex
+	leases map[string]map[string]string
+}
+
+func (m *memoryLeases) AddLease(_ context.Context, executionID, leaseKey string, lease []byte, _ time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.leases[executionID] == nil {
+		m.leases[executionID] = make(map[string]string)
+	}
+	m.leases[executionID][leaseKey] = string(lease)
+	return nil
+}
+
+func (m *memoryLeases) Leases(_ context.Context, executionID string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	leases := make(map[string]string)
+	for k, v := range m.leases[executionID] {
+		leases[k] = v
+	}
+	return leases, nil
+}
+
+func (m *memoryLeases) DeleteLease(_ context.Context, executionID, leaseKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.leases[executionID], leaseKey)
+	return nil
+}
+
+// fakeVault issues database credentials and records revoked leases
+type fakeVault struct {
+	mu      sync.Mutex
+	revoked []string
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "root" {
+		http.Error(w, "permission denied", http.StatusForbidden)
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/database/creds/reporting":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"lease_id":       "database/creds/reporting/abc",
+			"lease_duration": 3600,
+			"data":           map[string]interface{}{"username": "v-reporting", "password": "secret"},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/v1/sys/leases/revoke":
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			LeaseID string `json:"lease_id"`
+		}
+		json.Unmarshal(body, &req)
+		v.mu.Lock()
+		v.revoked = append(v.revoked, req.LeaseID)
+		v.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestBroker(t *testing.T) (*Broker, *fakeVault, *memoryLeases) {
+	t.Helper()
+	vault := &fakeVault{}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+
+	leases := &memoryLeases{leases: make(map[string]map[string]string)}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	broker := NewBroker(config.CredentialsConfig{
+		DefaultTTL: 15 * time.Minute,
+		MaxTTL:     time.Hour,
+		Vault: config.VaultCredentialsConfig{
+			Enabled: true,
+			Address: server.URL,
+			Token:   "root",
+			Roles: map[string]config.CredentialRoleConfig{
+				"reporting": {Target: "database/creds/reporting", MaxTTL: 30 * time.Minute},
+				"admin":     {Target: "database/creds/admin", AllowedUsers: []string{"user-2"}},
+			},
+		},
+	}, leases, log)
+	if broker == nil {
+		t.Fatal("NewBroker returned nil with vault enabled")
+	}
+	return broker, vault, leases
+}
+
+func TestMintAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	broker, vault, leases := newTestBroker(t)
+
+	credential, err := broker.Mint(ctx, "exec-1", "user-1", types.CredentialRequest{Provider: "vault", Role: "reporting", TTL: 7200})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if credential.Values["username"] != "v-reporting" || credential.Provider != "vault" || credential.Role != "reporting" {
+		t.Fatalf("unexpected credential: %+v", credential)
+	}
+	// The requested TTL is capped by the role's maximum
+	if until := time.Until(credential.ExpiresAt); until > 30*time.Minute || until < 29*time.Minute {
+		t.Errorf("credential expires in %s, want about 30m", until)
+	}
+
+	stored, _ := leases.Leases(ctx, "exec-1")
+	if len(stored) != 1 {
+		t.Fatalf("stored %d leases, want 1", len(stored))
+	}
+
+	revoked, err := broker.RevokeExecution(ctx, "exec-1")
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeExecution = %d, %v; want 1, nil", revoked, err)
+	}
+	if len(vault.revoked) != 1 || vault.revoked[0] != "database/creds/reporting/abc" {
+		t.Errorf("vault revoked %v", vault.revoked)
+	}
+	if stored, _ := leases.Leases(ctx, "exec-1"); len(stored) != 0 {
+		t.Errorf("%d leases left after revoking", len(stored))
+	}
+}
+
+func TestMintRejectsUnknownAndDeniedRoles(t *testing.T) {
+	ctx := context.Background()
+	broker, _, _ := newTestBroker(t)
+
+	if _, err := broker.Mint(ctx, "exec-1", "user-1", types.CredentialRequest{Provider: "aws", Role: "reporting"}); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("unconfigured provider: got %v, want ErrUnknownRole", err)
+	}
+	if _, err := broker.Mint(ctx, "exec-1", "user-1", types.CredentialRequest{Provider: "vault", Role: "missing"}); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("unknown role: got %v, want ErrUnknownRole", err)
+	}
+	if _, err := broker.Mint(ctx, "exec-1", "user-1", types.CredentialRequest{Provider: "vault", Role: "admin"}); !errors.Is(err, ErrDenied) {
+		t.Errorf("role of another user: got %v, want ErrDenied", err)
+	}
+
+	var none *Broker
+	if _, err := none.Mint(ctx, "exec-1", "user-1", types.CredentialRequest{Provider: "vault", Role: "reporting"}); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("no broker: got %v, want ErrUnknownRole", err)
+	}
+}
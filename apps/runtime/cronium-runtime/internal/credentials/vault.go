@@ -0,0 +1,126 @@
+package credentials
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// vaultProvider leases dynamic secrets, such as database users, from Vault.
+// Leases are revoked when the execution ends, which for a database user
+// drops the user.
+type vaultProvider struct {
+	config     config.VaultCredentialsConfig
+	httpClient *http.Client
+}
+
+func newVaultProvider(cfg config.VaultCredentialsConfig) *vaultProvider {
+	return &vaultProvider{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns the provider name
+func (p *vaultProvider) Name() string {
+	return "vault"
+}
+
+// MinTTL returns zero; Vault leases can be revoked at any time
+func (p *vaultProvider) MinTTL() time.Duration {
+	return 0
+}
+
+// secretResponse is the part of a Vault secret response the provider uses
+type secretResponse struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Data          map[string]interface{} `json:"data"`
+}
+
+// Mint reads the role's path, which issues new credentials on every read.
+// The credentials expire when the lease does or the TTL passes, whichever
+// comes first.
+func (p *vaultProvider) Mint(ctx context.Context, req *Request) (*types.Credential, *Lease, error) {
+	var secret secretResponse
+	if err := p.call(ctx, http.MethodGet, "/v1/"+strings.TrimPrefix(req.Target, "/"), nil, &secret); err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string, len(secret.Data))
+	for k, v := range secret.Data {
+		if s, ok := v.(string); ok {
+			values[k] = s
+		} else {
+			values[k] = fmt.Sprint(v)
+		}
+	}
+
+	expiresAt := time.Now().Add(req.TTL)
+	if secret.LeaseDuration > 0 {
+		if leaseEnd := time.Now().Add(time.Duration(secret.LeaseDuration) * time.Second); leaseEnd.Before(expiresAt) {
+			expiresAt = leaseEnd
+		}
+	}
+
+	credential := &types.Credential{Values: values, ExpiresAt: expiresAt}
+	if secret.LeaseID == "" {
+		return credential, nil, nil
+	}
+	return credential, &Lease{Provider: p.Name(), ID: secret.LeaseID, ExpiresAt: expiresAt}, nil
+}
+
+// Revoke revokes a lease
+func (p *vaultProvider) Revoke(ctx context.Context, leaseID string) error {
+	return p.call(ctx, http.MethodPut, "/v1/sys/leases/revoke", map[string]string{"lease_id": leaseID}, nil)
+}
+
+// call makes a Vault API request and decodes the response into out
+func (p *vaultProvider) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(p.config.Address, "/")+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", p.config.Token)
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Vault error: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to parse Vault response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Package envelope encrypts sensitive values with envelope encryption. Each
+// execution gets its own data key, which encrypts the values; the data key is
+// stored only wrapped, encrypted by a master key that never leaves the
+// KeyWrapper, so the cache holds neither a plaintext value nor a usable key.
+package envelope
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// keySize is the size of master and data keys, for AES-256
+const keySize = 32
+
+// sealedPrefix marks the format of sealed values
+const sealedPrefix = "v1:"
+
+// ErrNoDataKey is returned when opening a value of an execution whose data
+// key has expired or was never created
+var ErrNoDataKey = errors.New("no data key for execution")
+
+// KeyWrapper encrypts and decrypts data keys with a master key. LocalWrapper
+// keeps the master key in memory; a KMS client can implement it to keep the
+// master key in the KMS.
+type KeyWrapper interface {
+	Wrap(ctx context.Context, key []byte) ([]byte, error)
+	Unwrap(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// KeyStore keeps the wrapped data keys of executions
+type KeyStore interface {
+	// GetDataKey returns an execution's wrapped data key, or nil when it
+	// has none
+	GetDataKey(ctx context.Context, executionID string) ([]byte, error)
+
+	// SetDataKey stores an execution's wrapped data key unless it has one
+	// already, and returns the one stored
+	SetDataKey(ctx context.Context, executionID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalWrapper wraps data keys with a master key held in memory
+type LocalWrapper struct {
+	aead cipher.AEAD
+}
+
+// NewLocalWrapper creates a wrapper for a 32-byte master key
+func NewLocalWrapper(masterKey []byte) (*LocalWrapper, error) {
+	if len(masterKey) != keySize {
+		return nil, fmt.Errorf("master key must be %d bytes, got %d", keySize, len(masterKey))
+	}
+	aead, err := newAEAD(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &LocalWrapper{aead: aead}, nil
+}
+
+// Wrap encrypts a data key
+func (w *LocalWrapper) Wrap(_ context.Context, key []byte) ([]byte, error) {
+	return seal(w.aead, key, nil)
+}
+
+// Unwrap decrypts a data key
+func (w *LocalWrapper) Unwrap(_ context.Context, wrapped []byte) ([]byte, error) {
+	return open(w.aead, wrapped, nil)
+}
+
+// Keyring seals and opens values with the data keys of executions
+type Keyring struct {
+	wrapper KeyWrapper
+	store   KeyStore
+}
+
+// NewKeyring creates a keyring
+func NewKeyring(wrapper KeyWrapper, store KeyStore) *Keyring {
+	return &Keyring{wrapper: wrapper, store: store}
+}
+
+// Seal encrypts a value with the execution's data key, creating the key on
+// first use. aad binds the sealed value to its context, such as the
+// variable's name, so it cannot be opened as another value.
+func (k *Keyring) Seal(ctx context.Context, executionID string, plaintext, aad []byte) (string, error) {
+	aead, err := k.dataKey(ctx, executionID, true)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, plaintext, aad)
+	if err != nil {
+		return "", err
+	}
+	return sealedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value sealed for the execution with the same aad
+func (k *Keyring) Open(ctx context.Context, executionID, sealed string, aad []byte) ([]byte, error) {
+	encoded, ok := strings.CutPrefix(sealed, sealedPrefix)
+	if !ok {
+		return nil, fmt.Errorf("unknown sealed value format")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed value: %w", err)
+	}
+
+	aead, err := k.dataKey(ctx, executionID, false)
+	if err != nil {
+		return nil, err
+	}
+	return open(aead, data, aad)
+}
+
+// dataKey returns the cipher of an execution's data key. Runtimes serving
+// the same execution race to create it; the key stored first wins.
+func (k *Keyring) dataKey(ctx context.Context, executionID string, create bool) (cipher.AEAD, error) {
+	wrapped, err := k.store.GetDataKey(ctx, executionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data key: %w", err)
+	}
+
+	if wrapped == nil {
+		if !create {
+			return nil, ErrNoDataKey
+		}
+		key := make([]byte, keySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate data key: %w", err)
+		}
+		if wrapped, err = k.wrapper.Wrap(ctx, key); err != nil {
+			return nil, fmt.Errorf("failed to wrap data key: %w", err)
+		}
+		if wrapped, err = k.store.SetDataKey(ctx, executionID, wrapped); err != nil {
+			return nil, fmt.Errorf("failed to store data key: %w", err)
+		}
+	}
+
+	key, err := k.wrapper.Unwrap(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	return newAEAD(key)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("sealed value is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
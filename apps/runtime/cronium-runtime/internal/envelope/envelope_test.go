@@ -0,0 +1,96 @@
+package envelope
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryStore keeps wrapped data keys in memory
+type memoryStore struct {
+	mu   sync.Mutex
+	keys map[string][]byte
+}
+
+func (s *memoryStore) GetDataKey(_ context.Context, executionID string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[executionID], nil
+}
+
+func (s *memoryStore) SetDataKey(_ context.Context, executionID string, wrapped []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.keys[executionID]; ok {
+		return existing, nil
+	}
+	s.keys[executionID] = wrapped
+	return wrapped, nil
+}
+
+func newTestKeyring(t *testing.T) (*Keyring, *memoryStore) {
+	t.Helper()
+	wrapper, err := NewLocalWrapper([]byte(strings.Repeat("k", keySize)))
+	if err != nil {
+		t.Fatalf("NewLocalWrapper: %v", err)
+	}
+	store := &memoryStore{keys: make(map[string][]byte)}
+	return NewKeyring(wrapper, store), store
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	keyring, store := newTestKeyring(t)
+
+	sealed, err := keyring.Seal(ctx, "exec-1", []byte(`"hunter2"`), []byte("password"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if strings.Contains(sealed, "hunter2") {
+		t.Fatalf("sealed value contains the plaintext: %s", sealed)
+	}
+	if strings.Contains(string(store.keys["exec-1"]), "hunter2") || len(store.keys["exec-1"]) == keySize {
+		t.Fatalf("stored data key does not look wrapped")
+	}
+
+	plaintext, err := keyring.Open(ctx, "exec-1", sealed, []byte("password"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if string(plaintext) != `"hunter2"` {
+		t.Fatalf("Open returned %q", plaintext)
+	}
+}
+
+func TestOpenRejectsOtherContexts(t *testing.T) {
+	ctx := context.Background()
+	keyring, _ := newTestKeyring(t)
+
+	sealed, err := keyring.Seal(ctx, "exec-1", []byte("secret"), []byte("password"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+
+	if _, err := keyring.Open(ctx, "exec-1", sealed, []byte("token")); err == nil {
+		t.Error("opened a value sealed for another variable")
+	}
+
+	// Another execution has no data key yet, and a new one cannot open it
+	if _, err := keyring.Open(ctx, "exec-2", sealed, []byte("password")); !errors.Is(err, ErrNoDataKey) {
+		t.Errorf("Open with another execution: got %v, want ErrNoDataKey", err)
+	}
+	if _, err := keyring.Seal(ctx, "exec-2", []byte("other"), nil); err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if _, err := keyring.Open(ctx, "exec-2", sealed, []byte("password")); err == nil {
+		t.Error("opened a value with another execution's data key")
+	}
+}
+
+func TestNewLocalWrapperKeySize(t *testing.T) {
+	if _, err := NewLocalWrapper([]byte("short")); err == nil {
+		t.Error("accepted a short master key")
+	}
+}
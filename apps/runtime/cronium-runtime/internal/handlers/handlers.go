@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 
+	"github.com/addison-moore/cronium/apps/runtime/internal/credentials"
 	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
 	"github.com/addison-moore/cronium/apps/runtime/internal/service"
 	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
@@ -61,7 +65,8 @@ func (h *Handler) SetOutput(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var body struct {
-		Data interface{} `json:"data"`
+		Data   interface{}       `json:"data"`
+		Render *types.RenderHint `json:"render"`
 	}
 	
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -69,7 +74,11 @@ func (h *Handler) SetOutput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.SetOutput(r.Context(), executionID, body.Data); err != nil {
+	if err := h.service.SetOutput(r.Context(), executionID, body.Data, body.Render); err != nil {
+		if errors.Is(err, service.ErrInvalidRenderHint) {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		h.log.WithError(err).Error("Failed to set output")
 		h.writeError(w, http.StatusInternalServerError, "failed to set output")
 		return
@@ -80,6 +89,77 @@ func (h *Handler) SetOutput(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// UploadFile handles POST /executions/{id}/files. The file is either the
+// first part of a multipart/form-data body, or the raw request body with its
+// name given by the "name" query parameter.
+func (h *Handler) UploadFile(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	maxSize := h.service.MaxArtifactSize()
+	if r.ContentLength > maxSize {
+		h.writeError(w, http.StatusRequestEntityTooLarge, "file exceeds maximum artifact size")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+
+	var (
+		file     io.Reader = r.Body
+		name               = r.URL.Query().Get("name")
+		mimeType           = r.Header.Get("Content-Type")
+		size               = r.ContentLength
+	)
+
+	if strings.HasPrefix(mimeType, "multipart/form-data") {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid multipart body")
+			return
+		}
+		part, err := reader.NextPart()
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "multipart body has no file")
+			return
+		}
+		defer part.Close()
+
+		file = part
+		if part.FileName() != "" {
+			name = part.FileName()
+		}
+		mimeType = part.Header.Get("Content-Type")
+		size = -1
+	}
+
+	if name == "" {
+		h.writeError(w, http.StatusBadRequest, "file name is required")
+		return
+	}
+
+	artifact, err := h.service.UploadFile(r.Context(), executionID, name, mimeType, file, size)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.writeError(w, http.StatusRequestEntityTooLarge, "file exceeds maximum artifact size")
+			return
+		}
+		h.log.WithError(err).Error("Failed to upload file")
+		h.writeError(w, http.StatusInternalServerError, "failed to upload file")
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, types.SuccessResponse{
+		Success: true,
+		Data:    artifact,
+	})
+}
+
 // GetVariable handles GET /executions/{id}/variables/{key}
 func (h *Handler) GetVariable(w http.ResponseWriter, r *http.Request) {
 	executionID := chi.URLParam(r, "id")
@@ -151,6 +231,427 @@ func (h *Handler) SetVariable(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// IncrementVariable handles POST /executions/{id}/variables/{key}/increment
+func (h *Handler) IncrementVariable(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	key := chi.URLParam(r, "key")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	// An empty body increments by one
+	body := struct {
+		By *float64 `json:"by"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err != io.EOF {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	by := 1.0
+	if body.By != nil {
+		by = *body.By
+	}
+
+	value, err := h.service.IncrementVariable(r.Context(), executionID, key, by)
+	if err != nil {
+		h.writeAtomicError(w, err, "failed to increment variable")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":   key,
+			"value": value,
+		},
+	})
+}
+
+// AppendVariable handles POST /executions/{id}/variables/{key}/append
+func (h *Handler) AppendVariable(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	key := chi.URLParam(r, "key")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var body struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	items, err := h.service.AppendVariable(r.Context(), executionID, key, body.Value)
+	if err != nil {
+		h.writeAtomicError(w, err, "failed to append to variable")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":   key,
+			"value": items,
+		},
+	})
+}
+
+// AddToSetVariable handles POST /executions/{id}/variables/{key}/add-to-set
+func (h *Handler) AddToSetVariable(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	key := chi.URLParam(r, "key")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var body struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	members, added, err := h.service.AddToSetVariable(r.Context(), executionID, key, body.Value)
+	if err != nil {
+		h.writeAtomicError(w, err, "failed to add to variable")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":   key,
+			"value": members,
+			"added": added,
+		},
+	})
+}
+
+// writeAtomicError answers a failed atomic operation: 409 when the variable
+// holds the wrong type, 500 otherwise
+func (h *Handler) writeAtomicError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, service.ErrVariableType) {
+		h.writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.log.WithError(err).Error("Atomic variable operation failed")
+	h.writeError(w, http.StatusInternalServerError, message)
+}
+
+// GetScratch handles GET /executions/{id}/scratch/{key}
+func (h *Handler) GetScratch(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	key := chi.URLParam(r, "key")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	value, err := h.service.GetScratch(r.Context(), executionID, key)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to get scratch value")
+		h.writeError(w, http.StatusInternalServerError, "failed to get scratch value")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"key":   key,
+			"value": value,
+		},
+	})
+}
+
+// SetScratch handles PUT /executions/{id}/scratch/{key}
+func (h *Handler) SetScratch(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	key := chi.URLParam(r, "key")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var body struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.service.SetScratch(r.Context(), executionID, key, body.Value); err != nil {
+		if errors.Is(err, service.ErrScratchLimit) {
+			h.writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		h.log.WithError(err).Error("Failed to set scratch value")
+		h.writeError(w, http.StatusInternalServerError, "failed to set scratch value")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+	})
+}
+
+// GetCredential handles POST /executions/{id}/credentials
+func (h *Handler) GetCredential(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var req types.CredentialRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Provider == "" || req.Role == "" {
+		h.writeError(w, http.StatusBadRequest, "provider and role are required")
+		return
+	}
+	if req.TTL < 0 {
+		h.writeError(w, http.StatusBadRequest, "ttl must not be negative")
+		return
+	}
+
+	credential, err := h.service.GetCredential(r.Context(), executionID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, credentials.ErrUnknownRole):
+			h.writeError(w, http.StatusNotFound, err.Error())
+		case errors.Is(err, credentials.ErrDenied):
+			h.writeError(w, http.StatusForbidden, err.Error())
+		default:
+			h.log.WithError(err).Error("Failed to get credential")
+			h.writeError(w, http.StatusBadGateway, "failed to get credential")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data:    credential,
+	})
+}
+
+// SubmitJob handles POST /executions/{id}/jobs
+func (h *Handler) SubmitJob(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var req types.JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.EventID == "" {
+		h.writeError(w, http.StatusBadRequest, "eventId is required")
+		return
+	}
+
+	job, err := h.service.SubmitJob(r.Context(), executionID, claims.JobID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobLimit):
+			h.writeError(w, http.StatusTooManyRequests, err.Error())
+		case errors.Is(err, service.ErrJobPending):
+			h.writeError(w, http.StatusConflict, err.Error())
+		case errors.Is(err, service.ErrNotFound):
+			h.writeError(w, http.StatusNotFound, "event not found")
+		default:
+			h.log.WithError(err).Error("Failed to submit job")
+			h.writeError(w, http.StatusInternalServerError, "failed to submit job")
+		}
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, types.SuccessResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// GetJob handles GET /executions/{id}/jobs/{jobId}
+func (h *Handler) GetJob(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+	jobID := chi.URLParam(r, "jobId")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	job, err := h.service.GetJob(r.Context(), executionID, jobID)
+	if err != nil {
+		if errors.Is(err, service.ErrJobNotFound) {
+			h.writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		h.log.WithError(err).Error("Failed to get job")
+		h.writeError(w, http.StatusInternalServerError, "failed to get job")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data:    job,
+	})
+}
+
+// RevokeCredentials handles DELETE /internal/executions/{id}/credentials,
+// which the backend calls when an execution ends without reporting results
+func (h *Handler) RevokeCredentials(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	revoked, err := h.service.RevokeCredentials(r.Context(), executionID)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to revoke credentials")
+		h.writeError(w, http.StatusInternalServerError, "failed to revoke credentials")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"revoked": revoked},
+	})
+}
+
+// InvalidateExecutionCache handles DELETE /internal/cache/executions/{id},
+// optionally limited to the comma-separated ?types=
+func (h *Handler) InvalidateExecutionCache(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	var kinds []string
+	if raw := r.URL.Query().Get("types"); raw != "" {
+		kinds = strings.Split(raw, ",")
+	}
+
+	removed, err := h.service.InvalidateExecutionCache(r.Context(), executionID, kinds)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownCacheType) {
+			h.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		h.log.WithError(err).Error("Failed to invalidate execution cache")
+		h.writeError(w, http.StatusInternalServerError, "failed to invalidate execution cache")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"removed": removed},
+	})
+}
+
+// InvalidateVariableCache handles DELETE /internal/cache/users/{userId}/variables/{key}
+func (h *Handler) InvalidateVariableCache(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userId")
+	key := chi.URLParam(r, "key")
+
+	removed, err := h.service.InvalidateVariableCache(r.Context(), userID, key)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to invalidate variable cache")
+		h.writeError(w, http.StatusInternalServerError, "failed to invalidate variable cache")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+		Data:    map[string]interface{}{"removed": removed},
+	})
+}
+
+// maxResultUploadSize bounds the body of a result upload, which anyone
+// holding the URL can send
+const maxResultUploadSize = 10 << 20
+
+// SubmitResults handles POST /results/{id}, the signed one-shot URL that
+// bundled-mode runners use to push their final output and variables, and
+// with ?partial=true their results so far
+func (h *Handler) SubmitResults(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	expiresAt, ok := middleware.GetSignedURLExpiry(r.Context())
+	if !ok {
+		h.writeError(w, http.StatusUnauthorized, "missing upload signature")
+		return
+	}
+
+	var body types.ResultUpload
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxResultUploadSize)).Decode(&body); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeError(w, http.StatusRequestEntityTooLarge, "results exceed maximum upload size")
+			return
+		}
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	// Partial uploads may repeat while the script runs; only the final one
+	// uses up the URL
+	if r.URL.Query().Get("partial") == "true" {
+		if err := h.service.SubmitPartialResults(r.Context(), executionID, &body); err != nil {
+			h.log.WithError(err).Error("Failed to submit partial results")
+			h.writeError(w, http.StatusInternalServerError, "failed to submit results")
+			return
+		}
+		h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+			Success: true,
+		})
+		return
+	}
+
+	if err := h.service.SubmitResults(r.Context(), executionID, &body, expiresAt); err != nil {
+		if errors.Is(err, service.ErrResultsAlreadySubmitted) {
+			h.writeError(w, http.StatusConflict, "results already submitted")
+			return
+		}
+		h.log.WithError(err).Error("Failed to submit results")
+		h.writeError(w, http.StatusInternalServerError, "failed to submit results")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+	})
+}
+
 // SetCondition handles POST /executions/{id}/condition
 func (h *Handler) SetCondition(w http.ResponseWriter, r *http.Request) {
 	executionID := chi.URLParam(r, "id")
@@ -182,6 +683,38 @@ func (h *Handler) SetCondition(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// ReportProgress handles POST /executions/{id}/progress
+func (h *Handler) ReportProgress(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	var body types.ProgressReport
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if body.Percentage < 0 || body.Percentage > 100 {
+		h.writeError(w, http.StatusBadRequest, "percentage must be between 0 and 100")
+		return
+	}
+
+	if err := h.service.ReportProgress(r.Context(), executionID, &body); err != nil {
+		h.log.WithError(err).Error("Failed to report progress")
+		h.writeError(w, http.StatusInternalServerError, "failed to report progress")
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, types.SuccessResponse{
+		Success: true,
+	})
+}
+
 // GetContext handles GET /executions/{id}/context
 func (h *Handler) GetContext(w http.ResponseWriter, r *http.Request) {
 	executionID := chi.URLParam(r, "id")
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/auth"
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	"github.com/sirupsen/logrus"
+)
+
+func TestSubmitResultsRejectsLargeBody(t *testing.T) {
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+	h := NewHandler(nil, log)
+	jwtManager := auth.NewJWTManager(config.AuthConfig{JWTSecret: "secret"})
+
+	r := chi.NewRouter()
+	r.With(middleware.SignedURLMiddleware(jwtManager, log)).Post("/results/{id}", h.SubmitResults)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	target := "/results/exec_1?expires=" + strconv.FormatInt(expires, 10) +
+		"&sig=" + auth.ResultUploadSignature("secret", "exec_1", expires)
+	body := `{"output":"` + strings.Repeat("x", maxResultUploadSize) + `"}`
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
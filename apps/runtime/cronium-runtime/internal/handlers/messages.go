@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
+	"github.com/addison-moore/cronium/apps/runtime/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+)
+
+// messageWriteTimeout bounds each write to a message channel
+const messageWriteTimeout = 10 * time.Second
+
+// Scripts connect from the job container, not from browsers, so the origin
+// is not checked; the execution token authenticates them
+var messageUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// Messages handles GET /executions/{id}/messages, upgrading to a WebSocket
+// on which the script receives push messages as JSON text frames. The
+// script sends nothing; the connection closes when either side goes away.
+func (h *Handler) Messages(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "id")
+
+	// Verify token matches execution
+	claims, _ := middleware.GetTokenClaims(r.Context())
+	if claims.ExecutionID != executionID {
+		h.writeError(w, http.StatusForbidden, "execution ID mismatch")
+		return
+	}
+
+	messages, closeMessages, err := h.service.SubscribeMessages(r.Context(), claims)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManySubscribers) {
+			h.writeError(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		h.log.WithError(err).Error("Failed to subscribe to messages")
+		h.writeError(w, http.StatusInternalServerError, "failed to subscribe to messages")
+		return
+	}
+	defer closeMessages()
+
+	conn, err := messageUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written the error response
+		h.log.WithError(err).Debug("Failed to upgrade message channel")
+		return
+	}
+	defer conn.Close()
+
+	// Read to process pongs and notice the script closing the connection
+	pingInterval := h.service.MessagePingInterval()
+	conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+	})
+	gone := make(chan struct{})
+	go func() {
+		defer close(gone)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gone:
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(messageWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(messageWriteTimeout))
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(messageWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -2,18 +2,22 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/addison-moore/cronium/apps/runtime/internal/auth"
 	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/go-chi/chi/v5"
 	"github.com/sirupsen/logrus"
 )
 
 type contextKey string
 
 const (
-	tokenClaimsKey contextKey = "tokenClaims"
+	tokenClaimsKey      contextKey = "tokenClaims"
+	signedURLExpiresKey contextKey = "signedURLExpires"
 )
 
 // AuthMiddleware handles JWT authentication
@@ -59,6 +63,56 @@ func AuthMiddleware(jwtManager *auth.JWTManager, log *logrus.Logger) func(http.H
 	}
 }
 
+// SignedURLMiddleware authenticates result uploads by the signature and
+// expiry in the query string instead of a bearer token
+func SignedURLMiddleware(jwtManager *auth.JWTManager, log *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			executionID := chi.URLParam(r, "id")
+			query := r.URL.Query()
+
+			expiresAt, err := jwtManager.VerifyResultUpload(executionID, query.Get("expires"), query.Get("sig"))
+			if err != nil {
+				log.WithError(err).WithField("executionID", executionID).Debug("Signed URL validation failed")
+				writeError(w, http.StatusUnauthorized, "invalid or expired upload URL")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), signedURLExpiresKey, expiresAt)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ServiceTokenMiddleware authenticates the backend on internal endpoints by
+// the shared service token. Without a configured token the endpoints are
+// disabled.
+func ServiceTokenMiddleware(token string, log *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				writeError(w, http.StatusNotFound, "internal endpoints are disabled")
+				return
+			}
+
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				log.WithField("path", r.URL.Path).Warn("Rejected internal request with an invalid service token")
+				writeError(w, http.StatusUnauthorized, "invalid service token")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// GetSignedURLExpiry retrieves the expiry of a verified signed URL from context
+func GetSignedURLExpiry(ctx context.Context) (time.Time, bool) {
+	expiresAt, ok := ctx.Value(signedURLExpiresKey).(time.Time)
+	return expiresAt, ok
+}
+
 // GetTokenClaims retrieves token claims from context
 func GetTokenClaims(ctx context.Context) (*types.TokenClaims, bool) {
 	claims, ok := ctx.Value(tokenClaimsKey).(*types.TokenClaims)
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// HelperCallRecorder counts the helper calls of executions
+type HelperCallRecorder interface {
+	RecordHelperCall(executionID, operation string, duration time.Duration, failed bool)
+}
+
+// HelperStatsMiddleware times every call made with an execution token. The
+// operation is named from the method and route pattern; calls answered with
+// a 5xx status count as failed. It must run after AuthMiddleware.
+func HelperStatsMiddleware(recorder HelperCallRecorder, operation func(method, pattern string) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			// Unknown routes have no pattern and are not counted
+			claims, ok := GetTokenClaims(r.Context())
+			rctx := chi.RouteContext(r.Context())
+			if !ok || claims.ExecutionID == "" || rctx == nil || rctx.RoutePattern() == "" {
+				return
+			}
+			recorder.RecordHelperCall(claims.ExecutionID, operation(r.Method, rctx.RoutePattern()), time.Since(start), wrapped.status >= 500)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_http_requests_total",
+			Help: "Total number of HTTP requests by method, route and status",
+		},
+		[]string{"method", "route", "status"},
+	)
+
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cronium_runtime_http_request_duration_seconds",
+			Help:    "HTTP request latency by method and route",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "route"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(httpRequests, httpDuration)
+}
+
+// unmatchedRoute labels requests no route matched, so unknown paths cannot
+// create a series each
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware counts and times HTTP requests by route pattern rather
+// than path, keeping execution IDs and keys out of the labels
+func MetricsMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			wrapped := &responseWriter{
+				ResponseWriter: w,
+				status:         http.StatusOK,
+			}
+
+			next.ServeHTTP(wrapped, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(wrapped.status)).Inc()
+			httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+		})
+	}
+}
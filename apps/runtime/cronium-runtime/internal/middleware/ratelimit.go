@@ -44,6 +44,12 @@ func (rl *RateLimiter) getLimiter(key string) *rate.Limiter {
 	return limiter
 }
 
+// Allow reports whether a request for key, an execution ID or client
+// address, is within the rate limit
+func (rl *RateLimiter) Allow(key string) bool {
+	return rl.getLimiter(key).Allow()
+}
+
 // cleanup removes old limiters
 func (rl *RateLimiter) cleanup() {
 	for {
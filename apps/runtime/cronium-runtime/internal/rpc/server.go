@@ -0,0 +1,505 @@
+// Package rpc serves the helper protocol on a Unix socket: newline-delimited
+// JSON-RPC 2.0 requests, one per line, each answered by one response line.
+// Every request carries the execution token in a "token" member since the
+// socket has no headers to put it in.
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/auth"
+	"github.com/addison-moore/cronium/apps/runtime/internal/credentials"
+	"github.com/addison-moore/cronium/apps/runtime/internal/middleware"
+	"github.com/addison-moore/cronium/apps/runtime/internal/service"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxMessageSize bounds a single request line
+const maxMessageSize = 16 * 1024 * 1024
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+	codeUnauthorized   = -32001
+	codeRateLimited    = -32002
+)
+
+// Request is one JSON-RPC request line
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Token   string          `json:"token,omitempty"`
+}
+
+// Response is one JSON-RPC response line
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error object. The cause of a server error is logged but
+// not sent to the script, as with the HTTP API.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+
+	cause error
+}
+
+// Server serves the helper protocol for the runtime service
+type Server struct {
+	runtime    *service.RuntimeService
+	jwtManager *auth.JWTManager
+	limiter    *middleware.RateLimiter
+	timeout    time.Duration
+	log        *logrus.Logger
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a helper protocol server. timeout bounds each call, and
+// limiter applies the HTTP API's per-execution rate limit.
+func NewServer(runtime *service.RuntimeService, jwtManager *auth.JWTManager, limiter *middleware.RateLimiter, timeout time.Duration, log *logrus.Logger) *Server {
+	return &Server{
+		runtime:    runtime,
+		jwtManager: jwtManager,
+		limiter:    limiter,
+		timeout:    timeout,
+		log:        log,
+		conns:      make(map[net.Conn]struct{}),
+	}
+}
+
+// ListenAndServe listens on the Unix socket at path, replacing a stale
+// socket file, and serves connections until Close is called
+func (s *Server) ListenAndServe(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	// The socket is shared with the job container, which runs as another user;
+	// requests are authenticated by token
+	if err := os.Chmod(path, 0o666); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	s.mu.Lock()
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops accepting connections, closes open ones and waits for their
+// in-flight calls to finish
+func (s *Server) Close() error {
+	s.mu.Lock()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+// serveConn answers requests on one connection in order
+func (s *Server) serveConn(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+		s.wg.Done()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+	encoder := json.NewEncoder(conn)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		if err := encoder.Encode(s.handle(line)); err != nil {
+			s.log.WithError(err).Debug("Failed to write helper response")
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, net.ErrClosed) {
+		s.log.WithError(err).Debug("Failed to read helper request")
+	}
+}
+
+// handle decodes, authenticates and dispatches one request line
+func (s *Server) handle(line []byte) *Response {
+	var req Request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, codeParseError, "parse error")
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, codeInvalidRequest, "invalid request")
+	}
+
+	claims, err := s.jwtManager.ValidateToken(req.Token)
+	if err != nil {
+		s.log.WithError(err).Debug("Helper token validation failed")
+		return errorResponse(req.ID, codeUnauthorized, "invalid or expired token")
+	}
+	if !s.limiter.Allow(claims.ExecutionID) {
+		s.log.WithField("executionId", claims.ExecutionID).Warn("Rate limit exceeded")
+		return errorResponse(req.ID, codeRateLimited, "rate limit exceeded")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result, rpcErr := s.dispatch(ctx, claims, req.Method, req.Params)
+	if rpcErr == nil || rpcErr.Code != codeMethodNotFound {
+		s.runtime.RecordHelperCall(claims.ExecutionID, req.Method, time.Since(start), rpcErr != nil && rpcErr.Code == codeServerError)
+	}
+	if rpcErr != nil {
+		if rpcErr.cause != nil {
+			s.log.WithError(rpcErr.cause).WithFields(logrus.Fields{
+				"executionID": claims.ExecutionID,
+				"method":      req.Method,
+			}).Error("Helper call failed")
+		}
+		return &Response{JSONRPC: "2.0", ID: req.ID, Error: rpcErr}
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return errorResponse(req.ID, codeServerError, "failed to encode result")
+	}
+	return &Response{JSONRPC: "2.0", ID: req.ID, Result: encoded}
+}
+
+// dispatch runs a method for the execution the token belongs to
+func (s *Server) dispatch(ctx context.Context, claims *types.TokenClaims, method string, params json.RawMessage) (interface{}, *Error) {
+	executionID := claims.ExecutionID
+	switch method {
+	case "input":
+		input, err := s.runtime.GetInput(ctx, executionID)
+		if err != nil {
+			return nil, serverError("failed to get input", err)
+		}
+		return input, nil
+
+	case "output":
+		var p struct {
+			Data   interface{}       `json:"data"`
+			Render *types.RenderHint `json:"render"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if err := s.runtime.SetOutput(ctx, executionID, p.Data, p.Render); err != nil {
+			if errors.Is(err, service.ErrInvalidRenderHint) {
+				return nil, &Error{Code: codeInvalidParams, Message: err.Error()}
+			}
+			return nil, serverError("failed to set output", err)
+		}
+		return true, nil
+
+	case "getVariable":
+		var p struct {
+			Key string `json:"key"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		value, err := s.runtime.GetVariable(ctx, executionID, p.Key)
+		if err != nil {
+			return nil, serverError("failed to get variable", err)
+		}
+		return value, nil
+
+	case "setVariable":
+		var p struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		if err := s.runtime.SetVariable(ctx, executionID, p.Key, p.Value); err != nil {
+			return nil, serverError("failed to set variable", err)
+		}
+		return true, nil
+
+	case "incrementVariable":
+		p := struct {
+			Key string   `json:"key"`
+			By  *float64 `json:"by"`
+		}{}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		by := 1.0
+		if p.By != nil {
+			by = *p.By
+		}
+		value, err := s.runtime.IncrementVariable(ctx, executionID, p.Key, by)
+		if err != nil {
+			return nil, atomicError("failed to increment variable", err)
+		}
+		return value, nil
+
+	case "appendVariable":
+		var p struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		items, err := s.runtime.AppendVariable(ctx, executionID, p.Key, p.Value)
+		if err != nil {
+			return nil, atomicError("failed to append to variable", err)
+		}
+		return items, nil
+
+	case "addToSet":
+		var p struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		members, added, err := s.runtime.AddToSetVariable(ctx, executionID, p.Key, p.Value)
+		if err != nil {
+			return nil, atomicError("failed to add to variable", err)
+		}
+		return map[string]interface{}{"members": members, "added": added}, nil
+
+	case "scratchGet":
+		var p struct {
+			Key string `json:"key"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		value, err := s.runtime.GetScratch(ctx, executionID, p.Key)
+		if err != nil {
+			return nil, serverError("failed to get scratch value", err)
+		}
+		return value, nil
+
+	case "scratchSet":
+		var p struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Key == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "key is required"}
+		}
+		if err := s.runtime.SetScratch(ctx, executionID, p.Key, p.Value); err != nil {
+			if errors.Is(err, service.ErrScratchLimit) {
+				return nil, &Error{Code: codeInvalidParams, Message: err.Error()}
+			}
+			return nil, serverError("failed to set scratch value", err)
+		}
+		return true, nil
+
+	case "setCondition":
+		var p struct {
+			Condition bool `json:"condition"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if err := s.runtime.SetCondition(ctx, executionID, p.Condition); err != nil {
+			return nil, serverError("failed to set condition", err)
+		}
+		return true, nil
+
+	case "progress":
+		var p types.ProgressReport
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Percentage < 0 || p.Percentage > 100 {
+			return nil, &Error{Code: codeInvalidParams, Message: "percentage must be between 0 and 100"}
+		}
+		if err := s.runtime.ReportProgress(ctx, executionID, &p); err != nil {
+			return nil, serverError("failed to report progress", err)
+		}
+		return true, nil
+
+	case "event":
+		eventContext, err := s.runtime.GetEventContext(ctx, executionID)
+		if err != nil {
+			return nil, serverError("failed to get context", err)
+		}
+		return eventContext, nil
+
+	case "toolAction":
+		var p types.ToolActionConfig
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		result, err := s.runtime.ExecuteToolAction(ctx, executionID, p)
+		if err != nil {
+			return nil, serverError("failed to execute tool action", err)
+		}
+		return result, nil
+
+	case "submitJob":
+		var p types.JobRequest
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.EventID == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "eventId is required"}
+		}
+		job, err := s.runtime.SubmitJob(ctx, executionID, claims.JobID, p)
+		if err != nil {
+			if errors.Is(err, service.ErrJobLimit) || errors.Is(err, service.ErrJobPending) {
+				return nil, &Error{Code: codeInvalidParams, Message: err.Error()}
+			}
+			return nil, serverError("failed to submit job", err)
+		}
+		return job, nil
+
+	case "getJob":
+		var p struct {
+			JobID string `json:"jobId"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.JobID == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "jobId is required"}
+		}
+		job, err := s.runtime.GetJob(ctx, executionID, p.JobID)
+		if err != nil {
+			if errors.Is(err, service.ErrJobNotFound) {
+				return nil, &Error{Code: codeInvalidParams, Message: err.Error()}
+			}
+			return nil, serverError("failed to get job", err)
+		}
+		return job, nil
+
+	case "getCredential":
+		var p types.CredentialRequest
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.Provider == "" || p.Role == "" {
+			return nil, &Error{Code: codeInvalidParams, Message: "provider and role are required"}
+		}
+		if p.TTL < 0 {
+			return nil, &Error{Code: codeInvalidParams, Message: "ttl must not be negative"}
+		}
+		credential, err := s.runtime.GetCredential(ctx, executionID, p)
+		if err != nil {
+			switch {
+			case errors.Is(err, credentials.ErrUnknownRole):
+				return nil, &Error{Code: codeInvalidParams, Message: err.Error()}
+			case errors.Is(err, credentials.ErrDenied):
+				return nil, &Error{Code: codeUnauthorized, Message: err.Error()}
+			}
+			return nil, serverError("failed to get credential", err)
+		}
+		return credential, nil
+
+	default:
+		return nil, &Error{Code: codeMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// decodeParams unmarshals named params; missing params decode as empty
+func decodeParams(params json.RawMessage, v interface{}) *Error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func serverError(message string, err error) *Error {
+	return &Error{Code: codeServerError, Message: message, cause: err}
+}
+
+// atomicError reports a variable of the wrong type as invalid params, since
+// the script can fix the call; other failures are server errors
+func atomicError(message string, err error) *Error {
+	if errors.Is(err, service.ErrVariableType) {
+		return &Error{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return serverError(message, err)
+}
+
+func errorResponse(id json.RawMessage, code int, message string) *Response {
+	return &Response{JSONRPC: "2.0", ID: id, Error: &Error{Code: code, Message: message}}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// MaxArtifactSize returns the largest file a script may upload
+func (s *RuntimeService) MaxArtifactSize() int64 {
+	return s.config.Storage.MaxArtifactSize
+}
+
+// UploadFile stores a file uploaded by a script and registers it as an
+// execution artifact. A size of -1 means the length is not known up front.
+func (s *RuntimeService) UploadFile(ctx context.Context, executionID, name, mimeType string, r io.Reader, size int64) (*types.Artifact, error) {
+	if s.storage == nil {
+		return nil, fmt.Errorf("artifact storage is not configured")
+	}
+
+	// Get execution context to verify permissions
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	name = filepath.Base(filepath.Clean("/" + name))
+	if name == "/" || name == "." {
+		return nil, fmt.Errorf("invalid file name")
+	}
+
+	id, err := newArtifactID()
+	if err != nil {
+		return nil, err
+	}
+
+	// Peek at the content so the type can be sniffed if the client didn't set one
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+	mimeType = detectMimeType(name, mimeType, head)
+
+	hash := sha256.New()
+	ref, err := s.storage.SaveArtifact(ctx, executionID, id, io.TeeReader(br, hash), size)
+	if err != nil {
+		return nil, err
+	}
+
+	artifact := &types.Artifact{
+		ID:        id,
+		Name:      name,
+		Size:      ref.Size,
+		MimeType:  mimeType,
+		Checksum:  hex.EncodeToString(hash.Sum(nil)),
+		Ref:       ref,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.backend.RegisterArtifact(ctx, executionID, artifact); err != nil {
+		return nil, err
+	}
+
+	// Audit log
+	s.backend.AuditLog(ctx, executionID, "upload_file", map[string]interface{}{
+		"name":   name,
+		"size":   artifact.Size,
+		"userId": execContext.UserID,
+	})
+
+	return artifact, nil
+}
+
+// detectMimeType prefers the declared type, then the file extension, then
+// the content itself
+func detectMimeType(name, declared string, head []byte) string {
+	if declared != "" && !strings.HasPrefix(declared, "application/octet-stream") {
+		return declared
+	}
+	if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+		return byExt
+	}
+	return http.DetectContentType(head)
+}
+
+// newArtifactID returns a random identifier for an artifact
+func newArtifactID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate artifact ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrVariableType is returned when an atomic operation does not fit the
+// variable's value, such as incrementing a string
+var ErrVariableType = errors.New("variable has the wrong type")
+
+// atomicSyncTimeout bounds how long an atomic operation waits to write the
+// variable through to the backend
+const atomicSyncTimeout = 5 * time.Second
+
+// IncrementVariable atomically adds by to a numeric variable and returns the
+// new value. A missing variable counts as zero.
+func (s *RuntimeService) IncrementVariable(ctx context.Context, executionID, key string, by float64) (float64, error) {
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := s.cache.IncrementVariable(ctx, execContext.UserID, key, by, nil)
+	if errors.Is(err, cache.ErrNoSeed) {
+		var stored interface{}
+		stored, err = s.storedVariable(ctx, executionID, execContext.UserID, key)
+		if err != nil {
+			return 0, err
+		}
+		seed, ok := numericValue(stored)
+		if !ok {
+			return 0, fmt.Errorf("%w: %s is not a number", ErrVariableType, key)
+		}
+		value, err = s.cache.IncrementVariable(ctx, execContext.UserID, key, by, &seed)
+	}
+	if errors.Is(err, cache.ErrWrongType) {
+		return 0, fmt.Errorf("%w: %s is not a number", ErrVariableType, key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.syncAtomicVariable(ctx, executionID, execContext.UserID, key)
+	s.notifyVariableChange(ctx, executionID, execContext.UserID, key)
+	s.backend.AuditLog(ctx, executionID, "increment_variable", map[string]interface{}{
+		"key": key,
+		"by":  by,
+	})
+
+	return value, nil
+}
+
+// AppendVariable atomically appends item to a list variable and returns the
+// list. A missing variable counts as an empty list.
+func (s *RuntimeService) AppendVariable(ctx context.Context, executionID, key string, item interface{}) ([]interface{}, error) {
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode item: %w", err)
+	}
+
+	items, err := s.cache.AppendVariable(ctx, execContext.UserID, key, string(encoded), nil)
+	if errors.Is(err, cache.ErrNoSeed) {
+		var seed []string
+		seed, err = s.collectionSeed(ctx, executionID, execContext.UserID, key)
+		if err != nil {
+			return nil, err
+		}
+		items, err = s.cache.AppendVariable(ctx, execContext.UserID, key, string(encoded), seed)
+	}
+	if errors.Is(err, cache.ErrWrongType) {
+		return nil, fmt.Errorf("%w: %s is not a list", ErrVariableType, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.syncAtomicVariable(ctx, executionID, execContext.UserID, key)
+	s.notifyVariableChange(ctx, executionID, execContext.UserID, key)
+	s.backend.AuditLog(ctx, executionID, "append_variable", map[string]interface{}{
+		"key":    key,
+		"length": len(items),
+	})
+
+	return decodeItems(items), nil
+}
+
+// AddToSetVariable atomically adds member to a set variable, stored as a list
+// without duplicates. It returns the members and whether member was new.
+func (s *RuntimeService) AddToSetVariable(ctx context.Context, executionID, key string, member interface{}) ([]interface{}, bool, error) {
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	encoded, err := json.Marshal(member)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode member: %w", err)
+	}
+
+	members, added, err := s.cache.AddToSetVariable(ctx, execContext.UserID, key, string(encoded), nil)
+	if errors.Is(err, cache.ErrNoSeed) {
+		var seed []string
+		seed, err = s.collectionSeed(ctx, executionID, execContext.UserID, key)
+		if err != nil {
+			return nil, false, err
+		}
+		members, added, err = s.cache.AddToSetVariable(ctx, execContext.UserID, key, string(encoded), seed)
+	}
+	if errors.Is(err, cache.ErrWrongType) {
+		return nil, false, fmt.Errorf("%w: %s is not a set", ErrVariableType, key)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if added {
+		s.syncAtomicVariable(ctx, executionID, execContext.UserID, key)
+		s.notifyVariableChange(ctx, executionID, execContext.UserID, key)
+	}
+	s.backend.AuditLog(ctx, executionID, "add_to_set_variable", map[string]interface{}{
+		"key":   key,
+		"added": added,
+	})
+
+	return decodeItems(members), added, nil
+}
+
+// storedVariable loads a variable's value from the backend to seed an atomic
+// operation. Values stored as JSON text are decoded; a missing variable is nil.
+func (s *RuntimeService) storedVariable(ctx context.Context, executionID, userID, key string) (interface{}, error) {
+	variable, err := s.backend.GetVariable(ctx, executionID, userID, key)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variable: %w", err)
+	}
+	if variable == nil {
+		return nil, nil
+	}
+	// Atomic values live in the cache in plaintext
+	if variable.Sensitive {
+		return nil, fmt.Errorf("%w: %s is sensitive and cannot be changed atomically", ErrVariableType, key)
+	}
+
+	if text, ok := variable.Value.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+			return decoded, nil
+		}
+	}
+	return variable.Value, nil
+}
+
+// collectionSeed loads a list or set variable's stored items, encoded. A
+// missing variable seeds an empty collection.
+func (s *RuntimeService) collectionSeed(ctx context.Context, executionID, userID, key string) ([]string, error) {
+	stored, err := s.storedVariable(ctx, executionID, userID, key)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return []string{}, nil
+	}
+
+	list, ok := stored.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not a list", ErrVariableType, key)
+	}
+	seed := make([]string, 0, len(list))
+	for _, item := range list {
+		encoded, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode item: %w", err)
+		}
+		seed = append(seed, string(encoded))
+	}
+	return seed, nil
+}
+
+// syncAtomicVariable writes an atomic variable's current value through to the
+// backend and the execution's variable cache. Writers take turns and each
+// writes the latest value, so concurrent operations cannot leave an older one
+// behind. A failed write is only logged: the operation has been applied, and
+// the next one writes the value again.
+func (s *RuntimeService) syncAtomicVariable(ctx context.Context, executionID, userID, key string) {
+	log := s.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"key":         key,
+	})
+
+	lockKey := fmt.Sprintf("atomic:%s:%s", userID, key)
+	deadline := time.Now().Add(atomicSyncTimeout)
+	for {
+		locked, err := s.cache.Lock(ctx, lockKey, atomicSyncTimeout)
+		if err != nil {
+			log.WithError(err).Error("Failed to lock atomic variable")
+			return
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			log.Error("Timed out waiting to store atomic variable")
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	defer s.cache.Unlock(ctx, lockKey)
+
+	current, ok, err := s.cache.AtomicVariable(ctx, userID, key)
+	if err != nil || !ok {
+		if err != nil {
+			log.WithError(err).Error("Failed to read atomic variable")
+		}
+		return
+	}
+
+	value := current
+	if items, isList := current.([]string); isList {
+		value = decodeItems(items)
+	}
+
+	// Variables are stored as text, so lists keep their JSON form
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode atomic variable")
+		return
+	}
+	if err := s.backend.SetVariable(ctx, executionID, userID, key, string(encoded)); err != nil {
+		log.WithError(err).Error("Failed to store atomic variable")
+		return
+	}
+
+	variable := &types.Variable{
+		Key:       key,
+		Value:     value,
+		UpdatedAt: time.Now(),
+	}
+	if err := s.cache.SetVariable(ctx, executionID, key, variable); err != nil {
+		log.WithError(err).Error("Failed to cache variable")
+	}
+}
+
+// numericValue converts a stored variable to a counter; nil is zero
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case nil:
+		return 0, true
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// decodeItems decodes the JSON-encoded items of a list or set
+func decodeItems(items []string) []interface{} {
+	decoded := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		var v interface{}
+		if err := json.Unmarshal([]byte(item), &v); err != nil {
+			v = item
+		}
+		decoded = append(decoded, v)
+	}
+	return decoded
+}
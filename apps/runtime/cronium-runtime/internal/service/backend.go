@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/runtime/internal/config"
@@ -14,6 +16,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// ErrNotFound is wrapped by errors for requests the backend answered with 404
+var ErrNotFound = errors.New("not found")
+
 // BackendClient handles communication with the Cronium backend
 type BackendClient struct {
 	config     config.BackendConfig
@@ -90,14 +95,18 @@ func (c *BackendClient) GetExecutionContext(ctx context.Context, executionID str
 	return &context, nil
 }
 
-// SaveOutput saves execution output to the backend
-func (c *BackendClient) SaveOutput(ctx context.Context, executionID string, output interface{}) error {
+// SaveOutput saves execution output and its rendering hint, if any, to the
+// backend
+func (c *BackendClient) SaveOutput(ctx context.Context, executionID string, output interface{}, render *types.RenderHint) error {
 	url := fmt.Sprintf("%s/api/internal/executions/%s/output", c.config.URL, executionID)
 	
 	body := map[string]interface{}{
 		"output": output,
 		"timestamp": time.Now(),
 	}
+	if render != nil {
+		body["render"] = render
+	}
 	
 	req, err := c.newRequest(ctx, "POST", url, body)
 	if err != nil {
@@ -111,6 +120,92 @@ func (c *BackendClient) SaveOutput(ctx context.Context, executionID string, outp
 	return nil
 }
 
+// SaveOutputStream saves an already-encoded JSON output to the backend,
+// streaming it from r instead of holding it in memory. The body cannot be
+// replayed, so the request is attempted only once.
+func (c *BackendClient) SaveOutputStream(ctx context.Context, executionID string, r io.Reader, render *types.RenderHint) error {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/output", c.config.URL, executionID)
+
+	timestamp, err := json.Marshal(time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to marshal timestamp: %w", err)
+	}
+	trailer := `,"timestamp":` + string(timestamp)
+	if render != nil {
+		hint, err := json.Marshal(render)
+		if err != nil {
+			return fmt.Errorf("failed to marshal render hint: %w", err)
+		}
+		trailer += `,"render":` + string(hint)
+	}
+	body := io.MultiReader(
+		strings.NewReader(`{"output":`),
+		r,
+		strings.NewReader(trailer+`}`),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		recordBackendError(req.Method, backendTransport)
+		return fmt.Errorf("failed to save output: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		class := backendClient
+		if resp.StatusCode >= 500 {
+			class = backendServer
+		}
+		recordBackendError(req.Method, class)
+		return fmt.Errorf("failed to save output: backend error: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// SaveResults sends a batch of partial results, or marks the results final
+func (c *BackendClient) SaveResults(ctx context.Context, executionID string, batch *types.ResultSync) error {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/results", c.config.URL, executionID)
+
+	req, err := c.newRequest(ctx, "POST", url, batch)
+	if err != nil {
+		return err
+	}
+
+	if err := c.doRequest(req, nil); err != nil {
+		return fmt.Errorf("failed to save results: %w", err)
+	}
+
+	return nil
+}
+
+// RegisterArtifact records an uploaded file against an execution
+func (c *BackendClient) RegisterArtifact(ctx context.Context, executionID string, artifact *types.Artifact) error {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/artifacts", c.config.URL, executionID)
+
+	req, err := c.newRequest(ctx, "POST", url, artifact)
+	if err != nil {
+		return err
+	}
+
+	if err := c.doRequest(req, nil); err != nil {
+		return fmt.Errorf("failed to register artifact: %w", err)
+	}
+
+	return nil
+}
+
 // SaveCondition saves workflow condition result to the backend
 func (c *BackendClient) SaveCondition(ctx context.Context, executionID string, condition bool) error {
 	url := fmt.Sprintf("%s/api/internal/executions/%s/condition", c.config.URL, executionID)
@@ -132,6 +227,45 @@ func (c *BackendClient) SaveCondition(ctx context.Context, executionID string, c
 	return nil
 }
 
+// SaveProgress sends a script's progress report to the backend
+func (c *BackendClient) SaveProgress(ctx context.Context, executionID string, progress *types.ProgressReport) error {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/progress", c.config.URL, executionID)
+
+	body := map[string]interface{}{
+		"percentage": progress.Percentage,
+		"message":    progress.Message,
+		"timestamp":  time.Now(),
+	}
+
+	req, err := c.newRequest(ctx, "POST", url, body)
+	if err != nil {
+		return err
+	}
+
+	if err := c.doRequest(req, nil); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	return nil
+}
+
+// SaveHelperStats attaches the summary of an execution's helper calls to the
+// execution record
+func (c *BackendClient) SaveHelperStats(ctx context.Context, executionID string, stats *types.HelperStats) error {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/helper-stats", c.config.URL, executionID)
+
+	req, err := c.newRequest(ctx, "POST", url, stats)
+	if err != nil {
+		return err
+	}
+
+	if err := c.doRequest(req, nil); err != nil {
+		return fmt.Errorf("failed to save helper stats: %w", err)
+	}
+
+	return nil
+}
+
 // ExecuteToolAction executes a tool action via the backend
 func (c *BackendClient) ExecuteToolAction(ctx context.Context, executionID, userID string, config types.ToolActionConfig) (*types.ToolActionResult, error) {
 	url := fmt.Sprintf("%s/api/internal/tools/execute", c.config.URL)
@@ -157,6 +291,41 @@ func (c *BackendClient) ExecuteToolAction(ctx context.Context, executionID, user
 	return &result, nil
 }
 
+// SubmitJob queues a child job of an execution. The idempotency key lets
+// the backend answer a retried request with the job it already created.
+func (c *BackendClient) SubmitJob(ctx context.Context, executionID string, submission *jobSubmission) (*types.Job, error) {
+	url := fmt.Sprintf("%s/api/internal/executions/%s/jobs", c.config.URL, executionID)
+
+	req, err := c.newRequest(ctx, "POST", url, submission)
+	if err != nil {
+		return nil, err
+	}
+
+	var job types.Job
+	if err := c.doRequest(req, &job); err != nil {
+		return nil, fmt.Errorf("failed to submit job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// GetJob returns a job with its status, and its output once it has finished
+func (c *BackendClient) GetJob(ctx context.Context, jobID string) (*types.Job, error) {
+	url := fmt.Sprintf("%s/api/internal/jobs/%s", c.config.URL, jobID)
+
+	req, err := c.newRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var job types.Job
+	if err := c.doRequest(req, &job); err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+
+	return &job, nil
+}
+
 // AuditLog sends an audit log entry to the backend
 func (c *BackendClient) AuditLog(ctx context.Context, executionID, action string, metadata map[string]interface{}) error {
 	url := fmt.Sprintf("%s/api/internal/audit", c.config.URL)
@@ -223,6 +392,7 @@ func (c *BackendClient) doRequest(req *http.Request, result interface{}) error {
 		
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
+			recordBackendError(req.Method, backendTransport)
 			lastErr = err
 			continue
 		}
@@ -232,6 +402,7 @@ func (c *BackendClient) doRequest(req *http.Request, result interface{}) error {
 		// Read response body
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
+			recordBackendError(req.Method, backendTransport)
 			lastErr = fmt.Errorf("failed to read response: %w", err)
 			continue
 		}
@@ -245,10 +416,16 @@ func (c *BackendClient) doRequest(req *http.Request, result interface{}) error {
 				lastErr = fmt.Errorf("backend error: %s", resp.Status)
 			}
 			
+			if resp.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%w: %v", ErrNotFound, lastErr)
+			}
+
 			// Don't retry client errors
 			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+				recordBackendError(req.Method, backendClient)
 				return lastErr
 			}
+			recordBackendError(req.Method, backendServer)
 			
 			continue
 		}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/credentials"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// WithCredentials sets the broker that mints short-lived credentials for
+// scripts. Without one every credential request is refused.
+func (s *RuntimeService) WithCredentials(broker *credentials.Broker) *RuntimeService {
+	s.credentials = broker
+	return s
+}
+
+// GetCredential mints short-lived credentials for a role, scoped to the
+// execution's user. Only the request is audited, never the credentials.
+func (s *RuntimeService) GetCredential(ctx context.Context, executionID string, req types.CredentialRequest) (*types.Credential, error) {
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.credentials.Mint(ctx, executionID, execContext.UserID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.backend.AuditLog(ctx, executionID, "get_credential", map[string]interface{}{
+		"provider":  req.Provider,
+		"role":      req.Role,
+		"expiresAt": credential.ExpiresAt,
+	})
+	return credential, nil
+}
+
+// RevokeCredentials revokes the credentials an execution still holds, for
+// when it has finished
+func (s *RuntimeService) RevokeCredentials(ctx context.Context, executionID string) (int, error) {
+	revoked, err := s.credentials.RevokeExecution(ctx, executionID)
+	if revoked > 0 {
+		s.log.WithFields(logrus.Fields{
+			"executionId": executionID,
+			"revoked":     revoked,
+		}).Info("Revoked execution credentials")
+	}
+	return revoked, err
+}
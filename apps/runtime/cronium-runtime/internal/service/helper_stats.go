@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// maxLatencySamples bounds the latencies kept per operation; beyond it the
+// percentiles come from a uniform sample of the calls
+const maxLatencySamples = 1000
+
+// helperStats counts the helper calls of running executions and sends a
+// summary of each execution's calls to the backend when it has changed
+type helperStats struct {
+	backend  *BackendClient
+	interval time.Duration
+	log      *logrus.Logger
+
+	mu         sync.Mutex
+	executions map[string]*executionCalls
+}
+
+// executionCalls are the helper calls of one execution by operation
+type executionCalls struct {
+	operations map[string]*operationCalls
+	changed    bool
+	lastCall   time.Time
+}
+
+type operationCalls struct {
+	calls   int
+	errors  int
+	total   time.Duration
+	samples []time.Duration
+}
+
+func newHelperStats(backend *BackendClient, interval time.Duration, log *logrus.Logger) *helperStats {
+	return &helperStats{
+		backend:    backend,
+		interval:   interval,
+		log:        log,
+		executions: make(map[string]*executionCalls),
+	}
+}
+
+// record counts one helper call
+func (h *helperStats) record(executionID, operation string, duration time.Duration, failed bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e, ok := h.executions[executionID]
+	if !ok {
+		e = &executionCalls{operations: make(map[string]*operationCalls)}
+		h.executions[executionID] = e
+		activeExecutions.Set(float64(len(h.executions)))
+	}
+	op, ok := e.operations[operation]
+	if !ok {
+		op = &operationCalls{}
+		e.operations[operation] = op
+	}
+
+	op.calls++
+	op.total += duration
+	if failed {
+		op.errors++
+	}
+	if len(op.samples) < maxLatencySamples {
+		op.samples = append(op.samples, duration)
+	} else if i := rand.IntN(op.calls); i < maxLatencySamples {
+		op.samples[i] = duration
+	}
+	e.changed = true
+	e.lastCall = time.Now()
+}
+
+// summary returns the summary of an execution's calls; h.mu must be held
+func (e *executionCalls) summary() *types.HelperStats {
+	stats := &types.HelperStats{
+		Operations: make([]types.HelperCallStats, 0, len(e.operations)),
+		UpdatedAt:  time.Now(),
+	}
+	for name, op := range e.operations {
+		samples := append([]time.Duration(nil), op.samples...)
+		sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+		stats.Calls += op.calls
+		stats.Errors += op.errors
+		stats.TotalMs += milliseconds(op.total)
+		stats.Operations = append(stats.Operations, types.HelperCallStats{
+			Operation: name,
+			Calls:     op.calls,
+			Errors:    op.errors,
+			TotalMs:   milliseconds(op.total),
+			P50Ms:     milliseconds(percentile(samples, 0.50)),
+			P95Ms:     milliseconds(percentile(samples, 0.95)),
+		})
+	}
+	sort.Slice(stats.Operations, func(i, j int) bool {
+		return stats.Operations[i].TotalMs > stats.Operations[j].TotalMs
+	})
+	return stats
+}
+
+// flushAll sends the summary of every execution with new calls and forgets
+// executions that have gone quiet
+func (h *helperStats) flushAll(ctx context.Context) {
+	h.mu.Lock()
+	summaries := make(map[string]*types.HelperStats)
+	for executionID, e := range h.executions {
+		if e.changed {
+			summaries[executionID] = e.summary()
+			e.changed = false
+		} else if time.Since(e.lastCall) > syncIdleTimeout {
+			delete(h.executions, executionID)
+		}
+	}
+	activeExecutions.Set(float64(len(h.executions)))
+	h.mu.Unlock()
+
+	for executionID, stats := range summaries {
+		if err := h.backend.SaveHelperStats(ctx, executionID, stats); err != nil {
+			h.log.WithError(err).WithField("executionId", executionID).Warn("Failed to send helper stats")
+			h.markChanged(executionID)
+		}
+	}
+}
+
+// markChanged makes the next flush send an execution's summary again
+func (h *helperStats) markChanged(executionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if e, ok := h.executions[executionID]; ok {
+		e.changed = true
+	}
+}
+
+// run flushes on every interval until ctx is done, then flushes once more
+func (h *helperStats) run(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), syncFlushTimeout)
+			h.flushAll(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			h.flushAll(ctx)
+		}
+	}
+}
+
+// percentile returns the p-th percentile of sorted samples by nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// RecordHelperCall counts a helper call an execution made, for the summary
+// attached to its execution record
+func (s *RuntimeService) RecordHelperCall(executionID, operation string, duration time.Duration, failed bool) {
+	s.stats.record(executionID, operation, duration, failed)
+}
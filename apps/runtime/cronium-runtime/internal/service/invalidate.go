@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrUnknownCacheType is returned when an invalidation names a type of
+// object the runtime does not cache
+var ErrUnknownCacheType = errors.New("unknown cache type")
+
+// InvalidateExecutionCache drops an execution's cached objects of the given
+// types (context, input, output, variable), or all of them when kinds is
+// empty, so the next read loads them from the backend. The backend calls it
+// when it changes data a running execution may have cached.
+func (s *RuntimeService) InvalidateExecutionCache(ctx context.Context, executionID string, kinds []string) (int, error) {
+	for _, kind := range kinds {
+		if !slices.Contains(cache.CachedTypes, kind) {
+			return 0, fmt.Errorf("%w: %s", ErrUnknownCacheType, kind)
+		}
+	}
+
+	removed, err := s.cache.InvalidateExecution(ctx, executionID, kinds...)
+	if err != nil {
+		return removed, err
+	}
+	s.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"types":       kinds,
+		"removed":     removed,
+	}).Debug("Invalidated execution cache")
+	return removed, nil
+}
+
+// InvalidateVariableCache drops every cached copy of a user's variable, for
+// when it is changed outside the runtime, and tells the user's subscribed
+// scripts about the change
+func (s *RuntimeService) InvalidateVariableCache(ctx context.Context, userID, key string) (int, error) {
+	removed, err := s.cache.InvalidateVariable(ctx, userID, key)
+	if err != nil {
+		return removed, err
+	}
+	s.notifyVariableChange(ctx, "", userID, key)
+	s.log.WithFields(logrus.Fields{
+		"userId":  userID,
+		"key":     key,
+		"removed": removed,
+	}).Debug("Invalidated variable cache")
+	return removed, nil
+}
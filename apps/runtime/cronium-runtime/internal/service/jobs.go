@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrJobLimit is returned when an execution may not submit another child
+	// job, because it has submitted too many or is nested too deeply
+	ErrJobLimit = errors.New("child job limit exceeded")
+
+	// ErrJobPending is returned while an earlier submission with the same
+	// idempotency key is still being created
+	ErrJobPending = errors.New("child job submission in progress")
+
+	// ErrJobNotFound is returned for a job that is not a child of the
+	// execution
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// Metadata keys that link a child job to the executions above it
+const (
+	metaParentExecutionID = "parentExecutionId"
+	metaRootExecutionID   = "rootExecutionId"
+	metaParentJobID       = "parentJobId"
+	metaRootJobID         = "rootJobId"
+	metaDepth             = "depth"
+)
+
+// jobSubmission is the child job request sent to the backend
+type jobSubmission struct {
+	EventID           string                 `json:"eventId"`
+	UserID            string                 `json:"userId"`
+	ParentExecutionID string                 `json:"parentExecutionId"`
+	ParentJobID       string                 `json:"parentJobId,omitempty"`
+	ParentEventID     string                 `json:"parentEventId"`
+	RootExecutionID   string                 `json:"rootExecutionId"`
+	RootJobID         string                 `json:"rootJobId,omitempty"`
+	Depth             int                    `json:"depth"`
+	Input             interface{}            `json:"input,omitempty"`
+	Metadata          map[string]interface{} `json:"metadata"`
+	IdempotencyKey    string                 `json:"idempotencyKey"`
+}
+
+// SubmitJob queues a child job of an execution, which runs the job with
+// jobID. The child runs as the execution's user, inherits its metadata under
+// the request's own and is linked to it and to the root of its tree.
+// Submitting again with the same idempotency key returns the job already
+// created.
+func (s *RuntimeService) SubmitJob(ctx context.Context, executionID, jobID string, req types.JobRequest) (*types.Job, error) {
+	execContext, err := s.getExecutionContext(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := s.config.Jobs
+	depth := metadataDepth(execContext.Metadata) + 1
+	if depth > limits.MaxDepth {
+		return nil, fmt.Errorf("%w: child jobs may be nested %d deep", ErrJobLimit, limits.MaxDepth)
+	}
+
+	submissionKey := req.IdempotencyKey
+	if submissionKey == "" {
+		if submissionKey, err = newSubmissionKey(); err != nil {
+			return nil, err
+		}
+	}
+	childID, err := s.cache.ReserveChildJob(ctx, executionID, submissionKey, limits.MaxChildren, limits.TTL)
+	switch {
+	case errors.Is(err, cache.ErrChildJobsFull):
+		return nil, fmt.Errorf("%w: an execution may submit %d child jobs", ErrJobLimit, limits.MaxChildren)
+	case errors.Is(err, cache.ErrChildJobPending):
+		return nil, ErrJobPending
+	case err != nil:
+		return nil, err
+	case childID != "":
+		return s.GetJob(ctx, executionID, childID)
+	}
+
+	rootExecutionID := executionID
+	if root, ok := execContext.Metadata[metaRootExecutionID].(string); ok && root != "" {
+		rootExecutionID = root
+	}
+	rootJobID := jobID
+	if root, ok := execContext.Metadata[metaRootJobID].(string); ok && root != "" {
+		rootJobID = root
+	}
+	metadata := make(map[string]interface{}, len(execContext.Metadata)+len(req.Metadata))
+	for k, v := range execContext.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	metadata[metaParentExecutionID] = executionID
+	metadata[metaRootExecutionID] = rootExecutionID
+	metadata[metaDepth] = depth
+	if jobID != "" {
+		metadata[metaParentJobID] = jobID
+		metadata[metaRootJobID] = rootJobID
+	}
+
+	job, err := s.backend.SubmitJob(ctx, executionID, &jobSubmission{
+		EventID:           req.EventID,
+		UserID:            execContext.UserID,
+		ParentExecutionID: executionID,
+		ParentJobID:       jobID,
+		ParentEventID:     execContext.EventID,
+		RootExecutionID:   rootExecutionID,
+		RootJobID:         rootJobID,
+		Depth:             depth,
+		Input:             req.Input,
+		Metadata:          metadata,
+		IdempotencyKey:    executionID + ":" + submissionKey,
+	})
+	if err != nil {
+		if releaseErr := s.cache.ReleaseChildJob(ctx, executionID, submissionKey); releaseErr != nil {
+			s.log.WithError(releaseErr).WithField("executionId", executionID).Warn("Failed to release child job reservation")
+		}
+		return nil, err
+	}
+
+	if err := s.cache.SetChildJob(ctx, executionID, submissionKey, job.ID); err != nil {
+		// The job exists; only a repeated submission may create another
+		s.log.WithError(err).WithField("executionId", executionID).Warn("Failed to record child job")
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"jobId":       job.ID,
+		"eventId":     req.EventID,
+		"depth":       depth,
+	}).Info("Submitted child job")
+
+	s.backend.AuditLog(ctx, executionID, "submit_job", map[string]interface{}{
+		"jobId":   job.ID,
+		"eventId": req.EventID,
+		"depth":   depth,
+	})
+	return job, nil
+}
+
+// GetJob returns a child job of an execution with its status. Jobs of other
+// executions are reported as not found.
+func (s *RuntimeService) GetJob(ctx context.Context, executionID, jobID string) (*types.Job, error) {
+	job, err := s.backend.GetJob(ctx, jobID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if job.ParentExecutionID != executionID {
+		return nil, ErrJobNotFound
+	}
+	return job, nil
+}
+
+// newSubmissionKey returns a random key for a submission without an
+// idempotency key
+func newSubmissionKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate submission key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// metadataDepth returns how deep an execution is nested below the root of
+// its job tree; a top-level execution has depth 0
+func metadataDepth(metadata map[string]interface{}) int {
+	switch depth := metadata[metaDepth].(type) {
+	case float64:
+		return int(depth)
+	case int:
+		return depth
+	}
+	return 0
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// ErrTooManySubscribers is returned when an execution already has as many
+// message channels open as it may
+var ErrTooManySubscribers = errors.New("too many open message channels")
+
+// subscriberLimit counts the message channels open per execution
+type subscriberLimit struct {
+	mu    sync.Mutex
+	open  map[string]int
+	limit int
+}
+
+func (l *subscriberLimit) acquire(executionID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[executionID] >= l.limit {
+		return false
+	}
+	l.open[executionID]++
+	return true
+}
+
+func (l *subscriberLimit) release(executionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.open[executionID]--; l.open[executionID] <= 0 {
+		delete(l.open, executionID)
+	}
+}
+
+// SubscribeMessages opens the push message channel of the token's
+// execution: messages for its job and changes to its user's variables made
+// elsewhere. Messages arrive until ctx is done; close must be called to
+// release the channel.
+func (s *RuntimeService) SubscribeMessages(ctx context.Context, claims *types.TokenClaims) (<-chan types.PushMessage, func(), error) {
+	executionID := claims.ExecutionID
+	// Container tokens name the job only as the execution
+	jobID := claims.JobID
+	if jobID == "" {
+		jobID = executionID
+	}
+	userID := claims.UserID
+	if userID == "" {
+		execContext, err := s.getExecutionContext(ctx, executionID)
+		if err != nil {
+			return nil, nil, err
+		}
+		userID = execContext.UserID
+	}
+
+	if !s.subscribers.acquire(executionID) {
+		return nil, nil, ErrTooManySubscribers
+	}
+
+	channels := []string{cache.JobMessageChannel(jobID)}
+	if userID != "" {
+		channels = append(channels, cache.UserMessageChannel(userID))
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	in, unsubscribe, err := s.cache.SubscribeMessages(ctx, channels...)
+	if err != nil {
+		cancel()
+		s.subscribers.release(executionID)
+		return nil, nil, err
+	}
+
+	out := make(chan types.PushMessage)
+	go func() {
+		defer close(out)
+		for msg := range in {
+			// The script already knows about its own writes
+			if msg.Type == types.MessageVariable && msg.Source == executionID {
+				continue
+			}
+			select {
+			case out <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	closeFn := func() {
+		once.Do(func() {
+			cancel()
+			unsubscribe()
+			s.subscribers.release(executionID)
+		})
+	}
+	return out, closeFn, nil
+}
+
+// notifyVariableChange tells the user's subscribed scripts that a variable
+// changed. Only the key is sent, so sensitive values never leave through
+// the channel. It is best effort: a missed notice only means a script reads
+// the value later than it could have.
+func (s *RuntimeService) notifyVariableChange(ctx context.Context, executionID, userID, key string) {
+	if userID == "" {
+		return
+	}
+	_, err := s.cache.PublishMessage(ctx, cache.UserMessageChannel(userID), types.PushMessage{
+		Type:      types.MessageVariable,
+		Key:       key,
+		Source:    executionID,
+		Timestamp: time.Now(),
+	})
+	if err != nil {
+		s.log.WithError(err).WithField("key", key).Debug("Failed to publish variable change")
+	}
+}
+
+// MessagePingInterval returns how often idle message channels are pinged
+func (s *RuntimeService) MessagePingInterval() time.Duration {
+	return s.config.Messages.PingInterval
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	backendErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cronium_runtime_backend_errors_total",
+			Help: "Total number of failed backend calls by method and class (transport, server, client)",
+		},
+		[]string{"method", "class"},
+	)
+
+	activeExecutions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cronium_runtime_active_executions",
+			Help: "Number of executions that made helper calls and have not gone quiet",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(backendErrors, activeExecutions)
+}
+
+// Classes of failed backend calls
+const (
+	backendTransport = "transport" // no response, or it could not be read
+	backendServer    = "server"    // 5xx response
+	backendClient    = "client"    // 4xx response other than not found
+)
+
+// recordBackendError counts a failed backend call attempt
+func recordBackendError(method, class string) {
+	backendErrors.WithLabelValues(method, class).Inc()
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// ErrInvalidRenderHint is returned when an output's rendering hint is
+// unknown or does not fit the output
+var ErrInvalidRenderHint = errors.New("invalid render hint")
+
+// ValidateRenderHint checks that an output can be rendered as hinted. A nil
+// hint leaves rendering to the front-end.
+func ValidateRenderHint(hint *types.RenderHint, data interface{}) error {
+	if hint == nil {
+		return nil
+	}
+	if len(hint.Columns) > 0 && hint.Type != types.RenderTable {
+		return fmt.Errorf("%w: columns only apply to tables", ErrInvalidRenderHint)
+	}
+	if (hint.Label != "" || hint.Unit != "") && hint.Type != types.RenderMetric {
+		return fmt.Errorf("%w: label and unit only apply to metrics", ErrInvalidRenderHint)
+	}
+
+	switch hint.Type {
+	case types.RenderJSON:
+		return nil
+	case types.RenderMarkdown:
+		if _, ok := data.(string); !ok {
+			return fmt.Errorf("%w: markdown output must be a string", ErrInvalidRenderHint)
+		}
+		return nil
+	case types.RenderMetric:
+		if _, ok := data.(float64); ok {
+			return nil
+		}
+		if m, ok := data.(map[string]interface{}); ok {
+			if _, ok := m["value"].(float64); ok {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: metric output must be a number or an object with a numeric value", ErrInvalidRenderHint)
+	case types.RenderTable:
+		return validateTable(hint.Columns, data)
+	}
+	return fmt.Errorf("%w: unknown type %q", ErrInvalidRenderHint, hint.Type)
+}
+
+// validateTable checks that a table is a list of rows of one kind, either
+// objects or lists of cells, with no more cells than columns
+func validateTable(columns []string, data interface{}) error {
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if column == "" || seen[column] {
+			return fmt.Errorf("%w: table columns must be unique and not empty", ErrInvalidRenderHint)
+		}
+		seen[column] = true
+	}
+
+	rows, ok := data.([]interface{})
+	if !ok {
+		return fmt.Errorf("%w: table output must be a list of rows", ErrInvalidRenderHint)
+	}
+	var objects, lists int
+	for _, row := range rows {
+		switch r := row.(type) {
+		case map[string]interface{}:
+			objects++
+		case []interface{}:
+			lists++
+			if len(columns) > 0 && len(r) > len(columns) {
+				return fmt.Errorf("%w: a table row has more cells than columns", ErrInvalidRenderHint)
+			}
+		default:
+			return fmt.Errorf("%w: table rows must be objects or lists", ErrInvalidRenderHint)
+		}
+	}
+	if objects > 0 && lists > 0 {
+		return fmt.Errorf("%w: table rows must all be objects or all be lists", ErrInvalidRenderHint)
+	}
+	return nil
+}
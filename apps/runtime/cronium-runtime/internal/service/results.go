@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// ErrResultsAlreadySubmitted is returned when a result upload URL is reused
+var ErrResultsAlreadySubmitted = errors.New("results already submitted")
+
+// SubmitResults stores the output and variables uploaded by a runner through
+// a signed URL. Each URL may be used once; the claim is held until the URL
+// expires and is released again if storing the results fails.
+func (s *RuntimeService) SubmitResults(ctx context.Context, executionID string, results *types.ResultUpload, expiresAt time.Time) error {
+	lockKey := "result-upload:" + executionID
+	ok, err := s.cache.Lock(ctx, lockKey, time.Until(expiresAt))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrResultsAlreadySubmitted
+	}
+
+	if err := s.storeResults(ctx, executionID, results); err != nil {
+		if unlockErr := s.cache.Unlock(ctx, lockKey); unlockErr != nil {
+			s.log.WithError(unlockErr).Warn("Failed to release result upload claim")
+		}
+		return err
+	}
+
+	// Partial results still pending are older than the final ones; tell the
+	// backend to replace whatever partial results it has
+	final := &types.ResultSync{
+		Sequence:  s.sync.finish(executionID),
+		Partial:   false,
+		Timestamp: time.Now(),
+	}
+	if err := s.backend.SaveResults(ctx, executionID, final); err != nil {
+		s.log.WithError(err).WithField("executionId", executionID).Warn("Failed to mark results final")
+	}
+
+	// The script has finished, so its credentials are no longer needed
+	if _, err := s.RevokeCredentials(ctx, executionID); err != nil {
+		s.log.WithError(err).WithField("executionId", executionID).Warn("Failed to revoke execution credentials")
+	}
+
+	s.backend.AuditLog(ctx, executionID, "submit_results", map[string]interface{}{
+		"hasOutput": results.Output != nil,
+		"variables": len(results.Variables),
+	})
+
+	return nil
+}
+
+// storeResults writes uploaded results through the regular output and
+// variable paths
+func (s *RuntimeService) storeResults(ctx context.Context, executionID string, results *types.ResultUpload) error {
+	if results.Output != nil {
+		if err := s.SetOutput(ctx, executionID, results.Output, results.Render); err != nil {
+			return fmt.Errorf("failed to store output: %w", err)
+		}
+	}
+
+	for key, value := range results.Variables {
+		if err := s.SetVariable(ctx, executionID, key, value); err != nil {
+			return fmt.Errorf("failed to store variable %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
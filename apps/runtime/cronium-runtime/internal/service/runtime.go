@@ -2,39 +2,65 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
 	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
 	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/credentials"
+	"github.com/addison-moore/cronium/apps/runtime/internal/envelope"
+	"github.com/addison-moore/cronium/apps/runtime/internal/storage"
+	"github.com/addison-moore/cronium/apps/runtime/internal/tools"
 	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
 	"github.com/sirupsen/logrus"
 )
 
 // RuntimeService implements the runtime API logic
 type RuntimeService struct {
-	backend *BackendClient
-	cache   *cache.ValkeyClient
-	config  *config.Config
-	log     *logrus.Logger
+	backend     *BackendClient
+	cache       *cache.ValkeyClient
+	storage     *storage.Manager
+	tools       *tools.Registry
+	keyring     *envelope.Keyring
+	credentials *credentials.Broker
+	sync        *resultSyncer
+	stats       *helperStats
+	subscribers *subscriberLimit
+	config      *config.Config
+	log         *logrus.Logger
 }
 
 // NewRuntimeService creates a new runtime service
-func NewRuntimeService(backend *BackendClient, cache *cache.ValkeyClient, config *config.Config, log *logrus.Logger) *RuntimeService {
+func NewRuntimeService(backend *BackendClient, cache *cache.ValkeyClient, storage *storage.Manager, config *config.Config, log *logrus.Logger) *RuntimeService {
 	return &RuntimeService{
 		backend: backend,
 		cache:   cache,
-		config:  config,
-		log:     log,
+		storage: storage,
+		sync:    newResultSyncer(backend, config.Sync.Interval, config.Sync.MaxPendingBytes, log),
+		stats:   newHelperStats(backend, config.Sync.Interval, log),
+		subscribers: &subscriberLimit{
+			open:  make(map[string]int),
+			limit: config.Messages.MaxConnections,
+		},
+		config: config,
+		log:    log,
 	}
 }
 
+// WithTools sets the registry of tools run locally instead of by the backend
+func (s *RuntimeService) WithTools(registry *tools.Registry) *RuntimeService {
+	s.tools = registry
+	return s
+}
+
 // GetInput retrieves input data for an execution
 func (s *RuntimeService) GetInput(ctx context.Context, executionID string) (interface{}, error) {
 	// Try cache first
 	input, err := s.cache.GetInput(ctx, executionID)
 	if err != nil {
 		s.log.WithError(err).Error("Failed to get input from cache")
+		cache.RecordFallback("get_input")
 	}
 	if input != nil {
 		s.log.WithField("executionId", executionID).Debug("Input retrieved from cache")
@@ -56,6 +82,7 @@ func (s *RuntimeService) GetInput(ctx context.Context, executionID string) (inte
 		}
 		if err := s.cache.SetInput(ctx, executionID, input); err != nil {
 			s.log.WithError(err).Error("Failed to cache input")
+			cache.RecordFallback("set_input")
 		}
 		
 		// Audit log
@@ -67,32 +94,85 @@ func (s *RuntimeService) GetInput(ctx context.Context, executionID string) (inte
 	return nil, nil
 }
 
-// SetOutput stores output data for an execution
-func (s *RuntimeService) SetOutput(ctx context.Context, executionID string, data interface{}) error {
+// SetOutput stores output data for an execution, with an optional hint on
+// how to render it
+func (s *RuntimeService) SetOutput(ctx context.Context, executionID string, data interface{}, render *types.RenderHint) error {
+	if err := ValidateRenderHint(render, data); err != nil {
+		return err
+	}
+
 	// Get execution context to verify permissions
 	execContext, err := s.getExecutionContext(ctx, executionID)
 	if err != nil {
 		return err
 	}
 
-	// Store in cache
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if s.storage != nil && s.storage.ShouldOffload(int64(len(encoded))) {
+		if err := s.setLargeOutput(ctx, executionID, encoded, render); err != nil {
+			return err
+		}
+	} else {
+		// Store in cache
+		output := &types.OutputData{
+			Data:      data,
+			Render:    render,
+			Timestamp: time.Now(),
+		}
+		if err := s.cache.SetOutput(ctx, executionID, output); err != nil {
+			s.log.WithError(err).Error("Failed to cache output")
+			cache.RecordFallback("set_output")
+		}
+
+		// Save to backend
+		if err := s.backend.SaveOutput(ctx, executionID, data, render); err != nil {
+			return fmt.Errorf("failed to save output: %w", err)
+		}
+	}
+
+	// Audit log
+	details := map[string]interface{}{
+		"userId": execContext.UserID,
+	}
+	if render != nil {
+		details["render"] = render.Type
+	}
+	s.backend.AuditLog(ctx, executionID, "set_output", details)
+
+	return nil
+}
+
+// setLargeOutput writes an output to external storage, caches only its
+// reference and streams it from storage to the backend
+func (s *RuntimeService) setLargeOutput(ctx context.Context, executionID string, encoded []byte, render *types.RenderHint) error {
+	ref, err := s.storage.SaveOutput(ctx, executionID, encoded)
+	if err != nil {
+		return err
+	}
+
 	output := &types.OutputData{
-		Data:      data,
+		Ref:       ref,
+		Render:    render,
 		Timestamp: time.Now(),
 	}
 	if err := s.cache.SetOutput(ctx, executionID, output); err != nil {
-		s.log.WithError(err).Error("Failed to cache output")
+		s.log.WithError(err).Error("Failed to cache output reference")
+		cache.RecordFallback("set_output")
 	}
 
-	// Save to backend
-	if err := s.backend.SaveOutput(ctx, executionID, data); err != nil {
-		return fmt.Errorf("failed to save output: %w", err)
+	reader, err := s.storage.Open(ctx, ref)
+	if err != nil {
+		return fmt.Errorf("failed to read stored output: %w", err)
 	}
+	defer reader.Close()
 
-	// Audit log
-	s.backend.AuditLog(ctx, executionID, "set_output", map[string]interface{}{
-		"userId": execContext.UserID,
-	})
+	if err := s.backend.SaveOutputStream(ctx, executionID, reader, render); err != nil {
+		return fmt.Errorf("failed to save output: %w", err)
+	}
 
 	return nil
 }
@@ -100,15 +180,19 @@ func (s *RuntimeService) SetOutput(ctx context.Context, executionID string, data
 // GetVariable retrieves a variable value
 func (s *RuntimeService) GetVariable(ctx context.Context, executionID, key string) (interface{}, error) {
 	// Try cache first
-	variable, err := s.cache.GetVariable(ctx, executionID, key)
+	variable, err := s.cachedVariable(ctx, executionID, key)
 	if err != nil {
 		s.log.WithError(err).Error("Failed to get variable from cache")
+		cache.RecordFallback("get_variable")
 	}
 	if variable != nil {
 		s.log.WithFields(logrus.Fields{
 			"executionId": executionID,
 			"key":         key,
 		}).Debug("Variable retrieved from cache")
+		if variable.Sensitive {
+			s.auditSensitiveAccess(ctx, executionID, key, "cache")
+		}
 		return variable.Value, nil
 	}
 
@@ -129,14 +213,18 @@ func (s *RuntimeService) GetVariable(ctx context.Context, executionID, key strin
 	}
 
 	// Cache for future requests
-	if err := s.cache.SetVariable(ctx, executionID, key, variable); err != nil {
+	if err := s.cacheVariable(ctx, executionID, key, variable); err != nil {
 		s.log.WithError(err).Error("Failed to cache variable")
+		cache.RecordFallback("set_variable")
 	}
 
 	// Audit log
 	s.backend.AuditLog(ctx, executionID, "get_variable", map[string]interface{}{
 		"key": key,
 	})
+	if variable.Sensitive {
+		s.auditSensitiveAccess(ctx, executionID, key, "backend")
+	}
 
 	return variable.Value, nil
 }
@@ -160,25 +248,44 @@ func (s *RuntimeService) SetVariable(ctx context.Context, executionID, key strin
 	}
 	defer s.cache.Unlock(ctx, lockKey)
 
+	// Whether the variable is sensitive is known from its cached copy, which
+	// is read before the copy is replaced
+	cached, err := s.cache.GetVariable(ctx, executionID, key)
+	if err != nil {
+		s.log.WithError(err).Error("Failed to get variable from cache")
+	}
+
 	// Save to backend
 	if err := s.backend.SetVariable(ctx, executionID, execContext.UserID, key, value); err != nil {
 		return fmt.Errorf("failed to set variable: %w", err)
 	}
 
-	// Update cache
-	variable := &types.Variable{
-		Key:       key,
-		Value:     value,
-		UpdatedAt: time.Now(),
+	// A plain set replaces any atomic value; the next atomic operation reseeds
+	if err := s.cache.DeleteAtomicVariable(ctx, execContext.UserID, key); err != nil {
+		s.log.WithError(err).Error("Failed to reset atomic variable")
 	}
-	if err := s.cache.SetVariable(ctx, executionID, key, variable); err != nil {
-		s.log.WithError(err).Error("Failed to cache variable")
+
+	// Update cache. Without a cached copy the variable may be sensitive, so
+	// it is left for the next read to load from the backend with its flag.
+	if cached != nil {
+		variable := &types.Variable{
+			Key:       key,
+			Value:     value,
+			Type:      cached.Type,
+			UpdatedAt: time.Now(),
+			Sensitive: cached.Sensitive,
+		}
+		if err := s.cacheVariable(ctx, executionID, key, variable); err != nil {
+			s.log.WithError(err).Error("Failed to cache variable")
+			cache.RecordFallback("set_variable")
+		}
 	}
 
 	// Audit log
 	s.backend.AuditLog(ctx, executionID, "set_variable", map[string]interface{}{
 		"key": key,
 	})
+	s.notifyVariableChange(ctx, executionID, execContext.UserID, key)
 
 	return nil
 }
@@ -205,6 +312,20 @@ func (s *RuntimeService) SetCondition(ctx context.Context, executionID string, c
 	return nil
 }
 
+// ReportProgress records a script's progress on its execution
+func (s *RuntimeService) ReportProgress(ctx context.Context, executionID string, progress *types.ProgressReport) error {
+	// Get execution context to verify the execution exists
+	if _, err := s.getExecutionContext(ctx, executionID); err != nil {
+		return err
+	}
+
+	if err := s.backend.SaveProgress(ctx, executionID, progress); err != nil {
+		return fmt.Errorf("failed to save progress: %w", err)
+	}
+
+	return nil
+}
+
 // GetEventContext retrieves the execution context
 func (s *RuntimeService) GetEventContext(ctx context.Context, executionID string) (*types.ExecutionContext, error) {
 	return s.getExecutionContext(ctx, executionID)
@@ -218,16 +339,23 @@ func (s *RuntimeService) ExecuteToolAction(ctx context.Context, executionID stri
 		return nil, err
 	}
 
-	// Execute via backend
-	result, err := s.backend.ExecuteToolAction(ctx, executionID, execContext.UserID, config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute tool action: %w", err)
+	// Run locally when the runtime has the tool, otherwise via the backend
+	executedBy := "runtime"
+	result, ok := s.tools.Execute(ctx, executionID, execContext.UserID, config)
+	if !ok {
+		executedBy = "backend"
+		result, err = s.backend.ExecuteToolAction(ctx, executionID, execContext.UserID, config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute tool action: %w", err)
+		}
 	}
 
 	// Audit log
 	s.backend.AuditLog(ctx, executionID, "execute_tool_action", map[string]interface{}{
-		"tool":   config.Tool,
-		"action": config.Action,
+		"tool":       config.Tool,
+		"action":     config.Action,
+		"executedBy": executedBy,
+		"success":    result.Success,
 	})
 
 	return result, nil
@@ -239,6 +367,7 @@ func (s *RuntimeService) getExecutionContext(ctx context.Context, executionID st
 	execContext, err := s.cache.GetContext(ctx, executionID)
 	if err != nil {
 		s.log.WithError(err).Error("Failed to get context from cache")
+		cache.RecordFallback("get_context")
 	}
 	if execContext != nil {
 		return execContext, nil
@@ -253,6 +382,7 @@ func (s *RuntimeService) getExecutionContext(ctx context.Context, executionID st
 	// Cache for future requests
 	if err := s.cache.SetContext(ctx, executionID, execContext); err != nil {
 		s.log.WithError(err).Error("Failed to cache context")
+		cache.RecordFallback("set_context")
 	}
 
 	return execContext, nil
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+)
+
+// ErrScratchLimit is returned when a scratch value is too large or the
+// execution has too many of them
+var ErrScratchLimit = errors.New("scratch limit exceeded")
+
+// GetScratch returns an execution's scratch value, or nil when it is not set.
+// Scratch values are transient state such as loop cursors or temporary
+// tokens. Unlike variables they only live in the cache: they are never
+// written to the backend, are not visible to other executions and expire
+// with the execution.
+func (s *RuntimeService) GetScratch(ctx context.Context, executionID, key string) (interface{}, error) {
+	encoded, ok, err := s.cache.GetScratch(ctx, executionID, key)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(encoded), &value); err != nil {
+		return nil, fmt.Errorf("failed to decode scratch value: %w", err)
+	}
+	return value, nil
+}
+
+// SetScratch stores an execution's scratch value. Setting nil removes it.
+func (s *RuntimeService) SetScratch(ctx context.Context, executionID, key string, value interface{}) error {
+	if value == nil {
+		return s.cache.DeleteScratch(ctx, executionID, key)
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode scratch value: %w", err)
+	}
+	limits := s.config.Scratch
+	if len(encoded) > limits.MaxValueSize {
+		return fmt.Errorf("%w: value is %d bytes, the limit is %d", ErrScratchLimit, len(encoded), limits.MaxValueSize)
+	}
+
+	err = s.cache.SetScratch(ctx, executionID, key, string(encoded), limits.TTL, limits.MaxKeys)
+	if errors.Is(err, cache.ErrScratchFull) {
+		return fmt.Errorf("%w: an execution may keep %d scratch keys", ErrScratchLimit, limits.MaxKeys)
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/envelope"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// WithKeyring sets the keyring that seals sensitive variables in the cache.
+// Without one sensitive variables are not cached.
+func (s *RuntimeService) WithKeyring(keyring *envelope.Keyring) *RuntimeService {
+	s.keyring = keyring
+	return s
+}
+
+// cacheVariable caches a variable for an execution. Sensitive variables are
+// sealed with the execution's data key first, and skipped when there is no
+// keyring.
+func (s *RuntimeService) cacheVariable(ctx context.Context, executionID, key string, variable *types.Variable) error {
+	if !variable.Sensitive {
+		return s.cache.SetVariable(ctx, executionID, key, variable)
+	}
+	if s.keyring == nil {
+		return nil
+	}
+
+	plaintext, err := json.Marshal(variable.Value)
+	if err != nil {
+		return fmt.Errorf("failed to encode variable: %w", err)
+	}
+	sealed, err := s.keyring.Seal(ctx, executionID, plaintext, []byte(key))
+	if err != nil {
+		return fmt.Errorf("failed to seal variable: %w", err)
+	}
+
+	return s.cache.SetVariable(ctx, executionID, key, &types.Variable{
+		Key:       variable.Key,
+		Type:      variable.Type,
+		UpdatedAt: variable.UpdatedAt,
+		Sensitive: true,
+		Encrypted: sealed,
+	})
+}
+
+// cachedVariable reads an execution's cached variable, opening it when it
+// is sealed. A sealed copy that cannot be opened, because its data key has
+// expired, counts as not cached.
+func (s *RuntimeService) cachedVariable(ctx context.Context, executionID, key string) (*types.Variable, error) {
+	variable, err := s.cache.GetVariable(ctx, executionID, key)
+	if err != nil || variable == nil || !variable.Sensitive {
+		return variable, err
+	}
+	if s.keyring == nil || variable.Encrypted == "" {
+		return nil, nil
+	}
+
+	plaintext, err := s.keyring.Open(ctx, executionID, variable.Encrypted, []byte(key))
+	if err != nil {
+		if !errors.Is(err, envelope.ErrNoDataKey) {
+			s.log.WithError(err).WithFields(logrus.Fields{
+				"executionId": executionID,
+				"key":         key,
+			}).Warn("Failed to open sensitive variable")
+		}
+		return nil, nil
+	}
+
+	opened := *variable
+	opened.Encrypted = ""
+	if err := json.Unmarshal(plaintext, &opened.Value); err != nil {
+		return nil, fmt.Errorf("failed to decode sensitive variable: %w", err)
+	}
+	return &opened, nil
+}
+
+// auditSensitiveAccess marks a read of a sensitive variable in the audit log
+func (s *RuntimeService) auditSensitiveAccess(ctx context.Context, executionID, key, source string) {
+	s.backend.AuditLog(ctx, executionID, "access_sensitive_variable", map[string]interface{}{
+		"key":    key,
+		"source": source,
+	})
+}
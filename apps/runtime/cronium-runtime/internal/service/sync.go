@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// syncIdleTimeout is how long an execution's sequence number is kept after
+// its last partial upload when no final upload arrives
+const syncIdleTimeout = time.Hour
+
+// syncFlushTimeout bounds the flush of pending results on shutdown
+const syncFlushTimeout = 10 * time.Second
+
+// resultSyncer batches the partial results of running executions and sends
+// them to the backend on an interval, or early once enough is pending
+type resultSyncer struct {
+	backend  *BackendClient
+	interval time.Duration
+	maxBytes int
+	log      *logrus.Logger
+
+	mu        sync.Mutex
+	pending   map[string]*pendingResults
+	sequences map[string]*syncSequence
+}
+
+// pendingResults are the partial results of one execution not sent yet. A
+// newer output replaces an older one; variables are merged.
+type pendingResults struct {
+	output    interface{}
+	hasOutput bool
+	variables map[string]interface{}
+	size      int
+}
+
+type syncSequence struct {
+	next     int
+	lastSeen time.Time
+}
+
+func newResultSyncer(backend *BackendClient, interval time.Duration, maxBytes int, log *logrus.Logger) *resultSyncer {
+	return &resultSyncer{
+		backend:   backend,
+		interval:  interval,
+		maxBytes:  maxBytes,
+		log:       log,
+		pending:   make(map[string]*pendingResults),
+		sequences: make(map[string]*syncSequence),
+	}
+}
+
+// add queues partial results and reports whether the execution has enough
+// pending to be flushed right away
+func (r *resultSyncer) add(executionID string, results *types.ResultUpload, size int) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[executionID]
+	if !ok {
+		p = &pendingResults{variables: make(map[string]interface{})}
+		r.pending[executionID] = p
+	}
+	if results.Output != nil {
+		p.output = results.Output
+		p.hasOutput = true
+	}
+	for key, value := range results.Variables {
+		p.variables[key] = value
+	}
+	p.size += size
+
+	r.sequence(executionID).lastSeen = time.Now()
+	return r.maxBytes > 0 && p.size >= r.maxBytes
+}
+
+// finish drops the pending results of an execution, which the final upload
+// supersedes, and returns the sequence number of its final batch
+func (r *resultSyncer) finish(executionID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.pending, executionID)
+	seq := r.sequence(executionID).next
+	delete(r.sequences, executionID)
+	return seq
+}
+
+// sequence returns the execution's sequence state; r.mu must be held
+func (r *resultSyncer) sequence(executionID string) *syncSequence {
+	seq, ok := r.sequences[executionID]
+	if !ok {
+		seq = &syncSequence{next: 1}
+		r.sequences[executionID] = seq
+	}
+	return seq
+}
+
+// flush sends the pending results of one execution. Results that fail to
+// send are queued again under any newer ones.
+func (r *resultSyncer) flush(ctx context.Context, executionID string) {
+	r.mu.Lock()
+	p, ok := r.pending[executionID]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.pending, executionID)
+	seq := r.sequence(executionID)
+	batch := &types.ResultSync{
+		Sequence:  seq.next,
+		Partial:   true,
+		Variables: p.variables,
+		Timestamp: time.Now(),
+	}
+	if p.hasOutput {
+		batch.Output = p.output
+	}
+	seq.next++
+	r.mu.Unlock()
+
+	if err := r.backend.SaveResults(ctx, executionID, batch); err != nil {
+		r.log.WithError(err).WithField("executionId", executionID).Warn("Failed to send partial results")
+		r.requeue(executionID, p)
+		return
+	}
+
+	r.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"sequence":    batch.Sequence,
+		"variables":   len(batch.Variables),
+	}).Debug("Sent partial results")
+}
+
+// requeue puts results that failed to send back in front of newer ones
+func (r *resultSyncer) requeue(executionID string, old *pendingResults) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The final upload has arrived in the meantime
+	if _, ok := r.sequences[executionID]; !ok {
+		return
+	}
+
+	p, ok := r.pending[executionID]
+	if !ok {
+		r.pending[executionID] = old
+		return
+	}
+	if !p.hasOutput && old.hasOutput {
+		p.output = old.output
+		p.hasOutput = true
+	}
+	for key, value := range old.variables {
+		if _, newer := p.variables[key]; !newer {
+			p.variables[key] = value
+		}
+	}
+	p.size += old.size
+}
+
+// flushAll sends the pending results of every execution and forgets the
+// sequences of executions that have gone quiet
+func (r *resultSyncer) flushAll(ctx context.Context) {
+	r.mu.Lock()
+	executionIDs := make([]string, 0, len(r.pending))
+	for executionID := range r.pending {
+		executionIDs = append(executionIDs, executionID)
+	}
+	for executionID, seq := range r.sequences {
+		if _, ok := r.pending[executionID]; !ok && time.Since(seq.lastSeen) > syncIdleTimeout {
+			delete(r.sequences, executionID)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, executionID := range executionIDs {
+		r.flush(ctx, executionID)
+	}
+}
+
+// run flushes on every interval until ctx is done, then flushes once more
+func (r *resultSyncer) run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), syncFlushTimeout)
+			r.flushAll(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			r.flushAll(ctx)
+		}
+	}
+}
+
+// SubmitPartialResults queues output and variables pushed by a bundled-mode
+// runner while its script is still running. They reach the backend marked
+// partial with the next flush; the final upload replaces them.
+func (s *RuntimeService) SubmitPartialResults(ctx context.Context, executionID string, results *types.ResultUpload) error {
+	encoded, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+
+	if s.sync.add(executionID, results, len(encoded)) {
+		s.sync.flush(ctx, executionID)
+	}
+	return nil
+}
+
+// RunResultSync sends partial results and helper call summaries to the
+// backend until ctx is done
+func (s *RuntimeService) RunResultSync(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.stats.run(ctx)
+	}()
+
+	s.sync.run(ctx)
+	wg.Wait()
+}
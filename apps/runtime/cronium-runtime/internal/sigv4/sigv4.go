@@ -0,0 +1,179 @@
+// Package sigv4 signs requests to AWS and S3-compatible services with AWS
+// Signature Version 4, either in the Authorization header or in the query of
+// a presigned URL.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Algorithm names the signing algorithm in signatures and presigned URLs
+const Algorithm = "AWS4-HMAC-SHA256"
+
+// UnsignedPayload skips body hashing so uploads can be streamed
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Signer signs requests for one service in one region
+type Signer struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	// Service is the signing name, such as "s3" or "sts"
+	Service string
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Content-Sha256, X-Amz-Security-Token and
+// Authorization headers to req. payloadHash is PayloadHash of the body, or
+// UnsignedPayload. The host, the Content-Type when set and the x-amz headers
+// are signed.
+func (s Signer) Sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+
+	// Send the path encoded exactly as it is signed
+	if req.URL.Path != "" {
+		req.URL.RawPath = EscapePath(req.URL.Path)
+	}
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		headers["content-type"] = contentType
+	}
+	if s.SessionToken != "" {
+		headers["x-amz-security-token"] = s.SessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(now)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		Algorithm, s.AccessKeyID, scope, signedHeaders, s.signature(now, scope, canonicalRequest)))
+}
+
+// Presign returns u signed for method in its query, valid for expires. Only
+// the host is signed, so the URL can be handed to any HTTP client.
+func (s Signer) Presign(method string, u *url.URL, expires time.Duration, now time.Time) string {
+	now = now.UTC()
+	scope := s.scope(now)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", Algorithm)
+	query.Set("X-Amz-Credential", s.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	encoded := canonicalQuery(query)
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		canonicalPath(u),
+		encoded,
+		"host:" + u.Host + "\n",
+		"host",
+		UnsignedPayload,
+	}, "\n")
+
+	return u.Scheme + "://" + u.Host + canonicalPath(u) + "?" + encoded +
+		"&X-Amz-Signature=" + s.signature(now, scope, canonicalRequest)
+}
+
+// scope is the credential scope of a signature made at now
+func (s Signer) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.Region + "/" + s.Service + "/aws4_request"
+}
+
+// signature signs a canonical request with the key derived for scope
+func (s Signer) signature(now time.Time, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		Algorithm,
+		now.Format("20060102T150405Z"),
+		scope,
+		PayloadHash([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// PayloadHash returns the hex SHA-256 of a request body
+func PayloadHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// EscapePath URI-encodes an object key as SigV4 expects, keeping slashes
+func EscapePath(key string) string {
+	var b strings.Builder
+	for _, c := range []byte(key) {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalPath is the URI-encoded path of u, "/" when it is empty
+func canonicalPath(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+	return EscapePath(u.Path)
+}
+
+// canonicalQuery encodes a query sorted by key with spaces as %20
+func canonicalQuery(query url.Values) string {
+	return strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
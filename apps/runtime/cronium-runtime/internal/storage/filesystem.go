@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+)
+
+// FilesystemStore keeps outputs as files under a root directory
+type FilesystemStore struct {
+	root string
+}
+
+// NewFilesystemStore creates a store rooted at the configured path
+func NewFilesystemStore(cfg config.FilesystemStorageConfig) (*FilesystemStore, error) {
+	root, err := filepath.Abs(cfg.Path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid storage path: %w", err)
+	}
+	if err := os.MkdirAll(root, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &FilesystemStore{root: root}, nil
+}
+
+// Name returns the backend name
+func (s *FilesystemStore) Name() string {
+	return "filesystem"
+}
+
+// Put writes an object to disk, replacing any previous version atomically
+func (s *FilesystemStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write object: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open opens an object on disk
+func (s *FilesystemStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open object: %w", err)
+	}
+	return f, nil
+}
+
+// path maps a key to a file below the root, rejecting keys that escape it
+func (s *FilesystemStore) path(key string) (string, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid storage key: %s", key)
+	}
+	return path, nil
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/sigv4"
+)
+
+// S3Store keeps outputs in an S3-compatible bucket
+type S3Store struct {
+	config     config.S3StorageConfig
+	signer     sigv4.Signer
+	endpoint   *url.URL
+	httpClient *http.Client
+}
+
+// NewS3Store creates a store for the configured bucket
+func NewS3Store(cfg config.S3StorageConfig) (*S3Store, error) {
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint: %s", cfg.Endpoint)
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+
+	return &S3Store{
+		config: cfg,
+		signer: sigv4.Signer{
+			AccessKeyID:     cfg.AccessKeyID,
+			SecretAccessKey: cfg.SecretAccessKey,
+			SessionToken:    cfg.SessionToken,
+			Region:          cfg.Region,
+			Service:         "s3",
+		},
+		endpoint:   endpoint,
+		httpClient: &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// Name returns the backend name
+func (s *S3Store) Name() string {
+	return "s3"
+}
+
+// Put uploads an object
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return s.PutObject(ctx, key, r, size, "application/json")
+}
+
+// PutObject uploads an object with the given content type
+func (s *S3Store) PutObject(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), r)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+	s.signer.Sign(req, sigv4.UnsignedPayload, time.Now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload object: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return s3Error(resp)
+	}
+	return nil
+}
+
+// Open downloads an object; the caller must close the returned reader
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	s.signer.Sign(req, sigv4.UnsignedPayload, time.Now())
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download object: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, s3Error(resp)
+	}
+	return resp.Body, nil
+}
+
+// objectURL builds the URL of an object using path or virtual-hosted style
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+	objectPath := "/" + s.config.Prefix + key
+	if s.config.UsePathStyle {
+		u.Path = "/" + s.config.Bucket + objectPath
+	} else {
+		u.Host = s.config.Bucket + "." + u.Host
+		u.Path = objectPath
+	}
+	return u.String()
+}
+
+// s3Error converts an S3 error response into an error
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 error: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+}
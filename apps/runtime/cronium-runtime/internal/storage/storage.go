@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Store persists execution outputs that are too large for the cache
+type Store interface {
+	// Name identifies the backend in storage references
+	Name() string
+
+	// Put writes size bytes from r under key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Open returns a reader for the object stored under key
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// Manager decides where outputs are stored and resolves references to them
+type Manager struct {
+	store     Store
+	stores    map[string]Store
+	threshold int64
+	log       *logrus.Logger
+}
+
+// NewManager creates a storage manager for the configured backend
+func NewManager(cfg config.StorageConfig, cacheClient *cache.ValkeyClient, log *logrus.Logger) (*Manager, error) {
+	valkey := NewValkeyStore(cacheClient)
+
+	var store Store
+	switch cfg.Backend {
+	case "", "valkey":
+		store = valkey
+	case "filesystem":
+		fs, err := NewFilesystemStore(cfg.Filesystem)
+		if err != nil {
+			return nil, err
+		}
+		store = fs
+	case "s3":
+		s3, err := NewS3Store(cfg.S3)
+		if err != nil {
+			return nil, err
+		}
+		store = s3
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+
+	return &Manager{
+		store: store,
+		stores: map[string]Store{
+			valkey.Name(): valkey,
+			store.Name():  store,
+		},
+		threshold: cfg.InlineThreshold,
+		log:       log,
+	}, nil
+}
+
+// ShouldOffload reports whether an output of the given size belongs in
+// external storage rather than inline in the cache
+func (m *Manager) ShouldOffload(size int64) bool {
+	return m.threshold > 0 && size > m.threshold
+}
+
+// SaveOutput writes an encoded output to the configured backend
+func (m *Manager) SaveOutput(ctx context.Context, executionID string, data []byte) (*types.StorageRef, error) {
+	key := executionID + "/output.json"
+	if err := m.store.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("failed to store output in %s: %w", m.store.Name(), err)
+	}
+
+	m.log.WithFields(logrus.Fields{
+		"executionId": executionID,
+		"backend":     m.store.Name(),
+		"size":        len(data),
+	}).Debug("Output stored externally")
+
+	return &types.StorageRef{
+		Backend: m.store.Name(),
+		Key:     key,
+		Size:    int64(len(data)),
+	}, nil
+}
+
+// SaveArtifact writes an uploaded file to the configured backend. Uploads of
+// unknown size are spooled to a temporary file first so the size can be
+// declared to the backend.
+func (m *Manager) SaveArtifact(ctx context.Context, executionID, artifactID string, r io.Reader, size int64) (*types.StorageRef, error) {
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "cronium-artifact-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create spool file: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if size, err = io.Copy(tmp, r); err != nil {
+			return nil, fmt.Errorf("failed to spool artifact: %w", err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind spool file: %w", err)
+		}
+		r = tmp
+	}
+
+	key := executionID + "/artifacts/" + artifactID
+	if err := m.store.Put(ctx, key, r, size); err != nil {
+		return nil, fmt.Errorf("failed to store artifact in %s: %w", m.store.Name(), err)
+	}
+
+	return &types.StorageRef{
+		Backend: m.store.Name(),
+		Key:     key,
+		Size:    size,
+	}, nil
+}
+
+// Open returns a reader for a stored output
+func (m *Manager) Open(ctx context.Context, ref *types.StorageRef) (io.ReadCloser, error) {
+	store, ok := m.stores[ref.Backend]
+	if !ok {
+		return nil, fmt.Errorf("storage backend %s is not configured", ref.Backend)
+	}
+	return store.Open(ctx, ref.Key)
+}
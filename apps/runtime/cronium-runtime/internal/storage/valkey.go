@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/cache"
+)
+
+// ValkeyStore keeps outputs as separate cache entries
+type ValkeyStore struct {
+	cache *cache.ValkeyClient
+}
+
+// NewValkeyStore creates a store backed by the Valkey cache
+func NewValkeyStore(cacheClient *cache.ValkeyClient) *ValkeyStore {
+	return &ValkeyStore{cache: cacheClient}
+}
+
+// Name returns the backend name
+func (s *ValkeyStore) Name() string {
+	return "valkey"
+}
+
+// Put stores an object in the cache
+func (s *ValkeyStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read object: %w", err)
+	}
+	return s.cache.SetBlob(ctx, key, data)
+}
+
+// Open reads an object from the cache
+func (s *ValkeyStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, err := s.cache.GetBlob(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("object %s not found", key)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+)
+
+// defaultMaxResponseSize caps response bodies when no limit is configured
+const defaultMaxResponseSize = 1024 * 1024
+
+// httpTool calls HTTP APIs on allowlisted hosts. Redirects are followed only
+// to allowlisted hosts.
+type httpTool struct {
+	hosts           []string
+	methods         map[string]bool
+	maxResponseSize int64
+	httpClient      *http.Client
+}
+
+func newHTTPTool(cfg config.HTTPToolConfig, timeout time.Duration) *httpTool {
+	t := &httpTool{
+		methods:         make(map[string]bool),
+		maxResponseSize: cfg.MaxResponseSize,
+	}
+	for _, host := range cfg.AllowedHosts {
+		t.hosts = append(t.hosts, strings.ToLower(host))
+	}
+	methods := cfg.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost}
+	}
+	for _, method := range methods {
+		t.methods[strings.ToUpper(method)] = true
+	}
+	if t.maxResponseSize <= 0 {
+		t.maxResponseSize = defaultMaxResponseSize
+	}
+	t.httpClient = &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			if !t.hostAllowed(req.URL) {
+				return fmt.Errorf("redirect to %s is not allowed", req.URL.Host)
+			}
+			return nil
+		},
+	}
+	return t
+}
+
+// Name returns the tool name
+func (t *httpTool) Name() string {
+	return "http"
+}
+
+// Execute runs request with the params url, method (default GET), headers
+// and body; a body that is not a string is sent as JSON. Responses with any
+// status are returned as {status, headers, body}.
+func (t *httpTool) Execute(ctx context.Context, req *Request) (interface{}, error) {
+	if req.Action != "request" {
+		return nil, unknownAction(t.Name(), req.Action)
+	}
+
+	rawURL, err := stringParam(req.Params, "url", true)
+	if err != nil {
+		return nil, err
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url: %s", rawURL)
+	}
+	if !t.hostAllowed(target) {
+		return nil, fmt.Errorf("host %s is not allowed", target.Hostname())
+	}
+
+	method, err := stringParam(req.Params, "method", false)
+	if err != nil {
+		return nil, err
+	}
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !t.methods[method] {
+		return nil, fmt.Errorf("method %s is not allowed", method)
+	}
+
+	var body io.Reader
+	contentType := ""
+	switch value := req.Params["body"].(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(value)
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode body: %w", err)
+		}
+		body = bytes.NewReader(data)
+		contentType = "application/json"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := req.Params["headers"].(map[string]interface{}); ok {
+		for key, value := range headers {
+			httpReq.Header.Set(key, fmt.Sprint(value))
+		}
+	}
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, t.maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(data)) > t.maxResponseSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", t.maxResponseSize)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for key := range resp.Header {
+		headers[key] = resp.Header.Get(key)
+	}
+
+	var responseBody interface{} = string(data)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var decoded interface{}
+		if json.Unmarshal(data, &decoded) == nil {
+			responseBody = decoded
+		}
+	}
+
+	return map[string]interface{}{
+		"status":  resp.StatusCode,
+		"headers": headers,
+		"body":    responseBody,
+	}, nil
+}
+
+// hostAllowed reports whether a URL's host is an allowed host or a
+// subdomain of an allowed "*." entry
+func (t *httpTool) hostAllowed(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range t.hosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
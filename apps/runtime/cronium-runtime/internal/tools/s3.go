@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/internal/storage"
+)
+
+// defaultMaxObjectSize caps uploaded objects when no limit is configured
+const defaultMaxObjectSize = 10 * 1024 * 1024
+
+// s3Tool puts objects into allowlisted buckets with the runtime's own
+// credentials
+type s3Tool struct {
+	config  config.S3ToolConfig
+	buckets map[string][]string // bucket -> allowed key prefixes; "" allows any key
+}
+
+func newS3Tool(cfg config.S3ToolConfig) (*s3Tool, error) {
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3.amazonaws.com"
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.MaxObjectSize <= 0 {
+		cfg.MaxObjectSize = defaultMaxObjectSize
+	}
+
+	t := &s3Tool{config: cfg, buckets: make(map[string][]string)}
+	for _, allowed := range cfg.AllowedBuckets {
+		bucket, prefix, _ := strings.Cut(allowed, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid allowed bucket %q", allowed)
+		}
+		t.buckets[bucket] = append(t.buckets[bucket], prefix)
+	}
+	return t, nil
+}
+
+// Name returns the tool name
+func (t *s3Tool) Name() string {
+	return "s3"
+}
+
+// Execute runs put_object with the params bucket, key, body, contentType
+// (default text/plain) and encoding ("base64" for binary bodies)
+func (t *s3Tool) Execute(ctx context.Context, req *Request) (interface{}, error) {
+	if req.Action != "put_object" {
+		return nil, unknownAction(t.Name(), req.Action)
+	}
+
+	bucket, err := stringParam(req.Params, "bucket", true)
+	if err != nil {
+		return nil, err
+	}
+	key, err := stringParam(req.Params, "key", true)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return nil, fmt.Errorf("invalid key %q", key)
+	}
+	if !t.keyAllowed(bucket, key) {
+		return nil, fmt.Errorf("key %q in bucket %s is not allowed", key, bucket)
+	}
+
+	content, err := stringParam(req.Params, "body", true)
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := stringParam(req.Params, "encoding", false)
+	if err != nil {
+		return nil, err
+	}
+	data := []byte(content)
+	if encoding == "base64" {
+		if data, err = base64.StdEncoding.DecodeString(content); err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+	}
+	if int64(len(data)) > t.config.MaxObjectSize {
+		return nil, fmt.Errorf("object exceeds %d bytes", t.config.MaxObjectSize)
+	}
+
+	contentType, err := stringParam(req.Params, "contentType", false)
+	if err != nil {
+		return nil, err
+	}
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+
+	store, err := storage.NewS3Store(config.S3StorageConfig{
+		Endpoint:        t.config.Endpoint,
+		Region:          t.config.Region,
+		Bucket:          bucket,
+		AccessKeyID:     t.config.AccessKeyID,
+		SecretAccessKey: t.config.SecretAccessKey,
+		SessionToken:    t.config.SessionToken,
+		UsePathStyle:    t.config.UsePathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.PutObject(ctx, key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"bucket": bucket,
+		"key":    key,
+		"size":   len(data),
+	}, nil
+}
+
+// keyAllowed reports whether a key falls under one of the bucket's allowed
+// prefixes
+func (t *s3Tool) keyAllowed(bucket, key string) bool {
+	for _, prefix := range t.buckets[bucket] {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+)
+
+// slackTool posts messages to configured incoming webhooks. Scripts pick a
+// webhook by name, so they never see or choose the webhook URLs.
+type slackTool struct {
+	config     config.SlackToolConfig
+	httpClient *http.Client
+}
+
+func newSlackTool(cfg config.SlackToolConfig, timeout time.Duration) *slackTool {
+	return &slackTool{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Name returns the tool name
+func (t *slackTool) Name() string {
+	return "slack"
+}
+
+// Execute runs send_message with the params webhook (optional with a default
+// webhook), text and blocks
+func (t *slackTool) Execute(ctx context.Context, req *Request) (interface{}, error) {
+	if req.Action != "send_message" {
+		return nil, unknownAction(t.Name(), req.Action)
+	}
+
+	name, err := stringParam(req.Params, "webhook", false)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = t.config.DefaultWebhook
+	}
+	if name == "" {
+		return nil, fmt.Errorf("webhook is required")
+	}
+	webhookURL, ok := t.config.Webhooks[name]
+	if !ok {
+		return nil, fmt.Errorf("webhook %q is not allowed", name)
+	}
+
+	message := make(map[string]interface{})
+	for _, key := range []string{"text", "blocks", "attachments", "username", "icon_emoji", "thread_ts"} {
+		if value, ok := req.Params[key]; ok {
+			message[key] = value
+		}
+	}
+	if message["text"] == nil && message["blocks"] == nil {
+		return nil, fmt.Errorf("text or blocks is required")
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to post message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	reply, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("slack returned %s: %s", resp.Status, strings.TrimSpace(string(reply)))
+	}
+	return map[string]interface{}{"webhook": name}, nil
+}
@@ -0,0 +1,166 @@
+// Package tools runs tool actions inside the runtime service. Actions that
+// are latency sensitive or do not need the backend, such as posting to a
+// Slack webhook, calling an allowlisted HTTP API or putting an S3 object, are
+// executed here without a backend round trip. Tools that are not registered,
+// or not enabled for the calling user, are forwarded to the backend as before.
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/internal/config"
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultTimeout bounds a local tool action when no timeout is configured
+const defaultTimeout = 30 * time.Second
+
+// Request is a tool action to run for an execution
+type Request struct {
+	ExecutionID string
+	UserID      string
+	Action      string
+	Params      map[string]interface{}
+}
+
+// Tool runs the actions of one tool. Execute returns the action's result
+// data; an error fails the action.
+type Tool interface {
+	Name() string
+	Execute(ctx context.Context, req *Request) (interface{}, error)
+}
+
+// entry is a registered tool and the users it runs locally for
+type entry struct {
+	tool  Tool
+	users map[string]bool // nil means every user
+}
+
+// Registry holds the tools run by the runtime
+type Registry struct {
+	timeout time.Duration
+	tools   map[string]*entry
+	log     *logrus.Logger
+}
+
+// NewRegistry creates a registry with the enabled built-in tools. It returns
+// nil when no tool is enabled; a nil registry runs nothing locally.
+func NewRegistry(cfg config.ToolsConfig, log *logrus.Logger) (*Registry, error) {
+	r := &Registry{
+		timeout: cfg.Timeout,
+		tools:   make(map[string]*entry),
+		log:     log,
+	}
+	if r.timeout <= 0 {
+		r.timeout = defaultTimeout
+	}
+
+	if cfg.Slack.Enabled {
+		r.Register(newSlackTool(cfg.Slack, r.timeout), cfg.Slack.AllowedUsers)
+	}
+	if cfg.HTTP.Enabled {
+		r.Register(newHTTPTool(cfg.HTTP, r.timeout), cfg.HTTP.AllowedUsers)
+	}
+	if cfg.S3.Enabled {
+		tool, err := newS3Tool(cfg.S3)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create s3 tool: %w", err)
+		}
+		r.Register(tool, cfg.S3.AllowedUsers)
+	}
+
+	if len(r.tools) == 0 {
+		return nil, nil
+	}
+	for name := range r.tools {
+		log.WithField("tool", name).Info("Tool actions run locally")
+	}
+	return r, nil
+}
+
+// Register adds a tool, replacing any tool with the same name. users limits
+// local execution to those user IDs; other users' actions go to the backend.
+func (r *Registry) Register(tool Tool, users []string) {
+	e := &entry{tool: tool}
+	if len(users) > 0 {
+		e.users = make(map[string]bool, len(users))
+		for _, user := range users {
+			e.users[user] = true
+		}
+	}
+	r.tools[tool.Name()] = e
+}
+
+// Handles reports whether a tool runs locally for a user
+func (r *Registry) Handles(tool, userID string) bool {
+	if r == nil {
+		return false
+	}
+	e, ok := r.tools[tool]
+	return ok && (e.users == nil || e.users[userID])
+}
+
+// Execute runs an action of a local tool. It returns false when the tool
+// does not run locally for the user and the action belongs to the backend.
+func (r *Registry) Execute(ctx context.Context, executionID, userID string, action types.ToolActionConfig) (*types.ToolActionResult, bool) {
+	if !r.Handles(action.Tool, userID) {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	data, err := r.tools[action.Tool].tool.Execute(ctx, &Request{
+		ExecutionID: executionID,
+		UserID:      userID,
+		Action:      action.Action,
+		Params:      action.Params,
+	})
+
+	result := &types.ToolActionResult{
+		Success: err == nil,
+		Data:    data,
+		Metadata: map[string]interface{}{
+			"executedBy": "runtime",
+			"durationMs": time.Since(start).Milliseconds(),
+		},
+	}
+	if err != nil {
+		result.Error = err.Error()
+		r.log.WithError(err).WithFields(logrus.Fields{
+			"executionId": executionID,
+			"tool":        action.Tool,
+			"action":      action.Action,
+		}).Warn("Local tool action failed")
+	}
+	return result, true
+}
+
+// stringParam returns a string parameter, or an error if it is required and
+// missing
+func stringParam(params map[string]interface{}, name string, required bool) (string, error) {
+	value, ok := params[name]
+	if !ok || value == nil {
+		if required {
+			return "", fmt.Errorf("%s is required", name)
+		}
+		return "", nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%s must be a string", name)
+	}
+	if required && s == "" {
+		return "", fmt.Errorf("%s is required", name)
+	}
+	return s, nil
+}
+
+// unknownAction is the error for an action a tool does not have
+func unknownAction(tool, action string) error {
+	return fmt.Errorf("unknown %s action %q", tool, action)
+}
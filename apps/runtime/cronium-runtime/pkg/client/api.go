@@ -0,0 +1,301 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// GetInput returns the execution's input data
+func (c *Client) GetInput(ctx context.Context) (interface{}, error) {
+	var input interface{}
+	if err := c.DecodeInput(ctx, &input); err != nil {
+		return nil, err
+	}
+	return input, nil
+}
+
+// DecodeInput decodes the execution's input data into v
+func (c *Client) DecodeInput(ctx context.Context, v interface{}) error {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodGet, c.executionPath("/input"), nil, &data, true); err != nil {
+		return err
+	}
+	return decodeData(data, v)
+}
+
+// SetOutput stores the execution's output data
+func (c *Client) SetOutput(ctx context.Context, data interface{}) error {
+	body := map[string]interface{}{"data": data}
+	return c.do(ctx, http.MethodPost, c.executionPath("/output"), body, nil, true)
+}
+
+// SetRenderedOutput stores the execution's output data with a hint on how
+// front-ends should render it, such as a table or a metric
+func (c *Client) SetRenderedOutput(ctx context.Context, data interface{}, render types.RenderHint) error {
+	body := map[string]interface{}{"data": data, "render": render}
+	return c.do(ctx, http.MethodPost, c.executionPath("/output"), body, nil, true)
+}
+
+// GetVariable returns a user variable, or nil if it is not set
+func (c *Client) GetVariable(ctx context.Context, key string) (interface{}, error) {
+	var value interface{}
+	if err := c.DecodeVariable(ctx, key, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// DecodeVariable decodes a user variable into v
+func (c *Client) DecodeVariable(ctx context.Context, key string, v interface{}) error {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodGet, c.executionPath("/variables/"+url.PathEscape(key)), nil, &data, true); err != nil {
+		return err
+	}
+	var variable struct {
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &variable); err != nil {
+		return fmt.Errorf("failed to parse variable: %w", err)
+	}
+	return decodeData(variable.Value, v)
+}
+
+// SetVariable sets a user variable
+func (c *Client) SetVariable(ctx context.Context, key string, value interface{}) error {
+	body := map[string]interface{}{"value": value}
+	return c.do(ctx, http.MethodPut, c.executionPath("/variables/"+url.PathEscape(key)), body, nil, true)
+}
+
+// IncrementVariable atomically adds by to a numeric user variable and returns
+// the new value. A missing variable counts as zero. Increments are not
+// idempotent, so they are only retried when rate limited.
+func (c *Client) IncrementVariable(ctx context.Context, key string, by float64) (float64, error) {
+	var data json.RawMessage
+	body := map[string]interface{}{"by": by}
+	if err := c.do(ctx, http.MethodPost, c.executionPath("/variables/"+url.PathEscape(key)+"/increment"), body, &data, false); err != nil {
+		return 0, err
+	}
+	var variable struct {
+		Value float64 `json:"value"`
+	}
+	if err := json.Unmarshal(data, &variable); err != nil {
+		return 0, fmt.Errorf("failed to parse variable: %w", err)
+	}
+	return variable.Value, nil
+}
+
+// AppendVariable atomically appends value to a list user variable and
+// returns the list
+func (c *Client) AppendVariable(ctx context.Context, key string, value interface{}) ([]interface{}, error) {
+	var data json.RawMessage
+	body := map[string]interface{}{"value": value}
+	if err := c.do(ctx, http.MethodPost, c.executionPath("/variables/"+url.PathEscape(key)+"/append"), body, &data, false); err != nil {
+		return nil, err
+	}
+	var variable struct {
+		Value []interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &variable); err != nil {
+		return nil, fmt.Errorf("failed to parse variable: %w", err)
+	}
+	return variable.Value, nil
+}
+
+// AddToSetVariable atomically adds value to a set user variable. It returns
+// the members and whether value was new. Adding is idempotent, so it is
+// retried like a plain set.
+func (c *Client) AddToSetVariable(ctx context.Context, key string, value interface{}) ([]interface{}, bool, error) {
+	var data json.RawMessage
+	body := map[string]interface{}{"value": value}
+	if err := c.do(ctx, http.MethodPost, c.executionPath("/variables/"+url.PathEscape(key)+"/add-to-set"), body, &data, true); err != nil {
+		return nil, false, err
+	}
+	var variable struct {
+		Value []interface{} `json:"value"`
+		Added bool          `json:"added"`
+	}
+	if err := json.Unmarshal(data, &variable); err != nil {
+		return nil, false, fmt.Errorf("failed to parse variable: %w", err)
+	}
+	return variable.Value, variable.Added, nil
+}
+
+// GetScratch returns a scratch value kept for this execution only, or nil
+// when it is not set
+func (c *Client) GetScratch(ctx context.Context, key string) (interface{}, error) {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodGet, c.executionPath("/scratch/"+url.PathEscape(key)), nil, &data, true); err != nil {
+		return nil, err
+	}
+	var scratch struct {
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &scratch); err != nil {
+		return nil, fmt.Errorf("failed to parse scratch value: %w", err)
+	}
+	return scratch.Value, nil
+}
+
+// SetScratch sets a scratch value kept for this execution only. Unlike a
+// variable it never reaches the backend. A nil value removes it.
+func (c *Client) SetScratch(ctx context.Context, key string, value interface{}) error {
+	body := map[string]interface{}{"value": value}
+	return c.do(ctx, http.MethodPut, c.executionPath("/scratch/"+url.PathEscape(key)), body, nil, true)
+}
+
+// GetCredential mints short-lived credentials for a configured provider
+// role. A zero ttl asks for the runtime's default.
+func (c *Client) GetCredential(ctx context.Context, provider, role string, ttl time.Duration) (*types.Credential, error) {
+	var data json.RawMessage
+	body := types.CredentialRequest{Provider: provider, Role: role, TTL: int(ttl.Seconds())}
+	if err := c.do(ctx, http.MethodPost, c.executionPath("/credentials"), body, &data, false); err != nil {
+		return nil, err
+	}
+	var credential types.Credential
+	if err := decodeData(data, &credential); err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// SubmitJob queues a child job that runs an event with input. It inherits
+// this execution's user and metadata. Jobs submitted with an idempotency key
+// are only created once, so the request is retried when one is set.
+func (c *Client) SubmitJob(ctx context.Context, req types.JobRequest) (*types.Job, error) {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodPost, c.executionPath("/jobs"), req, &data, req.IdempotencyKey != ""); err != nil {
+		return nil, err
+	}
+	var job types.Job
+	if err := decodeData(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob returns a child job's status, and its output once it has finished
+func (c *Client) GetJob(ctx context.Context, jobID string) (*types.Job, error) {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodGet, c.executionPath("/jobs/"+url.PathEscape(jobID)), nil, &data, true); err != nil {
+		return nil, err
+	}
+	var job types.Job
+	if err := decodeData(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// WaitForJob polls a child job every interval until it has finished or ctx
+// is done
+func (c *Client) WaitForJob(ctx context.Context, jobID string, interval time.Duration) (*types.Job, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := c.GetJob(ctx, jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job.Finished() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// GetContext returns the event and execution details
+func (c *Client) GetContext(ctx context.Context) (*types.ExecutionContext, error) {
+	var data json.RawMessage
+	if err := c.do(ctx, http.MethodGet, c.executionPath("/context"), nil, &data, true); err != nil {
+		return nil, err
+	}
+	var execContext types.ExecutionContext
+	if err := decodeData(data, &execContext); err != nil {
+		return nil, err
+	}
+	return &execContext, nil
+}
+
+// SetCondition sets the workflow condition result of the execution
+func (c *Client) SetCondition(ctx context.Context, condition bool) error {
+	body := map[string]interface{}{"condition": condition}
+	return c.do(ctx, http.MethodPost, c.executionPath("/condition"), body, nil, true)
+}
+
+// ReportProgress reports how far the execution has got, as a percentage
+// from 0 to 100 with an optional message
+func (c *Client) ReportProgress(ctx context.Context, percentage int, message string) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("percentage must be between 0 and 100")
+	}
+	body := types.ProgressReport{Percentage: percentage, Message: message}
+	return c.do(ctx, http.MethodPost, c.executionPath("/progress"), body, nil, true)
+}
+
+// ExecuteToolAction runs a tool action (for example a Slack message) with
+// the execution owner's credentials. Tool actions have side effects, so
+// they are only retried when rate limited. A result reporting failure is
+// returned together with an error.
+func (c *Client) ExecuteToolAction(ctx context.Context, tool, action string, params map[string]interface{}) (*types.ToolActionResult, error) {
+	body := types.ToolActionConfig{Tool: tool, Action: action, Params: params}
+
+	var result types.ToolActionResult
+	if err := c.do(ctx, http.MethodPost, "/tool-actions/execute", body, &result, false); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return &result, fmt.Errorf("tool action %s.%s failed: %s", tool, action, result.Error)
+	}
+	return &result, nil
+}
+
+// UploadFile stores a file as an artifact of the execution. The body is
+// streamed, so uploads are not retried.
+func (c *Client) UploadFile(ctx context.Context, name, mimeType string, r io.Reader) (*types.Artifact, error) {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	path := c.executionPath("/files") + "?name=" + url.QueryEscape(name)
+
+	resp, err := c.send(ctx, http.MethodPost, path, r, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	var data json.RawMessage
+	if err := decodeResponse(resp, &data); err != nil {
+		return nil, err
+	}
+	var artifact types.Artifact
+	if err := decodeData(data, &artifact); err != nil {
+		return nil, err
+	}
+	return &artifact, nil
+}
+
+// executionPath returns an API path under the client's execution
+func (c *Client) executionPath(suffix string) string {
+	return "/executions/" + url.PathEscape(c.executionID) + suffix
+}
+
+// decodeData decodes a response payload into v, leaving v untouched when
+// the payload is missing or null
+func decodeData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse response data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,337 @@
+// Package client is a Go client for the Cronium runtime API. Jobs written in
+// Go can use it instead of shelling out to the cronium helper binaries, and
+// internal tools can use it with an execution token of their own.
+//
+// A client is bound to one execution. Inside a job, NewFromEnv picks up the
+// endpoint, token and execution ID the orchestrator provides:
+//
+//	c, err := client.NewFromEnv()
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	var input struct{ Path string }
+//	if err := c.DecodeInput(ctx, &input); err != nil {
+//		log.Fatal(err)
+//	}
+//	c.ReportProgress(ctx, 50, "halfway")
+//	c.SetOutput(ctx, map[string]any{"rows": 42})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+)
+
+// Defaults for new clients
+const (
+	DefaultTimeout    = 30 * time.Second
+	DefaultMaxRetries = 3
+	DefaultRetryDelay = 500 * time.Millisecond
+
+	// maxRetryDelay caps the exponential backoff between attempts
+	maxRetryDelay = 10 * time.Second
+)
+
+// ErrUnauthorized matches errors for requests the runtime API rejected
+// because the token is invalid, expired or belongs to another execution
+var ErrUnauthorized = errors.New("runtime API rejected the execution token")
+
+// APIError is a non-success response from the runtime API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+// Error implements error
+func (e *APIError) Error() string {
+	return fmt.Sprintf("runtime API error (HTTP %d): %s", e.StatusCode, e.Message)
+}
+
+// Is makes 401 and 403 responses match ErrUnauthorized
+func (e *APIError) Is(target error) bool {
+	return target == ErrUnauthorized &&
+		(e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden)
+}
+
+// Client calls the runtime API on behalf of one execution
+type Client struct {
+	endpoint    string
+	token       string
+	executionID string
+	httpClient  *http.Client
+	maxRetries  int
+	retryDelay  time.Duration
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithRetry sets how often a failed request is retried and the delay before
+// the first retry, which doubles on each further attempt
+func WithRetry(maxRetries int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryDelay = delay
+	}
+}
+
+// WithExecutionID sets the execution the client acts on. Without it the
+// execution ID is read from the token.
+func WithExecutionID(executionID string) Option {
+	return func(c *Client) {
+		c.executionID = executionID
+	}
+}
+
+// New creates a client for the runtime API at endpoint, authenticating with
+// an execution token
+func New(endpoint, token string, opts ...Option) (*Client, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("runtime API endpoint is required")
+	}
+	if token == "" {
+		return nil, fmt.Errorf("execution token is required")
+	}
+
+	c := &Client{
+		endpoint:   strings.TrimRight(endpoint, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: DefaultTimeout},
+		maxRetries: DefaultMaxRetries,
+		retryDelay: DefaultRetryDelay,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.executionID == "" {
+		c.executionID = executionIDFromToken(token)
+	}
+	if c.executionID == "" {
+		return nil, fmt.Errorf("execution ID is required (not found in token)")
+	}
+	return c, nil
+}
+
+// NewFromEnv creates a client from the environment a job runs in: the
+// container variables CRONIUM_RUNTIME_API and CRONIUM_EXECUTION_TOKEN, or the
+// SSH runner's CRONIUM_API_ENDPOINT and CRONIUM_API_TOKEN, with
+// CRONIUM_EXECUTION_ID
+func NewFromEnv(opts ...Option) (*Client, error) {
+	endpoint := firstEnv("CRONIUM_RUNTIME_API", "CRONIUM_API_ENDPOINT")
+	token := firstEnv("CRONIUM_EXECUTION_TOKEN", "CRONIUM_API_TOKEN")
+	if id := os.Getenv("CRONIUM_EXECUTION_ID"); id != "" {
+		opts = append([]Option{WithExecutionID(id)}, opts...)
+	}
+	return New(endpoint, token, opts...)
+}
+
+// ExecutionID returns the execution the client acts on
+func (c *Client) ExecutionID() string {
+	return c.executionID
+}
+
+// do sends a request and decodes the response into result. Requests are
+// retried on network errors, rate limiting and server errors when
+// idempotent, and on rate limiting only otherwise, since a rate-limited
+// request was never processed.
+func (c *Client) do(ctx context.Context, method, path string, body, result interface{}, idempotent bool) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request body: %w", err)
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			if err := c.wait(ctx, attempt, lastErr); err != nil {
+				return err
+			}
+		}
+
+		var bodyReader io.Reader
+		if payload != nil {
+			bodyReader = bytes.NewReader(payload)
+		}
+		resp, err := c.send(ctx, method, path, bodyReader, "application/json")
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			lastErr = err
+			if idempotent {
+				continue
+			}
+			return err
+		}
+
+		err = decodeResponse(resp, result)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+		retryable := apiErr.StatusCode == http.StatusTooManyRequests ||
+			(idempotent && apiErr.StatusCode >= 500)
+		if !retryable {
+			return err
+		}
+	}
+	return lastErr
+}
+
+// send performs one HTTP request against the runtime API
+func (c *Client) send(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// wait sleeps before a retry, honouring Retry-After on rate-limited
+// responses
+func (c *Client) wait(ctx context.Context, attempt int, lastErr error) error {
+	delay := c.retryDelay << (attempt - 1)
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	var rateErr *rateLimitError
+	if errors.As(lastErr, &rateErr) && rateErr.retryAfter > 0 {
+		delay = rateErr.retryAfter
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitError is a 429 response carrying the server's Retry-After
+type rateLimitError struct {
+	*APIError
+	retryAfter time.Duration
+}
+
+// Unwrap exposes the APIError
+func (e *rateLimitError) Unwrap() error {
+	return e.APIError
+}
+
+// decodeResponse reads a runtime API response. Success responses wrap their
+// payload as {"success": true, "data": ...}; result receives the whole body
+// unless it is a *json.RawMessage, which receives only data.
+func decodeResponse(resp *http.Response, result interface{}) error {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 400 {
+		var errResp types.ErrorResponse
+		message := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &errResp) == nil && (errResp.Message != "" || errResp.Error != "") {
+			message = errResp.Message
+			if message == "" {
+				message = errResp.Error
+			}
+		}
+		apiErr := &APIError{StatusCode: resp.StatusCode, Message: message}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+			return &rateLimitError{APIError: apiErr, retryAfter: time.Duration(seconds) * time.Second}
+		}
+		return apiErr
+	}
+
+	if result == nil {
+		return nil
+	}
+	if raw, ok := result.(*json.RawMessage); ok {
+		var envelope struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			return fmt.Errorf("failed to parse response: %w", err)
+		}
+		*raw = envelope.Data
+		return nil
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// executionIDFromToken reads the executionId claim of a JWT without
+// verifying it; the runtime API does the verification
+func executionIDFromToken(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		ExecutionID string `json:"executionId"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.ExecutionID
+}
+
+// firstEnv returns the first non-empty environment variable of names
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/addison-moore/cronium/apps/runtime/pkg/types"
+	"github.com/gorilla/websocket"
+)
+
+// Messages opens the execution's message channel and returns the push
+// messages it receives: cancellation notices, changed variables and
+// operator messages. The channel is closed when ctx is done or the
+// connection drops; callers that need to keep listening reconnect.
+func (c *Client) Messages(ctx context.Context) (<-chan types.PushMessage, error) {
+	u := c.endpoint + c.executionPath("/messages")
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		u = "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		u = "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.token)
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= 400 {
+			return nil, decodeResponse(resp, nil)
+		}
+		return nil, fmt.Errorf("failed to open message channel: %w", err)
+	}
+
+	messages := make(chan types.PushMessage, 16)
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		defer close(messages)
+		defer conn.Close()
+		for {
+			var msg types.PushMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			select {
+			case messages <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return messages, nil
+}
@@ -21,6 +21,11 @@ type Variable struct {
 	Value     interface{} `json:"value"`
 	Type      string      `json:"type"`
 	UpdatedAt time.Time   `json:"updatedAt"`
+
+	// Sensitive variables are cached only sealed, in Encrypted, and never
+	// with their Value
+	Sensitive bool   `json:"sensitive,omitempty"`
+	Encrypted string `json:"encrypted,omitempty"`
 }
 
 // ToolActionConfig represents configuration for executing a tool action
@@ -57,18 +62,187 @@ type InputData struct {
 	Timestamp time.Time   `json:"timestamp"`
 }
 
-// OutputData represents output data from an execution
+// OutputData represents output data from an execution. Large outputs are kept
+// in external storage and only Ref is set.
 type OutputData struct {
 	Data      interface{} `json:"data"`
+	Ref       *StorageRef `json:"ref,omitempty"`
+	Render    *RenderHint `json:"render,omitempty"`
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// Kinds of output rendering
+const (
+	RenderTable    = "table"
+	RenderJSON     = "json"
+	RenderMarkdown = "markdown"
+	RenderMetric   = "metric"
+)
+
+// RenderHint tells front-ends and notification templates how a script wants
+// its output displayed. A table is a list of rows, either objects or lists
+// of cells; markdown is a string; a metric is a number, or an object with a
+// numeric "value".
+type RenderHint struct {
+	Type string `json:"type"`
+	// Column order of a table; defaults to the keys of the first row
+	Columns []string `json:"columns,omitempty"`
+	// Label and unit of a metric, such as "Queue depth" and "jobs"
+	Label string `json:"label,omitempty"`
+	Unit  string `json:"unit,omitempty"`
+}
+
+// StorageRef points to an output held in external storage
+type StorageRef struct {
+	Backend string `json:"backend"`
+	Key     string `json:"key"`
+	Size    int64  `json:"size"`
+}
+
+// Artifact is a file uploaded by a script during an execution
+type Artifact struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Size      int64       `json:"size"`
+	MimeType  string      `json:"mimeType"`
+	Checksum  string      `json:"checksum"`
+	Ref       *StorageRef `json:"ref"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// ResultUpload is the final output and variables pushed by a runner in
+// bundled mode through a signed upload URL
+type ResultUpload struct {
+	Output    interface{}            `json:"output,omitempty"`
+	Render    *RenderHint            `json:"render,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// ResultSync is a batch of results sent to the backend while an execution
+// runs. Partial batches are numbered by Sequence, so the backend can ignore
+// one that arrives late. The last batch has Partial false: the final output
+// and variables have been stored and replace any partial ones.
+type ResultSync struct {
+	Sequence  int                    `json:"sequence"`
+	Partial   bool                   `json:"partial"`
+	Output    interface{}            `json:"output,omitempty"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
 // ConditionResult represents a workflow condition result
 type ConditionResult struct {
 	Result    bool      `json:"result"`
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// ProgressReport is a script's report of how far it has got
+type ProgressReport struct {
+	Percentage int    `json:"percentage"`
+	Message    string `json:"message,omitempty"`
+}
+
+// HelperStats summarizes the helper calls an execution has made, so users
+// can see when a script is slow because of them. Operations are ordered by
+// the time spent in them, most first.
+type HelperStats struct {
+	Calls      int               `json:"calls"`
+	Errors     int               `json:"errors"`
+	TotalMs    float64           `json:"totalMs"`
+	Operations []HelperCallStats `json:"operations"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+}
+
+// HelperCallStats summarizes the calls to one helper operation. Percentiles
+// are taken from a sample of the calls when there are many.
+type HelperCallStats struct {
+	Operation string  `json:"operation"`
+	Calls     int     `json:"calls"`
+	Errors    int     `json:"errors"`
+	TotalMs   float64 `json:"totalMs"`
+	P50Ms     float64 `json:"p50Ms"`
+	P95Ms     float64 `json:"p95Ms"`
+}
+
+// CredentialRequest asks for short-lived credentials for a role of a
+// configured provider. TTL is in seconds; zero uses the default.
+type CredentialRequest struct {
+	Provider string `json:"provider"`
+	Role     string `json:"role"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// Credential is a short-lived credential minted for one execution. Values
+// depend on the provider: accessKeyId, secretAccessKey and sessionToken for
+// aws; the secret's fields, such as username and password, for vault.
+type Credential struct {
+	Provider  string            `json:"provider"`
+	Role      string            `json:"role"`
+	Values    map[string]string `json:"values"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// JobRequest asks for a child job of the running execution. The child runs
+// the event with the input and inherits the execution's user and metadata.
+// Submitting again with the same IdempotencyKey returns the same job.
+type JobRequest struct {
+	EventID        string                 `json:"eventId"`
+	Input          interface{}            `json:"input,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"`
+	IdempotencyKey string                 `json:"idempotencyKey,omitempty"`
+}
+
+// Job is a child job submitted by an execution
+type Job struct {
+	ID                string      `json:"id"`
+	EventID           string      `json:"eventId"`
+	ParentExecutionID string      `json:"parentExecutionId"`
+	ExecutionID       string      `json:"executionId,omitempty"`
+	Status            string      `json:"status"`
+	Output            interface{} `json:"output,omitempty"`
+	Error             string      `json:"error,omitempty"`
+	CreatedAt         time.Time   `json:"createdAt"`
+	CompletedAt       *time.Time  `json:"completedAt,omitempty"`
+}
+
+// Finished reports whether a job has completed, failed or been cancelled
+func (j *Job) Finished() bool {
+	switch j.Status {
+	case "completed", "failed", "cancelled":
+		return true
+	}
+	return false
+}
+
+// Push message types
+const (
+	MessageCancel   = "cancel"
+	MessageVariable = "variable"
+	MessageOperator = "message"
+)
+
+// PushMessage is pushed to a running script over its message channel: a
+// cancellation notice, a change to one of its user's variables, or a message
+// an operator sent through the orchestrator's admin API
+type PushMessage struct {
+	Type string `json:"type"`
+	// Operator messages also reach the job's messages file, so scripts
+	// reading both can tell them apart
+	ID    string `json:"id,omitempty"`
+	JobID string `json:"jobId,omitempty"`
+	// Cancellation reason
+	Reason string `json:"reason,omitempty"`
+	// Variable that changed; scripts read the new value with getVariable
+	Key string `json:"key,omitempty"`
+	// Operator message text and optional structured payload
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	// Execution the change came from, so a script does not hear its own
+	// variable writes
+	Source    string    `json:"source,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
 // TokenClaims represents JWT token claims
 type TokenClaims struct {
 	JobID       string    `json:"jobId"`
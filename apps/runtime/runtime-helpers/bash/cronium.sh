@@ -28,6 +28,11 @@ CRONIUM_EXEC_ID="${CRONIUM_EXECUTION_ID}"
 MAX_RETRIES=3
 RETRY_DELAY=1
 
+# Helper calls are recorded here, one line per call, so calls made in
+# subshells and child scripts are counted too
+CRONIUM_HELPER_STATS_FILE="${CRONIUM_HELPER_STATS_FILE:-${TMPDIR:-/tmp}/cronium-helper-stats-${CRONIUM_EXECUTION_ID}}"
+export CRONIUM_HELPER_STATS_FILE
+
 # Check required environment variables
 if [ -z "$CRONIUM_TOKEN" ]; then
     echo "Error: CRONIUM_EXECUTION_TOKEN environment variable not set" >&2
@@ -47,8 +52,37 @@ for cmd in curl jq; do
     fi
 done
 
-# Helper function to make API requests with retry logic
+# Current time in microseconds
+_cronium_now_us() {
+    if [ -n "${EPOCHREALTIME:-}" ]; then
+        echo "${EPOCHREALTIME//[.,]/}"
+    else
+        date +%s%6N
+    fi
+}
+
+# Make an API request, counting it in the helper call stats
 _cronium_request() {
+    local start=$(_cronium_now_us)
+    local status
+
+    _cronium_send_request "$@"
+    status=$?
+
+    # Name the call by its route, without the execution ID or keys
+    local path="${2%%\?*}"
+    path="${path//"$CRONIUM_EXEC_ID"/\{id\}}"
+    if [[ "$path" =~ ^(.*/(variables|scratch)/)[^/]+(.*)$ ]]; then
+        path="${BASH_REMATCH[1]}{key}${BASH_REMATCH[3]}"
+    fi
+    printf '%s %s\t%s\t%s\n' "$1" "$path" "$(( $(_cronium_now_us) - start ))" "$status" \
+        >> "$CRONIUM_HELPER_STATS_FILE" 2>/dev/null
+
+    return $status
+}
+
+# Helper function to make API requests with retry logic
+_cronium_send_request() {
     local method="$1"
     local path="$2"
     local data="$3"
@@ -130,8 +164,11 @@ cronium_input() {
 }
 
 # Set output data for this execution
+# Usage: cronium_output <data> [render]
+# render is table, json, markdown or metric, or a JSON hint object
 cronium_output() {
     local data="$1"
+    local render="$2"
     
     # Ensure data is valid JSON
     if ! echo "$data" | jq . >/dev/null 2>&1; then
@@ -139,10 +176,57 @@ cronium_output() {
         data=$(jq -n --arg d "$data" '$d')
     fi
     
-    local payload=$(jq -n --argjson data "$data" '{data: $data}')
+    local payload
+    if [ -z "$render" ]; then
+        payload=$(jq -n --argjson data "$data" '{data: $data}')
+    else
+        if ! echo "$render" | jq -e 'type == "object"' >/dev/null 2>&1; then
+            render=$(jq -n --arg t "$render" '{type: $t}')
+        fi
+        payload=$(jq -n --argjson data "$data" --argjson render "$render" '{data: $data, render: $render}')
+    fi
     _cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/output" "$payload" >/dev/null
 }
 
+# Upload a file as an execution artifact
+# Usage: cronium_upload_file <path> [name] [content-type]
+cronium_upload_file() {
+    local path="$1"
+    local name="${2:-$(basename "$path")}"
+    local content_type="${3:-application/octet-stream}"
+    local encoded_name=$(printf '%s' "$name" | jq -sRr @uri)
+    local temp_file=$(mktemp)
+    local status_code
+
+    if [ ! -f "$path" ]; then
+        rm -f "$temp_file"
+        echo "Error: File not found: $path" >&2
+        return 1
+    fi
+
+    # Uploads stream the file and are not retried
+    status_code=$(curl -s -X POST \
+        -H "Authorization: Bearer $CRONIUM_TOKEN" \
+        -H "Content-Type: $content_type" \
+        -H "Accept: application/json" \
+        --data-binary "@$path" \
+        -w "%{http_code}" \
+        -o "$temp_file" \
+        "${CRONIUM_API}/executions/${CRONIUM_EXEC_ID}/files?name=${encoded_name}")
+
+    local response=$(cat "$temp_file")
+    rm -f "$temp_file"
+
+    if [ "$status_code" -ge 200 ] 2>/dev/null && [ "$status_code" -lt 300 ]; then
+        echo "$response" | jq -c '.data'
+        return 0
+    fi
+
+    local error_msg=$(echo "$response" | jq -r '.message // "Unknown error"' 2>/dev/null || echo "HTTP $status_code")
+    echo "Error: File upload failed - $error_msg" >&2
+    return 1
+}
+
 # Get a variable value
 cronium_get_variable() {
     local key="$1"
@@ -173,6 +257,108 @@ cronium_set_variable() {
     _cronium_request "PUT" "/executions/${CRONIUM_EXEC_ID}/variables/${encoded_key}" "$payload" >/dev/null
 }
 
+# Get a scratch value kept for this execution only
+cronium_scratch_get() {
+    local key="$1"
+    local encoded_key=$(printf '%s' "$key" | jq -sRr @uri)
+    local response
+
+    response=$(_cronium_request "GET" "/executions/${CRONIUM_EXEC_ID}/scratch/${encoded_key}")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -r '.data.value // empty'
+    else
+        return 1
+    fi
+}
+
+# Set a scratch value kept for this execution only; it is never stored as a
+# variable. Setting null removes it.
+cronium_scratch_set() {
+    local key="$1"
+    local value="$2"
+    local encoded_key=$(printf '%s' "$key" | jq -sRr @uri)
+
+    if ! echo "$value" | jq . >/dev/null 2>&1; then
+        value=$(jq -n --arg v "$value" '$v')
+    fi
+
+    local payload=$(jq -n --argjson value "$value" '{value: $value}')
+    _cronium_request "PUT" "/executions/${CRONIUM_EXEC_ID}/scratch/${encoded_key}" "$payload" >/dev/null
+}
+
+# Queue a child job that runs another event; prints the job ID. The child
+# inherits this execution's user and metadata. Input is JSON; an optional
+# idempotency key makes a repeated submission return the same job.
+cronium_submit_job() {
+    local event_id="$1"
+    local input="${2:-null}"
+    local idempotency_key="$3"
+    local response
+
+    if ! echo "$input" | jq . >/dev/null 2>&1; then
+        input=$(jq -n --arg v "$input" '$v')
+    fi
+
+    local payload=$(jq -n --arg eventId "$event_id" --argjson input "$input" --arg key "$idempotency_key" \
+        '{eventId: $eventId, input: $input} + (if $key != "" then {idempotencyKey: $key} else {} end)')
+    response=$(_cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/jobs" "$payload")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -r '.data.id'
+    else
+        return 1
+    fi
+}
+
+# Wait until a child job has finished and print it as JSON. Returns 0 when
+# it completed, 1 when it failed or was cancelled and 2 when it did not
+# finish within the optional timeout in seconds.
+cronium_wait_for_job() {
+    local job_id="$1"
+    local timeout="${2:-0}"
+    local interval="${3:-2}"
+    local encoded_id=$(printf '%s' "$job_id" | jq -sRr @uri)
+    local started=$SECONDS
+    local response status
+
+    while true; do
+        response=$(_cronium_request "GET" "/executions/${CRONIUM_EXEC_ID}/jobs/${encoded_id}") || return 1
+        status=$(echo "$response" | jq -r '.data.status')
+        case "$status" in
+            completed)
+                echo "$response" | jq -c '.data'
+                return 0
+                ;;
+            failed|cancelled)
+                echo "$response" | jq -c '.data'
+                return 1
+                ;;
+        esac
+        if [ "$timeout" -gt 0 ] && [ $((SECONDS - started + interval)) -gt "$timeout" ]; then
+            echo "Error: job $job_id did not finish within ${timeout}s" >&2
+            return 2
+        fi
+        sleep "$interval"
+    done
+}
+
+# Get short-lived credentials for a configured role; prints the credential
+# values as JSON. An optional TTL in seconds bounds how long they last.
+cronium_get_credential() {
+    local provider="$1"
+    local role="$2"
+    local ttl="${3:-0}"
+    local response
+
+    local payload=$(jq -n --arg provider "$provider" --arg role "$role" --argjson ttl "$ttl" \
+        '{provider: $provider, role: $role} + (if $ttl > 0 then {ttl: $ttl} else {} end)')
+    response=$(_cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/credentials" "$payload")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -c '.data.values'
+    else
+        return 1
+    fi
+}
+
 # Set workflow condition
 cronium_set_condition() {
     local condition="$1"
@@ -336,21 +522,66 @@ cronium_delete_variable() {
     cronium_set_variable "$key" "null"
 }
 
-# Utility function to increment a numeric variable
+# Atomically increment a numeric variable and print the new value.
+# Concurrent executions cannot lose each other's increments.
 cronium_increment_variable() {
     local key="$1"
     local increment="${2:-1}"
-    local current=$(cronium_get_variable "$key" 2>/dev/null || echo "0")
-    
-    # Check if current value is numeric
-    if ! [[ "$current" =~ ^-?[0-9]+$ ]]; then
-        echo "Error: Variable '$key' is not numeric" >&2
+    local encoded_key=$(printf '%s' "$key" | jq -sRr @uri)
+    local response
+
+    if ! [[ "$increment" =~ ^-?[0-9]+([.][0-9]+)?$ ]]; then
+        echo "Error: Increment must be a number" >&2
+        return 1
+    fi
+
+    local payload=$(jq -n --argjson by "$increment" '{by: $by}')
+    response=$(_cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/variables/${encoded_key}/increment" "$payload")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -r '.data.value'
+    else
+        return 1
+    fi
+}
+
+# Atomically append a value to a list variable and print the list
+cronium_list_append() {
+    local key="$1"
+    local value="$2"
+    local encoded_key=$(printf '%s' "$key" | jq -sRr @uri)
+    local response
+
+    if ! echo "$value" | jq . >/dev/null 2>&1; then
+        value=$(jq -n --arg v "$value" '$v')
+    fi
+
+    local payload=$(jq -n --argjson value "$value" '{value: $value}')
+    response=$(_cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/variables/${encoded_key}/append" "$payload")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -c '.data.value'
+    else
+        return 1
+    fi
+}
+
+# Atomically add a value to a set variable and print the members
+cronium_set_add() {
+    local key="$1"
+    local value="$2"
+    local encoded_key=$(printf '%s' "$key" | jq -sRr @uri)
+    local response
+
+    if ! echo "$value" | jq . >/dev/null 2>&1; then
+        value=$(jq -n --arg v "$value" '$v')
+    fi
+
+    local payload=$(jq -n --argjson value "$value" '{value: $value}')
+    response=$(_cronium_request "POST" "/executions/${CRONIUM_EXEC_ID}/variables/${encoded_key}/add-to-set" "$payload")
+    if [ $? -eq 0 ]; then
+        echo "$response" | jq -c '.data.value'
+    else
         return 1
     fi
-    
-    local new_value=$((current + increment))
-    cronium_set_variable "$key" "$new_value"
-    echo "$new_value"
 }
 
 # Utility function to append to a string variable
@@ -367,6 +598,83 @@ cronium_append_variable() {
     fi
 }
 
+# Check whether the execution has been cancelled
+# Usage: if cronium_cancelled; then ...; fi
+cronium_cancelled() {
+    [ -n "${CRONIUM_CANCEL_FILE:-}" ] && [ -f "$CRONIUM_CANCEL_FILE" ]
+}
+
+# Register a cleanup command to run when the execution is cancelled. The
+# script exits after the command; it has CRONIUM_CANCEL_GRACE_PERIOD seconds
+# before it is killed.
+# Usage: cronium_on_cancel "cleanup_function"
+cronium_on_cancel() {
+    local handler="$1"
+    trap "$handler; exit 143" TERM INT
+}
+
+# Print the operator messages sent to the execution as JSON lines, oldest
+# first; with --follow, keep printing new ones
+# Usage: cronium_messages --follow | while read -r msg; do ...; done
+cronium_messages() {
+    local file="${CRONIUM_MESSAGES_FILE:-}"
+    [ -n "$file" ] || return 0
+    if [ "${1:-}" = "--follow" ]; then
+        touch "$file" && tail -n +1 -F "$file" 2>/dev/null
+    elif [ -f "$file" ]; then
+        cat "$file"
+    fi
+}
+
+# Print push messages from the runtime as JSON, one per line, until the
+# channel closes: "cancel", "variable" (only the key is sent) and operator
+# "message"s. Messages sent while it is closed are lost. Needs websocat.
+# Usage: cronium_message_channel | while read -r msg; do ...; done
+cronium_message_channel() {
+    if ! command -v websocat &> /dev/null; then
+        echo "Error: cronium_message_channel requires websocat" >&2
+        return 1
+    fi
+    local url="${CRONIUM_API/#http/ws}/executions/${CRONIUM_EXEC_ID}/messages"
+    websocat --text --no-close -H "Authorization: Bearer $CRONIUM_TOKEN" "$url" < /dev/null
+}
+
+# Print the helper calls made so far as JSON: calls, errors, total time and
+# p50/p95 latency per operation. With CRONIUM_HELPER_STATS set, the summary
+# is printed to stderr when the script exits.
+cronium_helper_stats() {
+    [ -s "$CRONIUM_HELPER_STATS_FILE" ] || { echo '{}'; return 0; }
+    jq -R -s '
+        def ms: . * 1000 | round / 1000;
+        def rank($p): .[([($p * length | ceil) - 1, 0] | max)];
+        split("\n")
+        | map(select(length > 0) | split("\t") | {op: .[0], us: (.[1] | tonumber), failed: (.[2] != "0")})
+        | group_by(.op)
+        | map({
+            key: .[0].op,
+            value: ((map(.us / 1000) | sort) as $s | {
+                calls: length,
+                errors: (map(select(.failed)) | length),
+                totalMs: ($s | add | ms),
+                p50Ms: ($s | rank(0.50) | ms),
+                p95Ms: ($s | rank(0.95) | ms)
+            })
+          })
+        | from_entries' "$CRONIUM_HELPER_STATS_FILE"
+}
+
+# Print the helper call stats to stderr, most time spent first
+_cronium_log_helper_stats() {
+    cronium_helper_stats | jq -r '
+        to_entries | sort_by(-.value.totalMs)[]
+        | "cronium: \(.key): \(.value.calls) calls (\(.value.errors) failed), \(.value.totalMs) ms total, p50 \(.value.p50Ms) ms, p95 \(.value.p95Ms) ms"' >&2
+}
+
+# Log the stats at exit unless the script has an exit trap of its own
+if [ -n "${CRONIUM_HELPER_STATS:-}" ] && [ -z "$(trap -p EXIT)" ]; then
+    trap _cronium_log_helper_stats EXIT
+fi
+
 # Print SDK info (useful for debugging)
 cronium_info() {
     echo "Cronium Bash SDK v2.0.0"
@@ -378,8 +686,14 @@ cronium_info() {
 # Export all functions
 export -f cronium_input
 export -f cronium_output
+export -f cronium_upload_file
 export -f cronium_get_variable
 export -f cronium_set_variable
+export -f cronium_scratch_get
+export -f cronium_scratch_set
+export -f cronium_submit_job
+export -f cronium_wait_for_job
+export -f cronium_get_credential
 export -f cronium_set_condition
 export -f cronium_event
 export -f cronium_event_field
@@ -391,5 +705,14 @@ export -f cronium_variable_exists
 export -f cronium_delete_variable
 export -f cronium_increment_variable
 export -f cronium_append_variable
+export -f cronium_list_append
+export -f cronium_set_add
+export -f cronium_cancelled
+export -f cronium_on_cancel
+export -f cronium_messages
+export -f cronium_message_channel
+export -f cronium_helper_stats
 export -f cronium_info
+export -f _cronium_now_us
+export -f _cronium_send_request
 export -f _cronium_request
\ No newline at end of file
@@ -36,6 +36,95 @@ export interface EventContext {
   [key: string]: any;
 }
 
+/**
+ * File upload options
+ */
+export interface UploadFileOptions {
+  name?: string;
+  contentType?: string;
+}
+
+/**
+ * How front-ends should display an output
+ */
+export interface RenderHint {
+  type: "table" | "json" | "markdown" | "metric";
+  /** Column order of a table */
+  columns?: string[];
+  /** Label and unit of a metric */
+  label?: string;
+  unit?: string;
+}
+
+/**
+ * Calls to one helper operation and their latency
+ */
+export interface HelperCallStats {
+  calls: number;
+  errors: number;
+  totalMs: number;
+  p50Ms: number;
+  p95Ms: number;
+}
+
+/**
+ * Execution artifact registered by an upload
+ */
+export interface Artifact {
+  id: string;
+  name: string;
+  size: number;
+  mimeType: string;
+  checksum: string;
+  createdAt: string;
+}
+
+/**
+ * Options for submitting a child job
+ */
+export interface SubmitJobOptions {
+  /** Metadata added to the metadata inherited from this execution */
+  metadata?: Record<string, any>;
+  /** Submitting again with the same key returns the same job */
+  idempotencyKey?: string;
+}
+
+/**
+ * Options for waiting on a child job
+ */
+export interface WaitForJobOptions {
+  /** Milliseconds to wait at most; no limit when omitted */
+  timeout?: number;
+  /** Milliseconds between status checks (default 2000) */
+  pollInterval?: number;
+}
+
+/**
+ * Child job submitted by this execution
+ */
+export interface Job {
+  id: string;
+  eventId: string;
+  parentExecutionId: string;
+  executionId?: string;
+  status: "queued" | "claimed" | "running" | "completed" | "failed" | "cancelled";
+  output?: any;
+  error?: string;
+  createdAt: string;
+  completedAt?: string;
+}
+
+/**
+ * Short-lived credentials minted for the execution
+ */
+export interface Credential {
+  provider: string;
+  role: string;
+  /** accessKeyId, secretAccessKey and sessionToken for aws; the secret's fields for vault */
+  values: Record<string, string>;
+  expiresAt: string;
+}
+
 /**
  * Email options
  */
@@ -77,6 +166,25 @@ export interface ToolActionConfig {
   [key: string]: any;
 }
 
+/**
+ * Message pushed by the runtime while the script runs
+ */
+export interface PushMessage {
+  type: "cancel" | "variable" | "message";
+  /** Set on operator messages */
+  id?: string;
+  jobId?: string;
+  /** Cancellation reason */
+  reason?: string;
+  /** Variable that changed; read it with getVariable */
+  key?: string;
+  /** Operator message and its optional payload */
+  message?: string;
+  data?: any;
+  source?: string;
+  timestamp: string;
+}
+
 /**
  * Main Cronium client class
  */
@@ -91,7 +199,12 @@ export declare class Cronium {
   /**
    * Set output data for this execution
    */
-  output(data: any): Promise<void>;
+  output(data: any, render?: RenderHint["type"] | RenderHint): Promise<void>;
+
+  /**
+   * Upload a file as an execution artifact
+   */
+  uploadFile(filePath: string, options?: UploadFileOptions): Promise<Artifact>;
 
   /**
    * Get a variable value
@@ -103,6 +216,57 @@ export declare class Cronium {
    */
   setVariable(key: string, value: any): Promise<void>;
 
+  /**
+   * Atomically add to a numeric variable and return the new value
+   */
+  incrementVariable(key: string, by?: number): Promise<number>;
+
+  /**
+   * Atomically append a value to a list variable and return the list
+   */
+  appendToList(key: string, value: any): Promise<any[]>;
+
+  /**
+   * Atomically add a value to a set variable; resolves to whether it was new
+   */
+  addToSet(key: string, value: any): Promise<boolean>;
+
+  /**
+   * Get a scratch value kept for this execution only
+   */
+  scratchGet(key: string): Promise<any>;
+
+  /**
+   * Set a scratch value kept for this execution only; null removes it
+   */
+  scratchSet(key: string, value: any): Promise<void>;
+
+  /**
+   * Queue a child job that runs another event and inherits this execution's
+   * user and metadata
+   */
+  submitJob(
+    eventId: number | string,
+    input?: any,
+    options?: SubmitJobOptions,
+  ): Promise<Job>;
+
+  /**
+   * Get a child job's status, and its output once it has finished
+   */
+  getJob(jobId: string): Promise<Job>;
+
+  /**
+   * Wait until a child job has completed, failed or been cancelled; rejects
+   * with CroniumTimeoutError after options.timeout
+   */
+  waitForJob(jobId: string, options?: WaitForJobOptions): Promise<Job>;
+
+  /**
+   * Get short-lived credentials for a configured role; ttl is in seconds
+   */
+  getCredential(provider: string, role: string, ttl?: number): Promise<Credential>;
+
   /**
    * Set the workflow condition
    */
@@ -136,6 +300,31 @@ export declare class Cronium {
    * Send a Discord message
    */
   sendDiscordMessage(options: DiscordOptions): Promise<any>;
+
+  /**
+   * Check whether the execution has been cancelled
+   */
+  cancelled(): boolean;
+
+  /**
+   * Register a cleanup handler to run when the execution is cancelled
+   */
+  onCancel(handler: () => void | Promise<void>): void;
+
+  /**
+   * Get the operator messages sent so far, oldest first
+   */
+  messages(): PushMessage[];
+
+  /**
+   * Receive push messages; returns a function that stops listening
+   */
+  onMessage(handler: (message: PushMessage) => void): () => void;
+
+  /**
+   * Get the helper calls made so far, per operation
+   */
+  helperStats(): Record<string, HelperCallStats>;
 }
 
 /**
@@ -147,9 +336,36 @@ export declare const cronium: Cronium;
  * Convenience functions
  */
 export declare function input(): Promise<any>;
-export declare function output(data: any): Promise<void>;
+export declare function output(
+  data: any,
+  render?: RenderHint["type"] | RenderHint,
+): Promise<void>;
+export declare function uploadFile(
+  filePath: string,
+  options?: UploadFileOptions,
+): Promise<Artifact>;
 export declare function getVariable(key: string): Promise<any>;
 export declare function setVariable(key: string, value: any): Promise<void>;
+export declare function incrementVariable(key: string, by?: number): Promise<number>;
+export declare function appendToList(key: string, value: any): Promise<any[]>;
+export declare function addToSet(key: string, value: any): Promise<boolean>;
+export declare function scratchGet(key: string): Promise<any>;
+export declare function scratchSet(key: string, value: any): Promise<void>;
+export declare function submitJob(
+  eventId: number | string,
+  input?: any,
+  options?: SubmitJobOptions,
+): Promise<Job>;
+export declare function getJob(jobId: string): Promise<Job>;
+export declare function waitForJob(
+  jobId: string,
+  options?: WaitForJobOptions,
+): Promise<Job>;
+export declare function getCredential(
+  provider: string,
+  role: string,
+  ttl?: number,
+): Promise<Credential>;
 export declare function setCondition(condition: boolean): Promise<void>;
 export declare function event(): Promise<EventContext>;
 export declare function executeToolAction(
@@ -162,5 +378,12 @@ export declare function sendSlackMessage(options: SlackOptions): Promise<any>;
 export declare function sendDiscordMessage(
   options: DiscordOptions,
 ): Promise<any>;
+export declare function cancelled(): boolean;
+export declare function onCancel(handler: () => void | Promise<void>): void;
+export declare function messages(): PushMessage[];
+export declare function onMessage(
+  handler: (message: PushMessage) => void,
+): () => void;
+export declare function helperStats(): Record<string, HelperCallStats>;
 
 export default Cronium;
@@ -6,10 +6,19 @@
  * variables, input/output data, and tool actions.
  */
 
+const fs = require("fs");
+const crypto = require("crypto");
+const path = require("path");
 const http = require("http");
 const https = require("https");
 const { URL } = require("url");
 
+// Job statuses after which a child job will not change any more
+const FINISHED_JOB_STATUSES = ["completed", "failed", "cancelled"];
+
+// Appended to the WebSocket key to compute the handshake answer
+const WEBSOCKET_GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11";
+
 /**
  * Base error class for Cronium SDK errors
  */
@@ -41,6 +50,90 @@ class CroniumTimeoutError extends CroniumError {
   }
 }
 
+/**
+ * Counts helper calls and their latencies by operation. Beyond MAX_SAMPLES
+ * calls of an operation, percentiles come from a uniform sample of them.
+ * @private
+ */
+class HelperStats {
+  static MAX_SAMPLES = 1000;
+
+  constructor() {
+    this.operations = new Map();
+  }
+
+  record(operation, ms, failed) {
+    let op = this.operations.get(operation);
+    if (!op) {
+      op = { calls: 0, errors: 0, totalMs: 0, samples: [] };
+      this.operations.set(operation, op);
+    }
+    op.calls++;
+    op.totalMs += ms;
+    if (failed) {
+      op.errors++;
+    }
+    if (op.samples.length < HelperStats.MAX_SAMPLES) {
+      op.samples.push(ms);
+    } else {
+      const i = Math.floor(Math.random() * op.calls);
+      if (i < HelperStats.MAX_SAMPLES) {
+        op.samples[i] = ms;
+      }
+    }
+  }
+
+  summary() {
+    const result = {};
+    for (const [name, op] of this.operations) {
+      const samples = [...op.samples].sort((a, b) => a - b);
+      result[name] = {
+        calls: op.calls,
+        errors: op.errors,
+        totalMs: round(op.totalMs),
+        p50Ms: round(percentile(samples, 0.5)),
+        p95Ms: round(percentile(samples, 0.95)),
+      };
+    }
+    return result;
+  }
+}
+
+/**
+ * Nearest-rank percentile of sorted samples
+ * @private
+ */
+function percentile(sorted, p) {
+  if (sorted.length === 0) {
+    return 0;
+  }
+  const i = Math.max(Math.ceil(p * sorted.length) - 1, 0);
+  return sorted[Math.min(i, sorted.length - 1)];
+}
+
+function round(ms) {
+  return Math.round(ms * 1000) / 1000;
+}
+
+/**
+ * Parse JSON lines, skipping invalid ones
+ * @private
+ */
+function parseMessageLines(text) {
+  const messages = [];
+  for (const line of text.split("\n")) {
+    if (!line.trim()) {
+      continue;
+    }
+    try {
+      messages.push(JSON.parse(line));
+    } catch (error) {
+      console.error("Skipped an invalid line in the messages file");
+    }
+  }
+  return messages;
+}
+
 /**
  * Main Cronium client class
  */
@@ -69,13 +162,81 @@ class Cronium {
     this.maxRetries = 3;
     this.retryDelay = 1000; // ms
     this.timeout = 30000; // ms
+
+    // Cancellation
+    this.cancelFile = process.env.CRONIUM_CANCEL_FILE;
+    this.cancelRequested = false;
+
+    // Operator messages are also appended to a file the executor writes
+    this.messagesFile = process.env.CRONIUM_MESSAGES_FILE;
+
+    // Helper call stats, logged at exit with CRONIUM_HELPER_STATS set
+    this.stats = new HelperStats();
+    if (process.env.CRONIUM_HELPER_STATS) {
+      process.on("exit", () => this._logHelperStats());
+    }
   }
 
   /**
-   * Make an HTTP request to the Runtime API with retry logic
+   * Name a request by its route, without the execution ID or keys
+   * @private
+   */
+  _operation(method, path) {
+    const route = path
+      .split("?")[0]
+      .replace(this.executionId, "{id}")
+      .replace(/\/(variables|scratch)\/[^/]+/, "/$1/{key}")
+      .replace(/\/jobs\/[^/]+/, "/jobs/{jobId}");
+    return `${method} ${route}`;
+  }
+
+  /**
+   * Make a request, counting it in the helper call stats
    * @private
    */
   async _makeRequest(method, path, data = null) {
+    const start = process.hrtime.bigint();
+    let failed = false;
+    try {
+      return await this._sendRequest(method, path, data);
+    } catch (error) {
+      failed = true;
+      throw error;
+    } finally {
+      const ms = Number(process.hrtime.bigint() - start) / 1e6;
+      this.stats.record(this._operation(method, path), ms, failed);
+    }
+  }
+
+  /**
+   * Get the helper calls this script has made so far
+   * @returns {Object} Per operation: calls, errors, totalMs, p50Ms and p95Ms
+   */
+  helperStats() {
+    return this.stats.summary();
+  }
+
+  /**
+   * @private
+   */
+  _logHelperStats() {
+    const entries = Object.entries(this.stats.summary()).sort(
+      (a, b) => b[1].totalMs - a[1].totalMs,
+    );
+    for (const [operation, s] of entries) {
+      process.stderr.write(
+        `cronium: ${operation}: ${s.calls} calls (${s.errors} failed), ` +
+          `${s.totalMs.toFixed(1)} ms total, p50 ${s.p50Ms.toFixed(1)} ms, ` +
+          `p95 ${s.p95Ms.toFixed(1)} ms\n`,
+      );
+    }
+  }
+
+  /**
+   * Make an HTTP request to the Runtime API with retry logic
+   * @private
+   */
+  async _sendRequest(method, path, data = null) {
     const url = new URL(path, this.apiUrl);
 
     for (let attempt = 0; attempt < this.maxRetries; attempt++) {
@@ -200,11 +361,87 @@ class Cronium {
   /**
    * Set output data for this execution
    * @param {any} data - The output data
+   * @param {string|Object} [render] - How front-ends should display it:
+   *   "table", "json", "markdown" or "metric", or an object such as
+   *   { type: "metric", label: "Backlog", unit: "jobs" }
    * @returns {Promise<void>}
    */
-  async output(data) {
-    await this._makeRequest("POST", `/executions/${this.executionId}/output`, {
-      data,
+  async output(data, render) {
+    const body = { data };
+    if (render) {
+      body.render = typeof render === "string" ? { type: render } : render;
+    }
+    await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/output`,
+      body,
+    );
+  }
+
+  /**
+   * Upload a file as an execution artifact. The file is streamed from disk,
+   * so the upload is not retried.
+   * @param {string} filePath - Path of the file to upload
+   * @param {Object} [options]
+   * @param {string} [options.name] - Artifact name (defaults to the file's base name)
+   * @param {string} [options.contentType] - MIME type (detected by the runtime if omitted)
+   * @returns {Promise<Object>} The registered artifact
+   */
+  async uploadFile(filePath, options = {}) {
+    const name = options.name || path.basename(filePath);
+    const url = new URL(
+      `/executions/${this.executionId}/files?name=${encodeURIComponent(name)}`,
+      this.apiUrl,
+    );
+    const { size } = await fs.promises.stat(filePath);
+
+    return new Promise((resolve, reject) => {
+      const req = this.httpModule.request(
+        {
+          hostname: url.hostname,
+          port: url.port || (url.protocol === "https:" ? 443 : 80),
+          path: url.pathname + url.search,
+          method: "POST",
+          headers: {
+            Authorization: `Bearer ${this.token}`,
+            "Content-Type": options.contentType || "application/octet-stream",
+            "Content-Length": size,
+            Accept: "application/json",
+          },
+        },
+        (res) => {
+          let responseData = "";
+          res.on("data", (chunk) => {
+            responseData += chunk;
+          });
+          res.on("end", () => {
+            let parsed = null;
+            try {
+              parsed = responseData ? JSON.parse(responseData) : null;
+            } catch (e) {
+              reject(new CroniumError(`Failed to parse response: ${e.message}`));
+              return;
+            }
+            if (res.statusCode >= 400) {
+              const message = parsed?.message || `HTTP ${res.statusCode}`;
+              reject(new CroniumAPIError(res.statusCode, message));
+              return;
+            }
+            resolve(parsed?.data || null);
+          });
+        },
+      );
+
+      req.on("error", (error) => {
+        reject(new CroniumError(`File upload failed: ${error.message}`));
+      });
+
+      const stream = fs.createReadStream(filePath);
+      stream.on("error", (error) => {
+        req.destroy();
+        reject(new CroniumError(`Failed to read file: ${error.message}`));
+      });
+      stream.pipe(req);
     });
   }
 
@@ -242,6 +479,171 @@ class Cronium {
     );
   }
 
+  /**
+   * Atomically add to a numeric variable. Concurrent executions cannot lose
+   * each other's increments; a missing variable counts as zero.
+   * @param {string} key - The variable key
+   * @param {number} [by=1] - The amount to add
+   * @returns {Promise<number>} The new value
+   */
+  async incrementVariable(key, by = 1) {
+    const result = await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/variables/${encodeURIComponent(key)}/increment`,
+      { by },
+    );
+    return result?.data?.value;
+  }
+
+  /**
+   * Atomically append a value to a list variable
+   * @param {string} key - The variable key
+   * @param {any} value - The value to append
+   * @returns {Promise<any[]>} The list after appending
+   */
+  async appendToList(key, value) {
+    const result = await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/variables/${encodeURIComponent(key)}/append`,
+      { value },
+    );
+    return result?.data?.value || [];
+  }
+
+  /**
+   * Atomically add a value to a set variable, stored as a list without
+   * duplicates
+   * @param {string} key - The variable key
+   * @param {any} value - The value to add
+   * @returns {Promise<boolean>} Whether the value was new
+   */
+  async addToSet(key, value) {
+    const result = await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/variables/${encodeURIComponent(key)}/add-to-set`,
+      { value },
+    );
+    return Boolean(result?.data?.added);
+  }
+
+  /**
+   * Get a scratch value kept for this execution only
+   * @param {string} key - The scratch key
+   * @returns {Promise<any>} The value, or null if not set
+   */
+  async scratchGet(key) {
+    const result = await this._makeRequest(
+      "GET",
+      `/executions/${this.executionId}/scratch/${encodeURIComponent(key)}`,
+    );
+    return result?.data?.value ?? null;
+  }
+
+  /**
+   * Set a scratch value kept for this execution only. Scratch values suit
+   * transient state such as loop cursors: they are never stored as variables
+   * and expire with the execution.
+   * @param {string} key - The scratch key
+   * @param {any} value - The value to store; null removes the key
+   * @returns {Promise<void>}
+   */
+  async scratchSet(key, value) {
+    await this._makeRequest(
+      "PUT",
+      `/executions/${this.executionId}/scratch/${encodeURIComponent(key)}`,
+      { value },
+    );
+  }
+
+  /**
+   * Queue a child job that runs another event. The child runs as the same
+   * user and inherits this execution's metadata, linked to it as its parent.
+   * @param {number|string} eventId - The event the child job runs
+   * @param {any} [input] - Input data for the child job
+   * @param {Object} [options] - Submission options
+   * @param {Object} [options.metadata] - Metadata added to the inherited metadata
+   * @param {string} [options.idempotencyKey] - Submitting again with the same key returns the same job
+   * @returns {Promise<Object>} The job, with its id and status
+   */
+  async submitJob(eventId, input, options = {}) {
+    const body = { eventId: String(eventId) };
+    if (input !== undefined) {
+      body.input = input;
+    }
+    if (options.metadata) {
+      body.metadata = options.metadata;
+    }
+    if (options.idempotencyKey) {
+      body.idempotencyKey = options.idempotencyKey;
+    }
+    const result = await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/jobs`,
+      body,
+    );
+    return result?.data ?? null;
+  }
+
+  /**
+   * Get a child job's status, and its output once it has finished
+   * @param {string} jobId - The ID returned by submitJob
+   * @returns {Promise<Object>} The job
+   */
+  async getJob(jobId) {
+    const result = await this._makeRequest(
+      "GET",
+      `/executions/${this.executionId}/jobs/${encodeURIComponent(jobId)}`,
+    );
+    return result?.data ?? null;
+  }
+
+  /**
+   * Wait until a child job has completed, failed or been cancelled
+   * @param {string} jobId - The ID returned by submitJob
+   * @param {Object} [options] - Wait options
+   * @param {number} [options.timeout] - Milliseconds to wait at most; no limit when omitted
+   * @param {number} [options.pollInterval=2000] - Milliseconds between status checks
+   * @returns {Promise<Object>} The finished job with its status and output or error
+   */
+  async waitForJob(jobId, options = {}) {
+    const { timeout, pollInterval = 2000 } = options;
+    const deadline = timeout !== undefined ? Date.now() + timeout : undefined;
+    for (;;) {
+      const job = await this.getJob(jobId);
+      if (FINISHED_JOB_STATUSES.includes(job?.status)) {
+        return job;
+      }
+      if (deadline !== undefined && Date.now() + pollInterval > deadline) {
+        throw new CroniumTimeoutError(
+          `Job ${jobId} did not finish within ${timeout}ms`,
+        );
+      }
+      await new Promise((resolve) => setTimeout(resolve, pollInterval));
+    }
+  }
+
+  /**
+   * Get short-lived credentials for a role configured in the runtime. They
+   * are minted for this execution only and are revoked or expire when it
+   * ends.
+   * @param {string} provider - The credential provider, such as "aws" or "vault"
+   * @param {string} role - The role name
+   * @param {number} [ttl] - How long the credentials should last in seconds
+   * @returns {Promise<Object>} The provider's values and expiresAt
+   */
+  async getCredential(provider, role, ttl) {
+    const body = { provider, role };
+    if (ttl) {
+      body.ttl = ttl;
+    }
+    const result = await this._makeRequest(
+      "POST",
+      `/executions/${this.executionId}/credentials`,
+      body,
+    );
+    return result?.data ?? null;
+  }
+
   /**
    * Set the workflow condition
    * @param {boolean} condition - The condition value
@@ -325,6 +727,278 @@ class Cronium {
     };
     return this.executeToolAction("discord", "send_message", config);
   }
+
+  /**
+   * Check whether the execution has been cancelled
+   * @returns {boolean} True once cancellation was requested
+   */
+  cancelled() {
+    if (this.cancelRequested) {
+      return true;
+    }
+    return Boolean(this.cancelFile) && fs.existsSync(this.cancelFile);
+  }
+
+  /**
+   * Register a cleanup handler to run when the execution is cancelled. The
+   * process exits once the handler settles; it has
+   * CRONIUM_CANCEL_GRACE_PERIOD seconds before it is killed.
+   * @param {Function} handler - Cleanup function, may return a Promise
+   */
+  onCancel(handler) {
+    const run = (signal) => {
+      this.cancelRequested = true;
+      const code = signal === "SIGINT" ? 130 : 143;
+      Promise.resolve()
+        .then(() => handler())
+        .catch((error) => console.error("Cancel handler failed:", error))
+        .finally(() => process.exit(code));
+    };
+    process.once("SIGTERM", run);
+    process.once("SIGINT", run);
+  }
+
+  /**
+   * Get the operator messages sent to the execution so far, oldest first,
+   * from the messages file the executor appends them to
+   * @returns {Array<Object>} Messages
+   */
+  messages() {
+    if (!this.messagesFile || !fs.existsSync(this.messagesFile)) {
+      return [];
+    }
+    return parseMessageLines(fs.readFileSync(this.messagesFile, "utf8"));
+  }
+
+  /**
+   * Call a handler for every push message the runtime sends: "cancel" when
+   * the job is being cancelled, "variable" when one of the user's variables
+   * changed, and "message" for messages from an operator. Operator messages
+   * are also read from the messages file, which replays those sent earlier
+   * and keeps them coming while the channel is down; each is handled once.
+   * The channel is reopened when it drops. A cancel message also makes
+   * cancelled() return true.
+   * @param {Function} handler - Called with each message object
+   * @returns {Function} Stops listening
+   */
+  onMessage(handler) {
+    let stopped = false;
+    let socket = null;
+    let delay = this.retryDelay;
+    let offset = 0;
+    let partial = "";
+    const seen = new Set();
+
+    const deliver = (message) => {
+      if (message.id) {
+        if (seen.has(message.id)) {
+          return;
+        }
+        seen.add(message.id);
+      }
+      if (message.type === "cancel") {
+        this.cancelRequested = true;
+      }
+      try {
+        handler(message);
+      } catch (error) {
+        console.error("Message handler failed:", error);
+      }
+    };
+    // Deliver the complete lines added to the messages file since last time
+    const readFile = () => {
+      if (!this.messagesFile || !fs.existsSync(this.messagesFile)) {
+        return;
+      }
+      const size = fs.statSync(this.messagesFile).size;
+      if (size <= offset) {
+        return;
+      }
+      const buffer = Buffer.alloc(size - offset);
+      const fd = fs.openSync(this.messagesFile, "r");
+      fs.readSync(fd, buffer, 0, buffer.length, offset);
+      fs.closeSync(fd);
+      offset = size;
+      const text = partial + buffer.toString("utf8");
+      const end = text.lastIndexOf("\n") + 1;
+      partial = text.slice(end);
+      parseMessageLines(text.slice(0, end)).forEach(deliver);
+    };
+
+    const connect = () => {
+      if (stopped) {
+        return;
+      }
+      this._openMessageChannel((message) => {
+        delay = this.retryDelay;
+        deliver(message);
+      })
+        .then((s) => {
+          socket = s;
+          s.on("close", () => {
+            socket = null;
+            retry();
+          });
+        })
+        .catch((error) => {
+          if (
+            error instanceof CroniumAPIError &&
+            [401, 403, 404].includes(error.statusCode)
+          ) {
+            console.error("Message channel unavailable:", error.message);
+            return;
+          }
+          retry();
+        });
+    };
+    const retry = () => {
+      if (stopped) {
+        return;
+      }
+      // Do not keep the process alive just to reconnect
+      setTimeout(connect, delay).unref();
+      delay = Math.min(delay * 2, 30000);
+    };
+
+    // The file is followed throughout, so operator messages arrive even
+    // while the channel is down
+    readFile();
+    const timer = setInterval(readFile, 500);
+    timer.unref();
+    connect();
+    return () => {
+      stopped = true;
+      clearInterval(timer);
+      if (socket) {
+        socket.destroy();
+      }
+    };
+  }
+
+  /**
+   * Open the message channel, a WebSocket on which the runtime sends JSON
+   * text frames and pings and expects only pongs back
+   * @private
+   */
+  _openMessageChannel(onMessage) {
+    const url = new URL(
+      `/executions/${this.executionId}/messages`,
+      this.apiUrl,
+    );
+    const key = crypto.randomBytes(16).toString("base64");
+
+    return new Promise((resolve, reject) => {
+      const req = this.httpModule.request(url, {
+        headers: {
+          Authorization: `Bearer ${this.token}`,
+          Connection: "Upgrade",
+          Upgrade: "websocket",
+          "Sec-WebSocket-Key": key,
+          "Sec-WebSocket-Version": "13",
+        },
+        timeout: this.timeout,
+      });
+
+      req.on("upgrade", (res, socket, head) => {
+        const accept = crypto
+          .createHash("sha1")
+          .update(key + WEBSOCKET_GUID)
+          .digest("base64");
+        if (res.headers["sec-websocket-accept"] !== accept) {
+          socket.destroy();
+          reject(new CroniumError("Message channel handshake failed"));
+          return;
+        }
+        // Messages may be far apart; the runtime's pings keep it alive
+        socket.setTimeout(0);
+        socket.unref();
+
+        const send = (opcode, payload) => {
+          // Client frames are masked; control frames are short
+          const mask = crypto.randomBytes(4);
+          const masked = Buffer.from(payload.map((b, i) => b ^ mask[i % 4]));
+          socket.write(
+            Buffer.concat([
+              Buffer.from([0x80 | opcode, 0x80 | payload.length]),
+              mask,
+              masked,
+            ]),
+          );
+        };
+
+        let buffer = head;
+        let fragments = [];
+        const parse = () => {
+          while (buffer.length >= 2) {
+            const fin = buffer[0] & 0x80;
+            const opcode = buffer[0] & 0x0f;
+            let length = buffer[1] & 0x7f;
+            let offset = 2;
+            if (length === 126) {
+              if (buffer.length < 4) return;
+              length = buffer.readUInt16BE(2);
+              offset = 4;
+            } else if (length === 127) {
+              if (buffer.length < 10) return;
+              length = Number(buffer.readBigUInt64BE(2));
+              offset = 10;
+            }
+            if (buffer.length < offset + length) return;
+            const payload = buffer.subarray(offset, offset + length);
+            buffer = buffer.subarray(offset + length);
+
+            if (opcode === 0x8) {
+              send(0x8, payload.subarray(0, 2));
+              socket.end();
+              return;
+            }
+            if (opcode === 0x9) {
+              send(0xa, payload);
+            } else if (opcode === 0x0 || opcode === 0x1) {
+              fragments.push(payload);
+              if (fin) {
+                const text = Buffer.concat(fragments).toString("utf8");
+                fragments = [];
+                try {
+                  onMessage(JSON.parse(text));
+                } catch (error) {
+                  console.error("Invalid push message:", error.message);
+                }
+              }
+            }
+          }
+        };
+
+        socket.on("data", (chunk) => {
+          buffer = Buffer.concat([buffer, chunk]);
+          parse();
+        });
+        socket.on("error", () => socket.destroy());
+        resolve(socket);
+        parse();
+      });
+
+      req.on("response", (res) => {
+        let body = "";
+        res.on("data", (chunk) => (body += chunk));
+        res.on("end", () => {
+          let message = res.statusMessage;
+          try {
+            const parsed = JSON.parse(body);
+            message = parsed.message || parsed.error || message;
+          } catch (e) {
+            // Not JSON; keep the status text
+          }
+          reject(new CroniumAPIError(res.statusCode, message));
+        });
+      });
+      req.on("timeout", () => {
+        req.destroy(new CroniumTimeoutError("Message channel timed out"));
+      });
+      req.on("error", reject);
+      req.end();
+    });
+  }
 }
 
 // Create singleton instance
@@ -336,9 +1010,24 @@ module.exports.cronium = cronium;
 
 // Export convenience functions
 module.exports.input = () => cronium.input();
-module.exports.output = (data) => cronium.output(data);
+module.exports.output = (data, render) => cronium.output(data, render);
+module.exports.uploadFile = (filePath, options) =>
+  cronium.uploadFile(filePath, options);
 module.exports.getVariable = (key) => cronium.getVariable(key);
 module.exports.setVariable = (key, value) => cronium.setVariable(key, value);
+module.exports.incrementVariable = (key, by) =>
+  cronium.incrementVariable(key, by);
+module.exports.appendToList = (key, value) => cronium.appendToList(key, value);
+module.exports.addToSet = (key, value) => cronium.addToSet(key, value);
+module.exports.scratchGet = (key) => cronium.scratchGet(key);
+module.exports.scratchSet = (key, value) => cronium.scratchSet(key, value);
+module.exports.submitJob = (eventId, input, options) =>
+  cronium.submitJob(eventId, input, options);
+module.exports.getJob = (jobId) => cronium.getJob(jobId);
+module.exports.waitForJob = (jobId, options) =>
+  cronium.waitForJob(jobId, options);
+module.exports.getCredential = (provider, role, ttl) =>
+  cronium.getCredential(provider, role, ttl);
 module.exports.setCondition = (condition) => cronium.setCondition(condition);
 module.exports.event = () => cronium.event();
 module.exports.executeToolAction = (tool, action, config) =>
@@ -348,6 +1037,11 @@ module.exports.sendSlackMessage = (options) =>
   cronium.sendSlackMessage(options);
 module.exports.sendDiscordMessage = (options) =>
   cronium.sendDiscordMessage(options);
+module.exports.cancelled = () => cronium.cancelled();
+module.exports.onCancel = (handler) => cronium.onCancel(handler);
+module.exports.messages = () => cronium.messages();
+module.exports.onMessage = (handler) => cronium.onMessage(handler);
+module.exports.helperStats = () => cronium.helperStats();
 
 // Export error classes
 module.exports.CroniumError = CroniumError;
@@ -7,14 +7,25 @@ variables, input/output data, and tool actions.
 """
 
 import os
+import re
+import sys
+import math
 import json
 import time
+import atexit
+import random
 import asyncio
-from typing import Any, Dict, Optional, Union, AsyncIterator
+import base64
+import hashlib
+import socket
+import struct
+import threading
+from typing import Any, Dict, Optional, Union, AsyncIterator, Iterator
 from urllib.request import Request, urlopen
 from urllib.error import HTTPError, URLError
-from urllib.parse import urljoin, quote
+from urllib.parse import urljoin, urlparse, quote
 import ssl
+import signal
 import logging
 
 # Set up logging
@@ -40,6 +51,206 @@ class CroniumTimeoutError(CroniumError):
     pass
 
 
+# Job statuses after which a child job will not change any more
+_FINISHED_JOB_STATUSES = ("completed", "failed", "cancelled")
+
+
+def _output_payload(data: Any, render: Optional[Union[str, Dict[str, Any]]]) -> Dict[str, Any]:
+    """Build an output request, accepting a render hint as a bare type name."""
+    payload: Dict[str, Any] = {"data": data}
+    if isinstance(render, str):
+        payload["render"] = {"type": render}
+    elif render is not None:
+        payload["render"] = render
+    return payload
+
+
+class _HelperStats:
+    """
+    Counts helper calls and their latencies by operation. Beyond
+    _MAX_SAMPLES calls of an operation, percentiles come from a uniform
+    sample of them.
+    """
+    
+    _MAX_SAMPLES = 1000
+    
+    def __init__(self):
+        self._operations: Dict[str, Dict[str, Any]] = {}
+    
+    def record(self, operation: str, seconds: float, failed: bool) -> None:
+        op = self._operations.setdefault(operation, {"calls": 0, "errors": 0, "total": 0.0, "samples": []})
+        op["calls"] += 1
+        op["total"] += seconds
+        if failed:
+            op["errors"] += 1
+        if len(op["samples"]) < self._MAX_SAMPLES:
+            op["samples"].append(seconds)
+        else:
+            i = random.randrange(op["calls"])
+            if i < self._MAX_SAMPLES:
+                op["samples"][i] = seconds
+    
+    def summary(self) -> Dict[str, Dict[str, float]]:
+        result = {}
+        for name, op in self._operations.items():
+            samples = sorted(op["samples"])
+            result[name] = {
+                "calls": op["calls"],
+                "errors": op["errors"],
+                "totalMs": round(op["total"] * 1000, 3),
+                "p50Ms": round(_percentile(samples, 0.50) * 1000, 3),
+                "p95Ms": round(_percentile(samples, 0.95) * 1000, 3),
+            }
+        return result
+
+
+def _percentile(sorted_samples: list, p: float) -> float:
+    """Nearest-rank percentile of sorted samples."""
+    if not sorted_samples:
+        return 0.0
+    i = max(math.ceil(p * len(sorted_samples)) - 1, 0)
+    return sorted_samples[min(i, len(sorted_samples) - 1)]
+
+
+class _MessageFile:
+    """
+    Reads the operator messages appended to CRONIUM_MESSAGES_FILE, one JSON
+    object per line, remembering how far it got.
+    """
+    
+    def __init__(self, path: Optional[str]):
+        self.path = path
+        self._offset = 0
+        self._partial = b""
+    
+    def read(self) -> list:
+        """Return the complete messages added since the last read."""
+        if not self.path or not os.path.exists(self.path):
+            return []
+        with open(self.path, "rb") as f:
+            f.seek(self._offset)
+            data = self._partial + f.read()
+            self._offset = f.tell()
+        *lines, self._partial = data.split(b"\n")
+        messages = []
+        for line in lines:
+            if not line.strip():
+                continue
+            try:
+                messages.append(json.loads(line))
+            except ValueError:
+                logger.warning(f"Skipped an invalid line in the messages file: {line[:100]!r}")
+        return messages
+
+
+class _MessageChannel:
+    """
+    Minimal WebSocket client for the runtime's message channel. The runtime
+    only sends JSON text frames, pings and a close frame, and expects nothing
+    but pongs back, so that is all this handles.
+    """
+    
+    _GUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+    
+    def __init__(self, url: str, token: str, ssl_context: ssl.SSLContext, timeout: float):
+        parsed = urlparse(url)
+        secure = parsed.scheme == "https"
+        host = parsed.hostname or "localhost"
+        port = parsed.port or (443 if secure else 80)
+        path = parsed.path or "/"
+        
+        sock = socket.create_connection((host, port), timeout=timeout)
+        if secure:
+            sock = ssl_context.wrap_socket(sock, server_hostname=host)
+        self._sock = sock
+        self._file = sock.makefile("rb")
+        
+        key = base64.b64encode(os.urandom(16)).decode()
+        sock.sendall((
+            f"GET {path} HTTP/1.1\r\n"
+            f"Host: {parsed.netloc}\r\n"
+            "Upgrade: websocket\r\n"
+            "Connection: Upgrade\r\n"
+            f"Sec-WebSocket-Key: {key}\r\n"
+            "Sec-WebSocket-Version: 13\r\n"
+            f"Authorization: Bearer {token}\r\n"
+            "\r\n"
+        ).encode())
+        
+        status = self._file.readline().decode("latin-1").split(" ", 2)
+        headers = {}
+        while True:
+            line = self._file.readline().decode("latin-1").strip()
+            if not line:
+                break
+            name, _, value = line.partition(":")
+            headers[name.strip().lower()] = value.strip()
+        
+        code = int(status[1]) if len(status) > 1 and status[1].isdigit() else 0
+        if code != 101:
+            message = status[2].strip() if len(status) > 2 else "handshake failed"
+            length = int(headers.get("content-length", "0") or 0)
+            if length:
+                try:
+                    body = json.loads(self._file.read(length))
+                    message = body.get("message") or body.get("error") or message
+                except ValueError:
+                    pass
+            self.close()
+            raise CroniumAPIError(code, message)
+        
+        accept = base64.b64encode(hashlib.sha1((key + self._GUID).encode()).digest()).decode()
+        if headers.get("sec-websocket-accept") != accept:
+            self.close()
+            raise CroniumError("Message channel handshake failed")
+        
+        # Messages may be far apart; the runtime's pings keep the channel alive
+        sock.settimeout(None)
+    
+    def receive(self) -> Optional[Dict[str, Any]]:
+        """Return the next message, or None once the channel is closed."""
+        fragments = []
+        while True:
+            head = self._read(2)
+            fin, opcode = head[0] & 0x80, head[0] & 0x0F
+            length = head[1] & 0x7F
+            if length == 126:
+                length = struct.unpack("!H", self._read(2))[0]
+            elif length == 127:
+                length = struct.unpack("!Q", self._read(8))[0]
+            payload = self._read(length)
+            
+            if opcode == 0x8:
+                self._send(0x8, payload[:2])
+                return None
+            if opcode == 0x9:
+                self._send(0xA, payload)
+                continue
+            if opcode in (0x0, 0x1):
+                fragments.append(payload)
+                if fin:
+                    return json.loads(b"".join(fragments).decode())
+    
+    def _read(self, n: int) -> bytes:
+        data = self._file.read(n) if n else b""
+        if len(data) < n:
+            raise CroniumError("Message channel closed")
+        return data
+    
+    def _send(self, opcode: int, payload: bytes = b"") -> None:
+        # Client frames are masked; control frames are short
+        mask = os.urandom(4)
+        masked = bytes(b ^ mask[i % 4] for i, b in enumerate(payload))
+        self._sock.sendall(bytes([0x80 | opcode, 0x80 | len(payload)]) + mask + masked)
+    
+    def close(self) -> None:
+        try:
+            self._file.close()
+            self._sock.close()
+        except OSError:
+            pass
+
+
 class Cronium:
     """
     Main class for interacting with the Cronium Runtime API.
@@ -72,8 +283,56 @@ class Cronium:
         
         # SSL context for HTTPS
         self.ssl_context = ssl.create_default_context()
+        
+        # Cancellation
+        self.cancel_file = os.environ.get("CRONIUM_CANCEL_FILE")
+        self._cancel_requested = False
+        
+        # Operator messages are also appended to a file the executor writes
+        self._messages_file = _MessageFile(os.environ.get("CRONIUM_MESSAGES_FILE"))
+        self._seen_messages = set()
+        
+        # Helper call stats, logged at exit with CRONIUM_HELPER_STATS set
+        self._stats = _HelperStats()
+        if os.environ.get("CRONIUM_HELPER_STATS"):
+            atexit.register(self._log_helper_stats)
+    
+    def _operation(self, method: str, path: str) -> str:
+        """Name a request by its route, without the execution ID or keys."""
+        path = path.split("?", 1)[0].replace(self.execution_id, "{id}")
+        path = re.sub(r"/jobs/[^/]+", "/jobs/{jobId}", path)
+        return f"{method} " + re.sub(r"/(variables|scratch)/[^/]+", r"/\1/{key}", path)
     
     def _make_request(self, method: str, path: str, data: Any = None) -> Any:
+        """Make a request, counting it in the helper call stats."""
+        start = time.monotonic()
+        failed = False
+        try:
+            return self._send_request(method, path, data)
+        except Exception:
+            failed = True
+            raise
+        finally:
+            self._stats.record(self._operation(method, path), time.monotonic() - start, failed)
+    
+    def helper_stats(self) -> Dict[str, Dict[str, float]]:
+        """
+        Get the helper calls this script has made so far.
+        
+        Returns:
+            Per operation: calls, errors, totalMs, p50Ms and p95Ms
+        """
+        return self._stats.summary()
+    
+    def _log_helper_stats(self) -> None:
+        for operation, stats in sorted(self._stats.summary().items(), key=lambda item: -item[1]["totalMs"]):
+            print(
+                f"cronium: {operation}: {stats['calls']} calls ({stats['errors']} failed), "
+                f"{stats['totalMs']:.1f} ms total, p50 {stats['p50Ms']:.1f} ms, p95 {stats['p95Ms']:.1f} ms",
+                file=sys.stderr,
+            )
+    
+    def _send_request(self, method: str, path: str, data: Any = None) -> Any:
         """
         Make an HTTP request to the Runtime API with retry logic.
         
@@ -153,14 +412,54 @@ class Cronium:
         result = self._make_request("GET", f"/executions/{self.execution_id}/input")
         return result.get("data") if result else None
     
-    def output(self, data: Any) -> None:
+    def output(self, data: Any, render: Optional[Union[str, Dict[str, Any]]] = None) -> None:
         """
         Set output data for this execution.
         
         Args:
             data: The output data to store. Can be any JSON-serializable value.
+            render: How front-ends should display the output: "table", "json",
+                "markdown" or "metric", or a dict such as
+                {"type": "metric", "label": "Backlog", "unit": "jobs"}
+        """
+        self._make_request("POST", f"/executions/{self.execution_id}/output", _output_payload(data, render))
+    
+    def upload_file(self, path: str, name: Optional[str] = None,
+                    content_type: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Upload a file as an execution artifact.
+        
+        Args:
+            path: Path of the file to upload
+            name: Artifact name (defaults to the file's base name)
+            content_type: MIME type (detected by the runtime if omitted)
+            
+        Returns:
+            The registered artifact, including its id, size and mimeType
         """
-        self._make_request("POST", f"/executions/{self.execution_id}/output", {"data": data})
+        name = name or os.path.basename(path)
+        url = urljoin(self.api_url, f"/executions/{self.execution_id}/files?name={quote(name)}")
+        headers = dict(self.headers)
+        headers["Content-Type"] = content_type or "application/octet-stream"
+        headers["Content-Length"] = str(os.path.getsize(path))
+        
+        # The file is streamed from disk, so the upload is not retried
+        with open(path, "rb") as f:
+            req = Request(url, data=f, headers=headers, method="POST")
+            try:
+                response = urlopen(req, timeout=self.timeout, context=self.ssl_context)
+            except HTTPError as e:
+                error_body = e.read().decode("utf-8")
+                try:
+                    message = json.loads(error_body).get("message", str(e))
+                except json.JSONDecodeError:
+                    message = error_body or str(e)
+                raise CroniumAPIError(e.code, message)
+            except URLError as e:
+                raise CroniumError(f"File upload failed: {e}")
+        
+        result = json.loads(response.read().decode("utf-8"))
+        return result.get("data", {})
     
     def get_variable(self, key: str) -> Any:
         """
@@ -190,6 +489,167 @@ class Cronium:
         """
         self._make_request("PUT", f"/executions/{self.execution_id}/variables/{quote(key)}", {"value": value})
     
+    def increment_variable(self, key: str, by: float = 1) -> float:
+        """
+        Atomically add to a numeric variable.
+        
+        Unlike get_variable followed by set_variable, concurrent executions
+        cannot lose each other's increments. A missing variable counts as zero.
+        
+        Args:
+            key: The variable key to increment
+            by: The amount to add (may be negative)
+            
+        Returns:
+            The new value
+        """
+        result = self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/increment", {"by": by})
+        return result.get("data", {}).get("value")
+    
+    def append_to_list(self, key: str, value: Any) -> list:
+        """
+        Atomically append a value to a list variable.
+        
+        Args:
+            key: The variable key of the list
+            value: Any JSON-serializable value
+            
+        Returns:
+            The list after appending
+        """
+        result = self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/append", {"value": value})
+        return result.get("data", {}).get("value", [])
+    
+    def add_to_set(self, key: str, value: Any) -> bool:
+        """
+        Atomically add a value to a set variable, stored as a list without
+        duplicates.
+        
+        Args:
+            key: The variable key of the set
+            value: Any JSON-serializable value
+            
+        Returns:
+            True if the value was not in the set yet
+        """
+        result = self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/add-to-set", {"value": value})
+        return bool(result.get("data", {}).get("added"))
+    
+    def scratch_get(self, key: str) -> Any:
+        """
+        Get a scratch value kept for this execution only.
+        
+        Args:
+            key: The scratch key to retrieve
+            
+        Returns:
+            The value, or None if not set
+        """
+        result = self._make_request("GET", f"/executions/{self.execution_id}/scratch/{quote(key)}")
+        return result.get("data", {}).get("value") if result else None
+    
+    def scratch_set(self, key: str, value: Any) -> None:
+        """
+        Set a scratch value kept for this execution only.
+        
+        Scratch values suit transient state such as loop cursors or temporary
+        tokens: they are never stored as variables and expire with the
+        execution.
+        
+        Args:
+            key: The scratch key to set
+            value: Any JSON-serializable value; None removes the key
+        """
+        self._make_request("PUT", f"/executions/{self.execution_id}/scratch/{quote(key)}", {"value": value})
+    
+    def submit_job(self, event_id: Union[int, str], input: Any = None,
+                   metadata: Optional[Dict[str, Any]] = None,
+                   idempotency_key: Optional[str] = None) -> Dict[str, Any]:
+        """
+        Queue a child job that runs another event.
+        
+        The child runs as the same user and inherits this execution's
+        metadata, linked to it as its parent.
+        
+        Args:
+            event_id: The event the child job runs
+            input: Input data for the child job
+            metadata: Metadata added to the inherited metadata
+            idempotency_key: Submitting again with the same key returns the
+                same job instead of queueing another
+            
+        Returns:
+            The job, with its "id" and "status"
+        """
+        payload: Dict[str, Any] = {"eventId": str(event_id)}
+        if input is not None:
+            payload["input"] = input
+        if metadata:
+            payload["metadata"] = metadata
+        if idempotency_key:
+            payload["idempotencyKey"] = idempotency_key
+        result = self._make_request("POST", f"/executions/{self.execution_id}/jobs", payload)
+        return result.get("data", {}) if result else {}
+    
+    def get_job(self, job_id: str) -> Dict[str, Any]:
+        """
+        Get a child job's status, and its output once it has finished.
+        
+        Args:
+            job_id: The ID returned by submit_job
+            
+        Returns:
+            The job
+        """
+        result = self._make_request("GET", f"/executions/{self.execution_id}/jobs/{quote(job_id)}")
+        return result.get("data", {}) if result else {}
+    
+    def wait_for_job(self, job_id: str, timeout: Optional[float] = None, poll_interval: float = 2.0) -> Dict[str, Any]:
+        """
+        Wait until a child job has completed, failed or been cancelled.
+        
+        Args:
+            job_id: The ID returned by submit_job
+            timeout: Seconds to wait at most; no limit when omitted
+            poll_interval: Seconds between status checks
+            
+        Returns:
+            The finished job with its "status" and "output" or "error"
+            
+        Raises:
+            CroniumTimeoutError: If the job has not finished within timeout
+        """
+        deadline = time.monotonic() + timeout if timeout is not None else None
+        while True:
+            job = self.get_job(job_id)
+            if job.get("status") in _FINISHED_JOB_STATUSES:
+                return job
+            if deadline is not None and time.monotonic() + poll_interval > deadline:
+                raise CroniumTimeoutError(f"Job {job_id} did not finish within {timeout}s")
+            time.sleep(poll_interval)
+    
+    def get_credential(self, provider: str, role: str, ttl: Optional[int] = None) -> Dict[str, Any]:
+        """
+        Get short-lived credentials for a role configured in the runtime.
+        
+        The credentials are minted for this execution only and are revoked
+        or expire when it ends.
+        
+        Args:
+            provider: The credential provider, such as "aws" or "vault"
+            role: The role name
+            ttl: How long the credentials should last in seconds; the
+                runtime default when omitted
+            
+        Returns:
+            A dict with the provider's "values" and "expiresAt"
+        """
+        payload: Dict[str, Any] = {"provider": provider, "role": role}
+        if ttl:
+            payload["ttl"] = ttl
+        result = self._make_request("POST", f"/executions/{self.execution_id}/credentials", payload)
+        return result.get("data", {}) if result else {}
+    
     def set_condition(self, condition: bool) -> None:
         """
         Set the workflow condition for this execution.
@@ -285,6 +745,121 @@ class Cronium:
             **kwargs
         }
         return self.execute_tool_action("discord", "send_message", config)
+    
+    def cancelled(self) -> bool:
+        """
+        Check whether the execution has been cancelled.
+        
+        Long-running scripts can poll this and stop at a safe point.
+        """
+        if self._cancel_requested:
+            return True
+        return bool(self.cancel_file) and os.path.exists(self.cancel_file)
+    
+    def on_cancel(self, handler) -> None:
+        """
+        Register a cleanup handler to run when the execution is cancelled.
+        
+        The handler runs on SIGTERM/SIGINT, after which the script exits. It
+        has CRONIUM_CANCEL_GRACE_PERIOD seconds before the script is killed.
+        
+        Args:
+            handler: Callable taking no arguments
+        """
+        def _handle(signum, frame):
+            self._cancel_requested = True
+            try:
+                handler()
+            finally:
+                raise SystemExit(128 + signum)
+        
+        signal.signal(signal.SIGTERM, _handle)
+        signal.signal(signal.SIGINT, _handle)
+    
+    def messages(self) -> Iterator[Dict[str, Any]]:
+        """
+        Receive push messages from the runtime as they happen.
+        
+        Yields dicts with a "type" of "cancel" (the job is being cancelled),
+        "variable" (one of the user's variables changed; read it with
+        get_variable) or "message" (sent by an operator). Operator messages
+        sent earlier are read first from CRONIUM_MESSAGES_FILE, and each is
+        yielded once per client even across calls. Blocks between messages
+        and returns when the channel closes. When the runtime channel cannot
+        be opened, the messages file is followed instead.
+        """
+        try:
+            channel = _MessageChannel(
+                urljoin(self.api_url, f"/executions/{self.execution_id}/messages"),
+                self.token, self.ssl_context, self.timeout,
+            )
+        except (OSError, CroniumError) as e:
+            if not self._messages_file.path:
+                raise
+            logger.debug(f"Message channel unavailable, following the messages file: {e}")
+            while True:
+                yield from self._unseen(self._messages_file.read())
+                time.sleep(0.5)
+        
+        try:
+            # Read the file after opening the channel so nothing falls between
+            yield from self._unseen(self._messages_file.read())
+            while True:
+                message = channel.receive()
+                if message is None:
+                    return
+                if message.get("type") == "cancel":
+                    self._cancel_requested = True
+                yield from self._unseen([message])
+        finally:
+            channel.close()
+    
+    def _unseen(self, messages: list) -> Iterator[Dict[str, Any]]:
+        """Drop operator messages already yielded, by their ID."""
+        for message in messages:
+            message_id = message.get("id")
+            if message_id:
+                if message_id in self._seen_messages:
+                    continue
+                self._seen_messages.add(message_id)
+            yield message
+    
+    def on_message(self, handler) -> threading.Thread:
+        """
+        Call a handler for every push message in a background thread.
+        
+        The channel is reopened when it drops. A cancel message also makes
+        cancelled() return True, so polling scripts stop early.
+        
+        Args:
+            handler: Callable taking the message dict
+        
+        Returns:
+            The daemon thread receiving the messages
+        """
+        def _listen():
+            delay = self.retry_delay
+            while True:
+                try:
+                    for message in self.messages():
+                        delay = self.retry_delay
+                        try:
+                            handler(message)
+                        except Exception:
+                            logger.exception("Message handler failed")
+                except CroniumAPIError as e:
+                    if e.status_code in (401, 403, 404):
+                        logger.warning(f"Message channel unavailable: {e}")
+                        return
+                    logger.debug(f"Message channel failed: {e}")
+                except (OSError, CroniumError) as e:
+                    logger.debug(f"Message channel dropped: {e}")
+                time.sleep(delay)
+                delay = min(delay * 2, 30.0)
+        
+        thread = threading.Thread(target=_listen, name="cronium-messages", daemon=True)
+        thread.start()
+        return thread
 
 
 # Async support for advanced use cases
@@ -310,6 +885,18 @@ class AsyncCronium(Cronium):
             )
     
     async def _make_request(self, method: str, path: str, data: Any = None) -> Any:
+        """Make an async request, counting it in the helper call stats."""
+        start = time.monotonic()
+        failed = False
+        try:
+            return await self._send_request(method, path, data)
+        except Exception:
+            failed = True
+            raise
+        finally:
+            self._stats.record(self._operation(method, path), time.monotonic() - start, failed)
+    
+    async def _send_request(self, method: str, path: str, data: Any = None) -> Any:
         """Make an async HTTP request."""
         await self._ensure_session()
         url = urljoin(self.api_url, path)
@@ -362,8 +949,8 @@ class AsyncCronium(Cronium):
         result = await self._make_request("GET", f"/executions/{self.execution_id}/input")
         return result.get("data") if result else None
     
-    async def output(self, data: Any) -> None:
-        await self._make_request("POST", f"/executions/{self.execution_id}/output", {"data": data})
+    async def output(self, data: Any, render: Optional[Union[str, Dict[str, Any]]] = None) -> None:
+        await self._make_request("POST", f"/executions/{self.execution_id}/output", _output_payload(data, render))
     
     async def get_variable(self, key: str) -> Any:
         try:
@@ -377,6 +964,59 @@ class AsyncCronium(Cronium):
     async def set_variable(self, key: str, value: Any) -> None:
         await self._make_request("PUT", f"/executions/{self.execution_id}/variables/{quote(key)}", {"value": value})
     
+    async def increment_variable(self, key: str, by: float = 1) -> float:
+        result = await self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/increment", {"by": by})
+        return result.get("data", {}).get("value")
+    
+    async def append_to_list(self, key: str, value: Any) -> list:
+        result = await self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/append", {"value": value})
+        return result.get("data", {}).get("value", [])
+    
+    async def add_to_set(self, key: str, value: Any) -> bool:
+        result = await self._make_request("POST", f"/executions/{self.execution_id}/variables/{quote(key)}/add-to-set", {"value": value})
+        return bool(result.get("data", {}).get("added"))
+    
+    async def scratch_get(self, key: str) -> Any:
+        result = await self._make_request("GET", f"/executions/{self.execution_id}/scratch/{quote(key)}")
+        return result.get("data", {}).get("value") if result else None
+    
+    async def scratch_set(self, key: str, value: Any) -> None:
+        await self._make_request("PUT", f"/executions/{self.execution_id}/scratch/{quote(key)}", {"value": value})
+    
+    async def submit_job(self, event_id: Union[int, str], input: Any = None,
+                         metadata: Optional[Dict[str, Any]] = None,
+                         idempotency_key: Optional[str] = None) -> Dict[str, Any]:
+        payload: Dict[str, Any] = {"eventId": str(event_id)}
+        if input is not None:
+            payload["input"] = input
+        if metadata:
+            payload["metadata"] = metadata
+        if idempotency_key:
+            payload["idempotencyKey"] = idempotency_key
+        result = await self._make_request("POST", f"/executions/{self.execution_id}/jobs", payload)
+        return result.get("data", {}) if result else {}
+    
+    async def get_job(self, job_id: str) -> Dict[str, Any]:
+        result = await self._make_request("GET", f"/executions/{self.execution_id}/jobs/{quote(job_id)}")
+        return result.get("data", {}) if result else {}
+    
+    async def wait_for_job(self, job_id: str, timeout: Optional[float] = None, poll_interval: float = 2.0) -> Dict[str, Any]:
+        deadline = time.monotonic() + timeout if timeout is not None else None
+        while True:
+            job = await self.get_job(job_id)
+            if job.get("status") in _FINISHED_JOB_STATUSES:
+                return job
+            if deadline is not None and time.monotonic() + poll_interval > deadline:
+                raise CroniumTimeoutError(f"Job {job_id} did not finish within {timeout}s")
+            await asyncio.sleep(poll_interval)
+    
+    async def get_credential(self, provider: str, role: str, ttl: Optional[int] = None) -> Dict[str, Any]:
+        payload: Dict[str, Any] = {"provider": provider, "role": role}
+        if ttl:
+            payload["ttl"] = ttl
+        result = await self._make_request("POST", f"/executions/{self.execution_id}/credentials", payload)
+        return result.get("data", {}) if result else {}
+    
     async def set_condition(self, condition: bool) -> None:
         await self._make_request("POST", f"/executions/{self.execution_id}/condition", {"condition": condition})
     
@@ -425,11 +1065,26 @@ cronium = Cronium()
 # For backward compatibility and convenience
 input = cronium.input
 output = cronium.output
+upload_file = cronium.upload_file
 get_variable = cronium.get_variable
 set_variable = cronium.set_variable
+increment_variable = cronium.increment_variable
+append_to_list = cronium.append_to_list
+add_to_set = cronium.add_to_set
+scratch_get = cronium.scratch_get
+scratch_set = cronium.scratch_set
+submit_job = cronium.submit_job
+get_job = cronium.get_job
+wait_for_job = cronium.wait_for_job
+get_credential = cronium.get_credential
 set_condition = cronium.set_condition
 event = cronium.event
 execute_tool_action = cronium.execute_tool_action
 send_email = cronium.send_email
 send_slack_message = cronium.send_slack_message
-send_discord_message = cronium.send_discord_message
\ No newline at end of file
+send_discord_message = cronium.send_discord_message
+cancelled = cronium.cancelled
+on_cancel = cronium.on_cancel
+messages = cronium.messages
+on_message = cronium.on_message
+helper_stats = cronium.helper_stats
\ No newline at end of file
@@ -211,6 +211,70 @@ class TestCronium:
         assert payload["config"]["to"] == ["test@example.com"]
         assert payload["config"]["cc"] == ["cc@example.com"]
     
+    def test_messages_over_websocket(self):
+        """Test receiving push messages and answering pings"""
+        import base64, hashlib, socket, threading
+        server = socket.socket()
+        server.bind(("127.0.0.1", 0))
+        server.listen(1)
+        received = {}
+        
+        def serve():
+            conn, _ = server.accept()
+            f = conn.makefile("rb")
+            request = b""
+            while not request.endswith(b"\r\n\r\n"):
+                request += f.read(1)
+            received["request"] = request.decode()
+            key = [l.split(": ")[1] for l in received["request"].split("\r\n") if l.startswith("Sec-WebSocket-Key")][0]
+            accept = base64.b64encode(hashlib.sha1((key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11").encode()).digest()).decode()
+            conn.sendall(f"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: {accept}\r\n\r\n".encode())
+            conn.sendall(b"\x89\x02hi")
+            pong = f.read(8)
+            mask = pong[2:6]
+            received["pong"] = bytes(b ^ mask[i % 4] for i, b in enumerate(pong[6:]))
+            body = json.dumps({"type": "message", "message": "x" * 200}).encode()
+            conn.sendall(b"\x81\x7e" + len(body).to_bytes(2, "big") + body)
+            body = json.dumps({"type": "cancel", "reason": "stop"}).encode()
+            conn.sendall(b"\x81" + bytes([len(body)]) + body)
+            conn.sendall(b"\x88\x02\x03\xe8")
+            f.read(8)
+            conn.close()
+        
+        thread = threading.Thread(target=serve)
+        thread.start()
+        self.client.api_url = "http://127.0.0.1:%d" % server.getsockname()[1]
+        messages = list(self.client.messages())
+        thread.join()
+        server.close()
+        
+        assert "GET /executions/test-execution-id/messages HTTP/1.1" in received["request"]
+        assert "Authorization: Bearer test-token" in received["request"]
+        assert received["pong"] == b"hi"
+        assert [m["type"] for m in messages] == ["message", "cancel"]
+        assert len(messages[0]["message"]) == 200
+        assert self.client.cancelled()
+    
+    def test_messages_file_fallback(self, tmp_path):
+        """Test following the messages file when the channel is unavailable"""
+        import itertools, socket
+        path = tmp_path / "messages"
+        path.write_text(
+            '{"type": "message", "id": "m1", "message": "confirm"}\n'
+            '{"type": "message", "id": "m1", "message": "confirm"}\n'
+            '{"type": "message", "id": "m2", "data": {"batchSize": 50}}\n'
+            '{"type": "mess'
+        )
+        closed = socket.socket()
+        closed.bind(("127.0.0.1", 0))
+        self.client.api_url = "http://127.0.0.1:%d" % closed.getsockname()[1]
+        closed.close()
+        self.client._messages_file = cronium._MessageFile(str(path))
+        
+        messages = list(itertools.islice(self.client.messages(), 2))
+        assert [m["id"] for m in messages] == ["m1", "m2"]
+        assert messages[1]["data"] == {"batchSize": 50}
+    
     def test_missing_token_error(self):
         """Test error when token is missing"""
         del os.environ["CRONIUM_EXECUTION_TOKEN"]